@@ -144,10 +144,10 @@ class ApiClient(
             httpClient.newCall(request).execute().use { response ->
                 val body = response.body?.string().orEmpty()
                 if (!response.isSuccessful) {
-                    val message = runCatching {
+                    val detail = runCatching {
                         CentaurxJson.decodeFromString(ErrorResponse.serializer(), body).error
-                    }.getOrNull() ?: "request failed"
-                    throw ApiException(message, response.code)
+                    }.getOrNull() ?: ErrorDetail()
+                    throw ApiException(detail.message, response.code, detail.code)
                 }
                 if (T::class == Unit::class) {
                     @Suppress("UNCHECKED_CAST")
@@ -163,4 +163,8 @@ class ApiClient(
     }
 }
 
-class ApiException(message: String, val statusCode: Int? = null) : Exception(message)
+class ApiException(
+    message: String,
+    val statusCode: Int? = null,
+    val code: String? = null,
+) : Exception(message)
@@ -42,6 +42,12 @@ data class TabSnapshot(
     val status: TabStatus? = null,
     @JsonNames("active", "Active")
     val active: Boolean = false,
+    @JsonNames("ephemeral", "Ephemeral")
+    val ephemeral: Boolean = false,
+    @JsonNames("repo_url", "RepoURL")
+    val repoUrl: String? = null,
+    @JsonNames("last_activity_at", "LastActivityAt")
+    val lastActivityAt: String? = null,
 )
 
 @Serializable
@@ -117,7 +123,15 @@ data class LoginResponse(
 @Serializable
 data class ErrorResponse(
     @SerialName("error")
-    val error: String = "request failed",
+    val error: ErrorDetail = ErrorDetail(),
+)
+
+@Serializable
+data class ErrorDetail(
+    @SerialName("code")
+    val code: String = "internal",
+    @SerialName("message")
+    val message: String = "request failed",
 )
 
 @Serializable
@@ -99,7 +99,7 @@ fun TerminalScreen(state: UiState, viewModel: AppViewModel) {
     val lines = when {
         !activeTab.isNullOrBlank() -> state.buffers[activeTab].orEmpty()
         state.systemLines.isNotEmpty() -> state.systemLines
-        else -> listOf("no active tab; use /new <repo>")
+        else -> listOf("no active tab — open one with /new <repo>")
     }
 
     fun sendPrompt() {
@@ -376,7 +376,7 @@ private fun TabsRow(
         ) {
             itemsIndexed(tabs) { _, tab ->
                 val isActive = tab.id == activeTabId
-                val label = tab.name ?: tab.id
+                val label = (if (tab.ephemeral) "~" else "") + (tab.name ?: tab.id)
                 Surface(
                     shape = RoundedCornerShape(6.dp),
                     border = BorderStroke(
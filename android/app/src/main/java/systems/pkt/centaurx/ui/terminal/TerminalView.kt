@@ -121,9 +121,25 @@ private fun TerminalLine(line: String, baseStyle: TextStyle) {
         LineKind.AboutCopyright -> extras.aboutCopyright
         LineKind.AboutLink -> extras.aboutLink
         LineKind.AboutVersion -> MaterialTheme.colorScheme.onSurface
+        LineKind.Field -> MaterialTheme.colorScheme.onSurface
         LineKind.Normal -> MaterialTheme.colorScheme.onSurface
     }
 
+    if (parsed.kind == LineKind.Field) {
+        // The value wraps in its own column so continuation rows stay
+        // aligned under it.
+        Row {
+            Text(text = parsed.label + " ", color = baseColor, style = baseStyle, softWrap = false)
+            Text(
+                text = parsed.text.ifEmpty { "\u00a0" },
+                color = baseColor,
+                style = baseStyle,
+                modifier = Modifier.weight(1f),
+            )
+        }
+        return
+    }
+
     if (parsed.kind == LineKind.Worked) {
         Row(verticalAlignment = Alignment.CenterVertically) {
             Text(
@@ -208,6 +224,7 @@ private fun buildAnnotatedString(
 
 private const val COMMAND_MARKER = '\u001a'
 private const val AGENT_MARKER = '\u001c'
+private const val FINAL_AGENT_MARKER = '\u0015'
 private const val REASONING_MARKER = '\u001d'
 private const val WORKED_MARKER = '\u001e'
 private const val STDERR_MARKER = '\u001f'
@@ -215,6 +232,7 @@ private const val HELP_MARKER = '\u0016'
 private const val ABOUT_VERSION_MARKER = '\u0017'
 private const val ABOUT_COPYRIGHT_MARKER = '\u0018'
 private const val ABOUT_LINK_MARKER = '\u0019'
+private const val FIELD_MARKER = '\u0014'
 
 private fun parseLine(line: String): ParsedLine {
     var text = line
@@ -233,7 +251,7 @@ private fun parseLine(line: String): ParsedLine {
     if (text.startsWith(ABOUT_LINK_MARKER)) {
         return ParsedLine(text = text.drop(1), kind = LineKind.AboutLink)
     }
-    if (text.startsWith(AGENT_MARKER)) {
+    if (text.startsWith(AGENT_MARKER) || text.startsWith(FINAL_AGENT_MARKER)) {
         return ParsedLine(text = text.drop(1), kind = LineKind.Agent, markdown = true)
     }
     if (text.startsWith(REASONING_MARKER)) {
@@ -242,6 +260,14 @@ private fun parseLine(line: String): ParsedLine {
     if (text.startsWith(COMMAND_MARKER)) {
         return ParsedLine(text = text.drop(1), kind = LineKind.Command)
     }
+    if (text.startsWith(FIELD_MARKER)) {
+        val body = text.drop(1)
+        return ParsedLine(
+            text = body.substringAfter('\t', ""),
+            kind = LineKind.Field,
+            label = body.substringBefore('\t'),
+        )
+    }
     var stderr = false
     if (text.startsWith(STDERR_MARKER)) {
         stderr = true
@@ -260,10 +286,12 @@ private data class ParsedLine(
     val text: String,
     val kind: LineKind,
     val markdown: Boolean = false,
+    val label: String = "",
 )
 
 private enum class LineKind {
     Normal,
+    Field,
     Command,
     Reasoning,
     Agent,
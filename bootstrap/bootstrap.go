@@ -26,6 +26,8 @@ type Files struct {
 	PodmanYAML            []byte
 	CentaurxContainerfile []byte
 	RunnerContainerfile   []byte
+	// SampleTheme is an example custom theme for the themes directory.
+	SampleTheme []byte
 }
 
 // Assets defines optional bootstrap assets to emit alongside files.
@@ -35,10 +37,22 @@ type Assets struct {
 }
 
 // Options controls optional bootstrap behaviors.
+// Zero values keep the defaults from appconfig.DefaultConfig.
 type Options struct {
 	SeedUsers       bool
 	Overrides       []ConfigOverride
 	Redistributable bool
+	// HTTPPort and SSHPort set the listen and published ports.
+	HTTPPort int
+	SSHPort  int
+	// RepoRoot and StateDir are host paths mounted into the server container.
+	RepoRoot       string
+	StateDir       string
+	ContainerScope string
+	CPUPercent     int
+	MemoryPercent  int
+	AllowedModels  []string
+	DefaultModel   string
 }
 
 // BundlePaths lists output locations for generated artifacts.
@@ -50,6 +64,7 @@ type BundlePaths struct {
 	RunnerContainerfile   string
 	RunnerInstallScript   string
 	SkelDir               string
+	SampleThemePath       string
 }
 
 // Paths reports where bootstrap wrote its outputs.
@@ -63,6 +78,7 @@ type Paths struct {
 const (
 	containerConfigName       = "config-for-container.yaml"
 	runnerInstallRel          = "files/cxrunner-install.sh"
+	sampleThemeRel            = "themes/example.yaml.sample"
 	composeEnvName            = ".env"
 	defaultServerImage        = "docker.io/pktsystems/centaurx"
 	defaultRunnerImage        = "docker.io/pktsystems/centaurxrunner"
@@ -99,13 +115,22 @@ type templateData struct {
 	HostRepoDir       string
 	HostPodmanSock    string
 	ServerImage       string
+	HTTPPort          int
+	SSHPort           int
 }
 
 func baseConfig(opts Options, imageTag string) (appconfig.Config, error) {
+	if err := opts.Validate(); err != nil {
+		return appconfig.Config{}, err
+	}
 	cfg, err := appconfig.DefaultConfig()
 	if err != nil {
 		return appconfig.Config{}, err
 	}
+	if repoRoot := strings.TrimSpace(opts.RepoRoot); repoRoot != "" {
+		cfg.RepoRoot = repoRoot
+	}
+	cfg = rebaseStateDir(cfg, opts.StateDir)
 	if opts.SeedUsers {
 		cfg.Auth.SeedUsers = appconfig.DefaultSeedUsers()
 	}
@@ -124,7 +149,7 @@ func baseConfig(opts Options, imageTag string) (appconfig.Config, error) {
 	if cfg.Runner.CommandNice == 0 {
 		cfg.Runner.CommandNice = 5
 	}
-	return cfg, nil
+	return applyOptions(cfg, opts), nil
 }
 
 func applyHostPaths(cfg appconfig.Config) appconfig.Config {
@@ -147,6 +172,7 @@ func applyContainerPaths(cfg appconfig.Config, hostRepoRoot, hostStateDir string
 	cfg.SSH.KeyDir = "/cx/state/ssh/keys"
 	cfg.SSH.AgentDir = "/cx/state/ssh/agent"
 	cfg.Auth.UserFile = "/cx/state/users.json"
+	cfg.Auth.LockoutFile = "/cx/state/lockouts.json"
 	cfg.HTTP.SessionStorePath = "/cx/state/sessions.json"
 	return cfg
 }
@@ -164,10 +190,11 @@ func DefaultFilesWithOptions(opts Options) (Files, *Assets, error) {
 	}
 	hostCfg := applyHostPaths(baseCfg)
 	containerCfg := applyContainerPaths(baseCfg, hostCfg.RepoRoot, hostCfg.StateDir)
-	configYAML, err := yaml.Marshal(containerCfg)
+	configYAML, err := marshalConfig(containerCfg)
 	if err != nil {
 		return Files{}, nil, err
 	}
+	httpPort, sshPort := opts.ports()
 	tplData := templateData{
 		ConfigFile:        containerConfigName,
 		RunnerInstallPath: runnerInstallRel,
@@ -176,6 +203,8 @@ func DefaultFilesWithOptions(opts Options) (Files, *Assets, error) {
 		HostRepoDir:       hostCfg.RepoRoot,
 		HostPodmanSock:    defaultPodmanSockPath(),
 		ServerImage:       tagImage(defaultServerImage, resolveImageTag("")),
+		HTTPPort:          httpPort,
+		SSHPort:           sshPort,
 	}
 	composeYAML, err := renderComposeYAML(tplData)
 	if err != nil {
@@ -204,6 +233,7 @@ func DefaultFilesWithOptions(opts Options) (Files, *Assets, error) {
 		PodmanYAML:            podmanYAML,
 		CentaurxContainerfile: centaurxFile,
 		RunnerContainerfile:   runnerFile,
+		SampleTheme:           sshserver.SampleThemeYAML(),
 	}
 	return files, &Assets{InstallScript: runnerScript, IncludeSkel: true}, nil
 }
@@ -219,19 +249,30 @@ func DefaultRepoBundleWithOptions(opts Options) (Files, *Assets, error) {
 	if err != nil {
 		return Files{}, nil, err
 	}
-	containerCfg := applyContainerPaths(baseCfg, defaultHostRepoTemplate, defaultHostStateTemplate)
-	configYAML, err := yaml.Marshal(containerCfg)
+	hostRepoDir := defaultHostRepoTemplate
+	if value := strings.TrimSpace(opts.RepoRoot); value != "" {
+		hostRepoDir = value
+	}
+	hostStateDir := defaultHostStateTemplate
+	if value := strings.TrimSpace(opts.StateDir); value != "" {
+		hostStateDir = value
+	}
+	containerCfg := applyContainerPaths(baseCfg, hostRepoDir, hostStateDir)
+	configYAML, err := marshalConfig(containerCfg)
 	if err != nil {
 		return Files{}, nil, err
 	}
+	httpPort, sshPort := opts.ports()
 	tplData := templateData{
 		ConfigFile:        containerConfigName,
 		RunnerInstallPath: runnerInstallRel,
 		HostConfigPath:    defaultHostConfigTemplate,
-		HostStateDir:      defaultHostStateTemplate,
-		HostRepoDir:       defaultHostRepoTemplate,
+		HostStateDir:      hostStateDir,
+		HostRepoDir:       hostRepoDir,
 		HostPodmanSock:    defaultPodmanSockTemplate,
 		ServerImage:       tagImage(defaultServerImage, resolveImageTag("")),
+		HTTPPort:          httpPort,
+		SSHPort:           sshPort,
 	}
 	composeYAML, err := renderComposeYAML(tplData)
 	if err != nil {
@@ -255,6 +296,7 @@ func DefaultRepoBundleWithOptions(opts Options) (Files, *Assets, error) {
 		PodmanYAML:            podmanYAML,
 		CentaurxContainerfile: centaurxFile,
 		RunnerContainerfile:   runnerFile,
+		SampleTheme:           sshserver.SampleThemeYAML(),
 	}, nil, nil
 }
 
@@ -264,7 +306,103 @@ func DefaultHostConfigYAML() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return yaml.Marshal(cfg)
+	return marshalConfig(cfg)
+}
+
+// configComments documents config keys in generated config files, keyed by
+// their dotted path.
+var configComments = map[string]string{
+	"ssh.host_keys": "More host key files served next to host_key_path, so clients can pick the\n" +
+		"algorithm they prefer. Relative paths are resolved under state_dir/ssh.\n" +
+		"Missing keys are generated at first start with mode 0600: RSA when the file\n" +
+		"name contains \"rsa\", ed25519 otherwise. Fingerprints are logged at start\n" +
+		"and printed by `centaurx doctor`. Example:\n" +
+		"  host_keys: [ssh_host_rsa_key]",
+	"http.trusted_proxies": "Reverse proxies (IPs or CIDRs) whose X-Forwarded-For header names the\n" +
+		"client. Other requests are attributed to their peer address, which is\n" +
+		"also what failed login throttling is keyed on. Example:\n" +
+		"  trusted_proxies: [127.0.0.1, 10.0.0.0/8]",
+	"auth.oidc.users": "Maps username_claim values to centaurx users; only mapped values can log\n" +
+		"in unless allow_unmapped is true. The email claim also needs\n" +
+		"email_verified. Example:\n" +
+		"  users: [{claim: 248289761001, user: alice}]",
+	"auth.oidc.allow_unmapped": "Let unmapped claim values log in as the user of the same name. Values\n" +
+		"naming a local account are still rejected.",
+	"service.onboarding": "New users get a getting started checklist in the system buffer (codex auth,\n" +
+		"git key, first repo) until they finish it or run /onboarding off. false\n" +
+		"hides it for everyone.",
+	"runner.codex_flags": "Extra codex exec flags for every run; codex_flags_by_model adds flags for\n" +
+		"runs with one model. Flags centaurx sets itself (--json, --model, resume)\n" +
+		"are rejected. Example:\n" +
+		"  codex_flags: [\"--sandbox\", \"workspace-write\"]\n" +
+		"  codex_flags_by_model:\n" +
+		"    - model: gpt-5.1-codex-mini\n" +
+		"      flags: [\"-c\", \"tools.web_search=false\"]",
+	"runner.extra_mounts": "Extra host directories mounted into every runner container, for repos kept\n" +
+		"outside repo_root or shared data. host must exist and be the same path for\n" +
+		"centaurx and the container runtime; container must be absolute and clear of\n" +
+		"system and centaurx paths. Admins add per-user mounts with `centaurx users\n" +
+		"set-mounts`. Changes apply when a container is recreated. Prompts in repos\n" +
+		"outside repo_root and every mount fail with a \"not mounted into the runner\"\n" +
+		"error. Example:\n" +
+		"  extra_mounts:\n" +
+		"    - host: /srv/projects\n" +
+		"      container: /projects\n" +
+		"      read_only: false",
+	"runner.log_capture_bytes": "Runner container output kept in memory for startup checks and log tails\n" +
+		"(containerd only): log_capture_bytes per container and stream, and\n" +
+		"log_capture_total_bytes for all containers together (0 = no cap). Over the\n" +
+		"cap, the least recently used captures of containers older than two minutes\n" +
+		"are shrunk or dropped first. `centaurx debug logcapture` shows the usage.",
+	"runner.stream_reattach_attempts": "How often a codex run whose event stream broke, such as when the connection\n" +
+		"to the runner was reset, is re-attached before the tab gives up on it and\n" +
+		"shows the stream error (0 = never). The runner keeps a run going for 30s\n" +
+		"after its stream broke and replays the events the server missed.",
+	"runner.network": "Egress control, off by default. When allowed_hosts lists \"host:port\" or CIDR\n" +
+		"entries, runner containers run without a network and reach only those\n" +
+		"destinations through a filtering proxy; git over ssh is tunneled through it\n" +
+		"too. Refused destinations show up in the tab as\n" +
+		"\"network access to <host:port> denied by policy\". Example:\n" +
+		"  allowed_hosts: [\"git.example.com:22\", \"api.openai.com:443\", \"10.0.0.0/8\"]",
+}
+
+// marshalConfig renders cfg as YAML with configComments attached.
+func marshalConfig(cfg appconfig.Config) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	for path, comment := range configComments {
+		if key := findKeyNode(&node, strings.Split(path, ".")); key != nil {
+			key.HeadComment = comment
+		}
+	}
+	return yaml.Marshal(&node)
+}
+
+// findKeyNode returns the key node at path in a mapping node.
+func findKeyNode(node *yaml.Node, path []string) *yaml.Node {
+	for i, name := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value != name {
+				continue
+			}
+			if i == len(path)-1 {
+				return node.Content[j]
+			}
+			next = node.Content[j+1]
+			break
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return nil
 }
 
 // DefaultHostConfig returns a host-oriented config.
@@ -295,11 +433,18 @@ func WriteFilesWithAssets(outputDir string, files Files, assets *Assets, overwri
 	runnerFile := filepath.Join(outputDir, "Containerfile.cxrunner")
 	runnerInstall := filepath.Join(outputDir, runnerInstallRel)
 	skelDir := filepath.Join(outputDir, "files", "skel")
+	sampleTheme := ""
+	if len(files.SampleTheme) > 0 {
+		sampleTheme = filepath.Join(outputDir, sampleThemeRel)
+	}
 
 	pathsToCheck := []string{configPath, composePath, podmanPath, centaurxFile, runnerFile}
 	if includeAssets {
 		pathsToCheck = append(pathsToCheck, runnerInstall)
 	}
+	if sampleTheme != "" {
+		pathsToCheck = append(pathsToCheck, sampleTheme)
+	}
 	for _, path := range pathsToCheck {
 		if !overwrite {
 			if _, err := os.Stat(path); err == nil {
@@ -342,6 +487,8 @@ func WriteFilesWithAssets(outputDir string, files Files, assets *Assets, overwri
 			HostRepoDir:    repoDir,
 			HostPodmanSock: defaultPodmanSockPath(),
 			ServerImage:    tagImage(defaultServerImage, resolveImageTag("")),
+			HTTPPort:       defaultHTTPPort,
+			SSHPort:        defaultSSHPort,
 		})
 		if err != nil {
 			return BundlePaths{}, err
@@ -357,6 +504,14 @@ func WriteFilesWithAssets(outputDir string, files Files, assets *Assets, overwri
 	if err := os.WriteFile(runnerFile, files.RunnerContainerfile, 0o644); err != nil {
 		return BundlePaths{}, err
 	}
+	if sampleTheme != "" {
+		if err := os.MkdirAll(filepath.Dir(sampleTheme), 0o755); err != nil {
+			return BundlePaths{}, err
+		}
+		if err := os.WriteFile(sampleTheme, files.SampleTheme, 0o644); err != nil {
+			return BundlePaths{}, err
+		}
+	}
 	if includeAssets {
 		if err := os.WriteFile(runnerInstall, assets.InstallScript, 0o755); err != nil {
 			return BundlePaths{}, err
@@ -381,6 +536,7 @@ func WriteFilesWithAssets(outputDir string, files Files, assets *Assets, overwri
 		RunnerContainerfile:   runnerFile,
 		RunnerInstallScript:   runnerInstall,
 		SkelDir:               skelDir,
+		SampleThemePath:       sampleTheme,
 	}, nil
 }
 
@@ -391,51 +547,155 @@ func WriteBootstrap(outputDir string, overwrite bool, imageTag string) (Paths, e
 
 // WriteBootstrapWithOptions writes host config plus container bundle outputs.
 func WriteBootstrapWithOptions(outputDir string, overwrite bool, imageTag string, opts Options) (Paths, error) {
-	hostCfg, err := DefaultHostConfig()
+	plan, err := planBootstrap(outputDir, imageTag, opts)
 	if err != nil {
 		return Paths{}, err
 	}
-	if opts.SeedUsers {
-		hostCfg.Auth.SeedUsers = appconfig.DefaultSeedUsers()
+	if !overwrite {
+		if _, err := os.Stat(plan.hostPath); err == nil {
+			return Paths{}, fmt.Errorf("file already exists: %s", plan.hostPath)
+		}
+	}
+	paths, err := WriteFilesWithAssets(outputDir, plan.bundle, plan.assets, overwrite)
+	if err != nil {
+		return Paths{}, err
+	}
+	envPath, err := writeComposeEnv(outputDir, overwrite)
+	if err != nil {
+		return Paths{}, err
+	}
+	if err := ensureHostAssets(plan.hostCfg); err != nil {
+		return Paths{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(plan.hostPath), 0o755); err != nil {
+		return Paths{}, err
+	}
+	if err := os.WriteFile(plan.hostPath, plan.hostYAML, 0o600); err != nil {
+		return Paths{}, err
+	}
+	if err := ensureSeedHomes(plan.hostCfg, paths.SkelDir); err != nil {
+		return Paths{}, err
+	}
+	return Paths{
+		HostConfigPath: plan.hostPath,
+		Bundle:         paths,
+		EnvPath:        envPath,
+		BinPath:        "",
+	}, nil
+}
+
+// DryRunBootstrap prints the files WriteBootstrapWithOptions would write to w
+// without touching the filesystem.
+func DryRunBootstrap(w io.Writer, outputDir string, imageTag string, opts Options) error {
+	plan, err := planBootstrap(outputDir, imageTag, opts)
+	if err != nil {
+		return err
+	}
+	if err := writeDryRunFile(w, plan.hostPath, plan.hostYAML); err != nil {
+		return err
+	}
+	if err := DryRunFiles(w, outputDir, plan.bundle); err != nil {
+		return err
+	}
+	env := fmt.Sprintf("UID=%d\nGID=%d\n", os.Getuid(), os.Getgid())
+	return writeDryRunFile(w, filepath.Join(outputDir, composeEnvName), []byte(env))
+}
+
+// DryRunFiles prints the bundle files WriteFilesWithAssets would write to w.
+func DryRunFiles(w io.Writer, outputDir string, files Files) error {
+	entries := []struct {
+		name string
+		data []byte
+	}{
+		{containerConfigName, files.ConfigYAML},
+		{"docker-compose.yaml", files.ComposeYAML},
+		{"podman.yaml", files.PodmanYAML},
+		{"Containerfile.centaurx", files.CentaurxContainerfile},
+		{"Containerfile.cxrunner", files.RunnerContainerfile},
+	}
+	if len(files.SampleTheme) > 0 {
+		entries = append(entries, struct {
+			name string
+			data []byte
+		}{sampleThemeRel, files.SampleTheme})
+	}
+	for _, entry := range entries {
+		if err := writeDryRunFile(w, filepath.Join(outputDir, entry.name), entry.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDryRunFile(w io.Writer, path string, data []byte) error {
+	if _, err := fmt.Fprintf(w, "==> %s <==\n", path); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
 	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// bootstrapPlan holds fully rendered bootstrap outputs prior to writing.
+type bootstrapPlan struct {
+	hostCfg  appconfig.Config
+	hostPath string
+	hostYAML []byte
+	bundle   Files
+	assets   *Assets
+}
+
+func planBootstrap(outputDir string, imageTag string, opts Options) (bootstrapPlan, error) {
+	hostCfg, err := baseConfig(opts, imageTag)
+	if err != nil {
+		return bootstrapPlan{}, err
+	}
+	hostCfg = applyHostPaths(hostCfg)
 	runnerTag := resolveRunnerTag(imageTag, opts.Redistributable)
 	serverTag := resolveImageTag(imageTag)
-	hostCfg.Runner.Image = tagImage(defaultRunnerImage, runnerTag)
 	if overrides := filterOverrides(opts.Overrides, OverrideHost); len(overrides) > 0 {
-		var err error
 		hostCfg, err = applyOverrides(hostCfg, overrides)
 		if err != nil {
-			return Paths{}, err
+			return bootstrapPlan{}, err
 		}
 	}
 	hostPath, err := appconfig.DefaultConfigPath()
 	if err != nil {
-		return Paths{}, err
-	}
-	if !overwrite {
-		if _, err := os.Stat(hostPath); err == nil {
-			return Paths{}, fmt.Errorf("file already exists: %s", hostPath)
-		}
+		return bootstrapPlan{}, err
 	}
 	bundle, assets, err := DefaultFilesWithOptions(opts)
 	if err != nil {
-		return Paths{}, err
+		return bootstrapPlan{}, err
 	}
 	rootDir, err := filepath.Abs(outputDir)
 	if err != nil {
 		rootDir = outputDir
 	}
 	hostStateDir := filepath.Join(rootDir, "state")
+	if value := strings.TrimSpace(opts.StateDir); value != "" {
+		hostStateDir = value
+	}
 	hostRepoDir := filepath.Join(rootDir, "repos")
+	if value := strings.TrimSpace(opts.RepoRoot); value != "" {
+		hostRepoDir = value
+	}
 	if bundle.ConfigYAML, err = overrideContainerConfig(bundle.ConfigYAML, hostStateDir, hostRepoDir, runnerTag); err != nil {
-		return Paths{}, err
+		return bootstrapPlan{}, err
 	}
 	if overrides := filterOverrides(opts.Overrides, OverrideContainer); len(overrides) > 0 {
 		bundle.ConfigYAML, err = applyOverridesToYAML(bundle.ConfigYAML, overrides)
 		if err != nil {
-			return Paths{}, err
+			return bootstrapPlan{}, err
 		}
 	}
+	httpPort, sshPort := opts.ports()
 	tplData := templateData{
 		ConfigFile:        containerConfigName,
 		RunnerInstallPath: runnerInstallRel,
@@ -444,42 +704,25 @@ func WriteBootstrapWithOptions(outputDir string, overwrite bool, imageTag string
 		HostRepoDir:       hostRepoDir,
 		HostPodmanSock:    defaultPodmanSockPath(),
 		ServerImage:       tagImage(defaultServerImage, serverTag),
+		HTTPPort:          httpPort,
+		SSHPort:           sshPort,
 	}
 	if bundle.ComposeYAML, err = renderComposeYAML(tplData); err != nil {
-		return Paths{}, err
+		return bootstrapPlan{}, err
 	}
 	if bundle.PodmanYAML, err = renderPodmanYAML(tplData); err != nil {
-		return Paths{}, err
+		return bootstrapPlan{}, err
 	}
-	paths, err := WriteFilesWithAssets(outputDir, bundle, assets, overwrite)
+	hostYAML, err := yaml.Marshal(hostCfg)
 	if err != nil {
-		return Paths{}, err
-	}
-	envPath, err := writeComposeEnv(outputDir, overwrite)
-	if err != nil {
-		return Paths{}, err
-	}
-	if err := ensureHostAssets(hostCfg); err != nil {
-		return Paths{}, err
-	}
-	if err := os.MkdirAll(filepath.Dir(hostPath), 0o755); err != nil {
-		return Paths{}, err
-	}
-	hostConfig, err := yaml.Marshal(hostCfg)
-	if err != nil {
-		return Paths{}, err
-	}
-	if err := os.WriteFile(hostPath, hostConfig, 0o600); err != nil {
-		return Paths{}, err
-	}
-	if err := ensureSeedHomes(hostCfg, paths.SkelDir); err != nil {
-		return Paths{}, err
-	}
-	return Paths{
-		HostConfigPath: hostPath,
-		Bundle:         paths,
-		EnvPath:        envPath,
-		BinPath:        "",
+		return bootstrapPlan{}, err
+	}
+	return bootstrapPlan{
+		hostCfg:  hostCfg,
+		hostPath: hostPath,
+		hostYAML: hostYAML,
+		bundle:   bundle,
+		assets:   assets,
 	}, nil
 }
 
@@ -501,7 +744,7 @@ func ensureHostAssets(cfg appconfig.Config) error {
 	if _, err := sshkeys.NewStore(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir); err != nil {
 		return err
 	}
-	if _, err := sshserver.EnsureHostKey(cfg.SSH.HostKeyPath); err != nil {
+	if _, err := sshserver.EnsureHostKeys(append([]string{cfg.SSH.HostKeyPath}, cfg.SSH.HostKeys...)); err != nil {
 		return err
 	}
 	return nil
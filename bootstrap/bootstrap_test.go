@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -158,6 +159,7 @@ func stripBootstrapPaths(cfg *appconfig.Config) {
 	cfg.SSH.KeyDir = ""
 	cfg.SSH.AgentDir = ""
 	cfg.Auth.UserFile = ""
+	cfg.Auth.LockoutFile = ""
 	cfg.HTTP.SessionStorePath = ""
 }
 
@@ -171,3 +173,117 @@ func TestResolveRunnerTag(t *testing.T) {
 		t.Fatalf("expected override tag, got %q", override)
 	}
 }
+
+func TestDefaultRepoBundleOptionsGolden(t *testing.T) {
+	t.Setenv("HOME", "/home/cx")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	tests := []struct {
+		name   string
+		opts   Options
+		golden string
+	}{
+		{
+			name:   "defaults",
+			opts:   Options{},
+			golden: "bundle_defaults.golden",
+		},
+		{
+			name: "custom",
+			opts: Options{
+				SeedUsers:      true,
+				HTTPPort:       8080,
+				SSHPort:        2222,
+				RepoRoot:       "/srv/centaurx/repos",
+				StateDir:       "/srv/centaurx/state",
+				ContainerScope: "tab",
+				CPUPercent:     50,
+				MemoryPercent:  40,
+				AllowedModels:  []string{"gpt-5.1-codex-mini", "gpt-5.2-codex"},
+				DefaultModel:   "gpt-5.1-codex-mini",
+			},
+			golden: "bundle_custom.golden",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files, _, err := DefaultRepoBundleWithOptions(tc.opts)
+			if err != nil {
+				t.Fatalf("DefaultRepoBundleWithOptions: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := DryRunFiles(&buf, "bundle", files); err != nil {
+				t.Fatalf("DryRunFiles: %v", err)
+			}
+			got := strings.ReplaceAll(buf.String(), resolveImageTag(""), "VERSION")
+			want, err := os.ReadFile(filepath.Join("testdata", tc.golden))
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			if got != string(want) {
+				t.Fatalf("bundle mismatch for %s:\n%s", tc.golden, got)
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{name: "http port", opts: Options{HTTPPort: 70000}, want: "http port 70000 out of range (1-65535, or 0 for the default)"},
+		{name: "ssh port", opts: Options{SSHPort: -1}, want: "ssh port -1 out of range (1-65535, or 0 for the default)"},
+		{name: "same port", opts: Options{HTTPPort: 2222, SSHPort: 2222}, want: "must differ"},
+		{name: "cpu", opts: Options{CPUPercent: 101}, want: "cpu percent 101 out of range (1-100, or 0 for the default)"},
+		{name: "memory", opts: Options{MemoryPercent: -5}, want: "memory percent -5 out of range (1-100, or 0 for the default)"},
+		{name: "scope", opts: Options{ContainerScope: "global"}, want: "container scope"},
+		{name: "empty models", opts: Options{AllowedModels: []string{" ", ""}}, want: "allowed models"},
+		{name: "default not allowed", opts: Options{AllowedModels: []string{"a"}, DefaultModel: "b"}, want: "not in allowed models"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+	if err := (Options{HTTPPort: 8080, CPUPercent: 100, AllowedModels: []string{"a"}}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := (Options{HTTPPort: 0, SSHPort: 0, CPUPercent: 0, MemoryPercent: 0}).Validate(); err != nil {
+		t.Fatalf("expected zero values to select the defaults, got %v", err)
+	}
+}
+
+func TestWriteBootstrapInvalidOptionsWritesNothing(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	outputDir := filepath.Join(t.TempDir(), "bundle")
+	if _, err := WriteBootstrapWithOptions(outputDir, true, "v1.2.3", Options{CPUPercent: 150}); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("expected no output dir, got %v", err)
+	}
+}
+
+func TestDryRunBootstrapWritesNothing(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	outputDir := filepath.Join(t.TempDir(), "bundle")
+	var buf bytes.Buffer
+	if err := DryRunBootstrap(&buf, outputDir, "v1.2.3", Options{HTTPPort: 8080}); err != nil {
+		t.Fatalf("DryRunBootstrap: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"==> " + filepath.Join(outputDir, "docker-compose.yaml"), `"8080:8080"`, "addr: :8080"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected dry run output to contain %q", want)
+		}
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("expected no output dir, got %v", err)
+	}
+}
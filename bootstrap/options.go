@@ -0,0 +1,144 @@
+package bootstrap
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/internal/appconfig"
+)
+
+const (
+	defaultHTTPPort = 27480
+	defaultSSHPort  = 27422
+)
+
+// Validate checks option values before any file is rendered or written.
+// Zero values mean "use the default" and are always accepted.
+func (o Options) Validate() error {
+	if err := validatePort("http port", o.HTTPPort); err != nil {
+		return err
+	}
+	if err := validatePort("ssh port", o.SSHPort); err != nil {
+		return err
+	}
+	if o.HTTPPort != 0 && o.HTTPPort == o.SSHPort {
+		return fmt.Errorf("http port and ssh port must differ (both %d)", o.HTTPPort)
+	}
+	if err := validatePercent("cpu percent", o.CPUPercent); err != nil {
+		return err
+	}
+	if err := validatePercent("memory percent", o.MemoryPercent); err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(o.ContainerScope)) {
+	case "", "user", "tab":
+	default:
+		return fmt.Errorf("invalid container scope %q (expected user or tab)", o.ContainerScope)
+	}
+	if o.AllowedModels != nil && len(cleanModels(o.AllowedModels)) == 0 {
+		return fmt.Errorf("allowed models must not be empty")
+	}
+	if model := strings.TrimSpace(o.DefaultModel); model != "" && len(o.AllowedModels) > 0 {
+		if !slices.Contains(cleanModels(o.AllowedModels), model) {
+			return fmt.Errorf("default model %q is not in allowed models", model)
+		}
+	}
+	return nil
+}
+
+func validatePort(name string, port int) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("%s %d out of range (1-65535, or 0 for the default)", name, port)
+	}
+	return nil
+}
+
+func validatePercent(name string, value int) error {
+	if value < 0 || value > 100 {
+		return fmt.Errorf("%s %d out of range (1-100, or 0 for the default)", name, value)
+	}
+	return nil
+}
+
+func cleanModels(models []string) []string {
+	out := make([]string, 0, len(models))
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if model == "" || slices.Contains(out, model) {
+			continue
+		}
+		out = append(out, model)
+	}
+	return out
+}
+
+// applyOptions applies the configurable option values to cfg. Path options
+// are host paths and are applied by the callers that know the host layout.
+func applyOptions(cfg appconfig.Config, opts Options) appconfig.Config {
+	if opts.HTTPPort != 0 {
+		cfg.HTTP.Addr = ":" + strconv.Itoa(opts.HTTPPort)
+	}
+	if opts.SSHPort != 0 {
+		cfg.SSH.Addr = ":" + strconv.Itoa(opts.SSHPort)
+	}
+	if scope := strings.ToLower(strings.TrimSpace(opts.ContainerScope)); scope != "" {
+		cfg.Runner.ContainerScope = scope
+	}
+	if opts.CPUPercent != 0 {
+		cfg.Runner.Limits.CPUPercent = opts.CPUPercent
+	}
+	if opts.MemoryPercent != 0 {
+		cfg.Runner.Limits.MemoryPercent = opts.MemoryPercent
+	}
+	if len(opts.AllowedModels) > 0 {
+		cfg.Models.Allowed = cleanModels(opts.AllowedModels)
+		if !slices.Contains(cfg.Models.Allowed, cfg.Models.Default) {
+			cfg.Models.Default = cfg.Models.Allowed[0]
+		}
+	}
+	if model := strings.TrimSpace(opts.DefaultModel); model != "" {
+		cfg.Models.Default = model
+		if !slices.Contains(cfg.Models.Allowed, model) {
+			cfg.Models.Allowed = append([]string{model}, cfg.Models.Allowed...)
+		}
+	}
+	return cfg
+}
+
+// rebaseStateDir moves state-derived paths from the current state dir to stateDir.
+func rebaseStateDir(cfg appconfig.Config, stateDir string) appconfig.Config {
+	stateDir = strings.TrimSpace(stateDir)
+	if stateDir == "" || stateDir == cfg.StateDir {
+		return cfg
+	}
+	old := cfg.StateDir
+	rebase := func(path string) string {
+		if old != "" && strings.HasPrefix(path, old) {
+			return stateDir + strings.TrimPrefix(path, old)
+		}
+		return path
+	}
+	cfg.StateDir = stateDir
+	cfg.Runner.SockDir = rebase(cfg.Runner.SockDir)
+	cfg.Runner.SocketPath = rebase(cfg.Runner.SocketPath)
+	cfg.SSH.KeyStorePath = rebase(cfg.SSH.KeyStorePath)
+	cfg.SSH.KeyDir = rebase(cfg.SSH.KeyDir)
+	cfg.SSH.AgentDir = rebase(cfg.SSH.AgentDir)
+	cfg.Auth.UserFile = rebase(cfg.Auth.UserFile)
+	cfg.HTTP.SessionStorePath = rebase(cfg.HTTP.SessionStorePath)
+	return cfg
+}
+
+// ports returns the published HTTP and SSH ports, applying defaults.
+func (o Options) ports() (int, int) {
+	httpPort, sshPort := o.HTTPPort, o.SSHPort
+	if httpPort == 0 {
+		httpPort = defaultHTTPPort
+	}
+	if sshPort == 0 {
+		sshPort = defaultSSHPort
+	}
+	return httpPort, sshPort
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// Service is the part of the service API the client uses. core.Service
+// implements it, so an in-process service can be passed to New directly.
+type Service interface {
+	CreateTab(ctx context.Context, req schema.CreateTabRequest) (schema.CreateTabResponse, error)
+	ListTabs(ctx context.Context, req schema.ListTabsRequest) (schema.ListTabsResponse, error)
+	SendPrompt(ctx context.Context, req schema.SendPromptRequest) (schema.SendPromptResponse, error)
+	GetBuffer(ctx context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error)
+	GetTabStatus(ctx context.Context, req schema.GetTabStatusRequest) (schema.GetTabStatusResponse, error)
+	GetTabUsage(ctx context.Context, req schema.GetTabUsageRequest) (schema.GetTabUsageResponse, error)
+}
+
+// DefaultPollInterval is how often WaitForIdle checks the tab status.
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	pollInterval time.Duration
+	httpClient   *http.Client
+}
+
+// WithPollInterval sets how often WaitForIdle checks the tab status.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// Client acts as one user against a service. The UserID of every request
+// is set to that user; over HTTP the session user applies regardless.
+type Client struct {
+	service Service
+	user    schema.UserID
+	opts    options
+	close   func(context.Context) error
+}
+
+// New returns a client acting as user against service, typically an
+// in-process core.Service.
+func New(service Service, user schema.UserID, opts ...Option) *Client {
+	options := options{pollInterval: DefaultPollInterval}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Client{service: service, user: user, opts: options}
+}
+
+// User returns the user the client acts as.
+func (c *Client) User() schema.UserID {
+	return c.user
+}
+
+// Close ends the client's session. It is a no-op for in-process clients.
+func (c *Client) Close(ctx context.Context) error {
+	if c.close == nil {
+		return nil
+	}
+	return c.close(ctx)
+}
+
+// CreateTab opens a tab on a repo.
+func (c *Client) CreateTab(ctx context.Context, req schema.CreateTabRequest) (schema.CreateTabResponse, error) {
+	req.UserID = c.user
+	return c.service.CreateTab(ctx, req)
+}
+
+// ListTabs lists the user's tabs.
+func (c *Client) ListTabs(ctx context.Context) (schema.ListTabsResponse, error) {
+	return c.service.ListTabs(ctx, schema.ListTabsRequest{UserID: c.user})
+}
+
+// SendPrompt starts a codex run in a tab and returns once it is running.
+// It fails with schema.ErrTabBusy while a run is in progress.
+func (c *Client) SendPrompt(ctx context.Context, req schema.SendPromptRequest) (schema.SendPromptResponse, error) {
+	req.UserID = c.user
+	return c.service.SendPrompt(ctx, req)
+}
+
+// GetBuffer returns a tab's scrollback. A zero Limit returns all of it.
+func (c *Client) GetBuffer(ctx context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+	req.UserID = c.user
+	return c.service.GetBuffer(ctx, req)
+}
+
+// GetTabStatus reports a tab's model, directory, session and usage.
+func (c *Client) GetTabStatus(ctx context.Context, tabID schema.TabID) (schema.GetTabStatusResponse, error) {
+	return c.service.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: c.user, TabID: tabID})
+}
+
+// GetTabUsage returns the token usage codex last reported for a tab.
+func (c *Client) GetTabUsage(ctx context.Context, tabID schema.TabID) (schema.GetTabUsageResponse, error) {
+	return c.service.GetTabUsage(ctx, schema.GetTabUsageRequest{UserID: c.user, TabID: tabID})
+}
+
+// WaitForIdle polls the tab until no run is in progress and returns its
+// snapshot. It returns schema.ErrTabNotFound if the tab goes away.
+func (c *Client) WaitForIdle(ctx context.Context, tabID schema.TabID) (schema.TabSnapshot, error) {
+	ticker := time.NewTicker(c.opts.pollInterval)
+	defer ticker.Stop()
+	for {
+		tab, err := c.tab(ctx, tabID)
+		if err != nil {
+			return schema.TabSnapshot{}, err
+		}
+		if !tab.Status.Busy() {
+			return tab, nil
+		}
+		select {
+		case <-ctx.Done():
+			return schema.TabSnapshot{}, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) tab(ctx context.Context, tabID schema.TabID) (schema.TabSnapshot, error) {
+	resp, err := c.ListTabs(ctx)
+	if err != nil {
+		return schema.TabSnapshot{}, err
+	}
+	for _, tab := range resp.Tabs {
+		if tab.ID == tabID {
+			return tab, nil
+		}
+	}
+	return schema.TabSnapshot{}, schema.ErrTabNotFound
+}
+
+// RunResult is the outcome of RunPrompt.
+type RunResult struct {
+	// Tab is the tab once the run finished.
+	Tab   schema.TabSnapshot
+	RunID schema.RunID
+	// Answer is the final agent message, one buffer line per line.
+	Answer string
+	// Usage is the token usage codex reported for the run, if any.
+	Usage *schema.TurnUsage
+}
+
+// RunError reports a run that wrote error lines to the tab buffer, such as
+// a failed turn or a non-zero codex exit.
+type RunError struct {
+	RunID schema.RunID
+	Lines []string
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("run %s failed: %s", e.RunID, strings.Join(e.Lines, "; "))
+}
+
+// RunPrompt sends prompt to a tab, waits for the run to finish and returns
+// the final agent message and usage. A run that reports errors returns the
+// result alongside a *RunError. The answer is read from the buffer after the
+// prompt line, so prompts sent to the same tab by others meanwhile can be
+// mistaken for this one.
+func (c *Client) RunPrompt(ctx context.Context, tabID schema.TabID, prompt string) (RunResult, error) {
+	sent, err := c.SendPrompt(ctx, schema.SendPromptRequest{TabID: tabID, Prompt: prompt})
+	if err != nil {
+		return RunResult{}, err
+	}
+	if !sent.Accepted {
+		return RunResult{}, errors.New("prompt not accepted")
+	}
+	result := RunResult{RunID: sent.Tab.RunID}
+	tab, err := c.WaitForIdle(ctx, tabID)
+	if err != nil {
+		return result, err
+	}
+	result.Tab = tab
+	buf, err := c.GetBuffer(ctx, schema.GetBufferRequest{TabID: tabID, Structured: true})
+	if err != nil {
+		return result, err
+	}
+	answer, failures := runOutput(buf.Buffer.Structured)
+	result.Answer = answer
+	usage, err := c.GetTabUsage(ctx, tabID)
+	if err != nil {
+		return result, err
+	}
+	result.Usage = usage.Usage
+	if len(failures) > 0 {
+		return result, &RunError{RunID: result.RunID, Lines: failures}
+	}
+	return result, nil
+}
+
+// runOutput returns the final answer and error lines written after the last
+// prompt in lines.
+func runOutput(lines []schema.BufferLine) (string, []string) {
+	start := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].Kind == schema.LineKindPrompt {
+			start = i + 1
+			break
+		}
+	}
+	var answer, failures []string
+	for _, line := range lines[start:] {
+		switch line.Kind {
+		case schema.LineKindAnswer:
+			answer = append(answer, line.Text)
+		case schema.LineKindError:
+			failures = append(failures, line.Text)
+		}
+	}
+	return strings.Join(answer, "\n"), failures
+}
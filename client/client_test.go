@@ -0,0 +1,237 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+
+	"pkt.systems/centaurx/client"
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/httpapi"
+	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/codex"
+	"pkt.systems/centaurx/internal/command"
+	"pkt.systems/centaurx/schema"
+)
+
+var codexMockBin string
+
+// TestMain builds the centaurx binary under the name that makes it act as
+// codex-mock, for the contract tests.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		os.Exit(m.Run())
+	}
+	dir, err := os.MkdirTemp("", "centaurx-client-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	codexMockBin = filepath.Join(dir, "codex-mock")
+	if out, err := exec.Command("go", "build", "-o", codexMockBin, "pkt.systems/centaurx/cmd/centaurx").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "build codex-mock: %v\n%s", err, out)
+		_ = os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// contractServer is a service backed by codex-mock, reachable in-process
+// and over HTTP.
+type contractServer struct {
+	service core.Service
+	url     string
+	creds   client.Credentials
+	secret  string
+}
+
+func newContractServer(t *testing.T) *contractServer {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping codex-mock contract test in short mode")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	scenario, err := filepath.Abs(filepath.Join("testdata", "contract.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, err := codex.NewRunner(codex.Config{
+		BinaryPath: codexMockBin,
+		ExtraArgs:  []string{"--scenario", scenario, "--delay-ms", "0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := core.StaticRunnerProvider{Runner: runner}
+	hub := httpapi.NewHub(100)
+	service, err := core.NewService(schema.ServiceConfig{
+		RepoRoot:      t.TempDir(),
+		StateDir:      t.TempDir(),
+		DefaultModel:  "gpt-5.2-codex",
+		AllowedModels: []schema.ModelID{"gpt-5.2-codex"},
+	}, core.ServiceDeps{RunnerProvider: provider, EventSink: hub})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password := "contract-password"
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "centaurx", AccountName: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authStore, err := auth.NewStoreWithLogger(filepath.Join(t.TempDir(), "users.json"), []appconfig.SeedUser{{
+		Username:     "tester",
+		PasswordHash: string(hash),
+		TOTPSecret:   key.Secret(),
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := command.NewHandler(service, provider, command.HandlerConfig{
+		AllowedModels: []schema.ModelID{"gpt-5.2-codex"},
+	})
+	httpSrv := httpapi.NewServer(httpapi.Config{
+		SessionCookie:      "centaurx_session",
+		SessionTTLHours:    1,
+		InitialBufferLines: 200,
+	}, service, handler, authStore, hub)
+	ts := httptest.NewServer(httpSrv.Handler())
+	t.Cleanup(ts.Close)
+
+	return &contractServer{
+		service: service,
+		url:     ts.URL,
+		creds:   client.Credentials{Username: "tester", Password: password},
+		secret:  key.Secret(),
+	}
+}
+
+// clients returns an in-process and an HTTP client for the same user.
+func (s *contractServer) clients(t *testing.T) map[string]*client.Client {
+	t.Helper()
+	creds := s.creds
+	code, err := totp.GenerateCode(s.secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds.TOTP = code
+	remote, err := client.Dial(context.Background(), s.url, creds, client.WithPollInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = remote.Close(context.Background())
+	})
+	return map[string]*client.Client{
+		"in-process": client.New(s.service, "tester", client.WithPollInterval(20*time.Millisecond)),
+		"http":       remote,
+	}
+}
+
+func TestClientContract(t *testing.T) {
+	srv := newContractServer(t)
+	for name, c := range srv.clients(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if c.User() != "tester" {
+				t.Fatalf("expected user tester, got %q", c.User())
+			}
+			created, err := c.CreateTab(ctx, schema.CreateTabRequest{RepoName: schema.RepoName("repo-" + name), CreateRepo: true})
+			if err != nil {
+				t.Fatalf("create tab: %v", err)
+			}
+			tabID := created.Tab.ID
+
+			t.Run("RunPrompt", func(t *testing.T) {
+				result, err := c.RunPrompt(ctx, tabID, "hello")
+				if err != nil {
+					t.Fatalf("run prompt: %v", err)
+				}
+				if result.Answer != "Contract answer." {
+					t.Fatalf("expected final answer, got %q", result.Answer)
+				}
+				if result.Usage == nil || result.Usage.InputTokens != 42 || result.Usage.OutputTokens != 7 {
+					t.Fatalf("expected usage from the run, got %+v", result.Usage)
+				}
+				if result.RunID == "" || result.Tab.RunID != result.RunID || result.Tab.Status == schema.TabStatusRunning {
+					t.Fatalf("unexpected run result: %+v", result)
+				}
+			})
+
+			t.Run("Busy", func(t *testing.T) {
+				if _, err := c.SendPrompt(ctx, schema.SendPromptRequest{TabID: tabID, Prompt: "slow one"}); err != nil {
+					t.Fatalf("send prompt: %v", err)
+				}
+				if _, err := c.SendPrompt(ctx, schema.SendPromptRequest{TabID: tabID, Prompt: "again"}); !errors.Is(err, schema.ErrTabBusy) {
+					t.Fatalf("expected ErrTabBusy, got %v", err)
+				}
+				tab, err := c.WaitForIdle(ctx, tabID)
+				if err != nil {
+					t.Fatalf("wait for idle: %v", err)
+				}
+				if tab.Status == schema.TabStatusRunning {
+					t.Fatalf("expected idle tab, got %s", tab.Status)
+				}
+			})
+
+			t.Run("RunError", func(t *testing.T) {
+				_, err := c.RunPrompt(ctx, tabID, "please fail")
+				var runErr *client.RunError
+				if !errors.As(err, &runErr) || len(runErr.Lines) == 0 {
+					t.Fatalf("expected RunError, got %v", err)
+				}
+			})
+
+			t.Run("Status", func(t *testing.T) {
+				status, err := c.GetTabStatus(ctx, tabID)
+				if err != nil {
+					t.Fatalf("tab status: %v", err)
+				}
+				if status.Status.TabID != tabID || status.Status.Model != "gpt-5.2-codex" {
+					t.Fatalf("unexpected status: %+v", status.Status)
+				}
+			})
+
+			t.Run("UnknownTab", func(t *testing.T) {
+				if _, err := c.WaitForIdle(ctx, "missing"); !errors.Is(err, schema.ErrTabNotFound) {
+					t.Fatalf("expected ErrTabNotFound, got %v", err)
+				}
+				if _, err := c.GetTabUsage(ctx, "missing"); !errors.Is(err, schema.ErrTabNotFound) {
+					t.Fatalf("expected ErrTabNotFound, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestDialRejectsBadCredentials(t *testing.T) {
+	srv := newContractServer(t)
+	creds := srv.creds
+	creds.Password = "wrong"
+	_, err := client.Dial(context.Background(), srv.url, creds)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || apiErr.Code != schema.CodeUnauthorized {
+		t.Fatalf("expected unauthorized APIError, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+// Package client drives centaurx programmatically, over the HTTP API or
+// in-process against a core.Service.
+package client
@@ -0,0 +1,51 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"pkt.systems/centaurx/client"
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/schema"
+)
+
+func ExampleDial() {
+	ctx := context.Background()
+	c, err := client.Dial(ctx, "https://centaurx.example.com", client.Credentials{
+		Username: "alice",
+		Password: "secret",
+		TOTP:     "123456",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		_ = c.Close(ctx)
+	}()
+
+	tab, err := c.CreateTab(ctx, schema.CreateTabRequest{RepoName: "demo", CreateRepo: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	result, err := c.RunPrompt(ctx, tab.Tab.ID, "summarise the README")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.Answer)
+}
+
+func ExampleNew() {
+	var service core.Service // from core.NewService
+	c := client.New(service, "alice")
+
+	ctx := context.Background()
+	if _, err := c.SendPrompt(ctx, schema.SendPromptRequest{TabID: "tab-1", Prompt: "run the tests"}); err != nil {
+		log.Fatal(err)
+	}
+	tab, err := c.WaitForIdle(ctx, "tab-1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(tab.Status)
+}
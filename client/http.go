@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// Credentials log a client in over HTTP.
+type Credentials struct {
+	Username string
+	Password string
+	// TOTP is the current one-time code.
+	TOTP string
+}
+
+// WithHTTPClient sets the http.Client Dial makes requests with, for
+// timeouts or TLS settings. Its cookie jar is replaced by one holding the
+// session. New ignores it.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		if client != nil {
+			o.httpClient = client
+		}
+	}
+}
+
+// Dial logs in to the centaurx HTTP API at baseURL, including any base
+// path, and returns a client acting as the logged in user.
+func Dial(ctx context.Context, baseURL string, creds Credentials, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("base url %q: scheme must be http or https", baseURL)
+	}
+	options := options{httpClient: &http.Client{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	client := *options.httpClient
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Jar = jar
+	svc := &httpService{base: base, client: &client}
+	var login struct {
+		Username string `json:"username"`
+	}
+	if err := svc.do(ctx, http.MethodPost, "/api/login", map[string]string{
+		"username": creds.Username,
+		"password": creds.Password,
+		"totp":     creds.TOTP,
+	}, &login); err != nil {
+		return nil, err
+	}
+	c := New(svc, schema.UserID(login.Username), opts...)
+	c.close = svc.logout
+	return c, nil
+}
+
+// APIError is an error response from the HTTP API. It matches the schema
+// error with the same code under errors.Is, so callers can check for
+// schema.ErrTabBusy and friends regardless of transport.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the stable error code reported by the server.
+func (e *APIError) ErrorCode() string {
+	return e.Code
+}
+
+// Is reports whether target is a schema error with the same code.
+func (e *APIError) Is(target error) bool {
+	var coder schema.Coder
+	return e.Code != "" && errors.As(target, &coder) && coder.ErrorCode() == e.Code
+}
+
+// httpService implements Service over the HTTP API. Requests act as the
+// session user; UserID fields are ignored.
+type httpService struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func (s *httpService) CreateTab(ctx context.Context, req schema.CreateTabRequest) (schema.CreateTabResponse, error) {
+	var resp schema.CreateTabResponse
+	err := s.do(ctx, http.MethodPost, "/api/tabs", map[string]any{
+		"repo_name": req.RepoName,
+		"create":    req.CreateRepo,
+		"ephemeral": req.Ephemeral,
+	}, &resp)
+	return resp, err
+}
+
+func (s *httpService) ListTabs(ctx context.Context, req schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+	var resp schema.ListTabsResponse
+	path := "/api/tabs"
+	if req.IncludeArchived {
+		path += "?archived=1"
+	}
+	err := s.do(ctx, http.MethodGet, path, nil, &resp)
+	return resp, err
+}
+
+// SendPrompt posts to /api/prompt, which runs slash commands instead of
+// sending them to codex; those return a zero response.
+func (s *httpService) SendPrompt(ctx context.Context, req schema.SendPromptRequest) (schema.SendPromptResponse, error) {
+	var resp schema.SendPromptResponse
+	err := s.do(ctx, http.MethodPost, "/api/prompt", map[string]any{
+		"tab_id": req.TabID,
+		"input":  req.Prompt,
+	}, &resp)
+	return resp, err
+}
+
+func (s *httpService) GetBuffer(ctx context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+	query := url.Values{}
+	query.Set("tab_id", string(req.TabID))
+	query.Set("limit", strconv.Itoa(req.Limit))
+	if req.Structured {
+		query.Set("structured", "1")
+	}
+	if req.Rendering != "" {
+		query.Set("rendering", string(req.Rendering))
+	}
+	var resp schema.GetBufferResponse
+	err := s.do(ctx, http.MethodGet, "/api/buffer?"+query.Encode(), nil, &resp)
+	return resp, err
+}
+
+func (s *httpService) GetTabStatus(ctx context.Context, req schema.GetTabStatusRequest) (schema.GetTabStatusResponse, error) {
+	var resp schema.GetTabStatusResponse
+	err := s.do(ctx, http.MethodGet, "/api/tabs/"+url.PathEscape(string(req.TabID))+"/status", nil, &resp.Status)
+	return resp, err
+}
+
+func (s *httpService) GetTabUsage(ctx context.Context, req schema.GetTabUsageRequest) (schema.GetTabUsageResponse, error) {
+	var resp schema.GetTabUsageResponse
+	err := s.do(ctx, http.MethodGet, "/api/tabs/"+url.PathEscape(string(req.TabID))+"/usage", nil, &resp)
+	return resp, err
+}
+
+func (s *httpService) logout(ctx context.Context) error {
+	return s.do(ctx, http.MethodPost, "/api/logout", nil, nil)
+}
+
+// do sends payload as JSON to path and decodes the response into out.
+// Error responses are returned as *APIError.
+func (s *httpService) do(ctx context.Context, method, path string, payload any, out any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.base.String()+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeAPIError(resp.StatusCode, data)
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func decodeAPIError(status int, data []byte) error {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	apiErr := &APIError{StatusCode: status}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error.Message != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+		return apiErr
+	}
+	apiErr.Message = strings.TrimSpace(string(data))
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(status)
+	}
+	return apiErr
+}
@@ -20,6 +20,8 @@ func newBootstrapCmd() *cobra.Command {
 	var seedUsers bool
 	var redistributable bool
 	var overrides []string
+	var dryRun bool
+	var opts bootstrap.Options
 	cmd := &cobra.Command{
 		Use:   "bootstrap",
 		Short: "Generate default config and container files",
@@ -37,11 +39,13 @@ func newBootstrapCmd() *cobra.Command {
 				}
 				out = filepath.Join(home, ".centaurx")
 			}
-			paths, err := bootstrap.WriteBootstrapWithOptions(out, overwrite, imageTag, bootstrap.Options{
-				SeedUsers:       seedUsers,
-				Overrides:       parsedOverrides,
-				Redistributable: redistributable,
-			})
+			opts.SeedUsers = seedUsers
+			opts.Overrides = parsedOverrides
+			opts.Redistributable = redistributable
+			if dryRun {
+				return bootstrap.DryRunBootstrap(cmd.OutOrStdout(), out, imageTag, opts)
+			}
+			paths, err := bootstrap.WriteBootstrapWithOptions(out, overwrite, imageTag, opts)
 			if err != nil {
 				return err
 			}
@@ -53,6 +57,7 @@ func newBootstrapCmd() *cobra.Command {
 			logger.Info("bootstrap", "path", paths.Bundle.RunnerContainerfile, "name", "Containerfile.cxrunner")
 			logger.Info("bootstrap", "path", paths.Bundle.RunnerInstallScript, "name", "cxrunner-install.sh")
 			logger.Info("bootstrap", "path", paths.Bundle.SkelDir, "name", "skel/")
+			logger.Info("bootstrap", "path", paths.Bundle.SampleThemePath, "name", "example.yaml.sample")
 			logger.Info("bootstrap", "path", paths.EnvPath, "name", ".env")
 			if paths.BinPath != "" {
 				logger.Info("bootstrap", "path", paths.BinPath, "name", "centaurx")
@@ -66,6 +71,16 @@ func newBootstrapCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&seedUsers, "seed-users", false, "seed default users (admin)")
 	cmd.Flags().BoolVar(&redistributable, "redistributable", false, "use redistributable runner image tag in generated config")
 	cmd.Flags().StringArrayVarP(&overrides, "config", "c", nil, "config override (e.g. http.base_path=/cx or host:http.base_path=/cx)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print generated files to stdout instead of writing them")
+	cmd.Flags().IntVar(&opts.HTTPPort, "http-port", 0, "HTTP listen and published port (default 27480)")
+	cmd.Flags().IntVar(&opts.SSHPort, "ssh-port", 0, "SSH listen and published port (default 27422)")
+	cmd.Flags().StringVar(&opts.RepoRoot, "repo-root", "", "host repo root mounted into the container")
+	cmd.Flags().StringVar(&opts.StateDir, "state-dir", "", "host state dir mounted into the container")
+	cmd.Flags().StringVar(&opts.ContainerScope, "container-scope", "", "runner container scope (user or tab)")
+	cmd.Flags().IntVar(&opts.CPUPercent, "cpu-percent", 0, "runner CPU limit in percent of host (default 70)")
+	cmd.Flags().IntVar(&opts.MemoryPercent, "memory-percent", 0, "runner memory limit in percent of host (default 70)")
+	cmd.Flags().StringSliceVar(&opts.AllowedModels, "models", nil, "allowed models (comma-separated)")
+	cmd.Flags().StringVar(&opts.DefaultModel, "default-model", "", "default model")
 	return cmd
 }
 
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/containerd/platforms"
 	"github.com/spf13/cobra"
 
 	"pkt.systems/centaurx/bootstrap"
@@ -32,6 +33,11 @@ type buildSharedOptions struct {
 	namespace       string
 	disableImport   bool
 	redistributable bool
+	platform        string
+	pin             bool
+	// bundleDir holds the bootstrap bundle Containerfiles whose base image
+	// pins builds honor; it is the config directory.
+	bundleDir string
 }
 
 func newBuildCmd() *cobra.Command {
@@ -45,10 +51,13 @@ func newBuildCmd() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&opts.namespace, "namespace", "", "override containerd namespace for import (containerd only)")
 	cmd.PersistentFlags().BoolVar(&opts.disableImport, "disable-import", false, "skip importing the built image into containerd (containerd only)")
 	cmd.PersistentFlags().BoolVar(&opts.redistributable, "redistributable", false, "build redistributable runner image (excludes non-redistributable tooling)")
+	cmd.PersistentFlags().StringVar(&opts.platform, "platform", "", "target platform, e.g. linux/arm64 (default: host platform; other platforms are not imported into containerd)")
+	cmd.PersistentFlags().BoolVar(&opts.pin, "pin", false, "resolve base image tags to digests and pin them in the bootstrap bundle Containerfiles")
 
 	cmd.AddCommand(newBuildServerCmd(opts))
 	cmd.AddCommand(newBuildRunnerCmd(opts))
 	cmd.AddCommand(newBuildAllCmd(opts))
+	cmd.AddCommand(newBuildVerifyCmd(opts))
 	return cmd
 }
 
@@ -63,6 +72,7 @@ func newBuildServerCmd(shared *buildSharedOptions) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			shared.bundleDir = filepath.Dir(configPath)
 			tags, err := buildTags(defaultServerImage, tag)
 			if err != nil {
 				return err
@@ -94,6 +104,7 @@ func newBuildRunnerCmd(shared *buildSharedOptions) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			shared.bundleDir = filepath.Dir(configPath)
 			tags, err := buildRunnerTags(cfg.Runner.Image, tag, shared.redistributable)
 			if err != nil {
 				return err
@@ -126,6 +137,7 @@ func newBuildAllCmd(shared *buildSharedOptions) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			shared.bundleDir = filepath.Dir(configPath)
 			serverTags, err := buildTags(defaultServerImage, serverTag)
 			if err != nil {
 				return err
@@ -166,6 +178,13 @@ func buildServer(ctx context.Context, cfg appconfig.Config, builder shipohoy.Bui
 	if err := ensureStaticBinary(binPath); err != nil {
 		return err
 	}
+	platform, err := buildPlatform(shared)
+	if err != nil {
+		return err
+	}
+	if err := ensureBinaryPlatform(binPath, platform); err != nil {
+		return err
+	}
 	contextDir, cleanup, err := prepareServerContext(binPath)
 	if err != nil {
 		return err
@@ -176,21 +195,30 @@ func buildServer(ctx context.Context, cfg appconfig.Config, builder shipohoy.Bui
 	if err != nil {
 		return err
 	}
+	containerfile, manifest, err := prepareBuildInputs(ctx, shared, "server", serverBundleContainerfile, files.CentaurxContainerfile, tags, platform)
+	if err != nil {
+		return err
+	}
 	spec := shipohoy.BuildSpec{
 		ContextDir:        contextDir,
-		ContainerfileData: files.CentaurxContainerfile,
+		ContainerfileData: containerfile,
+		Labels:            manifest.Labels(),
 		Tags:              tags,
 		BuildArgs: map[string]string{
 			"CENTAURX_BIN": "bin/centaurx",
 		},
 		Timeout:    buildTimeout(cfg),
 		OutputPath: outputPath,
+		Platform:   platform,
 	}
-	logger.Info("build.start", "target", "server", "tags", tags, "output", outputPath)
+	logger.Info("build.start", "target", "server", "tags", tags, "output", outputPath, "platform", platform)
 	_, err = runBuild(ctx, builder, spec, logger)
 	if err != nil {
 		return err
 	}
+	if err := writeBuildManifest(ctx, manifest, outputPath); err != nil {
+		return err
+	}
 	return postBuild(ctx, cfg, runtimeKind, shared, outputPath, spec.Tags)
 }
 
@@ -207,15 +235,27 @@ func buildRunner(ctx context.Context, cfg appconfig.Config, builder shipohoy.Bui
 	if assets == nil || len(assets.InstallScript) == 0 {
 		return errors.New("runner install script missing")
 	}
+	platform, err := buildPlatform(shared)
+	if err != nil {
+		return err
+	}
+	if err := ensureBinaryPlatform(binPath, platform); err != nil {
+		return err
+	}
 	contextDir, cleanup, err := prepareRunnerContext(binPath, assets.InstallScript)
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
+	containerfile, manifest, err := prepareBuildInputs(ctx, shared, "runner", runnerBundleContainerfile, files.RunnerContainerfile, tags, platform)
+	if err != nil {
+		return err
+	}
 	spec := shipohoy.BuildSpec{
 		ContextDir:        contextDir,
-		ContainerfileData: files.RunnerContainerfile,
+		ContainerfileData: containerfile,
+		Labels:            manifest.Labels(),
 		Tags:              tags,
 		BuildArgs: map[string]string{
 			"RUNNER_INSTALL": "files/cxrunner-install.sh",
@@ -223,15 +263,19 @@ func buildRunner(ctx context.Context, cfg appconfig.Config, builder shipohoy.Bui
 		},
 		Timeout:    buildTimeout(cfg),
 		OutputPath: outputPath,
+		Platform:   platform,
 	}
 	if shared != nil && shared.redistributable {
 		spec.BuildArgs["CX_REDISTRIBUTABLE"] = "1"
 	}
-	logger.Info("build.start", "target", "runner", "tags", tags, "output", outputPath)
+	logger.Info("build.start", "target", "runner", "tags", tags, "output", outputPath, "platform", platform)
 	_, err = runBuild(ctx, builder, spec, logger)
 	if err != nil {
 		return err
 	}
+	if err := writeBuildManifest(ctx, manifest, outputPath); err != nil {
+		return err
+	}
 	return postBuild(ctx, cfg, runtimeKind, shared, outputPath, spec.Tags)
 }
 
@@ -323,6 +367,11 @@ func selectBuilder(cfg appconfig.Config) (shipohoy.Builder, string, error) {
 func postBuild(ctx context.Context, cfg appconfig.Config, runtimeKind string, shared *buildSharedOptions, outputPath string, images []string) error {
 	switch runtimeKind {
 	case "containerd":
+		if shared != nil && isCrossPlatform(shared.platform) {
+			// containerd only imports images for the host platform.
+			pslog.Ctx(ctx).Info("build.import.skipped", "path", outputPath, "platform", shared.platform, "reason", "cross-platform build")
+			return nil
+		}
 		if err := importBuildOutputContainerd(ctx, cfg, shared, outputPath, images); err != nil {
 			return err
 		}
@@ -638,3 +687,71 @@ func ensureStaticBinary(path string) error {
 	}
 	return nil
 }
+
+// buildPlatform returns the normalized --platform value, or "" when the
+// image is built for the host.
+func buildPlatform(shared *buildSharedOptions) (string, error) {
+	if shared == nil || strings.TrimSpace(shared.platform) == "" {
+		return "", nil
+	}
+	platform, err := platforms.Parse(strings.TrimSpace(shared.platform))
+	if err != nil {
+		return "", fmt.Errorf("invalid --platform: %w", err)
+	}
+	if platform.OS != "linux" {
+		return "", fmt.Errorf("invalid --platform %q: images are built for linux", shared.platform)
+	}
+	return platforms.Format(platform), nil
+}
+
+// isCrossPlatform reports whether value names a platform the host cannot run.
+func isCrossPlatform(value string) bool {
+	if strings.TrimSpace(value) == "" {
+		return false
+	}
+	platform, err := platforms.Parse(value)
+	if err != nil {
+		return false
+	}
+	return !platforms.Default().Match(platform)
+}
+
+// elfMachines maps GOARCH-style architectures to ELF machine types.
+var elfMachines = map[string]elf.Machine{
+	"amd64":   elf.EM_X86_64,
+	"arm64":   elf.EM_AARCH64,
+	"arm":     elf.EM_ARM,
+	"386":     elf.EM_386,
+	"ppc64le": elf.EM_PPC64,
+	"s390x":   elf.EM_S390,
+	"riscv64": elf.EM_RISCV,
+}
+
+// ensureBinaryPlatform checks that the centaurx binary copied into an image
+// built with --platform matches the target architecture.
+func ensureBinaryPlatform(path string, platform string) error {
+	if platform == "" {
+		return nil
+	}
+	target, err := platforms.Parse(platform)
+	if err != nil {
+		return err
+	}
+	want, ok := elfMachines[target.Architecture]
+	if !ok {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	ef, err := elf.NewFile(file)
+	if err != nil {
+		return fmt.Errorf("centaurx binary is not a valid ELF file: %w", err)
+	}
+	if ef.Machine != want {
+		return fmt.Errorf("centaurx binary is built for %s, but the image targets %s; use --bin with a binary built for GOARCH=%s", ef.Machine, platforms.Format(target), target.Architecture)
+	}
+	return nil
+}
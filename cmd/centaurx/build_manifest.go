@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"pkt.systems/centaurx/bootstrap"
+	"pkt.systems/centaurx/internal/buildmanifest"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
+	"pkt.systems/centaurx/internal/version"
+	"pkt.systems/pslog"
+)
+
+const (
+	serverBundleContainerfile = "Containerfile.centaurx"
+	runnerBundleContainerfile = "Containerfile.cxrunner"
+	resolveDigestTimeout      = 30 * time.Second
+)
+
+// resolveImageDigest is replaced in tests to avoid registry access.
+var resolveImageDigest = shipohoy.ResolveDigest
+
+// prepareBuildInputs pins the base images of containerfile to the digests
+// recorded in the bootstrap bundle copy of it and resolves the digests of the
+// remaining ones. With --pin, resolution failures are errors and the resolved
+// digests are written back into the bundle copy. It returns the Containerfile
+// to build and the manifest describing the build.
+func prepareBuildInputs(ctx context.Context, shared *buildSharedOptions, target, bundleName string, containerfile []byte, tags []string, platform string) ([]byte, buildmanifest.Manifest, error) {
+	logger := pslog.Ctx(ctx)
+	bundlePath, bundleData, err := readBundleContainerfile(shared, bundleName)
+	if err != nil {
+		return nil, buildmanifest.Manifest{}, err
+	}
+	containerfile = buildmanifest.Pin(containerfile, baseImageDigests(buildmanifest.BaseImages(bundleData)))
+	pin := shared != nil && shared.pin
+
+	bases := buildmanifest.BaseImages(containerfile)
+	resolved := map[string]string{}
+	for i, base := range bases {
+		if base.Digest != "" {
+			continue
+		}
+		if digest, ok := resolved[base.Ref]; ok {
+			bases[i].Digest = digest
+			continue
+		}
+		resolveCtx, cancel := context.WithTimeout(ctx, resolveDigestTimeout)
+		digest, err := resolveImageDigest(resolveCtx, base.Ref)
+		cancel()
+		if err != nil {
+			if pin {
+				return nil, buildmanifest.Manifest{}, fmt.Errorf("pin base image: %w", err)
+			}
+			logger.Warn("build.base.resolve_failed", "target", target, "image", base.Ref, "err", err)
+			continue
+		}
+		bases[i].Digest = digest
+		resolved[base.Ref] = digest
+	}
+	if pin {
+		containerfile = buildmanifest.Pin(containerfile, resolved)
+		if bundlePath != "" && len(resolved) > 0 {
+			out := containerfile
+			if bundleData != nil {
+				out = buildmanifest.Pin(bundleData, resolved)
+			}
+			if err := os.WriteFile(bundlePath, out, 0o644); err != nil {
+				return nil, buildmanifest.Manifest{}, fmt.Errorf("write pinned Containerfile: %w", err)
+			}
+			logger.Info("build.pin", "target", target, "path", bundlePath, "images", baseImageStrings(bases))
+		}
+	}
+
+	manifest := buildmanifest.Manifest{
+		Target:              target,
+		Tags:                tags,
+		Platform:            platform,
+		Version:             version.Current(),
+		BuildTime:           time.Now().UTC(),
+		BaseImages:          bases,
+		ContainerfileSHA256: buildmanifest.ContainerfileDigest(containerfile),
+	}
+	return containerfile, manifest, nil
+}
+
+// expectedBuildLabels returns the labels a build of containerfile with the
+// current bundle pins and binary would set. The build time is left out.
+func expectedBuildLabels(shared *buildSharedOptions, target, bundleName string, containerfile []byte) (map[string]string, error) {
+	_, bundleData, err := readBundleContainerfile(shared, bundleName)
+	if err != nil {
+		return nil, err
+	}
+	containerfile = buildmanifest.Pin(containerfile, baseImageDigests(buildmanifest.BaseImages(bundleData)))
+	manifest := buildmanifest.Manifest{
+		Target:              target,
+		Version:             version.Current(),
+		BaseImages:          buildmanifest.BaseImages(containerfile),
+		ContainerfileSHA256: buildmanifest.ContainerfileDigest(containerfile),
+	}
+	return manifest.Labels(), nil
+}
+
+// readBundleContainerfile reads a Containerfile from the bootstrap bundle
+// next to the config. A missing file returns nil data.
+func readBundleContainerfile(shared *buildSharedOptions, name string) (string, []byte, error) {
+	if shared == nil || strings.TrimSpace(shared.bundleDir) == "" {
+		return "", nil, nil
+	}
+	path := filepath.Join(shared.bundleDir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return path, nil, nil
+		}
+		return "", nil, err
+	}
+	return path, data, nil
+}
+
+// writeBuildManifest adds the installed packages of the exported image to
+// the manifest and writes it next to the export.
+func writeBuildManifest(ctx context.Context, manifest buildmanifest.Manifest, outputPath string) error {
+	if strings.TrimSpace(outputPath) == "" {
+		return nil
+	}
+	logger := pslog.Ctx(ctx)
+	pkgs, err := buildmanifest.Packages(outputPath)
+	if err != nil {
+		logger.Warn("build.manifest.packages_failed", "path", outputPath, "err", err)
+	}
+	manifest.Packages = pkgs
+	path := buildmanifest.Path(outputPath)
+	if err := manifest.Write(path); err != nil {
+		return err
+	}
+	logger.Info("build.manifest", "target", manifest.Target, "path", path, "packages", len(pkgs))
+	return nil
+}
+
+func baseImageDigests(bases []buildmanifest.BaseImage) map[string]string {
+	out := map[string]string{}
+	for _, base := range bases {
+		if base.Digest != "" {
+			out[base.Ref] = base.Digest
+		}
+	}
+	return out
+}
+
+func baseImageStrings(bases []buildmanifest.BaseImage) []string {
+	out := make([]string, 0, len(bases))
+	for _, base := range bases {
+		out = append(out, base.String())
+	}
+	return out
+}
+
+func newBuildVerifyCmd(shared *buildSharedOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify",
+		Short: "Compare running runner images with the local build inputs",
+		Long: "Compare the build labels of the images behind running runner containers " +
+			"(or the configured runner image when none run) with the labels a build " +
+			"from the local Containerfile and bundle pins would set, and report drift.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, configPath, err := loadRequiredConfig(shared.configPath)
+			if err != nil {
+				return err
+			}
+			shared.bundleDir = filepath.Dir(configPath)
+			files, _, err := bootstrap.DefaultFiles()
+			if err != nil {
+				return err
+			}
+			expected, err := expectedBuildLabels(shared, "runner", runnerBundleContainerfile, files.RunnerContainerfile)
+			if err != nil {
+				return err
+			}
+			rt, closeFn, err := selectRuntime(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = closeFn() }()
+			return verifyRunnerImages(cmd.Context(), cmd.OutOrStdout(), rt, expected, cfg.Runner.Image)
+		},
+	}
+}
+
+// verifyRunnerImages reports, per running runner container, whether its image
+// labels match expected. Without running runners the fallback image is
+// checked instead.
+func verifyRunnerImages(ctx context.Context, w io.Writer, rt shipohoy.Runtime, expected map[string]string, fallbackImage string) error {
+	inspector, ok := rt.(shipohoy.ImageInspector)
+	if !ok {
+		return errors.New("runtime cannot inspect images")
+	}
+	containers, err := rt.ListManaged(ctx, nil)
+	if err != nil {
+		return err
+	}
+	type subject struct {
+		name  string
+		image string
+	}
+	var subjects []subject
+	for _, container := range containers {
+		if !labels.IsManaged(container.Labels) || container.Labels[labels.User] == "" || container.State != shipohoy.ContainerRunning {
+			continue
+		}
+		image := container.Image
+		if image == "" {
+			image = fallbackImage
+		}
+		subjects = append(subjects, subject{name: container.Name, image: image})
+	}
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].name < subjects[j].name })
+	if len(subjects) == 0 {
+		if strings.TrimSpace(fallbackImage) == "" {
+			return errors.New("no running runner containers and no runner image configured")
+		}
+		_, _ = fmt.Fprintln(w, "no running runner containers; checking the configured runner image")
+		subjects = append(subjects, subject{name: "(configured)", image: fallbackImage})
+	}
+
+	inspected := map[string]shipohoy.ImageInfo{}
+	drifted := 0
+	for _, s := range subjects {
+		info, ok := inspected[s.image]
+		if !ok {
+			info, err = inspector.InspectImage(ctx, s.image)
+			if err != nil {
+				return fmt.Errorf("inspect %s: %w", s.image, err)
+			}
+			inspected[s.image] = info
+		}
+		drift := buildmanifest.Drift(expected, info.Labels)
+		status := "ok"
+		if len(drift) > 0 {
+			status = "drift"
+			drifted++
+		}
+		_, _ = fmt.Fprintf(w, "%s  image=%s digest=%s built=%s: %s\n", s.name, s.image, orUnknown(info.Digest), orUnknown(info.Labels[buildmanifest.LabelTime]), status)
+		for _, line := range drift {
+			_, _ = fmt.Fprintf(w, "  - %s\n", line)
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%d of %d runner images drifted from the local build inputs", drifted, len(subjects))
+	}
+	return nil
+}
+
+func orUnknown(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/buildmanifest"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
+	"pkt.systems/centaurx/internal/version"
+)
+
+const testContainerfile = "FROM debian:stable-slim\nRUN true\n"
+
+func stubResolveImageDigest(t *testing.T, fn func(context.Context, string) (string, error)) {
+	t.Helper()
+	prev := resolveImageDigest
+	resolveImageDigest = fn
+	t.Cleanup(func() { resolveImageDigest = prev })
+}
+
+func TestPrepareBuildInputsPinWritesBundle(t *testing.T) {
+	stubResolveImageDigest(t, func(context.Context, string) (string, error) { return "sha256:abc", nil })
+	dir := t.TempDir()
+	bundlePath := filepath.Join(dir, runnerBundleContainerfile)
+	if err := os.WriteFile(bundlePath, []byte("# local\n"+testContainerfile), 0o644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	shared := &buildSharedOptions{pin: true, bundleDir: dir}
+
+	containerfile, manifest, err := prepareBuildInputs(context.Background(), shared, "runner", runnerBundleContainerfile, []byte(testContainerfile), []string{"cxrunner:latest"}, "linux/amd64")
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if !strings.HasPrefix(string(containerfile), "FROM debian:stable-slim@sha256:abc\n") {
+		t.Fatalf("expected a pinned Containerfile, got %q", containerfile)
+	}
+	bundle, err := os.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	if string(bundle) != "# local\nFROM debian:stable-slim@sha256:abc\nRUN true\n" {
+		t.Fatalf("expected the bundle to be pinned in place, got %q", bundle)
+	}
+	got := manifest.Labels()
+	if got[buildmanifest.LabelBaseImages] != "debian:stable-slim@sha256:abc" || got[buildmanifest.LabelVersion] != version.Current() || got[buildmanifest.LabelTime] == "" {
+		t.Fatalf("unexpected labels %v", got)
+	}
+	if manifest.ContainerfileSHA256 != buildmanifest.ContainerfileDigest(containerfile) {
+		t.Fatalf("expected the digest of the built Containerfile")
+	}
+}
+
+func TestPrepareBuildInputsHonorsBundlePins(t *testing.T) {
+	stubResolveImageDigest(t, func(context.Context, string) (string, error) {
+		t.Fatalf("expected no registry lookup for pinned images")
+		return "", nil
+	})
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, runnerBundleContainerfile), []byte("FROM debian:stable-slim@sha256:def\n"), 0o644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	shared := &buildSharedOptions{bundleDir: dir}
+	containerfile, _, err := prepareBuildInputs(context.Background(), shared, "runner", runnerBundleContainerfile, []byte(testContainerfile), nil, "")
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if !strings.HasPrefix(string(containerfile), "FROM debian:stable-slim@sha256:def\n") {
+		t.Fatalf("expected the bundle pin to be applied, got %q", containerfile)
+	}
+
+	expected, err := expectedBuildLabels(shared, "runner", runnerBundleContainerfile, []byte(testContainerfile))
+	if err != nil {
+		t.Fatalf("expected labels: %v", err)
+	}
+	if expected[buildmanifest.LabelContainerfile] != buildmanifest.ContainerfileDigest(containerfile) {
+		t.Fatalf("expected verify to use the pinned Containerfile digest")
+	}
+}
+
+func TestPrepareBuildInputsResolveFailure(t *testing.T) {
+	stubResolveImageDigest(t, func(context.Context, string) (string, error) { return "", errors.New("offline") })
+	_, manifest, err := prepareBuildInputs(context.Background(), &buildSharedOptions{}, "server", serverBundleContainerfile, []byte(testContainerfile), nil, "")
+	if err != nil {
+		t.Fatalf("expected an unresolved digest to be tolerated without --pin: %v", err)
+	}
+	if manifest.BaseImages[0].Digest != "" {
+		t.Fatalf("expected no digest, got %+v", manifest.BaseImages)
+	}
+	if _, _, err := prepareBuildInputs(context.Background(), &buildSharedOptions{pin: true}, "server", serverBundleContainerfile, []byte(testContainerfile), nil, ""); err == nil {
+		t.Fatalf("expected --pin to fail when a digest cannot be resolved")
+	}
+}
+
+type verifyRuntime struct {
+	shipohoy.Runtime
+	containers []shipohoy.ManagedContainer
+	images     map[string]shipohoy.ImageInfo
+}
+
+func (r *verifyRuntime) ListManaged(context.Context, map[string]string) ([]shipohoy.ManagedContainer, error) {
+	return r.containers, nil
+}
+
+func (r *verifyRuntime) InspectImage(_ context.Context, image string) (shipohoy.ImageInfo, error) {
+	info, ok := r.images[image]
+	if !ok {
+		return shipohoy.ImageInfo{}, errors.New("image not found")
+	}
+	return info, nil
+}
+
+func TestVerifyRunnerImagesReportsDrift(t *testing.T) {
+	expected := buildmanifest.Manifest{Target: "runner", Version: "v1.2.0", BaseImages: []buildmanifest.BaseImage{{Ref: "debian:stable-slim"}}, ContainerfileSHA256: "abc"}.Labels()
+	stale := buildmanifest.Manifest{Target: "runner", Version: "v1.1.0", BaseImages: []buildmanifest.BaseImage{{Ref: "debian:stable-slim", Digest: "sha256:old"}}, ContainerfileSHA256: "abc"}.Labels()
+	current := buildmanifest.Manifest{Target: "runner", Version: "v1.2.0", BaseImages: []buildmanifest.BaseImage{{Ref: "debian:stable-slim", Digest: "sha256:new"}}, ContainerfileSHA256: "abc"}.Labels()
+	rt := &verifyRuntime{
+		containers: []shipohoy.ManagedContainer{
+			{Name: "cx-bob", Image: "cxrunner:old", State: shipohoy.ContainerRunning, Labels: labels.Runner("bob", "", "user", time.Unix(0, 0))},
+			{Name: "cx-alice", Image: "cxrunner:new", State: shipohoy.ContainerRunning, Labels: labels.Runner("alice", "", "user", time.Unix(0, 0))},
+			{Name: "cx-carol", Image: "cxrunner:old", State: shipohoy.ContainerStopped, Labels: labels.Runner("carol", "", "user", time.Unix(0, 0))},
+		},
+		images: map[string]shipohoy.ImageInfo{
+			"cxrunner:old": {Digest: "sha256:1", Labels: stale},
+			"cxrunner:new": {Digest: "sha256:2", Labels: current},
+		},
+	}
+	var out bytes.Buffer
+	err := verifyRunnerImages(context.Background(), &out, rt, expected, "cxrunner:new")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2") {
+		t.Fatalf("expected one drifted image, got %v", err)
+	}
+	text := out.String()
+	if !strings.Contains(text, "cx-alice  image=cxrunner:new digest=sha256:2") || !strings.Contains(text, "cx-bob  image=cxrunner:old digest=sha256:1 built=unknown: drift") {
+		t.Fatalf("unexpected report:\n%s", text)
+	}
+	if !strings.Contains(text, "expected v1.2.0, got v1.1.0") || strings.Contains(text, "cx-carol") {
+		t.Fatalf("unexpected report:\n%s", text)
+	}
+}
+
+func TestVerifyRunnerImagesFallsBackToConfiguredImage(t *testing.T) {
+	expected := buildmanifest.Manifest{Target: "runner", Version: "v1.2.0"}.Labels()
+	rt := &verifyRuntime{images: map[string]shipohoy.ImageInfo{"cxrunner:latest": {Digest: "sha256:3", Labels: expected}}}
+	var out bytes.Buffer
+	if err := verifyRunnerImages(context.Background(), &out, rt, expected, "cxrunner:latest"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !strings.Contains(out.String(), "(configured)  image=cxrunner:latest digest=sha256:3 built=unknown: ok") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -125,3 +126,48 @@ func TestBuildRunnerTagsDefault(t *testing.T) {
 		t.Fatalf("buildRunnerTags default = %v, want %v", tags, want)
 	}
 }
+
+func TestBuildPlatform(t *testing.T) {
+	got, err := buildPlatform(&buildSharedOptions{})
+	if err != nil || got != "" {
+		t.Fatalf("expected host platform, got %q %v", got, err)
+	}
+	got, err = buildPlatform(&buildSharedOptions{platform: " linux/aarch64 "})
+	if err != nil {
+		t.Fatalf("build platform: %v", err)
+	}
+	if got != "linux/arm64" {
+		t.Fatalf("expected normalized linux/arm64, got %q", got)
+	}
+	if _, err := buildPlatform(&buildSharedOptions{platform: "windows/amd64"}); err == nil {
+		t.Fatalf("expected non-linux platform to be rejected")
+	}
+	if _, err := buildPlatform(&buildSharedOptions{platform: "linux/nope/x/y"}); err == nil {
+		t.Fatalf("expected invalid platform to be rejected")
+	}
+}
+
+func TestEnsureBinaryPlatform(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("executable: %v", err)
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("test binary is not ELF")
+	}
+	host := "linux/" + runtime.GOARCH
+	if err := ensureBinaryPlatform(exe, host); err != nil {
+		t.Fatalf("expected test binary to match %s: %v", host, err)
+	}
+	other := "linux/arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "linux/amd64"
+	}
+	err = ensureBinaryPlatform(exe, other)
+	if err == nil || !strings.Contains(err.Error(), other) {
+		t.Fatalf("expected mismatch naming %s, got %v", other, err)
+	}
+	if isCrossPlatform("") || isCrossPlatform(host) || !isCrossPlatform(other) {
+		t.Fatalf("unexpected cross-platform detection for host %s", host)
+	}
+}
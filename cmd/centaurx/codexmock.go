@@ -21,10 +21,15 @@ import (
 
 func newCodexMockCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:           "codex-mock exec [--json] [--model <id>] [--seed <n>] [--scenario <name>] [--delay-ms <n>] [--linger-ms <n>] [resume <id>] [prompt|-]",
-		Short:         "Mock codex exec --json streams for testing",
-		SilenceErrors: true,
-		SilenceUsage:  true,
+		Use:   "codex-mock exec [--json] [--model <id>] [-c <key=value>] [--seed <n>] [--scenario <name|file>] [--record <file>] [--codex-bin <path>] [--delay-ms <n>] [--linger-ms <n>] [resume <id>] [prompt|-]",
+		Short: "Mock codex exec --json streams for testing",
+		Long: "Mock codex exec --json streams for testing.\n\n" +
+			"--scenario accepts a built-in scenario name or a JSON/YAML scenario file " +
+			"(also read from " + codexMockScenarioEnv + "). --record runs the real codex binary " +
+			"and captures its JSON stream into a scenario file.",
+		SilenceErrors:      true,
+		SilenceUsage:       true,
+		DisableFlagParsing: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runCodexMock(args, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
 		},
@@ -45,6 +50,10 @@ func runCodexMock(args []string, stdin io.Reader, stdout io.Writer, stderr io.Wr
 	}
 	cfg.prompt = prompt
 
+	if cfg.record != "" {
+		return runCodexRecord(cfg, stdout, stderr)
+	}
+
 	if !cfg.seedSet {
 		cfg.seed = hashSeed(cfg.prompt, cfg.resumeID, cfg.model, cfg.scenario)
 	}
@@ -70,6 +79,15 @@ func runCodexMock(args []string, stdin io.Reader, stdout io.Writer, stderr io.Wr
 		return nil
 	}
 
+	if isScenarioFile(cfg.scenario) {
+		file, err := loadScenarioFile(cfg.scenario)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+			return err
+		}
+		return runScenarioFile(cfg, file, threadID, writer, stderr, signalSeen)
+	}
+
 	if err := writeEvent(writer, map[string]any{
 		"type":      "thread.started",
 		"thread_id": threadID,
@@ -134,6 +152,9 @@ type mockConfig struct {
 	seed       uint64
 	seedSet    bool
 	scenario   string
+	overrides  []string
+	record     string
+	codexBin   string
 	delay      time.Duration
 	linger     time.Duration
 }
@@ -145,14 +166,16 @@ type mockScenario struct {
 
 func parseMockArgs(args []string) (mockConfig, error) {
 	if len(args) == 0 {
-		return mockConfig{}, errors.New("usage: codex-mock exec [--json] [--model <id>] [--seed <n>] [--scenario <name>] [--delay-ms <n>] [--linger-ms <n>] [resume <id>] [prompt|-]")
+		return mockConfig{}, errors.New("usage: codex-mock exec [--json] [--model <id>] [-c <key=value>] [--seed <n>] [--scenario <name|file>] [--record <file>] [--codex-bin <path>] [--delay-ms <n>] [--linger-ms <n>] [resume <id>] [prompt|-]")
 	}
 	if args[0] != "exec" {
 		return mockConfig{}, fmt.Errorf("unsupported command: %s", args[0])
 	}
 	cfg := mockConfig{
-		delay:  30 * time.Millisecond,
-		linger: 0,
+		scenario: strings.TrimSpace(os.Getenv(codexMockScenarioEnv)),
+		codexBin: "codex",
+		delay:    30 * time.Millisecond,
+		linger:   0,
 	}
 	args = args[1:]
 	for len(args) > 0 {
@@ -204,6 +227,24 @@ func parseMockArgs(args []string) (mockConfig, error) {
 			}
 			cfg.scenario = args[1]
 			args = args[2:]
+		case "-c", "--config":
+			if len(args) < 2 {
+				return mockConfig{}, fmt.Errorf("%s requires a value", args[0])
+			}
+			cfg.overrides = append(cfg.overrides, args[1])
+			args = args[2:]
+		case "--record":
+			if len(args) < 2 {
+				return mockConfig{}, errors.New("--record requires a file path")
+			}
+			cfg.record = args[1]
+			args = args[2:]
+		case "--codex-bin":
+			if len(args) < 2 {
+				return mockConfig{}, errors.New("--codex-bin requires a value")
+			}
+			cfg.codexBin = args[1]
+			args = args[2:]
 		case "--delay-ms":
 			if len(args) < 2 {
 				return mockConfig{}, errors.New("--delay-ms requires a value")
@@ -292,6 +333,7 @@ func buildScenarios() []mockScenario {
 		{name: "websearch", run: scenarioWebSearch},
 		{name: "todo", run: scenarioTodo},
 		{name: "failure", run: scenarioFailure},
+		{name: "reasoning", run: scenarioReasoning},
 	}
 }
 
@@ -428,6 +470,37 @@ func scenarioTodo(cfg mockConfig, w *bufio.Writer) error {
 	}, cfg.delay)
 }
 
+func scenarioReasoning(cfg mockConfig, w *bufio.Writer) error {
+	if err := writeItem(w, "item.started", "item_0", map[string]any{
+		"type": "reasoning",
+		"text": "**Planning**",
+	}, cfg.delay); err != nil {
+		return err
+	}
+	if err := writeItem(w, "item.updated", "item_0", map[string]any{
+		"type": "reasoning",
+		"text": "**Planning**\n\nLooking at the repo layout.",
+	}, cfg.delay); err != nil {
+		return err
+	}
+	if err := writeItem(w, "item.completed", "item_0", map[string]any{
+		"type": "reasoning",
+		"text": "**Planning**\n\nLooking at the repo layout.\nThen answering.",
+	}, cfg.delay); err != nil {
+		return err
+	}
+	if err := writeItem(w, "item.completed", "item_0", map[string]any{
+		"type": "reasoning",
+		"text": "**Planning**\n\nLooking at the repo layout.\nThen answering.",
+	}, cfg.delay); err != nil {
+		return err
+	}
+	return writeItem(w, "item.completed", "item_1", map[string]any{
+		"type": "agent_message",
+		"text": "Reasoned about the request.",
+	}, cfg.delay)
+}
+
 func scenarioFailure(cfg mockConfig, w *bufio.Writer) error {
 	if err := writeItem(w, "item.completed", "item_0", map[string]any{
 		"type": "reasoning",
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// runCodexRecord runs the real codex binary, mirrors its output, and writes
+// the captured JSON stream to cfg.record as a scenario file.
+func runCodexRecord(cfg mockConfig, stdout io.Writer, stderr io.Writer) error {
+	cmd := exec.Command(cfg.codexBin, recordArgs(cfg)...)
+	cmd.Stdin = strings.NewReader(cfg.prompt)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderrBuf bytes.Buffer
+	cmd.Stderr = io.MultiWriter(stderr, &stderrBuf)
+	if err := cmd.Start(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "start %s: %v\n", cfg.codexBin, err)
+		return err
+	}
+
+	file := mockScenarioFile{Name: "recorded"}
+	reader := bufio.NewReader(out)
+	last := time.Now()
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			_, _ = stdout.Write(line)
+			now := time.Now()
+			var event map[string]any
+			if err := json.Unmarshal(bytes.TrimSpace(line), &event); err != nil {
+				_, _ = fmt.Fprintf(stderr, "record: skipping non-JSON line: %v\n", err)
+			} else {
+				if event["type"] == "thread.started" {
+					delete(event, "thread_id")
+				}
+				file.Events = append(file.Events, mockScenarioEvent{
+					DelayMS: int(now.Sub(last).Milliseconds()),
+					Event:   event,
+				})
+				last = now
+			}
+		}
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				return readErr
+			}
+			break
+		}
+	}
+	waitErr := cmd.Wait()
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return waitErr
+	}
+	if exitErr != nil {
+		file.ExitCode = exitErr.ExitCode()
+	}
+	file.Stderr = stderrBuf.String()
+	if err := writeScenarioFile(cfg.record, file); err != nil {
+		_, _ = fmt.Fprintf(stderr, "write scenario: %v\n", err)
+		return err
+	}
+	if file.ExitCode != 0 {
+		return &mockExitError{code: file.ExitCode}
+	}
+	return nil
+}
+
+func recordArgs(cfg mockConfig) []string {
+	args := []string{"exec", "--json"}
+	if cfg.model != "" {
+		args = append(args, "--model", cfg.model)
+	}
+	for _, override := range cfg.overrides {
+		args = append(args, "-c", override)
+	}
+	if cfg.resumeID != "" {
+		args = append(args, "resume", cfg.resumeID)
+	}
+	return append(args, "-")
+}
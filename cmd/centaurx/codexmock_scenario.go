@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// codexMockScenarioEnv selects a scenario when --scenario is not passed, which
+// lets the runner invoke codex-mock with the exact argv it uses for codex.
+const codexMockScenarioEnv = "CODEX_MOCK_SCENARIO"
+
+// mockScenarioFile describes a scripted codex exec --json stream.
+type mockScenarioFile struct {
+	Name      string                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Events    []mockScenarioEvent    `json:"events,omitempty" yaml:"events,omitempty"`
+	Stderr    string                 `json:"stderr,omitempty" yaml:"stderr,omitempty"`
+	ExitCode  int                    `json:"exit_code,omitempty" yaml:"exit_code,omitempty"`
+	Responses []mockScenarioResponse `json:"responses,omitempty" yaml:"responses,omitempty"`
+}
+
+// mockScenarioResponse overrides the default stream when Match matches the prompt.
+type mockScenarioResponse struct {
+	Match    string              `json:"match" yaml:"match"`
+	Events   []mockScenarioEvent `json:"events,omitempty" yaml:"events,omitempty"`
+	Stderr   string              `json:"stderr,omitempty" yaml:"stderr,omitempty"`
+	ExitCode int                 `json:"exit_code,omitempty" yaml:"exit_code,omitempty"`
+}
+
+// mockScenarioEvent is a single JSONL event emitted after an optional delay.
+type mockScenarioEvent struct {
+	DelayMS int            `json:"delay_ms,omitempty" yaml:"delay_ms,omitempty"`
+	Event   map[string]any `json:"event" yaml:"event"`
+}
+
+type mockExitError struct {
+	code int
+}
+
+func (e *mockExitError) Error() string {
+	return fmt.Sprintf("codex-mock exited with code %d", e.code)
+}
+
+func isScenarioFile(name string) bool {
+	if name == "" {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return strings.ContainsRune(name, os.PathSeparator)
+}
+
+func loadScenarioFile(path string) (mockScenarioFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mockScenarioFile{}, fmt.Errorf("read scenario: %w", err)
+	}
+	var file mockScenarioFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return mockScenarioFile{}, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	for i, resp := range file.Responses {
+		if _, err := regexp.Compile(resp.Match); err != nil {
+			return mockScenarioFile{}, fmt.Errorf("scenario %s: response %d: invalid match: %w", path, i, err)
+		}
+	}
+	return file, nil
+}
+
+func writeScenarioFile(path string, file mockScenarioFile) error {
+	var (
+		data []byte
+		err  error
+	)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = json.MarshalIndent(file, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(file)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// selectResponse returns the stream for the first response whose match
+// expression matches the prompt, falling back to the top-level stream.
+func (f mockScenarioFile) selectResponse(prompt string) mockScenarioResponse {
+	for _, resp := range f.Responses {
+		if regexp.MustCompile(resp.Match).MatchString(prompt) {
+			return resp
+		}
+	}
+	return mockScenarioResponse{Events: f.Events, Stderr: f.Stderr, ExitCode: f.ExitCode}
+}
+
+func runScenarioFile(cfg mockConfig, file mockScenarioFile, threadID string, w *bufio.Writer, stderr io.Writer, signals <-chan os.Signal) error {
+	resp := file.selectResponse(cfg.prompt)
+	for _, entry := range resp.Events {
+		if entry.DelayMS > 0 {
+			timer := time.NewTimer(time.Duration(entry.DelayMS) * time.Millisecond)
+			select {
+			case sig := <-signals:
+				timer.Stop()
+				return emitSignalError(w, sig)
+			case <-timer.C:
+			}
+		}
+		event := make(map[string]any, len(entry.Event)+1)
+		for key, value := range entry.Event {
+			event[key] = value
+		}
+		if event["type"] == "thread.started" {
+			if id, _ := event["thread_id"].(string); id == "" {
+				event["thread_id"] = threadID
+			}
+		}
+		if err := writeEvent(w, event); err != nil {
+			return err
+		}
+	}
+	if resp.Stderr != "" {
+		_, _ = io.WriteString(stderr, resp.Stderr)
+		if !strings.HasSuffix(resp.Stderr, "\n") {
+			_, _ = io.WriteString(stderr, "\n")
+		}
+	}
+	if resp.ExitCode != 0 {
+		return &mockExitError{code: resp.ExitCode}
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -25,3 +29,102 @@ func TestParseMockArgsResumeOrdering(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestParseMockArgsAcceptsCodexFlags(t *testing.T) {
+	cfg, err := parseMockArgs([]string{"exec", "--json", "--model", "gpt-5.2-codex", "-c", "model_reasoning_effort=high", "--scenario", "happy.yaml", "-"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.overrides) != 1 || cfg.overrides[0] != "model_reasoning_effort=high" {
+		t.Fatalf("unexpected overrides: %v", cfg.overrides)
+	}
+	if cfg.scenario != "happy.yaml" {
+		t.Fatalf("unexpected scenario: %q", cfg.scenario)
+	}
+}
+
+func TestRunCodexMockScenarioFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	data := `name: test
+events:
+  - event: {type: thread.started}
+  - event:
+      type: item.completed
+      item: {id: item_0, type: agent_message, text: default}
+responses:
+  - match: "(?i)fail"
+    events:
+      - event: {type: turn.failed, error: {message: boom}}
+    stderr: "fatal"
+    exit_code: 3
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write scenario: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := runCodexMock([]string{"exec", "--json", "--scenario", path, "hello"}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatalf("run default: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 events, got %v", lines)
+	}
+	if !strings.Contains(lines[0], `"thread_id":"mock-`) {
+		t.Fatalf("expected generated thread id, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"text":"default"`) {
+		t.Fatalf("expected default response, got %s", lines[1])
+	}
+
+	stdout.Reset()
+	err := runCodexMock([]string{"exec", "--json", "--scenario", path, "please FAIL"}, strings.NewReader(""), &stdout, &stderr)
+	var exitErr *mockExitError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"turn.failed"`) {
+		t.Fatalf("expected matched response, got %s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "fatal") {
+		t.Fatalf("expected stderr output, got %q", stderr.String())
+	}
+}
+
+func TestRunCodexMockRecord(t *testing.T) {
+	dir := t.TempDir()
+	fake := filepath.Join(dir, "codex")
+	script := `#!/bin/sh
+cat >/dev/null
+echo '{"type":"thread.started","thread_id":"real-thread"}'
+echo '{"type":"item.completed","item":{"id":"item_0","type":"agent_message","text":"recorded"}}'
+echo 'warning: something' >&2
+exit 2
+`
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake codex: %v", err)
+	}
+	out := filepath.Join(dir, "recorded.json")
+	var stdout, stderr bytes.Buffer
+	err := runCodexMock([]string{"exec", "--json", "--record", out, "--codex-bin", fake, "hello"}, strings.NewReader(""), &stdout, &stderr)
+	var exitErr *mockExitError
+	if !errors.As(err, &exitErr) || exitErr.code != 2 {
+		t.Fatalf("expected exit code 2, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "recorded") {
+		t.Fatalf("expected mirrored stdout, got %q", stdout.String())
+	}
+	file, err := loadScenarioFile(out)
+	if err != nil {
+		t.Fatalf("load recorded scenario: %v", err)
+	}
+	if len(file.Events) != 2 || file.ExitCode != 2 {
+		t.Fatalf("unexpected recording: %+v", file)
+	}
+	if _, ok := file.Events[0].Event["thread_id"]; ok {
+		t.Fatalf("expected thread id to be stripped, got %v", file.Events[0].Event)
+	}
+	if !strings.Contains(file.Stderr, "warning: something") {
+		t.Fatalf("expected stderr captured, got %q", file.Stderr)
+	}
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
+	"pkt.systems/centaurx/internal/admin"
 	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/statereport"
+	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
 )
 
@@ -17,9 +25,147 @@ func newDebugCmd() *cobra.Command {
 		Short: "Debug helpers for centaurx",
 	}
 	cmd.AddCommand(newDebugRunnerCmd())
+	cmd.AddCommand(newDebugVerifyStateCmd())
+	cmd.AddCommand(newDebugLogCaptureCmd())
+	cmd.AddCommand(newDebugStateCmd())
 	return cmd
 }
 
+func newDebugStateCmd() *cobra.Command {
+	var cfgPath string
+	var user string
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Show the state directory layout and per-user disk usage",
+		Long:  "Show the state directory layout and per-user disk usage: snapshot and home sizes, whether a codex auth.json and a git SSH key exist, and totals. Entries that cannot be read are listed and left out of the totals.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := appconfig.Load(cfgPath)
+			if err != nil {
+				return err
+			}
+			var users []schema.UserID
+			if strings.TrimSpace(user) != "" {
+				userID := schema.UserID(strings.TrimSpace(user))
+				if err := schema.ValidateUserID(userID); err != nil {
+					return err
+				}
+				users = []schema.UserID{userID}
+			} else {
+				store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, cfg.Auth.SeedUsers, pslog.Ctx(cmd.Context()))
+				if err != nil {
+					return err
+				}
+				for _, record := range store.LoadUsers() {
+					users = append(users, schema.UserID(record.Username))
+				}
+			}
+			paths := statereport.Paths{StateDir: cfg.StateDir, GitKeyDir: cfg.SSH.KeyDir}
+			out := cmd.OutOrStdout()
+			for _, line := range statereport.Build(paths, users).Lines() {
+				_, _ = fmt.Fprintln(out, line)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
+	cmd.Flags().StringVar(&user, "user", "", "only report this user")
+	return cmd
+}
+
+func newDebugLogCaptureCmd() *cobra.Command {
+	var cfgPath string
+	cmd := &cobra.Command{
+		Use:   "logcapture",
+		Short: "Show the in-memory runner log capture usage of the running server",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := adminClient(cfgPath)
+			if err != nil {
+				return err
+			}
+			stats, err := client.LogCapture(cmd.Context())
+			if err != nil {
+				return err
+			}
+			printLogCapture(cmd.OutOrStdout(), stats)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
+	return cmd
+}
+
+// printLogCapture writes the totals followed by one line per container.
+func printLogCapture(w io.Writer, stats admin.LogCapture) {
+	limit := "none"
+	if stats.LimitBytes > 0 {
+		limit = fmt.Sprintf("%d", stats.LimitBytes)
+	}
+	_, _ = fmt.Fprintf(w, "%d container(s), capacity %d bytes, used %d bytes, limit %s\n", len(stats.Containers), stats.CapacityBytes, stats.UsedBytes, limit)
+	if len(stats.Containers) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CONTAINER\tCAPACITY\tUSED\tCREATED\tLAST USED\tSTATE")
+	for _, capture := range stats.Containers {
+		state := "ok"
+		switch {
+		case capture.Shrunk && capture.CapacityBytes == 0:
+			state = "dropped"
+		case capture.Shrunk:
+			state = "shrunk"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n", capture.Name, capture.CapacityBytes, capture.UsedBytes, formatLastUsed(capture.Created), formatLastUsed(capture.LastUsed), state)
+	}
+	_ = tw.Flush()
+}
+
+func newDebugVerifyStateCmd() *cobra.Command {
+	var cfgPath string
+	cmd := &cobra.Command{
+		Use:   "verify-state",
+		Short: "Validate persisted user state in the state directory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := appconfig.Load(cfgPath)
+			if err != nil {
+				return err
+			}
+			results, err := persist.VerifyDir(cfg.StateDir, cfg.Auth.UserFile, cfg.Auth.LockoutFile, cfg.HTTP.SessionStorePath)
+			if err != nil {
+				return err
+			}
+			if failed := printStateReport(cmd.OutOrStdout(), cfg.StateDir, results); failed > 0 {
+				return fmt.Errorf("%d state file(s) failed verification", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
+	return cmd
+}
+
+// printStateReport writes one line per verified file and returns the number
+// of files that failed.
+func printStateReport(w io.Writer, dir string, results []persist.VerifyResult) int {
+	failed := 0
+	for _, result := range results {
+		name := result.Path
+		if rel, err := filepath.Rel(dir, result.Path); err == nil {
+			name = rel
+		}
+		switch {
+		case result.Quarantined:
+			_, _ = fmt.Fprintf(w, "SKIP  %s (quarantined corrupt file)\n", name)
+		case result.Err != nil:
+			failed++
+			_, _ = fmt.Fprintf(w, "FAIL  %s: %v\n", name, result.Err)
+		default:
+			_, _ = fmt.Fprintf(w, "OK    %s (v%d, %d tabs)\n", name, result.Version, result.Tabs)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d file(s) checked in %s, %d failed\n", len(results), dir, failed)
+	return failed
+}
+
 func newDebugRunnerCmd() *cobra.Command {
 	var cfgPath string
 	var user string
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -13,12 +14,15 @@ import (
 	"github.com/spf13/cobra"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/admin"
 	"pkt.systems/centaurx/internal/appconfig"
 	"pkt.systems/centaurx/internal/runnercontainer"
+	"pkt.systems/centaurx/internal/shipohoy"
 	"pkt.systems/centaurx/internal/sshagent"
 	"pkt.systems/centaurx/internal/sshkeys"
 	"pkt.systems/centaurx/internal/userhome"
 	"pkt.systems/centaurx/schema"
+	"pkt.systems/centaurx/sshserver"
 	"pkt.systems/pslog"
 )
 
@@ -28,15 +32,36 @@ func newDoctorCmd() *cobra.Command {
 	var codexPrompt string
 	var commandTimeout time.Duration
 	var codexTimeout time.Duration
+	var full bool
+	var jsonOut bool
 	cmd := &cobra.Command{
 		Use:   "doctor",
 		Short: "Run centaurx diagnostics",
+		Long: "Run centaurx diagnostics against the configured runner.\n\n" +
+			"--full runs an end-to-end self-test: it creates a throwaway user and tab in a temporary " +
+			"state dir, starts a runner container, runs `echo ok` and a codex-mock exec inside it, " +
+			"verifies the tab buffer fills, and tears everything down. Each step prints PASS/FAIL " +
+			"and the command exits non-zero if any step fails.\n\n" +
+			"--json prints the checks as an array of {name, status, detail, hint} instead, where " +
+			"status is pass, fail or skip, detail holds the error of a failed check and hint how to " +
+			"fix it. Checks after a failed one are skipped, and the command exits non-zero if any " +
+			"check fails.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logger := pslog.Ctx(cmd.Context())
+			out := cmd.OutOrStdout()
 
 			cfg, err := appconfig.Load(cfgPath)
 			if err != nil {
-				return err
+				if !jsonOut {
+					return err
+				}
+				report := &doctorReport{w: out, json: true}
+				report.run(cmd.Context(), doctorStep{
+					name: "config",
+					hint: "fix the config file or pass its path with --config",
+					run:  func(context.Context) error { return err },
+				}, false)
+				return report.finish()
 			}
 			configPath := cfgPath
 			if strings.TrimSpace(configPath) == "" {
@@ -46,113 +71,308 @@ func newDoctorCmd() *cobra.Command {
 				}
 				configPath = path
 			}
-			logger.Info("doctor start", "config", configPath)
-
-			if err := validateRunnerConfig(cfg); err != nil {
-				return err
+			logger.Info("doctor start", "config", configPath, "full", full)
+			if full {
+				return runDoctorFull(cmd.Context(), out, cfg, doctorFullConfig{
+					User:           user,
+					CommandTimeout: commandTimeout,
+					CodexTimeout:   codexTimeout,
+					JSON:           jsonOut,
+				})
 			}
+			return runDoctor(cmd.Context(), &doctorReport{w: out, json: jsonOut}, cfg, doctorConfig{
+				User:           user,
+				CodexPrompt:    codexPrompt,
+				CommandTimeout: commandTimeout,
+				CodexTimeout:   codexTimeout,
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
+	cmd.Flags().StringVar(&user, "user", "doctor", "runner username for diagnostics")
+	cmd.Flags().StringVar(&codexPrompt, "codex-prompt", "Say 'ok' and exit.", "prompt used for codex exec test")
+	cmd.Flags().DurationVar(&commandTimeout, "command-timeout", 15*time.Second, "timeout for command checks")
+	cmd.Flags().DurationVar(&codexTimeout, "codex-timeout", 90*time.Second, "timeout for codex exec check")
+	cmd.Flags().BoolVar(&full, "full", false, "run the end-to-end self-test with a throwaway user, tab and runner")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the checks as JSON")
+	return cmd
+}
 
-			rt, closeFn, err := selectRuntime(cmd.Context(), cfg)
-			if err != nil {
-				return err
-			}
-			if closeFn != nil {
-				defer func() { _ = closeFn() }()
-			}
+// doctorConfig configures the default diagnostics.
+type doctorConfig struct {
+	User           string
+	CodexPrompt    string
+	CommandTimeout time.Duration
+	CodexTimeout   time.Duration
+}
 
-			if err := verifyRunnerImage(cmd.Context(), rt, cfg.Runner.Image); err != nil {
-				return err
-			}
-			logger.Info("doctor runner image ok", "image", cfg.Runner.Image)
+// runDoctor checks the configuration, the runtime and a runner for the
+// configured user. Without JSON output it stops at the first failing check
+// and returns its error; the checks log what they find.
+func runDoctor(ctx context.Context, report *doctorReport, cfg appconfig.Config, doctorCfg doctorConfig) error {
+	logger := pslog.Ctx(ctx)
+	userID := schema.UserID(doctorCfg.User)
 
-			if err := verifyRunnerRuntime(cmd.Context(), rt, cfg); err != nil {
-				return err
-			}
-			logger.Info("doctor runner runtime ok", "binary", cfg.Runner.Binary)
+	var (
+		rt             shipohoy.Runtime
+		closeRT        func() error
+		agentManager   *sshagent.Manager
+		runnerProvider *runnercontainer.Provider
+		tabID          schema.TabID
+		resp           core.RunnerResponse
+		workDir        string
+	)
+	defer func() {
+		if tabID != "" {
+			_ = runnerProvider.CloseTab(context.Background(), core.RunnerCloseRequest{UserID: userID, TabID: tabID})
+		}
+		if agentManager != nil {
+			_ = agentManager.Close()
+		}
+		if closeRT != nil {
+			_ = closeRT()
+		}
+	}()
 
-			keyStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
-			if err != nil {
-				return err
-			}
-			agentManager, err := sshagent.NewManagerWithLogger(keyStore, cfg.SSH.AgentDir, logger)
-			if err != nil {
-				return err
-			}
-			defer func() { _ = agentManager.Close() }()
-
-			runnerProvider, err := runnercontainer.NewProvider(cmd.Context(), runnercontainer.Config{
-				Image:          cfg.Runner.Image,
-				RepoRoot:       cfg.RepoRoot,
-				RunnerRepoRoot: cfg.Runner.RepoRoot,
-				HostRepoRoot:   cfg.Runner.HostRepoRoot,
-				HostStateDir:   cfg.Runner.HostStateDir,
-				SockDir:        cfg.Runner.SockDir,
-				StateDir:       cfg.StateDir,
-				SkelData:       userhome.DefaultTemplateData(cfg),
-				SSHAgentDir:    cfg.SSH.AgentDir,
-				RunnerBinary:   cfg.Runner.Binary,
-				RunnerArgs:     cfg.Runner.Args,
-				RunnerEnv:      cfg.Runner.Env,
-				GitSSHDebug:    cfg.Runner.GitSSHDebug,
-				IdleTimeout:    0,
-			}, rt, agentManager)
-			if err != nil {
+	steps := []doctorStep{
+		{
+			name: "runner config",
+			hint: "check runner.* settings in the config file",
+			run: func(context.Context) error {
+				return validateRunnerConfig(cfg)
+			},
+		},
+		{
+			name: "runner security",
+			hint: "runner.security.seccomp_profile must be a readable JSON seccomp profile",
+			run: func(context.Context) error {
+				return checkRunnerSecurity(logger, cfg)
+			},
+		},
+		{
+			name: "admin socket",
+			hint: "stop the server and remove the admin socket; the server recreates it with mode 0600",
+			run: func(stepCtx context.Context) error {
+				return checkAdminSocket(stepCtx, logger, cfg.AdminSocketPath())
+			},
+		},
+		{
+			name: "ssh host keys",
+			hint: "ssh.host_key_path and ssh.host_keys must name readable private keys",
+			run: func(context.Context) error {
+				return checkSSHHostKeys(logger, cfg.SSH)
+			},
+		},
+		{
+			name: "container runtime",
+			hint: "ensure the runtime socket (runner.podman.address or runner.containerd.address) is reachable",
+			run: func(stepCtx context.Context) error {
+				var err error
+				rt, closeRT, err = selectRuntime(stepCtx, cfg)
 				return err
-			}
+			},
+		},
+		{
+			name: "runner image",
+			hint: "build or pull the image with `centaurx build runner`",
+			run: func(stepCtx context.Context) error {
+				if err := verifyRunnerImage(stepCtx, rt, cfg.Runner.Image); err != nil {
+					return err
+				}
+				logger.Info("doctor runner image ok", "image", cfg.Runner.Image)
+				return nil
+			},
+		},
+		{
+			name: "runner runtime",
+			hint: "runner.binary must be installed in the runner image",
+			run: func(stepCtx context.Context) error {
+				if err := verifyRunnerRuntime(stepCtx, rt, cfg); err != nil {
+					return err
+				}
+				logger.Info("doctor runner runtime ok", "binary", cfg.Runner.Binary)
+				return nil
+			},
+		},
+		{
+			name: "runner container",
+			hint: "the runner socket did not come up; check runner.sock_dir and host_state_dir mappings",
+			run: func(stepCtx context.Context) error {
+				keyStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
+				if err != nil {
+					return err
+				}
+				agentManager, err = sshagent.NewManagerWithLogger(keyStore, cfg.SSH.AgentDir, logger)
+				if err != nil {
+					return err
+				}
+				runnerProvider, err = runnercontainer.NewProvider(stepCtx, runnercontainer.Config{
+					Image:          cfg.Runner.Image,
+					RepoRoot:       cfg.RepoRoot,
+					RunnerRepoRoot: cfg.Runner.RepoRoot,
+					HostRepoRoot:   cfg.Runner.HostRepoRoot,
+					HostStateDir:   cfg.Runner.HostStateDir,
+					SockDir:        cfg.Runner.SockDir,
+					StateDir:       cfg.StateDir,
+					SkelData:       userhome.DefaultTemplateData(cfg),
+					SSHAgentDir:    cfg.SSH.AgentDir,
+					RunnerBinary:   cfg.Runner.Binary,
+					RunnerArgs:     cfg.Runner.Args,
+					RunnerEnv:      cfg.Runner.Env,
+					Mounts:         runnerMounts(cfg),
+					GitSSHDebug:    cfg.Runner.GitSSHDebug,
+					IdleTimeout:    0,
+					Security:       runnerSecurity(cfg),
+					AllowedHosts:   cfg.Runner.Network.AllowedHosts,
+				}, rt, agentManager)
+				if err != nil {
+					return err
+				}
 
-			repoName := fmt.Sprintf("doctor-%d", time.Now().UnixNano())
-			hostRepoPath := filepath.Join(cfg.RepoRoot, repoName)
-			if err := os.MkdirAll(hostRepoPath, 0o755); err != nil {
-				return fmt.Errorf("doctor repo: %w", err)
-			}
+				repoName := fmt.Sprintf("doctor-%d", time.Now().UnixNano())
+				hostRepoPath := filepath.Join(cfg.RepoRoot, repoName)
+				if err := os.MkdirAll(hostRepoPath, 0o755); err != nil {
+					return fmt.Errorf("doctor repo: %w", err)
+				}
 
-			tabID := schema.TabID(fmt.Sprintf("doctor-%d", time.Now().UnixNano()))
-			resp, err := runnerProvider.RunnerFor(cmd.Context(), core.RunnerRequest{
-				UserID: schema.UserID(user),
-				TabID:  tabID,
-			})
-			if err != nil {
-				return err
-			}
-			defer func() {
-				_ = runnerProvider.CloseTab(context.Background(), core.RunnerCloseRequest{
-					UserID: schema.UserID(user),
-					TabID:  tabID,
+				id := schema.TabID(fmt.Sprintf("doctor-%d", time.Now().UnixNano()))
+				resp, err = runnerProvider.RunnerFor(stepCtx, core.RunnerRequest{
+					UserID: userID,
+					TabID:  id,
 				})
-			}()
+				if err != nil {
+					return err
+				}
+				tabID = id
+				workDir = path.Join(resp.Info.RepoRoot, repoName)
+				logger.Info("doctor runner ready", "user", doctorCfg.User, "tab", tabID, "workdir", workDir)
+				return nil
+			},
+		},
+		{
+			name: "runner commands",
+			hint: "commands run via bash inside the runner image; ensure bash and git are installed",
+			run: func(stepCtx context.Context) error {
+				for _, command := range []string{"pwd", "git init -q", "git status --porcelain"} {
+					if _, err := runDoctorCommand(stepCtx, logger, resp.Runner, resp.Info.SSHAuthSock, workDir, command, doctorCfg.CommandTimeout); err != nil {
+						return err
+					}
+				}
+				logger.Info("doctor command checks ok")
+				return nil
+			},
+		},
+		{
+			name: "codex exec",
+			hint: "check the codex login of the user and that models.default is available to the account",
+			run: func(stepCtx context.Context) error {
+				return runDoctorCodex(stepCtx, logger, resp.Runner, resp.Info.SSHAuthSock, workDir, doctorCfg.CodexPrompt, schema.ModelID(cfg.Models.Default), doctorCfg.CodexTimeout)
+			},
+		},
+	}
 
-			workDir := path.Join(resp.Info.RepoRoot, repoName)
-			logger.Info("doctor runner ready", "user", user, "tab", tabID, "workdir", workDir)
+	if report.json {
+		ok := true
+		for _, step := range steps {
+			ok = report.run(ctx, step, !ok)
+		}
+		return report.finish()
+	}
+	for _, step := range steps {
+		if err := step.run(ctx); err != nil {
+			return err
+		}
+	}
+	logger.Info("doctor complete")
+	return nil
+}
 
-			if err := runDoctorCommand(cmd.Context(), logger, resp.Runner, resp.Info.SSHAuthSock, workDir, "pwd", commandTimeout); err != nil {
-				return err
-			}
-			if err := runDoctorCommand(cmd.Context(), logger, resp.Runner, resp.Info.SSHAuthSock, workDir, "git init -q", commandTimeout); err != nil {
-				return err
-			}
-			if err := runDoctorCommand(cmd.Context(), logger, resp.Runner, resp.Info.SSHAuthSock, workDir, "git status --porcelain", commandTimeout); err != nil {
-				return err
-			}
-			logger.Info("doctor command checks ok")
+// checkRunnerSecurity reports the hardening options applied to runner
+// containers. containerd loads a seccomp profile file in this process, so the
+// file is checked here; podman resolves the path itself.
+func checkRunnerSecurity(logger pslog.Logger, cfg appconfig.Config) error {
+	security := runnerSecurity(cfg)
+	logger.Info("doctor runner security", "active", strings.Join(security.Active(), ", "))
+	if hosts := cfg.Runner.Network.AllowedHosts; len(hosts) > 0 {
+		logger.Info("doctor runner egress control on", "allowed_hosts", strings.Join(hosts, ", "))
+	} else {
+		logger.Info("doctor runner egress control off")
+	}
+	profile := security.SeccompProfile
+	if cfg.Runner.Runtime != "containerd" || profile == "" || profile == shipohoy.SeccompDefault || profile == shipohoy.SeccompUnconfined {
+		return nil
+	}
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		return fmt.Errorf("runner.security.seccomp_profile: %w", err)
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("runner.security.seccomp_profile %s is not valid JSON", profile)
+	}
+	return nil
+}
 
-			if err := runDoctorCodex(cmd.Context(), logger, resp.Runner, resp.Info.SSHAuthSock, workDir, codexPrompt, schema.ModelID(cfg.Models.Default), codexTimeout); err != nil {
-				return err
-			}
-			logger.Info("doctor complete")
-			return nil
-		},
+// checkSSHHostKeys prints the fingerprints of the SSH host keys, so they can
+// be published for users to compare with. Missing keys are left for the
+// server to generate.
+func checkSSHHostKeys(logger pslog.Logger, cfg appconfig.SSHConfig) error {
+	for _, path := range append([]string{cfg.HostKeyPath}, cfg.HostKeys...) {
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Info("doctor ssh host key missing; generated at first start", "path", path)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("doctor ssh host key: %w", err)
+		}
+		if perm := info.Mode().Perm(); perm&0o077 != 0 {
+			logger.Warn("doctor ssh host key readable by others", "path", path, "mode", fmt.Sprintf("%04o", perm))
+		}
+		key, err := sshserver.LoadHostKey(path)
+		if err != nil {
+			return fmt.Errorf("doctor ssh host key %s: %w", path, err)
+		}
+		logger.Info("doctor ssh host key", "path", path, "type", key.Type(), "fingerprint", key.Fingerprint())
 	}
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
-	cmd.Flags().StringVar(&user, "user", "doctor", "runner username for diagnostics")
-	cmd.Flags().StringVar(&codexPrompt, "codex-prompt", "Say 'ok' and exit.", "prompt used for codex exec test")
-	cmd.Flags().DurationVar(&commandTimeout, "command-timeout", 15*time.Second, "timeout for command checks")
-	cmd.Flags().DurationVar(&codexTimeout, "codex-timeout", 90*time.Second, "timeout for codex exec check")
-	return cmd
+	return nil
+}
+
+// checkAdminSocket verifies the admin socket of a running server: it must be
+// a socket only its owner can use, and the server must answer on it. A
+// missing socket only means no server is running.
+func checkAdminSocket(ctx context.Context, logger pslog.Logger, socketPath string) error {
+	info, err := os.Lstat(socketPath)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Info("doctor admin socket absent; server not running", "socket", socketPath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("doctor admin socket: %w", err)
+	}
+	if info.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("doctor admin socket %s is not a socket", socketPath)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("doctor admin socket %s has mode %04o; want 0600", socketPath, perm)
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	runners, err := admin.NewClient(socketPath).ListRunners(checkCtx)
+	if err != nil {
+		logger.Warn("doctor admin socket not answering; stale socket from a stopped server?", "socket", socketPath, "err", err)
+		return nil
+	}
+	logger.Info("doctor admin socket ok", "socket", socketPath, "runners", len(runners))
+	return nil
 }
 
-func runDoctorCommand(ctx context.Context, logger pslog.Logger, runner core.Runner, sshSock, workDir, cmd string, timeout time.Duration) error {
+// runDoctorCommand runs cmd in the runner and returns its stdout.
+func runDoctorCommand(ctx context.Context, logger pslog.Logger, runner core.Runner, sshSock, workDir, cmd string, timeout time.Duration) (string, error) {
 	if strings.TrimSpace(cmd) == "" {
-		return errors.New("doctor command is empty")
+		return "", errors.New("doctor command is empty")
 	}
 	runCtx := ctx
 	var cancel context.CancelFunc
@@ -168,10 +388,11 @@ func runDoctorCommand(ctx context.Context, logger pslog.Logger, runner core.Runn
 		SSHAuthSock: sshSock,
 	})
 	if err != nil {
-		return fmt.Errorf("doctor command start (%s): %w", cmd, err)
+		return "", fmt.Errorf("doctor command start (%s): %w", cmd, err)
 	}
 	outputs := handle.Outputs()
 	done := make(chan struct{})
+	var stdout strings.Builder
 	go func() {
 		defer close(done)
 		for {
@@ -182,19 +403,23 @@ func runDoctorCommand(ctx context.Context, logger pslog.Logger, runner core.Runn
 			if strings.TrimSpace(out.Text) == "" {
 				continue
 			}
+			if out.Stream == core.CommandStreamStdout {
+				stdout.WriteString(out.Text)
+				stdout.WriteString("\n")
+			}
 			logger.Debug("doctor command output", "command", cmd, "stream", out.Stream, "text", out.Text)
 		}
 	}()
 	result, err := handle.Wait(runCtx)
 	<-done
 	if err != nil {
-		return fmt.Errorf("doctor command failed (%s): %w", cmd, err)
+		return "", fmt.Errorf("doctor command failed (%s): %w", cmd, err)
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("doctor command failed (%s exit %d)", cmd, result.ExitCode)
+		return "", fmt.Errorf("doctor command failed (%s exit %d)", cmd, result.ExitCode)
 	}
 	logger.Info("doctor command ok", "command", cmd, "exit", result.ExitCode)
-	return nil
+	return stdout.String(), nil
 }
 
 func runDoctorCodex(ctx context.Context, logger pslog.Logger, runner core.Runner, sshSock, workDir, prompt string, modelID schema.ModelID, timeout time.Duration) error {
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/runnercontainer"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/sshagent"
+	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/internal/userhome"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+const (
+	doctorFullRepo       = "doctor"
+	doctorMockBinary     = "codex-mock"
+	doctorStartTimeout   = 3 * time.Minute
+	doctorTeardownWindow = time.Minute
+)
+
+// doctorFullConfig configures the end-to-end self-test.
+type doctorFullConfig struct {
+	User           string
+	CommandTimeout time.Duration
+	CodexTimeout   time.Duration
+	JSON           bool
+}
+
+// doctorStep is a single named self-test step with a bounded timeout.
+type doctorStep struct {
+	name    string
+	hint    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// doctorCheck is the outcome of one step in `doctor --json`.
+type doctorCheck struct {
+	Name string `json:"name"`
+	// Status is pass, fail or skip.
+	Status string `json:"status"`
+	// Detail is the error of a failed check.
+	Detail string `json:"detail"`
+	// Hint suggests how to fix a failed check.
+	Hint string `json:"hint"`
+}
+
+// doctorReport prints step outcomes and tracks failures. With json set the
+// outcomes are collected and printed by finish instead.
+type doctorReport struct {
+	w      io.Writer
+	json   bool
+	checks []doctorCheck
+	failed int
+}
+
+// run executes step unless an earlier step failed and skip is set. It reports
+// whether the step passed.
+func (r *doctorReport) run(ctx context.Context, step doctorStep, skip bool) bool {
+	if skip {
+		r.checks = append(r.checks, doctorCheck{Name: step.name, Status: "skip"})
+		if !r.json {
+			_, _ = fmt.Fprintf(r.w, "SKIP  %s\n", step.name)
+		}
+		return false
+	}
+	stepCtx := ctx
+	var cancel context.CancelFunc
+	if step.timeout > 0 {
+		stepCtx, cancel = context.WithTimeout(ctx, step.timeout)
+		defer cancel()
+	}
+	started := time.Now()
+	err := step.run(stepCtx)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err == nil {
+		r.checks = append(r.checks, doctorCheck{Name: step.name, Status: "pass"})
+		if !r.json {
+			_, _ = fmt.Fprintf(r.w, "PASS  %s (%s)\n", step.name, elapsed)
+		}
+		return true
+	}
+	r.failed++
+	if errors.Is(err, context.DeadlineExceeded) && step.timeout > 0 {
+		err = fmt.Errorf("timed out after %s: %w", step.timeout, err)
+	}
+	r.checks = append(r.checks, doctorCheck{Name: step.name, Status: "fail", Detail: err.Error(), Hint: step.hint})
+	if r.json {
+		return false
+	}
+	_, _ = fmt.Fprintf(r.w, "FAIL  %s (%s): %v\n", step.name, elapsed, err)
+	if step.hint != "" {
+		_, _ = fmt.Fprintf(r.w, "      hint: %s\n", step.hint)
+	}
+	return false
+}
+
+// err summarizes the report as an error when any step failed.
+func (r *doctorReport) err() error {
+	if r.failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("doctor: %d step(s) failed", r.failed)
+}
+
+// finish prints the collected checks when json is set and summarizes the
+// report like err.
+func (r *doctorReport) finish() error {
+	if r.json {
+		checks := r.checks
+		if checks == nil {
+			checks = []doctorCheck{}
+		}
+		if err := writeJSON(r.w, checks); err != nil {
+			return err
+		}
+	}
+	return r.err()
+}
+
+// runDoctorFull exercises a runner end to end against a throwaway user, tab
+// and state dir, printing one PASS/FAIL/SKIP line per step to w.
+func runDoctorFull(ctx context.Context, w io.Writer, cfg appconfig.Config, fullCfg doctorFullConfig) error {
+	logger := pslog.Ctx(ctx)
+	report := &doctorReport{w: w, json: fullCfg.JSON}
+	suffix := time.Now().UnixNano()
+	user := schema.UserID(fmt.Sprintf("%s-%d", fullCfg.User, suffix))
+
+	var (
+		rt       shipohoy.Runtime
+		closeRT  func() error
+		tmpState string
+		agents   *sshagent.Manager
+		provider *runnercontainer.Provider
+		svc      core.Service
+		tabID    schema.TabID
+		workDir  string
+		sshSock  string
+		runner   core.Runner
+	)
+
+	ok := report.run(ctx, doctorStep{
+		name: "runner config",
+		hint: "check runner.* settings in the config file",
+		run: func(context.Context) error {
+			return validateRunnerConfig(cfg)
+		},
+	}, false)
+	ok = report.run(ctx, doctorStep{
+		name:    "container runtime",
+		hint:    "ensure the runtime socket (runner.podman.address or runner.containerd.address) is reachable",
+		timeout: 30 * time.Second,
+		run: func(stepCtx context.Context) error {
+			var err error
+			rt, closeRT, err = selectRuntime(stepCtx, cfg)
+			return err
+		},
+	}, !ok)
+	if closeRT != nil {
+		defer func() { _ = closeRT() }()
+	}
+	ok = report.run(ctx, doctorStep{
+		name:    "runner image",
+		hint:    "build or pull the image with `centaurx build runner`",
+		timeout: 30 * time.Second,
+		run: func(stepCtx context.Context) error {
+			return verifyRunnerImage(stepCtx, rt, cfg.Runner.Image)
+		},
+	}, !ok)
+	ok = report.run(ctx, doctorStep{
+		name: "temporary state dir",
+		hint: "state_dir must be writable by the centaurx process",
+		run: func(stepCtx context.Context) error {
+			root := filepath.Join(cfg.StateDir, "doctor", fmt.Sprintf("full-%d", suffix))
+			if err := os.MkdirAll(root, 0o700); err != nil {
+				return err
+			}
+			tmpState = root
+			hostStateDir := strings.TrimSpace(cfg.Runner.HostStateDir)
+			if hostStateDir == "" {
+				hostStateDir = cfg.StateDir
+			}
+			keyStore, err := sshkeys.NewStoreWithLogger(filepath.Join(root, "ssh", "keys.bundle"), filepath.Join(root, "ssh", "keys"), logger)
+			if err != nil {
+				return err
+			}
+			agents, err = sshagent.NewManagerWithLogger(keyStore, filepath.Join(root, "ssh", "agent"), logger)
+			if err != nil {
+				return err
+			}
+			provider, err = runnercontainer.NewProvider(stepCtx, runnercontainer.Config{
+				Image:          cfg.Runner.Image,
+				RepoRoot:       cfg.RepoRoot,
+				RunnerRepoRoot: cfg.Runner.RepoRoot,
+				HostRepoRoot:   cfg.Runner.HostRepoRoot,
+				HostStateDir:   filepath.Join(hostStateDir, "doctor", filepath.Base(root)),
+				SockDir:        filepath.Join(root, "runner"),
+				StateDir:       root,
+				SkelData:       userhome.DefaultTemplateData(cfg),
+				SSHAgentDir:    filepath.Join(root, "ssh", "agent"),
+				RunnerBinary:   doctorMockBinary,
+				RunnerArgs:     []string{"--scenario", "summary", "--delay-ms", "0"},
+				RunnerEnv:      cfg.Runner.Env,
+				ContainerScope: "user",
+				NamePrefix:     "centaurx-doctor",
+				CPUPercent:     cfg.Runner.Limits.CPUPercent,
+				MemoryPercent:  cfg.Runner.Limits.MemoryPercent,
+				Security:       runnerSecurity(cfg),
+				AllowedHosts:   cfg.Runner.Network.AllowedHosts,
+			}, rt, agents)
+			if err != nil {
+				return err
+			}
+			resolver, err := core.NewRunnerRepoResolver(cfg.RepoRoot, provider)
+			if err != nil {
+				return err
+			}
+			svc, err = core.NewService(schema.ServiceConfig{
+				RepoRoot:          cfg.RepoRoot,
+				StateDir:          root,
+				DefaultModel:      schema.ModelID(cfg.Models.Default),
+				AllowedModels:     toModelIDs(cfg.Models.Allowed),
+				CodexFlags:        cfg.Runner.CodexFlags,
+				CodexFlagsByModel: codexFlagsByModel(cfg.Runner.CodexFlagsByModel),
+			}, core.ServiceDeps{
+				RunnerProvider: provider,
+				RepoResolver:   resolver,
+				Logger:         logger,
+			})
+			return err
+		},
+	}, !ok)
+	ok = report.run(ctx, doctorStep{
+		name:    "create user and tab",
+		hint:    "runner container failed to start; inspect `podman logs` for centaurx-doctor-* containers",
+		timeout: doctorStartTimeout,
+		run: func(stepCtx context.Context) error {
+			resp, err := svc.CreateTab(stepCtx, schema.CreateTabRequest{UserID: user, RepoName: doctorFullRepo, CreateRepo: true})
+			if err != nil {
+				return err
+			}
+			tabID = resp.Tab.ID
+			return nil
+		},
+	}, !ok)
+	ok = report.run(ctx, doctorStep{
+		name:    "request runner",
+		hint:    "the runner socket did not come up; check runner.sock_dir and host_state_dir mappings",
+		timeout: doctorStartTimeout,
+		run: func(stepCtx context.Context) error {
+			resp, err := provider.RunnerFor(stepCtx, core.RunnerRequest{UserID: user, TabID: tabID})
+			if err != nil {
+				return err
+			}
+			runner = resp.Runner
+			sshSock = resp.Info.SSHAuthSock
+			workDir = path.Join(resp.Info.RepoRoot, doctorFullRepo)
+			return nil
+		},
+	}, !ok)
+	ok = report.run(ctx, doctorStep{
+		name:    "runner command (echo ok)",
+		hint:    "commands run via bash inside the runner image; ensure bash is installed",
+		timeout: fullCfg.CommandTimeout,
+		run: func(stepCtx context.Context) error {
+			output, err := runDoctorCommand(stepCtx, logger, runner, sshSock, workDir, "echo ok", 0)
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(output) != "ok" {
+				return fmt.Errorf("unexpected output %q", output)
+			}
+			return nil
+		},
+	}, !ok)
+	report.run(ctx, doctorStep{
+		name:    "codex account models",
+		hint:    "set models.default and models.allowed to models the codex account can use, or rebuild the runner image with `centaurx build runner`",
+		timeout: 30 * time.Second,
+		run: func(stepCtx context.Context) error {
+			return verifyDoctorModels(stepCtx, cfg, runner)
+		},
+	}, !ok)
+	ok = report.run(ctx, doctorStep{
+		name:    "codex-mock exec",
+		hint:    "the runner image must ship /usr/bin/codex-mock (rebuild with `centaurx build runner`)",
+		timeout: fullCfg.CodexTimeout,
+		run: func(stepCtx context.Context) error {
+			if _, err := svc.SendPrompt(stepCtx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "doctor self-test"}); err != nil {
+				return err
+			}
+			return waitDoctorTabIdle(stepCtx, svc, user, tabID)
+		},
+	}, !ok)
+	report.run(ctx, doctorStep{
+		name:    "event stream and buffer",
+		hint:    "codex-mock ran but no agent output reached the tab buffer",
+		timeout: 10 * time.Second,
+		run: func(stepCtx context.Context) error {
+			return verifyDoctorBuffer(stepCtx, svc, user, tabID)
+		},
+	}, !ok)
+
+	report.run(ctx, doctorStep{
+		name:    "teardown",
+		hint:    "remove leftover centaurx-doctor-* containers and state_dir/doctor manually",
+		timeout: doctorTeardownWindow,
+		run: func(stepCtx context.Context) error {
+			var errs []error
+			if svc != nil && tabID != "" {
+				if _, err := svc.CloseTab(stepCtx, schema.CloseTabRequest{UserID: user, TabID: tabID}); err != nil {
+					errs = append(errs, fmt.Errorf("close tab: %w", err))
+				}
+			}
+			if provider != nil {
+				if err := provider.CloseAll(stepCtx); err != nil {
+					errs = append(errs, fmt.Errorf("close runners: %w", err))
+				}
+			}
+			if agents != nil {
+				if err := agents.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("close agents: %w", err))
+				}
+			}
+			if tmpState != "" {
+				if err := os.RemoveAll(tmpState); err != nil {
+					errs = append(errs, fmt.Errorf("remove state: %w", err))
+				}
+				if err := os.RemoveAll(filepath.Join(cfg.RepoRoot, string(user))); err != nil {
+					errs = append(errs, fmt.Errorf("remove repos: %w", err))
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}, false)
+	return report.finish()
+}
+
+func waitDoctorTabIdle(ctx context.Context, svc core.Service, user schema.UserID, tabID schema.TabID) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		resp, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+		if err != nil {
+			return err
+		}
+		for _, tab := range resp.Tabs {
+			if tab.ID == tabID && tab.Status == schema.TabStatusIdle {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func verifyDoctorBuffer(ctx context.Context, svc core.Service, user schema.UserID, tabID schema.TabID) error {
+	resp, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 200})
+	if err != nil {
+		return err
+	}
+	var agentLines int
+	for _, line := range resp.Buffer.Lines {
+		if strings.HasPrefix(line, schema.FinalAgentMarker) || strings.HasPrefix(line, schema.AgentMarker) {
+			agentLines++
+		}
+		if strings.HasPrefix(line, "error:") {
+			return fmt.Errorf("buffer reports %s", line)
+		}
+	}
+	if agentLines == 0 {
+		return fmt.Errorf("no agent output in %d buffer lines", len(resp.Buffer.Lines))
+	}
+	return nil
+}
+
+// verifyDoctorModels checks the configured models against the models the
+// runner's codex account can use. It passes when the account cannot list
+// models.
+func verifyDoctorModels(ctx context.Context, cfg appconfig.Config, runner core.Runner) error {
+	lister, ok := runner.(core.ModelLister)
+	if !ok {
+		return nil
+	}
+	list, err := lister.Models(ctx)
+	if err != nil {
+		return err
+	}
+	if !list.Listed || len(list.Models) == 0 {
+		return nil
+	}
+	missing := core.UnsupportedModels(schema.ModelID(cfg.Models.Default), toModelIDs(cfg.Models.Allowed), list.Models)
+	if len(missing) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(missing))
+	for _, model := range missing {
+		names = append(names, string(model))
+	}
+	return fmt.Errorf("codex account cannot use configured model(s) %s", strings.Join(names, ", "))
+}
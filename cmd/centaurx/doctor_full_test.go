@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/appconfig"
+)
+
+func TestDoctorReportStepOutcomes(t *testing.T) {
+	var buf bytes.Buffer
+	report := &doctorReport{w: &buf}
+	ctx := context.Background()
+
+	ok := report.run(ctx, doctorStep{name: "first", run: func(context.Context) error { return nil }}, false)
+	if !ok {
+		t.Fatal("expected first step to pass")
+	}
+	ok = report.run(ctx, doctorStep{
+		name: "second",
+		hint: "fix the thing",
+		run:  func(context.Context) error { return errors.New("boom") },
+	}, !ok)
+	if ok {
+		t.Fatal("expected second step to fail")
+	}
+	ok = report.run(ctx, doctorStep{name: "third", run: func(context.Context) error {
+		t.Fatal("skipped step must not run")
+		return nil
+	}}, !ok)
+	if ok {
+		t.Fatal("expected skipped step to report failure")
+	}
+	report.run(ctx, doctorStep{
+		name:    "slow",
+		timeout: 10 * time.Millisecond,
+		run: func(stepCtx context.Context) error {
+			<-stepCtx.Done()
+			return stepCtx.Err()
+		},
+	}, false)
+
+	out := buf.String()
+	for _, want := range []string{"PASS  first", "FAIL  second", ": boom", "hint: fix the thing", "SKIP  third", "FAIL  slow", "timed out after 10ms"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected report to contain %q, got:\n%s", want, out)
+		}
+	}
+	err := report.err()
+	if err == nil || !strings.Contains(err.Error(), "2 step(s) failed") {
+		t.Fatalf("expected failure summary, got %v", err)
+	}
+}
+
+func TestDoctorHasFullFlag(t *testing.T) {
+	cmd := newDoctorCmd()
+	if cmd.Flags().Lookup("full") == nil {
+		t.Fatal("expected doctor --full flag")
+	}
+}
+
+// doctorCheckSchema pins the documented `doctor --json` schema.
+type doctorCheckSchema struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint"`
+}
+
+func decodeDoctorChecks(t *testing.T, data []byte) []doctorCheckSchema {
+	t.Helper()
+	var checks []doctorCheckSchema
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&checks); err != nil {
+		t.Fatalf("decode doctor output %q: %v", data, err)
+	}
+	return checks
+}
+
+func TestDoctorReportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	report := &doctorReport{w: &buf, json: true}
+	ctx := context.Background()
+	ok := report.run(ctx, doctorStep{name: "first", hint: "unused", run: func(context.Context) error { return nil }}, false)
+	ok = report.run(ctx, doctorStep{name: "second", hint: "fix the thing", run: func(context.Context) error { return errors.New("boom") }}, !ok)
+	report.run(ctx, doctorStep{name: "third", run: func(context.Context) error { return nil }}, !ok)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output before finish, got %q", buf.String())
+	}
+	err := report.finish()
+	if err == nil || !strings.Contains(err.Error(), "1 step(s) failed") {
+		t.Fatalf("expected failure summary, got %v", err)
+	}
+	checks := decodeDoctorChecks(t, buf.Bytes())
+	want := []doctorCheckSchema{
+		{Name: "first", Status: "pass"},
+		{Name: "second", Status: "fail", Detail: "boom", Hint: "fix the thing"},
+		{Name: "third", Status: "skip"},
+	}
+	if !slices.Equal(checks, want) {
+		t.Fatalf("unexpected checks %+v", checks)
+	}
+}
+
+func TestDoctorJSONSkipsAfterFailedCheck(t *testing.T) {
+	cfg, err := appconfig.DefaultConfig()
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	cfg.Runner.Runtime = "podman"
+	cfg.Runner.Podman.Address = ""
+	var buf bytes.Buffer
+	if err := runDoctor(context.Background(), &doctorReport{w: &buf, json: true}, cfg, doctorConfig{User: "doctor"}); err == nil {
+		t.Fatal("expected doctor to fail")
+	}
+	checks := decodeDoctorChecks(t, buf.Bytes())
+	if len(checks) < 2 || checks[0].Name != "runner config" || checks[0].Status != "fail" || !strings.Contains(checks[0].Detail, "runner.podman.address") {
+		t.Fatalf("unexpected checks %+v", checks)
+	}
+	for _, check := range checks[1:] {
+		if check.Status != "skip" {
+			t.Fatalf("expected checks after the failure to be skipped, got %+v", check)
+		}
+	}
+}
+
+func TestDoctorJSONReportsConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("repo_root: [\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"doctor", "--json", "-c", path})
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected doctor to fail on a broken config")
+	}
+	checks := decodeDoctorChecks(t, stdout.Bytes())
+	if len(checks) != 1 || checks[0].Name != "config" || checks[0].Status != "fail" || checks[0].Hint == "" {
+		t.Fatalf("unexpected checks %+v", checks)
+	}
+}
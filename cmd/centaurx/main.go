@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
@@ -33,6 +34,10 @@ func submain(ctx context.Context) int {
 		if !isCodexMockInvocation(args) {
 			pslog.Ctx(ctx).With("err", err).Error("centaurx command failed")
 		}
+		var exitErr *mockExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.code
+		}
 		return 1
 	}
 	return 0
@@ -48,6 +53,7 @@ func newRootCmd() *cobra.Command {
 
 	root.AddCommand(newServeCmd())
 	root.AddCommand(newRunnerCmd())
+	root.AddCommand(newEgressConnectCmd())
 	root.AddCommand(newCodexMockCmd())
 	root.AddCommand(newBootstrapCmd())
 	root.AddCommand(newBuildCmd())
@@ -55,6 +61,7 @@ func newRootCmd() *cobra.Command {
 	root.AddCommand(newDebugCmd())
 	root.AddCommand(newVersionCmd())
 	root.AddCommand(newUsersCmd())
+	root.AddCommand(newRunnersCmd())
 
 	return root
 }
@@ -132,3 +132,10 @@ func TestRootHasDebug(t *testing.T) {
 		t.Fatalf("expected root command to include debug")
 	}
 }
+
+func TestDebugHasVerifyState(t *testing.T) {
+	cmd, _, err := newRootCmd().Find([]string{"debug", "verify-state"})
+	if err != nil || cmd.Name() != "verify-state" {
+		t.Fatalf("expected debug verify-state command, got %v err=%v", cmd, err)
+	}
+}
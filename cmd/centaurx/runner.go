@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"os"
 	"os/signal"
 	"strings"
 	"syscall"
@@ -10,6 +14,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"pkt.systems/centaurx/internal/codex"
+	"pkt.systems/centaurx/internal/egress"
 	"pkt.systems/centaurx/internal/runnerconfig"
 	"pkt.systems/centaurx/internal/runnergrpc"
 	"pkt.systems/pslog"
@@ -25,6 +30,7 @@ func newRunnerCmd() *cobra.Command {
 	var keepaliveMisses int
 	var execNice int
 	var commandNice int
+	var egressSocket string
 	cmd := &cobra.Command{
 		Use:   "runner",
 		Short: "Start the runner daemon",
@@ -56,6 +62,11 @@ func newRunnerCmd() *cobra.Command {
 
 			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
 			defer stop()
+			if strings.TrimSpace(egressSocket) != "" {
+				if err := startEgressBridge(ctx, logger, egressSocket); err != nil {
+					return err
+				}
+			}
 			logger.Info("runner binary ready", "path", cfg.Binary)
 			logger.Info("runner socket listening", "socket", cfg.SocketPath)
 			return server.ListenAndServe(ctx)
@@ -70,9 +81,56 @@ func newRunnerCmd() *cobra.Command {
 	cmd.Flags().IntVar(&keepaliveMisses, "keepalive-misses", 0, "runner keepalive misses before exit")
 	cmd.Flags().IntVar(&execNice, "exec-nice", 0, "nice value for codex exec processes")
 	cmd.Flags().IntVar(&commandNice, "command-nice", 0, "nice value for shell command processes")
+	cmd.Flags().StringVar(&egressSocket, "egress-proxy-socket", "", "egress proxy socket; route outbound traffic through it")
 	return cmd
 }
 
+// startEgressBridge relays a loopback port to the egress proxy socket and
+// points the proxy environment of codex and shell commands at it.
+func startEgressBridge(ctx context.Context, logger pslog.Logger, socketPath string) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("egress bridge: %w", err)
+	}
+	addr := listener.Addr().String()
+	for key, value := range egress.ProxyEnv(addr) {
+		if err := os.Setenv(key, value); err != nil {
+			_ = listener.Close()
+			return err
+		}
+	}
+	go func() {
+		if err := egress.Bridge(ctx, listener, socketPath); err != nil {
+			logger.Warn("runner egress bridge stopped", "err", err)
+		}
+	}()
+	logger.Info("runner egress bridge listening", "addr", addr, "socket", socketPath)
+	return nil
+}
+
+func newEgressConnectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "egress-connect <host> <port>",
+		Short:  "Tunnel stdin/stdout to host:port through the egress proxy",
+		Long:   "Tunnel stdin/stdout to host:port through the egress proxy of a runner container, for use as an ssh ProxyCommand.",
+		Hidden: true,
+		Args:   cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proxyAddr := strings.TrimSpace(os.Getenv(egress.ProxyAddrEnv))
+			if proxyAddr == "" {
+				return fmt.Errorf("%s is not set; egress-connect runs inside runner containers with egress control", egress.ProxyAddrEnv)
+			}
+			err := egress.Connect(cmd.Context(), proxyAddr, net.JoinHostPort(args[0], args[1]), cmd.InOrStdin(), cmd.OutOrStdout())
+			if err != nil {
+				// ssh shows ProxyCommand stderr as is, so the reason is
+				// printed plainly before the error log line.
+				_, _ = fmt.Fprintln(cmd.ErrOrStderr(), err)
+			}
+			return err
+		},
+	}
+}
+
 func flattenEnv(env map[string]string) []string {
 	if len(env) == 0 {
 		return nil
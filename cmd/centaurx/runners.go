@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"pkt.systems/centaurx/internal/admin"
+	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/schema"
+)
+
+func newRunnersCmd() *cobra.Command {
+	var cfgPath string
+	cmd := &cobra.Command{
+		Use:   "runners",
+		Short: "Manage the runner containers of the running server",
+		Long: "Manage the runner containers of the running server.\n\n" +
+			"The commands talk to `centaurx serve` over its admin socket (admin.sock in the state dir), " +
+			"so they act on the live runners instead of starting a provider of their own.",
+	}
+	cmd.PersistentFlags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
+
+	cmd.AddCommand(newRunnersListCmd(&cfgPath))
+	cmd.AddCommand(newRunnersCloseCmd(&cfgPath))
+
+	return cmd
+}
+
+func newRunnersListCmd(cfgPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List live runner containers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := adminClient(*cfgPath)
+			if err != nil {
+				return err
+			}
+			runners, err := client.ListRunners(cmd.Context())
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if len(runners) == 0 {
+				_, _ = fmt.Fprintln(out, "no runners")
+				return nil
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "USER\tTABS\tCONTAINER\tRUNNING\tLAST USED")
+			for _, runner := range runners {
+				tabs := runner.Tabs
+				if runner.Tab != "" {
+					tabs = []schema.TabID{runner.Tab}
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", runner.User, joinTabs(tabs), orDash(runner.Container), runner.Running, formatLastUsed(runner.LastUsed))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newRunnersCloseCmd(cfgPath *string) *cobra.Command {
+	var user string
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "close (--user <id> | --all)",
+		Short: "Stop and remove runner containers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user = strings.TrimSpace(user)
+			if all == (user != "") {
+				return errors.New("use either --user <id> or --all")
+			}
+			if user != "" {
+				if err := validateUsername(user); err != nil {
+					return err
+				}
+			}
+			client, err := adminClient(*cfgPath)
+			if err != nil {
+				return err
+			}
+			closed, err := client.CloseRunners(cmd.Context(), admin.CloseRunnersRequest{User: schema.UserID(user), All: all})
+			if err != nil {
+				return err
+			}
+			noun := "runners"
+			if closed == 1 {
+				noun = "runner"
+			}
+			if all {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "closed %d %s\n", closed, noun)
+			} else {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "closed %d %s for %s\n", closed, noun, user)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&user, "user", "", "close the runners of this user")
+	cmd.Flags().BoolVar(&all, "all", false, "close every runner")
+	return cmd
+}
+
+func adminClient(cfgPath string) (*admin.Client, error) {
+	cfg, err := appconfig.Load(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	return admin.NewClient(cfg.AdminSocketPath()), nil
+}
+
+func joinTabs(tabs []schema.TabID) string {
+	if len(tabs) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(tabs))
+	for _, tab := range tabs {
+		parts = append(parts, string(tab))
+	}
+	return strings.Join(parts, ",")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func formatLastUsed(at time.Time) string {
+	if at.IsZero() {
+		return "-"
+	}
+	return at.Local().Format(time.DateTime)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/admin"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+type adminRunnerProvider struct {
+	core.StaticRunnerProvider
+	runners []core.RunnerStatus
+	closed  []schema.UserID
+}
+
+func (p *adminRunnerProvider) ListRunners(context.Context) ([]core.RunnerStatus, error) {
+	return p.runners, nil
+}
+
+func (p *adminRunnerProvider) CloseUser(_ context.Context, userID schema.UserID) (int, error) {
+	p.closed = append(p.closed, userID)
+	return 2, nil
+}
+
+func TestRunnersCommands(t *testing.T) {
+	cfgPath := writeTestConfig(t)
+	cfg := loadConfigFromPath(t, cfgPath)
+	provider := &adminRunnerProvider{runners: []core.RunnerStatus{
+		{UserID: "alice", Tabs: []schema.TabID{"t1", "t2"}, Container: "centaurx-runner-alice", Running: 1},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- (&admin.Server{SocketPath: cfg.AdminSocketPath(), Runners: provider}).ListenAndServe(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	run := func(args ...string) (string, error) {
+		cmd := newRunnersCmd()
+		var out bytes.Buffer
+		cmd.SetArgs(append([]string{"-c", cfgPath}, args...))
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	var out string
+	var err error
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if out, err = run("list"); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("runners list: %v", err)
+	}
+	if !strings.Contains(out, "USER") || !strings.Contains(out, "alice") || !strings.Contains(out, "t1,t2") || !strings.Contains(out, "centaurx-runner-alice") {
+		t.Fatalf("unexpected list output:\n%s", out)
+	}
+
+	out, err = run("close", "--user", "alice")
+	if err != nil {
+		t.Fatalf("runners close --user: %v", err)
+	}
+	if out != "closed 2 runners for alice\n" || len(provider.closed) != 1 || provider.closed[0] != "alice" {
+		t.Fatalf("unexpected close output %q (closed %v)", out, provider.closed)
+	}
+
+	out, err = run("close", "--all")
+	if err != nil {
+		t.Fatalf("runners close --all: %v", err)
+	}
+	if out != "closed 1 runner\n" {
+		t.Fatalf("unexpected close --all output %q", out)
+	}
+
+	if _, err := run("close"); err == nil || !strings.Contains(err.Error(), "--user <id> or --all") {
+		t.Fatalf("expected selection error, got %v", err)
+	}
+	if _, err := run("close", "--user", "Bad User"); err == nil {
+		t.Fatalf("expected invalid username error")
+	}
+}
+
+func TestRunnersListServerDown(t *testing.T) {
+	cfgPath := writeTestConfig(t)
+	cmd := newRunnersCmd()
+	cmd.SetArgs([]string{"-c", cfgPath, "list"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "is the server running?") {
+		t.Fatalf("expected server down error, got %v", err)
+	}
+}
+
+func TestCheckAdminSocket(t *testing.T) {
+	ctx := context.Background()
+	logger := pslog.Ctx(ctx)
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "admin.sock")
+	if err := checkAdminSocket(ctx, logger, socket); err != nil {
+		t.Fatalf("absent socket: %v", err)
+	}
+
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := checkAdminSocket(ctx, logger, socket); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Fatalf("expected not a socket error, got %v", err)
+	}
+	_ = os.Remove(socket)
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	if err := os.Chmod(socket, 0o666); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := checkAdminSocket(ctx, logger, socket); err == nil || !strings.Contains(err.Error(), "want 0600") {
+		t.Fatalf("expected mode error, got %v", err)
+	}
+}
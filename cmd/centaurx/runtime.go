@@ -25,9 +25,10 @@ func selectRuntime(ctx context.Context, cfg appconfig.Config) (shipohoy.Runtime,
 		return rt, rt.Close, nil
 	case "containerd":
 		rt, err := containerd.New(ctx, containerd.Config{
-			Address:     cfg.Runner.Containerd.Address,
-			Namespace:   cfg.Runner.Containerd.Namespace,
-			PullTimeout: time.Duration(cfg.Runner.PullTimeout) * time.Minute,
+			Address:              cfg.Runner.Containerd.Address,
+			Namespace:            cfg.Runner.Containerd.Namespace,
+			PullTimeout:          time.Duration(cfg.Runner.PullTimeout) * time.Minute,
+			LogCaptureTotalBytes: int64(cfg.Runner.LogCaptureTotalBytes),
 		})
 		if err != nil {
 			return nil, nil, fmt.Errorf("containerd connection failed (%s): %w", cfg.Runner.Containerd.Address, err)
@@ -12,6 +12,7 @@ import (
 	"os/signal"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
@@ -21,11 +22,18 @@ import (
 	"pkt.systems/centaurx"
 	"pkt.systems/centaurx/core"
 	"pkt.systems/centaurx/httpapi"
+	"pkt.systems/centaurx/internal/admin"
 	"pkt.systems/centaurx/internal/appconfig"
 	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/codex"
+	"pkt.systems/centaurx/internal/egress"
+	"pkt.systems/centaurx/internal/logring"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/provision"
 	"pkt.systems/centaurx/internal/runnercontainer"
 	"pkt.systems/centaurx/internal/runnergrpc"
 	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
 	"pkt.systems/centaurx/internal/sshagent"
 	"pkt.systems/centaurx/internal/sshkeys"
 	"pkt.systems/centaurx/internal/userhome"
@@ -58,12 +66,35 @@ func newServeCmd() *cobra.Command {
 			if disableAuditTrails {
 				cfg.Logging.DisableAuditTrails = true
 			}
+			var serverLog *logring.Ring
+			if cfg.Logging.ServerLogLines > 0 {
+				// Record what the log writer emits: LOG_LEVEL, or debug
+				// when it is unset.
+				level, ok := pslog.LevelFromEnv("LOG_LEVEL")
+				if !ok {
+					level = pslog.DebugLevel
+				}
+				serverLog = logring.New(cfg.Logging.ServerLogLines)
+				logger = logring.Tee(logger, serverLog, level)
+				cmd.SetContext(pslog.ContextWithLogger(cmd.Context(), logger))
+			}
 			if err := validateRunnerConfig(cfg); err != nil {
 				return err
 			}
 			if err := ensureUserHomes(cfg, logger); err != nil {
 				return err
 			}
+			mountStore, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, cfg.Auth.SeedUsers, logger)
+			if err != nil {
+				return err
+			}
+			if err := validateUserRunnerMounts(cfg, mountStore.LoadUsers()); err != nil {
+				return err
+			}
+			themesDir := cfg.ThemesDir()
+			if _, err := sshserver.LoadThemes(cmd.Context(), themesDir); err != nil {
+				logger.Warn("custom themes load failed", "themes_dir", themesDir, "err", err)
+			}
 			switch cfg.Runner.Runtime {
 			case "podman":
 				logger.Info("runner runtime selected", "runtime", cfg.Runner.Runtime, "address", cfg.Runner.Podman.Address, "userns", cfg.Runner.Podman.UserNSMode)
@@ -72,6 +103,15 @@ func newServeCmd() *cobra.Command {
 			default:
 				logger.Info("runner runtime selected", "runtime", cfg.Runner.Runtime)
 			}
+			readiness := httpapi.NewReadiness(
+				httpapi.DependencyService,
+				httpapi.DependencyStore,
+				httpapi.DependencyRuntime,
+				httpapi.DependencySSH,
+			)
+			readiness.SetCheck(httpapi.DependencyStore, func(context.Context) error {
+				return persist.ProbeWritable(cfg.StateDir)
+			})
 			rt, closeFn, err := selectRuntime(cmd.Context(), cfg)
 			if err != nil {
 				return err
@@ -79,6 +119,7 @@ func newServeCmd() *cobra.Command {
 			if closeFn != nil {
 				defer func() { _ = closeFn() }()
 			}
+			readiness.SetReady(httpapi.DependencyRuntime, true)
 
 			logger.Info("runner image verify start", "image", cfg.Runner.Image)
 			if err := verifyRunnerImage(cmd.Context(), rt, cfg.Runner.Image); err != nil {
@@ -92,14 +133,38 @@ func newServeCmd() *cobra.Command {
 			logger.Info("runner runtime verify ok", "binary", cfg.Runner.Binary)
 
 			serviceCfg := schema.ServiceConfig{
-				RepoRoot:            cfg.RepoRoot,
-				StateDir:            cfg.StateDir,
-				DefaultModel:        schema.ModelID(cfg.Models.Default),
-				AllowedModels:       toModelIDs(cfg.Models.Allowed),
-				TabNameMax:          10,
-				TabNameSuffix:       "$",
-				BufferMaxLines:      cfg.Service.BufferMaxLines,
-				DisableAuditLogging: cfg.Logging.DisableAuditTrails,
+				RepoRoot:               cfg.RepoRoot,
+				StateDir:               cfg.StateDir,
+				DefaultModel:           schema.ModelID(cfg.Models.Default),
+				AllowedModels:          toModelIDs(cfg.Models.Allowed),
+				CodexFlags:             cfg.Runner.CodexFlags,
+				CodexFlagsByModel:      codexFlagsByModel(cfg.Runner.CodexFlagsByModel),
+				TabNameMax:             10,
+				TabNameSuffix:          "$",
+				BufferMaxLines:         cfg.Service.BufferMaxLines,
+				HistoryMax:             cfg.Service.HistoryMax,
+				GlobalHistoryMax:       cfg.Service.GlobalHistoryMax,
+				ClosedTabTTL:           time.Duration(cfg.Service.ClosedTabTTLHours) * time.Hour,
+				GitSummaryTTL:          time.Duration(cfg.Service.GitSummaryTTLSeconds) * time.Second,
+				GitSummaryRemotesMax:   cfg.Service.GitSummaryRemotesMax,
+				TimeFormat:             cfg.UI.TimeFormat,
+				Timezone:               cfg.UI.Timezone,
+				SummariesEnabled:       cfg.Summaries.Enabled,
+				SummaryTime:            cfg.Summaries.Time,
+				SummaryModel:           schema.ModelID(cfg.Summaries.Model),
+				SummaryInputMax:        cfg.Summaries.MaxInputBytes,
+				UsageWarnBelowPercent:  cfg.Usage.WarnBelowPercent,
+				UsageBlockBelowPercent: cfg.Usage.BlockBelowPercent,
+				DailyTokenBudget:       cfg.Budgets.DailyTokensPerUser,
+				TokenBudgetWarnPercent: cfg.Budgets.WarnPercent,
+				BatchParallelism:       cfg.Batch.Parallelism,
+				PromptMaxBytes:         cfg.Prompts.MaxBytes,
+				PromptWarnBytes:        cfg.Prompts.WarnBytes,
+				ConcurrentRuns:         schema.ConcurrentRunsPolicy(cfg.Repos.ConcurrentRuns),
+				StopGracePeriod:        time.Duration(cfg.Runner.StopGracePeriodSeconds) * time.Second,
+				StreamReattachAttempts: cfg.Runner.StreamReattachAttempts,
+				DisableAuditLogging:    cfg.Logging.DisableAuditTrails,
+				DisableOnboarding:      !cfg.Service.Onboarding,
 			}
 
 			keyStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
@@ -114,7 +179,7 @@ func newServeCmd() *cobra.Command {
 
 			serverCfg := centaurx.ServerConfig{
 				Service:             serviceCfg,
-				HTTP:                toHTTPConfig(cfg.HTTP),
+				HTTP:                toHTTPConfig(cfg.HTTP, cfg.SSH.MOTDFile),
 				SSH:                 toSSHConfig(cfg.SSH),
 				Auth:                toAuthConfig(cfg.Auth),
 				HubHistory:          1000,
@@ -133,6 +198,8 @@ func newServeCmd() *cobra.Command {
 				RunnerBinary:      cfg.Runner.Binary,
 				RunnerArgs:        cfg.Runner.Args,
 				RunnerEnv:         cfg.Runner.Env,
+				Mounts:            runnerMounts(cfg),
+				UserMounts:        userRunnerMounts(mountStore),
 				GitSSHDebug:       cfg.Runner.GitSSHDebug,
 				ContainerScope:    cfg.Runner.ContainerScope,
 				ExecNice:          cfg.Runner.ExecNice,
@@ -142,11 +209,15 @@ func newServeCmd() *cobra.Command {
 				KeepaliveMisses:   cfg.Runner.KeepaliveMisses,
 				CPUPercent:        cfg.Runner.Limits.CPUPercent,
 				MemoryPercent:     cfg.Runner.Limits.MemoryPercent,
+				Security:          runnerSecurity(cfg),
+				AllowedHosts:      cfg.Runner.Network.AllowedHosts,
+				LogBufferBytes:    cfg.Runner.LogCaptureBytes,
+				CloseParallelism:  cfg.Runner.CloseParallelism,
 			}, rt, agentManager)
 			if err != nil {
 				return err
 			}
-			repoResolver, err := core.NewRunnerRepoResolver(cfg.RepoRoot, runnerProvider)
+			repoResolver, err := core.NewRunnerRepoResolver(cfg.RepoRoot, runnerProvider, core.WithSkipPreflight(cfg.Repos.SkipPreflight))
 			if err != nil {
 				return err
 			}
@@ -156,6 +227,14 @@ func newServeCmd() *cobra.Command {
 					RepoResolver:   repoResolver,
 					Logger:         logger,
 				},
+				Readiness: readiness,
+				EnsureUserHome: func(username string) error {
+					_, err := userhome.EnsureHome(cfg.StateDir, username, userhome.SkelDir(cfg.StateDir), userhome.DefaultTemplateData(cfg))
+					return err
+				},
+			}
+			if serverLog != nil {
+				serverDeps.ServerLog = serverLog
 			}
 			server, err := centaurx.New(serverCfg, serverDeps, centaurx.WithHTTP(), centaurx.WithSSH())
 			if err != nil {
@@ -164,12 +243,24 @@ func newServeCmd() *cobra.Command {
 
 			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
 			defer stop()
+			defer reloadThemesOnHangup(cmd.Context(), themesDir)()
 			serverCtx := pslog.ContextWithLogger(context.Background(), logger)
 			logger.Info("http server listening", "addr", serverCfg.HTTP.Addr)
 			logger.Info("ssh server listening", "addr", serverCfg.SSH.Addr)
 			if err := server.Start(serverCtx); err != nil {
 				return err
 			}
+			adminCtx, stopAdmin := context.WithCancel(serverCtx)
+			defer stopAdmin()
+			adminSrv := &admin.Server{SocketPath: cfg.AdminSocketPath(), Runners: runnerProvider}
+			if reporter, ok := rt.(shipohoy.LogCaptureReporter); ok {
+				adminSrv.LogCapture = reporter
+			}
+			go func() {
+				if err := adminSrv.ListenAndServe(adminCtx); err != nil {
+					logger.Warn("admin socket failed", "socket", adminSrv.SocketPath, "err", err)
+				}
+			}()
 			waitCh := make(chan error, 1)
 			go func() { waitCh <- server.Wait() }()
 			select {
@@ -191,6 +282,30 @@ func newServeCmd() *cobra.Command {
 	return cmd
 }
 
+// reloadThemesOnHangup reloads custom themes from dir whenever the process
+// receives SIGHUP, until the returned function is called.
+func reloadThemesOnHangup(ctx context.Context, dir string) func() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if _, err := sshserver.LoadThemes(ctx, dir); err != nil {
+					pslog.Ctx(ctx).Warn("custom themes reload failed", "themes_dir", dir, "err", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
+
 func toModelIDs(values []string) []schema.ModelID {
 	if len(values) == 0 {
 		return nil
@@ -202,7 +317,21 @@ func toModelIDs(values []string) []schema.ModelID {
 	return out
 }
 
-func toHTTPConfig(cfg appconfig.HTTPConfig) httpapi.Config {
+// codexFlagsByModel indexes the per-model codex flags by model; entries for
+// the same model are concatenated.
+func codexFlagsByModel(entries []appconfig.ModelCodexFlags) map[schema.ModelID][]string {
+	if len(entries) == 0 {
+		return nil
+	}
+	out := make(map[schema.ModelID][]string, len(entries))
+	for _, entry := range entries {
+		model := schema.ModelID(strings.TrimSpace(entry.Model))
+		out[model] = append(out[model], entry.Flags...)
+	}
+	return out
+}
+
+func toHTTPConfig(cfg appconfig.HTTPConfig, motdFile string) httpapi.Config {
 	return httpapi.Config{
 		Addr:               cfg.Addr,
 		SessionCookie:      cfg.SessionCookie,
@@ -212,20 +341,30 @@ func toHTTPConfig(cfg appconfig.HTTPConfig) httpapi.Config {
 		BasePath:           cfg.BasePath,
 		InitialBufferLines: cfg.InitialBufferLines,
 		UIMaxBufferLines:   cfg.UIMaxBufferLines,
+		MOTDFile:           motdFile,
+		TrustedProxies:     cfg.TrustedProxies,
 	}
 }
 
 func toSSHConfig(cfg appconfig.SSHConfig) sshserver.Config {
 	return sshserver.Config{
-		Addr:         cfg.Addr,
-		HostKeyPath:  cfg.HostKeyPath,
-		IdlePrompt:   "> ",
-		KeyStorePath: cfg.KeyStorePath,
-		KeyDir:       cfg.KeyDir,
+		Addr:           cfg.Addr,
+		HostKeyPath:    cfg.HostKeyPath,
+		HostKeys:       cfg.HostKeys,
+		KeyStorePath:   cfg.KeyStorePath,
+		KeyDir:         cfg.KeyDir,
+		BannerFile:     cfg.BannerFile,
+		MOTDFile:       cfg.MOTDFile,
+		PromptTemplate: cfg.PromptTemplate,
+		ShowTabNumbers: cfg.ShowTabNumbers,
 	}
 }
 
 func toAuthConfig(cfg appconfig.AuthConfig) centaurx.AuthConfig {
+	oidcUsers := make(map[string]string, len(cfg.OIDC.Users))
+	for _, user := range cfg.OIDC.Users {
+		oidcUsers[user.Claim] = user.User
+	}
 	seeds := make([]centaurx.SeedUser, 0, len(cfg.SeedUsers))
 	for _, seed := range cfg.SeedUsers {
 		seeds = append(seeds, centaurx.SeedUser{
@@ -237,6 +376,56 @@ func toAuthConfig(cfg appconfig.AuthConfig) centaurx.AuthConfig {
 	return centaurx.AuthConfig{
 		UserFile:  cfg.UserFile,
 		SeedUsers: seeds,
+		Throttle:  toThrottleConfig(cfg),
+		LDAP: auth.LDAPConfig{
+			URL:                cfg.LDAP.URL,
+			BindDN:             cfg.LDAP.BindDN,
+			BindPassword:       cfg.LDAP.BindPassword,
+			BaseDN:             cfg.LDAP.BaseDN,
+			UserFilter:         cfg.LDAP.UserFilter,
+			GroupAttribute:     cfg.LDAP.GroupAttribute,
+			StartTLS:           cfg.LDAP.StartTLS,
+			CAFile:             cfg.LDAP.CAFile,
+			InsecureSkipVerify: cfg.LDAP.InsecureSkipVerify,
+			Timeout:            time.Duration(cfg.LDAP.TimeoutSeconds) * time.Second,
+		},
+		OIDC: auth.OIDCConfig{
+			Issuer:        cfg.OIDC.Issuer,
+			ClientID:      cfg.OIDC.ClientID,
+			ClientSecret:  cfg.OIDC.ClientSecret,
+			Scopes:        cfg.OIDC.Scopes,
+			UsernameClaim: cfg.OIDC.UsernameClaim,
+			GroupsClaim:   cfg.OIDC.GroupsClaim,
+			Users:         oidcUsers,
+			AllowUnmapped: cfg.OIDC.AllowUnmapped,
+		},
+		Groups: auth.GroupMapping{
+			Admin:    cfg.Groups.Admin,
+			ReadOnly: cfg.Groups.ReadOnly,
+		},
+		AutoProvision: toAutoProvisionConfig(cfg.AutoProvision),
+	}
+}
+
+func toAutoProvisionConfig(cfg appconfig.AutoProvisionConfig) *provision.SourceConfig {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &provision.SourceConfig{
+		GitHubOrg:         cfg.GitHubOrg,
+		GitHubToken:       cfg.GitHubToken,
+		AuthorizedKeysURL: cfg.AuthorizedKeysURL,
+		Refresh:           time.Duration(cfg.RefreshMinutes) * time.Minute,
+	}
+}
+
+func toThrottleConfig(cfg appconfig.AuthConfig) auth.ThrottleConfig {
+	return auth.ThrottleConfig{
+		BackoffAfter: cfg.BackoffAfter,
+		LockoutAfter: cfg.LockoutAfter,
+		Window:       time.Duration(cfg.FailureWindowMinutes) * time.Minute,
+		Lockout:      time.Duration(cfg.LockoutMinutes) * time.Minute,
+		StatePath:    cfg.LockoutFile,
 	}
 }
 
@@ -359,8 +548,12 @@ func verifyRunnerRuntime(ctx context.Context, rt shipohoy.Runtime, cfg appconfig
 			{Target: "/var/run", Options: []string{"mode=0755", "rw"}},
 			{Target: "/var/tmp", Options: []string{"mode=1777", "rw"}},
 		},
-		Labels: map[string]string{"centaurx.check": "true"},
+		Labels: map[string]string{
+			labels.Check:     "true",
+			labels.CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		},
 	}
+	runnerSecurity(cfg).Apply(&spec)
 	log.Debug("runner runtime verify container", "container", name, "image", cfg.Runner.Image)
 	log.Trace("runner runtime verify command", "command", strings.Join(spec.Command, " "))
 
@@ -573,6 +766,45 @@ func validateRunnerConfig(cfg appconfig.Config) error {
 	if cfg.Runner.Limits.MemoryPercent < 0 || cfg.Runner.Limits.MemoryPercent > 100 {
 		return fmt.Errorf("runner.limits.memory_percent must be between 0 and 100")
 	}
+	if err := validateRunnerSecurity(cfg.Runner.Security); err != nil {
+		return err
+	}
+	if cfg.Runner.CloseParallelism < 0 {
+		return fmt.Errorf("runner.close_parallelism must not be negative")
+	}
+	if cfg.Runner.LogCaptureBytes < 0 {
+		return fmt.Errorf("runner.log_capture_bytes must not be negative")
+	}
+	if cfg.Runner.LogCaptureTotalBytes < 0 {
+		return fmt.Errorf("runner.log_capture_total_bytes must not be negative")
+	}
+	if err := codex.ValidateExtraArgs(cfg.Runner.CodexFlags); err != nil {
+		return fmt.Errorf("runner.codex_flags: %w", err)
+	}
+	for i, entry := range cfg.Runner.CodexFlagsByModel {
+		if strings.TrimSpace(entry.Model) == "" {
+			return fmt.Errorf("runner.codex_flags_by_model[%d]: model is required", i)
+		}
+		if err := codex.ValidateExtraArgs(entry.Flags); err != nil {
+			return fmt.Errorf("runner.codex_flags_by_model[%d] (%s): %w", i, entry.Model, err)
+		}
+	}
+	for i, mount := range cfg.Runner.ExtraMounts {
+		if !filepath.IsAbs(mount.Host) {
+			return fmt.Errorf("runner.extra_mounts[%d]: host must be an absolute path (got %q)", i, mount.Host)
+		}
+		if !path.IsAbs(mount.Container) {
+			return fmt.Errorf("runner.extra_mounts[%d]: container must be an absolute path (got %q)", i, mount.Container)
+		}
+	}
+	if len(cfg.Runner.Network.AllowedHosts) > 0 {
+		if _, err := egress.ParsePolicy(cfg.Runner.Network.AllowedHosts); err != nil {
+			return fmt.Errorf("runner.network.allowed_hosts: %w", err)
+		}
+		if runnerSecurity(cfg).NetworkMode == shipohoy.NetworkHost {
+			return fmt.Errorf("runner.network.allowed_hosts cannot be combined with runner.security.network: host")
+		}
+	}
 	hostRepoRoot := filepath.Clean(cfg.Runner.HostRepoRoot)
 	runnerRepoRoot := filepath.Clean(cfg.Runner.RepoRoot)
 	if hostRepoRoot != "" {
@@ -583,3 +815,103 @@ func validateRunnerConfig(cfg appconfig.Config) error {
 	}
 	return nil
 }
+
+var capabilityNamePattern = regexp.MustCompile(`^(CAP_)?[A-Z_]+$`)
+
+func validateRunnerSecurity(sec appconfig.RunnerSecurity) error {
+	switch sec.Network {
+	case "", string(shipohoy.NetworkHost), string(shipohoy.NetworkNone):
+	default:
+		return fmt.Errorf("runner.security.network must be empty, \"host\" or \"none\" (got %q)", sec.Network)
+	}
+	switch profile := strings.TrimSpace(sec.SeccompProfile); profile {
+	case "", shipohoy.SeccompDefault, shipohoy.SeccompUnconfined:
+	default:
+		if !filepath.IsAbs(profile) {
+			return fmt.Errorf("runner.security.seccomp_profile must be \"default\", \"unconfined\" or an absolute path (got %q)", sec.SeccompProfile)
+		}
+	}
+	for _, capability := range sec.DropCapabilities {
+		if !capabilityNamePattern.MatchString(strings.ToUpper(strings.TrimSpace(capability))) {
+			return fmt.Errorf("runner.security.drop_capabilities: invalid capability %q", capability)
+		}
+	}
+	return nil
+}
+
+// runnerMounts maps runner.extra_mounts to the extra mounts of runner containers.
+func runnerMounts(cfg appconfig.Config) []runnercontainer.Mount {
+	mounts := make([]runnercontainer.Mount, 0, len(cfg.Runner.ExtraMounts))
+	for _, mount := range cfg.Runner.ExtraMounts {
+		mounts = append(mounts, runnercontainer.Mount{
+			Host:      filepath.Clean(mount.Host),
+			Container: path.Clean(mount.Container),
+			ReadOnly:  mount.ReadOnly,
+		})
+	}
+	return mounts
+}
+
+// toRunnerMounts maps the runner mounts of a user to container mounts.
+func toRunnerMounts(userMounts []auth.RunnerMount) []runnercontainer.Mount {
+	mounts := make([]runnercontainer.Mount, 0, len(userMounts))
+	for _, mount := range userMounts {
+		mounts = append(mounts, runnercontainer.Mount{
+			Host:      filepath.Clean(mount.Host),
+			Container: path.Clean(mount.Container),
+			ReadOnly:  mount.ReadOnly,
+		})
+	}
+	return mounts
+}
+
+// userRunnerMounts looks up the runner mounts of a user in the user store on
+// every container start, so mounts set with users set-mounts apply once the
+// user's containers are recreated.
+func userRunnerMounts(store *auth.Store) func(schema.UserID) []runnercontainer.Mount {
+	return func(userID schema.UserID) []runnercontainer.Mount {
+		return toRunnerMounts(store.RunnerMounts(userID))
+	}
+}
+
+// runnerMountConfig is the part of the runner provider config the mount
+// validation checks against.
+func runnerMountConfig(cfg appconfig.Config) runnercontainer.Config {
+	return runnercontainer.Config{
+		RunnerRepoRoot: cfg.Runner.RepoRoot,
+		SockDir:        cfg.Runner.SockDir,
+		SSHAgentDir:    cfg.SSH.AgentDir,
+	}
+}
+
+// validateUserRunnerMounts checks the runner mounts of every user.
+func validateUserRunnerMounts(cfg appconfig.Config, users []auth.User) error {
+	mountCfg := runnerMountConfig(cfg)
+	for _, user := range users {
+		if err := mountCfg.ValidateMounts(toRunnerMounts(user.RunnerMounts)); err != nil {
+			return fmt.Errorf("runner mounts of user %s: %w", user.Username, err)
+		}
+	}
+	return nil
+}
+
+// runnerSecurity maps runner.security to the options applied to runner
+// containers.
+func runnerSecurity(cfg appconfig.Config) runnercontainer.Security {
+	sec := cfg.Runner.Security
+	caps := make([]string, 0, len(sec.DropCapabilities))
+	for _, capability := range sec.DropCapabilities {
+		if name := strings.ToUpper(strings.TrimSpace(capability)); name != "" {
+			caps = append(caps, name)
+		}
+	}
+	if len(caps) == 0 {
+		caps = nil
+	}
+	return runnercontainer.Security{
+		NoNewPrivileges:  sec.NoNewPrivileges,
+		SeccompProfile:   strings.TrimSpace(sec.SeccompProfile),
+		DropCapabilities: caps,
+		NetworkMode:      shipohoy.NetworkMode(sec.Network),
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"slices"
+	"strings"
 	"testing"
 
 	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/shipohoy"
 )
 
 func TestValidateRunnerConfigRejectsHostRepoRoot(t *testing.T) {
@@ -21,3 +24,139 @@ func TestValidateRunnerConfigRejectsHostRepoRoot(t *testing.T) {
 		t.Fatalf("expected validation error for runner.repo_root pointing at host path")
 	}
 }
+
+func TestValidateRunnerSecurity(t *testing.T) {
+	valid := []appconfig.RunnerSecurity{
+		{},
+		{NoNewPrivileges: true, SeccompProfile: "default", DropCapabilities: []string{"net_raw", "CAP_SYS_ADMIN"}, Network: "none"},
+		{SeccompProfile: "/etc/centaurx/seccomp.json", Network: "host"},
+	}
+	for _, sec := range valid {
+		if err := validateRunnerSecurity(sec); err != nil {
+			t.Fatalf("expected %+v to be valid: %v", sec, err)
+		}
+	}
+	invalid := []appconfig.RunnerSecurity{
+		{Network: "bridge"},
+		{SeccompProfile: "seccomp.json"},
+		{DropCapabilities: []string{"net raw"}},
+	}
+	for _, sec := range invalid {
+		if err := validateRunnerSecurity(sec); err == nil {
+			t.Fatalf("expected %+v to be rejected", sec)
+		}
+	}
+}
+
+func TestValidateRunnerConfigAllowedHosts(t *testing.T) {
+	cfg, err := appconfig.DefaultConfig()
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	cfg.Runner.Network.AllowedHosts = []string{"github.com:22", "10.0.0.0/8"}
+	if err := validateRunnerConfig(cfg); err != nil {
+		t.Fatalf("expected allowlist to be valid: %v", err)
+	}
+	cfg.Runner.Security.Network = "Host"
+	if err := validateRunnerConfig(cfg); err == nil {
+		t.Fatalf("expected allowlist with host network to be rejected")
+	}
+	cfg.Runner.Security.Network = ""
+	cfg.Runner.Network.AllowedHosts = []string{"github.com"}
+	if err := validateRunnerConfig(cfg); err == nil {
+		t.Fatalf("expected entry without port to be rejected")
+	}
+}
+
+func TestValidateRunnerConfigLogCapture(t *testing.T) {
+	cfg, err := appconfig.DefaultConfig()
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	cfg.Runner.LogCaptureTotalBytes = 0
+	if err := validateRunnerConfig(cfg); err != nil {
+		t.Fatalf("expected uncapped log capture to be valid: %v", err)
+	}
+	cfg.Runner.LogCaptureTotalBytes = -1
+	if err := validateRunnerConfig(cfg); err == nil || !strings.Contains(err.Error(), "runner.log_capture_total_bytes") {
+		t.Fatalf("expected negative total to be rejected, got %v", err)
+	}
+	cfg.Runner.LogCaptureTotalBytes = 0
+	cfg.Runner.LogCaptureBytes = -1
+	if err := validateRunnerConfig(cfg); err == nil || !strings.Contains(err.Error(), "runner.log_capture_bytes") {
+		t.Fatalf("expected negative size to be rejected, got %v", err)
+	}
+}
+
+func TestValidateRunnerConfigCodexFlags(t *testing.T) {
+	cfg, err := appconfig.DefaultConfig()
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	cfg.Runner.CodexFlags = []string{"--sandbox", "workspace-write"}
+	cfg.Runner.CodexFlagsByModel = []appconfig.ModelCodexFlags{{Model: "gpt-5.2-codex", Flags: []string{"-c", "tools.web_search=true"}}}
+	if err := validateRunnerConfig(cfg); err != nil {
+		t.Fatalf("expected codex flags to be valid: %v", err)
+	}
+	byModel := codexFlagsByModel(append(cfg.Runner.CodexFlagsByModel, appconfig.ModelCodexFlags{Model: " gpt-5.2-codex ", Flags: []string{"--full-auto"}}))
+	if got := byModel["gpt-5.2-codex"]; !slices.Equal(got, []string{"-c", "tools.web_search=true", "--full-auto"}) {
+		t.Fatalf("unexpected flags by model %v", byModel)
+	}
+
+	cfg.Runner.CodexFlags = []string{"--json"}
+	if err := validateRunnerConfig(cfg); err == nil || !strings.Contains(err.Error(), "runner.codex_flags") {
+		t.Fatalf("expected managed flag to be rejected, got %v", err)
+	}
+	cfg.Runner.CodexFlags = nil
+	cfg.Runner.CodexFlagsByModel = []appconfig.ModelCodexFlags{{Model: "gpt-5.2-codex", Flags: []string{"--model", "o3"}}}
+	if err := validateRunnerConfig(cfg); err == nil || !strings.Contains(err.Error(), "runner.codex_flags_by_model[0]") {
+		t.Fatalf("expected per-model managed flag to be rejected, got %v", err)
+	}
+	cfg.Runner.CodexFlagsByModel = []appconfig.ModelCodexFlags{{Flags: []string{"--full-auto"}}}
+	if err := validateRunnerConfig(cfg); err == nil {
+		t.Fatalf("expected entry without model to be rejected")
+	}
+}
+
+func TestValidateRunnerConfigMounts(t *testing.T) {
+	cfg, err := appconfig.DefaultConfig()
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	cfg.Runner.ExtraMounts = []appconfig.RunnerMount{{Host: "/data/projects/", Container: "/projects", ReadOnly: true}}
+	if err := validateRunnerConfig(cfg); err != nil {
+		t.Fatalf("expected mounts to be valid: %v", err)
+	}
+	if got := runnerMounts(cfg); len(got) != 1 || got[0].Host != "/data/projects" || !got[0].ReadOnly {
+		t.Fatalf("unexpected runner mounts %+v", got)
+	}
+	cfg.Runner.ExtraMounts = []appconfig.RunnerMount{{Host: "data", Container: "/data"}}
+	if err := validateRunnerConfig(cfg); err == nil || !strings.Contains(err.Error(), "runner.extra_mounts[0]: host") {
+		t.Fatalf("expected a relative host path to be rejected, got %v", err)
+	}
+	cfg.Runner.ExtraMounts = []appconfig.RunnerMount{{Host: "/data", Container: ""}}
+	if err := validateRunnerConfig(cfg); err == nil || !strings.Contains(err.Error(), "runner.extra_mounts[0]: container") {
+		t.Fatalf("expected a missing container path to be rejected, got %v", err)
+	}
+}
+
+func TestRunnerSecurity(t *testing.T) {
+	cfg, err := appconfig.DefaultConfig()
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	if got := runnerSecurity(cfg); got.NoNewPrivileges || got.SeccompProfile != "" || got.DropCapabilities != nil || got.NetworkMode != shipohoy.NetworkDefault {
+		t.Fatalf("expected defaults to keep runtime behavior, got %+v", got)
+	}
+
+	cfg.Runner.Security = appconfig.RunnerSecurity{
+		NoNewPrivileges:  true,
+		SeccompProfile:   " unconfined ",
+		DropCapabilities: []string{"net_raw", " ", "ALL"},
+		Network:          "none",
+	}
+	got := runnerSecurity(cfg)
+	if !got.NoNewPrivileges || got.SeccompProfile != shipohoy.SeccompUnconfined || !slices.Equal(got.DropCapabilities, []string{"NET_RAW", "ALL"}) || got.NetworkMode != shipohoy.NetworkNone {
+		t.Fatalf("unexpected security %+v", got)
+	}
+}
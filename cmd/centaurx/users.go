@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mdp/qrterminal/v3"
 	"github.com/pquerna/otp/totp"
@@ -16,6 +19,7 @@ import (
 
 	"pkt.systems/centaurx/internal/appconfig"
 	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/persist"
 	"pkt.systems/centaurx/internal/sshkeys"
 	"pkt.systems/centaurx/internal/userhome"
 	"pkt.systems/centaurx/schema"
@@ -33,8 +37,14 @@ func newUsersCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "users",
 		Short: "Manage centaurx users",
+		Long: "Manage centaurx users.\n\n" +
+			"With --json, list prints an array of {id, created, admin, login_pubkeys, git_keys, " +
+			"daily_token_budget, tokens_used_today} sorted by id, list-login-pubkeys an array of " +
+			"{id, key}, and the other subcommands a {id, action, changed, ...} object. Errors print " +
+			"nothing on stdout and exit non-zero.",
 	}
 	cmd.PersistentFlags().StringVarP(&cfgPath, "config", "c", "", "path to config file")
+	cmd.PersistentFlags().Bool("json", false, "print machine-readable JSON")
 
 	cmd.AddCommand(newUsersListCmd(&cfgPath))
 	cmd.AddCommand(newUsersAddCmd(&cfgPath))
@@ -45,6 +55,11 @@ func newUsersCmd() *cobra.Command {
 	cmd.AddCommand(newUsersAddLoginPubKey(&cfgPath))
 	cmd.AddCommand(newUsersListLoginPubKeys(&cfgPath))
 	cmd.AddCommand(newUsersRemoveLoginPubKey(&cfgPath))
+	cmd.AddCommand(newUsersSetTokenBudget(&cfgPath))
+	cmd.AddCommand(newUsersSetMounts(&cfgPath))
+	cmd.AddCommand(newUsersUnlockCmd(&cfgPath))
+	cmd.AddCommand(newUsersExportCmd(&cfgPath))
+	cmd.AddCommand(newUsersImportCmd(&cfgPath))
 
 	return cmd
 }
@@ -63,6 +78,21 @@ func newUsersListCmd(cfgPath *string) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if usersJSON(cmd) {
+				keyStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
+				if err != nil {
+					return err
+				}
+				state, err := persist.NewStoreWithLogger(cfg.StateDir, logger)
+				if err != nil {
+					return err
+				}
+				records, err := listUserRecords(cfg, store, keyStore, state, logger, time.Now())
+				if err != nil {
+					return err
+				}
+				return writeJSON(cmd.OutOrStdout(), records)
+			}
 			users := store.LoadUsers()
 			out := cmd.OutOrStdout()
 			for _, user := range users {
@@ -131,8 +161,7 @@ func newUsersAddCmd(cfgPath *string) *cobra.Command {
 				_ = keyStore.RemoveKey(username)
 				return err
 			}
-			printUserEnrollment(cmd.OutOrStdout(), username, password, generated, secret, url, pubKey)
-			return nil
+			return writeUserEnrollment(cmd, "add", username, password, generated, secret, url, pubKey)
 		},
 	}
 	cmd.Flags().BoolVar(&passwordFromStdin, "password-from-stdin", false, "read password from stdin")
@@ -167,8 +196,47 @@ func newUsersDeleteCmd(cfgPath *string) *cobra.Command {
 			if err := keyStore.RemoveKey(args[0]); err != nil {
 				return err
 			}
-			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "deleted user: %s\n", args[0])
-			return nil
+			return writeUserResult(cmd, userResult{ID: args[0], Action: "delete", Changed: true}, func(out io.Writer) {
+				_, _ = fmt.Fprintf(out, "deleted user: %s\n", args[0])
+			})
+		},
+	}
+}
+
+func newUsersUnlockCmd(cfgPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlock <username>",
+		Short: "Clear failed login attempts and lockout for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			username := args[0]
+			if err := validateUsername(username); err != nil {
+				return err
+			}
+			cfg, err := appconfig.Load(*cfgPath)
+			if err != nil {
+				return err
+			}
+			if cfg.Auth.LockoutFile == "" {
+				return errors.New("auth.lockout_file is not set; lockouts are kept in server memory only")
+			}
+			throttleCfg := toThrottleConfig(cfg.Auth)
+			throttleCfg.Logger = pslog.Ctx(cmd.Context())
+			throttle, err := auth.NewThrottle(throttleCfg)
+			if err != nil {
+				return err
+			}
+			cleared, err := throttle.Unlock(username)
+			if err != nil {
+				return err
+			}
+			return writeUserResult(cmd, userResult{ID: username, Action: "unlock", Changed: cleared}, func(out io.Writer) {
+				if !cleared {
+					_, _ = fmt.Fprintf(out, "no failed logins recorded for %s\n", username)
+					return
+				}
+				_, _ = fmt.Fprintf(out, "unlocked user: %s\n", username)
+			})
 		},
 	}
 }
@@ -209,8 +277,7 @@ func newUsersRotateTOTP(cfgPath *string) *cobra.Command {
 					return err
 				}
 			}
-			printUserEnrollment(cmd.OutOrStdout(), username, "", false, secret, url, pubKey)
-			return nil
+			return writeUserEnrollment(cmd, "rotate-totp", username, "", false, secret, url, pubKey)
 		},
 	}
 }
@@ -260,8 +327,7 @@ func newUsersChpasswd(cfgPath *string) *cobra.Command {
 			if _, err := userhome.EnsureHome(cfg.StateDir, username, skelDir, data); err != nil {
 				return err
 			}
-			printUserEnrollment(cmd.OutOrStdout(), username, password, generated, "", "", pubKey)
-			return nil
+			return writeUserEnrollment(cmd, "chpasswd", username, password, generated, "", "", pubKey)
 		},
 	}
 	cmd.Flags().BoolVar(&passwordFromStdin, "password-from-stdin", false, "read password from stdin")
@@ -294,8 +360,7 @@ func newUsersRotateSSHKey(cfgPath *string) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			printUserEnrollment(cmd.OutOrStdout(), username, "", false, "", "", pubKey)
-			return nil
+			return writeUserEnrollment(cmd, "rotate-ssh-key", username, "", false, "", "", pubKey)
 		},
 	}
 	cmd.Flags().StringVar(&sshKeyType, "ssh-key-type", sshkeys.KeyTypeEd25519, "ssh key type (ed25519 or rsa)")
@@ -330,8 +395,9 @@ func newUsersAddLoginPubKey(cfgPath *string) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "login pubkey added (id %d)\n", id)
-			return nil
+			return writeUserResult(cmd, userResult{ID: username, Action: "add-login-pubkey", Changed: true, LoginPubKeyID: id}, func(out io.Writer) {
+				_, _ = fmt.Fprintf(out, "login pubkey added (id %d)\n", id)
+			})
 		},
 	}
 }
@@ -360,6 +426,13 @@ func newUsersListLoginPubKeys(cfgPath *string) *cobra.Command {
 				return err
 			}
 			out := cmd.OutOrStdout()
+			if usersJSON(cmd) {
+				records := make([]loginPubKeyRecord, 0, len(keys))
+				for idx, key := range keys {
+					records = append(records, loginPubKeyRecord{ID: idx + 1, Key: strings.TrimSpace(key)})
+				}
+				return writeJSON(out, records)
+			}
 			if len(keys) == 0 {
 				_, _ = fmt.Fprintln(out, "no login pubkeys")
 				return nil
@@ -398,12 +471,138 @@ func newUsersRemoveLoginPubKey(cfgPath *string) *cobra.Command {
 			if err := store.RemoveLoginPubKey(schema.UserID(username), id); err != nil {
 				return err
 			}
-			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "login pubkey removed (id %d)\n", id)
-			return nil
+			return writeUserResult(cmd, userResult{ID: username, Action: "rm-login-pubkey", Changed: true, LoginPubKeyID: id}, func(out io.Writer) {
+				_, _ = fmt.Fprintf(out, "login pubkey removed (id %d)\n", id)
+			})
+		},
+	}
+}
+
+func newUsersSetTokenBudget(cfgPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-token-budget <username> <tokens|unlimited|default>",
+		Short: "Set a user's daily codex token budget",
+		Long:  "Overrides budgets.daily_tokens_per_user for one user. unlimited lifts the budget and default returns the user to the configured one.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			username := args[0]
+			if err := validateUsername(username); err != nil {
+				return err
+			}
+			var budget *int64
+			switch value := strings.ToLower(args[1]); value {
+			case "default":
+			case "unlimited":
+				budget = new(int64)
+			default:
+				tokens, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || tokens <= 0 {
+					return errors.New("token budget must be a positive number, unlimited or default")
+				}
+				budget = &tokens
+			}
+			cfg, err := appconfig.Load(*cfgPath)
+			if err != nil {
+				return err
+			}
+			logger := pslog.Ctx(cmd.Context())
+			store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, cfg.Auth.SeedUsers, logger)
+			if err != nil {
+				return err
+			}
+			if err := store.SetDailyTokenBudget(username, budget); err != nil {
+				return err
+			}
+			effective := cfg.Budgets.DailyTokensPerUser
+			if budget != nil {
+				effective = *budget
+			}
+			return writeUserResult(cmd, userResult{ID: username, Action: "set-token-budget", Changed: true, DailyTokenBudget: &effective}, func(out io.Writer) {
+				switch {
+				case budget == nil:
+					_, _ = fmt.Fprintf(out, "token budget of %s: default (%d tokens per day, 0 = none)\n", username, cfg.Budgets.DailyTokensPerUser)
+				case *budget == 0:
+					_, _ = fmt.Fprintf(out, "token budget of %s: unlimited\n", username)
+				default:
+					_, _ = fmt.Fprintf(out, "token budget of %s: %d tokens per day\n", username, *budget)
+				}
+			})
 		},
 	}
 }
 
+func newUsersSetMounts(cfgPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-mounts <username> [host:container[:ro]]...",
+		Short: "Set the extra runner mounts of a user",
+		Long: "Replaces the host directories mounted into the user's runner containers on top of runner.extra_mounts; " +
+			"a user mount at the same container path as a global one replaces it. Without mounts the user's own are removed. " +
+			"Changes apply when the user's runner containers are next created.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			username := args[0]
+			if err := validateUsername(username); err != nil {
+				return err
+			}
+			mounts := make([]auth.RunnerMount, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				mount, err := parseRunnerMount(arg)
+				if err != nil {
+					return err
+				}
+				mounts = append(mounts, mount)
+			}
+			cfg, err := appconfig.Load(*cfgPath)
+			if err != nil {
+				return err
+			}
+			if err := runnerMountConfig(cfg).ValidateMounts(toRunnerMounts(mounts)); err != nil {
+				return err
+			}
+			logger := pslog.Ctx(cmd.Context())
+			store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, cfg.Auth.SeedUsers, logger)
+			if err != nil {
+				return err
+			}
+			if err := store.SetRunnerMounts(username, mounts); err != nil {
+				return err
+			}
+			return writeUserResult(cmd, userResult{ID: username, Action: "set-mounts", Changed: true, RunnerMounts: mounts}, func(out io.Writer) {
+				if len(mounts) == 0 {
+					_, _ = fmt.Fprintf(out, "runner mounts of %s: runner.extra_mounts only\n", username)
+					return
+				}
+				for _, mount := range mounts {
+					mode := "read-write"
+					if mount.ReadOnly {
+						mode = "read-only"
+					}
+					_, _ = fmt.Fprintf(out, "runner mount of %s: %s -> %s (%s)\n", username, mount.Host, mount.Container, mode)
+				}
+				_, _ = fmt.Fprintln(out, "restart the user's runner containers for the change to apply")
+			})
+		},
+	}
+}
+
+// parseRunnerMount parses a host:container[:ro] mount argument.
+func parseRunnerMount(value string) (auth.RunnerMount, error) {
+	parts := strings.Split(value, ":")
+	mount := auth.RunnerMount{}
+	switch {
+	case len(parts) == 3 && (parts[2] == "ro" || parts[2] == "rw"):
+		mount.ReadOnly = parts[2] == "ro"
+	case len(parts) != 2:
+		return auth.RunnerMount{}, fmt.Errorf("invalid mount %q: expected host:container[:ro]", value)
+	}
+	mount.Host = filepath.Clean(parts[0])
+	mount.Container = path.Clean(parts[1])
+	if !filepath.IsAbs(parts[0]) || !path.IsAbs(parts[1]) {
+		return auth.RunnerMount{}, fmt.Errorf("invalid mount %q: host and container must be absolute paths", value)
+	}
+	return mount, nil
+}
+
 func resolvePassword(cmd *cobra.Command, fromStdin, auto bool) (string, bool, error) {
 	if fromStdin && auto {
 		return "", false, errors.New("choose one of --password-from-stdin or --auto-password")
@@ -470,6 +669,25 @@ func generateTOTP(username string) (string, string, error) {
 	return key.Secret(), key.URL(), nil
 }
 
+// writeUserEnrollment prints the credentials of a new or changed user. The
+// password is only included when it was generated.
+func writeUserEnrollment(cmd *cobra.Command, action, username, password string, showPassword bool, secret, url string, sshPublicKey string) error {
+	result := userResult{
+		ID:           username,
+		Action:       action,
+		Changed:      true,
+		TOTPSecret:   secret,
+		OTPAuthURL:   url,
+		SSHPublicKey: strings.TrimSpace(sshPublicKey),
+	}
+	if showPassword {
+		result.Password = password
+	}
+	return writeUserResult(cmd, result, func(out io.Writer) {
+		printUserEnrollment(out, username, password, showPassword, secret, url, sshPublicKey)
+	})
+}
+
 func printUserEnrollment(w io.Writer, username, password string, showPassword bool, secret, url string, sshPublicKey string) {
 	_, _ = fmt.Fprintf(w, "username: %s\n", username)
 	if showPassword && password != "" {
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/internal/userexport"
+	"pkt.systems/centaurx/internal/userhome"
+	"pkt.systems/centaurx/internal/version"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+func newUsersExportCmd(cfgPath *string) *cobra.Command {
+	var outPath string
+	var includeSecrets bool
+	cmd := &cobra.Command{
+		Use:   "export <username>",
+		Short: "Export a user's state to a tar.gz bundle",
+		Long: "Export a user's tabs, buffers, prompt history, login public keys and git public key to a tar.gz bundle for `users import` on another server.\n\n" +
+			"With --include-secrets the bundle also carries the password hash, the TOTP secret, the git private key and the codex auth.json; keep such a bundle private. Repos are not included.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			username := args[0]
+			if err := validateUsername(username); err != nil {
+				return err
+			}
+			if strings.TrimSpace(outPath) == "" {
+				return errors.New("--out is required")
+			}
+			cfg, err := appconfig.Load(*cfgPath)
+			if err != nil {
+				return err
+			}
+			logger := pslog.Ctx(cmd.Context())
+			store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, cfg.Auth.SeedUsers, logger)
+			if err != nil {
+				return err
+			}
+			keyStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
+			if err != nil {
+				return err
+			}
+			state, err := persist.NewStoreWithLogger(cfg.StateDir, logger)
+			if err != nil {
+				return err
+			}
+			user, found := lookupUser(store.LoadUsers(), username)
+			snapshot, hasState, err := state.Load(schema.UserID(username))
+			if err != nil {
+				return err
+			}
+			if !found && !hasState {
+				return fmt.Errorf("user %s not found", username)
+			}
+			bundle := userexport.Bundle{
+				Manifest: userexport.Manifest{
+					ServerVersion:   version.Current(),
+					ExportedAt:      time.Now().UTC(),
+					Username:        username,
+					RepoRoot:        cfg.RepoRoot,
+					IncludesSecrets: includeSecrets,
+				},
+				Account: userexport.Account{
+					PasswordHash: user.PasswordHash,
+					TOTPSecret:   user.TOTPSecret,
+					LoginPubKeys: user.LoginPubKeys,
+				},
+			}
+			if hasState {
+				bundle.Snapshot = &snapshot
+			}
+			bundle.Account.GitPublicKey, err = keyStore.LoadPublicKey(username)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if includeSecrets {
+				bundle.GitPrivateKey, err = keyStore.ExportKey(username)
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				bundle.CodexAuth, err = os.ReadFile(userhome.AuthPath(cfg.StateDir, username))
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
+			file, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			if err := userexport.Write(file, bundle); err != nil {
+				_ = file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+			result := userResult{ID: username, Action: "export", Changed: true, Bundle: outPath, IncludesSecrets: includeSecrets}
+			return writeUserResult(cmd, result, func(out io.Writer) {
+				_, _ = fmt.Fprintf(out, "exported user %s to %s\n", username, outPath)
+				if includeSecrets {
+					_, _ = fmt.Fprintln(out, "the bundle includes secrets; keep it private")
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&outPath, "out", "o", "", "path of the tar.gz bundle to write")
+	cmd.Flags().BoolVar(&includeSecrets, "include-secrets", false, "include the password hash, TOTP secret, git private key and codex auth.json")
+	return cmd
+}
+
+func newUsersImportCmd(cfgPath *string) *cobra.Command {
+	var inPath string
+	var rename string
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import a user from a tar.gz bundle",
+		Long: "Import a user exported with `users export`. Repo paths in the user's tabs are moved to this server's repo root; repos that do not exist here are reported and can be cloned again with /new.\n\n" +
+			"Without secrets in the bundle a new password, TOTP secret and git SSH key are generated and printed. Tab shares and codex sessions stay behind. " +
+			"Import while the user is signed out, or restart the server afterwards, so the running server does not overwrite the imported state.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.TrimSpace(inPath) == "" {
+				return errors.New("--in is required")
+			}
+			file, err := os.Open(inPath)
+			if err != nil {
+				return err
+			}
+			bundle, err := userexport.Read(file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+			username := bundle.Manifest.Username
+			if rename != "" {
+				username = rename
+			}
+			if err := validateUsername(username); err != nil {
+				return err
+			}
+			cfg, err := appconfig.Load(*cfgPath)
+			if err != nil {
+				return err
+			}
+			logger := pslog.Ctx(cmd.Context())
+			store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, cfg.Auth.SeedUsers, logger)
+			if err != nil {
+				return err
+			}
+			keyStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
+			if err != nil {
+				return err
+			}
+			state, err := persist.NewStoreWithLogger(cfg.StateDir, logger)
+			if err != nil {
+				return err
+			}
+			previous, exists := lookupUser(store.LoadUsers(), username)
+			_, hasState, err := state.Load(schema.UserID(username))
+			if err != nil && !force {
+				return err
+			}
+			_, keyErr := keyStore.LoadPublicKey(username)
+			if (exists || hasState || keyErr == nil) && !force {
+				return fmt.Errorf("user %s already exists; use --force to replace it", username)
+			}
+			if len(bundle.GitPrivateKey) > 0 {
+				if _, err := ssh.ParseRawPrivateKey(bundle.GitPrivateKey); err != nil {
+					return fmt.Errorf("parse git private key in bundle: %w", err)
+				}
+			}
+			user := auth.User{
+				Username:     username,
+				PasswordHash: bundle.Account.PasswordHash,
+				TOTPSecret:   bundle.Account.TOTPSecret,
+				LoginPubKeys: bundle.Account.LoginPubKeys,
+			}
+			var password, secret, url string
+			if user.PasswordHash == "" {
+				password, err = generatePassword(defaultPasswordLength)
+				if err != nil {
+					return err
+				}
+				hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+				if err != nil {
+					return err
+				}
+				user.PasswordHash = string(hash)
+			}
+			if user.TOTPSecret == "" {
+				secret, url, err = generateTOTP(username)
+				if err != nil {
+					return err
+				}
+				user.TOTPSecret = secret
+			}
+			var warnings []string
+			snapshot := persist.UserSnapshot{}
+			if bundle.Snapshot != nil {
+				snapshot = *bundle.Snapshot
+				warnings = append(warnings, userexport.PrepareSnapshot(&snapshot, bundle.Manifest, cfg.RepoRoot, username)...)
+			}
+			skelDir := userhome.SkelDir(cfg.StateDir)
+			data := userhome.DefaultTemplateData(cfg)
+			if _, err := userhome.EnsureHome(cfg.StateDir, username, skelDir, data); err != nil {
+				return err
+			}
+
+			// Keep what the import replaces so a failure below can put the
+			// original account back instead of leaving no account at all.
+			var previousKey []byte
+			if keyErr == nil {
+				previousKey, err = keyStore.ExportKey(username)
+				if err != nil {
+					return fmt.Errorf("read existing git key: %w", err)
+				}
+			}
+			authPath := userhome.AuthPath(cfg.StateDir, username)
+			previousAuth, err := os.ReadFile(authPath)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			hadAuth := err == nil
+			restore := func(cause error) error {
+				errs := []error{cause}
+				if err := store.DeleteUser(username); err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+					errs = append(errs, fmt.Errorf("remove imported user: %w", err))
+				}
+				if exists {
+					if err := store.AddUser(previous); err != nil {
+						errs = append(errs, fmt.Errorf("restore user: %w", err))
+					}
+				}
+				if previousKey != nil {
+					if _, err := keyStore.ImportKey(username, previousKey); err != nil {
+						errs = append(errs, fmt.Errorf("restore git key: %w", err))
+					}
+				} else if err := keyStore.RemoveKey(username); err != nil {
+					errs = append(errs, fmt.Errorf("remove imported git key: %w", err))
+				}
+				if hadAuth {
+					if err := os.WriteFile(authPath, previousAuth, 0o600); err != nil {
+						errs = append(errs, fmt.Errorf("restore codex auth: %w", err))
+					}
+				} else if err := os.Remove(authPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, fmt.Errorf("remove imported codex auth: %w", err))
+				}
+				return errors.Join(errs...)
+			}
+
+			if exists {
+				if err := store.DeleteUser(username); err != nil {
+					return err
+				}
+			}
+			if err := store.AddUser(user); err != nil {
+				return restore(err)
+			}
+			var pubKey string
+			if len(bundle.GitPrivateKey) > 0 {
+				pubKey, err = keyStore.ImportKey(username, bundle.GitPrivateKey)
+			} else {
+				pubKey, err = keyStore.RotateKey(username, sshkeys.KeyTypeEd25519, 0)
+				if bundle.Account.GitPublicKey != "" {
+					warnings = append(warnings, "the git ssh key was not exported; add the new public key to your git hosts")
+				}
+			}
+			if err != nil {
+				return restore(err)
+			}
+			if len(bundle.CodexAuth) > 0 {
+				if err := os.WriteFile(authPath, bundle.CodexAuth, 0o600); err != nil {
+					return restore(err)
+				}
+			}
+			if bundle.Snapshot != nil || hasState {
+				if err := state.Save(schema.UserID(username), snapshot); err != nil {
+					return restore(err)
+				}
+			}
+
+			result := userResult{
+				ID:           username,
+				Action:       "import",
+				Changed:      true,
+				Password:     password,
+				TOTPSecret:   secret,
+				OTPAuthURL:   url,
+				SSHPublicKey: strings.TrimSpace(pubKey),
+				Bundle:       inPath,
+				Warnings:     warnings,
+			}
+			return writeUserResult(cmd, result, func(out io.Writer) {
+				_, _ = fmt.Fprintf(out, "imported user %s from %s (exported by centaurx %s at %s)\n",
+					username, bundle.Manifest.Username, bundle.Manifest.ServerVersion, bundle.Manifest.ExportedAt.Format(time.RFC3339))
+				printUserEnrollment(out, username, password, password != "", secret, url, pubKey)
+				for _, warning := range warnings {
+					_, _ = fmt.Fprintf(out, "warning: %s\n", warning)
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&inPath, "in", "i", "", "path of the tar.gz bundle to read")
+	cmd.Flags().StringVar(&rename, "rename", "", "import under another username")
+	cmd.Flags().BoolVar(&force, "force", false, "replace an existing user")
+	return cmd
+}
+
+func lookupUser(users []auth.User, username string) (auth.User, bool) {
+	for _, user := range users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return auth.User{}, false
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/internal/userexport"
+	"pkt.systems/centaurx/schema"
+)
+
+func runUsersCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := newUsersCmd()
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestUsersExportImportMovesState(t *testing.T) {
+	srcPath := writeTestConfig(t)
+	src := loadConfigFromPath(t, srcPath)
+	if _, err := runUsersCmd(t, "-c", srcPath, "add", "alice", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	state, err := persist.NewStore(src.StateDir)
+	if err != nil {
+		t.Fatalf("state store: %v", err)
+	}
+	if err := state.Save("alice", persist.UserSnapshot{
+		Order: []schema.TabID{"tab1"},
+		Tabs: []persist.TabSnapshot{{
+			ID:      "tab1",
+			Name:    "demo",
+			Repo:    schema.RepoRef{Name: "demo", Path: filepath.Join(src.RepoRoot, "alice", "demo")},
+			History: []persist.HistoryEntry{{Text: "fix the tests"}},
+		}},
+	}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "alice.tar.gz")
+	if _, err := runUsersCmd(t, "-c", srcPath, "export", "alice", "--out", bundlePath, "--include-secrets"); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dstPath := writeTestConfig(t)
+	dst := loadConfigFromPath(t, dstPath)
+	out, err := runUsersCmd(t, "-c", dstPath, "import", "--in", bundlePath, "--rename", "bob")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !strings.Contains(out, "repo demo is not on this server") {
+		t.Fatalf("expected a missing repo warning, got %q", out)
+	}
+
+	srcUsers, err := auth.NewStoreWithLogger(src.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("source users: %v", err)
+	}
+	dstUsers, err := auth.NewStoreWithLogger(dst.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("target users: %v", err)
+	}
+	alice := findUser(srcUsers.LoadUsers(), "alice")
+	bob := findUser(dstUsers.LoadUsers(), "bob")
+	if bob == nil || bob.PasswordHash != alice.PasswordHash || bob.TOTPSecret != alice.TOTPSecret {
+		t.Fatalf("expected the secrets of alice, got %+v", bob)
+	}
+	srcKeys, err := sshkeys.NewStore(src.SSH.KeyStorePath, src.SSH.KeyDir)
+	if err != nil {
+		t.Fatalf("source keys: %v", err)
+	}
+	dstKeys, err := sshkeys.NewStore(dst.SSH.KeyStorePath, dst.SSH.KeyDir)
+	if err != nil {
+		t.Fatalf("target keys: %v", err)
+	}
+	srcPub, _ := srcKeys.LoadPublicKey("alice")
+	dstPub, _ := dstKeys.LoadPublicKey("bob")
+	if srcPub == "" || srcPub != dstPub {
+		t.Fatalf("expected the git key to move, got %q and %q", srcPub, dstPub)
+	}
+
+	dstState, err := persist.NewStore(dst.StateDir)
+	if err != nil {
+		t.Fatalf("target state: %v", err)
+	}
+	snapshot, ok, err := dstState.Load("bob")
+	if err != nil || !ok || len(snapshot.Tabs) != 1 {
+		t.Fatalf("expected the imported tab, got %+v, %v, %v", snapshot, ok, err)
+	}
+	if got, want := snapshot.Tabs[0].Repo.Path, filepath.Join(dst.RepoRoot, "bob", "demo"); got != want {
+		t.Fatalf("expected repo path %s, got %s", want, got)
+	}
+	if len(snapshot.Tabs[0].History) != 1 {
+		t.Fatalf("expected the prompt history, got %+v", snapshot.Tabs[0].History)
+	}
+
+	if _, err := runUsersCmd(t, "-c", dstPath, "import", "--in", bundlePath, "--rename", "bob"); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected an existing user to be refused, got %v", err)
+	}
+	if _, err := runUsersCmd(t, "-c", dstPath, "import", "--in", bundlePath, "--rename", "bob", "--force"); err != nil {
+		t.Fatalf("forced import: %v", err)
+	}
+}
+
+func TestUsersExportWithoutSecrets(t *testing.T) {
+	srcPath := writeTestConfig(t)
+	if _, err := runUsersCmd(t, "-c", srcPath, "add", "carol", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "carol.tar.gz")
+	if _, err := runUsersCmd(t, "-c", srcPath, "export", "carol", "--out", bundlePath); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	dstPath := writeTestConfig(t)
+	out, err := runUsersCmd(t, "-c", dstPath, "import", "--in", bundlePath)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	for _, want := range []string{"password: ", "totp_secret: ", "ssh_public_key: ", "git ssh key was not exported"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestUsersImportForceRestoresUserOnFailure(t *testing.T) {
+	srcPath := writeTestConfig(t)
+	if _, err := runUsersCmd(t, "-c", srcPath, "add", "alice", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	src := loadConfigFromPath(t, srcPath)
+	srcState, err := persist.NewStore(src.StateDir)
+	if err != nil {
+		t.Fatalf("state store: %v", err)
+	}
+	if err := srcState.Save("alice", persist.UserSnapshot{}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "alice.tar.gz")
+	if _, err := runUsersCmd(t, "-c", srcPath, "export", "alice", "--out", bundlePath, "--include-secrets"); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dstPath := writeTestConfig(t)
+	dst := loadConfigFromPath(t, dstPath)
+	if _, err := runUsersCmd(t, "-c", dstPath, "add", "bob", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	dstUsers, err := auth.NewStoreWithLogger(dst.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("target users: %v", err)
+	}
+	before := findUser(dstUsers.LoadUsers(), "bob")
+	dstKeys, err := sshkeys.NewStore(dst.SSH.KeyStorePath, dst.SSH.KeyDir)
+	if err != nil {
+		t.Fatalf("target keys: %v", err)
+	}
+	beforePub, err := dstKeys.LoadPublicKey("bob")
+	if err != nil {
+		t.Fatalf("load git key: %v", err)
+	}
+
+	// A file where bob's buffer segments go makes the final state save
+	// fail after the account and key have been replaced.
+	segments := persist.UserPaths(dst.StateDir, "bob").Segments
+	if err := os.WriteFile(segments, nil, 0o600); err != nil {
+		t.Fatalf("block state path: %v", err)
+	}
+	if _, err := runUsersCmd(t, "-c", dstPath, "import", "--in", bundlePath, "--rename", "bob", "--force"); err == nil {
+		t.Fatalf("expected the import to fail")
+	}
+
+	dstUsers, err = auth.NewStoreWithLogger(dst.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("target users: %v", err)
+	}
+	after := findUser(dstUsers.LoadUsers(), "bob")
+	if after == nil || after.PasswordHash != before.PasswordHash || after.TOTPSecret != before.TOTPSecret {
+		t.Fatalf("expected the original user back, got %+v", after)
+	}
+	afterPub, err := dstKeys.LoadPublicKey("bob")
+	if err != nil || afterPub != beforePub {
+		t.Fatalf("expected the original git key back, got %q, %v", afterPub, err)
+	}
+}
+
+func TestUsersImportRejectsBadGitKeyBeforeReplacing(t *testing.T) {
+	dstPath := writeTestConfig(t)
+	dst := loadConfigFromPath(t, dstPath)
+	if _, err := runUsersCmd(t, "-c", dstPath, "add", "bob", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := userexport.Write(&buf, userexport.Bundle{
+		Manifest:      userexport.Manifest{Username: "bob", IncludesSecrets: true},
+		GitPrivateKey: []byte("not a key"),
+	}); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "bob.tar.gz")
+	if err := os.WriteFile(bundlePath, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	if _, err := runUsersCmd(t, "-c", dstPath, "import", "--in", bundlePath, "--force"); err == nil {
+		t.Fatalf("expected a bad git key to be refused")
+	}
+	dstUsers, err := auth.NewStoreWithLogger(dst.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("target users: %v", err)
+	}
+	if findUser(dstUsers.LoadUsers(), "bob") == nil {
+		t.Fatalf("expected bob to survive a refused import")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// userRecord is one user in `users list --json`.
+type userRecord struct {
+	ID string `json:"id"`
+	// Created is when the user was added; null for users added before
+	// creation times were recorded.
+	Created *time.Time `json:"created"`
+	// Admin is true when the user has admin capabilities. Local users never
+	// do; admins come from auth.groups.admin of a directory provider.
+	Admin bool `json:"admin"`
+	// LoginPubKeys is the number of SSH login public keys.
+	LoginPubKeys int `json:"login_pubkeys"`
+	// GitKeys is the number of git SSH keys, 0 or 1.
+	GitKeys int `json:"git_keys"`
+	// DailyTokenBudget is the user's effective daily codex token budget;
+	// 0 means no budget.
+	DailyTokenBudget int64 `json:"daily_token_budget"`
+	// TokensUsedToday is the number of codex tokens used on the current
+	// UTC day as last saved by the server; null when the user's state could
+	// not be read.
+	TokensUsedToday *int64 `json:"tokens_used_today"`
+}
+
+// userResult is the outcome of a `users` subcommand that changes a user,
+// printed in place of the human output with --json. Fields that do not
+// apply to the subcommand are omitted.
+type userResult struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	// Changed is false when the subcommand had nothing to do.
+	Changed          bool               `json:"changed"`
+	Password         string             `json:"password,omitempty"`
+	TOTPSecret       string             `json:"totp_secret,omitempty"`
+	OTPAuthURL       string             `json:"otpauth_url,omitempty"`
+	SSHPublicKey     string             `json:"ssh_public_key,omitempty"`
+	LoginPubKeyID    int                `json:"login_pubkey_id,omitempty"`
+	DailyTokenBudget *int64             `json:"daily_token_budget,omitempty"`
+	RunnerMounts     []auth.RunnerMount `json:"runner_mounts,omitempty"`
+	Bundle           string             `json:"bundle,omitempty"`
+	IncludesSecrets  bool               `json:"includes_secrets,omitempty"`
+	Warnings         []string           `json:"warnings,omitempty"`
+}
+
+// loginPubKeyRecord is one key in `users list-login-pubkeys --json`.
+type loginPubKeyRecord struct {
+	ID  int    `json:"id"`
+	Key string `json:"key"`
+}
+
+// usersJSON reports whether --json was given to the users command.
+func usersJSON(cmd *cobra.Command) bool {
+	enabled, _ := cmd.Flags().GetBool("json")
+	return enabled
+}
+
+// writeUserResult prints result as JSON with --json and calls human
+// otherwise.
+func writeUserResult(cmd *cobra.Command, result userResult, human func(io.Writer)) error {
+	if usersJSON(cmd) {
+		return writeJSON(cmd.OutOrStdout(), result)
+	}
+	human(cmd.OutOrStdout())
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// listUserRecords describes every user in store, sorted by id.
+func listUserRecords(cfg appconfig.Config, store *auth.Store, keyStore *sshkeys.Store, state *persist.Store, logger pslog.Logger, now time.Time) ([]userRecord, error) {
+	users := store.LoadUsers()
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	today := now.UTC().Format("2006-01-02")
+	records := make([]userRecord, 0, len(users))
+	for _, user := range users {
+		record := userRecord{
+			ID:               user.Username,
+			Admin:            store.Capabilities(user.Username).Admin,
+			LoginPubKeys:     len(user.LoginPubKeys),
+			DailyTokenBudget: cfg.Budgets.DailyTokensPerUser,
+		}
+		if !user.CreatedAt.IsZero() {
+			created := user.CreatedAt
+			record.Created = &created
+		}
+		if user.DailyTokenBudget != nil {
+			record.DailyTokenBudget = *user.DailyTokenBudget
+		}
+		if _, err := keyStore.LoadPublicKey(user.Username); err == nil {
+			record.GitKeys = 1
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		snapshot, _, err := state.Load(schema.UserID(user.Username))
+		if err != nil {
+			logger.Warn("users list state unreadable", "user", user.Username, "err", err)
+		} else {
+			var used int64
+			if usage := snapshot.TokenUsage; usage != nil && usage.Day == today {
+				used = usage.Tokens
+			}
+			record.TokensUsedToday = &used
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/schema"
+)
+
+// The structs below pin the documented --json schemas; decoding rejects
+// fields they do not know.
+
+type userRecordSchema struct {
+	ID               string     `json:"id"`
+	Created          *time.Time `json:"created"`
+	Admin            bool       `json:"admin"`
+	LoginPubKeys     int        `json:"login_pubkeys"`
+	GitKeys          int        `json:"git_keys"`
+	DailyTokenBudget int64      `json:"daily_token_budget"`
+	TokensUsedToday  *int64     `json:"tokens_used_today"`
+}
+
+type userResultSchema struct {
+	ID               string   `json:"id"`
+	Action           string   `json:"action"`
+	Changed          bool     `json:"changed"`
+	Password         string   `json:"password"`
+	TOTPSecret       string   `json:"totp_secret"`
+	OTPAuthURL       string   `json:"otpauth_url"`
+	SSHPublicKey     string   `json:"ssh_public_key"`
+	LoginPubKeyID    int      `json:"login_pubkey_id"`
+	DailyTokenBudget *int64   `json:"daily_token_budget"`
+	RunnerMounts     []any    `json:"runner_mounts"`
+	Bundle           string   `json:"bundle"`
+	IncludesSecrets  bool     `json:"includes_secrets"`
+	Warnings         []string `json:"warnings"`
+}
+
+func runUsersJSON(t *testing.T, out any, args ...string) {
+	t.Helper()
+	var stdout bytes.Buffer
+	cmd := newUsersCmd()
+	cmd.SetArgs(append(args, "--json"))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("users %v: %v", args, err)
+	}
+	dec := json.NewDecoder(&stdout)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		t.Fatalf("decode users %v output %q: %v", args, stdout.String(), err)
+	}
+}
+
+func TestUsersJSONSchema(t *testing.T) {
+	cfgPath := writeTestConfig(t)
+	cfg := loadConfigFromPath(t, cfgPath)
+
+	var added userResultSchema
+	runUsersJSON(t, &added, "-c", cfgPath, "add", "bob", "--auto-password")
+	if added.ID != "bob" || added.Action != "add" || !added.Changed || added.Password == "" || added.TOTPSecret == "" || added.SSHPublicKey == "" {
+		t.Fatalf("unexpected add result %+v", added)
+	}
+	runUsersJSON(t, &added, "-c", cfgPath, "add", "alice", "--auto-password")
+
+	var budget userResultSchema
+	runUsersJSON(t, &budget, "-c", cfgPath, "set-token-budget", "bob", "5000")
+	if budget.Action != "set-token-budget" || budget.DailyTokenBudget == nil || *budget.DailyTokenBudget != 5000 {
+		t.Fatalf("unexpected budget result %+v", budget)
+	}
+	var pubKey userResultSchema
+	runUsersJSON(t, &pubKey, "-c", cfgPath, "add-login-pubkey", "alice", "ssh-ed25519", "AAAAC3NzaC1lZDI1NTE5AAAAIHqf0rRk3g4Dk8Zb7lq7W0b1n2Y8b2y8m1C1p9Q2ZcXr", "alice@laptop")
+	if pubKey.LoginPubKeyID != 1 {
+		t.Fatalf("unexpected add-login-pubkey result %+v", pubKey)
+	}
+
+	state, err := persist.NewStore(cfg.StateDir)
+	if err != nil {
+		t.Fatalf("state store: %v", err)
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	if err := state.Save(schema.UserID("alice"), persist.UserSnapshot{TokenUsage: &persist.TokenUsage{Day: today, Tokens: 1234}}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	if err := state.Save(schema.UserID("bob"), persist.UserSnapshot{TokenUsage: &persist.TokenUsage{Day: "2000-01-01", Tokens: 99}}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	var records []userRecordSchema
+	runUsersJSON(t, &records, "-c", cfgPath, "list")
+	if len(records) != 2 || records[0].ID != "alice" || records[1].ID != "bob" {
+		t.Fatalf("expected users sorted by id, got %+v", records)
+	}
+	alice, bob := records[0], records[1]
+	if alice.Created == nil || time.Since(*alice.Created) > time.Minute || alice.Admin {
+		t.Fatalf("unexpected alice record %+v", alice)
+	}
+	if alice.LoginPubKeys != 1 || alice.GitKeys != 1 || alice.DailyTokenBudget != cfg.Budgets.DailyTokensPerUser {
+		t.Fatalf("unexpected alice record %+v", alice)
+	}
+	if alice.TokensUsedToday == nil || *alice.TokensUsedToday != 1234 {
+		t.Fatalf("expected today's usage for alice, got %v", alice.TokensUsedToday)
+	}
+	if bob.DailyTokenBudget != 5000 || bob.TokensUsedToday == nil || *bob.TokensUsedToday != 0 {
+		t.Fatalf("expected bob's override and no usage today, got %+v", bob)
+	}
+
+	var keys []struct {
+		ID  int    `json:"id"`
+		Key string `json:"key"`
+	}
+	runUsersJSON(t, &keys, "-c", cfgPath, "list-login-pubkeys", "alice")
+	if len(keys) != 1 || keys[0].ID != 1 || keys[0].Key == "" {
+		t.Fatalf("unexpected login pubkeys %+v", keys)
+	}
+}
+
+func TestUsersJSONErrorExitsNonZero(t *testing.T) {
+	cfgPath := writeTestConfig(t)
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"users", "-c", cfgPath, "--json", "delete", "nobody"})
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatalf("expected deleting an unknown user to fail")
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no JSON output on error, got %q", stdout.String())
+	}
+}
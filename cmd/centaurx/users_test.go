@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -108,6 +109,47 @@ func TestUsersRotateTOTP(t *testing.T) {
 	}
 }
 
+func TestUsersSetTokenBudget(t *testing.T) {
+	cfgPath := writeTestConfig(t)
+	cfg := loadConfigFromPath(t, cfgPath)
+
+	run := func(args ...string) error {
+		cmd := newUsersCmd()
+		cmd.SetArgs(append([]string{"-c", cfgPath}, args...))
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		return cmd.Execute()
+	}
+	if err := run("add", "erin", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	if err := run("set-token-budget", "erin", "-5"); err == nil {
+		t.Fatalf("expected a negative budget to be rejected")
+	}
+	if err := run("set-token-budget", "erin", "250000"); err != nil {
+		t.Fatalf("set-token-budget: %v", err)
+	}
+	if budget, ok := store.DailyTokenBudget("erin"); !ok || budget != 250000 {
+		t.Fatalf("expected a 250000 token budget, got %d %v", budget, ok)
+	}
+	if err := run("set-token-budget", "erin", "unlimited"); err != nil {
+		t.Fatalf("set-token-budget unlimited: %v", err)
+	}
+	if budget, ok := store.DailyTokenBudget("erin"); !ok || budget != 0 {
+		t.Fatalf("expected no budget, got %d %v", budget, ok)
+	}
+	if err := run("set-token-budget", "erin", "default"); err != nil {
+		t.Fatalf("set-token-budget default: %v", err)
+	}
+	if _, ok := store.DailyTokenBudget("erin"); ok {
+		t.Fatalf("expected erin to use the configured budget")
+	}
+}
+
 func TestUsersRotateSSHKey(t *testing.T) {
 	cfgPath := writeTestConfig(t)
 
@@ -220,3 +262,42 @@ func findUser(users []auth.User, username string) *auth.User {
 	}
 	return nil
 }
+
+func TestUsersSetMounts(t *testing.T) {
+	cfgPath := writeTestConfig(t)
+	cfg := loadConfigFromPath(t, cfgPath)
+	dataset := t.TempDir()
+
+	run := func(args ...string) error {
+		cmd := newUsersCmd()
+		cmd.SetArgs(append([]string{"-c", cfgPath}, args...))
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		return cmd.Execute()
+	}
+	if err := run("add", "erin", "--auto-password"); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, nil, nil)
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	for _, bad := range []string{"data:/data", dataset + ":/etc/data", filepath.Join(dataset, "missing") + ":/data", dataset + ":/data:rx"} {
+		if err := run("set-mounts", "erin", bad); err == nil {
+			t.Fatalf("expected mount %q to be rejected", bad)
+		}
+	}
+	if err := run("set-mounts", "erin", dataset+":/data:ro"); err != nil {
+		t.Fatalf("set-mounts: %v", err)
+	}
+	want := []auth.RunnerMount{{Host: dataset, Container: "/data", ReadOnly: true}}
+	if got := store.RunnerMounts("erin"); !slices.Equal(got, want) {
+		t.Fatalf("expected mounts %+v, got %+v", want, got)
+	}
+	if err := run("set-mounts", "erin"); err != nil {
+		t.Fatalf("set-mounts clear: %v", err)
+	}
+	if got := store.RunnerMounts("erin"); len(got) != 0 {
+		t.Fatalf("expected no mounts, got %+v", got)
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// activityPrefix starts the text of every activity feed line.
+const activityPrefix = "event: "
+
+// RecordActivity appends an activity feed entry to the user's system buffer.
+func (s *service) RecordActivity(ctx context.Context, req schema.RecordActivityRequest) (schema.RecordActivityResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.RecordActivityResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID).With("activity", req.Kind)
+	label := req.Kind.Label()
+	if label == "" {
+		return schema.RecordActivityResponse{}, fmt.Errorf("%w: unknown activity kind %q", schema.ErrInvalidRequest, req.Kind)
+	}
+	text := activityPrefix + label
+	if detail := strings.TrimSpace(req.Detail); detail != "" {
+		text += " (" + detail + ")"
+	}
+	line := schema.Line(schema.LineKindActivity, text)
+
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	if state.system != nil {
+		state.system.Append(line)
+		line = state.system.lines[len(state.system.lines)-1]
+	}
+	s.mu.Unlock()
+	s.emitSystemOutput(userID, []string{line.Legacy()})
+	s.persistUser(log, userID)
+	log.Info("service activity recorded", "detail", req.Detail)
+	return schema.RecordActivityResponse{Line: line}, nil
+}
+
+// ListActivity returns the most recent activity feed entries still held by
+// the user's system buffer.
+func (s *service) ListActivity(ctx context.Context, req schema.ListActivityRequest) (schema.ListActivityResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ListActivityResponse{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.getOrCreateUserStateLocked(userID)
+	if state.system == nil {
+		return schema.ListActivityResponse{}, nil
+	}
+	var entries []schema.BufferLine
+	for i := len(state.system.lines) - 1; i >= 0; i-- {
+		if req.Limit > 0 && len(entries) == req.Limit {
+			break
+		}
+		if line := state.system.lines[i]; line.Kind == schema.LineKindActivity {
+			entries = append(entries, line)
+		}
+	}
+	slices.Reverse(entries)
+	logx.WithUser(ctx, userID).Trace("service activity listed", "entries", len(entries))
+	return schema.ListActivityResponse{Entries: entries}, nil
+}
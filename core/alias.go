@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// maxAliasNameLen bounds command alias names.
+const maxAliasNameLen = 32
+
+// ListAliases returns the user's command aliases sorted by name.
+func (s *service) ListAliases(ctx context.Context, req schema.ListAliasesRequest) (schema.ListAliasesResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ListAliasesResponse{}, err
+	}
+	s.mu.Lock()
+	aliases := sortedAliases(s.getOrCreateUserStateLocked(userID).aliases)
+	s.mu.Unlock()
+	logx.WithUser(ctx, userID).Trace("service aliases listed", "aliases", len(aliases))
+	return schema.ListAliasesResponse{Aliases: aliases}, nil
+}
+
+// SetAlias creates or replaces a command alias. Aliases do not expand
+// recursively, so an expansion may not invoke another alias and an alias
+// invoked by another alias's expansion may not be defined.
+func (s *service) SetAlias(ctx context.Context, req schema.SetAliasRequest) (schema.SetAliasResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetAliasResponse{}, err
+	}
+	alias, err := normalizeAlias(req.Alias)
+	log := logx.WithUser(ctx, userID).With("alias", alias.Name)
+	if err != nil {
+		log.Warn("service alias rejected", "err", err)
+		return schema.SetAliasResponse{}, err
+	}
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	if err := checkAliasChain(state.aliases, alias); err != nil {
+		s.mu.Unlock()
+		log.Warn("service alias rejected", "err", err)
+		return schema.SetAliasResponse{}, err
+	}
+	if state.aliases == nil {
+		state.aliases = make(map[string]string)
+	}
+	state.aliases[alias.Name] = alias.Expansion
+	aliases := sortedAliases(state.aliases)
+	s.mu.Unlock()
+	s.persistUser(log, userID)
+	log.Info("service alias set", "aliases", len(aliases))
+	return schema.SetAliasResponse{Aliases: aliases}, nil
+}
+
+// RemoveAlias removes a command alias by name.
+func (s *service) RemoveAlias(ctx context.Context, req schema.RemoveAliasRequest) (schema.RemoveAliasResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.RemoveAliasResponse{}, err
+	}
+	name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(req.Name), "/"))
+	log := logx.WithUser(ctx, userID).With("alias", name)
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	expansion, ok := state.aliases[name]
+	if !ok {
+		s.mu.Unlock()
+		log.Warn("service alias remove failed", "err", schema.ErrAliasNotFound)
+		return schema.RemoveAliasResponse{}, schema.ErrAliasNotFound
+	}
+	delete(state.aliases, name)
+	aliases := sortedAliases(state.aliases)
+	s.mu.Unlock()
+	s.persistUser(log, userID)
+	log.Info("service alias removed", "aliases", len(aliases))
+	return schema.RemoveAliasResponse{Removed: schema.CommandAlias{Name: name, Expansion: expansion}, Aliases: aliases}, nil
+}
+
+// normalizeAlias validates an alias. Names are lowercase letters, digits, '-'
+// and '_' (a leading slash is dropped); expansions are a single line starting
+// with '/' or '!'.
+func normalizeAlias(alias schema.CommandAlias) (schema.CommandAlias, error) {
+	name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(alias.Name), "/"))
+	if name == "" || len(name) > maxAliasNameLen {
+		return schema.CommandAlias{}, fmt.Errorf("%w: name must be 1-%d characters", schema.ErrInvalidAlias, maxAliasNameLen)
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return schema.CommandAlias{}, fmt.Errorf("%w: name may only contain a-z, 0-9, '-' and '_'", schema.ErrInvalidAlias)
+		}
+	}
+	expansion := strings.TrimSpace(alias.Expansion)
+	if strings.ContainsAny(expansion, "\r\n") {
+		return schema.CommandAlias{}, fmt.Errorf("%w: expansion must be a single line", schema.ErrInvalidAlias)
+	}
+	if len(expansion) < 2 || (expansion[0] != '/' && expansion[0] != '!') {
+		return schema.CommandAlias{}, fmt.Errorf("%w: expansion must be a /command or !shell command", schema.ErrInvalidAlias)
+	}
+	return schema.CommandAlias{Name: name, Expansion: expansion}, nil
+}
+
+// checkAliasChain rejects aliases that would need more than one expansion
+// step, which also rules out cycles.
+func checkAliasChain(existing map[string]string, alias schema.CommandAlias) error {
+	target := aliasTarget(alias.Expansion)
+	if target == alias.Name {
+		return fmt.Errorf("%w: /%s expands to itself", schema.ErrInvalidAlias, alias.Name)
+	}
+	if _, ok := existing[target]; ok {
+		return fmt.Errorf("%w: expansion invokes alias /%s; aliases do not expand recursively", schema.ErrInvalidAlias, target)
+	}
+	for name, expansion := range existing {
+		if name != alias.Name && aliasTarget(expansion) == alias.Name {
+			return fmt.Errorf("%w: alias /%s invokes /%s; aliases do not expand recursively", schema.ErrInvalidAlias, name, alias.Name)
+		}
+	}
+	return nil
+}
+
+// aliasTarget returns the slash command name an expansion invokes, or "" for
+// shell commands.
+func aliasTarget(expansion string) string {
+	if !strings.HasPrefix(expansion, "/") {
+		return ""
+	}
+	fields := strings.Fields(expansion[1:])
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+func sortedAliases(aliases map[string]string) []schema.CommandAlias {
+	names := slices.Sorted(maps.Keys(aliases))
+	out := make([]schema.CommandAlias, 0, len(names))
+	for _, name := range names {
+		out = append(out, schema.CommandAlias{Name: name, Expansion: aliases[name]})
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// archiveTooLargeExitCode is the exit code archiveCommand uses when the
+// built archive exceeds the size limit.
+const archiveTooLargeExitCode = 3
+
+// WriteRepoArchive builds an archive inside the runner and streams it to
+// req.Writer. It only reads the repo, so it may run while the tab is busy.
+func (s *service) WriteRepoArchive(ctx context.Context, req schema.WriteRepoArchiveRequest) (schema.WriteRepoArchiveResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.WriteRepoArchiveResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	if req.Writer == nil {
+		return schema.WriteRepoArchiveResponse{}, errors.New("archive writer is required")
+	}
+	format := req.Format
+	switch format {
+	case "":
+		format = schema.ArchiveFormatTarGz
+	case schema.ArchiveFormatTar, schema.ArchiveFormatTarGz:
+	default:
+		return schema.WriteRepoArchiveResponse{}, fmt.Errorf("%w: %s", schema.ErrInvalidArchiveFormat, format)
+	}
+	maxBytes := req.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = schema.DefaultRepoArchiveMaxBytes
+	}
+	target, err := s.resolveRepoTarget(ctx, userID, req.TabID, req.Path)
+	if err != nil {
+		log.Warn("service repo archive resolve failed", "path", req.Path, "err", err)
+		return schema.WriteRepoArchiveResponse{}, err
+	}
+	log = log.With("path", target.rel, "format", format, "worktree", req.Worktree)
+	if target.runner == nil {
+		return schema.WriteRepoArchiveResponse{}, schema.ErrRunnerUnavailable
+	}
+	handle, err := target.runner.RunCommand(ctx, RunCommandRequest{
+		WorkingDir:  target.workingDir,
+		Command:     archiveCommand(target.rel, format, req.Worktree, maxBytes),
+		UseShell:    true,
+		SSHAuthSock: target.sshAuthSock,
+	})
+	if err != nil {
+		log.Warn("service repo archive failed", "err", err)
+		return schema.WriteRepoArchiveResponse{}, err
+	}
+	defer func() { _ = handle.Close() }()
+	// base64 wraps at 76 columns, so every stdout line decodes on its own
+	// and the archive never has to be held in memory.
+	stream := handle.Outputs()
+	var size int64
+	var stderr string
+	for {
+		output, err := stream.Next(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			log.Warn("service repo archive failed", "err", err)
+			return schema.WriteRepoArchiveResponse{}, err
+		}
+		if output.Stream == CommandStreamStderr {
+			if stderr == "" {
+				stderr = strings.TrimSpace(output.Text)
+			}
+			continue
+		}
+		chunk, err := base64.StdEncoding.DecodeString(output.Text)
+		if err != nil {
+			log.Warn("service repo archive decode failed", "err", err)
+			return schema.WriteRepoArchiveResponse{}, fmt.Errorf("archive %s: %w", target.rel, err)
+		}
+		size += int64(len(chunk))
+		if size > maxBytes {
+			return schema.WriteRepoArchiveResponse{}, fmt.Errorf("%w: archive of %s exceeds %d bytes", schema.ErrFileTooLarge, target.rel, maxBytes)
+		}
+		if _, err := req.Writer.Write(chunk); err != nil {
+			log.Warn("service repo archive write failed", "err", err)
+			return schema.WriteRepoArchiveResponse{}, err
+		}
+	}
+	result, err := handle.Wait(ctx)
+	if err != nil {
+		log.Warn("service repo archive failed", "err", err)
+		return schema.WriteRepoArchiveResponse{}, err
+	}
+	switch result.ExitCode {
+	case 0:
+	case archiveTooLargeExitCode:
+		log.Warn("service repo archive rejected", "reason", "too large", "max_bytes", maxBytes)
+		return schema.WriteRepoArchiveResponse{}, fmt.Errorf("%w: archive of %s exceeds %d bytes", schema.ErrFileTooLarge, target.rel, maxBytes)
+	default:
+		log.Warn("service repo archive failed", "exit_code", result.ExitCode, "stderr", stderr)
+		if stderr != "" {
+			return schema.WriteRepoArchiveResponse{}, fmt.Errorf("archive %s: %s", target.rel, stderr)
+		}
+		return schema.WriteRepoArchiveResponse{}, fmt.Errorf("archive %s: command exited with code %d", target.rel, result.ExitCode)
+	}
+	log.Info("service repo archive written", "bytes", size)
+	return schema.WriteRepoArchiveResponse{
+		Name:   archiveName(target.repo, target.rel, format),
+		Format: format,
+		Size:   size,
+	}, nil
+}
+
+// archiveCommand builds the archive into a temp file inside the runner,
+// checks its size and prints it base64 encoded. It exits with
+// archiveTooLargeExitCode when the archive exceeds maxBytes.
+func archiveCommand(rel string, format schema.ArchiveFormat, worktree bool, maxBytes int64) string {
+	arg := "."
+	if rel != "." {
+		arg = shellQuote("./" + rel)
+	}
+	var build string
+	switch {
+	case worktree && format == schema.ArchiveFormatTar:
+		build = "tar --exclude-vcs -cf - -- " + arg
+	case worktree:
+		build = "tar --exclude-vcs -czf - -- " + arg
+	default:
+		build = "git archive --format=" + string(format) + " HEAD -- " + arg
+	}
+	return `f=$(mktemp) || exit 1; trap 'rm -f "$f"' EXIT; ` +
+		build + ` >"$f" || exit 1; ` +
+		`[ "$(wc -c <"$f")" -le ` + strconv.FormatInt(maxBytes, 10) + ` ] || exit ` + strconv.Itoa(archiveTooLargeExitCode) + `; ` +
+		`base64 -- "$f"`
+}
+
+// archiveName suggests a download file name such as demo-src-app.tar.gz.
+func archiveName(repo schema.RepoName, rel string, format schema.ArchiveFormat) string {
+	name := string(repo)
+	if name == "" {
+		name = "repo"
+	}
+	if rel != "." {
+		name += "-" + strings.ReplaceAll(rel, "/", "-")
+	}
+	return name + "." + string(format)
+}
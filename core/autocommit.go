@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// autoCommitKey marks contexts of auto-commits, so runs started from one
+// never trigger another.
+type autoCommitKey struct{}
+
+// SetAutoCommitter sets what commits after successful runs in tabs with
+// /autocommit on. Without one, the setting has no effect.
+func (s *service) SetAutoCommitter(committer AutoCommitter) {
+	s.mu.Lock()
+	s.autoCommitter = committer
+	s.mu.Unlock()
+}
+
+// SetTabAutoCommit turns automatic commits after successful runs on or off
+// for a tab. The setting is persisted with the tab.
+func (s *service) SetTabAutoCommit(ctx context.Context, req schema.SetTabAutoCommitRequest) (schema.SetTabAutoCommitResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetTabAutoCommitResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service autocommit update failed", "err", err)
+		return schema.SetTabAutoCommitResponse{}, err
+	}
+	ref.tab.autoCommit = req.Enabled
+	active := activeTabFromContext(ctx, state)
+	event := s.tabEventLocked(ref, schema.TabEventUpdated, active)
+	snapshot := s.snapshotRef(ref, req.TabID == active)
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, ref.owner)
+	log.Info("service autocommit updated", "enabled", req.Enabled)
+	return schema.SetTabAutoCommitResponse{Tab: snapshot}, nil
+}
+
+// autoCommitAfterRun hands a successful run of tabID to the auto-committer
+// when the tab has /autocommit on. It is called before the repo run lock is
+// released and the tab turns idle, so the commit cannot race another prompt
+// in the repo.
+func (s *service) autoCommitAfterRun(ctx context.Context, userID schema.UserID, tabID schema.TabID) {
+	if ctx.Value(autoCommitKey{}) != nil {
+		return
+	}
+	s.mu.Lock()
+	committer := s.autoCommitter
+	var runID schema.RunID
+	enabled := false
+	if state := s.userTabs[userID]; state != nil {
+		if tab := state.tabs[tabID]; tab != nil {
+			enabled = tab.autoCommit
+			runID = tab.RunID
+		}
+	}
+	s.mu.Unlock()
+	if !enabled || committer == nil {
+		return
+	}
+	logx.WithUserTab(ctx, userID, tabID).Debug("service autocommit start", "run_id", runID)
+	committer.AutoCommit(context.WithValue(ctx, autoCommitKey{}, true), AutoCommitRequest{UserID: userID, TabID: tabID, RunID: runID})
+}
@@ -0,0 +1,410 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// userBatch holds a user's batch repo selection and latest batch.
+type userBatch struct {
+	repos []schema.RepoName
+	batch *schema.BatchStatus
+}
+
+// UpdateBatchRepos adds repos to or removes repos from the user's batch
+// selection. Added repos must resolve.
+func (s *service) UpdateBatchRepos(ctx context.Context, req schema.UpdateBatchReposRequest) (schema.UpdateBatchReposResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.UpdateBatchReposResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	var add []schema.RepoName
+	for _, name := range req.Add {
+		name = schema.RepoName(strings.TrimSpace(string(name)))
+		if name == "" {
+			continue
+		}
+		resp, err := s.repos.ResolveRepo(ctx, ResolveRepoRequest{UserID: userID, Name: name})
+		if err != nil {
+			log.Warn("service batch repos update failed", "repo", name, "err", err)
+			return schema.UpdateBatchReposResponse{}, err
+		}
+		add = append(add, resp.Repo.Name)
+	}
+	s.mu.Lock()
+	entry := s.batchLocked(userID)
+	for _, name := range req.Remove {
+		index := slices.Index(entry.repos, schema.RepoName(strings.TrimSpace(string(name))))
+		if index < 0 {
+			s.mu.Unlock()
+			err := fmt.Errorf("%w: repo %q is not in the batch", schema.ErrInvalidRequest, name)
+			log.Warn("service batch repos update failed", "err", err)
+			return schema.UpdateBatchReposResponse{}, err
+		}
+		entry.repos = slices.Delete(entry.repos, index, index+1)
+	}
+	for _, name := range add {
+		if !slices.Contains(entry.repos, name) {
+			entry.repos = append(entry.repos, name)
+		}
+	}
+	repos := slices.Clone(entry.repos)
+	s.mu.Unlock()
+	s.persistBatch(log, userID)
+	log.Info("service batch repos updated", "repos", len(repos))
+	return schema.UpdateBatchReposResponse{Repos: repos}, nil
+}
+
+// GetBatch returns the user's batch selection and latest batch.
+func (s *service) GetBatch(ctx context.Context, req schema.GetBatchRequest) (schema.GetBatchResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.GetBatchResponse{}, err
+	}
+	resp := schema.GetBatchResponse{Parallelism: s.cfg.BatchParallelism}
+	s.mu.Lock()
+	if entry := s.batches[userID]; entry != nil {
+		resp.Repos = slices.Clone(entry.repos)
+		if entry.batch != nil {
+			batch := cloneBatch(*entry.batch)
+			resp.Batch = &batch
+		}
+	}
+	s.mu.Unlock()
+	return resp, nil
+}
+
+// StartBatch sends the prompt to every selected repo, running at most
+// BatchParallelism of them at once. Each repo runs in an idle tab of that
+// repo, or in a new tab when there is none.
+func (s *service) StartBatch(ctx context.Context, req schema.StartBatchRequest) (schema.StartBatchResponse, error) {
+	if s.runners == nil {
+		return schema.StartBatchResponse{}, schema.ErrRunnerUnavailable
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return schema.StartBatchResponse{}, schema.ErrEmptyPrompt
+	}
+	if err := s.checkPromptSize(req.Prompt); err != nil {
+		return schema.StartBatchResponse{}, err
+	}
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.StartBatchResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	s.mu.Lock()
+	entry := s.batchLocked(userID)
+	if len(entry.repos) == 0 {
+		s.mu.Unlock()
+		err := fmt.Errorf("%w: no repos in the batch", schema.ErrInvalidRequest)
+		log.Warn("service batch start rejected", "err", err)
+		return schema.StartBatchResponse{}, err
+	}
+	if entry.batch != nil && entry.batch.Active() {
+		s.mu.Unlock()
+		log.Warn("service batch start rejected", "err", schema.ErrBatchRunning)
+		return schema.StartBatchResponse{}, schema.ErrBatchRunning
+	}
+	batch := &schema.BatchStatus{Prompt: req.Prompt, StartedAt: s.now()}
+	for _, name := range entry.repos {
+		batch.Repos = append(batch.Repos, schema.BatchRepoStatus{Repo: name, State: schema.BatchRepoPending})
+	}
+	entry.batch = batch
+	snapshot := cloneBatch(*batch)
+	s.mu.Unlock()
+	s.persistBatch(log, userID)
+	log.Info("service batch started", "repos", len(snapshot.Repos), "parallelism", s.cfg.BatchParallelism, "prompt_len", len(req.Prompt))
+	go s.advanceBatch(batchContext(ctx), userID)
+	return schema.StartBatchResponse{Batch: snapshot}, nil
+}
+
+// StopBatch cancels the pending repos of the running batch. Repos already
+// running are left to finish.
+func (s *service) StopBatch(ctx context.Context, req schema.StopBatchRequest) (schema.StopBatchResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.StopBatchResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	s.mu.Lock()
+	entry := s.batches[userID]
+	if entry == nil || entry.batch == nil || !entry.batch.Active() {
+		s.mu.Unlock()
+		err := fmt.Errorf("%w: no batch is running", schema.ErrInvalidRequest)
+		log.Warn("service batch stop rejected", "err", err)
+		return schema.StopBatchResponse{}, err
+	}
+	batch := entry.batch
+	batch.Stopped = true
+	canceled := 0
+	now := s.now()
+	for i := range batch.Repos {
+		if batch.Repos[i].State == schema.BatchRepoPending {
+			batch.Repos[i].State = schema.BatchRepoCanceled
+			batch.Repos[i].FinishedAt = now
+			canceled++
+		}
+	}
+	s.mu.Unlock()
+	s.persistBatch(log, userID)
+	log.Info("service batch stopped", "canceled", canceled)
+	// Finishes the batch when nothing was running.
+	s.advanceBatch(batchContext(ctx), userID)
+	s.mu.Lock()
+	snapshot := cloneBatch(*batch)
+	s.mu.Unlock()
+	return schema.StopBatchResponse{Batch: snapshot}, nil
+}
+
+// advanceBatch starts pending repos of the user's batch until the
+// parallelism limit is reached, and finishes the batch once no repo is
+// pending or running.
+func (s *service) advanceBatch(ctx context.Context, userID schema.UserID) {
+	log := logx.WithUser(ctx, userID)
+	for {
+		s.mu.Lock()
+		entry := s.batches[userID]
+		if entry == nil || entry.batch == nil || !entry.batch.Active() {
+			s.mu.Unlock()
+			return
+		}
+		batch := entry.batch
+		running := 0
+		next := -1
+		for i, repo := range batch.Repos {
+			switch repo.State {
+			case schema.BatchRepoRunning:
+				running++
+			case schema.BatchRepoPending:
+				if next < 0 {
+					next = i
+				}
+			}
+		}
+		if next < 0 && running == 0 {
+			batch.FinishedAt = s.now()
+			lines := batchSummaryLines(*batch)
+			// The summary goes to the system buffer, which needs the user's
+			// state loaded after a restart.
+			s.getOrCreateUserStateLocked(userID)
+			s.mu.Unlock()
+			s.persistBatch(log, userID)
+			s.appendSystemLines(log, userID, lines)
+			log.Info("service batch finished", "repos", len(lines)-1)
+			return
+		}
+		if next < 0 || running >= s.cfg.BatchParallelism {
+			s.mu.Unlock()
+			return
+		}
+		repo := &batch.Repos[next]
+		repo.State = schema.BatchRepoRunning
+		repo.StartedAt = s.now()
+		repo.TabID = s.idleRepoTabLocked(userID, repo.Repo)
+		name, tabID, prompt := repo.Repo, repo.TabID, batch.Prompt
+		s.mu.Unlock()
+		s.persistBatch(log, userID)
+		if err := s.startBatchRepo(ctx, userID, name, tabID, prompt); err != nil {
+			log.Warn("service batch repo failed to start", "repo", name, "err", err)
+			s.finishBatchRepo(log, userID, func(repo schema.BatchRepoStatus) bool { return repo.Repo == name }, err.Error())
+		}
+	}
+}
+
+// startBatchRepo sends prompt to tabID, creating a tab for the repo first
+// when tabID is empty.
+func (s *service) startBatchRepo(ctx context.Context, userID schema.UserID, name schema.RepoName, tabID schema.TabID, prompt string) error {
+	if tabID == "" {
+		created, err := s.CreateTab(ctx, schema.CreateTabRequest{UserID: userID, RepoName: name})
+		if err != nil {
+			return err
+		}
+		tabID = created.Tab.ID
+		// Recorded before the prompt starts, so a run that finishes right
+		// away still finds its repo.
+		s.mu.Lock()
+		if entry := s.batches[userID]; entry != nil && entry.batch != nil {
+			for i := range entry.batch.Repos {
+				if entry.batch.Repos[i].Repo == name && entry.batch.Repos[i].State == schema.BatchRepoRunning {
+					entry.batch.Repos[i].TabID = tabID
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	_, err := s.SendPrompt(ctx, schema.SendPromptRequest{UserID: userID, TabID: tabID, Prompt: prompt})
+	return err
+}
+
+// batchRunFinished records the end of a run in tabID for the user's batch,
+// if the batch is waiting on it, and starts the next pending repo. failure
+// is empty for a successful run.
+func (s *service) batchRunFinished(ctx context.Context, userID schema.UserID, tabID schema.TabID, failure string) {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if !s.finishBatchRepo(log, userID, func(repo schema.BatchRepoStatus) bool { return repo.TabID == tabID }, failure) {
+		return
+	}
+	s.advanceBatch(batchContext(ctx), userID)
+}
+
+// finishBatchRepo marks the first running repo matching match as
+// succeeded, or failed when failure is set, and reports whether one matched.
+func (s *service) finishBatchRepo(log pslog.Logger, userID schema.UserID, match func(schema.BatchRepoStatus) bool, failure string) bool {
+	s.mu.Lock()
+	entry := s.batches[userID]
+	if entry == nil || entry.batch == nil {
+		s.mu.Unlock()
+		return false
+	}
+	index := slices.IndexFunc(entry.batch.Repos, func(repo schema.BatchRepoStatus) bool {
+		return repo.State == schema.BatchRepoRunning && match(repo)
+	})
+	if index < 0 {
+		s.mu.Unlock()
+		return false
+	}
+	repo := &entry.batch.Repos[index]
+	repo.FinishedAt = s.now()
+	repo.State = schema.BatchRepoSucceeded
+	if failure != "" {
+		repo.State = schema.BatchRepoFailed
+		repo.Error = failure
+	}
+	name, state := repo.Repo, repo.State
+	s.mu.Unlock()
+	s.persistBatch(log, userID)
+	log.Info("service batch repo finished", "repo", name, "state", state)
+	return true
+}
+
+// idleRepoTabLocked returns an idle, persistent tab of the user's open on
+// the repo, or "" when there is none.
+func (s *service) idleRepoTabLocked(userID schema.UserID, name schema.RepoName) schema.TabID {
+	state := s.getOrCreateUserStateLocked(userID)
+	for _, id := range state.order {
+		tab := state.tabs[id]
+		if tab != nil && tab.Repo.Name == name && !tab.ephemeral && !tab.archived && !tab.Status.Busy() {
+			return id
+		}
+	}
+	return ""
+}
+
+func (s *service) batchLocked(userID schema.UserID) *userBatch {
+	entry := s.batches[userID]
+	if entry == nil {
+		entry = &userBatch{}
+		s.batches[userID] = entry
+	}
+	return entry
+}
+
+func (s *service) persistBatch(log pslog.Logger, userID schema.UserID) {
+	if s.store == nil {
+		return
+	}
+	s.mu.Lock()
+	snapshot := persist.BatchSnapshot{User: userID}
+	if entry := s.batches[userID]; entry != nil {
+		snapshot.Repos = slices.Clone(entry.repos)
+		if entry.batch != nil {
+			batch := cloneBatch(*entry.batch)
+			snapshot.Batch = &batch
+		}
+	}
+	s.mu.Unlock()
+	if err := s.store.SaveBatch(snapshot); err != nil && log != nil {
+		log.Warn("service batch persist failed", "err", err)
+	}
+}
+
+// loadBatches restores saved batches. Repos that were running when the
+// server stopped are queued again; the returned users have a batch to
+// resume.
+func (s *service) loadBatches() []schema.UserID {
+	if s.store == nil {
+		return nil
+	}
+	snapshots, err := s.store.LoadBatches()
+	if err != nil {
+		s.logger.Warn("service batch load failed", "err", err)
+	}
+	var resume []schema.UserID
+	for _, snapshot := range snapshots {
+		entry := &userBatch{repos: snapshot.Repos, batch: snapshot.Batch}
+		s.batches[snapshot.User] = entry
+		if entry.batch == nil || !entry.batch.Active() {
+			continue
+		}
+		for i := range entry.batch.Repos {
+			if repo := &entry.batch.Repos[i]; repo.State == schema.BatchRepoRunning {
+				repo.State = schema.BatchRepoPending
+				repo.StartedAt = time.Time{}
+				repo.TabID = ""
+			}
+		}
+		resume = append(resume, snapshot.User)
+	}
+	return resume
+}
+
+// batchSummaryLines describes a finished batch for the system buffer.
+func batchSummaryLines(batch schema.BatchStatus) []schema.BufferLine {
+	counts := make(map[schema.BatchRepoState]int)
+	for _, repo := range batch.Repos {
+		counts[repo.State]++
+	}
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSystem, fmt.Sprintf("batch finished: %d succeeded, %d failed, %d canceled",
+		counts[schema.BatchRepoSucceeded], counts[schema.BatchRepoFailed], counts[schema.BatchRepoCanceled]))}
+	for _, repo := range batch.Repos {
+		text := fmt.Sprintf("  %s: %s", repo.Repo, repo.State)
+		if !repo.StartedAt.IsZero() && !repo.FinishedAt.IsZero() {
+			text += " in " + formatWorkedDuration(repo.FinishedAt.Sub(repo.StartedAt))
+		}
+		kind := schema.LineKindSystem
+		if repo.State == schema.BatchRepoFailed {
+			kind = schema.LineKindError
+			if repo.Error != "" {
+				text += ": " + repo.Error
+			}
+		}
+		lines = append(lines, schema.Line(kind, text))
+	}
+	return lines
+}
+
+func cloneBatch(batch schema.BatchStatus) schema.BatchStatus {
+	batch.Repos = slices.Clone(batch.Repos)
+	return batch
+}
+
+// batchContext detaches ctx from the request that started or stopped a
+// batch; the runs outlive it and must not change the session's active tab.
+func batchContext(ctx context.Context) context.Context {
+	return sessionprefs.WithoutContext(context.WithoutCancel(ctx))
+}
+
+// runFailure describes why a run did not succeed, or returns "" when it
+// completed its turn and exited cleanly.
+func runFailure(turnCompleted bool, result RunResult, err error) string {
+	switch {
+	case err != nil:
+		return err.Error()
+	case result.ExitCode != 0:
+		return fmt.Sprintf("run exited with code %d", result.ExitCode)
+	case !turnCompleted:
+		return "turn did not complete"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/internal/timefmt"
+	"pkt.systems/centaurx/schema"
+)
+
+// tokenDayLayout formats the UTC day a tokenDay counts.
+const tokenDayLayout = "2006-01-02"
+
+// tokenDay counts the codex tokens a user used on one UTC day. Usage from
+// an earlier day reads as zero, so the count resets at midnight UTC without
+// a timer.
+type tokenDay struct {
+	day    string
+	tokens int64
+}
+
+func (d tokenDay) usedOn(now time.Time) int64 {
+	if d.day != now.UTC().Format(tokenDayLayout) {
+		return 0
+	}
+	return d.tokens
+}
+
+func (d *tokenDay) add(now time.Time, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	day := now.UTC().Format(tokenDayLayout)
+	if d.day != day {
+		d.day, d.tokens = day, 0
+	}
+	d.tokens += tokens
+}
+
+func (d tokenDay) export() *persist.TokenUsage {
+	if d.day == "" {
+		return nil
+	}
+	return &persist.TokenUsage{Day: d.day, Tokens: d.tokens}
+}
+
+func importTokenDay(usage *persist.TokenUsage) tokenDay {
+	if usage == nil {
+		return tokenDay{}
+	}
+	return tokenDay{day: usage.Day, tokens: usage.Tokens}
+}
+
+// dailyTokenBudget returns the budget of userID: their own when the budget
+// source has one, else the configured default. 0 means no budget.
+func (s *service) dailyTokenBudget(userID schema.UserID) int64 {
+	if s.tokenBudgets != nil {
+		if budget, ok := s.tokenBudgets.DailyTokenBudget(userID); ok {
+			return budget
+		}
+	}
+	return s.cfg.DailyTokenBudget
+}
+
+// tokenBudgetStatus returns the budget of userID and the tokens used today.
+// Admins and users without a budget get a zero budget.
+func (s *service) tokenBudgetStatus(ctx context.Context, userID schema.UserID) (budget, used int64) {
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.Capabilities.Admin {
+		return 0, 0
+	}
+	budget = s.dailyTokenBudget(userID)
+	if budget <= 0 {
+		return 0, 0
+	}
+	s.mu.Lock()
+	used = s.getOrCreateUserStateLocked(userID).tokens.usedOn(s.now())
+	s.mu.Unlock()
+	return budget, used
+}
+
+// checkTokenBudget returns schema.ErrTokenBudgetExceeded once userID has
+// used up today's token budget. Below that, it returns a warning for the
+// tab buffer when the budget is used past budgets.warn_percent.
+func (s *service) checkTokenBudget(ctx context.Context, userID schema.UserID) (string, error) {
+	budget, used := s.tokenBudgetStatus(ctx, userID)
+	if budget <= 0 {
+		return "", nil
+	}
+	now := s.now()
+	if used >= budget {
+		return "", fmt.Errorf("%w: %s", schema.ErrTokenBudgetExceeded, formatTokenBudget(used, budget, now))
+	}
+	if warn := int64(s.cfg.TokenBudgetWarnPercent); warn > 0 && used*100 >= budget*warn {
+		return "⚠ daily token budget: " + formatTokenBudget(used, budget, now), nil
+	}
+	return "", nil
+}
+
+// CheckTokenBudget implements TokenMeter.
+func (s *service) CheckTokenBudget(ctx context.Context, userID schema.UserID) error {
+	userID, err := normalizeUserID(userID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.checkTokenBudget(ctx, userID); err != nil {
+		logx.WithUser(ctx, userID).Warn("service token budget exceeded", "err", err)
+		return err
+	}
+	return nil
+}
+
+// RecordTokens implements TokenMeter.
+func (s *service) RecordTokens(ctx context.Context, userID schema.UserID, tokens int64) {
+	userID, err := normalizeUserID(userID)
+	if err != nil || tokens <= 0 {
+		return
+	}
+	s.mu.Lock()
+	s.getOrCreateUserStateLocked(userID).tokens.add(s.now(), tokens)
+	s.mu.Unlock()
+	s.persistUser(logx.WithUser(ctx, userID), userID)
+}
+
+// formatTokenBudget describes budget use as "850,000 of 1,000,000 tokens used
+// today (85%) — resets at 00:00 UTC, in 3h12m".
+func formatTokenBudget(used, budget int64, now time.Time) string {
+	now = now.UTC()
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return fmt.Sprintf("%s of %s tokens used today (%d%%) — resets at 00:00 UTC, in %s",
+		formatThousands(int(used)), formatThousands(int(budget)), used*100/budget, timefmt.Duration(reset.Sub(now)))
+}
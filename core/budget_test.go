@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+type fakeTokenBudgets map[schema.UserID]int64
+
+func (f fakeTokenBudgets) DailyTokenBudget(userID schema.UserID) (int64, bool) {
+	budget, ok := f[userID]
+	return budget, ok
+}
+
+func TestTokenBudgetWarnsThenRefusesPrompts(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	// Every turn uses 400 tokens.
+	runner := eventRunner{events: []schema.ExecEvent{{Type: schema.EventTurnCompleted, Usage: &schema.TurnUsage{InputTokens: 300, OutputTokens: 100}}}}
+	cfg := schema.ServiceConfig{
+		RepoRoot:               repoRoot,
+		StateDir:               stateDir,
+		DailyTokenBudget:       1000,
+		TokenBudgetWarnPercent: 75,
+	}
+	deps := ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		TokenBudgets:   fakeTokenBudgets{"bob": 0},
+	}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	clock := time.Date(2025, time.January, 2, 21, 30, 0, 0, time.UTC)
+	svc.(*service).now = func() time.Time { return clock }
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	turn := func(ctx context.Context) error {
+		if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err != nil {
+			return err
+		}
+		waitForTabIdle(t, svc, user, tabID)
+		return nil
+	}
+	budgetLines := func() []string {
+		buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 500})
+		if err != nil {
+			t.Fatalf("get buffer: %v", err)
+		}
+		var out []string
+		for _, line := range buf.Buffer.Lines {
+			if strings.Contains(line, "tokens used today") {
+				out = append(out, line)
+			}
+		}
+		return out
+	}
+
+	for i := range 2 {
+		if err := turn(ctx); err != nil {
+			t.Fatalf("turn %d: %v", i, err)
+		}
+	}
+	if lines := budgetLines(); len(lines) != 0 {
+		t.Fatalf("expected no warning below the soft threshold, got %q", lines)
+	}
+	// 800 of 1000 tokens used: past the soft threshold, the run goes ahead.
+	if err := turn(ctx); err != nil {
+		t.Fatalf("turn past the soft threshold: %v", err)
+	}
+	want := "⚠ daily token budget: 800 of 1,000 tokens used today (80%) — resets at 00:00 UTC, in 2h30m"
+	if lines := budgetLines(); len(lines) != 1 || lines[0] != want {
+		t.Fatalf("expected a soft warning, got %q", lines)
+	}
+
+	err = turn(ctx)
+	if !errors.Is(err, schema.ErrTokenBudgetExceeded) || schema.ErrorCode(err) != schema.CodeTokenBudgetExceeded {
+		t.Fatalf("expected the budget to refuse the prompt, got %v", err)
+	}
+	if lines := budgetLines(); len(lines) != 2 || !strings.Contains(lines[1], "1,200 of 1,000 tokens used today") {
+		t.Fatalf("expected usage and reset time in the buffer, got %q", lines)
+	}
+
+	admin := sessionprefs.New()
+	admin.Capabilities.Admin = true
+	if err := turn(sessionprefs.WithContext(ctx, admin)); err != nil {
+		t.Fatalf("expected admins to bypass the budget, got %v", err)
+	}
+
+	meter := svc.(TokenMeter)
+	if err := meter.CheckTokenBudget(ctx, user); !errors.Is(err, schema.ErrTokenBudgetExceeded) {
+		t.Fatalf("expected commit message runs to be refused too, got %v", err)
+	}
+	meter.RecordTokens(ctx, "bob", 5000)
+	if err := meter.CheckTokenBudget(ctx, "bob"); err != nil {
+		t.Fatalf("expected a user without a budget to pass, got %v", err)
+	}
+
+	// The count survives a restart and resets at midnight UTC.
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	reloaded.(*service).now = func() time.Time { return clock }
+	if err := reloaded.(TokenMeter).CheckTokenBudget(ctx, user); !errors.Is(err, schema.ErrTokenBudgetExceeded) {
+		t.Fatalf("expected the day's usage to survive a restart, got %v", err)
+	}
+	clock = clock.Add(3 * time.Hour)
+	if err := turn(ctx); err != nil {
+		t.Fatalf("expected the budget to reset the next day, got %v", err)
+	}
+}
@@ -1,41 +1,157 @@
 package core
 
-import "pkt.systems/centaurx/schema"
+import (
+	"time"
 
-// bufferView is a snapshot of a buffer's visible state.
+	"pkt.systems/centaurx/schema"
+)
+
+// bufferView is a snapshot of a buffer's visible state. Lines holds the
+// marker-prefixed rendering of Entries, which include the transient lines
+// among the visible ones; TotalLines and ScrollOffset count stored lines
+// only.
 type bufferView struct {
 	Lines        []string
+	Entries      []schema.BufferLine
 	TotalLines   int
 	ScrollOffset int
 	AtBottom     bool
+	// Sources are the codex events rendered into the visible lines, and
+	// First counts the lines appended before the first stored one.
+	Sources []*eventSource
+	First   int64
+	// stored are the visible stored lines and transient the transient
+	// lines among them, for rerender.
+	stored    []schema.BufferLine
+	transient []transientLine
 }
 
-const defaultMaxLines = schema.DefaultBufferMaxLines
+const (
+	defaultMaxLines = schema.DefaultBufferMaxLines
+	// maxTransientLines bounds the transient lines a buffer keeps.
+	maxTransientLines = 200
+)
+
+// transientLine is a line shown live but never persisted. It sits before
+// the stored line with sequence number at.
+type transientLine struct {
+	at   int64
+	line schema.BufferLine
+}
 
 // buffer stores scrollback lines and scroll state.
 // ScrollOffset is the number of lines from the bottom; 0 means at bottom.
 type buffer struct {
-	lines        []string
+	lines        []schema.BufferLine
 	scrollOffset int
 	maxLines     int
+	// seq counts every line appended, including lines trimmed since, so
+	// the store can persist just the new ones.
+	seq int64
+	// guestOffsets are the scroll offsets of guests viewing a shared tab.
+	// They are not persisted.
+	guestOffsets map[schema.UserID]int
+	// sources are the codex events the lines were rendered from, oldest
+	// first. They are not persisted, so lines loaded from state read the
+	// same in every rendering.
+	sources []*eventSource
+	// transient are status lines shown among the stored ones until a
+	// restart. They are not persisted, do not count against maxLines and
+	// are not part of the scroll math.
+	transient []transientLine
+}
+
+// eventSource records the codex event a run of lines was rendered from, so
+// the lines can be rendered differently at read time.
+type eventSource struct {
+	// start counts the lines appended before the event's; count may be
+	// zero for events the stored rendering skips.
+	start int64
+	count int
+	event schema.ExecEvent
+	// final marks the last agent message of a turn.
+	final    bool
+	at       time.Time
+	rendered map[schema.Rendering][]schema.BufferLine
+}
+
+// render returns the event as renderer formats it, caching the result
+// under name.
+func (src *eventSource) render(name schema.Rendering, renderer Renderer) []schema.BufferLine {
+	if lines, ok := src.rendered[name]; ok {
+		return lines
+	}
+	var lines []schema.BufferLine
+	raw, err := formatEvent(renderer, src.event)
+	if err != nil {
+		lines = unrenderedEventLines(src.event, err)
+	} else {
+		if src.final {
+			raw = markFinalAgentLines(raw)
+		}
+		lines = schema.ParseBufferLines(raw)
+	}
+	for i := range lines {
+		lines[i].Timestamp = src.at
+	}
+	if src.rendered == nil {
+		src.rendered = make(map[schema.Rendering][]schema.BufferLine)
+	}
+	src.rendered[name] = lines
+	return lines
 }
 
 // persistedBuffer captures buffer lines and scroll offset for persistence.
 type persistedBuffer struct {
-	Lines        []string
+	Lines        []schema.BufferLine
 	ScrollOffset int
+	Seq          int64
+}
+
+// AppendRaw classifies marker-prefixed strings and appends them.
+func (b *buffer) AppendRaw(lines ...string) {
+	b.Append(schema.ParseBufferLines(lines)...)
+}
+
+// AppendEvent appends the lines rendered from event and records it as their
+// source.
+func (b *buffer) AppendEvent(event schema.ExecEvent, final bool, lines ...schema.BufferLine) {
+	b.sources = append(b.sources, &eventSource{start: b.seq, count: len(lines), event: event, final: final, at: time.Now().UTC()})
+	b.Append(lines...)
+}
+
+// lastAppended returns the timestamp of the newest line, or the zero time
+// for an empty buffer.
+func (b *buffer) lastAppended() time.Time {
+	if len(b.lines) == 0 {
+		return time.Time{}
+	}
+	return b.lines[len(b.lines)-1].Timestamp
 }
 
 // Append adds lines to the buffer. If the buffer is scrolled up, the scroll offset
 // is increased to keep the view anchored.
-func (b *buffer) Append(lines ...string) {
+func (b *buffer) Append(lines ...schema.BufferLine) {
 	if len(lines) == 0 {
 		return
 	}
+	start := len(b.lines)
 	b.lines = append(b.lines, lines...)
+	b.seq += int64(len(lines))
+	now := time.Now().UTC()
+	for i := start; i < len(b.lines); i++ {
+		if b.lines[i].Timestamp.IsZero() {
+			b.lines[i].Timestamp = now
+		}
+	}
 	if b.scrollOffset > 0 {
 		b.scrollOffset += len(lines)
 	}
+	for guest, offset := range b.guestOffsets {
+		if offset > 0 {
+			b.guestOffsets[guest] = offset + len(lines)
+		}
+	}
 	maxLines := b.maxLines
 	if maxLines <= 0 {
 		maxLines = defaultMaxLines
@@ -49,6 +165,40 @@ func (b *buffer) Append(lines ...string) {
 		if b.scrollOffset < 0 {
 			b.scrollOffset = 0
 		}
+		for guest, offset := range b.guestOffsets {
+			b.guestOffsets[guest] = min(offset, len(b.lines))
+		}
+		first := b.seq - int64(len(b.lines))
+		drop := 0
+		for drop < len(b.sources) && b.sources[drop].start < first && b.sources[drop].start+int64(b.sources[drop].count) <= first {
+			drop++
+		}
+		if drop > 0 {
+			b.sources = append([]*eventSource(nil), b.sources[drop:]...)
+		}
+		drop = 0
+		for drop < len(b.transient) && b.transient[drop].at <= first {
+			drop++
+		}
+		if drop > 0 {
+			b.transient = append([]transientLine(nil), b.transient[drop:]...)
+		}
+	}
+}
+
+// AppendTransient adds lines that are shown after the current lines but are
+// not stored: they are left out of Export, TotalLines and the scroll offset,
+// and go away with the stored lines before them or after a restart.
+func (b *buffer) AppendTransient(lines ...schema.BufferLine) {
+	now := time.Now().UTC()
+	for _, line := range lines {
+		if line.Timestamp.IsZero() {
+			line.Timestamp = now
+		}
+		b.transient = append(b.transient, transientLine{at: b.seq, line: line})
+	}
+	if extra := len(b.transient) - maxTransientLines; extra > 0 {
+		b.transient = append([]transientLine(nil), b.transient[extra:]...)
 	}
 }
 
@@ -63,19 +213,77 @@ func (b *buffer) Scroll(delta, limit int) {
 	b.scrollOffset = clampScroll(b.scrollOffset+delta, len(b.lines), limit)
 }
 
+// ScrollGuest is Scroll for a guest's own view of a shared tab.
+func (b *buffer) ScrollGuest(guest schema.UserID, delta, limit int) {
+	if b.guestOffsets == nil {
+		b.guestOffsets = make(map[schema.UserID]int)
+	}
+	b.guestOffsets[guest] = clampScroll(b.guestOffsets[guest]+delta, len(b.lines), limit)
+}
+
+// ScrollToLine scrolls so the line numbered line, counting every line ever
+// appended from 1, sits mid-view for the viewport limit. A line trimmed
+// since scrolls to the top.
+func (b *buffer) ScrollToLine(line int64, limit int) {
+	b.scrollOffset = b.offsetForLine(line, limit)
+}
+
+// ScrollGuestToLine is ScrollToLine for a guest's own view of a shared tab.
+func (b *buffer) ScrollGuestToLine(guest schema.UserID, line int64, limit int) {
+	if b.guestOffsets == nil {
+		b.guestOffsets = make(map[schema.UserID]int)
+	}
+	b.guestOffsets[guest] = b.offsetForLine(line, limit)
+}
+
+func (b *buffer) offsetForLine(line int64, limit int) int {
+	total := len(b.lines)
+	index := int(max(line-1-(b.seq-int64(total)), 0))
+	return clampScroll(total-index-1-limit/2, total, limit)
+}
+
+// recentLines returns up to n stored lines, newest first, with their line
+// numbers as used by ScrollToLine.
+func (b *buffer) recentLines(n int) ([]schema.BufferLine, []int64) {
+	total := len(b.lines)
+	n = min(n, total)
+	lines := make([]schema.BufferLine, 0, n)
+	numbers := make([]int64, 0, n)
+	for i := total - 1; i >= total-n; i-- {
+		lines = append(lines, b.lines[i])
+		numbers = append(numbers, b.seq-int64(total-i)+1)
+	}
+	return lines, numbers
+}
+
 // Snapshot returns a view of the buffer for the given viewport limit.
 func (b *buffer) Snapshot(limit int) bufferView {
+	return b.snapshotAt(&b.scrollOffset, limit)
+}
+
+// SnapshotGuest is Snapshot for a guest's own view of a shared tab.
+func (b *buffer) SnapshotGuest(guest schema.UserID, limit int) bufferView {
+	offset := b.guestOffsets[guest]
+	view := b.snapshotAt(&offset, limit)
+	if b.guestOffsets != nil {
+		b.guestOffsets[guest] = offset
+	}
+	return view
+}
+
+// snapshotAt clamps *offset to the viewport and returns the view at it.
+func (b *buffer) snapshotAt(offset *int, limit int) bufferView {
 	total := len(b.lines)
 	if limit <= 0 || limit > total {
 		limit = total
 	}
 
 	maxScroll := maxScroll(total, limit)
-	if b.scrollOffset > maxScroll {
-		b.scrollOffset = maxScroll
+	if *offset > maxScroll {
+		*offset = maxScroll
 	}
 
-	end := total - b.scrollOffset
+	end := total - *offset
 	if end < 0 {
 		end = 0
 	}
@@ -84,15 +292,87 @@ func (b *buffer) Snapshot(limit int) bufferView {
 		start = 0
 	}
 
-	lines := make([]string, end-start)
-	copy(lines, b.lines[start:end])
+	stored := make([]schema.BufferLine, end-start)
+	copy(stored, b.lines[start:end])
 
-	return bufferView{
-		Lines:        lines,
+	first := b.seq - int64(total) + int64(start)
+	last := first + int64(len(stored))
+	// Transient lines on a window edge belong to the window ending there,
+	// except at the top of the buffer.
+	var transient []transientLine
+	for _, t := range b.transient {
+		if (t.at > first || (t.at == first && start == 0)) && t.at <= last {
+			transient = append(transient, t)
+		}
+	}
+	var sources []*eventSource
+	for _, src := range b.sources {
+		srcEnd := src.start + int64(src.count)
+		// Events without lines belong to the window they sit in; at the
+		// bottom that includes the ones after the last line.
+		inside := srcEnd > first && src.start < last
+		if src.count == 0 {
+			inside = src.start >= first && (src.start < last || (src.start == last && *offset == 0))
+		}
+		if inside {
+			sources = append(sources, src)
+		}
+	}
+
+	view := bufferView{
 		TotalLines:   total,
-		ScrollOffset: b.scrollOffset,
-		AtBottom:     b.scrollOffset == 0,
+		ScrollOffset: *offset,
+		AtBottom:     *offset == 0,
+		Sources:      sources,
+		First:        first,
+		stored:       stored,
+		transient:    transient,
+	}
+	view.Entries = view.rerender(nil)
+	view.Lines = schema.LegacyLines(view.Entries)
+	if view.Lines == nil {
+		view.Lines = []string{}
+	}
+	return view
+}
+
+// rerender returns the view's stored lines with the transient lines merged
+// in and, when render is set, the lines of each event source replaced by
+// render's output for the event. A source cut off by the edge of the view is
+// rendered in full.
+func (v bufferView) rerender(render func(*eventSource) []schema.BufferLine) []schema.BufferLine {
+	out := make([]schema.BufferLine, 0, len(v.stored)+len(v.transient))
+	next, nextTransient := 0, 0
+	transientBefore := func(seq int64) {
+		for nextTransient < len(v.transient) && v.transient[nextTransient].at <= seq {
+			out = append(out, v.transient[nextTransient].line)
+			nextTransient++
+		}
+	}
+	covered := v.First
+	for i, line := range v.stored {
+		seq := v.First + int64(i)
+		transientBefore(seq)
+		for render != nil && next < len(v.Sources) && v.Sources[next].start <= seq {
+			src := v.Sources[next]
+			next++
+			out = append(out, render(src)...)
+			covered = max(covered, src.start+int64(src.count))
+		}
+		if seq < covered {
+			continue
+		}
+		out = append(out, line)
+	}
+	if render != nil {
+		for _, src := range v.Sources[next:] {
+			out = append(out, render(src)...)
+		}
+	}
+	for _, t := range v.transient[nextTransient:] {
+		out = append(out, t.line)
 	}
+	return out
 }
 
 // Export returns the buffer state for persistence.
@@ -100,7 +380,7 @@ func (b *buffer) Export() persistedBuffer {
 	if b == nil {
 		return persistedBuffer{}
 	}
-	lines := append([]string(nil), b.lines...)
+	lines := append([]schema.BufferLine(nil), b.lines...)
 	offset := b.scrollOffset
 	if offset < 0 {
 		offset = 0
@@ -111,6 +391,7 @@ func (b *buffer) Export() persistedBuffer {
 	return persistedBuffer{
 		Lines:        lines,
 		ScrollOffset: offset,
+		Seq:          max(b.seq, int64(len(lines))),
 	}
 }
 
@@ -130,7 +411,7 @@ func newBufferWithMaxLines(maxLines int) *buffer {
 // newBufferFromPersisted constructs a buffer from persisted data.
 func newBufferFromPersistedWithMaxLines(state persistedBuffer, maxLines int) *buffer {
 	b := newBufferWithMaxLines(maxLines)
-	lines := append([]string(nil), state.Lines...)
+	lines := append([]schema.BufferLine(nil), state.Lines...)
 	if b.maxLines > 0 && len(lines) > b.maxLines {
 		lines = lines[len(lines)-b.maxLines:]
 	}
@@ -143,6 +424,7 @@ func newBufferFromPersistedWithMaxLines(state persistedBuffer, maxLines int) *bu
 	}
 	b.lines = lines
 	b.scrollOffset = offset
+	b.seq = max(state.Seq, int64(len(lines)))
 	return b
 }
 
@@ -1,15 +1,20 @@
 package core
 
-import "testing"
+import (
+	"slices"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
 
 func TestBufferScrollAnchorsOnAppend(t *testing.T) {
 	b := &buffer{maxLines: 100}
-	b.Append("one", "two", "three", "four", "five")
+	b.AppendRaw("one", "two", "three", "four", "five")
 	b.Scroll(2, 3) // scroll up two lines with viewport size 3
 	if b.scrollOffset != 2 {
 		t.Fatalf("expected scroll offset 2, got %d", b.scrollOffset)
 	}
-	b.Append("six", "seven")
+	b.AppendRaw("six", "seven")
 	if b.scrollOffset != 4 {
 		t.Fatalf("expected scroll offset 4 after append, got %d", b.scrollOffset)
 	}
@@ -24,7 +29,7 @@ func TestBufferScrollAnchorsOnAppend(t *testing.T) {
 
 func TestBufferRespectsMaxLines(t *testing.T) {
 	b := &buffer{maxLines: 3}
-	b.Append("one", "two", "three", "four", "five")
+	b.AppendRaw("one", "two", "three", "four", "five")
 	view := b.Snapshot(10)
 	if view.TotalLines != 3 {
 		t.Fatalf("expected total lines 3, got %d", view.TotalLines)
@@ -39,7 +44,7 @@ func TestBufferRespectsMaxLines(t *testing.T) {
 
 func TestBufferResetScroll(t *testing.T) {
 	b := &buffer{maxLines: 10}
-	b.Append("one", "two", "three")
+	b.AppendRaw("one", "two", "three")
 	b.Scroll(1, 2)
 	if b.scrollOffset == 0 {
 		t.Fatalf("expected scroll offset > 0")
@@ -52,7 +57,7 @@ func TestBufferResetScroll(t *testing.T) {
 
 func TestBufferScrollClampsToBounds(t *testing.T) {
 	b := &buffer{maxLines: 10}
-	b.Append("one", "two", "three", "four", "five")
+	b.AppendRaw("one", "two", "three", "four", "five")
 
 	b.Scroll(10, 3)
 	if b.scrollOffset != 2 {
@@ -67,7 +72,7 @@ func TestBufferScrollClampsToBounds(t *testing.T) {
 
 func TestBufferSnapshotClampsOffset(t *testing.T) {
 	b := &buffer{maxLines: 10}
-	b.Append("one", "two", "three", "four", "five")
+	b.AppendRaw("one", "two", "three", "four", "five")
 	b.scrollOffset = 10
 
 	view := b.Snapshot(3)
@@ -81,3 +86,75 @@ func TestBufferSnapshotClampsOffset(t *testing.T) {
 		t.Fatalf("unexpected lines: %v", view.Lines)
 	}
 }
+
+func TestBufferSeqCountsTrimmedLines(t *testing.T) {
+	b := newBufferFromPersistedWithMaxLines(persistedBuffer{Lines: schema.ParseBufferLines([]string{"one", "two"}), Seq: 10}, 3)
+	b.AppendRaw("three", "four")
+	exported := b.Export()
+	if exported.Seq != 12 {
+		t.Fatalf("expected seq 12, got %d", exported.Seq)
+	}
+	if len(exported.Lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(exported.Lines))
+	}
+	fresh := newBufferFromPersistedWithMaxLines(persistedBuffer{Lines: schema.ParseBufferLines([]string{"one", "two"})}, 3)
+	if fresh.Export().Seq != 2 {
+		t.Fatalf("expected seq to default to the line count, got %d", fresh.Export().Seq)
+	}
+}
+
+func TestBufferTransientLines(t *testing.T) {
+	b := &buffer{maxLines: 4}
+	b.AppendRaw("one")
+	b.AppendTransient(schema.Line(schema.LineKindSystem, "status: working"))
+	b.AppendRaw("two", "three")
+
+	view := b.Snapshot(0)
+	if want := []string{"one", "status: working", "two", "three"}; !slices.Equal(view.Lines, want) {
+		t.Fatalf("expected the transient line in place, got %q", view.Lines)
+	}
+	if view.TotalLines != 3 || len(view.Entries) != 4 {
+		t.Fatalf("expected TotalLines to count stored lines only, got %d of %d", view.TotalLines, len(view.Entries))
+	}
+	if exported := b.Export(); len(exported.Lines) != 3 || exported.Seq != 3 {
+		t.Fatalf("expected the transient line not to be exported, got %+v", exported)
+	}
+
+	// The transient line does not count against maxLines.
+	b.AppendRaw("four")
+	if got := b.Snapshot(0).Lines; !slices.Equal(got, []string{"one", "status: working", "two", "three", "four"}) {
+		t.Fatalf("unexpected lines %q", got)
+	}
+
+	// Scrolling counts stored lines; the transient line shows in the
+	// window ending at it.
+	b.Scroll(3, 1)
+	view = b.Snapshot(1)
+	if view.ScrollOffset != 3 || !slices.Equal(view.Lines, []string{"one", "status: working"}) {
+		t.Fatalf("unexpected scrolled view %d %q", view.ScrollOffset, view.Lines)
+	}
+	b.Scroll(-1, 1)
+	if got := b.Snapshot(1).Lines; !slices.Equal(got, []string{"two"}) {
+		t.Fatalf("unexpected scrolled view %q", got)
+	}
+
+	// Trimming the stored line before it drops the transient line.
+	b.ResetScroll()
+	b.AppendRaw("five")
+	if got := b.Snapshot(0).Lines; !slices.Equal(got, []string{"two", "three", "four", "five"}) || len(b.transient) != 0 {
+		t.Fatalf("expected the transient line to go with the trimmed lines, got %q", got)
+	}
+}
+
+func TestBufferTransientLinesAreBounded(t *testing.T) {
+	b := newBuffer()
+	for i := 0; i < maxTransientLines+5; i++ {
+		b.AppendTransient(schema.Line(schema.LineKindSystem, "status"))
+	}
+	if len(b.transient) != maxTransientLines {
+		t.Fatalf("expected %d transient lines, got %d", maxTransientLines, len(b.transient))
+	}
+	if view := b.Snapshot(0); view.TotalLines != 0 || len(view.Lines) != maxTransientLines {
+		t.Fatalf("unexpected view of an empty buffer with transient lines: %d %d", view.TotalLines, len(view.Lines))
+	}
+}
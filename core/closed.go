@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+// closedTab is a tab kept after CloseTab so ReopenTab can restore it.
+type closedTab struct {
+	tab      *tab
+	closedAt time.Time
+}
+
+// ReopenTab restores a recently closed tab with its buffer, history, session
+// and repo. The runner is started again on first use.
+func (s *service) ReopenTab(ctx context.Context, req schema.ReopenTabRequest) (schema.ReopenTabResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ReopenTabResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	index := req.Index
+	if index == 0 {
+		index = 1
+	}
+
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	s.pruneClosedTabsLocked(userID, state)
+	if index < 1 || index > len(state.closed) {
+		s.mu.Unlock()
+		log.Warn("service tab reopen failed", "err", schema.ErrClosedTabNotFound, "index", req.Index)
+		return schema.ReopenTabResponse{}, schema.ErrClosedTabNotFound
+	}
+	pos := len(state.closed) - index
+	restored := state.closed[pos].tab
+	state.closed = append(state.closed[:pos], state.closed[pos+1:]...)
+	s.scheduleClosedPruneLocked(userID, state)
+	restored.archived = false
+	if _, exists := state.tabs[restored.ID]; exists {
+		restored.ID = schema.TabID(newID())
+	}
+	state.tabs[restored.ID] = restored
+	state.order = append(state.order, restored.ID)
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil {
+		prefs.ActiveTab = restored.ID
+	}
+	active := activeTabFromContext(ctx, state)
+	snapshot := s.snapshotTab(userID, restored, active == restored.ID)
+	event := schema.TabEvent{
+		UserID:    userID,
+		Type:      schema.TabEventCreated,
+		Tab:       snapshot,
+		ActiveTab: active,
+	}
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, userID)
+	log.Info("service tab reopened", "tab", restored.ID, "tab_name", restored.Name, "session", restored.SessionID)
+	return schema.ReopenTabResponse{Tab: snapshot}, nil
+}
+
+// ListClosedTabs lists the tabs ReopenTab can restore, most recent first.
+func (s *service) ListClosedTabs(ctx context.Context, req schema.ListClosedTabsRequest) (schema.ListClosedTabsResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ListClosedTabsResponse{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.getOrCreateUserStateLocked(userID)
+	s.pruneClosedTabsLocked(userID, state)
+	tabs := make([]schema.ClosedTabInfo, 0, len(state.closed))
+	for i := len(state.closed) - 1; i >= 0; i-- {
+		entry := state.closed[i]
+		tabs = append(tabs, schema.ClosedTabInfo{
+			Tab:       s.snapshotTab(userID, entry.tab, false),
+			ClosedAt:  entry.closedAt,
+			ExpiresAt: entry.closedAt.Add(s.cfg.ClosedTabTTL),
+		})
+	}
+	logx.WithUser(ctx, userID).Trace("service closed tabs listed", "tabs", len(tabs))
+	return schema.ListClosedTabsResponse{Tabs: tabs}, nil
+}
+
+// rememberClosedTabLocked keeps a closed tab for ReopenTab, dropping the
+// oldest entries beyond ClosedTabsMax. Shares are not restored.
+func (s *service) rememberClosedTabLocked(userID schema.UserID, state *userState, closed *tab) {
+	closed.Status = schema.TabStatusIdle
+	closed.Run = nil
+	closed.RunCancel = nil
+	closed.progress = ""
+	closed.commands = nil
+	closed.shares = nil
+	state.closed = append(state.closed, closedTab{tab: closed, closedAt: s.now()})
+	if excess := len(state.closed) - s.cfg.ClosedTabsMax; excess > 0 {
+		state.closed = append([]closedTab(nil), state.closed[excess:]...)
+	}
+	s.scheduleClosedPruneLocked(userID, state)
+}
+
+// pruneClosedTabsLocked drops expired closed tabs and reports whether any
+// were dropped.
+func (s *service) pruneClosedTabsLocked(userID schema.UserID, state *userState) bool {
+	now := s.now()
+	kept := state.closed[:0]
+	for _, entry := range state.closed {
+		if now.Sub(entry.closedAt) < s.cfg.ClosedTabTTL {
+			kept = append(kept, entry)
+		}
+	}
+	pruned := len(kept) != len(state.closed)
+	clear(state.closed[len(kept):])
+	state.closed = kept
+	s.scheduleClosedPruneLocked(userID, state)
+	return pruned
+}
+
+// scheduleClosedPruneLocked arms the timer that prunes the oldest closed tab
+// when it expires.
+func (s *service) scheduleClosedPruneLocked(userID schema.UserID, state *userState) {
+	if state.closedTimer != nil {
+		state.closedTimer.Stop()
+		state.closedTimer = nil
+	}
+	if len(state.closed) == 0 {
+		return
+	}
+	delay := max(state.closed[0].closedAt.Add(s.cfg.ClosedTabTTL).Sub(s.now()), 0)
+	state.closedTimer = time.AfterFunc(delay, func() { s.pruneExpiredClosedTabs(userID) })
+}
+
+func (s *service) pruneExpiredClosedTabs(userID schema.UserID) {
+	s.mu.Lock()
+	state := s.userTabs[userID]
+	if state == nil {
+		s.mu.Unlock()
+		return
+	}
+	pruned := s.pruneClosedTabsLocked(userID, state)
+	s.mu.Unlock()
+	if pruned {
+		log := s.logger.With("user", userID)
+		s.persistUser(log, userID)
+		log.Debug("service closed tabs pruned")
+	}
+}
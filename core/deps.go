@@ -1,12 +1,38 @@
 package core
 
-import "pkt.systems/pslog"
+import (
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
 
 // ServiceDeps captures optional dependencies for the core service.
 type ServiceDeps struct {
 	RunnerProvider RunnerProvider
 	RepoResolver   RepoResolver
-	Renderer       Renderer
-	EventSink      EventSink
-	Logger         pslog.Logger
+	// Renderer produces the lines stored in tab buffers, which are what
+	// schema.RenderingPlain returns.
+	Renderer Renderer
+	// Renderers are the other renderings GetBuffer can ask for, by name.
+	// They replace or add to the built-in markdown and raw renderers.
+	Renderers map[schema.Rendering]Renderer
+	EventSink EventSink
+	Logger    pslog.Logger
+	// TokenBudgets, when set, supplies per-user daily token budgets that
+	// override ServiceConfig.DailyTokenBudget.
+	TokenBudgets TokenBudgetSource
+	// Users, when set, lists the accounts of the auth store. Tabs can be
+	// shared with these and with users the service already has state for.
+	Users UserDirectory
+}
+
+// UserDirectory reports whether an account exists.
+type UserDirectory interface {
+	HasUser(username string) bool
+}
+
+// TokenBudgetSource looks up a user's own daily token budget; ok is false
+// when the user has none and the configured default applies. A budget of 0
+// means no budget.
+type TokenBudgetSource interface {
+	DailyTokenBudget(userID schema.UserID) (budget int64, ok bool)
 }
@@ -5,116 +5,317 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"pkt.systems/centaurx/internal/timefmt"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
 )
 
+// unknownBranch is reported when the branch cannot be determined.
+const unknownBranch = "(unknown)"
+
+// gitNotInitialized is shown instead of the git lines when the tab's
+// directory is not a git repository.
+const gitNotInitialized = "not initialized — run /git init to set up version control"
+
 type gitSummary struct {
+	// notRepo is set when the working directory is not in a git work tree.
+	notRepo     bool
 	branch      string
 	remotes     []string
 	statusLines []string
 }
 
-func buildExecStartLines(now time.Time, tab *tab, summary gitSummary) []string {
-	labelWidth := maxLabelWidth([]string{"Repository", "Branch", "Remote", "Git status", "Model", "Session"})
+func buildExecStartLines(started time.Time, clock timefmt.Clock, tab *tab, lastRunAt time.Time, runID schema.RunID, summary gitSummary, tools []schema.ToolName) []string {
+	labelWidth := schema.LabelWidth("Repository", "Git", "Branch", "Remote", "Git status", "Model", "Tools", "Session", "Run")
 	repoLabel := ""
-	session := ""
+	session := "new"
 	model := schema.ModelID("")
 	effort := schema.ModelReasoningEffort("")
 	if tab != nil {
 		repoLabel = string(tab.Repo.Name)
-		session = string(tab.SessionID)
+		session = sessionLabel(tab.SessionID, lastRunAt, started)
 		model = tab.Model
 		effort = tab.ModelReasoningEffort
 	}
 	if strings.TrimSpace(repoLabel) == "" {
 		repoLabel = "(unknown)"
 	}
-	if strings.TrimSpace(session) == "" {
-		session = "(new)"
-	}
 
 	lines := []string{
-		schema.WorkedForMarker + fmt.Sprintf("%s Starting codex exec", now.Format("15:04:05")),
+		schema.WorkedForMarker + fmt.Sprintf("%s Starting codex exec", clock.Format(started)),
 	}
 	lines = append(lines, formatLabeledLines("Repository", []string{repoLabel}, labelWidth)...)
-	lines = append(lines, formatLabeledLines("Branch", []string{summary.branch}, labelWidth)...)
-	lines = append(lines, formatLabeledLines("Remote", summary.remotes, labelWidth)...)
-	lines = append(lines, formatLabeledLines("Git status", summary.statusLines, labelWidth)...)
+	if summary.notRepo {
+		lines = append(lines, formatLabeledLines("Git", []string{gitNotInitialized}, labelWidth)...)
+	} else {
+		lines = append(lines, formatLabeledLines("Branch", []string{summary.branch}, labelWidth)...)
+		lines = append(lines, formatLabeledLines("Remote", summary.remotes, labelWidth)...)
+		lines = append(lines, formatLabeledLines("Git status", summary.statusLines, labelWidth)...)
+	}
 	lines = append(lines, formatLabeledLines("Model", []string{schema.FormatModelWithReasoning(model, effort)}, labelWidth)...)
+	if len(tools) > 0 {
+		names := make([]string, 0, len(tools))
+		for _, name := range tools {
+			names = append(names, string(name))
+		}
+		lines = append(lines, formatLabeledLines("Tools", []string{strings.Join(names, ", ")}, labelWidth)...)
+	}
 	lines = append(lines, formatLabeledLines("Session", []string{session}, labelWidth)...)
+	if runID != "" {
+		lines = append(lines, formatLabeledLines("Run", []string{shortRunID(runID)}, labelWidth)...)
+	}
 	return lines
 }
 
-func collectGitSummary(ctx context.Context, runner Runner, workingDir, sshAuthSock string) gitSummary {
+// sessionLabel describes whether a run starts a new codex session or resumes
+// one, and when a resumed session was last used.
+func sessionLabel(id schema.SessionID, lastRunAt, now time.Time) string {
+	if strings.TrimSpace(string(id)) == "" {
+		return "new"
+	}
+	label := "resuming " + shortID(string(id))
+	if !lastRunAt.IsZero() {
+		label += " (last used " + timefmt.Ago(now, lastRunAt) + ")"
+	}
+	return label
+}
+
+// shortRunID returns the prefix of id shown in the exec start summary; it is
+// enough to grep the logs for the full id.
+func shortRunID(id schema.RunID) string {
+	return shortID(string(id))
+}
+
+// shortID returns the first eight characters of id, marked as cut when
+// longer.
+func shortID(id string) string {
+	const size = 8
+	if len(id) <= size {
+		return id
+	}
+	return id[:size] + "…"
+}
+
+// gitSummarySection ends the output of each git command in
+// gitSummaryCommand, followed by the command's exit code.
+const gitSummarySection = "--centaurx-git-summary--"
+
+// gitSummaryNotRepo is printed instead of the sections when the working
+// directory is not in a git work tree.
+const gitSummaryNotRepo = gitSummarySection + " not-a-repo"
+
+// gitSummaryCommand gathers the branch, remotes, status and the branch's
+// upstream in one shell call so the exec start summary costs a single runner
+// round-trip. A directory that is not a git repository is detected first, so
+// the summary can say so instead of showing every command as unavailable.
+const gitSummaryCommand = "git rev-parse --is-inside-work-tree >/dev/null 2>&1 || { echo " + gitSummaryNotRepo + "; exit 0; }; " +
+	"git rev-parse --abbrev-ref HEAD 2>/dev/null; echo " + gitSummarySection + " $?; " +
+	"git remote -v 2>/dev/null; echo " + gitSummarySection + " $?; " +
+	"git status --short 2>/dev/null; echo " + gitSummarySection + " $?; " +
+	"git rev-parse --abbrev-ref --symbolic-full-name @{u} 2>/dev/null; echo " + gitSummarySection + " $?"
+
+// collectGitSummary runs gitSummaryCommand in workingDir and lists up to
+// maxRemotes remotes.
+func collectGitSummary(ctx context.Context, runner Runner, workingDir, sshAuthSock string, maxRemotes int) gitSummary {
 	summary := gitSummary{
-		branch:      "(unknown)",
+		branch:      unknownBranch,
 		remotes:     []string{"(unavailable)"},
 		statusLines: []string{"(unavailable)"},
 	}
 	if runner == nil {
 		return summary
 	}
-
-	branchLines, err := runCommandLines(ctx, runner, RunCommandRequest{
+	lines, err := runCommandLines(ctx, runner, RunCommandRequest{
 		WorkingDir:  workingDir,
-		Command:     "git rev-parse --abbrev-ref HEAD",
-		UseShell:    false,
+		Command:     gitSummaryCommand,
+		UseShell:    true,
 		SSHAuthSock: sshAuthSock,
 	})
-	if err == nil && len(branchLines) > 0 {
+	if err != nil {
+		return summary
+	}
+	if slices.Contains(lines, gitSummaryNotRepo) {
+		summary.notRepo = true
+		return summary
+	}
+	sections := splitGitSummarySections(lines)
+
+	if branchLines, ok := sections[0]; ok && len(branchLines) > 0 {
 		branch := strings.TrimSpace(branchLines[0])
 		if branch == "" {
-			branch = "(unknown)"
+			branch = unknownBranch
 		} else if branch == "HEAD" {
 			branch = "(detached)"
 		}
 		summary.branch = branch
 	}
-
-	remoteLines, err := runCommandLines(ctx, runner, RunCommandRequest{
-		WorkingDir:  workingDir,
-		Command:     "git remote -v",
-		UseShell:    false,
-		SSHAuthSock: sshAuthSock,
-	})
-	if err == nil {
-		parsed := parseGitRemotes(remoteLines)
+	if remoteLines, ok := sections[1]; ok {
+		upstream := ""
+		if upstreamLines, ok := sections[3]; ok && len(upstreamLines) > 0 {
+			upstream = strings.TrimSpace(upstreamLines[0])
+		}
+		parsed := parseGitRemotes(remoteLines, upstream, summary.branch, maxRemotes)
 		if len(parsed) == 0 {
 			summary.remotes = []string{"(none)"}
 		} else {
 			summary.remotes = parsed
 		}
 	}
+	if statusLines, ok := sections[2]; ok {
+		summary.statusLines = summarizeGitStatus(trimEmptyLines(statusLines))
+	}
+	return summary
+}
 
-	statusLines, err := runCommandLines(ctx, runner, RunCommandRequest{
-		WorkingDir:  workingDir,
-		Command:     "git status --short",
-		UseShell:    false,
-		SSHAuthSock: sshAuthSock,
-	})
-	if err == nil {
-		statusLines = trimEmptyLines(statusLines)
-		if len(statusLines) == 0 {
-			summary.statusLines = []string{"(working tree clean)"}
-		} else {
-			summary.statusLines = statusLines
+// gitignoreHintThreshold is the number of untracked entries above which the
+// summary suggests adding a .gitignore.
+const gitignoreHintThreshold = 20
+
+// gitignoreHint follows the untracked count when it exceeds
+// gitignoreHintThreshold.
+const gitignoreHint = "many untracked files, consider a .gitignore (/gitignore suggest)"
+
+// summarizeGitStatus keeps the tracked entries of git status --short as they
+// are and collapses untracked ones into a count, so a repo without a
+// .gitignore does not flood the summary with build artifacts.
+func summarizeGitStatus(lines []string) []string {
+	summary := make([]string, 0, len(lines))
+	untracked := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "?? ") {
+			untracked++
+			continue
+		}
+		summary = append(summary, line)
+	}
+	if untracked > 0 {
+		summary = append(summary, fmt.Sprintf("+%d untracked", untracked))
+	}
+	if untracked > gitignoreHintThreshold {
+		summary = append(summary, gitignoreHint)
+	}
+	if len(summary) == 0 {
+		return []string{"(working tree clean)"}
+	}
+	return summary
+}
+
+// splitGitSummarySections splits the output of gitSummaryCommand into the
+// lines of each git command, keyed by position. Commands that failed are
+// left out.
+func splitGitSummarySections(lines []string) map[int][]string {
+	sections := make(map[int][]string)
+	var current []string
+	index := 0
+	for _, line := range lines {
+		code, ok := strings.CutPrefix(line, gitSummarySection)
+		if !ok {
+			current = append(current, line)
+			continue
+		}
+		if strings.TrimSpace(code) == "0" {
+			sections[index] = current
+		}
+		current = nil
+		index++
+	}
+	return sections
+}
+
+// gitSummaryCache reuses the exec start git summary of a tab's working
+// directory for a short while, so prompts sent in quick succession do not
+// ask the runner again. Entries are dropped when a run or shell command in
+// the tab finishes, since either may have changed the working tree.
+type gitSummaryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[gitSummaryKey]gitSummaryEntry
+}
+
+type gitSummaryKey struct {
+	tabID      schema.TabID
+	workingDir string
+}
+
+type gitSummaryEntry struct {
+	fetchedAt time.Time
+	summary   gitSummary
+}
+
+func newGitSummaryCache(ttl time.Duration) *gitSummaryCache {
+	return &gitSummaryCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[gitSummaryKey]gitSummaryEntry),
+	}
+}
+
+func (c *gitSummaryCache) get(tabID schema.TabID, workingDir string) (gitSummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := gitSummaryKey{tabID: tabID, workingDir: workingDir}
+	entry, ok := c.entries[key]
+	if !ok {
+		return gitSummary{}, false
+	}
+	if c.now().Sub(entry.fetchedAt) > c.ttl {
+		delete(c.entries, key)
+		return gitSummary{}, false
+	}
+	return entry.summary, true
+}
+
+// store caches summary unless the branch could not be read, which usually
+// means the runner was not ready yet.
+func (c *gitSummaryCache) store(tabID schema.TabID, workingDir string, summary gitSummary) {
+	if summary.branch == unknownBranch {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[gitSummaryKey{tabID: tabID, workingDir: workingDir}] = gitSummaryEntry{fetchedAt: c.now(), summary: summary}
+}
+
+func (c *gitSummaryCache) invalidate(tabID schema.TabID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.tabID == tabID {
+			delete(c.entries, key)
 		}
 	}
+}
 
+// gitSummaryFor returns the cached git summary of the tab's working
+// directory, collecting it through the runner when there is none.
+func (s *service) gitSummaryFor(ctx context.Context, runner Runner, tabID schema.TabID, workingDir, sshAuthSock string) gitSummary {
+	if summary, ok := s.gitSummaries.get(tabID, workingDir); ok {
+		return summary
+	}
+	summary := collectGitSummary(ctx, runner, workingDir, sshAuthSock, s.cfg.GitSummaryRemotesMax)
+	s.gitSummaries.store(tabID, workingDir, summary)
 	return summary
 }
 
-func parseGitRemotes(lines []string) []string {
+// parseGitRemotes turns git remote -v output into one "name  url" value per
+// remote, or a push and a fetch value when the two URLs differ. Remotes are
+// sorted by name with origin first, and names are padded so the URLs line
+// up. The remote that upstream (such as "origin/main") belongs to is marked
+// as the upstream of branch. Remotes beyond maxRemotes are counted on a
+// final "+N more" value.
+func parseGitRemotes(lines []string, upstream, branch string, maxRemotes int) []string {
 	type remoteInfo struct {
 		fetch string
 		push  string
 	}
 	remotes := make(map[string]*remoteInfo)
-	order := make([]string, 0, len(lines))
+	names := make([]string, 0, len(lines))
 	for _, line := range lines {
 		fields := strings.Fields(line)
 		if len(fields) < 3 {
@@ -127,7 +328,7 @@ func parseGitRemotes(lines []string) []string {
 		if info == nil {
 			info = &remoteInfo{}
 			remotes[name] = info
-			order = append(order, name)
+			names = append(names, name)
 		}
 		switch kind {
 		case "fetch":
@@ -136,48 +337,76 @@ func parseGitRemotes(lines []string) []string {
 			info.push = url
 		}
 	}
-	if len(remotes) == 0 {
-		return nil
-	}
-	name := ""
-	if _, ok := remotes["origin"]; ok {
-		name = "origin"
-	} else {
-		name = order[0]
-	}
-	info := remotes[name]
-	if info == nil {
+	if len(names) == 0 {
 		return nil
 	}
-	fetch := info.fetch
-	push := info.push
-	if fetch == "" {
-		fetch = push
-	}
-	if push == "" {
-		push = fetch
+	slices.SortFunc(names, func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case a == "origin":
+			return -1
+		case b == "origin":
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	tracked := upstreamRemote(upstream, names)
+	hidden := 0
+	if maxRemotes > 0 && len(names) > maxRemotes {
+		hidden = len(names) - maxRemotes
+		names = names[:maxRemotes]
 	}
-	if fetch == "" && push == "" {
-		return nil
+
+	labels := make([]string, len(names))
+	width := 0
+	for i, name := range names {
+		labels[i] = name
+		if name == tracked {
+			labels[i] = fmt.Sprintf("%s (upstream of %s)", name, branch)
+		}
+		width = max(width, len(labels[i]))
 	}
-	if fetch == push {
-		return []string{fetch}
+	out := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		info := remotes[name]
+		fetch, push := info.fetch, info.push
+		if fetch == "" {
+			fetch = push
+		}
+		if push == "" {
+			push = fetch
+		}
+		label := fmt.Sprintf("%-*s", width, labels[i])
+		if fetch == push {
+			out = append(out, label+"  "+fetch)
+			continue
+		}
+		out = append(out, label+"  "+push+" (push)", label+"  "+fetch+" (fetch)")
 	}
-	lines = []string{}
-	if push != "" {
-		lines = append(lines, fmt.Sprintf("%s (push)", push))
+	if hidden > 0 {
+		out = append(out, fmt.Sprintf("+%d more", hidden))
 	}
-	if fetch != "" {
-		lines = append(lines, fmt.Sprintf("%s (fetch)", fetch))
+	return out
+}
+
+// upstreamRemote returns the remote of an upstream such as "origin/main".
+// Remote names may contain slashes, so the longest matching name wins.
+func upstreamRemote(upstream string, names []string) string {
+	best := ""
+	for _, name := range names {
+		if strings.HasPrefix(upstream, name+"/") && len(name) > len(best) {
+			best = name
+		}
 	}
-	return lines
+	return best
 }
 
 func runCommandLines(ctx context.Context, runner Runner, req RunCommandRequest) ([]string, error) {
 	log := pslog.Ctx(ctx)
 	if runner == nil {
 		log.Debug("exec start command skipped", "reason", "runner unavailable")
-		return nil, errors.New("runner not available")
+		return nil, schema.WithCode(schema.CodeRunnerUnavailable, errors.New("runner not available"))
 	}
 	handle, err := runner.RunCommand(ctx, req)
 	if err != nil {
@@ -224,38 +453,24 @@ func trimEmptyLines(lines []string) []string {
 	return out
 }
 
-func maxLabelWidth(labels []string) int {
-	max := 0
-	for _, label := range labels {
-		if label == "" {
-			continue
-		}
-		width := len(label) + 1
-		if width > max {
-			max = width
-		}
-	}
-	return max
-}
-
+// formatLabeledLines returns field lines for label and its values. The label
+// is padded to labelWidth so a block of fields aligns; later values get a
+// blank label of the same width. Wrapping is left to the renderer.
 func formatLabeledLines(label string, values []string, labelWidth int) []string {
-	if labelWidth <= 0 {
-		labelWidth = len(label) + 1
-	}
 	if len(values) == 0 {
 		values = []string{"(unknown)"}
 	}
 	lines := make([]string, 0, len(values))
-	prefix := fmt.Sprintf("%-*s ", labelWidth, label+":")
-	indent := strings.Repeat(" ", len(prefix))
+	padded := schema.PadLabel(label, labelWidth)
+	blank := strings.Repeat(" ", len(padded))
 	for i, value := range values {
 		if strings.TrimSpace(value) == "" {
 			value = "(unknown)"
 		}
 		if i == 0 {
-			lines = append(lines, prefix+value)
+			lines = append(lines, schema.FieldLine(padded, value))
 		} else {
-			lines = append(lines, indent+value)
+			lines = append(lines, schema.FieldLine(blank, value))
 		}
 	}
 	return lines
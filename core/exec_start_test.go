@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/timefmt"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestExecStartLinesGolden(t *testing.T) {
+	clock, err := timefmt.New("15:04", "Europe/Stockholm")
+	if err != nil {
+		t.Fatalf("clock: %v", err)
+	}
+	started := time.Date(2026, time.March, 4, 12, 30, 0, 0, time.UTC)
+	summary := gitSummary{branch: "centaurx", remotes: []string{"origin  git@example.com:demo.git (fetch)"}, statusLines: []string{"clean"}}
+	cases := []struct {
+		name      string
+		tab       *tab
+		lastRunAt time.Time
+		summary   gitSummary
+		tools     []schema.ToolName
+		golden    string
+	}{
+		{
+			name:    "new",
+			tab:     &tab{Repo: schema.RepoRef{Name: "demo"}, Model: "gpt-5.2-codex", ModelReasoningEffort: "high"},
+			summary: gitSummary{notRepo: true},
+			golden:  "exec_start_new.golden",
+		},
+		{
+			name:      "resume",
+			tab:       &tab{Repo: schema.RepoRef{Name: "demo"}, Model: "gpt-5.2-codex", SessionID: "0199a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b"},
+			lastRunAt: started.Add(-2*time.Hour - 10*time.Minute),
+			summary:   summary,
+			tools:     []schema.ToolName{schema.ToolWebSearch},
+			golden:    "exec_start_resume.golden",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := buildExecStartLines(started, clock, tc.tab, tc.lastRunAt, "run-0123456789", tc.summary, tc.tools)
+			got := goldenExecStartLines(lines)
+			data, err := os.ReadFile(filepath.Join("testdata", tc.golden))
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			want := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("output mismatch\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+// goldenExecStartLines spells out the header marker and joins field labels
+// and values the way the terminal renders them.
+func goldenExecStartLines(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, schema.WorkedForMarker):
+			out = append(out, "[header] "+strings.TrimPrefix(line, schema.WorkedForMarker))
+		case strings.HasPrefix(line, schema.FieldMarker):
+			label, value := schema.SplitField(strings.TrimPrefix(line, schema.FieldMarker))
+			out = append(out, label+" "+value)
+		default:
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func TestSummarizeGitStatus(t *testing.T) {
+	many := make([]string, 0, gitignoreHintThreshold+1)
+	for i := range gitignoreHintThreshold + 1 {
+		many = append(many, fmt.Sprintf("?? build/out%d.o", i))
+	}
+	cases := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{name: "clean", want: []string{"(working tree clean)"}},
+		{name: "tracked", lines: []string{" M main.go", "A  new.go"}, want: []string{" M main.go", "A  new.go"}},
+		{name: "untracked counted", lines: []string{" M main.go", "?? notes.txt", "?? bin/"}, want: []string{" M main.go", "+2 untracked"}},
+		{name: "only untracked", lines: []string{"?? notes.txt"}, want: []string{"+1 untracked"}},
+		{name: "gitignore hint", lines: many, want: []string{fmt.Sprintf("+%d untracked", len(many)), gitignoreHint}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := summarizeGitStatus(tc.lines); !slices.Equal(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseGitRemotes(t *testing.T) {
+	remote := func(name, url string) []string {
+		return []string{name + " " + url + " (fetch)", name + " " + url + " (push)"}
+	}
+	cases := []struct {
+		name     string
+		lines    []string
+		upstream string
+		max      int
+		want     []string
+	}{
+		{name: "none"},
+		{name: "single", lines: remote("origin", "git@example.com:demo.git"), want: []string{"origin  git@example.com:demo.git"}},
+		{
+			name:     "origin first then by name",
+			lines:    slices.Concat(remote("upstream", "u.git"), remote("fork", "f.git"), remote("origin", "o.git")),
+			upstream: "origin/main",
+			max:      3,
+			want: []string{
+				"origin (upstream of main)  o.git",
+				"fork                       f.git",
+				"upstream                   u.git",
+			},
+		},
+		{
+			name:     "upstream on another remote",
+			lines:    slices.Concat(remote("origin", "o.git"), remote("team/ci", "c.git"), remote("team", "t.git")),
+			upstream: "team/ci/main",
+			want: []string{
+				"origin                      o.git",
+				"team                        t.git",
+				"team/ci (upstream of main)  c.git",
+			},
+		},
+		{
+			name:  "push differs",
+			lines: []string{"origin https://example.com/demo.git (fetch)", "origin git@example.com:demo.git (push)"},
+			want:  []string{"origin  git@example.com:demo.git (push)", "origin  https://example.com/demo.git (fetch)"},
+		},
+		{
+			name:  "capped",
+			lines: slices.Concat(remote("d", "d.git"), remote("c", "c.git"), remote("b", "b.git"), remote("origin", "o.git")),
+			max:   2,
+			want:  []string{"origin  o.git", "b       b.git", "+2 more"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseGitRemotes(tc.lines, tc.upstream, "main", tc.max); !slices.Equal(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCollectGitSummaryUsesOneCommand(t *testing.T) {
+	runner := &gitInfoRunner{outputs: map[string][]string{
+		"git rev-parse --abbrev-ref HEAD": {"main"},
+		"git remote -v":                   {},
+		"git status --short":              {" M main.go", "?? a.out"},
+	}}
+	summary := collectGitSummary(context.Background(), runner, "/repo", "", schema.DefaultGitSummaryRemotesMax)
+	if runner.commandCount() != 1 {
+		t.Fatalf("expected one batched command, got %d", runner.commandCount())
+	}
+	if want := []string{" M main.go", "+1 untracked"}; !slices.Equal(summary.statusLines, want) {
+		t.Fatalf("status lines = %q, want %q", summary.statusLines, want)
+	}
+}
@@ -0,0 +1,331 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// repoTarget is a repo path resolved inside the runner.
+type repoTarget struct {
+	runner      Runner
+	sshAuthSock string
+	repo        schema.RepoName
+	workingDir  string
+	// rel is the cleaned path relative to the repo root.
+	rel string
+	// abs is the symlink-free path inside the runner.
+	abs     string
+	kind    string
+	size    int64
+	modTime time.Time
+}
+
+// commandCapture is the collected stdout of a runner command.
+type commandCapture struct {
+	lines    []string
+	exitCode int
+}
+
+func (s *service) ListRepoFiles(ctx context.Context, req schema.ListRepoFilesRequest) (schema.ListRepoFilesResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ListRepoFilesResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	target, err := s.resolveRepoTarget(ctx, userID, req.TabID, req.Path)
+	if err != nil {
+		log.Warn("service repo files resolve failed", "path", req.Path, "err", err)
+		return schema.ListRepoFilesResponse{}, err
+	}
+	if target.kind != "dir" {
+		return schema.ListRepoFilesResponse{}, fmt.Errorf("%w: %s", schema.ErrNotDirectory, target.rel)
+	}
+	capture, err := captureCommand(ctx, target.runner, RunCommandRequest{
+		WorkingDir:  target.workingDir,
+		Command:     "find -H " + shellQuote(target.abs) + ` -mindepth 1 -maxdepth 1 -printf '%y\t%s\t%m\t%T@\t%f\n'`,
+		UseShell:    true,
+		SSHAuthSock: target.sshAuthSock,
+	})
+	if err != nil {
+		log.Warn("service repo files list failed", "path", target.rel, "err", err)
+		return schema.ListRepoFilesResponse{}, err
+	}
+	if capture.exitCode != 0 {
+		log.Warn("service repo files list failed", "path", target.rel, "exit_code", capture.exitCode)
+		return schema.ListRepoFilesResponse{}, fmt.Errorf("list %s: command exited with code %d", target.rel, capture.exitCode)
+	}
+	entries := make([]schema.RepoFileEntry, 0, len(capture.lines))
+	for _, line := range capture.lines {
+		entry, ok := parseRepoFileEntry(line)
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if (entries[i].Type == "dir") != (entries[j].Type == "dir") {
+			return entries[i].Type == "dir"
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	log.Debug("service repo files listed", "path", target.rel, "entries", len(entries))
+	return schema.ListRepoFilesResponse{Path: target.rel, Entries: entries}, nil
+}
+
+func (s *service) ReadRepoFile(ctx context.Context, req schema.ReadRepoFileRequest) (schema.ReadRepoFileResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ReadRepoFileResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	maxBytes := req.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = schema.DefaultRepoFileMaxBytes
+	}
+	target, err := s.resolveRepoTarget(ctx, userID, req.TabID, req.Path)
+	if err != nil {
+		log.Warn("service repo file resolve failed", "path", req.Path, "err", err)
+		return schema.ReadRepoFileResponse{}, err
+	}
+	switch target.kind {
+	case "file":
+	case "dir":
+		return schema.ReadRepoFileResponse{}, fmt.Errorf("%w: %s", schema.ErrIsDirectory, target.rel)
+	default:
+		return schema.ReadRepoFileResponse{}, fmt.Errorf("%w: %s is not a regular file", schema.ErrInvalidPath, target.rel)
+	}
+	if target.size > maxBytes {
+		return schema.ReadRepoFileResponse{}, fmt.Errorf("%w: %s is %d bytes (limit %d)", schema.ErrFileTooLarge, target.rel, target.size, maxBytes)
+	}
+	// Runner output is line oriented, so contents travel base64 encoded.
+	capture, err := captureCommand(ctx, target.runner, RunCommandRequest{
+		WorkingDir:  target.workingDir,
+		Command:     "base64 -- " + shellQuote(target.abs),
+		UseShell:    true,
+		SSHAuthSock: target.sshAuthSock,
+	})
+	if err != nil {
+		log.Warn("service repo file read failed", "path", target.rel, "err", err)
+		return schema.ReadRepoFileResponse{}, err
+	}
+	if capture.exitCode != 0 {
+		log.Warn("service repo file read failed", "path", target.rel, "exit_code", capture.exitCode)
+		return schema.ReadRepoFileResponse{}, fmt.Errorf("read %s: command exited with code %d", target.rel, capture.exitCode)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.Join(capture.lines, ""))
+	if err != nil {
+		log.Warn("service repo file decode failed", "path", target.rel, "err", err)
+		return schema.ReadRepoFileResponse{}, fmt.Errorf("read %s: %w", target.rel, err)
+	}
+	if int64(len(content)) > maxBytes {
+		return schema.ReadRepoFileResponse{}, fmt.Errorf("%w: %s is %d bytes (limit %d)", schema.ErrFileTooLarge, target.rel, len(content), maxBytes)
+	}
+	log.Debug("service repo file read", "path", target.rel, "bytes", len(content))
+	return schema.ReadRepoFileResponse{
+		Path:    target.rel,
+		Size:    int64(len(content)),
+		ModTime: target.modTime,
+		Content: content,
+	}, nil
+}
+
+// resolveRepoTarget resolves requested inside the runner's view of the tab's
+// repo. Paths that resolve outside the repo, including through symlinks, are
+// rejected.
+func (s *service) resolveRepoTarget(ctx context.Context, userID schema.UserID, tabID schema.TabID, requested string) (repoTarget, error) {
+	rel, err := cleanRepoPath(requested)
+	if err != nil {
+		return repoTarget{}, err
+	}
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, tabID, schema.ShareAccessRead)
+	var repoName schema.RepoName
+	if err == nil {
+		repoName = ref.tab.Repo.Name
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return repoTarget{}, err
+	}
+	if s.runners == nil {
+		return repoTarget{}, schema.ErrRunnerUnavailable
+	}
+	runnerResp, err := s.runners.RunnerFor(ctx, RunnerRequest{UserID: ref.owner, TabID: tabID})
+	if err != nil {
+		return repoTarget{}, err
+	}
+	info := runnerResp.Info
+	workingDir, err := s.repoPath(ref.owner, repoName)
+	if err != nil {
+		return repoTarget{}, err
+	}
+	if info.RepoRoot != "" {
+		mapped, err := MapRepoPath(s.repoRoot, info, workingDir)
+		if err != nil {
+			return repoTarget{}, err
+		}
+		workingDir = mapped
+	}
+	arg := shellQuote("./" + rel)
+	capture, err := captureCommand(ctx, runnerResp.Runner, RunCommandRequest{
+		WorkingDir:  workingDir,
+		Command:     "realpath -e -- . " + arg + " && find -H " + arg + ` -maxdepth 0 -printf '%y\t%s\t%T@\n'`,
+		UseShell:    true,
+		SSHAuthSock: info.SSHAuthSock,
+	})
+	if err != nil {
+		return repoTarget{}, err
+	}
+	if capture.exitCode != 0 || len(capture.lines) < 3 {
+		return repoTarget{}, fmt.Errorf("%w: %s", schema.ErrFileNotFound, rel)
+	}
+	root, abs := capture.lines[0], capture.lines[1]
+	if abs != root && !strings.HasPrefix(abs, strings.TrimSuffix(root, "/")+"/") {
+		return repoTarget{}, fmt.Errorf("%w: %s resolves outside the repo", schema.ErrInvalidPath, rel)
+	}
+	fields := strings.Split(capture.lines[2], "\t")
+	if len(fields) != 3 {
+		return repoTarget{}, fmt.Errorf("stat %s: unexpected output %q", rel, capture.lines[2])
+	}
+	size, _ := strconv.ParseInt(fields[1], 10, 64)
+	return repoTarget{
+		runner:      runnerResp.Runner,
+		sshAuthSock: info.SSHAuthSock,
+		repo:        repoName,
+		workingDir:  workingDir,
+		rel:         rel,
+		abs:         abs,
+		kind:        repoFileType(fields[0]),
+		size:        size,
+		modTime:     parseUnixTime(fields[2]),
+	}, nil
+}
+
+// cleanRepoPath normalizes a repo-relative path, rejecting absolute paths and
+// paths that climb out of the repo.
+func cleanRepoPath(requested string) (string, error) {
+	if requested == "" {
+		return ".", nil
+	}
+	if strings.ContainsAny(requested, "\x00\n") {
+		return "", fmt.Errorf("%w: %q", schema.ErrInvalidPath, requested)
+	}
+	if path.IsAbs(requested) {
+		return "", fmt.Errorf("%w: %s must be relative to the repo", schema.ErrInvalidPath, requested)
+	}
+	cleaned := path.Clean(requested)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s is outside the repo", schema.ErrInvalidPath, requested)
+	}
+	return cleaned, nil
+}
+
+// parseRepoFileEntry parses a find -printf '%y\t%s\t%m\t%T@\t%f' line.
+func parseRepoFileEntry(line string) (schema.RepoFileEntry, bool) {
+	fields := strings.SplitN(line, "\t", 5)
+	if len(fields) != 5 || fields[4] == "" {
+		return schema.RepoFileEntry{}, false
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return schema.RepoFileEntry{}, false
+	}
+	perm, err := strconv.ParseUint(fields[2], 8, 32)
+	if err != nil {
+		return schema.RepoFileEntry{}, false
+	}
+	kind := repoFileType(fields[0])
+	mode := fs.FileMode(perm) & fs.ModePerm
+	switch kind {
+	case "dir":
+		mode |= fs.ModeDir
+	case "symlink":
+		mode |= fs.ModeSymlink
+	case "other":
+		mode |= fs.ModeIrregular
+	}
+	return schema.RepoFileEntry{
+		Name:    fields[4],
+		Type:    kind,
+		Size:    size,
+		Mode:    mode.String(),
+		ModTime: parseUnixTime(fields[3]),
+	}, true
+}
+
+func repoFileType(code string) string {
+	switch code {
+	case "f":
+		return "file"
+	case "d":
+		return "dir"
+	case "l":
+		return "symlink"
+	default:
+		return "other"
+	}
+}
+
+// parseUnixTime parses find's %T@ ("seconds.fraction") as UTC.
+func parseUnixTime(value string) time.Time {
+	secs, frac, _ := strings.Cut(value, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	var nsec int64
+	if frac != "" {
+		frac = (frac + "000000000")[:9]
+		nsec, _ = strconv.ParseInt(frac, 10, 64)
+	}
+	return time.Unix(sec, nsec).UTC()
+}
+
+// captureCommand runs a command and collects its stdout lines verbatim.
+func captureCommand(ctx context.Context, runner Runner, req RunCommandRequest) (commandCapture, error) {
+	if runner == nil {
+		return commandCapture{}, schema.ErrRunnerUnavailable
+	}
+	handle, err := runner.RunCommand(ctx, req)
+	if err != nil {
+		return commandCapture{}, err
+	}
+	defer func() { _ = handle.Close() }()
+	stream := handle.Outputs()
+	var capture commandCapture
+	for {
+		output, err := stream.Next(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return commandCapture{}, err
+		}
+		if output.Stream == CommandStreamStderr {
+			continue
+		}
+		capture.lines = append(capture.lines, output.Text)
+	}
+	result, err := handle.Wait(ctx)
+	if err != nil {
+		return commandCapture{}, err
+	}
+	capture.exitCode = result.ExitCode
+	return capture, nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
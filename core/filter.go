@@ -0,0 +1,124 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// outputFilter suppresses command output lines matching a pattern.
+type outputFilter struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func compileOutputFilter(pattern string) (outputFilter, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return outputFilter{}, fmt.Errorf("%w: empty pattern", schema.ErrInvalidOutputFilter)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return outputFilter{}, fmt.Errorf("%w: %v", schema.ErrInvalidOutputFilter, err)
+	}
+	return outputFilter{pattern: pattern, re: re}, nil
+}
+
+// compileOutputFilters restores persisted patterns, skipping any that no
+// longer compile.
+func compileOutputFilters(patterns []string) []outputFilter {
+	var filters []outputFilter
+	for _, pattern := range patterns {
+		filter, err := compileOutputFilter(pattern)
+		if err != nil {
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+func filterPatterns(filters []outputFilter) []string {
+	if len(filters) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		out = append(out, filter.pattern)
+	}
+	return out
+}
+
+// applyOutputFilters removes lines of output matching any filter. It returns
+// the remaining output and the number of lines suppressed by each filter.
+func applyOutputFilters(filters []outputFilter, output string) (string, []int) {
+	if len(filters) == 0 || output == "" {
+		return output, nil
+	}
+	counts := make([]int, len(filters))
+	suppressed := false
+	lines := strings.Split(output, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		matched := false
+		for i, filter := range filters {
+			if filter.re.MatchString(line) {
+				counts[i]++
+				matched = true
+				break
+			}
+		}
+		if matched {
+			suppressed = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !suppressed {
+		return output, nil
+	}
+	return strings.Join(kept, "\n"), counts
+}
+
+// formatSuppressedLine summarizes suppressed lines per command, or returns ""
+// when nothing was suppressed.
+func formatSuppressedLine(filters []outputFilter, counts []int) string {
+	total := 0
+	var patterns []string
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		total += count
+		patterns = append(patterns, "/"+filters[i].pattern+"/")
+	}
+	if total == 0 {
+		return ""
+	}
+	noun := "lines"
+	if total == 1 {
+		noun = "line"
+	}
+	return fmt.Sprintf("(suppressed %s %s matching %s)", formatThousands(total), noun, strings.Join(patterns, ", "))
+}
+
+func formatThousands(n int) string {
+	digits := strconv.Itoa(n)
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	lead := len(digits) % 3
+	if lead > 0 {
+		b.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
@@ -1,30 +1,42 @@
 package core
 
-import "strings"
+import (
+	"strings"
+	"time"
 
-const defaultHistoryMax = 200
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/schema"
+)
+
+type historyEntry struct {
+	text string
+	at   time.Time
+}
 
 type historyBuffer struct {
-	entries []string
+	entries []historyEntry
 	max     int
 }
 
 func newHistory(max int) *historyBuffer {
 	if max <= 0 {
-		max = defaultHistoryMax
+		max = schema.DefaultHistoryMax
 	}
 	return &historyBuffer{max: max}
 }
 
-func newHistoryFromPersisted(entries []string) *historyBuffer {
-	h := newHistory(defaultHistoryMax)
+func newHistoryFromPersisted(entries []persist.HistoryEntry, max int) *historyBuffer {
+	h := newHistory(max)
 	if len(entries) == 0 {
 		return h
 	}
 	if len(entries) > h.max {
 		entries = entries[len(entries)-h.max:]
 	}
-	h.entries = append([]string(nil), entries...)
+	h.entries = make([]historyEntry, 0, len(entries))
+	for _, entry := range entries {
+		h.entries = append(h.entries, historyEntry{text: entry.Text, at: entry.Time})
+	}
 	return h
 }
 
@@ -35,10 +47,10 @@ func (h *historyBuffer) Append(entry string) bool {
 	if strings.TrimSpace(entry) == "" {
 		return false
 	}
-	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1].text == entry {
 		return false
 	}
-	h.entries = append(h.entries, entry)
+	h.entries = append(h.entries, historyEntry{text: entry, at: time.Now().UTC()})
 	if len(h.entries) > h.max {
 		h.entries = h.entries[len(h.entries)-h.max:]
 	}
@@ -49,5 +61,33 @@ func (h *historyBuffer) Entries() []string {
 	if h == nil {
 		return nil
 	}
-	return append([]string(nil), h.entries...)
+	out := make([]string, 0, len(h.entries))
+	for _, entry := range h.entries {
+		out = append(out, entry.text)
+	}
+	return out
+}
+
+// Items returns the history with timestamps, oldest first.
+func (h *historyBuffer) Items() []schema.HistoryEntry {
+	if h == nil {
+		return nil
+	}
+	out := make([]schema.HistoryEntry, 0, len(h.entries))
+	for _, entry := range h.entries {
+		out = append(out, schema.HistoryEntry{Text: entry.text, Time: entry.at})
+	}
+	return out
+}
+
+// Export returns the history with timestamps for persistence.
+func (h *historyBuffer) Export() []persist.HistoryEntry {
+	if h == nil || len(h.entries) == 0 {
+		return nil
+	}
+	out := make([]persist.HistoryEntry, 0, len(h.entries))
+	for _, entry := range h.entries {
+		out = append(out, persist.HistoryEntry{Text: entry.text, Time: entry.at})
+	}
+	return out
 }
@@ -3,6 +3,8 @@ package core
 import (
 	"crypto/rand"
 	"encoding/hex"
+
+	"pkt.systems/centaurx/schema"
 )
 
 func newID() string {
@@ -12,3 +14,11 @@ func newID() string {
 	}
 	return hex.EncodeToString(buf[:])
 }
+
+func newRunID() schema.RunID {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "run-unknown"
+	}
+	return schema.RunID(hex.EncodeToString(buf[:]))
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// SetTabLabel adds a label to a tab or removes one. Adding a label the tab
+// already has changes nothing.
+func (s *service) SetTabLabel(ctx context.Context, req schema.SetTabLabelRequest) (schema.SetTabLabelResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetTabLabelResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	label, err := schema.NormalizeTabLabel(string(req.Label))
+	if err != nil {
+		log.Warn("service label update rejected", "err", err)
+		return schema.SetTabLabelResponse{}, err
+	}
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service label update failed", "err", err)
+		return schema.SetTabLabelResponse{}, err
+	}
+	labels := ref.tab.labels
+	index, found := slices.BinarySearch(labels, label)
+	switch {
+	case req.Remove && !found:
+		s.mu.Unlock()
+		return schema.SetTabLabelResponse{}, fmt.Errorf("%w: tab has no label %q", schema.ErrInvalidTabLabel, label)
+	case req.Remove:
+		labels = slices.Delete(slices.Clone(labels), index, index+1)
+	case !found && len(labels) >= schema.MaxTabLabels:
+		s.mu.Unlock()
+		return schema.SetTabLabelResponse{}, fmt.Errorf("%w: a tab has at most %d labels", schema.ErrInvalidTabLabel, schema.MaxTabLabels)
+	case !found:
+		labels = slices.Insert(slices.Clone(labels), index, label)
+	}
+	ref.tab.labels = labels
+	active := activeTabFromContext(ctx, state)
+	event := s.tabEventLocked(ref, schema.TabEventUpdated, active)
+	snapshot := s.snapshotRef(ref, req.TabID == active)
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, ref.owner)
+	log.Info("service label updated", "label", label, "remove", req.Remove, "labels", len(labels))
+	return schema.SetTabLabelResponse{Tab: snapshot}, nil
+}
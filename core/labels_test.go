@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestSetTabLabel(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	deps := ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: &fileRunner{}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+
+	if _, err := svc.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: user, TabID: tabID, Label: "has space"}); !errors.Is(err, schema.ErrInvalidTabLabel) {
+		t.Fatalf("expected malformed label to be rejected, got %v", err)
+	}
+	for _, label := range []schema.TabLabel{"#Backend", "api", "backend"} {
+		if _, err := svc.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: user, TabID: tabID, Label: label}); err != nil {
+			t.Fatalf("add %s: %v", label, err)
+		}
+	}
+	list, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list tabs: %v", err)
+	}
+	if got := list.Tabs[0].Labels; !slices.Equal(got, []schema.TabLabel{"api", "backend"}) {
+		t.Fatalf("expected sorted labels without duplicates, got %v", got)
+	}
+	for i := range schema.MaxTabLabels - 2 {
+		if _, err := svc.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: user, TabID: tabID, Label: schema.TabLabel(fmt.Sprintf("l%d", i))}); err != nil {
+			t.Fatalf("add label %d: %v", i, err)
+		}
+	}
+	if _, err := svc.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: user, TabID: tabID, Label: "extra"}); !errors.Is(err, schema.ErrInvalidTabLabel) {
+		t.Fatalf("expected labels beyond the maximum to be rejected, got %v", err)
+	}
+	removed, err := svc.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: user, TabID: tabID, Label: "api", Remove: true})
+	if err != nil {
+		t.Fatalf("remove label: %v", err)
+	}
+	if slices.Contains(removed.Tab.Labels, "api") || len(removed.Tab.Labels) != schema.MaxTabLabels-1 {
+		t.Fatalf("unexpected labels after remove %v", removed.Tab.Labels)
+	}
+	if _, err := svc.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: user, TabID: tabID, Label: "api", Remove: true}); !errors.Is(err, schema.ErrInvalidTabLabel) {
+		t.Fatalf("expected removing a missing label to fail, got %v", err)
+	}
+
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	list, err = reloaded.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list reloaded tabs: %v", err)
+	}
+	if got := list.Tabs[0].Labels; !slices.Equal(got, removed.Tab.Labels) {
+		t.Fatalf("expected labels to survive a restart, got %v want %v", got, removed.Tab.Labels)
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// runnerErrorLine renders a runner failure as a single buffer line, with its
+// hints appended after the error.
+func runnerErrorLine(err error) string {
+	line, hints := runnerErrorLines(err)
+	if len(hints) == 0 {
+		return line
+	}
+	return line + " (" + strings.Join(hints, "; ") + ")"
+}
+
+// setLastError records err as the tab's last runner failure, or clears it
+// when err is nil, and emits a tab update when it changes. The error is not
+// persisted.
+func (s *service) setLastError(userID schema.UserID, tabID schema.TabID, err error) {
+	var lastError *schema.TabError
+	if err != nil {
+		line, _ := runnerErrorLines(err)
+		lastError = &schema.TabError{
+			Kind:    schema.ErrorCode(err),
+			Message: strings.TrimPrefix(line, "error: "),
+			Time:    time.Now(),
+		}
+	}
+	s.mu.Lock()
+	state := s.userTabs[userID]
+	if state == nil {
+		s.mu.Unlock()
+		return
+	}
+	tab := state.tabs[tabID]
+	if tab == nil || (tab.lastError == nil && lastError == nil) {
+		s.mu.Unlock()
+		return
+	}
+	tab.lastError = lastError
+	event := schema.TabEvent{
+		UserID: userID,
+		Type:   schema.TabEventUpdated,
+		Tab:    s.snapshotTab(userID, tab, false),
+	}
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+}
+
+func cloneTabError(e *schema.TabError) *schema.TabError {
+	if e == nil {
+		return nil
+	}
+	clone := *e
+	return &clone
+}
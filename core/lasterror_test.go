@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestSendPromptSetsAndClearsLastError(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	runners := &fakeRunnerProvider{
+		err:    NewRunnerError(RunnerErrorUnavailable, "exec", errors.New("connection refused")),
+		runner: eventRunner{events: progressEvents},
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: runners,
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	lastError := func() *schema.TabError {
+		t.Helper()
+		resp, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+		if err != nil {
+			t.Fatalf("list tabs: %v", err)
+		}
+		for _, tab := range resp.Tabs {
+			if tab.ID == tabID {
+				return tab.LastError
+			}
+		}
+		t.Fatalf("tab %s not listed", tabID)
+		return nil
+	}
+
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err == nil {
+		t.Fatal("expected send prompt to fail")
+	}
+	got := lastError()
+	if got == nil || got.Kind != schema.CodeRunnerUnavailable || got.Message != "runner unavailable" || got.Time.IsZero() {
+		t.Fatalf("expected the runner failure on the tab, got %+v", got)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	var errorLines []string
+	for _, line := range buf.Buffer.Lines {
+		if strings.Contains(line, "runner") {
+			errorLines = append(errorLines, line)
+		}
+	}
+	if len(errorLines) != 1 || !strings.Contains(errorLines[0], "hint: check that the runner container is running") {
+		t.Fatalf("expected one error line with its hint, got %q", errorLines)
+	}
+
+	runners.err = nil
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "again"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	if got := lastError(); got != nil {
+		t.Fatalf("expected a started prompt to clear the error, got %+v", got)
+	}
+	waitForTabIdle(t, svc, user, tabID)
+}
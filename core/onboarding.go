@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/userhome"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// onboarding is a new user's progress through the first-run checklist. The
+// checklist is not stored in the system buffer: GetSystemBuffer renders it
+// below the stored lines from this state, so finished steps show their
+// checkmark on the next read.
+type onboarding struct {
+	done []schema.OnboardingStep
+}
+
+func (o *onboarding) has(step schema.OnboardingStep) bool {
+	return slices.Contains(o.done, step)
+}
+
+func importOnboarding(snap *persist.Onboarding) *onboarding {
+	if snap == nil {
+		return nil
+	}
+	return &onboarding{done: slices.Clone(snap.Done)}
+}
+
+func (o *onboarding) export() *persist.Onboarding {
+	if o == nil {
+		return nil
+	}
+	return &persist.Onboarding{Done: slices.Clone(o.done)}
+}
+
+// onboardingCompleteLine is appended to the system buffer when the last
+// checklist step is done.
+const onboardingCompleteLine = "all set: every getting started step is done; /help lists the commands"
+
+// onboardingStepText describes each checklist step with the command that
+// does it.
+var onboardingStepText = map[schema.OnboardingStep]string{
+	schema.OnboardingCodexAuth: "set up codex auth → /codexauth",
+	schema.OnboardingPubKey:    "add your git key to your git host → /pubkey",
+	schema.OnboardingFirstTab:  "open your first repo → /new <repo|git-url>",
+}
+
+// onboardingLines renders the checklist for the steps in done.
+func onboardingLines(done []schema.OnboardingStep) []schema.BufferLine {
+	lines := []schema.BufferLine{
+		schema.Line(schema.LineKindSeparator, fmt.Sprintf("Getting started (%d/%d)", len(done), len(schema.OnboardingSteps))),
+	}
+	for _, step := range schema.OnboardingSteps {
+		mark := "[ ]"
+		if slices.Contains(done, step) {
+			mark = "[✓]"
+		}
+		lines = append(lines, schema.Line(schema.LineKindSystem, mark+" "+onboardingStepText[step]))
+	}
+	return append(lines, schema.Line(schema.LineKindSystem, "/onboarding off hides this checklist"))
+}
+
+// onboardingStatusLocked reports the user's checklist progress.
+func (s *service) onboardingStatusLocked(state *userState) schema.OnboardingStatus {
+	if s.cfg.DisableOnboarding || state.onboarding == nil {
+		return schema.OnboardingStatus{}
+	}
+	return schema.OnboardingStatus{Active: true, Done: slices.Clone(state.onboarding.done)}
+}
+
+// completeOnboarding marks steps done for a user still onboarding. The
+// last step ends onboarding with a line in the system buffer.
+func (s *service) completeOnboarding(log pslog.Logger, userID schema.UserID, steps ...schema.OnboardingStep) {
+	s.mu.Lock()
+	state := s.userTabs[userID]
+	if s.cfg.DisableOnboarding || state == nil || state.onboarding == nil {
+		s.mu.Unlock()
+		return
+	}
+	changed := false
+	for _, step := range steps {
+		if !state.onboarding.has(step) {
+			state.onboarding.done = append(state.onboarding.done, step)
+			changed = true
+		}
+	}
+	finished := len(state.onboarding.done) >= len(schema.OnboardingSteps)
+	if finished {
+		state.onboarding = nil
+	}
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+	log.Info("service onboarding step done", "steps", steps, "finished", finished)
+	if finished {
+		s.appendSystemLines(log, userID, []schema.BufferLine{schema.Line(schema.LineKindSystem, onboardingCompleteLine)})
+		return
+	}
+	s.persistUser(log, userID)
+}
+
+// detectOnboarding marks the steps a user has done outside the commands
+// that report them, such as an auth.json an administrator put in place.
+func (s *service) detectOnboarding(log pslog.Logger, userID schema.UserID) {
+	s.mu.Lock()
+	state := s.userTabs[userID]
+	if s.cfg.DisableOnboarding || state == nil || state.onboarding == nil {
+		s.mu.Unlock()
+		return
+	}
+	checkAuth := !state.onboarding.has(schema.OnboardingCodexAuth)
+	var steps []schema.OnboardingStep
+	if len(state.tabs) > 0 {
+		steps = append(steps, schema.OnboardingFirstTab)
+	}
+	s.mu.Unlock()
+	if stateDir := strings.TrimSpace(s.cfg.StateDir); checkAuth && stateDir != "" {
+		if _, err := os.Stat(userhome.AuthPath(stateDir, string(userID))); err == nil {
+			steps = append(steps, schema.OnboardingCodexAuth)
+		}
+	}
+	if len(steps) > 0 {
+		s.completeOnboarding(log, userID, steps...)
+	}
+}
+
+// UpdateOnboarding marks a checklist step done or turns the checklist off
+// for the user.
+func (s *service) UpdateOnboarding(ctx context.Context, req schema.UpdateOnboardingRequest) (schema.UpdateOnboardingResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.UpdateOnboardingResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	if req.Complete != "" {
+		if _, ok := onboardingStepText[req.Complete]; !ok {
+			return schema.UpdateOnboardingResponse{}, fmt.Errorf("%w: unknown onboarding step %q", schema.ErrInvalidRequest, req.Complete)
+		}
+	}
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	s.mu.Unlock()
+	if req.Complete != "" {
+		s.completeOnboarding(log, userID, req.Complete)
+	}
+	if req.Off {
+		s.mu.Lock()
+		wasOn := state.onboarding != nil
+		state.onboarding = nil
+		s.mu.Unlock()
+		if wasOn {
+			s.persistUser(log, userID)
+			log.Info("service onboarding turned off")
+		}
+	}
+	s.mu.Lock()
+	status := s.onboardingStatusLocked(state)
+	s.mu.Unlock()
+	return schema.UpdateOnboardingResponse{Onboarding: status}, nil
+}
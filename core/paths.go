@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -9,22 +10,57 @@ import (
 	"pkt.systems/centaurx/schema"
 )
 
-// MapRepoPath maps a host repo path to a runner repo root.
-func MapRepoPath(hostRoot, runnerRoot, hostPath string) (string, error) {
+// PathMapping maps a host directory to the path it is mounted at inside a
+// runner.
+type PathMapping struct {
+	Host     string
+	Runner   string
+	ReadOnly bool
+}
+
+// MapRepoPath maps a host repo path to the path the runner sees. The mount
+// of info.Mounts with the longest host prefix of hostPath wins; paths outside
+// every mount fall back to mapping hostRoot onto info.RepoRoot. Runners
+// without a RepoRoot of their own share the host paths. Paths covered by
+// neither fail with an error naming runner.extra_mounts.
+func MapRepoPath(hostRoot string, info RunnerInfo, hostPath string) (string, error) {
+	best := -1
+	var bestRel string
+	for i, mount := range info.Mounts {
+		rel, ok := pathWithin(mount.Host, hostPath)
+		if !ok {
+			continue
+		}
+		if best < 0 || len(filepath.Clean(mount.Host)) > len(filepath.Clean(info.Mounts[best].Host)) {
+			best = i
+			bestRel = rel
+		}
+	}
+	if best >= 0 {
+		return filepath.Join(info.Mounts[best].Runner, bestRel), nil
+	}
+	runnerRoot := info.RepoRoot
 	if strings.TrimSpace(runnerRoot) == "" || runnerRoot == hostRoot {
 		return hostPath, nil
 	}
-	rel, err := filepath.Rel(hostRoot, hostPath)
-	if err != nil {
-		return "", err
+	rel, ok := pathWithin(hostRoot, hostPath)
+	if !ok {
+		return "", fmt.Errorf("path %s is not mounted into the runner; add it to runner.extra_mounts: %w", hostPath, schema.ErrInvalidRepo)
 	}
-	if rel == "." {
-		return runnerRoot, nil
+	return filepath.Join(runnerRoot, rel), nil
+}
+
+// pathWithin returns the path of p relative to root when p is root or lies
+// below it.
+func pathWithin(root, p string) (string, bool) {
+	if strings.TrimSpace(root) == "" {
+		return "", false
 	}
-	if strings.HasPrefix(rel, "..") {
-		return "", schema.ErrInvalidRepo
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
-	return filepath.Join(runnerRoot, rel), nil
+	return rel, true
 }
 
 // RepoPath builds a repo path using the configured root and user/repo identity.
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"pkt.systems/centaurx/schema"
@@ -47,13 +49,14 @@ func TestRepoRefForUser(t *testing.T) {
 func TestMapRepoPath(t *testing.T) {
 	root := filepath.Join("/repos", "alice")
 	path := filepath.Join(root, "demo")
-	if got, err := MapRepoPath(root, "", path); err != nil || got != path {
+	if got, err := MapRepoPath(root, RunnerInfo{}, path); err != nil || got != path {
 		t.Fatalf("expected passthrough mapping, got %q (%v)", got, err)
 	}
-	if got, err := MapRepoPath(root, root, path); err != nil || got != path {
+	if got, err := MapRepoPath(root, RunnerInfo{RepoRoot: root}, path); err != nil || got != path {
 		t.Fatalf("expected passthrough mapping, got %q (%v)", got, err)
 	}
-	mapped, err := MapRepoPath(root, "/container/repos", path)
+	info := RunnerInfo{RepoRoot: "/container/repos"}
+	mapped, err := MapRepoPath(root, info, path)
 	if err != nil {
 		t.Fatalf("map: %v", err)
 	}
@@ -61,10 +64,46 @@ func TestMapRepoPath(t *testing.T) {
 	if mapped != want {
 		t.Fatalf("expected %q, got %q", want, mapped)
 	}
-	if _, err := MapRepoPath(root, "/container/repos", "/other/demo"); err != schema.ErrInvalidRepo {
-		t.Fatalf("expected invalid repo error, got %v", err)
+	if _, err := MapRepoPath(root, info, "/other/demo"); !errors.Is(err, schema.ErrInvalidRepo) || !strings.Contains(err.Error(), "path /other/demo is not mounted into the runner; add it to runner.extra_mounts") {
+		t.Fatalf("expected a not mounted error, got %v", err)
 	}
-	if mapped, err := MapRepoPath(root, "/container/repos", root); err != nil || mapped != "/container/repos" {
+	if _, err := MapRepoPath(root, info, "/repos/alice2/demo"); err == nil {
+		t.Fatal("expected a sibling with a common prefix to be outside the root")
+	}
+	if mapped, err := MapRepoPath(root, info, root); err != nil || mapped != "/container/repos" {
 		t.Fatalf("expected root mapping, got %q (%v)", mapped, err)
 	}
 }
+
+func TestMapRepoPathMounts(t *testing.T) {
+	info := RunnerInfo{
+		RepoRoot: "/repos",
+		Mounts: []PathMapping{
+			{Host: "/srv/repos/alice", Runner: "/repos/alice"},
+			{Host: "/data", Runner: "/mnt/data"},
+			{Host: "/data/projects/shared", Runner: "/shared"},
+			{Host: "/data/projects", Runner: "/projects"},
+		},
+	}
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/srv/repos/alice/demo", "/repos/alice/demo"},
+		{"/data/notes", "/mnt/data/notes"},
+		{"/data/projects", "/projects"},
+		{"/data/projects/api", "/projects/api"},
+		{"/data/projects/shared/lib", "/shared/lib"},
+		{"/data/projects/shared-old", "/projects/shared-old"},
+		// Outside every mount, the repo root mapping still applies.
+		{"/srv/repos/bob/demo", "/repos/bob/demo"},
+	} {
+		got, err := MapRepoPath("/srv/repos", info, tc.path)
+		if err != nil || got != tc.want {
+			t.Fatalf("%s: expected %q, got %q (%v)", tc.path, tc.want, got, err)
+		}
+	}
+	if _, err := MapRepoPath("/srv/repos", info, "/home/alice/src/demo"); err == nil || !strings.Contains(err.Error(), "add it to runner.extra_mounts") {
+		t.Fatalf("expected an unmounted path to fail, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// progressHint returns what a running tab is doing after event, given the
+// current hint. Started and updated items set it; completed items and the
+// end of a turn clear it.
+func progressHint(event schema.ExecEvent, current string) string {
+	switch event.Type {
+	case schema.EventItemCompleted, schema.EventTurnCompleted, schema.EventTurnFailed:
+		return ""
+	case schema.EventItemStarted, schema.EventItemUpdated:
+	default:
+		return current
+	}
+	if event.Item == nil {
+		return current
+	}
+	switch event.Item.Type {
+	case schema.ItemReasoning:
+		return "thinking…"
+	case schema.ItemCommandExecution:
+		if command := firstLine(event.Item.Command); command != "" {
+			return "running: " + command
+		}
+		return "running a command"
+	case schema.ItemFileChange:
+		for _, change := range event.Item.Changes {
+			if change.Path != "" {
+				return "editing: " + change.Path
+			}
+		}
+		return "editing files"
+	case schema.ItemWebSearch:
+		if query := firstLine(event.Item.Query); query != "" {
+			return "searching: " + query
+		}
+		return "searching the web"
+	case schema.ItemMcpToolCall:
+		return "calling a tool"
+	}
+	return current
+}
+
+func firstLine(value string) string {
+	value = strings.TrimSpace(value)
+	if idx := strings.IndexByte(value, '\n'); idx > -1 {
+		value = strings.TrimSpace(value[:idx]) + " …"
+	}
+	return value
+}
+
+// updateProgress applies event to the progress hint of a running tab and
+// emits a tab update when the hint changes. The hint is not persisted.
+func (s *service) updateProgress(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle RunHandle, event schema.ExecEvent) {
+	s.mu.Lock()
+	state := s.userTabs[userID]
+	if state == nil {
+		s.mu.Unlock()
+		return
+	}
+	tab := state.tabs[tabID]
+	if tab == nil || tab.Run != handle {
+		s.mu.Unlock()
+		return
+	}
+	hint := progressHint(event, tab.progress)
+	if hint == tab.progress {
+		s.mu.Unlock()
+		return
+	}
+	tab.progress = hint
+	active := activeTabFromContext(ctx, state)
+	tabEvent := schema.TabEvent{
+		UserID:    userID,
+		Type:      schema.TabEventUpdated,
+		Tab:       s.snapshotTab(userID, tab, tabID == active),
+		ActiveTab: active,
+	}
+	s.mu.Unlock()
+	s.emitTabEvent(tabEvent)
+}
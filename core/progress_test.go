@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// progressEvents is a scripted turn: reasoning, a command, a file change
+// and the final answer.
+var progressEvents = []schema.ExecEvent{
+	{Type: schema.EventTurnStarted},
+	{Type: schema.EventItemStarted, Item: &schema.ItemEvent{ID: "r1", Type: schema.ItemReasoning}},
+	{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "r1", Type: schema.ItemReasoning, Text: "plan"}},
+	{Type: schema.EventItemStarted, Item: &schema.ItemEvent{ID: "c1", Type: schema.ItemCommandExecution, Command: "go test ./...\necho done"}},
+	{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "c1", Type: schema.ItemCommandExecution, Command: "go test ./..."}},
+	{Type: schema.EventItemStarted, Item: &schema.ItemEvent{ID: "f1", Type: schema.ItemFileChange, Changes: []schema.FileChange{{Path: "main.go", Kind: "update"}}}},
+	{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "done"}},
+	{Type: schema.EventTurnCompleted},
+}
+
+func TestProgressHintFollowsEvents(t *testing.T) {
+	want := []string{"", "thinking…", "", "running: go test ./... …", "", "editing: main.go", "", ""}
+	hint := ""
+	for i, event := range progressEvents {
+		hint = progressHint(event, hint)
+		if hint != want[i] {
+			t.Fatalf("event %d (%s): expected %q, got %q", i, event.Type, want[i], hint)
+		}
+	}
+	if got := progressHint(schema.ExecEvent{Type: schema.EventItemUpdated, Item: &schema.ItemEvent{Type: schema.ItemTodoList}}, "thinking…"); got != "thinking…" {
+		t.Fatalf("expected other items to keep the hint, got %q", got)
+	}
+}
+
+func TestSendPromptEmitsProgress(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	sink := &slowSink{}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: progressEvents}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		EventSink:      sink,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+	flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := svc.(EventDispatcher).FlushEvents(flushCtx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	sink.mu.Lock()
+	var hints []string
+	var last schema.TabEvent
+	for _, event := range sink.tabs {
+		if event.Type == schema.TabEventUpdated {
+			hints = append(hints, event.Tab.Progress)
+		}
+		last = event
+	}
+	sink.mu.Unlock()
+	want := []string{"thinking…", "", "running: go test ./... …", "", "editing: main.go", ""}
+	if !slices.Equal(hints, want) {
+		t.Fatalf("expected progress updates %q, got %q", want, hints)
+	}
+	if last.Type != schema.TabEventStatus || last.Tab.Status != schema.TabStatusIdle || last.Tab.Progress != "" {
+		t.Fatalf("expected an idle status without progress last, got %+v", last)
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// checkPromptSize refuses prompts larger than PromptMaxBytes.
+func (s *service) checkPromptSize(prompt string) error {
+	limit := s.cfg.PromptMaxBytes
+	if limit <= 0 {
+		limit = schema.DefaultPromptMaxBytes
+	}
+	if len(prompt) > limit {
+		return fmt.Errorf("%w: %s exceeds the %s limit; attach a file or trim the prompt", schema.ErrPromptTooLarge, formatPromptSize(len(prompt)), formatPromptSize(limit))
+	}
+	return nil
+}
+
+// promptSizeWarning returns the warning shown for prompts larger than
+// PromptWarnBytes, or "" for smaller ones.
+func (s *service) promptSizeWarning(prompt string) string {
+	threshold := s.cfg.PromptWarnBytes
+	if threshold <= 0 {
+		threshold = schema.DefaultPromptWarnBytes
+	}
+	if len(prompt) <= threshold {
+		return ""
+	}
+	return fmt.Sprintf("⚠ large prompt: %s — consider attaching a file or trimming", formatPromptSize(len(prompt)))
+}
+
+// formatPromptSize formats n bytes as "180KB" or "2.0MB".
+func formatPromptSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
@@ -1,8 +1,101 @@
 package core
 
-import "pkt.systems/centaurx/schema"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
 
 // Renderer formats normalized events into display lines for a transport.
+// Lines are stored in buffers and shown at any width, so they must not be
+// wrapped or padded for one; alignment is expressed with schema markers.
 type Renderer interface {
 	FormatEvent(event schema.ExecEvent) ([]string, error)
 }
+
+const (
+	// unrenderedMaxLines and unrenderedMaxBytes bound the raw content
+	// shown for an event the renderer failed on.
+	unrenderedMaxLines = 40
+	unrenderedMaxBytes = 4096
+)
+
+// formatEvent calls renderer.FormatEvent and turns a panic into an error,
+// so a renderer bug cannot stop the goroutine consuming run events.
+func formatEvent(renderer Renderer, event schema.ExecEvent) (lines []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			lines, err = nil, fmt.Errorf("renderer panic: %v", r)
+		}
+	}()
+	return renderer.FormatEvent(event)
+}
+
+// unrenderedEventLines describes an event the renderer failed on: an error
+// line followed by the event's text, command output or, for other events,
+// its JSON, truncated and marked "(unrendered)". What codex said is kept
+// even when it cannot be formatted.
+func unrenderedEventLines(event schema.ExecEvent, err error) []schema.BufferLine {
+	lines := []schema.BufferLine{schema.Line(schema.LineKindError, fmt.Sprintf("render error: %v", err))}
+	kind, content := unrenderedContent(event)
+	content = strings.TrimRight(content, "\n")
+	if strings.TrimSpace(content) == "" {
+		return lines
+	}
+	truncated := false
+	if len(content) > unrenderedMaxBytes {
+		content = strings.ToValidUTF8(content[:unrenderedMaxBytes], "")
+		truncated = true
+	}
+	parts := strings.Split(content, "\n")
+	if len(parts) > unrenderedMaxLines {
+		parts = parts[:unrenderedMaxLines]
+		truncated = true
+	}
+	parts[0] = "(unrendered) " + parts[0]
+	for _, part := range parts {
+		lines = append(lines, schema.Line(kind, part))
+	}
+	if truncated {
+		lines = append(lines, schema.Line(schema.LineKindSystem, "(unrendered output truncated)"))
+	}
+	return lines
+}
+
+// unrenderedContent returns the salient content of event and the line kind
+// to show it as.
+func unrenderedContent(event schema.ExecEvent) (schema.LineKind, string) {
+	if item := event.Item; item != nil {
+		switch item.Type {
+		case schema.ItemAgentMessage:
+			return schema.LineKindAgent, item.Text
+		case schema.ItemReasoning:
+			return schema.LineKindReasoning, item.Text
+		case schema.ItemCommandExecution:
+			content := item.Command
+			if item.AggregatedOutput != "" {
+				content = strings.TrimSpace(content + "\n" + item.AggregatedOutput)
+			}
+			return schema.LineKindCommand, content
+		}
+		if item.Text != "" {
+			return schema.LineKindSystem, item.Text
+		}
+	}
+	if event.Error != nil && event.Error.Message != "" {
+		return schema.LineKindError, event.Error.Message
+	}
+	if event.Message != "" {
+		return schema.LineKindSystem, event.Message
+	}
+	if len(event.Raw) > 0 {
+		return schema.LineKindSystem, string(event.Raw)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return schema.LineKindSystem, ""
+	}
+	return schema.LineKindSystem, string(data)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"pkt.systems/centaurx/internal/logx"
 	"pkt.systems/centaurx/internal/repo"
@@ -15,20 +16,40 @@ import (
 	"pkt.systems/pslog"
 )
 
+// gitHostProbeTimeout bounds the reachability probe run before a clone.
+const gitHostProbeTimeout = 15 * time.Second
+
+// RepoResolverOption configures a runner repo resolver.
+type RepoResolverOption func(*runnerRepoResolver)
+
+// WithSkipPreflight disables the git host reachability probe before clones.
+func WithSkipPreflight(skip bool) RepoResolverOption {
+	return func(r *runnerRepoResolver) {
+		r.skipPreflight = skip
+	}
+}
+
 // NewRunnerRepoResolver returns a resolver that performs git operations via the runner.
-func NewRunnerRepoResolver(root string, runners RunnerProvider) (RepoResolver, error) {
+func NewRunnerRepoResolver(root string, runners RunnerProvider, opts ...RepoResolverOption) (RepoResolver, error) {
 	if strings.TrimSpace(root) == "" {
 		return nil, schema.ErrInvalidRepo
 	}
 	if runners == nil {
 		return nil, errors.New("runner provider is required")
 	}
-	return &runnerRepoResolver{root: root, runners: runners}, nil
+	r := &runnerRepoResolver{root: root, runners: runners}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	return r, nil
 }
 
 type runnerRepoResolver struct {
-	root    string
-	runners RunnerProvider
+	root          string
+	runners       RunnerProvider
+	skipPreflight bool
 }
 
 func (r *runnerRepoResolver) CreateRepo(ctx context.Context, req CreateRepoRequest) (CreateRepoResponse, error) {
@@ -59,7 +80,7 @@ func (r *runnerRepoResolver) CreateRepo(ctx context.Context, req CreateRepoReque
 	}
 	runner := runnerResp.Runner
 	info := runnerResp.Info
-	workingDir, repoPath, err := r.mapRepoPath(manager.Root(), path, info.RepoRoot)
+	workingDir, repoPath, err := r.mapRepoPath(manager.Root(), path, info)
 	if err != nil {
 		log.Warn("repo create map failed", "err", err)
 		return CreateRepoResponse{}, err
@@ -145,11 +166,16 @@ func (r *runnerRepoResolver) OpenOrCloneURL(ctx context.Context, req OpenOrClone
 	}
 	runner := runnerResp.Runner
 	info := runnerResp.Info
-	workingDir, repoPath, err := r.mapRepoPath(manager.Root(), path, info.RepoRoot)
+	workingDir, repoPath, err := r.mapRepoPath(manager.Root(), path, info)
 	if err != nil {
 		log.Warn("repo clone map failed", "err", err)
 		return OpenOrCloneResponse{}, err
 	}
+	if !r.skipPreflight {
+		if err := r.probeGitHost(ctx, log, runner, workingDir, info.SSHAuthSock, cloneURL); err != nil {
+			return OpenOrCloneResponse{}, err
+		}
+	}
 	cmd := fmt.Sprintf("git clone %s %s", cloneURL, repoPath)
 	if err := r.runCommand(ctx, log, runner, coreRunCommand(workingDir, info.SSHAuthSock, cmd, false)); err != nil {
 		return OpenOrCloneResponse{}, err
@@ -158,20 +184,89 @@ func (r *runnerRepoResolver) OpenOrCloneURL(ctx context.Context, req OpenOrClone
 	return OpenOrCloneResponse{Repo: schema.RepoRef{Name: repoName, Path: path}, Created: true}, nil
 }
 
+// probeGitHost opens an ssh connection to the git host from the runner so
+// an unreachable host fails in seconds rather than when git times out. The
+// probe uses GIT_SSH_COMMAND, so it goes through the same proxy and agent
+// as the clone. An authentication or host key failure means the host
+// answered and is left for git to report. Probe output only goes to the
+// log; it never reaches the buffer.
+func (r *runnerRepoResolver) probeGitHost(ctx context.Context, log pslog.Logger, runner Runner, workingDir, sshAuthSock, cloneURL string) error {
+	remote, err := repo.ParseGitRemote(cloneURL)
+	if err != nil {
+		return err
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, gitHostProbeTimeout)
+	defer cancel()
+	cmd := fmt.Sprintf("${GIT_SSH_COMMAND:-ssh} -o BatchMode=yes -o ConnectTimeout=5 -p %s -T %s exit 2>&1", shellQuote(remote.Port), shellQuote(remote.Destination()))
+	exitCode, output, err := r.runCommandOutput(probeCtx, runner, coreRunCommand(workingDir, sshAuthSock, cmd, true))
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == nil && gitHostAnswered(exitCode, output) {
+		log.Debug("git host probe ok", "host", remote.Address(), "exit_code", exitCode)
+		return nil
+	}
+	log.Warn("git host probe failed", "host", remote.Address(), "exit_code", exitCode, "err", err, "output", strings.Join(output, "\n"))
+	return fmt.Errorf("%w %s from the runner — check network policy", schema.ErrGitHostUnreachable, remote.Address())
+}
+
+// gitHostAnswered reports whether an ssh probe reached the host. ssh exits
+// with 255 on connection errors, but also when authentication fails.
+func gitHostAnswered(exitCode int, output []string) bool {
+	if exitCode != 255 {
+		return true
+	}
+	for _, line := range output {
+		if strings.Contains(line, "Permission denied") ||
+			strings.Contains(line, "Host key verification failed") ||
+			strings.Contains(line, "REMOTE HOST IDENTIFICATION HAS CHANGED") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *runnerRepoResolver) runCommandOutput(ctx context.Context, runner Runner, req RunCommandRequest) (int, []string, error) {
+	handle, err := runner.RunCommand(ctx, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = handle.Close() }()
+	stream := handle.Outputs()
+	output := make([]string, 0, 8)
+	for {
+		line, err := stream.Next(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, output, err
+		}
+		if line.Text != "" {
+			output = append(output, line.Text)
+		}
+	}
+	result, err := handle.Wait(ctx)
+	if err != nil {
+		return 0, output, err
+	}
+	return result.ExitCode, output, nil
+}
+
 func (r *runnerRepoResolver) manager(ctx context.Context, userID schema.UserID) (*repo.Manager, error) {
 	path := filepath.Join(r.root, string(userID))
 	return repo.NewManagerWithLogger(path, logx.WithUser(ctx, userID))
 }
 
-func (r *runnerRepoResolver) mapRepoPath(root, path, runnerRoot string) (string, string, error) {
+func (r *runnerRepoResolver) mapRepoPath(root, path string, info RunnerInfo) (string, string, error) {
 	workingDir := root
 	repoPath := path
-	if runnerRoot != "" {
-		mappedRoot, err := MapRepoPath(r.root, runnerRoot, root)
+	if info.RepoRoot != "" {
+		mappedRoot, err := MapRepoPath(r.root, info, root)
 		if err != nil {
 			return "", "", err
 		}
-		mappedPath, err := MapRepoPath(r.root, runnerRoot, path)
+		mappedPath, err := MapRepoPath(r.root, info, path)
 		if err != nil {
 			return "", "", err
 		}
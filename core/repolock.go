@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// repoRun is the tab running codex in a repo, and the prompts queued behind
+// it under ConcurrentRunsQueue. Locks are advisory: shell commands do not
+// take them.
+type repoRun struct {
+	tabID   schema.TabID
+	tabName schema.TabName
+	waiting []queuedPrompt
+}
+
+// queuedPrompt is a prompt waiting for the run lock of its repo.
+type queuedPrompt struct {
+	ctx context.Context
+	req schema.SendPromptRequest
+}
+
+// repoRunKey returns the lock key of an owner's repo, its host path.
+func (s *service) repoRunKey(owner schema.UserID, name schema.RepoName) string {
+	path, err := s.repoPath(owner, name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// acquireRepoRunLocked takes the run lock of the repo at key for tab and
+// returns nil, or returns the run holding it for another tab.
+func (s *service) acquireRepoRunLocked(key string, tab *tab) *repoRun {
+	if key == "" {
+		return nil
+	}
+	if held := s.repoRuns[key]; held != nil {
+		if held.tabID == tab.ID {
+			return nil
+		}
+		return held
+	}
+	s.repoRuns[key] = &repoRun{tabID: tab.ID, tabName: tab.Name}
+	return nil
+}
+
+// queuedLocked reports whether a prompt of tabID waits behind run.
+func (run *repoRun) queuedLocked(tabID schema.TabID) bool {
+	for _, queued := range run.waiting {
+		if queued.req.TabID == tabID {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseRepoRun drops the run lock tabID holds on the repo at key. The
+// first queued prompt inherits the lock, with the rest behind it, and is
+// started. Releasing a lock the tab does not hold is a no-op, so every exit
+// path may call it.
+func (s *service) releaseRepoRun(key string, tabID schema.TabID) {
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	held := s.repoRuns[key]
+	if held == nil || held.tabID != tabID {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.repoRuns, key)
+	if len(held.waiting) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	next := held.waiting[0]
+	s.repoRuns[key] = &repoRun{tabID: next.req.TabID, tabName: s.queuedTabNameLocked(next.req), waiting: held.waiting[1:]}
+	s.mu.Unlock()
+	go s.startQueuedPrompt(key, next)
+}
+
+// queuedTabNameLocked returns the name of the tab a queued prompt was sent
+// to, for the busy error of later prompts.
+func (s *service) queuedTabNameLocked(req schema.SendPromptRequest) schema.TabName {
+	if ref, err := s.lookupTabLocked(req.UserID, req.TabID, schema.ShareAccessReadWrite); err == nil {
+		return ref.tab.Name
+	}
+	return schema.TabName(req.TabID)
+}
+
+// startQueuedPrompt sends a prompt that waited for the run lock at key. A
+// prompt that fails to start, for example because its tab was closed,
+// passes the lock on.
+func (s *service) startQueuedPrompt(key string, queued queuedPrompt) {
+	if _, err := s.SendPrompt(queued.ctx, queued.req); err != nil {
+		logx.WithUserTab(queued.ctx, queued.req.UserID, queued.req.TabID).Warn("service queued prompt failed", "err", err)
+		s.releaseRepoRun(key, queued.req.TabID)
+	}
+}
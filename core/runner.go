@@ -14,13 +14,24 @@ type Runner interface {
 
 // RunRequest describes a codex exec invocation.
 type RunRequest struct {
-	WorkingDir           string
+	// RunID identifies the prompt run in runner logs; runners generate one
+	// when it is empty.
+	RunID      schema.RunID
+	WorkingDir string
+	// Prompt is written to codex exec on stdin, never passed as an
+	// argument, so its size is bounded by prompts.max_bytes, not ARG_MAX.
 	Prompt               string
 	Model                schema.ModelID
 	ModelReasoningEffort schema.ModelReasoningEffort
 	ResumeSessionID      schema.SessionID
 	JSON                 bool
 	SSHAuthSock          string
+	// ExtraArgs are appended to the codex exec flags after the ones the
+	// runner sets itself.
+	ExtraArgs []string
+	// EnableWebSearch turns codex web search on or off; nil leaves the
+	// codex default.
+	EnableWebSearch *bool
 }
 
 // RunHandle exposes the event stream and process lifecycle controls.
@@ -31,6 +42,16 @@ type RunHandle interface {
 	Close() error
 }
 
+// RunReattacher is implemented by run handles that can re-attach to their
+// run after its event stream failed, such as when the connection to the
+// runner was reset. Events then continues after the last event the failed
+// stream delivered.
+type RunReattacher interface {
+	// Reattach opens a new event stream for the run. It fails when the
+	// runner no longer knows the run or has dropped events since the break.
+	Reattach(ctx context.Context) error
+}
+
 // EventStream yields normalized events from codex exec.
 type EventStream interface {
 	Next(ctx context.Context) (schema.ExecEvent, error)
@@ -40,6 +61,14 @@ type EventStream interface {
 // RunResult describes the process outcome.
 type RunResult struct {
 	ExitCode int
+	// SavedOutput is the path, relative to the working directory, that a
+	// command's output was saved to when RunCommandRequest.SaveOutput was
+	// set, and SavedLines the number of lines written to it.
+	SavedOutput string
+	SavedLines  int
+	// OrphansKilled reports that processes a command left running in its
+	// process group after it exited had to be killed.
+	OrphansKilled bool
 }
 
 // RunCommandRequest describes an arbitrary command invocation.
@@ -48,6 +77,19 @@ type RunCommandRequest struct {
 	Command     string
 	UseShell    bool
 	SSHAuthSock string
+	// PTY runs the command on a pseudo-terminal of Rows x Cols instead of
+	// pipes. Its output arrives on the stdout stream only.
+	PTY  bool
+	Rows int
+	Cols int
+	// Stdin is written to the command's standard input, which is otherwise
+	// empty. It cannot be combined with PTY.
+	Stdin []byte
+	// SaveOutput is a path relative to WorkingDir that the runner tees the
+	// command's stdout and stderr lines into. It must stay inside
+	// WorkingDir; an existing file is never overwritten, a numeric suffix is
+	// added instead (out.txt, out-1.txt, ...).
+	SaveOutput string
 }
 
 // CommandStreamKind indicates which stream produced output.
@@ -76,6 +118,9 @@ type CommandStream interface {
 type CommandHandle interface {
 	Outputs() CommandStream
 	Signal(ctx context.Context, sig ProcessSignal) error
+	// Resize changes the terminal size of a PTY command. It is a no-op for
+	// commands started without a PTY.
+	Resize(ctx context.Context, rows, cols int) error
 	Wait(ctx context.Context) (RunResult, error)
 	Close() error
 }
@@ -90,4 +135,6 @@ const (
 	ProcessSignalTERM ProcessSignal = "TERM"
 	// ProcessSignalKILL requests an immediate kill signal.
 	ProcessSignalKILL ProcessSignal = "KILL"
+	// ProcessSignalINT requests an interrupt, as Ctrl+C on a terminal.
+	ProcessSignalINT ProcessSignal = "INT"
 )
@@ -1,6 +1,11 @@
 package core
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"pkt.systems/centaurx/schema"
+)
 
 // RunnerErrorKind classifies runner failures for user-facing hints.
 type RunnerErrorKind string
@@ -26,6 +31,10 @@ const (
 	RunnerErrorExec RunnerErrorKind = "exec"
 	// RunnerErrorCommand indicates a command request failed.
 	RunnerErrorCommand RunnerErrorKind = "command"
+	// RunnerErrorEnvironment indicates a failure in a runner container whose
+	// preflight found a broken environment, such as a skewed clock or
+	// missing CA certificates.
+	RunnerErrorEnvironment RunnerErrorKind = "environment"
 )
 
 // RunnerError wraps runner failures with a stable classification.
@@ -34,6 +43,8 @@ type RunnerError struct {
 	Op      string
 	Message string
 	Err     error
+	// Hints are shown to the user after the error line.
+	Hints []string
 }
 
 // NewRunnerError constructs a classified runner error.
@@ -63,3 +74,41 @@ func (e *RunnerError) Unwrap() error {
 	}
 	return e.Err
 }
+
+// ErrorCode maps the runner error kind to its schema error code.
+func (e *RunnerError) ErrorCode() string {
+	if e == nil {
+		return ""
+	}
+	switch e.Kind {
+	case RunnerErrorUnavailable:
+		return schema.CodeRunnerUnavailable
+	case RunnerErrorUnauthorized:
+		return schema.CodeRunnerUnauthorized
+	case RunnerErrorPermissionDenied:
+		return schema.CodePermissionDenied
+	case RunnerErrorTimeout:
+		return schema.CodeRunnerTimeout
+	case RunnerErrorCanceled:
+		return schema.CodeRunnerCanceled
+	case RunnerErrorContainerStart:
+		return schema.CodeRunnerContainerStart
+	case RunnerErrorContainerSocket:
+		return schema.CodeRunnerContainerSocket
+	case RunnerErrorExec:
+		return schema.CodeRunnerExec
+	case RunnerErrorCommand:
+		return schema.CodeRunnerCommand
+	case RunnerErrorEnvironment:
+		return schema.CodeRunnerEnvironment
+	default:
+		return schema.CodeRunnerFailed
+	}
+}
+
+// IsTransientRunnerError reports whether err is a runner failure that may
+// pass on its own, such as a reset connection or a restarting runner.
+func IsTransientRunnerError(err error) bool {
+	var runnerErr *RunnerError
+	return errors.As(err, &runnerErr) && runnerErr.Kind == RunnerErrorUnavailable
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestRunnerErrorCodes(t *testing.T) {
+	tests := []struct {
+		kind RunnerErrorKind
+		code string
+		line string
+	}{
+		{RunnerErrorUnavailable, "runner_unavailable", "error: runner unavailable"},
+		{RunnerErrorUnauthorized, "runner_unauthorized", "error: runner authentication failed"},
+		{RunnerErrorPermissionDenied, "permission_denied", "error: runner permission denied"},
+		{RunnerErrorTimeout, "runner_timeout", "error: runner timed out"},
+		{RunnerErrorCanceled, "runner_canceled", "error: runner canceled"},
+		{RunnerErrorContainerStart, "runner_container_start", "error: runner container failed to start"},
+		{RunnerErrorContainerSocket, "runner_container_socket", "error: runner socket did not become ready"},
+		{RunnerErrorExec, "runner_exec", "error: runner exec failed"},
+		{RunnerErrorCommand, "runner_command", "error: runner command failed"},
+		{RunnerErrorEnvironment, "runner_environment", "error: run: boom"},
+		{RunnerErrorUnknown, "runner_failed", "error: run: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			err := fmt.Errorf("run: %w", NewRunnerError(tt.kind, "run", errors.New("boom")))
+			if got := schema.ErrorCode(err); got != tt.code {
+				t.Fatalf("ErrorCode = %q, want %q", got, tt.code)
+			}
+			line, _ := runnerErrorLines(err)
+			if line != tt.line {
+				t.Fatalf("line = %q, want %q", line, tt.line)
+			}
+		})
+	}
+}
+
+func TestRunnerErrorLinesKeepHints(t *testing.T) {
+	_, hints := runnerErrorLines(NewRunnerError(RunnerErrorUnauthorized, "run", errors.New("401")))
+	if len(hints) == 0 || !strings.Contains(hints[0], "codex login") {
+		t.Fatalf("expected codex login hint, got %v", hints)
+	}
+	_, hints = runnerErrorLines(schema.WithCode(schema.CodeRunnerUnavailable, errors.New("runner not available")))
+	if len(hints) == 0 {
+		t.Fatalf("expected hints for coded runner_unavailable error")
+	}
+	env := NewRunnerError(RunnerErrorEnvironment, "run", errors.New("401"))
+	env.Message = "runner environment problem: runner image has no CA certificates (401)"
+	env.Hints = []string{"hint: install the ca-certificates package in the runner image"}
+	line, hints := runnerErrorLines(env)
+	if !strings.Contains(line, "no CA certificates") || len(hints) != 1 || !strings.Contains(hints[0], "ca-certificates") {
+		t.Fatalf("expected the environment problem and its hint, got %q %v", line, hints)
+	}
+}
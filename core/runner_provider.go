@@ -2,7 +2,8 @@ package core
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
 	"pkt.systems/centaurx/schema"
 )
@@ -12,6 +13,9 @@ type RunnerInfo struct {
 	RepoRoot    string
 	HomeDir     string
 	SSHAuthSock string
+	// Mounts lists the host directories mounted into the runner; see
+	// MapRepoPath.
+	Mounts []PathMapping
 }
 
 // RunnerRequest selects a runner instance.
@@ -39,6 +43,60 @@ type RunnerProvider interface {
 	CloseAll(ctx context.Context) error
 }
 
+// RunnerStatus describes a live runner.
+type RunnerStatus struct {
+	UserID schema.UserID
+	// TabID is set when runners are scoped per tab.
+	TabID schema.TabID
+	// Tabs lists the tabs using a per-user runner.
+	Tabs      []schema.TabID
+	Container string
+	Running   int
+	LastUsed  time.Time
+}
+
+// RunnerAdmin is implemented by runner providers whose runners an operator
+// can list and close.
+type RunnerAdmin interface {
+	ListRunners(ctx context.Context) ([]RunnerStatus, error)
+	// CloseUser stops every runner of a user and returns how many were
+	// stopped.
+	CloseUser(ctx context.Context, userID schema.UserID) (int, error)
+}
+
+// RunnerPreflight is the result of the environment checks run in a runner
+// container when it starts.
+type RunnerPreflight struct {
+	Container string
+	CheckedAt time.Time
+	// ClockSkew is the container clock minus the host clock; it is only
+	// meaningful when ClockChecked is set.
+	ClockSkew    time.Duration
+	ClockChecked bool
+	// CACerts reports whether /etc/ssl/certs exists in the container; it is
+	// only meaningful when CAChecked is set.
+	CACerts   bool
+	CAChecked bool
+	// Problems name what is broken, with Hints on how to fix it.
+	Problems []string
+	Hints    []string
+	// Mounts lists the directories mounted into the runner.
+	Mounts []PathMapping
+}
+
+// OK reports whether the preflight found no problems.
+func (p RunnerPreflight) OK() bool {
+	return len(p.Problems) == 0
+}
+
+// RunnerPreflighter is implemented by runner providers that check the
+// environment of their runners.
+type RunnerPreflighter interface {
+	// Preflight runs the environment checks again in the runner of a tab,
+	// starting it when needed.
+	Preflight(ctx context.Context, req RunnerRequest) (RunnerPreflight, error)
+}
+
 // StaticRunnerProvider wraps a single runner instance for all users.
 type StaticRunnerProvider struct {
 	Runner Runner
@@ -47,7 +105,7 @@ type StaticRunnerProvider struct {
 // RunnerFor returns the configured runner.
 func (p StaticRunnerProvider) RunnerFor(_ context.Context, _ RunnerRequest) (RunnerResponse, error) {
 	if p.Runner == nil {
-		return RunnerResponse{}, fmt.Errorf("runner provider has no runner")
+		return RunnerResponse{}, schema.WithCode(schema.CodeRunnerUnavailable, errors.New("runner provider has no runner"))
 	}
 	return RunnerResponse{Runner: p.Runner}, nil
 }
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// searchExcerptRunes is the length excerpts of long matching lines are cut
+// to, with searchExcerptLead runes kept before the match.
+const (
+	searchExcerptRunes = 160
+	searchExcerptLead  = 40
+)
+
+// searchTab is what SearchAll copies out of a tab under the lock, so the
+// matching itself runs without it.
+type searchTab struct {
+	id      schema.TabID
+	name    schema.TabName
+	lines   []schema.BufferLine
+	numbers []int64
+	history []schema.HistoryEntry
+}
+
+// SearchAll searches the buffers and prompt histories of all of a user's
+// tabs. Each tab has at most MaxLinesPerTab of its newest buffer lines
+// scanned; transient lines are not searched.
+func (s *service) SearchAll(ctx context.Context, req schema.SearchAllRequest) (schema.SearchAllResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SearchAllResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	re, err := compileSearchQuery(req.Query, req.CaseSensitive, req.Regexp)
+	if err != nil {
+		log.Warn("service search rejected", "err", err)
+		return schema.SearchAllResponse{}, err
+	}
+	maxLines := req.MaxLinesPerTab
+	if maxLines <= 0 {
+		maxLines = schema.DefaultSearchMaxLinesPerTab
+	}
+	maxMatches := req.MaxMatches
+	if maxMatches <= 0 {
+		maxMatches = schema.DefaultSearchMaxMatches
+	}
+
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	tabs := make([]searchTab, 0, len(state.order)+len(state.shared))
+	collect := func(tab *tab) {
+		entry := searchTab{id: tab.ID, name: tab.Name, history: tab.history.Items()}
+		if tab.buffer != nil {
+			entry.lines, entry.numbers = tab.buffer.recentLines(maxLines)
+		}
+		tabs = append(tabs, entry)
+	}
+	for _, id := range state.order {
+		if tab := state.tabs[id]; tab != nil {
+			collect(tab)
+		}
+	}
+	for _, shared := range append([]sharedTab(nil), state.shared...) {
+		if ref, err := s.lookupTabLocked(userID, shared.tabID, schema.ShareAccessRead); err == nil {
+			collect(ref.tab)
+		}
+	}
+	s.mu.Unlock()
+
+	var resp schema.SearchAllResponse
+	add := func(match schema.SearchMatch) bool {
+		if len(resp.Matches) == maxMatches {
+			resp.Truncated = true
+			return false
+		}
+		resp.Matches = append(resp.Matches, match)
+		return true
+	}
+	scanned := 0
+search:
+	for _, tab := range tabs {
+		scanned += len(tab.lines)
+		for i, line := range tab.lines {
+			if loc := re.FindStringIndex(line.Text); loc != nil {
+				if !add(schema.SearchMatch{
+					TabID:     tab.id,
+					TabName:   tab.name,
+					Source:    schema.SearchSourceBuffer,
+					Line:      tab.numbers[i],
+					Kind:      line.Kind,
+					Excerpt:   searchExcerpt(line.Text, loc),
+					Timestamp: line.Timestamp,
+				}) {
+					break search
+				}
+			}
+		}
+		for i := len(tab.history) - 1; i >= 0; i-- {
+			entry := tab.history[i]
+			if loc := re.FindStringIndex(entry.Text); loc != nil {
+				if !add(schema.SearchMatch{
+					TabID:     tab.id,
+					TabName:   tab.name,
+					Source:    schema.SearchSourceHistory,
+					Line:      int64(i + 1),
+					Kind:      schema.LineKindPrompt,
+					Excerpt:   searchExcerpt(entry.Text, loc),
+					Timestamp: entry.Time,
+				}) {
+					break search
+				}
+			}
+		}
+	}
+	log.Info("service search completed", "tabs", len(tabs), "lines", scanned, "matches", len(resp.Matches), "truncated", resp.Truncated)
+	return resp, nil
+}
+
+// compileSearchQuery turns a SearchAll query into a regular expression. A
+// plain query matches literally.
+func compileSearchQuery(query string, caseSensitive, isRegexp bool) (*regexp.Regexp, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("%w: empty query", schema.ErrInvalidSearchQuery)
+	}
+	pattern := query
+	if !isRegexp {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", schema.ErrInvalidSearchQuery, err)
+	}
+	return re, nil
+}
+
+// searchExcerpt returns text on one line, cut around the match at loc when
+// it is longer than searchExcerptRunes.
+func searchExcerpt(text string, loc []int) string {
+	// Same-length replacements keep loc valid.
+	text = strings.NewReplacer("\n", " ", "\r", " ", "\t", " ").Replace(text)
+	if utf8.RuneCountInString(text) <= searchExcerptRunes {
+		return strings.TrimSpace(text)
+	}
+	runes := []rune(text)
+	from := max(utf8.RuneCountInString(text[:loc[0]])-searchExcerptLead, 0)
+	to := min(from+searchExcerptRunes, len(runes))
+	from = max(to-searchExcerptRunes, 0)
+	excerpt := strings.TrimSpace(string(runes[from:to]))
+	if from > 0 {
+		excerpt = "…" + excerpt
+	}
+	if to < len(runes) {
+		excerpt += "…"
+	}
+	return excerpt
+}
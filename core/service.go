@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,7 +17,9 @@ import (
 	"pkt.systems/centaurx/internal/format"
 	"pkt.systems/centaurx/internal/logx"
 	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/repo"
 	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/internal/timefmt"
 	"pkt.systems/centaurx/internal/userhome"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
@@ -27,21 +31,56 @@ type service struct {
 	repoRoot string
 	runners  RunnerProvider
 	renderer Renderer
-	sink     EventSink
-	store    *persist.Store
-	repos    RepoResolver
-	logger   pslog.Logger
-	mu       sync.Mutex
-	userTabs map[schema.UserID]*userState
+	// renderers are the read-time renderings besides the stored one.
+	renderers map[schema.Rendering]Renderer
+	sink      *sinkQueue
+	store     *persist.Store
+	repos     RepoResolver
+	logger    pslog.Logger
+	usage     *usageCache
+	// gitSummaries caches the git state shown when a prompt starts.
+	gitSummaries *gitSummaryCache
+	clock        timefmt.Clock // server default time layout and zone
+	now          func() time.Time
+	mu           sync.Mutex
+	userTabs     map[schema.UserID]*userState
+	batches      map[schema.UserID]*userBatch
+	// repoRuns are the run locks of repos with a running prompt, by host
+	// path.
+	repoRuns map[string]*repoRun
+	// shareLinks are the /sharelink grants by token hash.
+	shareLinks map[string]shareLink
+	// tokenBudgets supplies per-user daily token budgets.
+	tokenBudgets TokenBudgetSource
+	// users lists the accounts of the auth store.
+	users UserDirectory
+	// autoCommitter commits after successful runs in /autocommit tabs.
+	autoCommitter AutoCommitter
+	// streamRetryDelay is the wait before the first attempt to re-attach a
+	// broken run event stream; it doubles on each further attempt.
+	streamRetryDelay time.Duration
 }
 
-var stopSleep = time.Sleep
-
 type userState struct {
-	tabs   map[schema.TabID]*tab
-	order  []schema.TabID
-	system *buffer
-	theme  schema.ThemeName
+	tabs    map[schema.TabID]*tab
+	order   []schema.TabID
+	system  *buffer
+	theme   schema.ThemeName
+	history *historyBuffer // prompts from every tab
+	shared  []sharedTab    // tabs other users share with this user
+	aliases map[string]string
+	// timezone is the user's /tz choice; empty uses the server default.
+	timezone string
+	// tokens counts the codex tokens used in the current UTC day, for the
+	// daily token budget.
+	tokens tokenDay
+	// closed holds recently closed tabs, oldest first; closedTimer prunes
+	// them when they expire.
+	closed      []closedTab
+	closedTimer *time.Timer
+	// onboarding is the first-run checklist of a new user; nil once it is
+	// finished or turned off.
+	onboarding *onboarding
 }
 
 // NewService constructs the core service implementation.
@@ -51,12 +90,22 @@ func NewService(cfg schema.ServiceConfig, deps ServiceDeps) (Service, error) {
 		return nil, err
 	}
 	cfg = normalized
+	clock, err := timefmt.New(cfg.TimeFormat, cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(cfg.RepoRoot, 0o755); err != nil {
 		return nil, err
 	}
 	if deps.Renderer == nil {
 		deps.Renderer = format.NewPlainRenderer()
 	}
+	renderers := map[schema.Rendering]Renderer{
+		schema.RenderingMarkdown: format.NewMarkdownRenderer(),
+		schema.RenderingRaw:      format.NewRawRenderer(),
+	}
+	maps.Copy(renderers, deps.Renderers)
+	delete(renderers, schema.RenderingPlain)
 	if deps.RepoResolver == nil {
 		resolver, err := NewRepoResolver(cfg.RepoRoot)
 		if err != nil {
@@ -70,22 +119,44 @@ func NewService(cfg schema.ServiceConfig, deps ServiceDeps) (Service, error) {
 		if err != nil {
 			return nil, err
 		}
+		store.SetBufferMaxLines(cfg.BufferMaxLines)
 	}
 	logger := deps.Logger
 	if logger == nil {
 		logger = pslog.Ctx(context.Background())
 	}
-	return &service{
-		cfg:      cfg,
-		repoRoot: cfg.RepoRoot,
-		runners:  deps.RunnerProvider,
-		renderer: deps.Renderer,
-		sink:     deps.EventSink,
-		store:    store,
-		repos:    deps.RepoResolver,
-		logger:   logger,
-		userTabs: make(map[schema.UserID]*userState),
-	}, nil
+	var sink *sinkQueue
+	if deps.EventSink != nil {
+		sink = newSinkQueue(deps.EventSink, cfg.EventQueueSize)
+	}
+	svc := &service{
+		cfg:              cfg,
+		repoRoot:         cfg.RepoRoot,
+		runners:          deps.RunnerProvider,
+		renderer:         deps.Renderer,
+		renderers:        renderers,
+		sink:             sink,
+		store:            store,
+		repos:            deps.RepoResolver,
+		logger:           logger,
+		usage:            newUsageCache(usageCacheTTL),
+		gitSummaries:     newGitSummaryCache(cfg.GitSummaryTTL),
+		clock:            clock,
+		now:              time.Now,
+		userTabs:         make(map[schema.UserID]*userState),
+		batches:          make(map[schema.UserID]*userBatch),
+		repoRuns:         make(map[string]*repoRun),
+		shareLinks:       make(map[string]shareLink),
+		tokenBudgets:     deps.TokenBudgets,
+		users:            deps.Users,
+		streamRetryDelay: time.Second,
+	}
+	svc.loadShareLinks()
+	svc.scheduleSummaries(svc.now())
+	for _, userID := range svc.loadBatches() {
+		go svc.advanceBatch(context.Background(), userID)
+	}
+	return svc, nil
 }
 
 func (s *service) CreateTab(ctx context.Context, req schema.CreateTabRequest) (schema.CreateTabResponse, error) {
@@ -97,7 +168,7 @@ func (s *service) CreateTab(ctx context.Context, req schema.CreateTabRequest) (s
 		return schema.CreateTabResponse{}, err
 	}
 	log := logx.WithUser(ctx, userID)
-	log.Info("service tab create start", "repo_name", req.RepoName, "repo_url", req.RepoURL, "create_repo", req.CreateRepo, "tab_name", req.TabName)
+	log.Info("service tab create start", "repo_name", req.RepoName, "repo_url", req.RepoURL, "create_repo", req.CreateRepo, "tab_name", req.TabName, "ephemeral", req.Ephemeral)
 	if strings.TrimSpace(req.RepoURL) == "" && strings.TrimSpace(string(req.RepoName)) == "" {
 		return schema.CreateTabResponse{}, schema.ErrInvalidRepo
 	}
@@ -158,7 +229,9 @@ func (s *service) CreateTab(ctx context.Context, req schema.CreateTabRequest) (s
 		ModelReasoningEffort: schema.DefaultModelReasoningEffort,
 		Status:               schema.TabStatusIdle,
 		buffer:               newBufferWithMaxLines(s.cfg.BufferMaxLines),
-		history:              newHistory(defaultHistoryMax),
+		history:              newHistory(s.cfg.HistoryMax),
+		ephemeral:            req.Ephemeral,
+		repoURL:              strings.TrimSpace(req.RepoURL),
 	}
 
 	s.mu.Lock()
@@ -177,6 +250,7 @@ func (s *service) CreateTab(ctx context.Context, req schema.CreateTabRequest) (s
 	s.emitTabEvent(event)
 	s.persistUser(log, userID)
 	logx.WithRepo(log.With("tab", tab.ID, "tab_name", tab.Name, "repo_created", repoCreated), snapshot.Repo).Info("service tab created")
+	s.completeOnboarding(log, userID, schema.OnboardingFirstTab)
 
 	return schema.CreateTabResponse{Tab: snapshot, RepoCreated: repoCreated}, nil
 }
@@ -201,6 +275,10 @@ func (s *service) CloseTab(ctx context.Context, req schema.CloseTabRequest) (sch
 	var commands []commandRun
 	if tab == nil {
 		s.mu.Unlock()
+		// Closing a tab shared by another user only removes it for the guest.
+		if resp, ok := s.leaveSharedTab(ctx, userID, req.TabID); ok {
+			return resp, nil
+		}
 		log.Warn("service tab close failed", "err", schema.ErrTabNotFound)
 		return schema.CloseTabResponse{}, schema.ErrTabNotFound
 	}
@@ -209,6 +287,9 @@ func (s *service) CloseTab(ctx context.Context, req schema.CloseTabRequest) (sch
 	if len(tab.commands) > 0 {
 		commands = append([]commandRun(nil), tab.commands...)
 	}
+	if handle != nil && tab.Status == schema.TabStatusRunning {
+		tab.Status = schema.TabStatusStopping
+	}
 	delete(state.tabs, req.TabID)
 	state.order = removeTabID(state.order, req.TabID)
 	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.ActiveTab == req.TabID {
@@ -222,14 +303,24 @@ func (s *service) CloseTab(ctx context.Context, req schema.CloseTabRequest) (sch
 		Tab:       snapshot,
 		ActiveTab: active,
 	}
+	guestEvents := s.dropGuestsLocked(userID, tab)
+	droppedLinks := s.dropShareLinksLocked(userID, req.TabID)
+	s.rememberClosedTabLocked(userID, state, tab)
 	s.mu.Unlock()
 	s.emitTabEvent(event)
 	s.persistUser(log, userID)
+	if droppedLinks > 0 {
+		s.persistShareLinks(log, userID)
+	}
+	for _, guestEvent := range guestEvents {
+		s.emitTabEvent(guestEvent)
+		s.persistUser(log, guestEvent.UserID)
+	}
 	if s.runners != nil {
 		_ = s.runners.CloseTab(ctx, RunnerCloseRequest{UserID: userID, TabID: req.TabID})
 	}
 	if handle != nil || len(commands) > 0 {
-		go s.stopTabHandles(log, userID, req.TabID, handle, runCancel, commands)
+		go s.stopTabHandles(log, userID, req.TabID, handle, runCancel, commands, s.cfg.StopGracePeriod)
 	}
 	log.Info("service tab closed")
 	return schema.CloseTabResponse{Tab: snapshot}, nil
@@ -250,13 +341,23 @@ func (s *service) ListTabs(ctx context.Context, req schema.ListTabsRequest) (sch
 	tabs := make([]schema.TabSnapshot, 0, len(state.order))
 	for _, id := range state.order {
 		tab := state.tabs[id]
-		if tab == nil {
+		if tab == nil || (tab.archived && !req.IncludeArchived) {
 			continue
 		}
 		tabs = append(tabs, s.snapshotTab(userID, tab, id == active))
 	}
-
 	var activeRepo schema.RepoRef
+	for _, entry := range append([]sharedTab(nil), state.shared...) {
+		ref, err := s.lookupTabLocked(userID, entry.tabID, schema.ShareAccessRead)
+		if err != nil {
+			continue
+		}
+		tabs = append(tabs, s.snapshotRef(ref, entry.tabID == active))
+		if entry.tabID == active {
+			activeRepo = ref.tab.Repo
+		}
+	}
+
 	if active != "" {
 		if tab := state.tabs[active]; tab != nil {
 			activeRepo = tab.Repo
@@ -269,6 +370,9 @@ func (s *service) ListTabs(ctx context.Context, req schema.ListTabsRequest) (sch
 		ActiveRepo: activeRepo,
 		Theme:      state.theme,
 	}
+	clock := s.clockForLocked(ctx, state)
+	resp.TimeFormat = clock.Layout()
+	resp.Timezone = clock.Location().String()
 	log.Trace("service tabs listed", "count", len(tabs), "active", resp.ActiveTab)
 	return resp, nil
 }
@@ -282,17 +386,22 @@ func (s *service) ActivateTab(ctx context.Context, req schema.ActivateTabRequest
 
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
-	if tab == nil {
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	if err != nil {
 		s.mu.Unlock()
-		log.Warn("service tab activate failed", "err", schema.ErrTabNotFound)
-		return schema.ActivateTabResponse{}, schema.ErrTabNotFound
+		log.Warn("service tab activate failed", "err", err)
+		return schema.ActivateTabResponse{}, err
+	}
+	// Activating an archived tab restores it.
+	restored := !ref.shared() && ref.tab.archived
+	if restored {
+		ref.tab.archived = false
 	}
 	if prefs := sessionprefs.FromContext(ctx); prefs != nil {
 		prefs.ActiveTab = req.TabID
 	}
 	active := activeTabFromContext(ctx, state)
-	snapshot := s.snapshotTab(userID, tab, active == tab.ID)
+	snapshot := s.snapshotRef(ref, active == req.TabID)
 	event := schema.TabEvent{
 		UserID:    userID,
 		Type:      schema.TabEventActivated,
@@ -302,7 +411,7 @@ func (s *service) ActivateTab(ctx context.Context, req schema.ActivateTabRequest
 	s.mu.Unlock()
 	s.emitTabEvent(event)
 	s.persistUser(log, userID)
-	log.Info("service tab activated")
+	log.Info("service tab activated", "restored", restored)
 	return schema.ActivateTabResponse{Tab: snapshot}, nil
 }
 
@@ -313,6 +422,9 @@ func (s *service) SendPrompt(ctx context.Context, req schema.SendPromptRequest)
 	if strings.TrimSpace(req.Prompt) == "" {
 		return schema.SendPromptResponse{}, schema.ErrEmptyPrompt
 	}
+	if err := s.checkPromptSize(req.Prompt); err != nil {
+		return schema.SendPromptResponse{}, err
+	}
 	if ctx == nil {
 		return schema.SendPromptResponse{}, errors.New("missing context")
 	}
@@ -323,35 +435,104 @@ func (s *service) SendPrompt(ctx context.Context, req schema.SendPromptRequest)
 	baseLog := logx.WithUserTab(ctx, userID, req.TabID)
 	ctx = logx.ContextWithUserTabLogger(ctx, baseLog, userID, req.TabID)
 	log := baseLog
+	if err := checkAccountWrite(ctx); err != nil {
+		log.Warn("service prompt rejected", "err", err)
+		return schema.SendPromptResponse{}, err
+	}
 
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
 	active := activeTabFromContext(ctx, state)
-	if tab != nil && tab.Status == schema.TabStatusRunning {
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service prompt rejected", "err", err)
+		return schema.SendPromptResponse{}, err
+	}
+	tab := ref.tab
+	if tab.Status.Busy() {
 		s.mu.Unlock()
 		log.Warn("service prompt rejected", "err", schema.ErrTabBusy)
 		return schema.SendPromptResponse{}, schema.ErrTabBusy
 	}
+	// This also drops prompts queued before the tab was archived.
+	if tab.archived {
+		s.mu.Unlock()
+		log.Warn("service prompt rejected", "err", schema.ErrTabArchived)
+		return schema.SendPromptResponse{}, schema.ErrTabArchived
+	}
+	repoKey := s.repoRunKey(ref.owner, tab.Repo.Name)
+	if holder := s.acquireRepoRunLocked(repoKey, tab); holder != nil {
+		busy := fmt.Errorf("%w in tab '%s'", schema.ErrRepoBusy, holder.tabName)
+		if s.cfg.ConcurrentRuns != schema.ConcurrentRunsQueue {
+			s.mu.Unlock()
+			log.Warn("service prompt rejected", "err", busy)
+			return schema.SendPromptResponse{}, busy
+		}
+		if holder.queuedLocked(tab.ID) {
+			s.mu.Unlock()
+			log.Warn("service prompt rejected", "err", schema.ErrTabBusy)
+			return schema.SendPromptResponse{}, schema.ErrTabBusy
+		}
+		holder.waiting = append(holder.waiting, queuedPrompt{ctx: batchContext(ctx), req: req})
+		snapshot := s.snapshotRef(ref, tab.ID == active)
+		s.mu.Unlock()
+		log.Info("service prompt queued", "holder", holder.tabID)
+		s.appendTransientLine(ref.owner, tab.ID, schema.LineKindSystem, fmt.Sprintf("queued: %v; the prompt starts when that run ends", busy))
+		return schema.SendPromptResponse{Tab: snapshot, Accepted: true}, nil
+	}
+	// The lock is released by consumeEvents, or here when the run does not
+	// start.
+	runStarted := false
+	defer func() {
+		if !runStarted {
+			s.releaseRepoRun(repoKey, tab.ID)
+		}
+	}()
+	// Recorded before the runner is reached, so /redo can replay prompts
+	// that failed to start.
+	tab.LastPrompt = req.Prompt
+	clock := s.clockForLocked(ctx, state)
+	tools := maps.Clone(tab.tools)
+	lastRunAt := tab.lastRunAt
 	s.mu.Unlock()
-	if tab == nil {
-		log.Warn("service prompt rejected", "err", schema.ErrTabNotFound)
-		return schema.SendPromptResponse{}, schema.ErrTabNotFound
+	// Prompts in a shared tab run in the owner's runner and repo.
+	owner := ref.owner
+	if err := s.checkUsageLimit(owner); err != nil {
+		log.Warn("service prompt rejected", "err", err)
+		return schema.SendPromptResponse{}, err
+	}
+	budgetWarning, err := s.checkTokenBudget(ctx, owner)
+	if err != nil {
+		log.Warn("service prompt rejected", "err", err)
+		s.appendLine(log, owner, tab.ID, schema.LineKindError, err.Error())
+		return schema.SendPromptResponse{}, err
 	}
-	sessionLog := logx.WithSession(baseLog, tab.SessionID)
-	ctx = logx.ContextWithUserTabLogger(ctx, sessionLog, userID, req.TabID)
-	repoRef := s.repoRef(userID, tab.Repo.Name)
+	runID := newRunID()
+	sessionLog := logx.WithSession(baseLog, tab.SessionID).With("run_id", runID)
+	ctx = logx.ContextWithRun(logx.ContextWithUserTabLogger(ctx, sessionLog, userID, req.TabID), runID)
+	repoRef := s.repoRef(owner, tab.Repo.Name)
 	log = logx.WithRepo(sessionLog, repoRef).With("model", tab.Model, "prompt_len", len(req.Prompt))
 	log.Info("service prompt start")
-	s.appendLine(log, userID, tab.ID, fmt.Sprintf("> %s", req.Prompt))
+	s.appendLine(log, owner, tab.ID, schema.LineKindPrompt, req.Prompt)
+	if warning := s.promptSizeWarning(req.Prompt); warning != "" {
+		s.appendLine(log, owner, tab.ID, schema.LineKindSystem, warning)
+	}
+	if budgetWarning != "" {
+		s.appendLine(log, owner, tab.ID, schema.LineKindSystem, budgetWarning)
+	}
 
 	runCtx, runCancel := detachRunContext(ctx)
-	runnerResp, err := s.runners.RunnerFor(runCtx, RunnerRequest{UserID: userID, TabID: tab.ID})
+	if ref.shared() {
+		// The guest's session prefs say nothing about the owner's active tab.
+		runCtx = sessionprefs.WithoutContext(runCtx)
+	}
+	runnerResp, err := s.runners.RunnerFor(runCtx, RunnerRequest{UserID: owner, TabID: tab.ID})
 	if err != nil {
 		log.Error("service runner lookup failed", "err", err)
-		startLines := buildExecStartLines(time.Now(), tab, gitSummary{})
-		s.appendLines(log, userID, tab.ID, startLines)
-		s.appendErrorLine(log, userID, tab.ID, err)
+		startLines := buildExecStartLines(time.Now(), clock, tab, lastRunAt, runID, gitSummary{}, schema.EnabledTools(tools))
+		s.appendLines(log, owner, tab.ID, startLines)
+		s.appendErrorLine(log, owner, tab.ID, err)
 		if runCancel != nil {
 			runCancel()
 		}
@@ -359,20 +540,21 @@ func (s *service) SendPrompt(ctx context.Context, req schema.SendPromptRequest)
 	}
 	runner := runnerResp.Runner
 	info := runnerResp.Info
-	workingDir, err := s.repoPath(userID, tab.Repo.Name)
+	go s.probeModels(runCtx, owner, tab.ID, runner)
+	workingDir, err := s.repoPath(owner, tab.Repo.Name)
 	if err != nil {
 		log.Error("service repo path failed", "err", err)
-		s.appendErrorLine(log, userID, tab.ID, err)
+		s.appendErrorLine(log, owner, tab.ID, err)
 		if runCancel != nil {
 			runCancel()
 		}
 		return schema.SendPromptResponse{}, err
 	}
 	if info.RepoRoot != "" {
-		mapped, err := MapRepoPath(s.repoRoot, info.RepoRoot, workingDir)
+		mapped, err := MapRepoPath(s.repoRoot, info, workingDir)
 		if err != nil {
 			log.Error("service repo map failed", "err", err)
-			s.appendErrorLine(log, userID, tab.ID, err)
+			s.appendErrorLine(log, owner, tab.ID, err)
 			if runCancel != nil {
 				runCancel()
 			}
@@ -380,17 +562,28 @@ func (s *service) SendPrompt(ctx context.Context, req schema.SendPromptRequest)
 		}
 		workingDir = mapped
 	}
+	extraArgs := s.codexFlags(tab.Model)
 	if !s.cfg.DisableAuditLogging {
 		command := "codex exec --json"
 		if tab.SessionID != "" {
 			command = fmt.Sprintf("codex exec resume %s --json", tab.SessionID)
 		}
 		auditLog := logx.WithRepo(sessionLog, repoRef).With("model", tab.Model)
-		auditLog.Debug("audit command", "command_type", "codex", "command", command, "workdir", workingDir)
-	}
-	startLines := buildExecStartLines(time.Now(), tab, collectGitSummary(runCtx, runner, workingDir, info.SSHAuthSock))
-	s.appendLines(log, userID, tab.ID, startLines)
+		if tab.ephemeral {
+			auditLog = auditLog.With("ephemeral", true, "prompt_bytes", len(req.Prompt))
+		}
+		auditLog.Debug("audit command", "command_type", "codex", "command", command, "extra_args", extraArgs, "workdir", workingDir)
+	}
+	// The git summary is collected while the run starts; consumeEvents only
+	// begins once the start lines are in the buffer, so they still come
+	// first.
+	startedAt := time.Now()
+	summaryCh := make(chan gitSummary, 1)
+	go func() {
+		summaryCh <- s.gitSummaryFor(runCtx, runner, tab.ID, workingDir, info.SSHAuthSock)
+	}()
 	runReq := RunRequest{
+		RunID:                runID,
 		WorkingDir:           workingDir,
 		Prompt:               req.Prompt,
 		Model:                tab.Model,
@@ -398,12 +591,16 @@ func (s *service) SendPrompt(ctx context.Context, req schema.SendPromptRequest)
 		ResumeSessionID:      tab.SessionID,
 		JSON:                 true,
 		SSHAuthSock:          info.SSHAuthSock,
+		ExtraArgs:            extraArgs,
+		EnableWebSearch:      toolSetting(tools, schema.ToolWebSearch),
 	}
 	started := time.Now()
 	handle, err := runner.Run(runCtx, runReq)
+	summary := <-summaryCh
+	s.appendLines(log, owner, tab.ID, buildExecStartLines(startedAt, clock, tab, lastRunAt, runID, summary, schema.EnabledTools(tools)))
 	if err != nil {
 		log.Error("service runner start failed", "err", err)
-		s.appendErrorLine(log, userID, tab.ID, err)
+		s.appendErrorLine(log, owner, tab.ID, err)
 		if runCancel != nil {
 			runCancel()
 		}
@@ -412,20 +609,24 @@ func (s *service) SendPrompt(ctx context.Context, req schema.SendPromptRequest)
 
 	s.mu.Lock()
 	tab.Status = schema.TabStatusRunning
+	tab.RunID = runID
+	tab.lastRunAt = startedAt
+	if summary.branch != unknownBranch {
+		tab.branch = summary.branch
+	}
 	tab.Run = handle
 	tab.RunCancel = runCancel
-	event := schema.TabEvent{
-		UserID:    userID,
-		Type:      schema.TabEventStatus,
-		Tab:       s.snapshotTab(userID, tab, tab.ID == active),
-		ActiveTab: active,
-	}
+	tab.lastError = nil
+	tab.renderErrors = 0
+	event := s.tabEventLocked(ref, schema.TabEventStatus, active)
+	snapshot := s.snapshotRef(ref, tab.ID == active)
 	s.mu.Unlock()
 	s.emitTabEvent(event)
 	log.Info("service runner started", "workdir", workingDir)
 
-	go s.consumeEvents(runCtx, userID, tab.ID, handle, runCancel, started)
-	return schema.SendPromptResponse{Tab: s.snapshotTab(userID, tab, tab.ID == active), Accepted: true}, nil
+	runStarted = true
+	go s.consumeEvents(runCtx, owner, tab.ID, handle, runCancel, started, repoKey)
+	return schema.SendPromptResponse{Tab: snapshot, Accepted: true}, nil
 }
 
 func (s *service) SetModel(ctx context.Context, req schema.SetModelRequest) (schema.SetModelResponse, error) {
@@ -449,12 +650,14 @@ func (s *service) SetModel(ctx context.Context, req schema.SetModelRequest) (sch
 
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
-	if tab == nil {
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
 		s.mu.Unlock()
-		log.Warn("service model update failed", "err", schema.ErrTabNotFound)
-		return schema.SetModelResponse{}, schema.ErrTabNotFound
+		log.Warn("service model update failed", "err", err)
+		return schema.SetModelResponse{}, err
 	}
+	tab := ref.tab
+	prevModel, prevEffort := tab.Model, tab.ModelReasoningEffort
 	tab.Model = normalizedModel
 	if strings.TrimSpace(string(tab.ModelReasoningEffort)) == "" {
 		tab.ModelReasoningEffort = schema.DefaultModelReasoningEffort
@@ -462,18 +665,39 @@ func (s *service) SetModel(ctx context.Context, req schema.SetModelRequest) (sch
 	if strings.TrimSpace(string(normalizedEffort)) != "" {
 		tab.ModelReasoningEffort = normalizedEffort
 	}
-	active := activeTabFromContext(ctx, state)
-	event := schema.TabEvent{
-		UserID:    userID,
-		Type:      schema.TabEventUpdated,
-		Tab:       s.snapshotTab(userID, tab, req.TabID == active),
-		ActiveTab: active,
+	changed := tab.Model != prevModel || tab.ModelReasoningEffort != prevEffort
+	var marker []string
+	if changed && tab.buffer != nil {
+		line := schema.Line(schema.LineKindSeparator, formatModelChangedLine(prevModel, prevEffort, tab.Model, tab.ModelReasoningEffort))
+		tab.buffer.Append(line)
+		marker = []string{line.Legacy()}
 	}
+	active := activeTabFromContext(ctx, state)
+	event := s.tabEventLocked(ref, schema.TabEventUpdated, active)
+	snapshot := s.snapshotRef(ref, req.TabID == active)
 	s.mu.Unlock()
+	s.emitOutput(ref.owner, tab.ID, marker)
 	s.emitTabEvent(event)
-	s.persistUser(log, userID)
-	log.Info("service model updated", "model", normalizedModel)
-	return schema.SetModelResponse{Tab: s.snapshotTab(userID, tab, req.TabID == active)}, nil
+	s.persistUser(log, ref.owner)
+	log.Info("service model updated", "model", normalizedModel, "changed", changed)
+	return schema.SetModelResponse{Tab: snapshot, Changed: changed}, nil
+}
+
+// formatModelChangedLine describes a model switch for the separator line
+// SetModel appends, e.g. "model changed: gpt-5.1-mini → gpt-5.2-codex (high)".
+// The reasoning effort is shown when it is not the default.
+func formatModelChangedLine(prevModel schema.ModelID, prevEffort schema.ModelReasoningEffort, model schema.ModelID, effort schema.ModelReasoningEffort) string {
+	format := func(model schema.ModelID, effort schema.ModelReasoningEffort) string {
+		name := strings.TrimSpace(string(model))
+		if name == "" {
+			name = "unknown"
+		}
+		if effort == "" || effort == schema.DefaultModelReasoningEffort {
+			return name
+		}
+		return fmt.Sprintf("%s (%s)", name, effort)
+	}
+	return fmt.Sprintf("model changed: %s → %s", format(prevModel, prevEffort), format(model, effort))
 }
 
 func (s *service) SwitchRepo(ctx context.Context, req schema.SwitchRepoRequest) (schema.SwitchRepoResponse, error) {
@@ -482,7 +706,15 @@ func (s *service) SwitchRepo(ctx context.Context, req schema.SwitchRepoRequest)
 		return schema.SwitchRepoResponse{}, err
 	}
 	log := logx.WithUserTab(ctx, userID, req.TabID)
-	repoResp, err := s.repos.ResolveRepo(ctx, ResolveRepoRequest{UserID: userID, Name: req.RepoName})
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	s.mu.Unlock()
+	if err != nil {
+		log.Warn("service repo switch failed", "err", err)
+		return schema.SwitchRepoResponse{}, err
+	}
+	// Repos of a shared tab are the owner's.
+	repoResp, err := s.repos.ResolveRepo(ctx, ResolveRepoRequest{UserID: ref.owner, Name: req.RepoName})
 	if err != nil {
 		log.Warn("service repo switch failed", "err", err, "repo_name", req.RepoName)
 		return schema.SwitchRepoResponse{}, err
@@ -491,28 +723,28 @@ func (s *service) SwitchRepo(ctx context.Context, req schema.SwitchRepoRequest)
 
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
-	if tab == nil {
+	ref, err = s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
 		s.mu.Unlock()
-		log.Warn("service repo switch failed", "err", schema.ErrTabNotFound)
-		return schema.SwitchRepoResponse{}, schema.ErrTabNotFound
+		log.Warn("service repo switch failed", "err", err)
+		return schema.SwitchRepoResponse{}, err
 	}
-	tab.Repo = schema.RepoRef{Name: repoName}
+	ref.tab.Repo = schema.RepoRef{Name: repoName}
 	active := activeTabFromContext(ctx, state)
-	snapshot := s.snapshotTab(userID, tab, req.TabID == active)
-	event := schema.TabEvent{
-		UserID:    userID,
-		Type:      schema.TabEventUpdated,
-		Tab:       snapshot,
-		ActiveTab: active,
-	}
+	snapshot := s.snapshotRef(ref, req.TabID == active)
+	event := s.tabEventLocked(ref, schema.TabEventUpdated, active)
 	s.mu.Unlock()
 	s.emitTabEvent(event)
-	s.persistUser(log, userID)
+	s.persistUser(log, ref.owner)
 	logx.WithRepo(log, snapshot.Repo).Info("service repo switched")
 	return schema.SwitchRepoResponse{Tab: snapshot}, nil
 }
 
+// listReposSizeLimit is the number of repos up to which ListRepos measures
+// sizes on disk without being asked to; above it, walking every work tree
+// would make /listrepos slow.
+const listReposSizeLimit = 20
+
 func (s *service) ListRepos(ctx context.Context, req schema.ListReposRequest) (schema.ListReposResponse, error) {
 	userID, err := normalizeUserID(req.UserID)
 	if err != nil {
@@ -524,12 +756,16 @@ func (s *service) ListRepos(ctx context.Context, req schema.ListReposRequest) (s
 		log.Warn("service repos list failed", "err", err)
 		return schema.ListReposResponse{}, err
 	}
+	sized := req.Sizes || len(listResp.Repos) <= listReposSizeLimit
 	repos := make([]schema.RepoRef, 0, len(listResp.Repos))
-	for _, repo := range listResp.Repos {
-		repos = append(repos, s.repoRef(userID, repo.Name))
-	}
-	log.Debug("service repos listed", "count", len(repos))
-	return schema.ListReposResponse{Repos: repos}, nil
+	details := make([]schema.RepoDetails, 0, len(listResp.Repos))
+	for _, ref := range listResp.Repos {
+		ref = s.repoRef(userID, ref.Name)
+		repos = append(repos, ref)
+		details = append(details, repo.Describe(ref.Path, sized))
+	}
+	log.Debug("service repos listed", "count", len(repos), "sized", sized)
+	return schema.ListReposResponse{Repos: repos, Details: details}, nil
 }
 
 func (s *service) StopSession(ctx context.Context, req schema.StopSessionRequest) (schema.StopSessionResponse, error) {
@@ -544,39 +780,66 @@ func (s *service) StopSession(ctx context.Context, req schema.StopSessionRequest
 		return schema.StopSessionResponse{}, err
 	}
 	log := logx.WithUserTab(ctx, userID, req.TabID)
+	if err := checkAccountWrite(ctx); err != nil {
+		log.Warn("service stop failed", "err", err)
+		return schema.StopSessionResponse{}, err
+	}
 
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
 	active := activeTabFromContext(ctx, state)
-	handle := RunHandle(nil)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service stop failed", "err", err)
+		return schema.StopSessionResponse{}, err
+	}
+	tab := ref.tab
+	handle := tab.Run
+	runCancel := tab.RunCancel
 	var commands []commandRun
-	if tab != nil {
-		handle = tab.Run
-		if len(tab.commands) > 0 {
-			commands = append([]commandRun(nil), tab.commands...)
-		}
+	if len(tab.commands) > 0 {
+		commands = append([]commandRun(nil), tab.commands...)
+	}
+	// The run stays stopping until consumeEvents sees its handle finish and
+	// turns the tab idle.
+	var event *schema.TabEvent
+	if handle != nil && tab.Status == schema.TabStatusRunning {
+		tab.Status = schema.TabStatusStopping
+		tabEvent := s.tabEventLocked(ref, schema.TabEventStatus, active)
+		event = &tabEvent
 	}
 	s.mu.Unlock()
-	if tab == nil {
-		log.Warn("service stop failed", "err", schema.ErrTabNotFound)
-		return schema.StopSessionResponse{}, schema.ErrTabNotFound
+	owner := ref.owner
+	if event != nil {
+		s.emitTabEvent(*event)
 	}
 
 	if handle == nil && len(commands) == 0 {
 		log.Info("service stop ignored", "reason", "no running process")
-		s.appendLine(log, userID, req.TabID, "stop requested: no running process")
-		return schema.StopSessionResponse{Tab: s.snapshotTab(userID, tab, req.TabID == active)}, nil
+		s.appendLine(log, owner, req.TabID, schema.LineKindSystem, "stop requested: no running process")
+		return schema.StopSessionResponse{Tab: s.snapshotRef(ref, req.TabID == active)}, nil
 	}
 
-	log.Info("service stop requested")
-	s.appendLine(log, userID, req.TabID, "stop requested: sending SIGTERM")
-	go s.stopTabHandlesAsync(log, userID, req.TabID, handle, tab.RunCancel, commands)
+	grace := s.cfg.StopGracePeriod
+	if req.GracePeriod > 0 {
+		grace = req.GracePeriod
+	}
+	if req.Kill {
+		grace = 0
+	}
+	log.Info("service stop requested", "grace", grace)
+	if grace > 0 {
+		s.appendLine(log, owner, req.TabID, schema.LineKindSystem, "stop requested: sending SIGTERM")
+	} else {
+		s.appendLine(log, owner, req.TabID, schema.LineKindSystem, "stop requested: sending SIGKILL")
+	}
+	go s.stopTabHandlesAsync(log, owner, req.TabID, handle, runCancel, commands, grace)
 
-	return schema.StopSessionResponse{Tab: s.snapshotTab(userID, tab, req.TabID == active)}, nil
+	return schema.StopSessionResponse{Tab: s.snapshotRef(ref, req.TabID == active)}, nil
 }
 
-func (s *service) stopTabHandles(log pslog.Logger, userID schema.UserID, tabID schema.TabID, handle RunHandle, runCancel context.CancelFunc, commands []commandRun) {
+func (s *service) stopTabHandles(log pslog.Logger, userID schema.UserID, tabID schema.TabID, handle RunHandle, runCancel context.CancelFunc, commands []commandRun, grace time.Duration) {
 	signalCtx := context.Background()
 	if log != nil {
 		signalCtx = logx.ContextWithUserTabLogger(signalCtx, log, userID, tabID)
@@ -594,19 +857,7 @@ func (s *service) stopTabHandles(log pslog.Logger, userID schema.UserID, tabID s
 			log.Warn("service stop signal failed", "signal", ProcessSignalTERM, "err", err)
 		}
 	}
-	stopSleep(10 * time.Second)
-	shouldKill := handle != nil && !isDone(handleDone(handle))
-	if !shouldKill {
-		for _, cmd := range commands {
-			if cmd.handle == nil {
-				continue
-			}
-			if !isDone(handleDone(cmd.handle)) {
-				shouldKill = true
-				break
-			}
-		}
-	}
+	shouldKill := !waitForStop(grace, handle, commands)
 	if handle != nil && shouldKill && !isDone(handleDone(handle)) {
 		if err := handle.Signal(signalCtx, ProcessSignalKILL); err != nil && log != nil {
 			log.Warn("service stop signal failed", "signal", ProcessSignalKILL, "err", err)
@@ -635,45 +886,38 @@ func (s *service) stopTabHandles(log pslog.Logger, userID schema.UserID, tabID s
 	}
 }
 
-func (s *service) stopTabHandlesAsync(log pslog.Logger, userID schema.UserID, tabID schema.TabID, handle RunHandle, runCancel context.CancelFunc, commands []commandRun) {
+// stopTabHandlesAsync sends SIGTERM and then SIGKILL to whatever has not
+// exited after grace. A grace of zero sends SIGKILL right away.
+func (s *service) stopTabHandlesAsync(log pslog.Logger, userID schema.UserID, tabID schema.TabID, handle RunHandle, runCancel context.CancelFunc, commands []commandRun, grace time.Duration) {
 	signalCtx := context.Background()
 	if log != nil {
 		signalCtx = logx.ContextWithUserTabLogger(signalCtx, log, userID, tabID)
 	}
-	if handle != nil {
-		if err := handle.Signal(signalCtx, ProcessSignalTERM); err != nil {
-			if log != nil {
-				log.Warn("service stop signal failed", "signal", ProcessSignalTERM, "err", err)
-			}
-			s.appendLine(log, userID, tabID, fmt.Sprintf("signal error: %v", err))
-		}
-	}
-	for _, cmd := range commands {
-		if cmd.handle == nil {
-			continue
-		}
-		if err := cmd.handle.Signal(signalCtx, ProcessSignalTERM); err != nil {
-			if log != nil {
-				log.Warn("service stop signal failed", "signal", ProcessSignalTERM, "err", err)
+	shouldKill := true
+	if grace > 0 {
+		if handle != nil {
+			if err := handle.Signal(signalCtx, ProcessSignalTERM); err != nil {
+				if log != nil {
+					log.Warn("service stop signal failed", "signal", ProcessSignalTERM, "err", err)
+				}
+				s.appendLine(log, userID, tabID, schema.LineKindError, fmt.Sprintf("signal error: %v", err))
 			}
-			s.appendLine(log, userID, tabID, fmt.Sprintf("signal error: %v", err))
 		}
-	}
-	stopSleep(10 * time.Second)
-	shouldKill := handle != nil && !isDone(handleDone(handle))
-	if !shouldKill {
 		for _, cmd := range commands {
 			if cmd.handle == nil {
 				continue
 			}
-			if !isDone(handleDone(cmd.handle)) {
-				shouldKill = true
-				break
+			if err := cmd.handle.Signal(signalCtx, ProcessSignalTERM); err != nil {
+				if log != nil {
+					log.Warn("service stop signal failed", "signal", ProcessSignalTERM, "err", err)
+				}
+				s.appendLine(log, userID, tabID, schema.LineKindError, fmt.Sprintf("signal error: %v", err))
 			}
 		}
-	}
-	if shouldKill {
-		s.appendLine(log, userID, tabID, "stop requested: sending SIGKILL")
+		shouldKill = !waitForStop(grace, handle, commands)
+		if shouldKill {
+			s.appendLine(log, userID, tabID, schema.LineKindSystem, "stop requested: sending SIGKILL")
+		}
 	}
 	if handle != nil {
 		if shouldKill && !isDone(handleDone(handle)) {
@@ -681,7 +925,7 @@ func (s *service) stopTabHandlesAsync(log pslog.Logger, userID schema.UserID, ta
 				if log != nil {
 					log.Warn("service stop signal failed", "signal", ProcessSignalKILL, "err", err)
 				}
-				s.appendLine(log, userID, tabID, fmt.Sprintf("signal error: %v", err))
+				s.appendLine(log, userID, tabID, schema.LineKindError, fmt.Sprintf("signal error: %v", err))
 			}
 		}
 	}
@@ -694,7 +938,7 @@ func (s *service) stopTabHandlesAsync(log pslog.Logger, userID schema.UserID, ta
 				if log != nil {
 					log.Warn("service stop signal failed", "signal", ProcessSignalKILL, "err", err)
 				}
-				s.appendLine(log, userID, tabID, fmt.Sprintf("signal error: %v", err))
+				s.appendLine(log, userID, tabID, schema.LineKindError, fmt.Sprintf("signal error: %v", err))
 			}
 		}
 	}
@@ -712,6 +956,66 @@ func (s *service) stopTabHandlesAsync(log pslog.Logger, userID schema.UserID, ta
 	}
 }
 
+// waitForStop waits up to grace for the run and commands to exit and
+// reports whether they all did. Handles that cannot report their exit are
+// waited on for the whole period.
+func waitForStop(grace time.Duration, handle RunHandle, commands []commandRun) bool {
+	var dones []<-chan struct{}
+	if handle != nil {
+		dones = append(dones, handleDone(handle))
+	}
+	for _, cmd := range commands {
+		if cmd.handle != nil {
+			dones = append(dones, handleDone(cmd.handle))
+		}
+	}
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for _, done := range dones {
+		select {
+		case <-done:
+		case <-timer.C:
+			return false
+		}
+	}
+	return true
+}
+
+// reattachStream tries to continue a run whose event stream failed with a
+// transient runner error, up to StreamReattachAttempts times with a doubling
+// delay, and tells the user about each attempt. It returns the new stream,
+// or false when the handle cannot re-attach or every attempt failed.
+func (s *service) reattachStream(ctx context.Context, log pslog.Logger, userID schema.UserID, tabID schema.TabID, handle RunHandle, err error) (EventStream, bool) {
+	reattacher, ok := handle.(RunReattacher)
+	attempts := s.cfg.StreamReattachAttempts
+	if !ok || attempts <= 0 || !IsTransientRunnerError(err) {
+		return nil, false
+	}
+	delay := s.streamRetryDelay
+	for attempt := 1; attempt <= attempts; attempt++ {
+		log.Warn("service exec stream interrupted", "err", err, "attempt", attempt, "attempts", attempts)
+		s.appendUserLine(log, userID, tabID, schema.Line(schema.LineKindSystem, fmt.Sprintf("stream interrupted — reattempting (%d/%d)", attempt, attempts)))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false
+		case <-timer.C:
+		}
+		delay *= 2
+		if err = reattacher.Reattach(ctx); err == nil {
+			log.Info("service exec stream reattached", "attempt", attempt)
+			return handle.Events(), true
+		}
+		if !IsTransientRunnerError(err) {
+			log.Warn("service exec stream reattach failed", "err", err)
+			return nil, false
+		}
+	}
+	log.Warn("service exec stream reattach gave up", "err", err, "attempts", attempts)
+	return nil, false
+}
+
 func handleDone(h any) <-chan struct{} {
 	if h == nil {
 		return nil
@@ -734,6 +1038,10 @@ func isDone(ch <-chan struct{}) bool {
 	}
 }
 
+// sessionRenewedLine is the separator RenewSession appends, so readers of
+// the transcript see where the codex context was reset.
+const sessionRenewedLine = "session renewed (new context)"
+
 func (s *service) RenewSession(ctx context.Context, req schema.RenewSessionRequest) (schema.RenewSessionResponse, error) {
 	if ctx == nil {
 		return schema.RenewSessionResponse{}, errors.New("missing context")
@@ -746,32 +1054,36 @@ func (s *service) RenewSession(ctx context.Context, req schema.RenewSessionReque
 
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
 	active := activeTabFromContext(ctx, state)
-	if tab == nil {
+	if err != nil {
 		s.mu.Unlock()
-		log.Warn("service renew failed", "err", schema.ErrTabNotFound)
-		return schema.RenewSessionResponse{}, schema.ErrTabNotFound
+		log.Warn("service renew failed", "err", err)
+		return schema.RenewSessionResponse{}, err
 	}
-	if tab.Status == schema.TabStatusRunning {
+	tab := ref.tab
+	if tab.Status.Busy() {
 		s.mu.Unlock()
 		log.Warn("service renew failed", "err", schema.ErrTabBusy)
 		return schema.RenewSessionResponse{}, schema.ErrTabBusy
 	}
 	tab.SessionID = ""
 	tab.LastUsage = nil
-	event := schema.TabEvent{
-		UserID:    userID,
-		Type:      schema.TabEventUpdated,
-		Tab:       s.snapshotTab(userID, tab, req.TabID == active),
-		ActiveTab: active,
-	}
+	var marker []string
+	if tab.buffer != nil {
+		line := schema.Line(schema.LineKindSeparator, sessionRenewedLine)
+		tab.buffer.Append(line)
+		marker = []string{line.Legacy()}
+	}
+	event := s.tabEventLocked(ref, schema.TabEventUpdated, active)
+	snapshot := s.snapshotRef(ref, req.TabID == active)
 	s.mu.Unlock()
 
+	s.emitOutput(ref.owner, tab.ID, marker)
 	s.emitTabEvent(event)
-	s.persistUser(log, userID)
+	s.persistUser(log, ref.owner)
 	log.Info("service session renewed")
-	return schema.RenewSessionResponse{Tab: s.snapshotTab(userID, tab, req.TabID == active)}, nil
+	return schema.RenewSessionResponse{Tab: snapshot}, nil
 }
 
 func (s *service) SetTheme(ctx context.Context, req schema.SetThemeRequest) (schema.SetThemeResponse, error) {
@@ -781,7 +1093,7 @@ func (s *service) SetTheme(ctx context.Context, req schema.SetThemeRequest) (sch
 	}
 	log := logx.WithUser(ctx, userID)
 	if strings.TrimSpace(string(req.Theme)) == "" {
-		return schema.SetThemeResponse{}, errors.New("theme is required")
+		return schema.SetThemeResponse{}, schema.WithCode(schema.CodeInvalidRequest, errors.New("theme is required"))
 	}
 
 	var tabSnapshot schema.TabSnapshot
@@ -815,18 +1127,42 @@ func (s *service) GetBuffer(ctx context.Context, req schema.GetBufferRequest) (s
 		return schema.GetBufferResponse{}, err
 	}
 	log := logx.WithUserTab(ctx, userID, req.TabID)
+	var renderer Renderer
+	if req.Rendering != "" && req.Rendering != schema.RenderingPlain {
+		renderer = s.renderers[req.Rendering]
+		if renderer == nil {
+			err := fmt.Errorf("%w: unknown rendering %q", schema.ErrInvalidRequest, req.Rendering)
+			log.Warn("service buffer get failed", "err", err)
+			return schema.GetBufferResponse{}, err
+		}
+	}
 
 	s.mu.Lock()
-	tab := s.getOrCreateUserStateLocked(userID).tabs[req.TabID]
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	var view bufferView
+	if err == nil {
+		view = viewBufferLocked(ref, userID, req.Limit)
+		if renderer != nil {
+			// Rendered lazily and cached on the source; TotalLines and
+			// ScrollOffset still count stored lines.
+			view.Entries = view.rerender(func(src *eventSource) []schema.BufferLine {
+				return src.render(req.Rendering, renderer)
+			})
+			view.Lines = schema.LegacyLines(view.Entries)
+		}
+	}
 	s.mu.Unlock()
-	if tab == nil {
-		log.Warn("service buffer get failed", "err", schema.ErrTabNotFound)
-		return schema.GetBufferResponse{}, schema.ErrTabNotFound
+	if err != nil {
+		log.Warn("service buffer get failed", "err", err)
+		return schema.GetBufferResponse{}, err
 	}
 
-	view := tab.buffer.Snapshot(req.Limit)
-	log.Trace("service buffer snapshot", "lines", view.TotalLines, "offset", view.ScrollOffset, "limit", req.Limit)
-	return schema.GetBufferResponse{Buffer: mapBufferSnapshot(req.TabID, view)}, nil
+	log.Trace("service buffer snapshot", "lines", view.TotalLines, "offset", view.ScrollOffset, "limit", req.Limit, "rendering", req.Rendering)
+	snapshot := mapBufferSnapshot(req.TabID, view)
+	if req.Structured {
+		snapshot.Structured = view.Entries
+	}
+	return schema.GetBufferResponse{Buffer: snapshot}, nil
 }
 
 func (s *service) ScrollBuffer(ctx context.Context, req schema.ScrollBufferRequest) (schema.ScrollBufferResponse, error) {
@@ -838,18 +1174,31 @@ func (s *service) ScrollBuffer(ctx context.Context, req schema.ScrollBufferReque
 	log := logx.WithUserTab(ctx, userID, req.TabID)
 
 	s.mu.Lock()
-	tab := s.getOrCreateUserStateLocked(userID).tabs[req.TabID]
-	if tab != nil {
-		tab.buffer.Scroll(req.Delta, req.Limit)
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	var view bufferView
+	if err == nil {
+		// Guests scroll their own view; the owner's offset is persisted.
+		switch {
+		case req.ToLine > 0 && ref.shared():
+			ref.tab.buffer.ScrollGuestToLine(userID, req.ToLine, req.Limit)
+		case req.ToLine > 0:
+			ref.tab.buffer.ScrollToLine(req.ToLine, req.Limit)
+		case ref.shared():
+			ref.tab.buffer.ScrollGuest(userID, req.Delta, req.Limit)
+		default:
+			ref.tab.buffer.Scroll(req.Delta, req.Limit)
+		}
+		view = viewBufferLocked(ref, userID, req.Limit)
 	}
 	s.mu.Unlock()
-	if tab == nil {
-		log.Warn("service buffer scroll failed", "err", schema.ErrTabNotFound)
-		return schema.ScrollBufferResponse{}, schema.ErrTabNotFound
+	if err != nil {
+		log.Warn("service buffer scroll failed", "err", err)
+		return schema.ScrollBufferResponse{}, err
 	}
 
-	view := tab.buffer.Snapshot(req.Limit)
-	s.persistUser(log, userID)
+	if !ref.shared() {
+		s.persistUser(log, userID)
+	}
 	log.Debug("service buffer scrolled", "offset", view.ScrollOffset, "limit", req.Limit)
 	return schema.ScrollBufferResponse{Buffer: mapBufferSnapshot(req.TabID, view)}, nil
 }
@@ -860,25 +1209,36 @@ func (s *service) AppendOutput(ctx context.Context, req schema.AppendOutputReque
 		return schema.AppendOutputResponse{}, err
 	}
 	log := logx.WithUserTab(ctx, userID, req.TabID)
-	if len(req.Lines) == 0 {
+	lines := requestLines(req.Lines, req.Structured)
+	if len(lines) == 0 {
 		return schema.AppendOutputResponse{}, nil
 	}
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
 	active := activeTabFromContext(ctx, state)
-	if tab != nil && tab.buffer != nil {
-		tab.buffer.Append(req.Lines...)
+	var snapshot schema.TabSnapshot
+	if err == nil {
+		if ref.tab.buffer != nil {
+			if req.Transient {
+				ref.tab.buffer.AppendTransient(lines...)
+			} else {
+				ref.tab.buffer.Append(lines...)
+			}
+		}
+		snapshot = s.snapshotRef(ref, req.TabID == active)
 	}
 	s.mu.Unlock()
-	if tab == nil {
-		log.Warn("service output append failed", "err", schema.ErrTabNotFound)
-		return schema.AppendOutputResponse{}, schema.ErrTabNotFound
+	if err != nil {
+		log.Warn("service output append failed", "err", err)
+		return schema.AppendOutputResponse{}, err
 	}
-	s.emitOutput(userID, req.TabID, req.Lines)
-	s.persistUser(log, userID)
-	log.Trace("service output appended", "lines", len(req.Lines))
-	return schema.AppendOutputResponse{Tab: s.snapshotTab(userID, tab, req.TabID == active)}, nil
+	s.emitOutput(ref.owner, req.TabID, schema.LegacyLines(lines))
+	if !req.Transient {
+		s.persistUser(log, ref.owner)
+	}
+	log.Trace("service output appended", "lines", len(lines))
+	return schema.AppendOutputResponse{Tab: snapshot}, nil
 }
 
 func (s *service) AppendSystemOutput(ctx context.Context, req schema.AppendSystemOutputRequest) (schema.AppendSystemOutputResponse, error) {
@@ -888,21 +1248,35 @@ func (s *service) AppendSystemOutput(ctx context.Context, req schema.AppendSyste
 		return schema.AppendSystemOutputResponse{}, err
 	}
 	log := logx.WithUser(ctx, userID)
-	if len(req.Lines) == 0 {
+	lines := requestLines(req.Lines, req.Structured)
+	if len(lines) == 0 {
 		return schema.AppendSystemOutputResponse{}, nil
 	}
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
 	if state.system != nil {
-		state.system.Append(req.Lines...)
+		if req.Transient {
+			state.system.AppendTransient(lines...)
+		} else {
+			state.system.Append(lines...)
+		}
 	}
 	s.mu.Unlock()
-	s.emitSystemOutput(userID, req.Lines)
+	s.emitSystemOutput(userID, schema.LegacyLines(lines))
+	if req.Transient {
+		return schema.AppendSystemOutputResponse{}, nil
+	}
 	s.persistUser(log, userID)
-	log.Trace("service system output appended", "lines", len(req.Lines))
+	log.Trace("service system output appended", "lines", len(lines))
 	return schema.AppendSystemOutputResponse{}, nil
 }
 
+// requestLines merges the legacy and structured lines of an append request.
+func requestLines(raw []string, structured []schema.BufferLine) []schema.BufferLine {
+	lines := schema.ParseBufferLines(raw)
+	return append(lines, structured...)
+}
+
 func (s *service) GetSystemBuffer(ctx context.Context, req schema.GetSystemBufferRequest) (schema.GetSystemBufferResponse, error) {
 	_ = ctx
 	userID, err := normalizeUserID(req.UserID)
@@ -917,50 +1291,98 @@ func (s *service) GetSystemBuffer(ctx context.Context, req schema.GetSystemBuffe
 	if system == nil {
 		return schema.GetSystemBufferResponse{Buffer: schema.SystemBufferSnapshot{}}, nil
 	}
+	s.detectOnboarding(log, userID)
+	s.mu.Lock()
 	view := system.Snapshot(req.Limit)
+	onboarding := s.onboardingStatusLocked(state)
+	s.mu.Unlock()
+	// The checklist sits below the newest line until it is finished.
+	if onboarding.Active && view.AtBottom {
+		view.Entries = append(view.Entries, onboardingLines(onboarding.Done)...)
+		view.Lines = schema.LegacyLines(view.Entries)
+	}
 	log.Trace("service system buffer snapshot", "lines", view.TotalLines, "offset", view.ScrollOffset, "limit", req.Limit)
-	return schema.GetSystemBufferResponse{
-		Buffer: schema.SystemBufferSnapshot{
-			Lines:        view.Lines,
-			TotalLines:   view.TotalLines,
-			ScrollOffset: view.ScrollOffset,
-			AtBottom:     view.AtBottom,
-		},
-	}, nil
+	snapshot := schema.SystemBufferSnapshot{
+		Lines:        view.Lines,
+		TotalLines:   view.TotalLines,
+		ScrollOffset: view.ScrollOffset,
+		AtBottom:     view.AtBottom,
+	}
+	if req.Structured {
+		snapshot.Structured = view.Entries
+	}
+	return schema.GetSystemBufferResponse{Buffer: snapshot}, nil
 }
 
 func (s *service) GetHistory(ctx context.Context, req schema.GetHistoryRequest) (schema.GetHistoryResponse, error) {
-	_ = ctx
 	userID, err := normalizeUserID(req.UserID)
 	if err != nil {
 		return schema.GetHistoryResponse{}, err
 	}
 	log := logx.WithUserTab(ctx, userID, req.TabID)
-	if req.TabID == "" {
+	scope, err := resolveHistoryScope(ctx, req.Scope)
+	if err != nil {
+		return schema.GetHistoryResponse{}, err
+	}
+	if req.TabID == "" && scope == schema.HistoryScopeTab {
 		return schema.GetHistoryResponse{}, schema.ErrTabNotFound
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
-	if tab == nil {
-		log.Warn("service history get failed", "err", schema.ErrTabNotFound)
-		return schema.GetHistoryResponse{}, schema.ErrTabNotFound
+	var history *historyBuffer
+	if scope == schema.HistoryScopeGlobal {
+		history = state.history
+	} else {
+		ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+		if err != nil {
+			log.Warn("service history get failed", "err", err)
+			return schema.GetHistoryResponse{}, err
+		}
+		tab := ref.tab
+		if tab.history == nil {
+			tab.history = newHistory(s.cfg.HistoryMax)
+		}
+		history = tab.history
 	}
-	if tab.history == nil {
-		tab.history = newHistory(defaultHistoryMax)
+	log.Debug("service history fetched", "scope", scope, "entries", len(history.entries))
+	return schema.GetHistoryResponse{Entries: history.Entries(), Items: history.Items(), Scope: scope}, nil
+}
+
+func (s *service) GetLastPrompt(ctx context.Context, req schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.GetLastPromptResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	prompt := ""
+	if err == nil {
+		prompt = ref.tab.LastPrompt
+	}
+	s.mu.Unlock()
+	if err != nil {
+		log.Warn("service last prompt failed", "err", err)
+		return schema.GetLastPromptResponse{}, err
 	}
-	log.Debug("service history fetched", "entries", len(tab.history.Entries()))
-	return schema.GetHistoryResponse{Entries: tab.history.Entries()}, nil
+	if prompt == "" {
+		return schema.GetLastPromptResponse{}, schema.ErrNoPrompt
+	}
+	log.Debug("service last prompt fetched", "prompt_len", len(prompt))
+	return schema.GetLastPromptResponse{Prompt: prompt}, nil
 }
 
 func (s *service) AppendHistory(ctx context.Context, req schema.AppendHistoryRequest) (schema.AppendHistoryResponse, error) {
-	_ = ctx
 	userID, err := normalizeUserID(req.UserID)
 	if err != nil {
 		return schema.AppendHistoryResponse{}, err
 	}
 	log := logx.WithUserTab(ctx, userID, req.TabID)
+	scope, err := resolveHistoryScope(ctx, req.Scope)
+	if err != nil {
+		return schema.AppendHistoryResponse{}, err
+	}
 	if req.TabID == "" {
 		return schema.AppendHistoryResponse{}, schema.ErrTabNotFound
 	}
@@ -968,30 +1390,68 @@ func (s *service) AppendHistory(ctx context.Context, req schema.AppendHistoryReq
 	changed := false
 	s.mu.Lock()
 	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
-	if tab == nil {
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
 		s.mu.Unlock()
-		log.Warn("service history append failed", "err", schema.ErrTabNotFound)
-		return schema.AppendHistoryResponse{}, schema.ErrTabNotFound
+		log.Warn("service history append failed", "err", err)
+		return schema.AppendHistoryResponse{}, err
 	}
+	tab := ref.tab
 	if tab.history == nil {
-		tab.history = newHistory(defaultHistoryMax)
+		tab.history = newHistory(s.cfg.HistoryMax)
 	}
-	if tab.history.Append(req.Entry) {
+	tabChanged := tab.history.Append(req.Entry)
+	if tabChanged && !tab.ephemeral {
 		changed = true
 	}
-	entries = tab.history.Entries()
+	// The global history is persisted, so it never sees ephemeral prompts.
+	if !tab.ephemeral && state.history.Append(req.Entry) {
+		changed = true
+	}
+	if scope == schema.HistoryScopeGlobal {
+		entries = state.history.Entries()
+	} else {
+		entries = tab.history.Entries()
+	}
 	s.mu.Unlock()
 	if changed {
 		s.persistUser(log, userID)
 	}
-	log.Debug("service history appended", "changed", changed, "entries", len(entries))
-	return schema.AppendHistoryResponse{Entries: entries}, nil
+	if tabChanged && !tab.ephemeral && ref.shared() {
+		s.persistUser(log, ref.owner)
+	}
+	log.Debug("service history appended", "scope", scope, "changed", changed, "entries", len(entries))
+	return schema.AppendHistoryResponse{Entries: entries, Scope: scope}, nil
+}
+
+// resolveHistoryScope validates scope, falling back to the session
+// preference when it is unset.
+func resolveHistoryScope(ctx context.Context, scope schema.HistoryScope) (schema.HistoryScope, error) {
+	switch scope {
+	case schema.HistoryScopeTab, schema.HistoryScopeGlobal:
+		return scope, nil
+	case "":
+		if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.GlobalHistory {
+			return schema.HistoryScopeGlobal, nil
+		}
+		return schema.HistoryScopeTab, nil
+	default:
+		return "", fmt.Errorf("%w: unknown history scope %q", schema.ErrInvalidRequest, scope)
+	}
 }
 
 // RegisterCommand tracks a running shell command for the tab.
 func (s *service) RegisterCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle CommandHandle, cancel context.CancelFunc) {
-	if handle == nil || strings.TrimSpace(string(tabID)) == "" {
+	s.registerCommand(ctx, userID, tabID, commandRun{handle: handle, cancel: cancel})
+}
+
+// RegisterPTYCommand tracks a running shell command that has a terminal.
+func (s *service) RegisterPTYCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle CommandHandle, cancel context.CancelFunc) {
+	s.registerCommand(ctx, userID, tabID, commandRun{handle: handle, cancel: cancel, pty: true})
+}
+
+func (s *service) registerCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID, run commandRun) {
+	if run.handle == nil || strings.TrimSpace(string(tabID)) == "" {
 		return
 	}
 	if ctx == nil {
@@ -999,30 +1459,83 @@ func (s *service) RegisterCommand(ctx context.Context, userID schema.UserID, tab
 	}
 	log := logx.WithUserTab(ctx, userID, tabID)
 	s.mu.Lock()
-	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[tabID]
-	if tab == nil {
+	ref, err := s.lookupTabLocked(userID, tabID, schema.ShareAccessReadWrite)
+	if err != nil {
 		s.mu.Unlock()
 		return
 	}
-	tab.commands = append(tab.commands, commandRun{handle: handle, cancel: cancel})
+	tab := ref.tab
+	tab.commands = append(tab.commands, run)
 	count := len(tab.commands)
 	s.mu.Unlock()
-	log.Debug("service command registered", "running", count)
+	log.Debug("service command registered", "running", count, "pty", run.pty)
 }
 
-// UnregisterCommand removes a completed shell command from tracking.
-func (s *service) UnregisterCommand(userID schema.UserID, tabID schema.TabID, handle CommandHandle) {
+// InterruptCommand sends SIGINT to the most recent command of the tab if it
+// runs on a pseudo-terminal.
+func (s *service) InterruptCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, tabID, schema.ShareAccessReadWrite)
+	if err != nil || len(ref.tab.commands) == 0 {
+		s.mu.Unlock()
+		return false, nil
+	}
+	last := ref.tab.commands[len(ref.tab.commands)-1]
+	s.mu.Unlock()
+	if !last.pty {
+		return false, nil
+	}
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if err := last.handle.Signal(ctx, ProcessSignalINT); err != nil {
+		log.Warn("service command interrupt failed", "err", err)
+		return true, err
+	}
+	log.Info("service command interrupted")
+	return true, nil
+}
+
+// ResizeCommands resizes the terminals of the tab's PTY commands.
+func (s *service) ResizeCommands(ctx context.Context, userID schema.UserID, tabID schema.TabID, rows, cols int) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, tabID, schema.ShareAccessRead)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	var handles []CommandHandle
+	for _, run := range ref.tab.commands {
+		if run.pty {
+			handles = append(handles, run.handle)
+		}
+	}
+	s.mu.Unlock()
+	log := logx.WithUserTab(ctx, userID, tabID)
+	for _, handle := range handles {
+		if err := handle.Resize(ctx, rows, cols); err != nil {
+			log.Debug("service command resize failed", "rows", rows, "cols", cols, "err", err)
+		}
+	}
+}
+
+// UnregisterCommand removes a completed shell command from tracking.
+func (s *service) UnregisterCommand(userID schema.UserID, tabID schema.TabID, handle CommandHandle) {
 	if handle == nil || strings.TrimSpace(string(tabID)) == "" {
 		return
 	}
+	s.gitSummaries.invalidate(tabID)
 	s.mu.Lock()
-	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[tabID]
-	if tab == nil || len(tab.commands) == 0 {
+	ref, err := s.lookupTabLocked(userID, tabID, schema.ShareAccessRead)
+	if err != nil || len(ref.tab.commands) == 0 {
 		s.mu.Unlock()
 		return
 	}
+	tab := ref.tab
 	next := tab.commands[:0]
 	for _, entry := range tab.commands {
 		if entry.handle == handle {
@@ -1064,6 +1577,9 @@ func (s *service) SaveCodexAuth(ctx context.Context, req schema.SaveCodexAuthReq
 	if err := os.WriteFile(authPath, payload, 0o600); err != nil {
 		return schema.SaveCodexAuthResponse{}, err
 	}
+	// The cached usage belongs to the previous account.
+	s.usage.invalidate(userID)
+	s.completeOnboarding(logx.WithUser(ctx, userID), userID, schema.OnboardingCodexAuth)
 	return schema.SaveCodexAuthResponse{}, nil
 }
 
@@ -1100,23 +1616,105 @@ func (s *service) GetTabUsage(ctx context.Context, req schema.GetTabUsageRequest
 		return schema.GetTabUsageResponse{}, schema.ErrTabNotFound
 	}
 	s.mu.Lock()
-	state := s.getOrCreateUserStateLocked(userID)
-	tab := state.tabs[req.TabID]
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
 	var usage *schema.TurnUsage
-	if tab != nil && tab.LastUsage != nil {
-		usageCopy := *tab.LastUsage
+	if err == nil && ref.tab.LastUsage != nil {
+		usageCopy := *ref.tab.LastUsage
 		usage = &usageCopy
 	}
 	s.mu.Unlock()
-	if tab == nil {
-		log.Warn("service tab usage failed", "err", schema.ErrTabNotFound)
-		return schema.GetTabUsageResponse{}, schema.ErrTabNotFound
+	if err != nil {
+		log.Warn("service tab usage failed", "err", err)
+		return schema.GetTabUsageResponse{}, err
 	}
 	log.Debug("service tab usage fetched", "has_usage", usage != nil)
 	return schema.GetTabUsageResponse{Usage: usage}, nil
 }
 
-func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle RunHandle, cancel context.CancelFunc, started time.Time) {
+func (s *service) ListOutputFilters(ctx context.Context, req schema.ListOutputFiltersRequest) (schema.ListOutputFiltersResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ListOutputFiltersResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	if err != nil {
+		log.Warn("service output filters list failed", "err", err)
+		return schema.ListOutputFiltersResponse{}, err
+	}
+	return schema.ListOutputFiltersResponse{Patterns: filterPatterns(ref.tab.filters)}, nil
+}
+
+func (s *service) AddOutputFilter(ctx context.Context, req schema.AddOutputFilterRequest) (schema.AddOutputFilterResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.AddOutputFilterResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	filter, err := compileOutputFilter(req.Pattern)
+	if err != nil {
+		log.Warn("service output filter rejected", "err", err)
+		return schema.AddOutputFilterResponse{}, err
+	}
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service output filter add failed", "err", err)
+		return schema.AddOutputFilterResponse{}, err
+	}
+	ref.tab.filters = append(ref.tab.filters, filter)
+	patterns := filterPatterns(ref.tab.filters)
+	s.mu.Unlock()
+	s.persistUser(log, ref.owner)
+	log.Info("service output filter added", "filters", len(patterns))
+	return schema.AddOutputFilterResponse{Patterns: patterns}, nil
+}
+
+func (s *service) RemoveOutputFilter(ctx context.Context, req schema.RemoveOutputFilterRequest) (schema.RemoveOutputFilterResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.RemoveOutputFilterResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service output filter remove failed", "err", err)
+		return schema.RemoveOutputFilterResponse{}, err
+	}
+	tab := ref.tab
+	if req.Index < 1 || req.Index > len(tab.filters) {
+		count := len(tab.filters)
+		s.mu.Unlock()
+		return schema.RemoveOutputFilterResponse{}, fmt.Errorf("%w: no output filter %d (have %d)", schema.ErrInvalidRequest, req.Index, count)
+	}
+	removed := tab.filters[req.Index-1].pattern
+	tab.filters = append(tab.filters[:req.Index-1:req.Index-1], tab.filters[req.Index:]...)
+	patterns := filterPatterns(tab.filters)
+	s.mu.Unlock()
+	s.persistUser(log, ref.owner)
+	log.Info("service output filter removed", "filters", len(patterns))
+	return schema.RemoveOutputFilterResponse{Removed: removed, Patterns: patterns}, nil
+}
+
+// outputFilters returns the tab's current filters. The slice is never
+// modified in place, so callers may use it without holding s.mu.
+func (s *service) outputFilters(userID schema.UserID, tabID schema.TabID) []outputFilter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state := s.userTabs[userID]; state != nil {
+		if tab := state.tabs[tabID]; tab != nil {
+			return tab.filters
+		}
+	}
+	return nil
+}
+
+func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle RunHandle, cancel context.CancelFunc, started time.Time, repoKey string) {
 	log := logx.WithUserTab(ctx, userID, tabID)
 	defer func() {
 		if cancel != nil {
@@ -1125,9 +1723,34 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 	}()
 	log.Info("service exec stream start")
 	stream := handle.Events()
-	workedInserted := false
 	eventCount := 0
-	var seenCommandIDs map[string]bool
+	var pendingAgent []string
+	var agentEvent schema.ExecEvent
+	agentFinal := false
+	flushAgent := func() {
+		if len(pendingAgent) > 0 {
+			s.appendEventLines(log, userID, tabID, agentEvent, agentFinal, pendingAgent)
+			pendingAgent = nil
+		}
+	}
+	// Error events before the first other event are held back, so a codex
+	// that exits right away (on a bad flag, say) gets its stderr folded into
+	// the exit error instead of a bare exit code.
+	var earlyErrors []schema.ExecEvent
+	streaming := false
+	flushEarlyErrors := func() {
+		for _, event := range earlyErrors {
+			lines, err := formatEvent(s.renderer, event)
+			if err != nil {
+				s.appendUnrenderedEvent(log, userID, tabID, event, err)
+				continue
+			}
+			s.appendEventLines(log, userID, tabID, event, false, lines)
+		}
+		earlyErrors = nil
+	}
+	items := newItemTracker()
+	turnCompleted := false
 	lastCommand := ""
 	lastCommandEvent := false
 	for {
@@ -1136,21 +1759,22 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 			if err == io.EOF {
 				break
 			}
+			if next, ok := s.reattachStream(ctx, log, userID, tabID, handle, err); ok {
+				stream = next
+				continue
+			}
 			log.Warn("service exec stream error", "err", err)
 			s.appendErrorLine(log, userID, tabID, fmt.Errorf("stream error: %w", err))
 			break
 		}
 		eventCount++
-		if !workedInserted && event.Type == schema.EventItemCompleted && event.Item != nil && event.Item.Type == schema.ItemAgentMessage {
-			s.appendLine(log, userID, tabID, formatWorkedForLine(time.Since(started)))
-			workedInserted = true
-		}
 		if event.ThreadID != "" {
 			if s.setSessionID(userID, tabID, event.ThreadID) {
 				s.persistUser(log, userID)
 				log.Debug("service session captured", "session", event.ThreadID)
 			}
 		}
+		s.updateProgress(ctx, userID, tabID, handle, event)
 		if event.Type == schema.EventTurnFailed {
 			if event.Error != nil && event.Error.Message != "" {
 				log.Warn("service exec turn failed", "message", event.Error.Message)
@@ -1165,6 +1789,14 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 				log.Warn("service exec error")
 			}
 		}
+		if !streaming {
+			if event.Type == schema.EventError {
+				earlyErrors = append(earlyErrors, event)
+				continue
+			}
+			streaming = true
+			flushEarlyErrors()
+		}
 		if event.Type == schema.EventTurnCompleted && event.Usage != nil {
 			usageCopy := *event.Usage
 			s.mu.Lock()
@@ -1172,18 +1804,21 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 				if tab := state.tabs[tabID]; tab != nil {
 					tab.LastUsage = &usageCopy
 				}
+				state.tokens.add(s.now(), int64(usageCopy.InputTokens+usageCopy.OutputTokens))
 			}
 			s.mu.Unlock()
 		}
+		if event.Item != nil && event.Item.Type == schema.ItemReasoning && event.Item.ID != "" {
+			if !items.observe(event.Type, event.Item.ID) {
+				continue
+			}
+		}
 		if event.Item != nil && event.Item.Type == schema.ItemCommandExecution && event.Item.Command != "" {
 			if event.Item.ID != "" {
-				if seenCommandIDs == nil {
-					seenCommandIDs = make(map[string]bool)
-				}
-				if seenCommandIDs[event.Item.ID] {
+				if items.seen(event.Item.ID) {
 					event.Item.Command = ""
 				} else {
-					seenCommandIDs[event.Item.ID] = true
+					items.observe(event.Type, event.Item.ID)
 				}
 			} else if lastCommandEvent && event.Item.Command == lastCommand && event.Type != schema.EventItemStarted {
 				event.Item.Command = ""
@@ -1195,29 +1830,62 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 			lastCommand = ""
 			lastCommandEvent = false
 		}
-		lines, err := s.renderer.FormatEvent(event)
-		if err != nil {
-			itemType := ""
-			if event.Item != nil {
-				itemType = string(event.Item.Type)
+		var suppressed string
+		if event.Item != nil && event.Item.Type == schema.ItemCommandExecution && event.Item.AggregatedOutput != "" {
+			if filters := s.outputFilters(userID, tabID); len(filters) > 0 {
+				var counts []int
+				event.Item.AggregatedOutput, counts = applyOutputFilters(filters, event.Item.AggregatedOutput)
+				suppressed = formatSuppressedLine(filters, counts)
 			}
-			log.Warn("service render failed", "type", event.Type, "item_type", itemType, "err", err)
-			s.appendLine(log, userID, tabID, fmt.Sprintf("render error: %v", err))
+		}
+		if event.Item != nil && event.Item.Type == schema.ItemAgentMessage && event.Type != schema.EventItemCompleted {
+			continue
+		}
+		lines, err := formatEvent(s.renderer, event)
+		if err != nil {
+			s.appendUnrenderedEvent(log, userID, tabID, event, err)
+			continue
+		}
+		if event.Item != nil && event.Item.ID != "" && dedupedByContent(event.Item.Type) && items.repeated(event.Item.ID, lines) {
 			continue
 		}
 		if event.Item != nil && event.Item.Type == schema.ItemCommandExecution {
 			lines = trimCommandLines(ctx, lines)
+			if suppressed != "" {
+				lines = append(lines, schema.CommandMarker+suppressed)
+			}
+		}
+		if event.Type == schema.EventItemCompleted && event.Item != nil && event.Item.Type == schema.ItemReasoning {
+			lines = trimReasoningLines(ctx, lines)
 		}
-		if len(lines) > 0 {
-			s.appendLines(log, userID, tabID, lines)
+		// Completed agent messages are held back until the next event so the
+		// last one before turn completion can be marked as the final answer.
+		if event.Type == schema.EventItemCompleted && event.Item != nil && event.Item.Type == schema.ItemAgentMessage {
+			flushAgent()
+			pendingAgent, agentEvent, agentFinal = lines, event, false
+			continue
 		}
+		if event.Type == schema.EventTurnCompleted {
+			turnCompleted = true
+			s.appendLine(log, userID, tabID, schema.LineKindSeparator, formatWorkedForLine(time.Since(started)))
+			pendingAgent = markFinalAgentLines(pendingAgent)
+			agentFinal = true
+		}
+		flushAgent()
+		s.appendEventLines(log, userID, tabID, event, false, lines)
 	}
+	flushAgent()
 	result, err := handle.Wait(ctx)
-	if err != nil {
-		log.Warn("service exec wait failed", "err", err)
-		s.appendErrorLine(log, userID, tabID, err)
-	} else if result.ExitCode != 0 {
-		s.appendErrorLine(log, userID, tabID, fmt.Errorf("run exited with code %d", result.ExitCode))
+	if err == nil && result.ExitCode != 0 && len(earlyErrors) > 0 {
+		s.appendErrorLine(log, userID, tabID, fmt.Errorf("run exited with code %d: %s", result.ExitCode, errorExcerpt(earlyErrors)))
+	} else {
+		flushEarlyErrors()
+		if err != nil {
+			log.Warn("service exec wait failed", "err", err)
+			s.appendErrorLine(log, userID, tabID, err)
+		} else if result.ExitCode != 0 {
+			s.appendErrorLine(log, userID, tabID, fmt.Errorf("run exited with code %d", result.ExitCode))
+		}
 	}
 	if err := handle.Close(); err != nil {
 		log.Warn("service exec close failed", "err", err)
@@ -1225,8 +1893,19 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 	}
 
 	if err == nil {
-		log.Info("service exec finished", "exit_code", result.ExitCode, "events", eventCount, "duration_ms", time.Since(started).Milliseconds())
+		log.Info("service exec finished", "exit_code", result.ExitCode, "events", eventCount, "render_errors", s.renderErrors(userID, tabID), "duration_ms", time.Since(started).Milliseconds())
+	}
+	if turnCompleted {
+		s.warnUsageLimits(ctx, userID, tabID)
 	}
+	failure := runFailure(turnCompleted, result, err)
+	if failure == "" {
+		s.autoCommitAfterRun(ctx, userID, tabID)
+	}
+	// Stream errors and failed waits end up here too. The repo is released
+	// before the tab turns idle, so a client that sees the idle tab can
+	// prompt another tab in the repo right away.
+	s.releaseRepoRun(repoKey, tabID)
 	s.mu.Lock()
 	state := s.userTabs[userID]
 	var event *schema.TabEvent
@@ -1237,6 +1916,7 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 			tab.Status = schema.TabStatusIdle
 			tab.Run = nil
 			tab.RunCancel = nil
+			tab.progress = ""
 			tabEvent := schema.TabEvent{
 				UserID:    userID,
 				Type:      schema.TabEventStatus,
@@ -1250,6 +1930,8 @@ func (s *service) consumeEvents(ctx context.Context, userID schema.UserID, tabID
 	if event != nil {
 		s.emitTabEvent(*event)
 	}
+	s.gitSummaries.invalidate(tabID)
+	s.batchRunFinished(ctx, userID, tabID, failure)
 }
 
 const maxCommandLinesTerse = 5
@@ -1267,72 +1949,174 @@ func trimCommandLines(ctx context.Context, lines []string) []string {
 	return lines
 }
 
+// trimReasoningLines collapses completed reasoning to its first non-empty
+// line unless the session asked for full reasoning.
+func trimReasoningLines(ctx context.Context, lines []string) []string {
+	if len(lines) == 0 {
+		return lines
+	}
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.FullReasoning {
+		return lines
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(strings.TrimPrefix(line, schema.ReasoningMarker)) != "" {
+			return []string{line}
+		}
+	}
+	return nil
+}
+
+// itemTracker dedupes items that re-emit their content across the
+// started/updated/completed events of a single run.
+type itemTracker struct {
+	started   map[string]bool
+	completed map[string]bool
+	// rendered holds the lines last rendered for items deduped by content.
+	rendered map[string]string
+}
+
+func newItemTracker() *itemTracker {
+	return &itemTracker{started: make(map[string]bool), completed: make(map[string]bool), rendered: make(map[string]string)}
+}
+
+// observe records the event and reports whether its content has not been
+// rendered yet: started/updated events render once per item and completed
+// events render once per item.
+func (t *itemTracker) observe(eventType schema.EventType, id string) bool {
+	if eventType == schema.EventItemCompleted {
+		if t.completed[id] {
+			return false
+		}
+		t.completed[id] = true
+		t.started[id] = true
+		return true
+	}
+	if t.started[id] {
+		return false
+	}
+	t.started[id] = true
+	return true
+}
+
+// seen reports whether any event for the item was observed.
+func (t *itemTracker) seen(id string) bool {
+	return t.started[id] || t.completed[id]
+}
+
+// repeated reports whether lines are what was last rendered for the item,
+// and records them otherwise.
+func (t *itemTracker) repeated(id string, lines []string) bool {
+	joined := strings.Join(lines, "\n")
+	if last, ok := t.rendered[id]; ok && last == joined {
+		return true
+	}
+	t.rendered[id] = joined
+	return false
+}
+
+// dedupedByContent reports whether events of an item type are rendered only
+// when their content changed. Reasoning and command items have their own
+// rules; agent messages only render once completed.
+func dedupedByContent(itemType schema.ItemType) bool {
+	switch itemType {
+	case schema.ItemReasoning, schema.ItemCommandExecution:
+		return false
+	default:
+		return true
+	}
+}
+
+// errorExcerpt joins the first lines of the messages of error events, as
+// codex writes them to stderr when it refuses to start.
+func errorExcerpt(events []schema.ExecEvent) string {
+	const maxLines, maxBytes = 3, 300
+	var parts []string
+	for _, event := range events {
+		if msg := strings.TrimSpace(event.Message); msg != "" {
+			parts = append(parts, strings.TrimPrefix(msg, "error: "))
+		}
+		if len(parts) == maxLines {
+			break
+		}
+	}
+	excerpt := strings.Join(parts, "; ")
+	if len(excerpt) > maxBytes {
+		excerpt = excerpt[:maxBytes] + "..."
+	}
+	return excerpt
+}
+
 func (s *service) appendErrorLine(log pslog.Logger, userID schema.UserID, tabID schema.TabID, err error) {
 	if err == nil {
 		return
 	}
 	var runnerErr *RunnerError
 	if errors.As(err, &runnerErr) {
-		line, hints := runnerErrorLines(runnerErr)
-		s.appendUserLine(log, userID, tabID, line)
-		for _, hint := range hints {
-			s.appendUserLine(log, userID, tabID, hint)
-		}
+		s.appendUserLine(log, userID, tabID, schema.Line(schema.LineKindError, runnerErrorLine(err)))
+		s.setLastError(userID, tabID, err)
 		return
 	}
-	s.appendUserLine(log, userID, tabID, fmt.Sprintf("error: %v", err))
+	s.appendUserLine(log, userID, tabID, schema.Line(schema.LineKindError, fmt.Sprintf("error: %v", err)))
 }
 
-func runnerErrorLines(err *RunnerError) (string, []string) {
+// runnerErrorLines renders a runner failure as an error line plus hints,
+// keyed on the schema error code of err.
+func runnerErrorLines(err error) (string, []string) {
 	if err == nil {
 		return "error: runner failed", nil
 	}
-	switch err.Kind {
-	case RunnerErrorUnauthorized:
+	switch schema.ErrorCode(err) {
+	case schema.CodeRunnerUnauthorized:
 		return "error: runner authentication failed", []string{
 			"hint: run `codex login` to refresh credentials",
 			"hint: ensure .codex/auth.json is available inside the runner container",
 		}
-	case RunnerErrorPermissionDenied:
+	case schema.CodePermissionDenied:
 		return "error: runner permission denied", []string{
 			"hint: check file permissions and SSH key access inside the runner",
 		}
-	case RunnerErrorUnavailable:
+	case schema.CodeRunnerUnavailable:
 		return "error: runner unavailable", []string{
 			"hint: check that the runner container is running and reachable",
 		}
-	case RunnerErrorTimeout:
+	case schema.CodeRunnerTimeout:
 		return "error: runner timed out", []string{
 			"hint: retry or check runner health",
 		}
-	case RunnerErrorCanceled:
+	case schema.CodeRunnerCanceled:
 		return "error: runner canceled", nil
-	case RunnerErrorContainerStart:
+	case schema.CodeRunnerContainerStart:
 		return "error: runner container failed to start", []string{
 			"hint: check container runtime logs (podman/containerd) for details",
 		}
-	case RunnerErrorContainerSocket:
+	case schema.CodeRunnerContainerSocket:
 		return "error: runner socket did not become ready", []string{
 			"hint: check runner logs for startup failures",
 		}
-	case RunnerErrorExec:
+	case schema.CodeRunnerExec:
 		return "error: runner exec failed", nil
-	case RunnerErrorCommand:
+	case schema.CodeRunnerCommand:
 		return "error: runner command failed", nil
+	case schema.CodeRunnerEnvironment:
+		var runnerErr *RunnerError
+		if errors.As(err, &runnerErr) {
+			return fmt.Sprintf("error: %v", err), runnerErr.Hints
+		}
+		return fmt.Sprintf("error: %v", err), nil
 	default:
 		return fmt.Sprintf("error: %v", err), nil
 	}
 }
 
-func (s *service) appendUserLine(log pslog.Logger, userID schema.UserID, tabID schema.TabID, line string) {
-	if strings.TrimSpace(line) == "" {
+func (s *service) appendUserLine(log pslog.Logger, userID schema.UserID, tabID schema.TabID, line schema.BufferLine) {
+	if strings.TrimSpace(line.Text) == "" {
 		return
 	}
 	if tabID == "" {
-		s.appendSystemLines(log, userID, []string{line})
+		s.appendSystemLines(log, userID, []schema.BufferLine{line})
 		return
 	}
-	s.appendLines(log, userID, tabID, []string{line})
+	s.appendBufferLines(log, userID, tabID, []schema.BufferLine{line})
 }
 
 func (s *service) setSessionID(userID schema.UserID, tabID schema.TabID, sessionID schema.SessionID) bool {
@@ -1353,12 +2137,26 @@ func (s *service) setSessionID(userID schema.UserID, tabID schema.TabID, session
 	return false
 }
 
-func (s *service) appendLine(log pslog.Logger, userID schema.UserID, tabID schema.TabID, line string) {
-	s.appendLines(log, userID, tabID, []string{line})
+func (s *service) appendLine(log pslog.Logger, userID schema.UserID, tabID schema.TabID, kind schema.LineKind, text string) {
+	s.appendBufferLines(log, userID, tabID, []schema.BufferLine{schema.Line(kind, text)})
 }
 
 func formatWorkedForLine(duration time.Duration) string {
-	return schema.WorkedForMarker + "Worked for " + formatWorkedDuration(duration)
+	return "Worked for " + formatWorkedDuration(duration)
+}
+
+func markFinalAgentLines(lines []string) []string {
+	if len(lines) == 0 {
+		return lines
+	}
+	marked := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(line, schema.AgentMarker) {
+			line = schema.FinalAgentMarker + strings.TrimPrefix(line, schema.AgentMarker)
+		}
+		marked = append(marked, line)
+	}
+	return marked
 }
 
 func formatWorkedDuration(duration time.Duration) string {
@@ -1389,7 +2187,82 @@ func formatWorkedDuration(duration time.Duration) string {
 	return fmt.Sprintf("%dh", hours)
 }
 
+// appendLines classifies marker-prefixed lines, such as rendered exec events,
+// and appends them to the tab buffer.
 func (s *service) appendLines(log pslog.Logger, userID schema.UserID, tabID schema.TabID, lines []string) {
+	s.appendBufferLines(log, userID, tabID, schema.ParseBufferLines(lines))
+}
+
+func (s *service) appendBufferLines(log pslog.Logger, userID schema.UserID, tabID schema.TabID, lines []schema.BufferLine) {
+	if len(lines) == 0 {
+		return
+	}
+	s.appendTabLines(log, userID, tabID, lines, nil)
+}
+
+// appendTransientLine shows a notice in a tab without persisting it; see
+// buffer.AppendTransient.
+func (s *service) appendTransientLine(userID schema.UserID, tabID schema.TabID, kind schema.LineKind, text string) {
+	line := schema.Line(kind, text)
+	s.mu.Lock()
+	state := s.userTabs[userID]
+	if state == nil || state.tabs[tabID] == nil || state.tabs[tabID].buffer == nil {
+		s.mu.Unlock()
+		return
+	}
+	state.tabs[tabID].buffer.AppendTransient(line)
+	s.mu.Unlock()
+	s.emitOutput(userID, tabID, []string{line.Legacy()})
+}
+
+// appendEventLines appends the lines rendered from a codex event, keeping
+// the event so GetBuffer can render it differently. Events that rendered
+// to no lines are kept too.
+func (s *service) appendEventLines(log pslog.Logger, userID schema.UserID, tabID schema.TabID, event schema.ExecEvent, final bool, lines []string) {
+	event.Raw = nil
+	if event.Item != nil {
+		item := *event.Item
+		item.Raw = nil
+		event.Item = &item
+	}
+	s.appendTabLines(log, userID, tabID, schema.ParseBufferLines(lines), &eventSource{event: event, final: final})
+}
+
+// appendUnrenderedEvent shows an event the renderer failed on as raw
+// content and counts the failure on the tab's run. The lines carry no event
+// source, so every rendering shows them as they are.
+func (s *service) appendUnrenderedEvent(log pslog.Logger, userID schema.UserID, tabID schema.TabID, event schema.ExecEvent, err error) {
+	itemType := ""
+	if event.Item != nil {
+		itemType = string(event.Item.Type)
+	}
+	log.Warn("service render failed", "type", event.Type, "item_type", itemType, "err", err)
+	s.mu.Lock()
+	if state := s.userTabs[userID]; state != nil {
+		if tab := state.tabs[tabID]; tab != nil {
+			tab.renderErrors++
+		}
+	}
+	s.mu.Unlock()
+	s.appendTabLines(log, userID, tabID, unrenderedEventLines(event, err), nil)
+}
+
+// renderErrors returns how many events of the tab's latest run could not be
+// rendered.
+func (s *service) renderErrors(userID schema.UserID, tabID schema.TabID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state := s.userTabs[userID]; state != nil {
+		if tab := state.tabs[tabID]; tab != nil {
+			return tab.renderErrors
+		}
+	}
+	return 0
+}
+
+// appendTabLines appends lines to a tab buffer, recording source as their
+// origin when set.
+func (s *service) appendTabLines(log pslog.Logger, userID schema.UserID, tabID schema.TabID, lines []schema.BufferLine, source *eventSource) {
 	s.mu.Lock()
 	state := s.userTabs[userID]
 	if state == nil {
@@ -1401,16 +2274,23 @@ func (s *service) appendLines(log pslog.Logger, userID schema.UserID, tabID sche
 		s.mu.Unlock()
 		return
 	}
-	tab.buffer.Append(lines...)
+	if source != nil {
+		tab.buffer.AppendEvent(source.event, source.final, lines...)
+	} else {
+		tab.buffer.Append(lines...)
+	}
 	s.mu.Unlock()
-	s.emitOutput(userID, tabID, lines)
+	if len(lines) == 0 {
+		return
+	}
+	s.emitOutput(userID, tabID, schema.LegacyLines(lines))
 	s.persistUser(log, userID)
 	if log != nil {
 		log.Trace("service output appended", "lines", len(lines))
 	}
 }
 
-func (s *service) appendSystemLines(log pslog.Logger, userID schema.UserID, lines []string) {
+func (s *service) appendSystemLines(log pslog.Logger, userID schema.UserID, lines []schema.BufferLine) {
 	if len(lines) == 0 {
 		return
 	}
@@ -1422,39 +2302,93 @@ func (s *service) appendSystemLines(log pslog.Logger, userID schema.UserID, line
 	}
 	state.system.Append(lines...)
 	s.mu.Unlock()
-	s.emitSystemOutput(userID, lines)
+	s.emitSystemOutput(userID, schema.LegacyLines(lines))
 	s.persistUser(log, userID)
 	if log != nil {
 		log.Trace("service system output appended", "lines", len(lines))
 	}
 }
 
+// emitOutput publishes output of the owner's tab to the owner and every
+// guest the tab is shared with.
 func (s *service) emitOutput(userID schema.UserID, tabID schema.TabID, lines []string) {
 	if s.sink == nil || len(lines) == 0 {
 		return
 	}
-	s.sink.OnOutput(schema.OutputEvent{
+	s.mu.Lock()
+	shares := s.tabSharesLocked(userID, tabID)
+	s.mu.Unlock()
+	s.sink.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{
 		UserID: userID,
 		TabID:  tabID,
 		Lines:  append([]string(nil), lines...),
-	})
+	}})
+	for _, share := range shares {
+		s.sink.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{
+			UserID: share.User,
+			TabID:  tabID,
+			Lines:  append([]string(nil), lines...),
+		}})
+	}
 }
 
 func (s *service) emitSystemOutput(userID schema.UserID, lines []string) {
 	if s.sink == nil || len(lines) == 0 {
 		return
 	}
-	s.sink.OnSystemOutput(schema.SystemOutputEvent{
+	s.sink.enqueue(sinkEvent{kind: sinkEventSystemOutput, system: schema.SystemOutputEvent{
 		UserID: userID,
 		Lines:  append([]string(nil), lines...),
-	})
+	}})
 }
 
+// emitTabEvent publishes a tab event. Status and update events of a shared
+// tab are also published to its guests; activation is per viewer.
 func (s *service) emitTabEvent(event schema.TabEvent) {
 	if s.sink == nil {
 		return
 	}
-	s.sink.OnTabEvent(event)
+	s.sink.enqueue(sinkEvent{kind: sinkEventTab, tab: event})
+	if event.Tab.ID == "" || (event.Type != schema.TabEventStatus && event.Type != schema.TabEventUpdated) {
+		return
+	}
+	s.mu.Lock()
+	shares := s.tabSharesLocked(event.UserID, event.Tab.ID)
+	s.mu.Unlock()
+	for _, share := range shares {
+		guestEvent := event
+		guestEvent.UserID = share.User
+		guestEvent.ActiveTab = ""
+		guestEvent.Theme = ""
+		guestEvent.Tab.Active = false
+		guestEvent.Tab.Owner = event.UserID
+		guestEvent.Tab.Access = share.Access
+		s.sink.enqueue(sinkEvent{kind: sinkEventTab, tab: guestEvent})
+	}
+}
+
+// FlushEvents waits until all queued sink events have been delivered.
+func (s *service) FlushEvents(ctx context.Context) error {
+	if s.sink == nil {
+		return nil
+	}
+	return s.sink.flush(ctx)
+}
+
+// CloseEvents drains the sink queue and stops delivery.
+func (s *service) CloseEvents(ctx context.Context) error {
+	if s.sink == nil {
+		return nil
+	}
+	return s.sink.close(ctx)
+}
+
+// EventQueueStats reports sink queue depth and drop counters.
+func (s *service) EventQueueStats() EventQueueStats {
+	if s.sink == nil {
+		return EventQueueStats{}
+	}
+	return s.sink.stats()
 }
 
 func (s *service) getOrCreateUserStateLocked(userID schema.UserID) *userState {
@@ -1469,6 +2403,9 @@ func (s *service) getOrCreateUserStateLocked(userID schema.UserID) *userState {
 	if entry.theme == "" {
 		entry.theme = s.cfg.DefaultTheme
 	}
+	if entry.history == nil {
+		entry.history = newHistory(s.cfg.GlobalHistoryMax)
+	}
 	return entry
 }
 
@@ -1482,37 +2419,44 @@ func (s *service) loadUserStateLocked(userID schema.UserID) *userState {
 	}
 	snapshot, ok, err := s.store.Load(userID)
 	if err != nil || !ok {
+		fresh := &userState{tabs: make(map[schema.TabID]*tab), system: newBufferWithMaxLines(s.cfg.BufferMaxLines), theme: s.cfg.DefaultTheme}
 		if err != nil {
 			log.Warn("service state load failed", "err", err)
+			var corrupt *persist.CorruptStateError
+			if errors.As(err, &corrupt) {
+				fresh.system.Append(schema.Line(schema.LineKindError, corruptStateNotice(corrupt)))
+			}
 		} else {
+			// A user without state has never used the server, so they get
+			// the first-run checklist.
 			log.Debug("service state missing")
+			if !s.cfg.DisableOnboarding {
+				fresh.onboarding = &onboarding{}
+			}
 		}
-		return &userState{tabs: make(map[schema.TabID]*tab), system: newBufferWithMaxLines(s.cfg.BufferMaxLines), theme: s.cfg.DefaultTheme}
+		return fresh
 	}
 	log.Debug("service state loaded", "tabs", len(snapshot.Tabs))
 	loaded := &userState{
-		tabs:   make(map[schema.TabID]*tab),
-		order:  make([]schema.TabID, 0, len(snapshot.Order)),
-		system: newBufferFromPersistedWithMaxLines(persistedBuffer{Lines: snapshot.System.Lines, ScrollOffset: snapshot.System.ScrollOffset}, s.cfg.BufferMaxLines),
-		theme:  snapshot.Theme,
+		tabs:       make(map[schema.TabID]*tab),
+		order:      make([]schema.TabID, 0, len(snapshot.Order)),
+		system:     newBufferFromPersistedWithMaxLines(persistedBuffer{Lines: snapshot.System.Lines, ScrollOffset: snapshot.System.ScrollOffset, Seq: snapshot.System.Seq}, s.cfg.BufferMaxLines),
+		theme:      snapshot.Theme,
+		history:    newHistoryFromPersisted(snapshot.GlobalHistory, s.cfg.GlobalHistoryMax),
+		aliases:    snapshot.Aliases,
+		timezone:   snapshot.Timezone,
+		tokens:     importTokenDay(snapshot.TokenUsage),
+		onboarding: importOnboarding(snapshot.Onboarding),
 	}
 	for _, snap := range snapshot.Tabs {
-		repoName := snap.Repo.Name
-		effort := snap.ModelReasoningEffort
-		if strings.TrimSpace(string(effort)) == "" {
-			effort = schema.DefaultModelReasoningEffort
-		}
-		loaded.tabs[snap.ID] = &tab{
-			ID:                   snap.ID,
-			Name:                 snap.Name,
-			Repo:                 schema.RepoRef{Name: repoName},
-			Model:                snap.Model,
-			ModelReasoningEffort: effort,
-			SessionID:            snap.SessionID,
-			Status:               schema.TabStatusIdle,
-			buffer:               newBufferFromPersistedWithMaxLines(persistedBuffer{Lines: snap.Buffer.Lines, ScrollOffset: snap.Buffer.ScrollOffset}, s.cfg.BufferMaxLines),
-			history:              newHistoryFromPersisted(snap.History),
-		}
+		loaded.tabs[snap.ID] = s.importTab(snap)
+	}
+	for _, entry := range snapshot.ClosedTabs {
+		loaded.closed = append(loaded.closed, closedTab{tab: s.importTab(entry.Tab), closedAt: entry.ClosedAt})
+	}
+	s.pruneClosedTabsLocked(userID, loaded)
+	for _, entry := range snapshot.SharedTabs {
+		loaded.shared = append(loaded.shared, sharedTab{tabID: entry.TabID, owner: entry.Owner})
 	}
 	for _, id := range snapshot.Order {
 		if _, ok := loaded.tabs[id]; ok {
@@ -1527,9 +2471,124 @@ func (s *service) loadUserStateLocked(userID schema.UserID) *userState {
 	if loaded.theme == "" {
 		loaded.theme = s.cfg.DefaultTheme
 	}
+	// The recovery marker is not exported again, so the notice is shown once.
+	if snapshot.Recovery != nil {
+		log.Warn("service state recovered from backup", "backup_taken_at", snapshot.Recovery.BackupTakenAt, "corrupt_path", snapshot.Recovery.CorruptPath)
+		loaded.system.Append(schema.Line(schema.LineKindSystem, recoveryNotice(snapshot.Recovery)))
+	}
 	return loaded
 }
 
+// recoveryNotice tells the user their state was restored from a backup.
+func recoveryNotice(recovery *persist.Recovery) string {
+	return fmt.Sprintf("state recovered from backup taken at %s; last few minutes of history may be missing (damaged file kept as %s)",
+		recovery.BackupTakenAt.UTC().Format("2006-01-02 15:04:05 MST"), recovery.CorruptPath)
+}
+
+// corruptStateNotice tells the user their state could not be restored.
+func corruptStateNotice(err *persist.CorruptStateError) string {
+	if err.MovedTo == "" {
+		return "state could not be loaded and was reset"
+	}
+	return fmt.Sprintf("state could not be loaded and was reset; no usable backup was available (damaged file kept as %s)", err.MovedTo)
+}
+
+// importTab restores a tab from its persisted form.
+func (s *service) importTab(snap persist.TabSnapshot) *tab {
+	effort := snap.ModelReasoningEffort
+	if strings.TrimSpace(string(effort)) == "" {
+		effort = schema.DefaultModelReasoningEffort
+	}
+	// Runs do not survive a restart, so a tab saved while running or
+	// stopping loads idle.
+	restored := &tab{
+		ID:                   snap.ID,
+		Name:                 snap.Name,
+		Repo:                 schema.RepoRef{Name: snap.Repo.Name},
+		Model:                snap.Model,
+		ModelReasoningEffort: effort,
+		SessionID:            snap.SessionID,
+		LastPrompt:           snap.LastPrompt,
+		Status:               schema.TabStatusIdle,
+		buffer:               newBufferFromPersistedWithMaxLines(persistedBuffer{Lines: snap.Buffer.Lines, ScrollOffset: snap.Buffer.ScrollOffset, Seq: snap.Buffer.Seq}, s.cfg.BufferMaxLines),
+		history:              newHistoryFromPersisted(snap.History, s.cfg.HistoryMax),
+		filters:              compileOutputFilters(snap.OutputFilters),
+		shares:               loadTabShares(snap.Shares),
+		summariesOn:          snap.SummariesEnabled,
+		summaries:            snap.Summaries,
+		ephemeral:            snap.Ephemeral,
+		tools:                snap.Tools,
+		lastRunAt:            snap.LastRunAt,
+		repoURL:              snap.RepoURL,
+		labels:               snap.Labels,
+		autoCommit:           snap.AutoCommit,
+		archived:             snap.Archived,
+	}
+	if restored.ephemeral {
+		restored.buffer.Append(schema.Line(schema.LineKindSystem, ephemeralRestoredNotice))
+	}
+	return restored
+}
+
+// ephemeralRestoredNotice opens the buffer of an ephemeral tab loaded from
+// state, which is always empty.
+const ephemeralRestoredNotice = "(ephemeral) tab restored empty; its buffer, history and prompts are not saved"
+
+// exportTab returns the persisted form of a tab. Ephemeral tabs keep only
+// what is needed to reopen them empty.
+func exportTab(tab *tab) persist.TabSnapshot {
+	if tab.ephemeral {
+		return persist.TabSnapshot{
+			ID:                   tab.ID,
+			Name:                 tab.Name,
+			Repo:                 schema.RepoRef{Name: tab.Repo.Name},
+			Model:                tab.Model,
+			ModelReasoningEffort: tab.ModelReasoningEffort,
+			OutputFilters:        filterPatterns(tab.filters),
+			Shares:               exportTabShares(tab.shares),
+			Ephemeral:            true,
+			Tools:                maps.Clone(tab.tools),
+			RepoURL:              tab.repoURL,
+			Labels:               slices.Clone(tab.labels),
+			AutoCommit:           tab.autoCommit,
+			Archived:             tab.archived,
+		}
+	}
+	buffer := persistedBuffer{}
+	if tab.buffer != nil {
+		buffer = tab.buffer.Export()
+	}
+	var history []persist.HistoryEntry
+	if tab.history != nil {
+		history = tab.history.Export()
+	}
+	return persist.TabSnapshot{
+		ID:                   tab.ID,
+		Name:                 tab.Name,
+		Repo:                 schema.RepoRef{Name: tab.Repo.Name},
+		Model:                tab.Model,
+		ModelReasoningEffort: tab.ModelReasoningEffort,
+		SessionID:            tab.SessionID,
+		LastPrompt:           tab.LastPrompt,
+		Buffer: persist.BufferSnapshot{
+			Lines:        buffer.Lines,
+			ScrollOffset: buffer.ScrollOffset,
+			Seq:          buffer.Seq,
+		},
+		History:          history,
+		OutputFilters:    filterPatterns(tab.filters),
+		Shares:           exportTabShares(tab.shares),
+		SummariesEnabled: tab.summariesOn,
+		Summaries:        slices.Clone(tab.summaries),
+		Tools:            maps.Clone(tab.tools),
+		LastRunAt:        tab.lastRunAt,
+		RepoURL:          tab.repoURL,
+		Labels:           slices.Clone(tab.labels),
+		AutoCommit:       tab.autoCommit,
+		Archived:         tab.archived,
+	}
+}
+
 func (s *service) persistUser(log pslog.Logger, userID schema.UserID) {
 	if s.store == nil {
 		return
@@ -1565,27 +2624,11 @@ func (s *service) snapshotUser(userID schema.UserID) (persist.UserSnapshot, bool
 		if tab == nil {
 			continue
 		}
-		buffer := persistedBuffer{}
-		if tab.buffer != nil {
-			buffer = tab.buffer.Export()
-		}
-		history := []string(nil)
-		if tab.history != nil {
-			history = tab.history.Entries()
-		}
-		tabs = append(tabs, persist.TabSnapshot{
-			ID:                   tab.ID,
-			Name:                 tab.Name,
-			Repo:                 schema.RepoRef{Name: tab.Repo.Name},
-			Model:                tab.Model,
-			ModelReasoningEffort: tab.ModelReasoningEffort,
-			SessionID:            tab.SessionID,
-			Buffer: persist.BufferSnapshot{
-				Lines:        buffer.Lines,
-				ScrollOffset: buffer.ScrollOffset,
-			},
-			History: history,
-		})
+		tabs = append(tabs, exportTab(tab))
+	}
+	var closed []persist.ClosedTabSnapshot
+	for _, entry := range userState.closed {
+		closed = append(closed, persist.ClosedTabSnapshot{Tab: exportTab(entry.tab), ClosedAt: entry.closedAt})
 	}
 	order := append([]schema.TabID(nil), userState.order...)
 	system := persistedBuffer{}
@@ -1598,11 +2641,28 @@ func (s *service) snapshotUser(userID schema.UserID) (persist.UserSnapshot, bool
 		System: persist.BufferSnapshot{
 			Lines:        system.Lines,
 			ScrollOffset: system.ScrollOffset,
+			Seq:          system.Seq,
 		},
-		Theme: userState.theme,
+		Theme:         userState.theme,
+		GlobalHistory: userState.history.Export(),
+		SharedTabs:    exportSharedTabs(userState.shared),
+		ClosedTabs:    closed,
+		Aliases:       maps.Clone(userState.aliases),
+		Timezone:      userState.timezone,
+		TokenUsage:    userState.tokens.export(),
+		Onboarding:    userState.onboarding.export(),
 	}, true
 }
 
+// codexFlags returns the extra codex exec flags for a run with model.
+func (s *service) codexFlags(model schema.ModelID) []string {
+	byModel := s.cfg.CodexFlagsByModel[model]
+	if len(s.cfg.CodexFlags) == 0 && len(byModel) == 0 {
+		return nil
+	}
+	return append(slices.Clone(s.cfg.CodexFlags), byModel...)
+}
+
 func (s *service) repoPath(userID schema.UserID, repoName schema.RepoName) (string, error) {
 	return RepoPath(s.repoRoot, userID, repoName)
 }
@@ -1627,28 +2687,37 @@ func activeTabFromContext(ctx context.Context, state *userState) schema.TabID {
 	if prefs == nil {
 		return ""
 	}
-	if state == nil || state.tabs == nil || len(state.tabs) == 0 {
+	if state == nil || (len(state.tabs) == 0 && len(state.shared) == 0) {
 		prefs.ActiveTab = ""
 		return ""
 	}
 	active := prefs.ActiveTab
 	if active != "" {
-		if _, ok := state.tabs[active]; ok {
+		if tab, ok := state.tabs[active]; ok && !tab.archived {
+			return active
+		}
+		if _, ok := state.sharedOwner(active); ok {
 			return active
 		}
 		prefs.ActiveTab = ""
 	}
-	if len(state.order) > 0 {
-		first := state.order[0]
-		if _, ok := state.tabs[first]; ok {
-			prefs.ActiveTab = first
-			return first
+	for _, id := range state.order {
+		if tab, ok := state.tabs[id]; ok && !tab.archived {
+			prefs.ActiveTab = id
+			return id
 		}
 	}
-	for id := range state.tabs {
+	for id, tab := range state.tabs {
+		if tab.archived {
+			continue
+		}
 		prefs.ActiveTab = id
 		return id
 	}
+	if len(state.shared) > 0 {
+		prefs.ActiveTab = state.shared[0].tabID
+		return prefs.ActiveTab
+	}
 	return ""
 }
 
@@ -1662,6 +2731,9 @@ func detachRunContext(ctx context.Context) (context.Context, context.CancelFunc)
 			copyPrefs := *prefs
 			base = sessionprefs.WithContext(base, &copyPrefs)
 		}
+		if marked := ctx.Value(autoCommitKey{}); marked != nil {
+			base = context.WithValue(base, autoCommitKey{}, marked)
+		}
 	}
 	return context.WithCancel(base)
 }
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestActivityFeedRecordsAndLists(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+
+	if _, err := svc.RecordActivity(ctx, schema.RecordActivityRequest{UserID: user, Kind: "bogus"}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected unknown kind to be rejected, got %v", err)
+	}
+	recorded, err := svc.RecordActivity(ctx, schema.RecordActivityRequest{UserID: user, Kind: schema.ActivityRunnerCreated, Detail: "centaurx-runner-alice"})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if recorded.Line.Kind != schema.LineKindActivity || recorded.Line.Text != "event: runner container started (centaurx-runner-alice)" || recorded.Line.Timestamp.IsZero() {
+		t.Fatalf("unexpected activity line: %+v", recorded.Line)
+	}
+	if _, err := svc.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: user, Lines: []string{"not an event"}}); err != nil {
+		t.Fatalf("append system: %v", err)
+	}
+	for _, kind := range []schema.ActivityKind{schema.ActivitySSHKeyRotated, schema.ActivityQuotaWarning} {
+		if _, err := svc.RecordActivity(ctx, schema.RecordActivityRequest{UserID: user, Kind: kind}); err != nil {
+			t.Fatalf("record %s: %v", kind, err)
+		}
+	}
+
+	listed, err := svc.ListActivity(ctx, schema.ListActivityRequest{UserID: user, Limit: 2})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(listed.Entries) != 2 || listed.Entries[0].Text != "event: git SSH key rotated" || listed.Entries[1].Text != "event: usage quota warning" {
+		t.Fatalf("expected the two latest events oldest first, got %+v", listed.Entries)
+	}
+
+	reloaded, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	all, err := reloaded.ListActivity(ctx, schema.ListActivityRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list after reload: %v", err)
+	}
+	if len(all.Entries) != 3 || !strings.HasPrefix(all.Entries[0].Text, "event: runner container started") {
+		t.Fatalf("expected persisted events, got %+v", all.Entries)
+	}
+}
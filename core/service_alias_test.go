@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestAliasesSetListRemoveAndPersist(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+
+	for _, alias := range []schema.CommandAlias{
+		{Name: "Bad Name", Expansion: "!ls"},
+		{Name: "ls", Expansion: "ls -la"},
+		{Name: "ls", Expansion: "!ls\n!rm"},
+		{Name: "loop", Expansion: "/loop again"},
+	} {
+		if _, err := svc.SetAlias(ctx, schema.SetAliasRequest{UserID: user, Alias: alias}); !errors.Is(err, schema.ErrInvalidAlias) {
+			t.Fatalf("expected %+v to be rejected, got %v", alias, err)
+		}
+	}
+	if _, err := svc.SetAlias(ctx, schema.SetAliasRequest{UserID: user, Alias: schema.CommandAlias{Name: "/test", Expansion: " !go test ./... "}}); err != nil {
+		t.Fatalf("set test: %v", err)
+	}
+	if _, err := svc.SetAlias(ctx, schema.SetAliasRequest{UserID: user, Alias: schema.CommandAlias{Name: "t", Expansion: "/test -run X"}}); !errors.Is(err, schema.ErrInvalidAlias) {
+		t.Fatalf("expected alias invoking an alias to be rejected, got %v", err)
+	}
+	if _, err := svc.SetAlias(ctx, schema.SetAliasRequest{UserID: user, Alias: schema.CommandAlias{Name: "c", Expansion: "/cm"}}); err != nil {
+		t.Fatalf("set c: %v", err)
+	}
+	if _, err := svc.SetAlias(ctx, schema.SetAliasRequest{UserID: user, Alias: schema.CommandAlias{Name: "cm", Expansion: "/git commit"}}); !errors.Is(err, schema.ErrInvalidAlias) {
+		t.Fatalf("expected alias invoked by an alias to be rejected, got %v", err)
+	}
+	set, err := svc.SetAlias(ctx, schema.SetAliasRequest{UserID: user, Alias: schema.CommandAlias{Name: "c", Expansion: "/git commit"}})
+	if err != nil {
+		t.Fatalf("replace c: %v", err)
+	}
+	want := []schema.CommandAlias{{Name: "c", Expansion: "/git commit"}, {Name: "test", Expansion: "!go test ./..."}}
+	if len(set.Aliases) != 2 || set.Aliases[0] != want[0] || set.Aliases[1] != want[1] {
+		t.Fatalf("unexpected aliases: %+v", set.Aliases)
+	}
+
+	reloaded, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	listed, err := reloaded.ListAliases(ctx, schema.ListAliasesRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(listed.Aliases) != 2 || listed.Aliases[1] != want[1] {
+		t.Fatalf("expected persisted aliases, got %+v", listed.Aliases)
+	}
+	removed, err := reloaded.RemoveAlias(ctx, schema.RemoveAliasRequest{UserID: user, Name: "/test"})
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if removed.Removed != want[1] || len(removed.Aliases) != 1 {
+		t.Fatalf("unexpected remove response: %+v", removed)
+	}
+	if _, err := reloaded.RemoveAlias(ctx, schema.RemoveAliasRequest{UserID: user, Name: "test"}); !errors.Is(err, schema.ErrAliasNotFound) {
+		t.Fatalf("expected missing alias error, got %v", err)
+	}
+}
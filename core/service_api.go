@@ -10,8 +10,15 @@ import (
 type Service interface {
 	CreateTab(ctx context.Context, req schema.CreateTabRequest) (schema.CreateTabResponse, error)
 	CloseTab(ctx context.Context, req schema.CloseTabRequest) (schema.CloseTabResponse, error)
+	ReopenTab(ctx context.Context, req schema.ReopenTabRequest) (schema.ReopenTabResponse, error)
+	ListClosedTabs(ctx context.Context, req schema.ListClosedTabsRequest) (schema.ListClosedTabsResponse, error)
 	ListTabs(ctx context.Context, req schema.ListTabsRequest) (schema.ListTabsResponse, error)
 	ActivateTab(ctx context.Context, req schema.ActivateTabRequest) (schema.ActivateTabResponse, error)
+	ShareTab(ctx context.Context, req schema.ShareTabRequest) (schema.ShareTabResponse, error)
+	UnshareTab(ctx context.Context, req schema.UnshareTabRequest) (schema.UnshareTabResponse, error)
+	CreateShareLink(ctx context.Context, req schema.CreateShareLinkRequest) (schema.CreateShareLinkResponse, error)
+	RevokeShareLinks(ctx context.Context, req schema.RevokeShareLinksRequest) (schema.RevokeShareLinksResponse, error)
+	ViewShareLink(ctx context.Context, req schema.ViewShareLinkRequest) (schema.ViewShareLinkResponse, error)
 	SendPrompt(ctx context.Context, req schema.SendPromptRequest) (schema.SendPromptResponse, error)
 	SetModel(ctx context.Context, req schema.SetModelRequest) (schema.SetModelResponse, error)
 	SwitchRepo(ctx context.Context, req schema.SwitchRepoRequest) (schema.SwitchRepoResponse, error)
@@ -19,19 +26,106 @@ type Service interface {
 	StopSession(ctx context.Context, req schema.StopSessionRequest) (schema.StopSessionResponse, error)
 	RenewSession(ctx context.Context, req schema.RenewSessionRequest) (schema.RenewSessionResponse, error)
 	SetTheme(ctx context.Context, req schema.SetThemeRequest) (schema.SetThemeResponse, error)
+	SetTimezone(ctx context.Context, req schema.SetTimezoneRequest) (schema.SetTimezoneResponse, error)
 	GetBuffer(ctx context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error)
 	ScrollBuffer(ctx context.Context, req schema.ScrollBufferRequest) (schema.ScrollBufferResponse, error)
+	SearchAll(ctx context.Context, req schema.SearchAllRequest) (schema.SearchAllResponse, error)
 	AppendOutput(ctx context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error)
 	AppendSystemOutput(ctx context.Context, req schema.AppendSystemOutputRequest) (schema.AppendSystemOutputResponse, error)
 	GetSystemBuffer(ctx context.Context, req schema.GetSystemBufferRequest) (schema.GetSystemBufferResponse, error)
 	GetHistory(ctx context.Context, req schema.GetHistoryRequest) (schema.GetHistoryResponse, error)
 	AppendHistory(ctx context.Context, req schema.AppendHistoryRequest) (schema.AppendHistoryResponse, error)
+	GetLastPrompt(ctx context.Context, req schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error)
 	SaveCodexAuth(ctx context.Context, req schema.SaveCodexAuthRequest) (schema.SaveCodexAuthResponse, error)
+	UpdateOnboarding(ctx context.Context, req schema.UpdateOnboardingRequest) (schema.UpdateOnboardingResponse, error)
 	GetTabUsage(ctx context.Context, req schema.GetTabUsageRequest) (schema.GetTabUsageResponse, error)
+	GetTabStatus(ctx context.Context, req schema.GetTabStatusRequest) (schema.GetTabStatusResponse, error)
+	ListOutputFilters(ctx context.Context, req schema.ListOutputFiltersRequest) (schema.ListOutputFiltersResponse, error)
+	AddOutputFilter(ctx context.Context, req schema.AddOutputFilterRequest) (schema.AddOutputFilterResponse, error)
+	RemoveOutputFilter(ctx context.Context, req schema.RemoveOutputFilterRequest) (schema.RemoveOutputFilterResponse, error)
+	ListRepoFiles(ctx context.Context, req schema.ListRepoFilesRequest) (schema.ListRepoFilesResponse, error)
+	ReadRepoFile(ctx context.Context, req schema.ReadRepoFileRequest) (schema.ReadRepoFileResponse, error)
+	WriteRepoArchive(ctx context.Context, req schema.WriteRepoArchiveRequest) (schema.WriteRepoArchiveResponse, error)
+	RecordActivity(ctx context.Context, req schema.RecordActivityRequest) (schema.RecordActivityResponse, error)
+	ListActivity(ctx context.Context, req schema.ListActivityRequest) (schema.ListActivityResponse, error)
+	ListAliases(ctx context.Context, req schema.ListAliasesRequest) (schema.ListAliasesResponse, error)
+	SetAlias(ctx context.Context, req schema.SetAliasRequest) (schema.SetAliasResponse, error)
+	RemoveAlias(ctx context.Context, req schema.RemoveAliasRequest) (schema.RemoveAliasResponse, error)
+	SetTabSummaries(ctx context.Context, req schema.SetTabSummariesRequest) (schema.SetTabSummariesResponse, error)
+	ListTabSummaries(ctx context.Context, req schema.ListTabSummariesRequest) (schema.ListTabSummariesResponse, error)
+	SetTabTool(ctx context.Context, req schema.SetTabToolRequest) (schema.SetTabToolResponse, error)
+	SetTabAutoCommit(ctx context.Context, req schema.SetTabAutoCommitRequest) (schema.SetTabAutoCommitResponse, error)
+	SetTabArchived(ctx context.Context, req schema.SetTabArchivedRequest) (schema.SetTabArchivedResponse, error)
+	SetTabLabel(ctx context.Context, req schema.SetTabLabelRequest) (schema.SetTabLabelResponse, error)
+	UpdateBatchRepos(ctx context.Context, req schema.UpdateBatchReposRequest) (schema.UpdateBatchReposResponse, error)
+	GetBatch(ctx context.Context, req schema.GetBatchRequest) (schema.GetBatchResponse, error)
+	StartBatch(ctx context.Context, req schema.StartBatchRequest) (schema.StartBatchResponse, error)
+	StopBatch(ctx context.Context, req schema.StopBatchRequest) (schema.StopBatchResponse, error)
+}
+
+// ActivityRecorder records entries in a user's activity feed.
+type ActivityRecorder interface {
+	RecordActivity(ctx context.Context, req schema.RecordActivityRequest) (schema.RecordActivityResponse, error)
+}
+
+// ActivityReporter is implemented by dependencies that report activity of
+// their own, such as runner providers starting containers.
+type ActivityReporter interface {
+	SetActivityRecorder(recorder ActivityRecorder)
+}
+
+// OutputAppender appends lines to a tab buffer.
+type OutputAppender interface {
+	AppendOutput(ctx context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error)
+}
+
+// OutputReporter is implemented by dependencies that write to tab buffers,
+// such as runner providers reporting refused network access.
+type OutputReporter interface {
+	SetOutputAppender(appender OutputAppender)
+}
+
+// AutoCommitter commits the changes a successful prompt run left in the repo
+// of a tab with /autocommit on. Failures are reported in the tab and never
+// fail the run.
+type AutoCommitter interface {
+	AutoCommit(ctx context.Context, req AutoCommitRequest)
+}
+
+// AutoCommitRequest identifies the run an auto-commit follows. UserID is the
+// tab owner.
+type AutoCommitRequest struct {
+	UserID schema.UserID
+	TabID  schema.TabID
+	RunID  schema.RunID
+}
+
+// AutoCommitReporter is implemented by services that hand successful runs to
+// an AutoCommitter.
+type AutoCommitReporter interface {
+	SetAutoCommitter(committer AutoCommitter)
+}
+
+// TokenMeter counts codex runs made outside prompts, such as commit message
+// generation, against the daily token budget.
+type TokenMeter interface {
+	// CheckTokenBudget returns schema.ErrTokenBudgetExceeded when userID has
+	// used up today's token budget.
+	CheckTokenBudget(ctx context.Context, userID schema.UserID) error
+	// RecordTokens adds tokens to what userID used today.
+	RecordTokens(ctx context.Context, userID schema.UserID, tokens int64)
 }
 
 // CommandTracker allows tracking long-running shell commands per tab.
 type CommandTracker interface {
 	RegisterCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle CommandHandle, cancel context.CancelFunc)
+	// RegisterPTYCommand is RegisterCommand for a command running on a
+	// pseudo-terminal, which InterruptCommand and ResizeCommands act on.
+	RegisterPTYCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle CommandHandle, cancel context.CancelFunc)
 	UnregisterCommand(userID schema.UserID, tabID schema.TabID, handle CommandHandle)
+	// InterruptCommand sends SIGINT to the tab's most recent command when it
+	// runs on a pseudo-terminal, and reports whether it did.
+	InterruptCommand(ctx context.Context, userID schema.UserID, tabID schema.TabID) (bool, error)
+	// ResizeCommands forwards a terminal size to the tab's PTY commands.
+	ResizeCommands(ctx context.Context, userID schema.UserID, tabID schema.TabID, rows, cols int)
 }
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+// closeRecordingProvider records the tabs whose runner was released.
+type closeRecordingProvider struct {
+	fakeRunnerProvider
+	mu     sync.Mutex
+	closed []schema.TabID
+}
+
+func (p *closeRecordingProvider) CloseTab(_ context.Context, req RunnerCloseRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = append(p.closed, req.TabID)
+	return nil
+}
+
+func listedTabIDs(t *testing.T, svc Service, ctx context.Context, user schema.UserID, includeArchived bool) []schema.TabID {
+	t.Helper()
+	resp, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user, IncludeArchived: includeArchived})
+	if err != nil {
+		t.Fatalf("list tabs: %v", err)
+	}
+	ids := make([]schema.TabID, 0, len(resp.Tabs))
+	for _, tab := range resp.Tabs {
+		ids = append(ids, tab.ID)
+	}
+	return ids
+}
+
+func TestArchivedTabsAreHiddenAndRestorable(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	provider := &closeRecordingProvider{fakeRunnerProvider: fakeRunnerProvider{runner: workedRunner{}}}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	svc, err := NewService(cfg, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}, RunnerProvider: provider})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	prefs := sessionprefs.New()
+	ctx := sessionprefs.WithContext(context.Background(), prefs)
+	first, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	second, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create second tab: %v", err)
+	}
+	if _, err := svc.ActivateTab(ctx, schema.ActivateTabRequest{UserID: user, TabID: first.Tab.ID}); err != nil {
+		t.Fatalf("activate tab: %v", err)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: first.Tab.ID, Lines: []string{"kept"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+
+	resp, err := svc.SetTabArchived(ctx, schema.SetTabArchivedRequest{UserID: user, TabID: first.Tab.ID, Archived: true})
+	if err != nil {
+		t.Fatalf("archive tab: %v", err)
+	}
+	if !resp.Tab.Archived {
+		t.Fatalf("expected an archived snapshot, got %+v", resp.Tab)
+	}
+	if got := listedTabIDs(t, svc, ctx, user, false); !slices.Equal(got, []schema.TabID{second.Tab.ID}) {
+		t.Fatalf("expected only the second tab listed, got %v", got)
+	}
+	if got := listedTabIDs(t, svc, ctx, user, true); !slices.Equal(got, []schema.TabID{first.Tab.ID, second.Tab.ID}) {
+		t.Fatalf("expected archived tabs with IncludeArchived, got %v", got)
+	}
+	if prefs.ActiveTab != second.Tab.ID {
+		t.Fatalf("expected the active tab to move off the archived tab, got %q", prefs.ActiveTab)
+	}
+	if !slices.Equal(provider.closed, []schema.TabID{first.Tab.ID}) {
+		t.Fatalf("expected the archived tab's runner to be released, got %v", provider.closed)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: first.Tab.ID, Prompt: "hello"}); !errors.Is(err, schema.ErrTabArchived) {
+		t.Fatalf("expected prompts to an archived tab to fail, got %v", err)
+	}
+
+	// The archived state survives a restart, and activating the tab
+	// restores it with its buffer.
+	reloaded, err := NewService(cfg, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}, RunnerProvider: provider})
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	if got := listedTabIDs(t, reloaded, ctx, user, false); !slices.Equal(got, []schema.TabID{second.Tab.ID}) {
+		t.Fatalf("expected the tab to stay archived after a restart, got %v", got)
+	}
+	activated, err := reloaded.ActivateTab(ctx, schema.ActivateTabRequest{UserID: user, TabID: first.Tab.ID})
+	if err != nil {
+		t.Fatalf("activate archived tab: %v", err)
+	}
+	if activated.Tab.Archived {
+		t.Fatalf("expected activating the tab to restore it, got %+v", activated.Tab)
+	}
+	if got := listedTabIDs(t, reloaded, ctx, user, false); !slices.Equal(got, []schema.TabID{first.Tab.ID, second.Tab.ID}) {
+		t.Fatalf("expected the restored tab listed again, got %v", got)
+	}
+	buf, err := reloaded.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: first.Tab.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if !slices.Contains(buf.Buffer.Lines, "kept") {
+		t.Fatalf("expected the buffer to survive archiving, got %q", buf.Buffer.Lines)
+	}
+}
+
+func TestArchiveRejectsRunningTab(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	impl := svc.(*service)
+	impl.mu.Lock()
+	impl.userTabs[user].tabs[created.Tab.ID].Status = schema.TabStatusRunning
+	impl.mu.Unlock()
+	if _, err := svc.SetTabArchived(ctx, schema.SetTabArchivedRequest{UserID: user, TabID: created.Tab.ID, Archived: true}); !errors.Is(err, schema.ErrTabBusy) {
+		t.Fatalf("expected archiving a running tab to fail, got %v", err)
+	}
+}
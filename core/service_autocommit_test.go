@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// recordingCommitter records auto-commits and, while one runs, what the
+// service reports for the repo.
+type recordingCommitter struct {
+	svc   Service
+	other schema.TabID
+
+	mu       sync.Mutex
+	requests []AutoCommitRequest
+	status   schema.TabStatus
+	busyErr  error
+	nested   bool
+}
+
+func (c *recordingCommitter) AutoCommit(ctx context.Context, req AutoCommitRequest) {
+	tabs, _ := c.svc.ListTabs(ctx, schema.ListTabsRequest{UserID: req.UserID})
+	_, busyErr := c.svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: req.UserID, TabID: c.other, Prompt: "meanwhile"})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	for _, tab := range tabs.Tabs {
+		if tab.ID == req.TabID {
+			c.status = tab.Status
+		}
+	}
+	c.busyErr = busyErr
+	c.nested = ctx.Value(autoCommitKey{}) != nil
+}
+
+func (c *recordingCommitter) calls() []AutoCommitRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]AutoCommitRequest(nil), c.requests...)
+}
+
+func TestAutoCommitAfterSuccessfulRun(t *testing.T) {
+	runner := newGatedRunner()
+	svc, one, two := newRepoLockService(t, runner, "")
+	committer := &recordingCommitter{svc: svc, other: two}
+	svc.(AutoCommitReporter).SetAutoCommitter(committer)
+	ctx := context.Background()
+
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: one, Prompt: "edit"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	runner.waitStarted(t, 1)
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", one)
+	if calls := committer.calls(); len(calls) != 0 {
+		t.Fatalf("expected no auto-commit with the setting off, got %+v", calls)
+	}
+
+	resp, err := svc.SetTabAutoCommit(ctx, schema.SetTabAutoCommitRequest{UserID: "alice", TabID: one, Enabled: true})
+	if err != nil || !resp.Tab.AutoCommit {
+		t.Fatalf("expected autocommit on, got %+v %v", resp, err)
+	}
+	sent, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: one, Prompt: "edit again"})
+	if err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	runner.waitStarted(t, 1)
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", one)
+
+	calls := committer.calls()
+	if len(calls) != 1 || calls[0].UserID != "alice" || calls[0].TabID != one || calls[0].RunID != sent.Tab.RunID {
+		t.Fatalf("expected one auto-commit of the run, got %+v", calls)
+	}
+	if committer.status != schema.TabStatusRunning {
+		t.Fatalf("expected the tab to stay busy during the auto-commit, got %q", committer.status)
+	}
+	if !errors.Is(committer.busyErr, schema.ErrRepoBusy) {
+		t.Fatalf("expected the repo to stay locked during the auto-commit, got %v", committer.busyErr)
+	}
+	if !committer.nested {
+		t.Fatalf("expected the auto-commit context to be marked")
+	}
+}
+
+func TestAutoCommitSkipsFailedAndNestedRuns(t *testing.T) {
+	runner := newGatedRunner("demo")
+	svc, one, two := newRepoLockService(t, runner, "")
+	committer := &recordingCommitter{svc: svc, other: two}
+	svc.(AutoCommitReporter).SetAutoCommitter(committer)
+	ctx := context.Background()
+	if _, err := svc.SetTabAutoCommit(ctx, schema.SetTabAutoCommitRequest{UserID: "alice", TabID: one, Enabled: true}); err != nil {
+		t.Fatalf("set autocommit: %v", err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: one, Prompt: "edit"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	runner.waitStarted(t, 1)
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", one)
+	if calls := committer.calls(); len(calls) != 0 {
+		t.Fatalf("expected no auto-commit after a failed run, got %+v", calls)
+	}
+
+	runner.fail = nil
+	nested := context.WithValue(ctx, autoCommitKey{}, true)
+	if _, err := svc.SendPrompt(nested, schema.SendPromptRequest{UserID: "alice", TabID: one, Prompt: "edit"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	runner.waitStarted(t, 1)
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", one)
+	if calls := committer.calls(); len(calls) != 0 {
+		t.Fatalf("expected a run started by an auto-commit not to trigger another, got %+v", calls)
+	}
+}
+
+func TestAutoCommitSettingIsPersisted(t *testing.T) {
+	stateDir := t.TempDir()
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	open := func() Service {
+		svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+			RunnerProvider: fakeRunnerProvider{runner: newGatedRunner()},
+			RepoResolver:   fakeRepoResolver{repo: repo},
+		})
+		if err != nil {
+			t.Fatalf("new service: %v", err)
+		}
+		return svc
+	}
+	ctx := context.Background()
+	svc := open()
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: "alice", RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SetTabAutoCommit(ctx, schema.SetTabAutoCommitRequest{UserID: "alice", TabID: created.Tab.ID, Enabled: true}); err != nil {
+		t.Fatalf("set autocommit: %v", err)
+	}
+
+	tabs, err := open().ListTabs(ctx, schema.ListTabsRequest{UserID: "alice"})
+	if err != nil || len(tabs.Tabs) != 1 || !tabs.Tabs[0].AutoCommit {
+		t.Fatalf("expected autocommit to survive a restart, got %+v %v", tabs.Tabs, err)
+	}
+}
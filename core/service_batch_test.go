@@ -0,0 +1,284 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestBatchRunsReposWithBoundedParallelism(t *testing.T) {
+	repoRoot := t.TempDir()
+	runner := newGatedRunner("c")
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir(), BatchParallelism: 2}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   namedRepoResolver{root: repoRoot},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	existing, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: "b"})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.StartBatch(ctx, schema.StartBatchRequest{UserID: user, Prompt: "bump deps"}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected a batch without repos to be rejected, got %v", err)
+	}
+	repos, err := svc.UpdateBatchRepos(ctx, schema.UpdateBatchReposRequest{UserID: user, Add: []schema.RepoName{"a", "b", "c", "a"}})
+	if err != nil {
+		t.Fatalf("add repos: %v", err)
+	}
+	if len(repos.Repos) != 3 {
+		t.Fatalf("expected three repos, got %v", repos.Repos)
+	}
+	if _, err := svc.StartBatch(ctx, schema.StartBatchRequest{UserID: user, Prompt: "bump deps"}); err != nil {
+		t.Fatalf("start batch: %v", err)
+	}
+	if _, err := svc.StartBatch(ctx, schema.StartBatchRequest{UserID: user, Prompt: "again"}); !errors.Is(err, schema.ErrBatchRunning) {
+		t.Fatalf("expected ErrBatchRunning, got %v", err)
+	}
+	runner.waitStarted(t, 2)
+	select {
+	case <-runner.started:
+		t.Fatal("expected the third repo to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	runner.release <- struct{}{}
+	runner.waitStarted(t, 1)
+	runner.release <- struct{}{}
+	runner.release <- struct{}{}
+
+	batch := waitForBatchFinished(t, svc, user)
+	want := map[schema.RepoName]schema.BatchRepoState{"a": schema.BatchRepoSucceeded, "b": schema.BatchRepoSucceeded, "c": schema.BatchRepoFailed}
+	for _, repo := range batch.Repos {
+		if repo.State != want[repo.Repo] {
+			t.Fatalf("repo %s: got %s, want %s (%+v)", repo.Repo, repo.State, want[repo.Repo], batch.Repos)
+		}
+		if repo.Repo == "b" && repo.TabID != existing.Tab.ID {
+			t.Fatalf("expected the idle tab of b to be reused, got %s", repo.TabID)
+		}
+	}
+	if runner.maxActive() != 2 {
+		t.Fatalf("expected two runs at once, got %d", runner.maxActive())
+	}
+	system, err := svc.GetSystemBuffer(ctx, schema.GetSystemBufferRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("system buffer: %v", err)
+	}
+	text := strings.Join(system.Buffer.Lines, "\n")
+	if !strings.Contains(text, "batch finished: 2 succeeded, 1 failed, 0 canceled") || !strings.Contains(text, "c: failed in ") {
+		t.Fatalf("expected a batch summary, got %q", system.Buffer.Lines)
+	}
+}
+
+func TestStopBatchCancelsPendingRepos(t *testing.T) {
+	repoRoot := t.TempDir()
+	runner := newGatedRunner()
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   namedRepoResolver{root: repoRoot},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	if _, err := svc.UpdateBatchRepos(ctx, schema.UpdateBatchReposRequest{UserID: user, Add: []schema.RepoName{"a", "b"}}); err != nil {
+		t.Fatalf("add repos: %v", err)
+	}
+	if _, err := svc.StartBatch(ctx, schema.StartBatchRequest{UserID: user, Prompt: "bump deps"}); err != nil {
+		t.Fatalf("start batch: %v", err)
+	}
+	runner.waitStarted(t, 1)
+	stopped, err := svc.StopBatch(ctx, schema.StopBatchRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("stop batch: %v", err)
+	}
+	if !stopped.Batch.Stopped || stopped.Batch.Repos[0].State != schema.BatchRepoRunning || stopped.Batch.Repos[1].State != schema.BatchRepoCanceled {
+		t.Fatalf("unexpected stopped batch: %+v", stopped.Batch)
+	}
+	runner.release <- struct{}{}
+	batch := waitForBatchFinished(t, svc, user)
+	if batch.Repos[0].State != schema.BatchRepoSucceeded || batch.Repos[1].State != schema.BatchRepoCanceled {
+		t.Fatalf("unexpected finished batch: %+v", batch.Repos)
+	}
+	if _, err := svc.StopBatch(ctx, schema.StopBatchRequest{UserID: user}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected stopping a finished batch to fail, got %v", err)
+	}
+}
+
+func TestBatchResumesAfterRestart(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	store, err := persist.NewStore(stateDir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	started := time.Now().Add(-time.Minute)
+	if err := store.SaveBatch(persist.BatchSnapshot{
+		User:  "alice",
+		Repos: []schema.RepoName{"a", "b", "c"},
+		Batch: &schema.BatchStatus{
+			Prompt:    "bump deps",
+			StartedAt: started,
+			Repos: []schema.BatchRepoStatus{
+				{Repo: "a", State: schema.BatchRepoSucceeded, StartedAt: started, FinishedAt: started.Add(time.Second)},
+				{Repo: "b", TabID: "gone", State: schema.BatchRepoRunning, StartedAt: started},
+				{Repo: "c", State: schema.BatchRepoPending},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("save batch: %v", err)
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: []schema.ExecEvent{{Type: schema.EventTurnCompleted}}}},
+		RepoResolver:   namedRepoResolver{root: repoRoot},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	batch := waitForBatchFinished(t, svc, "alice")
+	for _, repo := range batch.Repos {
+		if repo.State != schema.BatchRepoSucceeded {
+			t.Fatalf("expected every repo to succeed, got %+v", batch.Repos)
+		}
+	}
+	if batch.Repos[1].TabID == "gone" || batch.Repos[1].TabID == "" {
+		t.Fatalf("expected b to run again in a new tab, got %q", batch.Repos[1].TabID)
+	}
+	tabs, err := svc.ListTabs(context.Background(), schema.ListTabsRequest{UserID: "alice"})
+	if err != nil || len(tabs.Tabs) != 2 {
+		t.Fatalf("expected tabs for b and c only, got %+v (err %v)", tabs.Tabs, err)
+	}
+}
+
+func waitForBatchFinished(t *testing.T, svc Service, user schema.UserID) schema.BatchStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := svc.GetBatch(context.Background(), schema.GetBatchRequest{UserID: user})
+		if err != nil {
+			t.Fatalf("get batch: %v", err)
+		}
+		if resp.Batch != nil && !resp.Batch.Active() {
+			return *resp.Batch
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the batch to finish")
+	return schema.BatchStatus{}
+}
+
+// namedRepoResolver resolves any repo name under root.
+type namedRepoResolver struct {
+	root string
+}
+
+func (r namedRepoResolver) CreateRepo(_ context.Context, req CreateRepoRequest) (CreateRepoResponse, error) {
+	return CreateRepoResponse{Repo: schema.RepoRef{Name: req.Name, Path: filepath.Join(r.root, string(req.Name))}}, nil
+}
+
+func (r namedRepoResolver) ResolveRepo(_ context.Context, req ResolveRepoRequest) (ResolveRepoResponse, error) {
+	return ResolveRepoResponse{Repo: schema.RepoRef{Name: req.Name, Path: filepath.Join(r.root, string(req.Name))}}, nil
+}
+
+func (namedRepoResolver) ListRepos(context.Context, ListReposRequest) (ListReposResponse, error) {
+	return ListReposResponse{}, nil
+}
+
+func (namedRepoResolver) OpenOrCloneURL(context.Context, OpenOrCloneRequest) (OpenOrCloneResponse, error) {
+	return OpenOrCloneResponse{}, errors.New("clone not supported")
+}
+
+// gatedRunner holds every run until release receives a value, and fails
+// runs in the repos named in fail.
+type gatedRunner struct {
+	release chan struct{}
+	started chan struct{}
+	fail    []string
+
+	mu     sync.Mutex
+	active int
+	peak   int
+}
+
+func newGatedRunner(fail ...string) *gatedRunner {
+	return &gatedRunner{release: make(chan struct{}), started: make(chan struct{}, 8), fail: fail}
+}
+
+func (r *gatedRunner) Run(_ context.Context, req RunRequest) (RunHandle, error) {
+	r.mu.Lock()
+	r.active++
+	r.peak = max(r.peak, r.active)
+	r.mu.Unlock()
+	r.started <- struct{}{}
+	exitCode := 0
+	for _, name := range r.fail {
+		if filepath.Base(req.WorkingDir) == name {
+			exitCode = 1
+		}
+	}
+	return &gatedHandle{runner: r, exitCode: exitCode}, nil
+}
+
+func (*gatedRunner) RunCommand(context.Context, RunCommandRequest) (CommandHandle, error) {
+	return nil, errors.New("command not supported")
+}
+
+func (r *gatedRunner) waitStarted(t *testing.T, count int) {
+	t.Helper()
+	for range count {
+		select {
+		case <-r.started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a run to start")
+		}
+	}
+}
+
+func (r *gatedRunner) maxActive() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.peak
+}
+
+type gatedHandle struct {
+	runner   *gatedRunner
+	exitCode int
+	done     bool
+}
+
+func (h *gatedHandle) Events() EventStream { return h }
+
+func (h *gatedHandle) Next(ctx context.Context) (schema.ExecEvent, error) {
+	if h.done {
+		return schema.ExecEvent{}, io.EOF
+	}
+	select {
+	case <-h.runner.release:
+	case <-ctx.Done():
+		return schema.ExecEvent{}, ctx.Err()
+	}
+	h.done = true
+	h.runner.mu.Lock()
+	h.runner.active--
+	h.runner.mu.Unlock()
+	return schema.ExecEvent{Type: schema.EventTurnCompleted}, nil
+}
+
+func (h *gatedHandle) Signal(context.Context, ProcessSignal) error { return nil }
+
+func (h *gatedHandle) Wait(context.Context) (RunResult, error) {
+	return RunResult{ExitCode: h.exitCode}, nil
+}
+
+func (h *gatedHandle) Close() error { return nil }
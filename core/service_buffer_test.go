@@ -0,0 +1,213 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestGetBufferStructuredReturnsTypedLines(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	resolver := fakeRepoResolver{repo: repo}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: resolver})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{
+		UserID: user,
+		TabID:  tabID,
+		Lines:  []string{"> hello", schema.AgentMarker + "hi"},
+		Structured: []schema.BufferLine{
+			schema.Line(schema.LineKindError, "error: boom"),
+			schema.Line(schema.LineKindSeparator, "Worked for 1s"),
+		},
+	}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+
+	legacy, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 10})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if legacy.Buffer.Structured != nil {
+		t.Fatalf("expected no structured lines unless requested, got %+v", legacy.Buffer.Structured)
+	}
+	wantLegacy := []string{"> hello", schema.AgentMarker + "hi", "error: boom", schema.WorkedForMarker + "Worked for 1s"}
+	if len(legacy.Buffer.Lines) != len(wantLegacy) {
+		t.Fatalf("unexpected legacy lines: %q", legacy.Buffer.Lines)
+	}
+	for i, line := range wantLegacy {
+		if legacy.Buffer.Lines[i] != line {
+			t.Fatalf("legacy line %d: got %q want %q", i, legacy.Buffer.Lines[i], line)
+		}
+	}
+
+	resp, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 10, Structured: true})
+	if err != nil {
+		t.Fatalf("get structured buffer: %v", err)
+	}
+	wantKinds := []schema.LineKind{schema.LineKindPrompt, schema.LineKindAgent, schema.LineKindError, schema.LineKindSeparator}
+	if len(resp.Buffer.Structured) != len(wantKinds) {
+		t.Fatalf("unexpected structured lines: %+v", resp.Buffer.Structured)
+	}
+	for i, line := range resp.Buffer.Structured {
+		if line.Kind != wantKinds[i] {
+			t.Fatalf("line %d: got kind %q want %q", i, line.Kind, wantKinds[i])
+		}
+		if line.Timestamp.IsZero() {
+			t.Fatalf("line %d: expected append timestamp", i)
+		}
+	}
+	if resp.Buffer.Structured[0].Text != "hello" {
+		t.Fatalf("expected prompt text without prefix, got %q", resp.Buffer.Structured[0].Text)
+	}
+}
+
+func TestTransientOutputIsNotPersisted(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	open := func() Service {
+		svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+		if err != nil {
+			t.Fatalf("new service: %v", err)
+		}
+		return svc
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	svc := open()
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	before, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: tabID, Lines: []string{"status: generating commit message"}, Transient: true}); err != nil {
+		t.Fatalf("append transient: %v", err)
+	}
+	if _, err := svc.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: user, Lines: []string{"status: cloning"}, Transient: true}); err != nil {
+		t.Fatalf("append transient system output: %v", err)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: tabID, Lines: []string{"committed abc123"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+
+	live, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	lines := live.Buffer.Lines
+	if len(lines) < 2 || lines[len(lines)-2] != "status: generating commit message" || lines[len(lines)-1] != "committed abc123" {
+		t.Fatalf("expected the transient line shown live, got %q", lines)
+	}
+	if live.Buffer.TotalLines != before.Buffer.TotalLines+1 {
+		t.Fatalf("expected TotalLines to skip the transient line, got %d after %d", live.Buffer.TotalLines, before.Buffer.TotalLines)
+	}
+	system, err := svc.GetSystemBuffer(ctx, schema.GetSystemBufferRequest{UserID: user})
+	if err != nil || !slices.Contains(system.Buffer.Lines, "status: cloning") || system.Buffer.TotalLines != 0 {
+		t.Fatalf("expected the transient system line shown live, got %+v %v", system.Buffer, err)
+	}
+
+	err = filepath.WalkDir(stateDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte("status: ")) {
+			t.Errorf("transient line written to %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk state dir: %v", err)
+	}
+
+	reloaded, err := open().GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get reloaded buffer: %v", err)
+	}
+	if slices.Contains(reloaded.Buffer.Lines, "status: generating commit message") || !slices.Contains(reloaded.Buffer.Lines, "committed abc123") {
+		t.Fatalf("expected only the stored lines after a restart, got %q", reloaded.Buffer.Lines)
+	}
+}
+
+func TestModelAndSessionChangesAreMarkedInBuffer(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir, DefaultModel: "gpt-5.1-mini"}
+	svc, err := NewService(cfg, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+
+	changed, err := svc.SetModel(ctx, schema.SetModelRequest{UserID: user, TabID: tabID, Model: "gpt-5.2-codex", ModelReasoningEffort: "high"})
+	if err != nil {
+		t.Fatalf("set model: %v", err)
+	}
+	unchanged, err := svc.SetModel(ctx, schema.SetModelRequest{UserID: user, TabID: tabID, Model: "gpt-5.2-codex"})
+	if err != nil {
+		t.Fatalf("set model again: %v", err)
+	}
+	if !changed.Changed || unchanged.Changed {
+		t.Fatalf("expected only the first SetModel to report a change, got %v and %v", changed.Changed, unchanged.Changed)
+	}
+	if _, err := svc.RenewSession(ctx, schema.RenewSessionRequest{UserID: user, TabID: tabID}); err != nil {
+		t.Fatalf("renew session: %v", err)
+	}
+
+	want := []schema.BufferLine{
+		schema.Line(schema.LineKindSeparator, "model changed: gpt-5.1-mini → gpt-5.2-codex (high)"),
+		schema.Line(schema.LineKindSeparator, "session renewed (new context)"),
+	}
+	check := func(svc Service) {
+		t.Helper()
+		resp, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 10, Structured: true})
+		if err != nil {
+			t.Fatalf("get buffer: %v", err)
+		}
+		got := make([]schema.BufferLine, 0, len(resp.Buffer.Structured))
+		for _, line := range resp.Buffer.Structured {
+			got = append(got, schema.Line(line.Kind, line.Text))
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("unexpected buffer lines %+v, want %+v", got, want)
+		}
+	}
+	check(svc)
+
+	reloaded, err := NewService(cfg, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	check(reloaded)
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestReopenTabRestoresClosedTab(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir, ClosedTabTTL: time.Hour, ClosedTabsMax: 2}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	clock := time.Date(2025, time.January, 2, 12, 0, 0, 0, time.UTC)
+	svc.(*service).now = func() time.Time { return clock }
+	ctx := context.Background()
+	user := schema.UserID("alice")
+
+	var ids []schema.TabID
+	for range 3 {
+		created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+		if err != nil {
+			t.Fatalf("create tab: %v", err)
+		}
+		ids = append(ids, created.Tab.ID)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: ids[0], Lines: []string{"first tab output"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	if _, err := svc.AppendHistory(ctx, schema.AppendHistoryRequest{UserID: user, TabID: ids[0], Entry: "fix the bug"}); err != nil {
+		t.Fatalf("append history: %v", err)
+	}
+	for _, id := range ids {
+		if _, err := svc.CloseTab(ctx, schema.CloseTabRequest{UserID: user, TabID: id}); err != nil {
+			t.Fatalf("close tab: %v", err)
+		}
+		clock = clock.Add(time.Minute)
+	}
+
+	// Only ClosedTabsMax entries are kept, most recent first.
+	listed, err := svc.ListClosedTabs(ctx, schema.ListClosedTabsRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list closed: %v", err)
+	}
+	if len(listed.Tabs) != 2 || listed.Tabs[0].Tab.ID != ids[2] || listed.Tabs[1].Tab.ID != ids[1] {
+		t.Fatalf("unexpected closed tabs: %+v", listed.Tabs)
+	}
+	if !listed.Tabs[0].ExpiresAt.Equal(listed.Tabs[0].ClosedAt.Add(time.Hour)) {
+		t.Fatalf("unexpected expiry: %+v", listed.Tabs[0])
+	}
+
+	reopened, err := svc.ReopenTab(ctx, schema.ReopenTabRequest{UserID: user, Index: 2})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Tab.ID != ids[1] || reopened.Tab.Repo.Name != repo.Name {
+		t.Fatalf("unexpected reopened tab: %+v", reopened.Tab)
+	}
+	tabs, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list tabs: %v", err)
+	}
+	if len(tabs.Tabs) != 1 || tabs.Tabs[0].ID != ids[1] {
+		t.Fatalf("expected reopened tab to be open, got %+v", tabs.Tabs)
+	}
+
+	// Entries expire after the TTL; a restarted service prunes them on load.
+	clock = clock.Add(time.Hour)
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	reloaded.(*service).now = func() time.Time { return clock }
+	if _, err := reloaded.ReopenTab(ctx, schema.ReopenTabRequest{UserID: user}); !errors.Is(err, schema.ErrClosedTabNotFound) {
+		t.Fatalf("expected expired tab to be gone, got %v", err)
+	}
+}
+
+func TestReopenTabRestoresBufferHistoryAndSession(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := created.Tab.ID
+	impl := svc.(*service)
+	impl.mu.Lock()
+	impl.userTabs[user].tabs[tabID].SessionID = "session-1"
+	impl.mu.Unlock()
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: tabID, Lines: []string{"kept output"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	if _, err := svc.AppendHistory(ctx, schema.AppendHistoryRequest{UserID: user, TabID: tabID, Entry: "fix the bug"}); err != nil {
+		t.Fatalf("append history: %v", err)
+	}
+	if _, err := svc.CloseTab(ctx, schema.CloseTabRequest{UserID: user, TabID: tabID}); err != nil {
+		t.Fatalf("close tab: %v", err)
+	}
+
+	// Closed tabs survive a restart.
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	reopened, err := reloaded.ReopenTab(ctx, schema.ReopenTabRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Tab.ID != tabID || reopened.Tab.SessionID != "session-1" || reopened.Tab.Status != schema.TabStatusIdle {
+		t.Fatalf("unexpected reopened tab: %+v", reopened.Tab)
+	}
+	buffer, err := reloaded.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 10})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if len(buffer.Buffer.Lines) == 0 || buffer.Buffer.Lines[len(buffer.Buffer.Lines)-1] != "kept output" {
+		t.Fatalf("expected restored buffer, got %+v", buffer.Buffer.Lines)
+	}
+	history, err := reloaded.GetHistory(ctx, schema.GetHistoryRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get history: %v", err)
+	}
+	if len(history.Entries) != 1 || history.Entries[0] != "fix the bug" {
+		t.Fatalf("expected restored history, got %+v", history.Entries)
+	}
+}
+
+func TestClosedTabsPrunedOnTimer(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir(), ClosedTabTTL: 20 * time.Millisecond}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.CloseTab(ctx, schema.CloseTabRequest{UserID: user, TabID: created.Tab.ID}); err != nil {
+		t.Fatalf("close tab: %v", err)
+	}
+	impl := svc.(*service)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		impl.mu.Lock()
+		remaining := len(impl.userTabs[user].closed)
+		impl.mu.Unlock()
+		if remaining == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected timer to prune the closed tab")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
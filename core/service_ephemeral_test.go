@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestEphemeralTabNeverPersistsBuffer(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	deps := ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: workedRunner{}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir, ClosedTabTTL: time.Hour, ClosedTabsMax: 5}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+
+	kept, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name, Ephemeral: true})
+	if err != nil {
+		t.Fatalf("create ephemeral tab: %v", err)
+	}
+	tabID := created.Tab.ID
+	if !created.Tab.Ephemeral {
+		t.Fatalf("expected ephemeral snapshot, got %+v", created.Tab)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "the password is hunter2"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabID)
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: tabID, Lines: []string{"hunter2 in output"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	if _, err := svc.AppendHistory(ctx, schema.AppendHistoryRequest{UserID: user, TabID: tabID, Entry: "the password is hunter2"}); err != nil {
+		t.Fatalf("append history: %v", err)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: kept.Tab.ID, Lines: []string{"regular output"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil || !strings.Contains(strings.Join(buf.Buffer.Lines, "\n"), "hunter2 in output") {
+		t.Fatalf("expected the buffer to be kept in memory, got %v (err %v)", buf.Buffer.Lines, err)
+	}
+	status, err := svc.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: user, TabID: tabID})
+	if err != nil || !status.Status.Ephemeral {
+		t.Fatalf("expected ephemeral status, got %+v (err %v)", status.Status, err)
+	}
+	if _, err := svc.SetTabSummaries(ctx, schema.SetTabSummariesRequest{UserID: user, TabID: tabID, Enabled: true}); err == nil {
+		t.Fatal("expected summaries to be rejected for an ephemeral tab")
+	}
+	assertStateDirLacks(t, stateDir, "hunter2")
+	assertStateDirHas(t, stateDir, "regular output")
+
+	// Recently closed tabs are saved the same way.
+	if _, err := svc.CloseTab(ctx, schema.CloseTabRequest{UserID: user, TabID: tabID}); err != nil {
+		t.Fatalf("close tab: %v", err)
+	}
+	assertStateDirLacks(t, stateDir, "hunter2")
+
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	reopened, err := reloaded.ReopenTab(ctx, schema.ReopenTabRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Tab.ID != tabID || !reopened.Tab.Ephemeral || reopened.Tab.SessionID != "" {
+		t.Fatalf("unexpected reopened tab: %+v", reopened.Tab)
+	}
+	buf, err = reloaded.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if len(buf.Buffer.Lines) != 1 || !strings.Contains(buf.Buffer.Lines[0], ephemeralRestoredNotice) {
+		t.Fatalf("expected only the restore notice, got %q", buf.Buffer.Lines)
+	}
+	history, err := reloaded.GetHistory(ctx, schema.GetHistoryRequest{UserID: user, TabID: tabID, Scope: schema.HistoryScopeGlobal})
+	if err != nil || len(history.Entries) != 0 {
+		t.Fatalf("expected empty global history, got %v (err %v)", history.Entries, err)
+	}
+}
+
+func assertStateDirLacks(t *testing.T, dir, text string) {
+	t.Helper()
+	if path := findInDir(t, dir, text); path != "" {
+		t.Fatalf("found %q in %s", text, path)
+	}
+}
+
+func assertStateDirHas(t *testing.T, dir, text string) {
+	t.Helper()
+	if findInDir(t, dir, text) == "" {
+		t.Fatalf("expected %q under %s", text, dir)
+	}
+}
+
+// findInDir returns the first file under dir containing text.
+func findInDir(t *testing.T, dir, text string) string {
+	t.Helper()
+	var found string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || found != "" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(data), text) {
+			found = path
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", dir, err)
+	}
+	return found
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// fileRunner answers RunCommand from canned outputs keyed by command and
+// exits non-zero for unknown commands.
+type fileRunner struct {
+	outputs map[string][]string
+
+	mu       sync.Mutex
+	commands []RunCommandRequest
+}
+
+func (r *fileRunner) Run(context.Context, RunRequest) (RunHandle, error) {
+	return nil, errors.New("run not supported")
+}
+
+func (r *fileRunner) RunCommand(_ context.Context, req RunCommandRequest) (CommandHandle, error) {
+	r.mu.Lock()
+	r.commands = append(r.commands, req)
+	r.mu.Unlock()
+	lines, ok := r.outputs[req.Command]
+	if !ok {
+		return &exitCommandHandle{exitCode: 1}, nil
+	}
+	return &exitCommandHandle{lines: lines}, nil
+}
+
+type exitCommandHandle struct {
+	lines    []string
+	exitCode int
+}
+
+func (h *exitCommandHandle) Outputs() CommandStream {
+	return &staticCommandStream{lines: append([]string(nil), h.lines...)}
+}
+func (h *exitCommandHandle) Signal(context.Context, ProcessSignal) error { return nil }
+func (h *exitCommandHandle) Resize(context.Context, int, int) error      { return nil }
+func (h *exitCommandHandle) Wait(context.Context) (RunResult, error) {
+	return RunResult{ExitCode: h.exitCode}, nil
+}
+func (h *exitCommandHandle) Close() error { return nil }
+
+func newFileService(t *testing.T, runner Runner) (Service, schema.UserID, schema.TabID) {
+	t.Helper()
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: runner, info: RunnerInfo{RepoRoot: "/repos"}},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	return svc, user, tabResp.Tab.ID
+}
+
+const (
+	resolveSrc   = `realpath -e -- . './src' && find -H './src' -maxdepth 0 -printf '%y\t%s\t%T@\n'`
+	resolveMain  = `realpath -e -- . './src/main.go' && find -H './src/main.go' -maxdepth 0 -printf '%y\t%s\t%T@\n'`
+	resolveLink  = `realpath -e -- . './link' && find -H './link' -maxdepth 0 -printf '%y\t%s\t%T@\n'`
+	listSrc      = `find -H '/repos/alice/demo/src' -mindepth 1 -maxdepth 1 -printf '%y\t%s\t%m\t%T@\t%f\n'`
+	readMainFile = `base64 -- '/repos/alice/demo/src/main.go'`
+)
+
+func TestListRepoFilesParsesRunnerListing(t *testing.T) {
+	runner := &fileRunner{outputs: map[string][]string{
+		resolveSrc: {"/repos/alice/demo", "/repos/alice/demo/src", "d\t4096\t1767322800.5"},
+		listSrc: {
+			"f\t12\t644\t1767322800.25\tmain.go",
+			"d\t4096\t755\t1767322801.0\tinternal",
+			"l\t7\t777\t1767322802.0\tcurrent",
+			"garbage",
+		},
+	}}
+	svc, user, tabID := newFileService(t, runner)
+
+	resp, err := svc.ListRepoFiles(context.Background(), schema.ListRepoFilesRequest{UserID: user, TabID: tabID, Path: "src/"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if resp.Path != "src" || len(resp.Entries) != 3 {
+		t.Fatalf("unexpected listing: %+v", resp)
+	}
+	dir, link, file := resp.Entries[0], resp.Entries[1], resp.Entries[2]
+	if dir.Name != "internal" || dir.Type != "dir" || dir.Mode != "drwxr-xr-x" {
+		t.Fatalf("expected directories first, got %+v", dir)
+	}
+	if link.Name != "current" || link.Type != "symlink" {
+		t.Fatalf("unexpected symlink entry: %+v", link)
+	}
+	wantTime := time.Unix(1767322800, 250000000).UTC()
+	if file.Name != "main.go" || file.Size != 12 || file.Mode != "-rw-r--r--" || !file.ModTime.Equal(wantTime) {
+		t.Fatalf("unexpected file entry: %+v", file)
+	}
+	for _, cmd := range runner.commands {
+		if cmd.WorkingDir != "/repos/alice/demo" {
+			t.Fatalf("expected commands in the mapped repo path, got %q", cmd.WorkingDir)
+		}
+	}
+}
+
+func TestReadRepoFileDecodesContents(t *testing.T) {
+	content := []byte("package main\x00\n")
+	runner := &fileRunner{outputs: map[string][]string{
+		resolveMain:  {"/repos/alice/demo", "/repos/alice/demo/src/main.go", "f\t14\t1767322800"},
+		readMainFile: {base64.StdEncoding.EncodeToString(content)},
+	}}
+	svc, user, tabID := newFileService(t, runner)
+
+	resp, err := svc.ReadRepoFile(context.Background(), schema.ReadRepoFileRequest{UserID: user, TabID: tabID, Path: "src/main.go"})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(resp.Content) != string(content) || resp.Size != int64(len(content)) {
+		t.Fatalf("unexpected content: %+v", resp)
+	}
+	if _, err := svc.ReadRepoFile(context.Background(), schema.ReadRepoFileRequest{UserID: user, TabID: tabID, Path: "src/main.go", MaxBytes: 4}); !errors.Is(err, schema.ErrFileTooLarge) {
+		t.Fatalf("expected size cap error, got %v", err)
+	}
+}
+
+func TestRepoFilesRejectPathsOutsideRepo(t *testing.T) {
+	runner := &fileRunner{outputs: map[string][]string{
+		resolveLink: {"/repos/alice/demo", "/repos/alice/demo-secrets/key", "f\t10\t1767322800"},
+	}}
+	svc, user, tabID := newFileService(t, runner)
+	ctx := context.Background()
+
+	for _, p := range []string{"../bob/demo", "src/../../..", "/etc/passwd"} {
+		if _, err := svc.ListRepoFiles(ctx, schema.ListRepoFilesRequest{UserID: user, TabID: tabID, Path: p}); !errors.Is(err, schema.ErrInvalidPath) {
+			t.Fatalf("expected %q to be rejected, got %v", p, err)
+		}
+	}
+	if len(runner.commands) != 0 {
+		t.Fatalf("expected traversal to be rejected before reaching the runner, got %+v", runner.commands)
+	}
+	if _, err := svc.ReadRepoFile(ctx, schema.ReadRepoFileRequest{UserID: user, TabID: tabID, Path: "link"}); !errors.Is(err, schema.ErrInvalidPath) {
+		t.Fatalf("expected symlink escape to be rejected, got %v", err)
+	}
+	if _, err := svc.ReadRepoFile(ctx, schema.ReadRepoFileRequest{UserID: user, TabID: tabID, Path: "missing"}); !errors.Is(err, schema.ErrFileNotFound) {
+		t.Fatalf("expected missing file error, got %v", err)
+	}
+}
+
+func TestWriteRepoArchiveStreamsDecodedArchive(t *testing.T) {
+	archive := bytes.Repeat([]byte("\x1f\x8b archive bytes "), 20)
+	encoded := base64.StdEncoding.EncodeToString(archive)
+	var lines []string
+	for len(encoded) > 76 {
+		lines = append(lines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	lines = append(lines, encoded)
+	headCmd := archiveCommand("src", schema.ArchiveFormatTarGz, false, schema.DefaultRepoArchiveMaxBytes)
+	runner := &fileRunner{outputs: map[string][]string{
+		resolveSrc: {"/repos/alice/demo", "/repos/alice/demo/src", "d\t4096\t1767322800"},
+		headCmd:    lines,
+		// A runner that skips its own size check is still capped locally.
+		archiveCommand("src", schema.ArchiveFormatTarGz, false, 16): lines,
+	}}
+	svc, user, tabID := newFileService(t, runner)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	resp, err := svc.WriteRepoArchive(ctx, schema.WriteRepoArchiveRequest{UserID: user, TabID: tabID, Path: "src", Writer: &out})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), archive) || resp.Size != int64(len(archive)) {
+		t.Fatalf("unexpected archive contents (%d bytes, resp %+v)", out.Len(), resp)
+	}
+	if resp.Name != "demo-src.tar.gz" || resp.Format != schema.ArchiveFormatTarGz {
+		t.Fatalf("unexpected archive response: %+v", resp)
+	}
+	if !strings.Contains(headCmd, "git archive --format=tar.gz HEAD -- './src'") {
+		t.Fatalf("expected git archive of HEAD, got %q", headCmd)
+	}
+	if cmd := archiveCommand(".", schema.ArchiveFormatTar, true, 10); !strings.Contains(cmd, "tar --exclude-vcs -cf - -- .") || !strings.Contains(cmd, "-le 10 ] || exit 3") {
+		t.Fatalf("unexpected worktree command %q", cmd)
+	}
+
+	out.Reset()
+	if _, err := svc.WriteRepoArchive(ctx, schema.WriteRepoArchiveRequest{UserID: user, TabID: tabID, Path: "src", MaxBytes: 16, Writer: &out}); !errors.Is(err, schema.ErrFileTooLarge) {
+		t.Fatalf("expected size cap error, got %v", err)
+	}
+	if _, err := svc.WriteRepoArchive(ctx, schema.WriteRepoArchiveRequest{UserID: user, TabID: tabID, Path: "src", Format: "zip", Writer: &out}); !errors.Is(err, schema.ErrInvalidArchiveFormat) {
+		t.Fatalf("expected invalid format error, got %v", err)
+	}
+	if _, err := svc.WriteRepoArchive(ctx, schema.WriteRepoArchiveRequest{UserID: user, TabID: "other", Path: "src", Writer: &out}); !errors.Is(err, schema.ErrTabNotFound) {
+		t.Fatalf("expected unknown tab to be rejected, got %v", err)
+	}
+}
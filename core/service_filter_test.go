@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestOutputFiltersManageAndPersist(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	resolver := fakeRepoResolver{repo: repo}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: resolver})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+
+	if _, err := svc.AddOutputFilter(ctx, schema.AddOutputFilterRequest{UserID: user, TabID: tabID, Pattern: "("}); !errors.Is(err, schema.ErrInvalidOutputFilter) {
+		t.Fatalf("expected invalid regex to be rejected, got %v", err)
+	}
+	for _, pattern := range []string{`^ok\s`, `^=== RUN`, `^--- PASS`} {
+		if _, err := svc.AddOutputFilter(ctx, schema.AddOutputFilterRequest{UserID: user, TabID: tabID, Pattern: pattern}); err != nil {
+			t.Fatalf("add filter %q: %v", pattern, err)
+		}
+	}
+	removed, err := svc.RemoveOutputFilter(ctx, schema.RemoveOutputFilterRequest{UserID: user, TabID: tabID, Index: 2})
+	if err != nil {
+		t.Fatalf("remove filter: %v", err)
+	}
+	if removed.Removed != `^=== RUN` || !slices.Equal(removed.Patterns, []string{`^ok\s`, `^--- PASS`}) {
+		t.Fatalf("unexpected remove result: %+v", removed)
+	}
+	if _, err := svc.RemoveOutputFilter(ctx, schema.RemoveOutputFilterRequest{UserID: user, TabID: tabID, Index: 3}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+
+	svc2, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: resolver})
+	if err != nil {
+		t.Fatalf("new service reload: %v", err)
+	}
+	listed, err := svc2.ListOutputFilters(ctx, schema.ListOutputFiltersRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("list filters: %v", err)
+	}
+	if !slices.Equal(listed.Patterns, []string{`^ok\s`, `^--- PASS`}) {
+		t.Fatalf("unexpected filters after reload: %v", listed.Patterns)
+	}
+}
+
+func TestCommandExecutionOutputFilterSuppressesLines(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: commandRunner{outputLines: 2500}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.AddOutputFilter(context.Background(), schema.AddOutputFilterRequest{UserID: user, TabID: tabID, Pattern: `^line ([2-9]|\d{2,})$`}); err != nil {
+		t.Fatalf("add filter: %v", err)
+	}
+	ctx := sessionprefs.WithContext(context.Background(), sessionprefs.New())
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+
+	want := []string{
+		schema.CommandMarker + "$ echo hello",
+		schema.CommandMarker + "line 1",
+		schema.CommandMarker + "exit code: 0",
+		schema.CommandMarker + `(suppressed 2,499 lines matching /^line ([2-9]|\d{2,})$/)`,
+	}
+	deadline := time.Now().Add(500 * time.Millisecond)
+	var commandLines []string
+	for time.Now().Before(deadline) {
+		buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 200})
+		if err != nil {
+			t.Fatalf("get buffer: %v", err)
+		}
+		commandLines = filterLinesWithPrefix(buf.Buffer.Lines, schema.CommandMarker)
+		if len(commandLines) == len(want) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !slices.Equal(commandLines, want) {
+		t.Fatalf("unexpected command lines:\n%q", commandLines)
+	}
+}
+
+func TestFormatSuppressedLine(t *testing.T) {
+	filters := compileOutputFilters([]string{"a", "b", "c"})
+	if got := formatSuppressedLine(filters, []int{1, 0, 0}); got != "(suppressed 1 line matching /a/)" {
+		t.Fatalf("unexpected single summary: %q", got)
+	}
+	if got := formatSuppressedLine(filters, []int{1000, 0, 234}); got != "(suppressed 1,234 lines matching /a/, /c/)" {
+		t.Fatalf("unexpected multi summary: %q", got)
+	}
+	if got := formatSuppressedLine(filters, nil); got != "" {
+		t.Fatalf("expected empty summary, got %q", got)
+	}
+}
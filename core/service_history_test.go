@@ -2,9 +2,12 @@ package core
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
+	"slices"
 	"testing"
 
+	"pkt.systems/centaurx/internal/sessionprefs"
 	"pkt.systems/centaurx/schema"
 )
 
@@ -79,3 +82,86 @@ func TestHistoryPersistsAndDedupes(t *testing.T) {
 		t.Fatalf("unexpected history after reload: %v", hist2.Entries)
 	}
 }
+
+func TestGlobalHistoryAcrossTabs(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	resolver := fakeRepoResolver{repo: repo}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir, HistoryMax: 2, GlobalHistoryMax: 3}
+	svc, err := NewService(cfg, ServiceDeps{RepoResolver: resolver})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	var tabs []schema.TabID
+	for range 2 {
+		resp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+		if err != nil {
+			t.Fatalf("create tab: %v", err)
+		}
+		tabs = append(tabs, resp.Tab.ID)
+	}
+	for _, step := range []struct {
+		tab   schema.TabID
+		entry string
+	}{
+		{tabs[0], "one"},
+		{tabs[1], "two"},
+		{tabs[1], "two"},
+		{tabs[0], "three"},
+		{tabs[0], "four"},
+	} {
+		if _, err := svc.AppendHistory(ctx, schema.AppendHistoryRequest{UserID: user, TabID: step.tab, Entry: step.entry}); err != nil {
+			t.Fatalf("append history: %v", err)
+		}
+	}
+
+	tabHist, err := svc.GetHistory(ctx, schema.GetHistoryRequest{UserID: user, TabID: tabs[0]})
+	if err != nil {
+		t.Fatalf("get tab history: %v", err)
+	}
+	if tabHist.Scope != schema.HistoryScopeTab || !slices.Equal(tabHist.Entries, []string{"three", "four"}) {
+		t.Fatalf("unexpected tab history: %+v", tabHist)
+	}
+	want := []string{"two", "three", "four"}
+	global, err := svc.GetHistory(ctx, schema.GetHistoryRequest{UserID: user, Scope: schema.HistoryScopeGlobal})
+	if err != nil {
+		t.Fatalf("get global history: %v", err)
+	}
+	if !slices.Equal(global.Entries, want) {
+		t.Fatalf("unexpected global history: %v", global.Entries)
+	}
+	for _, item := range global.Items {
+		if item.Time.IsZero() {
+			t.Fatalf("expected timestamped global entries, got %+v", global.Items)
+		}
+	}
+
+	prefs := sessionprefs.New()
+	prefs.GlobalHistory = true
+	prefCtx := sessionprefs.WithContext(ctx, prefs)
+	viaPref, err := svc.GetHistory(prefCtx, schema.GetHistoryRequest{UserID: user, TabID: tabs[1]})
+	if err != nil {
+		t.Fatalf("get history via pref: %v", err)
+	}
+	if viaPref.Scope != schema.HistoryScopeGlobal || !slices.Equal(viaPref.Entries, want) {
+		t.Fatalf("expected session preference to select global history, got %+v", viaPref)
+	}
+	if _, err := svc.GetHistory(ctx, schema.GetHistoryRequest{UserID: user, TabID: tabs[0], Scope: "bogus"}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected invalid scope error, got %v", err)
+	}
+
+	svc2, err := NewService(cfg, ServiceDeps{RepoResolver: resolver})
+	if err != nil {
+		t.Fatalf("new service reload: %v", err)
+	}
+	reloaded, err := svc2.GetHistory(ctx, schema.GetHistoryRequest{UserID: user, Scope: schema.HistoryScopeGlobal})
+	if err != nil {
+		t.Fatalf("get global history reload: %v", err)
+	}
+	if !slices.Equal(reloaded.Entries, want) {
+		t.Fatalf("unexpected global history after reload: %v", reloaded.Entries)
+	}
+}
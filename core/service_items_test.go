@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestItemsRenderOnceAcrossEvents(t *testing.T) {
+	started := func(item schema.ItemEvent) schema.ExecEvent {
+		return schema.ExecEvent{Type: schema.EventItemStarted, Item: &item}
+	}
+	updated := func(item schema.ItemEvent) schema.ExecEvent {
+		return schema.ExecEvent{Type: schema.EventItemUpdated, Item: &item}
+	}
+	completed := func(item schema.ItemEvent) schema.ExecEvent {
+		return schema.ExecEvent{Type: schema.EventItemCompleted, Item: &item}
+	}
+	changes := []schema.FileChange{{Path: "main.go", Kind: "update"}}
+	open := []schema.TodoItem{{Text: "write tests"}}
+	done := []schema.TodoItem{{Text: "write tests", Completed: true}}
+	cases := []struct {
+		name   string
+		events []schema.ExecEvent
+		line   string
+		want   int
+	}{
+		{
+			name: "agent message",
+			events: []schema.ExecEvent{
+				started(schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "Hel"}),
+				updated(schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "Hello"}),
+				completed(schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "Hello"}),
+				completed(schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "Hello"}),
+			},
+			line: "Hel",
+			want: 1,
+		},
+		{
+			name: "file change",
+			events: []schema.ExecEvent{
+				started(schema.ItemEvent{ID: "f1", Type: schema.ItemFileChange, Changes: changes, Status: "in_progress"}),
+				completed(schema.ItemEvent{ID: "f1", Type: schema.ItemFileChange, Changes: changes, Status: "completed"}),
+			},
+			line: "- update main.go",
+			want: 1,
+		},
+		{
+			name: "web search",
+			events: []schema.ExecEvent{
+				started(schema.ItemEvent{ID: "w1", Type: schema.ItemWebSearch, Query: "go generics"}),
+				completed(schema.ItemEvent{ID: "w1", Type: schema.ItemWebSearch, Query: "go generics"}),
+			},
+			line: "web search: go generics",
+			want: 1,
+		},
+		{
+			name: "mcp tool call",
+			events: []schema.ExecEvent{
+				started(schema.ItemEvent{ID: "m1", Type: schema.ItemMcpToolCall}),
+				updated(schema.ItemEvent{ID: "m1", Type: schema.ItemMcpToolCall}),
+				completed(schema.ItemEvent{ID: "m1", Type: schema.ItemMcpToolCall}),
+			},
+			line: "mcp_tool_call event",
+			want: 1,
+		},
+		{
+			name: "todo list",
+			events: []schema.ExecEvent{
+				started(schema.ItemEvent{ID: "t1", Type: schema.ItemTodoList, Items: open}),
+				updated(schema.ItemEvent{ID: "t1", Type: schema.ItemTodoList, Items: open}),
+				updated(schema.ItemEvent{ID: "t1", Type: schema.ItemTodoList, Items: done}),
+				completed(schema.ItemEvent{ID: "t1", Type: schema.ItemTodoList, Items: done}),
+			},
+			line: "write tests",
+			want: 2,
+		},
+		{
+			name: "error",
+			events: []schema.ExecEvent{
+				started(schema.ItemEvent{ID: "e1", Type: schema.ItemError}),
+				completed(schema.ItemEvent{ID: "e1", Type: schema.ItemError}),
+			},
+			line: "error event",
+			want: 1,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := append(tc.events, schema.ExecEvent{Type: schema.EventTurnCompleted})
+			count := 0
+			for _, line := range runItemEvents(t, events) {
+				if strings.Contains(line, tc.line) {
+					count++
+				}
+			}
+			if count != tc.want {
+				t.Fatalf("expected %d lines with %q, got %d", tc.want, tc.line, count)
+			}
+		})
+	}
+}
+
+// runItemEvents runs a prompt whose run emits events and returns the tab
+// buffer once the run finished.
+func runItemEvents(t *testing.T, events []schema.ExecEvent) []string {
+	t.Helper()
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: events}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	ctx := sessionprefs.WithContext(context.Background(), sessionprefs.New())
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+	buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID, Limit: 200})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	return buf.Buffer.Lines
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/internal/userhome"
+	"pkt.systems/centaurx/schema"
+)
+
+func systemLines(t *testing.T, svc Service, user schema.UserID) []string {
+	t.Helper()
+	resp, err := svc.GetSystemBuffer(context.Background(), schema.GetSystemBufferRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("get system buffer: %v", err)
+	}
+	return resp.Buffer.Lines
+}
+
+func checklistMarks(lines []string) []string {
+	var marks []string
+	for _, line := range lines {
+		if strings.Contains(line, "[✓]") {
+			marks = append(marks, "done")
+		} else if strings.Contains(line, "[ ]") {
+			marks = append(marks, "todo")
+		}
+	}
+	return marks
+}
+
+func TestOnboardingChecklistProgression(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+
+	lines := systemLines(t, svc, user)
+	if !slices.ContainsFunc(lines, func(line string) bool { return strings.HasSuffix(line, "Getting started (0/3)") }) {
+		t.Fatalf("expected the checklist for a new user, got %q", lines)
+	}
+	if got := checklistMarks(lines); !slices.Equal(got, []string{"todo", "todo", "todo"}) {
+		t.Fatalf("expected three open steps, got %v in %q", got, lines)
+	}
+
+	if _, err := svc.SaveCodexAuth(ctx, schema.SaveCodexAuthRequest{UserID: user, AuthJSON: []byte(`{"token":"x"}`)}); err != nil {
+		t.Fatalf("save codex auth: %v", err)
+	}
+	if got := checklistMarks(systemLines(t, svc, user)); !slices.Equal(got, []string{"done", "todo", "todo"}) {
+		t.Fatalf("expected codex auth checked off, got %v", got)
+	}
+
+	resp, err := svc.UpdateOnboarding(ctx, schema.UpdateOnboardingRequest{UserID: user, Complete: schema.OnboardingPubKey})
+	if err != nil {
+		t.Fatalf("update onboarding: %v", err)
+	}
+	if !resp.Onboarding.Active || len(resp.Onboarding.Done) != 2 {
+		t.Fatalf("expected two steps done, got %+v", resp.Onboarding)
+	}
+
+	// Progress survives a restart.
+	svc, err = NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	if got := checklistMarks(systemLines(t, svc, user)); !slices.Equal(got, []string{"done", "done", "todo"}) {
+		t.Fatalf("expected the progress restored, got %v", got)
+	}
+
+	if _, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name}); err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	lines = systemLines(t, svc, user)
+	if got := checklistMarks(lines); len(got) != 0 || !slices.Contains(lines, onboardingCompleteLine) {
+		t.Fatalf("expected the checklist replaced by the completion line, got %q", lines)
+	}
+	resp, err = svc.UpdateOnboarding(ctx, schema.UpdateOnboardingRequest{UserID: user})
+	if err != nil || resp.Onboarding.Active {
+		t.Fatalf("expected onboarding finished, got %+v: %v", resp.Onboarding, err)
+	}
+}
+
+func TestOnboardingDetectsAuthAndTurnsOff(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("bob")
+
+	// An auth.json put in place by an administrator counts too.
+	authPath := userhome.AuthPath(stateDir, string(user))
+	if err := os.MkdirAll(filepath.Dir(authPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+	if got := checklistMarks(systemLines(t, svc, user)); !slices.Equal(got, []string{"done", "todo", "todo"}) {
+		t.Fatalf("expected the existing auth.json detected, got %v", got)
+	}
+
+	if _, err := svc.UpdateOnboarding(ctx, schema.UpdateOnboardingRequest{UserID: user, Complete: "bogus"}); err == nil {
+		t.Fatalf("expected an unknown step to be rejected")
+	}
+	resp, err := svc.UpdateOnboarding(ctx, schema.UpdateOnboardingRequest{UserID: user, Off: true})
+	if err != nil || resp.Onboarding.Active {
+		t.Fatalf("expected onboarding off, got %+v: %v", resp.Onboarding, err)
+	}
+	if got := checklistMarks(systemLines(t, svc, user)); len(got) != 0 {
+		t.Fatalf("expected no checklist after /onboarding off, got %v", got)
+	}
+}
+
+func TestOnboardingDisabledByConfig(t *testing.T) {
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: t.TempDir(), StateDir: t.TempDir(), DisableOnboarding: true}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	if lines := systemLines(t, svc, "carol"); len(checklistMarks(lines)) != 0 {
+		t.Fatalf("expected no checklist when onboarding is disabled, got %q", lines)
+	}
+}
+
+func TestOnboardingSkipsExistingUsers(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir, DisableOnboarding: true}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	if _, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: "dave", RepoName: repo.Name}); err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	// Turning onboarding on later does not start it for users with state.
+	cfg.DisableOnboarding = false
+	svc, err = NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	if lines := systemLines(t, svc, "dave"); len(checklistMarks(lines)) != 0 {
+		t.Fatalf("expected no checklist for an existing user, got %q", lines)
+	}
+}
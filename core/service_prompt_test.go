@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -187,19 +188,6 @@ func TestSendPromptDetachesRunContext(t *testing.T) {
 }
 
 func TestStopSessionSignalsCommandRuns(t *testing.T) {
-	origSleep := stopSleep
-	sleepStarted := make(chan struct{})
-	unblockSleep := make(chan struct{})
-	stopSleep = func(time.Duration) {
-		select {
-		case <-sleepStarted:
-		default:
-			close(sleepStarted)
-		}
-		<-unblockSleep
-	}
-	defer func() { stopSleep = origSleep }()
-
 	repoRoot := t.TempDir()
 	stateDir := t.TempDir()
 	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
@@ -234,11 +222,12 @@ func TestStopSessionSignalsCommandRuns(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected *service implementation")
 	}
+	canceled := make(chan struct{})
 	svcImpl.mu.Lock()
 	state := svcImpl.getOrCreateUserStateLocked(user)
 	if tab := state.tabs[tabResp.Tab.ID]; tab != nil {
 		tab.Run = runHandle
-		tab.RunCancel = func() {}
+		tab.RunCancel = func() { close(canceled) }
 	}
 	svcImpl.mu.Unlock()
 
@@ -263,33 +252,171 @@ func TestStopSessionSignalsCommandRuns(t *testing.T) {
 	cmd1.markDone()
 	cmd2.markDone()
 
+	// The stop finishes once everything has exited, well inside the grace
+	// period.
 	select {
-	case <-sleepStarted:
+	case <-canceled:
 	case <-time.After(1 * time.Second):
-		t.Fatalf("expected stop sleep to start")
+		t.Fatalf("expected the stop to finish once the handles exited")
 	}
 
-	close(unblockSleep)
-
 	assertNoSignal(t, runHandle, ProcessSignalKILL)
 	assertNoSignal(t, cmd1, ProcessSignalKILL)
 	assertNoSignal(t, cmd2, ProcessSignalKILL)
 }
 
-func TestCloseTabSkipsKillWhenDone(t *testing.T) {
-	origSleep := stopSleep
-	sleepStarted := make(chan struct{})
-	unblockSleep := make(chan struct{})
-	stopSleep = func(time.Duration) {
-		select {
-		case <-sleepStarted:
-		default:
-			close(sleepStarted)
+func TestStopSessionGraceAndNow(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tracker := svc.(CommandTracker)
+
+	// A command that ignores SIGTERM is killed after the requested grace.
+	stubborn := newSignalCommandHandle()
+	tracker.RegisterCommand(context.Background(), user, tabResp.Tab.ID, stubborn, nil)
+	if _, err := svc.StopSession(context.Background(), schema.StopSessionRequest{UserID: user, TabID: tabResp.Tab.ID, GracePeriod: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("stop session: %v", err)
+	}
+	waitForSignal(t, stubborn, ProcessSignalKILL)
+	if got := stubborn.Signals(); len(got) != 2 || got[0] != ProcessSignalTERM {
+		t.Fatalf("expected SIGTERM then SIGKILL, got %v", got)
+	}
+	tracker.UnregisterCommand(user, tabResp.Tab.ID, stubborn)
+
+	// --now skips SIGTERM.
+	cmd := newSignalCommandHandle()
+	tracker.RegisterCommand(context.Background(), user, tabResp.Tab.ID, cmd, nil)
+	if _, err := svc.StopSession(context.Background(), schema.StopSessionRequest{UserID: user, TabID: tabResp.Tab.ID, Kill: true}); err != nil {
+		t.Fatalf("stop session: %v", err)
+	}
+	waitForSignal(t, cmd, ProcessSignalKILL)
+	assertNoSignal(t, cmd, ProcessSignalTERM)
+	buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID, Limit: 50})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if lines := filterLines(buf.Buffer.Lines, "stop requested: sending SIGKILL"); len(lines) != 2 {
+		t.Fatalf("expected a SIGKILL notice per stop, got %q", buf.Buffer.Lines)
+	}
+}
+
+func TestStopSessionReportsStoppingUntilRunExits(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	block := make(chan struct{})
+	runner := &capturingRunner{stream: &blockingStream{block: block}, ready: make(chan struct{})}
+	sink := &slowSink{}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		EventSink:      sink,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	<-runner.ready
+
+	// The run ignores SIGTERM, so the tab stays stopping for the grace
+	// period unless the run exits first.
+	resp, err := svc.StopSession(ctx, schema.StopSessionRequest{UserID: user, TabID: tabID, GracePeriod: time.Minute})
+	if err != nil {
+		t.Fatalf("stop session: %v", err)
+	}
+	if resp.Tab.Status != schema.TabStatusStopping {
+		t.Fatalf("expected the stopped tab to be stopping, got %s", resp.Tab.Status)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "again"}); !errors.Is(err, schema.ErrTabBusy) {
+		t.Fatalf("expected a stopping tab to reject prompts, got %v", err)
+	}
+
+	close(block)
+	waitForTabIdle(t, svc, user, tabID)
+	flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := svc.(EventDispatcher).FlushEvents(flushCtx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	sink.mu.Lock()
+	var statuses []schema.TabStatus
+	for _, event := range sink.tabs {
+		if event.Type == schema.TabEventStatus && event.Tab.ID == tabID {
+			statuses = append(statuses, event.Tab.Status)
 		}
-		<-unblockSleep
 	}
-	defer func() { stopSleep = origSleep }()
+	sink.mu.Unlock()
+	want := []schema.TabStatus{schema.TabStatusRunning, schema.TabStatusStopping, schema.TabStatusIdle}
+	if got := slices.Compact(statuses); !slices.Equal(got, want) {
+		t.Fatalf("expected status events %v, got %v", want, statuses)
+	}
+}
+
+func TestInterruptCommandSignalsMostRecentPTYCommand(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	tracker := svc.(CommandTracker)
+	ctx := context.Background()
+
+	if sent, _ := tracker.InterruptCommand(ctx, user, tabID); sent {
+		t.Fatalf("expected no interrupt without commands")
+	}
+	ptyCmd := newSignalCommandHandle()
+	plainCmd := newSignalCommandHandle()
+	tracker.RegisterPTYCommand(ctx, user, tabID, ptyCmd, nil)
+	tracker.RegisterCommand(ctx, user, tabID, plainCmd, nil)
+	if sent, _ := tracker.InterruptCommand(ctx, user, tabID); sent {
+		t.Fatalf("expected no interrupt when the most recent command has no pty")
+	}
+
+	tracker.ResizeCommands(ctx, user, tabID, 40, 120)
+	if len(ptyCmd.sizes) != 1 || ptyCmd.sizes[0] != [2]int{40, 120} || len(plainCmd.sizes) != 0 {
+		t.Fatalf("unexpected resizes: pty %v plain %v", ptyCmd.sizes, plainCmd.sizes)
+	}
+
+	tracker.UnregisterCommand(user, tabID, plainCmd)
+	sent, err := tracker.InterruptCommand(ctx, user, tabID)
+	if !sent || err != nil {
+		t.Fatalf("expected interrupt, got sent %v err %v", sent, err)
+	}
+	if got := ptyCmd.Signals(); len(got) != 1 || got[0] != ProcessSignalINT {
+		t.Fatalf("expected SIGINT, got %v", got)
+	}
+}
 
+func TestCloseTabSkipsKillWhenDone(t *testing.T) {
 	repoRoot := t.TempDir()
 	stateDir := t.TempDir()
 	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
@@ -343,14 +470,6 @@ func TestCloseTabSkipsKillWhenDone(t *testing.T) {
 	cmd1.markDone()
 	cmd2.markDone()
 
-	select {
-	case <-sleepStarted:
-	case <-time.After(1 * time.Second):
-		t.Fatalf("expected stop sleep to start")
-	}
-
-	close(unblockSleep)
-
 	assertNoSignal(t, runHandle, ProcessSignalKILL)
 	assertNoSignal(t, cmd1, ProcessSignalKILL)
 	assertNoSignal(t, cmd2, ProcessSignalKILL)
@@ -490,6 +609,52 @@ func TestSendPromptEventErrorAppendsErrorLine(t *testing.T) {
 	t.Fatalf("expected error line, got %v", buf.Buffer.Lines)
 }
 
+func TestSendPromptEarlyExitFoldsStderrIntoExitError(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	resolver := fakeRepoResolver{repo: repo}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{
+			events: []schema.ExecEvent{
+				{Type: schema.EventError, Message: "error: unexpected argument '--sandbx' found"},
+				{Type: schema.EventError, Message: "Usage: codex exec [OPTIONS] [PROMPT]"},
+			},
+			exitCode: 2,
+		}},
+		RepoResolver: resolver,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+
+	want := "run exited with code 2: unexpected argument '--sandbx' found; Usage: codex exec [OPTIONS] [PROMPT]"
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID})
+		if err != nil {
+			t.Fatalf("get buffer: %v", err)
+		}
+		if containsLine(buf.Buffer.Lines, want) {
+			if containsLine(buf.Buffer.Lines, "error: unexpected argument '--sandbx' found") {
+				t.Fatalf("expected stderr folded into the exit error, got %v", buf.Buffer.Lines)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	buf, _ := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID})
+	t.Fatalf("expected exit error with stderr excerpt, got %v", buf.Buffer.Lines)
+}
+
 func TestSendPromptTurnFailedAppendsLine(t *testing.T) {
 	repoRoot := t.TempDir()
 	stateDir := t.TempDir()
@@ -628,7 +793,7 @@ func TestSendPromptAddsWorkedForSeparator(t *testing.T) {
 		workedIdx := -1
 		msgIdx := -1
 		for i, line := range lines {
-			if strings.Contains(line, "Worked for") {
+			if strings.HasPrefix(line, schema.WorkedForMarker+"Worked for") {
 				workedIdx = i
 			}
 			if strings.Contains(line, "final response") {
@@ -639,6 +804,9 @@ func TestSendPromptAddsWorkedForSeparator(t *testing.T) {
 			if workedIdx >= msgIdx {
 				t.Fatalf("expected worked-for line before agent message, got worked=%d msg=%d lines=%v", workedIdx, msgIdx, lines)
 			}
+			if !strings.HasPrefix(lines[msgIdx], schema.FinalAgentMarker) {
+				t.Fatalf("expected final agent marker on %q", lines[msgIdx])
+			}
 			return
 		}
 		time.Sleep(10 * time.Millisecond)
@@ -646,6 +814,109 @@ func TestSendPromptAddsWorkedForSeparator(t *testing.T) {
 	t.Fatalf("timed out waiting for worked-for line")
 }
 
+func TestSendPromptWorkedForWithMultipleAgentMessages(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	resolver := fakeRepoResolver{repo: repo}
+	exitCode := 0
+	events := []schema.ExecEvent{
+		{Type: schema.EventTurnStarted},
+		{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "msg-1", Type: schema.ItemAgentMessage, Text: "intermediate summary"}},
+		{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "cmd-1", Type: schema.ItemCommandExecution, Command: "ls", ExitCode: &exitCode}},
+		{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "msg-2", Type: schema.ItemAgentMessage, Text: "final answer"}},
+		{Type: schema.EventTurnCompleted},
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: events}},
+		RepoResolver:   resolver,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+
+	buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID, Limit: 200})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	lines := buf.Buffer.Lines
+	worked := filterLinesWithPrefix(lines, schema.WorkedForMarker+"Worked for")
+	if len(worked) != 1 {
+		t.Fatalf("expected one worked-for line, got %d (%v)", len(worked), lines)
+	}
+	workedIdx, interimIdx, finalIdx := -1, -1, -1
+	for i, line := range lines {
+		switch line {
+		case worked[0]:
+			workedIdx = i
+		case schema.AgentMarker + "intermediate summary":
+			interimIdx = i
+		case schema.FinalAgentMarker + "final answer":
+			finalIdx = i
+		}
+	}
+	if interimIdx < 0 || finalIdx < 0 {
+		t.Fatalf("expected intermediate and final agent lines, got %v", lines)
+	}
+	if !(interimIdx < workedIdx && workedIdx < finalIdx) {
+		t.Fatalf("expected worked-for between messages, got interim=%d worked=%d final=%d lines=%v", interimIdx, workedIdx, finalIdx, lines)
+	}
+}
+
+func TestSendPromptWorkedForWithoutAgentMessage(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	resolver := fakeRepoResolver{repo: repo}
+	exitCode := 0
+	events := []schema.ExecEvent{
+		{Type: schema.EventTurnStarted},
+		{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "cmd-1", Type: schema.ItemCommandExecution, Command: "make test", ExitCode: &exitCode}},
+		{Type: schema.EventTurnCompleted},
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: events}},
+		RepoResolver:   resolver,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+
+	buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID, Limit: 200})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	lines := buf.Buffer.Lines
+	worked := filterLinesWithPrefix(lines, schema.WorkedForMarker+"Worked for")
+	if len(worked) != 1 {
+		t.Fatalf("expected one worked-for line, got %d (%v)", len(worked), lines)
+	}
+	if last := lines[len(lines)-1]; last != worked[0] {
+		t.Fatalf("expected worked-for line last, got %q (%v)", last, lines)
+	}
+	if len(filterLinesWithPrefix(lines, schema.FinalAgentMarker)) != 0 {
+		t.Fatalf("unexpected final agent lines: %v", lines)
+	}
+}
+
 func TestSendPromptTracksUsage(t *testing.T) {
 	repoRoot := t.TempDir()
 	stateDir := t.TempDir()
@@ -702,10 +973,13 @@ func TestSendPromptAddsExecStartSummary(t *testing.T) {
 		outputs: map[string][]string{
 			"git rev-parse --abbrev-ref HEAD": {"main"},
 			"git remote -v": {
+				"upstream git@github.com:pkt/centaurx.git (fetch)",
+				"upstream git@github.com:pkt/centaurx.git (push)",
 				"origin git@github.com:sa6mwa/centaurx.git (fetch)",
 				"origin git@github.com:sa6mwa/centaurx.git (push)",
 			},
 			"git status --short": {"M BACKLOG.md", "M testserver.go"},
+			"git rev-parse --abbrev-ref --symbolic-full-name @{u}": {"origin/main"},
 		},
 	}
 	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
@@ -747,27 +1021,30 @@ func TestSendPromptAddsExecStartSummary(t *testing.T) {
 		t.Fatalf("expected branch line, got %v", lines)
 	}
 	remoteLines := filterLines(lines, "Remote:")
-	if len(remoteLines) != 1 {
-		t.Fatalf("expected one remote line, got %v", remoteLines)
+	if len(remoteLines) != 1 || !strings.HasSuffix(remoteLines[0], "\torigin (upstream of main)  git@github.com:sa6mwa/centaurx.git") {
+		t.Fatalf("expected origin first, marked as upstream and deduped, got %q", lines)
 	}
-	if strings.Contains(remoteLines[0], "(push)") || strings.Contains(remoteLines[0], "(fetch)") {
-		t.Fatalf("expected remote to be deduped, got %v", remoteLines)
+	remoteLabel, _ := schema.SplitField(strings.TrimPrefix(remoteLines[0], schema.FieldMarker))
+	if _, ok := findLineWithPrefix(lines, schema.FieldLine(strings.Repeat(" ", len(remoteLabel)), "upstream                   git@github.com:pkt/centaurx.git")); !ok {
+		t.Fatalf("expected the second remote on an aligned continuation line, got %q", lines)
 	}
-	statusLine, ok := findLineWithPrefix(lines, "Git status:")
+	statusLine, ok := findLineWithPrefix(lines, schema.FieldLine("Git status:", ""))
 	if !ok || !strings.Contains(statusLine, "BACKLOG.md") {
-		t.Fatalf("expected git status line, got %v", lines)
+		t.Fatalf("expected git status line, got %q", lines)
+	}
+	// Continuation values carry a blank label as wide as the others, so
+	// renderers align them at any width.
+	label, _ := schema.SplitField(strings.TrimPrefix(statusLine, schema.FieldMarker))
+	if _, ok := findLineWithPrefix(lines, schema.FieldLine(strings.Repeat(" ", len(label)), "M testserver.go")); !ok {
+		t.Fatalf("expected aligned git status continuation, got %q", lines)
 	}
-	indent := strings.Repeat(" ", len("Git status:")+1)
-	foundSecond := false
 	for _, line := range lines {
-		if strings.HasPrefix(line, indent) && strings.Contains(line, "testserver.go") {
-			foundSecond = true
-			break
+		if strings.HasPrefix(line, schema.FieldMarker) {
+			if label, _ := schema.SplitField(strings.TrimPrefix(line, schema.FieldMarker)); len(label) != len("Git status:") {
+				t.Fatalf("expected labels padded to a common width, got %q", line)
+			}
 		}
 	}
-	if !foundSecond {
-		t.Fatalf("expected aligned git status continuation, got %v", lines)
-	}
 
 	deadline := time.Now().Add(500 * time.Millisecond)
 	for time.Now().Before(deadline) {
@@ -784,6 +1061,115 @@ func TestSendPromptAddsExecStartSummary(t *testing.T) {
 	t.Fatalf("timed out waiting for tab idle: %v", resp.Tabs)
 }
 
+func TestExecStartSummaryReportsMissingRepo(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: &gitInfoRunner{notRepo: true}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID, Limit: 200})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if line, ok := findLineWithPrefix(buf.Buffer.Lines, schema.FieldMarker+"Git:"); !ok || !strings.HasSuffix(line, "\t"+gitNotInitialized) {
+		t.Fatalf("expected the not initialized line, got %q", buf.Buffer.Lines)
+	}
+	for _, label := range []string{"Branch:", "Remote:", "Git status:"} {
+		if lines := filterLines(buf.Buffer.Lines, label); len(lines) != 0 {
+			t.Fatalf("expected no %s line outside a repo, got %q", label, lines)
+		}
+	}
+}
+
+func TestExecStartGitSummaryIsCached(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	gitRunner := &gitInfoRunner{
+		outputs: map[string][]string{
+			"git rev-parse --abbrev-ref HEAD": {"main"},
+			"git remote -v":                   {"origin git@github.com:sa6mwa/centaurx.git (fetch)"},
+		},
+		runErr: errors.New("run failed"),
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: gitRunner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	clock := time.Now()
+	svc.(*service).gitSummaries.now = func() time.Time { return clock }
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	prompt := func() {
+		t.Helper()
+		_, _ = svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"})
+	}
+	expectCommands := func(want int, why string) {
+		t.Helper()
+		if got := gitRunner.commandCount(); got != want {
+			t.Fatalf("%s: expected %d git summary commands, got %d", why, want, got)
+		}
+	}
+
+	// Runs that fail to start leave the working tree alone, so the second
+	// prompt reuses the summary.
+	prompt()
+	prompt()
+	expectCommands(1, "within the TTL")
+	if cmd := gitRunner.commands[0]; cmd.Command != gitSummaryCommand || !cmd.UseShell {
+		t.Fatalf("expected one shell call for the summary, got %+v", cmd)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 200})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if branches := filterLines(buf.Buffer.Lines, "Branch:"); len(branches) != 2 || !strings.Contains(branches[1], "main") {
+		t.Fatalf("expected the cached branch, got %q", branches)
+	}
+	if status := filterLines(buf.Buffer.Lines, "Git status:"); len(status) != 2 || !strings.Contains(status[0], "(unavailable)") {
+		t.Fatalf("expected a failed git status to show as unavailable, got %q", status)
+	}
+
+	clock = clock.Add(schema.DefaultGitSummaryTTL + time.Second)
+	prompt()
+	expectCommands(2, "after the TTL")
+
+	// A finished shell command may have changed the tree.
+	svc.(*service).UnregisterCommand(user, tabID, &staticCommandHandle{})
+	prompt()
+	expectCommands(3, "after a shell command")
+
+	// So may a finished run.
+	gitRunner.setRunErr(nil)
+	prompt()
+	waitForTabIdle(t, svc, user, tabID)
+	expectCommands(3, "starting a run")
+	gitRunner.setRunErr(errors.New("run failed"))
+	prompt()
+	expectCommands(4, "after a run")
+}
+
 func TestCommandExecutionTerseLimit(t *testing.T) {
 	repoRoot := t.TempDir()
 	stateDir := t.TempDir()
@@ -1005,6 +1391,151 @@ func TestSendPromptUsesComputedRepoPath(t *testing.T) {
 	}
 }
 
+func TestSendPromptPassesCodexFlags(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	runner := &captureRunRunner{}
+	svc, err := NewService(schema.ServiceConfig{
+		RepoRoot:     repoRoot,
+		StateDir:     stateDir,
+		DefaultModel: "gpt-5.1-codex-mini",
+		CodexFlags:   []string{"--sandbox", "workspace-write"},
+		CodexFlagsByModel: map[schema.ModelID][]string{
+			"gpt-5.1-codex-mini": {"-c", "tools.web_search=false"},
+			"gpt-5.2-codex":      {"--full-auto"},
+		},
+	}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	select {
+	case <-runner.done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timed out waiting for runner to finish")
+	}
+	want := []string{"--sandbox", "workspace-write", "-c", "tools.web_search=false"}
+	if !slices.Equal(runner.lastRun.ExtraArgs, want) {
+		t.Fatalf("expected extra args %v, got %v", want, runner.lastRun.ExtraArgs)
+	}
+}
+
+func TestSendPromptEnforcesPromptSize(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	runner := &captureRunRunner{}
+	svc, err := NewService(schema.ServiceConfig{
+		RepoRoot:        repoRoot,
+		StateDir:        stateDir,
+		PromptMaxBytes:  4 << 10,
+		PromptWarnBytes: 2 << 10,
+	}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	_, err = svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: strings.Repeat("x", 5<<10)})
+	if !errors.Is(err, schema.ErrPromptTooLarge) || !strings.Contains(err.Error(), "5KB exceeds the 4KB limit") {
+		t.Fatalf("expected prompt too large, got %v", err)
+	}
+	if _, err := svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: strings.Repeat("x", 3<<10)}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	select {
+	case <-runner.done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timed out waiting for runner to finish")
+	}
+	buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if lines := strings.Join(buf.Buffer.Lines, "\n"); !strings.Contains(lines, "large prompt: 3KB") {
+		t.Fatalf("expected a large prompt warning, got %v", buf.Buffer.Lines)
+	}
+}
+
+func TestSendPromptPassesTabTools(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	runner := &captureRunRunner{}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	deps := ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.SetTabTool(ctx, schema.SetTabToolRequest{UserID: user, TabID: tabID, Tool: "browser", Enabled: true}); !errors.Is(err, schema.ErrInvalidRequest) || !strings.Contains(err.Error(), "known: web") {
+		t.Fatalf("expected unknown tool error listing known tools, got %v", err)
+	}
+	set, err := svc.SetTabTool(ctx, schema.SetTabToolRequest{UserID: user, TabID: tabID, Tool: schema.ToolWebSearch, Enabled: true})
+	if err != nil {
+		t.Fatalf("set tool: %v", err)
+	}
+	if !set.Tab.Tools[schema.ToolWebSearch] {
+		t.Fatalf("expected web search on the snapshot, got %+v", set.Tab.Tools)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	select {
+	case <-runner.done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timed out waiting for runner to finish")
+	}
+	if runner.lastRun.EnableWebSearch == nil || !*runner.lastRun.EnableWebSearch {
+		t.Fatalf("expected web search on the run request, got %v", runner.lastRun.EnableWebSearch)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 100})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if !slices.ContainsFunc(buf.Buffer.Lines, func(line string) bool {
+		return strings.Contains(line, "Tools") && strings.HasSuffix(line, "web")
+	}) {
+		t.Fatalf("expected a tools line in the exec start summary, got %q", buf.Buffer.Lines)
+	}
+
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	tabs, err := reloaded.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil || len(tabs.Tabs) != 1 || !tabs.Tabs[0].Tools[schema.ToolWebSearch] {
+		t.Fatalf("expected the tool setting to persist, got %+v (err %v)", tabs.Tabs, err)
+	}
+}
+
 type fakeRepoResolver struct {
 	repo schema.RepoRef
 }
@@ -1184,6 +1715,7 @@ func (s *blockingStream) Close() error { return nil }
 type signalCommandHandle struct {
 	mu      sync.Mutex
 	signals []ProcessSignal
+	sizes   [][2]int
 	done    chan struct{}
 	once    sync.Once
 }
@@ -1201,6 +1733,13 @@ func (h *signalCommandHandle) Signal(_ context.Context, sig ProcessSignal) error
 	return nil
 }
 
+func (h *signalCommandHandle) Resize(_ context.Context, rows, cols int) error {
+	h.mu.Lock()
+	h.sizes = append(h.sizes, [2]int{rows, cols})
+	h.mu.Unlock()
+	return nil
+}
+
 func (h *signalCommandHandle) Wait(context.Context) (RunResult, error) {
 	h.markDone()
 	return RunResult{ExitCode: 0}, nil
@@ -1463,20 +2002,63 @@ func (s *commandStream) Next(context.Context) (schema.ExecEvent, error) {
 
 func (s *commandStream) Close() error { return nil }
 
+// gitInfoRunner answers commands from outputs. The exec start summary
+// command is answered as a shell running each git command would.
 type gitInfoRunner struct {
 	outputs map[string][]string
+	// notRepo answers as a directory outside any git work tree.
+	notRepo bool
+
+	mu       sync.Mutex
+	runErr   error
+	commands []RunCommandRequest
 }
 
 func (g *gitInfoRunner) Run(context.Context, RunRequest) (RunHandle, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.runErr != nil {
+		return nil, g.runErr
+	}
 	return &workedHandle{}, nil
 }
 
 func (g *gitInfoRunner) RunCommand(ctx context.Context, req RunCommandRequest) (CommandHandle, error) {
 	_ = ctx
-	lines := g.outputs[req.Command]
+	g.mu.Lock()
+	g.commands = append(g.commands, req)
+	g.mu.Unlock()
+	if req.Command != gitSummaryCommand {
+		return &staticCommandHandle{lines: g.outputs[req.Command]}, nil
+	}
+	if g.notRepo {
+		return &staticCommandHandle{lines: []string{gitSummaryNotRepo}}, nil
+	}
+	var lines []string
+	for _, command := range []string{"git rev-parse --abbrev-ref HEAD", "git remote -v", "git status --short", "git rev-parse --abbrev-ref --symbolic-full-name @{u}"} {
+		output, ok := g.outputs[command]
+		lines = append(lines, output...)
+		if ok {
+			lines = append(lines, gitSummarySection+" 0")
+		} else {
+			lines = append(lines, gitSummarySection+" 128")
+		}
+	}
 	return &staticCommandHandle{lines: lines}, nil
 }
 
+func (g *gitInfoRunner) setRunErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.runErr = err
+}
+
+func (g *gitInfoRunner) commandCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.commands)
+}
+
 type staticCommandHandle struct {
 	lines []string
 }
@@ -1485,6 +2067,7 @@ func (h *staticCommandHandle) Outputs() CommandStream {
 	return &staticCommandStream{lines: append([]string(nil), h.lines...)}
 }
 func (h *staticCommandHandle) Signal(context.Context, ProcessSignal) error { return nil }
+func (h *staticCommandHandle) Resize(context.Context, int, int) error      { return nil }
 func (h *staticCommandHandle) Wait(context.Context) (RunResult, error) {
 	return RunResult{ExitCode: 0}, nil
 }
@@ -1548,3 +2131,50 @@ func (s *eventStream) Next(context.Context) (schema.ExecEvent, error) {
 }
 
 func (s *eventStream) Close() error { return nil }
+
+func TestGetLastPromptRecordsPromptAndPersists(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	deps := ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: errorRunner{err: errors.New("run failed")}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.GetLastPrompt(context.Background(), schema.GetLastPromptRequest{UserID: user, TabID: tabID}); !errors.Is(err, schema.ErrNoPrompt) {
+		t.Fatalf("expected ErrNoPrompt for a fresh tab, got %v", err)
+	}
+	// A prompt that fails to start is still the one /redo replays.
+	if _, err := svc.SendPrompt(context.Background(), schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err == nil {
+		t.Fatalf("expected send prompt to fail")
+	}
+	resp, err := svc.GetLastPrompt(context.Background(), schema.GetLastPromptRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get last prompt: %v", err)
+	}
+	if resp.Prompt != "hello" {
+		t.Fatalf("expected last prompt %q, got %q", "hello", resp.Prompt)
+	}
+
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	resp, err = reloaded.GetLastPrompt(context.Background(), schema.GetLastPromptRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get last prompt after reload: %v", err)
+	}
+	if resp.Prompt != "hello" {
+		t.Fatalf("expected persisted last prompt %q, got %q", "hello", resp.Prompt)
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestReasoningDedupeGolden(t *testing.T) {
+	events := loadRecordedEvents(t, filepath.Join("testdata", "reasoning_stream.jsonl"))
+	cases := []struct {
+		name   string
+		full   bool
+		golden string
+	}{
+		{name: "collapsed", full: false, golden: "reasoning_collapsed.golden"},
+		{name: "full", full: true, golden: "reasoning_full.golden"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := t.TempDir()
+			repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+			svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+				RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: cloneEvents(events)}},
+				RepoResolver:   fakeRepoResolver{repo: repo},
+			})
+			if err != nil {
+				t.Fatalf("new service: %v", err)
+			}
+			user := schema.UserID("alice")
+			tabResp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+			if err != nil {
+				t.Fatalf("create tab: %v", err)
+			}
+			prefs := sessionprefs.New()
+			prefs.FullReasoning = tc.full
+			ctx := sessionprefs.WithContext(context.Background(), prefs)
+			if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+				t.Fatalf("send prompt: %v", err)
+			}
+			waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+			buf, err := svc.GetBuffer(context.Background(), schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID, Limit: 200})
+			if err != nil {
+				t.Fatalf("get buffer: %v", err)
+			}
+			got := goldenItemLines(buf.Buffer.Lines)
+			data, err := os.ReadFile(filepath.Join("testdata", tc.golden))
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			want := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("output mismatch\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func loadRecordedEvents(t *testing.T, path string) []schema.ExecEvent {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open events: %v", err)
+	}
+	defer file.Close()
+	var events []schema.ExecEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var event schema.ExecEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scan events: %v", err)
+	}
+	return events
+}
+
+func cloneEvents(events []schema.ExecEvent) []schema.ExecEvent {
+	out := make([]schema.ExecEvent, 0, len(events))
+	for _, event := range events {
+		if event.Item != nil {
+			item := *event.Item
+			event.Item = &item
+		}
+		out = append(out, event)
+	}
+	return out
+}
+
+// goldenItemLines keeps reasoning and agent lines and spells out their markers.
+func goldenItemLines(lines []string) []string {
+	markers := []struct {
+		marker string
+		label  string
+	}{
+		{schema.ReasoningMarker, "[reasoning] "},
+		{schema.AgentMarker, "[agent] "},
+		{schema.FinalAgentMarker, "[final] "},
+	}
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		for _, m := range markers {
+			if strings.HasPrefix(line, m.marker) {
+				out = append(out, m.label+strings.TrimPrefix(line, m.marker))
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestStreamReattachContinuesRun(t *testing.T) {
+	handle := &reattachHandle{
+		segments: [][]schema.ExecEvent{
+			{{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "before the break"}}},
+			{{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "a2", Type: schema.ItemAgentMessage, Text: "after the break"}}, {Type: schema.EventTurnCompleted}},
+		},
+		reattachErrs: []error{NewRunnerError(RunnerErrorUnavailable, "attach", errors.New("connection refused"))},
+	}
+	lines := runReattachPrompt(t, handle, 3)
+
+	// The agent message before the break is held back as a final answer
+	// candidate, so it follows the notices.
+	want := []string{"stream interrupted — reattempting (1/3)", "stream interrupted — reattempting (2/3)", "before the break", "after the break"}
+	if !containsInOrder(lines, want) {
+		t.Fatalf("expected %q in the buffer, got %q", want, lines)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "runner unavailable") || strings.Contains(line, "(3/3)") {
+			t.Fatalf("expected the run to continue after re-attaching, got %q", lines)
+		}
+	}
+	if got := handle.reattached(); got != 2 {
+		t.Fatalf("expected two re-attach attempts, got %d", got)
+	}
+}
+
+func TestStreamReattachGivesUp(t *testing.T) {
+	unavailable := NewRunnerError(RunnerErrorUnavailable, "attach", errors.New("connection refused"))
+	handle := &reattachHandle{
+		segments:     [][]schema.ExecEvent{{{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "before the break"}}}},
+		reattachErrs: []error{unavailable, unavailable},
+	}
+	lines := runReattachPrompt(t, handle, 2)
+
+	want := []string{"stream interrupted — reattempting (1/2)", "stream interrupted — reattempting (2/2)", "runner unavailable"}
+	if !containsInOrder(lines, want) {
+		t.Fatalf("expected %q in the buffer, got %q", want, lines)
+	}
+	if got := handle.reattached(); got != 2 {
+		t.Fatalf("expected two re-attach attempts, got %d", got)
+	}
+}
+
+func TestStreamReattachDisabled(t *testing.T) {
+	handle := &reattachHandle{
+		segments: [][]schema.ExecEvent{
+			{{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "a1", Type: schema.ItemAgentMessage, Text: "before the break"}}},
+			{{Type: schema.EventTurnCompleted}},
+		},
+	}
+	lines := runReattachPrompt(t, handle, 0)
+
+	if !containsInOrder(lines, []string{"runner unavailable", "before the break"}) {
+		t.Fatalf("expected the stream error, got %q", lines)
+	}
+	if got := handle.reattached(); got != 0 {
+		t.Fatalf("expected no re-attach attempts, got %d", got)
+	}
+}
+
+// runReattachPrompt runs a prompt on handle with the given re-attach limit
+// and returns the tab buffer once the run ended.
+func runReattachPrompt(t *testing.T, handle *reattachHandle, attempts int) []string {
+	t.Helper()
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir(), StreamReattachAttempts: attempts}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: reattachRunner{handle: handle}},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	svc.(*service).streamRetryDelay = time.Millisecond
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	return buf.Buffer.Lines
+}
+
+func containsInOrder(lines, want []string) bool {
+	for _, line := range lines {
+		if len(want) > 0 && strings.Contains(line, want[0]) {
+			want = want[1:]
+		}
+	}
+	return len(want) == 0
+}
+
+type reattachRunner struct {
+	handle *reattachHandle
+}
+
+func (r reattachRunner) Run(context.Context, RunRequest) (RunHandle, error) {
+	return r.handle, nil
+}
+
+func (reattachRunner) RunCommand(context.Context, RunCommandRequest) (CommandHandle, error) {
+	return nil, errors.New("command not supported")
+}
+
+// reattachHandle streams its segments one stream at a time; every segment
+// but the last ends in a reset connection. Reattach fails with the queued
+// errors before it moves on to the next segment.
+type reattachHandle struct {
+	mu           sync.Mutex
+	segments     [][]schema.ExecEvent
+	segment      int
+	reattachErrs []error
+	attempts     int
+}
+
+func (h *reattachHandle) Events() EventStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream := &segmentStream{events: h.segments[h.segment], err: io.EOF}
+	if h.segment+1 < len(h.segments) || len(h.reattachErrs) > 0 {
+		stream.err = NewRunnerError(RunnerErrorUnavailable, "exec", errors.New("connection reset"))
+	}
+	return stream
+}
+
+func (h *reattachHandle) Reattach(context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.attempts++
+	if len(h.reattachErrs) > 0 {
+		err := h.reattachErrs[0]
+		h.reattachErrs = h.reattachErrs[1:]
+		return err
+	}
+	if h.segment+1 >= len(h.segments) {
+		return errors.New("run not found")
+	}
+	h.segment++
+	return nil
+}
+
+func (h *reattachHandle) reattached() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.attempts
+}
+
+func (h *reattachHandle) Signal(context.Context, ProcessSignal) error { return nil }
+func (h *reattachHandle) Wait(context.Context) (RunResult, error)     { return RunResult{}, nil }
+func (h *reattachHandle) Close() error                                { return nil }
+
+type segmentStream struct {
+	events []schema.ExecEvent
+	err    error
+}
+
+func (s *segmentStream) Next(context.Context) (schema.ExecEvent, error) {
+	if len(s.events) == 0 {
+		return schema.ExecEvent{}, s.err
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func (s *segmentStream) Close() error { return nil }
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/internal/format"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestGetBufferRenderings(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	exitCode := 0
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: []schema.ExecEvent{
+			{Type: schema.EventThreadStarted, ThreadID: "thread-1"},
+			{Type: schema.EventTurnStarted},
+			{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "c1", Type: schema.ItemCommandExecution, Command: "ls", AggregatedOutput: "a.go\n", ExitCode: &exitCode}},
+			{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "m1", Type: schema.ItemAgentMessage, Text: "Done **now**."}},
+			{Type: schema.EventTurnCompleted, Usage: &schema.TurnUsage{InputTokens: 3, OutputTokens: 1}},
+		}}},
+		RepoResolver: fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := created.Tab.ID
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "list files"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabID)
+
+	const worked = "\x00worked"
+	want := map[schema.Rendering][]string{
+		schema.RenderingPlain: {
+			schema.CommandMarker + "$ ls",
+			schema.CommandMarker + "a.go",
+			schema.CommandMarker + "exit code: 0",
+			worked,
+			schema.FinalAgentMarker + "Done **now**.",
+		},
+		schema.RenderingMarkdown: {
+			schema.CommandMarker + "```console",
+			schema.CommandMarker + "$ ls",
+			schema.CommandMarker + "a.go",
+			schema.CommandMarker + "```",
+			schema.CommandMarker + "exit code: `0`",
+			worked,
+			schema.FinalAgentMarker + "Done **now**.",
+		},
+		schema.RenderingRaw: {
+			`{"type":"thread.started","thread_id":"thread-1"}`,
+			`{"type":"turn.started"}`,
+			`{"type":"item.completed","item":{"id":"c1","type":"command_execution","command":"ls","aggregated_output":"a.go\n","exit_code":0}}`,
+			worked,
+			`{"type":"item.completed","item":{"id":"m1","type":"agent_message","text":"Done **now**."}}`,
+			`{"type":"turn.completed","usage":{"input_tokens":3,"output_tokens":1}}`,
+		},
+	}
+	var prefix []string
+	for _, rendering := range []schema.Rendering{"", schema.RenderingPlain, schema.RenderingMarkdown, schema.RenderingRaw} {
+		expected := want[rendering]
+		if rendering == "" {
+			expected = want[schema.RenderingPlain]
+		}
+		resp, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Rendering: rendering, Structured: true})
+		if err != nil {
+			t.Fatalf("get buffer %q: %v", rendering, err)
+		}
+		lines := resp.Buffer.Lines
+		if len(lines) < len(expected) || len(resp.Buffer.Structured) != len(lines) {
+			t.Fatalf("%q: unexpected buffer %q", rendering, lines)
+		}
+		head, tail := lines[:len(lines)-len(expected)], lines[len(lines)-len(expected):]
+		for i, line := range expected {
+			if line == worked {
+				if !strings.HasPrefix(tail[i], schema.WorkedForMarker+"Worked for") {
+					t.Fatalf("%q: expected worked-for separator at %d, got %q", rendering, i, tail)
+				}
+				continue
+			}
+			if tail[i] != line {
+				t.Fatalf("%q: line %d = %q, want %q (tail %q)", rendering, i, tail[i], line, tail)
+			}
+		}
+		// Prompt and exec start lines do not come from codex events.
+		if prefix == nil {
+			prefix = head
+		} else if !slices.Equal(head, prefix) {
+			t.Fatalf("%q: lines before the run differ: %q vs %q", rendering, head, prefix)
+		}
+		if resp.Buffer.TotalLines != len(prefix)+len(want[schema.RenderingPlain]) {
+			t.Fatalf("%q: expected TotalLines to count stored lines, got %d", rendering, resp.Buffer.TotalLines)
+		}
+	}
+	if !slices.Contains(prefix, "> list files") {
+		t.Fatalf("expected the prompt before the run, got %q", prefix)
+	}
+
+	if _, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Rendering: "html"}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected invalid request for an unknown rendering, got %v", err)
+	}
+}
+
+func TestBufferRerenderPartialView(t *testing.T) {
+	b := newBufferWithMaxLines(4)
+	b.AppendRaw("before")
+	b.AppendEvent(schema.ExecEvent{Type: schema.EventTurnStarted}, false)
+	b.AppendEvent(schema.ExecEvent{Type: schema.EventItemCompleted}, false, schema.Line(schema.LineKindAgent, "one"), schema.Line(schema.LineKindAgent, "two"))
+	b.AppendRaw("after")
+	b.AppendEvent(schema.ExecEvent{Type: schema.EventTurnCompleted}, false)
+
+	name := func(src *eventSource) []schema.BufferLine {
+		return []schema.BufferLine{schema.Line(schema.LineKindSystem, string(src.event.Type))}
+	}
+	if got := schema.LegacyLines(b.Snapshot(0).rerender(name)); !slices.Equal(got, []string{"before", "turn.started", "item.completed", "after", "turn.completed"}) {
+		t.Fatalf("unexpected full view %q", got)
+	}
+	// A view starting inside an event renders the whole event once.
+	b.Scroll(1, 2)
+	if got := schema.LegacyLines(b.Snapshot(1).rerender(name)); !slices.Equal(got, []string{"item.completed"}) {
+		t.Fatalf("unexpected scrolled view %q", got)
+	}
+	// Trimming drops the sources whose lines are all gone.
+	b.AppendRaw("x", "y")
+	if len(b.sources) != 2 || b.sources[0].event.Type != schema.EventItemCompleted {
+		t.Fatalf("expected sources of trimmed lines to be dropped, got %d", len(b.sources))
+	}
+}
+
+// tableRenderer panics on agent messages containing a table, the way a
+// renderer bug would, and formats other events as plain text.
+type tableRenderer struct{}
+
+func (tableRenderer) FormatEvent(event schema.ExecEvent) ([]string, error) {
+	if event.Item != nil && strings.Contains(event.Item.Text, "|") {
+		panic("malformed table")
+	}
+	return format.NewPlainRenderer().FormatEvent(event)
+}
+
+func TestRendererPanicFallsBackToUnrenderedContent(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: eventRunner{events: []schema.ExecEvent{
+			{Type: schema.EventTurnStarted},
+			{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "m1", Type: schema.ItemAgentMessage, Text: "| a | b |\n|---"}},
+			{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{ID: "m2", Type: schema.ItemAgentMessage, Text: "Done."}},
+			{Type: schema.EventTurnCompleted},
+		}}},
+		RepoResolver: fakeRepoResolver{repo: repo},
+		Renderer:     tableRenderer{},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := created.Tab.ID
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "table"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabID)
+
+	resp, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	lines := resp.Buffer.Lines
+	for _, want := range []string{"render error: renderer panic: malformed table", schema.AgentMarker + "(unrendered) | a | b |", schema.AgentMarker + "|---", schema.FinalAgentMarker + "Done."} {
+		if !slices.Contains(lines, want) {
+			t.Fatalf("expected %q in buffer %q", want, lines)
+		}
+	}
+	tabs, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil || len(tabs.Tabs) != 1 || tabs.Tabs[0].RenderErrors != 1 {
+		t.Fatalf("expected one render error on the tab, got %+v %v", tabs.Tabs, err)
+	}
+}
+
+func TestEventSourceRenderFallsBack(t *testing.T) {
+	src := &eventSource{event: schema.ExecEvent{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{Type: schema.ItemAgentMessage, Text: "a | b"}}}
+	got := schema.LegacyLines(src.render(schema.RenderingMarkdown, tableRenderer{}))
+	if len(got) != 2 || got[0] != "render error: renderer panic: malformed table" || got[1] != schema.AgentMarker+"(unrendered) a | b" {
+		t.Fatalf("unexpected fallback %q", got)
+	}
+}
+
+func TestUnrenderedEventLinesTruncates(t *testing.T) {
+	output := strings.Repeat("line\n", unrenderedMaxLines+10)
+	lines := unrenderedEventLines(schema.ExecEvent{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{Type: schema.ItemCommandExecution, Command: "yes", AggregatedOutput: output}}, errors.New("boom"))
+	if len(lines) != unrenderedMaxLines+2 {
+		t.Fatalf("expected %d lines, got %d", unrenderedMaxLines+2, len(lines))
+	}
+	if lines[1].Kind != schema.LineKindCommand || lines[1].Text != "(unrendered) yes" || lines[len(lines)-1].Text != "(unrendered output truncated)" {
+		t.Fatalf("unexpected lines %+v", lines)
+	}
+
+	lines = unrenderedEventLines(schema.ExecEvent{Type: schema.EventTurnFailed, Raw: []byte(`{"type":"turn.failed"}`)}, errors.New("boom"))
+	if len(lines) != 2 || lines[1].Text != `(unrendered) {"type":"turn.failed"}` {
+		t.Fatalf("expected the raw event, got %+v", lines)
+	}
+}
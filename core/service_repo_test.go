@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
@@ -126,3 +128,56 @@ func TestListReposUsesComputedPath(t *testing.T) {
 		t.Fatalf("expected computed path %q, got %q", want, resp.Repos[0].Path)
 	}
 }
+
+// countRepoResolver lists count repos named repo-0, repo-1 and so on.
+type countRepoResolver struct {
+	fakeRepoResolver
+	count int
+}
+
+func (c countRepoResolver) ListRepos(context.Context, ListReposRequest) (ListReposResponse, error) {
+	repos := make([]schema.RepoRef, 0, c.count)
+	for i := range c.count {
+		repos = append(repos, schema.RepoRef{Name: schema.RepoName(fmt.Sprintf("repo-%d", i))})
+	}
+	return ListReposResponse{Repos: repos}, nil
+}
+
+func TestListReposMeasuresSizesUpToLimit(t *testing.T) {
+	repoRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repoRoot, "alice", "repo-0", ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, count := range []int{1, listReposSizeLimit + 1} {
+		svc, err := NewService(schema.ServiceConfig{
+			RepoRoot: repoRoot,
+			StateDir: t.TempDir(),
+		}, ServiceDeps{RepoResolver: countRepoResolver{count: count}})
+		if err != nil {
+			t.Fatalf("new service: %v", err)
+		}
+		resp, err := svc.ListRepos(context.Background(), schema.ListReposRequest{UserID: "alice"})
+		if err != nil {
+			t.Fatalf("list repos: %v", err)
+		}
+		if len(resp.Details) != count {
+			t.Fatalf("expected %d details, got %d", count, len(resp.Details))
+		}
+		if resp.Details[0].ModifiedAt.IsZero() {
+			t.Fatalf("expected modified time for repo-0")
+		}
+		measured := resp.Details[0].SizeBytes >= 0
+		if measured != (count <= listReposSizeLimit) {
+			t.Fatalf("%d repos: expected measured=%v, got size %d", count, count <= listReposSizeLimit, resp.Details[0].SizeBytes)
+		}
+		if count > listReposSizeLimit {
+			resp, err = svc.ListRepos(context.Background(), schema.ListReposRequest{UserID: "alice", Sizes: true})
+			if err != nil {
+				t.Fatalf("list repos with sizes: %v", err)
+			}
+			if resp.Details[0].SizeBytes < 0 {
+				t.Fatalf("expected size when asked for sizes")
+			}
+		}
+	}
+}
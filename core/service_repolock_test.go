@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// newRepoLockService opens two tabs, "one" and "two", on the same repo.
+func newRepoLockService(t *testing.T, runner Runner, policy schema.ConcurrentRunsPolicy) (Service, schema.TabID, schema.TabID) {
+	t.Helper()
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir(), ConcurrentRuns: policy}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	var ids []schema.TabID
+	for _, name := range []schema.TabName{"one", "two"} {
+		resp, err := svc.CreateTab(context.Background(), schema.CreateTabRequest{UserID: "alice", RepoName: repo.Name, TabName: name})
+		if err != nil {
+			t.Fatalf("create tab: %v", err)
+		}
+		ids = append(ids, resp.Tab.ID)
+	}
+	return svc, ids[0], ids[1]
+}
+
+func TestSendPromptRejectsSecondRunInRepo(t *testing.T) {
+	runner := newGatedRunner()
+	svc, one, two := newRepoLockService(t, runner, "")
+	ctx := context.Background()
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: one, Prompt: "edit"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	runner.waitStarted(t, 1)
+
+	_, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: two, Prompt: "edit too"})
+	if !errors.Is(err, schema.ErrRepoBusy) || !strings.Contains(err.Error(), "repo busy in tab 'one'") {
+		t.Fatalf("expected a repo busy error, got %v", err)
+	}
+
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", one)
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: two, Prompt: "edit too"}); err != nil {
+		t.Fatalf("expected the repo to be free after the run, got %v", err)
+	}
+	runner.waitStarted(t, 1)
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", two)
+}
+
+func TestSendPromptReleasesRepoWhenRunFailsToStart(t *testing.T) {
+	svc, one, two := newRepoLockService(t, errorRunner{}, "")
+	ctx := context.Background()
+	for _, tabID := range []schema.TabID{one, two} {
+		_, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: tabID, Prompt: "edit"})
+		if err == nil || errors.Is(err, schema.ErrRepoBusy) {
+			t.Fatalf("expected the run error in %s, got %v", tabID, err)
+		}
+	}
+}
+
+func TestSendPromptQueuesBehindRunInRepo(t *testing.T) {
+	runner := newGatedRunner()
+	svc, one, two := newRepoLockService(t, runner, schema.ConcurrentRunsQueue)
+	ctx := context.Background()
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: one, Prompt: "edit"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	runner.waitStarted(t, 1)
+
+	resp, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: two, Prompt: "edit too"})
+	if err != nil || !resp.Accepted {
+		t.Fatalf("expected the prompt to be queued, got %+v %v", resp, err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: "alice", TabID: two, Prompt: "again"}); !errors.Is(err, schema.ErrTabBusy) {
+		t.Fatalf("expected a second queued prompt to be refused, got %v", err)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: "alice", TabID: two})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if !strings.Contains(strings.Join(buf.Buffer.Lines, "\n"), "queued: repo busy in tab 'one'") {
+		t.Fatalf("expected a queued notice, got %q", buf.Buffer.Lines)
+	}
+	select {
+	case <-runner.started:
+		t.Fatal("expected the queued prompt to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	runner.release <- struct{}{}
+	runner.waitStarted(t, 1)
+	if runner.maxActive() != 1 {
+		t.Fatalf("expected one run at a time, got %d", runner.maxActive())
+	}
+	runner.release <- struct{}{}
+	waitForTabIdle(t, svc, "alice", two)
+	last, err := svc.GetLastPrompt(ctx, schema.GetLastPromptRequest{UserID: "alice", TabID: two})
+	if err != nil || last.Prompt != "edit too" {
+		t.Fatalf("expected the queued prompt to run, got %+v %v", last, err)
+	}
+}
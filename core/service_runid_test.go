@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+type runIDRunner struct {
+	workedRunner
+	mu  sync.Mutex
+	req RunRequest
+}
+
+func (r *runIDRunner) Run(ctx context.Context, req RunRequest) (RunHandle, error) {
+	r.mu.Lock()
+	r.req = req
+	r.mu.Unlock()
+	pslog.Ctx(ctx).Info("fake runner exec start")
+	return r.workedRunner.Run(ctx, req)
+}
+
+func TestSendPromptRunIDTiesLogsEventsAndBuffer(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+
+	capture := newLogCapture(t)
+	logger := pslog.NewWithOptions(capture, pslog.Options{
+		Mode:          pslog.ModeStructured,
+		NoColor:       true,
+		VerboseFields: true,
+		MinLevel:      pslog.DebugLevel,
+	})
+	ctx := pslog.ContextWithLogger(context.Background(), logger)
+	runner := &runIDRunner{}
+	sink := &slowSink{}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		EventSink:      sink,
+		Logger:         logger,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+		t.Fatalf("send prompt: %v", err)
+	}
+	waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+
+	runner.mu.Lock()
+	runID := runner.req.RunID
+	runner.mu.Unlock()
+	if runID == "" {
+		t.Fatalf("expected run id on the run request")
+	}
+
+	// Every line logged for the run, by the service and by the runner it
+	// calls, carries the same run id.
+	for _, message := range []string{"service prompt start", "audit command", "fake runner exec start", "service runner started", "service exec stream start"} {
+		found := false
+		for _, entry := range capture.Entries() {
+			if entry.Message != message {
+				continue
+			}
+			found = true
+			if entry.Fields["run_id"] != string(runID) {
+				t.Fatalf("%q logged run_id %v, want %q", message, entry.Fields["run_id"], runID)
+			}
+		}
+		if !found {
+			t.Fatalf("expected log %q", message)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		sink.mu.Lock()
+		var running, idle bool
+		for _, event := range sink.tabs {
+			if event.Type != schema.TabEventStatus || event.Tab.RunID != runID {
+				continue
+			}
+			running = running || event.Tab.Status == schema.TabStatusRunning
+			idle = idle || event.Tab.Status == schema.TabStatusIdle
+		}
+		sink.mu.Unlock()
+		if running && idle {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected running and idle status events with run id %q (running=%t idle=%t)", runID, running, idle)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabResp.Tab.ID})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if !strings.Contains(strings.Join(buf.Buffer.Lines, "\n"), shortRunID(runID)) {
+		t.Fatalf("expected run id %q in exec start summary, got %v", shortRunID(runID), buf.Buffer.Lines)
+	}
+	if shortRunID(runID) == string(runID) || !strings.HasPrefix(string(runID), strings.TrimSuffix(shortRunID(runID), "…")) {
+		t.Fatalf("unexpected short run id %q for %q", shortRunID(runID), runID)
+	}
+}
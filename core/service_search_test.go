@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// newSearchService returns a service where alice has three tabs with
+// output and prompt history to search.
+func newSearchService(t *testing.T) (Service, []schema.TabID) {
+	t.Helper()
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabs := []struct {
+		name    schema.TabName
+		lines   []string
+		history []string
+	}{
+		{name: "alpha", lines: []string{"run the Migration script", "unrelated", "migration done"}},
+		{name: "beta", lines: []string{"nothing here"}, history: []string{"apply the migration"}},
+		{name: "gamma", lines: []string{"MIGRATION failed", "see the failure above"}},
+	}
+	var ids []schema.TabID
+	for _, tab := range tabs {
+		created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name, TabName: tab.name})
+		if err != nil {
+			t.Fatalf("create tab %s: %v", tab.name, err)
+		}
+		ids = append(ids, created.Tab.ID)
+		if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: created.Tab.ID, Lines: tab.lines}); err != nil {
+			t.Fatalf("append output: %v", err)
+		}
+		for _, entry := range tab.history {
+			if _, err := svc.AppendHistory(ctx, schema.AppendHistoryRequest{UserID: user, TabID: created.Tab.ID, Entry: entry}); err != nil {
+				t.Fatalf("append history: %v", err)
+			}
+		}
+	}
+	return svc, ids
+}
+
+func searchExcerpts(resp schema.SearchAllResponse) []string {
+	excerpts := make([]string, 0, len(resp.Matches))
+	for _, match := range resp.Matches {
+		excerpts = append(excerpts, string(match.TabName)+": "+match.Excerpt)
+	}
+	return excerpts
+}
+
+func TestSearchAllAcrossTabs(t *testing.T) {
+	svc, ids := newSearchService(t)
+	ctx := context.Background()
+	user := schema.UserID("alice")
+
+	resp, err := svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "migration"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	want := []string{"alpha: migration done", "alpha: run the Migration script", "beta: apply the migration", "gamma: MIGRATION failed"}
+	if got := searchExcerpts(resp); !slices.Equal(got, want) || resp.Truncated {
+		t.Fatalf("expected case-insensitive matches %q, got %q (truncated %v)", want, got, resp.Truncated)
+	}
+	if resp.Matches[2].Source != schema.SearchSourceHistory || resp.Matches[0].Source != schema.SearchSourceBuffer {
+		t.Fatalf("expected buffer and history sources, got %+v", resp.Matches)
+	}
+
+	resp, err = svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "migration", CaseSensitive: true})
+	if err != nil {
+		t.Fatalf("case-sensitive search: %v", err)
+	}
+	if got, want := searchExcerpts(resp), []string{"alpha: migration done", "beta: apply the migration"}; !slices.Equal(got, want) {
+		t.Fatalf("expected case-sensitive matches %q, got %q", want, got)
+	}
+
+	resp, err = svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "fail(ed|ure)", Regexp: true})
+	if err != nil {
+		t.Fatalf("regexp search: %v", err)
+	}
+	if got, want := searchExcerpts(resp), []string{"gamma: see the failure above", "gamma: MIGRATION failed"}; !slices.Equal(got, want) {
+		t.Fatalf("expected regexp matches %q, got %q", want, got)
+	}
+	if _, err := svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "fail(", Regexp: true}); !errors.Is(err, schema.ErrInvalidSearchQuery) {
+		t.Fatalf("expected an invalid regexp to be rejected, got %v", err)
+	}
+
+	resp, err = svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "migration", MaxMatches: 2})
+	if err != nil {
+		t.Fatalf("capped search: %v", err)
+	}
+	if len(resp.Matches) != 2 || !resp.Truncated {
+		t.Fatalf("expected two matches and truncation, got %d (truncated %v)", len(resp.Matches), resp.Truncated)
+	}
+	resp, err = svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "migration script", MaxLinesPerTab: 1})
+	if err != nil {
+		t.Fatalf("bounded search: %v", err)
+	}
+	if len(resp.Matches) != 0 {
+		t.Fatalf("expected only the newest line of each tab to be scanned, got %q", searchExcerpts(resp))
+	}
+
+	// A buffer match's line number scrolls the view to it.
+	resp, err = svc.SearchAll(ctx, schema.SearchAllRequest{UserID: user, Query: "migration script"})
+	if err != nil || len(resp.Matches) != 1 {
+		t.Fatalf("expected one match, got %v: %v", resp.Matches, err)
+	}
+	match := resp.Matches[0]
+	if match.TabID != ids[0] {
+		t.Fatalf("expected the match in the first tab, got %s", match.TabID)
+	}
+	if _, err := svc.ScrollBuffer(ctx, schema.ScrollBufferRequest{UserID: user, TabID: match.TabID, ToLine: match.Line, Limit: 1}); err != nil {
+		t.Fatalf("scroll to line: %v", err)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: match.TabID, Limit: 1})
+	if err != nil {
+		t.Fatalf("get buffer: %v", err)
+	}
+	if !slices.Equal(buf.Buffer.Lines, []string{"run the Migration script"}) {
+		t.Fatalf("expected the view scrolled to the match, got %q", buf.Buffer.Lines)
+	}
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// knownUsers is a UserDirectory holding the listed accounts.
+type knownUsers []string
+
+func (u knownUsers) HasUser(username string) bool { return slices.Contains(u, username) }
+
+func TestShareTabGrantsGuestAccess(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	sink := &slowSink{}
+	deps := ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: &fileRunner{}},
+		EventSink:      sink,
+		Users:          knownUsers{"bob"},
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	owner, guest := schema.UserID("alice"), schema.UserID("bob")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: owner, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+
+	if _, err := svc.ShareTab(ctx, schema.ShareTabRequest{UserID: owner, TabID: tabID, Guest: owner}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected sharing with yourself to be rejected, got %v", err)
+	}
+	if _, err := svc.ShareTab(ctx, schema.ShareTabRequest{UserID: guest, TabID: tabID, Guest: owner}); !errors.Is(err, schema.ErrTabNotFound) {
+		t.Fatalf("expected only the owner to share, got %v", err)
+	}
+	shared, err := svc.ShareTab(ctx, schema.ShareTabRequest{UserID: owner, TabID: tabID, Guest: guest})
+	if err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	if len(shared.Shares) != 1 || shared.Shares[0] != (schema.TabShare{User: guest, Access: schema.ShareAccessRead}) {
+		t.Fatalf("unexpected shares: %+v", shared.Shares)
+	}
+
+	listed, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: guest})
+	if err != nil {
+		t.Fatalf("list tabs: %v", err)
+	}
+	if len(listed.Tabs) != 1 || listed.Tabs[0].ID != tabID || listed.Tabs[0].Owner != owner || listed.Tabs[0].Access != schema.ShareAccessRead {
+		t.Fatalf("expected annotated shared tab, got %+v", listed.Tabs)
+	}
+	ownerTabs, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: owner})
+	if err != nil {
+		t.Fatalf("list owner tabs: %v", err)
+	}
+	if len(ownerTabs.Tabs) != 1 || ownerTabs.Tabs[0].Owner != "" {
+		t.Fatalf("expected owner view without annotation, got %+v", ownerTabs.Tabs)
+	}
+
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: owner, TabID: tabID, Lines: []string{"hello"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: guest, TabID: tabID, Limit: 10})
+	if err != nil {
+		t.Fatalf("guest buffer: %v", err)
+	}
+	if len(buf.Buffer.Lines) == 0 || buf.Buffer.Lines[len(buf.Buffer.Lines)-1] != "hello" {
+		t.Fatalf("expected guest to see owner output, got %+v", buf.Buffer.Lines)
+	}
+
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: guest, TabID: tabID, Prompt: "hi"}); !errors.Is(err, schema.ErrTabAccessDenied) {
+		t.Fatalf("expected read-only guest prompt to be denied, got %v", err)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: guest, TabID: tabID, Lines: []string{"x"}}); !errors.Is(err, schema.ErrTabAccessDenied) {
+		t.Fatalf("expected read-only guest output to be denied, got %v", err)
+	}
+	if _, err := svc.StopSession(ctx, schema.StopSessionRequest{UserID: guest, TabID: tabID}); !errors.Is(err, schema.ErrTabAccessDenied) {
+		t.Fatalf("expected read-only guest stop to be denied, got %v", err)
+	}
+
+	if _, err := svc.ShareTab(ctx, schema.ShareTabRequest{UserID: owner, TabID: tabID, Guest: guest, Access: schema.ShareAccessReadWrite}); err != nil {
+		t.Fatalf("upgrade share: %v", err)
+	}
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: guest, TabID: tabID, Lines: []string{"from bob"}}); err != nil {
+		t.Fatalf("read-write guest output: %v", err)
+	}
+	if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: guest, TabID: tabID, Prompt: "hi"}); errors.Is(err, schema.ErrTabAccessDenied) {
+		t.Fatalf("expected read-write guest prompt to pass the access check, got %v", err)
+	}
+
+	dispatcher := svc.(EventDispatcher)
+	flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := dispatcher.FlushEvents(flushCtx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	sink.mu.Lock()
+	var guestOutputs, ownerOutputs int
+	for _, event := range sink.outputs {
+		switch event.UserID {
+		case guest:
+			guestOutputs++
+		case owner:
+			ownerOutputs++
+		}
+	}
+	var guestCreated bool
+	for _, event := range sink.tabs {
+		if event.UserID == guest && event.Type == schema.TabEventCreated && event.Tab.Owner == owner {
+			guestCreated = true
+		}
+	}
+	sink.mu.Unlock()
+	if guestOutputs == 0 || guestOutputs != ownerOutputs {
+		t.Fatalf("expected output fanned out to both users, got owner=%d guest=%d", ownerOutputs, guestOutputs)
+	}
+	if !guestCreated {
+		t.Fatalf("expected a created event for the guest")
+	}
+
+	reloaded, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := reloaded.ListTabs(ctx, schema.ListTabsRequest{UserID: owner}); err != nil {
+		t.Fatalf("reload owner: %v", err)
+	}
+	listed, err = reloaded.ListTabs(ctx, schema.ListTabsRequest{UserID: guest})
+	if err != nil {
+		t.Fatalf("reload list: %v", err)
+	}
+	if len(listed.Tabs) != 1 || listed.Tabs[0].Access != schema.ShareAccessReadWrite {
+		t.Fatalf("expected persisted read-write share, got %+v", listed.Tabs)
+	}
+
+	if _, err := reloaded.UnshareTab(ctx, schema.UnshareTabRequest{UserID: owner, TabID: tabID, Guest: guest}); err != nil {
+		t.Fatalf("unshare: %v", err)
+	}
+	listed, err = reloaded.ListTabs(ctx, schema.ListTabsRequest{UserID: guest})
+	if err != nil {
+		t.Fatalf("list after unshare: %v", err)
+	}
+	if len(listed.Tabs) != 0 {
+		t.Fatalf("expected shared tab to be gone, got %+v", listed.Tabs)
+	}
+	if _, err := reloaded.GetBuffer(ctx, schema.GetBufferRequest{UserID: guest, TabID: tabID}); !errors.Is(err, schema.ErrTabNotFound) {
+		t.Fatalf("expected revoked guest to lose access, got %v", err)
+	}
+}
+
+func TestSharedTabScrollIsPerViewer(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}, Users: knownUsers{"bob"}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	owner, guest := schema.UserID("alice"), schema.UserID("bob")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: owner, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.ShareTab(ctx, schema.ShareTabRequest{UserID: owner, TabID: tabID, Guest: guest}); err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: owner, TabID: tabID, Lines: []string{"line"}}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	scrolled, err := svc.ScrollBuffer(ctx, schema.ScrollBufferRequest{UserID: guest, TabID: tabID, Delta: 5, Limit: 5})
+	if err != nil {
+		t.Fatalf("guest scroll: %v", err)
+	}
+	if scrolled.Buffer.ScrollOffset != 5 {
+		t.Fatalf("expected guest offset 5, got %d", scrolled.Buffer.ScrollOffset)
+	}
+	ownerBuf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: owner, TabID: tabID, Limit: 5})
+	if err != nil {
+		t.Fatalf("owner buffer: %v", err)
+	}
+	if ownerBuf.Buffer.ScrollOffset != 0 || !ownerBuf.Buffer.AtBottom {
+		t.Fatalf("expected owner view unaffected by guest scroll, got %+v", ownerBuf.Buffer)
+	}
+}
+
+func TestShareTabRejectsUnknownGuest(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}, Users: knownUsers{"alice"}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: "alice", RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.ShareTab(ctx, schema.ShareTabRequest{UserID: "alice", TabID: tabResp.Tab.ID, Guest: "mallory"}); !errors.Is(err, schema.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	impl := svc.(*service)
+	if _, ok := impl.userTabs["mallory"]; ok {
+		t.Fatalf("expected no state for the unknown guest")
+	}
+	if _, ok, _ := impl.store.Load("mallory"); ok {
+		t.Fatalf("expected no persisted state for the unknown guest")
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestShareLinkLifecycle(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	deps := ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: &fileRunner{}},
+	}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	// Restored links are checked against the real clock.
+	clock := time.Now()
+	svc.(*service).now = func() time.Time { return clock }
+	ctx := context.Background()
+	owner := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: owner, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: owner, TabID: tabID, Lines: []string{"hello"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+
+	if _, err := svc.CreateShareLink(ctx, schema.CreateShareLinkRequest{UserID: "bob", TabID: tabID}); !errors.Is(err, schema.ErrTabNotFound) {
+		t.Fatalf("expected only the owner to create links, got %v", err)
+	}
+	if _, err := svc.CreateShareLink(ctx, schema.CreateShareLinkRequest{UserID: owner, TabID: tabID, TTL: schema.MaxShareLinkTTL + time.Hour}); !errors.Is(err, schema.ErrInvalidRequest) {
+		t.Fatalf("expected ttl above the maximum to be rejected, got %v", err)
+	}
+	created, err := svc.CreateShareLink(ctx, schema.CreateShareLinkRequest{UserID: owner, TabID: tabID})
+	if err != nil {
+		t.Fatalf("create share link: %v", err)
+	}
+	if len(created.Token) < 40 || strings.Contains(created.Token, string(owner)) || !created.ExpiresAt.Equal(clock.Add(schema.DefaultShareLinkTTL)) {
+		t.Fatalf("unexpected link %+v", created)
+	}
+	data, err := os.ReadFile(filepath.Join(stateDir, "sharelinks", "alice.json"))
+	if err != nil || strings.Contains(string(data), created.Token) {
+		t.Fatalf("expected only the token hash on disk, got %q (err %v)", data, err)
+	}
+
+	view, err := svc.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: created.Token, Limit: 10})
+	if err != nil {
+		t.Fatalf("view: %v", err)
+	}
+	if view.Owner != owner || view.TabID != tabID || len(view.Buffer.Lines) == 0 || view.Buffer.Lines[len(view.Buffer.Lines)-1] != "hello" {
+		t.Fatalf("unexpected view %+v", view)
+	}
+	if _, err := svc.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: created.Token + "x"}); !errors.Is(err, schema.ErrShareLinkNotFound) {
+		t.Fatalf("expected unknown token to be rejected, got %v", err)
+	}
+
+	// Links survive a restart.
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	reloaded.(*service).now = func() time.Time { return clock }
+	if _, err := reloaded.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: created.Token}); err != nil {
+		t.Fatalf("expected link to survive a restart, got %v", err)
+	}
+
+	revoked, err := svc.RevokeShareLinks(ctx, schema.RevokeShareLinksRequest{UserID: owner, TabID: tabID})
+	if err != nil || revoked.Revoked != 1 {
+		t.Fatalf("revoke: %+v %v", revoked, err)
+	}
+	if _, err := svc.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: created.Token}); !errors.Is(err, schema.ErrShareLinkNotFound) {
+		t.Fatalf("expected revoked link to be rejected, got %v", err)
+	}
+
+	short, err := svc.CreateShareLink(ctx, schema.CreateShareLinkRequest{UserID: owner, TabID: tabID, TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("create short link: %v", err)
+	}
+	clock = clock.Add(time.Minute)
+	if _, err := svc.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: short.Token}); !errors.Is(err, schema.ErrShareLinkNotFound) {
+		t.Fatalf("expected expired link to be rejected, got %v", err)
+	}
+
+	closing, err := svc.CreateShareLink(ctx, schema.CreateShareLinkRequest{UserID: owner, TabID: tabID})
+	if err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	if _, err := svc.CloseTab(ctx, schema.CloseTabRequest{UserID: owner, TabID: tabID}); err != nil {
+		t.Fatalf("close tab: %v", err)
+	}
+	if _, err := svc.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: closing.Token}); !errors.Is(err, schema.ErrShareLinkNotFound) {
+		t.Fatalf("expected closing the tab to end its links, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "sharelinks", "alice.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no links left on disk, got %v", err)
+	}
+}
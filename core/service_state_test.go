@@ -2,7 +2,10 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"pkt.systems/centaurx/internal/persist"
@@ -49,3 +52,108 @@ func TestPersistedRepoPathIsEmpty(t *testing.T) {
 		t.Fatalf("expected empty repo path, got %q", snapshot.Tabs[0].Repo.Path)
 	}
 }
+
+func TestCorruptStateRecoveredNoticeInSystemBuffer(t *testing.T) {
+	stateDir := t.TempDir()
+	store, err := persist.NewStore(stateDir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	for _, theme := range []schema.ThemeName{"outrun", "gruvbox"} {
+		if err := store.Save("alice", persist.UserSnapshot{Theme: theme}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	path := filepath.Join(stateDir, "alice.json")
+	if err := os.WriteFile(path, []byte(`{"version": 3, "theme": "gru`), 0o600); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: t.TempDir(), StateDir: stateDir}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	resp, err := svc.GetSystemBuffer(ctx, schema.GetSystemBufferRequest{UserID: "alice", Structured: true})
+	if err != nil {
+		t.Fatalf("system buffer: %v", err)
+	}
+	lines := resp.Buffer.Structured
+	if len(lines) == 0 || !strings.HasPrefix(lines[len(lines)-1].Text, "state recovered from backup taken at ") {
+		t.Fatalf("expected recovery notice, got %+v", lines)
+	}
+	if theme := svc.(*service).userTabs["alice"].theme; theme != "outrun" {
+		t.Fatalf("expected theme from backup, got %q", theme)
+	}
+}
+
+func TestCorruptStateWithoutBackupNoticeInSystemBuffer(t *testing.T) {
+	stateDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(stateDir, "alice.json"), []byte(`{"version": 3, "tabs": [`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: t.TempDir(), StateDir: stateDir}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	resp, err := svc.GetSystemBuffer(context.Background(), schema.GetSystemBufferRequest{UserID: "alice", Structured: true})
+	if err != nil {
+		t.Fatalf("system buffer: %v", err)
+	}
+	lines := resp.Buffer.Structured
+	if len(lines) != 1 || lines[0].Kind != schema.LineKindError || !strings.Contains(lines[0].Text, "alice.json.corrupt-") {
+		t.Fatalf("expected corrupt state notice, got %+v", lines)
+	}
+}
+
+func TestTabSnapshotRepoURLAndLastActivity(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	const url = "git@example.com:alice/demo.git"
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoURL: url})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if created.Tab.RepoURL != url || !created.Tab.LastActivityAt.IsZero() {
+		t.Fatalf("expected the repo url and no activity, got %+v", created.Tab)
+	}
+	data, err := json.Marshal(created.Tab)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"RepoURL":"`+url+`"`) || strings.Contains(string(data), "LastActivityAt") {
+		t.Fatalf("unexpected snapshot json: %s", data)
+	}
+
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: created.Tab.ID, Lines: []string{"hello"}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	list, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list tabs: %v", err)
+	}
+	activity := list.Tabs[0].LastActivityAt
+	if activity.IsZero() {
+		t.Fatalf("expected activity after output, got %+v", list.Tabs[0])
+	}
+
+	reloaded, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir}, deps)
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	list, err = reloaded.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list reloaded tabs: %v", err)
+	}
+	if list.Tabs[0].RepoURL != url || !list.Tabs[0].LastActivityAt.Equal(activity) {
+		t.Fatalf("expected the url and activity to survive a restart, got %+v", list.Tabs[0])
+	}
+}
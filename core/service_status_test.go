@@ -0,0 +1,364 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+type accountUsageRunner struct {
+	errorRunner
+	info  UsageInfo
+	calls int
+}
+
+func (u *accountUsageRunner) Usage(context.Context) (UsageInfo, error) {
+	u.calls++
+	return u.info, nil
+}
+
+func TestGetTabStatusReportsTabAndCachedUsage(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	reset := time.Date(2025, time.January, 2, 15, 30, 0, 0, time.UTC)
+	runner := &accountUsageRunner{
+		errorRunner: errorRunner{err: errors.New("unexpected run")},
+		info: UsageInfo{
+			ChatGPT:   true,
+			Primary:   &UsageWindow{UsedPercent: 95, LimitWindowSeconds: 18000, ResetAt: reset.Unix()},
+			Secondary: &UsageWindow{UsedPercent: 40},
+		},
+	}
+	deps := ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: runner, info: RunnerInfo{RepoRoot: "/repos"}},
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	clock := time.Date(2025, time.January, 2, 13, 0, 0, 0, time.UTC)
+	svc.(*service).usage.now = func() time.Time { return clock }
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+
+	if _, err := svc.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: user}); !errors.Is(err, schema.ErrTabNotFound) {
+		t.Fatalf("expected missing tab to be rejected, got %v", err)
+	}
+	resp, err := svc.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	status := resp.Status
+	if status.TabID != tabID || status.Model != tabResp.Tab.Model || status.Directory != "/repos/alice/demo" || status.TokensUsed != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status.Usage == nil || !status.Usage.ChatGPT || status.Usage.Primary == nil || !status.Usage.Primary.ResetAt.Equal(reset) || status.Usage.Primary.WindowSeconds != 18000 {
+		t.Fatalf("unexpected usage: %+v", status.Usage)
+	}
+	if status.Usage.Secondary == nil || !status.Usage.Secondary.ResetAt.IsZero() {
+		t.Fatalf("expected secondary window without reset time, got %+v", status.Usage.Secondary)
+	}
+
+	// The service caches usage; a second status within the TTL does not ask
+	// the runner again and does not repeat the quota warning.
+	if _, err := svc.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: user, TabID: tabID}); err != nil {
+		t.Fatalf("second status: %v", err)
+	}
+	if runner.calls != 1 {
+		t.Fatalf("expected cached usage, runner called %d times", runner.calls)
+	}
+	clock = clock.Add(usageCacheTTL + time.Minute)
+	if _, err := svc.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: user, TabID: tabID}); err != nil {
+		t.Fatalf("third status: %v", err)
+	}
+	if runner.calls != 2 {
+		t.Fatalf("expected usage refetch after TTL, runner called %d times", runner.calls)
+	}
+
+	activity, err := svc.ListActivity(ctx, schema.ListActivityRequest{UserID: user})
+	if err != nil {
+		t.Fatalf("list activity: %v", err)
+	}
+	if len(activity.Entries) != 1 || activity.Entries[0].Text != "event: usage quota warning (5h limit 95% used)" {
+		t.Fatalf("expected a single quota warning, got %+v", activity.Entries)
+	}
+}
+
+func TestGetTabStatusRefreshAndCodexAuthBypassUsageCache(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	runner := &accountUsageRunner{
+		errorRunner: errorRunner{err: errors.New("unexpected run")},
+		info:        UsageInfo{ChatGPT: true, Primary: &UsageWindow{UsedPercent: 10}},
+	}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	status := func(refresh bool) float64 {
+		t.Helper()
+		resp, err := svc.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: user, TabID: tabResp.Tab.ID, Refresh: refresh})
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		return resp.Status.Usage.Primary.UsedPercent
+	}
+
+	if used := status(false); used != 10 || runner.calls != 1 {
+		t.Fatalf("expected the first usage read, got %v after %d calls", used, runner.calls)
+	}
+	runner.info.Primary = &UsageWindow{UsedPercent: 50}
+	if used := status(false); used != 10 || runner.calls != 1 {
+		t.Fatalf("expected cached usage, got %v after %d calls", used, runner.calls)
+	}
+	if used := status(true); used != 50 || runner.calls != 2 {
+		t.Fatalf("expected refresh to read usage again, got %v after %d calls", used, runner.calls)
+	}
+
+	// A new codex login belongs to another account; its usage is read again.
+	runner.info.Primary = &UsageWindow{UsedPercent: 5}
+	if _, err := svc.SaveCodexAuth(ctx, schema.SaveCodexAuthRequest{UserID: user, AuthJSON: []byte(`{"token":"new"}`)}); err != nil {
+		t.Fatalf("save codex auth: %v", err)
+	}
+	if used := status(false); used != 5 || runner.calls != 3 {
+		t.Fatalf("expected usage of the new account, got %v after %d calls", used, runner.calls)
+	}
+}
+
+func TestQuotaWarningRepeatsAfterDroppingBelowThreshold(t *testing.T) {
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: t.TempDir(), StateDir: t.TempDir()}, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	impl := svc.(*service)
+	ctx := context.Background()
+	high := UsageInfo{Primary: &UsageWindow{UsedPercent: 95}}
+	low := UsageInfo{Primary: &UsageWindow{UsedPercent: 10}}
+	for _, info := range []UsageInfo{high, high, low, high} {
+		impl.checkQuota(ctx, "alice", info)
+	}
+	activity, err := svc.ListActivity(ctx, schema.ListActivityRequest{UserID: "alice"})
+	if err != nil {
+		t.Fatalf("list activity: %v", err)
+	}
+	if len(activity.Entries) != 2 {
+		t.Fatalf("expected two quota warnings, got %+v", activity.Entries)
+	}
+}
+
+type turnUsageRunner struct {
+	eventRunner
+	mu   sync.Mutex
+	info UsageInfo
+}
+
+func (u *turnUsageRunner) Usage(context.Context) (UsageInfo, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.info, nil
+}
+
+func (u *turnUsageRunner) setUsed(used float64, resetAt time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.info = UsageInfo{ChatGPT: true, Primary: &UsageWindow{UsedPercent: used, ResetAt: resetAt.Unix()}}
+}
+
+func TestUsageLimitWarningsAfterTurns(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	runner := &turnUsageRunner{eventRunner: eventRunner{events: []schema.ExecEvent{{Type: schema.EventTurnCompleted}}}}
+	svc, err := NewService(schema.ServiceConfig{
+		RepoRoot:               repoRoot,
+		StateDir:               t.TempDir(),
+		UsageWarnBelowPercent:  []int{20, 5},
+		UsageBlockBelowPercent: 3,
+	}, ServiceDeps{
+		RepoResolver:   fakeRepoResolver{repo: repo},
+		RunnerProvider: fakeRunnerProvider{runner: runner},
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	var clockMu sync.Mutex
+	clock := time.Date(2025, time.January, 2, 13, 0, 0, 0, time.UTC)
+	svc.(*service).usage.now = func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return clock
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := tabResp.Tab.ID
+	turn := func() error {
+		if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: "hello"}); err != nil {
+			return err
+		}
+		waitForTabIdle(t, svc, user, tabID)
+		return nil
+	}
+	warnings := func() []string {
+		buf, err := svc.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 500})
+		if err != nil {
+			t.Fatalf("get buffer: %v", err)
+		}
+		return filterLinesWithPrefix(buf.Buffer.Lines, "⚠ ")
+	}
+
+	reset := clock.Add(90 * time.Minute)
+	for _, used := range []float64{70, 82, 85, 96, 96.5} {
+		runner.setUsed(used, reset)
+		if err := turn(); err != nil {
+			t.Fatalf("turn at %.1f%%: %v", used, err)
+		}
+	}
+	want := []string{
+		"⚠ 5h limit at 82% — resets in 1h30m",
+		"⚠ 5h limit at 96% — resets in 1h30m",
+	}
+	if got := warnings(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected one warning per threshold, got %q", got)
+	}
+
+	// Below usage.block_below_percent prompts are refused until the window
+	// resets.
+	runner.setUsed(98, reset)
+	if err := turn(); err != nil {
+		t.Fatalf("turn at 98%%: %v", err)
+	}
+	err = turn()
+	if !errors.Is(err, schema.ErrUsageLimited) || !strings.Contains(err.Error(), "5h limit at 98%") {
+		t.Fatalf("expected usage limit error, got %v", err)
+	}
+	if schema.ErrorCode(err) != schema.CodeUsageLimited {
+		t.Fatalf("expected usage_limited code, got %q", schema.ErrorCode(err))
+	}
+
+	clockMu.Lock()
+	clock = reset.Add(time.Minute)
+	clockMu.Unlock()
+	runner.setUsed(81, clock.Add(5*time.Hour))
+	if err := turn(); err != nil {
+		t.Fatalf("turn after reset: %v", err)
+	}
+	if got := warnings(); len(got) != 3 || got[2] != "⚠ 5h limit at 81% — resets in 5h" {
+		t.Fatalf("expected the warning to repeat in the new window, got %q", got)
+	}
+}
+
+type modelListRunner struct {
+	eventRunner
+	mu    sync.Mutex
+	list  ModelList
+	calls int
+}
+
+func (m *modelListRunner) Models(context.Context) (ModelList, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	return m.list, nil
+}
+
+func (m *modelListRunner) probes() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+func TestModelProbeWarnsOnceAboutUnsupportedModels(t *testing.T) {
+	cases := []struct {
+		name string
+		list ModelList
+		want string
+	}{
+		{
+			name: "unsupported",
+			list: ModelList{Listed: true, Models: []schema.ModelID{"gpt-5.2-codex", "gpt-5.2"}},
+			want: "⚠ your codex account cannot use configured model(s) gpt-9 (default), gpt-9-mini — prompts with them will fail; switch with /model",
+		},
+		{name: "all supported", list: ModelList{Listed: true, Models: []schema.ModelID{"gpt-9", "gpt-9-mini", "gpt-5.2"}}},
+		{name: "not listed", list: ModelList{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := t.TempDir()
+			repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+			runner := &modelListRunner{eventRunner: eventRunner{events: []schema.ExecEvent{{Type: schema.EventTurnCompleted}}}, list: tc.list}
+			svc, err := NewService(schema.ServiceConfig{
+				RepoRoot:      repoRoot,
+				StateDir:      t.TempDir(),
+				DefaultModel:  "gpt-9",
+				AllowedModels: []schema.ModelID{"gpt-9", "gpt-9-mini", "gpt-5.2"},
+			}, ServiceDeps{
+				RepoResolver:   fakeRepoResolver{repo: repo},
+				RunnerProvider: fakeRunnerProvider{runner: runner},
+			})
+			if err != nil {
+				t.Fatalf("new service: %v", err)
+			}
+			ctx := context.Background()
+			user := schema.UserID("alice")
+			tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+			if err != nil {
+				t.Fatalf("create tab: %v", err)
+			}
+			for range 2 {
+				if _, err := svc.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabResp.Tab.ID, Prompt: "hello"}); err != nil {
+					t.Fatalf("send prompt: %v", err)
+				}
+				waitForTabIdle(t, svc, user, tabResp.Tab.ID)
+			}
+			warnings := func() []string {
+				buf, err := svc.GetSystemBuffer(ctx, schema.GetSystemBufferRequest{UserID: user, Limit: 100})
+				if err != nil {
+					t.Fatalf("system buffer: %v", err)
+				}
+				return filterLinesWithPrefix(buf.Buffer.Lines, "⚠ ")
+			}
+			// The probe runs in the background; wait until it is done.
+			cache := svc.(*service).usage
+			deadline := time.Now().Add(2 * time.Second)
+			for time.Now().Before(deadline) {
+				cache.mu.Lock()
+				listed := cache.models[user].Listed
+				cache.mu.Unlock()
+				if runner.probes() > 0 && (!tc.list.Listed || listed && (tc.want == "" || len(warnings()) > 0)) {
+					break
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			if got := strings.Join(warnings(), "\n"); got != tc.want {
+				t.Fatalf("expected warning %q, got %q", tc.want, got)
+			}
+			if probes := runner.probes(); probes != 1 {
+				t.Fatalf("expected one probe per user, got %d", probes)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+type summaryRunner struct {
+	eventRunner
+	runs []RunRequest
+}
+
+func (r *summaryRunner) Run(ctx context.Context, req RunRequest) (RunHandle, error) {
+	r.runs = append(r.runs, req)
+	return r.eventRunner.Run(ctx, req)
+}
+
+func TestRunSummariesWritesDailySummary(t *testing.T) {
+	repoRoot := t.TempDir()
+	stateDir := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	runner := &summaryRunner{eventRunner: eventRunner{events: []schema.ExecEvent{
+		{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{Type: schema.ItemAgentMessage, Text: "- Fixed the parser tests"}},
+		{Type: schema.EventTurnCompleted, Usage: &schema.TurnUsage{InputTokens: 900, OutputTokens: 60}},
+	}}}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}, RunnerProvider: fakeRunnerProvider{runner: runner}}
+	cfg := schema.ServiceConfig{RepoRoot: repoRoot, StateDir: stateDir, Timezone: "UTC", SummariesEnabled: true}
+	svc, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	tabID := created.Tab.ID
+	at := time.Date(2025, time.March, 4, 23, 30, 0, 0, time.UTC)
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: tabID, Structured: []schema.BufferLine{
+		{Kind: schema.LineKindPrompt, Text: "last week's work", Timestamp: at.Add(-30 * time.Hour)},
+		{Kind: schema.LineKindPrompt, Text: "fix the parser tests", Timestamp: at.Add(-2 * time.Hour)},
+		{Kind: schema.LineKindReasoning, Text: "thinking", Timestamp: at.Add(-time.Hour)},
+		{Kind: schema.LineKindAnswer, Text: "Fixed the fixture.", Timestamp: at.Add(-time.Hour)},
+	}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+
+	// Tabs are only summarized after /summary on.
+	svc.(*service).runSummaries(ctx, at)
+	if len(runner.runs) != 0 {
+		t.Fatalf("summary written for a tab without /summary on")
+	}
+	if _, err := svc.SetTabSummaries(ctx, schema.SetTabSummariesRequest{UserID: user, TabID: tabID, Enabled: true}); err != nil {
+		t.Fatalf("set summaries: %v", err)
+	}
+	svc.(*service).runSummaries(ctx, at)
+	svc.(*service).runSummaries(ctx, at)
+	if len(runner.runs) != 1 {
+		t.Fatalf("expected one summary run, got %d", len(runner.runs))
+	}
+	run := runner.runs[0]
+	if run.Model != schema.DefaultSummaryModel || run.ResumeSessionID != "" {
+		t.Fatalf("unexpected summary run %+v", run)
+	}
+	if !strings.Contains(run.Prompt, "21:30 User: fix the parser tests") || !strings.Contains(run.Prompt, "22:30 Codex: Fixed the fixture.") {
+		t.Fatalf("summary prompt misses the day's activity:\n%s", run.Prompt)
+	}
+	if strings.Contains(run.Prompt, "last week") || strings.Contains(run.Prompt, "thinking") {
+		t.Fatalf("summary prompt includes lines it should not:\n%s", run.Prompt)
+	}
+
+	activity, err := svc.ListActivity(ctx, schema.ListActivityRequest{UserID: user})
+	if err != nil || len(activity.Entries) != 1 || !strings.Contains(activity.Entries[0].Text, "tab summary written") || !strings.Contains(activity.Entries[0].Text, "960 tokens") {
+		t.Fatalf("expected summary activity with token usage, got %+v (%v)", activity.Entries, err)
+	}
+
+	reloaded, err := NewService(cfg, deps)
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	listed, err := reloaded.ListTabSummaries(ctx, schema.ListTabSummariesRequest{UserID: user, TabID: tabID})
+	if err != nil {
+		t.Fatalf("list summaries: %v", err)
+	}
+	if !listed.Available || !listed.Enabled || listed.Time != schema.DefaultSummaryTime || len(listed.Summaries) != 1 {
+		t.Fatalf("unexpected summaries after reload: %+v", listed)
+	}
+	summary := listed.Summaries[0]
+	if summary.Date != "2025-03-04" || summary.Text != "- Fixed the parser tests" || summary.Usage == nil || summary.Usage.InputTokens != 900 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
+
+func TestRunSummariesSkipsIdleAndBusyTabs(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	runner := &summaryRunner{}
+	deps := ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}, RunnerProvider: fakeRunnerProvider{runner: runner}}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir(), SummariesEnabled: true}, deps)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	if _, err := svc.SetTabSummaries(ctx, schema.SetTabSummariesRequest{UserID: user, TabID: created.Tab.ID, Enabled: true}); err != nil {
+		t.Fatalf("set summaries: %v", err)
+	}
+	now := time.Now()
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: created.Tab.ID, Structured: []schema.BufferLine{schema.Line(schema.LineKindCommand, "ok  demo 0.01s")}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	// Command output alone is no activity worth summarizing.
+	svc.(*service).runSummaries(ctx, now.Add(time.Minute))
+	if len(runner.runs) != 0 {
+		t.Fatalf("summary written for a tab without prompts")
+	}
+
+	if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: created.Tab.ID, Structured: []schema.BufferLine{schema.Line(schema.LineKindPrompt, "add a flag")}}); err != nil {
+		t.Fatalf("append output: %v", err)
+	}
+	impl := svc.(*service)
+	impl.mu.Lock()
+	impl.userTabs[user].tabs[created.Tab.ID].Status = schema.TabStatusRunning
+	impl.mu.Unlock()
+	impl.summarizeTab(ctx, user, created.Tab.ID, now.Add(time.Minute), summaryRetryMax)
+	if len(runner.runs) != 0 {
+		t.Fatalf("summary written while the tab was busy")
+	}
+}
+
+func TestSetTabSummariesRequiresServerSetting(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "alice", "demo")}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{RepoResolver: fakeRepoResolver{repo: repo}})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	created, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: "alice", RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+	_, err = svc.SetTabSummaries(ctx, schema.SetTabSummariesRequest{UserID: "alice", TabID: created.Tab.ID, Enabled: true})
+	if !errors.Is(err, schema.ErrSummariesDisabled) {
+		t.Fatalf("expected ErrSummariesDisabled, got %v", err)
+	}
+}
+
+func TestSummaryInputCapsAndGroups(t *testing.T) {
+	base := time.Date(2025, time.March, 4, 8, 0, 0, 0, time.UTC)
+	lines := []schema.BufferLine{
+		{Kind: schema.LineKindPrompt, Text: "first", Timestamp: base},
+		{Kind: schema.LineKindAgent, Text: "line one", Timestamp: base.Add(time.Minute)},
+		{Kind: schema.LineKindAgent, Text: "line two", Timestamp: base.Add(time.Minute)},
+		{Kind: schema.LineKindPrompt, Text: strings.Repeat("x", 2*summaryEntryMax), Timestamp: base.Add(time.Hour)},
+		{Kind: schema.LineKindAgent, Text: "third", Timestamp: base.Add(2 * time.Hour)},
+	}
+	input, ok := summaryInput(lines, base, base.Add(24*time.Hour), time.UTC, 1<<10)
+	if !ok {
+		t.Fatalf("expected activity")
+	}
+	if !strings.Contains(input, "08:01 Codex: line one\nline two\n") {
+		t.Fatalf("agent lines not grouped:\n%s", input)
+	}
+	if strings.Contains(input, "third") || !strings.Contains(input, "(2 later entries omitted)") {
+		t.Fatalf("input not capped:\n%s", input)
+	}
+
+	input, _ = summaryInput(lines, base, base.Add(24*time.Hour), time.UTC, 1<<20)
+	if !strings.Contains(input, strings.Repeat("x", summaryEntryMax)+" ...\n") || strings.Contains(input, strings.Repeat("x", summaryEntryMax+1)) {
+		t.Fatalf("long entry not truncated")
+	}
+
+	errorsOnly := []schema.BufferLine{{Kind: schema.LineKindError, Text: "runner failed", Timestamp: base}}
+	if _, ok := summaryInput(errorsOnly, base, base.Add(time.Hour), time.UTC, 1<<10); ok {
+		t.Fatalf("errors alone should not count as activity")
+	}
+}
+
+func TestNextSummaryRun(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	cases := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2025, 3, 4, 10, 0, 0, 0, loc), time.Date(2025, 3, 4, 23, 30, 0, 0, loc)},
+		{time.Date(2025, 3, 4, 23, 30, 0, 0, loc), time.Date(2025, 3, 5, 23, 30, 0, 0, loc)},
+		{time.Date(2025, 12, 31, 23, 45, 0, 0, loc), time.Date(2026, 1, 1, 23, 30, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		if got := nextSummaryRun(tc.now, "23:30"); !got.Equal(tc.want) {
+			t.Fatalf("nextSummaryRun(%v) = %v, want %v", tc.now, got, tc.want)
+		}
+	}
+}
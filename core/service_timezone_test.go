@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestSetTimezonePersistsAndOverridesServerDefault(t *testing.T) {
+	stateDir := t.TempDir()
+	cfg := schema.ServiceConfig{RepoRoot: t.TempDir(), StateDir: stateDir, TimeFormat: "3:04 PM", Timezone: "UTC"}
+	svc, err := NewService(cfg, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	prefs := sessionprefs.New()
+	ctx := sessionprefs.WithContext(context.Background(), prefs)
+	user := schema.UserID("alice")
+
+	list, err := svc.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+	if err != nil || list.Timezone != "UTC" || list.TimeFormat != "3:04 PM" {
+		t.Fatalf("expected server default clock, got %+v err=%v", list, err)
+	}
+	if _, err := svc.SetTimezone(ctx, schema.SetTimezoneRequest{UserID: user, Timezone: "Europe/Atlantis"}); !errors.Is(err, schema.ErrInvalidTimezone) {
+		t.Fatalf("expected invalid timezone error, got %v", err)
+	}
+	resp, err := svc.SetTimezone(ctx, schema.SetTimezoneRequest{UserID: user, Timezone: "America/New_York"})
+	if err != nil || resp.Timezone != "America/New_York" || resp.Default {
+		t.Fatalf("set timezone: %+v err=%v", resp, err)
+	}
+	if prefs.Timezone != "America/New_York" {
+		t.Fatalf("expected session prefs updated, got %q", prefs.Timezone)
+	}
+
+	// Another service instance without session prefs reads the saved zone.
+	reloaded, err := NewService(cfg, ServiceDeps{})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	list, err = reloaded.ListTabs(context.Background(), schema.ListTabsRequest{UserID: user})
+	if err != nil || list.Timezone != "America/New_York" {
+		t.Fatalf("expected persisted timezone, got %+v err=%v", list, err)
+	}
+	// A session's own choice wins over the saved zone.
+	tokyo := sessionprefs.WithContext(context.Background(), &sessionprefs.Prefs{Timezone: "Asia/Tokyo"})
+	list, err = reloaded.ListTabs(tokyo, schema.ListTabsRequest{UserID: user})
+	if err != nil || list.Timezone != "Asia/Tokyo" {
+		t.Fatalf("expected session timezone, got %+v err=%v", list, err)
+	}
+
+	resp, err = reloaded.SetTimezone(context.Background(), schema.SetTimezoneRequest{UserID: user})
+	if err != nil || !resp.Default || resp.Timezone != "UTC" {
+		t.Fatalf("expected reset to server default, got %+v err=%v", resp, err)
+	}
+	list, err = reloaded.ListTabs(context.Background(), schema.ListTabsRequest{UserID: user})
+	if err != nil || list.Timezone != "UTC" {
+		t.Fatalf("expected server default after reset, got %+v err=%v", list, err)
+	}
+}
+
+func TestNewServiceRejectsInvalidClock(t *testing.T) {
+	for _, cfg := range []schema.ServiceConfig{
+		{RepoRoot: t.TempDir(), StateDir: t.TempDir(), Timezone: "Nowhere/Special"},
+		{RepoRoot: t.TempDir(), StateDir: t.TempDir(), TimeFormat: "Jan 2"},
+	} {
+		if _, err := NewService(cfg, ServiceDeps{}); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
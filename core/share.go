@@ -0,0 +1,348 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+// sharedTab is a tab another user has shared with this user.
+type sharedTab struct {
+	tabID schema.TabID
+	owner schema.UserID
+}
+
+// tabRef is a tab resolved for a viewer. access is empty when the viewer
+// owns the tab.
+type tabRef struct {
+	owner  schema.UserID
+	tab    *tab
+	access schema.ShareAccess
+}
+
+func (r tabRef) shared() bool {
+	return r.access != ""
+}
+
+// lookupTabLocked resolves tabID for userID, following share grants to tabs
+// owned by other users. need is the access the operation requires; owners
+// have full access. Grants that no longer exist are dropped from the
+// viewer's shared list.
+func (s *service) lookupTabLocked(userID schema.UserID, tabID schema.TabID, need schema.ShareAccess) (tabRef, error) {
+	state := s.getOrCreateUserStateLocked(userID)
+	if tab := state.tabs[tabID]; tab != nil {
+		return tabRef{owner: userID, tab: tab}, nil
+	}
+	owner, ok := state.sharedOwner(tabID)
+	if !ok {
+		return tabRef{}, schema.ErrTabNotFound
+	}
+	tab := s.getOrCreateUserStateLocked(owner).tabs[tabID]
+	access := tab.shareAccess(userID)
+	if access == "" {
+		state.removeShared(tabID)
+		return tabRef{}, schema.ErrTabNotFound
+	}
+	if need == schema.ShareAccessReadWrite && access != schema.ShareAccessReadWrite {
+		return tabRef{}, fmt.Errorf("%w: %s shared this tab read-only", schema.ErrTabAccessDenied, owner)
+	}
+	return tabRef{owner: owner, tab: tab, access: access}, nil
+}
+
+// checkAccountWrite rejects prompts and stops from sessions of accounts the
+// auth backend maps to read-only access.
+func checkAccountWrite(ctx context.Context) error {
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.Capabilities.ReadOnly {
+		return schema.ErrReadOnlyAccount
+	}
+	return nil
+}
+
+// snapshotRef snapshots the tab as seen by the viewer ref was resolved for.
+func (s *service) snapshotRef(ref tabRef, active bool) schema.TabSnapshot {
+	snapshot := s.snapshotTab(ref.owner, ref.tab, active)
+	if ref.shared() {
+		snapshot.Owner = ref.owner
+		snapshot.Access = ref.access
+	}
+	return snapshot
+}
+
+// viewBufferLocked snapshots the tab buffer at the viewer's scroll offset.
+func viewBufferLocked(ref tabRef, viewer schema.UserID, limit int) bufferView {
+	if ref.shared() {
+		return ref.tab.buffer.SnapshotGuest(viewer, limit)
+	}
+	return ref.tab.buffer.Snapshot(limit)
+}
+
+// tabEventLocked builds a tab event for the owner. Events caused by a guest
+// carry no activation state since the owner's active tab is unknown.
+func (s *service) tabEventLocked(ref tabRef, eventType schema.TabEventType, active schema.TabID) schema.TabEvent {
+	if ref.shared() {
+		active = ""
+	}
+	return schema.TabEvent{
+		UserID:    ref.owner,
+		Type:      eventType,
+		Tab:       s.snapshotTab(ref.owner, ref.tab, ref.tab.ID == active),
+		ActiveTab: active,
+	}
+}
+
+// tabSharesLocked returns the grants of the owner's tab.
+func (s *service) tabSharesLocked(owner schema.UserID, tabID schema.TabID) []schema.TabShare {
+	state := s.userTabs[owner]
+	if state == nil {
+		return nil
+	}
+	if tab := state.tabs[tabID]; tab != nil {
+		return append([]schema.TabShare(nil), tab.shares...)
+	}
+	return nil
+}
+
+func (s *service) ShareTab(ctx context.Context, req schema.ShareTabRequest) (schema.ShareTabResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ShareTabResponse{}, err
+	}
+	guest, err := normalizeUserID(req.Guest)
+	if err != nil {
+		return schema.ShareTabResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID).With("guest", guest)
+	access := req.Access
+	switch access {
+	case "":
+		access = schema.ShareAccessRead
+	case schema.ShareAccessRead, schema.ShareAccessReadWrite:
+	default:
+		return schema.ShareTabResponse{}, fmt.Errorf("%w: unknown share access %q", schema.ErrInvalidRequest, access)
+	}
+	if guest == userID {
+		return schema.ShareTabResponse{}, fmt.Errorf("%w: cannot share a tab with yourself", schema.ErrInvalidRequest)
+	}
+	if !s.userExists(guest) {
+		log.Warn("service tab share failed", "err", schema.ErrUserNotFound)
+		return schema.ShareTabResponse{}, fmt.Errorf("%w: %s", schema.ErrUserNotFound, guest)
+	}
+
+	s.mu.Lock()
+	tab := s.getOrCreateUserStateLocked(userID).tabs[req.TabID]
+	if tab == nil {
+		s.mu.Unlock()
+		log.Warn("service tab share failed", "err", schema.ErrTabNotFound)
+		return schema.ShareTabResponse{}, schema.ErrTabNotFound
+	}
+	added := tab.shareAccess(guest) == ""
+	tab.setShare(guest, access)
+	shares := append([]schema.TabShare(nil), tab.shares...)
+	guestState := s.getOrCreateUserStateLocked(guest)
+	if _, ok := guestState.sharedOwner(req.TabID); !ok {
+		guestState.shared = append(guestState.shared, sharedTab{tabID: req.TabID, owner: userID})
+	}
+	eventType := schema.TabEventUpdated
+	if added {
+		eventType = schema.TabEventCreated
+	}
+	event := schema.TabEvent{
+		UserID: guest,
+		Type:   eventType,
+		Tab:    s.snapshotRef(tabRef{owner: userID, tab: tab, access: access}, false),
+	}
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, userID)
+	s.persistUser(log, guest)
+	log.Info("service tab shared", "access", access)
+	return schema.ShareTabResponse{Shares: shares}, nil
+}
+
+// userExists reports whether userID is an account of the auth store or a
+// user the service has state for, in memory or persisted.
+func (s *service) userExists(userID schema.UserID) bool {
+	if s.users != nil && s.users.HasUser(string(userID)) {
+		return true
+	}
+	s.mu.Lock()
+	_, ok := s.userTabs[userID]
+	s.mu.Unlock()
+	if ok || s.store == nil {
+		return ok
+	}
+	_, ok, err := s.store.Load(userID)
+	return err == nil && ok
+}
+
+func (s *service) UnshareTab(ctx context.Context, req schema.UnshareTabRequest) (schema.UnshareTabResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.UnshareTabResponse{}, err
+	}
+	guest, err := normalizeUserID(req.Guest)
+	if err != nil {
+		return schema.UnshareTabResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID).With("guest", guest)
+
+	s.mu.Lock()
+	tab := s.getOrCreateUserStateLocked(userID).tabs[req.TabID]
+	if tab == nil {
+		s.mu.Unlock()
+		log.Warn("service tab unshare failed", "err", schema.ErrTabNotFound)
+		return schema.UnshareTabResponse{}, schema.ErrTabNotFound
+	}
+	access := tab.shareAccess(guest)
+	if access == "" {
+		s.mu.Unlock()
+		return schema.UnshareTabResponse{}, fmt.Errorf("%w: tab is not shared with %s", schema.ErrInvalidRequest, guest)
+	}
+	event := s.revokeShareLocked(tabRef{owner: userID, tab: tab, access: access}, guest)
+	shares := append([]schema.TabShare(nil), tab.shares...)
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, userID)
+	s.persistUser(log, guest)
+	log.Info("service tab unshared")
+	return schema.UnshareTabResponse{Shares: shares}, nil
+}
+
+// leaveSharedTab removes a tab shared with guest from the guest's tabs. It
+// reports false when tabID is not shared with guest.
+func (s *service) leaveSharedTab(ctx context.Context, guest schema.UserID, tabID schema.TabID) (schema.CloseTabResponse, bool) {
+	log := logx.WithUserTab(ctx, guest, tabID)
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(guest, tabID, schema.ShareAccessRead)
+	if err != nil {
+		s.mu.Unlock()
+		return schema.CloseTabResponse{}, false
+	}
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.ActiveTab == tabID {
+		prefs.ActiveTab = ""
+	}
+	event := s.revokeShareLocked(ref, guest)
+	event.ActiveTab = activeTabFromContext(ctx, s.getOrCreateUserStateLocked(guest))
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, ref.owner)
+	s.persistUser(log, guest)
+	log.Info("service shared tab left", "owner", ref.owner)
+	return schema.CloseTabResponse{Tab: event.Tab}, true
+}
+
+// revokeShareLocked removes guest's grant to ref.tab and returns the closed
+// event for the guest.
+func (s *service) revokeShareLocked(ref tabRef, guest schema.UserID) schema.TabEvent {
+	ref.tab.removeShare(guest)
+	s.getOrCreateUserStateLocked(guest).removeShared(ref.tab.ID)
+	if ref.tab.buffer != nil {
+		delete(ref.tab.buffer.guestOffsets, guest)
+	}
+	return schema.TabEvent{
+		UserID: guest,
+		Type:   schema.TabEventClosed,
+		Tab:    s.snapshotRef(ref, false),
+	}
+}
+
+// dropGuestsLocked removes a closed tab from its guests' shared lists and
+// returns the closed events for them.
+func (s *service) dropGuestsLocked(owner schema.UserID, tab *tab) []schema.TabEvent {
+	events := make([]schema.TabEvent, 0, len(tab.shares))
+	for _, share := range tab.shares {
+		s.getOrCreateUserStateLocked(share.User).removeShared(tab.ID)
+		events = append(events, schema.TabEvent{
+			UserID: share.User,
+			Type:   schema.TabEventClosed,
+			Tab:    s.snapshotRef(tabRef{owner: owner, tab: tab, access: share.Access}, false),
+		})
+	}
+	return events
+}
+
+func (t *tab) shareAccess(user schema.UserID) schema.ShareAccess {
+	if t == nil {
+		return ""
+	}
+	for _, share := range t.shares {
+		if share.User == user {
+			return share.Access
+		}
+	}
+	return ""
+}
+
+func (t *tab) setShare(user schema.UserID, access schema.ShareAccess) {
+	for i := range t.shares {
+		if t.shares[i].User == user {
+			t.shares[i].Access = access
+			return
+		}
+	}
+	t.shares = append(t.shares, schema.TabShare{User: user, Access: access})
+}
+
+func (t *tab) removeShare(user schema.UserID) {
+	for i, share := range t.shares {
+		if share.User == user {
+			t.shares = append(t.shares[:i:i], t.shares[i+1:]...)
+			return
+		}
+	}
+}
+
+func (u *userState) sharedOwner(tabID schema.TabID) (schema.UserID, bool) {
+	for _, entry := range u.shared {
+		if entry.tabID == tabID {
+			return entry.owner, true
+		}
+	}
+	return "", false
+}
+
+func (u *userState) removeShared(tabID schema.TabID) {
+	for i, entry := range u.shared {
+		if entry.tabID == tabID {
+			u.shared = append(u.shared[:i:i], u.shared[i+1:]...)
+			return
+		}
+	}
+}
+
+func loadTabShares(shares []persist.TabShare) []schema.TabShare {
+	var out []schema.TabShare
+	for _, share := range shares {
+		if share.User == "" {
+			continue
+		}
+		out = append(out, schema.TabShare{User: share.User, Access: share.Access})
+	}
+	return out
+}
+
+func exportTabShares(shares []schema.TabShare) []persist.TabShare {
+	if len(shares) == 0 {
+		return nil
+	}
+	out := make([]persist.TabShare, 0, len(shares))
+	for _, share := range shares {
+		out = append(out, persist.TabShare{User: share.User, Access: share.Access})
+	}
+	return out
+}
+
+func exportSharedTabs(shared []sharedTab) []persist.SharedTab {
+	if len(shared) == 0 {
+		return nil
+	}
+	out := make([]persist.SharedTab, 0, len(shared))
+	for _, entry := range shared {
+		out = append(out, persist.SharedTab{TabID: entry.tabID, Owner: entry.owner})
+	}
+	return out
+}
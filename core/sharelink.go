@@ -0,0 +1,213 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// shareLink is a /sharelink grant, keyed by the SHA-256 of its token in
+// service.shareLinks. The token itself is only handed to the owner.
+type shareLink struct {
+	owner     schema.UserID
+	tabID     schema.TabID
+	expiresAt time.Time
+}
+
+func (s *service) CreateShareLink(ctx context.Context, req schema.CreateShareLinkRequest) (schema.CreateShareLinkResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.CreateShareLinkResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	ttl := req.TTL
+	switch {
+	case ttl == 0:
+		ttl = schema.DefaultShareLinkTTL
+	case ttl < 0:
+		return schema.CreateShareLinkResponse{}, fmt.Errorf("%w: share link ttl must be positive", schema.ErrInvalidRequest)
+	case ttl > schema.MaxShareLinkTTL:
+		return schema.CreateShareLinkResponse{}, fmt.Errorf("%w: share links last at most %s", schema.ErrInvalidRequest, schema.MaxShareLinkTTL)
+	}
+	token, err := newShareLinkToken()
+	if err != nil {
+		return schema.CreateShareLinkResponse{}, err
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	// Only the owner can hand out links; guests get the same not found as
+	// for /share.
+	if s.getOrCreateUserStateLocked(userID).tabs[req.TabID] == nil {
+		s.mu.Unlock()
+		log.Warn("service share link create failed", "err", schema.ErrTabNotFound)
+		return schema.CreateShareLinkResponse{}, schema.ErrTabNotFound
+	}
+	expired := s.sweepShareLinksLocked(now)
+	link := shareLink{owner: userID, tabID: req.TabID, expiresAt: now.Add(ttl)}
+	s.shareLinks[hashShareLinkToken(token)] = link
+	s.mu.Unlock()
+	s.persistShareLinks(log, userID)
+	for _, owner := range expired {
+		if owner != userID {
+			s.persistShareLinks(log, owner)
+		}
+	}
+	log.Info("service share link created", "expires_at", link.expiresAt)
+	return schema.CreateShareLinkResponse{Token: token, ExpiresAt: link.expiresAt}, nil
+}
+
+func (s *service) RevokeShareLinks(ctx context.Context, req schema.RevokeShareLinksRequest) (schema.RevokeShareLinksResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.RevokeShareLinksResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+
+	s.mu.Lock()
+	if s.getOrCreateUserStateLocked(userID).tabs[req.TabID] == nil {
+		s.mu.Unlock()
+		log.Warn("service share link revoke failed", "err", schema.ErrTabNotFound)
+		return schema.RevokeShareLinksResponse{}, schema.ErrTabNotFound
+	}
+	revoked := s.dropShareLinksLocked(userID, req.TabID)
+	s.mu.Unlock()
+	if revoked > 0 {
+		s.persistShareLinks(log, userID)
+	}
+	log.Info("service share links revoked", "count", revoked)
+	return schema.RevokeShareLinksResponse{Revoked: revoked}, nil
+}
+
+// ViewShareLink resolves a link token. It is called for every page load and
+// event of a link viewer, so revoked and expired links stop working at once.
+func (s *service) ViewShareLink(ctx context.Context, req schema.ViewShareLinkRequest) (schema.ViewShareLinkResponse, error) {
+	if req.Token == "" {
+		return schema.ViewShareLinkResponse{}, schema.ErrShareLinkNotFound
+	}
+	hash := hashShareLinkToken(req.Token)
+	now := s.now()
+
+	s.mu.Lock()
+	link, ok := s.shareLinks[hash]
+	if !ok {
+		s.mu.Unlock()
+		return schema.ViewShareLinkResponse{}, schema.ErrShareLinkNotFound
+	}
+	log := logx.WithUserTab(ctx, link.owner, link.tabID)
+	tab := s.getOrCreateUserStateLocked(link.owner).tabs[link.tabID]
+	if tab == nil || !now.Before(link.expiresAt) {
+		delete(s.shareLinks, hash)
+		s.mu.Unlock()
+		s.persistShareLinks(log, link.owner)
+		reason := "expired"
+		if tab == nil {
+			reason = "tab closed"
+		}
+		log.Info("service share link dropped", "reason", reason)
+		return schema.ViewShareLinkResponse{}, schema.ErrShareLinkNotFound
+	}
+	// Link viewers always follow the bottom of the buffer.
+	offset := 0
+	view := tab.buffer.snapshotAt(&offset, req.Limit)
+	resp := schema.ViewShareLinkResponse{
+		Owner:     link.owner,
+		TabID:     tab.ID,
+		TabName:   tab.Name,
+		Buffer:    mapBufferSnapshot(tab.ID, view),
+		ExpiresAt: link.expiresAt,
+	}
+	s.mu.Unlock()
+	log.Trace("service share link viewed", "lines", view.TotalLines)
+	return resp, nil
+}
+
+// dropShareLinksLocked removes the links to the owner's tab and returns how
+// many there were.
+func (s *service) dropShareLinksLocked(owner schema.UserID, tabID schema.TabID) int {
+	dropped := 0
+	for hash, link := range s.shareLinks {
+		if link.owner == owner && link.tabID == tabID {
+			delete(s.shareLinks, hash)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// sweepShareLinksLocked removes expired links and returns their owners.
+func (s *service) sweepShareLinksLocked(now time.Time) []schema.UserID {
+	var owners []schema.UserID
+	for hash, link := range s.shareLinks {
+		if now.Before(link.expiresAt) {
+			continue
+		}
+		delete(s.shareLinks, hash)
+		if !slices.Contains(owners, link.owner) {
+			owners = append(owners, link.owner)
+		}
+	}
+	return owners
+}
+
+func (s *service) persistShareLinks(log pslog.Logger, userID schema.UserID) {
+	if s.store == nil {
+		return
+	}
+	s.mu.Lock()
+	snapshot := persist.ShareLinkSnapshot{User: userID}
+	for hash, link := range s.shareLinks {
+		if link.owner == userID {
+			snapshot.Links = append(snapshot.Links, persist.ShareLink{Hash: hash, TabID: link.tabID, ExpiresAt: link.expiresAt})
+		}
+	}
+	s.mu.Unlock()
+	if err := s.store.SaveShareLinks(snapshot); err != nil && log != nil {
+		log.Warn("service share link persist failed", "err", err)
+	}
+}
+
+// loadShareLinks restores saved links that have not expired.
+func (s *service) loadShareLinks() {
+	if s.store == nil {
+		return
+	}
+	snapshots, err := s.store.LoadShareLinks()
+	if err != nil {
+		s.logger.Warn("service share link load failed", "err", err)
+	}
+	now := s.now()
+	for _, snapshot := range snapshots {
+		for _, link := range snapshot.Links {
+			if link.Hash == "" || !now.Before(link.ExpiresAt) {
+				continue
+			}
+			s.shareLinks[link.Hash] = shareLink{owner: snapshot.User, tabID: link.TabID, expiresAt: link.ExpiresAt}
+		}
+	}
+}
+
+// newShareLinkToken returns 256 random bits; the token carries nothing
+// about the owner or the tab.
+func newShareLinkToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashShareLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
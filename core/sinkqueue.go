@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// EventDispatcher is implemented by services that deliver EventSink calls
+// asynchronously from a bounded queue.
+type EventDispatcher interface {
+	// FlushEvents blocks until every event queued before the call has been
+	// delivered or ctx is done.
+	FlushEvents(ctx context.Context) error
+	// CloseEvents drains the queue and stops delivery. Events emitted after
+	// CloseEvents are discarded.
+	CloseEvents(ctx context.Context) error
+	// EventQueueStats reports queue depth and drop counters.
+	EventQueueStats() EventQueueStats
+}
+
+// EventQueueStats reports sink dispatch queue metrics.
+type EventQueueStats struct {
+	Depth          int
+	DroppedOutputs uint64
+	Delivered      uint64
+}
+
+type sinkEventKind int
+
+const (
+	sinkEventOutput sinkEventKind = iota
+	sinkEventSystemOutput
+	sinkEventTab
+)
+
+type sinkEvent struct {
+	kind   sinkEventKind
+	output schema.OutputEvent
+	system schema.SystemOutputEvent
+	tab    schema.TabEvent
+}
+
+// droppable reports whether the event may be discarded under pressure. Only
+// tab output is dropped; tab events carry status transitions and system
+// output carries notices the user has no other way to see.
+func (e sinkEvent) droppable() bool {
+	return e.kind == sinkEventOutput
+}
+
+// sinkQueue delivers events to an EventSink in order on a dedicated
+// goroutine so slow sinks never block the buffer append path.
+type sinkQueue struct {
+	sink EventSink
+	max  int
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	events    []sinkEvent
+	inflight  bool
+	closed    bool
+	enqueued  uint64
+	completed uint64 // delivered or dropped after being enqueued
+	delivered uint64
+	dropped   uint64
+	done      chan struct{}
+}
+
+func newSinkQueue(sink EventSink, max int) *sinkQueue {
+	if max <= 0 {
+		max = schema.DefaultEventQueueSize
+	}
+	q := &sinkQueue{sink: sink, max: max, done: make(chan struct{})}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+func (q *sinkQueue) enqueue(event sinkEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	if len(q.events) >= q.max && !q.dropOldestOutputLocked() && event.droppable() {
+		// The queue holds no output to drop; drop the new output instead.
+		q.dropped++
+		return
+	}
+	q.events = append(q.events, event)
+	q.enqueued++
+	q.cond.Broadcast()
+}
+
+// dropOldestOutputLocked removes the oldest output event, counting it as
+// completed so flush watermarks still advance.
+func (q *sinkQueue) dropOldestOutputLocked() bool {
+	for i, queued := range q.events {
+		if !queued.droppable() {
+			continue
+		}
+		q.events = append(q.events[:i], q.events[i+1:]...)
+		q.dropped++
+		q.completed++
+		return true
+	}
+	return false
+}
+
+func (q *sinkQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for len(q.events) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.events) == 0 && q.closed {
+			q.mu.Unlock()
+			return
+		}
+		event := q.events[0]
+		q.events[0] = sinkEvent{}
+		q.events = q.events[1:]
+		q.inflight = true
+		q.mu.Unlock()
+
+		q.deliver(event)
+
+		q.mu.Lock()
+		q.inflight = false
+		q.delivered++
+		q.completed++
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+func (q *sinkQueue) deliver(event sinkEvent) {
+	switch event.kind {
+	case sinkEventOutput:
+		q.sink.OnOutput(event.output)
+	case sinkEventSystemOutput:
+		q.sink.OnSystemOutput(event.system)
+	case sinkEventTab:
+		q.sink.OnTabEvent(event.tab)
+	}
+}
+
+func (q *sinkQueue) flush(ctx context.Context) error {
+	q.mu.Lock()
+	target := q.enqueued
+	q.mu.Unlock()
+	return q.waitFor(ctx, func() bool { return q.completed >= target })
+}
+
+func (q *sinkQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitFor blocks until cond holds (evaluated under q.mu) or ctx is done.
+func (q *sinkQueue) waitFor(ctx context.Context, cond func() bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	defer stop()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !cond() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q.cond.Wait()
+	}
+	return nil
+}
+
+func (q *sinkQueue) stats() EventQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	depth := len(q.events)
+	if q.inflight {
+		depth++
+	}
+	return EventQueueStats{Depth: depth, DroppedOutputs: q.dropped, Delivered: q.delivered}
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+type slowSink struct {
+	delay time.Duration
+	gate  chan struct{}
+
+	mu      sync.Mutex
+	outputs []schema.OutputEvent
+	system  []schema.SystemOutputEvent
+	tabs    []schema.TabEvent
+}
+
+func (s *slowSink) wait() {
+	if s.gate != nil {
+		<-s.gate
+	}
+	if s.delay > 0 {
+		time.Sleep(s.delay)
+	}
+}
+
+func (s *slowSink) OnOutput(event schema.OutputEvent) {
+	s.wait()
+	s.mu.Lock()
+	s.outputs = append(s.outputs, event)
+	s.mu.Unlock()
+}
+
+func (s *slowSink) OnSystemOutput(event schema.SystemOutputEvent) {
+	s.wait()
+	s.mu.Lock()
+	s.system = append(s.system, event)
+	s.mu.Unlock()
+}
+
+func (s *slowSink) OnTabEvent(event schema.TabEvent) {
+	s.wait()
+	s.mu.Lock()
+	s.tabs = append(s.tabs, event)
+	s.mu.Unlock()
+}
+
+func TestAppendLatencyWithSlowSink(t *testing.T) {
+	repoRoot := t.TempDir()
+	repo := schema.RepoRef{Name: "demo", Path: filepath.Join(repoRoot, "demo")}
+	sink := &slowSink{delay: 20 * time.Millisecond}
+	svc, err := NewService(schema.ServiceConfig{RepoRoot: repoRoot, StateDir: t.TempDir()}, ServiceDeps{
+		RepoResolver: fakeRepoResolver{repo: repo},
+		EventSink:    sink,
+	})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := context.Background()
+	user := schema.UserID("alice")
+	tabResp, err := svc.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: repo.Name})
+	if err != nil {
+		t.Fatalf("create tab: %v", err)
+	}
+
+	const appends = 100
+	started := time.Now()
+	for i := 0; i < appends; i++ {
+		if _, err := svc.AppendOutput(ctx, schema.AppendOutputRequest{UserID: user, TabID: tabResp.Tab.ID, Lines: []string{"line"}}); err != nil {
+			t.Fatalf("append output: %v", err)
+		}
+	}
+	total := time.Since(started)
+	// Synchronous delivery would take appends*delay (2s); the queue keeps
+	// each append independent of the sink.
+	if total > time.Duration(appends)*sink.delay/4 {
+		t.Fatalf("appends took %s with a slow sink", total)
+	}
+
+	dispatcher := svc.(EventDispatcher)
+	flushCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := dispatcher.FlushEvents(flushCtx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	stats := dispatcher.EventQueueStats()
+	if stats.Depth != 0 {
+		t.Fatalf("expected empty queue after flush, got %+v", stats)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.outputs) != appends || len(sink.tabs) != 1 {
+		t.Fatalf("expected %d outputs and 1 tab event, got %d and %d", appends, len(sink.outputs), len(sink.tabs))
+	}
+}
+
+func TestSinkQueueNeverDropsTabEvents(t *testing.T) {
+	sink := &slowSink{gate: make(chan struct{})}
+	q := newSinkQueue(sink, 4)
+	const tabEvents = 20
+	for i := 0; i < tabEvents; i++ {
+		for j := 0; j < 10; j++ {
+			q.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{TabID: schema.TabID("t"), Lines: []string{"x"}}})
+		}
+		q.enqueue(sinkEvent{kind: sinkEventTab, tab: schema.TabEvent{Type: schema.TabEventStatus, Tab: schema.TabSnapshot{ID: schema.TabID(string(rune('a' + i)))}}})
+	}
+	stats := q.stats()
+	if stats.DroppedOutputs == 0 {
+		t.Fatalf("expected outputs dropped under pressure, got %+v", stats)
+	}
+	if stats.Depth < tabEvents {
+		t.Fatalf("expected queue to grow past its bound for tab events, got depth %d", stats.Depth)
+	}
+	close(sink.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if err := q.close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.tabs) != tabEvents {
+		t.Fatalf("expected %d tab events, got %d", tabEvents, len(sink.tabs))
+	}
+	for i, event := range sink.tabs {
+		if want := schema.TabID(string(rune('a' + i))); event.Tab.ID != want {
+			t.Fatalf("tab event %d out of order: got %q want %q", i, event.Tab.ID, want)
+		}
+	}
+	final := q.stats()
+	if final.Delivered+final.DroppedOutputs != uint64(tabEvents*11) {
+		t.Fatalf("unexpected counters: %+v", final)
+	}
+}
+
+func TestSinkQueueCloseDrains(t *testing.T) {
+	sink := &slowSink{delay: time.Millisecond}
+	q := newSinkQueue(sink, 64)
+	for i := 0; i < 10; i++ {
+		q.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{Lines: []string{"x"}}})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	q.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{Lines: []string{"late"}}})
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.outputs) != 10 {
+		t.Fatalf("expected 10 drained outputs, got %d", len(sink.outputs))
+	}
+}
+
+func TestSinkQueueKeepsSystemOutput(t *testing.T) {
+	sink := &slowSink{gate: make(chan struct{})}
+	q := newSinkQueue(sink, 4)
+	// Park the delivery goroutine on a first event so the system output
+	// stays queued behind it.
+	q.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{TabID: "t", Lines: []string{"first"}}})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		q.mu.Lock()
+		parked := q.inflight
+		q.mu.Unlock()
+		if parked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	q.enqueue(sinkEvent{kind: sinkEventSystemOutput, system: schema.SystemOutputEvent{Lines: []string{"notice"}}})
+	for i := 0; i < 20; i++ {
+		q.enqueue(sinkEvent{kind: sinkEventOutput, output: schema.OutputEvent{TabID: "t", Lines: []string{"x"}}})
+	}
+	if stats := q.stats(); stats.DroppedOutputs == 0 {
+		t.Fatalf("expected outputs dropped under pressure, got %+v", stats)
+	}
+	close(sink.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.system) != 1 || sink.system[0].Lines[0] != "notice" {
+		t.Fatalf("expected the system output to survive the overflow, got %+v", sink.system)
+	}
+}
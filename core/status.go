@@ -0,0 +1,436 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/timefmt"
+	"pkt.systems/centaurx/schema"
+)
+
+// usageCacheTTL is how long account usage read from a runner is reused.
+// Usage lookups start the user's runner, so /status and the HTTP status
+// endpoint share this cache instead of asking the runner every time.
+const usageCacheTTL = 30 * time.Minute
+
+// quotaWarnPercent is the used share of a usage window at which the user is
+// warned through the activity feed.
+const quotaWarnPercent = 90
+
+// usageRefreshTimeout bounds the usage lookup made when a turn completes.
+const usageRefreshTimeout = 5 * time.Second
+
+// usageCache caches account usage per user and tracks which users have been
+// warned about their current quota.
+type usageCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[schema.UserID]usageCacheEntry
+	warned  map[schema.UserID]bool
+	// limits holds the lowest usage.warn_below_percent threshold each
+	// window has been warned about, keyed by user and window label.
+	limits map[schema.UserID]map[string]limitWarning
+	// models holds the models each user's codex account can use. A user is
+	// present from the start of the first probe, so each account is probed
+	// once.
+	models map[schema.UserID]ModelList
+}
+
+type limitWarning struct {
+	threshold int
+	resetAt   time.Time
+}
+
+type usageCacheEntry struct {
+	fetchedAt time.Time
+	info      UsageInfo
+	err       error
+}
+
+func newUsageCache(ttl time.Duration) *usageCache {
+	return &usageCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[schema.UserID]usageCacheEntry),
+		warned:  make(map[schema.UserID]bool),
+		limits:  make(map[schema.UserID]map[string]limitWarning),
+		models:  make(map[schema.UserID]ModelList),
+	}
+}
+
+func (c *usageCache) get(userID schema.UserID) (usageCacheEntry, bool) {
+	if c.ttl <= 0 {
+		return usageCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[userID]
+	if !ok {
+		return usageCacheEntry{}, false
+	}
+	if c.now().Sub(entry.fetchedAt) > c.ttl {
+		delete(c.entries, userID)
+		return usageCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *usageCache) store(userID schema.UserID, info UsageInfo, err error) usageCacheEntry {
+	entry := usageCacheEntry{fetchedAt: c.now(), info: info, err: err}
+	if c.ttl <= 0 || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return entry
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[userID] = entry
+	return entry
+}
+
+// invalidate drops the cached usage and the warning state of userID, whose
+// account changed.
+func (c *usageCache) invalidate(userID schema.UserID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+	delete(c.warned, userID)
+	delete(c.limits, userID)
+	delete(c.models, userID)
+}
+
+// startModelProbe reports whether the models of userID still need probing
+// and marks them as probed.
+func (c *usageCache) startModelProbe(userID schema.UserID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.models[userID]; ok {
+		return false
+	}
+	c.models[userID] = ModelList{}
+	return true
+}
+
+func (c *usageCache) storeModels(userID schema.UserID, list ModelList) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.models[userID] = list
+}
+
+// markWarned records whether userID is over the quota threshold and reports
+// whether a warning should be issued, i.e. the user just crossed it.
+func (c *usageCache) markWarned(userID schema.UserID, over bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	warned := c.warned[userID]
+	c.warned[userID] = over
+	return over && !warned
+}
+
+// markLimitWarned records that a usage window of userID has dropped below
+// threshold (0 when above every threshold) and reports whether to warn: the
+// threshold is lower than any warned about since the window last reset.
+func (c *usageCache) markLimitWarned(userID schema.UserID, window string, threshold int, resetAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	windows := c.limits[userID]
+	prev, ok := windows[window]
+	if ok && !prev.resetAt.IsZero() && !c.now().Before(prev.resetAt) {
+		ok = false
+	}
+	if threshold == 0 {
+		delete(windows, window)
+		return false
+	}
+	if ok && prev.threshold <= threshold {
+		return false
+	}
+	if windows == nil {
+		windows = make(map[string]limitWarning)
+		c.limits[userID] = windows
+	}
+	windows[window] = limitWarning{threshold: threshold, resetAt: resetAt}
+	return true
+}
+
+// GetTabStatus reports the model, directory, session and usage of a tab.
+func (s *service) GetTabStatus(ctx context.Context, req schema.GetTabStatusRequest) (schema.GetTabStatusResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.GetTabStatusResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	if req.TabID == "" {
+		return schema.GetTabStatusResponse{}, schema.ErrTabNotFound
+	}
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service tab status failed", "err", err)
+		return schema.GetTabStatusResponse{}, err
+	}
+	snapshot := s.snapshotTab(ref.owner, ref.tab, false)
+	tokensUsed := 0
+	if usage := ref.tab.LastUsage; usage != nil {
+		tokensUsed = usage.InputTokens + usage.OutputTokens
+	}
+	s.mu.Unlock()
+
+	status := schema.TabStatusInfo{
+		TabID:                req.TabID,
+		Model:                snapshot.Model,
+		ModelReasoningEffort: snapshot.ModelReasoningEffort,
+		Directory:            s.statusDirectory(ctx, ref.owner, req.TabID, snapshot.Repo.Name),
+		SessionID:            snapshot.SessionID,
+		TokensUsed:           tokensUsed,
+		Usage:                s.accountUsage(ctx, userID, req.TabID, req.Refresh),
+		Ephemeral:            snapshot.Ephemeral,
+	}
+	log.Debug("service tab status fetched", "tokens_used", tokensUsed, "usage", status.Usage != nil)
+	return schema.GetTabStatusResponse{Status: status}, nil
+}
+
+// statusDirectory returns the tab's repo path as seen by the runner, falling
+// back to the host path.
+func (s *service) statusDirectory(ctx context.Context, owner schema.UserID, tabID schema.TabID, repoName schema.RepoName) string {
+	repoPath, err := RepoPath(s.repoRoot, owner, repoName)
+	if err != nil {
+		if strings.TrimSpace(string(repoName)) != "" {
+			return string(repoName)
+		}
+		return "unknown"
+	}
+	if s.runners != nil {
+		if resp, err := s.runners.RunnerFor(ctx, RunnerRequest{UserID: owner, TabID: tabID}); err == nil && strings.TrimSpace(resp.Info.RepoRoot) != "" {
+			if mapped, err := MapRepoPath(s.repoRoot, resp.Info, repoPath); err == nil {
+				return mapped
+			}
+		}
+	}
+	return repoPath
+}
+
+// accountUsage reads the user's account usage through the runner, using the
+// usage cache unless refresh is set. It returns nil when no runner can report
+// usage.
+func (s *service) accountUsage(ctx context.Context, userID schema.UserID, tabID schema.TabID, refresh bool) *schema.AccountUsageStatus {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	var entry usageCacheEntry
+	ok := false
+	if !refresh {
+		entry, ok = s.usage.get(userID)
+	}
+	if ok {
+		log.Debug("usage cache hit", "err", entry.err != nil, "chatgpt", entry.info.ChatGPT)
+	} else if entry, ok = s.refreshUsage(ctx, userID, tabID); !ok {
+		return nil
+	}
+	status := &schema.AccountUsageStatus{
+		ChatGPT:   entry.info.ChatGPT,
+		Primary:   usageWindowStatus(entry.info.Primary),
+		Secondary: usageWindowStatus(entry.info.Secondary),
+		FetchedAt: entry.fetchedAt,
+	}
+	if entry.err != nil {
+		status.Error = entry.err.Error()
+	}
+	return status
+}
+
+// refreshUsage reads account usage through the runner and replaces the
+// cached entry. It reports false when no runner can report usage.
+func (s *service) refreshUsage(ctx context.Context, userID schema.UserID, tabID schema.TabID) (usageCacheEntry, bool) {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if s.runners == nil {
+		log.Debug("usage lookup skipped", "reason", "runner unavailable")
+		return usageCacheEntry{}, false
+	}
+	runnerResp, err := s.runners.RunnerFor(ctx, RunnerRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		log.Warn("usage runner lookup failed", "err", err)
+		return usageCacheEntry{}, false
+	}
+	reader, ok := runnerResp.Runner.(UsageReader)
+	if !ok {
+		log.Debug("usage reader missing")
+		return usageCacheEntry{}, false
+	}
+	info, err := reader.Usage(ctx)
+	entry := s.usage.store(userID, info, err)
+	if err == nil {
+		s.checkQuota(ctx, userID, info)
+	}
+	log.Info("usage lookup completed", "err", err != nil, "chatgpt", info.ChatGPT)
+	return entry, true
+}
+
+// probeModels lists the models of the user's codex account on the first
+// runner contact and warns in the system buffer about configured models the
+// account cannot use. It runs in the background and gives up quietly when
+// the runner cannot list models; prompts never wait on it.
+func (s *service) probeModels(ctx context.Context, userID schema.UserID, tabID schema.TabID, runner Runner) {
+	lister, ok := runner.(ModelLister)
+	if !ok || !s.usage.startModelProbe(userID) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), usageRefreshTimeout)
+	defer cancel()
+	log := logx.WithUserTab(ctx, userID, tabID)
+	list, err := lister.Models(ctx)
+	if err != nil {
+		log.Debug("model probe failed", "err", err)
+		return
+	}
+	if !list.Listed || len(list.Models) == 0 {
+		log.Debug("model probe skipped", "reason", "models not listed")
+		return
+	}
+	s.usage.storeModels(userID, list)
+	missing := UnsupportedModels(s.cfg.DefaultModel, s.cfg.AllowedModels, list.Models)
+	if len(missing) == 0 {
+		log.Debug("model probe completed", "models", len(list.Models))
+		return
+	}
+	log.Warn("model probe found unsupported models", "unsupported", missing, "available", list.Models)
+	s.appendSystemLines(log, userID, []schema.BufferLine{schema.Line(schema.LineKindSystem, "⚠ "+s.formatUnsupportedModels(missing))})
+}
+
+// formatUnsupportedModels describes models the account cannot use, marking
+// the configured default.
+func (s *service) formatUnsupportedModels(models []schema.ModelID) string {
+	names := make([]string, 0, len(models))
+	for _, model := range models {
+		name := string(model)
+		if model == s.cfg.DefaultModel {
+			name += " (default)"
+		}
+		names = append(names, name)
+	}
+	return fmt.Sprintf("your codex account cannot use configured model(s) %s — prompts with them will fail; switch with /model", strings.Join(names, ", "))
+}
+
+// usageWindow is an account usage window with the label used in warnings.
+type usageWindow struct {
+	label string
+	usage *UsageWindow
+}
+
+func usageWindows(info UsageInfo) []usageWindow {
+	return []usageWindow{{"5h limit", info.Primary}, {"week limit", info.Secondary}}
+}
+
+// warnUsageLimits refreshes the account usage after a turn and appends a
+// warning to the tab buffer when a window drops below one of the
+// usage.warn_below_percent thresholds. Each threshold is warned about once
+// per window.
+func (s *service) warnUsageLimits(ctx context.Context, userID schema.UserID, tabID schema.TabID) {
+	if len(s.cfg.UsageWarnBelowPercent) == 0 && s.cfg.UsageBlockBelowPercent <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, usageRefreshTimeout)
+	defer cancel()
+	entry, ok := s.refreshUsage(ctx, userID, tabID)
+	if !ok || entry.err != nil {
+		return
+	}
+	log := logx.WithUserTab(ctx, userID, tabID)
+	now := s.usage.now()
+	for _, window := range usageWindows(entry.info) {
+		if window.usage == nil {
+			continue
+		}
+		remaining := 100 - window.usage.UsedPercent
+		crossed := 0
+		for _, threshold := range s.cfg.UsageWarnBelowPercent {
+			if remaining < float64(threshold) && (crossed == 0 || threshold < crossed) {
+				crossed = threshold
+			}
+		}
+		resetAt := usageResetTime(window.usage)
+		if !s.usage.markLimitWarned(userID, window.label, crossed, resetAt) {
+			continue
+		}
+		log.Info("usage limit warning", "window", window.label, "used_percent", window.usage.UsedPercent, "threshold", crossed)
+		s.appendLine(log, userID, tabID, schema.LineKindSystem, "⚠ "+formatUsageLimit(window, resetAt, now))
+	}
+}
+
+// checkUsageLimit returns schema.ErrUsageLimited when the cached usage of
+// userID has a window below usage.block_below_percent that has not reset
+// yet. Without cached usage prompts are allowed.
+func (s *service) checkUsageLimit(userID schema.UserID) error {
+	limit := s.cfg.UsageBlockBelowPercent
+	if limit <= 0 {
+		return nil
+	}
+	entry, ok := s.usage.get(userID)
+	if !ok || entry.err != nil {
+		return nil
+	}
+	now := s.usage.now()
+	for _, window := range usageWindows(entry.info) {
+		if window.usage == nil || 100-window.usage.UsedPercent >= float64(limit) {
+			continue
+		}
+		resetAt := usageResetTime(window.usage)
+		if !resetAt.IsZero() && !now.Before(resetAt) {
+			continue
+		}
+		return fmt.Errorf("%w: %s", schema.ErrUsageLimited, formatUsageLimit(window, resetAt, now))
+	}
+	return nil
+}
+
+func usageResetTime(window *UsageWindow) time.Time {
+	if window.ResetAt <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(window.ResetAt, 0)
+}
+
+// formatUsageLimit describes a window as "5h limit at 82% — resets in 1h10m".
+func formatUsageLimit(window usageWindow, resetAt, now time.Time) string {
+	text := fmt.Sprintf("%s at %d%%", window.label, int(math.Round(window.usage.UsedPercent)))
+	if !resetAt.IsZero() {
+		text += " — resets in " + timefmt.Duration(resetAt.Sub(now))
+	}
+	return text
+}
+
+// checkQuota records a quota warning when a usage window crosses
+// quotaWarnPercent. The warning is repeated only after usage drops below the
+// threshold again.
+func (s *service) checkQuota(ctx context.Context, userID schema.UserID, info UsageInfo) {
+	var details []string
+	for _, window := range usageWindows(info) {
+		if window.usage != nil && window.usage.UsedPercent >= quotaWarnPercent {
+			details = append(details, fmt.Sprintf("%s %d%% used", window.label, int(math.Round(window.usage.UsedPercent))))
+		}
+	}
+	if !s.usage.markWarned(userID, len(details) > 0) {
+		return
+	}
+	if _, err := s.RecordActivity(ctx, schema.RecordActivityRequest{UserID: userID, Kind: schema.ActivityQuotaWarning, Detail: strings.Join(details, ", ")}); err != nil {
+		logx.WithUser(ctx, userID).Warn("service quota warning failed", "err", err)
+	}
+}
+
+func usageWindowStatus(window *UsageWindow) *schema.UsageWindowStatus {
+	if window == nil {
+		return nil
+	}
+	status := &schema.UsageWindowStatus{
+		UsedPercent:   window.UsedPercent,
+		WindowSeconds: window.LimitWindowSeconds,
+	}
+	if window.ResetAt > 0 {
+		status.ResetAt = time.Unix(window.ResetAt, 0).UTC()
+	}
+	return status
+}
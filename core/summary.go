@@ -0,0 +1,343 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+const (
+	// summaryRetryDelay is how long a summary waits for a busy tab.
+	summaryRetryDelay = 15 * time.Minute
+	// summaryRetryMax bounds the retries for a busy tab; the day is skipped
+	// after that.
+	summaryRetryMax = 8
+	// summaryEntryMax bounds a single prompt or message in the summary input
+	// so one long answer cannot crowd out the rest of the day.
+	summaryEntryMax = 1000
+	// summaryDateLayout is the layout of schema.TabSummary.Date.
+	summaryDateLayout = "2006-01-02"
+)
+
+const summaryPrompt = `Summarize what was done in this coding session during the last day for a project status report.
+Write 3 to 8 short bullet points covering goals, changes made, problems found and what is left open.
+Answer only from the log below; do not run commands or read files.
+
+Log:
+`
+
+// SetTabSummaries turns the daily summary of a tab on or off.
+func (s *service) SetTabSummaries(ctx context.Context, req schema.SetTabSummariesRequest) (schema.SetTabSummariesResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetTabSummariesResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	if req.Enabled && !s.cfg.SummariesEnabled {
+		log.Warn("service summaries rejected", "err", schema.ErrSummariesDisabled)
+		return schema.SetTabSummariesResponse{}, schema.ErrSummariesDisabled
+	}
+	s.mu.Lock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service summaries update failed", "err", err)
+		return schema.SetTabSummariesResponse{}, err
+	}
+	if req.Enabled && ref.tab.ephemeral {
+		s.mu.Unlock()
+		err := fmt.Errorf("%w: summaries are not kept for ephemeral tabs", schema.ErrInvalidRequest)
+		log.Warn("service summaries rejected", "err", err)
+		return schema.SetTabSummariesResponse{}, err
+	}
+	ref.tab.summariesOn = req.Enabled
+	s.mu.Unlock()
+	s.persistUser(log, ref.owner)
+	log.Info("service summaries updated", "enabled", req.Enabled)
+	return schema.SetTabSummariesResponse{Enabled: req.Enabled, Time: s.cfg.SummaryTime}, nil
+}
+
+// ListTabSummaries returns the summaries written for a tab, oldest first.
+func (s *service) ListTabSummaries(ctx context.Context, req schema.ListTabSummariesRequest) (schema.ListTabSummariesResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.ListTabSummariesResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessRead)
+	if err != nil {
+		log.Warn("service summaries list failed", "err", err)
+		return schema.ListTabSummariesResponse{}, err
+	}
+	return schema.ListTabSummariesResponse{
+		Available: s.cfg.SummariesEnabled,
+		Enabled:   ref.tab.summariesOn,
+		Time:      s.cfg.SummaryTime,
+		Summaries: slices.Clone(ref.tab.summaries),
+	}, nil
+}
+
+// scheduleSummaries arms the timer for the first daily summary run after
+// now. Each run schedules the next one.
+func (s *service) scheduleSummaries(now time.Time) {
+	if !s.cfg.SummariesEnabled {
+		return
+	}
+	next := nextSummaryRun(now.In(s.clock.Location()), s.cfg.SummaryTime)
+	s.logger.Debug("service summaries scheduled", "at", next)
+	time.AfterFunc(next.Sub(now), func() {
+		s.runSummaries(context.Background(), next)
+		now := s.now()
+		if now.Before(next) {
+			now = next
+		}
+		s.scheduleSummaries(now)
+	})
+}
+
+// nextSummaryRun returns the first time of day hhmm strictly after now, in
+// now's location.
+func nextSummaryRun(now time.Time, hhmm string) time.Time {
+	clock, err := time.Parse(schema.SummaryTimeLayout, hhmm)
+	if err != nil {
+		clock, _ = time.Parse(schema.SummaryTimeLayout, schema.DefaultSummaryTime)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runSummaries writes the summaries due at the given time for every tab
+// that has them turned on. Only users with state loaded are visited; tab
+// activity always loads the user's state, so nobody with activity is
+// missed.
+func (s *service) runSummaries(ctx context.Context, at time.Time) {
+	type dueTab struct {
+		userID schema.UserID
+		tabID  schema.TabID
+	}
+	var due []dueTab
+	s.mu.Lock()
+	for userID, state := range s.userTabs {
+		for _, tabID := range state.order {
+			if tab := state.tabs[tabID]; tab != nil && tab.summariesOn {
+				due = append(due, dueTab{userID: userID, tabID: tabID})
+			}
+		}
+	}
+	s.mu.Unlock()
+	s.logger.Info("service summaries run", "tabs", len(due))
+	for _, entry := range due {
+		s.summarizeTab(ctx, entry.userID, entry.tabID, at, 0)
+	}
+}
+
+// summarizeTab writes the summary of a tab for the 24 hours before at. Tabs
+// with a run in progress are retried later; tabs without activity or with a
+// summary for the day already are skipped.
+func (s *service) summarizeTab(ctx context.Context, userID schema.UserID, tabID schema.TabID, at time.Time, attempt int) {
+	log := s.logger.With("user", userID, "tab", tabID)
+	date := at.In(s.clock.Location()).Format(summaryDateLayout)
+	s.mu.Lock()
+	var tab *tab
+	if state := s.userTabs[userID]; state != nil {
+		tab = state.tabs[tabID]
+	}
+	if tab == nil || !tab.summariesOn || hasSummary(tab.summaries, date) {
+		s.mu.Unlock()
+		return
+	}
+	if tab.Status.Busy() {
+		s.mu.Unlock()
+		if attempt >= summaryRetryMax {
+			log.Warn("service summary skipped", "date", date, "reason", "tab busy")
+			return
+		}
+		log.Debug("service summary deferred", "date", date, "reason", "tab busy", "attempt", attempt+1)
+		time.AfterFunc(summaryRetryDelay, func() { s.summarizeTab(ctx, userID, tabID, at, attempt+1) })
+		return
+	}
+	var lines []schema.BufferLine
+	if tab.buffer != nil {
+		lines = slices.Clone(tab.buffer.lines)
+	}
+	repoName := tab.Repo.Name
+	s.mu.Unlock()
+
+	input, ok := summaryInput(lines, at.Add(-24*time.Hour), at, s.clock.Location(), s.cfg.SummaryInputMax)
+	if !ok {
+		log.Debug("service summary skipped", "date", date, "reason", "no activity")
+		return
+	}
+	log = logx.WithRepo(log, s.repoRef(userID, repoName)).With("model", s.cfg.SummaryModel)
+	text, usage, err := s.writeSummary(ctx, userID, tabID, repoName, summaryPrompt+input)
+	if err != nil {
+		log.Warn("service summary failed", "date", date, "err", err)
+		return
+	}
+	summary := schema.TabSummary{Date: date, Text: text, CreatedAt: s.now(), Usage: usage}
+	s.mu.Lock()
+	if state := s.userTabs[userID]; state != nil {
+		tab = state.tabs[tabID]
+	}
+	if tab == nil {
+		s.mu.Unlock()
+		log.Debug("service summary dropped", "date", date, "reason", "tab closed")
+		return
+	}
+	tab.summaries = append(tab.summaries, summary)
+	if excess := len(tab.summaries) - s.cfg.SummariesMax; excess > 0 {
+		tab.summaries = slices.Delete(tab.summaries, 0, excess)
+	}
+	tabName := tab.Name
+	s.mu.Unlock()
+	s.persistUser(log, userID)
+
+	tokens := 0
+	if usage != nil {
+		tokens = usage.InputTokens + usage.OutputTokens
+	}
+	detail := fmt.Sprintf("%s, %s, %d tokens", tabName, date, tokens)
+	if _, err := s.RecordActivity(ctx, schema.RecordActivityRequest{UserID: userID, Kind: schema.ActivitySummaryWritten, Detail: detail}); err != nil {
+		log.Warn("service summary activity failed", "err", err)
+	}
+	log.Info("service summary written", "date", date, "input_len", len(input), "tokens", tokens)
+}
+
+// writeSummary runs prompt in a fresh codex session in the tab's repo and
+// returns the final agent message with the usage codex reported.
+func (s *service) writeSummary(ctx context.Context, userID schema.UserID, tabID schema.TabID, repoName schema.RepoName, prompt string) (string, *schema.TurnUsage, error) {
+	if s.runners == nil {
+		return "", nil, schema.ErrRunnerUnavailable
+	}
+	runnerResp, err := s.runners.RunnerFor(ctx, RunnerRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		return "", nil, err
+	}
+	info := runnerResp.Info
+	workingDir, err := s.repoPath(userID, repoName)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.RepoRoot != "" {
+		if workingDir, err = MapRepoPath(s.repoRoot, info, workingDir); err != nil {
+			return "", nil, err
+		}
+	}
+	extraArgs := s.codexFlags(s.cfg.SummaryModel)
+	if !s.cfg.DisableAuditLogging {
+		s.logger.With("user", userID, "tab", tabID).Debug("audit command", "command_type", "codex", "command", "codex exec --json", "extra_args", extraArgs, "workdir", workingDir)
+	}
+	handle, err := runnerResp.Runner.Run(ctx, RunRequest{
+		WorkingDir:           workingDir,
+		Prompt:               prompt,
+		Model:                s.cfg.SummaryModel,
+		ModelReasoningEffort: schema.ModelReasoningLow,
+		JSON:                 true,
+		SSHAuthSock:          info.SSHAuthSock,
+		ExtraArgs:            extraArgs,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { _ = handle.Close() }()
+	var text string
+	var usage *schema.TurnUsage
+	stream := handle.Events()
+	for {
+		event, err := stream.Next(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return "", nil, err
+			}
+			break
+		}
+		switch {
+		case event.Item != nil && event.Item.Type == schema.ItemAgentMessage && event.Item.Text != "":
+			text = event.Item.Text
+		case event.Type == schema.EventTurnCompleted && event.Usage != nil:
+			usageCopy := *event.Usage
+			usage = &usageCopy
+		case event.Type == schema.EventTurnFailed:
+			if event.Error != nil && event.Error.Message != "" {
+				return "", usage, fmt.Errorf("codex turn failed: %s", event.Error.Message)
+			}
+			return "", usage, errors.New("codex turn failed")
+		case event.Type == schema.EventError:
+			return "", usage, fmt.Errorf("codex error: %s", event.Message)
+		}
+	}
+	_, _ = handle.Wait(ctx)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", usage, errors.New("no summary produced")
+	}
+	return text, usage, nil
+}
+
+// summaryInput renders the prompts, agent messages and errors appended in
+// [from, to) as the log sent to the model, at most limit bytes. Consecutive
+// lines of one kind form one entry. It reports false when there was no
+// prompt or agent message in the window.
+func summaryInput(lines []schema.BufferLine, from, to time.Time, loc *time.Location, limit int) (string, bool) {
+	type entry struct {
+		at    time.Time
+		label string
+		text  strings.Builder
+	}
+	var entries []*entry
+	active := false
+	for _, line := range lines {
+		if line.Timestamp.Before(from) || !line.Timestamp.Before(to) {
+			continue
+		}
+		var label string
+		switch line.Kind {
+		case schema.LineKindPrompt:
+			label = "User"
+		case schema.LineKindAgent, schema.LineKindAnswer:
+			label = "Codex"
+		case schema.LineKindError:
+			label = "Error"
+		default:
+			continue
+		}
+		active = active || line.Kind != schema.LineKindError
+		last := len(entries) - 1
+		if last < 0 || entries[last].label != label {
+			entries = append(entries, &entry{at: line.Timestamp, label: label})
+			last++
+		} else {
+			entries[last].text.WriteByte('\n')
+		}
+		entries[last].text.WriteString(line.Text)
+	}
+	var b strings.Builder
+	for i, e := range entries {
+		text := strings.TrimSpace(e.text.String())
+		if len(text) > summaryEntryMax {
+			text = strings.ToValidUTF8(text[:summaryEntryMax], "") + " ..."
+		}
+		rendered := e.at.In(loc).Format("15:04") + " " + e.label + ": " + text + "\n"
+		if b.Len()+len(rendered) > limit {
+			fmt.Fprintf(&b, "(%d later entries omitted)\n", len(entries)-i)
+			break
+		}
+		b.WriteString(rendered)
+	}
+	return b.String(), active
+}
+
+func hasSummary(summaries []schema.TabSummary, date string) bool {
+	return slices.ContainsFunc(summaries, func(summary schema.TabSummary) bool { return summary.Date == date })
+}
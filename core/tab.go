@@ -2,6 +2,9 @@ package core
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"time"
 
 	"pkt.systems/centaurx/schema"
 )
@@ -15,17 +18,53 @@ type tab struct {
 	ModelReasoningEffort schema.ModelReasoningEffort
 	SessionID            schema.SessionID
 	Status               schema.TabStatus
+	RunID                schema.RunID
+	LastPrompt           string
 	LastUsage            *schema.TurnUsage
 	buffer               *buffer
 	history              *historyBuffer
+	filters              []outputFilter
+	shares               []schema.TabShare
 	Run                  RunHandle
 	RunCancel            context.CancelFunc
 	commands             []commandRun
+	// summariesOn records /summary on; summaries holds the daily summaries
+	// written for the tab, oldest first.
+	summariesOn bool
+	summaries   []schema.TabSummary
+	// ephemeral tabs keep their buffer, history and prompts in memory only.
+	ephemeral bool
+	// branch is the git branch seen when the last prompt started.
+	branch string
+	// autoCommit records /autocommit on: successful runs that change the
+	// repo are committed.
+	autoCommit bool
+	// archived tabs are left out of ListTabs and take no prompts until
+	// restored.
+	archived bool
+	// tools holds the codex tools turned on or off with /tools.
+	tools map[schema.ToolName]bool
+	// lastRunAt is when the tab's latest codex run started.
+	lastRunAt time.Time
+	// repoURL is the URL the tab's repo was cloned from, if any.
+	repoURL string
+	// labels are the tab's /tag labels, sorted.
+	labels []schema.TabLabel
+	// progress is what the running prompt is doing, from its latest
+	// events. It is not persisted.
+	progress string
+	// lastError is the runner failure of the latest prompt. It is not
+	// persisted.
+	lastError *schema.TabError
+	// renderErrors counts the events of the latest run the renderer failed
+	// on. It is not persisted.
+	renderErrors int
 }
 
 type commandRun struct {
 	handle CommandHandle
 	cancel context.CancelFunc
+	pty    bool
 }
 
 // Snapshot returns a transport-friendly view of the tab.
@@ -39,5 +78,29 @@ func (t *tab) Snapshot(active bool, repo schema.RepoRef) schema.TabSnapshot {
 		SessionID:            t.SessionID,
 		Status:               t.Status,
 		Active:               active,
+		RunID:                t.RunID,
+		Ephemeral:            t.ephemeral,
+		Branch:               t.branch,
+		Tools:                maps.Clone(t.tools),
+		RepoURL:              t.repoURL,
+		Labels:               slices.Clone(t.labels),
+		AutoCommit:           t.autoCommit,
+		Archived:             t.archived,
+		LastActivityAt:       t.lastActivity(),
+		Progress:             t.progress,
+		LastError:            cloneTabError(t.lastError),
+		RenderErrors:         t.renderErrors,
 	}
 }
+
+// lastActivity returns when the tab last appended output or started a
+// run, whichever is later.
+func (t *tab) lastActivity() time.Time {
+	last := t.lastRunAt
+	if t.buffer != nil {
+		if at := t.buffer.lastAppended(); at.After(last) {
+			last = at
+		}
+	}
+	return last
+}
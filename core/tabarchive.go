@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+// SetTabArchived archives a tab or restores an archived one. An archived
+// tab keeps its buffer, history and session but is left out of ListTabs,
+// takes no prompts and has its runner released. Clients see a closed event
+// when the tab is archived and a created event when it is restored, as for
+// CloseTab and ReopenTab. Only the owner archives a tab; guests keep seeing
+// it.
+func (s *service) SetTabArchived(ctx context.Context, req schema.SetTabArchivedRequest) (schema.SetTabArchivedResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetTabArchivedResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	tab := state.tabs[req.TabID]
+	if tab == nil {
+		s.mu.Unlock()
+		log.Warn("service tab archive failed", "err", schema.ErrTabNotFound)
+		return schema.SetTabArchivedResponse{}, schema.ErrTabNotFound
+	}
+	if tab.archived == req.Archived {
+		active := activeTabFromContext(ctx, state)
+		snapshot := s.snapshotTab(userID, tab, tab.ID == active)
+		s.mu.Unlock()
+		return schema.SetTabArchivedResponse{Tab: snapshot}, nil
+	}
+	if req.Archived && (tab.Status.Busy() || len(tab.commands) > 0) {
+		s.mu.Unlock()
+		log.Warn("service tab archive failed", "err", schema.ErrTabBusy)
+		return schema.SetTabArchivedResponse{}, schema.ErrTabBusy
+	}
+	tab.archived = req.Archived
+	eventType := schema.TabEventCreated
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil {
+		switch {
+		case req.Archived && prefs.ActiveTab == tab.ID:
+			prefs.ActiveTab = ""
+		case !req.Archived:
+			prefs.ActiveTab = tab.ID
+		}
+	}
+	if req.Archived {
+		eventType = schema.TabEventClosed
+	}
+	active := activeTabFromContext(ctx, state)
+	snapshot := s.snapshotTab(userID, tab, tab.ID == active)
+	event := schema.TabEvent{
+		UserID:    userID,
+		Type:      eventType,
+		Tab:       snapshot,
+		ActiveTab: active,
+	}
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, userID)
+	if req.Archived && s.runners != nil {
+		_ = s.runners.CloseTab(ctx, RunnerCloseRequest{UserID: userID, TabID: tab.ID})
+	}
+	log.Info("service tab archive updated", "archived", req.Archived, "tab_name", tab.Name)
+	return schema.SetTabArchivedResponse{Tab: snapshot}, nil
+}
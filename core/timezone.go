@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/internal/timefmt"
+	"pkt.systems/centaurx/schema"
+)
+
+// SetTimezone sets the zone times are shown to the user in. The choice is
+// kept in the session prefs and persisted with the user's state so new
+// sessions pick it up.
+func (s *service) SetTimezone(ctx context.Context, req schema.SetTimezoneRequest) (schema.SetTimezoneResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetTimezoneResponse{}, err
+	}
+	log := logx.WithUser(ctx, userID)
+	zone := strings.TrimSpace(req.Timezone)
+	if zone != "" {
+		loc, err := timefmt.LoadLocation(zone)
+		if err != nil {
+			log.Warn("service timezone rejected", "err", err)
+			return schema.SetTimezoneResponse{}, fmt.Errorf("%w: %q", schema.ErrInvalidTimezone, zone)
+		}
+		zone = loc.String()
+	}
+	s.mu.Lock()
+	s.getOrCreateUserStateLocked(userID).timezone = zone
+	s.mu.Unlock()
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil {
+		prefs.Timezone = zone
+	}
+	s.persistUser(log, userID)
+	log.Info("service timezone updated", "timezone", zone)
+	if zone == "" {
+		return schema.SetTimezoneResponse{Timezone: s.clock.Location().String(), Default: true}, nil
+	}
+	return schema.SetTimezoneResponse{Timezone: zone}, nil
+}
+
+// clockForLocked returns the clock times are shown to the user with: the
+// session's /tz choice, else the user's saved zone, else the server default.
+func (s *service) clockForLocked(ctx context.Context, state *userState) timefmt.Clock {
+	zone := state.timezone
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.Timezone != "" {
+		zone = prefs.Timezone
+	}
+	clock, err := s.clock.WithZone(zone)
+	if err != nil {
+		return s.clock
+	}
+	return clock
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// SetTabTool turns a codex tool on or off for a tab. The setting is
+// persisted with the tab and applies from the next prompt.
+func (s *service) SetTabTool(ctx context.Context, req schema.SetTabToolRequest) (schema.SetTabToolResponse, error) {
+	userID, err := normalizeUserID(req.UserID)
+	if err != nil {
+		return schema.SetTabToolResponse{}, err
+	}
+	log := logx.WithUserTab(ctx, userID, req.TabID)
+	tool, err := schema.NormalizeToolName(string(req.Tool))
+	if err != nil {
+		log.Warn("service tool update rejected", "err", err)
+		return schema.SetTabToolResponse{}, err
+	}
+	s.mu.Lock()
+	state := s.getOrCreateUserStateLocked(userID)
+	ref, err := s.lookupTabLocked(userID, req.TabID, schema.ShareAccessReadWrite)
+	if err != nil {
+		s.mu.Unlock()
+		log.Warn("service tool update failed", "err", err)
+		return schema.SetTabToolResponse{}, err
+	}
+	if ref.tab.tools == nil {
+		ref.tab.tools = make(map[schema.ToolName]bool)
+	}
+	ref.tab.tools[tool] = req.Enabled
+	active := activeTabFromContext(ctx, state)
+	event := s.tabEventLocked(ref, schema.TabEventUpdated, active)
+	snapshot := s.snapshotRef(ref, req.TabID == active)
+	s.mu.Unlock()
+	s.emitTabEvent(event)
+	s.persistUser(log, ref.owner)
+	log.Info("service tool updated", "tool", tool, "enabled", req.Enabled)
+	return schema.SetTabToolResponse{Tab: snapshot}, nil
+}
+
+// toolSetting returns the tab's setting for name, or nil when it was never
+// set and the codex default applies.
+func toolSetting(tools map[schema.ToolName]bool, name schema.ToolName) *bool {
+	enabled, ok := tools[name]
+	if !ok {
+		return nil
+	}
+	return &enabled
+}
@@ -1,6 +1,11 @@
 package core
 
-import "context"
+import (
+	"context"
+	"slices"
+
+	"pkt.systems/centaurx/schema"
+)
 
 // UsageWindow captures account usage for a single window.
 type UsageWindow struct {
@@ -20,3 +25,29 @@ type UsageInfo struct {
 type UsageReader interface {
 	Usage(ctx context.Context) (UsageInfo, error)
 }
+
+// ModelList is the set of models the runner's codex account can use. Listed
+// is false when the runner could not list them, e.g. without a ChatGPT
+// login.
+type ModelList struct {
+	Listed bool
+	Models []schema.ModelID
+}
+
+// ModelLister lists the models available to the runner's codex account.
+type ModelLister interface {
+	Models(ctx context.Context) (ModelList, error)
+}
+
+// UnsupportedModels returns the configured models, default first, that are
+// missing from available.
+func UnsupportedModels(defaultModel schema.ModelID, allowed []schema.ModelID, available []schema.ModelID) []schema.ModelID {
+	var missing []schema.ModelID
+	for _, model := range append([]schema.ModelID{defaultModel}, allowed...) {
+		if model == "" || slices.Contains(available, model) || slices.Contains(missing, model) {
+			continue
+		}
+		missing = append(missing, model)
+	}
+	return missing
+}
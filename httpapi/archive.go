@@ -0,0 +1,117 @@
+package httpapi
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/internal/archivestore"
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// SetArchiveStore enables one-time archive downloads created by /archive.
+func (s *Server) SetArchiveStore(store archivestore.Store) {
+	if s == nil {
+		return
+	}
+	s.archives = store
+}
+
+// ArchiveURLPrefix returns the URL prefix that archive download tokens are
+// appended to.
+func ArchiveURLPrefix(cfg Config) string {
+	base := buildBaseHref(cfg.BaseURL, cfg.BasePath)
+	if base == "" {
+		base = "/"
+	}
+	return base + "api/archives/"
+}
+
+func (s *Server) handleRepoArchive(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	tabID := schema.TabID(r.PathValue("id"))
+	log := logx.WithUserTab(r.Context(), userID, tabID)
+	query := r.URL.Query()
+	// Spool to disk first so failures still map to a proper status code.
+	spool, err := os.CreateTemp("", "centaurx-archive-*")
+	if err != nil {
+		log.Warn("http repo archive spool failed", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+	resp, err := s.service.WriteRepoArchive(r.Context(), schema.WriteRepoArchiveRequest{
+		UserID:   userID,
+		TabID:    tabID,
+		Path:     query.Get("path"),
+		Format:   schema.ArchiveFormat(query.Get("format")),
+		Worktree: parseBool(query.Get("worktree")),
+		Writer:   spool,
+	})
+	if err != nil {
+		log.Warn("http repo archive failed", "err", err)
+		writeError(w, repoFileErrorStatus(err), err)
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeArchive(w, resp.Name, resp.Size, spool)
+	log.Info("http repo archive ok", "name", resp.Name, "bytes", resp.Size)
+}
+
+func (s *Server) handleArchiveDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.archives == nil {
+		writeError(w, http.StatusNotFound, archivestore.ErrNotFound)
+		return
+	}
+	// The token is the credential: it is random, single use and expiring,
+	// so SSH users can fetch archives without a UI session.
+	download, err := s.archives.Open(r.PathValue("token"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, archivestore.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	defer func() { _ = download.Close() }()
+	log := logx.WithUser(r.Context(), download.UserID)
+	writeArchive(w, download.Name, download.Size, download.Content)
+	log.Info("http archive download ok", "name", download.Name, "bytes", download.Size)
+}
+
+func writeArchive(w http.ResponseWriter, name string, size int64, content io.Reader) {
+	header := w.Header()
+	header.Set("Content-Type", archiveContentType(name))
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, content)
+}
+
+func archiveContentType(name string) string {
+	if strings.HasSuffix(name, "."+string(schema.ArchiveFormatTarGz)) {
+		return "application/gzip"
+	}
+	return "application/x-tar"
+}
@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pkt.systems/centaurx/internal/archivestore"
+	"pkt.systems/centaurx/schema"
+)
+
+type repoArchiveService struct {
+	repoFileService
+	archive func(schema.WriteRepoArchiveRequest) (schema.WriteRepoArchiveResponse, error)
+}
+
+func (s repoArchiveService) WriteRepoArchive(_ context.Context, req schema.WriteRepoArchiveRequest) (schema.WriteRepoArchiveResponse, error) {
+	return s.archive(req)
+}
+
+func TestRepoArchiveEndpointStreamsAttachment(t *testing.T) {
+	svc := repoArchiveService{archive: func(req schema.WriteRepoArchiveRequest) (schema.WriteRepoArchiveResponse, error) {
+		if req.UserID != "alice" || req.TabID != "tab1" || req.Path != "src" || !req.Worktree {
+			return schema.WriteRepoArchiveResponse{}, schema.ErrTabNotFound
+		}
+		if req.Format == "zip" {
+			return schema.WriteRepoArchiveResponse{}, schema.ErrInvalidArchiveFormat
+		}
+		_, _ = io.WriteString(req.Writer, "tarball")
+		return schema.WriteRepoArchiveResponse{Name: "demo-src.tar.gz", Format: schema.ArchiveFormatTarGz, Size: 7}, nil
+	}}
+
+	rec := serveRepoFiles(t, svc, "/api/v1/tabs/tab1/archive?path=src&worktree=1")
+	if rec.Code != http.StatusOK || rec.Body.String() != "tarball" {
+		t.Fatalf("unexpected response %d: %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename=demo-src.tar.gz` {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+	if rec.Header().Get("Content-Type") != "application/gzip" || rec.Header().Get("Content-Length") != "7" {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+
+	if rec := serveRepoFiles(t, svc, "/api/v1/tabs/other/archive?path=src&worktree=1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another user's or unknown tab to 404, got %d", rec.Code)
+	}
+	if rec := serveRepoFiles(t, svc, "/api/v1/tabs/tab1/archive?path=src&worktree=1&format=zip"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad format to 400, got %d", rec.Code)
+	}
+}
+
+func TestArchiveDownloadTokenIsSingleUse(t *testing.T) {
+	store, err := archivestore.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	saved, err := store.Save(context.Background(), archivestore.SaveRequest{
+		UserID: "alice",
+		Write: func(w io.Writer) (string, error) {
+			_, err := io.WriteString(w, "tarball")
+			return "demo.tar", err
+		},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	srv := NewServer(Config{SessionCookie: "cx_session"}, repoFileService{}, nil, nil, nil)
+	srv.SetArchiveStore(store)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/archives/"+saved.Token, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "tarball" || rec.Header().Get("Content-Type") != "application/x-tar" {
+		t.Fatalf("unexpected download %d: %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/archives/"+saved.Token, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected reused token to 404, got %d", rec.Code)
+	}
+}
+
+func TestArchiveURLPrefix(t *testing.T) {
+	cases := []struct {
+		cfg  Config
+		want string
+	}{
+		{cfg: Config{}, want: "/api/archives/"},
+		{cfg: Config{BasePath: "/cx"}, want: "/cx/api/archives/"},
+		{cfg: Config{BaseURL: "https://example.com", BasePath: "cx/"}, want: "https://example.com/cx/api/archives/"},
+	}
+	for _, tc := range cases {
+		if got := ArchiveURLPrefix(tc.cfg); got != tc.want {
+			t.Fatalf("ArchiveURLPrefix(%+v) = %q, want %q", tc.cfg, got, tc.want)
+		}
+	}
+}
@@ -3,6 +3,10 @@
   const terminalPanel = document.getElementById('terminal-panel');
   const loginForm = document.getElementById('login-form');
   const loginError = document.getElementById('login-error');
+  const loginTotpField = document.getElementById('login-totp-field');
+  const loginTotp = document.getElementById('login-totp');
+  const loginDevice = document.getElementById('login-device');
+  const loginDeviceInfo = document.getElementById('login-device-info');
   const sessionEl = document.getElementById('session');
   const tabsBarEl = document.getElementById('tabs-bar');
   const tabsLeftEl = document.getElementById('tabs-left');
@@ -42,6 +46,7 @@
     Number.isFinite(uiMaxLines) && uiMaxLines > 0 ? uiMaxLines : 2000;
   const COMMAND_MARKER = '\u001a';
   const AGENT_MARKER = '\u001c';
+  const FINAL_AGENT_MARKER = '\u0015';
   const REASONING_MARKER = '\u001d';
   const STDERR_MARKER = '\u001f';
   const WORKED_MARKER = '\u001e';
@@ -49,6 +54,7 @@
   const ABOUT_VERSION_MARKER = '\u0017';
   const ABOUT_COPYRIGHT_MARKER = '\u0018';
   const ABOUT_LINK_MARKER = '\u0019';
+  const FIELD_MARKER = '\u0014';
   const tabWindow = window.CentaurxTabWindow || {};
   const STREAM_SESSION_CHECK_COOLDOWN_MS = 15000;
   const SESSION_VALIDATE_COOLDOWN_MS = 15000;
@@ -60,10 +66,11 @@
   let sessionValidationAfter = 0;
 
   class ApiError extends Error {
-    constructor(message, status) {
+    constructor(message, status, code = '') {
       super(message);
       this.name = 'ApiError';
       this.status = status;
+      this.code = code;
     }
   }
 
@@ -74,6 +81,7 @@
     scroll: new Map(),
     eventSource: null,
     user: null,
+    externalCredentials: false,
     systemLines: [],
     theme: null,
     history: new Map(),
@@ -87,9 +95,10 @@
       ...options,
     });
     if (!res.ok) {
-      const err = await res.json().catch(() => ({ error: 'request failed' }));
-      const message = err.error || 'request failed';
-      const apiErr = new ApiError(message, res.status);
+      const body = await res.json().catch(() => ({}));
+      const err = body.error || {};
+      const message = err.message || 'request failed';
+      const apiErr = new ApiError(message, res.status, err.code || '');
       if (apiErr.status === 401 && handle401 && path !== 'api/login') {
         queueSessionValidation('session expired');
       }
@@ -113,6 +122,7 @@
       const data = await fetchJson('api/me', {}, false);
       clearSessionState();
       state.user = data.username;
+      state.externalCredentials = Boolean(data.external_credentials);
       showTerminal();
       startStream();
     } catch (err) {
@@ -120,6 +130,98 @@
     }
   }
 
+  async function loadLoginOptions() {
+    try {
+      const options = await fetchJson('api/login/options', {}, false);
+      state.externalCredentials = !options.totp;
+      if (loginTotpField) loginTotpField.hidden = !options.totp;
+      if (loginTotp) loginTotp.required = Boolean(options.totp);
+      if (loginDevice) loginDevice.hidden = !options.device_login;
+    } catch (err) {
+      // Keep the password and TOTP form.
+    }
+  }
+
+  let deviceLoginTimer = null;
+
+  function stopDeviceLogin() {
+    if (deviceLoginTimer) {
+      clearTimeout(deviceLoginTimer);
+      deviceLoginTimer = null;
+    }
+    if (loginDeviceInfo) {
+      loginDeviceInfo.hidden = true;
+      loginDeviceInfo.textContent = '';
+    }
+    if (loginDevice) loginDevice.disabled = false;
+  }
+
+  function showDeviceLoginInfo(device) {
+    if (!loginDeviceInfo) return;
+    loginDeviceInfo.textContent = '';
+    const link = document.createElement('a');
+    link.href = device.verification_uri_complete || device.verification_uri;
+    link.target = '_blank';
+    link.rel = 'noopener';
+    link.textContent = device.verification_uri;
+    const code = document.createElement('code');
+    code.textContent = device.user_code;
+    loginDeviceInfo.append('Open ', link, ' and enter ', code, ' to sign in.');
+    loginDeviceInfo.hidden = false;
+  }
+
+  async function startDeviceLogin() {
+    stopDeviceLogin();
+    loginError.textContent = '';
+    if (loginDevice) loginDevice.disabled = true;
+    let device;
+    try {
+      device = await fetchJson('api/login/device', { method: 'POST' }, false);
+    } catch (err) {
+      stopDeviceLogin();
+      loginError.textContent = err.message;
+      return;
+    }
+    showDeviceLoginInfo(device);
+    const interval = Math.max(device.interval || 5, 1) * 1000;
+    const poll = async () => {
+      deviceLoginTimer = null;
+      let res;
+      try {
+        res = await fetch('api/login/device/poll', {
+          method: 'POST',
+          credentials: 'same-origin',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ login_id: device.login_id }),
+        });
+      } catch (err) {
+        deviceLoginTimer = setTimeout(poll, interval);
+        return;
+      }
+      if (res.status === 202) {
+        deviceLoginTimer = setTimeout(poll, interval);
+        return;
+      }
+      const body = await res.json().catch(() => ({}));
+      stopDeviceLogin();
+      if (!res.ok) {
+        loginError.textContent = (body.error && body.error.message) || 'login failed';
+        return;
+      }
+      completeLogin(body);
+    };
+    deviceLoginTimer = setTimeout(poll, interval);
+  }
+
+  function completeLogin(data) {
+    clearSessionState();
+    state.user = data.username;
+    showTerminal();
+    startStream();
+    setStatus('');
+    focusPrompt();
+  }
+
   function handleInvalidSession(message) {
     if (sessionInvalidated) return;
     const hadSession = Boolean(state.user);
@@ -144,6 +246,7 @@
   }
 
   function showLogin() {
+    loadLoginOptions();
     loginPanel.classList.remove('hidden');
     terminalPanel.classList.add('hidden');
     sessionEl.textContent = '';
@@ -153,6 +256,7 @@
   }
 
   function showTerminal() {
+    stopDeviceLogin();
     loginPanel.classList.add('hidden');
     terminalPanel.classList.remove('hidden');
     sessionEl.textContent = state.user ? `signed in as ${state.user}` : '';
@@ -169,23 +273,32 @@
     visible: false,
   };
 
+  function tabStatus(tab) {
+    if (!tab) return '';
+    return String(tab.status || tab.Status || '').toLowerCase();
+  }
+
+  // A stopping tab still has a run until it exits.
   function isTabRunning(tab) {
-    if (!tab) return false;
-    const status = String(tab.status || tab.Status || '').toLowerCase();
-    return status === 'running';
+    const status = tabStatus(tab);
+    return status === 'running' || status === 'stopping';
+  }
+
+  function activeTab() {
+    if (!state.activeTab) return null;
+    return state.tabs.find((entry) => entry.id === state.activeTab) || null;
   }
 
   function isActiveTabRunning() {
-    if (!state.activeTab) return false;
-    const tab = state.tabs.find((entry) => entry.id === state.activeTab);
-    return isTabRunning(tab);
+    return isTabRunning(activeTab());
   }
 
   function updateStatusUI() {
     if (!statusEl) return;
     const busy = busyState.visible || isActiveTabRunning();
-    const message = statusState.message || '';
-    const level = statusState.message ? statusState.level : 'info';
+    const stopping = tabStatus(activeTab()) === 'stopping';
+    const message = statusState.message || (stopping ? 'stopping…' : '');
+    const level = statusState.message ? statusState.level : (stopping ? 'warn' : 'info');
     if (statusTextEl) {
       statusTextEl.textContent = message || '';
     } else {
@@ -505,24 +618,25 @@
     const payload = {
       username: document.getElementById('login-username').value.trim(),
       password: document.getElementById('login-password').value,
-      totp: document.getElementById('login-totp').value.trim(),
+      totp: loginTotp.value.trim(),
     };
     try {
       const data = await api('api/login', {
         method: 'POST',
         body: JSON.stringify(payload),
       });
-      clearSessionState();
-      state.user = data.username;
-      showTerminal();
-      startStream();
-      setStatus('');
-      focusPrompt();
+      completeLogin(data);
     } catch (err) {
       loginError.textContent = err.message;
     }
   });
 
+  if (loginDevice) {
+    loginDevice.addEventListener('click', () => {
+      startDeviceLogin();
+    });
+  }
+
   if (chpasswdCancel) {
     chpasswdCancel.addEventListener('click', () => {
       closeChpasswdDialog();
@@ -780,6 +894,10 @@
     promptInput.value = '';
     resizePrompt();
     if (isChpasswdInput(payloadInput)) {
+      if (state.externalCredentials) {
+        reportError('password is managed externally');
+        return;
+      }
       openChpasswdDialog();
       return;
     }
@@ -1070,8 +1188,9 @@
     tabsEl.innerHTML = '';
     state.tabs.forEach((tab) => {
       const btn = document.createElement('button');
-      btn.className = 'tab' + (tab.id === state.activeTab ? ' active' : '');
-      btn.textContent = tab.name || tab.id;
+      btn.className = 'tab' + (tab.id === state.activeTab ? ' active' : '') + (tab.owner ? ' shared' : '') + (tab.ephemeral ? ' ephemeral' : '');
+      btn.textContent = (tab.owner ? tab.owner + ':' : '') + (tab.ephemeral ? '~' : '') + (tab.name || tab.id);
+      if (tab.ephemeral) btn.title = 'ephemeral: buffer, history and prompts are not saved';
       btn.onclick = async () => {
         try {
           await api('api/tabs/activate', {
@@ -1158,7 +1277,7 @@
       if (state.systemLines.length) {
         lines = state.systemLines;
       } else {
-        lines = ['no active tab; use /new <repo>'];
+        lines = ['no active tab — open one with /new <repo>'];
       }
     }
     terminalEl.innerHTML = '';
@@ -1170,7 +1289,15 @@
       if (parsed.klass) {
         el.classList.add(parsed.klass);
       }
-      if (parsed.link) {
+      if (parsed.field) {
+        const label = document.createElement('span');
+        label.className = 'field-label';
+        label.textContent = parsed.field.label + ' ';
+        const value = document.createElement('span');
+        value.className = 'field-value';
+        value.textContent = parsed.field.value === '' ? '\u00a0' : parsed.field.value;
+        el.append(label, value);
+      } else if (parsed.link) {
         const link = document.createElement('a');
         link.href = parsed.text;
         link.textContent = parsed.text === '' ? '\u00a0' : parsed.text;
@@ -1221,12 +1348,23 @@
     if (text.startsWith(AGENT_MARKER)) {
       return { text: text.slice(AGENT_MARKER.length), klass: 'agent', markdown: true };
     }
+    if (text.startsWith(FINAL_AGENT_MARKER)) {
+      return { text: text.slice(FINAL_AGENT_MARKER.length), klass: 'agent final', markdown: true };
+    }
     if (text.startsWith(REASONING_MARKER)) {
       return { text: text.slice(REASONING_MARKER.length), klass: 'reasoning', markdown: true };
     }
     if (text.startsWith(COMMAND_MARKER)) {
       return { text: text.slice(COMMAND_MARKER.length), klass: 'command' };
     }
+    if (text.startsWith(FIELD_MARKER)) {
+      // Label and value are split so the value wraps under its own column.
+      const body = text.slice(FIELD_MARKER.length);
+      const tab = body.indexOf('\t');
+      const label = tab === -1 ? body : body.slice(0, tab);
+      const value = tab === -1 ? '' : body.slice(tab + 1);
+      return { text: `${label} ${value}`, klass: 'field', field: { label, value } };
+    }
     let stderr = false;
     if (text.startsWith(STDERR_MARKER)) {
       stderr = true;
@@ -1339,6 +1477,8 @@
     if (!normalized.name && normalized.Name) normalized.name = normalized.Name;
     if (!normalized.repo && normalized.Repo) normalized.repo = normalized.Repo;
     if (!normalized.status && normalized.Status) normalized.status = normalized.Status;
+    if (!normalized.owner && normalized.Owner) normalized.owner = normalized.Owner;
+    if (!normalized.ephemeral && normalized.Ephemeral) normalized.ephemeral = normalized.Ephemeral;
     if (normalized.repo) {
       if (!normalized.repo.name && normalized.repo.Name) normalized.repo.name = normalized.repo.Name;
       if (!normalized.repo.path && normalized.repo.Path) normalized.repo.path = normalized.repo.Path;
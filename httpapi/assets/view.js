@@ -0,0 +1,63 @@
+// Read-only viewer for /sharelink links. The token is the last path
+// segment; the page only listens and never sends input.
+(() => {
+  const token = window.location.pathname.split('/').filter(Boolean).pop() || '';
+  const title = document.getElementById('view-title');
+  const status = document.getElementById('view-status');
+  const output = document.getElementById('view-output');
+  const maxLines = window.centaurxConfig.uiMaxBufferLines;
+
+  const atBottom = () => window.innerHeight + window.scrollY >= document.body.scrollHeight - 4;
+
+  const append = (lines) => {
+    const follow = atBottom();
+    for (const line of lines || []) {
+      const row = document.createElement('div');
+      row.textContent = line;
+      output.appendChild(row);
+    }
+    while (output.childNodes.length > maxLines) {
+      output.removeChild(output.firstChild);
+    }
+    if (follow) {
+      window.scrollTo(0, document.body.scrollHeight);
+    }
+  };
+
+  const ended = (text) => {
+    status.textContent = text;
+    source.close();
+  };
+
+  const source = new EventSource(`api/view/${encodeURIComponent(token)}/stream`);
+  source.onmessage = (message) => {
+    const event = JSON.parse(message.data);
+    switch (event.type) {
+      case 'snapshot':
+        title.textContent = event.tab || 'centaurx';
+        document.title = `${title.textContent} (read-only)`;
+        status.textContent = event.expires_at
+          ? `read-only · link expires ${new Date(event.expires_at).toLocaleString()}`
+          : 'read-only';
+        output.replaceChildren();
+        append(event.lines);
+        window.scrollTo(0, document.body.scrollHeight);
+        break;
+      case 'output':
+        append(event.lines);
+        break;
+      case 'closed':
+        ended('this link has expired or was revoked');
+        break;
+      default:
+        break;
+    }
+  };
+  source.onerror = () => {
+    if (source.readyState === EventSource.CLOSED) {
+      ended('this link has expired or was revoked');
+    } else {
+      status.textContent = 'reconnecting…';
+    }
+  };
+})();
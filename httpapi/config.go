@@ -10,4 +10,15 @@ type Config struct {
 	BasePath           string
 	InitialBufferLines int
 	UIMaxBufferLines   int
+	// MOTDFile is the message of the day shared with the SSH server. It is
+	// appended to the system buffer on login and served by /api/motd.
+	MOTDFile string
+	// MaxPollsPerUser caps concurrent /api/v1/poll requests per user.
+	// Defaults to 4.
+	MaxPollsPerUser int
+	// TrustedProxies are the addresses (IPs or CIDRs) of reverse proxies
+	// whose X-Forwarded-For header names the client. Requests from anywhere
+	// else are attributed to their peer address, so a client cannot pick
+	// its own login throttling key.
+	TrustedProxies []string
 }
@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// DeviceLogin runs device-code logins against an identity provider.
+type DeviceLogin interface {
+	Start(ctx context.Context) (auth.DeviceAuthorization, error)
+	Poll(ctx context.Context, deviceCode string) (auth.Identity, error)
+}
+
+// maxPendingDeviceLogins bounds the device logins waiting for approval.
+const maxPendingDeviceLogins = 256
+
+var errUnknownDeviceLogin = errors.New("unknown or expired login")
+
+// deviceLogins keeps started device logins. The device code stays on the
+// server; browsers poll with a login id instead.
+type deviceLogins struct {
+	flow DeviceLogin
+
+	mu      sync.Mutex
+	pending map[string]*pendingDeviceLogin
+}
+
+type pendingDeviceLogin struct {
+	device   auth.DeviceAuthorization
+	lastPoll time.Time
+}
+
+// SetDeviceLogin enables device-code logins in the web UI.
+func (s *Server) SetDeviceLogin(flow DeviceLogin) {
+	if s == nil || flow == nil {
+		return
+	}
+	s.devices = &deviceLogins{flow: flow, pending: make(map[string]*pendingDeviceLogin)}
+}
+
+func (d *deviceLogins) add(device auth.DeviceAuthorization) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for id, entry := range d.pending {
+		if now.After(entry.device.ExpiresAt) {
+			delete(d.pending, id)
+		}
+	}
+	if len(d.pending) >= maxPendingDeviceLogins {
+		return "", false
+	}
+	id := randomToken(24)
+	d.pending[id] = &pendingDeviceLogin{device: device}
+	return id, true
+}
+
+// due returns the device code of loginID when it may be polled again, so
+// browsers cannot make us poll the provider faster than it asked for.
+func (d *deviceLogins) due(loginID string) (string, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.pending[loginID]
+	if !ok {
+		return "", false, errUnknownDeviceLogin
+	}
+	now := time.Now()
+	if now.After(entry.device.ExpiresAt) {
+		delete(d.pending, loginID)
+		return "", false, errUnknownDeviceLogin
+	}
+	if !entry.lastPoll.IsZero() && now.Sub(entry.lastPoll) < entry.device.Interval {
+		return "", false, nil
+	}
+	entry.lastPoll = now
+	return entry.device.DeviceCode, true, nil
+}
+
+func (d *deviceLogins) remove(loginID string) {
+	d.mu.Lock()
+	delete(d.pending, loginID)
+	d.mu.Unlock()
+}
+
+func (s *Server) handleDeviceLoginStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.devices == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log := logx.Ctx(r.Context()).With("remote", s.clientIP(r))
+	device, err := s.devices.flow.Start(r.Context())
+	if err != nil {
+		log.Warn("http device login start failed", "err", err)
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	loginID, ok := s.devices.add(device)
+	if !ok {
+		log.Warn("http device login start rejected", "reason", "too many pending logins")
+		writeError(w, http.StatusTooManyRequests, errors.New("too many pending logins"))
+		return
+	}
+	log.Info("http device login started")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"login_id":                  loginID,
+		"user_code":                 device.UserCode,
+		"verification_uri":          device.VerificationURI,
+		"verification_uri_complete": device.VerificationURIComplete,
+		"interval":                  int(device.Interval / time.Second),
+		"expires_in":                int(time.Until(device.ExpiresAt) / time.Second),
+	})
+}
+
+// handleDeviceLoginPoll answers 202 while the login waits for approval and
+// starts a session once the provider vouches for the user.
+func (s *Server) handleDeviceLoginPoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.devices == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log := logx.Ctx(r.Context()).With("remote", s.clientIP(r))
+	var payload struct {
+		LoginID string `json:"login_id"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	deviceCode, due, err := s.devices.due(payload.LoginID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if !due {
+		writeJSON(w, http.StatusAccepted, map[string]any{"pending": true})
+		return
+	}
+	identity, err := s.devices.flow.Poll(r.Context(), deviceCode)
+	if errors.Is(err, auth.ErrAuthorizationPending) {
+		writeJSON(w, http.StatusAccepted, map[string]any{"pending": true})
+		return
+	}
+	s.devices.remove(payload.LoginID)
+	if err != nil {
+		log.Warn("http device login failed", "err", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	s.startSession(w, r, schema.UserID(identity.Username), identity.Capabilities)
+	log.Info("http device login ok", "user", identity.Username)
+}
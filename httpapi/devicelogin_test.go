@@ -0,0 +1,142 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/schema"
+)
+
+type fakeDeviceLogin struct {
+	approved atomic.Bool
+	polls    atomic.Int32
+}
+
+func (f *fakeDeviceLogin) Start(context.Context) (auth.DeviceAuthorization, error) {
+	return auth.DeviceAuthorization{
+		DeviceCode:      "device-123",
+		UserCode:        "ABCD-EFGH",
+		VerificationURI: "https://idp.example.com/activate",
+		ExpiresAt:       time.Now().Add(time.Minute),
+	}, nil
+}
+
+func (f *fakeDeviceLogin) Poll(_ context.Context, deviceCode string) (auth.Identity, error) {
+	f.polls.Add(1)
+	if deviceCode != "device-123" {
+		return auth.Identity{}, errors.New("invalid_grant")
+	}
+	if !f.approved.Load() {
+		return auth.Identity{}, auth.ErrAuthorizationPending
+	}
+	return auth.Identity{Username: "alice", Capabilities: schema.Capabilities{ReadOnly: true}}, nil
+}
+
+// externalAuth is an Authenticator whose credentials live elsewhere.
+type externalAuth struct{}
+
+func (externalAuth) AuthenticateFrom(string, string, string, string) error { return nil }
+
+func (externalAuth) ChangePasswordFrom(string, string, string, string, string) error {
+	return errors.New("unexpected password change")
+}
+
+func (externalAuth) ExternalCredentials() bool { return true }
+
+func (externalAuth) Capabilities(string) schema.Capabilities { return schema.Capabilities{} }
+
+func postJSON(t *testing.T, srv *Server, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDeviceLoginStartsSessionOnceApproved(t *testing.T) {
+	srv := NewServer(Config{SessionCookie: "cx_session"}, nil, nil, externalAuth{}, nil)
+	flow := &fakeDeviceLogin{}
+	srv.SetDeviceLogin(flow)
+
+	rec := postJSON(t, srv, "/api/login/device", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("start: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var started struct {
+		LoginID  string `json:"login_id"`
+		UserCode string `json:"user_code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if started.LoginID == "" || started.UserCode != "ABCD-EFGH" || strings.Contains(rec.Body.String(), "device-123") {
+		t.Fatalf("unexpected start response %s", rec.Body.String())
+	}
+
+	poll := `{"login_id":"` + started.LoginID + `"}`
+	if rec := postJSON(t, srv, "/api/login/device/poll", poll); rec.Code != http.StatusAccepted {
+		t.Fatalf("pending poll: expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	flow.approved.Store(true)
+	rec = postJSON(t, srv, "/api/login/device/poll", poll)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("approved poll: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "cx_session" {
+		t.Fatalf("expected a session cookie, got %v", cookies)
+	}
+	sess, ok := srv.sessions.get(cookies[0].Value)
+	if !ok || sess.userID != "alice" || !sess.caps.ReadOnly || !sess.prefs.Capabilities.ReadOnly {
+		t.Fatalf("unexpected session %+v", sess)
+	}
+	if rec := postJSON(t, srv, "/api/login/device/poll", poll); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused login: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestDeviceLoginPollHonoursInterval(t *testing.T) {
+	srv := NewServer(Config{SessionCookie: "cx_session"}, nil, nil, externalAuth{}, nil)
+	flow := &fakeDeviceLogin{}
+	srv.SetDeviceLogin(flow)
+	loginID, _ := srv.devices.add(auth.DeviceAuthorization{
+		DeviceCode: "device-123",
+		Interval:   time.Hour,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	})
+	for range 3 {
+		if rec := postJSON(t, srv, "/api/login/device/poll", `{"login_id":"`+loginID+`"}`); rec.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", rec.Code)
+		}
+	}
+	if got := flow.polls.Load(); got != 1 {
+		t.Fatalf("expected one provider poll, got %d", got)
+	}
+}
+
+func TestLoginOptionsAndExternalPasswordChange(t *testing.T) {
+	srv := NewServer(Config{SessionCookie: "cx_session"}, nil, nil, externalAuth{}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/login/options", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"totp":false`) || !strings.Contains(rec.Body.String(), `"device_login":false`) {
+		t.Fatalf("unexpected login options %d: %s", rec.Code, rec.Body.String())
+	}
+
+	token, _ := srv.sessions.create("alice", schema.Capabilities{})
+	req = httptest.NewRequest(http.MethodPost, "/api/chpasswd", strings.NewReader(`{}`))
+	req.AddCookie(&http.Cookie{Name: "cx_session", Value: token})
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), schema.CodeManagedExternally) {
+		t.Fatalf("expected managed externally, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
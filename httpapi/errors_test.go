@@ -0,0 +1,49 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestWriteErrorEmitsCodeAndMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		err     error
+		code    string
+		message string
+	}{
+		{"schema", http.StatusNotFound, schema.ErrTabNotFound, "tab_not_found", "tab not found"},
+		{"wrapped", http.StatusConflict, fmt.Errorf("%w: tab1", schema.ErrTabBusy), "tab_busy", "tab is busy: tab1"},
+		{"bad request", http.StatusBadRequest, errors.New("passwords do not match"), "invalid_request", "passwords do not match"},
+		{"unauthorized", http.StatusUnauthorized, errors.New("invalid credentials"), "unauthorized", "invalid credentials"},
+		{"internal", http.StatusInternalServerError, errors.New("disk full"), "internal", "disk full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, tt.status, tt.err)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			var body struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+			}
+			if body.Error.Code != tt.code || body.Error.Message != tt.message {
+				t.Fatalf("unexpected error body: %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+func (s *Server) handleRepoFiles(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	tabID := schema.TabID(r.PathValue("id"))
+	log := logx.WithUserTab(r.Context(), userID, tabID)
+	resp, err := s.service.ListRepoFiles(r.Context(), schema.ListRepoFilesRequest{
+		UserID: userID,
+		TabID:  tabID,
+		Path:   r.URL.Query().Get("path"),
+	})
+	if err != nil {
+		log.Warn("http repo files failed", "err", err)
+		writeError(w, repoFileErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+	log.Debug("http repo files ok", "path", resp.Path, "entries", len(resp.Entries))
+}
+
+func (s *Server) handleRepoFile(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	tabID := schema.TabID(r.PathValue("id"))
+	log := logx.WithUserTab(r.Context(), userID, tabID)
+	resp, err := s.service.ReadRepoFile(r.Context(), schema.ReadRepoFileRequest{
+		UserID: userID,
+		TabID:  tabID,
+		Path:   r.URL.Query().Get("path"),
+	})
+	if err != nil {
+		log.Warn("http repo file failed", "err", err)
+		writeError(w, repoFileErrorStatus(err), err)
+		return
+	}
+	// Repo contents are untrusted: never let the browser render them as
+	// active content on the UI origin.
+	header := w.Header()
+	header.Set("Content-Type", repoFileContentType(resp.Content))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	http.ServeContent(w, r, path.Base(resp.Path), resp.ModTime, bytes.NewReader(resp.Content))
+	log.Debug("http repo file ok", "path", resp.Path, "bytes", resp.Size)
+}
+
+// repoFileContentType sniffs content and serves every text type, markup
+// included, as plain text.
+func repoFileContentType(content []byte) string {
+	contentType := http.DetectContentType(content)
+	if strings.HasPrefix(contentType, "text/") {
+		return "text/plain; charset=utf-8"
+	}
+	return contentType
+}
+
+func repoFileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, schema.ErrTabNotFound), errors.Is(err, schema.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, schema.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, schema.ErrRunnerUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, schema.ErrInvalidPath),
+		errors.Is(err, schema.ErrNotDirectory),
+		errors.Is(err, schema.ErrIsDirectory),
+		errors.Is(err, schema.ErrInvalidArchiveFormat),
+		errors.Is(err, schema.ErrInvalidUser):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
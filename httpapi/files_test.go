@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/schema"
+)
+
+type repoFileService struct {
+	core.Service
+	list func(schema.ListRepoFilesRequest) (schema.ListRepoFilesResponse, error)
+	read func(schema.ReadRepoFileRequest) (schema.ReadRepoFileResponse, error)
+}
+
+func (s repoFileService) ListRepoFiles(_ context.Context, req schema.ListRepoFilesRequest) (schema.ListRepoFilesResponse, error) {
+	return s.list(req)
+}
+
+func (s repoFileService) ReadRepoFile(_ context.Context, req schema.ReadRepoFileRequest) (schema.ReadRepoFileResponse, error) {
+	return s.read(req)
+}
+
+func serveRepoFiles(t *testing.T, svc core.Service, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	srv := NewServer(Config{SessionCookie: "cx_session"}, svc, nil, nil, nil)
+	token, _ := srv.sessions.create("alice", schema.Capabilities{})
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.AddCookie(&http.Cookie{Name: "cx_session", Value: token})
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRepoFilesEndpointListsDirectory(t *testing.T) {
+	svc := repoFileService{list: func(req schema.ListRepoFilesRequest) (schema.ListRepoFilesResponse, error) {
+		if req.UserID != "alice" || req.TabID != "tab1" || req.Path != "src" {
+			return schema.ListRepoFilesResponse{}, fmt.Errorf("unexpected request %+v", req)
+		}
+		return schema.ListRepoFilesResponse{Path: "src", Entries: []schema.RepoFileEntry{{Name: "main.go", Type: "file", Size: 12, Mode: "-rw-r--r--"}}}, nil
+	}}
+	rec := serveRepoFiles(t, svc, "/api/v1/tabs/tab1/files?path=src")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Entries []map[string]any
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0]["name"] != "main.go" || resp.Entries[0]["mode"] != "-rw-r--r--" {
+		t.Fatalf("unexpected listing: %s", rec.Body.String())
+	}
+}
+
+func TestRepoFileEndpointServesContentSafely(t *testing.T) {
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := repoFileService{read: func(req schema.ReadRepoFileRequest) (schema.ReadRepoFileResponse, error) {
+		switch req.Path {
+		case "index.html":
+			content := []byte("<html><script>alert(1)</script></html>")
+			return schema.ReadRepoFileResponse{Path: req.Path, Size: int64(len(content)), ModTime: modTime, Content: content}, nil
+		case "logo.png":
+			content := []byte("\x89PNG\r\n\x1a\n0000")
+			return schema.ReadRepoFileResponse{Path: req.Path, Size: int64(len(content)), ModTime: modTime, Content: content}, nil
+		case "../etc/passwd":
+			return schema.ReadRepoFileResponse{}, schema.ErrInvalidPath
+		case "big.bin":
+			return schema.ReadRepoFileResponse{}, schema.ErrFileTooLarge
+		default:
+			return schema.ReadRepoFileResponse{}, schema.ErrFileNotFound
+		}
+	}}
+
+	rec := serveRepoFiles(t, svc, "/api/v1/tabs/tab1/file?path=index.html")
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html><script>alert(1)</script></html>" {
+		t.Fatalf("unexpected response %d: %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Fatalf("expected markup served as plain text, got %q", got)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" || rec.Header().Get("Content-Security-Policy") == "" {
+		t.Fatalf("expected hardening headers, got %v", rec.Header())
+	}
+	if got := rec.Header().Get("Last-Modified"); got != modTime.Format(http.TimeFormat) {
+		t.Fatalf("unexpected Last-Modified %q", got)
+	}
+
+	if rec := serveRepoFiles(t, svc, "/api/v1/tabs/tab1/file?path=logo.png"); rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("expected sniffed image type, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	for path, want := range map[string]int{
+		"../etc/passwd": http.StatusBadRequest,
+		"big.bin":       http.StatusRequestEntityTooLarge,
+		"missing":       http.StatusNotFound,
+	} {
+		if rec := serveRepoFiles(t, svc, "/api/v1/tabs/tab1/file?path="+path); rec.Code != want {
+			t.Fatalf("path %q: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestRepoFileEndpointsRequireSession(t *testing.T) {
+	srv := NewServer(Config{SessionCookie: "cx_session"}, repoFileService{}, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tabs/tab1/files", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// Dependency names a component /readyz waits for.
+type Dependency string
+
+const (
+	// DependencyService is the core service.
+	DependencyService Dependency = "service"
+	// DependencyStore is the state directory snapshots are saved in.
+	DependencyStore Dependency = "store"
+	// DependencyRuntime is the container runtime client.
+	DependencyRuntime Dependency = "runtime"
+	// DependencySSH is the SSH listener.
+	DependencySSH Dependency = "ssh"
+)
+
+// Readiness tracks the dependencies /readyz reports on. A dependency is
+// ready once SetReady marked it, or, when it has a check, while the check
+// passes; checks run on every /readyz request. Drain makes /readyz fail for
+// good so load balancers stop sending new sessions during shutdown. It is
+// safe for concurrent use.
+type Readiness struct {
+	mu       sync.Mutex
+	required []Dependency
+	ready    map[Dependency]bool
+	checks   map[Dependency]func(context.Context) error
+	draining bool
+}
+
+// ReadinessReport is the /readyz response body.
+type ReadinessReport struct {
+	Ready    bool                  `json:"ready"`
+	Draining bool                  `json:"draining,omitempty"`
+	NotReady []Dependency          `json:"not_ready,omitempty"`
+	Errors   map[Dependency]string `json:"errors,omitempty"`
+}
+
+// NewReadiness returns a Readiness waiting for required.
+func NewReadiness(required ...Dependency) *Readiness {
+	return &Readiness{
+		required: slices.Clone(required),
+		ready:    make(map[Dependency]bool),
+		checks:   make(map[Dependency]func(context.Context) error),
+	}
+}
+
+// SetReady marks dep as ready or not ready.
+func (r *Readiness) SetReady(dep Dependency, ready bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready[dep] = ready
+}
+
+// SetCheck makes the readiness of dep the result of check.
+func (r *Readiness) SetCheck(dep Dependency, check func(context.Context) error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[dep] = check
+}
+
+// Drain reports the server as not ready from now on.
+func (r *Readiness) Drain() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draining = true
+}
+
+// Report evaluates the dependencies.
+func (r *Readiness) Report(ctx context.Context) ReadinessReport {
+	r.mu.Lock()
+	required := slices.Clone(r.required)
+	ready := make(map[Dependency]bool, len(r.ready))
+	for dep, ok := range r.ready {
+		ready[dep] = ok
+	}
+	checks := make(map[Dependency]func(context.Context) error, len(r.checks))
+	for dep, check := range r.checks {
+		checks[dep] = check
+	}
+	draining := r.draining
+	r.mu.Unlock()
+
+	report := ReadinessReport{Draining: draining}
+	for _, dep := range required {
+		if check := checks[dep]; check != nil {
+			if err := check(ctx); err != nil {
+				report.NotReady = append(report.NotReady, dep)
+				if report.Errors == nil {
+					report.Errors = make(map[Dependency]string)
+				}
+				report.Errors[dep] = err.Error()
+			}
+			continue
+		}
+		if !ready[dep] {
+			report.NotReady = append(report.NotReady, dep)
+		}
+	}
+	report.Ready = !draining && len(report.NotReady) == 0
+	return report
+}
+
+// SetReadiness sets the dependencies /readyz reports on. Without it /readyz
+// reports ready whenever the HTTP server answers.
+func (s *Server) SetReadiness(readiness *Readiness) {
+	if s == nil {
+		return
+	}
+	s.readiness = readiness
+}
+
+// handleHealthz reports that the process is up.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the server takes new sessions.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.readiness == nil {
+		writeJSON(w, http.StatusOK, ReadinessReport{Ready: true})
+		return
+	}
+	report := s.readiness.Report(r.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
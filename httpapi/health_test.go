@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+var allDependencies = []Dependency{DependencyService, DependencyStore, DependencyRuntime, DependencySSH}
+
+func getProbe(t *testing.T, handler http.Handler, path string) (int, ReadinessReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report ReadinessReport
+	if path == "/readyz" {
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode %s: %v (%q)", path, err, rec.Body.String())
+		}
+	}
+	return rec.Code, report
+}
+
+func TestReadyzReportsEachNotReadyCombination(t *testing.T) {
+	for mask := 0; mask < 1<<len(allDependencies); mask++ {
+		readiness := NewReadiness(allDependencies...)
+		var want []Dependency
+		for i, dep := range allDependencies {
+			if mask&(1<<i) != 0 {
+				readiness.SetReady(dep, true)
+			} else {
+				want = append(want, dep)
+			}
+		}
+		srv := NewServer(Config{}, nil, nil, nil, nil)
+		srv.SetReadiness(readiness)
+		handler := srv.Handler()
+
+		code, report := getProbe(t, handler, "/readyz")
+		wantCode := http.StatusServiceUnavailable
+		if len(want) == 0 {
+			wantCode = http.StatusOK
+		}
+		if code != wantCode {
+			t.Fatalf("mask %04b: expected status %d, got %d", mask, wantCode, code)
+		}
+		if report.Ready != (len(want) == 0) {
+			t.Fatalf("mask %04b: expected ready=%v, got %v", mask, len(want) == 0, report.Ready)
+		}
+		if !slices.Equal(report.NotReady, want) {
+			t.Fatalf("mask %04b: expected not ready %v, got %v", mask, want, report.NotReady)
+		}
+		if code, _ := getProbe(t, handler, "/healthz"); code != http.StatusOK {
+			t.Fatalf("mask %04b: expected healthz 200, got %d", mask, code)
+		}
+	}
+}
+
+func TestReadyzRunsChecks(t *testing.T) {
+	readiness := NewReadiness(DependencyStore)
+	var probeErr error
+	readiness.SetCheck(DependencyStore, func(context.Context) error { return probeErr })
+	srv := NewServer(Config{}, nil, nil, nil, nil)
+	srv.SetReadiness(readiness)
+	handler := srv.Handler()
+
+	if code, _ := getProbe(t, handler, "/readyz"); code != http.StatusOK {
+		t.Fatalf("expected 200 with passing check, got %d", code)
+	}
+	probeErr = errors.New("read-only file system")
+	code, report := getProbe(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with failing check, got %d", code)
+	}
+	if !slices.Equal(report.NotReady, []Dependency{DependencyStore}) {
+		t.Fatalf("expected store not ready, got %v", report.NotReady)
+	}
+	if report.Errors[DependencyStore] != "read-only file system" {
+		t.Fatalf("expected check error in report, got %v", report.Errors)
+	}
+}
+
+func TestReadyzFailsWhileDraining(t *testing.T) {
+	readiness := NewReadiness(allDependencies...)
+	for _, dep := range allDependencies {
+		readiness.SetReady(dep, true)
+	}
+	srv := NewServer(Config{BasePath: "/cx"}, nil, nil, nil, nil)
+	srv.SetReadiness(readiness)
+	handler := srv.Handler()
+
+	if code, _ := getProbe(t, handler, "/readyz"); code != http.StatusOK {
+		t.Fatalf("expected 200 before drain, got %d", code)
+	}
+	readiness.Drain()
+	code, report := getProbe(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", code)
+	}
+	if !report.Draining || len(report.NotReady) != 0 {
+		t.Fatalf("expected draining report without missing dependencies, got %+v", report)
+	}
+	if code, _ := getProbe(t, handler, "/healthz"); code != http.StatusOK {
+		t.Fatalf("expected healthz 200 while draining, got %d", code)
+	}
+}
+
+func TestReadyzWithoutReadiness(t *testing.T) {
+	srv := NewServer(Config{}, nil, nil, nil, nil)
+	code, report := getProbe(t, srv.Handler(), "/readyz")
+	if code != http.StatusOK || !report.Ready {
+		t.Fatalf("expected ready without readiness tracking, got %d %+v", code, report)
+	}
+}
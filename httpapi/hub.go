@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"context"
+	"strconv"
 	"sync"
 	"time"
 
@@ -37,6 +38,7 @@ type Hub struct {
 	mu          sync.Mutex
 	users       map[schema.UserID]*userHub
 	historySize int
+	epoch       string
 }
 
 // NewHub constructs a hub with the given history size.
@@ -47,9 +49,16 @@ func NewHub(historySize int) *Hub {
 	return &Hub{
 		users:       make(map[schema.UserID]*userHub),
 		historySize: historySize,
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
 	}
 }
 
+// Epoch identifies this hub instance. Event sequence numbers restart with
+// every hub, so cursors handed to clients carry the epoch they belong to.
+func (h *Hub) Epoch() string {
+	return h.epoch
+}
+
 // OnOutput implements core.EventSink.
 func (h *Hub) OnOutput(event schema.OutputEvent) {
 	log := logx.WithUser(context.Background(), event.UserID).With("tab", event.TabID)
@@ -1,7 +1,9 @@
 package httpapi
 
 import (
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"time"
 
@@ -41,7 +43,7 @@ func (r *responseRecorder) Flush() {
 
 type sessionLookupFunc func(*http.Request) (userID schema.UserID, sessionID string)
 
-func withRequestLogging(next http.Handler, lookup sessionLookupFunc) http.Handler {
+func withRequestLogging(next http.Handler, lookup sessionLookupFunc, proxies []netip.Prefix) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		var userID schema.UserID
@@ -55,11 +57,11 @@ func withRequestLogging(next http.Handler, lookup sessionLookupFunc) http.Handle
 		if status == 0 {
 			status = http.StatusOK
 		}
-		path := r.URL.Path
+		path := redactViewToken(r.URL.Path)
 		if r.URL.RawQuery != "" {
 			path = path + "?" + r.URL.RawQuery
 		}
-		logger := pslog.Ctx(r.Context()).With("remote", clientIP(r))
+		logger := pslog.Ctx(r.Context()).With("remote", clientIP(r, proxies))
 		if userID != "" {
 			logger = logger.With("user", userID)
 		}
@@ -71,16 +73,95 @@ func withRequestLogging(next http.Handler, lookup sessionLookupFunc) http.Handle
 	})
 }
 
-func clientIP(r *http.Request) string {
+// redactViewToken hides share link tokens, which stay valid after the
+// request, from the request log.
+func redactViewToken(path string) string {
+	for _, prefix := range []string{"/view/", "/api/view/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok && rest != "" {
+			if _, tail, found := strings.Cut(rest, "/"); found {
+				return prefix + "REDACTED/" + tail
+			}
+			return prefix + "REDACTED"
+		}
+	}
+	return path
+}
+
+// clientIP returns the address a request came from, for logs and login
+// throttling. X-Forwarded-For is only believed when the peer is one of
+// proxies; the client is then the rightmost entry that is not a trusted
+// proxy itself, since anything left of it was sent by the client.
+func clientIP(r *http.Request, proxies []netip.Prefix) string {
 	if r == nil {
 		return ""
 	}
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		if len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
+	if !trustedProxy(r.RemoteAddr, proxies) {
+		return r.RemoteAddr
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !trustedProxy(hop, proxies) {
+			return hop
 		}
 	}
 	return r.RemoteAddr
 }
+
+func (s *Server) clientIP(r *http.Request) string {
+	return clientIP(r, s.proxies)
+}
+
+// trustedProxy reports whether addr, with or without a port, is in proxies.
+func trustedProxy(addr string, proxies []netip.Prefix) bool {
+	if len(proxies) == 0 {
+		return false
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses IPs and CIDRs; the config loader has already
+// rejected entries that do not parse, so they are skipped here.
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if prefix, err := ParseTrustedProxy(entry); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// ParseTrustedProxy parses a trusted proxy entry, an IP or a CIDR.
+func ParseTrustedProxy(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
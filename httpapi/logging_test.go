@@ -0,0 +1,97 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	cases := []struct {
+		name      string
+		remote    string
+		forwarded string
+		proxies   []netip.Prefix
+		want      string
+	}{
+		{name: "no proxies", remote: "198.51.100.7:4321", forwarded: "203.0.113.9", want: "198.51.100.7:4321"},
+		{name: "untrusted peer", remote: "198.51.100.7:4321", forwarded: "203.0.113.9", proxies: proxies, want: "198.51.100.7:4321"},
+		{name: "trusted peer", remote: "192.0.2.1:4321", forwarded: "203.0.113.9", proxies: proxies, want: "203.0.113.9"},
+		{name: "spoofed entries left of the client", remote: "10.1.2.3:4321", forwarded: "1.2.3.4, 203.0.113.9, 10.9.9.9", proxies: proxies, want: "203.0.113.9"},
+		{name: "trusted peer without header", remote: "10.1.2.3:4321", proxies: proxies, want: "10.1.2.3:4321"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if got := clientIP(req, tc.proxies); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// sourceAuth records the source of every credential check and rejects
+// them all.
+type sourceAuth struct {
+	mu      sync.Mutex
+	sources []string
+}
+
+func (a *sourceAuth) record(source string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sources = append(a.sources, source)
+	return errors.New("invalid credentials")
+}
+
+func (a *sourceAuth) AuthenticateFrom(source, _, _, _ string) error { return a.record(source) }
+
+func (a *sourceAuth) ChangePasswordFrom(source, _, _, _, _ string) error { return a.record(source) }
+
+func (a *sourceAuth) ExternalCredentials() bool { return false }
+
+func (a *sourceAuth) Capabilities(string) schema.Capabilities { return schema.Capabilities{} }
+
+func TestLoginThrottleSourceIgnoresForgedForwardedFor(t *testing.T) {
+	authStore := &sourceAuth{}
+	srv := NewServer(Config{SessionCookie: "cx_session"}, nil, nil, authStore, nil)
+	handler := srv.Handler()
+	for _, forged := range []string{"203.0.113.1", "203.0.113.2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"admin","password":"guess"}`))
+		req.RemoteAddr = "198.51.100.7:4321"
+		req.Header.Set("X-Forwarded-For", forged)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(authStore.sources) != 2 || authStore.sources[0] != "198.51.100.7:4321" || authStore.sources[1] != authStore.sources[0] {
+		t.Fatalf("expected every attempt keyed on the peer address, got %q", authStore.sources)
+	}
+}
+
+func TestChangePasswordThrottleSourceIgnoresForgedForwardedFor(t *testing.T) {
+	authStore := &sourceAuth{}
+	srv := NewServer(Config{SessionCookie: "cx_session"}, nil, nil, authStore, nil)
+	token, _ := srv.sessions.create("alice", schema.Capabilities{})
+	handler := srv.Handler()
+	for _, forged := range []string{"203.0.113.1", "203.0.113.2"} {
+		body := `{"current_password":"guess","totp":"000000","new_password":"next"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/me/password", strings.NewReader(body))
+		req.RemoteAddr = "198.51.100.7:4321"
+		req.Header.Set("X-Forwarded-For", forged)
+		req.AddCookie(&http.Cookie{Name: "cx_session", Value: token})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(authStore.sources) != 2 || authStore.sources[0] != "198.51.100.7:4321" || authStore.sources[1] != authStore.sources[0] {
+		t.Fatalf("expected every attempt keyed on the peer address, got %q", authStore.sources)
+	}
+}
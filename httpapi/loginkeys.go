@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// LoginPubKeyStore manages SSH login public keys per user. It is the store
+// behind /addloginpubkey, /listloginpubkeys and /rmloginpubkey.
+type LoginPubKeyStore interface {
+	AddLoginPubKey(userID schema.UserID, pubKey string) (int, error)
+	ListLoginPubKeys(userID schema.UserID) ([]string, error)
+	RemoveLoginPubKey(userID schema.UserID, index int) error
+}
+
+// LoginPubKey is a login public key with the 1-based id used to remove it.
+type LoginPubKey struct {
+	ID  int    `json:"id"`
+	Key string `json:"key"`
+}
+
+var errLoginPubKeysUnavailable = errors.New("login pubkey store not configured")
+
+// SetLoginPubKeyStore enables the /api/v1/me/keys endpoints.
+func (s *Server) SetLoginPubKeyStore(store LoginPubKeyStore) {
+	if s == nil {
+		return
+	}
+	s.loginKeys = store
+}
+
+// handleLoginPubKeys lists the user's login public keys (GET) or adds one
+// (POST {"pubkey": "ssh-ed25519 ..."}).
+func (s *Server) handleLoginPubKeys(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listLoginPubKeys(w, r, userID)
+	case http.MethodPost:
+		s.addLoginPubKey(w, r, userID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) listLoginPubKeys(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	log := logx.Ctx(r.Context()).With("user", userID, "remote", s.clientIP(r))
+	if s.loginKeys == nil {
+		writeError(w, http.StatusServiceUnavailable, errLoginPubKeysUnavailable)
+		return
+	}
+	keys, err := s.loginKeys.ListLoginPubKeys(userID)
+	if err != nil {
+		log.Warn("http listloginpubkeys failed", "err", err)
+		writeError(w, loginPubKeyErrorStatus(err), err)
+		return
+	}
+	out := make([]LoginPubKey, 0, len(keys))
+	for i, key := range keys {
+		out = append(out, LoginPubKey{ID: i + 1, Key: strings.TrimSpace(key)})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": out})
+	log.Info("http listloginpubkeys ok", "command", "/listloginpubkeys", "count", len(out))
+}
+
+func (s *Server) addLoginPubKey(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	log := logx.Ctx(r.Context()).With("user", userID, "remote", s.clientIP(r))
+	log.Info("http addloginpubkey request", "command", "/addloginpubkey")
+	if s.loginKeys == nil {
+		writeError(w, http.StatusServiceUnavailable, errLoginPubKeysUnavailable)
+		return
+	}
+	var payload struct {
+		PubKey string `json:"pubkey"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		log.Warn("http addloginpubkey decode failed", "err", err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := s.loginKeys.AddLoginPubKey(userID, payload.PubKey)
+	if err != nil {
+		log.Warn("http addloginpubkey failed", "err", err)
+		writeError(w, loginPubKeyErrorStatus(err), err)
+		return
+	}
+	s.appendAccountLine(r, userID, "login pubkey added (id "+strconv.Itoa(id)+")")
+	writeJSON(w, http.StatusCreated, map[string]any{"id": id})
+	log.Info("http addloginpubkey ok", "command", "/addloginpubkey", "id", id)
+}
+
+// handleLoginPubKey removes a login public key by its id (DELETE).
+func (s *Server) handleLoginPubKey(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	log := logx.Ctx(r.Context()).With("user", userID, "remote", s.clientIP(r))
+	log.Info("http rmloginpubkey request", "command", "/rmloginpubkey")
+	if s.loginKeys == nil {
+		writeError(w, http.StatusServiceUnavailable, errLoginPubKeysUnavailable)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid pubkey id"))
+		return
+	}
+	if err := s.loginKeys.RemoveLoginPubKey(userID, id); err != nil {
+		log.Warn("http rmloginpubkey failed", "err", err)
+		writeError(w, loginPubKeyErrorStatus(err), err)
+		return
+	}
+	s.appendAccountLine(r, userID, "login pubkey removed (id "+strconv.Itoa(id)+")")
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	log.Info("http rmloginpubkey ok", "command", "/rmloginpubkey", "id", id)
+}
+
+func loginPubKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, auth.ErrPubKeyRequired), errors.Is(err, auth.ErrInvalidPubKey):
+		return http.StatusBadRequest
+	case errors.Is(err, auth.ErrPubKeyExists):
+		return http.StatusConflict
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrPubKeyNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
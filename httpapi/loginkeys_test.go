@@ -0,0 +1,29 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"pkt.systems/centaurx/internal/auth"
+)
+
+func TestLoginPubKeyErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{auth.ErrPubKeyRequired, http.StatusBadRequest},
+		{auth.ErrInvalidPubKey, http.StatusBadRequest},
+		{auth.ErrPubKeyExists, http.StatusConflict},
+		{fmt.Errorf("%w: id 3 out of range", auth.ErrPubKeyNotFound), http.StatusNotFound},
+		{fmt.Errorf("load: %w", auth.ErrUserNotFound), http.StatusNotFound},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := loginPubKeyErrorStatus(tt.err); got != tt.status {
+			t.Fatalf("%v: expected status %d, got %d", tt.err, tt.status, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestMOTDEndpointRendersTemplate(t *testing.T) {
+	motdFile := filepath.Join(t.TempDir(), "motd")
+	if err := os.WriteFile(motdFile, []byte("hello {{.User}}\nsecond line\n"), 0o600); err != nil {
+		t.Fatalf("write motd: %v", err)
+	}
+	srv := NewServer(Config{SessionCookie: "cx_session", MOTDFile: motdFile}, nil, nil, nil, nil)
+	token, _ := srv.sessions.create("alice", schema.Capabilities{})
+	req := httptest.NewRequest(http.MethodGet, "/api/motd", nil)
+	req.AddCookie(&http.Cookie{Name: "cx_session", Value: token})
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Lines []string `json:"lines"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Lines) != 2 || resp.Lines[0] != "hello alice" {
+		t.Fatalf("unexpected motd: %q", resp.Lines)
+	}
+}
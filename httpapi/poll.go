@@ -0,0 +1,177 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+const (
+	defaultPollTimeout     = 25 * time.Second
+	maxPollTimeout         = 60 * time.Second
+	defaultMaxPollsPerUser = 4
+)
+
+// PollResponse is returned by /api/v1/poll. Resync is set when the cursor
+// cannot be continued (server restart, events trimmed from history, or no
+// cursor at all); the client should then refetch tabs and buffers in full
+// and poll on with Cursor.
+type PollResponse struct {
+	Cursor string        `json:"cursor"`
+	Events []StreamEvent `json:"events"`
+	Resync bool          `json:"resync,omitempty"`
+}
+
+// handlePoll long-polls for tab and system events, for clients that cannot
+// keep an SSE stream open.
+func (s *Server) handlePoll(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	log := logx.WithUser(r.Context(), userID)
+	timeout, err := parsePollTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, schema.WithCode(schema.CodeInvalidRequest, err))
+		return
+	}
+	if s.hub == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("event hub unavailable"))
+		return
+	}
+	limit := s.cfg.MaxPollsPerUser
+	if limit <= 0 {
+		limit = defaultMaxPollsPerUser
+	}
+	if !s.polls.acquire(userID, limit) {
+		log.Warn("http poll rejected", "limit", limit)
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("too many concurrent polls (max %d)", limit))
+		return
+	}
+	defer s.polls.release(userID)
+
+	resp := s.hub.Poll(r.Context(), userID, r.URL.Query().Get("since"), timeout)
+	writeJSON(w, http.StatusOK, resp)
+	log.Debug("http poll ok", "events", len(resp.Events), "resync", resp.Resync)
+}
+
+// Poll waits up to timeout for tab or system events after the since cursor.
+// Events already in history are returned at once. Output events advance the
+// cursor but are not returned; clients fetch buffers when a tab changes.
+func (h *Hub) Poll(ctx context.Context, userID schema.UserID, since string, timeout time.Duration) PollResponse {
+	ch, unsubscribe, seq, history := h.Subscribe(userID)
+	defer unsubscribe()
+
+	after, ok := h.parseCursor(since)
+	if !ok || after > seq || (after < seq && (len(history) == 0 || history[0].Seq > after+1)) {
+		return PollResponse{Cursor: h.cursor(seq), Events: []StreamEvent{}, Resync: true}
+	}
+	last := after
+	events := []StreamEvent{}
+	for _, event := range history {
+		if event.Seq <= after {
+			continue
+		}
+		last = event.Seq
+		if pollable(event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) > 0 {
+		return PollResponse{Cursor: h.cursor(last), Events: events}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return PollResponse{Cursor: h.cursor(last), Events: events}
+		case <-timer.C:
+			return PollResponse{Cursor: h.cursor(last), Events: events}
+		case event := <-ch:
+			// A full subscriber channel drops events; a skipped sequence
+			// number means this poll missed one.
+			if event.Seq != last+1 {
+				return PollResponse{Cursor: h.cursor(event.Seq), Events: []StreamEvent{}, Resync: true}
+			}
+			last = event.Seq
+			if pollable(event) {
+				events = append(events, event)
+			}
+			if len(events) > 0 && len(ch) == 0 {
+				return PollResponse{Cursor: h.cursor(last), Events: events}
+			}
+		}
+	}
+}
+
+func pollable(event StreamEvent) bool {
+	return event.Type == "tab" || event.Type == "system"
+}
+
+// cursor encodes seq as "<epoch>.<seq>".
+func (h *Hub) cursor(seq uint64) string {
+	return h.epoch + "." + strconv.FormatUint(seq, 10)
+}
+
+// parseCursor returns the sequence number of a cursor issued by this hub.
+func (h *Hub) parseCursor(cursor string) (uint64, bool) {
+	epoch, value, ok := strings.Cut(cursor, ".")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+func parsePollTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultPollTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return min(timeout, maxPollTimeout), nil
+}
+
+// pollLimiter counts concurrent polls per user.
+type pollLimiter struct {
+	mu     sync.Mutex
+	active map[schema.UserID]int
+}
+
+func (l *pollLimiter) acquire(userID schema.UserID, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active == nil {
+		l.active = make(map[schema.UserID]int)
+	}
+	if l.active[userID] >= limit {
+		return false
+	}
+	l.active[userID]++
+	return true
+}
+
+func (l *pollLimiter) release(userID schema.UserID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[userID] <= 1 {
+		delete(l.active, userID)
+		return
+	}
+	l.active[userID]--
+}
@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func servePoll(t *testing.T, srv *Server, token, since, timeout string) (*httptest.ResponseRecorder, PollResponse) {
+	t.Helper()
+	query := url.Values{}
+	query.Set("since", since)
+	query.Set("timeout", timeout)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/poll?"+query.Encode(), nil)
+	req.AddCookie(&http.Cookie{Name: "cx_session", Value: token})
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var resp PollResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return rec, resp
+}
+
+func newPollServer(t *testing.T, hub *Hub, maxPolls int) (*Server, string) {
+	t.Helper()
+	srv := NewServer(Config{SessionCookie: "cx_session", MaxPollsPerUser: maxPolls}, nil, nil, nil, hub)
+	token, _ := srv.sessions.create("alice", schema.Capabilities{})
+	return srv, token
+}
+
+func TestPollTimesOutWithoutEvents(t *testing.T) {
+	hub := NewHub(10)
+	srv, token := newPollServer(t, hub, 0)
+	_, first := servePoll(t, srv, token, "", "0s")
+	if !first.Resync {
+		t.Fatalf("expected resync without a cursor, got %+v", first)
+	}
+
+	hub.OnOutput(schema.OutputEvent{UserID: "alice", TabID: "tab1", Lines: []string{"noise"}})
+	start := time.Now()
+	rec, resp := servePoll(t, srv, token, first.Cursor, "50ms")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected poll to wait for the timeout, returned after %s", elapsed)
+	}
+	if resp.Resync || len(resp.Events) != 0 || resp.Cursor != hub.cursor(1) {
+		t.Fatalf("expected empty response past the output event, got %+v", resp)
+	}
+}
+
+func TestPollDeliversEvents(t *testing.T) {
+	hub := NewHub(10)
+	srv, token := newPollServer(t, hub, 0)
+	_, first := servePoll(t, srv, token, "", "0s")
+
+	hub.OnTabEvent(schema.TabEvent{UserID: "alice", Type: schema.TabEventStatus, Tab: schema.TabSnapshot{ID: "tab1"}})
+	_, resp := servePoll(t, srv, token, first.Cursor, "5s")
+	if len(resp.Events) != 1 || resp.Events[0].Tab.ID != "tab1" {
+		t.Fatalf("expected buffered tab event, got %+v", resp)
+	}
+
+	done := make(chan PollResponse, 1)
+	go func() {
+		_, next := servePoll(t, srv, token, resp.Cursor, "5s")
+		done <- next
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hub.mu.Lock()
+		subs := len(hub.users["alice"].subs)
+		hub.mu.Unlock()
+		if subs > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("poll never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	start := time.Now()
+	hub.OnSystemOutput(schema.SystemOutputEvent{UserID: "alice", Lines: []string{"hello"}})
+	select {
+	case next := <-done:
+		if len(next.Events) != 1 || next.Events[0].Type != "system" || next.Cursor != hub.cursor(2) {
+			t.Fatalf("expected system event, got %+v", next)
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("poll returned late")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("poll did not return on event")
+	}
+}
+
+func TestPollCursorGapRequestsResync(t *testing.T) {
+	hub := NewHub(2)
+	srv, token := newPollServer(t, hub, 0)
+
+	stale := NewHub(2).cursor(0)
+	if _, resp := servePoll(t, srv, token, stale, "5s"); !resp.Resync {
+		t.Fatalf("expected resync for a cursor from another epoch, got %+v", resp)
+	}
+
+	for range 3 {
+		hub.OnSystemOutput(schema.SystemOutputEvent{UserID: "alice", Lines: []string{"line"}})
+	}
+	_, resp := servePoll(t, srv, token, hub.cursor(0), "5s")
+	if !resp.Resync || len(resp.Events) != 0 || resp.Cursor != hub.cursor(3) {
+		t.Fatalf("expected resync for trimmed history, got %+v", resp)
+	}
+	_, resp = servePoll(t, srv, token, hub.cursor(1), "5s")
+	if resp.Resync || len(resp.Events) != 2 {
+		t.Fatalf("expected events 2 and 3 from history, got %+v", resp)
+	}
+	if _, resp = servePoll(t, srv, token, hub.cursor(9), "5s"); !resp.Resync {
+		t.Fatalf("expected resync for a cursor ahead of the hub, got %+v", resp)
+	}
+}
+
+func TestPollLimitsConcurrentPollsPerUser(t *testing.T) {
+	srv, token := newPollServer(t, NewHub(10), 1)
+	if !srv.polls.acquire("alice", 1) {
+		t.Fatal("expected first poll slot")
+	}
+	rec, _ := servePoll(t, srv, token, "", "0s")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"].Code != schema.CodeTooManyRequests {
+		t.Fatalf("expected too_many_requests code, got %s", rec.Body.String())
+	}
+	srv.polls.release("alice")
+	if rec, _ := servePoll(t, srv, token, "", "0s"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 once the slot is free, got %d", rec.Code)
+	}
+	if rec, _ := servePoll(t, srv, token, "", "soon"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad timeout, got %d", rec.Code)
+	}
+}
@@ -10,20 +10,34 @@ import (
 	"io"
 	"io/fs"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/archivestore"
+	"pkt.systems/centaurx/internal/auth"
 	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/motd"
+	"pkt.systems/centaurx/internal/version"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
 )
 
-// Authenticator verifies username, password, and totp.
+// Authenticator verifies username, password, and totp. AuthenticateFrom
+// throttles repeated failures per user and source address.
 type Authenticator interface {
-	Authenticate(username, password, totp string) error
-	ChangePassword(username, currentPassword, totp, newPassword string) error
+	AuthenticateFrom(source, username, password, totp string) error
+	// ChangePasswordFrom changes the password of username. Wrong
+	// credentials count as failed logins from source.
+	ChangePasswordFrom(source, username, currentPassword, totp, newPassword string) error
+	// ExternalCredentials reports whether an external backend owns
+	// passwords and second factors; logins then need no TOTP code and
+	// password changes are refused.
+	ExternalCredentials() bool
+	// Capabilities returns what username may do, as of its last login.
+	Capabilities(username string) schema.Capabilities
 }
 
 // CommandHandler routes slash commands.
@@ -39,8 +53,14 @@ type Server struct {
 	authStore  Authenticator
 	sessions   *sessionStore
 	hub        *Hub
+	archives   archivestore.Store
+	readiness  *Readiness
 	basePath   string
 	baseHref   string
+	polls      pollLimiter
+	devices    *deviceLogins
+	loginKeys  LoginPubKeyStore
+	proxies    []netip.Prefix
 }
 
 // NewServer constructs an HTTP server.
@@ -58,6 +78,7 @@ func NewServer(cfg Config, service core.Service, handler CommandHandler, authSto
 		hub:        hub,
 		basePath:   normalizeBasePath(cfg.BasePath),
 		baseHref:   buildBaseHref(cfg.BaseURL, cfg.BasePath),
+		proxies:    parseTrustedProxies(cfg.TrustedProxies),
 	}
 }
 
@@ -76,24 +97,46 @@ func (s *Server) Handler() http.Handler {
 	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.FS(assetsFS))))
 
 	mux.HandleFunc("/api/login", s.handleLogin)
+	mux.HandleFunc("/api/login/options", s.handleLoginOptions)
+	mux.HandleFunc("/api/login/device", s.handleDeviceLoginStart)
+	mux.HandleFunc("/api/login/device/poll", s.handleDeviceLoginPoll)
 	mux.HandleFunc("/api/logout", s.handleLogout)
 	mux.HandleFunc("/api/chpasswd", s.requireSession(s.handleChangePassword))
 	mux.HandleFunc("/api/codexauth", s.requireSession(s.handleCodexAuth))
+	mux.HandleFunc("/api/v1/me/password", s.requireSession(s.handleChangePassword))
+	mux.HandleFunc("/api/v1/me/codexauth", s.requireSession(s.handleCodexAuth))
+	mux.HandleFunc("/api/v1/me/keys", s.requireSession(s.handleLoginPubKeys))
+	mux.HandleFunc("/api/v1/me/keys/{id}", s.requireSession(s.handleLoginPubKey))
 	mux.HandleFunc("/api/me", s.requireSession(s.handleMe))
+	mux.HandleFunc("/api/motd", s.requireSession(s.handleMOTD))
 	mux.HandleFunc("/api/tabs", s.requireSession(s.handleTabs))
 	mux.HandleFunc("/api/tabs/activate", s.requireSession(s.handleActivate))
+	mux.HandleFunc("/api/v1/tabs/{id}/files", s.requireSession(s.handleRepoFiles))
+	mux.HandleFunc("/api/v1/tabs/{id}/file", s.requireSession(s.handleRepoFile))
+	mux.HandleFunc("/api/v1/tabs/{id}/archive", s.requireSession(s.handleRepoArchive))
+	mux.HandleFunc("/api/tabs/{id}/status", s.requireSession(s.handleTabStatus))
+	mux.HandleFunc("/api/tabs/{id}/usage", s.requireSession(s.handleTabUsage))
+	mux.HandleFunc("/api/archives/{token}", s.handleArchiveDownload)
+	mux.HandleFunc("/view/{token}", s.handleView)
+	mux.HandleFunc("/api/view/{token}/stream", s.handleViewStream)
 	mux.HandleFunc("/api/prompt", s.requireSession(s.handlePrompt))
 	mux.HandleFunc("/api/buffer", s.requireSession(s.handleBuffer))
 	mux.HandleFunc("/api/system", s.requireSession(s.handleSystemBuffer))
 	mux.HandleFunc("/api/history", s.requireSession(s.handleHistory))
 	mux.HandleFunc("/api/stream", s.requireSession(s.handleStream))
+	mux.HandleFunc("/api/v1/poll", s.requireSession(s.handlePoll))
 
-	handler := withRequestLogging(mux, s.lookupSession)
+	handler := withRequestLogging(mux, s.lookupSession, s.proxies)
+	// Probes are served at the root, outside the base path and without
+	// request logging, so orchestrators can poll them cheaply.
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", s.handleHealthz)
+	root.HandleFunc("/readyz", s.handleReadyz)
 	if s.basePath == "" {
-		return handler
+		root.Handle("/", handler)
+		return root
 	}
 	prefix := s.basePath
-	root := http.NewServeMux()
 	root.Handle(prefix+"/", http.StripPrefix(prefix, handler))
 	root.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != prefix {
@@ -126,6 +169,9 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, "index.html", stat.ModTime(), reader)
 }
 
+// errNoActiveTab is returned by prompt and command requests without a tab.
+var errNoActiveTab = schema.ErrNoActiveTab
+
 const baseHrefPlaceholder = "<!-- BASE_HREF -->"
 const uiMaxBufferLinesPlaceholder = "UI_MAX_BUFFER_LINES"
 const defaultUIMaxBufferLines = 2000
@@ -151,7 +197,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	log := logx.Ctx(r.Context()).With("remote", clientIP(r))
+	log := logx.Ctx(r.Context()).With("remote", s.clientIP(r))
 	var payload struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -163,12 +209,19 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log = log.With("user", payload.Username)
-	if err := s.authStore.Authenticate(payload.Username, payload.Password, payload.TOTP); err != nil {
+	if err := s.authStore.AuthenticateFrom(s.clientIP(r), payload.Username, payload.Password, payload.TOTP); err != nil {
 		log.Warn("http login failed", "err", err)
 		writeError(w, http.StatusUnauthorized, err)
 		return
 	}
-	token, sess := s.sessions.create(schema.UserID(payload.Username))
+	s.startSession(w, r, schema.UserID(payload.Username), s.authStore.Capabilities(payload.Username))
+	log.Info("http login ok")
+}
+
+// startSession sets the session cookie of a new login and answers with the
+// account.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, userID schema.UserID, caps schema.Capabilities) {
+	token, sess := s.sessions.create(userID, caps)
 	cookie := &http.Cookie{
 		Name:     s.cfg.SessionCookie,
 		Value:    token,
@@ -178,8 +231,50 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Expires:  sess.expiresAt,
 	}
 	http.SetCookie(w, cookie)
-	writeJSON(w, http.StatusOK, map[string]any{"username": payload.Username})
-	log.Info("http login ok")
+	s.appendMOTD(r.Context(), userID)
+	writeJSON(w, http.StatusOK, map[string]any{"username": userID, "capabilities": caps})
+}
+
+// handleLoginOptions tells the login form which fields and login methods
+// apply.
+func (s *Server) handleLoginOptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"totp":         !s.authStore.ExternalCredentials(),
+		"device_login": s.devices != nil,
+	})
+}
+
+// appendMOTD writes the message of the day to the user's system buffer on
+// login, matching what the SSH server does when a session starts.
+func (s *Server) appendMOTD(ctx context.Context, userID schema.UserID) {
+	lines := s.motdLines(ctx, userID)
+	if len(lines) == 0 {
+		return
+	}
+	if _, err := s.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Lines: lines}); err != nil {
+		logx.WithUser(ctx, userID).Warn("http motd append failed", "err", err)
+	}
+}
+
+func (s *Server) motdLines(ctx context.Context, userID schema.UserID) []string {
+	return motd.Lines(ctx, s.cfg.MOTDFile, motd.Data{User: userID, Version: version.Current()})
+}
+
+func (s *Server) handleMOTD(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	lines := s.motdLines(r.Context(), userID)
+	if lines == nil {
+		lines = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"lines": lines})
+	logx.WithUser(r.Context(), userID).Debug("http motd ok", "lines", len(lines))
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -188,7 +283,7 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	token := s.sessionToken(r)
-	log := logx.Ctx(r.Context()).With("remote", clientIP(r))
+	log := logx.Ctx(r.Context()).With("remote", s.clientIP(r))
 	if token != "" {
 		if entry, ok := s.sessions.get(token); ok {
 			log = log.With("user", entry.userID, "http_session", entry.id)
@@ -211,8 +306,13 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request, us
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	log := logx.Ctx(r.Context()).With("user", userID, "remote", clientIP(r))
+	log := logx.Ctx(r.Context()).With("user", userID, "remote", s.clientIP(r))
 	log.Info("http chpasswd request", "command", "/chpasswd")
+	if s.authStore.ExternalCredentials() {
+		log.Info("http chpasswd rejected", "command", "/chpasswd", "reason", "managed externally")
+		writeError(w, http.StatusForbidden, schema.ErrManagedExternally)
+		return
+	}
 	var payload struct {
 		CurrentPassword string `json:"current_password"`
 		TOTP            string `json:"totp"`
@@ -232,11 +332,8 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request, us
 		writeError(w, http.StatusBadRequest, errors.New("new password is required"))
 		return
 	}
-	if strings.TrimSpace(payload.ConfirmPassword) == "" {
-		writeError(w, http.StatusBadRequest, errors.New("confirm password is required"))
-		return
-	}
-	if payload.NewPassword != payload.ConfirmPassword {
+	// The confirmation guards typed passwords; API clients may leave it out.
+	if payload.ConfirmPassword != "" && payload.NewPassword != payload.ConfirmPassword {
 		writeError(w, http.StatusBadRequest, errors.New("passwords do not match"))
 		return
 	}
@@ -244,11 +341,13 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request, us
 		writeError(w, http.StatusBadRequest, errors.New("totp is required"))
 		return
 	}
-	if err := s.authStore.ChangePassword(string(userID), payload.CurrentPassword, payload.TOTP, payload.NewPassword); err != nil {
+	if err := s.authStore.ChangePasswordFrom(s.clientIP(r), string(userID), payload.CurrentPassword, payload.TOTP, payload.NewPassword); err != nil {
 		log.Warn("http chpasswd failed", "err", err)
 		log.Info("http chpasswd rejected", "command", "/chpasswd")
 		status := http.StatusInternalServerError
 		switch {
+		case errors.Is(err, auth.ErrThrottled):
+			status = http.StatusTooManyRequests
 		case isPasswordChangeAuthError(err):
 			status = http.StatusUnauthorized
 		case isPasswordChangeValidationError(err):
@@ -257,20 +356,7 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request, us
 		writeError(w, status, err)
 		return
 	}
-	ctx := sessionContext(r.Context())
-	tabID := s.resolveTabID(ctx, userID, "")
-	if tabID != "" {
-		_, _ = s.service.AppendOutput(ctx, schema.AppendOutputRequest{
-			UserID: userID,
-			TabID:  tabID,
-			Lines:  []string{"password updated"},
-		})
-	} else {
-		_, _ = s.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{
-			UserID: userID,
-			Lines:  []string{"password updated"},
-		})
-	}
+	s.appendAccountLine(r, userID, "password updated")
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 	log.Info("http chpasswd ok", "command", "/chpasswd")
 }
@@ -280,7 +366,7 @@ func (s *Server) handleCodexAuth(w http.ResponseWriter, r *http.Request, userID
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	log := logx.Ctx(r.Context()).With("user", userID, "remote", clientIP(r))
+	log := logx.Ctx(r.Context()).With("user", userID, "remote", s.clientIP(r))
 	log.Info("http codexauth request", "command", "/codexauth")
 	const maxCodexAuthSize = 2 << 20
 	body, err := io.ReadAll(io.LimitReader(r.Body, maxCodexAuthSize+1))
@@ -305,26 +391,45 @@ func (s *Server) handleCodexAuth(w http.ResponseWriter, r *http.Request, userID
 		writeError(w, status, err)
 		return
 	}
+	_, _ = s.service.RecordActivity(r.Context(), schema.RecordActivityRequest{
+		UserID: userID,
+		Kind:   schema.ActivityCodexAuthUpdated,
+		Detail: "via web",
+	})
+	s.appendAccountLine(r, userID, "codex auth updated")
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	log.Info("http codexauth ok", "command", "/codexauth")
+}
+
+// appendAccountLine confirms an account change in the user's active tab, or
+// in the system buffer without one, as the SSH terminal does.
+func (s *Server) appendAccountLine(r *http.Request, userID schema.UserID, line string) {
 	ctx := sessionContext(r.Context())
 	tabID := s.resolveTabID(ctx, userID, "")
 	if tabID != "" {
 		_, _ = s.service.AppendOutput(ctx, schema.AppendOutputRequest{
 			UserID: userID,
 			TabID:  tabID,
-			Lines:  []string{"codex auth updated"},
-		})
-	} else {
-		_, _ = s.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{
-			UserID: userID,
-			Lines:  []string{"codex auth updated"},
+			Lines:  []string{line},
 		})
+		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
-	log.Info("http codexauth ok", "command", "/codexauth")
+	_, _ = s.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{
+		UserID: userID,
+		Lines:  []string{line},
+	})
 }
 
 func (s *Server) handleMe(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
-	writeJSON(w, http.StatusOK, map[string]any{"username": userID})
+	var caps schema.Capabilities
+	if sess, ok := r.Context().Value(sessionContextKey{}).(session); ok {
+		caps = sess.caps
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"username":             userID,
+		"capabilities":         caps,
+		"external_credentials": s.authStore.ExternalCredentials(),
+	})
 }
 
 func (s *Server) handleTabs(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
@@ -332,7 +437,10 @@ func (s *Server) handleTabs(w http.ResponseWriter, r *http.Request, userID schem
 	ctx := sessionContext(r.Context())
 	switch r.Method {
 	case http.MethodGet:
-		resp, err := s.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+		resp, err := s.service.ListTabs(ctx, schema.ListTabsRequest{
+			UserID:          userID,
+			IncludeArchived: parseBool(r.URL.Query().Get("archived")),
+		})
 		if err != nil {
 			log.Warn("http tabs list failed", "err", err)
 			writeError(w, http.StatusBadRequest, err)
@@ -342,8 +450,9 @@ func (s *Server) handleTabs(w http.ResponseWriter, r *http.Request, userID schem
 		log.Info("http tabs list ok", "count", len(resp.Tabs))
 	case http.MethodPost:
 		var payload struct {
-			RepoName string `json:"repo_name"`
-			Create   bool   `json:"create"`
+			RepoName  string `json:"repo_name"`
+			Create    bool   `json:"create"`
+			Ephemeral bool   `json:"ephemeral"`
 		}
 		if err := decodeJSON(r.Body, &payload); err != nil {
 			log.Warn("http tabs decode failed", "err", err)
@@ -354,6 +463,7 @@ func (s *Server) handleTabs(w http.ResponseWriter, r *http.Request, userID schem
 			UserID:     userID,
 			RepoName:   schema.RepoName(payload.RepoName),
 			CreateRepo: payload.Create,
+			Ephemeral:  payload.Ephemeral,
 		})
 		if err != nil {
 			log.Warn("http tabs create failed", "err", err)
@@ -436,7 +546,7 @@ func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request, userID sch
 		return
 	}
 	if tabID == "" {
-		writeError(w, http.StatusBadRequest, errors.New("no active tab; use /new <repo>"))
+		writeError(w, http.StatusBadRequest, errNoActiveTab)
 		log.Warn("http prompt rejected", "reason", "no active tab")
 		return
 	}
@@ -481,7 +591,7 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, userID sc
 			tabID = s.resolveTabID(ctx, userID, "")
 		}
 		if tabID == "" {
-			writeError(w, http.StatusBadRequest, errors.New("no active tab; use /new <repo>"))
+			writeError(w, http.StatusBadRequest, errNoActiveTab)
 			log.Warn("http history rejected", "reason", "no active tab")
 			return
 		}
@@ -508,7 +618,7 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, userID sc
 		}
 		tabID := s.resolveTabID(ctx, userID, schema.TabID(payload.TabID))
 		if tabID == "" {
-			writeError(w, http.StatusBadRequest, errors.New("no active tab; use /new <repo>"))
+			writeError(w, http.StatusBadRequest, errNoActiveTab)
 			log.Warn("http history rejected", "reason", "no active tab")
 			return
 		}
@@ -552,9 +662,11 @@ func (s *Server) handleBuffer(w http.ResponseWriter, r *http.Request, userID sch
 	tabID := schema.TabID(r.URL.Query().Get("tab_id"))
 	limit := parseInt(r.URL.Query().Get("limit"), s.cfg.InitialBufferLines)
 	resp, err := s.service.GetBuffer(r.Context(), schema.GetBufferRequest{
-		UserID: userID,
-		TabID:  tabID,
-		Limit:  limit,
+		UserID:     userID,
+		TabID:      tabID,
+		Limit:      limit,
+		Structured: parseBool(r.URL.Query().Get("structured")),
+		Rendering:  schema.Rendering(r.URL.Query().Get("rendering")),
 	})
 	if err != nil {
 		log.Warn("http buffer failed", "err", err)
@@ -703,17 +815,17 @@ func (s *Server) resolveTabID(ctx context.Context, userID schema.UserID, request
 
 func (s *Server) requireSession(next func(http.ResponseWriter, *http.Request, schema.UserID)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log := logx.Ctx(r.Context()).With("remote", clientIP(r))
+		log := logx.Ctx(r.Context()).With("remote", s.clientIP(r))
 		token := s.sessionToken(r)
 		if token == "" {
 			log.Warn("http session missing")
-			writeError(w, http.StatusUnauthorized, errors.New("missing session"))
+			writeError(w, http.StatusUnauthorized, schema.WithCode(schema.CodeUnauthorized, errors.New("missing session")))
 			return
 		}
 		entry, ok := s.sessions.get(token)
 		if !ok {
 			log.Warn("http session invalid")
-			writeError(w, http.StatusUnauthorized, errors.New("invalid session"))
+			writeError(w, http.StatusUnauthorized, schema.WithCode(schema.CodeUnauthorized, errors.New("invalid session")))
 			return
 		}
 		log = log.With("user", entry.userID, "http_session", entry.id)
@@ -781,8 +893,39 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
 	_, _ = w.Write(data)
 }
 
+// errorBody is the JSON error payload; Code is a schema error code.
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 func writeError(w http.ResponseWriter, status int, err error) {
-	writeJSON(w, status, map[string]any{"error": err.Error()})
+	writeJSON(w, status, map[string]errorBody{"error": {
+		Code:    errorCode(status, err),
+		Message: err.Error(),
+	}})
+}
+
+// errorCode returns the schema code of err, falling back to one derived from
+// status for errors raised by the HTTP layer itself.
+func errorCode(status int, err error) string {
+	if code := schema.ErrorCode(err); code != schema.CodeInternal {
+		return code
+	}
+	switch status {
+	case http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge:
+		return schema.CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return schema.CodeUnauthorized
+	case http.StatusForbidden:
+		return schema.CodePermissionDenied
+	case http.StatusNotFound:
+		return schema.CodeNotFound
+	case http.StatusTooManyRequests:
+		return schema.CodeTooManyRequests
+	default:
+		return schema.CodeInternal
+	}
 }
 
 func writeSSEvent(w http.ResponseWriter, event StreamEvent) error {
@@ -808,6 +951,11 @@ func parseUint(value string) uint64 {
 	return parsed
 }
 
+func parseBool(value string) bool {
+	parsed, err := strconv.ParseBool(value)
+	return err == nil && parsed
+}
+
 func parseInt(value string, fallback int) int {
 	if value == "" {
 		return fallback
@@ -836,7 +984,7 @@ func isPasswordChangeValidationError(err error) bool {
 		return false
 	}
 	switch strings.TrimSpace(err.Error()) {
-	case "current password is required", "totp is required", "new password is required", "confirm password is required", "passwords do not match":
+	case "current password is required", "totp is required", "new password is required", "passwords do not match":
 		return true
 	default:
 		return false
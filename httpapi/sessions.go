@@ -17,8 +17,10 @@ import (
 )
 
 type session struct {
-	id        string
-	userID    schema.UserID
+	id     string
+	userID schema.UserID
+	// caps are the capabilities the auth backend granted at login.
+	caps      schema.Capabilities
 	expiresAt time.Time
 	ctx       context.Context
 	cancel    context.CancelFunc
@@ -48,9 +50,9 @@ func newSessionStore(ttl time.Duration, path string) *sessionStore {
 	return store
 }
 
-func (s *sessionStore) create(userID schema.UserID) (string, session) {
+func (s *sessionStore) create(userID schema.UserID, caps schema.Capabilities) (string, session) {
 	token := randomToken(32)
-	entry := s.newSession(userID, time.Now().Add(s.ttl), "")
+	entry := s.newSession(userID, caps, time.Now().Add(s.ttl), "")
 	log := logx.WithUser(context.Background(), userID).With("http_session", entry.id)
 	s.mu.Lock()
 	s.items[token] = entry
@@ -114,6 +116,7 @@ func (s *sessionStore) setBaseContext(ctx context.Context) {
 		prefs := entry.prefs
 		if prefs == nil {
 			prefs = sessionprefs.New()
+			prefs.Capabilities = entry.caps
 		}
 		parent := sessionprefs.WithContext(ctx, prefs)
 		nextCtx, cancel := context.WithCancel(parent)
@@ -144,10 +147,11 @@ func randomToken(size int) string {
 }
 
 type sessionRecord struct {
-	Token     string    `json:"token"`
-	SessionID string    `json:"session_id"`
-	UserID    string    `json:"user_id"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token        string              `json:"token"`
+	SessionID    string              `json:"session_id"`
+	UserID       string              `json:"user_id"`
+	Capabilities schema.Capabilities `json:"capabilities,omitzero"`
+	ExpiresAt    time.Time           `json:"expires_at"`
 }
 
 type sessionFile struct {
@@ -155,17 +159,19 @@ type sessionFile struct {
 	Sessions []sessionRecord `json:"sessions"`
 }
 
-func (s *sessionStore) newSession(userID schema.UserID, expiresAt time.Time, sessionID string) session {
+func (s *sessionStore) newSession(userID schema.UserID, caps schema.Capabilities, expiresAt time.Time, sessionID string) session {
 	if strings.TrimSpace(sessionID) == "" {
 		sessionID = randomToken(12)
 	}
 	parent := s.baseContext()
 	prefs := sessionprefs.New()
+	prefs.Capabilities = caps
 	parent = sessionprefs.WithContext(parent, prefs)
 	ctx, cancel := context.WithCancel(parent)
 	return session{
 		id:        sessionID,
 		userID:    userID,
+		caps:      caps,
 		expiresAt: expiresAt,
 		ctx:       ctx,
 		cancel:    cancel,
@@ -201,7 +207,7 @@ func (s *sessionStore) load() error {
 		if now.After(record.ExpiresAt) {
 			continue
 		}
-		entry := s.newSession(schema.UserID(record.UserID), record.ExpiresAt, record.SessionID)
+		entry := s.newSession(schema.UserID(record.UserID), record.Capabilities, record.ExpiresAt, record.SessionID)
 		entries[record.Token] = entry
 	}
 	s.mu.Lock()
@@ -230,10 +236,11 @@ func (s *sessionStore) snapshot() []sessionRecord {
 	records := make([]sessionRecord, 0, len(s.items))
 	for token, entry := range s.items {
 		records = append(records, sessionRecord{
-			Token:     token,
-			SessionID: entry.id,
-			UserID:    string(entry.userID),
-			ExpiresAt: entry.expiresAt,
+			Token:        token,
+			SessionID:    entry.id,
+			UserID:       string(entry.userID),
+			Capabilities: entry.caps,
+			ExpiresAt:    entry.expiresAt,
 		})
 	}
 	return records
@@ -5,13 +5,15 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"pkt.systems/centaurx/schema"
 )
 
 type sessionTestKey struct{}
 
 func TestSessionStoreCreateGetDelete(t *testing.T) {
 	store := newSessionStore(time.Hour, "")
-	token, sess := store.create("alice")
+	token, sess := store.create("alice", schema.Capabilities{})
 	if token == "" {
 		t.Fatalf("expected token")
 	}
@@ -37,7 +39,7 @@ func TestSessionStoreCreateGetDelete(t *testing.T) {
 
 func TestSessionStoreExpiration(t *testing.T) {
 	store := newSessionStore(5*time.Millisecond, "")
-	token, sess := store.create("alice")
+	token, sess := store.create("alice", schema.Capabilities{})
 	time.Sleep(10 * time.Millisecond)
 	if _, ok := store.get(token); ok {
 		t.Fatalf("expected expired session")
@@ -54,7 +56,7 @@ func TestSessionStoreBaseContext(t *testing.T) {
 	baseKey := sessionTestKey{}
 	base := context.WithValue(context.Background(), baseKey, "value")
 	store.setBaseContext(base)
-	_, sess := store.create("alice")
+	_, sess := store.create("alice", schema.Capabilities{})
 	if got := sess.ctx.Value(baseKey); got != "value" {
 		t.Fatalf("expected base context value, got %v", got)
 	}
@@ -64,7 +66,7 @@ func TestSessionStorePersistsSessions(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "sessions.json")
 	store := newSessionStore(time.Hour, path)
-	token, _ := store.create("alice")
+	token, _ := store.create("alice", schema.Capabilities{})
 
 	loaded := newSessionStore(time.Hour, path)
 	if _, ok := loaded.get(token); !ok {
@@ -76,7 +78,7 @@ func TestSessionStorePersistsExpiration(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "sessions.json")
 	store := newSessionStore(5*time.Millisecond, path)
-	token, _ := store.create("alice")
+	token, _ := store.create("alice", schema.Capabilities{})
 	time.Sleep(10 * time.Millisecond)
 	if _, ok := store.get(token); ok {
 		t.Fatalf("expected session to expire")
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// handleTabStatus serves the /status report of a tab as JSON.
+func (s *Server) handleTabStatus(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	tabID := schema.TabID(r.PathValue("id"))
+	log := logx.WithUserTab(r.Context(), userID, tabID)
+	resp, err := s.service.GetTabStatus(r.Context(), schema.GetTabStatusRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		log.Warn("http tab status failed", "err", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, schema.ErrTabNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp.Status)
+	log.Debug("http tab status ok", "usage", resp.Status.Usage != nil)
+}
+
+// handleTabUsage serves the token usage codex last reported for a tab.
+func (s *Server) handleTabUsage(w http.ResponseWriter, r *http.Request, userID schema.UserID) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	tabID := schema.TabID(r.PathValue("id"))
+	log := logx.WithUserTab(r.Context(), userID, tabID)
+	resp, err := s.service.GetTabUsage(r.Context(), schema.GetTabUsageRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		log.Warn("http tab usage failed", "err", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, schema.ErrTabNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+	log.Debug("http tab usage ok", "has_usage", resp.Usage != nil)
+}
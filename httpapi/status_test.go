@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/schema"
+)
+
+type tabStatusService struct {
+	core.Service
+}
+
+func (tabStatusService) GetTabStatus(_ context.Context, req schema.GetTabStatusRequest) (schema.GetTabStatusResponse, error) {
+	if req.TabID != "tab1" {
+		return schema.GetTabStatusResponse{}, schema.ErrTabNotFound
+	}
+	return schema.GetTabStatusResponse{Status: schema.TabStatusInfo{
+		TabID:      req.TabID,
+		Model:      "gpt-5.2-codex",
+		Directory:  "/repos/" + string(req.UserID) + "/demo",
+		TokensUsed: 1200,
+		Usage: &schema.AccountUsageStatus{
+			ChatGPT: true,
+			Primary: &schema.UsageWindowStatus{UsedPercent: 42},
+		},
+	}}, nil
+}
+
+func TestTabStatusEndpointReturnsJSON(t *testing.T) {
+	rec := serveRepoFiles(t, tabStatusService{}, "/api/tabs/tab1/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	usage, _ := resp["usage"].(map[string]any)
+	primary, _ := usage["primary"].(map[string]any)
+	if resp["directory"] != "/repos/alice/demo" || resp["tokens_used"] != float64(1200) || primary["used_percent"] != float64(42) {
+		t.Fatalf("unexpected status: %s", rec.Body.String())
+	}
+
+	if rec := serveRepoFiles(t, tabStatusService{}, "/api/tabs/missing/status"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown tab, got %d", rec.Code)
+	}
+}
+
+func (tabStatusService) GetTabUsage(_ context.Context, req schema.GetTabUsageRequest) (schema.GetTabUsageResponse, error) {
+	if req.TabID != "tab1" {
+		return schema.GetTabUsageResponse{}, schema.ErrTabNotFound
+	}
+	return schema.GetTabUsageResponse{Usage: &schema.TurnUsage{InputTokens: 42, OutputTokens: 7}}, nil
+}
+
+func TestTabUsageEndpointReturnsJSON(t *testing.T) {
+	rec := serveRepoFiles(t, tabStatusService{}, "/api/tabs/tab1/usage")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp schema.GetTabUsageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens != 42 || resp.Usage.OutputTokens != 7 {
+		t.Fatalf("unexpected usage: %s", rec.Body.String())
+	}
+
+	if rec := serveRepoFiles(t, tabStatusService{}, "/api/tabs/missing/usage"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown tab, got %d", rec.Code)
+	}
+}
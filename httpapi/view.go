@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// viewRecheckInterval is how often an open view stream checks that its link
+// was not revoked while the tab is quiet.
+const viewRecheckInterval = 30 * time.Second
+
+// ViewEvent is sent to share link viewers. It carries plain text only: no
+// tab snapshot, since that includes the owner's repo path.
+type ViewEvent struct {
+	Type      string         `json:"type"`
+	Tab       schema.TabName `json:"tab,omitempty"`
+	Lines     []string       `json:"lines,omitempty"`
+	ExpiresAt time.Time      `json:"expires_at,omitzero"`
+}
+
+// ViewURLPrefix returns the URL prefix that share link tokens are appended
+// to.
+func ViewURLPrefix(cfg Config) string {
+	base := buildBaseHref(cfg.BaseURL, cfg.BasePath)
+	if base == "" {
+		base = "/"
+	}
+	return base + "view/"
+}
+
+// handleView serves the read-only page of a share link. The token in the
+// path is the only credential; the page has no prompt or command input.
+func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	setViewHeaders(w)
+	if _, err := s.service.ViewShareLink(r.Context(), schema.ViewShareLinkRequest{Token: r.PathValue("token"), Limit: 1}); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, schema.ErrShareLinkNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	data, err := fs.ReadFile(assetsFS, "view.html")
+	if err != nil {
+		http.Error(w, "view not found", http.StatusInternalServerError)
+		return
+	}
+	baseHref := s.baseHref
+	if baseHref == "" {
+		// The page sits below /view/, so relative asset URLs need a base.
+		baseHref = "/"
+	}
+	data = applyBaseHref(data, baseHref)
+	data = applyUIMaxBufferLines(data, s.cfg.UIMaxBufferLines)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// handleViewStream streams a shared tab's output to a link viewer. The link
+// is checked again on output and periodically, so revoking or closing the
+// tab ends the stream, and the stream ends when the link expires.
+func (s *Server) handleViewStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("stream unsupported"))
+		return
+	}
+	if s.hub == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("event hub unavailable"))
+		return
+	}
+	token := r.PathValue("token")
+	view, err := s.service.ViewShareLink(r.Context(), schema.ViewShareLinkRequest{Token: token, Limit: s.cfg.InitialBufferLines})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, schema.ErrShareLinkNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	log := logx.WithUserTab(r.Context(), view.Owner, view.TabID)
+
+	setViewHeaders(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Connection", "keep-alive")
+	_ = writeViewEvent(w, ViewEvent{Type: "snapshot", Tab: view.TabName, Lines: viewLines(view.Buffer.Lines), ExpiresAt: view.ExpiresAt})
+	flusher.Flush()
+
+	ch, unsubscribe, _, _ := s.hub.Subscribe(view.Owner)
+	defer unsubscribe()
+	expiry := time.NewTimer(time.Until(view.ExpiresAt))
+	defer expiry.Stop()
+	recheck := time.NewTicker(viewRecheckInterval)
+	defer recheck.Stop()
+
+	closeView := func(reason string) {
+		_ = writeViewEvent(w, ViewEvent{Type: "closed"})
+		flusher.Flush()
+		log.Info("http view stream ended", "reason", reason)
+	}
+	log.Info("http view stream opened", "lines", len(view.Buffer.Lines))
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Info("http view stream closed")
+			return
+		case <-expiry.C:
+			closeView("expired")
+			return
+		case <-recheck.C:
+			if !s.viewLinkValid(r.Context(), token) {
+				closeView("revoked")
+				return
+			}
+		case event := <-ch:
+			switch {
+			case event.Type == "output" && event.TabID == view.TabID:
+				if !s.viewLinkValid(r.Context(), token) {
+					closeView("revoked")
+					return
+				}
+				_ = writeViewEvent(w, ViewEvent{Type: "output", Lines: viewLines(event.Lines)})
+				flusher.Flush()
+			case event.Type == "tab" && event.Tab != nil && event.Tab.ID == view.TabID && event.TabEvent == string(schema.TabEventClosed):
+				closeView("tab closed")
+				return
+			}
+		}
+	}
+}
+
+// viewLinkValid is the capability check of a view stream: the link must
+// still resolve.
+func (s *Server) viewLinkValid(ctx context.Context, token string) bool {
+	_, err := s.service.ViewShareLink(ctx, schema.ViewShareLinkRequest{Token: token, Limit: 1})
+	return err == nil
+}
+
+// viewLines strips the line markers the web UI styles by; prompts keep
+// their "> " prefix.
+func viewLines(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	for _, raw := range lines {
+		line := schema.ParseBufferLine(raw)
+		if line.Kind == schema.LineKindPrompt {
+			out = append(out, raw)
+			continue
+		}
+		out = append(out, line.Text)
+	}
+	return out
+}
+
+func setViewHeaders(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Cache-Control", "no-store")
+	// The token is in the URL; keep it out of Referer headers and indexes.
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("X-Robots-Tag", "noindex")
+	header.Set("X-Content-Type-Options", "nosniff")
+}
+
+func writeViewEvent(w http.ResponseWriter, event ViewEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "data: %s\n\n", strings.TrimSpace(string(data)))
+	return nil
+}
@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/schema"
+)
+
+type shareLinkService struct {
+	core.Service
+	revoked *atomic.Bool
+}
+
+func (s shareLinkService) ViewShareLink(_ context.Context, req schema.ViewShareLinkRequest) (schema.ViewShareLinkResponse, error) {
+	if req.Token != "good" || s.revoked.Load() {
+		return schema.ViewShareLinkResponse{}, schema.ErrShareLinkNotFound
+	}
+	return schema.ViewShareLinkResponse{
+		Owner:     "alice",
+		TabID:     "tab1",
+		TabName:   "demo",
+		Buffer:    schema.BufferSnapshot{TabID: "tab1", Lines: []string{"> hello", schema.StderrMarker + "oops"}},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil
+}
+
+func TestViewPageRequiresLiveToken(t *testing.T) {
+	srv := NewServer(Config{SessionCookie: "cx_session"}, shareLinkService{revoked: &atomic.Bool{}}, nil, nil, NewHub(10))
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/view/guess", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown token to 404, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/view/good", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "assets/view.js") || !strings.Contains(rec.Body.String(), `<base href="/" />`) {
+		t.Fatalf("unexpected view page %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Referrer-Policy") != "no-referrer" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+	if got := redactViewToken("/api/view/good/stream"); got != "/api/view/REDACTED/stream" {
+		t.Fatalf("expected token to be redacted from logs, got %q", got)
+	}
+}
+
+func TestViewStreamFollowsTabUntilRevoked(t *testing.T) {
+	revoked := &atomic.Bool{}
+	hub := NewHub(10)
+	srv := NewServer(Config{SessionCookie: "cx_session"}, shareLinkService{revoked: revoked}, nil, nil, hub)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/view/good/stream")
+	if err != nil {
+		t.Fatalf("get stream: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	reader := bufio.NewReader(resp.Body)
+	next := func() ViewEvent {
+		t.Helper()
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+				var event ViewEvent
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatalf("decode event: %v", err)
+				}
+				return event
+			}
+		}
+	}
+
+	snapshot := next()
+	if snapshot.Type != "snapshot" || snapshot.Tab != "demo" || strings.Join(snapshot.Lines, "|") != "> hello|oops" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	waitForSubscriber(t, hub, "alice")
+	hub.OnOutput(schema.OutputEvent{UserID: "alice", TabID: "tab2", Lines: []string{"other tab"}})
+	hub.OnOutput(schema.OutputEvent{UserID: "alice", TabID: "tab1", Lines: []string{"working"}})
+	if event := next(); event.Type != "output" || strings.Join(event.Lines, "|") != "working" {
+		t.Fatalf("expected only the shared tab's output, got %+v", event)
+	}
+
+	revoked.Store(true)
+	hub.OnOutput(schema.OutputEvent{UserID: "alice", TabID: "tab1", Lines: []string{"secret"}})
+	if event := next(); event.Type != "closed" {
+		t.Fatalf("expected revoked link to close the stream, got %+v", event)
+	}
+}
+
+func waitForSubscriber(t *testing.T, hub *Hub, userID schema.UserID) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.Lock()
+		subs := 0
+		if uh := hub.users[userID]; uh != nil {
+			subs = len(uh.subs)
+		}
+		hub.mu.Unlock()
+		if subs > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no subscriber for %s", userID)
+}
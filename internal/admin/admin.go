@@ -0,0 +1,254 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Runner describes a live runner container.
+type Runner struct {
+	User      schema.UserID  `json:"user"`
+	Tab       schema.TabID   `json:"tab,omitempty"`
+	Tabs      []schema.TabID `json:"tabs,omitempty"`
+	Container string         `json:"container,omitempty"`
+	Running   int            `json:"running"`
+	LastUsed  time.Time      `json:"last_used"`
+}
+
+// ListRunnersResponse is returned by GET /runners.
+type ListRunnersResponse struct {
+	Runners []Runner `json:"runners"`
+}
+
+// CloseRunnersRequest selects the runners closed by POST /runners/close:
+// those of User, or every runner with All.
+type CloseRunnersRequest struct {
+	User schema.UserID `json:"user,omitempty"`
+	All  bool          `json:"all,omitempty"`
+}
+
+// CloseRunnersResponse reports how many runners were closed.
+type CloseRunnersResponse struct {
+	Closed int `json:"closed"`
+}
+
+// LogCapture is returned by GET /logcapture.
+type LogCapture struct {
+	LimitBytes    int64               `json:"limit_bytes"`
+	CapacityBytes int64               `json:"capacity_bytes"`
+	UsedBytes     int64               `json:"used_bytes"`
+	Containers    []LogCaptureCapture `json:"containers"`
+}
+
+// LogCaptureCapture describes the log capture of one container.
+type LogCaptureCapture struct {
+	Name          string    `json:"name"`
+	Created       time.Time `json:"created"`
+	LastUsed      time.Time `json:"last_used"`
+	CapacityBytes int64     `json:"capacity_bytes"`
+	UsedBytes     int64     `json:"used_bytes"`
+	Shrunk        bool      `json:"shrunk,omitempty"`
+}
+
+// Server answers admin requests on a unix socket only the server's user can
+// connect to. LogCapture is optional; it is set when the container runtime
+// keeps logs in memory.
+type Server struct {
+	SocketPath string
+	Runners    core.RunnerProvider
+	LogCapture shipohoy.LogCaptureReporter
+}
+
+// ListenAndServe serves admin requests until ctx is canceled and removes the
+// socket on return. A stale socket left by a crashed server is replaced; a
+// socket another server still answers on is not.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	log := pslog.Ctx(ctx)
+	if strings.TrimSpace(s.SocketPath) == "" {
+		return errors.New("admin socket path is required")
+	}
+	if s.Runners == nil {
+		return errors.New("runner provider is required")
+	}
+	if conn, err := net.Dial("unix", s.SocketPath); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("admin socket %s is in use by another server", s.SocketPath)
+	}
+	_ = os.Remove(s.SocketPath)
+	listener, err := listenPrivate(s.SocketPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(s.SocketPath) }()
+
+	server := &http.Server{
+		Handler:  s.Handler(),
+		ErrorLog: pslog.LogLoggerWithLevel(log, pslog.ErrorLevel),
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+	log.Info("admin socket listening", "socket", s.SocketPath)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+// listenPrivate listens on a unix socket at path that is mode 0600 from the
+// moment it can be reached: the socket is bound in a fresh 0700 directory
+// next to path, restricted there and then renamed into place.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".admin-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	tmp := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Handler returns the admin HTTP handler.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /runners", s.handleListRunners)
+	mux.HandleFunc("POST /runners/close", s.handleCloseRunners)
+	mux.HandleFunc("GET /logcapture", s.handleLogCapture)
+	return mux
+}
+
+func (s *Server) handleListRunners(w http.ResponseWriter, r *http.Request) {
+	admin, ok := s.Runners.(core.RunnerAdmin)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("runner provider cannot list runners"))
+		return
+	}
+	runners, err := admin.ListRunners(r.Context())
+	if err != nil {
+		pslog.Ctx(r.Context()).Warn("admin runner list failed", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := ListRunnersResponse{Runners: make([]Runner, 0, len(runners))}
+	for _, runner := range runners {
+		resp.Runners = append(resp.Runners, Runner{
+			User:      runner.UserID,
+			Tab:       runner.TabID,
+			Tabs:      runner.Tabs,
+			Container: runner.Container,
+			Running:   runner.Running,
+			LastUsed:  runner.LastUsed,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCloseRunners(w http.ResponseWriter, r *http.Request) {
+	log := pslog.Ctx(r.Context())
+	var req CloseRunnersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	req.User = schema.UserID(strings.TrimSpace(string(req.User)))
+	if req.All == (req.User != "") {
+		writeError(w, http.StatusBadRequest, errors.New("select either a user or all runners"))
+		return
+	}
+	admin, ok := s.Runners.(core.RunnerAdmin)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("runner provider cannot close runners"))
+		return
+	}
+	var closed int
+	var err error
+	if req.All {
+		var runners []core.RunnerStatus
+		if runners, err = admin.ListRunners(r.Context()); err == nil {
+			closed = len(runners)
+			err = s.Runners.CloseAll(r.Context())
+		}
+	} else {
+		closed, err = admin.CloseUser(r.Context(), req.User)
+	}
+	if err != nil {
+		log.Warn("admin runner close failed", "user", req.User, "all", req.All, "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	log.Info("admin runner close ok", "user", req.User, "all", req.All, "closed", closed)
+	writeJSON(w, http.StatusOK, CloseRunnersResponse{Closed: closed})
+}
+
+func (s *Server) handleLogCapture(w http.ResponseWriter, _ *http.Request) {
+	if s.LogCapture == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("container runtime does not capture logs in memory"))
+		return
+	}
+	stats := s.LogCapture.LogCaptureStats()
+	resp := LogCapture{
+		LimitBytes:    stats.LimitBytes,
+		CapacityBytes: stats.CapacityBytes,
+		UsedBytes:     stats.UsedBytes,
+		Containers:    make([]LogCaptureCapture, 0, len(stats.Containers)),
+	}
+	for _, usage := range stats.Containers {
+		resp.Containers = append(resp.Containers, LogCaptureCapture{
+			Name:          usage.Name,
+			Created:       usage.Created,
+			LastUsed:      usage.LastUsed,
+			CapacityBytes: usage.CapacityBytes,
+			UsedBytes:     usage.UsedBytes,
+			Shrunk:        usage.Shrunk,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	data, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]any{"error": err.Error()})
+}
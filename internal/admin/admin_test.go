@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/schema"
+)
+
+type fakeProvider struct {
+	mu       sync.Mutex
+	runners  []core.RunnerStatus
+	closeAll int
+}
+
+func (p *fakeProvider) RunnerFor(context.Context, core.RunnerRequest) (core.RunnerResponse, error) {
+	return core.RunnerResponse{}, nil
+}
+
+func (p *fakeProvider) CloseTab(context.Context, core.RunnerCloseRequest) error { return nil }
+
+func (p *fakeProvider) CloseAll(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeAll++
+	p.runners = nil
+	return nil
+}
+
+func (p *fakeProvider) ListRunners(context.Context) ([]core.RunnerStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]core.RunnerStatus(nil), p.runners...), nil
+}
+
+func (p *fakeProvider) CloseUser(_ context.Context, userID schema.UserID) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := p.runners[:0]
+	closed := 0
+	for _, runner := range p.runners {
+		if runner.UserID == userID {
+			closed++
+			continue
+		}
+		kept = append(kept, runner)
+	}
+	p.runners = kept
+	return closed, nil
+}
+
+func startServer(t *testing.T, provider core.RunnerProvider) string {
+	t.Helper()
+	return serve(t, &Server{Runners: provider})
+}
+
+// serve starts server on a socket in a temporary directory and returns the
+// socket path once it answers.
+func serve(t *testing.T, server *Server) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	server.SocketPath = socket
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.ListenAndServe(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		// Any answer means the socket is listening with its final mode.
+		if _, err := NewClient(socket).ListRunners(context.Background()); err == nil || !strings.Contains(err.Error(), "is the server running?") {
+			return socket
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("admin socket not created")
+	return ""
+}
+
+func TestAdminListAndCloseRunners(t *testing.T) {
+	used := time.Date(2025, time.March, 4, 5, 6, 7, 0, time.UTC)
+	provider := &fakeProvider{runners: []core.RunnerStatus{
+		{UserID: "alice", Tabs: []schema.TabID{"t1"}, Container: "centaurx-runner-alice", LastUsed: used},
+		{UserID: "bob", Container: "centaurx-runner-bob"},
+		{UserID: "carol", Container: "centaurx-runner-carol"},
+	}}
+	socket := startServer(t, provider)
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(socket))
+	if err != nil || len(entries) != 1 || entries[0].Name() != "admin.sock" {
+		t.Fatalf("expected only the socket next to it, got %v (%v)", entries, err)
+	}
+
+	ctx := context.Background()
+	client := NewClient(socket)
+	runners, err := client.ListRunners(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(runners) != 3 || runners[0].User != "alice" || runners[0].Tabs[0] != "t1" || !runners[0].LastUsed.Equal(used) {
+		t.Fatalf("unexpected runners %+v", runners)
+	}
+
+	closed, err := client.CloseRunners(ctx, CloseRunnersRequest{User: "alice"})
+	if err != nil || closed != 1 {
+		t.Fatalf("close alice: %d, %v", closed, err)
+	}
+	closed, err = client.CloseRunners(ctx, CloseRunnersRequest{All: true})
+	if err != nil || closed != 2 || provider.closeAll != 1 {
+		t.Fatalf("close all: %d, %v (CloseAll calls %d)", closed, err, provider.closeAll)
+	}
+	if _, err := client.CloseRunners(ctx, CloseRunnersRequest{User: "alice", All: true}); err == nil || !strings.Contains(err.Error(), "either a user or all") {
+		t.Fatalf("expected selection error, got %v", err)
+	}
+	if _, err := client.CloseRunners(ctx, CloseRunnersRequest{}); err == nil {
+		t.Fatalf("expected error for empty selection")
+	}
+}
+
+func TestAdminSocketInUse(t *testing.T) {
+	socket := startServer(t, &fakeProvider{})
+	server := &Server{SocketPath: socket, Runners: &fakeProvider{}}
+	if err := server.ListenAndServe(context.Background()); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("expected socket in use error, got %v", err)
+	}
+	if _, err := NewClient(socket).ListRunners(context.Background()); err != nil {
+		t.Fatalf("first server should still answer: %v", err)
+	}
+}
+
+func TestAdminClientServerDown(t *testing.T) {
+	_, err := NewClient(filepath.Join(t.TempDir(), "admin.sock")).ListRunners(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "is the server running?") {
+		t.Fatalf("expected server down error, got %v", err)
+	}
+}
+
+func TestAdminRequiresRunnerAdmin(t *testing.T) {
+	socket := startServer(t, core.StaticRunnerProvider{})
+	if _, err := NewClient(socket).ListRunners(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot list runners") {
+		t.Fatalf("expected unsupported error, got %v", err)
+	}
+}
+
+type fakeLogCapture struct {
+	stats shipohoy.LogCaptureStats
+}
+
+func (f fakeLogCapture) LogCaptureStats() shipohoy.LogCaptureStats { return f.stats }
+
+func TestAdminLogCapture(t *testing.T) {
+	socket := startServer(t, &fakeProvider{})
+	if _, err := NewClient(socket).LogCapture(context.Background()); err == nil || !strings.Contains(err.Error(), "does not capture logs") {
+		t.Fatalf("expected unsupported error without a reporter, got %v", err)
+	}
+
+	created := time.Date(2025, time.March, 4, 5, 6, 7, 0, time.UTC)
+	socket = serve(t, &Server{Runners: &fakeProvider{}, LogCapture: fakeLogCapture{stats: shipohoy.LogCaptureStats{
+		LimitBytes:    1024,
+		CapacityBytes: 512,
+		UsedBytes:     100,
+		Containers: []shipohoy.LogCaptureUsage{
+			{Name: "centaurx-runner-alice", Created: created, LastUsed: created, CapacityBytes: 512, UsedBytes: 100},
+			{Name: "centaurx-runner-bob", Created: created, Shrunk: true},
+		},
+	}}})
+	stats, err := NewClient(socket).LogCapture(context.Background())
+	if err != nil {
+		t.Fatalf("log capture: %v", err)
+	}
+	if stats.LimitBytes != 1024 || stats.CapacityBytes != 512 || stats.UsedBytes != 100 || len(stats.Containers) != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if first := stats.Containers[0]; first.Name != "centaurx-runner-alice" || !first.Created.Equal(created) || first.Shrunk {
+		t.Fatalf("unexpected capture %+v", first)
+	}
+	if !stats.Containers[1].Shrunk || stats.Containers[1].CapacityBytes != 0 {
+		t.Fatalf("expected dropped capture, got %+v", stats.Containers[1])
+	}
+}
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Client sends admin requests to a running server.
+type Client struct {
+	socketPath string
+	http       *http.Client
+}
+
+// NewClient returns a client for the admin socket at socketPath.
+func NewClient(socketPath string) *Client {
+	return &Client{
+		socketPath: socketPath,
+		http: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		}},
+	}
+}
+
+// ListRunners returns the live runners.
+func (c *Client) ListRunners(ctx context.Context) ([]Runner, error) {
+	var resp ListRunnersResponse
+	if err := c.do(ctx, http.MethodGet, "/runners", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Runners, nil
+}
+
+// CloseRunners closes the runners selected by req and returns how many were
+// closed.
+func (c *Client) CloseRunners(ctx context.Context, req CloseRunnersRequest) (int, error) {
+	var resp CloseRunnersResponse
+	if err := c.do(ctx, http.MethodPost, "/runners/close", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Closed, nil
+}
+
+// LogCapture returns the in-memory log capture usage of the container
+// runtime.
+func (c *Client) LogCapture(ctx context.Context) (LogCapture, error) {
+	var resp LogCapture
+	if err := c.do(ctx, http.MethodGet, "/logcapture", nil, &resp); err != nil {
+		return LogCapture{}, err
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://admin"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin socket %s: %w (is the server running?)", c.socketPath, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		var payload struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.Error == "" {
+			return fmt.Errorf("admin request failed: %s", resp.Status)
+		}
+		return fmt.Errorf("admin request failed: %s", payload.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
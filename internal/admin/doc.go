@@ -0,0 +1,3 @@
+// Package admin serves operator requests to a running centaurx server over
+// a local unix socket, and provides the client the CLI uses to send them.
+package admin
@@ -4,22 +4,32 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"pkt.systems/centaurx/internal/sshprompt"
+	"pkt.systems/centaurx/internal/timefmt"
 	"pkt.systems/centaurx/schema"
 )
 
 // Config is the top-level application configuration.
 type Config struct {
-	ConfigVersion int           `mapstructure:"config_version" yaml:"config_version"`
-	RepoRoot      string        `mapstructure:"repo_root" yaml:"repo_root"`
-	StateDir      string        `mapstructure:"state_dir" yaml:"state_dir"`
-	Models        ModelsConfig  `mapstructure:"models" yaml:"models"`
-	Service       ServiceConfig `mapstructure:"service" yaml:"service"`
-	Runner        RunnerConfig  `mapstructure:"runner" yaml:"runner"`
-	HTTP          HTTPConfig    `mapstructure:"http" yaml:"http"`
-	SSH           SSHConfig     `mapstructure:"ssh" yaml:"ssh"`
-	Auth          AuthConfig    `mapstructure:"auth" yaml:"auth"`
-	Logging       LoggingConfig `mapstructure:"logging" yaml:"logging"`
+	ConfigVersion int             `mapstructure:"config_version" yaml:"config_version"`
+	RepoRoot      string          `mapstructure:"repo_root" yaml:"repo_root"`
+	StateDir      string          `mapstructure:"state_dir" yaml:"state_dir"`
+	Models        ModelsConfig    `mapstructure:"models" yaml:"models"`
+	Service       ServiceConfig   `mapstructure:"service" yaml:"service"`
+	Prompts       PromptsConfig   `mapstructure:"prompts" yaml:"prompts"`
+	Repos         ReposConfig     `mapstructure:"repos" yaml:"repos"`
+	UI            UIConfig        `mapstructure:"ui" yaml:"ui"`
+	Summaries     SummariesConfig `mapstructure:"summaries" yaml:"summaries"`
+	Usage         UsageConfig     `mapstructure:"usage" yaml:"usage"`
+	Budgets       BudgetsConfig   `mapstructure:"budgets" yaml:"budgets"`
+	Batch         BatchConfig     `mapstructure:"batch" yaml:"batch"`
+	Runner        RunnerConfig    `mapstructure:"runner" yaml:"runner"`
+	HTTP          HTTPConfig      `mapstructure:"http" yaml:"http"`
+	SSH           SSHConfig       `mapstructure:"ssh" yaml:"ssh"`
+	Auth          AuthConfig      `mapstructure:"auth" yaml:"auth"`
+	Logging       LoggingConfig   `mapstructure:"logging" yaml:"logging"`
 }
 
 // CurrentConfigVersion marks the supported config version.
@@ -33,34 +43,156 @@ type ModelsConfig struct {
 
 // ServiceConfig controls core service behavior.
 type ServiceConfig struct {
-	BufferMaxLines int `mapstructure:"buffer_max_lines" yaml:"buffer_max_lines"`
+	BufferMaxLines   int `mapstructure:"buffer_max_lines" yaml:"buffer_max_lines"`
+	HistoryMax       int `mapstructure:"history_max" yaml:"history_max"`
+	GlobalHistoryMax int `mapstructure:"global_history_max" yaml:"global_history_max"`
+	// ClosedTabTTLHours is how long closed tabs can be restored with /reopen.
+	ClosedTabTTLHours int `mapstructure:"closed_tab_ttl_hours" yaml:"closed_tab_ttl_hours"`
+	// GitSummaryTTLSeconds is how long the git summary shown when a prompt
+	// starts is reused for the same tab.
+	GitSummaryTTLSeconds int `mapstructure:"git_summary_ttl_seconds" yaml:"git_summary_ttl_seconds"`
+	// GitSummaryRemotesMax is how many remotes the git summary lists before
+	// counting the rest.
+	GitSummaryRemotesMax int `mapstructure:"git_summary_remotes_max" yaml:"git_summary_remotes_max"`
+	// Onboarding shows new users the getting started checklist.
+	Onboarding bool `mapstructure:"onboarding" yaml:"onboarding"`
+}
+
+// PromptsConfig limits the size of prompts.
+type PromptsConfig struct {
+	// MaxBytes refuses larger prompts with an error naming the size and the
+	// limit.
+	MaxBytes int `mapstructure:"max_bytes" yaml:"max_bytes"`
+	// WarnBytes appends a warning to the tab buffer for larger prompts,
+	// which still run.
+	WarnBytes int `mapstructure:"warn_bytes" yaml:"warn_bytes"`
+}
+
+// ReposConfig controls how tabs share a repo.
+type ReposConfig struct {
+	// ConcurrentRuns is "reject" to refuse a prompt while another tab runs
+	// codex in the same repo, or "queue" to start it once that run ends.
+	ConcurrentRuns string `mapstructure:"concurrent_runs" yaml:"concurrent_runs"`
+	// SkipPreflight disables the reachability probe /new runs from the
+	// runner before cloning a git URL.
+	SkipPreflight bool `mapstructure:"skip_preflight" yaml:"skip_preflight"`
+}
+
+// UIConfig controls how times are shown to users and where custom themes
+// are loaded from.
+type UIConfig struct {
+	// TimeFormat is the Go time layout for times of day in status output,
+	// the timestamp column and exec headers.
+	TimeFormat string `mapstructure:"time_format" yaml:"time_format"`
+	// Timezone is the IANA zone times are shown in; empty uses the server's
+	// local zone. Users can override it with /tz.
+	Timezone string `mapstructure:"timezone" yaml:"timezone"`
+	// ThemesDir holds custom theme files; empty uses <state_dir>/themes.
+	ThemesDir string `mapstructure:"themes_dir" yaml:"themes_dir"`
+}
+
+// SummariesConfig controls the daily tab summaries written for tabs that
+// turned them on with /summary on.
+type SummariesConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Time is the time of day (HH:MM, in ui.timezone) summaries are written.
+	Time string `mapstructure:"time" yaml:"time"`
+	// Model writes the summaries; a small model keeps the cost down.
+	Model string `mapstructure:"model" yaml:"model"`
+	// MaxInputBytes caps the tab activity sent to the model per summary.
+	MaxInputBytes int `mapstructure:"max_input_bytes" yaml:"max_input_bytes"`
+}
+
+// UsageConfig controls warnings and limits based on the ChatGPT account
+// usage windows shown by /status.
+type UsageConfig struct {
+	// WarnBelowPercent lists remaining-percent thresholds; crossing one
+	// appends a warning to the tab buffer once per usage window.
+	WarnBelowPercent []int `mapstructure:"warn_below_percent" yaml:"warn_below_percent"`
+	// BlockBelowPercent refuses prompts while a usage window has less than
+	// this percent remaining; 0 turns blocking off.
+	BlockBelowPercent int `mapstructure:"block_below_percent" yaml:"block_below_percent"`
+}
+
+// BudgetsConfig caps the codex tokens each user may use per UTC day.
+// Individual users get their own budget with `centaurx users set-token-budget`.
+type BudgetsConfig struct {
+	// DailyTokensPerUser refuses prompts once a user has used this many
+	// tokens today; 0 turns budgets off.
+	DailyTokensPerUser int64 `mapstructure:"daily_tokens_per_user" yaml:"daily_tokens_per_user"`
+	// WarnPercent appends a warning to the tab buffer for prompts started
+	// with at least this share of the budget used; 0 turns warnings off.
+	WarnPercent int `mapstructure:"warn_percent" yaml:"warn_percent"`
+}
+
+// BatchConfig controls /batch runs of one prompt across several repos.
+type BatchConfig struct {
+	// Parallelism is how many repos of a batch run at once; 1 runs them
+	// one after another.
+	Parallelism int `mapstructure:"parallelism" yaml:"parallelism"`
+}
+
+// ThemesDir returns the directory custom themes are loaded from.
+func (c Config) ThemesDir() string {
+	if dir := c.UI.ThemesDir; dir != "" {
+		return dir
+	}
+	return filepath.Join(c.StateDir, "themes")
+}
+
+// AdminSocketPath returns the unix socket the running server accepts admin
+// requests on.
+func (c Config) AdminSocketPath() string {
+	return filepath.Join(c.StateDir, "admin.sock")
 }
 
 // RunnerConfig configures the runner backend and image settings.
 type RunnerConfig struct {
-	Runtime                  string            `mapstructure:"runtime" yaml:"runtime"`
-	Image                    string            `mapstructure:"image" yaml:"image"`
-	ContainerScope           string            `mapstructure:"container_scope" yaml:"container_scope"`
-	SockDir                  string            `mapstructure:"sock_dir" yaml:"sock_dir"`
-	RepoRoot                 string            `mapstructure:"repo_root" yaml:"repo_root"`
-	HostRepoRoot             string            `mapstructure:"host_repo_root" yaml:"host_repo_root"`
-	HostStateDir             string            `mapstructure:"host_state_dir" yaml:"host_state_dir"`
-	SocketPath               string            `mapstructure:"socket_path" yaml:"socket_path"`
-	Binary                   string            `mapstructure:"binary" yaml:"binary"`
-	Args                     []string          `mapstructure:"args" yaml:"args"`
-	Env                      map[string]string `mapstructure:"env" yaml:"env"`
+	Runtime        string            `mapstructure:"runtime" yaml:"runtime"`
+	Image          string            `mapstructure:"image" yaml:"image"`
+	ContainerScope string            `mapstructure:"container_scope" yaml:"container_scope"`
+	SockDir        string            `mapstructure:"sock_dir" yaml:"sock_dir"`
+	RepoRoot       string            `mapstructure:"repo_root" yaml:"repo_root"`
+	HostRepoRoot   string            `mapstructure:"host_repo_root" yaml:"host_repo_root"`
+	HostStateDir   string            `mapstructure:"host_state_dir" yaml:"host_state_dir"`
+	SocketPath     string            `mapstructure:"socket_path" yaml:"socket_path"`
+	Binary         string            `mapstructure:"binary" yaml:"binary"`
+	Args           []string          `mapstructure:"args" yaml:"args"`
+	Env            map[string]string `mapstructure:"env" yaml:"env"`
+	// ExtraMounts are host directories bind-mounted into every runner
+	// container, such as repos kept outside repo_root or a shared dataset.
+	// Users may have more; see auth.User.RunnerMounts.
+	ExtraMounts []RunnerMount `mapstructure:"extra_mounts" yaml:"extra_mounts"`
+	// CodexFlags are appended to every codex exec run, followed by the
+	// CodexFlagsByModel entry of the run's model.
+	CodexFlags               []string          `mapstructure:"codex_flags" yaml:"codex_flags"`
+	CodexFlagsByModel        []ModelCodexFlags `mapstructure:"codex_flags_by_model" yaml:"codex_flags_by_model"`
 	GitSSHDebug              bool              `mapstructure:"git_ssh_debug" yaml:"git_ssh_debug"`
 	ExecNice                 int               `mapstructure:"exec_nice" yaml:"exec_nice"`
 	CommandNice              int               `mapstructure:"command_nice" yaml:"command_nice"`
 	IdleTimeout              int               `mapstructure:"idle_timeout_hours" yaml:"idle_timeout_hours"`
 	KeepaliveIntervalSeconds int               `mapstructure:"keepalive_interval_seconds" yaml:"keepalive_interval_seconds"`
 	KeepaliveMisses          int               `mapstructure:"keepalive_misses" yaml:"keepalive_misses"`
+	CloseParallelism         int               `mapstructure:"close_parallelism" yaml:"close_parallelism"`
 	Podman                   PodmanConfig      `mapstructure:"podman" yaml:"podman"`
 	Containerd               ContainerdConfig  `mapstructure:"containerd" yaml:"containerd"`
 	BuildKit                 BuildKitConfig    `mapstructure:"buildkit" yaml:"buildkit"`
 	BuildTimeout             int               `mapstructure:"build_timeout_minutes" yaml:"build_timeout_minutes"`
 	PullTimeout              int               `mapstructure:"pull_timeout_minutes" yaml:"pull_timeout_minutes"`
-	Limits                   RunnerLimits      `mapstructure:"limits" yaml:"limits"`
+	// StopGracePeriodSeconds is how long /stop waits after SIGTERM before
+	// sending SIGKILL to what is still running.
+	StopGracePeriodSeconds int `mapstructure:"stop_grace_period_seconds" yaml:"stop_grace_period_seconds"`
+	// StreamReattachAttempts is how often a codex run whose event stream
+	// broke is re-attached before the tab gives up on it; 0 never tries.
+	StreamReattachAttempts int `mapstructure:"stream_reattach_attempts" yaml:"stream_reattach_attempts"`
+	// LogCaptureBytes sizes the in-memory capture of each runner
+	// container's stdout and stderr (containerd only); LogCaptureTotalBytes
+	// caps all captures together, 0 disables the cap.
+	LogCaptureBytes      int            `mapstructure:"log_capture_bytes" yaml:"log_capture_bytes"`
+	LogCaptureTotalBytes int            `mapstructure:"log_capture_total_bytes" yaml:"log_capture_total_bytes"`
+	Limits               RunnerLimits   `mapstructure:"limits" yaml:"limits"`
+	Security             RunnerSecurity `mapstructure:"security" yaml:"security"`
+	Network              RunnerNetwork  `mapstructure:"network" yaml:"network"`
 }
 
 // HTTPConfig configures the HTTP server.
@@ -73,26 +205,119 @@ type HTTPConfig struct {
 	BasePath           string `mapstructure:"base_path" yaml:"base_path"`
 	InitialBufferLines int    `mapstructure:"initial_buffer_lines" yaml:"initial_buffer_lines"`
 	UIMaxBufferLines   int    `mapstructure:"ui_max_buffer_lines" yaml:"ui_max_buffer_lines"`
+	// TrustedProxies are the IPs or CIDRs of reverse proxies whose
+	// X-Forwarded-For header is believed.
+	TrustedProxies []string `mapstructure:"trusted_proxies" yaml:"trusted_proxies"`
 }
 
 // SSHConfig configures the SSH server.
 type SSHConfig struct {
-	Addr         string `mapstructure:"addr" yaml:"addr"`
-	HostKeyPath  string `mapstructure:"host_key_path" yaml:"host_key_path"`
-	KeyStorePath string `mapstructure:"key_store_path" yaml:"key_store_path"`
-	KeyDir       string `mapstructure:"key_dir" yaml:"key_dir"`
-	AgentDir     string `mapstructure:"agent_dir" yaml:"agent_dir"`
+	Addr        string `mapstructure:"addr" yaml:"addr"`
+	HostKeyPath string `mapstructure:"host_key_path" yaml:"host_key_path"`
+	// HostKeys are more host key files served next to HostKeyPath, e.g. an
+	// RSA key for older clients. Relative paths are resolved under
+	// state_dir/ssh; missing keys are generated at first start (RSA when
+	// the file name contains "rsa", ed25519 otherwise).
+	HostKeys     []string `mapstructure:"host_keys" yaml:"host_keys"`
+	KeyStorePath string   `mapstructure:"key_store_path" yaml:"key_store_path"`
+	KeyDir       string   `mapstructure:"key_dir" yaml:"key_dir"`
+	AgentDir     string   `mapstructure:"agent_dir" yaml:"agent_dir"`
+	// BannerFile is shown before authentication; MOTDFile is rendered with
+	// {{.User}} and {{.Version}} when a session starts. Both are optional.
+	BannerFile string `mapstructure:"banner_file" yaml:"banner_file"`
+	MOTDFile   string `mapstructure:"motd_file" yaml:"motd_file"`
+	// PromptTemplate renders the input line prefix from {{.User}},
+	// {{.Repo}}, {{.Branch}}, {{.Model}} and {{.Tab}}.
+	PromptTemplate string `mapstructure:"prompt_template" yaml:"prompt_template"`
+	// ShowTabNumbers prefixes tab names in the tab bar with the numbers
+	// Alt+1 … Alt+0 and /tab jump to.
+	ShowTabNumbers bool `mapstructure:"show_tab_numbers" yaml:"show_tab_numbers"`
 }
 
 // AuthConfig configures auth storage and seed users.
 type AuthConfig struct {
 	UserFile  string     `mapstructure:"user_file" yaml:"user_file"`
 	SeedUsers []SeedUser `mapstructure:"seed_users" yaml:"seed_users"`
+	// Failed login throttling: backoff starts after BackoffAfter failures
+	// within FailureWindowMinutes and LockoutAfter failures lock the user or
+	// source address out for LockoutMinutes. LockoutFile persists the state.
+	LockoutFile          string `mapstructure:"lockout_file" yaml:"lockout_file"`
+	BackoffAfter         int    `mapstructure:"backoff_after" yaml:"backoff_after"`
+	LockoutAfter         int    `mapstructure:"lockout_after" yaml:"lockout_after"`
+	FailureWindowMinutes int    `mapstructure:"failure_window_minutes" yaml:"failure_window_minutes"`
+	LockoutMinutes       int    `mapstructure:"lockout_minutes" yaml:"lockout_minutes"`
+	// LDAP replaces the users file passwords with binds against a
+	// directory when its URL is set.
+	LDAP LDAPConfig `mapstructure:"ldap" yaml:"ldap"`
+	// OIDC adds device-code logins to the web UI when its issuer is set.
+	OIDC OIDCConfig `mapstructure:"oidc" yaml:"oidc"`
+	// Groups maps LDAP groups and OIDC group claims to capabilities.
+	Groups GroupsConfig `mapstructure:"groups" yaml:"groups"`
+	// AutoProvision creates unknown users on their first SSH login when a
+	// trusted source lists their public key.
+	AutoProvision AutoProvisionConfig `mapstructure:"auto_provision" yaml:"auto_provision"`
+}
+
+// AutoProvisionConfig selects the trusted key source of automatic user
+// provisioning: the published keys of GitHubOrg members, or an
+// authorized_keys list at AuthorizedKeysURL whose comments name the users,
+// refetched every RefreshMinutes.
+type AutoProvisionConfig struct {
+	Enabled           bool   `mapstructure:"enabled" yaml:"enabled"`
+	GitHubOrg         string `mapstructure:"github_org" yaml:"github_org"`
+	GitHubToken       string `mapstructure:"github_token" yaml:"github_token"`
+	AuthorizedKeysURL string `mapstructure:"authorized_keys_url" yaml:"authorized_keys_url"`
+	RefreshMinutes    int    `mapstructure:"refresh_minutes" yaml:"refresh_minutes"`
+}
+
+// LDAPConfig configures password logins against an LDAP directory. The
+// user filter contains %s for the escaped username.
+type LDAPConfig struct {
+	URL                string `mapstructure:"url" yaml:"url"`
+	BindDN             string `mapstructure:"bind_dn" yaml:"bind_dn"`
+	BindPassword       string `mapstructure:"bind_password" yaml:"bind_password"`
+	BaseDN             string `mapstructure:"base_dn" yaml:"base_dn"`
+	UserFilter         string `mapstructure:"user_filter" yaml:"user_filter"`
+	GroupAttribute     string `mapstructure:"group_attribute" yaml:"group_attribute"`
+	StartTLS           bool   `mapstructure:"start_tls" yaml:"start_tls"`
+	CAFile             string `mapstructure:"ca_file" yaml:"ca_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	TimeoutSeconds     int    `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`
+}
+
+// OIDCConfig configures web UI logins through an OpenID Connect provider
+// with the device authorization grant.
+type OIDCConfig struct {
+	Issuer        string     `mapstructure:"issuer" yaml:"issuer"`
+	ClientID      string     `mapstructure:"client_id" yaml:"client_id"`
+	ClientSecret  string     `mapstructure:"client_secret" yaml:"client_secret"`
+	Scopes        []string   `mapstructure:"scopes" yaml:"scopes"`
+	UsernameClaim string     `mapstructure:"username_claim" yaml:"username_claim"`
+	GroupsClaim   string     `mapstructure:"groups_claim" yaml:"groups_claim"`
+	Users         []OIDCUser `mapstructure:"users" yaml:"users"`
+	AllowUnmapped bool       `mapstructure:"allow_unmapped" yaml:"allow_unmapped"`
 }
 
-// LoggingConfig controls audit logging behavior.
+// OIDCUser maps a value of the OIDC username claim to a centaurx user.
+type OIDCUser struct {
+	Claim string `mapstructure:"claim" yaml:"claim"`
+	User  string `mapstructure:"user" yaml:"user"`
+}
+
+// GroupsConfig lists the groups, by DN or name, whose members get a
+// capability.
+type GroupsConfig struct {
+	Admin    []string `mapstructure:"admin" yaml:"admin"`
+	ReadOnly []string `mapstructure:"read_only" yaml:"read_only"`
+}
+
+// LoggingConfig controls audit logging behavior and the in-memory server
+// log admins read with /serverlog.
 type LoggingConfig struct {
 	DisableAuditTrails bool `mapstructure:"disable_audit_trails" yaml:"disable_audit_trails"`
+	// ServerLogLines is how many log entries /serverlog can show; 0 turns
+	// the in-memory log off.
+	ServerLogLines int `mapstructure:"server_log_lines" yaml:"server_log_lines"`
 }
 
 // SeedUser seeds a user record in the auth store.
@@ -125,6 +350,40 @@ type RunnerLimits struct {
 	MemoryPercent int `mapstructure:"memory_percent" yaml:"memory_percent"`
 }
 
+// RunnerSecurity configures hardening options for runner containers. The
+// zero value keeps the runtime defaults.
+type RunnerSecurity struct {
+	NoNewPrivileges bool `mapstructure:"no_new_privileges" yaml:"no_new_privileges"`
+	// SeccompProfile is empty for the runtime default, "default",
+	// "unconfined" or the path to a JSON profile.
+	SeccompProfile   string   `mapstructure:"seccomp_profile" yaml:"seccomp_profile"`
+	DropCapabilities []string `mapstructure:"drop_capabilities" yaml:"drop_capabilities"`
+	// Network is empty for the runtime default, "host" or "none".
+	Network string `mapstructure:"network" yaml:"network"`
+}
+
+// ModelCodexFlags lists extra codex exec flags for runs with one model.
+type ModelCodexFlags struct {
+	Model string   `mapstructure:"model" yaml:"model"`
+	Flags []string `mapstructure:"flags" yaml:"flags"`
+}
+
+// RunnerMount bind-mounts a host directory into runner containers. Host is
+// the path as seen by centaurx and the container runtime.
+type RunnerMount struct {
+	Host      string `mapstructure:"host" yaml:"host"`
+	Container string `mapstructure:"container" yaml:"container"`
+	ReadOnly  bool   `mapstructure:"read_only" yaml:"read_only"`
+}
+
+// RunnerNetwork configures outbound network access of runner containers.
+type RunnerNetwork struct {
+	// AllowedHosts turns on egress control when non-empty: containers run
+	// without a network and reach only these "host:port" and CIDR entries
+	// through a filtering proxy.
+	AllowedHosts []string `mapstructure:"allowed_hosts" yaml:"allowed_hosts"`
+}
+
 // DefaultConfig returns a config with sensible defaults.
 func DefaultConfig() (Config, error) {
 	home, err := os.UserHomeDir()
@@ -146,7 +405,37 @@ func DefaultConfig() (Config, error) {
 			Allowed: []string{"gpt-5.2-codex", "gpt-5.1-codex-max", "gpt-5.1-codex-mini"},
 		},
 		Service: ServiceConfig{
-			BufferMaxLines: schema.DefaultBufferMaxLines,
+			BufferMaxLines:       schema.DefaultBufferMaxLines,
+			HistoryMax:           schema.DefaultHistoryMax,
+			GlobalHistoryMax:     schema.DefaultGlobalHistoryMax,
+			ClosedTabTTLHours:    int(schema.DefaultClosedTabTTL / time.Hour),
+			GitSummaryTTLSeconds: int(schema.DefaultGitSummaryTTL / time.Second),
+			GitSummaryRemotesMax: schema.DefaultGitSummaryRemotesMax,
+			Onboarding:           true,
+		},
+		Prompts: PromptsConfig{
+			MaxBytes:  schema.DefaultPromptMaxBytes,
+			WarnBytes: schema.DefaultPromptWarnBytes,
+		},
+		Repos: ReposConfig{
+			ConcurrentRuns: string(schema.ConcurrentRunsReject),
+		},
+		UI: UIConfig{
+			TimeFormat: timefmt.DefaultLayout,
+		},
+		Summaries: SummariesConfig{
+			Time:          schema.DefaultSummaryTime,
+			Model:         string(schema.DefaultSummaryModel),
+			MaxInputBytes: schema.DefaultSummaryInputMax,
+		},
+		Usage: UsageConfig{
+			WarnBelowPercent: []int{20, 5},
+		},
+		Budgets: BudgetsConfig{
+			WarnPercent: schema.DefaultTokenBudgetWarnPercent,
+		},
+		Batch: BatchConfig{
+			Parallelism: schema.DefaultBatchParallelism,
 		},
 		Runner: RunnerConfig{
 			Runtime:                  "podman",
@@ -160,18 +449,32 @@ func DefaultConfig() (Config, error) {
 			Binary:                   "codex",
 			Args:                     []string{},
 			Env:                      map[string]string{},
+			CodexFlags:               []string{},
+			ExtraMounts:              []RunnerMount{},
+			CodexFlagsByModel:        []ModelCodexFlags{},
 			GitSSHDebug:              false,
 			ExecNice:                 10,
 			CommandNice:              5,
 			IdleTimeout:              8,
 			KeepaliveIntervalSeconds: 10,
 			KeepaliveMisses:          3,
+			CloseParallelism:         8,
+			StopGracePeriodSeconds:   int(schema.DefaultStopGracePeriod / time.Second),
+			StreamReattachAttempts:   schema.DefaultStreamReattachAttempts,
 			BuildTimeout:             20,
 			PullTimeout:              5,
+			LogCaptureBytes:          128 * 1024,
+			LogCaptureTotalBytes:     64 * 1024 * 1024,
 			Limits: RunnerLimits{
 				CPUPercent:    70,
 				MemoryPercent: 70,
 			},
+			Security: RunnerSecurity{
+				DropCapabilities: []string{},
+			},
+			Network: RunnerNetwork{
+				AllowedHosts: []string{},
+			},
 			Podman: PodmanConfig{
 				Address:    fmt.Sprintf("unix://%s", filepath.Join(runtimeDir, "podman", "podman.sock")),
 				UserNSMode: "keep-id",
@@ -193,20 +496,47 @@ func DefaultConfig() (Config, error) {
 			BasePath:           "",
 			InitialBufferLines: 200,
 			UIMaxBufferLines:   2000,
+			TrustedProxies:     []string{},
 		},
 		SSH: SSHConfig{
-			Addr:         ":27422",
-			HostKeyPath:  filepath.Join(home, ".centaurx", "ssh_host_key"),
-			KeyStorePath: filepath.Join(stateDir, "ssh", "keys.bundle"),
-			KeyDir:       filepath.Join(stateDir, "ssh", "keys"),
-			AgentDir:     filepath.Join(stateDir, "ssh", "agent"),
+			Addr:           ":27422",
+			HostKeyPath:    filepath.Join(home, ".centaurx", "ssh_host_key"),
+			HostKeys:       []string{},
+			KeyStorePath:   filepath.Join(stateDir, "ssh", "keys.bundle"),
+			KeyDir:         filepath.Join(stateDir, "ssh", "keys"),
+			AgentDir:       filepath.Join(stateDir, "ssh", "agent"),
+			PromptTemplate: sshprompt.DefaultTemplate,
 		},
 		Auth: AuthConfig{
-			UserFile:  filepath.Join(stateDir, "users.json"),
-			SeedUsers: []SeedUser{},
+			UserFile:             filepath.Join(stateDir, "users.json"),
+			SeedUsers:            []SeedUser{},
+			LockoutFile:          filepath.Join(stateDir, "lockouts.json"),
+			BackoffAfter:         3,
+			LockoutAfter:         10,
+			FailureWindowMinutes: 15,
+			LockoutMinutes:       15,
+			LDAP: LDAPConfig{
+				UserFilter:     "(uid=%s)",
+				GroupAttribute: "memberOf",
+				TimeoutSeconds: 10,
+			},
+			OIDC: OIDCConfig{
+				Scopes:        []string{"openid", "profile"},
+				UsernameClaim: "sub",
+				GroupsClaim:   "groups",
+				Users:         []OIDCUser{},
+			},
+			Groups: GroupsConfig{
+				Admin:    []string{},
+				ReadOnly: []string{},
+			},
+			AutoProvision: AutoProvisionConfig{
+				RefreshMinutes: 15,
+			},
 		},
 		Logging: LoggingConfig{
 			DisableAuditTrails: false,
+			ServerLogLines:     1000,
 		},
 	}, nil
 }
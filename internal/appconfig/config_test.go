@@ -11,3 +11,14 @@ func TestDefaultConfigGitSSHDebug(t *testing.T) {
 		t.Fatalf("expected git ssh debug to default false")
 	}
 }
+
+func TestConfigThemesDir(t *testing.T) {
+	cfg := Config{StateDir: "/cx/state"}
+	if got := cfg.ThemesDir(); got != "/cx/state/themes" {
+		t.Fatalf("expected themes dir under state dir, got %q", got)
+	}
+	cfg.UI.ThemesDir = "/etc/centaurx/themes"
+	if got := cfg.ThemesDir(); got != "/etc/centaurx/themes" {
+		t.Fatalf("expected configured themes dir, got %q", got)
+	}
+}
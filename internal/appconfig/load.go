@@ -1,14 +1,21 @@
 package appconfig
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
+
+	"pkt.systems/centaurx/internal/sshprompt"
+	"pkt.systems/centaurx/internal/timefmt"
+	"pkt.systems/centaurx/schema"
 )
 
 // Load reads configuration from the provided path. If path is empty, uses DefaultConfigPath.
@@ -35,6 +42,28 @@ func Load(path string) (Config, error) {
 	v.SetDefault("models.default", cfg.Models.Default)
 	v.SetDefault("models.allowed", cfg.Models.Allowed)
 	v.SetDefault("service.buffer_max_lines", cfg.Service.BufferMaxLines)
+	v.SetDefault("service.history_max", cfg.Service.HistoryMax)
+	v.SetDefault("service.global_history_max", cfg.Service.GlobalHistoryMax)
+	v.SetDefault("service.closed_tab_ttl_hours", cfg.Service.ClosedTabTTLHours)
+	v.SetDefault("service.git_summary_ttl_seconds", cfg.Service.GitSummaryTTLSeconds)
+	v.SetDefault("service.git_summary_remotes_max", cfg.Service.GitSummaryRemotesMax)
+	v.SetDefault("service.onboarding", cfg.Service.Onboarding)
+	v.SetDefault("prompts.max_bytes", cfg.Prompts.MaxBytes)
+	v.SetDefault("prompts.warn_bytes", cfg.Prompts.WarnBytes)
+	v.SetDefault("repos.concurrent_runs", cfg.Repos.ConcurrentRuns)
+	v.SetDefault("repos.skip_preflight", cfg.Repos.SkipPreflight)
+	v.SetDefault("ui.time_format", cfg.UI.TimeFormat)
+	v.SetDefault("ui.timezone", cfg.UI.Timezone)
+	v.SetDefault("summaries.enabled", cfg.Summaries.Enabled)
+	v.SetDefault("summaries.time", cfg.Summaries.Time)
+	v.SetDefault("summaries.model", cfg.Summaries.Model)
+	v.SetDefault("summaries.max_input_bytes", cfg.Summaries.MaxInputBytes)
+	v.SetDefault("usage.warn_below_percent", cfg.Usage.WarnBelowPercent)
+	v.SetDefault("usage.block_below_percent", cfg.Usage.BlockBelowPercent)
+	v.SetDefault("budgets.daily_tokens_per_user", cfg.Budgets.DailyTokensPerUser)
+	v.SetDefault("budgets.warn_percent", cfg.Budgets.WarnPercent)
+	v.SetDefault("batch.parallelism", cfg.Batch.Parallelism)
+	v.SetDefault("ui.themes_dir", cfg.UI.ThemesDir)
 	v.SetDefault("runner.runtime", cfg.Runner.Runtime)
 	v.SetDefault("runner.image", cfg.Runner.Image)
 	v.SetDefault("runner.container_scope", cfg.Runner.ContainerScope)
@@ -46,16 +75,29 @@ func Load(path string) (Config, error) {
 	v.SetDefault("runner.binary", cfg.Runner.Binary)
 	v.SetDefault("runner.args", cfg.Runner.Args)
 	v.SetDefault("runner.env", cfg.Runner.Env)
+	v.SetDefault("runner.codex_flags", cfg.Runner.CodexFlags)
+	v.SetDefault("runner.extra_mounts", cfg.Runner.ExtraMounts)
+	v.SetDefault("runner.codex_flags_by_model", cfg.Runner.CodexFlagsByModel)
 	v.SetDefault("runner.git_ssh_debug", cfg.Runner.GitSSHDebug)
 	v.SetDefault("runner.exec_nice", cfg.Runner.ExecNice)
 	v.SetDefault("runner.command_nice", cfg.Runner.CommandNice)
 	v.SetDefault("runner.idle_timeout_hours", cfg.Runner.IdleTimeout)
 	v.SetDefault("runner.keepalive_interval_seconds", cfg.Runner.KeepaliveIntervalSeconds)
 	v.SetDefault("runner.keepalive_misses", cfg.Runner.KeepaliveMisses)
+	v.SetDefault("runner.close_parallelism", cfg.Runner.CloseParallelism)
+	v.SetDefault("runner.stop_grace_period_seconds", cfg.Runner.StopGracePeriodSeconds)
+	v.SetDefault("runner.stream_reattach_attempts", cfg.Runner.StreamReattachAttempts)
 	v.SetDefault("runner.build_timeout_minutes", cfg.Runner.BuildTimeout)
 	v.SetDefault("runner.pull_timeout_minutes", cfg.Runner.PullTimeout)
+	v.SetDefault("runner.log_capture_bytes", cfg.Runner.LogCaptureBytes)
+	v.SetDefault("runner.log_capture_total_bytes", cfg.Runner.LogCaptureTotalBytes)
 	v.SetDefault("runner.limits.cpu_percent", cfg.Runner.Limits.CPUPercent)
 	v.SetDefault("runner.limits.memory_percent", cfg.Runner.Limits.MemoryPercent)
+	v.SetDefault("runner.security.no_new_privileges", cfg.Runner.Security.NoNewPrivileges)
+	v.SetDefault("runner.security.seccomp_profile", cfg.Runner.Security.SeccompProfile)
+	v.SetDefault("runner.security.drop_capabilities", cfg.Runner.Security.DropCapabilities)
+	v.SetDefault("runner.security.network", cfg.Runner.Security.Network)
+	v.SetDefault("runner.network.allowed_hosts", cfg.Runner.Network.AllowedHosts)
 	v.SetDefault("runner.podman.address", cfg.Runner.Podman.Address)
 	v.SetDefault("runner.podman.userns_mode", cfg.Runner.Podman.UserNSMode)
 	v.SetDefault("runner.containerd.address", cfg.Runner.Containerd.Address)
@@ -69,14 +111,51 @@ func Load(path string) (Config, error) {
 	v.SetDefault("http.base_path", cfg.HTTP.BasePath)
 	v.SetDefault("http.initial_buffer_lines", cfg.HTTP.InitialBufferLines)
 	v.SetDefault("http.ui_max_buffer_lines", cfg.HTTP.UIMaxBufferLines)
+	v.SetDefault("http.trusted_proxies", cfg.HTTP.TrustedProxies)
 	v.SetDefault("ssh.addr", cfg.SSH.Addr)
 	v.SetDefault("ssh.host_key_path", cfg.SSH.HostKeyPath)
+	v.SetDefault("ssh.host_keys", cfg.SSH.HostKeys)
 	v.SetDefault("ssh.key_store_path", cfg.SSH.KeyStorePath)
 	v.SetDefault("ssh.key_dir", cfg.SSH.KeyDir)
 	v.SetDefault("ssh.agent_dir", cfg.SSH.AgentDir)
+	v.SetDefault("ssh.banner_file", cfg.SSH.BannerFile)
+	v.SetDefault("ssh.motd_file", cfg.SSH.MOTDFile)
+	v.SetDefault("ssh.prompt_template", cfg.SSH.PromptTemplate)
+	v.SetDefault("ssh.show_tab_numbers", cfg.SSH.ShowTabNumbers)
 	v.SetDefault("auth.user_file", cfg.Auth.UserFile)
 	v.SetDefault("auth.seed_users", cfg.Auth.SeedUsers)
+	v.SetDefault("auth.lockout_file", cfg.Auth.LockoutFile)
+	v.SetDefault("auth.backoff_after", cfg.Auth.BackoffAfter)
+	v.SetDefault("auth.lockout_after", cfg.Auth.LockoutAfter)
+	v.SetDefault("auth.failure_window_minutes", cfg.Auth.FailureWindowMinutes)
+	v.SetDefault("auth.lockout_minutes", cfg.Auth.LockoutMinutes)
+	v.SetDefault("auth.ldap.url", cfg.Auth.LDAP.URL)
+	v.SetDefault("auth.ldap.bind_dn", cfg.Auth.LDAP.BindDN)
+	v.SetDefault("auth.ldap.bind_password", cfg.Auth.LDAP.BindPassword)
+	v.SetDefault("auth.ldap.base_dn", cfg.Auth.LDAP.BaseDN)
+	v.SetDefault("auth.ldap.user_filter", cfg.Auth.LDAP.UserFilter)
+	v.SetDefault("auth.ldap.group_attribute", cfg.Auth.LDAP.GroupAttribute)
+	v.SetDefault("auth.ldap.start_tls", cfg.Auth.LDAP.StartTLS)
+	v.SetDefault("auth.ldap.ca_file", cfg.Auth.LDAP.CAFile)
+	v.SetDefault("auth.ldap.insecure_skip_verify", cfg.Auth.LDAP.InsecureSkipVerify)
+	v.SetDefault("auth.ldap.timeout_seconds", cfg.Auth.LDAP.TimeoutSeconds)
+	v.SetDefault("auth.oidc.issuer", cfg.Auth.OIDC.Issuer)
+	v.SetDefault("auth.oidc.client_id", cfg.Auth.OIDC.ClientID)
+	v.SetDefault("auth.oidc.client_secret", cfg.Auth.OIDC.ClientSecret)
+	v.SetDefault("auth.oidc.scopes", cfg.Auth.OIDC.Scopes)
+	v.SetDefault("auth.oidc.username_claim", cfg.Auth.OIDC.UsernameClaim)
+	v.SetDefault("auth.oidc.groups_claim", cfg.Auth.OIDC.GroupsClaim)
+	v.SetDefault("auth.oidc.users", cfg.Auth.OIDC.Users)
+	v.SetDefault("auth.oidc.allow_unmapped", cfg.Auth.OIDC.AllowUnmapped)
+	v.SetDefault("auth.groups.admin", cfg.Auth.Groups.Admin)
+	v.SetDefault("auth.groups.read_only", cfg.Auth.Groups.ReadOnly)
+	v.SetDefault("auth.auto_provision.enabled", cfg.Auth.AutoProvision.Enabled)
+	v.SetDefault("auth.auto_provision.github_org", cfg.Auth.AutoProvision.GitHubOrg)
+	v.SetDefault("auth.auto_provision.github_token", cfg.Auth.AutoProvision.GitHubToken)
+	v.SetDefault("auth.auto_provision.authorized_keys_url", cfg.Auth.AutoProvision.AuthorizedKeysURL)
+	v.SetDefault("auth.auto_provision.refresh_minutes", cfg.Auth.AutoProvision.RefreshMinutes)
 	v.SetDefault("logging.disable_audit_trails", cfg.Logging.DisableAuditTrails)
+	v.SetDefault("logging.server_log_lines", cfg.Logging.ServerLogLines)
 
 	configLoaded := false
 	if err := v.ReadInConfig(); err != nil {
@@ -145,9 +224,125 @@ func Load(path string) (Config, error) {
 	if err := validateHTTPConfig(cfg.HTTP); err != nil {
 		return Config{}, err
 	}
+	if err := validateUIConfig(cfg.UI); err != nil {
+		return Config{}, err
+	}
+	if _, err := time.Parse(schema.SummaryTimeLayout, cfg.Summaries.Time); err != nil {
+		return Config{}, fmt.Errorf("summaries.time: invalid time %q: use HH:MM", cfg.Summaries.Time)
+	}
+	if err := validateUsageConfig(cfg.Usage); err != nil {
+		return Config{}, err
+	}
+	if err := validatePromptsConfig(cfg.Prompts); err != nil {
+		return Config{}, err
+	}
+	switch schema.ConcurrentRunsPolicy(cfg.Repos.ConcurrentRuns) {
+	case schema.ConcurrentRunsReject, schema.ConcurrentRunsQueue:
+	default:
+		return Config{}, fmt.Errorf("repos.concurrent_runs: invalid policy %q: use reject or queue", cfg.Repos.ConcurrentRuns)
+	}
+	if cfg.Service.GitSummaryTTLSeconds < 1 {
+		return Config{}, fmt.Errorf("service.git_summary_ttl_seconds: %d must be at least 1", cfg.Service.GitSummaryTTLSeconds)
+	}
+	if cfg.Service.GitSummaryRemotesMax < 1 {
+		return Config{}, fmt.Errorf("service.git_summary_remotes_max: %d must be at least 1", cfg.Service.GitSummaryRemotesMax)
+	}
+	if cfg.Runner.StopGracePeriodSeconds < 1 {
+		return Config{}, fmt.Errorf("runner.stop_grace_period_seconds: %d must be at least 1", cfg.Runner.StopGracePeriodSeconds)
+	}
+	if cfg.Runner.StreamReattachAttempts < 0 {
+		return Config{}, fmt.Errorf("runner.stream_reattach_attempts: %d must not be negative", cfg.Runner.StreamReattachAttempts)
+	}
+	if err := validateAuthConfig(cfg.Auth); err != nil {
+		return Config{}, err
+	}
+	if cfg.Logging.ServerLogLines < 0 {
+		return Config{}, fmt.Errorf("logging.server_log_lines: %d must not be negative", cfg.Logging.ServerLogLines)
+	}
+	if cfg.Budgets.DailyTokensPerUser < 0 {
+		return Config{}, fmt.Errorf("budgets.daily_tokens_per_user: %d must not be negative", cfg.Budgets.DailyTokensPerUser)
+	}
+	if cfg.Budgets.WarnPercent < 0 || cfg.Budgets.WarnPercent > 100 {
+		return Config{}, fmt.Errorf("budgets.warn_percent: %d must be between 0 and 100", cfg.Budgets.WarnPercent)
+	}
+	if cfg.Batch.Parallelism < 1 {
+		return Config{}, fmt.Errorf("batch.parallelism: %d must be at least 1", cfg.Batch.Parallelism)
+	}
+	if _, err := sshprompt.Parse(cfg.SSH.PromptTemplate); err != nil {
+		return Config{}, fmt.Errorf("ssh.prompt_template: %w", err)
+	}
 	return cfg, nil
 }
 
+func validateAuthConfig(cfg AuthConfig) error {
+	if cfg.LDAP.TimeoutSeconds < 1 {
+		return fmt.Errorf("auth.ldap.timeout_seconds: %d must be at least 1", cfg.LDAP.TimeoutSeconds)
+	}
+	if cfg.LDAP.URL != "" {
+		if strings.TrimSpace(cfg.LDAP.BaseDN) == "" {
+			return errors.New("auth.ldap.base_dn: required with auth.ldap.url")
+		}
+		if !strings.Contains(cfg.LDAP.UserFilter, "%s") {
+			return fmt.Errorf("auth.ldap.user_filter: %q must contain %%s for the username", cfg.LDAP.UserFilter)
+		}
+	}
+	if cfg.OIDC.Issuer != "" && strings.TrimSpace(cfg.OIDC.ClientID) == "" {
+		return errors.New("auth.oidc.client_id: required with auth.oidc.issuer")
+	}
+	for _, user := range cfg.OIDC.Users {
+		if strings.TrimSpace(user.Claim) == "" {
+			return errors.New("auth.oidc.users: claim is required")
+		}
+		if err := schema.ValidateUserID(schema.UserID(user.User)); err != nil {
+			return fmt.Errorf("auth.oidc.users: %q is not a valid username", user.User)
+		}
+	}
+	if cfg.AutoProvision.RefreshMinutes < 1 {
+		return fmt.Errorf("auth.auto_provision.refresh_minutes: %d must be at least 1", cfg.AutoProvision.RefreshMinutes)
+	}
+	if cfg.AutoProvision.Enabled {
+		if (strings.TrimSpace(cfg.AutoProvision.GitHubOrg) == "") == (strings.TrimSpace(cfg.AutoProvision.AuthorizedKeysURL) == "") {
+			return errors.New("auth.auto_provision: exactly one of github_org and authorized_keys_url is required")
+		}
+		if cfg.LDAP.URL != "" {
+			return errors.New("auth.auto_provision: cannot be combined with auth.ldap.url")
+		}
+	}
+	return nil
+}
+
+func validateUsageConfig(cfg UsageConfig) error {
+	for _, percent := range cfg.WarnBelowPercent {
+		if percent <= 0 || percent > 100 {
+			return fmt.Errorf("usage.warn_below_percent: %d must be between 1 and 100", percent)
+		}
+	}
+	if cfg.BlockBelowPercent < 0 || cfg.BlockBelowPercent > 100 {
+		return fmt.Errorf("usage.block_below_percent: %d must be between 0 and 100", cfg.BlockBelowPercent)
+	}
+	return nil
+}
+
+func validatePromptsConfig(cfg PromptsConfig) error {
+	if cfg.MaxBytes < 1 {
+		return fmt.Errorf("prompts.max_bytes: %d must be at least 1", cfg.MaxBytes)
+	}
+	if cfg.WarnBytes < 1 || cfg.WarnBytes > cfg.MaxBytes {
+		return fmt.Errorf("prompts.warn_bytes: %d must be between 1 and prompts.max_bytes (%d)", cfg.WarnBytes, cfg.MaxBytes)
+	}
+	return nil
+}
+
+func validateUIConfig(cfg UIConfig) error {
+	if err := timefmt.ValidateLayout(cfg.TimeFormat); err != nil {
+		return fmt.Errorf("ui.time_format: %w", err)
+	}
+	if _, err := timefmt.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("ui.timezone: %w", err)
+	}
+	return nil
+}
+
 func validateHTTPConfig(cfg HTTPConfig) error {
 	baseURL := strings.TrimSpace(cfg.BaseURL)
 	if baseURL != "" {
@@ -165,6 +360,15 @@ func validateHTTPConfig(cfg HTTPConfig) error {
 			return fmt.Errorf("http.base_path must not include query or fragment")
 		}
 	}
+	for _, proxy := range cfg.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			return fmt.Errorf("http.trusted_proxies: %q is not an IP or CIDR", proxy)
+		}
+	}
 	return nil
 }
 
@@ -184,11 +388,21 @@ func expandConfigEnv(cfg *Config) {
 	cfg.Runner.Containerd.Address = expandEnv(cfg.Runner.Containerd.Address)
 	cfg.Runner.BuildKit.Address = expandEnv(cfg.Runner.BuildKit.Address)
 	cfg.SSH.HostKeyPath = expandEnv(cfg.SSH.HostKeyPath)
+	for i, path := range cfg.SSH.HostKeys {
+		path = expandEnv(path)
+		if path != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.StateDir, "ssh", path)
+		}
+		cfg.SSH.HostKeys[i] = path
+	}
 	cfg.SSH.KeyStorePath = expandEnv(cfg.SSH.KeyStorePath)
 	cfg.SSH.KeyDir = expandEnv(cfg.SSH.KeyDir)
 	cfg.SSH.AgentDir = expandEnv(cfg.SSH.AgentDir)
+	cfg.SSH.BannerFile = expandEnv(cfg.SSH.BannerFile)
+	cfg.SSH.MOTDFile = expandEnv(cfg.SSH.MOTDFile)
 	cfg.HTTP.SessionStorePath = expandEnv(cfg.HTTP.SessionStorePath)
 	cfg.Auth.UserFile = expandEnv(cfg.Auth.UserFile)
+	cfg.Auth.LockoutFile = expandEnv(cfg.Auth.LockoutFile)
 }
 
 func expandEnv(value string) string {
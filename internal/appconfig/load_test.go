@@ -3,6 +3,7 @@ package appconfig
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -67,6 +68,245 @@ http:
 	}
 }
 
+func TestLoadRejectsInvalidUIConfig(t *testing.T) {
+	for _, tc := range []struct {
+		ui   string
+		want string
+	}{
+		{"  timezone: Europe/Atlantis", `ui.timezone: unknown time zone "Europe/Atlantis"`},
+		{`  time_format: "2006-01-02"`, `ui.time_format: time format "2006-01-02" does not show the time of day`},
+	} {
+		path := writeConfig(t, `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+ui:
+`+tc.ui+`
+`)
+		if _, err := Load(path); err == nil || err.Error() != tc.want {
+			t.Fatalf("expected %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidUsageConfig(t *testing.T) {
+	for _, tc := range []struct {
+		usage string
+		want  string
+	}{
+		{"  warn_below_percent: [20, 0]", "usage.warn_below_percent: 0 must be between 1 and 100"},
+		{"  block_below_percent: 150", "usage.block_below_percent: 150 must be between 0 and 100"},
+		{"budgets:\n  daily_tokens_per_user: -1", "budgets.daily_tokens_per_user: -1 must not be negative"},
+		{"budgets:\n  warn_percent: 101", "budgets.warn_percent: 101 must be between 0 and 100"},
+	} {
+		path := writeConfig(t, `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+usage:
+`+tc.usage+`
+`)
+		if _, err := Load(path); err == nil || err.Error() != tc.want {
+			t.Fatalf("expected %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidPromptsConfig(t *testing.T) {
+	for _, tc := range []struct {
+		prompts string
+		want    string
+	}{
+		{"  max_bytes: 0", "prompts.max_bytes: 0 must be at least 1"},
+		{"  max_bytes: 1024\n  warn_bytes: 2048", "prompts.warn_bytes: 2048 must be between 1 and prompts.max_bytes (1024)"},
+	} {
+		path := writeConfig(t, `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+prompts:
+`+tc.prompts+`
+`)
+		if _, err := Load(path); err == nil || err.Error() != tc.want {
+			t.Fatalf("expected %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestLoadReposConcurrentRuns(t *testing.T) {
+	const base = `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+`
+	cfg, err := Load(writeConfig(t, base))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Repos.ConcurrentRuns != "reject" {
+		t.Fatalf("expected reject by default, got %q", cfg.Repos.ConcurrentRuns)
+	}
+	cfg, err = Load(writeConfig(t, base+"repos:\n  concurrent_runs: queue\n"))
+	if err != nil || cfg.Repos.ConcurrentRuns != "queue" {
+		t.Fatalf("expected queue, got %q %v", cfg.Repos.ConcurrentRuns, err)
+	}
+	want := `repos.concurrent_runs: invalid policy "wait": use reject or queue`
+	if _, err := Load(writeConfig(t, base+"repos:\n  concurrent_runs: wait\n")); err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+}
+
+func TestLoadResolvesSSHHostKeys(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+config_version: 4
+state_dir: /state
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  host_keys: [ssh_host_rsa_key, /etc/centaurx/ssh_host_ecdsa_key]
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := []string{"/state/ssh/ssh_host_rsa_key", "/etc/centaurx/ssh_host_ecdsa_key"}
+	if len(cfg.SSH.HostKeys) != len(want) || cfg.SSH.HostKeys[0] != want[0] || cfg.SSH.HostKeys[1] != want[1] {
+		t.Fatalf("expected host keys %v, got %v", want, cfg.SSH.HostKeys)
+	}
+}
+
+func TestLoadRejectsInvalidAuthConfig(t *testing.T) {
+	for _, tc := range []struct {
+		auth string
+		want string
+	}{
+		{"  ldap:\n    url: ldap://ldap.example.com", "auth.ldap.base_dn: required with auth.ldap.url"},
+		{"  ldap:\n    url: ldap://ldap.example.com\n    base_dn: dc=example,dc=com\n    user_filter: (uid=alice)", `auth.ldap.user_filter: "(uid=alice)" must contain %s for the username`},
+		{"  ldap:\n    timeout_seconds: 0", "auth.ldap.timeout_seconds: 0 must be at least 1"},
+		{"  oidc:\n    issuer: https://id.example.com", "auth.oidc.client_id: required with auth.oidc.issuer"},
+		{"  oidc:\n    users:\n      - claim: \"1234\"\n        user: Admin", `auth.oidc.users: "Admin" is not a valid username`},
+		{"  auto_provision:\n    enabled: true", "auth.auto_provision: exactly one of github_org and authorized_keys_url is required"},
+		{"  auto_provision:\n    refresh_minutes: 0", "auth.auto_provision.refresh_minutes: 0 must be at least 1"},
+	} {
+		path := writeConfig(t, `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+auth:
+`+tc.auth+`
+`)
+		if _, err := Load(path); err == nil || err.Error() != tc.want {
+			t.Fatalf("expected %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidPromptTemplate(t *testing.T) {
+	path := writeConfig(t, `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+  prompt_template: "{{.User}@{{.Repo}}> "
+`)
+	_, err := Load(path)
+	if err == nil || !strings.HasPrefix(err.Error(), "ssh.prompt_template: template: prompt:1:") {
+		t.Fatalf("expected the template parse error, got %v", err)
+	}
+}
+
+func TestLoadCodexFlags(t *testing.T) {
+	path := writeConfig(t, `
+config_version: 4
+runner:
+  runtime: podman
+  image: demo
+  sock_dir: /socks
+  repo_root: /repos
+  podman:
+    address: unix:///run/user/1000/podman/podman.sock
+  codex_flags: ["--sandbox", "workspace-write"]
+  codex_flags_by_model:
+    - model: gpt-5.1-codex-mini
+      flags: ["-c", "tools.web_search=false"]
+ssh:
+  key_store_path: /state/ssh/keys.bundle
+  key_dir: /state/ssh/keys
+  agent_dir: /state/ssh/agent
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !slices.Equal(cfg.Runner.CodexFlags, []string{"--sandbox", "workspace-write"}) {
+		t.Fatalf("unexpected codex_flags %v", cfg.Runner.CodexFlags)
+	}
+	byModel := cfg.Runner.CodexFlagsByModel
+	if len(byModel) != 1 || byModel[0].Model != "gpt-5.1-codex-mini" || !slices.Equal(byModel[0].Flags, []string{"-c", "tools.web_search=false"}) {
+		t.Fatalf("unexpected codex_flags_by_model %+v", byModel)
+	}
+}
+
 func TestExpandEnv(t *testing.T) {
 	t.Setenv("FOO", "bar")
 	value := expandEnv("$FOO/$UID/$GID/$MISSING")
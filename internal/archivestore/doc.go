@@ -0,0 +1,3 @@
+// Package archivestore holds generated repo archives on disk behind
+// one-time, expiring download tokens.
+package archivestore
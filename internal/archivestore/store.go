@@ -0,0 +1,185 @@
+package archivestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// DefaultTTL is how long a saved archive stays downloadable.
+const DefaultTTL = 15 * time.Minute
+
+// ErrNotFound indicates an unknown, used or expired download token.
+var ErrNotFound = errors.New("archive not found")
+
+// Store saves archives and hands each out once by token.
+type Store interface {
+	// Save writes an archive to disk and registers a download token for it.
+	Save(ctx context.Context, req SaveRequest) (SaveResponse, error)
+	// Open redeems a token. The token is consumed and the file is removed
+	// from disk once the returned download is closed.
+	Open(token string) (Download, error)
+}
+
+// SaveRequest describes an archive to save.
+type SaveRequest struct {
+	UserID schema.UserID
+	// Write produces the archive contents and returns its download name.
+	Write func(w io.Writer) (string, error)
+}
+
+// SaveResponse reports the saved archive.
+type SaveResponse struct {
+	Token     string
+	Size      int64
+	ExpiresAt time.Time
+}
+
+// Download is a redeemed archive.
+type Download struct {
+	UserID  schema.UserID
+	Name    string
+	Size    int64
+	Content *os.File
+}
+
+// Close releases the archive file.
+func (d Download) Close() error {
+	if d.Content == nil {
+		return nil
+	}
+	return d.Content.Close()
+}
+
+type entry struct {
+	userID    schema.UserID
+	name      string
+	path      string
+	size      int64
+	expiresAt time.Time
+}
+
+type store struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// New returns a store keeping archives in dir. An empty dir creates a
+// private temp directory and a non-positive ttl uses DefaultTTL.
+func New(dir string, ttl time.Duration) (Store, error) {
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "centaurx-archives-")
+		if err != nil {
+			return nil, err
+		}
+		dir = tmp
+	} else if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &store{
+		dir:     dir,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]entry),
+	}, nil
+}
+
+func (s *store) Save(ctx context.Context, req SaveRequest) (SaveResponse, error) {
+	if req.Write == nil {
+		return SaveResponse{}, errors.New("archive writer is required")
+	}
+	if err := ctx.Err(); err != nil {
+		return SaveResponse{}, err
+	}
+	s.sweep()
+	file, err := os.CreateTemp(s.dir, "archive-*")
+	if err != nil {
+		return SaveResponse{}, err
+	}
+	path := file.Name()
+	name, writeErr := req.Write(file)
+	closeErr := file.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		_ = os.Remove(path)
+		return SaveResponse{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		_ = os.Remove(path)
+		return SaveResponse{}, err
+	}
+	token, err := newToken()
+	if err != nil {
+		_ = os.Remove(path)
+		return SaveResponse{}, err
+	}
+	expiresAt := s.now().Add(s.ttl)
+	s.mu.Lock()
+	s.entries[token] = entry{
+		userID:    req.UserID,
+		name:      name,
+		path:      path,
+		size:      info.Size(),
+		expiresAt: expiresAt,
+	}
+	s.mu.Unlock()
+	return SaveResponse{Token: token, Size: info.Size(), ExpiresAt: expiresAt}, nil
+}
+
+func (s *store) Open(token string) (Download, error) {
+	s.sweep()
+	s.mu.Lock()
+	item, ok := s.entries[token]
+	delete(s.entries, token)
+	s.mu.Unlock()
+	if !ok || token == "" {
+		return Download{}, ErrNotFound
+	}
+	file, err := os.Open(item.path)
+	// The open descriptor keeps the contents readable after the unlink.
+	_ = os.Remove(item.path)
+	if err != nil {
+		return Download{}, fmt.Errorf("open archive: %w", err)
+	}
+	return Download{UserID: item.userID, Name: item.name, Size: item.size, Content: file}, nil
+}
+
+// sweep removes expired archives.
+func (s *store) sweep() {
+	now := s.now()
+	var expired []string
+	s.mu.Lock()
+	for token, item := range s.entries {
+		if now.After(item.expiresAt) {
+			expired = append(expired, item.path)
+			delete(s.entries, token)
+		}
+	}
+	s.mu.Unlock()
+	for _, path := range expired {
+		_ = os.Remove(path)
+	}
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,88 @@
+package archivestore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func saveString(t *testing.T, s Store, content string) SaveResponse {
+	t.Helper()
+	resp, err := s.Save(context.Background(), SaveRequest{
+		UserID: "alice",
+		Write: func(w io.Writer) (string, error) {
+			_, err := io.WriteString(w, content)
+			return "demo.tar.gz", err
+		},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	return resp
+}
+
+func TestStoreTokensAreSingleUse(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	resp := saveString(t, s, "archive")
+	if resp.Token == "" || resp.Size != int64(len("archive")) {
+		t.Fatalf("unexpected save response: %+v", resp)
+	}
+
+	download, err := s.Open(resp.Token)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	content, err := io.ReadAll(download.Content)
+	_ = download.Close()
+	if err != nil || string(content) != "archive" || download.Name != "demo.tar.gz" || download.UserID != "alice" {
+		t.Fatalf("unexpected download %+v: %q (%v)", download, content, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected archive file removed, found %d", len(files))
+	}
+	if _, err := s.Open(resp.Token); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected reused token to be rejected, got %v", err)
+	}
+}
+
+func TestStoreExpiresArchives(t *testing.T) {
+	dir := t.TempDir()
+	created, err := New(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	s := created.(*store)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	resp := saveString(t, s, "archive")
+
+	now = now.Add(2 * time.Minute)
+	if _, err := s.Open(resp.Token); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected expired token to be rejected, got %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected expired archive removed, found %d", len(files))
+	}
+}
+
+func TestStoreDiscardsFailedWrites(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	_, err = s.Save(context.Background(), SaveRequest{Write: func(io.Writer) (string, error) { return "", errors.New("boom") }})
+	if err == nil {
+		t.Fatalf("expected write error")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected partial archive removed, found %d", len(files))
+	}
+}
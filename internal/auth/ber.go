@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tag classes and the constructed bit, combined with a tag number into
+// the single identifier octet LDAP needs: every tag number it uses is below
+// 31.
+const (
+	berClassApplication = 0x40
+	berClassContext     = 0x80
+	berConstructed      = 0x20
+)
+
+// Universal tags used by LDAP.
+const (
+	berTagBoolean     = 0x01
+	berTagInteger     = 0x02
+	berTagOctetString = 0x04
+	berTagEnumerated  = 0x0a
+	berTagSequence    = berConstructed | 0x10
+	berTagSet         = berConstructed | 0x11
+)
+
+// berMaxLength bounds the length of one element, so a broken peer cannot
+// make us allocate arbitrary amounts of memory.
+const berMaxLength = 16 << 20
+
+var errBERTruncated = errors.New("ber: truncated element")
+
+// berElement is a decoded BER element; value holds the raw contents.
+type berElement struct {
+	tag   byte
+	value []byte
+}
+
+// berEncode returns the element with tag wrapping the concatenated parts.
+func berEncode(tag byte, parts ...[]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += len(part)
+	}
+	out := make([]byte, 0, size+6)
+	out = append(out, tag)
+	out = appendBERLength(out, size)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func appendBERLength(out []byte, size int) []byte {
+	if size < 0x80 {
+		return append(out, byte(size))
+	}
+	var buf [4]byte
+	n := 0
+	for v := size; v > 0; v >>= 8 {
+		n++
+	}
+	for i := n - 1; i >= 0; i-- {
+		buf[n-1-i] = byte(size >> (8 * i))
+	}
+	out = append(out, 0x80|byte(n))
+	return append(out, buf[:n]...)
+}
+
+func berString(tag byte, value string) []byte {
+	return berEncode(tag, []byte(value))
+}
+
+func berInt(tag byte, value int) []byte {
+	// Two's complement, minimal length.
+	var buf []byte
+	v := int64(value)
+	for {
+		buf = append([]byte{byte(v)}, buf...)
+		v >>= 8
+		if (v == 0 && buf[0]&0x80 == 0) || (v == -1 && buf[0]&0x80 != 0) {
+			break
+		}
+	}
+	return berEncode(tag, buf)
+}
+
+func berBool(value bool) []byte {
+	if value {
+		return berEncode(berTagBoolean, []byte{0xff})
+	}
+	return berEncode(berTagBoolean, []byte{0x00})
+}
+
+// readBERElement reads one complete element from r.
+func readBERElement(r *bufio.Reader) (berElement, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	size, err := readBERLength(r)
+	if err != nil {
+		return berElement{}, err
+	}
+	value := make([]byte, size)
+	if _, err := io.ReadFull(r, value); err != nil {
+		if errors.Is(err, io.EOF) {
+			return berElement{}, errBERTruncated
+		}
+		return berElement{}, err
+	}
+	return berElement{tag: tag, value: value}, nil
+}
+
+func readBERLength(r io.ByteReader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, errBERTruncated
+		}
+		return 0, err
+	}
+	if first < 0x80 {
+		return int(first), nil
+	}
+	n := int(first & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, fmt.Errorf("ber: unsupported length encoding 0x%02x", first)
+	}
+	size := 0
+	for range n {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, errBERTruncated
+			}
+			return 0, err
+		}
+		size = size<<8 | int(b)
+	}
+	if size > berMaxLength {
+		return 0, fmt.Errorf("ber: element of %d bytes is too large", size)
+	}
+	return size, nil
+}
+
+// parseBERElements decodes the concatenated elements in data, such as the
+// contents of a sequence.
+func parseBERElements(data []byte) ([]berElement, error) {
+	var out []berElement
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errBERTruncated
+		}
+		tag := data[0]
+		reader := &sliceByteReader{data: data[1:]}
+		size, err := readBERLength(reader)
+		if err != nil {
+			return nil, err
+		}
+		rest := reader.data
+		if size > len(rest) {
+			return nil, errBERTruncated
+		}
+		out = append(out, berElement{tag: tag, value: rest[:size]})
+		data = rest[size:]
+	}
+	return out, nil
+}
+
+// children decodes the contents of a constructed element.
+func (e berElement) children() ([]berElement, error) {
+	if e.tag&berConstructed == 0 {
+		return nil, fmt.Errorf("ber: tag 0x%02x is not constructed", e.tag)
+	}
+	return parseBERElements(e.value)
+}
+
+func (e berElement) int() (int, error) {
+	if len(e.value) == 0 || len(e.value) > 4 {
+		return 0, fmt.Errorf("ber: invalid integer of %d bytes", len(e.value))
+	}
+	v := int32(int8(e.value[0]))
+	for _, b := range e.value[1:] {
+		v = v<<8 | int32(b)
+	}
+	return int(v), nil
+}
+
+func (e berElement) string() string {
+	return string(e.value)
+}
+
+type sliceByteReader struct {
+	data []byte
+}
+
+func (r *sliceByteReader) ReadByte() (byte, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b, nil
+}
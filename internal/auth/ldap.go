@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// LDAP defaults used for zero LDAPConfig values.
+const (
+	DefaultLDAPUserFilter     = "(uid=%s)"
+	DefaultLDAPGroupAttribute = "memberOf"
+	DefaultLDAPTimeout        = 10 * time.Second
+)
+
+// LDAPConfig configures password logins against an LDAP directory.
+type LDAPConfig struct {
+	// URL is ldap://host[:port] or ldaps://host[:port].
+	URL string
+	// BindDN and BindPassword authenticate the search for the user entry;
+	// empty searches anonymously.
+	BindDN       string
+	BindPassword string
+	// BaseDN is where the user search starts.
+	BaseDN string
+	// UserFilter finds the entry of a login; %s is replaced by the escaped
+	// username.
+	UserFilter string
+	// GroupAttribute lists the groups of a user entry, matched against the
+	// GroupMapping.
+	GroupAttribute string
+	// StartTLS upgrades ldap:// connections before binding.
+	StartTLS bool
+	// CAFile verifies the server certificate against these CAs instead of
+	// the system pool.
+	CAFile             string
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+// LDAPProvider verifies passwords with an LDAP bind as the user's entry.
+// Passwords and second factors are managed by the directory; login public
+// keys still come from the users file.
+type LDAPProvider struct {
+	cfg       LDAPConfig
+	groups    GroupMapping
+	tlsConfig *tls.Config
+	keys      *Store
+	throttle  *Throttle
+	log       pslog.Logger
+
+	mu   sync.Mutex
+	caps map[string]schema.Capabilities
+}
+
+// NewLDAPProvider validates cfg and returns a provider. keys, when set,
+// answers login public key checks.
+func NewLDAPProvider(cfg LDAPConfig, groups GroupMapping, keys *Store, logger pslog.Logger) (*LDAPProvider, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, errors.New("ldap url is required")
+	}
+	if strings.TrimSpace(cfg.BaseDN) == "" {
+		return nil, errors.New("ldap base dn is required")
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = DefaultLDAPUserFilter
+	}
+	if !strings.Contains(cfg.UserFilter, "%s") {
+		return nil, errors.New("ldap user filter must contain %s")
+	}
+	if _, err := parseLDAPFilter(fmt.Sprintf(cfg.UserFilter, "user")); err != nil {
+		return nil, err
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = DefaultLDAPGroupAttribute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultLDAPTimeout
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ldap ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ldap ca file: no certificates in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if logger != nil {
+		logger = logger.With("ldap_url", cfg.URL)
+	}
+	return &LDAPProvider{
+		cfg:       cfg,
+		groups:    groups,
+		tlsConfig: tlsConfig,
+		keys:      keys,
+		log:       logger,
+		caps:      make(map[string]schema.Capabilities),
+	}, nil
+}
+
+// SetThrottle enables failed login throttling for AuthenticateFrom.
+func (p *LDAPProvider) SetThrottle(throttle *Throttle) {
+	p.throttle = throttle
+}
+
+// AuthenticateFrom implements Provider. totpCode is ignored.
+func (p *LDAPProvider) AuthenticateFrom(source, username, password, totpCode string) error {
+	return throttled(p.throttle, source, username, func() error {
+		return p.Authenticate(context.Background(), username, password)
+	})
+}
+
+// Authenticate looks up the entry of username and binds as it with
+// password. The groups of the entry set the account's capabilities.
+func (p *LDAPProvider) Authenticate(ctx context.Context, username, password string) error {
+	username, err := validateUsername(username)
+	if err != nil {
+		return err
+	}
+	// An empty password would be an unauthenticated bind, which servers
+	// accept for any DN.
+	if password == "" {
+		return errInvalidCredentials
+	}
+	conn, err := dialLDAP(ctx, p.cfg, p.tlsConfig)
+	if err != nil {
+		p.warn("ldap connect failed", "err", err)
+		return fmt.Errorf("ldap: %w", err)
+	}
+	defer conn.close()
+	if p.cfg.BindDN != "" {
+		if err := conn.bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+			p.warn("ldap service bind failed", "err", err)
+			return fmt.Errorf("ldap service bind failed: %w", err)
+		}
+	}
+	filter, err := parseLDAPFilter(fmt.Sprintf(p.cfg.UserFilter, escapeFilterValue(username)))
+	if err != nil {
+		return err
+	}
+	entries, err := conn.search(p.cfg.BaseDN, filter, []string{p.cfg.GroupAttribute}, 2)
+	if err != nil {
+		p.warn("ldap user search failed", "user", username, "err", err)
+		return err
+	}
+	if len(entries) != 1 {
+		return errInvalidCredentials
+	}
+	entry := entries[0]
+	if err := conn.bind(entry.dn, password); err != nil {
+		return err
+	}
+	caps := p.groups.Capabilities(entry.values(p.cfg.GroupAttribute))
+	p.mu.Lock()
+	p.caps[username] = caps
+	p.mu.Unlock()
+	return nil
+}
+
+// ValidateTOTPFrom implements Provider. Second factors are the directory's
+// business, so public key logins go on to a password prompt instead.
+func (p *LDAPProvider) ValidateTOTPFrom(string, string, string) error {
+	return schema.ErrManagedExternally
+}
+
+// ChangePassword implements Provider; passwords are changed in the
+// directory.
+func (p *LDAPProvider) ChangePassword(string, string, string, string) error {
+	return schema.ErrManagedExternally
+}
+
+// ChangePasswordFrom implements Provider like ChangePassword.
+func (p *LDAPProvider) ChangePasswordFrom(string, string, string, string, string) error {
+	return schema.ErrManagedExternally
+}
+
+// HasLoginPubKey implements Provider with the keys of the users file.
+// Directory users without an entry there have no keys.
+func (p *LDAPProvider) HasLoginPubKey(userID schema.UserID, key ssh.PublicKey) (bool, error) {
+	if p.keys == nil {
+		return false, nil
+	}
+	return p.keys.HasLoginPubKey(userID, key)
+}
+
+// ExternalCredentials implements Provider.
+func (p *LDAPProvider) ExternalCredentials() bool {
+	return true
+}
+
+// Capabilities implements Provider with the groups seen at the user's last
+// successful bind.
+func (p *LDAPProvider) Capabilities(username string) schema.Capabilities {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.caps[username]
+}
+
+func (p *LDAPProvider) warn(msg string, keyvals ...any) {
+	if p.log != nil {
+		p.log.Warn(msg, keyvals...)
+	}
+}
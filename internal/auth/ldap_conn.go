@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LDAP protocol operations (RFC 4511 section 4.2 onwards).
+const (
+	ldapBindRequest       = berClassApplication | berConstructed | 0
+	ldapBindResponse      = berClassApplication | berConstructed | 1
+	ldapUnbindRequest     = berClassApplication | 2
+	ldapSearchRequest     = berClassApplication | berConstructed | 3
+	ldapSearchEntry       = berClassApplication | berConstructed | 4
+	ldapSearchDone        = berClassApplication | berConstructed | 5
+	ldapSearchReference   = berClassApplication | berConstructed | 19
+	ldapExtendedRequest   = berClassApplication | berConstructed | 23
+	ldapExtendedResponse  = berClassApplication | berConstructed | 24
+	ldapSimpleAuth        = berClassContext | 0
+	ldapExtendedName      = berClassContext | 0
+	ldapScopeWholeSubtree = 2
+	ldapNeverDerefAliases = 0
+	ldapStartTLSOID       = "1.3.6.1.4.1.1466.20037"
+)
+
+// LDAP result codes we tell apart.
+const (
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+)
+
+// ldapResultError is a non-success LDAPResult.
+type ldapResultError struct {
+	code    int
+	message string
+}
+
+func (e *ldapResultError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.code, e.message)
+}
+
+// ldapEntry is one search result.
+type ldapEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// values returns the values of attr, whose name LDAP compares without case.
+func (e ldapEntry) values(attr string) []string {
+	for name, values := range e.attributes {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+// ldapConn is a minimal LDAPv3 client: simple bind, search and StartTLS,
+// enough to verify a login against a directory.
+type ldapConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+}
+
+// dialLDAP connects to cfg.URL, upgrading ldap:// connections with StartTLS
+// when configured. The deadline of ctx, or cfg.Timeout, bounds every later
+// operation on the connection.
+func dialLDAP(ctx context.Context, cfg LDAPConfig, tlsConfig *tls.Config) (*ldapConn, error) {
+	parsed, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap url: %w", err)
+	}
+	host := parsed.Host
+	secure := false
+	switch strings.ToLower(parsed.Scheme) {
+	case "ldap":
+		if parsed.Port() == "" {
+			host = net.JoinHostPort(parsed.Hostname(), "389")
+		}
+	case "ldaps":
+		secure = true
+		if parsed.Port() == "" {
+			host = net.JoinHostPort(parsed.Hostname(), "636")
+		}
+	default:
+		return nil, fmt.Errorf("ldap url: unsupported scheme %q", parsed.Scheme)
+	}
+	deadline := time.Now().Add(cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(deadline)
+	if secure {
+		tlsConn := tls.Client(conn, tlsConfigFor(tlsConfig, parsed.Hostname()))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	c := &ldapConn{conn: conn, reader: bufio.NewReader(conn)}
+	if cfg.StartTLS && !secure {
+		if err := c.startTLS(ctx, tlsConfigFor(tlsConfig, parsed.Hostname())); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func tlsConfigFor(base *tls.Config, serverName string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = serverName
+	}
+	return cfg
+}
+
+func (c *ldapConn) close() {
+	_ = c.send(berEncode(ldapUnbindRequest))
+	_ = c.conn.Close()
+}
+
+func (c *ldapConn) startTLS(ctx context.Context, tlsConfig *tls.Config) error {
+	if err := c.send(berEncode(ldapExtendedRequest, berString(ldapExtendedName, ldapStartTLSOID))); err != nil {
+		return err
+	}
+	op, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if op.tag != ldapExtendedResponse {
+		return fmt.Errorf("ldap starttls: unexpected response 0x%02x", op.tag)
+	}
+	if err := checkLDAPResult(op); err != nil {
+		return fmt.Errorf("ldap starttls: %w", err)
+	}
+	tlsConn := tls.Client(c.conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// bind authenticates the connection as dn. Wrong credentials are reported
+// as errInvalidCredentials.
+func (c *ldapConn) bind(dn, password string) error {
+	request := berEncode(ldapBindRequest,
+		berInt(berTagInteger, 3),
+		berString(berTagOctetString, dn),
+		berString(ldapSimpleAuth, password),
+	)
+	if err := c.send(request); err != nil {
+		return err
+	}
+	op, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if op.tag != ldapBindResponse {
+		return fmt.Errorf("ldap bind: unexpected response 0x%02x", op.tag)
+	}
+	if err := checkLDAPResult(op); err != nil {
+		var result *ldapResultError
+		if errors.As(err, &result) && result.code == ldapResultInvalidCredentials {
+			return errInvalidCredentials
+		}
+		return fmt.Errorf("ldap bind: %w", err)
+	}
+	return nil
+}
+
+// search returns the entries below baseDN matching filter, with attrs.
+func (c *ldapConn) search(baseDN string, filter ldapFilter, attrs []string, sizeLimit int) ([]ldapEntry, error) {
+	attrList := make([][]byte, 0, len(attrs))
+	for _, attr := range attrs {
+		attrList = append(attrList, berString(berTagOctetString, attr))
+	}
+	request := berEncode(ldapSearchRequest,
+		berString(berTagOctetString, baseDN),
+		berInt(berTagEnumerated, ldapScopeWholeSubtree),
+		berInt(berTagEnumerated, ldapNeverDerefAliases),
+		berInt(berTagInteger, sizeLimit),
+		berInt(berTagInteger, 0),
+		berBool(false),
+		filter.encode(),
+		berEncode(berTagSequence, attrList...),
+	)
+	if err := c.send(request); err != nil {
+		return nil, err
+	}
+	var entries []ldapEntry
+	for {
+		op, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case ldapSearchEntry:
+			entry, err := parseLDAPEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case ldapSearchReference:
+			// Referrals to other servers are not followed.
+		case ldapSearchDone:
+			if err := checkLDAPResult(op); err != nil {
+				return nil, fmt.Errorf("ldap search: %w", err)
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("ldap search: unexpected response 0x%02x", op.tag)
+		}
+	}
+}
+
+func (c *ldapConn) send(op []byte) error {
+	c.nextID++
+	_, err := c.conn.Write(berEncode(berTagSequence, berInt(berTagInteger, c.nextID), op))
+	return err
+}
+
+// receive reads the next message for the last request and returns its
+// protocol operation.
+func (c *ldapConn) receive() (berElement, error) {
+	message, err := readBERElement(c.reader)
+	if err != nil {
+		return berElement{}, err
+	}
+	parts, err := message.children()
+	if err != nil {
+		return berElement{}, err
+	}
+	if len(parts) < 2 {
+		return berElement{}, errors.New("ldap: malformed message")
+	}
+	id, err := parts[0].int()
+	if err != nil {
+		return berElement{}, err
+	}
+	if id != c.nextID {
+		return berElement{}, fmt.Errorf("ldap: response to message %d, expected %d", id, c.nextID)
+	}
+	return parts[1], nil
+}
+
+// checkLDAPResult returns the LDAPResult at the start of op as an error
+// unless it reports success.
+func checkLDAPResult(op berElement) error {
+	parts, err := op.children()
+	if err != nil {
+		return err
+	}
+	if len(parts) < 3 {
+		return errors.New("ldap: malformed result")
+	}
+	code, err := parts[0].int()
+	if err != nil {
+		return err
+	}
+	if code == ldapResultSuccess {
+		return nil
+	}
+	return &ldapResultError{code: code, message: parts[2].string()}
+}
+
+func parseLDAPEntry(op berElement) (ldapEntry, error) {
+	parts, err := op.children()
+	if err != nil {
+		return ldapEntry{}, err
+	}
+	if len(parts) < 2 {
+		return ldapEntry{}, errors.New("ldap: malformed search entry")
+	}
+	entry := ldapEntry{dn: parts[0].string(), attributes: make(map[string][]string)}
+	attributes, err := parts[1].children()
+	if err != nil {
+		return ldapEntry{}, err
+	}
+	for _, attribute := range attributes {
+		fields, err := attribute.children()
+		if err != nil || len(fields) < 2 {
+			return ldapEntry{}, errors.New("ldap: malformed attribute")
+		}
+		values, err := fields[1].children()
+		if err != nil {
+			return ldapEntry{}, err
+		}
+		name := fields[0].string()
+		for _, value := range values {
+			entry.attributes[name] = append(entry.attributes[name], value.string())
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLDAPEntry is an entry of fakeLDAPServer; password, when set, lets the
+// entry bind.
+type fakeLDAPEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// fakeLDAPServer is an in-memory LDAP server speaking just enough of the
+// protocol for LDAPProvider: simple bind, subtree search and unbind.
+type fakeLDAPServer struct {
+	listener net.Listener
+	entries  []fakeLDAPEntry
+
+	mu    sync.Mutex
+	binds []string
+}
+
+func newFakeLDAPServer(t *testing.T, entries ...fakeLDAPEntry) *fakeLDAPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &fakeLDAPServer{listener: listener, entries: entries}
+	go server.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (s *fakeLDAPServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// bindDNs returns the DNs of all bind requests so far.
+func (s *fakeLDAPServer) bindDNs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *fakeLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLDAPServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		message, err := readBERElement(reader)
+		if err != nil {
+			return
+		}
+		parts, err := message.children()
+		if err != nil || len(parts) < 2 {
+			return
+		}
+		id, err := parts[0].int()
+		if err != nil {
+			return
+		}
+		op := parts[1]
+		var replies [][]byte
+		switch op.tag {
+		case ldapBindRequest:
+			replies = [][]byte{s.bind(op)}
+		case ldapSearchRequest:
+			replies = s.search(op)
+		case ldapUnbindRequest:
+			return
+		default:
+			replies = [][]byte{ldapResult(ldapExtendedResponse, 2, "unsupported operation")}
+		}
+		for _, reply := range replies {
+			if _, err := conn.Write(berEncode(berTagSequence, berInt(berTagInteger, id), reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *fakeLDAPServer) bind(op berElement) []byte {
+	fields, err := op.children()
+	if err != nil || len(fields) < 3 {
+		return ldapResult(ldapBindResponse, 2, "malformed bind")
+	}
+	dn := fields[1].string()
+	password := fields[2].string()
+	s.mu.Lock()
+	s.binds = append(s.binds, dn)
+	s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.dn, dn) && entry.password != "" && entry.password == password {
+			return ldapResult(ldapBindResponse, ldapResultSuccess, "")
+		}
+	}
+	return ldapResult(ldapBindResponse, ldapResultInvalidCredentials, "invalid credentials")
+}
+
+func (s *fakeLDAPServer) search(op berElement) [][]byte {
+	fields, err := op.children()
+	if err != nil || len(fields) < 8 {
+		return [][]byte{ldapResult(ldapSearchDone, 2, "malformed search")}
+	}
+	base := strings.ToLower(fields[0].string())
+	var replies [][]byte
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), base) {
+			continue
+		}
+		ok, err := fakeFilterMatch(fields[6], entry)
+		if err != nil {
+			return [][]byte{ldapResult(ldapSearchDone, 2, err.Error())}
+		}
+		if !ok {
+			continue
+		}
+		var attributes [][]byte
+		for name, values := range entry.attributes {
+			encoded := make([][]byte, 0, len(values))
+			for _, value := range values {
+				encoded = append(encoded, berString(berTagOctetString, value))
+			}
+			attributes = append(attributes, berEncode(berTagSequence, berString(berTagOctetString, name), berEncode(berTagSet, encoded...)))
+		}
+		replies = append(replies, berEncode(ldapSearchEntry, berString(berTagOctetString, entry.dn), berEncode(berTagSequence, attributes...)))
+	}
+	return append(replies, ldapResult(ldapSearchDone, ldapResultSuccess, ""))
+}
+
+func ldapResult(tag byte, code int, message string) []byte {
+	return berEncode(tag, berInt(berTagEnumerated, code), berString(berTagOctetString, ""), berString(berTagOctetString, message))
+}
+
+// fakeFilterMatch evaluates the BER filter against entry. Only the filters
+// user searches need are supported.
+func fakeFilterMatch(filter berElement, entry fakeLDAPEntry) (bool, error) {
+	switch filter.tag {
+	case filterAnd, filterOr:
+		children, err := filter.children()
+		if err != nil {
+			return false, err
+		}
+		for _, child := range children {
+			ok, err := fakeFilterMatch(child, entry)
+			if err != nil {
+				return false, err
+			}
+			if filter.tag == filterOr && ok {
+				return true, nil
+			}
+			if filter.tag == filterAnd && !ok {
+				return false, nil
+			}
+		}
+		return filter.tag == filterAnd, nil
+	case filterNot:
+		children, err := filter.children()
+		if err != nil || len(children) != 1 {
+			return false, errors.New("malformed not filter")
+		}
+		ok, err := fakeFilterMatch(children[0], entry)
+		return !ok, err
+	case filterPresent:
+		return len(fakeEntryValues(entry, filter.string())) > 0, nil
+	case filterEquality:
+		fields, err := filter.children()
+		if err != nil || len(fields) != 2 {
+			return false, errors.New("malformed equality filter")
+		}
+		for _, value := range fakeEntryValues(entry, fields[0].string()) {
+			if strings.EqualFold(value, fields[1].string()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, errors.New("unsupported filter")
+	}
+}
+
+func fakeEntryValues(entry fakeLDAPEntry, attr string) []string {
+	for name, values := range entry.attributes {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
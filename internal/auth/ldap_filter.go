@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choices of an LDAP search request (RFC 4511 section 4.5.1).
+const (
+	filterAnd            = berClassContext | berConstructed | 0
+	filterOr             = berClassContext | berConstructed | 1
+	filterNot            = berClassContext | berConstructed | 2
+	filterEquality       = berClassContext | berConstructed | 3
+	filterSubstrings     = berClassContext | berConstructed | 4
+	filterGreaterOrEqual = berClassContext | berConstructed | 5
+	filterLessOrEqual    = berClassContext | berConstructed | 6
+	filterPresent        = berClassContext | 7
+	filterApprox         = berClassContext | berConstructed | 8
+)
+
+// Substring choices within a substrings filter.
+const (
+	substringInitial = berClassContext | 0
+	substringAny     = berClassContext | 1
+	substringFinal   = berClassContext | 2
+)
+
+// ldapFilter is a parsed RFC 4515 search filter.
+type ldapFilter struct {
+	op       byte
+	attr     string
+	value    string
+	children []ldapFilter
+	// initial, any and final are the parts of a substrings filter.
+	initial string
+	any     []string
+	final   string
+}
+
+// escapeFilterValue escapes value for use in a filter string, so a username
+// cannot change the structure of the configured user filter.
+func escapeFilterValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseLDAPFilter parses a filter in the string form of RFC 4515.
+func parseLDAPFilter(text string) (ldapFilter, error) {
+	text = strings.TrimSpace(text)
+	if text != "" && text[0] != '(' {
+		text = "(" + text + ")"
+	}
+	filter, rest, err := parseFilterAt(text)
+	if err != nil {
+		return ldapFilter{}, err
+	}
+	if rest != "" {
+		return ldapFilter{}, fmt.Errorf("ldap filter: unexpected %q after filter", rest)
+	}
+	return filter, nil
+}
+
+func parseFilterAt(text string) (ldapFilter, string, error) {
+	if text == "" || text[0] != '(' {
+		return ldapFilter{}, "", fmt.Errorf("ldap filter: expected ( at %q", text)
+	}
+	text = text[1:]
+	if text == "" {
+		return ldapFilter{}, "", fmt.Errorf("ldap filter: unterminated filter")
+	}
+	var filter ldapFilter
+	switch text[0] {
+	case '&', '|':
+		filter.op = filterAnd
+		if text[0] == '|' {
+			filter.op = filterOr
+		}
+		text = text[1:]
+		for text != "" && text[0] == '(' {
+			child, rest, err := parseFilterAt(text)
+			if err != nil {
+				return ldapFilter{}, "", err
+			}
+			filter.children = append(filter.children, child)
+			text = rest
+		}
+		if len(filter.children) == 0 {
+			return ldapFilter{}, "", fmt.Errorf("ldap filter: empty filter list")
+		}
+	case '!':
+		child, rest, err := parseFilterAt(text[1:])
+		if err != nil {
+			return ldapFilter{}, "", err
+		}
+		filter = ldapFilter{op: filterNot, children: []ldapFilter{child}}
+		text = rest
+	default:
+		end := strings.IndexByte(text, ')')
+		if end < 0 {
+			return ldapFilter{}, "", fmt.Errorf("ldap filter: unterminated item")
+		}
+		item, err := parseFilterItem(text[:end])
+		if err != nil {
+			return ldapFilter{}, "", err
+		}
+		filter = item
+		text = text[end:]
+	}
+	if text == "" || text[0] != ')' {
+		return ldapFilter{}, "", fmt.Errorf("ldap filter: expected )")
+	}
+	return filter, text[1:], nil
+}
+
+func parseFilterItem(item string) (ldapFilter, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return ldapFilter{}, fmt.Errorf("ldap filter: invalid item %q", item)
+	}
+	attr := item[:eq]
+	raw := item[eq+1:]
+	op := byte(filterEquality)
+	switch attr[len(attr)-1] {
+	case '~':
+		op = filterApprox
+	case '>':
+		op = filterGreaterOrEqual
+	case '<':
+		op = filterLessOrEqual
+	}
+	if op != filterEquality {
+		attr = attr[:len(attr)-1]
+	}
+	attr = strings.TrimSpace(attr)
+	if attr == "" {
+		return ldapFilter{}, fmt.Errorf("ldap filter: missing attribute in %q", item)
+	}
+	if op == filterEquality && raw == "*" {
+		return ldapFilter{op: filterPresent, attr: attr}, nil
+	}
+	parts := strings.Split(raw, "*")
+	values := make([]string, len(parts))
+	for i, part := range parts {
+		value, err := unescapeFilterValue(part)
+		if err != nil {
+			return ldapFilter{}, err
+		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		return ldapFilter{op: op, attr: attr, value: values[0]}, nil
+	}
+	if op != filterEquality {
+		return ldapFilter{}, fmt.Errorf("ldap filter: wildcard in %q", item)
+	}
+	filter := ldapFilter{op: filterSubstrings, attr: attr, initial: values[0], final: values[len(values)-1]}
+	for _, value := range values[1 : len(values)-1] {
+		if value != "" {
+			filter.any = append(filter.any, value)
+		}
+	}
+	return filter, nil
+}
+
+func unescapeFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("ldap filter: truncated escape in %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap filter: invalid escape in %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// encode returns the filter in the BER form of a search request.
+func (f ldapFilter) encode() []byte {
+	switch f.op {
+	case filterAnd, filterOr, filterNot:
+		parts := make([][]byte, 0, len(f.children))
+		for _, child := range f.children {
+			parts = append(parts, child.encode())
+		}
+		return berEncode(f.op, parts...)
+	case filterPresent:
+		return berString(filterPresent, f.attr)
+	case filterSubstrings:
+		var subs [][]byte
+		if f.initial != "" {
+			subs = append(subs, berString(substringInitial, f.initial))
+		}
+		for _, value := range f.any {
+			subs = append(subs, berString(substringAny, value))
+		}
+		if f.final != "" {
+			subs = append(subs, berString(substringFinal, f.final))
+		}
+		return berEncode(filterSubstrings, berString(berTagOctetString, f.attr), berEncode(berTagSequence, subs...))
+	default:
+		return berEncode(f.op, berString(berTagOctetString, f.attr), berString(berTagOctetString, f.value))
+	}
+}
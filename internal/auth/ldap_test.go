@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func newTestLDAPProvider(t *testing.T, server *fakeLDAPServer) *LDAPProvider {
+	t.Helper()
+	provider, err := NewLDAPProvider(LDAPConfig{
+		URL:          server.url(),
+		BindDN:       "cn=centaurx,ou=services,dc=example,dc=com",
+		BindPassword: "service-secret",
+		BaseDN:       "ou=people,dc=example,dc=com",
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+	}, GroupMapping{
+		Admin:    []string{"admins"},
+		ReadOnly: []string{"cn=auditors,ou=groups,dc=example,dc=com"},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("new ldap provider: %v", err)
+	}
+	return provider
+}
+
+func testLDAPDirectory(t *testing.T) *fakeLDAPServer {
+	t.Helper()
+	return newFakeLDAPServer(t,
+		fakeLDAPEntry{dn: "cn=centaurx,ou=services,dc=example,dc=com", password: "service-secret"},
+		fakeLDAPEntry{
+			dn:       "uid=alice,ou=people,dc=example,dc=com",
+			password: "alice-secret",
+			attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"alice"},
+				"memberOf":    {"cn=admins,ou=groups,dc=example,dc=com"},
+			},
+		},
+		fakeLDAPEntry{
+			dn:       "uid=bob,ou=people,dc=example,dc=com",
+			password: "bob-secret",
+			attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"bob"},
+				"memberOf":    {"cn=auditors,ou=groups,dc=example,dc=com"},
+			},
+		},
+	)
+}
+
+func TestLDAPProviderAuthenticate(t *testing.T) {
+	server := testLDAPDirectory(t)
+	provider := newTestLDAPProvider(t, server)
+
+	if err := provider.AuthenticateFrom("192.0.2.1", "alice", "alice-secret", ""); err != nil {
+		t.Fatalf("authenticate alice: %v", err)
+	}
+	if got := provider.Capabilities("alice"); got != (schema.Capabilities{Admin: true}) {
+		t.Fatalf("alice capabilities = %+v", got)
+	}
+	want := []string{"cn=centaurx,ou=services,dc=example,dc=com", "uid=alice,ou=people,dc=example,dc=com"}
+	if got := server.bindDNs(); !slices.Equal(got, want) {
+		t.Fatalf("binds = %q, want %q", got, want)
+	}
+
+	if err := provider.AuthenticateFrom("192.0.2.1", "bob", "bob-secret", ""); err != nil {
+		t.Fatalf("authenticate bob: %v", err)
+	}
+	if got := provider.Capabilities("bob"); got != (schema.Capabilities{ReadOnly: true}) {
+		t.Fatalf("bob capabilities = %+v", got)
+	}
+}
+
+func TestLDAPProviderRejectsInvalidCredentials(t *testing.T) {
+	server := testLDAPDirectory(t)
+	provider := newTestLDAPProvider(t, server)
+
+	cases := []struct {
+		name     string
+		user     string
+		password string
+	}{
+		{name: "wrong password", user: "alice", password: "nope"},
+		{name: "unknown user", user: "mallory", password: "alice-secret"},
+		{name: "empty password", user: "alice", password: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := provider.AuthenticateFrom("192.0.2.1", tc.user, tc.password, ""); !errors.Is(err, errInvalidCredentials) {
+				t.Fatalf("expected invalid credentials, got %v", err)
+			}
+		})
+	}
+	if got := provider.Capabilities("alice"); got != (schema.Capabilities{}) {
+		t.Fatalf("failed logins must not grant capabilities, got %+v", got)
+	}
+}
+
+func TestLDAPProviderThrottlesFailures(t *testing.T) {
+	server := testLDAPDirectory(t)
+	provider := newTestLDAPProvider(t, server)
+	throttle, err := NewThrottle(ThrottleConfig{BackoffAfter: 1, LockoutAfter: 2})
+	if err != nil {
+		t.Fatalf("new throttle: %v", err)
+	}
+	provider.SetThrottle(throttle)
+	for range 2 {
+		_ = provider.AuthenticateFrom("192.0.2.1", "alice", "nope", "")
+	}
+	if err := provider.AuthenticateFrom("192.0.2.1", "alice", "alice-secret", ""); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected throttled login, got %v", err)
+	}
+}
+
+func TestLDAPProviderManagesCredentialsExternally(t *testing.T) {
+	provider := newTestLDAPProvider(t, testLDAPDirectory(t))
+	if !provider.ExternalCredentials() {
+		t.Fatalf("expected external credentials")
+	}
+	if err := provider.ChangePassword("alice", "alice-secret", "", "new-secret"); !errors.Is(err, schema.ErrManagedExternally) {
+		t.Fatalf("change password: expected managed externally, got %v", err)
+	}
+	if err := provider.ValidateTOTPFrom("192.0.2.1", "alice", "123456"); !errors.Is(err, schema.ErrManagedExternally) {
+		t.Fatalf("validate totp: expected managed externally, got %v", err)
+	}
+}
+
+func TestNewLDAPProviderValidatesConfig(t *testing.T) {
+	cases := []LDAPConfig{
+		{BaseDN: "dc=example,dc=com"},
+		{URL: "ldap://localhost"},
+		{URL: "ldap://localhost", BaseDN: "dc=example,dc=com", UserFilter: "(uid=alice)"},
+		{URL: "ldap://localhost", BaseDN: "dc=example,dc=com", UserFilter: "(uid=%s"},
+	}
+	for _, cfg := range cases {
+		if _, err := NewLDAPProvider(cfg, GroupMapping{}, nil, nil); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestLDAPFilterEscapesValues(t *testing.T) {
+	filter, err := parseLDAPFilter("(&(objectClass=person)(uid=" + escapeFilterValue("a*)(uid=b") + "))")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if filter.op != filterAnd || len(filter.children) != 2 {
+		t.Fatalf("unexpected filter %+v", filter)
+	}
+	if uid := filter.children[1]; uid.op != filterEquality || uid.value != "a*)(uid=b" {
+		t.Fatalf("escaped value changed the filter: %+v", uid)
+	}
+
+	substrings, err := parseLDAPFilter("cn=ad*min*s")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if substrings.op != filterSubstrings || substrings.initial != "ad" || !slices.Equal(substrings.any, []string{"min"}) || substrings.final != "s" {
+		t.Fatalf("unexpected substrings filter %+v", substrings)
+	}
+	if present, err := parseLDAPFilter("(mail=*)"); err != nil || present.op != filterPresent {
+		t.Fatalf("expected presence filter, got %+v, %v", present, err)
+	}
+}
+
+func TestGroupMappingMatchesDNOrName(t *testing.T) {
+	mapping := GroupMapping{Admin: []string{"Admins"}, ReadOnly: []string{"cn=auditors,ou=groups,dc=example,dc=com"}}
+	if got := mapping.Capabilities([]string{"cn=admins,ou=groups,dc=example,dc=com"}); !got.Admin || got.ReadOnly {
+		t.Fatalf("expected admin by group name, got %+v", got)
+	}
+	if got := mapping.Capabilities([]string{"CN=Auditors,OU=Groups,DC=example,DC=com"}); got.Admin || !got.ReadOnly {
+		t.Fatalf("expected read-only by DN, got %+v", got)
+	}
+	if got := mapping.Capabilities([]string{"developers"}); got != (schema.Capabilities{}) {
+		t.Fatalf("expected no capabilities, got %+v", got)
+	}
+}
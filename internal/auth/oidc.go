@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// OIDC defaults used for zero OIDCConfig values.
+const (
+	DefaultOIDCUsernameClaim = "sub"
+	DefaultOIDCGroupsClaim   = "groups"
+	defaultOIDCPollInterval  = 5 * time.Second
+	defaultOIDCTimeout       = 15 * time.Second
+)
+
+// ErrAuthorizationPending indicates the user has not approved a device login
+// yet; poll again after the interval.
+var ErrAuthorizationPending = errors.New("authorization pending")
+
+// OIDCConfig configures browser logins through an OpenID Connect provider
+// with the OAuth 2.0 device authorization grant (RFC 8628).
+type OIDCConfig struct {
+	// Issuer is the provider URL; its /.well-known/openid-configuration
+	// names the endpoints.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes default to openid and profile.
+	Scopes []string
+	// UsernameClaim names the userinfo claim that identifies the user.
+	// The email claim is only used when email_verified is true.
+	UsernameClaim string
+	// Users maps values of the UsernameClaim to centaurx usernames.
+	Users map[string]string
+	// AllowUnmapped lets a claim value without an entry in Users log in
+	// as the username of the same name, unless that names a local
+	// account.
+	AllowUnmapped bool
+	// GroupsClaim names the userinfo claim matched against the
+	// GroupMapping.
+	GroupsClaim string
+	// HTTPClient defaults to a client with a 15 second timeout.
+	HTTPClient *http.Client
+}
+
+// DeviceAuthorization is a started device login: the user enters UserCode
+// at VerificationURI, or opens VerificationURIComplete, while the server
+// polls with DeviceCode.
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	Interval                time.Duration
+	ExpiresAt               time.Time
+}
+
+// Identity is an account an identity provider vouched for.
+type Identity struct {
+	Username     string
+	Capabilities schema.Capabilities
+}
+
+// OIDCDeviceFlow runs device logins against an OpenID Connect provider.
+type OIDCDeviceFlow struct {
+	cfg    OIDCConfig
+	groups GroupMapping
+	local  *Store
+	now    func() time.Time
+
+	mu        sync.Mutex
+	endpoints *oidcEndpoints
+}
+
+type oidcEndpoints struct {
+	DeviceAuthorization string `json:"device_authorization_endpoint"`
+	Token               string `json:"token_endpoint"`
+	UserInfo            string `json:"userinfo_endpoint"`
+}
+
+// NewOIDCDeviceFlow validates cfg. The provider is contacted on the first
+// login. local, when set, lists the accounts unmapped claim values may not
+// take over.
+func NewOIDCDeviceFlow(cfg OIDCConfig, groups GroupMapping, local *Store) (*OIDCDeviceFlow, error) {
+	if strings.TrimSpace(cfg.Issuer) == "" {
+		return nil, errors.New("oidc issuer is required")
+	}
+	if strings.TrimSpace(cfg.ClientID) == "" {
+		return nil, errors.New("oidc client id is required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile"}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = DefaultOIDCUsernameClaim
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DefaultOIDCGroupsClaim
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultOIDCTimeout}
+	}
+	return &OIDCDeviceFlow{cfg: cfg, groups: groups, local: local, now: time.Now}, nil
+}
+
+// Start asks the provider for a device and user code.
+func (f *OIDCDeviceFlow) Start(ctx context.Context) (DeviceAuthorization, error) {
+	endpoints, err := f.discover(ctx)
+	if err != nil {
+		return DeviceAuthorization{}, err
+	}
+	if endpoints.DeviceAuthorization == "" {
+		return DeviceAuthorization{}, errors.New("oidc provider does not support device authorization")
+	}
+	form := f.clientForm()
+	form.Set("scope", strings.Join(f.cfg.Scopes, " "))
+	var resp struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	if err := f.postForm(ctx, endpoints.DeviceAuthorization, form, &resp); err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("oidc device authorization: %w", err)
+	}
+	if resp.DeviceCode == "" || resp.UserCode == "" || resp.VerificationURI == "" {
+		return DeviceAuthorization{}, errors.New("oidc device authorization: incomplete response")
+	}
+	interval := time.Duration(resp.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultOIDCPollInterval
+	}
+	return DeviceAuthorization{
+		DeviceCode:              resp.DeviceCode,
+		UserCode:                resp.UserCode,
+		VerificationURI:         resp.VerificationURI,
+		VerificationURIComplete: resp.VerificationURIComplete,
+		Interval:                interval,
+		ExpiresAt:               f.now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// Poll exchanges deviceCode for tokens and returns the identity from the
+// userinfo endpoint. It returns ErrAuthorizationPending until the user has
+// approved the login.
+func (f *OIDCDeviceFlow) Poll(ctx context.Context, deviceCode string) (Identity, error) {
+	endpoints, err := f.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	form := f.clientForm()
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	form.Set("device_code", deviceCode)
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := f.postForm(ctx, endpoints.Token, form, &token); err != nil {
+		var oauthErr *oauthError
+		if errors.As(err, &oauthErr) && (oauthErr.Code == "authorization_pending" || oauthErr.Code == "slow_down") {
+			return Identity{}, ErrAuthorizationPending
+		}
+		return Identity{}, fmt.Errorf("oidc token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return Identity{}, errors.New("oidc token: no access token")
+	}
+	claims, err := f.userInfo(ctx, endpoints.UserInfo, token.AccessToken)
+	if err != nil {
+		return Identity{}, err
+	}
+	username, err := f.username(claims)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidc claim %s: %w", f.cfg.UsernameClaim, err)
+	}
+	return Identity{
+		Username:     username,
+		Capabilities: f.groups.Capabilities(claimStrings(claims[f.cfg.GroupsClaim])),
+	}, nil
+}
+
+// username maps the configured claim to a centaurx username: through
+// Users, or, with AllowUnmapped, as is when it names no local account.
+func (f *OIDCDeviceFlow) username(claims map[string]any) (string, error) {
+	value, _ := claims[f.cfg.UsernameClaim].(string)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.New("missing")
+	}
+	if f.cfg.UsernameClaim == "email" {
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return "", errors.New("email is not verified")
+		}
+	}
+	if username, ok := f.cfg.Users[value]; ok {
+		return validateUsername(username)
+	}
+	if !f.cfg.AllowUnmapped {
+		return "", fmt.Errorf("%q is not mapped to a user", value)
+	}
+	username, err := validateUsername(strings.ToLower(value))
+	if err != nil {
+		return "", err
+	}
+	if f.local != nil && f.local.HasUser(username) {
+		return "", fmt.Errorf("%q names a local account; map it explicitly", value)
+	}
+	return username, nil
+}
+
+func (f *OIDCDeviceFlow) discover(ctx context.Context) (oidcEndpoints, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.endpoints != nil {
+		return *f.endpoints, nil
+	}
+	target := strings.TrimRight(f.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return oidcEndpoints{}, err
+	}
+	var endpoints oidcEndpoints
+	if err := f.do(req, &endpoints); err != nil {
+		return oidcEndpoints{}, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if endpoints.Token == "" || endpoints.UserInfo == "" {
+		return oidcEndpoints{}, errors.New("oidc discovery: token or userinfo endpoint missing")
+	}
+	f.endpoints = &endpoints
+	return endpoints, nil
+}
+
+func (f *OIDCDeviceFlow) userInfo(ctx context.Context, endpoint, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	claims := make(map[string]any)
+	if err := f.do(req, &claims); err != nil {
+		return nil, fmt.Errorf("oidc userinfo: %w", err)
+	}
+	return claims, nil
+}
+
+func (f *OIDCDeviceFlow) clientForm() url.Values {
+	form := url.Values{}
+	form.Set("client_id", f.cfg.ClientID)
+	if f.cfg.ClientSecret != "" {
+		form.Set("client_secret", f.cfg.ClientSecret)
+	}
+	return form
+}
+
+func (f *OIDCDeviceFlow) postForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return f.do(req, out)
+}
+
+// oauthError is an error response of an OAuth endpoint (RFC 6749 section
+// 5.2).
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+func (f *OIDCDeviceFlow) do(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr oauthError
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return &oauthErr
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// claimStrings returns a claim holding a string or a list of strings.
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// newFakeIdP serves discovery, device authorization, token and userinfo
+// endpoints. The token endpoint answers authorization_pending until approve
+// is called.
+func newFakeIdP(t *testing.T, claims map[string]any) (*httptest.Server, func()) {
+	t.Helper()
+	var approved atomic.Bool
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	writeJSON := func(w http.ResponseWriter, status int, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"device_authorization_endpoint": server.URL + "/device",
+			"token_endpoint":                server.URL + "/token",
+			"userinfo_endpoint":             server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "centaurx" || r.FormValue("scope") != "openid profile" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"device_code":      "device-123",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": server.URL + "/activate",
+			"expires_in":       600,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("device_code") != "device-123" || r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:device_code" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if !approved.Load() {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"access_token": "token-xyz", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-xyz" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return
+		}
+		writeJSON(w, http.StatusOK, claims)
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, func() { approved.Store(true) }
+}
+
+func TestOIDCDeviceFlow(t *testing.T) {
+	server, approve := newFakeIdP(t, map[string]any{
+		"sub":    "248289761001",
+		"groups": []any{"developers", "auditors"},
+	})
+	cfg := OIDCConfig{Issuer: server.URL, ClientID: "centaurx", Users: map[string]string{"248289761001": "alice"}}
+	flow, err := NewOIDCDeviceFlow(cfg, GroupMapping{ReadOnly: []string{"auditors"}}, nil)
+	if err != nil {
+		t.Fatalf("new flow: %v", err)
+	}
+	ctx := context.Background()
+	device, err := flow.Start(ctx)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if device.UserCode != "ABCD-EFGH" || device.DeviceCode != "device-123" || device.Interval.Seconds() != 1 {
+		t.Fatalf("unexpected device authorization %+v", device)
+	}
+	if _, err := flow.Poll(ctx, device.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("expected pending, got %v", err)
+	}
+	approve()
+	identity, err := flow.Poll(ctx, device.DeviceCode)
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if identity.Username != "alice" || identity.Capabilities != (schema.Capabilities{ReadOnly: true}) {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if _, err := flow.Poll(ctx, "stale"); err == nil || errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("expected an unknown device code to fail, got %v", err)
+	}
+}
+
+func TestOIDCDeviceFlowRejectsInvalidUsername(t *testing.T) {
+	server, approve := newFakeIdP(t, map[string]any{"sub": "not a user"})
+	approve()
+	flow, err := NewOIDCDeviceFlow(OIDCConfig{Issuer: server.URL, ClientID: "centaurx", AllowUnmapped: true}, GroupMapping{}, nil)
+	if err != nil {
+		t.Fatalf("new flow: %v", err)
+	}
+	if _, err := flow.Poll(context.Background(), "device-123"); err == nil {
+		t.Fatalf("expected an invalid username claim to fail")
+	}
+}
+
+func pollOIDCUsername(t *testing.T, cfg OIDCConfig, claims map[string]any, local *Store) (string, error) {
+	t.Helper()
+	server, approve := newFakeIdP(t, claims)
+	approve()
+	cfg.Issuer = server.URL
+	cfg.ClientID = "centaurx"
+	flow, err := NewOIDCDeviceFlow(cfg, GroupMapping{}, local)
+	if err != nil {
+		t.Fatalf("new flow: %v", err)
+	}
+	identity, err := flow.Poll(context.Background(), "device-123")
+	return identity.Username, err
+}
+
+func TestOIDCDeviceFlowRejectsUnmappedClaim(t *testing.T) {
+	if _, err := pollOIDCUsername(t, OIDCConfig{}, map[string]any{"sub": "alice"}, nil); err == nil {
+		t.Fatalf("expected an unmapped claim to fail without allow unmapped")
+	}
+}
+
+func TestOIDCDeviceFlowRejectsClaimNamingLocalUser(t *testing.T) {
+	local, err := NewStoreWithLogger(filepath.Join(t.TempDir(), "users.json"), nil, nil)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if err := local.AddUser(User{Username: "admin", PasswordHash: "hash", TOTPSecret: "secret"}); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	cfg := OIDCConfig{UsernameClaim: "preferred_username", AllowUnmapped: true}
+	if _, err := pollOIDCUsername(t, cfg, map[string]any{"preferred_username": "Admin"}, local); err == nil {
+		t.Fatalf("expected a claim naming a local account to fail")
+	}
+	username, err := pollOIDCUsername(t, cfg, map[string]any{"preferred_username": "bob"}, local)
+	if err != nil || username != "bob" {
+		t.Fatalf("expected bob, got %q, %v", username, err)
+	}
+	cfg.Users = map[string]string{"Admin": "admin"}
+	username, err = pollOIDCUsername(t, cfg, map[string]any{"preferred_username": "Admin"}, local)
+	if err != nil || username != "admin" {
+		t.Fatalf("expected the mapped admin, got %q, %v", username, err)
+	}
+}
+
+func TestOIDCDeviceFlowRequiresVerifiedEmail(t *testing.T) {
+	cfg := OIDCConfig{UsernameClaim: "email", Users: map[string]string{"alice@example.com": "alice"}}
+	if _, err := pollOIDCUsername(t, cfg, map[string]any{"email": "alice@example.com"}, nil); err == nil {
+		t.Fatalf("expected an unverified email to fail")
+	}
+	claims := map[string]any{"email": "alice@example.com", "email_verified": true}
+	if username, err := pollOIDCUsername(t, cfg, claims, nil); err != nil || username != "alice" {
+		t.Fatalf("expected alice, got %q, %v", username, err)
+	}
+}
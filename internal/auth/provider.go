@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// Provider authenticates logins for the SSH and HTTP servers. Store is the
+// default provider, backed by the users file; LDAPProvider binds against a
+// directory instead.
+type Provider interface {
+	// AuthenticateFrom verifies a password login from source. Providers
+	// with ExternalCredentials ignore totpCode; the backend enforces its
+	// own second factor, if any.
+	AuthenticateFrom(source, username, password, totpCode string) error
+	// ValidateTOTPFrom verifies the second step of an SSH public key login.
+	ValidateTOTPFrom(source, username, totpCode string) error
+	ChangePassword(username, currentPassword, totpCode, newPassword string) error
+	// ChangePasswordFrom is ChangePassword for a request from source,
+	// throttled like logins.
+	ChangePasswordFrom(source, username, currentPassword, totpCode, newPassword string) error
+	HasLoginPubKey(userID schema.UserID, key ssh.PublicKey) (bool, error)
+	// ExternalCredentials reports whether passwords and TOTP secrets live
+	// in an external backend. Changing them then fails with
+	// schema.ErrManagedExternally.
+	ExternalCredentials() bool
+	// Capabilities returns what username may do, as of its last login.
+	Capabilities(username string) schema.Capabilities
+}
+
+var (
+	_ Provider = (*Store)(nil)
+	_ Provider = (*LDAPProvider)(nil)
+)
+
+// GroupMapping maps directory groups or identity provider group claims to
+// capabilities. Entries match a group by its full DN or its first RDN value,
+// without case, so "admins" matches "cn=admins,ou=groups,dc=example,dc=com".
+type GroupMapping struct {
+	Admin    []string
+	ReadOnly []string
+}
+
+// Capabilities returns the capabilities granted to members of groups.
+func (m GroupMapping) Capabilities(groups []string) schema.Capabilities {
+	return schema.Capabilities{
+		Admin:    groupsMatch(groups, m.Admin),
+		ReadOnly: groupsMatch(groups, m.ReadOnly),
+	}
+}
+
+func groupsMatch(groups, wanted []string) bool {
+	for _, group := range groups {
+		name := groupName(group)
+		for _, want := range wanted {
+			want = strings.TrimSpace(want)
+			if want == "" {
+				continue
+			}
+			if strings.EqualFold(group, want) || strings.EqualFold(name, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupName returns the value of the first RDN of a DN like
+// "cn=admins,ou=groups", or group itself when it is not a DN.
+func groupName(group string) string {
+	first, _, _ := strings.Cut(group, ",")
+	if _, value, ok := strings.Cut(first, "="); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(group)
+}
+
+// ExternalCredentials implements Provider; the users file holds passwords
+// and TOTP secrets itself.
+func (s *Store) ExternalCredentials() bool {
+	return false
+}
+
+// Capabilities implements Provider. Accounts in the users file are regular
+// accounts.
+func (s *Store) Capabilities(string) schema.Capabilities {
+	return schema.Capabilities{}
+}
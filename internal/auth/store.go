@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
@@ -21,12 +22,43 @@ import (
 	"pkt.systems/pslog"
 )
 
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errInvalidTOTP        = errors.New("invalid totp")
+	// ErrUserNotFound indicates the user has no record in the store.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrPubKeyRequired indicates an empty login public key.
+	ErrPubKeyRequired = errors.New("pubkey is required")
+	// ErrInvalidPubKey indicates a login public key that does not parse.
+	ErrInvalidPubKey = errors.New("invalid pubkey")
+	// ErrPubKeyExists indicates the login public key is already registered.
+	ErrPubKeyExists = errors.New("login pubkey already exists")
+	// ErrPubKeyNotFound indicates no login public key has the given id.
+	ErrPubKeyNotFound = errors.New("login pubkey not found")
+)
+
 // User represents a stored user account.
 type User struct {
 	Username     string   `json:"username"`
 	PasswordHash string   `json:"password_hash"`
 	TOTPSecret   string   `json:"totp_secret"`
 	LoginPubKeys []string `json:"login_pubkeys,omitempty"`
+	// DailyTokenBudget overrides budgets.daily_tokens_per_user for the
+	// user; 0 means no budget.
+	DailyTokenBudget *int64 `json:"daily_token_budget,omitempty"`
+	// RunnerMounts are extra host directories mounted into the user's
+	// runner containers, on top of runner.extra_mounts.
+	RunnerMounts []RunnerMount `json:"runner_mounts,omitempty"`
+	// CreatedAt is when the user was added; zero for users added before it
+	// was recorded.
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// RunnerMount bind-mounts a host directory into a user's runner containers.
+type RunnerMount struct {
+	Host      string `json:"host"`
+	Container string `json:"container"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
 }
 
 // Store manages users stored on disk.
@@ -36,6 +68,7 @@ type Store struct {
 	users     map[string]User
 	fileState fileState
 	log       pslog.Logger
+	throttle  *Throttle
 }
 
 // NewStore loads or seeds the user store.
@@ -74,17 +107,65 @@ func (s *Store) Authenticate(username, password, totpCode string) error {
 	user, ok := s.users[username]
 	s.mu.RUnlock()
 	if !ok {
-		return errors.New("invalid credentials")
+		return errInvalidCredentials
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return errors.New("invalid credentials")
+		return errInvalidCredentials
 	}
 	if !totp.Validate(totpCode, user.TOTPSecret) {
-		return errors.New("invalid totp")
+		return errInvalidTOTP
 	}
 	return nil
 }
 
+// SetThrottle enables failed login throttling for AuthenticateFrom and
+// ValidateTOTPFrom.
+func (s *Store) SetThrottle(throttle *Throttle) {
+	s.throttle = throttle
+}
+
+// AuthenticateFrom is Authenticate for a login attempt from source, subject
+// to failed login throttling.
+func (s *Store) AuthenticateFrom(source, username, password, totpCode string) error {
+	return s.throttled(source, username, func() error {
+		return s.Authenticate(username, password, totpCode)
+	})
+}
+
+// ValidateTOTPFrom is ValidateTOTP for a login attempt from source, subject
+// to failed login throttling. Public key checks that precede it do not
+// count as failures.
+func (s *Store) ValidateTOTPFrom(source, username, totpCode string) error {
+	return s.throttled(source, username, func() error {
+		return s.ValidateTOTP(username, totpCode)
+	})
+}
+
+func (s *Store) throttled(source, username string, check func() error) error {
+	return throttled(s.throttle, source, username, check)
+}
+
+// throttled runs check unless throttle refuses the attempt, and counts
+// rejected credentials as failures. A nil throttle only runs check.
+func throttled(throttle *Throttle, source, username string, check func() error) error {
+	if throttle == nil {
+		return check()
+	}
+	if err := throttle.Allow(username, source); err != nil {
+		return err
+	}
+	err := check()
+	switch {
+	case err == nil:
+		throttle.Succeed(username, source)
+	case errors.Is(err, errInvalidCredentials), errors.Is(err, errInvalidTOTP):
+		throttle.Fail(username, source)
+	default:
+		throttle.Release(username, source)
+	}
+	return err
+}
+
 // ChangePassword verifies credentials and replaces the stored password hash.
 func (s *Store) ChangePassword(username, currentPassword, totpCode, newPassword string) error {
 	if strings.TrimSpace(newPassword) == "" {
@@ -100,6 +181,15 @@ func (s *Store) ChangePassword(username, currentPassword, totpCode, newPassword
 	return s.UpdatePassword(username, string(hash))
 }
 
+// ChangePasswordFrom is ChangePassword for a request from source, subject to
+// failed login throttling: a wrong current password or TOTP code counts as a
+// failed login.
+func (s *Store) ChangePasswordFrom(source, username, currentPassword, totpCode, newPassword string) error {
+	return s.throttled(source, username, func() error {
+		return s.ChangePassword(username, currentPassword, totpCode, newPassword)
+	})
+}
+
 // ValidateTOTP verifies the stored TOTP secret for a user.
 func (s *Store) ValidateTOTP(username string, totpCode string) error {
 	if err := s.refreshIfNeeded(); err != nil {
@@ -113,10 +203,10 @@ func (s *Store) ValidateTOTP(username string, totpCode string) error {
 	user, ok := s.users[normalized]
 	s.mu.RUnlock()
 	if !ok {
-		return errors.New("invalid credentials")
+		return errInvalidCredentials
 	}
 	if !totp.Validate(totpCode, user.TOTPSecret) {
-		return errors.New("invalid totp")
+		return errInvalidTOTP
 	}
 	return nil
 }
@@ -138,11 +228,11 @@ func (s *Store) AddLoginPubKey(userID schema.UserID, pubKey string) (int, error)
 	defer s.mu.Unlock()
 	user, ok := s.users[username]
 	if !ok {
-		return 0, errors.New("user not found")
+		return 0, ErrUserNotFound
 	}
 	for idx, existing := range user.LoginPubKeys {
 		if keyEqual(existing, parsed) {
-			return idx + 1, errors.New("login pubkey already exists")
+			return idx + 1, ErrPubKeyExists
 		}
 	}
 	user.LoginPubKeys = append(user.LoginPubKeys, normalized)
@@ -159,6 +249,17 @@ func (s *Store) AddLoginPubKey(userID schema.UserID, pubKey string) (int, error)
 	return len(user.LoginPubKeys), nil
 }
 
+// HasUser reports whether username is a local account.
+func (s *Store) HasUser(username string) bool {
+	if err := s.refreshIfNeeded(); err != nil && s.log != nil {
+		s.log.Warn("auth store refresh failed", "err", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.users[username]
+	return ok
+}
+
 // ListLoginPubKeys returns the user's login public keys.
 func (s *Store) ListLoginPubKeys(userID schema.UserID) ([]string, error) {
 	if err := s.refreshIfNeeded(); err != nil {
@@ -172,7 +273,7 @@ func (s *Store) ListLoginPubKeys(userID schema.UserID) ([]string, error) {
 	user, ok := s.users[username]
 	s.mu.RUnlock()
 	if !ok {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return append([]string{}, user.LoginPubKeys...), nil
 }
@@ -187,16 +288,16 @@ func (s *Store) RemoveLoginPubKey(userID schema.UserID, index int) error {
 		return err
 	}
 	if index <= 0 {
-		return errors.New("login pubkey id must be positive")
+		return fmt.Errorf("%w: id must be positive", ErrPubKeyNotFound)
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	user, ok := s.users[username]
 	if !ok {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 	if index > len(user.LoginPubKeys) {
-		return errors.New("login pubkey id out of range")
+		return fmt.Errorf("%w: id %d out of range", ErrPubKeyNotFound, index)
 	}
 	user.LoginPubKeys = append(user.LoginPubKeys[:index-1], user.LoginPubKeys[index:]...)
 	s.users[username] = user
@@ -225,7 +326,7 @@ func (s *Store) HasLoginPubKey(userID schema.UserID, key ssh.PublicKey) (bool, e
 	user, ok := s.users[username]
 	s.mu.RUnlock()
 	if !ok {
-		return false, errors.New("user not found")
+		return false, ErrUserNotFound
 	}
 	for _, raw := range user.LoginPubKeys {
 		if keyEqual(raw, key) {
@@ -266,6 +367,9 @@ func (s *Store) AddUser(user User) error {
 		return errors.New("user already exists")
 	}
 	user.Username = username
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now().UTC()
+	}
 	s.users[username] = user
 	if err := s.saveLocked(); err != nil {
 		if s.log != nil {
@@ -296,7 +400,7 @@ func (s *Store) UpdatePassword(username, passwordHash string) error {
 	defer s.mu.Unlock()
 	user, ok := s.users[username]
 	if !ok {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 	user.PasswordHash = passwordHash
 	s.users[username] = user
@@ -329,7 +433,7 @@ func (s *Store) UpdateTOTP(username, secret string) error {
 	defer s.mu.Unlock()
 	user, ok := s.users[username]
 	if !ok {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 	user.TOTPSecret = secret
 	s.users[username] = user
@@ -345,6 +449,99 @@ func (s *Store) UpdateTOTP(username, secret string) error {
 	return nil
 }
 
+// SetDailyTokenBudget overrides the daily token budget of a user; nil
+// returns the user to the configured default and 0 lifts the budget.
+func (s *Store) SetDailyTokenBudget(username string, budget *int64) error {
+	if err := s.refreshIfNeeded(); err != nil {
+		return err
+	}
+	normalized, err := validateUsername(username)
+	if err != nil {
+		return err
+	}
+	username = normalized
+	if budget != nil && *budget < 0 {
+		return errors.New("token budget must not be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.DailyTokenBudget = budget
+	s.users[username] = user
+	if err := s.saveLocked(); err != nil {
+		if s.log != nil {
+			s.log.Warn("auth token budget update failed", "user", username, "err", err)
+		}
+		return err
+	}
+	if s.log != nil {
+		s.log.Info("auth token budget updated", "user", username, "default", budget == nil)
+	}
+	return nil
+}
+
+// DailyTokenBudget returns the user's daily token budget override, if any.
+func (s *Store) DailyTokenBudget(userID schema.UserID) (int64, bool) {
+	if err := s.refreshIfNeeded(); err != nil {
+		return 0, false
+	}
+	s.mu.RLock()
+	user, ok := s.users[string(userID)]
+	s.mu.RUnlock()
+	if !ok || user.DailyTokenBudget == nil {
+		return 0, false
+	}
+	return *user.DailyTokenBudget, true
+}
+
+// SetRunnerMounts replaces the extra runner mounts of a user; an empty list
+// leaves only runner.extra_mounts. Callers validate the mounts.
+func (s *Store) SetRunnerMounts(username string, mounts []RunnerMount) error {
+	if err := s.refreshIfNeeded(); err != nil {
+		return err
+	}
+	normalized, err := validateUsername(username)
+	if err != nil {
+		return err
+	}
+	username = normalized
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.RunnerMounts = append([]RunnerMount(nil), mounts...)
+	s.users[username] = user
+	if err := s.saveLocked(); err != nil {
+		if s.log != nil {
+			s.log.Warn("auth runner mounts update failed", "user", username, "err", err)
+		}
+		return err
+	}
+	if s.log != nil {
+		s.log.Info("auth runner mounts updated", "user", username, "mounts", len(mounts))
+	}
+	return nil
+}
+
+// RunnerMounts returns the extra runner mounts of a user.
+func (s *Store) RunnerMounts(userID schema.UserID) []RunnerMount {
+	if err := s.refreshIfNeeded(); err != nil {
+		return nil
+	}
+	s.mu.RLock()
+	user, ok := s.users[string(userID)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return append([]RunnerMount(nil), user.RunnerMounts...)
+}
+
 // DeleteUser removes a user.
 func (s *Store) DeleteUser(username string) error {
 	if err := s.refreshIfNeeded(); err != nil {
@@ -358,7 +555,7 @@ func (s *Store) DeleteUser(username string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if _, ok := s.users[username]; !ok {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 	delete(s.users, username)
 	if err := s.saveLocked(); err != nil {
@@ -595,11 +792,11 @@ func (s *Store) loadFromDisk() error {
 func normalizeLoginPubKey(raw string) (string, ssh.PublicKey, error) {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
-		return "", nil, errors.New("pubkey is required")
+		return "", nil, ErrPubKeyRequired
 	}
 	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(trimmed))
 	if err != nil {
-		return "", nil, errors.New("invalid pubkey")
+		return "", nil, ErrInvalidPubKey
 	}
 	return trimmed, key, nil
 }
@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"pkt.systems/pslog"
+)
+
+// ErrThrottled indicates a login was refused because of earlier failures.
+var ErrThrottled = errors.New("too many failed login attempts")
+
+// Throttle defaults used for zero ThrottleConfig values.
+const (
+	DefaultBackoffAfter  = 3
+	DefaultLockoutAfter  = 10
+	DefaultFailureWindow = 15 * time.Minute
+	DefaultLockout       = 15 * time.Minute
+	defaultBaseDelay     = time.Second
+)
+
+// ThrottleConfig configures failed login throttling. Failures are counted
+// per user and per source address within Window. From BackoffAfter failures
+// on, each further attempt has to wait an exponentially growing delay; at
+// LockoutAfter failures the user or source is locked out for Lockout.
+type ThrottleConfig struct {
+	BackoffAfter int
+	LockoutAfter int
+	Window       time.Duration
+	Lockout      time.Duration
+	// BaseDelay is the first backoff delay; it doubles with every failure.
+	BaseDelay time.Duration
+	// StatePath persists throttle state across restarts and lets
+	// `centaurx users unlock` reach a running server. Empty keeps the state
+	// in memory only.
+	StatePath string
+	// Now is the clock; it defaults to time.Now.
+	Now    func() time.Time
+	Logger pslog.Logger
+}
+
+// Throttle tracks failed logins and decides when further attempts are
+// refused.
+type Throttle struct {
+	cfg     ThrottleConfig
+	mu      sync.Mutex
+	records map[string]*failureRecord
+	// pending counts the attempts per key admitted by Allow and not yet
+	// settled by Fail, Succeed or Release.
+	pending   map[string]int
+	fileState fileState
+}
+
+type failureRecord struct {
+	Failures    []time.Time `json:"failures,omitempty"`
+	LockedUntil time.Time   `json:"locked_until,omitzero"`
+}
+
+// NewThrottle constructs a throttle, loading persisted state when
+// cfg.StatePath exists.
+func NewThrottle(cfg ThrottleConfig) (*Throttle, error) {
+	if cfg.BackoffAfter <= 0 {
+		cfg.BackoffAfter = DefaultBackoffAfter
+	}
+	if cfg.LockoutAfter <= 0 {
+		cfg.LockoutAfter = DefaultLockoutAfter
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultFailureWindow
+	}
+	if cfg.Lockout <= 0 {
+		cfg.Lockout = DefaultLockout
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.Logger != nil && cfg.StatePath != "" {
+		cfg.Logger = cfg.Logger.With("lockout_file", cfg.StatePath)
+	}
+	t := &Throttle{cfg: cfg, records: make(map[string]*failureRecord), pending: make(map[string]int)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.refreshLocked(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Allow reports whether a login for username from source may be attempted
+// now. It returns an error wrapping ErrThrottled while backing off or locked
+// out. An admitted attempt is pending until it is settled with Fail, Succeed
+// or Release, and pending attempts count as failures made now, so parallel
+// attempts cannot all slip past the backoff before the first one fails.
+func (t *Throttle) Allow(username, source string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshQuietLocked()
+	now := t.cfg.Now()
+	keys := throttleKeys(username, source)
+	for _, key := range keys {
+		record := t.records[key]
+		if record == nil {
+			record = &failureRecord{}
+		}
+		if now.Before(record.LockedUntil) {
+			return fmt.Errorf("%w; locked out for %s", ErrThrottled, record.LockedUntil.Sub(now).Round(time.Second))
+		}
+		record.prune(now, t.cfg.Window)
+		pending := t.pending[key]
+		failures := len(record.Failures) + pending
+		if failures < t.cfg.BackoffAfter {
+			continue
+		}
+		last := now
+		if pending == 0 {
+			last = record.Failures[len(record.Failures)-1]
+		}
+		next := last.Add(t.backoff(failures))
+		if now.Before(next) {
+			return fmt.Errorf("%w; retry in %s", ErrThrottled, next.Sub(now).Round(time.Second))
+		}
+	}
+	for _, key := range keys {
+		t.pending[key]++
+	}
+	return nil
+}
+
+// Release settles an attempt admitted by Allow that neither failed nor
+// succeeded, such as one that hit a storage error.
+func (t *Throttle) Release(username, source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.releaseLocked(throttleKeys(username, source))
+}
+
+func (t *Throttle) releaseLocked(keys []string) {
+	for _, key := range keys {
+		if t.pending[key] <= 1 {
+			delete(t.pending, key)
+			continue
+		}
+		t.pending[key]--
+	}
+}
+
+// Fail records a failed login for username from source, settling the
+// attempt admitted by Allow.
+func (t *Throttle) Fail(username, source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshQuietLocked()
+	now := t.cfg.Now()
+	keys := throttleKeys(username, source)
+	t.releaseLocked(keys)
+	for _, key := range keys {
+		record := t.records[key]
+		if record == nil {
+			record = &failureRecord{}
+			t.records[key] = record
+		}
+		record.prune(now, t.cfg.Window)
+		record.Failures = append(record.Failures, now)
+		if len(record.Failures) >= t.cfg.LockoutAfter {
+			record.LockedUntil = now.Add(t.cfg.Lockout)
+			record.Failures = nil
+			if t.cfg.Logger != nil {
+				t.cfg.Logger.Warn("auth lockout", "key", key, "failures", t.cfg.LockoutAfter, "locked_until", record.LockedUntil)
+			}
+		}
+	}
+	t.saveLocked()
+}
+
+// Succeed settles the attempt admitted by Allow and clears the failures
+// recorded for username. Failures of the source address keep decaying on
+// their own.
+func (t *Throttle) Succeed(username, source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshQuietLocked()
+	t.releaseLocked(throttleKeys(username, source))
+	key := userKey(username)
+	if _, ok := t.records[key]; !ok {
+		return
+	}
+	delete(t.records, key)
+	t.saveLocked()
+}
+
+// Unlock clears failures and any lockout for username. It reports whether
+// anything was cleared.
+func (t *Throttle) Unlock(username string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.refreshLocked(); err != nil {
+		return false, err
+	}
+	key := userKey(username)
+	if _, ok := t.records[key]; !ok {
+		return false, nil
+	}
+	delete(t.records, key)
+	if err := t.writeLocked(); err != nil {
+		return false, err
+	}
+	if t.cfg.Logger != nil {
+		t.cfg.Logger.Info("auth lockout cleared", "key", key)
+	}
+	return true, nil
+}
+
+// LockedUntil reports when the lockout of username ends; zero when the
+// user is not locked out.
+func (t *Throttle) LockedUntil(username string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshQuietLocked()
+	record := t.records[userKey(username)]
+	if record == nil || !t.cfg.Now().Before(record.LockedUntil) {
+		return time.Time{}
+	}
+	return record.LockedUntil
+}
+
+func (t *Throttle) backoff(failures int) time.Duration {
+	delay := t.cfg.BaseDelay
+	for i := t.cfg.BackoffAfter; i < failures && delay < t.cfg.Lockout; i++ {
+		delay *= 2
+	}
+	return min(delay, t.cfg.Lockout)
+}
+
+func (r *failureRecord) prune(now time.Time, window time.Duration) {
+	kept := r.Failures[:0]
+	for _, at := range r.Failures {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	r.Failures = kept
+}
+
+func userKey(username string) string {
+	return "user:" + username
+}
+
+// throttleKeys returns the counters a login attempt touches. Source ports
+// are dropped so reconnects from the same host share a counter.
+func throttleKeys(username, source string) []string {
+	keys := []string{userKey(username)}
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+	if source != "" {
+		keys = append(keys, "source:"+source)
+	}
+	return keys
+}
+
+// refreshQuietLocked reloads persisted state, logging failures since the
+// throttle keeps working from memory.
+func (t *Throttle) refreshQuietLocked() {
+	if err := t.refreshLocked(); err != nil && t.cfg.Logger != nil {
+		t.cfg.Logger.Warn("auth throttle load failed", "err", err)
+	}
+}
+
+func (t *Throttle) refreshLocked() error {
+	if t.cfg.StatePath == "" {
+		return nil
+	}
+	info, err := os.Stat(t.cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	latest := fileStateFromInfo(info)
+	if t.fileState.equal(latest) {
+		return nil
+	}
+	data, err := os.ReadFile(t.cfg.StatePath)
+	if err != nil {
+		return err
+	}
+	records := make(map[string]*failureRecord)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	t.records = records
+	t.fileState = latest
+	return nil
+}
+
+// saveLocked persists the state, logging failures since the throttle keeps
+// working from memory.
+func (t *Throttle) saveLocked() {
+	now := t.cfg.Now()
+	for key, record := range t.records {
+		record.prune(now, t.cfg.Window)
+		if len(record.Failures) == 0 && !now.Before(record.LockedUntil) {
+			delete(t.records, key)
+		}
+	}
+	if err := t.writeLocked(); err != nil && t.cfg.Logger != nil {
+		t.cfg.Logger.Warn("auth throttle save failed", "err", err)
+	}
+}
+
+func (t *Throttle) writeLocked() error {
+	if t.cfg.StatePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(t.cfg.StatePath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "lockouts-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), t.cfg.StatePath); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if info, err := os.Stat(t.cfg.StatePath); err == nil {
+		t.fileState = fileStateFromInfo(info)
+	}
+	return nil
+}
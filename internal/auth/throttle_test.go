@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestThrottle(t *testing.T, clock *testClock, statePath string) *Throttle {
+	t.Helper()
+	throttle, err := NewThrottle(ThrottleConfig{
+		BackoffAfter: 2,
+		LockoutAfter: 4,
+		Window:       10 * time.Minute,
+		Lockout:      5 * time.Minute,
+		BaseDelay:    time.Second,
+		StatePath:    statePath,
+		Now:          clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("new throttle: %v", err)
+	}
+	return throttle
+}
+
+func TestThrottleBacksOffAndLocksOut(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := newTestThrottle(t, clock, "")
+
+	throttle.Fail("alice", "10.0.0.1:5000")
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); err != nil {
+		t.Fatalf("expected no backoff after one failure, got %v", err)
+	}
+	throttle.Fail("alice", "10.0.0.1:5000")
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected backoff after two failures, got %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); err != nil {
+		t.Fatalf("expected backoff to expire, got %v", err)
+	}
+	throttle.Fail("alice", "10.0.0.1:5000")
+	clock.Advance(time.Second)
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected backoff to double, got %v", err)
+	}
+	clock.Advance(time.Second)
+	throttle.Fail("alice", "10.0.0.1:5000")
+	if until := throttle.LockedUntil("alice"); !until.Equal(clock.now.Add(5 * time.Minute)) {
+		t.Fatalf("expected lockout until %s, got %s", clock.now.Add(5*time.Minute), until)
+	}
+	clock.Advance(4 * time.Minute)
+	if err := throttle.Allow("alice", ""); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected user lockout, got %v", err)
+	}
+	if err := throttle.Allow("bob", "10.0.0.1:6000"); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected source lockout for other users, got %v", err)
+	}
+	if err := throttle.Allow("bob", "10.0.0.2:6000"); err != nil {
+		t.Fatalf("expected other sources to be unaffected, got %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); err != nil {
+		t.Fatalf("expected lockout to expire, got %v", err)
+	}
+	if !throttle.LockedUntil("alice").IsZero() {
+		t.Fatalf("expected no lockout after expiry")
+	}
+}
+
+func TestThrottleFailuresDecayAndSuccessClears(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := newTestThrottle(t, clock, "")
+
+	for range 3 {
+		throttle.Fail("alice", "")
+		clock.Advance(time.Minute)
+	}
+	// The first failures fall out of the window, so the next failure does
+	// not reach the lockout threshold.
+	clock.Advance(8 * time.Minute)
+	throttle.Fail("alice", "")
+	if !throttle.LockedUntil("alice").IsZero() {
+		t.Fatalf("expected failures outside the window to decay")
+	}
+
+	throttle.Succeed("alice", "")
+	if err := throttle.Allow("alice", ""); err != nil {
+		t.Fatalf("expected success to clear failures, got %v", err)
+	}
+}
+
+func TestThrottleHoldsBackParallelAttempts(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := newTestThrottle(t, clock, "")
+
+	const attempts = 20
+	gate := make(chan struct{})
+	var checked, refused atomic.Int32
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := throttled(throttle, "10.0.0.1:5000", "alice", func() error {
+				checked.Add(1)
+				<-gate
+				return errInvalidCredentials
+			})
+			if errors.Is(err, ErrThrottled) {
+				refused.Add(1)
+			}
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for checked.Load()+refused.Load() < attempts {
+		if time.Now().After(deadline) {
+			t.Fatalf("attempts did not settle: checked %d refused %d", checked.Load(), refused.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	wg.Wait()
+	// Only BackoffAfter attempts reach the credential check; the rest
+	// see them pending and back off.
+	if got := checked.Load(); got != 2 {
+		t.Fatalf("expected 2 attempts to reach the check, got %d", got)
+	}
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected backoff after the failed burst, got %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := throttle.Allow("alice", "10.0.0.1:5000"); err != nil {
+		t.Fatalf("expected the settled attempts to leave only the backoff, got %v", err)
+	}
+	throttle.Release("alice", "10.0.0.1:5000")
+	if len(throttle.pending) != 0 {
+		t.Fatalf("expected no pending attempts, got %v", throttle.pending)
+	}
+}
+
+func TestThrottleUnlockReachesOtherInstance(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	path := filepath.Join(t.TempDir(), "lockouts.json")
+	server := newTestThrottle(t, clock, path)
+	for range 4 {
+		server.Fail("alice", "")
+	}
+	if server.LockedUntil("alice").IsZero() {
+		t.Fatalf("expected alice to be locked out")
+	}
+
+	restarted := newTestThrottle(t, clock, path)
+	if restarted.LockedUntil("alice").IsZero() {
+		t.Fatalf("expected lockout to persist")
+	}
+	cleared, err := restarted.Unlock("alice")
+	if err != nil || !cleared {
+		t.Fatalf("unlock: cleared=%v err=%v", cleared, err)
+	}
+	if cleared, err := restarted.Unlock("alice"); err != nil || cleared {
+		t.Fatalf("expected second unlock to be a no-op: cleared=%v err=%v", cleared, err)
+	}
+	if err := server.Allow("alice", ""); err != nil {
+		t.Fatalf("expected running throttle to pick up unlock, got %v", err)
+	}
+}
+
+func TestStoreAuthenticateFromThrottlesFailures(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStoreWithLogger(filepath.Join(dir, "users.json"), nil, nil)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	secret := "JBSWY3DPEHPK3PXP"
+	hash, err := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if err := store.AddUser(User{Username: "alice", PasswordHash: string(hash), TOTPSecret: secret}); err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	clock := &testClock{now: time.Now()}
+	store.SetThrottle(newTestThrottle(t, clock, ""))
+
+	for range 2 {
+		if err := store.AuthenticateFrom("10.0.0.1:22", "alice", "wrong", ""); err == nil || errors.Is(err, ErrThrottled) {
+			t.Fatalf("expected invalid credentials, got %v", err)
+		}
+	}
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("generate totp: %v", err)
+	}
+	if err := store.AuthenticateFrom("10.0.0.1:22", "alice", "pass", code); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected throttled login, got %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := store.AuthenticateFrom("10.0.0.1:22", "alice", "pass", code); err != nil {
+		t.Fatalf("authenticate after backoff: %v", err)
+	}
+	if err := store.AuthenticateFrom("10.0.0.2:22", "alice", "pass", code); err != nil {
+		t.Fatalf("expected success to clear user failures, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package buildmanifest
+
+import (
+	"strings"
+)
+
+// BaseImages returns the images the FROM lines of a Containerfile build on,
+// in order. scratch and references to earlier stages are skipped.
+func BaseImages(containerfile []byte) []BaseImage {
+	var out []BaseImage
+	stages := map[string]struct{}{}
+	for _, line := range strings.Split(string(containerfile), "\n") {
+		fields, image, ok := fromLine(line)
+		if !ok {
+			continue
+		}
+		_, stage := stages[strings.ToLower(fields[image])]
+		if !stage && !strings.EqualFold(fields[image], "scratch") {
+			out = append(out, splitDigest(fields[image]))
+		}
+		if n := len(fields); n > image+2 && strings.EqualFold(fields[n-2], "as") {
+			stages[strings.ToLower(fields[n-1])] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Pin rewrites the FROM lines of a Containerfile so each base image listed
+// in digests, keyed by ref, is pinned to its digest. A digest already on the
+// line is replaced; other lines are kept as they are.
+func Pin(containerfile []byte, digests map[string]string) []byte {
+	lines := strings.Split(string(containerfile), "\n")
+	for i, line := range lines {
+		fields, image, ok := fromLine(line)
+		if !ok {
+			continue
+		}
+		base := splitDigest(fields[image])
+		digest, ok := digests[base.Ref]
+		if !ok || digest == "" {
+			continue
+		}
+		base.Digest = digest
+		fields[image] = base.String()
+		lines[i] = strings.Join(fields, " ")
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// fromLine splits a FROM line into fields and returns the index of the image
+// field.
+func fromLine(line string) ([]string, int, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+		return nil, 0, false
+	}
+	for i := 1; i < len(fields); i++ {
+		if !strings.HasPrefix(fields[i], "--") {
+			return fields, i, true
+		}
+	}
+	return nil, 0, false
+}
+
+func splitDigest(ref string) BaseImage {
+	if name, digest, ok := strings.Cut(ref, "@"); ok {
+		return BaseImage{Ref: name, Digest: digest}
+	}
+	return BaseImage{Ref: ref}
+}
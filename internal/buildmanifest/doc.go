@@ -0,0 +1,6 @@
+// Package buildmanifest records what went into a centaurx image build: the
+// base images and their digests, the Containerfile, the centaurx version and
+// the installed packages. The manifest is written next to the exported image
+// and the parts known before the build are set as image labels, so a running
+// container can be traced back to its inputs.
+package buildmanifest
@@ -0,0 +1,141 @@
+package buildmanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Image labels set on built images.
+const (
+	// LabelTarget is the build target: "server" or "runner".
+	LabelTarget = "centaurx.build.target"
+	// LabelVersion is the centaurx version the image was built with.
+	LabelVersion = "centaurx.build.version"
+	// LabelTime is the build time in RFC 3339 format.
+	LabelTime = "centaurx.build.time"
+	// LabelBaseImages lists the base images, comma separated, as ref or
+	// ref@digest.
+	LabelBaseImages = "centaurx.build.base-images"
+	// LabelContainerfile is the SHA-256 of the Containerfile built.
+	LabelContainerfile = "centaurx.build.containerfile-sha256"
+)
+
+// BaseImage is an image a Containerfile builds on.
+type BaseImage struct {
+	Ref    string `json:"ref"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// String returns ref@digest, or ref when the digest is unknown.
+func (b BaseImage) String() string {
+	if b.Digest == "" {
+		return b.Ref
+	}
+	return b.Ref + "@" + b.Digest
+}
+
+// Package is an installed OS package.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Manifest describes an image build.
+type Manifest struct {
+	Target              string      `json:"target"`
+	Tags                []string    `json:"tags"`
+	Platform            string      `json:"platform,omitempty"`
+	Version             string      `json:"centaurx_version"`
+	BuildTime           time.Time   `json:"build_time"`
+	BaseImages          []BaseImage `json:"base_images"`
+	ContainerfileSHA256 string      `json:"containerfile_sha256"`
+	// Packages are read from the built image after the build; images
+	// without dpkg have none.
+	Packages []Package `json:"packages,omitempty"`
+}
+
+// Labels returns the image labels for the manifest.
+func (m Manifest) Labels() map[string]string {
+	bases := make([]string, 0, len(m.BaseImages))
+	for _, base := range m.BaseImages {
+		bases = append(bases, base.String())
+	}
+	out := map[string]string{
+		LabelTarget:        m.Target,
+		LabelVersion:       m.Version,
+		LabelBaseImages:    strings.Join(bases, ","),
+		LabelContainerfile: m.ContainerfileSHA256,
+	}
+	if !m.BuildTime.IsZero() {
+		out[LabelTime] = m.BuildTime.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+// Path returns where the manifest of an image exported to archivePath is
+// written.
+func Path(archivePath string) string {
+	return archivePath + ".manifest.json"
+}
+
+// Write stores the manifest as indented JSON.
+func (m Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write build manifest: %w", err)
+	}
+	return nil
+}
+
+// ContainerfileDigest returns the hex SHA-256 of a Containerfile.
+func ContainerfileDigest(containerfile []byte) string {
+	sum := sha256.Sum256(containerfile)
+	return hex.EncodeToString(sum[:])
+}
+
+// Drift compares the labels of an image with the labels expected from the
+// local build inputs and describes every difference. The build time is not
+// compared, and expected base images without a digest only compare refs.
+func Drift(expected, actual map[string]string) []string {
+	var drift []string
+	for _, key := range []string{LabelTarget, LabelVersion, LabelContainerfile} {
+		if want, got := expected[key], actual[key]; want != got {
+			drift = append(drift, fmt.Sprintf("%s: expected %s, got %s", key, orMissing(want), orMissing(got)))
+		}
+	}
+	want := parseBaseImages(expected[LabelBaseImages])
+	got := parseBaseImages(actual[LabelBaseImages])
+	same := len(want) == len(got)
+	for i := 0; same && i < len(want); i++ {
+		same = want[i].Ref == got[i].Ref && (want[i].Digest == "" || want[i].Digest == got[i].Digest)
+	}
+	if !same {
+		drift = append(drift, fmt.Sprintf("%s: expected %s, got %s", LabelBaseImages, orMissing(expected[LabelBaseImages]), orMissing(actual[LabelBaseImages])))
+	}
+	return drift
+}
+
+func parseBaseImages(value string) []BaseImage {
+	var out []BaseImage
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, splitDigest(item))
+		}
+	}
+	return out
+}
+
+func orMissing(value string) string {
+	if value == "" {
+		return "(missing)"
+	}
+	return value
+}
@@ -0,0 +1,233 @@
+package buildmanifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const containerfile = `FROM alpine:latest AS certs
+RUN apk add --no-cache ca-certificates
+
+FROM --platform=linux/amd64 docker.io/library/debian:stable-slim@sha256:aaa AS base
+FROM certs AS again
+FROM scratch
+COPY --from=certs /etc/ssl/certs /etc/ssl/certs
+`
+
+func TestBaseImages(t *testing.T) {
+	got := BaseImages([]byte(containerfile))
+	want := []BaseImage{
+		{Ref: "alpine:latest"},
+		{Ref: "docker.io/library/debian:stable-slim", Digest: "sha256:aaa"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestPin(t *testing.T) {
+	pinned := Pin([]byte(containerfile), map[string]string{
+		"alpine:latest":                        "sha256:bbb",
+		"docker.io/library/debian:stable-slim": "sha256:ccc",
+	})
+	lines := strings.Split(string(pinned), "\n")
+	if lines[0] != "FROM alpine:latest@sha256:bbb AS certs" {
+		t.Fatalf("unexpected first FROM %q", lines[0])
+	}
+	if lines[3] != "FROM --platform=linux/amd64 docker.io/library/debian:stable-slim@sha256:ccc AS base" {
+		t.Fatalf("unexpected second FROM %q", lines[3])
+	}
+	if !strings.Contains(string(pinned), "RUN apk add --no-cache ca-certificates\n\nFROM") || !strings.Contains(string(pinned), "FROM scratch\n") {
+		t.Fatalf("expected other lines to be kept, got\n%s", pinned)
+	}
+	if again := Pin(pinned, nil); !bytes.Equal(again, pinned) {
+		t.Fatalf("expected pinning without digests to keep the file")
+	}
+}
+
+func TestLabelsAndDrift(t *testing.T) {
+	m := Manifest{
+		Target:              "runner",
+		Version:             "v1.2.3",
+		BuildTime:           time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		BaseImages:          []BaseImage{{Ref: "debian:stable-slim", Digest: "sha256:abc"}},
+		ContainerfileSHA256: ContainerfileDigest([]byte("FROM debian:stable-slim\n")),
+	}
+	labels := m.Labels()
+	if labels[LabelBaseImages] != "debian:stable-slim@sha256:abc" || labels[LabelTime] != "2026-10-01T12:00:00Z" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if drift := Drift(labels, labels); len(drift) != 0 {
+		t.Fatalf("expected no drift, got %v", drift)
+	}
+
+	unpinned := m
+	unpinned.BaseImages = []BaseImage{{Ref: "debian:stable-slim"}}
+	unpinned.BuildTime = time.Time{}
+	if drift := Drift(unpinned.Labels(), labels); len(drift) != 0 {
+		t.Fatalf("expected an unpinned base to match any digest, got %v", drift)
+	}
+
+	newer := m
+	newer.Version = "v1.3.0"
+	newer.BaseImages = []BaseImage{{Ref: "debian:stable-slim", Digest: "sha256:def"}}
+	drift := Drift(newer.Labels(), labels)
+	if len(drift) != 2 || !strings.Contains(drift[0], "expected v1.3.0, got v1.2.3") || !strings.Contains(drift[1], "sha256:def") {
+		t.Fatalf("unexpected drift %v", drift)
+	}
+	if drift := Drift(labels, nil); len(drift) != 4 || !strings.Contains(drift[0], "got (missing)") {
+		t.Fatalf("expected an unlabeled image to drift on every label, got %v", drift)
+	}
+}
+
+func TestManifestWrite(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "runner.oci.tar"))
+	m := Manifest{Target: "runner", Tags: []string{"cxrunner:latest"}, Packages: []Package{{Name: "git", Version: "1:2.47.3-0+deb13u1"}}}
+	if err := m.Write(path); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var got Manifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Target != "runner" || len(got.Packages) != 1 || got.Packages[0].Version != "1:2.47.3-0+deb13u1" {
+		t.Fatalf("unexpected manifest %+v", got)
+	}
+}
+
+const dpkgStatus = `Package: git
+Status: install ok installed
+Version: 1:2.47.3-0+deb13u1
+Description: fast, scalable, distributed revision control system
+ multi-line description: with colons
+
+Package: bash
+Status: install ok installed
+Version: 5.2.37-2
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+`
+
+func TestParseDpkgStatus(t *testing.T) {
+	got, err := ParseDpkgStatus(strings.NewReader(dpkgStatus))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []Package{{Name: "bash", Version: "5.2.37-2"}, {Name: "git", Version: "1:2.47.3-0+deb13u1"}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestPackagesFromOCIArchive(t *testing.T) {
+	base := layerTar(t, map[string]string{"var/lib/dpkg/status": "Package: old\nStatus: install ok installed\nVersion: 1\n"})
+	top := layerTar(t, map[string]string{"./var/lib/dpkg/status": dpkgStatus, "usr/bin/tool": "x"})
+	config := layerTar(t, map[string]string{"etc/hostname": "x"})
+	archive := ociArchive(t, base, top, config)
+
+	got, err := Packages(archive)
+	if err != nil {
+		t.Fatalf("packages: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "bash" {
+		t.Fatalf("expected the packages of the top-most dpkg layer, got %+v", got)
+	}
+
+	none, err := Packages(ociArchive(t, config))
+	if err != nil || none != nil {
+		t.Fatalf("expected no packages without dpkg, got %+v %v", none, err)
+	}
+}
+
+func layerTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("layer header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("layer write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("layer close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("layer gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// ociArchive writes an OCI archive whose index points at a nested index, as
+// buildkit exports them.
+func ociArchive(t *testing.T, layers ...[]byte) string {
+	t.Helper()
+	blobs := map[string][]byte{}
+	add := func(data []byte) ocispec.Descriptor {
+		sum := sha256.Sum256(data)
+		blobs[fmt.Sprintf("blobs/sha256/%x", sum)] = data
+		return ocispec.Descriptor{Digest: digest.Digest(fmt.Sprintf("sha256:%x", sum)), Size: int64(len(data))}
+	}
+	manifest := ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest}
+	for _, layer := range layers {
+		desc := add(layer)
+		desc.MediaType = ocispec.MediaTypeImageLayerGzip
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+	manifestData, _ := json.Marshal(manifest)
+	manifestDesc := add(manifestData)
+	manifestDesc.MediaType = ocispec.MediaTypeImageManifest
+	nested, _ := json.Marshal(ocispec.Index{Manifests: []ocispec.Descriptor{manifestDesc}})
+	nestedDesc := add(nested)
+	nestedDesc.MediaType = ocispec.MediaTypeImageIndex
+	index, _ := json.Marshal(ocispec.Index{Manifests: []ocispec.Descriptor{nestedDesc}})
+
+	path := filepath.Join(t.TempDir(), "image.oci.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	tw := tar.NewWriter(file)
+	write := func(name string, data []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("archive header: %v", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("archive write: %v", err)
+		}
+	}
+	write("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	for name, data := range blobs {
+		write(name, data)
+	}
+	write("index.json", index)
+	if err := tw.Close(); err != nil {
+		t.Fatalf("archive close: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("archive file close: %v", err)
+	}
+	return path
+}
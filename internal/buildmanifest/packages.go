@@ -0,0 +1,222 @@
+package buildmanifest
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const dpkgStatusPath = "var/lib/dpkg/status"
+
+// errEntryNotFound is returned when an archive has no entry of a name.
+var errEntryNotFound = errors.New("archive entry not found")
+
+// Packages reads the dpkg status database from the top-most layer of the
+// image in an OCI or docker archive that has one and returns the installed
+// packages sorted by name. Images without dpkg have no packages.
+func Packages(archivePath string) ([]Package, error) {
+	layers, err := archiveLayers(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		var pkgs []Package
+		found := false
+		err := withEntry(archivePath, layers[i], func(r io.Reader) error {
+			var err error
+			pkgs, found, err = layerPackages(r)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", layers[i], err)
+		}
+		if found {
+			return pkgs, nil
+		}
+	}
+	return nil, nil
+}
+
+// archiveLayers returns the entry names of the image layers, bottom first.
+func archiveLayers(archivePath string) ([]string, error) {
+	data, err := readEntry(archivePath, "index.json")
+	if errors.Is(err, errEntryNotFound) {
+		return dockerArchiveLayers(archivePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parse index.json: %w", err)
+	}
+	for depth := 0; depth < 4; depth++ {
+		if len(index.Manifests) == 0 {
+			return nil, errors.New("archive has no image manifest")
+		}
+		desc := index.Manifests[0]
+		blob, err := readEntry(archivePath, blobPath(desc.Digest.String()))
+		if err != nil {
+			return nil, err
+		}
+		switch desc.MediaType {
+		case ocispec.MediaTypeImageIndex, "application/vnd.docker.distribution.manifest.list.v2+json":
+			index = ocispec.Index{}
+			if err := json.Unmarshal(blob, &index); err != nil {
+				return nil, fmt.Errorf("parse image index: %w", err)
+			}
+			continue
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(blob, &manifest); err != nil {
+			return nil, fmt.Errorf("parse image manifest: %w", err)
+		}
+		layers := make([]string, 0, len(manifest.Layers))
+		for _, layer := range manifest.Layers {
+			layers = append(layers, blobPath(layer.Digest.String()))
+		}
+		return layers, nil
+	}
+	return nil, errors.New("image index nested too deep")
+}
+
+func dockerArchiveLayers(archivePath string) ([]string, error) {
+	data, err := readEntry(archivePath, "manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("archive is neither OCI nor docker format: %w", err)
+	}
+	var manifests []struct {
+		Layers []string `json:"Layers"`
+	}
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		return nil, fmt.Errorf("parse manifest.json: %w", err)
+	}
+	if len(manifests) == 0 {
+		return nil, errors.New("archive has no image manifest")
+	}
+	return manifests[0].Layers, nil
+}
+
+func blobPath(digest string) string {
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, hex)
+}
+
+func readEntry(archivePath, name string) ([]byte, error) {
+	var data []byte
+	err := withEntry(archivePath, name, func(r io.Reader) error {
+		var err error
+		data, err = io.ReadAll(r)
+		return err
+	})
+	return data, err
+}
+
+// withEntry calls fn with the contents of the archive entry name.
+func withEntry(archivePath, name string, fn func(io.Reader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	tr := tar.NewReader(file)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", name, errEntryNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if path.Clean(strings.TrimPrefix(hdr.Name, "./")) == name {
+			return fn(tr)
+		}
+	}
+}
+
+// layerPackages scans a layer for the dpkg status file. found is false when
+// the layer does not touch it.
+func layerPackages(r io.Reader) ([]Package, bool, error) {
+	br := bufio.NewReader(r)
+	var layer io.Reader = br
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, false, err
+		}
+		defer func() { _ = gz.Close() }()
+		layer = gz
+	}
+	tr := tar.NewReader(layer)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		switch name {
+		case dpkgStatusPath:
+			pkgs, err := ParseDpkgStatus(tr)
+			return pkgs, true, err
+		case path.Join(path.Dir(dpkgStatusPath), ".wh."+path.Base(dpkgStatusPath)):
+			return nil, true, nil
+		}
+	}
+}
+
+// ParseDpkgStatus parses a dpkg status database and returns the installed
+// packages sorted by name.
+func ParseDpkgStatus(r io.Reader) ([]Package, error) {
+	var pkgs []Package
+	var current Package
+	installed := false
+	flush := func() {
+		if installed && current.Name != "" {
+			pkgs = append(pkgs, current)
+		}
+		current = Package{}
+		installed = false
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Package":
+			current.Name = value
+		case "Version":
+			current.Version = value
+		case "Status":
+			installed = strings.HasSuffix(value, " installed")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs, nil
+}
@@ -0,0 +1,41 @@
+package codex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// managedConfigKeys are the -c keys the runner sets from the request.
+var managedConfigKeys = map[string]bool{
+	"model":                  true,
+	"model_reasoning_effort": true,
+}
+
+// ValidateExtraArgs rejects extra codex exec args that conflict with the
+// ones the runner manages itself: the exec and resume subcommands, --json,
+// the model and its reasoning effort, and "-" for the prompt on stdin.
+func ValidateExtraArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "":
+			return fmt.Errorf("empty argument")
+		case "exec", "resume", "-", "--json", "--model", "-m":
+			return fmt.Errorf("%q is managed by centaurx", arg)
+		case "-c", "--config":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return fmt.Errorf("%q needs a key=value", arg)
+				}
+				i++
+				value = args[i]
+			}
+			key, _, _ := strings.Cut(strings.TrimSpace(value), "=")
+			if managedConfigKeys[strings.TrimSpace(key)] {
+				return fmt.Errorf("%q is managed by centaurx", "-c "+value)
+			}
+		}
+	}
+	return nil
+}
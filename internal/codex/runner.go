@@ -54,6 +54,7 @@ func (r *Runner) Run(ctx context.Context, req core.RunRequest) (core.RunHandle,
 			"reasoning_effort", req.ModelReasoningEffort,
 			"resume", req.ResumeSessionID != "",
 			"json", req.JSON,
+			"extra_args", req.ExtraArgs,
 			"prompt_len", len(req.Prompt),
 			"ssh_auth_sock", req.SSHAuthSock != "",
 			"env_extra", len(r.cfg.Env),
@@ -135,6 +136,11 @@ func buildExecArgs(cfg Config, req core.RunRequest) []string {
 	}
 	args = append(args, "-c", fmt.Sprintf("model_reasoning_effort=%s", effort))
 	args = append(args, cfg.ExtraArgs...)
+	args = append(args, req.ExtraArgs...)
+	// Per-tab tool settings come last so they override deployment flags.
+	if req.EnableWebSearch != nil {
+		args = append(args, "-c", fmt.Sprintf("tools.web_search=%t", *req.EnableWebSearch))
+	}
 	if req.ResumeSessionID != "" {
 		args = append(args, "resume", string(req.ResumeSessionID))
 	}
@@ -172,6 +178,8 @@ func (r *runHandle) Signal(ctx context.Context, sig core.ProcessSignal) error {
 		return r.cmd.Process.Signal(syscall.SIGTERM)
 	case core.ProcessSignalKILL:
 		return r.cmd.Process.Signal(syscall.SIGKILL)
+	case core.ProcessSignalINT:
+		return r.cmd.Process.Signal(syscall.SIGINT)
 	default:
 		return fmt.Errorf("unsupported signal: %s", sig)
 	}
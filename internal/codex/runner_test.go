@@ -48,3 +48,79 @@ func TestBuildExecArgsNewSession(t *testing.T) {
 		t.Fatalf("unexpected args:\nwant: %#v\ngot:  %#v", want, args)
 	}
 }
+
+func TestBuildExecArgsAppendsRequestExtraArgs(t *testing.T) {
+	cfg := Config{ExtraArgs: []string{"--verbose"}}
+	req := core.RunRequest{
+		Prompt:               "hello",
+		JSON:                 true,
+		ModelReasoningEffort: schema.ModelReasoningEffort("low"),
+		ResumeSessionID:      "session-1",
+		ExtraArgs:            []string{"--sandbox", "workspace-write", "-c", "tools.web_search=true"},
+	}
+	args := buildExecArgs(cfg, req)
+	want := []string{
+		"exec",
+		"--json",
+		"-c",
+		"model_reasoning_effort=low",
+		"--verbose",
+		"--sandbox",
+		"workspace-write",
+		"-c",
+		"tools.web_search=true",
+		"resume",
+		"session-1",
+		"-",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args:\nwant: %#v\ngot:  %#v", want, args)
+	}
+}
+
+func TestBuildExecArgsWebSearchOverridesExtraArgs(t *testing.T) {
+	disabled := false
+	req := core.RunRequest{
+		JSON:                 true,
+		ModelReasoningEffort: schema.ModelReasoningEffort("low"),
+		ExtraArgs:            []string{"-c", "tools.web_search=true"},
+		EnableWebSearch:      &disabled,
+	}
+	args := buildExecArgs(Config{}, req)
+	want := []string{"exec", "--json", "-c", "model_reasoning_effort=low", "-c", "tools.web_search=true", "-c", "tools.web_search=false", "-"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args:\nwant: %#v\ngot:  %#v", want, args)
+	}
+}
+
+func TestValidateExtraArgs(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"--sandbox", "workspace-write"},
+		{"-c", "approval_policy=never", "--config=tools.web_search=true"},
+		{"--full-auto"},
+	}
+	for _, args := range valid {
+		if err := ValidateExtraArgs(args); err != nil {
+			t.Fatalf("expected %q to be valid: %v", args, err)
+		}
+	}
+	invalid := [][]string{
+		{"--json"},
+		{"--model", "gpt-5.2-codex"},
+		{"--model=gpt-5.2-codex"},
+		{"-m", "o3"},
+		{"resume", "--last"},
+		{"exec"},
+		{"-"},
+		{"-c", "model_reasoning_effort=high"},
+		{"--config=model=o3"},
+		{"-c"},
+		{" "},
+	}
+	for _, args := range invalid {
+		if err := ValidateExtraArgs(args); err == nil {
+			t.Fatalf("expected %q to be rejected", args)
+		}
+	}
+}
@@ -57,6 +57,53 @@ func TestHandleShellAuditLog(t *testing.T) {
 	}
 }
 
+func TestHandleAliasAuditLog(t *testing.T) {
+	capture := newLogCapture(t)
+	logger := pslog.NewWithOptions(capture, pslog.Options{
+		Mode:          pslog.ModeStructured,
+		NoColor:       true,
+		VerboseFields: true,
+		MinLevel:      pslog.DebugLevel,
+	})
+	ctx := pslog.ContextWithLogger(context.Background(), logger)
+
+	tabID := schema.TabID("tab1")
+	svc := &fakeService{
+		listTabsFn: func(_ context.Context, req schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{
+				Tabs: []schema.TabSnapshot{
+					{ID: tabID, Repo: schema.RepoRef{Name: "demo"}},
+				},
+			}, nil
+		},
+		appendOutputFn: func(_ context.Context, _ schema.AppendOutputRequest) (schema.AppendOutputResponse, error) {
+			return schema.AppendOutputResponse{}, nil
+		},
+		aliases: []schema.CommandAlias{{Name: "test", Expansion: "!go test"}},
+	}
+
+	handler := NewHandler(svc, fakeRunnerProvider{resp: core.RunnerResponse{Runner: &fakeRunner{}}}, HandlerConfig{
+		RepoRoot: "/repos",
+	})
+	if _, err := handler.Handle(ctx, "alice", tabID, "/test ./..."); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	entries := capture.Entries()
+	found := false
+	for _, entry := range entries {
+		if entry.Message == "audit command" && entry.Fields["command_type"] == "alias" && entry.Fields["alias"] == "/test ./..." && entry.Fields["command"] == "!go test ./..." {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected audit log for alias expansion, got %d entries", len(entries))
+	}
+	if !hasAuditCommand(entries, "shell", "go test ./...", "/repos/alice/demo") {
+		t.Fatalf("expected audit log for expanded shell command, got %d entries", len(entries))
+	}
+}
+
 type logEntry struct {
 	Level   string
 	Message string
@@ -0,0 +1,610 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// CommandSpec describes a slash command for /help and command suggestions.
+type CommandSpec struct {
+	Name    string
+	Aliases []string
+	// Usage is the argument syntax shown after the command name.
+	Usage string
+	// Summary is the one-line description in the /help list.
+	Summary string
+	// Description is the longer text shown by /help <command>.
+	Description string
+	// Examples are complete command lines shown by /help <command>.
+	Examples []string
+	// Flags lists the --flags the command accepts; Parse rejects others.
+	Flags []FlagSpec
+	// Verbatim commands take free text (commit messages, regexes, alias
+	// expansions, keys). Parse splits their arguments on whitespace only and
+	// keeps quotes and --flags literal.
+	Verbatim bool
+	// NeedsTab commands act on the current tab. Handle rejects them with
+	// ErrNoActiveTab when there is none; the others run in the system
+	// context and write to the system buffer.
+	NeedsTab bool
+}
+
+// FlagSpec describes a --flag of a command.
+type FlagSpec struct {
+	Name string
+	// Value names the flag's argument; empty for boolean flags.
+	Value string
+}
+
+func (s CommandSpec) flag(name string) (FlagSpec, bool) {
+	for _, flag := range s.Flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return FlagSpec{}, false
+}
+
+// commandSpecs lists the slash commands in /help order. Commands handled by
+// the frontends (/quit, /chpasswd, /codexauth, /tabfilter) are listed here too so /help
+// documents everything a user can type.
+var commandSpecs = []CommandSpec{
+	{
+		Name:        "new",
+		Aliases:     []string{"n"},
+		Usage:       "<repo|git-url> [--ephemeral]",
+		Summary:     "create or open a repo (git URLs clone over SSH)",
+		Description: "Opens a tab for a repo under your repo root, creating an empty git repo when it does not exist. A git URL clones the repo over SSH using your git SSH key (see /pubkey). With --ephemeral the tab's buffer, history and prompts are never written to disk; after a restart the tab comes back empty.",
+		Examples:    []string{"/new demo", "/new git@github.com:acme/demo.git", "/new demo --ephemeral"},
+		Flags:       []FlagSpec{{Name: "ephemeral"}},
+	},
+	{
+		Name:        "listrepos",
+		Usage:       "[--sort name|size|date] [--size]",
+		Summary:     "list repos",
+		Description: "Lists the repos under your repo root with their size on disk, when they last changed and the tabs you have open on them, newest first. --sort orders by name, size or date. Sizes are only measured for up to 20 repos unless --size is given, since walking every repo takes time.",
+		Examples:    []string{"/listrepos", "/listrepos --sort name", "/listrepos --size"},
+		Flags:       []FlagSpec{{Name: "sort", Value: "order"}, {Name: "size"}},
+	},
+	{
+		Name:        "rm",
+		Usage:       "<number_or_name> [--force]",
+		Summary:     "close a tab",
+		Description: "Closes a tab by its position in the tab bar or by its name. The repo on disk is kept. Closing a running tab stops the run, so it has to be confirmed by repeating the command within 10 seconds or with --force.",
+		Examples:    []string{"/rm 2", "/rm demo --force"},
+		Flags:       []FlagSpec{{Name: "force"}},
+	},
+	{
+		Name:        "close",
+		Usage:       "[--force]",
+		Summary:     "close current tab",
+		Description: "Closes the current tab. The repo on disk is kept. Closing a running tab stops the run, so it has to be confirmed by repeating the command within 10 seconds or with --force.",
+		Examples:    []string{"/close", "/close --force"},
+		Flags:       []FlagSpec{{Name: "force"}},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "reopen",
+		Usage:       "[n] | list",
+		Summary:     "reopen the most recently closed tab (or the nth)",
+		Description: "Restores a closed tab with its scrollback, prompt history, codex session and repo. Closed tabs are kept for a limited time; list shows them numbered from the most recently closed.",
+		Examples:    []string{"/reopen", "/reopen list", "/reopen 2"},
+	},
+	{
+		Name:        "quit",
+		Aliases:     []string{"exit", "logout"},
+		Summary:     "exit session / log out",
+		Description: "Ends the SSH session, or logs out of the web UI.",
+		Examples:    []string{"/quit"},
+	},
+	{
+		Name:        "status",
+		Aliases:     []string{"s"},
+		Usage:       "[--refresh]",
+		Summary:     "show current session status",
+		Description: "Shows the model, directory, session id and token use of the current tab, and your account usage limits when the runner can report them. Usage limits are cached for 30 minutes; --refresh reads them again.",
+		Examples:    []string{"/status", "/status --refresh"},
+		Flags:       []FlagSpec{{Name: "refresh"}},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "runnerstatus",
+		Summary:     "check the runner container's clock and CA certificates",
+		Description: "Checks the clock and the CA certificates of the current tab's runner container, starting it when needed. A skewed clock or missing certificates break TLS and codex login in ways that look like auth errors.",
+		Examples:    []string{"/runnerstatus"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "events",
+		Usage:       "[n]",
+		Summary:     "show the last n activity events (default " + strconv.Itoa(defaultEventsListLimit) + ")",
+		Description: "Lists recent activity feed events such as runner containers starting, git SSH key rotations and usage quota warnings, oldest first.",
+		Examples:    []string{"/events", "/events 25"},
+	},
+	{
+		Name:        "serverlog",
+		Usage:       "[n] [level]",
+		Summary:     "show the last n server log entries (default " + strconv.Itoa(defaultServerLogLimit) + ", admins only)",
+		Description: "Writes the latest entries of the server's in-memory log at or above level (trace, debug, info, warn or error; default info) to your system buffer, oldest first. Sensitive fields are redacted. Only available to admin accounts.",
+		Examples:    []string{"/serverlog", "/serverlog 200", "/serverlog 100 warn"},
+	},
+	{
+		Name:        "debug",
+		Usage:       "state",
+		Summary:     "show where your state is stored and how big it is (admins only)",
+		Description: "Shows the size of your persisted tab snapshot and home directory, whether a codex auth.json and a git SSH key exist, and where they live under the state directory. Only available to admin accounts; `centaurx debug state` reports every user.",
+		Examples:    []string{"/debug state"},
+	},
+	{
+		Name:        "model",
+		Aliases:     []string{"m"},
+		Usage:       "<model> [reasoning]",
+		Summary:     "set model for current tab",
+		Description: "Sets the codex model and optionally the reasoning effort (" + modelReasoningEffortUsage + ") used by the current tab from the next prompt on.",
+		Examples:    []string{"/model gpt-5.2-codex", "/model gpt-5.2-codex high"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "stop",
+		Aliases:     []string{"z"},
+		Usage:       "[--grace <duration> | --now]",
+		Summary:     "stop running codex exec",
+		Description: "Stops the codex run or shell command running in the current tab. It is sent SIGTERM, then SIGKILL if it has not exited after the grace period (runner.stop_grace_period_seconds, default 10s). --grace sets the period for this stop; --now sends SIGKILL right away.",
+		Examples:    []string{"/stop", "/z", "/stop --grace 30s", "/stop --now"},
+		Flags:       []FlagSpec{{Name: "grace", Value: "duration"}, {Name: "now"}},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "renew",
+		Summary:     "start a fresh codex session for the current tab",
+		Description: "Forgets the codex session of the current tab so the next prompt starts a new conversation. Scrollback is kept.",
+		Examples:    []string{"/renew"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "redo",
+		Summary:     "resend the last prompt",
+		Description: "Sends the most recent prompt of the current tab again, for example after a run failed for reasons outside the prompt. It is recorded in history like a typed prompt, and the SSH terminal queues it while the tab is busy.",
+		Examples:    []string{"/redo"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "edit-last",
+		Summary:     "edit the last prompt before resending it",
+		Description: "Loads the most recent prompt of the current tab into the prompt editor so it can be changed before sending. Clients without an editor hook print the prompt instead.",
+		Examples:    []string{"/edit-last"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "chpasswd",
+		Summary:     "change your password",
+		Description: "Prompts for your current password, a new password and a TOTP code.",
+		Examples:    []string{"/chpasswd"},
+	},
+	{
+		Name:        "codexauth",
+		Summary:     "upload codex auth.json",
+		Description: "Uploads a codex auth.json so runners can use your ChatGPT login.",
+		Examples:    []string{"/codexauth"},
+	},
+	{
+		Name:        "git",
+		Usage:       "commit [message] | init",
+		Summary:     "commit changes or initialize the repo",
+		Description: "commit stages all changes in the current tab's repo and commits them. Without a message, codex writes one from the diff using the commit model. init turns a directory without version control into a git repository with an empty initial commit on branch centaurx.",
+		Examples:    []string{"/git commit", "/git commit Fix flaky test", "/git init"},
+		Verbatim:    true,
+		NeedsTab:    true,
+	},
+	{
+		Name:        "autocommit",
+		Usage:       "[on|off]",
+		Summary:     "commit the changes of every successful run in this tab",
+		Description: "With autocommit on, every prompt run that completes successfully and leaves changes in the repo is followed by the same steps as /git commit, with a message written by the commit model and prefixed [auto]. A failed auto-commit is reported in the tab but does not fail the run. Without arguments, shows the setting.",
+		Examples:    []string{"/autocommit on", "/autocommit off", "/autocommit"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "gitignore",
+		Usage:       "suggest",
+		Summary:     "propose a .gitignore",
+		Description: "suggest asks the commit model for a .gitignore that leaves out the untracked build artifacts in the current tab's repo. The proposal is shown, not written.",
+		Examples:    []string{"/gitignore suggest"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "addloginpubkey",
+		Usage:       "<pubkey>",
+		Summary:     "add an SSH login public key",
+		Description: "Adds an SSH public key that may log in to your account. SSH logins still ask for a TOTP code.",
+		Examples:    []string{"/addloginpubkey ssh-ed25519 AAAAC3Nza... alice@laptop"},
+		Verbatim:    true,
+	},
+	{
+		Name:        "listloginpubkeys",
+		Summary:     "list SSH login public keys",
+		Description: "Lists your SSH login public keys with the ids used by /rmloginpubkey.",
+		Examples:    []string{"/listloginpubkeys"},
+	},
+	{
+		Name:        "rmloginpubkey",
+		Usage:       "<id>",
+		Summary:     "remove SSH login public key by id",
+		Description: "Removes an SSH login public key by the id shown by /listloginpubkeys.",
+		Examples:    []string{"/rmloginpubkey 1"},
+	},
+	{
+		Name:        "pubkey",
+		Summary:     "show your git SSH public key",
+		Description: "Shows the public half of the SSH key used for git over SSH, to add as a deploy or user key on your git host.",
+		Examples:    []string{"/pubkey"},
+	},
+	{
+		Name:        "onboarding",
+		Usage:       "[off]",
+		Summary:     "show or turn off the getting started checklist",
+		Description: "New users get a getting started checklist below the system buffer: set up codex auth with /codexauth, add the git key from /pubkey to your git host and open a repo with /new. Each step is checked off as it is done and the checklist goes away when all are. off hides it for good. Without arguments, shows how far you are.",
+		Examples:    []string{"/onboarding", "/onboarding off"},
+	},
+	{
+		Name:        "rotatesshkey",
+		Usage:       "[affirm]",
+		Summary:     "rotate your git SSH key (affirm skips prompt)",
+		Description: "Replaces your git SSH key with a new one. The old key stops working, so add the new public key to your git host afterwards.",
+		Examples:    []string{"/rotatesshkey", "/rotatesshkey affirm"},
+	},
+	{
+		Name:        "togglefullcommandoutput",
+		Summary:     "toggle full command output",
+		Description: "Switches between truncated and full output of commands run by codex.",
+		Examples:    []string{"/togglefullcommandoutput"},
+	},
+	{
+		Name:        "togglefullreasoning",
+		Summary:     "toggle full reasoning output",
+		Description: "Switches between truncated and full codex reasoning output.",
+		Examples:    []string{"/togglefullreasoning"},
+	},
+	{
+		Name:        "history",
+		Usage:       "[n]",
+		Summary:     "show the last n prompts of the current tab (default " + strconv.Itoa(defaultHistoryListLimit) + ")",
+		Description: "Lists the latest prompts sent in the current tab, oldest first.",
+		Examples:    []string{"/history", "/history 30"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "filter",
+		Usage:       "add <regex> | list | rm <n>",
+		Summary:     "hide matching command output lines in this tab",
+		Description: "Manages regular expressions that hide matching command output lines in the current tab. Filters are listed with numbers used by rm.",
+		Examples:    []string{"/filter add ^npm WARN", "/filter rm 1"},
+		Verbatim:    true,
+		NeedsTab:    true,
+	},
+	{
+		Name:        "grep",
+		Usage:       "[-c] [-e] <query> | open <n>",
+		Summary:     "search the output and prompts of all your tabs",
+		Description: "Searches the scrollback and prompt history of all your tabs, newest first, and lists the matches numbered and grouped by tab. The search ignores case unless -c is given, and -e takes the query as a regular expression. open switches to the tab of a match and scrolls to it. Only the latest " + strconv.Itoa(schema.DefaultSearchMaxLinesPerTab) + " lines of each tab are searched.",
+		Examples:    []string{"/grep migration script", "/grep -c TODO", "/grep -e fail(ed|ure)", "/grep open 2"},
+		Verbatim:    true,
+	},
+	{
+		Name:        "timestamps",
+		Summary:     "toggle append-time prefixes on output lines",
+		Description: "Shows or hides the time each output line was appended.",
+		Examples:    []string{"/timestamps"},
+	},
+	{
+		Name:        "toggleglobalhistory",
+		Summary:     "toggle Up/Down history between the current tab and all tabs",
+		Description: "Switches the prompt history recalled with Up/Down between the current tab and all of your tabs.",
+		Examples:    []string{"/toggleglobalhistory"},
+	},
+	{
+		Name:        "theme",
+		Usage:       "[<name>|preview <name>]",
+		Summary:     "set UI theme",
+		Description: "Without arguments, lists the themes. With a name, sets the color theme of the UI. In the SSH terminal, preview shows sample output in a theme until the next keypress without changing the setting; themes marked with * need a truecolor terminal.",
+		Examples:    []string{"/theme", "/theme outrun", "/theme preview gruvbox"},
+	},
+	{
+		Name:        "tz",
+		Usage:       "[<zone>|default]",
+		Summary:     "show or set the time zone times are shown in",
+		Description: "Without arguments, shows the time zone in use. With an IANA zone name, shows times in that zone in this session and remembers it for future sessions; default returns to the server's zone.",
+		Examples:    []string{"/tz", "/tz Europe/Stockholm", "/tz default"},
+	},
+	{
+		Name:        "summary",
+		Usage:       "[on|off|<YYYY-MM-DD>]",
+		Summary:     "show this tab's daily summary, or turn daily summaries on or off",
+		Description: "When the server has summaries enabled, tabs with /summary on get a short summary of the last day's prompts and answers, written once a day with a small model. Without arguments, shows the latest summary; with a date, the summary written that day.",
+		Examples:    []string{"/summary on", "/summary", "/summary 2026-01-31"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "tools",
+		Usage:       "[<tool> on|off]",
+		Summary:     "list or toggle codex tools, such as web search, for this tab",
+		Description: "Without arguments, lists the tools that can be toggled and their setting in the current tab. With a tool name and on or off, changes the setting for the tab's next prompts; tools never set use the codex default.",
+		Examples:    []string{"/tools", "/tools web on", "/tools web off"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "tag",
+		Usage:       "[add|rm <label>]",
+		Summary:     "label this tab to group it with others",
+		Description: "Without arguments, lists the current tab's labels. add and rm put a label on the tab or take it off. Labels are short names of lowercase letters, digits, - and _, a tab has at most five, and the SSH terminal shows them as colored dots in the tab bar.",
+		Examples:    []string{"/tag", "/tag add backend", "/tag rm backend"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "tabs",
+		Usage:       "[label]",
+		Summary:     "list your tabs, optionally only those with a label",
+		Description: "Lists your tabs with the numbers /rm and /reopen take and their labels, marking the current tab with *. With a label, lists only the tabs carrying it.",
+		Examples:    []string{"/tabs", "/tabs backend"},
+	},
+	{
+		Name:        "tab",
+		Usage:       "<n>",
+		Summary:     "switch to a tab by its number",
+		Description: "Switches to the tab with the number /tabs and /rm use. In the SSH terminal, Alt+1 to Alt+9 switch to tabs 1 to 9 and Alt+0 to tab 10; /tab does the same in terminals that do not send Alt.",
+		Examples:    []string{"/tab 3"},
+	},
+	{
+		Name:        "tabfilter",
+		Usage:       "<label>|off",
+		Summary:     "show only tabs with a label in the tab bar",
+		Description: "In the SSH terminal, limits the tab bar and tab switching to the tabs carrying a label; the current tab always stays visible. off shows all tabs again. The filter lasts until you log out.",
+		Examples:    []string{"/tabfilter backend", "/tabfilter off"},
+	},
+	{
+		Name:        "batch",
+		Usage:       "repos add|rm <repo>... | repos [list] | run <prompt> | stop",
+		Summary:     "send one prompt to several repos",
+		Description: "Keeps a list of repos and sends a prompt to each of them with run, in an idle tab of the repo or a new one, a few at a time as the server allows. A summary of every repo's outcome goes to the system buffer when all have finished. stop cancels the repos not yet started and lets running ones finish. Without arguments, shows the progress of the latest batch. A batch survives a server restart and continues with the remaining repos.",
+		Examples:    []string{"/batch repos add api web", "/batch run Bump the Go toolchain to the latest release", "/batch", "/batch stop"},
+		Verbatim:    true,
+	},
+	{
+		Name:        "archive",
+		Usage:       "[--worktree] [path] | tab [number_or_name] | list | restore <number_or_name>",
+		Summary:     "download a tarball of the repo, or archive and restore tabs",
+		Description: "Packs HEAD of the current tab's repo, or the working tree including uncommitted changes with --worktree, optionally limited to a path, and prints a link that downloads it once. tab archives the current tab, or the one given by its position in the tab bar or its name: it leaves the tab bar but keeps its scrollback, prompt history and codex session, and its runner is released. list shows the archived tabs numbered, and restore brings one back by that number or its name; switching to an archived tab restores it too. To pack a path named tab, list or restore, write it as ./tab.",
+		Examples:    []string{"/archive", "/archive --worktree docs", "/archive tab", "/archive tab 2", "/archive list", "/archive restore demo"},
+		Flags:       []FlagSpec{{Name: "worktree"}},
+	},
+	{
+		Name:        "share",
+		Usage:       "<user> [rw]",
+		Summary:     "share the current tab with another user (read-only unless rw)",
+		Description: "Shows the current tab to another user. With rw they may also send prompts and commands.",
+		Examples:    []string{"/share bob", "/share bob rw"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "unshare",
+		Usage:       "<user>",
+		Summary:     "stop sharing the current tab with a user",
+		Description: "Removes a user's access to the current tab.",
+		Examples:    []string{"/unshare bob"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "sharelink",
+		Usage:       "[ttl] | revoke",
+		Summary:     "create a read-only web link to the current tab",
+		Description: "Prints a link where anyone holding it can watch the current tab's output live in a browser, without an account and without sending input. Links expire after ttl (default 1h, at most 168h); revoke ends every link to the tab. Closing the tab also ends its links.",
+		Examples:    []string{"/sharelink", "/sharelink 24h", "/sharelink revoke"},
+		NeedsTab:    true,
+	},
+	{
+		Name:        "alias",
+		Usage:       "set <name> <expansion> | list | rm <name>",
+		Summary:     "manage your own command aliases",
+		Description: "Defines shortcuts that expand to a slash command or ! shell command, with any arguments appended. Aliases are saved with your account, cannot shadow built-in commands and do not expand other aliases.",
+		Examples:    []string{"/alias set test !go test ./...", "/alias rm test"},
+		Verbatim:    true,
+	},
+	{
+		Name:        "version",
+		Summary:     "show version information",
+		Description: "Shows the centaurx version.",
+		Examples:    []string{"/version"},
+	},
+	{
+		Name:        "help",
+		Usage:       "[command]",
+		Summary:     "list commands or show help for one command",
+		Description: "Without arguments lists all commands. With a command name shows its usage, a description and examples.",
+		Examples:    []string{"/help", "/help git"},
+	},
+}
+
+// lookupCommandSpec finds a command by name or alias. A leading slash is
+// ignored.
+func lookupCommandSpec(name string) (CommandSpec, bool) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/"))
+	for _, spec := range commandSpecs {
+		if spec.Name == name {
+			return spec, true
+		}
+		for _, alias := range spec.Aliases {
+			if alias == name {
+				return spec, true
+			}
+		}
+	}
+	return CommandSpec{}, false
+}
+
+// suggestCommand returns the command name or alias closest to name by edit
+// distance, or "" when nothing is close enough to be a likely typo.
+func suggestCommand(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if name == "" {
+		return ""
+	}
+	best, bestDistance := "", 0
+	for _, spec := range commandSpecs {
+		for _, candidate := range append([]string{spec.Name}, spec.Aliases...) {
+			distance := editDistance(name, candidate)
+			if best == "" || distance < bestDistance {
+				best, bestDistance = candidate, distance
+			}
+		}
+	}
+	if bestDistance > max(2, len(name)/3) || bestDistance >= len(name) {
+		return ""
+	}
+	return best
+}
+
+// unknownCommandError reports an unknown command, suggesting the closest
+// known one.
+func unknownCommandError(name string) error {
+	if suggestion := suggestCommand(name); suggestion != "" {
+		return fmt.Errorf("unknown command: /%s (did you mean /%s?)", name, suggestion)
+	}
+	return fmt.Errorf("unknown command: /%s", name)
+}
+
+// noActiveTabError reports a NeedsTab command run without a tab. It matches
+// schema.ErrNoActiveTab and carries its code, but names the command.
+type noActiveTabError string
+
+func (e noActiveTabError) Error() string {
+	return fmt.Sprintf("/%s requires an active tab — open one with /new", string(e))
+}
+
+func (e noActiveTabError) Is(target error) bool {
+	return target == schema.ErrNoActiveTab
+}
+
+// ErrorCode implements schema.Coder.
+func (e noActiveTabError) ErrorCode() string {
+	return schema.CodeTabNotFound
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// shellCommandName is the /help topic of `!` shell commands.
+const shellCommandName = "!"
+
+// helpLines renders the /help command list. User aliases are listed under
+// the command they expand to.
+func helpLines(models []schema.ModelID, aliases []schema.CommandAlias) []schema.BufferLine {
+	lines := make([]schema.BufferLine, 0, len(commandSpecs)+len(aliases)+2)
+	lines = append(lines, schema.Line(schema.LineKindSeparator, "Commands"))
+	for _, spec := range commandSpecs {
+		summary := spec.Summary
+		if available := availableValues(spec.Name, models); available != "" {
+			summary += " (" + available + ")"
+		}
+		lines = append(lines, schema.Line(schema.LineKindHelp, commandSyntax(spec)+" - "+summary))
+		lines = append(lines, userAliasLines(spec.Name, aliases)...)
+	}
+	lines = append(lines, schema.Line(schema.LineKindHelp, "**!** `<cmd>` - run a shell command in the repo"))
+	lines = append(lines, schema.Line(schema.LineKindHelp, "**!!** `<cmd>` - run it on a terminal (colors, Ctrl+C interrupts it)"))
+	lines = append(lines, schema.Line(schema.LineKindHelp, "**!** `<cmd> <<` - pipe the lines that follow to its stdin (end with a lone . or Ctrl+D)"))
+	lines = append(lines, schema.Line(schema.LineKindHelp, "**!**`{save=<path>}` `<cmd>` - also save the full output to a file in the repo"))
+	lines = append(lines, userAliasLines(shellCommandName, aliases)...)
+	return lines
+}
+
+// commandHelpLines renders /help <command>.
+func commandHelpLines(spec CommandSpec, models []schema.ModelID, aliases []schema.CommandAlias) []schema.BufferLine {
+	lines := []schema.BufferLine{
+		schema.Line(schema.LineKindSeparator, "/"+spec.Name),
+		schema.Line(schema.LineKindHelp, commandSyntax(spec)+" - "+spec.Summary),
+		schema.Line(schema.LineKindHelp, spec.Description),
+	}
+	if available := availableValues(spec.Name, models); available != "" {
+		lines = append(lines, schema.Line(schema.LineKindHelp, available))
+	}
+	lines = append(lines, userAliasLines(spec.Name, aliases)...)
+	if len(spec.Examples) > 0 {
+		lines = append(lines, schema.Line(schema.LineKindHelp, "Examples:"))
+		for _, example := range spec.Examples {
+			lines = append(lines, schema.Line(schema.LineKindHelp, "`"+example+"`"))
+		}
+	}
+	return lines
+}
+
+// userAliasLines renders the user aliases that expand to the command name.
+func userAliasLines(name string, aliases []schema.CommandAlias) []schema.BufferLine {
+	var lines []schema.BufferLine
+	for _, alias := range aliases {
+		if aliasTarget(alias.Expansion) == name {
+			lines = append(lines, schema.Line(schema.LineKindHelp, "  alias **/"+alias.Name+"** - `"+alias.Expansion+"`"))
+		}
+	}
+	return lines
+}
+
+// aliasTarget returns the command an alias expansion runs: the canonical
+// slash command name, or shellCommandName for shell commands.
+func aliasTarget(expansion string) string {
+	if strings.HasPrefix(expansion, "!") {
+		return shellCommandName
+	}
+	parsed, _ := Parse(expansion)
+	if !parsed.IsCommand {
+		return ""
+	}
+	if spec, ok := lookupCommandSpec(parsed.Command.Name); ok {
+		return spec.Name
+	}
+	return parsed.Command.Name
+}
+
+func commandSyntax(spec CommandSpec) string {
+	names := make([]string, 0, 1+len(spec.Aliases))
+	for _, name := range append([]string{spec.Name}, spec.Aliases...) {
+		names = append(names, "**/"+name+"**")
+	}
+	syntax := strings.Join(names, ", ")
+	if spec.Usage != "" {
+		syntax += " `" + spec.Usage + "`"
+	}
+	return syntax
+}
+
+// availableValues lists the values a command accepts when they depend on
+// configuration.
+func availableValues(name string, models []schema.ModelID) string {
+	switch name {
+	case "model":
+		return "available: " + strings.Join(formatModels(models), ", ") + "; reasoning: " + modelReasoningEffortUsage
+	case "theme":
+		return "available: " + strings.Join(formatThemes(schema.AvailableThemes()), ", ")
+	default:
+		return ""
+	}
+}
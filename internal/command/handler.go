@@ -1,21 +1,30 @@
 package command
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/archivestore"
+	"pkt.systems/centaurx/internal/logring"
 	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/repo"
 	"pkt.systems/centaurx/internal/sessionprefs"
 	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/internal/statereport"
+	"pkt.systems/centaurx/internal/timefmt"
 	"pkt.systems/centaurx/internal/version"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
@@ -24,6 +33,14 @@ import (
 const defaultCommitModel schema.ModelID = "gpt-5.1-codex-mini"
 const usageBarWidth = 10
 const modelReasoningEffortUsage = "low|medium|high|xhigh"
+const defaultHistoryListLimit = 10
+
+// closeConfirmWindow is how long a repeated /rm or /close confirms closing a
+// running tab.
+const closeConfirmWindow = 10 * time.Second
+
+// forceFlag skips the confirmation of /rm and /close.
+const forceFlag = "--force"
 
 // HandlerConfig configures slash command behavior.
 type HandlerConfig struct {
@@ -34,6 +51,25 @@ type HandlerConfig struct {
 	GitKeyStore         GitKeyStore
 	GitKeyRotator       GitKeyRotator
 	DisableAuditLogging bool
+	// ArchiveStore holds archives created by /archive until downloaded.
+	// /archive is unavailable when nil.
+	ArchiveStore archivestore.Store
+	// ArchiveURLPrefix is prepended to archive download tokens.
+	ArchiveURLPrefix string
+	// ViewURLPrefix is prepended to /sharelink tokens. /sharelink is
+	// unavailable when empty.
+	ViewURLPrefix string
+	// ServerLog holds the latest server log entries for /serverlog, which
+	// is unavailable when nil.
+	ServerLog ServerLog
+	// StateDir and GitKeyDir locate the state measured by /debug state.
+	StateDir  string
+	GitKeyDir string
+}
+
+// ServerLog returns the latest server log entries.
+type ServerLog interface {
+	Last(n int, level pslog.Level) []logring.Entry
 }
 
 // LoginPubKeyStore manages SSH login public keys per user.
@@ -58,17 +94,20 @@ type Handler struct {
 	service core.Service
 	runners core.RunnerProvider
 	cfg     HandlerConfig
+	now     func() time.Time
 
-	usageMu    sync.Mutex
-	usageCache map[schema.UserID]usageCacheEntry
-	usageTTL   time.Duration
-	now        func() time.Time
+	confirmMu sync.Mutex
+	// closeConfirms records when closing a running tab was first requested.
+	closeConfirms map[closeConfirmKey]time.Time
+
+	grepMu sync.Mutex
+	// grepResults holds each user's latest /grep matches for /grep open.
+	grepResults map[schema.UserID][]schema.SearchMatch
 }
 
-type usageCacheEntry struct {
-	fetchedAt time.Time
-	info      core.UsageInfo
-	err       error
+type closeConfirmKey struct {
+	userID schema.UserID
+	tabID  schema.TabID
 }
 
 // NewHandler constructs a command handler.
@@ -77,12 +116,12 @@ func NewHandler(service core.Service, runners core.RunnerProvider, cfg HandlerCo
 		cfg.CommitModel = defaultCommitModel
 	}
 	return &Handler{
-		service:    service,
-		runners:    runners,
-		cfg:        cfg,
-		usageCache: make(map[schema.UserID]usageCacheEntry),
-		usageTTL:   30 * time.Minute,
-		now:        time.Now,
+		service:       service,
+		runners:       runners,
+		cfg:           cfg,
+		now:           time.Now,
+		closeConfirms: make(map[closeConfirmKey]time.Time),
+		grepResults:   make(map[schema.UserID][]schema.SearchMatch),
 	}
 }
 
@@ -95,41 +134,71 @@ func (h *Handler) Handle(ctx context.Context, userID schema.UserID, tabID schema
 	ctx = logx.ContextWithUserTabLogger(ctx, baseLog, userID, tabID)
 	log := baseLog.With("input_len", len(input))
 	trimmed := strings.TrimLeft(input, " \t")
+	if expanded, ok := h.expandAlias(ctx, userID, trimmed); ok {
+		if !h.cfg.DisableAuditLogging {
+			log.Debug("audit command", "command_type", "alias", "alias", strings.TrimSpace(trimmed), "command", expanded)
+		}
+		input, trimmed = expanded, expanded
+		log = baseLog.With("input_len", len(input))
+	}
 	if strings.HasPrefix(trimmed, "!") {
 		log.Info("command shell request")
 		return true, h.handleShell(ctx, userID, tabID, trimmed)
 	}
-	cmd, ok := Parse(input)
-	if !ok {
+	parsed, err := Parse(input)
+	if !parsed.IsCommand {
 		return false, nil
 	}
+	cmd := parsed.Command
 	if !h.cfg.DisableAuditLogging {
 		log.Debug("audit command", "command_type", "slash", "command", strings.TrimSpace(input))
 	}
 	log = log.With("command", cmd.Name, "args", len(cmd.Args))
 	log.Info("command slash request")
-	switch cmd.Name {
+	name := cmd.Name
+	if spec, ok := lookupCommandSpec(name); ok {
+		name = spec.Name
+		if err != nil {
+			log.Warn("command slash rejected", "reason", "syntax", "err", err)
+			return true, err
+		}
+		if spec.NeedsTab && tabID == "" {
+			log.Warn("command slash rejected", "reason", "no active tab")
+			return true, noActiveTabError(spec.Name)
+		}
+	}
+	switch name {
 	case "":
 		log.Warn("command slash rejected", "reason", "empty")
 		return true, fmt.Errorf("invalid command")
 	case "new":
 		return true, h.handleNew(ctx, userID, tabID, cmd)
 	case "listrepos":
-		return true, h.handleListRepos(ctx, userID, tabID)
+		return true, h.handleListRepos(ctx, userID, tabID, cmd)
 	case "rm":
 		return true, h.handleRemove(ctx, userID, tabID, cmd)
 	case "close":
 		return true, h.handleClose(ctx, userID, tabID, cmd)
+	case "reopen":
+		return true, h.handleReopen(ctx, userID, tabID, cmd)
 	case "help":
-		return true, h.handleHelp(ctx, userID, tabID)
+		return true, h.handleHelp(ctx, userID, tabID, cmd)
 	case "model":
 		return true, h.handleModel(ctx, userID, tabID, cmd)
-	case "stop", "z":
-		return true, h.handleStop(ctx, userID, tabID)
+	case "stop":
+		return true, h.handleStop(ctx, userID, tabID, cmd)
 	case "renew":
 		return true, h.handleRenew(ctx, userID, tabID)
+	case "redo":
+		return true, h.handleRedo(ctx, userID, tabID)
+	case "edit-last":
+		return true, h.handleEditLast(ctx, userID, tabID)
 	case "git":
 		return true, h.handleGit(ctx, userID, tabID, cmd)
+	case "autocommit":
+		return true, h.handleAutoCommit(ctx, userID, tabID, cmd)
+	case "gitignore":
+		return true, h.handleGitignore(ctx, userID, tabID, cmd)
 	case "addloginpubkey":
 		return true, h.handleAddLoginPubKey(ctx, userID, tabID, cmd)
 	case "listloginpubkeys":
@@ -140,28 +209,78 @@ func (h *Handler) Handle(ctx context.Context, userID schema.UserID, tabID schema
 		return true, h.handlePubKey(ctx, userID, tabID)
 	case "rotatesshkey":
 		return true, h.handleRotateSSHKey(ctx, userID, tabID, cmd)
+	case "onboarding":
+		return true, h.handleOnboarding(ctx, userID, tabID, cmd)
 	case "theme":
 		return true, h.handleTheme(ctx, userID, tabID, cmd)
+	case "tz":
+		return true, h.handleTimezone(ctx, userID, tabID, cmd)
+	case "summary":
+		return true, h.handleSummary(ctx, userID, tabID, cmd)
+	case "tools":
+		return true, h.handleTools(ctx, userID, tabID, cmd)
+	case "tag":
+		return true, h.handleTag(ctx, userID, tabID, cmd)
+	case "tabs":
+		return true, h.handleTabs(ctx, userID, tabID, cmd)
+	case "tab":
+		return true, h.handleTab(ctx, userID, tabID, cmd)
+	case "tabfilter":
+		log.Warn("command slash rejected", "reason", "terminal only")
+		return true, errors.New("/tabfilter is only available in the SSH terminal; use /tabs <label> to list tabs with a label")
+	case "batch":
+		return true, h.handleBatch(ctx, userID, tabID, cmd)
 	case "togglefullcommandoutput":
 		return true, h.handleToggleFullCommandOutput(ctx, userID, tabID)
+	case "togglefullreasoning":
+		return true, h.handleToggleFullReasoning(ctx, userID, tabID)
+	case "timestamps":
+		return true, h.handleToggleTimestamps(ctx, userID, tabID)
+	case "toggleglobalhistory":
+		return true, h.handleToggleGlobalHistory(ctx, userID, tabID)
+	case "history":
+		return true, h.handleHistory(ctx, userID, tabID, cmd)
+	case "filter":
+		return true, h.handleFilter(ctx, userID, tabID, cmd)
+	case "grep":
+		return true, h.handleGrep(ctx, userID, tabID, cmd)
+	case "archive":
+		return true, h.handleArchive(ctx, userID, tabID, cmd)
+	case "share":
+		return true, h.handleShare(ctx, userID, tabID, cmd)
+	case "unshare":
+		return true, h.handleUnshare(ctx, userID, tabID, cmd)
+	case "sharelink":
+		return true, h.handleShareLink(ctx, userID, tabID, cmd)
+	case "events":
+		return true, h.handleEvents(ctx, userID, tabID, cmd)
+	case "debug":
+		return true, h.handleDebug(ctx, userID, tabID, cmd)
+	case "serverlog":
+		return true, h.handleServerLog(ctx, userID, tabID, cmd)
 	case "status":
-		return true, h.handleStatus(ctx, userID, tabID)
+		return true, h.handleStatus(ctx, userID, tabID, cmd)
+	case "runnerstatus":
+		return true, h.handleRunnerStatus(ctx, userID, tabID)
 	case "version":
 		return true, h.handleVersion(ctx, userID, tabID)
+	case "alias":
+		return true, h.handleAlias(ctx, userID, tabID, cmd)
 	default:
 		log.Warn("command slash rejected", "reason", "unknown")
-		return true, fmt.Errorf("unknown command: /%s", cmd.Name)
+		return true, unknownCommandError(cmd.Name)
 	}
 }
 
 func (h *Handler) handleNew(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	if len(cmd.Args) < 1 {
-		return fmt.Errorf("usage: /new <repo|git-url>")
+		return fmt.Errorf("usage: /new <repo|git-url> [--ephemeral]")
 	}
 	repoArg := cmd.Args[0]
-	log := logx.WithUserTab(ctx, userID, tabID).With("repo_arg", repoArg)
+	ephemeral := cmd.HasFlag("ephemeral")
+	log := logx.WithUserTab(ctx, userID, tabID).With("repo_arg", repoArg, "ephemeral", ephemeral)
 	if looksLikeGitURL(repoArg) {
-		h.appendStatus(ctx, userID, "", fmt.Sprintf("cloning repo %s", repoArg))
+		h.appendStatus(ctx, userID, "", cloningStatus(repoArg))
 	} else {
 		h.appendStatus(ctx, userID, "", fmt.Sprintf("preparing repo %s", repoArg))
 	}
@@ -171,8 +290,9 @@ func (h *Handler) handleNew(ctx context.Context, userID schema.UserID, tabID sch
 	log = log.With("is_url", isURL)
 	if isURL {
 		resp, err = h.service.CreateTab(ctx, schema.CreateTabRequest{
-			UserID:  userID,
-			RepoURL: repoArg,
+			UserID:    userID,
+			RepoURL:   repoArg,
+			Ephemeral: ephemeral,
 		})
 		if err != nil {
 			log.Warn("command new failed", "err", err)
@@ -185,6 +305,7 @@ func (h *Handler) handleNew(ctx context.Context, userID schema.UserID, tabID sch
 			UserID:     userID,
 			RepoName:   repoName,
 			CreateRepo: true,
+			Ephemeral:  ephemeral,
 		})
 		if err != nil {
 			if errors.Is(err, schema.ErrRepoExists) {
@@ -192,6 +313,7 @@ func (h *Handler) handleNew(ctx context.Context, userID schema.UserID, tabID sch
 					UserID:     userID,
 					RepoName:   repoName,
 					CreateRepo: false,
+					Ephemeral:  ephemeral,
 				})
 			}
 			if err != nil {
@@ -214,13 +336,18 @@ func (h *Handler) handleNew(ctx context.Context, userID schema.UserID, tabID sch
 	if resp.RepoCreated {
 		if isURL {
 			lines = append(lines, fmt.Sprintf("repo cloned: %s", resp.Tab.Repo.Name))
+			h.recordActivity(ctx, userID, schema.ActivityRepoCloned, fmt.Sprintf("%s from %s", resp.Tab.Repo.Name, repoArg))
 		} else {
 			lines = append(lines, fmt.Sprintf("repo created: %s", resp.Tab.Repo.Name))
 		}
 	} else {
 		lines = append(lines, fmt.Sprintf("repo opened: %s", resp.Tab.Repo.Name))
 	}
-	lines = append(lines, fmt.Sprintf("tab opened: %s", resp.Tab.Name))
+	if resp.Tab.Ephemeral {
+		lines = append(lines, fmt.Sprintf("tab opened: %s (ephemeral: buffer, history and prompts are not saved)", resp.Tab.Name))
+	} else {
+		lines = append(lines, fmt.Sprintf("tab opened: %s", resp.Tab.Name))
+	}
 	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
 		UserID: userID,
 		TabID:  resp.Tab.ID,
@@ -230,6 +357,20 @@ func (h *Handler) handleNew(ctx context.Context, userID schema.UserID, tabID sch
 	return nil
 }
 
+// cloningStatus names the host and repo path parsed from a git URL so a
+// typo shows before the clone starts. Credentials in the URL are left out.
+func cloningStatus(raw string) string {
+	cloneURL, _, err := repo.NormalizeGitURL(raw)
+	if err != nil {
+		return fmt.Sprintf("cloning repo %s", raw)
+	}
+	remote, err := repo.ParseGitRemote(cloneURL)
+	if err != nil {
+		return fmt.Sprintf("cloning repo %s", raw)
+	}
+	return fmt.Sprintf("cloning repo %s from %s", remote.Path, remote.Address())
+}
+
 func looksLikeGitURL(value string) bool {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
@@ -241,40 +382,104 @@ func looksLikeGitURL(value string) bool {
 	return strings.Contains(trimmed, "/")
 }
 
-func (h *Handler) handleListRepos(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) handleListRepos(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
-	resp, err := h.service.ListRepos(ctx, schema.ListReposRequest{UserID: userID})
+	sortBy, ok := cmd.Flag("sort")
+	if !ok {
+		sortBy = "date"
+	}
+	switch sortBy {
+	case "name", "size", "date":
+	default:
+		return fmt.Errorf("usage: /listrepos [--sort name|size|date] [--size]")
+	}
+	resp, err := h.service.ListRepos(ctx, schema.ListReposRequest{UserID: userID, Sizes: cmd.HasFlag("size") || sortBy == "size"})
 	if err != nil {
 		log.Warn("command listrepos failed", "err", err)
 		return err
 	}
-	lines := []string{schema.WorkedForMarker + "Repos"}
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSeparator, "Repos")}
 	if len(resp.Repos) == 0 {
-		lines = append(lines, "no repos found")
+		lines = append(lines, schema.Line(schema.LineKindSystem, "no repos found"))
 	} else {
-		for _, repo := range resp.Repos {
-			lines = append(lines, fmt.Sprintf("- %s", repo.Name))
+		openTabs := map[schema.RepoName][]string{}
+		if listResp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID}); err == nil {
+			for _, tab := range listResp.Tabs {
+				if tab.Owner == "" {
+					openTabs[tab.Repo.Name] = append(openTabs[tab.Repo.Name], string(tab.Name))
+				}
+			}
 		}
+		lines = append(lines, repoListLines(resp, openTabs, sortBy, time.Now())...)
 	}
-	if tabID == "" {
-		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{
-			UserID: userID,
-			Lines:  lines,
-		})
-		return nil
-	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
-		UserID: userID,
-		TabID:  tabID,
-		Lines:  lines,
-	})
-	log.Info("command listrepos completed", "count", len(resp.Repos))
+	h.appendLines(ctx, userID, tabID, lines...)
+	log.Info("command listrepos completed", "count", len(resp.Repos), "sort", sortBy)
 	return nil
 }
 
+type repoListEntry struct {
+	repo    schema.RepoRef
+	details schema.RepoDetails
+}
+
+// repoListLines renders repos as a table of name, size, last modified and
+// the names of the viewer's tabs open on each repo. Repos are sorted by
+// sortBy: name ascending, or size or date descending.
+func repoListLines(resp schema.ListReposResponse, openTabs map[schema.RepoName][]string, sortBy string, now time.Time) []schema.BufferLine {
+	entries := make([]repoListEntry, 0, len(resp.Repos))
+	width := 0
+	for i, ref := range resp.Repos {
+		entry := repoListEntry{repo: ref, details: schema.RepoDetails{SizeBytes: -1}}
+		if i < len(resp.Details) {
+			entry.details = resp.Details[i]
+		}
+		entries = append(entries, entry)
+		width = max(width, len(ref.Name))
+	}
+	slices.SortStableFunc(entries, func(a, b repoListEntry) int {
+		switch sortBy {
+		case "size":
+			if c := cmp.Compare(b.details.SizeBytes, a.details.SizeBytes); c != 0 {
+				return c
+			}
+		case "date":
+			if c := b.details.ModifiedAt.Compare(a.details.ModifiedAt); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(string(a.repo.Name), string(b.repo.Name))
+	})
+	lines := make([]schema.BufferLine, 0, len(entries))
+	for _, entry := range entries {
+		text := fmt.Sprintf("  %-*s  %7s  %-8s", width, entry.repo.Name, formatRepoSize(entry.details.SizeBytes), formatRelativeTime(now, entry.details.ModifiedAt))
+		if tabs := openTabs[entry.repo.Name]; len(tabs) > 0 {
+			text += "  open in " + strings.Join(tabs, ", ")
+		}
+		lines = append(lines, schema.Line(schema.LineKindSystem, strings.TrimRight(text, " ")))
+	}
+	return lines
+}
+
+// formatRepoSize formats n bytes as "12K", "3.4M" or "1.2G"; a negative
+// size was not measured and shows as "-".
+func formatRepoSize(n int64) string {
+	switch {
+	case n < 0:
+		return "-"
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%dK", n>>10)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
 func (h *Handler) handleRemove(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	if len(cmd.Args) < 1 {
-		return fmt.Errorf("usage: /rm <number_or_name>")
+		return fmt.Errorf("usage: /rm <number_or_name> [--force]")
 	}
 	log := logx.WithUserTab(ctx, userID, tabID)
 	listResp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
@@ -287,6 +492,10 @@ func (h *Handler) handleRemove(ctx context.Context, userID schema.UserID, tabID
 		log.Warn("command rm resolve failed", "err", err)
 		return err
 	}
+	if err := h.confirmClose(userID, targetID, listResp.Tabs, cmd.HasFlag("force")); err != nil {
+		log.Info("command rm needs confirmation", "tab", targetID)
+		return err
+	}
 	_, err = h.service.CloseTab(ctx, schema.CloseTabRequest{
 		UserID: userID,
 		TabID:  targetID,
@@ -319,21 +528,22 @@ func (h *Handler) handleRemove(ctx context.Context, userID schema.UserID, tabID
 
 func (h *Handler) handleClose(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	if len(cmd.Args) != 0 {
-		return fmt.Errorf("usage: /close")
+		return fmt.Errorf("usage: /close [--force]")
 	}
-	if tabID == "" {
-		return errors.New("no active tab")
-	}
-	return h.closeTab(ctx, userID, tabID)
+	return h.closeTab(ctx, userID, tabID, cmd.HasFlag("force"))
 }
 
-func (h *Handler) closeTab(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) closeTab(ctx context.Context, userID schema.UserID, tabID schema.TabID, force bool) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
 	listResp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
 	if err != nil {
 		log.Warn("command close list failed", "err", err)
 		return err
 	}
+	if err := h.confirmClose(userID, tabID, listResp.Tabs, force); err != nil {
+		log.Info("command close needs confirmation")
+		return err
+	}
 	targetName := nameForTab(tabID, listResp.Tabs)
 	_, err = h.service.CloseTab(ctx, schema.CloseTabRequest{
 		UserID: userID,
@@ -365,6 +575,88 @@ func (h *Handler) closeTab(ctx context.Context, userID schema.UserID, tabID sche
 	return nil
 }
 
+const reopenUsage = "usage: /reopen [n] | /reopen list"
+
+func (h *Handler) handleReopen(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) > 1 {
+		return errors.New(reopenUsage)
+	}
+	if len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], "list") {
+		resp, err := h.service.ListClosedTabs(ctx, schema.ListClosedTabsRequest{UserID: userID})
+		if err != nil {
+			log.Warn("command reopen list failed", "err", err)
+			return err
+		}
+		if len(resp.Tabs) == 0 {
+			h.appendLine(ctx, userID, tabID, "closed tabs: none")
+			return nil
+		}
+		now := h.now()
+		for i, closed := range resp.Tabs {
+			h.appendLine(ctx, userID, tabID, fmt.Sprintf("%d. %s (%s) closed %s, expires in %s", i+1, closed.Tab.Name, closed.Tab.Repo.Name, formatRelativeTime(now, closed.ClosedAt), timefmt.Duration(closed.ExpiresAt.Sub(now))))
+		}
+		log.Info("command reopen listed", "tabs", len(resp.Tabs))
+		return nil
+	}
+	index := 1
+	if len(cmd.Args) == 1 {
+		n, err := strconv.Atoi(cmd.Args[0])
+		if err != nil || n < 1 {
+			return errors.New(reopenUsage)
+		}
+		index = n
+	}
+	resp, err := h.service.ReopenTab(ctx, schema.ReopenTabRequest{UserID: userID, Index: index})
+	if err != nil {
+		log.Warn("command reopen failed", "err", err)
+		return err
+	}
+	if _, err := h.service.ActivateTab(ctx, schema.ActivateTabRequest{UserID: userID, TabID: resp.Tab.ID}); err != nil {
+		log.Warn("command reopen activate failed", "err", err)
+		return err
+	}
+	h.appendLine(ctx, userID, resp.Tab.ID, fmt.Sprintf("tab reopened: %s", resp.Tab.Name))
+	log.Info("command reopen completed", "tab", resp.Tab.ID, "index", index)
+	return nil
+}
+
+// confirmClose protects running tabs from being closed by a slip of the
+// keyboard: the first /rm or /close is refused, and only a repeat within
+// closeConfirmWindow or --force closes the tab. CloseTab itself does not ask.
+func (h *Handler) confirmClose(userID schema.UserID, tabID schema.TabID, tabs []schema.TabSnapshot, force bool) error {
+	key := closeConfirmKey{userID: userID, tabID: tabID}
+	now := h.now()
+	h.confirmMu.Lock()
+	defer h.confirmMu.Unlock()
+	for pending, at := range h.closeConfirms {
+		if now.Sub(at) > closeConfirmWindow {
+			delete(h.closeConfirms, pending)
+		}
+	}
+	running := false
+	for _, tab := range tabs {
+		if tab.ID == tabID {
+			running = tab.Status.Busy()
+			break
+		}
+	}
+	if force || !running {
+		delete(h.closeConfirms, key)
+		return nil
+	}
+	if _, ok := h.closeConfirms[key]; ok {
+		delete(h.closeConfirms, key)
+		return nil
+	}
+	h.closeConfirms[key] = now
+	name := nameForTab(tabID, tabs)
+	if name == "" {
+		name = string(tabID)
+	}
+	return fmt.Errorf("tab %s is running — repeat the command within %d seconds to confirm (or use %s)", name, int(closeConfirmWindow/time.Second), forceFlag)
+}
+
 func (h *Handler) handleModel(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
 		return fmt.Errorf("usage: /model <model> [reasoning] (available: %s; reasoning: %s)", strings.Join(formatModels(h.cfg.AllowedModels), ", "), modelReasoningEffortUsage)
@@ -393,20 +685,35 @@ func (h *Handler) handleModel(ctx context.Context, userID schema.UserID, tabID s
 		log.Warn("command model failed", "err", err)
 		return err
 	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
-		UserID: userID,
-		TabID:  tabID,
-		Lines:  []string{fmt.Sprintf("model set to: %s", schema.FormatModelWithReasoning(resp.Tab.Model, resp.Tab.ModelReasoningEffort))},
-	})
+	// The service marks a change in the buffer itself.
+	if !resp.Changed {
+		h.appendStatus(ctx, userID, tabID, fmt.Sprintf("model unchanged: %s", schema.FormatModelWithReasoning(resp.Tab.Model, resp.Tab.ModelReasoningEffort)))
+	}
 	log.Info("command model completed", "model", resp.Tab.Model)
 	return nil
 }
 
-func (h *Handler) handleStop(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) handleStop(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) > 0 {
+		return errors.New("usage: /stop [--grace <duration> | --now]")
+	}
+	var grace time.Duration
+	if value, ok := cmd.Flag("grace"); ok {
+		if cmd.HasFlag("now") {
+			return errors.New("usage: /stop [--grace <duration> | --now]")
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid grace period %q: use a duration such as 30s", value)
+		}
+		grace = parsed
+	}
 	_, err := h.service.StopSession(ctx, schema.StopSessionRequest{
-		UserID: userID,
-		TabID:  tabID,
+		UserID:      userID,
+		TabID:       tabID,
+		GracePeriod: grace,
+		Kill:        cmd.HasFlag("now"),
 	})
 	if err != nil {
 		log.Warn("command stop failed", "err", err)
@@ -416,12 +723,44 @@ func (h *Handler) handleStop(ctx context.Context, userID schema.UserID, tabID sc
 	return err
 }
 
-func (h *Handler) handleRenew(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) handleRedo(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
-	if tabID == "" {
-		log.Warn("command renew rejected", "reason", "no active tab")
-		return errors.New("no active tab")
+	last, err := h.service.GetLastPrompt(ctx, schema.GetLastPromptRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		log.Warn("command redo rejected", "err", err)
+		return err
+	}
+	if _, err := h.service.AppendHistory(ctx, schema.AppendHistoryRequest{UserID: userID, TabID: tabID, Entry: last.Prompt}); err != nil {
+		log.Warn("command redo history failed", "err", err)
+	}
+	if _, err := h.service.SendPrompt(ctx, schema.SendPromptRequest{UserID: userID, TabID: tabID, Prompt: last.Prompt}); err != nil {
+		log.Warn("command redo failed", "err", err)
+		return err
+	}
+	log.Info("command redo completed", "prompt_len", len(last.Prompt))
+	return nil
+}
+
+// handleEditLast prints the last prompt. The SSH terminal handles /edit-last
+// itself and loads the prompt into its editor instead.
+func (h *Handler) handleEditLast(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	last, err := h.service.GetLastPrompt(ctx, schema.GetLastPromptRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		log.Warn("command edit-last rejected", "err", err)
+		return err
 	}
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSystem, "last prompt:")}
+	for _, line := range strings.Split(last.Prompt, "\n") {
+		lines = append(lines, schema.Line(schema.LineKindPrompt, line))
+	}
+	h.appendLines(ctx, userID, tabID, lines...)
+	log.Info("command edit-last completed", "prompt_len", len(last.Prompt))
+	return nil
+}
+
+func (h *Handler) handleRenew(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
 	_, err := h.service.RenewSession(ctx, schema.RenewSessionRequest{
 		UserID: userID,
 		TabID:  tabID,
@@ -430,28 +769,41 @@ func (h *Handler) handleRenew(ctx context.Context, userID schema.UserID, tabID s
 		log.Warn("command renew failed", "err", err)
 		return err
 	}
-	h.appendLine(ctx, userID, tabID, "session renewed (next prompt starts a new session)")
 	log.Info("command renew completed")
 	return nil
 }
 
-func (h *Handler) handleHelp(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) handleHelp(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
-	lines := helpLines(h.cfg.AllowedModels)
-	if tabID == "" {
-		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{
-			UserID: userID,
-			Lines:  lines,
-		})
+	if len(cmd.Args) > 1 {
+		log.Warn("command help rejected", "reason", "too many args")
+		return errors.New("usage: /help [command]")
+	}
+	var aliases []schema.CommandAlias
+	if resp, err := h.service.ListAliases(ctx, schema.ListAliasesRequest{UserID: userID}); err != nil {
+		log.Warn("command help alias lookup failed", "err", err)
+	} else {
+		aliases = resp.Aliases
+	}
+	if len(cmd.Args) == 0 {
+		h.appendLines(ctx, userID, tabID, helpLines(h.cfg.AllowedModels, aliases)...)
 		log.Info("command help completed")
 		return nil
 	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
-		UserID: userID,
-		TabID:  tabID,
-		Lines:  lines,
-	})
-	log.Info("command help completed")
+	topic := strings.ToLower(strings.TrimPrefix(cmd.Args[0], "/"))
+	for _, alias := range aliases {
+		if alias.Name == topic {
+			topic = aliasTarget(alias.Expansion)
+			break
+		}
+	}
+	spec, ok := lookupCommandSpec(topic)
+	if !ok {
+		log.Warn("command help rejected", "reason", "unknown command")
+		return unknownCommandError(strings.TrimPrefix(cmd.Args[0], "/"))
+	}
+	h.appendLines(ctx, userID, tabID, commandHelpLines(spec, h.cfg.AllowedModels, aliases)...)
+	log.Info("command help completed", "topic", spec.Name)
 	return nil
 }
 
@@ -491,20 +843,15 @@ func (h *Handler) handleListLoginPubKeys(ctx context.Context, userID schema.User
 		log.Warn("command listloginpubkeys failed", "err", err)
 		return err
 	}
-	lines := []string{schema.WorkedForMarker + "Login pubkeys"}
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSeparator, "Login pubkeys")}
 	if len(keys) == 0 {
-		lines = append(lines, "no login pubkeys")
+		lines = append(lines, schema.Line(schema.LineKindSystem, "no login pubkeys"))
 	} else {
 		for i, key := range keys {
-			lines = append(lines, fmt.Sprintf("%d) %s", i+1, strings.TrimSpace(key)))
+			lines = append(lines, schema.Line(schema.LineKindSystem, fmt.Sprintf("%d) %s", i+1, strings.TrimSpace(key))))
 		}
 	}
-	if tabID == "" {
-		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Lines: lines})
-		log.Info("command listloginpubkeys completed", "count", len(keys))
-		return nil
-	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{UserID: userID, TabID: tabID, Lines: lines})
+	h.appendLines(ctx, userID, tabID, lines...)
 	log.Info("command listloginpubkeys completed", "count", len(keys))
 	return nil
 }
@@ -548,20 +895,49 @@ func (h *Handler) handlePubKey(ctx context.Context, userID schema.UserID, tabID
 		log.Warn("command pubkey failed", "err", err)
 		return err
 	}
-	lines := []string{
-		schema.WorkedForMarker + "Git public key",
-		strings.TrimSpace(key),
-	}
-	if tabID == "" {
-		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Lines: lines})
-		log.Info("command pubkey completed")
-		return nil
+	h.appendLines(ctx, userID, tabID,
+		schema.Line(schema.LineKindSeparator, "Git public key"),
+		schema.Line(schema.LineKindSystem, strings.TrimSpace(key)),
+	)
+	if _, err := h.service.UpdateOnboarding(ctx, schema.UpdateOnboardingRequest{UserID: userID, Complete: schema.OnboardingPubKey}); err != nil {
+		log.Debug("command pubkey onboarding update failed", "err", err)
 	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{UserID: userID, TabID: tabID, Lines: lines})
 	log.Info("command pubkey completed")
 	return nil
 }
 
+const onboardingUsage = "usage: /onboarding [off]"
+
+func (h *Handler) handleOnboarding(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	req := schema.UpdateOnboardingRequest{UserID: userID}
+	switch {
+	case len(cmd.Args) == 0:
+	case len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], "off"):
+		if err := checkAccountWrite(ctx); err != nil {
+			return err
+		}
+		req.Off = true
+	default:
+		return errors.New(onboardingUsage)
+	}
+	resp, err := h.service.UpdateOnboarding(ctx, req)
+	if err != nil {
+		log.Warn("command onboarding failed", "err", err)
+		return err
+	}
+	switch {
+	case req.Off:
+		h.appendLine(ctx, userID, tabID, "onboarding: off")
+		log.Info("command onboarding turned off")
+	case resp.Onboarding.Active:
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("onboarding: %d of %d steps done (the checklist is below the system buffer)", len(resp.Onboarding.Done), len(schema.OnboardingSteps)))
+	default:
+		h.appendLine(ctx, userID, tabID, "onboarding: off")
+	}
+	return nil
+}
+
 func (h *Handler) handleRotateSSHKey(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
 	if len(cmd.Args) == 0 {
@@ -582,6 +958,7 @@ func (h *Handler) handleRotateSSHKey(ctx context.Context, userID schema.UserID,
 		log.Warn("command rotatesshkey failed", "err", err)
 		return err
 	}
+	h.recordActivity(ctx, userID, schema.ActivitySSHKeyRotated, sshkeys.KeyTypeEd25519)
 	lines := []string{"ssh key rotated", "git public key:", strings.TrimSpace(pubKey)}
 	if tabID == "" {
 		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Lines: lines})
@@ -607,6 +984,9 @@ func (h *Handler) handleTheme(ctx context.Context, userID schema.UserID, tabID s
 		log.Info("command theme listed", "current", current)
 		return nil
 	}
+	if cmd.Args[0] == "preview" {
+		return errors.New("theme preview is only available in the SSH terminal")
+	}
 	name, ok := schema.NormalizeThemeName(cmd.Args[0])
 	if !ok {
 		log.Warn("command theme rejected", "theme", cmd.Args[0])
@@ -621,6 +1001,50 @@ func (h *Handler) handleTheme(ctx context.Context, userID schema.UserID, tabID s
 	return nil
 }
 
+func (h *Handler) handleTimezone(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) == 0 {
+		clock := h.clock(ctx, userID)
+		now := h.now()
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("time zone: %s (now %s)", clock.Location(), clock.FormatFrom(now, now)))
+		log.Info("command tz listed", "timezone", clock.Location().String())
+		return nil
+	}
+	if len(cmd.Args) > 1 {
+		return errors.New("usage: /tz [<zone>|default]")
+	}
+	zone := cmd.Args[0]
+	if strings.EqualFold(zone, "default") {
+		zone = ""
+	}
+	resp, err := h.service.SetTimezone(ctx, schema.SetTimezoneRequest{UserID: userID, Timezone: zone})
+	if err != nil {
+		log.Warn("command tz failed", "err", err)
+		return err
+	}
+	if resp.Default {
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("time zone reset to server default (%s)", resp.Timezone))
+	} else {
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("time zone set to %s", resp.Timezone))
+	}
+	log.Info("command tz updated", "timezone", resp.Timezone, "default", resp.Default)
+	return nil
+}
+
+// clock returns the clock times are shown to userID with, falling back to the
+// server's local time when the service cannot say.
+func (h *Handler) clock(ctx context.Context, userID schema.UserID) timefmt.Clock {
+	resp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		return timefmt.Clock{}
+	}
+	clock, err := timefmt.New(resp.TimeFormat, resp.Timezone)
+	if err != nil {
+		return timefmt.Clock{}
+	}
+	return clock
+}
+
 func (h *Handler) handleToggleFullCommandOutput(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
 	prefs := sessionprefs.FromContext(ctx)
@@ -638,115 +1062,1280 @@ func (h *Handler) handleToggleFullCommandOutput(ctx context.Context, userID sche
 	return nil
 }
 
-func (h *Handler) handleStatus(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) handleToggleFullReasoning(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
-	if tabID == "" {
-		log.Warn("command status rejected", "reason", "no active tab")
-		return errors.New("no active tab")
-	}
-	tab, err := h.lookupTab(ctx, userID, tabID)
-	if err != nil {
-		log.Warn("command status lookup failed", "err", err)
-		return err
-	}
-	usageResp, err := h.service.GetTabUsage(ctx, schema.GetTabUsageRequest{UserID: userID, TabID: tabID})
-	if err != nil {
-		log.Warn("command status usage failed", "err", err)
-		return err
+	prefs := sessionprefs.FromContext(ctx)
+	if prefs == nil {
+		log.Warn("command reasoning toggle rejected", "reason", "session preferences unavailable")
+		return errors.New("session preferences unavailable")
 	}
-	tokensUsed := 0
-	if usageResp.Usage != nil {
-		tokensUsed = usageResp.Usage.InputTokens + usageResp.Usage.OutputTokens
+	prefs.FullReasoning = !prefs.FullReasoning
+	mode := "collapsed"
+	if prefs.FullReasoning {
+		mode = "full"
 	}
+	h.appendLine(ctx, userID, tabID, "reasoning: "+mode)
+	log.Info("command reasoning toggled", "mode", mode)
+	return nil
+}
 
-	model := schema.FormatModelWithReasoning(tab.Model, tab.ModelReasoningEffort)
-	dir := h.resolveStatusDir(ctx, userID, tabID, tab)
-	session := string(tab.SessionID)
-	if strings.TrimSpace(session) == "" {
-		session = "none"
+func (h *Handler) handleToggleTimestamps(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	prefs := sessionprefs.FromContext(ctx)
+	if prefs == nil {
+		log.Warn("command timestamps toggle rejected", "reason", "session preferences unavailable")
+		return errors.New("session preferences unavailable")
 	}
-
-	usageInfo, usageOK, usageErr := h.lookupUsage(ctx, userID, tabID)
-	labels := []string{"Model", "Directory", "Session", "Tokens used"}
-	if usageOK && usageInfo.ChatGPT {
-		labels = append(labels, "5h limit", "Week limit")
+	prefs.Timestamps = !prefs.Timestamps
+	mode := "off"
+	if prefs.Timestamps {
+		mode = "on"
 	}
-	labelWidth := maxLabelWidth(labels)
+	h.appendLine(ctx, userID, tabID, "timestamps: "+mode)
+	log.Info("command timestamps toggled", "mode", mode)
+	return nil
+}
 
-	lines := []string{
-		schema.WorkedForMarker + "Status",
-		formatStatusLine("Model", model, labelWidth),
-		formatStatusLine("Directory", dir, labelWidth),
-		formatStatusLine("Session", session, labelWidth),
-		formatStatusLine("Tokens used", formatTokensUsed(tokensUsed), labelWidth),
+func (h *Handler) handleToggleGlobalHistory(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	prefs := sessionprefs.FromContext(ctx)
+	if prefs == nil {
+		log.Warn("command history toggle rejected", "reason", "session preferences unavailable")
+		return errors.New("session preferences unavailable")
 	}
-
-	if usageOK && usageInfo.ChatGPT {
-		now := h.now()
-		lines = append(lines,
-			formatStatusLine("5h limit", formatUsageWindow(usageInfo.Primary, usageErr, now), labelWidth),
-			formatStatusLine("Week limit", formatUsageWindow(usageInfo.Secondary, usageErr, now), labelWidth),
-		)
+	prefs.GlobalHistory = !prefs.GlobalHistory
+	mode := "tab"
+	if prefs.GlobalHistory {
+		mode = "global"
 	}
-
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
-		UserID: userID,
-		TabID:  tabID,
-		Lines:  lines,
-	})
-	log.Info("command status completed", "tokens_used", tokensUsed, "usage_ok", usageOK, "chatgpt", usageInfo.ChatGPT)
+	h.appendLine(ctx, userID, tabID, "history: "+mode)
+	log.Info("command history toggled", "mode", mode)
 	return nil
 }
 
-func (h *Handler) handleVersion(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+func (h *Handler) handleHistory(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	log := logx.WithUserTab(ctx, userID, tabID)
-	versionLine := fmt.Sprintf("%s %s", version.Module(), version.Current())
-	lines := []string{
-		schema.WorkedForMarker + "About",
-		schema.AboutVersionMarker + versionLine,
-		schema.AboutCopyrightMarker + "Copyright (C) 2025-2026 Michel Blomgren",
-		schema.AboutLinkMarker + "https://github.com/sa6mwa/centaurx",
-		"",
+	limit := defaultHistoryListLimit
+	if len(cmd.Args) > 0 {
+		n, err := strconv.Atoi(cmd.Args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("usage: /history [n]")
+		}
+		limit = n
 	}
-	if tabID == "" {
-		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{
-			UserID: userID,
-			Lines:  lines,
-		})
-		log.Info("command version completed")
+	resp, err := h.service.GetHistory(ctx, schema.GetHistoryRequest{UserID: userID, TabID: tabID, Scope: schema.HistoryScopeTab})
+	if err != nil {
+		log.Warn("command history failed", "err", err)
+		return err
+	}
+	items := resp.Items
+	if len(items) == 0 {
+		h.appendLine(ctx, userID, tabID, "history: empty")
 		return nil
 	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
-		UserID: userID,
-		TabID:  tabID,
-		Lines:  lines,
-	})
-	log.Info("command version completed")
+	start := max(len(items)-limit, 0)
+	now := h.now()
+	for i := start; i < len(items); i++ {
+		item := items[i]
+		text := strings.ReplaceAll(item.Text, "\n", " ")
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("%4d  %-8s  %s", i+1, formatRelativeTime(now, item.Time), text))
+	}
+	log.Info("command history listed", "entries", len(items)-start)
 	return nil
 }
 
-func (h *Handler) handleShell(ctx context.Context, userID schema.UserID, tabID schema.TabID, input string) error {
-	baseLog := logx.WithUserTab(ctx, userID, tabID)
-	ctx = logx.ContextWithUserTabLogger(ctx, baseLog, userID, tabID)
-	log := baseLog
-	if h.runners == nil {
-		log.Warn("command shell rejected", "reason", "runner not configured")
-		return errors.New("runner not configured")
-	}
-	cmdText := strings.TrimSpace(strings.TrimPrefix(input, "!"))
-	if cmdText == "" {
-		log.Warn("command shell rejected", "reason", "empty command")
-		return fmt.Errorf("usage: ! <cmd>")
-	}
-	log = log.With("command_len", len(cmdText))
-	displayTabID := tabID
-	runnerTabID := tabID
+const filterUsage = "usage: /filter add <regex> | /filter list | /filter rm <n>"
+
+const summaryUsage = "usage: /summary [on|off|<YYYY-MM-DD>]"
+
+const toolsUsage = "usage: /tools [<tool> on|off]"
+
+// toolDescriptions explains the tools listed by /tools.
+var toolDescriptions = map[schema.ToolName]string{
+	schema.ToolWebSearch: "web search",
+}
+
+// summaryDatesShown bounds the other summary dates listed by /summary.
+const summaryDatesShown = 7
+
+func (h *Handler) handleTools(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	switch len(cmd.Args) {
+	case 0:
+		tab, err := h.lookupTab(ctx, userID, tabID)
+		if err != nil {
+			log.Warn("command tools lookup failed", "err", err)
+			return err
+		}
+		width := 0
+		for _, name := range schema.KnownTools {
+			width = max(width, len(name))
+		}
+		lines := []schema.BufferLine{schema.Line(schema.LineKindSystem, "tools:")}
+		for _, name := range schema.KnownTools {
+			state := "default"
+			if enabled, ok := tab.Tools[name]; ok {
+				state = "off"
+				if enabled {
+					state = "on"
+				}
+			}
+			lines = append(lines, schema.Line(schema.LineKindSystem, fmt.Sprintf("  %-*s  %-7s  %s", width, name, state, toolDescriptions[name])))
+		}
+		h.appendLines(ctx, userID, tabID, lines...)
+		return nil
+	case 2:
+	default:
+		return errors.New(toolsUsage)
+	}
+	tool, err := schema.NormalizeToolName(cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	arg := strings.ToLower(cmd.Args[1])
+	if arg != "on" && arg != "off" {
+		return errors.New(toolsUsage)
+	}
+	if _, err := h.service.SetTabTool(ctx, schema.SetTabToolRequest{UserID: userID, TabID: tabID, Tool: tool, Enabled: arg == "on"}); err != nil {
+		log.Warn("command tools update failed", "err", err)
+		return err
+	}
+	h.appendLine(ctx, userID, tabID, fmt.Sprintf("tool %s: %s (from the next prompt)", tool, arg))
+	log.Info("command tools updated", "tool", tool, "enabled", arg == "on")
+	return nil
+}
+
+const tagUsage = "usage: /tag [add|rm <label>]"
+
+func (h *Handler) handleTag(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	switch len(cmd.Args) {
+	case 0:
+		tab, err := h.lookupTab(ctx, userID, tabID)
+		if err != nil {
+			log.Warn("command tag lookup failed", "err", err)
+			return err
+		}
+		if len(tab.Labels) == 0 {
+			h.appendLine(ctx, userID, tabID, "labels: none (/tag add <label>)")
+			return nil
+		}
+		h.appendLine(ctx, userID, tabID, "labels: "+joinTabLabels(tab.Labels))
+		return nil
+	case 2:
+	default:
+		return errors.New(tagUsage)
+	}
+	action := strings.ToLower(cmd.Args[0])
+	if action != "add" && action != "rm" {
+		return errors.New(tagUsage)
+	}
+	resp, err := h.service.SetTabLabel(ctx, schema.SetTabLabelRequest{UserID: userID, TabID: tabID, Label: schema.TabLabel(cmd.Args[1]), Remove: action == "rm"})
+	if err != nil {
+		log.Warn("command tag update failed", "err", err)
+		return err
+	}
+	labels := "none"
+	if len(resp.Tab.Labels) > 0 {
+		labels = joinTabLabels(resp.Tab.Labels)
+	}
+	h.appendLine(ctx, userID, tabID, "labels: "+labels)
+	log.Info("command tag updated", "action", action, "labels", len(resp.Tab.Labels))
+	return nil
+}
+
+func (h *Handler) handleTabs(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	if len(cmd.Args) > 1 {
+		return errors.New("usage: /tabs [label]")
+	}
+	log := logx.WithUserTab(ctx, userID, tabID)
+	var filter schema.TabLabel
+	if len(cmd.Args) == 1 {
+		label, err := schema.NormalizeTabLabel(cmd.Args[0])
+		if err != nil {
+			return err
+		}
+		filter = label
+	}
+	resp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		log.Warn("command tabs list failed", "err", err)
+		return err
+	}
+	var lines []schema.BufferLine
+	for i, tab := range resp.Tabs {
+		if filter != "" && !slices.Contains(tab.Labels, filter) {
+			continue
+		}
+		marker := " "
+		if tab.ID == resp.ActiveTab {
+			marker = "*"
+		}
+		line := fmt.Sprintf("%s %d) %s", marker, i+1, tab.Name)
+		if len(tab.Labels) > 0 {
+			line += " [" + joinTabLabels(tab.Labels) + "]"
+		}
+		lines = append(lines, schema.Line(schema.LineKindSystem, line))
+	}
+	if len(lines) == 0 {
+		if filter != "" {
+			h.appendLine(ctx, userID, tabID, fmt.Sprintf("tabs: none labelled %s", filter))
+		} else {
+			h.appendLine(ctx, userID, tabID, "tabs: none")
+		}
+		return nil
+	}
+	h.appendLines(ctx, userID, tabID, lines...)
+	log.Info("command tabs listed", "label", filter, "count", len(lines))
+	return nil
+}
+
+// handleTab activates a tab by the number /tabs lists it with. It is the
+// fallback for Alt+digit in terminals that do not send Alt.
+func (h *Handler) handleTab(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	if len(cmd.Args) != 1 {
+		return errors.New("usage: /tab <n>")
+	}
+	n, err := strconv.Atoi(cmd.Args[0])
+	if err != nil {
+		return errors.New("usage: /tab <n>")
+	}
+	log := logx.WithUserTab(ctx, userID, tabID)
+	resp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		log.Warn("command tab list failed", "err", err)
+		return err
+	}
+	if n <= 0 || n > len(resp.Tabs) {
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("no tab %d (%d open)", n, len(resp.Tabs)))
+		return nil
+	}
+	next := resp.Tabs[n-1]
+	if _, err := h.service.ActivateTab(ctx, schema.ActivateTabRequest{UserID: userID, TabID: next.ID}); err != nil {
+		log.Warn("command tab activate failed", "err", err)
+		return err
+	}
+	log.Info("command tab activated", "to", next.ID, "number", n)
+	return nil
+}
+
+func joinTabLabels(labels []schema.TabLabel) string {
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, string(label))
+	}
+	return strings.Join(parts, ", ")
+}
+
+const batchUsage = "usage: /batch repos add|rm <repo>... | /batch repos [list] | /batch run <prompt> | /batch stop"
+
+func (h *Handler) handleBatch(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) == 0 {
+		resp, err := h.service.GetBatch(ctx, schema.GetBatchRequest{UserID: userID})
+		if err != nil {
+			log.Warn("command batch status failed", "err", err)
+			return err
+		}
+		if resp.Batch == nil {
+			h.appendLine(ctx, userID, tabID, "batch: none run yet (/batch run <prompt>)")
+			return nil
+		}
+		h.appendLines(ctx, userID, tabID, batchStatusLines(*resp.Batch, resp.Parallelism, h.now())...)
+		return nil
+	}
+	sub := strings.ToLower(cmd.Args[0])
+	log = log.With("subcommand", sub)
+	switch sub {
+	case "repos":
+		action := "list"
+		if len(cmd.Args) > 1 {
+			action = strings.ToLower(cmd.Args[1])
+		}
+		var req schema.UpdateBatchReposRequest
+		switch action {
+		case "list", "ls":
+			if len(cmd.Args) > 2 {
+				return errors.New(batchUsage)
+			}
+			resp, err := h.service.GetBatch(ctx, schema.GetBatchRequest{UserID: userID})
+			if err != nil {
+				log.Warn("command batch repos failed", "err", err)
+				return err
+			}
+			h.appendLine(ctx, userID, tabID, formatBatchRepos(resp.Repos))
+			return nil
+		case "add":
+			for _, name := range cmd.Args[2:] {
+				req.Add = append(req.Add, schema.RepoName(name))
+			}
+		case "rm":
+			for _, name := range cmd.Args[2:] {
+				req.Remove = append(req.Remove, schema.RepoName(name))
+			}
+		default:
+			return errors.New(batchUsage)
+		}
+		if len(cmd.Args) < 3 {
+			return fmt.Errorf("usage: /batch repos %s <repo>...", action)
+		}
+		req.UserID = userID
+		resp, err := h.service.UpdateBatchRepos(ctx, req)
+		if err != nil {
+			log.Warn("command batch repos failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, formatBatchRepos(resp.Repos))
+		log.Info("command batch repos updated", "repos", len(resp.Repos))
+		return nil
+	case "run":
+		prompt := remainderAfterTokens(cmd.Raw, 2)
+		if prompt == "" {
+			return errors.New("usage: /batch run <prompt>")
+		}
+		resp, err := h.service.StartBatch(ctx, schema.StartBatchRequest{UserID: userID, Prompt: prompt})
+		if err != nil {
+			log.Warn("command batch run failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("batch started in %d repos; /batch shows progress", len(resp.Batch.Repos)))
+		log.Info("command batch started", "repos", len(resp.Batch.Repos))
+		return nil
+	case "stop":
+		if len(cmd.Args) != 1 {
+			return errors.New(batchUsage)
+		}
+		resp, err := h.service.StopBatch(ctx, schema.StopBatchRequest{UserID: userID})
+		if err != nil {
+			log.Warn("command batch stop failed", "err", err)
+			return err
+		}
+		running := 0
+		for _, repo := range resp.Batch.Repos {
+			if repo.State == schema.BatchRepoRunning {
+				running++
+			}
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("batch stopped; %d running repos will finish", running))
+		log.Info("command batch stopped", "running", running)
+		return nil
+	default:
+		return errors.New(batchUsage)
+	}
+}
+
+func formatBatchRepos(repos []schema.RepoName) string {
+	if len(repos) == 0 {
+		return "batch repos: none"
+	}
+	names := make([]string, len(repos))
+	for i, repo := range repos {
+		names[i] = string(repo)
+	}
+	return "batch repos: " + strings.Join(names, ", ")
+}
+
+// batchStatusLines describes the progress of a batch for /batch.
+func batchStatusLines(batch schema.BatchStatus, parallelism int, now time.Time) []schema.BufferLine {
+	state := fmt.Sprintf("running, %d at a time", parallelism)
+	switch {
+	case !batch.Active():
+		state = "finished"
+	case batch.Stopped:
+		state = "stopping"
+	}
+	lines := []schema.BufferLine{
+		schema.Line(schema.LineKindSystem, fmt.Sprintf("batch (%s, started %s): %s", state, formatRelativeTime(now, batch.StartedAt), strings.ReplaceAll(batch.Prompt, "\n", " "))),
+	}
+	width := 0
+	for _, repo := range batch.Repos {
+		width = max(width, len(repo.Repo))
+	}
+	for _, repo := range batch.Repos {
+		text := fmt.Sprintf("  %-*s  %s", width, repo.Repo, repo.State)
+		if repo.Error != "" {
+			text += ": " + repo.Error
+		}
+		lines = append(lines, schema.Line(schema.LineKindSystem, text))
+	}
+	return lines
+}
+
+func (h *Handler) handleSummary(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) > 1 {
+		return errors.New(summaryUsage)
+	}
+	arg := ""
+	if len(cmd.Args) == 1 {
+		arg = strings.ToLower(cmd.Args[0])
+	}
+	if arg == "on" || arg == "off" {
+		resp, err := h.service.SetTabSummaries(ctx, schema.SetTabSummariesRequest{UserID: userID, TabID: tabID, Enabled: arg == "on"})
+		if err != nil {
+			log.Warn("command summary update failed", "err", err)
+			return err
+		}
+		if resp.Enabled {
+			h.appendLine(ctx, userID, tabID, fmt.Sprintf("summaries: on (written daily at %s)", resp.Time))
+		} else {
+			h.appendLine(ctx, userID, tabID, "summaries: off")
+		}
+		log.Info("command summary updated", "enabled", resp.Enabled)
+		return nil
+	}
+	if arg != "" {
+		if _, err := time.Parse("2006-01-02", arg); err != nil {
+			return errors.New(summaryUsage)
+		}
+	}
+	resp, err := h.service.ListTabSummaries(ctx, schema.ListTabSummariesRequest{UserID: userID, TabID: tabID})
+	if err != nil {
+		log.Warn("command summary list failed", "err", err)
+		return err
+	}
+	if arg == "" {
+		switch {
+		case !resp.Available:
+			h.appendLine(ctx, userID, tabID, "summaries are disabled on this server")
+		case resp.Enabled:
+			h.appendLine(ctx, userID, tabID, fmt.Sprintf("summaries: on (written daily at %s)", resp.Time))
+		default:
+			h.appendLine(ctx, userID, tabID, "summaries: off (turn on with /summary on)")
+		}
+		if len(resp.Summaries) == 0 {
+			h.appendLine(ctx, userID, tabID, "no summaries yet")
+			return nil
+		}
+	}
+	index := len(resp.Summaries) - 1
+	if arg != "" {
+		index = slices.IndexFunc(resp.Summaries, func(summary schema.TabSummary) bool { return summary.Date == arg })
+		if index < 0 {
+			log.Warn("command summary not found", "date", arg)
+			return fmt.Errorf("no summary for %s", arg)
+		}
+	}
+	summary := resp.Summaries[index]
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSeparator, "Summary "+summary.Date)}
+	for line := range strings.SplitSeq(summary.Text, "\n") {
+		lines = append(lines, schema.Line(schema.LineKindAgent, line))
+	}
+	h.appendLines(ctx, userID, tabID, lines...)
+	var others []string
+	for i := len(resp.Summaries) - 1; i >= 0 && len(others) < summaryDatesShown; i-- {
+		if i != index {
+			others = append(others, resp.Summaries[i].Date)
+		}
+	}
+	if len(others) > 0 {
+		h.appendLine(ctx, userID, tabID, "other summaries: "+strings.Join(others, ", "))
+	}
+	log.Info("command summary shown", "date", summary.Date)
+	return nil
+}
+
+func (h *Handler) handleFilter(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) == 0 {
+		return errors.New(filterUsage)
+	}
+	sub := strings.ToLower(cmd.Args[0])
+	log = log.With("subcommand", sub)
+	switch sub {
+	case "add":
+		pattern := remainderAfterTokens(cmd.Raw, 2)
+		if pattern == "" {
+			return errors.New("usage: /filter add <regex>")
+		}
+		resp, err := h.service.AddOutputFilter(ctx, schema.AddOutputFilterRequest{UserID: userID, TabID: tabID, Pattern: pattern})
+		if err != nil {
+			log.Warn("command filter add failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("filter %d added: /%s/", len(resp.Patterns), pattern))
+		log.Info("command filter added", "filters", len(resp.Patterns))
+		return nil
+	case "list", "ls":
+		resp, err := h.service.ListOutputFilters(ctx, schema.ListOutputFiltersRequest{UserID: userID, TabID: tabID})
+		if err != nil {
+			log.Warn("command filter list failed", "err", err)
+			return err
+		}
+		if len(resp.Patterns) == 0 {
+			h.appendLine(ctx, userID, tabID, "filters: none")
+			return nil
+		}
+		for i, pattern := range resp.Patterns {
+			h.appendLine(ctx, userID, tabID, fmt.Sprintf("%d. /%s/", i+1, pattern))
+		}
+		log.Info("command filter listed", "filters", len(resp.Patterns))
+		return nil
+	case "rm":
+		if len(cmd.Args) != 2 {
+			return errors.New("usage: /filter rm <n>")
+		}
+		index, err := strconv.Atoi(cmd.Args[1])
+		if err != nil {
+			return errors.New("usage: /filter rm <n>")
+		}
+		resp, err := h.service.RemoveOutputFilter(ctx, schema.RemoveOutputFilterRequest{UserID: userID, TabID: tabID, Index: index})
+		if err != nil {
+			log.Warn("command filter remove failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("filter removed: /%s/", resp.Removed))
+		log.Info("command filter removed", "filters", len(resp.Patterns))
+		return nil
+	default:
+		return errors.New(filterUsage)
+	}
+}
+
+// expandAlias expands a user-defined alias at the start of input, appending
+// any arguments to the expansion. Built-in commands and aliases always win,
+// and the expansion is not expanded again.
+func (h *Handler) expandAlias(ctx context.Context, userID schema.UserID, input string) (string, bool) {
+	// Only the name and the raw remainder are used, so syntax errors are
+	// left for the expanded command to report.
+	parsed, _ := Parse(input)
+	cmd := parsed.Command
+	if !parsed.IsCommand || cmd.Name == "" {
+		return "", false
+	}
+	if _, ok := lookupCommandSpec(cmd.Name); ok {
+		return "", false
+	}
+	resp, err := h.service.ListAliases(ctx, schema.ListAliasesRequest{UserID: userID})
+	if err != nil {
+		logx.WithUser(ctx, userID).Warn("command alias lookup failed", "err", err)
+		return "", false
+	}
+	for _, alias := range resp.Aliases {
+		if alias.Name != cmd.Name {
+			continue
+		}
+		if cmd.Remainder == "" {
+			return alias.Expansion, true
+		}
+		return alias.Expansion + " " + cmd.Remainder, true
+	}
+	return "", false
+}
+
+const aliasUsage = "usage: /alias set <name> <expansion> | /alias list | /alias rm <name>"
+
+func (h *Handler) handleAlias(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) == 0 {
+		return errors.New(aliasUsage)
+	}
+	sub := strings.ToLower(cmd.Args[0])
+	log = log.With("subcommand", sub)
+	switch sub {
+	case "set":
+		expansion := remainderAfterTokens(cmd.Raw, 3)
+		if len(cmd.Args) < 3 || expansion == "" {
+			return errors.New("usage: /alias set <name> <expansion>")
+		}
+		name := strings.ToLower(strings.TrimPrefix(cmd.Args[1], "/"))
+		if spec, ok := lookupCommandSpec(name); ok {
+			log.Warn("command alias rejected", "reason", "built-in command", "alias", name)
+			return fmt.Errorf("%w: /%s is a built-in command (/%s)", schema.ErrInvalidAlias, name, spec.Name)
+		}
+		resp, err := h.service.SetAlias(ctx, schema.SetAliasRequest{UserID: userID, Alias: schema.CommandAlias{Name: name, Expansion: expansion}})
+		if err != nil {
+			log.Warn("command alias set failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("alias set: /%s -> %s", name, expansion))
+		log.Info("command alias set", "aliases", len(resp.Aliases))
+		return nil
+	case "list", "ls":
+		resp, err := h.service.ListAliases(ctx, schema.ListAliasesRequest{UserID: userID})
+		if err != nil {
+			log.Warn("command alias list failed", "err", err)
+			return err
+		}
+		if len(resp.Aliases) == 0 {
+			h.appendLine(ctx, userID, tabID, "aliases: none")
+			return nil
+		}
+		for _, alias := range resp.Aliases {
+			h.appendLine(ctx, userID, tabID, fmt.Sprintf("/%s -> %s", alias.Name, alias.Expansion))
+		}
+		log.Info("command alias listed", "aliases", len(resp.Aliases))
+		return nil
+	case "rm":
+		if len(cmd.Args) != 2 {
+			return errors.New("usage: /alias rm <name>")
+		}
+		resp, err := h.service.RemoveAlias(ctx, schema.RemoveAliasRequest{UserID: userID, Name: cmd.Args[1]})
+		if err != nil {
+			log.Warn("command alias remove failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("alias removed: /%s", resp.Removed.Name))
+		log.Info("command alias removed", "aliases", len(resp.Aliases))
+		return nil
+	default:
+		return errors.New(aliasUsage)
+	}
+}
+
+const archiveUsage = "usage: /archive [--worktree] [path] | /archive tab [number_or_name] | /archive list | /archive restore <number_or_name>"
+
+func (h *Handler) handleArchive(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) > 0 && !cmd.HasFlag("worktree") {
+		switch strings.ToLower(cmd.Args[0]) {
+		case "tab":
+			return h.archiveTab(ctx, userID, tabID, cmd.Args[1:])
+		case "list", "ls":
+			return h.listArchivedTabs(ctx, userID, tabID, cmd.Args[1:])
+		case "restore":
+			return h.restoreArchivedTab(ctx, userID, tabID, cmd.Args[1:])
+		}
+	}
+	if h.cfg.ArchiveStore == nil {
+		log.Warn("command archive rejected", "reason", "archive downloads unavailable")
+		return errors.New("archive downloads require the HTTP server")
+	}
+	if tabID == "" {
+		return noActiveTabError("archive")
+	}
+	req := schema.WriteRepoArchiveRequest{UserID: userID, TabID: tabID, Worktree: cmd.HasFlag("worktree")}
+	switch len(cmd.Args) {
+	case 0:
+	case 1:
+		if strings.HasPrefix(cmd.Args[0], "-") {
+			return errors.New(archiveUsage)
+		}
+		req.Path = cmd.Args[0]
+	default:
+		return errors.New(archiveUsage)
+	}
+	var archive schema.WriteRepoArchiveResponse
+	saved, err := h.cfg.ArchiveStore.Save(ctx, archivestore.SaveRequest{
+		UserID: userID,
+		Write: func(w io.Writer) (string, error) {
+			req.Writer = w
+			resp, err := h.service.WriteRepoArchive(ctx, req)
+			archive = resp
+			return resp.Name, err
+		},
+	})
+	if err != nil {
+		log.Warn("command archive failed", "err", err)
+		return err
+	}
+	source := "HEAD"
+	if req.Worktree {
+		source = "working tree"
+	}
+	minutes := int(math.Ceil(saved.ExpiresAt.Sub(h.now()).Minutes()))
+	h.appendLine(ctx, userID, tabID, fmt.Sprintf("archive %s (%s, %d bytes): single download, expires in %d min", archive.Name, source, saved.Size, minutes))
+	h.appendLine(ctx, userID, tabID, h.cfg.ArchiveURLPrefix+saved.Token)
+	log.Info("command archive created", "bytes", saved.Size, "worktree", req.Worktree)
+	return nil
+}
+
+// archiveTab archives the current tab, or the tab named by args, hiding it
+// from the tab bar until /archive restore or activating it brings it back.
+func (h *Handler) archiveTab(ctx context.Context, userID schema.UserID, tabID schema.TabID, args []string) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(args) > 1 {
+		return errors.New(archiveUsage)
+	}
+	listResp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		log.Warn("command archive tab list failed", "err", err)
+		return err
+	}
+	targetID := tabID
+	targetName := nameForTab(tabID, listResp.Tabs)
+	if len(args) == 1 {
+		targetID, targetName, err = resolveTabRef(args[0], listResp.Tabs)
+		if err != nil {
+			log.Warn("command archive tab resolve failed", "err", err)
+			return err
+		}
+	} else if targetID == "" {
+		return noActiveTabError("archive")
+	}
+	if _, err := h.service.SetTabArchived(ctx, schema.SetTabArchivedRequest{UserID: userID, TabID: targetID, Archived: true}); err != nil {
+		log.Warn("command archive tab failed", "err", err)
+		return err
+	}
+	listResp, err = h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		return err
+	}
+	h.appendLine(ctx, userID, listResp.ActiveTab, fmt.Sprintf("tab archived: %s (/archive restore %s brings it back)", targetName, targetName))
+	log.Info("command archive tab completed", "tab", targetID, "name", targetName)
+	return nil
+}
+
+// archivedTabs lists the user's archived tabs in tab order; /archive list
+// numbers them from 1.
+func (h *Handler) archivedTabs(ctx context.Context, userID schema.UserID) ([]schema.TabSnapshot, error) {
+	resp, err := h.service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID, IncludeArchived: true})
+	if err != nil {
+		return nil, err
+	}
+	var archived []schema.TabSnapshot
+	for _, tab := range resp.Tabs {
+		if tab.Archived {
+			archived = append(archived, tab)
+		}
+	}
+	return archived, nil
+}
+
+func (h *Handler) listArchivedTabs(ctx context.Context, userID schema.UserID, tabID schema.TabID, args []string) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(args) > 0 {
+		return errors.New(archiveUsage)
+	}
+	tabs, err := h.archivedTabs(ctx, userID)
+	if err != nil {
+		log.Warn("command archive list failed", "err", err)
+		return err
+	}
+	if len(tabs) == 0 {
+		h.appendLine(ctx, userID, tabID, "archived tabs: none")
+		return nil
+	}
+	now := h.now()
+	for i, tab := range tabs {
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("%d. %s (%s) last active %s", i+1, tab.Name, tab.Repo.Name, formatRelativeTime(now, tab.LastActivityAt)))
+	}
+	log.Info("command archive listed", "tabs", len(tabs))
+	return nil
+}
+
+func (h *Handler) restoreArchivedTab(ctx context.Context, userID schema.UserID, tabID schema.TabID, args []string) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(args) != 1 {
+		return errors.New(archiveUsage)
+	}
+	tabs, err := h.archivedTabs(ctx, userID)
+	if err != nil {
+		log.Warn("command archive restore list failed", "err", err)
+		return err
+	}
+	targetID, targetName, err := resolveTabRef(args[0], tabs)
+	if err != nil {
+		log.Warn("command archive restore resolve failed", "err", err)
+		return fmt.Errorf("archived %w", err)
+	}
+	if _, err := h.service.SetTabArchived(ctx, schema.SetTabArchivedRequest{UserID: userID, TabID: targetID}); err != nil {
+		log.Warn("command archive restore failed", "err", err)
+		return err
+	}
+	if _, err := h.service.ActivateTab(ctx, schema.ActivateTabRequest{UserID: userID, TabID: targetID}); err != nil {
+		log.Warn("command archive restore activate failed", "err", err)
+		return err
+	}
+	h.appendLine(ctx, userID, targetID, fmt.Sprintf("tab restored: %s", targetName))
+	log.Info("command archive restore completed", "tab", targetID, "name", targetName)
+	return nil
+}
+
+const grepUsage = "usage: /grep [-c] [-e] <query> | /grep open <n>"
+
+// grepOpenViewLines is the view height /grep open centres a buffer match
+// in; the handler does not know the client's.
+const grepOpenViewLines = 20
+
+func (h *Handler) handleGrep(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) == 0 {
+		return errors.New(grepUsage)
+	}
+	if strings.EqualFold(cmd.Args[0], "open") && len(cmd.Args) == 2 {
+		if n, err := strconv.Atoi(cmd.Args[1]); err == nil {
+			return h.openGrepMatch(ctx, userID, tabID, n)
+		}
+	}
+	req := schema.SearchAllRequest{UserID: userID}
+	opts := 0
+options:
+	for ; opts < len(cmd.Args); opts++ {
+		switch cmd.Args[opts] {
+		case "-c":
+			req.CaseSensitive = true
+		case "-e":
+			req.Regexp = true
+		default:
+			break options
+		}
+	}
+	req.Query = remainderAfterTokens(cmd.Raw, opts+1)
+	if req.Query == "" {
+		return errors.New(grepUsage)
+	}
+	resp, err := h.service.SearchAll(ctx, req)
+	if err != nil {
+		log.Warn("command grep failed", "err", err)
+		return err
+	}
+	h.grepMu.Lock()
+	h.grepResults[userID] = resp.Matches
+	h.grepMu.Unlock()
+	h.appendLines(ctx, userID, tabID, grepResultLines(req.Query, resp, h.now())...)
+	log.Info("command grep completed", "matches", len(resp.Matches), "truncated", resp.Truncated)
+	return nil
+}
+
+// grepResultLines renders /grep matches grouped by tab, numbered across
+// the groups for /grep open.
+func grepResultLines(query string, resp schema.SearchAllResponse, now time.Time) []schema.BufferLine {
+	if len(resp.Matches) == 0 {
+		return []schema.BufferLine{schema.Line(schema.LineKindSystem, fmt.Sprintf("grep %q: no matches", query))}
+	}
+	tabs := 0
+	for i, match := range resp.Matches {
+		if i == 0 || match.TabID != resp.Matches[i-1].TabID {
+			tabs++
+		}
+	}
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSystem, fmt.Sprintf("grep %q: %d matches in %d tabs (/grep open <n> jumps to one)", query, len(resp.Matches), tabs))}
+	for i, match := range resp.Matches {
+		if i == 0 || match.TabID != resp.Matches[i-1].TabID {
+			lines = append(lines, schema.Line(schema.LineKindSystem, string(match.TabName)+":"))
+		}
+		source := ""
+		if match.Source == schema.SearchSourceHistory {
+			source = " prompt"
+		}
+		lines = append(lines, schema.Line(schema.LineKindSystem, fmt.Sprintf("  %d. [%s%s] %s", i+1, formatRelativeTime(now, match.Timestamp), source, match.Excerpt)))
+	}
+	if resp.Truncated {
+		lines = append(lines, schema.Line(schema.LineKindSystem, fmt.Sprintf("only the first %d matches are shown; narrow the query to see others", len(resp.Matches))))
+	}
+	return lines
+}
+
+// openGrepMatch switches to the tab of match n of the user's latest /grep
+// and scrolls its buffer to the matching line.
+func (h *Handler) openGrepMatch(ctx context.Context, userID schema.UserID, tabID schema.TabID, n int) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	h.grepMu.Lock()
+	matches := h.grepResults[userID]
+	h.grepMu.Unlock()
+	if len(matches) == 0 {
+		return errors.New("no /grep results to open")
+	}
+	if n < 1 || n > len(matches) {
+		return fmt.Errorf("grep match %d not found (1-%d)", n, len(matches))
+	}
+	match := matches[n-1]
+	if _, err := h.service.ActivateTab(ctx, schema.ActivateTabRequest{UserID: userID, TabID: match.TabID}); err != nil {
+		log.Warn("command grep open activate failed", "err", err)
+		return err
+	}
+	if match.Source == schema.SearchSourceHistory {
+		h.appendStatus(ctx, userID, match.TabID, "prompt matched by /grep: "+match.Excerpt)
+		log.Info("command grep open completed", "tab", match.TabID, "source", match.Source)
+		return nil
+	}
+	if _, err := h.service.ScrollBuffer(ctx, schema.ScrollBufferRequest{UserID: userID, TabID: match.TabID, ToLine: match.Line, Limit: grepOpenViewLines}); err != nil {
+		log.Warn("command grep open scroll failed", "err", err)
+		return err
+	}
+	log.Info("command grep open completed", "tab", match.TabID, "source", match.Source, "line", match.Line)
+	return nil
+}
+
+const shareUsage = "usage: /share <user> [rw]"
+
+func (h *Handler) handleShare(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	access := schema.ShareAccessRead
+	switch {
+	case len(cmd.Args) == 1:
+	case len(cmd.Args) == 2 && strings.EqualFold(cmd.Args[1], string(schema.ShareAccessReadWrite)):
+		access = schema.ShareAccessReadWrite
+	default:
+		return errors.New(shareUsage)
+	}
+	guest := schema.UserID(cmd.Args[0])
+	resp, err := h.service.ShareTab(ctx, schema.ShareTabRequest{UserID: userID, TabID: tabID, Guest: guest, Access: access})
+	if err != nil {
+		log.Warn("command share failed", "guest", guest, "err", err)
+		return err
+	}
+	mode := "read-only"
+	if access == schema.ShareAccessReadWrite {
+		mode = "read-write"
+	}
+	h.appendLine(ctx, userID, tabID, fmt.Sprintf("tab shared with %s (%s)", guest, mode))
+	log.Info("command share completed", "guest", guest, "access", access, "shares", len(resp.Shares))
+	return nil
+}
+
+func (h *Handler) handleUnshare(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) != 1 {
+		return errors.New("usage: /unshare <user>")
+	}
+	guest := schema.UserID(cmd.Args[0])
+	resp, err := h.service.UnshareTab(ctx, schema.UnshareTabRequest{UserID: userID, TabID: tabID, Guest: guest})
+	if err != nil {
+		log.Warn("command unshare failed", "guest", guest, "err", err)
+		return err
+	}
+	h.appendLine(ctx, userID, tabID, fmt.Sprintf("tab no longer shared with %s", guest))
+	log.Info("command unshare completed", "guest", guest, "shares", len(resp.Shares))
+	return nil
+}
+
+const shareLinkUsage = "usage: /sharelink [ttl] | revoke"
+
+// handleShareLink creates or revokes links that show the current tab
+// read-only in a browser without an account.
+func (h *Handler) handleShareLink(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) > 1 {
+		return errors.New(shareLinkUsage)
+	}
+	if len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], "revoke") {
+		resp, err := h.service.RevokeShareLinks(ctx, schema.RevokeShareLinksRequest{UserID: userID, TabID: tabID})
+		if err != nil {
+			log.Warn("command sharelink revoke failed", "err", err)
+			return err
+		}
+		h.appendLine(ctx, userID, tabID, fmt.Sprintf("share links revoked: %d", resp.Revoked))
+		log.Info("command sharelink revoke completed", "revoked", resp.Revoked)
+		return nil
+	}
+	if h.cfg.ViewURLPrefix == "" {
+		log.Warn("command sharelink rejected", "reason", "http disabled")
+		return errors.New("share links require the HTTP server")
+	}
+	var ttl time.Duration
+	if len(cmd.Args) == 1 {
+		parsed, err := time.ParseDuration(cmd.Args[0])
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid ttl %q: use a duration such as 30m or 24h", cmd.Args[0])
+		}
+		ttl = parsed
+	}
+	resp, err := h.service.CreateShareLink(ctx, schema.CreateShareLinkRequest{UserID: userID, TabID: tabID, TTL: ttl})
+	if err != nil {
+		log.Warn("command sharelink failed", "err", err)
+		return err
+	}
+	clock := h.clock(ctx, userID)
+	h.appendLines(ctx, userID, tabID,
+		schema.Line(schema.LineKindSystem, fmt.Sprintf("read-only link to this tab, valid until %s (/sharelink revoke ends it sooner):", clock.FormatFrom(resp.ExpiresAt, h.now()))),
+		schema.Line(schema.LineKindSystem, h.cfg.ViewURLPrefix+resp.Token),
+	)
+	log.Info("command sharelink completed", "expires_at", resp.ExpiresAt)
+	return nil
+}
+
+const defaultEventsListLimit = 10
+
+// handleEvents prints the latest activity feed entries from the system
+// buffer into the current tab.
+func (h *Handler) handleEvents(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	limit := defaultEventsListLimit
+	if len(cmd.Args) > 1 {
+		return errors.New("usage: /events [n]")
+	}
+	if len(cmd.Args) == 1 {
+		n, err := strconv.Atoi(cmd.Args[0])
+		if err != nil || n <= 0 {
+			return errors.New("usage: /events [n]")
+		}
+		limit = n
+	}
+	resp, err := h.service.ListActivity(ctx, schema.ListActivityRequest{UserID: userID, Limit: limit})
+	if err != nil {
+		log.Warn("command events failed", "err", err)
+		return err
+	}
+	if len(resp.Entries) == 0 {
+		h.appendLine(ctx, userID, tabID, "events: none")
+		return nil
+	}
+	lines := make([]schema.BufferLine, 0, len(resp.Entries))
+	for _, entry := range resp.Entries {
+		text := entry.Text
+		if !entry.Timestamp.IsZero() {
+			text = entry.Timestamp.Local().Format("2006-01-02 15:04:05") + "  " + text
+		}
+		lines = append(lines, schema.Line(schema.LineKindSystem, text))
+	}
+	h.appendLines(ctx, userID, tabID, lines...)
+	log.Info("command events listed", "entries", len(lines))
+	return nil
+}
+
+const defaultServerLogLimit = 50
+
+// handleServerLog writes the latest server log entries at or above a level
+// to the admin's system buffer.
+func (h *Handler) handleServerLog(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if prefs := sessionprefs.FromContext(ctx); prefs == nil || !prefs.Capabilities.Admin {
+		log.Warn("command serverlog rejected", "reason", "not admin")
+		return schema.ErrAdminOnly
+	}
+	if h.cfg.ServerLog == nil {
+		return errors.New("server log is off (logging.server_log_lines is 0)")
+	}
+	usage := errors.New("usage: /serverlog [n] [level]")
+	if len(cmd.Args) > 2 {
+		return usage
+	}
+	limit := defaultServerLogLimit
+	level := pslog.InfoLevel
+	for _, arg := range cmd.Args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n <= 0 {
+				return usage
+			}
+			limit = n
+			continue
+		}
+		parsed, ok := pslog.ParseLevel(arg)
+		if !ok {
+			return usage
+		}
+		level = parsed
+	}
+	entries := h.cfg.ServerLog.Last(limit, level)
+	if len(entries) == 0 {
+		h.appendLine(ctx, userID, "", "serverlog: no entries")
+		return nil
+	}
+	lines := make([]schema.BufferLine, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, schema.Line(schema.LineKindStderr, entry.String()))
+	}
+	h.appendLines(ctx, userID, "", lines...)
+	log.Info("command serverlog listed", "entries", len(lines), "level", pslog.LevelString(level))
+	return nil
+}
+
+// handleDebug runs /debug state, which shows the caller's own state usage.
+func (h *Handler) handleDebug(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if prefs := sessionprefs.FromContext(ctx); prefs == nil || !prefs.Capabilities.Admin {
+		log.Warn("command debug rejected", "reason", "not admin")
+		return schema.ErrAdminOnly
+	}
+	if len(cmd.Args) != 1 || cmd.Args[0] != "state" {
+		return errors.New("usage: /debug state")
+	}
+	if strings.TrimSpace(h.cfg.StateDir) == "" {
+		return errors.New("state dir is not configured")
+	}
+	report := statereport.MeasureUser(statereport.Paths{StateDir: h.cfg.StateDir, GitKeyDir: h.cfg.GitKeyDir}, userID)
+	lines := []schema.BufferLine{schema.Line(schema.LineKindSeparator, "State")}
+	for _, line := range report.Lines() {
+		lines = append(lines, schema.Line(schema.LineKindSystem, line))
+	}
+	h.appendLines(ctx, userID, tabID, lines...)
+	log.Info("command debug state listed", "bytes", report.Bytes())
+	return nil
+}
+
+// formatRelativeTime renders at relative to now in the largest whole unit.
+func formatRelativeTime(now, at time.Time) string {
+	if at.IsZero() {
+		return "-"
+	}
+	return timefmt.Ago(now, at)
+}
+
+func (h *Handler) handleStatus(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	resp, err := h.service.GetTabStatus(ctx, schema.GetTabStatusRequest{UserID: userID, TabID: tabID, Refresh: cmd.HasFlag("refresh")})
+	if err != nil {
+		log.Warn("command status failed", "err", err)
+		return err
+	}
+	h.appendLines(ctx, userID, tabID, h.renderStatus(resp.Status, h.clock(ctx, userID))...)
+	log.Info("command status completed", "tokens_used", resp.Status.TokensUsed, "usage_ok", resp.Status.Usage != nil, "chatgpt", resp.Status.Usage != nil && resp.Status.Usage.ChatGPT)
+	return nil
+}
+
+func (h *Handler) handleRunnerStatus(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	preflighter, ok := h.runners.(core.RunnerPreflighter)
+	if !ok {
+		return errors.New("this runner has no environment checks")
+	}
+	tab, err := h.lookupTab(ctx, userID, tabID)
+	if err != nil {
+		log.Warn("command runnerstatus lookup failed", "err", err)
+		return err
+	}
+	h.appendStatus(ctx, userID, tabID, "checking runner environment")
+	result, err := preflighter.Preflight(ctx, core.RunnerRequest{UserID: tabOwner(userID, tab), TabID: tabID})
+	if err != nil {
+		log.Warn("command runnerstatus failed", "err", err)
+		h.appendError(ctx, userID, tabID, err)
+		return err
+	}
+	h.appendLines(ctx, userID, tabID, renderRunnerStatus(result)...)
+	log.Info("command runnerstatus completed", "container", result.Container, "problems", len(result.Problems))
+	return nil
+}
+
+// renderRunnerStatus formats a runner preflight as /runnerstatus output.
+func renderRunnerStatus(result core.RunnerPreflight) []schema.BufferLine {
+	clock := "not checked"
+	if result.ClockChecked {
+		clock = "in sync"
+		if result.ClockSkew != 0 {
+			clock = fmt.Sprintf("skewed by %s", result.ClockSkew)
+		}
+	}
+	certs := "not checked"
+	if result.CAChecked {
+		certs = "present"
+		if !result.CACerts {
+			certs = "missing"
+		}
+	}
+	labelWidth := schema.LabelWidth("Container", "Clock", "CA certs", "Mount")
+	lines := []schema.BufferLine{
+		schema.Line(schema.LineKindSeparator, "Runner"),
+		formatStatusLine("Container", result.Container, labelWidth),
+		formatStatusLine("Clock", clock, labelWidth),
+		formatStatusLine("CA certs", certs, labelWidth),
+	}
+	for _, mount := range result.Mounts {
+		value := mount.Host + " -> " + mount.Runner
+		if mount.ReadOnly {
+			value += " (read-only)"
+		}
+		lines = append(lines, formatStatusLine("Mount", value, labelWidth))
+	}
+	for _, problem := range result.Problems {
+		lines = append(lines, schema.Line(schema.LineKindError, "error: "+problem))
+	}
+	for _, hint := range result.Hints {
+		lines = append(lines, schema.Line(schema.LineKindSystem, hint))
+	}
+	return lines
+}
+
+// renderStatus formats a tab status report as /status output.
+func (h *Handler) renderStatus(status schema.TabStatusInfo, clock timefmt.Clock) []schema.BufferLine {
+	model := schema.FormatModelWithReasoning(status.Model, status.ModelReasoningEffort)
+	session := string(status.SessionID)
+	if strings.TrimSpace(session) == "" {
+		session = "none"
+	}
+	usage := status.Usage
+	showUsage := usage != nil && usage.ChatGPT
+	labels := []string{"Model", "Directory", "Session", "Tokens used"}
+	if status.Ephemeral {
+		labels = append(labels, "Persistence")
+	}
+	if showUsage {
+		labels = append(labels, "5h limit", "Week limit")
+	}
+	labelWidth := schema.LabelWidth(labels...)
+
+	lines := []schema.BufferLine{
+		schema.Line(schema.LineKindSeparator, "Status"),
+		formatStatusLine("Model", model, labelWidth),
+		formatStatusLine("Directory", status.Directory, labelWidth),
+		formatStatusLine("Session", session, labelWidth),
+		formatStatusLine("Tokens used", formatTokensUsed(status.TokensUsed), labelWidth),
+	}
+	if status.Ephemeral {
+		lines = append(lines, formatStatusLine("Persistence", "off", labelWidth))
+	}
+	if showUsage {
+		now := h.now()
+		lines = append(lines,
+			formatStatusLine("5h limit", formatUsageWindow(usage.Primary, usage.Error, now, clock), labelWidth),
+			formatStatusLine("Week limit", formatUsageWindow(usage.Secondary, usage.Error, now, clock), labelWidth),
+		)
+	}
+	return lines
+}
+
+func (h *Handler) handleVersion(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	versionLine := fmt.Sprintf("%s %s", version.Module(), version.Current())
+	h.appendLines(ctx, userID, tabID,
+		schema.Line(schema.LineKindSeparator, "About"),
+		schema.Line(schema.LineKindAboutVersion, versionLine),
+		schema.Line(schema.LineKindAboutCopyright, "Copyright (C) 2025-2026 Michel Blomgren"),
+		schema.Line(schema.LineKindAboutLink, "https://github.com/sa6mwa/centaurx"),
+		schema.Line(schema.LineKindSystem, ""),
+	)
+	log.Info("command version completed")
+	return nil
+}
+
+func (h *Handler) handleShell(ctx context.Context, userID schema.UserID, tabID schema.TabID, input string) error {
+	baseLog := logx.WithUserTab(ctx, userID, tabID)
+	ctx = logx.ContextWithUserTabLogger(ctx, baseLog, userID, tabID)
+	log := baseLog
+	if h.runners == nil {
+		log.Warn("command shell rejected", "reason", "runner not configured")
+		return errors.New("runner not configured")
+	}
+	if err := checkAccountWrite(ctx); err != nil {
+		log.Warn("command shell rejected", "err", err)
+		return err
+	}
+	input, stdin, piped := splitShellStdin(input)
+	pty := strings.HasPrefix(input, "!!")
+	cmdText := strings.TrimSpace(strings.TrimPrefix(input, "!"))
+	if pty {
+		cmdText = strings.TrimSpace(strings.TrimPrefix(input, "!!"))
+	}
+	cmdText, saveOutput, err := splitShellOptions(cmdText)
+	if err != nil {
+		log.Warn("command shell rejected", "reason", "invalid options", "err", err)
+		return err
+	}
+	if cmdText == "" {
+		log.Warn("command shell rejected", "reason", "empty command")
+		if saveOutput != "" {
+			return fmt.Errorf("usage: !{save=<path>} <cmd>")
+		}
+		if pty {
+			return fmt.Errorf("usage: !! <cmd>")
+		}
+		return fmt.Errorf("usage: ! <cmd>")
+	}
+	if piped && pty {
+		log.Warn("command shell rejected", "reason", "stdin with pty")
+		return errors.New("input cannot be piped to !! commands")
+	}
+	if len(stdin) > maxShellStdin {
+		log.Warn("command shell rejected", "reason", "stdin too large", "stdin_len", len(stdin))
+		return fmt.Errorf("piped input is limited to %d KiB", maxShellStdin/1024)
+	}
+	if saveOutput != "" && tabID == "" {
+		log.Warn("command shell rejected", "reason", "save output without tab")
+		return schema.WithCode(schema.CodeTabNotFound, errors.New("saving output requires an active tab — open one with /new"))
+	}
+	log = log.With("command_len", len(cmdText), "pty", pty)
+	displayTabID := tabID
+	runnerTabID := tabID
 	if runnerTabID == "" {
 		runnerTabID = schema.TabID("system-shell")
 	}
 	var tab schema.TabSnapshot
 	if displayTabID != "" {
 		loaded, err := h.lookupTab(ctx, userID, displayTabID)
+		if err == nil {
+			err = checkWriteAccess(loaded)
+		}
 		if err != nil {
 			log.Warn("command shell lookup failed", "err", err)
 			h.appendError(ctx, userID, displayTabID, err)
@@ -755,10 +2344,11 @@ func (h *Handler) handleShell(ctx context.Context, userID schema.UserID, tabID s
 		tab = loaded
 		sessionLog := logx.WithSession(baseLog, tab.SessionID)
 		ctx = logx.ContextWithUserTabLogger(ctx, sessionLog, userID, displayTabID)
-		log = logx.WithRepo(sessionLog, core.RepoRefForUser(h.cfg.RepoRoot, userID, tab.Repo.Name)).With("command_len", len(cmdText))
+		log = logx.WithRepo(sessionLog, core.RepoRefForUser(h.cfg.RepoRoot, tabOwner(userID, tab), tab.Repo.Name)).With("command_len", len(cmdText), "pty", pty)
 	}
+	owner := tabOwner(userID, tab)
 	runCtx, runCancel := detachCommandContext(ctx)
-	runnerResp, err := h.runners.RunnerFor(runCtx, core.RunnerRequest{UserID: userID, TabID: runnerTabID})
+	runnerResp, err := h.runners.RunnerFor(runCtx, core.RunnerRequest{UserID: owner, TabID: runnerTabID})
 	if err != nil {
 		log.Warn("command shell runner failed", "err", err)
 		h.appendError(ctx, userID, displayTabID, err)
@@ -771,14 +2361,14 @@ func (h *Handler) handleShell(ctx context.Context, userID schema.UserID, tabID s
 	info := runnerResp.Info
 	workingDir := info.HomeDir
 	if displayTabID != "" {
-		workingDir, err = core.RepoPath(h.cfg.RepoRoot, userID, tab.Repo.Name)
+		workingDir, err = core.RepoPath(h.cfg.RepoRoot, owner, tab.Repo.Name)
 		if err != nil {
 			log.Warn("command shell repo path failed", "err", err)
 			h.appendError(ctx, userID, displayTabID, err)
 			return err
 		}
 		if info.RepoRoot != "" && h.cfg.RepoRoot != "" {
-			mapped, err := core.MapRepoPath(h.cfg.RepoRoot, info.RepoRoot, workingDir)
+			mapped, err := core.MapRepoPath(h.cfg.RepoRoot, info, workingDir)
 			if err != nil {
 				log.Warn("command shell repo map failed", "err", err)
 				h.appendError(ctx, userID, displayTabID, err)
@@ -791,15 +2381,22 @@ func (h *Handler) handleShell(ctx context.Context, userID schema.UserID, tabID s
 	}
 	tracker, _ := h.service.(core.CommandTracker)
 	if !h.cfg.DisableAuditLogging {
-		log.Debug("audit command", "command_type", "shell", "command", cmdText, "workdir", workingDir)
+		log.Debug("audit command", "command_type", "shell", "command", cmdText, "workdir", workingDir, "stdin_len", len(stdin), "save_output", saveOutput)
 	}
-	started := time.Now()
-	handle, err := runner.RunCommand(runCtx, core.RunCommandRequest{
+	req := core.RunCommandRequest{
 		WorkingDir:  workingDir,
 		Command:     cmdText,
 		UseShell:    true,
 		SSHAuthSock: info.SSHAuthSock,
-	})
+		PTY:         pty,
+		Stdin:       stdin,
+		SaveOutput:  saveOutput,
+	}
+	if prefs := sessionprefs.FromContext(ctx); pty && prefs != nil {
+		req.Rows, req.Cols = prefs.TermRows, prefs.TermCols
+	}
+	started := time.Now()
+	handle, err := runner.RunCommand(runCtx, req)
 	if err != nil {
 		log.Warn("command shell start failed", "err", err)
 		h.appendError(ctx, userID, displayTabID, err)
@@ -808,13 +2405,93 @@ func (h *Handler) handleShell(ctx context.Context, userID schema.UserID, tabID s
 		}
 		return err
 	}
-	h.appendLine(ctx, userID, displayTabID, "$ "+cmdText)
-	log.Trace("command shell started", "workdir", workingDir)
-	if tracker != nil && displayTabID != "" {
-		tracker.RegisterCommand(runCtx, userID, displayTabID, handle, runCancel)
+	if piped {
+		h.appendLine(ctx, userID, displayTabID, "$ "+cmdText+" <<")
+		h.appendLines(ctx, userID, displayTabID, stdinPreviewLines(stdin)...)
+	} else {
+		h.appendLine(ctx, userID, displayTabID, "$ "+cmdText)
+	}
+	log.Trace("command shell started", "workdir", workingDir)
+	if tracker != nil && displayTabID != "" {
+		if pty {
+			tracker.RegisterPTYCommand(runCtx, userID, displayTabID, handle, runCancel)
+		} else {
+			tracker.RegisterCommand(runCtx, userID, displayTabID, handle, runCancel)
+		}
+	}
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil {
+		prefs.Commands.Start()
+	}
+	go h.streamCommandOutput(runCtx, userID, displayTabID, handle, started, tracker, runCancel)
+	return nil
+}
+
+// splitShellOptions splits a leading {save=<path>} off a shell command and
+// returns the command and the save path. The path must be relative and stay
+// inside the repo; the runner re-checks it against symlinks.
+func splitShellOptions(cmdText string) (string, string, error) {
+	if !strings.HasPrefix(cmdText, "{") {
+		return cmdText, "", nil
+	}
+	end := strings.Index(cmdText, "}")
+	if end < 0 {
+		return "", "", fmt.Errorf("usage: !{save=<path>} <cmd>")
+	}
+	key, value, _ := strings.Cut(cmdText[1:end], "=")
+	if strings.TrimSpace(key) != "save" {
+		return "", "", fmt.Errorf("unknown shell option %q (supported: save)", strings.TrimSpace(key))
+	}
+	path := strings.TrimSpace(value)
+	if path == "" {
+		return "", "", fmt.Errorf("usage: !{save=<path>} <cmd>")
 	}
-	go h.streamCommandOutput(runCtx, userID, displayTabID, handle, started, tracker, runCancel)
-	return nil
+	if !filepath.IsLocal(path) {
+		return "", "", fmt.Errorf("save path %q must stay inside the repo", path)
+	}
+	return strings.TrimSpace(cmdText[end+1:]), filepath.Clean(path), nil
+}
+
+// maxShellStdin bounds the input piped to a shell command with <<.
+const maxShellStdin = 1 << 20
+
+// stdinPreviewLinesShown is how many piped lines are echoed to the buffer.
+const stdinPreviewLinesShown = 3
+
+// splitShellStdin splits `! <cmd> <<` input into the command line and the
+// text piped to its stdin, which is every line after the first. Input whose
+// first line does not end in "<<" is returned unchanged.
+func splitShellStdin(input string) (string, []byte, bool) {
+	first, rest, _ := strings.Cut(input, "\n")
+	trimmed := strings.TrimRight(first, " \t\r")
+	if !strings.HasSuffix(trimmed, "<<") {
+		return input, nil, false
+	}
+	if rest != "" && !strings.HasSuffix(rest, "\n") {
+		rest += "\n"
+	}
+	return strings.TrimSuffix(trimmed, "<<"), []byte(rest), true
+}
+
+// stdinPreviewLines echoes the start of piped input instead of all of it.
+func stdinPreviewLines(stdin []byte) []schema.BufferLine {
+	lines := strings.Split(strings.TrimSuffix(string(stdin), "\n"), "\n")
+	if len(stdin) == 0 {
+		lines = nil
+	}
+	shown := min(len(lines), stdinPreviewLinesShown)
+	out := make([]schema.BufferLine, 0, shown+1)
+	for _, line := range lines[:shown] {
+		out = append(out, schema.Line(schema.LineKindSystem, "< "+line))
+	}
+	switch more := len(lines) - shown; {
+	case len(lines) == 0:
+		out = append(out, schema.Line(schema.LineKindSystem, "(no input piped)"))
+	case more == 1:
+		out = append(out, schema.Line(schema.LineKindSystem, "(1 more line piped)"))
+	case more > 1:
+		out = append(out, schema.Line(schema.LineKindSystem, fmt.Sprintf("(%d more lines piped)", more)))
+	}
+	return out
 }
 
 func (h *Handler) streamCommandOutput(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle core.CommandHandle, started time.Time, tracker core.CommandTracker, cancel context.CancelFunc) {
@@ -836,31 +2513,40 @@ func (h *Handler) streamCommandOutput(ctx context.Context, userID schema.UserID,
 				break
 			}
 			log.Warn("command stream error", "err", err)
-			h.appendLine(ctx, userID, tabID, fmt.Sprintf("command error: %v", err))
+			h.appendLines(ctx, userID, tabID, schema.Line(schema.LineKindError, fmt.Sprintf("command error: %v", err)))
 			break
 		}
-		line := output.Text
+		kind := schema.LineKindSystem
 		if output.Stream == core.CommandStreamStderr {
-			line = schema.StderrMarker + line
+			kind = schema.LineKindStderr
 		}
-		h.appendLine(ctx, userID, tabID, line)
+		h.appendLines(ctx, userID, tabID, schema.Line(kind, output.Text))
 	}
 	result, err := handle.Wait(ctx)
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil {
+		// Deferred so line-mode sessions see the output lines first.
+		defer prefs.Commands.Finish(result.ExitCode, err)
+	}
 	if err != nil {
 		log.Warn("command wait failed", "err", err)
-		h.appendLine(ctx, userID, tabID, fmt.Sprintf("command failed: %v", err))
+		h.appendLines(ctx, userID, tabID, schema.Line(schema.LineKindError, fmt.Sprintf("command failed: %v", err)))
+		h.appendSavedOutput(ctx, userID, tabID, result)
 		return
 	}
 	h.appendLine(ctx, userID, tabID, formatCommandFinishedLine(time.Since(started), result.ExitCode))
+	if result.OrphansKilled {
+		h.appendLine(ctx, userID, tabID, "killed processes the command left running in the background")
+	}
+	h.appendSavedOutput(ctx, userID, tabID, result)
 	log.Trace("command completed", "exit_code", result.ExitCode, "duration_ms", time.Since(started).Milliseconds())
 }
 
 func (h *Handler) handleGit(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
 	if len(cmd.Args) == 0 {
-		return fmt.Errorf("usage: /git commit [message]")
+		return fmt.Errorf("usage: /git commit [message] | init")
 	}
 	sub := strings.ToLower(cmd.Args[0])
-	if sub != "commit" {
+	if sub != "commit" && sub != "init" {
 		return fmt.Errorf("unsupported /git subcommand: %s", sub)
 	}
 	if h.runners == nil {
@@ -871,83 +2557,254 @@ func (h *Handler) handleGit(ctx context.Context, userID schema.UserID, tabID sch
 	log := baseLog
 
 	tab, err := h.lookupTab(ctx, userID, tabID)
+	if err == nil {
+		err = checkWriteAccess(tab)
+	}
+	if err == nil {
+		err = checkAccountWrite(ctx)
+	}
 	if err != nil {
 		log.Warn("command git lookup failed", "err", err)
 		h.appendError(ctx, userID, tabID, err)
 		return err
 	}
-	if tab.Status == schema.TabStatusRunning {
+	if tab.Status.Busy() {
 		log.Warn("command git rejected", "err", schema.ErrTabBusy)
 		h.appendError(ctx, userID, tabID, schema.ErrTabBusy)
 		return schema.ErrTabBusy
 	}
+	owner := tabOwner(userID, tab)
 	sessionLog := logx.WithSession(baseLog, tab.SessionID)
 	ctx = logx.ContextWithUserTabLogger(ctx, sessionLog, userID, tabID)
-	log = logx.WithRepo(sessionLog, core.RepoRefForUser(h.cfg.RepoRoot, userID, tab.Repo.Name)).With("subcommand", sub)
+	log = logx.WithRepo(sessionLog, core.RepoRefForUser(h.cfg.RepoRoot, owner, tab.Repo.Name)).With("subcommand", sub)
 
 	message := remainderAfterTokens(cmd.Raw, 2)
 
-	if strings.TrimSpace(message) == "" {
+	if sub == "commit" && strings.TrimSpace(message) == "" {
 		h.appendStatus(ctx, userID, tabID, "generating commit message")
 		generated, err := h.generateCommitMessage(ctx, userID, tab, h.cfg.CommitModel)
 		if err != nil {
 			log.Warn("command git message failed", "err", err)
-			h.appendError(ctx, userID, tabID, err)
-			return err
+			return h.appendGitError(ctx, userID, tabID, err)
 		}
 		message = generated
 	}
 
-	runnerResp, err := h.runners.RunnerFor(ctx, core.RunnerRequest{UserID: userID, TabID: tabID})
+	repo, err := h.repoRunner(ctx, owner, tab)
 	if err != nil {
 		log.Warn("command git runner failed", "err", err)
 		h.appendError(ctx, userID, tabID, err)
 		return err
 	}
-	runner := runnerResp.Runner
-	info := runnerResp.Info
-	workingDir, err := core.RepoPath(h.cfg.RepoRoot, userID, tab.Repo.Name)
+	if sub == "init" {
+		return h.gitInit(ctx, userID, tabID, repo.runner, repo.info, repo.workingDir)
+	}
+
+	output, err := h.gitCommit(ctx, userID, tabID, repo, message)
 	if err != nil {
-		log.Warn("command git repo path failed", "err", err)
-		h.appendError(ctx, userID, tabID, err)
-		return err
+		log.Warn("command git commit failed", "err", err)
+		return h.appendGitError(ctx, userID, tabID, err)
 	}
-	if info.RepoRoot != "" && h.cfg.RepoRoot != "" {
-		mapped, err := core.MapRepoPath(h.cfg.RepoRoot, info.RepoRoot, workingDir)
+	h.appendCommitOutput(ctx, userID, tabID, "git commit completed", output)
+	log.Info("command git commit completed")
+	return nil
+}
+
+// repoRunner is the runner of a tab and the repo's path inside it.
+type repoRunner struct {
+	runner     core.Runner
+	info       core.RunnerInfo
+	workingDir string
+}
+
+// repoRunner looks up the runner of owner's tab and maps the tab's repo
+// into it.
+func (h *Handler) repoRunner(ctx context.Context, owner schema.UserID, tab schema.TabSnapshot) (repoRunner, error) {
+	runnerResp, err := h.runners.RunnerFor(ctx, core.RunnerRequest{UserID: owner, TabID: tab.ID})
+	if err != nil {
+		return repoRunner{}, err
+	}
+	workingDir, err := core.RepoPath(h.cfg.RepoRoot, owner, tab.Repo.Name)
+	if err != nil {
+		return repoRunner{}, err
+	}
+	if runnerResp.Info.RepoRoot != "" && h.cfg.RepoRoot != "" {
+		workingDir, err = core.MapRepoPath(h.cfg.RepoRoot, runnerResp.Info, workingDir)
 		if err != nil {
-			log.Warn("command git repo map failed", "err", err)
-			h.appendError(ctx, userID, tabID, err)
-			return err
+			return repoRunner{}, err
 		}
-		workingDir = mapped
 	}
+	return repoRunner{runner: runnerResp.Runner, info: runnerResp.Info, workingDir: workingDir}, nil
+}
 
+// gitCommit stages every change in the repo and commits it with message,
+// returning the output of git commit.
+func (h *Handler) gitCommit(ctx context.Context, userID schema.UserID, tabID schema.TabID, repo repoRunner, message string) (string, error) {
 	h.appendStatus(ctx, userID, tabID, "running git add")
-	if _, err := h.runCommandAndCapture(ctx, runner, core.RunCommandRequest{
-		WorkingDir:  workingDir,
+	if _, err := h.runCommandAndCapture(ctx, userID, tabID, repo.runner, core.RunCommandRequest{
+		WorkingDir:  repo.workingDir,
 		Command:     "git add -A",
 		UseShell:    false,
-		SSHAuthSock: info.SSHAuthSock,
+		SSHAuthSock: repo.info.SSHAuthSock,
 	}); err != nil {
-		log.Warn("command git add failed", "err", err)
-		h.appendError(ctx, userID, tabID, err)
+		return "", err
+	}
+	h.appendStatus(ctx, userID, tabID, "committing changes")
+	return h.runCommandAndCapture(ctx, userID, tabID, repo.runner, core.RunCommandRequest{
+		WorkingDir:  repo.workingDir,
+		Command:     fmt.Sprintf("git commit -m %s", shellQuote(message)),
+		UseShell:    true,
+		SSHAuthSock: repo.info.SSHAuthSock,
+	})
+}
+
+// appendCommitOutput appends title followed by the output of git commit.
+func (h *Handler) appendCommitOutput(ctx context.Context, userID schema.UserID, tabID schema.TabID, title, output string) {
+	lines := []string{title}
+	if strings.TrimSpace(output) != "" {
+		lines = append(lines, strings.Split(strings.TrimRight(output, "\n"), "\n")...)
+	}
+	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
+		UserID: userID,
+		TabID:  tabID,
+		Lines:  lines,
+	})
+}
+
+const autoCommitUsage = "usage: /autocommit [on|off]"
+
+// autoCommitPrefix starts the message of every auto-commit.
+const autoCommitPrefix = "[auto] "
+
+func (h *Handler) handleAutoCommit(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	log := logx.WithUserTab(ctx, userID, tabID)
+	if len(cmd.Args) > 1 {
+		return errors.New(autoCommitUsage)
+	}
+	if len(cmd.Args) == 0 {
+		tab, err := h.lookupTab(ctx, userID, tabID)
+		if err != nil {
+			return err
+		}
+		if tab.AutoCommit {
+			h.appendLine(ctx, userID, tabID, "autocommit: on")
+		} else {
+			h.appendLine(ctx, userID, tabID, "autocommit: off (turn on with /autocommit on)")
+		}
+		return nil
+	}
+	arg := strings.ToLower(cmd.Args[0])
+	if arg != "on" && arg != "off" {
+		return errors.New(autoCommitUsage)
+	}
+	if err := checkAccountWrite(ctx); err != nil {
+		return err
+	}
+	resp, err := h.service.SetTabAutoCommit(ctx, schema.SetTabAutoCommitRequest{UserID: userID, TabID: tabID, Enabled: arg == "on"})
+	if err != nil {
+		log.Warn("command autocommit update failed", "err", err)
 		return err
 	}
+	if resp.Tab.AutoCommit {
+		h.appendLine(ctx, userID, tabID, "autocommit: on (successful runs that change the repo are committed)")
+	} else {
+		h.appendLine(ctx, userID, tabID, "autocommit: off")
+	}
+	log.Info("command autocommit updated", "enabled", resp.Tab.AutoCommit)
+	return nil
+}
 
-	h.appendStatus(ctx, userID, tabID, "committing changes")
-	output, err := h.runCommandAndCapture(ctx, runner, core.RunCommandRequest{
+// AutoCommit commits the changes a successful run left in the tab's repo,
+// like /git commit with a generated message prefixed [auto]. Runs that
+// changed nothing are skipped. Failures are logged and reported in the tab.
+func (h *Handler) AutoCommit(ctx context.Context, req core.AutoCommitRequest) {
+	userID, tabID := req.UserID, req.TabID
+	log := logx.WithUserTab(ctx, userID, tabID).With("run_id", req.RunID)
+	ctx = logx.ContextWithUserTabLogger(ctx, log, userID, tabID)
+	if h.runners == nil {
+		return
+	}
+	err := h.autoCommit(ctx, userID, tabID)
+	switch {
+	case err == nil:
+	case errors.Is(err, errCaptureStopped):
+		h.appendLine(ctx, userID, tabID, "auto-commit cancelled")
+	default:
+		log.Warn("command autocommit failed", "err", err)
+		h.appendLines(ctx, userID, tabID, schema.Line(schema.LineKindError, fmt.Sprintf("auto-commit failed: %v", err)))
+	}
+}
+
+func (h *Handler) autoCommit(ctx context.Context, userID schema.UserID, tabID schema.TabID) error {
+	log := pslog.Ctx(ctx)
+	tab, err := h.lookupTab(ctx, userID, tabID)
+	if err != nil {
+		return err
+	}
+	repo, err := h.repoRunner(ctx, userID, tab)
+	if err != nil {
+		return err
+	}
+	status, err := h.runCommandAndCapture(ctx, userID, tabID, repo.runner, core.RunCommandRequest{
+		WorkingDir:  repo.workingDir,
+		Command:     "git status --porcelain",
+		UseShell:    false,
+		SSHAuthSock: repo.info.SSHAuthSock,
+	})
+	if err != nil {
+		return fmt.Errorf("git status: %w", err)
+	}
+	if strings.TrimSpace(status) == "" {
+		log.Debug("command autocommit skipped", "reason", "no changes")
+		return nil
+	}
+	h.appendStatus(ctx, userID, tabID, "auto-commit: generating commit message")
+	message, err := h.generateCommitMessage(ctx, userID, tab, h.cfg.CommitModel)
+	if err != nil {
+		return err
+	}
+	message = autoCommitPrefix + message
+	output, err := h.gitCommit(ctx, userID, tabID, repo, message)
+	if err != nil {
+		return err
+	}
+	h.appendCommitOutput(ctx, userID, tabID, "auto-commit completed", output)
+	log.Info("command autocommit completed", "changes", len(strings.Split(strings.TrimSpace(status), "\n")))
+	return nil
+}
+
+// gitInitCommand creates the repository on the centaurx branch with an
+// empty first commit, so HEAD exists for the exec start summary and diffs.
+const gitInitCommand = "git init -q && (git switch -q -c centaurx 2>/dev/null || git checkout -q -b centaurx) && git commit -q --allow-empty -m 'Initial commit'"
+
+// gitInit initializes version control in workingDir unless it is already
+// inside a git work tree. The commit uses the git identity configured in the
+// runner.
+func (h *Handler) gitInit(ctx context.Context, userID schema.UserID, tabID schema.TabID, runner core.Runner, info core.RunnerInfo, workingDir string) error {
+	log := pslog.Ctx(ctx)
+	if _, err := h.runCommandAndCapture(ctx, userID, tabID, runner, core.RunCommandRequest{
 		WorkingDir:  workingDir,
-		Command:     fmt.Sprintf("git commit -m %s", shellQuote(message)),
+		Command:     "git rev-parse --is-inside-work-tree",
+		UseShell:    false,
+		SSHAuthSock: info.SSHAuthSock,
+	}); err == nil {
+		h.appendLine(ctx, userID, tabID, "already a git repository")
+		return nil
+	}
+
+	h.appendStatus(ctx, userID, tabID, "initializing git repository")
+	output, err := h.runCommandAndCapture(ctx, userID, tabID, runner, core.RunCommandRequest{
+		WorkingDir:  workingDir,
+		Command:     gitInitCommand,
 		UseShell:    true,
 		SSHAuthSock: info.SSHAuthSock,
 	})
 	if err != nil {
-		log.Warn("command git commit failed", "err", err)
-		h.appendError(ctx, userID, tabID, err)
-		return err
+		log.Warn("command git init failed", "err", err)
+		return h.appendGitError(ctx, userID, tabID, err)
 	}
 
-	lines := []string{"git commit completed"}
+	lines := []string{"git init completed: initial commit on branch centaurx"}
 	if strings.TrimSpace(output) != "" {
 		lines = append(lines, strings.Split(strings.TrimRight(output, "\n"), "\n")...)
 	}
@@ -956,7 +2813,50 @@ func (h *Handler) handleGit(ctx context.Context, userID schema.UserID, tabID sch
 		TabID:  tabID,
 		Lines:  lines,
 	})
-	log.Info("command git commit completed")
+	log.Info("command git init completed")
+	return nil
+}
+
+// gitignorePrompt asks for a .gitignore covering the untracked files the
+// exec start summary only counts.
+const gitignorePrompt = "Propose a .gitignore for this repo that leaves out build artifacts, dependencies and other generated files among the untracked files. Do not create or change any files; answer only with the contents of the .gitignore."
+
+// handleGitignore asks the commit model to propose a .gitignore. The
+// proposal is shown, not written, so it can be reviewed first.
+func (h *Handler) handleGitignore(ctx context.Context, userID schema.UserID, tabID schema.TabID, cmd Command) error {
+	if len(cmd.Args) != 1 || strings.ToLower(cmd.Args[0]) != "suggest" {
+		return fmt.Errorf("usage: /gitignore suggest")
+	}
+	if h.runners == nil {
+		return errors.New("runner not configured")
+	}
+	log := logx.WithUserTab(ctx, userID, tabID)
+	tab, err := h.lookupTab(ctx, userID, tabID)
+	if err != nil {
+		log.Warn("command gitignore lookup failed", "err", err)
+		h.appendError(ctx, userID, tabID, err)
+		return err
+	}
+	if tab.Status.Busy() {
+		log.Warn("command gitignore rejected", "err", schema.ErrTabBusy)
+		h.appendError(ctx, userID, tabID, schema.ErrTabBusy)
+		return schema.ErrTabBusy
+	}
+
+	h.appendStatus(ctx, userID, tabID, "asking for a .gitignore")
+	proposal, err := h.askModel(ctx, userID, tab, h.cfg.CommitModel, ".gitignore proposal", gitignorePrompt)
+	if err != nil {
+		log.Warn("command gitignore failed", "err", err)
+		return h.appendGitError(ctx, userID, tabID, err)
+	}
+	lines := []string{"suggested .gitignore:"}
+	lines = append(lines, strings.Split(proposal, "\n")...)
+	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{
+		UserID: userID,
+		TabID:  tabID,
+		Lines:  lines,
+	})
+	log.Info("command gitignore suggested")
 	return nil
 }
 
@@ -973,31 +2873,89 @@ func (h *Handler) lookupTab(ctx context.Context, userID schema.UserID, tabID sch
 	return schema.TabSnapshot{}, schema.ErrTabNotFound
 }
 
+// tabOwner returns the user whose runner and repo back tab. Tabs shared by
+// another user carry their owner.
+func tabOwner(userID schema.UserID, tab schema.TabSnapshot) schema.UserID {
+	if tab.Owner != "" {
+		return tab.Owner
+	}
+	return userID
+}
+
+// checkWriteAccess rejects commands that change a tab shared read-only.
+func checkWriteAccess(tab schema.TabSnapshot) error {
+	if tab.Owner != "" && tab.Access != schema.ShareAccessReadWrite {
+		return fmt.Errorf("%w: %s shared this tab read-only", schema.ErrTabAccessDenied, tab.Owner)
+	}
+	return nil
+}
+
+// checkAccountWrite rejects commands that change repos from sessions of
+// accounts the auth backend maps to read-only access.
+func checkAccountWrite(ctx context.Context) error {
+	if prefs := sessionprefs.FromContext(ctx); prefs != nil && prefs.Capabilities.ReadOnly {
+		return schema.ErrReadOnlyAccount
+	}
+	return nil
+}
+
+// appendGitError reports a failed git step. A step interrupted by /stop is
+// reported as cancelled rather than returned as an error.
+func (h *Handler) appendGitError(ctx context.Context, userID schema.UserID, tabID schema.TabID, err error) error {
+	if errors.Is(err, errCaptureStopped) {
+		h.appendLine(ctx, userID, tabID, errCaptureStopped.Error())
+		return nil
+	}
+	h.appendError(ctx, userID, tabID, err)
+	return err
+}
+
 func (h *Handler) generateCommitMessage(ctx context.Context, userID schema.UserID, tab schema.TabSnapshot, modelID schema.ModelID) (string, error) {
-	log := logx.WithUserTab(ctx, userID, tab.ID).With("model", modelID)
+	prompt := "Give me a commit message according to conventionalcommits for the uncommitted changes in this repo, answer only with a single line."
+	message, err := h.askModel(ctx, userID, tab, modelID, "commit message", prompt)
+	if err != nil {
+		return "", err
+	}
+	if idx := strings.IndexByte(message, '\n'); idx > -1 {
+		message = strings.TrimSpace(message[:idx])
+	}
+	return message, nil
+}
+
+// askModel runs prompt once in the tab's session with modelID and returns
+// the last agent message. purpose names the request in logs and errors.
+func (h *Handler) askModel(ctx context.Context, userID schema.UserID, tab schema.TabSnapshot, modelID schema.ModelID, purpose, prompt string) (string, error) {
+	log := logx.WithUserTab(ctx, userID, tab.ID).With("model", modelID, "purpose", purpose)
 	ctx = logx.ContextWithUserTabLogger(ctx, log, userID, tab.ID)
-	runnerResp, err := h.runners.RunnerFor(ctx, core.RunnerRequest{UserID: userID, TabID: tab.ID})
+	owner := tabOwner(userID, tab)
+	// These runs count against the owner's daily token budget like prompts.
+	meter, _ := h.service.(core.TokenMeter)
+	if meter != nil {
+		if err := meter.CheckTokenBudget(ctx, owner); err != nil {
+			return "", err
+		}
+	}
+	runnerResp, err := h.runners.RunnerFor(ctx, core.RunnerRequest{UserID: owner, TabID: tab.ID})
 	if err != nil {
-		log.Warn("command git message runner failed", "err", err)
+		log.Warn("command ask runner failed", "err", err)
 		return "", err
 	}
 	runner := runnerResp.Runner
 	info := runnerResp.Info
-	prompt := "Give me a commit message according to conventionalcommits for the uncommitted changes in this repo, answer only with a single line."
-	workingDir, err := core.RepoPath(h.cfg.RepoRoot, userID, tab.Repo.Name)
+	workingDir, err := core.RepoPath(h.cfg.RepoRoot, owner, tab.Repo.Name)
 	if err != nil {
-		log.Warn("command git message repo path failed", "err", err)
+		log.Warn("command ask repo path failed", "err", err)
 		return "", err
 	}
 	if info.RepoRoot != "" && h.cfg.RepoRoot != "" {
-		mapped, err := core.MapRepoPath(h.cfg.RepoRoot, info.RepoRoot, workingDir)
+		mapped, err := core.MapRepoPath(h.cfg.RepoRoot, info, workingDir)
 		if err != nil {
-			log.Warn("command git message repo map failed", "err", err)
+			log.Warn("command ask repo map failed", "err", err)
 			return "", err
 		}
 		workingDir = mapped
 	}
-	log = logx.WithRepo(log, core.RepoRefForUser(h.cfg.RepoRoot, userID, tab.Repo.Name))
+	log = logx.WithRepo(log, core.RepoRefForUser(h.cfg.RepoRoot, owner, tab.Repo.Name))
 	log = logx.WithSession(log, tab.SessionID)
 	ctx = logx.ContextWithUserTabLogger(ctx, log, userID, tab.ID)
 	if !h.cfg.DisableAuditLogging {
@@ -1016,18 +2974,27 @@ func (h *Handler) generateCommitMessage(ctx context.Context, userID schema.UserI
 		JSON:                 true,
 		SSHAuthSock:          info.SSHAuthSock,
 	}
-	handle, err := runner.Run(ctx, runReq)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	run, err := runner.Run(ctx, runReq)
 	if err != nil {
-		log.Warn("command git message start failed", "err", err)
+		log.Warn("command ask start failed", "err", err)
 		return "", err
 	}
-	stream := handle.Events()
+	handle := &stoppableCommand{CommandHandle: runCommandHandle{RunHandle: run}, done: make(chan struct{})}
+	defer h.trackCapture(ctx, userID, tab.ID, handle, cancel)()
+	stream := run.Events()
 	var message string
 	for {
 		event, err := stream.Next(ctx)
 		if err != nil {
+			if handle.stopped.Load() {
+				_ = handle.Close()
+				log.Info("command ask stopped")
+				return "", errCaptureStopped
+			}
 			if errors.Is(err, context.Canceled) {
-				log.Warn("command git message canceled", "err", err)
+				log.Warn("command ask canceled", "err", err)
 				return "", err
 			}
 			break
@@ -1035,65 +3002,41 @@ func (h *Handler) generateCommitMessage(ctx context.Context, userID schema.UserI
 		if event.Item != nil && event.Item.Type == schema.ItemAgentMessage && event.Item.Text != "" {
 			message = event.Item.Text
 		}
+		if event.Type == schema.EventTurnCompleted && event.Usage != nil && meter != nil {
+			meter.RecordTokens(ctx, owner, int64(event.Usage.InputTokens+event.Usage.OutputTokens))
+		}
 		if event.Type == schema.EventTurnFailed {
 			if event.Error != nil && event.Error.Message != "" {
-				log.Warn("command git message turn failed", "message", event.Error.Message)
+				log.Warn("command ask turn failed", "message", event.Error.Message)
 				return "", fmt.Errorf("codex turn failed: %s", event.Error.Message)
 			}
-			log.Warn("command git message turn failed")
+			log.Warn("command ask turn failed")
 			return "", fmt.Errorf("codex turn failed")
 		}
 		if event.Type == schema.EventError {
 			if event.Message != "" {
-				log.Warn("command git message error", "message", event.Message)
+				log.Warn("command ask error", "message", event.Message)
 				return "", fmt.Errorf("codex error: %s", event.Message)
 			}
-			log.Warn("command git message error")
+			log.Warn("command ask error")
 			return "", fmt.Errorf("codex error")
 		}
 	}
 	_, _ = handle.Wait(ctx)
 	_ = handle.Close()
+	if handle.stopped.Load() {
+		log.Info("command ask stopped")
+		return "", errCaptureStopped
+	}
 
 	message = strings.TrimSpace(message)
 	if message == "" {
-		return "", fmt.Errorf("no commit message produced")
-	}
-	if idx := strings.IndexByte(message, '\n'); idx > -1 {
-		message = strings.TrimSpace(message[:idx])
+		return "", fmt.Errorf("no %s produced", purpose)
 	}
-	log.Info("command git message generated")
+	log.Info("command ask answered")
 	return message, nil
 }
 
-func helpLines(models []schema.ModelID) []string {
-	modelList := strings.Join(formatModels(models), ", ")
-	return []string{
-		schema.WorkedForMarker + "Commands",
-		schema.HelpMarker + "**/new** `<repo|git-url>` - create or open a repo (git URLs clone over SSH)",
-		schema.HelpMarker + "**/listrepos** - list repos",
-		schema.HelpMarker + "**/rm** `<number_or_name>` - close a tab",
-		schema.HelpMarker + "**/close** - close current tab",
-		schema.HelpMarker + "**/quit**, **/exit**, **/logout** - exit session / log out",
-		schema.HelpMarker + "**/status** - show current session status",
-		schema.HelpMarker + "**/model** `<model> [reasoning]` - set model for current tab (available: " + modelList + "; reasoning: " + modelReasoningEffortUsage + ")",
-		schema.HelpMarker + "**/stop** or **/z** - stop running codex exec",
-		schema.HelpMarker + "**/renew** - start a fresh codex session for the current tab",
-		schema.HelpMarker + "**/chpasswd** - change your password",
-		schema.HelpMarker + "**/codexauth** - upload codex auth.json",
-		schema.HelpMarker + "**/git** `commit [message]` - commit changes",
-		schema.HelpMarker + "**/addloginpubkey** `<pubkey>` - add an SSH login public key",
-		schema.HelpMarker + "**/listloginpubkeys** - list SSH login public keys",
-		schema.HelpMarker + "**/rmloginpubkey** `<id>` - remove SSH login public key by id",
-		schema.HelpMarker + "**/pubkey** - show your git SSH public key",
-		schema.HelpMarker + "**/rotatesshkey** `[affirm]` - rotate your git SSH key (affirm skips prompt)",
-		schema.HelpMarker + "**/togglefullcommandoutput** - toggle full command output",
-		schema.HelpMarker + "**/theme** `<name>` - set UI theme (available: " + strings.Join(formatThemes(schema.AvailableThemes()), ", ") + ")",
-		schema.HelpMarker + "**/version** - show version information",
-		schema.HelpMarker + "**!** `<cmd>` - run a shell command in the repo",
-	}
-}
-
 func firstToken(value string) string {
 	fields := strings.Fields(value)
 	if len(fields) == 0 {
@@ -1102,7 +3045,10 @@ func firstToken(value string) string {
 	return fields[0]
 }
 
-func (h *Handler) runCommandAndCapture(ctx context.Context, runner core.Runner, req core.RunCommandRequest) (string, error) {
+// runCommandAndCapture runs req to completion and returns its output. The
+// command is tracked on the tab while it runs, so /stop interrupts it; the
+// capture then fails with errCaptureStopped.
+func (h *Handler) runCommandAndCapture(ctx context.Context, userID schema.UserID, tabID schema.TabID, runner core.Runner, req core.RunCommandRequest) (string, error) {
 	log := pslog.Ctx(ctx).With("command_len", len(req.Command), "shell", req.UseShell)
 	if name := firstToken(req.Command); name != "" {
 		log = log.With("command", name)
@@ -1111,12 +3057,16 @@ func (h *Handler) runCommandAndCapture(ctx context.Context, runner core.Runner,
 		log.Debug("audit command", "command_type", "runner", "command", req.Command, "workdir", req.WorkingDir)
 	}
 	log.Debug("command capture start")
-	handle, err := runner.RunCommand(ctx, req)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	base, err := runner.RunCommand(ctx, req)
 	if err != nil {
 		log.Warn("command capture failed", "err", err)
 		return "", err
 	}
+	handle := &stoppableCommand{CommandHandle: base, done: make(chan struct{})}
 	defer func() { _ = handle.Close() }()
+	defer h.trackCapture(ctx, userID, tabID, handle, cancel)()
 	stream := handle.Outputs()
 	lines := make([]string, 0, 16)
 	for {
@@ -1125,6 +3075,10 @@ func (h *Handler) runCommandAndCapture(ctx context.Context, runner core.Runner,
 			if errors.Is(err, io.EOF) {
 				break
 			}
+			if handle.stopped.Load() {
+				log.Info("command capture stopped")
+				return "", errCaptureStopped
+			}
 			log.Warn("command capture stream failed", "err", err)
 			return "", err
 		}
@@ -1135,6 +3089,10 @@ func (h *Handler) runCommandAndCapture(ctx context.Context, runner core.Runner,
 		lines = append(lines, line)
 	}
 	result, err := handle.Wait(ctx)
+	if handle.stopped.Load() {
+		log.Info("command capture stopped")
+		return strings.Join(lines, "\n"), errCaptureStopped
+	}
 	if err != nil {
 		log.Warn("command capture wait failed", "err", err)
 		return strings.Join(lines, "\n"), err
@@ -1147,107 +3105,129 @@ func (h *Handler) runCommandAndCapture(ctx context.Context, runner core.Runner,
 	return strings.Join(lines, "\n"), nil
 }
 
-func (h *Handler) lookupUsage(ctx context.Context, userID schema.UserID, tabID schema.TabID) (core.UsageInfo, bool, error) {
-	if info, ok, err := h.cachedUsage(userID); ok {
-		logx.WithUserTab(ctx, userID, tabID).Debug("usage cache hit", "err", err != nil, "chatgpt", info.ChatGPT)
-		return info, true, err
-	}
-	if h.runners == nil {
-		logx.WithUserTab(ctx, userID, tabID).Debug("usage lookup skipped", "reason", "runner unavailable")
-		return core.UsageInfo{}, false, nil
-	}
-	runnerResp, err := h.runners.RunnerFor(ctx, core.RunnerRequest{UserID: userID, TabID: tabID})
-	if err != nil {
-		logx.WithUserTab(ctx, userID, tabID).Warn("usage runner lookup failed", "err", err)
-		return core.UsageInfo{}, false, err
-	}
-	reader, ok := runnerResp.Runner.(core.UsageReader)
-	if !ok {
-		logx.WithUserTab(ctx, userID, tabID).Debug("usage reader missing")
-		return core.UsageInfo{}, false, nil
+// errCaptureStopped is returned by captures interrupted by /stop.
+var errCaptureStopped = errors.New("git operation cancelled")
+
+// trackCapture registers handle as a running command of the tab, so /stop
+// signals it and cancels its context. The returned func unregisters it.
+func (h *Handler) trackCapture(ctx context.Context, userID schema.UserID, tabID schema.TabID, handle *stoppableCommand, cancel context.CancelFunc) func() {
+	tracker, ok := h.service.(core.CommandTracker)
+	if !ok || tabID == "" {
+		return handle.finish
 	}
-	info, err := reader.Usage(ctx)
-	if h.usageTTL > 0 && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
-		h.storeUsage(userID, info, err)
+	tracker.RegisterCommand(ctx, userID, tabID, handle, cancel)
+	return func() {
+		handle.finish()
+		tracker.UnregisterCommand(userID, tabID, handle)
 	}
-	logx.WithUserTab(ctx, userID, tabID).Info("usage lookup completed", "err", err != nil, "chatgpt", info.ChatGPT)
-	return info, true, err
 }
 
-func (h *Handler) cachedUsage(userID schema.UserID) (core.UsageInfo, bool, error) {
-	if h.usageTTL <= 0 {
-		return core.UsageInfo{}, false, nil
-	}
-	h.usageMu.Lock()
-	defer h.usageMu.Unlock()
-	entry, ok := h.usageCache[userID]
-	if !ok {
-		return core.UsageInfo{}, false, nil
-	}
-	if h.now().Sub(entry.fetchedAt) > h.usageTTL {
-		delete(h.usageCache, userID)
-		return core.UsageInfo{}, false, nil
-	}
-	return entry.info, true, entry.err
+// stoppableCommand records whether a tracked capture was signaled and
+// reports Done once the capture has returned, so a stop does not wait out
+// the grace period after the capture gave up.
+type stoppableCommand struct {
+	core.CommandHandle
+	stopped  atomic.Bool
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
-func (h *Handler) storeUsage(userID schema.UserID, info core.UsageInfo, err error) {
-	h.usageMu.Lock()
-	defer h.usageMu.Unlock()
-	h.usageCache[userID] = usageCacheEntry{
-		fetchedAt: h.now(),
-		info:      info,
-		err:       err,
-	}
+func (c *stoppableCommand) Signal(ctx context.Context, sig core.ProcessSignal) error {
+	c.stopped.Store(true)
+	return c.CommandHandle.Signal(ctx, sig)
 }
 
-func (h *Handler) resolveStatusDir(ctx context.Context, userID schema.UserID, tabID schema.TabID, tab schema.TabSnapshot) string {
-	if h.runners != nil {
-		if resp, err := h.runners.RunnerFor(ctx, core.RunnerRequest{UserID: userID, TabID: tabID}); err == nil {
-			if strings.TrimSpace(h.cfg.RepoRoot) != "" && strings.TrimSpace(resp.Info.RepoRoot) != "" {
-				if repoPath, err := core.RepoPath(h.cfg.RepoRoot, userID, tab.Repo.Name); err == nil {
-					if mapped, err := core.MapRepoPath(h.cfg.RepoRoot, resp.Info.RepoRoot, repoPath); err == nil {
-						return mapped
-					}
-				}
-			}
-		}
-	}
-	if repoPath, err := core.RepoPath(h.cfg.RepoRoot, userID, tab.Repo.Name); err == nil {
-		return repoPath
-	}
-	if strings.TrimSpace(string(tab.Repo.Name)) != "" {
-		return string(tab.Repo.Name)
+func (c *stoppableCommand) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *stoppableCommand) finish() {
+	c.doneOnce.Do(func() { close(c.done) })
+}
+
+// runCommandHandle adapts a codex run to core.CommandHandle so it can be
+// tracked like a shell command. A run has no command output and no PTY.
+type runCommandHandle struct {
+	core.RunHandle
+}
+
+func (r runCommandHandle) Outputs() core.CommandStream {
+	return nil
+}
+
+func (r runCommandHandle) Resize(context.Context, int, int) error {
+	return nil
+}
+
+// recordActivity adds an entry to the user's activity feed. Failures are
+// only logged since the feed is informational.
+func (h *Handler) recordActivity(ctx context.Context, userID schema.UserID, kind schema.ActivityKind, detail string) {
+	if _, err := h.service.RecordActivity(ctx, schema.RecordActivityRequest{UserID: userID, Kind: kind, Detail: detail}); err != nil {
+		logx.WithUser(ctx, userID).Warn("command activity record failed", "activity", kind, "err", err)
 	}
-	return "unknown"
 }
 
 func (h *Handler) appendStatus(ctx context.Context, userID schema.UserID, tabID schema.TabID, message string) {
 	if strings.TrimSpace(message) == "" {
 		return
 	}
-	h.appendLine(ctx, userID, tabID, "status: "+message)
+	h.appendTransientLines(ctx, userID, tabID, schema.Line(schema.LineKindSystem, "status: "+message))
 }
 
 func (h *Handler) appendError(ctx context.Context, userID schema.UserID, tabID schema.TabID, err error) {
 	if err == nil {
 		return
 	}
-	h.appendLine(ctx, userID, tabID, fmt.Sprintf("error: %v", err))
+	h.appendLines(ctx, userID, tabID, schema.Line(schema.LineKindError, fmt.Sprintf("error: %v", err)))
 }
 
 func (h *Handler) appendLine(ctx context.Context, userID schema.UserID, tabID schema.TabID, line string) {
 	if strings.TrimSpace(line) == "" {
 		return
 	}
-	if ctx == nil {
+	h.appendLines(ctx, userID, tabID, schema.Line(schema.LineKindSystem, line))
+}
+
+// appendLines appends typed lines to the tab, or to the system buffer when no
+// tab is active or the tab is shared read-only.
+func (h *Handler) appendLines(ctx context.Context, userID schema.UserID, tabID schema.TabID, lines ...schema.BufferLine) {
+	if ctx == nil || len(lines) == 0 {
 		return
 	}
-	if tabID == "" {
-		_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Lines: []string{line}})
+	if tabID != "" {
+		_, err := h.service.AppendOutput(ctx, schema.AppendOutputRequest{UserID: userID, TabID: tabID, Structured: lines})
+		if !errors.Is(err, schema.ErrTabAccessDenied) {
+			return
+		}
+	}
+	_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Structured: lines})
+}
+
+// appendTransientLines is appendLines for status notices that are shown
+// live but not persisted.
+func (h *Handler) appendTransientLines(ctx context.Context, userID schema.UserID, tabID schema.TabID, lines ...schema.BufferLine) {
+	if ctx == nil || len(lines) == 0 {
+		return
+	}
+	if tabID != "" {
+		_, err := h.service.AppendOutput(ctx, schema.AppendOutputRequest{UserID: userID, TabID: tabID, Structured: lines, Transient: true})
+		if !errors.Is(err, schema.ErrTabAccessDenied) {
+			return
+		}
+	}
+	_, _ = h.service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Structured: lines, Transient: true})
+}
+
+// appendSavedOutput reports where a {save=<path>} command's output went.
+func (h *Handler) appendSavedOutput(ctx context.Context, userID schema.UserID, tabID schema.TabID, result core.RunResult) {
+	if result.SavedOutput == "" {
 		return
 	}
-	_, _ = h.service.AppendOutput(ctx, schema.AppendOutputRequest{UserID: userID, TabID: tabID, Lines: []string{line}})
+	noun := "lines"
+	if result.SavedLines == 1 {
+		noun = "line"
+	}
+	h.appendLine(ctx, userID, tabID, fmt.Sprintf("output saved to %s (%d %s)", result.SavedOutput, result.SavedLines, noun))
 }
 
 func formatCommandFinishedLine(duration time.Duration, exitCode int) string {
@@ -1272,28 +3252,11 @@ func shellQuote(value string) string {
 	return "'" + strings.ReplaceAll(value, "'", `'\'\''`) + "'"
 }
 
-func maxLabelWidth(labels []string) int {
-	max := 0
-	for _, label := range labels {
-		if label == "" {
-			continue
-		}
-		width := len(label) + 1
-		if width > max {
-			max = width
-		}
-	}
-	return max
-}
-
-func formatStatusLine(label, value string, labelWidth int) string {
-	if labelWidth <= 0 {
-		labelWidth = len(label) + 1
-	}
+func formatStatusLine(label, value string, labelWidth int) schema.BufferLine {
 	if strings.TrimSpace(value) == "" {
 		value = "unknown"
 	}
-	return fmt.Sprintf("%-*s %s", labelWidth, label+":", value)
+	return schema.Line(schema.LineKindField, schema.PadLabel(label, labelWidth)+"\t"+value)
 }
 
 func formatTokensUsed(tokens int) string {
@@ -1303,13 +3266,13 @@ func formatTokensUsed(tokens int) string {
 	return fmt.Sprintf("%dK", tokens/1000)
 }
 
-func formatUsageWindow(window *core.UsageWindow, err error, now time.Time) string {
-	if err != nil || window == nil {
+func formatUsageWindow(window *schema.UsageWindowStatus, errText string, now time.Time, clock timefmt.Clock) string {
+	if errText != "" || window == nil {
 		return "unavailable"
 	}
 	percent := percentRemaining(window.UsedPercent)
 	bar := formatUsageBar(percent, usageBarWidth)
-	reset := formatUsageReset(window.ResetAt, now)
+	reset := formatUsageReset(window.ResetAt, now, clock)
 	return fmt.Sprintf("%s %d%% / %s", bar, percent, reset)
 }
 
@@ -1327,51 +3290,12 @@ func formatUsageBar(percent int, width int) string {
 	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
 }
 
-func formatUsageReset(resetAt int64, now time.Time) string {
-	if resetAt <= 0 {
+func formatUsageReset(resetAt time.Time, now time.Time, clock timefmt.Clock) string {
+	if resetAt.IsZero() {
 		return "reset unknown"
 	}
-	resetTime := time.Unix(resetAt, 0).Local()
-	duration := formatStatusDuration(resetTime.Sub(now))
-	return fmt.Sprintf("reset in %s @%s", duration, resetTime.Format("15:04 2 Jan"))
-}
-
-func formatStatusDuration(d time.Duration) string {
-	if d < 0 {
-		d = 0
-	}
-	if d%time.Minute != 0 {
-		d = d.Truncate(time.Minute) + time.Minute
-	} else {
-		d = d.Truncate(time.Minute)
-	}
-	totalMinutes := int64(d / time.Minute)
-	if totalMinutes == 0 {
-		return "0m"
-	}
-	const minutesPerHour = 60
-	const minutesPerDay = 24 * minutesPerHour
-	days := totalMinutes / minutesPerDay
-	hours := (totalMinutes / minutesPerHour) % 24
-	minutes := totalMinutes % minutesPerHour
-	if days > 0 {
-		parts := make([]string, 0, 3)
-		parts = append(parts, fmt.Sprintf("%dd", days))
-		if hours > 0 {
-			parts = append(parts, fmt.Sprintf("%dh", hours))
-		}
-		if minutes > 0 {
-			parts = append(parts, fmt.Sprintf("%dm", minutes))
-		}
-		return strings.Join(parts, " ")
-	}
-	if hours > 0 {
-		if minutes > 0 {
-			return fmt.Sprintf("%dh%dm", hours, minutes)
-		}
-		return fmt.Sprintf("%dh", hours)
-	}
-	return fmt.Sprintf("%dm", minutes)
+	duration := timefmt.Duration(resetAt.Sub(now))
+	return fmt.Sprintf("reset in %s @%s", duration, clock.FormatFrom(resetAt, now))
 }
 
 func percentRemaining(used float64) int {
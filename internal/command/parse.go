@@ -1,43 +1,212 @@
 package command
 
 import (
+	"fmt"
 	"strings"
 )
 
 // Command represents a parsed slash command.
 type Command struct {
-	Name      string
-	Args      []string
+	Name string
+	// Args holds the positional arguments with quotes removed; flags and the
+	// "--" terminator are not included.
+	Args []string
+	// Flags maps the --flags given to their values, keyed by the lowercased
+	// flag name. Boolean flags map to "".
+	Flags     map[string]string
 	Raw       string
 	Remainder string
 }
 
-// Parse parses a line and returns a Command if it starts with "/".
-func Parse(input string) (Command, bool) {
+// HasFlag reports whether --name was given.
+func (c Command) HasFlag(name string) bool {
+	_, ok := c.Flags[name]
+	return ok
+}
+
+// Flag returns the value of --name and whether it was given.
+func (c Command) Flag(name string) (string, bool) {
+	value, ok := c.Flags[name]
+	return value, ok
+}
+
+// ParseError reports malformed command syntax. Pos is the 1-based byte
+// column in the input line.
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// ParseResult is the outcome of Parse.
+type ParseResult struct {
+	// Command is the parsed command; it is zero unless IsCommand is set.
+	Command Command
+	// IsCommand reports whether the line is a slash command (it starts
+	// with "/").
+	IsCommand bool
+}
+
+// Parse parses a line and reports whether it is a slash command.
+//
+// Arguments are separated by whitespace. Single or double quotes group
+// words into one argument, and a backslash escapes the next character inside
+// and outside quotes. An unquoted argument starting with "--" is a flag:
+// boolean flags declared by the command's spec take no value, other flags
+// take the next argument or the text after "=" (--depth 1, --depth=1). A
+// bare "--" ends flag parsing. Commands whose spec is Verbatim take free text
+// and are split on whitespace only.
+//
+// Syntax errors are returned as *ParseError; the Command still carries Name,
+// Raw and Remainder so callers can tell which command was meant.
+func Parse(input string) (ParseResult, error) {
 	trimmed := strings.TrimLeft(input, " \t")
 	if !strings.HasPrefix(trimmed, "/") {
-		return Command{}, false
+		return ParseResult{}, nil
 	}
-	raw := strings.TrimSpace(trimmed[1:])
+	offset := len(input) - len(trimmed) + 1
+	body := trimmed[1:]
+	raw := strings.TrimSpace(body)
+	offset += len(body) - len(strings.TrimLeft(body, " \t\n\r"))
 	if raw == "" {
-		return Command{Name: "", Raw: ""}, true
-	}
-	fields := strings.Fields(raw)
-	if len(fields) == 0 {
-		return Command{Name: "", Raw: raw}, true
+		return ParseResult{IsCommand: true}, nil
 	}
-	name := strings.ToLower(fields[0])
-	args := []string{}
-	if len(fields) > 1 {
-		args = fields[1:]
+	nameEnd := strings.IndexAny(raw, " \t\n\r")
+	if nameEnd < 0 {
+		nameEnd = len(raw)
 	}
-	remainder := remainderAfterTokens(raw, 1)
-	return Command{
-		Name:      name,
-		Args:      args,
+	cmd := Command{
+		Name:      strings.ToLower(raw[:nameEnd]),
+		Args:      []string{},
 		Raw:       raw,
-		Remainder: remainder,
-	}, true
+		Remainder: remainderAfterTokens(raw, 1),
+	}
+	spec, known := lookupCommandSpec(cmd.Name)
+	if known && spec.Verbatim {
+		cmd.Args = strings.Fields(raw)[1:]
+		return ParseResult{Command: cmd, IsCommand: true}, nil
+	}
+	tokens, err := tokenize(raw[nameEnd:], offset+nameEnd+1)
+	if err != nil {
+		cmd.Args = nil
+		return ParseResult{Command: cmd, IsCommand: true}, err
+	}
+	flagsDone := false
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if flagsDone || tok.quoted || !strings.HasPrefix(tok.text, "--") {
+			cmd.Args = append(cmd.Args, tok.text)
+			continue
+		}
+		if tok.text == "--" {
+			flagsDone = true
+			continue
+		}
+		name, value, hasValue := strings.Cut(tok.text[2:], "=")
+		name = strings.ToLower(name)
+		if name == "" {
+			cmd.Args = nil
+			return ParseResult{Command: cmd, IsCommand: true}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("invalid flag %q", tok.text)}
+		}
+		boolean := !known
+		if known {
+			flag, ok := spec.flag(name)
+			if !ok {
+				cmd.Args = nil
+				return ParseResult{Command: cmd, IsCommand: true}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unknown flag --%s for /%s", name, spec.Name)}
+			}
+			boolean = flag.Value == ""
+		}
+		switch {
+		case boolean && hasValue:
+			cmd.Args = nil
+			return ParseResult{Command: cmd, IsCommand: true}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("flag --%s takes no value", name)}
+		case boolean:
+			value = ""
+		case !hasValue:
+			if i+1 >= len(tokens) || (!tokens[i+1].quoted && strings.HasPrefix(tokens[i+1].text, "--")) {
+				cmd.Args = nil
+				return ParseResult{Command: cmd, IsCommand: true}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("flag --%s needs a value", name)}
+			}
+			i++
+			value = tokens[i].text
+		}
+		if cmd.Flags == nil {
+			cmd.Flags = make(map[string]string)
+		}
+		cmd.Flags[name] = value
+	}
+	return ParseResult{Command: cmd, IsCommand: true}, nil
+}
+
+// token is one argument of a command line.
+type token struct {
+	text string
+	// pos is the 1-based column of the token in the input line.
+	pos int
+	// quoted reports whether any part of the token was quoted or escaped,
+	// which makes "--" prefixes literal.
+	quoted bool
+}
+
+// tokenize splits s into arguments, resolving quotes and escapes. base is
+// the column of s in the input line, used for error positions.
+func tokenize(s string, base int) ([]token, error) {
+	var tokens []token
+	var cur strings.Builder
+	var tok token
+	inToken := false
+	var quote byte
+	quoteAt := 0
+	start := func(i int) {
+		if !inToken {
+			inToken = true
+			tok = token{pos: base + i}
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, &ParseError{Pos: base + i, Msg: "trailing backslash"}
+			}
+			start(i)
+			tok.quoted = true
+			i++
+			cur.WriteByte(s[i])
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteByte(c)
+		case c == '"' || c == '\'':
+			start(i)
+			tok.quoted = true
+			quote = c
+			quoteAt = i
+		case isSpace(c):
+			if inToken {
+				tok.text = cur.String()
+				tokens = append(tokens, tok)
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			start(i)
+			cur.WriteByte(c)
+		}
+	}
+	if quote != 0 {
+		return nil, &ParseError{Pos: base + quoteAt, Msg: fmt.Sprintf("unterminated %c quote", quote)}
+	}
+	if inToken {
+		tok.text = cur.String()
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
 }
 
 func remainderAfterTokens(raw string, count int) string {
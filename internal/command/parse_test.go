@@ -0,0 +1,154 @@
+package command
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantName  string
+		wantArgs  []string
+		wantFlags map[string]string
+		wantRem   string
+	}{
+		{name: "bare", input: "/status", wantName: "status", wantArgs: []string{}},
+		{name: "case folded", input: "  /NEW Demo", wantName: "new", wantArgs: []string{"Demo"}, wantRem: "Demo"},
+		{name: "whitespace", input: "/model \t gpt-5  high ", wantName: "model", wantArgs: []string{"gpt-5", "high"}, wantRem: "gpt-5  high"},
+		{name: "double quotes", input: `/new "my repo"`, wantName: "new", wantArgs: []string{"my repo"}, wantRem: `"my repo"`},
+		{name: "single quotes", input: `/new 'my repo' x`, wantName: "new", wantArgs: []string{"my repo", "x"}, wantRem: `'my repo' x`},
+		{name: "escaped quote in double quotes", input: `/new "say \"hi\""`, wantName: "new", wantArgs: []string{`say "hi"`}, wantRem: `"say \"hi\""`},
+		{name: "escaped quote in single quotes", input: `/new 'it\'s'`, wantName: "new", wantArgs: []string{"it's"}, wantRem: `'it\'s'`},
+		{name: "other quote kind is literal", input: `/new "it's"`, wantName: "new", wantArgs: []string{"it's"}, wantRem: `"it's"`},
+		{name: "escaped space", input: `/new my\ repo`, wantName: "new", wantArgs: []string{"my repo"}, wantRem: `my\ repo`},
+		{name: "adjacent quoting joins", input: `/new a"b c"'d'`, wantName: "new", wantArgs: []string{"ab cd"}, wantRem: `a"b c"'d'`},
+		{name: "empty quoted argument", input: `/new "" x`, wantName: "new", wantArgs: []string{"", "x"}, wantRem: `"" x`},
+		{name: "boolean flag", input: "/close --force", wantName: "close", wantArgs: []string{}, wantFlags: map[string]string{"force": ""}, wantRem: "--force"},
+		{name: "boolean flag does not take a value", input: "/archive --worktree src", wantName: "archive", wantArgs: []string{"src"}, wantFlags: map[string]string{"worktree": ""}, wantRem: "--worktree src"},
+		{name: "flags interleaved", input: "/rm --force demo", wantName: "rm", wantArgs: []string{"demo"}, wantFlags: map[string]string{"force": ""}, wantRem: "--force demo"},
+		{name: "flag after positional", input: "/rm demo --FORCE", wantName: "rm", wantArgs: []string{"demo"}, wantFlags: map[string]string{"force": ""}, wantRem: "demo --FORCE"},
+		{name: "double dash ends flags", input: "/rm -- --force", wantName: "rm", wantArgs: []string{"--force"}, wantRem: "-- --force"},
+		{name: "quoted flag is positional", input: `/rm "--force"`, wantName: "rm", wantArgs: []string{"--force"}, wantRem: `"--force"`},
+		{name: "single dash is positional", input: "/events -1", wantName: "events", wantArgs: []string{"-1"}, wantRem: "-1"},
+		{name: "verbatim keeps quotes", input: `/git commit "it's done" --amend`, wantName: "git", wantArgs: []string{"commit", `"it's`, `done"`, "--amend"}, wantRem: `commit "it's done" --amend`},
+		{name: "unknown command flags are boolean", input: "/nope --x y", wantName: "nope", wantArgs: []string{"y"}, wantFlags: map[string]string{"x": ""}, wantRem: "--x y"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Parse(tc.input)
+			if !res.IsCommand || err != nil {
+				t.Fatalf("Parse(%q) = ok %v, err %v", tc.input, res.IsCommand, err)
+			}
+			cmd := res.Command
+			if cmd.Name != tc.wantName {
+				t.Fatalf("name = %q, want %q", cmd.Name, tc.wantName)
+			}
+			if !slices.Equal(cmd.Args, tc.wantArgs) || (cmd.Args == nil) != (tc.wantArgs == nil) {
+				t.Fatalf("args = %q, want %q", cmd.Args, tc.wantArgs)
+			}
+			if !maps.Equal(cmd.Flags, tc.wantFlags) {
+				t.Fatalf("flags = %v, want %v", cmd.Flags, tc.wantFlags)
+			}
+			if cmd.Remainder != tc.wantRem {
+				t.Fatalf("remainder = %q, want %q", cmd.Remainder, tc.wantRem)
+			}
+		})
+	}
+}
+
+func TestParseValueFlags(t *testing.T) {
+	saved := commandSpecs
+	t.Cleanup(func() { commandSpecs = saved })
+	commandSpecs = append(slices.Clone(saved), CommandSpec{
+		Name:  "clonetest",
+		Flags: []FlagSpec{{Name: "depth", Value: "n"}, {Name: "isolated"}},
+	})
+	tests := []struct {
+		input     string
+		wantArgs  []string
+		wantFlags map[string]string
+	}{
+		{"/clonetest --depth 1 repo", []string{"repo"}, map[string]string{"depth": "1"}},
+		{"/clonetest repo --depth=1", []string{"repo"}, map[string]string{"depth": "1"}},
+		{"/clonetest --depth= repo", []string{"repo"}, map[string]string{"depth": ""}},
+		{`/clonetest --depth "1 2" --isolated repo`, []string{"repo"}, map[string]string{"depth": "1 2", "isolated": ""}},
+		{"/clonetest --depth -1", []string{}, map[string]string{"depth": "-1"}},
+		{"/clonetest --depth 1 --depth 2", []string{}, map[string]string{"depth": "2"}},
+		{`/clonetest --depth "--5"`, []string{}, map[string]string{"depth": "--5"}},
+		{"/clonetest a -- --depth 1", []string{"a", "--depth", "1"}, nil},
+	}
+	for _, tc := range tests {
+		res, err := Parse(tc.input)
+		if !res.IsCommand || err != nil {
+			t.Fatalf("Parse(%q) = ok %v, err %v", tc.input, res.IsCommand, err)
+		}
+		cmd := res.Command
+		if !slices.Equal(cmd.Args, tc.wantArgs) || !maps.Equal(cmd.Flags, tc.wantFlags) {
+			t.Fatalf("Parse(%q) = args %q flags %v, want %q %v", tc.input, cmd.Args, cmd.Flags, tc.wantArgs, tc.wantFlags)
+		}
+	}
+	res, _ := Parse("/clonetest --depth 3")
+	cmd := res.Command
+	if value, ok := cmd.Flag("depth"); !ok || value != "3" || cmd.HasFlag("isolated") {
+		t.Fatalf("unexpected flag lookup on %+v", cmd)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	saved := commandSpecs
+	t.Cleanup(func() { commandSpecs = saved })
+	commandSpecs = append(slices.Clone(saved), CommandSpec{
+		Name:  "clonetest",
+		Flags: []FlagSpec{{Name: "depth", Value: "n"}},
+	})
+	tests := []struct {
+		input   string
+		wantPos int
+		wantMsg string
+	}{
+		{`/new "my repo`, 6, `unterminated " quote`},
+		{`  /new ok 'x`, 11, `unterminated ' quote`},
+		{`/new  x\`, 8, "trailing backslash"},
+		{"/rm demo --forse", 10, "unknown flag --forse for /rm"},
+		{"/close --force=yes", 8, "flag --force takes no value"},
+		{"/clonetest --depth", 12, "flag --depth needs a value"},
+		{"/clonetest --depth --other", 12, "flag --depth needs a value"},
+		{"/clonetest --=1", 12, `invalid flag "--=1"`},
+	}
+	for _, tc := range tests {
+		res, err := Parse(tc.input)
+		if !res.IsCommand {
+			t.Fatalf("Parse(%q) not recognised as a command", tc.input)
+		}
+		cmd := res.Command
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("Parse(%q) err = %v, want ParseError", tc.input, err)
+		}
+		if perr.Pos != tc.wantPos || perr.Msg != tc.wantMsg {
+			t.Fatalf("Parse(%q) = %q at %d, want %q at %d", tc.input, perr.Msg, perr.Pos, tc.wantMsg, tc.wantPos)
+		}
+		if cmd.Name == "" || cmd.Args != nil {
+			t.Fatalf("Parse(%q) should keep the name and drop args, got %+v", tc.input, cmd)
+		}
+	}
+	if _, err := Parse(`/new "x`); err == nil || err.Error() != `unterminated " quote at position 6` {
+		t.Fatalf("unexpected error text %v", err)
+	}
+}
+
+func TestParseNotCommand(t *testing.T) {
+	for _, input := range []string{"", "hello /new", "!ls"} {
+		if res, err := Parse(input); res.IsCommand || err != nil {
+			t.Fatalf("Parse(%q) = ok %v, err %v; want not a command", input, res.IsCommand, err)
+		}
+	}
+	res, err := Parse("/  ")
+	if !res.IsCommand || err != nil || res.Command.Name != "" {
+		t.Fatalf("expected empty command, got %+v err %v", res, err)
+	}
+}
@@ -0,0 +1,101 @@
+package egress
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ProxyAddrEnv names the environment variable holding the loopback address
+// of the bridge inside a runner container.
+const ProxyAddrEnv = "CENTAURX_EGRESS_PROXY"
+
+// ProxyEnv returns the environment pointing HTTP clients and Connect at the
+// bridge listening on addr.
+func ProxyEnv(addr string) map[string]string {
+	proxyURL := "http://" + addr
+	noProxy := "localhost,127.0.0.1,::1"
+	return map[string]string{
+		ProxyAddrEnv:  addr,
+		"HTTP_PROXY":  proxyURL,
+		"HTTPS_PROXY": proxyURL,
+		"ALL_PROXY":   proxyURL,
+		"NO_PROXY":    noProxy,
+		"http_proxy":  proxyURL,
+		"https_proxy": proxyURL,
+		"all_proxy":   proxyURL,
+		"no_proxy":    noProxy,
+	}
+}
+
+// Bridge relays every connection accepted on listener to the proxy socket
+// at socketPath until ctx is done, so processes in a container without a
+// network reach the proxy over loopback.
+func Bridge(ctx context.Context, listener net.Listener, socketPath string) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			var dialer net.Dialer
+			upstream, err := dialer.DialContext(ctx, "unix", socketPath)
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+			relay(conn, conn, upstream)
+		}()
+	}
+}
+
+// Connect opens a tunnel to dest through the proxy at proxyAddr and relays
+// it to in and out, as an ssh ProxyCommand does. A refused destination
+// returns the proxy's message.
+func Connect(ctx context.Context, proxyAddr, dest string, in io.Reader, out io.Writer) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return fmt.Errorf("egress proxy %s: %w", proxyAddr, err)
+	}
+	defer func() { _ = conn.Close() }()
+	req, err := http.NewRequestWithContext(ctx, http.MethodConnect, "http://"+dest, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = dest
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		_ = res.Body.Close()
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("egress proxy: %s", res.Status)
+	}
+	go func() {
+		_, _ = io.Copy(conn, in)
+		closeWrite(conn)
+	}()
+	_, err = io.Copy(out, reader)
+	return err
+}
@@ -0,0 +1,10 @@
+// Package egress enforces the outbound network allowlist of runner
+// containers.
+//
+// A container with an allowlist runs without a network of its own. The
+// server runs a Proxy per container on a unix socket in the runner socket
+// dir; inside the container the runner relays a loopback port to that socket
+// (Bridge) and points the proxy environment variables at it. The Proxy admits
+// only destinations in its Policy and reports the ones it refuses, so they
+// can be shown in the tab buffer.
+package egress
@@ -0,0 +1,121 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ErrDenied is returned for destinations outside the policy.
+var ErrDenied = errors.New("denied by policy")
+
+// Resolver looks up host addresses; *net.Resolver satisfies it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Policy is an allowlist of host:port and CIDR entries.
+type Policy struct {
+	hosts map[string]struct{}
+	nets  []netip.Prefix
+}
+
+// ParsePolicy parses allowlist entries. "host:port" admits that host and
+// port; a CIDR or a bare IP address admits every port of those addresses.
+func ParsePolicy(entries []string) (*Policy, error) {
+	p := &Policy{hosts: make(map[string]struct{})}
+	for _, raw := range entries {
+		entry := strings.TrimSpace(raw)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			p.nets = append(p.nets, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			p.nets = append(p.nets, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil || normalizeHost(host) == "" {
+			return nil, fmt.Errorf("allowed host %q: want host:port or CIDR", raw)
+		}
+		if !validPort(port) {
+			return nil, fmt.Errorf("allowed host %q: invalid port", raw)
+		}
+		p.hosts[net.JoinHostPort(normalizeHost(host), port)] = struct{}{}
+	}
+	return p, nil
+}
+
+// Resolve returns the address to dial for hostport, or ErrDenied when the
+// policy does not admit it. Host names outside the host:port entries are
+// resolved and admitted through the first address inside a CIDR entry, which
+// is then the address dialed; names that do not resolve are denied.
+func (p *Policy) Resolve(ctx context.Context, resolver Resolver, hostport string) (string, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", err
+	}
+	if !validPort(port) {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	host = normalizeHost(host)
+	if _, ok := p.hosts[net.JoinHostPort(host, port)]; ok {
+		return net.JoinHostPort(host, port), nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if p.containsAddr(addr) {
+			return net.JoinHostPort(addr.Unmap().String(), port), nil
+		}
+		return "", ErrDenied
+	}
+	if len(p.nets) == 0 {
+		return "", ErrDenied
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		// A name that does not resolve cannot be inside a CIDR entry.
+		return "", ErrDenied
+	}
+	for _, addr := range addrs {
+		if p.containsAddr(addr) {
+			return net.JoinHostPort(addr.Unmap().String(), port), nil
+		}
+	}
+	return "", ErrDenied
+}
+
+func (p *Policy) containsAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.nets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases host and strips a trailing dot; IP addresses are
+// returned in canonical form.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
@@ -0,0 +1,73 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+type fakeResolver map[string][]netip.Addr
+
+func (f fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := f[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func TestParsePolicyRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"git.internal", "git.internal:0", "git.internal:https", ":22", "10.0.0.0/33"} {
+		if _, err := ParsePolicy([]string{entry}); err == nil {
+			t.Fatalf("expected %q to be rejected", entry)
+		}
+	}
+}
+
+func TestPolicyResolve(t *testing.T) {
+	policy, err := ParsePolicy([]string{"Git.Internal:22", "api.openai.com:443", "10.1.0.0/16", "192.0.2.7", " "})
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	resolver := fakeResolver{
+		"build.internal": {netip.MustParseAddr("172.16.0.1"), netip.MustParseAddr("10.1.2.3")},
+		"example.com":    {netip.MustParseAddr("93.184.216.34")},
+	}
+	tests := []struct {
+		dest string
+		want string
+	}{
+		{dest: "git.internal.:22", want: "git.internal:22"},
+		{dest: "api.openai.com:443", want: "api.openai.com:443"},
+		{dest: "10.1.9.9:8080", want: "10.1.9.9:8080"},
+		{dest: "[::ffff:192.0.2.7]:443", want: "192.0.2.7:443"},
+		{dest: "build.internal:80", want: "10.1.2.3:80"},
+		{dest: "git.internal:443"},
+		{dest: "api.openai.com:80"},
+		{dest: "10.2.0.1:22"},
+		{dest: "example.com:443"},
+	}
+	for _, tc := range tests {
+		got, err := policy.Resolve(context.Background(), resolver, tc.dest)
+		if tc.want == "" {
+			if !errors.Is(err, ErrDenied) {
+				t.Fatalf("Resolve(%q) = %q, %v; want denied", tc.dest, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("Resolve(%q) = %q, %v; want %q", tc.dest, got, err, tc.want)
+		}
+	}
+}
+
+func TestPolicyResolveWithoutCIDRSkipsLookup(t *testing.T) {
+	policy, err := ParsePolicy([]string{"git.internal:22"})
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	if _, err := policy.Resolve(context.Background(), fakeResolver{}, "example.com:443"); !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected denied without a lookup, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"pkt.systems/pslog"
+)
+
+// Proxy is an HTTP forward proxy admitting only the destinations in its
+// Policy. It serves CONNECT tunnels and plain http:// requests.
+type Proxy struct {
+	Policy *Policy
+	// Denied is called with the destination of every refused request.
+	Denied func(dest string)
+	// Resolver defaults to net.DefaultResolver.
+	Resolver Resolver
+	// Dial defaults to a net.Dialer with a 30 second timeout.
+	Dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+	Logger pslog.Logger
+}
+
+// DeniedMessage is the text reported for a refused destination.
+func DeniedMessage(dest string) string {
+	return fmt.Sprintf("network access to %s denied by policy", dest)
+}
+
+// ListenUnix listens on a unix socket at path, replacing a stale socket, and
+// restricts it to the owner.
+func ListenUnix(path string) (net.Listener, error) {
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve serves the proxy on listener until ctx is done.
+func (p *Proxy) Serve(ctx context.Context, listener net.Listener) error {
+	srv := &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+	p.serveForward(w, r)
+}
+
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	dest := r.Host
+	if _, _, err := net.SplitHostPort(dest); err != nil {
+		http.Error(w, "CONNECT needs host:port", http.StatusBadRequest)
+		return
+	}
+	upstream, err := p.dial(r.Context(), dest)
+	if err != nil {
+		p.fail(w, dest, err)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		_ = upstream.Close()
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		return
+	}
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		_ = client.Close()
+		_ = upstream.Close()
+		return
+	}
+	p.logger().Debug("egress tunnel open", "dest", dest)
+	relay(client, buf.Reader, upstream)
+}
+
+func (p *Proxy) serveForward(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, "only CONNECT and absolute http:// requests are proxied", http.StatusBadRequest)
+		return
+	}
+	dest := r.URL.Host
+	if _, _, err := net.SplitHostPort(dest); err != nil {
+		dest = net.JoinHostPort(dest, "80")
+	}
+	addr, err := p.Policy.Resolve(r.Context(), p.Resolver, dest)
+	if err != nil {
+		p.fail(w, dest, err)
+		return
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return p.dialer()(ctx, network, addr)
+		},
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, header := range []string{"Proxy-Connection", "Proxy-Authorization", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(header)
+	}
+	res, err := transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+	for key, values := range res.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
+
+func (p *Proxy) dial(ctx context.Context, dest string) (net.Conn, error) {
+	addr, err := p.Policy.Resolve(ctx, p.Resolver, dest)
+	if err != nil {
+		return nil, err
+	}
+	return p.dialer()(ctx, "tcp", addr)
+}
+
+func (p *Proxy) fail(w http.ResponseWriter, dest string, err error) {
+	if errors.Is(err, ErrDenied) {
+		p.logger().Info("egress denied", "dest", dest)
+		if p.Denied != nil {
+			p.Denied(dest)
+		}
+		http.Error(w, DeniedMessage(dest), http.StatusForbidden)
+		return
+	}
+	p.logger().Debug("egress dial failed", "dest", dest, "err", err)
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+func (p *Proxy) dialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if p.Dial != nil {
+		return p.Dial
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return dialer.DialContext
+}
+
+func (p *Proxy) logger() pslog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return pslog.Ctx(context.Background())
+}
+
+// relay copies between a and b until both directions are done. pending
+// holds bytes already read from a.
+func relay(a net.Conn, pending io.Reader, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(b, pending)
+		closeWrite(b)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(a, b)
+		closeWrite(a)
+	}()
+	wg.Wait()
+	_ = a.Close()
+	_ = b.Close()
+}
+
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,143 @@
+package egress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type deniedRecorder struct {
+	mu    sync.Mutex
+	dests []string
+}
+
+func (d *deniedRecorder) record(dest string) {
+	d.mu.Lock()
+	d.dests = append(d.dests, dest)
+	d.mu.Unlock()
+}
+
+func (d *deniedRecorder) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dests...)
+}
+
+// startEchoServer accepts TCP connections and echoes what it reads.
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startProxyBridge serves proxy on a unix socket and bridges a loopback port
+// to it, as the server and the runner do.
+func startProxyBridge(t *testing.T, proxy *Proxy) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	socketPath := filepath.Join(t.TempDir(), "egress.sock")
+	listener, err := ListenUnix(socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	go func() { _ = proxy.Serve(ctx, listener) }()
+	bridge, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen bridge: %v", err)
+	}
+	go func() { _ = Bridge(ctx, bridge, socketPath) }()
+	return bridge.Addr().String()
+}
+
+func TestConnectThroughBridge(t *testing.T) {
+	echo := startEchoServer(t)
+	policy, err := ParsePolicy([]string{echo})
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	denied := &deniedRecorder{}
+	addr := startProxyBridge(t, &Proxy{Policy: policy, Denied: denied.record})
+
+	var out bytes.Buffer
+	if err := Connect(context.Background(), addr, echo, strings.NewReader("ping"), &out); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if out.String() != "ping" {
+		t.Fatalf("expected echoed payload, got %q", out.String())
+	}
+
+	err = Connect(context.Background(), addr, "203.0.113.5:22", strings.NewReader(""), io.Discard)
+	if err == nil || err.Error() != "network access to 203.0.113.5:22 denied by policy" {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	if got := denied.list(); len(got) != 1 || got[0] != "203.0.113.5:22" {
+		t.Fatalf("expected denied destination reported, got %v", got)
+	}
+}
+
+func TestProxyForwardsPlainHTTP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Connection") != "" {
+			t.Errorf("hop-by-hop header forwarded")
+		}
+		_, _ = io.WriteString(w, "hello "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+	policy, err := ParsePolicy([]string{upstreamURL.Host})
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	denied := &deniedRecorder{}
+	addr := startProxyBridge(t, &Proxy{Policy: policy, Denied: denied.record})
+	proxyURL, _ := url.Parse("http://" + addr)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	res, err := client.Get(upstream.URL + "/repo")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != "hello /repo" {
+		t.Fatalf("unexpected response %d %q", res.StatusCode, body)
+	}
+
+	res, err = client.Get("http://blocked.example/")
+	if err != nil {
+		t.Fatalf("get blocked: %v", err)
+	}
+	body, _ = io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusForbidden || strings.TrimSpace(string(body)) != "network access to blocked.example:80 denied by policy" {
+		t.Fatalf("unexpected blocked response %d %q", res.StatusCode, body)
+	}
+	if got := denied.list(); len(got) != 1 || got[0] != "blocked.example:80" {
+		t.Fatalf("expected denied destination reported, got %v", got)
+	}
+}
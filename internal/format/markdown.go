@@ -0,0 +1,93 @@
+package format
+
+import (
+	"fmt"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// MarkdownRenderer formats events as markdown for clients that render it
+// themselves. Command output is fenced, file changes and todo lists are
+// markdown lists, and reasoning is shown once it completes.
+type MarkdownRenderer struct {
+	plain PlainRenderer
+}
+
+// NewMarkdownRenderer returns a markdown renderer.
+func NewMarkdownRenderer() *MarkdownRenderer {
+	return &MarkdownRenderer{}
+}
+
+// FormatEvent converts an ExecEvent into marker-prefixed markdown lines.
+// Events without items render as they do in plain text.
+func (m *MarkdownRenderer) FormatEvent(event schema.ExecEvent) ([]string, error) {
+	switch event.Type {
+	case schema.EventItemStarted, schema.EventItemUpdated, schema.EventItemCompleted:
+		return m.formatItem(event.Type, event.Item), nil
+	default:
+		return m.plain.FormatEvent(event)
+	}
+}
+
+func (m *MarkdownRenderer) formatItem(eventType schema.EventType, item *schema.ItemEvent) []string {
+	if item == nil {
+		return nil
+	}
+	switch item.Type {
+	case schema.ItemReasoning:
+		if eventType != schema.EventItemCompleted {
+			return nil
+		}
+		return markLines(schema.ReasoningMarker, splitLines(item.Text))
+	case schema.ItemCommandExecution:
+		return markLines(schema.CommandMarker, markdownCommand(item, eventType))
+	case schema.ItemFileChange:
+		if len(item.Changes) == 0 {
+			return []string{"file change"}
+		}
+		lines := []string{"file changes:"}
+		for _, change := range item.Changes {
+			label := change.Kind
+			if label == "" {
+				label = "update"
+			}
+			lines = append(lines, fmt.Sprintf("- %s `%s`", label, change.Path))
+		}
+		return lines
+	case schema.ItemWebSearch:
+		if item.Query == "" {
+			return []string{"web search executed"}
+		}
+		return []string{fmt.Sprintf("web search: `%s`", item.Query)}
+	case schema.ItemTodoList:
+		if len(item.Items) == 0 {
+			return []string{"todo list updated"}
+		}
+		lines := []string{"todo list:"}
+		for _, entry := range item.Items {
+			box := "- [ ]"
+			if entry.Completed {
+				box = "- [x]"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s", box, entry.Text))
+		}
+		return lines
+	default:
+		return m.plain.formatItem(eventType, item)
+	}
+}
+
+// markdownCommand fences the command and its output as a console block.
+func markdownCommand(item *schema.ItemEvent, eventType schema.EventType) []string {
+	body := formatCommand(item, schema.EventItemStarted)
+	var lines []string
+	if len(body) > 0 {
+		lines = append(lines, "```console")
+		lines = append(lines, body...)
+		lines = append(lines, "```")
+	}
+	if eventType == schema.EventItemCompleted && item.ExitCode != nil {
+		lines = append(lines, fmt.Sprintf("exit code: `%d`", *item.ExitCode))
+	}
+	return lines
+}
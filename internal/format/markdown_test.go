@@ -0,0 +1,60 @@
+package format
+
+import (
+	"slices"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestMarkdownRendererItems(t *testing.T) {
+	renderer := NewMarkdownRenderer()
+	for _, tc := range []struct {
+		name  string
+		event schema.ExecEvent
+		want  []string
+	}{
+		{
+			name:  "reasoning in progress",
+			event: schema.ExecEvent{Type: schema.EventItemStarted, Item: &schema.ItemEvent{Type: schema.ItemReasoning}},
+		},
+		{
+			name:  "file changes",
+			event: schema.ExecEvent{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{Type: schema.ItemFileChange, Changes: []schema.FileChange{{Path: "main.go", Kind: "add"}, {Path: "go.mod"}}}},
+			want:  []string{"file changes:", "- add `main.go`", "- update `go.mod`"},
+		},
+		{
+			name:  "todo list",
+			event: schema.ExecEvent{Type: schema.EventItemUpdated, Item: &schema.ItemEvent{Type: schema.ItemTodoList, Items: []schema.TodoItem{{Text: "plan", Completed: true}, {Text: "ship"}}}},
+			want:  []string{"todo list:", "- [x] plan", "- [ ] ship"},
+		},
+		{
+			name:  "command started",
+			event: schema.ExecEvent{Type: schema.EventItemStarted, Item: &schema.ItemEvent{Type: schema.ItemCommandExecution, Command: "go test"}},
+			want:  []string{schema.CommandMarker + "```console", schema.CommandMarker + "$ go test", schema.CommandMarker + "```"},
+		},
+		{
+			name:  "turn failed",
+			event: schema.ExecEvent{Type: schema.EventTurnFailed, Error: &schema.ErrorEvent{Message: "boom"}},
+			want:  []string{"turn failed: boom"},
+		},
+	} {
+		got, err := renderer.FormatEvent(tc.event)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRawRendererKeepsEveryEvent(t *testing.T) {
+	got, err := NewRawRenderer().FormatEvent(schema.ExecEvent{Type: schema.EventThreadStarted, ThreadID: "t1"})
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if want := []string{`{"type":"thread.started","thread_id":"t1"}`}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"pkt.systems/centaurx/schema"
 )
 
+// ReasoningPlaceholder is rendered for reasoning items that have not completed yet.
+const ReasoningPlaceholder = "thinking…"
+
 // PlainRenderer formats events as plain text lines.
 type PlainRenderer struct{}
 
@@ -47,6 +50,9 @@ func (p *PlainRenderer) formatItem(eventType schema.EventType, item *schema.Item
 	case schema.ItemAgentMessage:
 		return markLines(schema.AgentMarker, splitLines(item.Text))
 	case schema.ItemReasoning:
+		if eventType != schema.EventItemCompleted {
+			return []string{schema.ReasoningMarker + ReasoningPlaceholder}
+		}
 		if item.Text == "" {
 			return nil
 		}
@@ -0,0 +1,25 @@
+package format
+
+import (
+	"encoding/json"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// RawRenderer formats every event, including those the other renderers
+// skip, as one line of JSON.
+type RawRenderer struct{}
+
+// NewRawRenderer returns a raw JSON renderer.
+func NewRawRenderer() *RawRenderer {
+	return &RawRenderer{}
+}
+
+// FormatEvent returns the event as a single JSON line.
+func (r *RawRenderer) FormatEvent(event schema.ExecEvent) ([]string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(data)}, nil
+}
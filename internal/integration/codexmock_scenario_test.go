@@ -0,0 +1,134 @@
+package integration_test
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/codex"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestCodexMockScenarios(t *testing.T) {
+	requireLong(t)
+	ensureGitAvailable(t)
+
+	bin := buildCodexMock(t)
+	tests := []struct {
+		scenario string
+		prompt   string
+		want     []string
+		wantNot  []string
+	}{
+		{
+			scenario: "happy_path.yaml",
+			prompt:   "hello",
+			want:     []string{schema.FinalAgentMarker + "Happy path answer.", "Worked for"},
+			wantNot:  []string{"error:"},
+		},
+		{
+			scenario: "happy_path.yaml",
+			prompt:   "please list files",
+			want:     []string{"$ bash -lc ls", "README.md", schema.FinalAgentMarker + "Listed README.md and main.go."},
+		},
+		{
+			scenario: "turn_failed.yaml",
+			prompt:   "hello",
+			want:     []string{"turn failed: stream disconnected before completion", "error: run exited with code 1"},
+		},
+		{
+			scenario: "auth_error.yaml",
+			prompt:   "hello",
+			want:     []string{"error: unexpected status 401 Unauthorized", "error: run exited with code 1"},
+		},
+		{
+			scenario: "slow_stream.yaml",
+			prompt:   "hello",
+			want:     []string{schema.FinalAgentMarker + "Slow answer.", "Worked for"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(strings.TrimSuffix(tc.scenario, ".yaml")+"/"+tc.prompt, func(t *testing.T) {
+			scenario, err := filepath.Abs(filepath.Join("testdata", "codexmock", tc.scenario))
+			if err != nil {
+				t.Fatal(err)
+			}
+			runner, err := codex.NewRunner(codex.Config{
+				BinaryPath: bin,
+				ExtraArgs:  []string{"--scenario", scenario, "--delay-ms", "0"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := newTestServerWithRunner(t, runner)
+			ctx := context.Background()
+			user := schema.UserID(ts.user)
+			tabResp, err := ts.service.CreateTab(ctx, schema.CreateTabRequest{UserID: user, RepoName: "demo", CreateRepo: true})
+			if err != nil {
+				t.Fatalf("create tab: %v", err)
+			}
+			tabID := tabResp.Tab.ID
+			if _, err := ts.service.SendPrompt(ctx, schema.SendPromptRequest{UserID: user, TabID: tabID, Prompt: tc.prompt}); err != nil {
+				t.Fatalf("send prompt: %v", err)
+			}
+			waitForServiceTabIdle(t, ts.service, user, tabID, 10*time.Second)
+
+			buf, err := ts.service.GetBuffer(ctx, schema.GetBufferRequest{UserID: user, TabID: tabID, Limit: 500})
+			if err != nil {
+				t.Fatalf("get buffer: %v", err)
+			}
+			text := strings.Join(buf.Buffer.Lines, "\n")
+			for _, want := range tc.want {
+				if !strings.Contains(text, want) {
+					t.Fatalf("expected buffer to contain %q, got:\n%s", want, text)
+				}
+			}
+			for _, unwanted := range tc.wantNot {
+				if strings.Contains(text, unwanted) {
+					t.Fatalf("expected buffer not to contain %q, got:\n%s", unwanted, text)
+				}
+			}
+			tabs, err := ts.service.ListTabs(ctx, schema.ListTabsRequest{UserID: user})
+			if err != nil {
+				t.Fatalf("list tabs: %v", err)
+			}
+			if len(tabs.Tabs) != 1 || !strings.HasPrefix(string(tabs.Tabs[0].SessionID), "mock-") {
+				t.Fatalf("expected mock session id captured, got %+v", tabs.Tabs)
+			}
+		})
+	}
+}
+
+// buildCodexMock builds the centaurx binary under the codex-mock argv0 alias
+// so the codex runner can execute it exactly like the real codex binary.
+func buildCodexMock(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "codex-mock")
+	cmd := exec.Command("go", "build", "-o", bin, "pkt.systems/centaurx/cmd/centaurx")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build codex-mock: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func waitForServiceTabIdle(t *testing.T, svc core.Service, user schema.UserID, tabID schema.TabID, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		resp, err := svc.ListTabs(context.Background(), schema.ListTabsRequest{UserID: user})
+		if err != nil {
+			t.Fatalf("list tabs: %v", err)
+		}
+		for _, tab := range resp.Tabs {
+			if tab.ID == tabID && tab.Status == schema.TabStatusIdle {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for tab %s to become idle", tabID)
+}
@@ -166,6 +166,9 @@ func (h *execCommandHandle) Outputs() core.CommandStream { return &execCommandSt
 func (h *execCommandHandle) Signal(context.Context, core.ProcessSignal) error {
 	return nil
 }
+func (h *execCommandHandle) Resize(context.Context, int, int) error {
+	return nil
+}
 func (h *execCommandHandle) Wait(context.Context) (core.RunResult, error) {
 	return core.RunResult{ExitCode: h.exitCode}, nil
 }
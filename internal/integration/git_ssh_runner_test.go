@@ -211,7 +211,7 @@ func (r hostNetworkRuntime) EnsureImage(ctx context.Context, image string) error
 	return r.base.EnsureImage(ctx, image)
 }
 func (r hostNetworkRuntime) EnsureRunning(ctx context.Context, spec shipohoy.ContainerSpec) (shipohoy.Handle, error) {
-	spec.HostNetwork = true
+	spec.NetworkMode = shipohoy.NetworkHost
 	return r.base.EnsureRunning(ctx, spec)
 }
 func (r hostNetworkRuntime) Stop(ctx context.Context, handle shipohoy.Handle) error {
@@ -232,6 +232,9 @@ func (r hostNetworkRuntime) WaitForLog(ctx context.Context, handle shipohoy.Hand
 func (r hostNetworkRuntime) Janitor(ctx context.Context, spec shipohoy.JanitorSpec) (int, error) {
 	return r.base.Janitor(ctx, spec)
 }
+func (r hostNetworkRuntime) ListManaged(ctx context.Context, selector map[string]string) ([]shipohoy.ManagedContainer, error) {
+	return r.base.ListManaged(ctx, selector)
+}
 
 func hasSSHDebugOutput(output string) bool {
 	return strings.Contains(output, "debug1:") ||
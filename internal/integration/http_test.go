@@ -14,8 +14,10 @@ import (
 	"time"
 
 	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/ssh"
 
 	"pkt.systems/centaurx/httpapi"
+	"pkt.systems/centaurx/internal/auth"
 	"pkt.systems/centaurx/schema"
 )
 
@@ -289,6 +291,100 @@ func TestHTTPChangePassword(t *testing.T) {
 	}
 }
 
+// TestHTTPAccountEndpoints rotates every credential of an account the way a
+// user without SSH access would, through /api/v1/me.
+func TestHTTPAccountEndpoints(t *testing.T) {
+	requireLong(t)
+	ts := newTestServer(t)
+
+	server := httptest.NewServer(ts.httpSrv.Handler())
+	t.Cleanup(server.Close)
+
+	client := ts.login(t, server.URL)
+	resp := writeJSON(t, client, server.URL+"/api/v1/me/password", map[string]string{
+		"current_password": ts.password,
+		"totp":             mustTOTP(t, ts.totp),
+		"new_password":     "rotated-password",
+	})
+	readJSON(t, resp, &map[string]any{})
+	if _, err := loginWithPassword(t, server.URL, ts.user, "rotated-password", ts.totp); err != nil {
+		t.Fatalf("expected rotated password login to succeed: %v", err)
+	}
+
+	resp, err := client.Post(server.URL+"/api/v1/me/codexauth", "application/json", strings.NewReader(`{"tokens":{"access_token":"tok","account_id":"acct"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readJSON(t, resp, &map[string]any{})
+	resp, err = client.Post(server.URL+"/api/v1/me/codexauth", "application/json", strings.NewReader(`not json`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid auth.json to be rejected, got %d", resp.StatusCode)
+	}
+
+	pubKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(newTestSigner(t).PublicKey())))
+	var added struct {
+		ID int `json:"id"`
+	}
+	readJSON(t, writeJSON(t, client, server.URL+"/api/v1/me/keys", map[string]string{"pubkey": pubKey}), &added)
+	if added.ID != 1 {
+		t.Fatalf("unexpected key id %d", added.ID)
+	}
+	resp = writeJSON(t, client, server.URL+"/api/v1/me/keys", map[string]string{"pubkey": pubKey})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected duplicate key to conflict, got %d", resp.StatusCode)
+	}
+	listKeys := func() []httpapi.LoginPubKey {
+		resp, err := client.Get(server.URL + "/api/v1/me/keys")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload struct {
+			Keys []httpapi.LoginPubKey `json:"keys"`
+		}
+		readJSON(t, resp, &payload)
+		return payload.Keys
+	}
+	if keys := listKeys(); len(keys) != 1 || keys[0].ID != 1 || keys[0].Key != pubKey {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/api/v1/me/keys/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readJSON(t, resp, &map[string]any{})
+	if keys := listKeys(); len(keys) != 0 {
+		t.Fatalf("expected key to be removed, got %+v", keys)
+	}
+
+	// Wrong current passwords count as failed logins.
+	throttle, err := auth.NewThrottle(auth.ThrottleConfig{BackoffAfter: 1, BaseDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.authStore.SetThrottle(throttle)
+	wrong := map[string]string{
+		"current_password": "wrong",
+		"totp":             mustTOTP(t, ts.totp),
+		"new_password":     "other-password",
+	}
+	for _, want := range []int{http.StatusUnauthorized, http.StatusTooManyRequests} {
+		resp := writeJSON(t, client, server.URL+"/api/v1/me/password", wrong)
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("expected status %d, got %d", want, resp.StatusCode)
+		}
+	}
+}
+
 func waitForTabIdle(t *testing.T, client *http.Client, baseURL string, timeout time.Duration) {
 	t.Helper()
 	deadline := time.Now().Add(timeout)
@@ -0,0 +1,135 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/command"
+	"pkt.systems/centaurx/internal/eventbus"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/centaurx/sshserver"
+)
+
+func TestSSHLineMode(t *testing.T) {
+	requireLong(t)
+	ts := newTestServer(t)
+	signer := registerSSHLoginKey(t, ts)
+
+	// Line mode copies output from the event bus, which the shared test
+	// server does not have.
+	bus := eventbus.New(nil)
+	runners := core.StaticRunnerProvider{Runner: &mockRunner{}}
+	repoRoot := t.TempDir()
+	service, err := core.NewService(schema.ServiceConfig{
+		RepoRoot:      repoRoot,
+		StateDir:      filepath.Join(t.TempDir(), "state"),
+		DefaultModel:  "gpt-5.2-codex",
+		AllowedModels: []schema.ModelID{"gpt-5.2-codex"},
+	}, core.ServiceDeps{RunnerProvider: runners, EventSink: bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := command.NewHandler(service, runners, command.HandlerConfig{
+		AllowedModels: []schema.ModelID{"gpt-5.2-codex"},
+		RepoRoot:      repoRoot,
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		_ = ln.Close()
+	}()
+	server := &sshserver.Server{
+		Addr:        ln.Addr().String(),
+		Listener:    ln,
+		HostKeyPath: fmt.Sprintf("%s/host_key", t.TempDir()),
+		Service:     service,
+		Handler:     handler,
+		AuthStore:   ts.authStore,
+		EventBus:    bus,
+	}
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	client := dialSSH(t, ln.Addr().String(), ts, signer)
+	defer client.Close()
+
+	run := func(command string) (string, string, int) {
+		t.Helper()
+		session, err := client.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer session.Close()
+		var stdout, stderr bytes.Buffer
+		session.Stdout = &stdout
+		session.Stderr = &stderr
+		if command == "" {
+			err = session.Shell()
+			if err == nil {
+				err = session.Wait()
+			}
+		} else {
+			err = session.Run(command)
+		}
+		code := 0
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitStatus()
+		} else if err != nil {
+			t.Fatalf("run %q: %v", command, err)
+		}
+		return stdout.String(), stderr.String(), code
+	}
+
+	out, errOut, code := run("/help tabs")
+	if code != 0 || !strings.Contains(out, "list your tabs") || errOut != "" {
+		t.Fatalf("expected /help output on stdout, got %d %q %q", code, out, errOut)
+	}
+	if strings.Contains(out, "\x1b") {
+		t.Fatalf("expected plain output without escapes, got %q", out)
+	}
+	out, _, code = run("! git status")
+	if code != 0 || !strings.Contains(out, "mock command: git status") || !strings.Contains(out, "command finished") {
+		t.Fatalf("expected the shell command output, got %d %q", code, out)
+	}
+	_, errOut, code = run("/nosuchcommand")
+	if code != 1 || !strings.Contains(errOut, "error:") {
+		t.Fatalf("expected an error exit, got %d %q", code, errOut)
+	}
+	out, errOut, code = run("")
+	if code == 0 || !strings.Contains(errOut, "ssh -t") || strings.Contains(out, "\x1b[?1049h") {
+		t.Fatalf("expected usage and a failing exit without a pty, got %d %q %q", code, out, errOut)
+	}
+
+	// Plain scp on OpenSSH 9+ asks for SFTP; it is told to use -O.
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		t.Fatalf("request sftp: %v", err)
+	}
+	hint, err := io.ReadAll(stderr)
+	if err != nil || !strings.Contains(string(hint), "scp -O") {
+		t.Fatalf("expected the sftp subsystem to point at scp -O, got %q %v", hint, err)
+	}
+}
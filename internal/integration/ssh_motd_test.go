@@ -0,0 +1,127 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/centaurx/sshserver"
+)
+
+func TestSSHBannerAndMOTD(t *testing.T) {
+	requireLong(t)
+	ts := newTestServer(t)
+	signer := registerSSHLoginKey(t, ts)
+	dir := t.TempDir()
+	bannerFile := filepath.Join(dir, "banner")
+	motdFile := filepath.Join(dir, "motd")
+	if err := os.WriteFile(bannerFile, []byte("Authorized use only\n"), 0o600); err != nil {
+		t.Fatalf("write banner: %v", err)
+	}
+	if err := os.WriteFile(motdFile, []byte("maintenance tonight, {{.User}}\n"), 0o600); err != nil {
+		t.Fatalf("write motd: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		_ = ln.Close()
+	}()
+	server := &sshserver.Server{
+		Addr:        ln.Addr().String(),
+		Listener:    ln,
+		HostKeyPath: fmt.Sprintf("%s/host_key", t.TempDir()),
+		Service:     ts.service,
+		Handler:     ts.handler,
+		AuthStore:   ts.authStore,
+		BannerFile:  bannerFile,
+		MOTDFile:    motdFile,
+	}
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	addr := ln.Addr().String()
+
+	// The banner is sent before authentication, so even a failed login sees it.
+	var mu sync.Mutex
+	var banner string
+	_, err = ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User: ts.user,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(newTestSigner(t))},
+		BannerCallback: func(message string) error {
+			mu.Lock()
+			banner = message
+			mu.Unlock()
+			return nil
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err == nil {
+		t.Fatalf("expected auth failure with unknown key")
+	}
+	mu.Lock()
+	if banner != "Authorized use only\n" {
+		t.Fatalf("expected banner before auth, got %q", banner)
+	}
+	mu.Unlock()
+
+	client := dialSSH(t, addr, ts, signer)
+	defer client.Close()
+	_, output, session := startSSHSession(t, client)
+	defer session.Close()
+	expectOutput(t, output, "maintenance tonight, "+ts.user, 5*time.Second)
+
+	// Outlive a few state refresh ticks before counting.
+	time.Sleep(5 * time.Second)
+	if got := countSystemLines(t, ts, "maintenance tonight"); got != 1 {
+		t.Fatalf("expected motd once per session, got %d", got)
+	}
+
+	second, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if err := second.RequestPty("xterm", 80, 40, ssh.TerminalModes{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Shell(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for countSystemLines(t, ts, "maintenance tonight") != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected motd for the new session")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func countSystemLines(t *testing.T, ts *testServer, substr string) int {
+	t.Helper()
+	resp, err := ts.service.GetSystemBuffer(context.Background(), schema.GetSystemBufferRequest{UserID: schema.UserID(ts.user), Limit: 1000})
+	if err != nil {
+		t.Fatalf("system buffer: %v", err)
+	}
+	count := 0
+	for _, line := range resp.Buffer.Lines {
+		if strings.Contains(line, substr) {
+			count++
+		}
+	}
+	return count
+}
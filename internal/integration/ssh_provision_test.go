@@ -0,0 +1,156 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/internal/provision"
+	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/centaurx/sshserver"
+)
+
+// staticKeySource vouches for a fixed set of keys per user.
+type staticKeySource map[string][]ssh.PublicKey
+
+func (s staticKeySource) Name() string { return "test keys" }
+
+func (s staticKeySource) Keys(_ context.Context, username string) ([]ssh.PublicKey, error) {
+	return s[username], nil
+}
+
+func TestSSHAutoProvisionWelcomesOnce(t *testing.T) {
+	requireLong(t)
+	ts := newTestServer(t)
+	trusted := newTestSigner(t)
+	untrusted := newTestSigner(t)
+	dir := t.TempDir()
+	gitKeys, err := sshkeys.NewStore(filepath.Join(dir, "keys.bundle"), filepath.Join(dir, "keys"))
+	if err != nil {
+		t.Fatalf("git key store: %v", err)
+	}
+	var homes []string
+	provisioner, err := provision.NewProvisioner(staticKeySource{"newbie": {trusted.PublicKey()}}, ts.authStore, gitKeys, func(username string) error {
+		homes = append(homes, username)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provisioner: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer ln.Close()
+	server := &sshserver.Server{
+		Addr:        ln.Addr().String(),
+		Listener:    ln,
+		HostKeyPath: fmt.Sprintf("%s/host_key", t.TempDir()),
+		Service:     ts.service,
+		Handler:     ts.handler,
+		AuthStore:   ts.authStore,
+		Provisioner: provisioner,
+	}
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	addr := ln.Addr().String()
+
+	if _, err := sshDial(addr, "newbie", []ssh.AuthMethod{ssh.PublicKeys(untrusted)}); err == nil {
+		t.Fatalf("expected an untrusted key to fail")
+	}
+	if len(ts.authStore.LoadUsers()) != 1 {
+		t.Fatalf("untrusted key must not provision a user")
+	}
+
+	client, err := sshDial(addr, "newbie", []ssh.AuthMethod{ssh.PublicKeys(trusted)})
+	if err != nil {
+		t.Fatalf("first login with a trusted key: %v", err)
+	}
+	stdin, output, session := startSSHSession(t, client)
+	expectOutput(t, output, "welcome to centaurx, newbie", 5*time.Second)
+	if len(homes) != 1 || homes[0] != "newbie" {
+		t.Fatalf("expected the home of newbie to be created, got %q", homes)
+	}
+	if _, err := gitKeys.LoadPublicKey("newbie"); err != nil {
+		t.Fatalf("expected a git ssh key: %v", err)
+	}
+	_, _ = fmt.Fprint(stdin, "/quit\r")
+	waitForSessionClose(t, session)
+	_ = client.Close()
+	expectNoStoredWelcome(t, ts, output.String())
+
+	if _, err := sshDial(addr, "newbie", []ssh.AuthMethod{ssh.PublicKeys(trusted)}); err == nil {
+		t.Fatalf("expected later logins to require a TOTP code")
+	}
+	var secret string
+	for _, user := range ts.authStore.LoadUsers() {
+		if user.Username == "newbie" {
+			secret = user.TOTPSecret
+		}
+	}
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	client, err = sshDial(addr, "newbie", []ssh.AuthMethod{
+		ssh.PublicKeys(trusted),
+		ssh.KeyboardInteractive(func(_, _ string, _ []string, _ []bool) ([]string, error) {
+			return []string{code}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	stdin, output, session = startSSHSession(t, client)
+	_, _ = fmt.Fprint(stdin, "/quit\r")
+	waitForSessionClose(t, session)
+	_ = client.Close()
+	if strings.Contains(output.String(), "welcome to centaurx") {
+		t.Fatalf("expected the welcome only on the first login")
+	}
+	expectNoStoredWelcome(t, ts, "")
+}
+
+// expectNoStoredWelcome fails when the system buffer of newbie holds the
+// welcome or its credentials. The password is only known from the first
+// session's output, where it follows "web login password: ".
+func expectNoStoredWelcome(t *testing.T, ts *testServer, firstOutput string) {
+	t.Helper()
+	var secrets []string
+	for _, user := range ts.authStore.LoadUsers() {
+		if user.Username == "newbie" {
+			secrets = append(secrets, user.TOTPSecret)
+		}
+	}
+	if _, rest, ok := strings.Cut(firstOutput, "web login password: "); ok {
+		if fields := strings.Fields(rest); len(fields) > 0 {
+			secrets = append(secrets, fields[0])
+		}
+	}
+	resp, err := ts.service.GetSystemBuffer(context.Background(), schema.GetSystemBufferRequest{UserID: "newbie", Limit: 200})
+	if err != nil {
+		t.Fatalf("system buffer: %v", err)
+	}
+	for _, line := range resp.Buffer.Lines {
+		if strings.Contains(line, "welcome to centaurx") || strings.Contains(line, "otpauth://") {
+			t.Fatalf("expected no welcome in the system buffer, got %q", line)
+		}
+		for _, secret := range secrets {
+			if secret != "" && strings.Contains(line, secret) {
+				t.Fatalf("expected no credentials in the system buffer, got %q", line)
+			}
+		}
+	}
+}
@@ -18,110 +18,6 @@ import (
 	"pkt.systems/centaurx/sshserver"
 )
 
-func TestSSHSession(t *testing.T) {
-	requireLong(t)
-	ensureGitAvailable(t)
-	ts := newTestServer(t)
-	signer := newTestSigner(t)
-	pubKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
-	if _, err := ts.authStore.AddLoginPubKey(schema.UserID(ts.user), pubKey); err != nil {
-		t.Fatalf("add login pubkey: %v", err)
-	}
-	totpCode, err := totp.GenerateCode(ts.totp, time.Now())
-	if err != nil {
-		t.Fatalf("totp code: %v", err)
-	}
-
-	ln, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer ln.Close()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	hostKey := fmt.Sprintf("%s/host_key", t.TempDir())
-	server := &sshserver.Server{
-		Addr:        ln.Addr().String(),
-		Listener:    ln,
-		HostKeyPath: hostKey,
-		Service:     ts.service,
-		Handler:     ts.handler,
-		AuthStore:   ts.authStore,
-	}
-	go func() {
-		_ = server.ListenAndServe(ctx)
-	}()
-
-	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
-		User: ts.user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-			ssh.KeyboardInteractive(func(_, _ string, _ []string, _ []bool) ([]string, error) {
-				return []string{totpCode}, nil
-			}),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         5 * time.Second,
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer client.Close()
-
-	session, err := client.NewSession()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer session.Close()
-
-	if err := session.RequestPty("xterm", 80, 40, ssh.TerminalModes{}); err != nil {
-		t.Fatal(err)
-	}
-
-	stdin, err := session.StdinPipe()
-	if err != nil {
-		t.Fatal(err)
-	}
-	stdout, err := session.StdoutPipe()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if err := session.Shell(); err != nil {
-		t.Fatal(err)
-	}
-
-	output := &lockedBuffer{}
-	go func() {
-		_, _ = io.Copy(output, stdout)
-	}()
-
-	if _, err := fmt.Fprint(stdin, "/new demo\r"); err != nil {
-		t.Fatal(err)
-	}
-	expectOutput(t, output, "tab opened", 5*time.Second)
-
-	if _, err := fmt.Fprint(stdin, "hello\r"); err != nil {
-		t.Fatal(err)
-	}
-	expectOutput(t, output, "mock response: hello", 5*time.Second)
-
-	if _, err := fmt.Fprint(stdin, "/quit\r"); err != nil {
-		t.Fatal(err)
-	}
-	done := make(chan error, 1)
-	go func() {
-		done <- session.Wait()
-	}()
-	select {
-	case <-time.After(5 * time.Second):
-		t.Fatalf("session did not close after /quit")
-	case <-done:
-	}
-}
-
 func TestSSHQuitKeepsRunAlive(t *testing.T) {
 	requireLong(t)
 	ensureGitAvailable(t)
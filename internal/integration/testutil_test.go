@@ -128,6 +128,10 @@ func (h *mockCommandHandle) Signal(ctx context.Context, sig core.ProcessSignal)
 	return nil
 }
 
+func (h *mockCommandHandle) Resize(context.Context, int, int) error {
+	return nil
+}
+
 func (h *mockCommandHandle) Wait(ctx context.Context) (core.RunResult, error) {
 	_ = ctx
 	return core.RunResult{ExitCode: 0}, nil
@@ -198,7 +202,9 @@ func (r *blockingRunner) Run(ctx context.Context, req core.RunRequest) (core.Run
 }
 
 func (r *blockingRunner) RunCommand(ctx context.Context, req core.RunCommandRequest) (core.CommandHandle, error) {
-	if !req.UseShell {
+	// The exec start git summary answers right away; only user shell
+	// commands block.
+	if !req.UseShell || strings.HasPrefix(req.Command, "git ") {
 		return &mockCommandHandle{outputs: nil}, nil
 	}
 	r.mu.Lock()
@@ -336,6 +342,10 @@ func (h *blockingCommandHandle) Signal(context.Context, core.ProcessSignal) erro
 	return nil
 }
 
+func (h *blockingCommandHandle) Resize(context.Context, int, int) error {
+	return nil
+}
+
 func (h *blockingCommandHandle) Wait(ctx context.Context) (core.RunResult, error) {
 	if err := h.gate.wait(ctx); err != nil {
 		return core.RunResult{}, err
@@ -449,6 +459,7 @@ func newTestServerWithRunner(t *testing.T, runner core.Runner) *testServer {
 		SessionTTLHours:    1,
 		InitialBufferLines: 200,
 	}, service, handler, authStore, hub)
+	httpSrv.SetLoginPubKeyStore(authStore)
 
 	server := &testServer{
 		service:   service,
@@ -0,0 +1,62 @@
+package integration_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"pkt.systems/centaurx/internal/tuitest"
+)
+
+func TestTUIFlows(t *testing.T) {
+	requireLong(t)
+	ensureGitAvailable(t)
+	bin := tuitest.BuildCodexMock(t)
+	scenario, err := filepath.Abs(filepath.Join("testdata", "codexmock", "tui.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newHarness := func(t *testing.T) *tuitest.Harness {
+		return tuitest.New(t, tuitest.Options{CodexBinary: bin, Scenario: scenario})
+	}
+
+	t.Run("new_tab", func(t *testing.T) {
+		h := newHarness(t)
+		h.TypeLine("/new demo")
+		h.ExpectRow("tab opened")
+		h.ExpectRowAt(0, "demo")
+
+		h.TypeLine("/quit")
+		h.Wait()
+	})
+
+	t.Run("prompt_worked_for", func(t *testing.T) {
+		h := newHarness(t)
+		h.TypeLine("/new demo")
+		h.ExpectRow("tab opened")
+		h.TypeLine("hello")
+		h.ExpectRow("TUI answer.")
+		h.ExpectRow("Worked for")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		h := newHarness(t)
+		h.TypeLine("/new demo")
+		h.ExpectRow("tab opened")
+		h.TypeLine("wait for it")
+		h.ExpectRow("sleep 60")
+		h.TypeLine("/stop")
+		h.ExpectRow("stop requested: sending SIGTERM")
+		h.ExpectRow("mock received terminated")
+		h.ExpectNoRow("Done waiting.")
+	})
+
+	t.Run("resize", func(t *testing.T) {
+		h := newHarness(t)
+		h.TypeLine("/new demo")
+		h.ExpectRow("tab opened")
+		h.ResizeTo(60, 20)
+		h.ExpectRowAt(0, "demo")
+		h.TypeLine("hello")
+		h.ExpectRow("TUI answer.")
+	})
+}
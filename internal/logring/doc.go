@@ -0,0 +1,3 @@
+// Package logring keeps the latest server log entries in memory so admins
+// can read them with /serverlog without shell access to the host.
+package logring
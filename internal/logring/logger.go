@@ -0,0 +1,195 @@
+package logring
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkt.systems/pslog"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeys are field names whose values the ring always redacts.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"bind_password": true,
+	"client_secret": true,
+	"cookie":        true,
+	"github_token":  true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"totp":          true,
+	"totp_secret":   true,
+}
+
+// Sensitive marks a log field value the ring redacts. The normal log
+// writer still sees the value.
+func Sensitive(value any) any {
+	return sensitive{value: value}
+}
+
+type sensitive struct {
+	value any
+}
+
+func (s sensitive) String() string {
+	return fmt.Sprint(s.value)
+}
+
+func (s sensitive) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.value)
+}
+
+// Tee returns a logger that writes to base and also records entries at or
+// above level in ring.
+func Tee(base pslog.Logger, ring *Ring, level pslog.Level) pslog.Logger {
+	return &teeLogger{base: base, ring: ring, level: level}
+}
+
+type teeLogger struct {
+	base  pslog.Logger
+	ring  *Ring
+	level pslog.Level
+	// fields are the rendered fields added with With.
+	fields string
+}
+
+func (l *teeLogger) Trace(msg string, keyvals ...any) {
+	l.base.Trace(msg, keyvals...)
+	l.record(pslog.TraceLevel, msg, keyvals)
+}
+
+func (l *teeLogger) Debug(msg string, keyvals ...any) {
+	l.base.Debug(msg, keyvals...)
+	l.record(pslog.DebugLevel, msg, keyvals)
+}
+
+func (l *teeLogger) Info(msg string, keyvals ...any) {
+	l.base.Info(msg, keyvals...)
+	l.record(pslog.InfoLevel, msg, keyvals)
+}
+
+func (l *teeLogger) Warn(msg string, keyvals ...any) {
+	l.base.Warn(msg, keyvals...)
+	l.record(pslog.WarnLevel, msg, keyvals)
+}
+
+func (l *teeLogger) Error(msg string, keyvals ...any) {
+	l.base.Error(msg, keyvals...)
+	l.record(pslog.ErrorLevel, msg, keyvals)
+}
+
+// Fatal and Panic record first since the base logger does not return.
+func (l *teeLogger) Fatal(msg string, keyvals ...any) {
+	l.record(pslog.FatalLevel, msg, keyvals)
+	l.base.Fatal(msg, keyvals...)
+}
+
+func (l *teeLogger) Panic(msg string, keyvals ...any) {
+	l.record(pslog.PanicLevel, msg, keyvals)
+	l.base.Panic(msg, keyvals...)
+}
+
+func (l *teeLogger) Log(level pslog.Level, msg string, keyvals ...any) {
+	l.base.Log(level, msg, keyvals...)
+	l.record(level, msg, keyvals)
+}
+
+func (l *teeLogger) With(keyvals ...any) pslog.Logger {
+	next := *l
+	next.base = l.base.With(keyvals...)
+	next.fields = joinFields(l.fields, renderFields(keyvals))
+	return &next
+}
+
+func (l *teeLogger) WithLogLevel() pslog.Logger {
+	next := *l
+	next.base = l.base.WithLogLevel()
+	return &next
+}
+
+func (l *teeLogger) LogLevel(level pslog.Level) pslog.Logger {
+	next := *l
+	next.base = l.base.LogLevel(level)
+	next.level = level
+	return &next
+}
+
+func (l *teeLogger) LogLevelFromEnv(key string) pslog.Logger {
+	next := *l
+	next.base = l.base.LogLevelFromEnv(key)
+	if level, ok := pslog.LevelFromEnv(key); ok {
+		next.level = level
+	}
+	return &next
+}
+
+func (l *teeLogger) record(level pslog.Level, msg string, keyvals []any) {
+	if l.level == pslog.Disabled || level < l.level {
+		return
+	}
+	l.ring.Add(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  joinFields(l.fields, renderFields(keyvals)),
+	})
+}
+
+func joinFields(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + " " + b
+}
+
+// renderFields renders keyvals as key=value pairs, redacting sensitive
+// values.
+func renderFields(keyvals []any) string {
+	if len(keyvals) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value any
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(renderValue(key, value))
+	}
+	return b.String()
+}
+
+func renderValue(key string, value any) string {
+	if _, ok := value.(sensitive); ok || sensitiveKeys[strings.ToLower(key)] {
+		return redacted
+	}
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return "<nil>"
+	case error:
+		text = v.Error()
+	case time.Time:
+		text = v.Format(time.RFC3339)
+	default:
+		text = fmt.Sprint(v)
+	}
+	if text == "" || strings.ContainsAny(text, " \t\n\"=") {
+		return strconv.Quote(text)
+	}
+	return text
+}
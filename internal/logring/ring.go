@@ -0,0 +1,92 @@
+package logring
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"pkt.systems/pslog"
+)
+
+// DefaultSize is the number of entries a ring keeps unless configured.
+const DefaultSize = 1000
+
+// Entry is a log entry kept in the ring.
+type Entry struct {
+	Time    time.Time
+	Level   pslog.Level
+	Message string
+	// Fields holds the entry's key=value pairs rendered with sensitive
+	// values redacted.
+	Fields string
+}
+
+// String renders the entry as a single log line.
+func (e Entry) String() string {
+	var b strings.Builder
+	b.WriteString(e.Time.Local().Format("2006-01-02 15:04:05"))
+	b.WriteByte(' ')
+	b.WriteString(pslog.LevelString(e.Level))
+	b.WriteByte(' ')
+	b.WriteString(e.Message)
+	if e.Fields != "" {
+		b.WriteByte(' ')
+		b.WriteString(e.Fields)
+	}
+	return b.String()
+}
+
+// Ring keeps the latest entries. Writers claim a slot with an atomic counter
+// and publish the entry with an atomic store; readers snapshot the slots
+// without taking a lock.
+type Ring struct {
+	slots []atomic.Pointer[slot]
+	next  atomic.Uint64
+}
+
+type slot struct {
+	seq   uint64
+	entry Entry
+}
+
+// New returns a ring keeping the latest size entries, or DefaultSize when
+// size is not positive.
+func New(size int) *Ring {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Ring{slots: make([]atomic.Pointer[slot], size)}
+}
+
+// Add records entry, replacing the oldest entry once the ring is full.
+func (r *Ring) Add(entry Entry) {
+	seq := r.next.Add(1) - 1
+	r.slots[seq%uint64(len(r.slots))].Store(&slot{seq: seq, entry: entry})
+}
+
+// Last returns up to n of the latest entries at or above level, oldest
+// first.
+func (r *Ring) Last(n int, level pslog.Level) []Entry {
+	if n <= 0 {
+		return nil
+	}
+	end := r.next.Load()
+	size := uint64(len(r.slots))
+	start := uint64(0)
+	if end > size {
+		start = end - size
+	}
+	entries := make([]Entry, 0, min(n, int(end-start)))
+	for seq := end; seq > start && len(entries) < n; seq-- {
+		s := r.slots[(seq-1)%size].Load()
+		// Skip slots a writer has claimed but not yet published, or has
+		// already reused for a newer entry.
+		if s == nil || s.seq != seq-1 || s.entry.Level < level {
+			continue
+		}
+		entries = append(entries, s.entry)
+	}
+	slices.Reverse(entries)
+	return entries
+}
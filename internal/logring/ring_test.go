@@ -0,0 +1,73 @@
+package logring
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"pkt.systems/pslog"
+)
+
+func messages(entries []Entry) []string {
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, entry.Message)
+	}
+	return out
+}
+
+func TestRingRollsOver(t *testing.T) {
+	ring := New(3)
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		ring.Add(Entry{Level: pslog.InfoLevel, Message: msg})
+	}
+	if got := strings.Join(messages(ring.Last(10, pslog.TraceLevel)), ","); got != "c,d,e" {
+		t.Fatalf("expected the last three entries, got %s", got)
+	}
+	if got := strings.Join(messages(ring.Last(2, pslog.TraceLevel)), ","); got != "d,e" {
+		t.Fatalf("expected the last two entries, got %s", got)
+	}
+	if got := New(3).Last(5, pslog.TraceLevel); len(got) != 0 {
+		t.Fatalf("expected an empty ring, got %v", got)
+	}
+}
+
+func TestRingFiltersLevels(t *testing.T) {
+	ring := New(10)
+	ring.Add(Entry{Level: pslog.DebugLevel, Message: "debug"})
+	ring.Add(Entry{Level: pslog.WarnLevel, Message: "warn"})
+	ring.Add(Entry{Level: pslog.InfoLevel, Message: "info"})
+	ring.Add(Entry{Level: pslog.ErrorLevel, Message: "error"})
+	if got := strings.Join(messages(ring.Last(10, pslog.WarnLevel)), ","); got != "warn,error" {
+		t.Fatalf("expected warn and error, got %s", got)
+	}
+	if got := strings.Join(messages(ring.Last(1, pslog.InfoLevel)), ","); got != "error" {
+		t.Fatalf("expected the newest matching entry, got %s", got)
+	}
+}
+
+func TestTeeRecordsAndRedacts(t *testing.T) {
+	var out bytes.Buffer
+	base := pslog.NewWithOptions(&out, pslog.Options{Mode: pslog.ModeStructured, NoColor: true})
+	ring := New(10)
+	logger := Tee(base, ring, pslog.InfoLevel).With("user", "alice")
+	logger.Debug("skipped")
+	logger.Info("login", "password", "hunter2", "code", Sensitive("123456"), "err", errors.New("bad things"))
+	logger.LogLevel(pslog.ErrorLevel).Warn("quiet")
+
+	entries := ring.Last(10, pslog.TraceLevel)
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %v", entries)
+	}
+	fields := entries[0].Fields
+	if fields != `user=alice password=[redacted] code=[redacted] err="bad things"` {
+		t.Fatalf("unexpected fields %q", fields)
+	}
+	if !strings.Contains(out.String(), "123456") {
+		t.Fatalf("expected the base logger to see the sensitive value, got %q", out.String())
+	}
+	if line := entries[0].String(); !strings.Contains(line, " info login user=alice") {
+		t.Fatalf("unexpected line %q", line)
+	}
+}
@@ -16,6 +16,7 @@ type contextValueReader interface {
 const (
 	userKey contextKey = iota
 	tabKey
+	runKey
 )
 
 // Ctx returns the logger bound to the provided context.
@@ -47,6 +48,18 @@ func WithUserTab(ctx context.Context, userID schema.UserID, tabID schema.TabID)
 	return log
 }
 
+// WithRun annotates the logger with the run id if present.
+func WithRun(ctx context.Context, runID schema.RunID) pslog.Logger {
+	log := pslog.Ctx(ctx)
+	if runID != "" {
+		if current, ok := ctx.Value(runKey).(schema.RunID); ok && current == runID {
+			return log
+		}
+		log = log.With("run_id", runID)
+	}
+	return log
+}
+
 // WithRepo annotates the logger with repo metadata when available.
 func WithRepo(log pslog.Logger, repo schema.RepoRef) pslog.Logger {
 	if repo.Name != "" {
@@ -82,6 +95,14 @@ func ContextWithTab(ctx context.Context, tabID schema.TabID) context.Context {
 	return context.WithValue(ctx, tabKey, tabID)
 }
 
+// ContextWithRun stores the run marker on the context for log de-duplication.
+func ContextWithRun(ctx context.Context, runID schema.RunID) context.Context {
+	if ctx == nil || runID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, runKey, runID)
+}
+
 // ContextWithUserTab stores user/tab markers on the context for log de-duplication.
 func ContextWithUserTab(ctx context.Context, userID schema.UserID, tabID schema.TabID) context.Context {
 	return ContextWithTab(ContextWithUser(ctx, userID), tabID)
@@ -99,7 +120,7 @@ func ContextWithUserTabLogger(ctx context.Context, log pslog.Logger, userID sche
 	return ContextWithUserTab(ctx, userID, tabID)
 }
 
-// CopyContextFields copies user/tab markers from src to ctx.
+// CopyContextFields copies user/tab/run markers from src to ctx.
 func CopyContextFields(ctx context.Context, src contextValueReader) context.Context {
 	if src == nil {
 		return ctx
@@ -110,5 +131,8 @@ func CopyContextFields(ctx context.Context, src contextValueReader) context.Cont
 	if tab, ok := src.Value(tabKey).(schema.TabID); ok && tab != "" {
 		ctx = ContextWithTab(ctx, tab)
 	}
+	if run, ok := src.Value(runKey).(schema.RunID); ok && run != "" {
+		ctx = ContextWithRun(ctx, run)
+	}
 	return ctx
 }
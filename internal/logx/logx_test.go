@@ -68,6 +68,28 @@ func TestWithUserTabAddsFields(t *testing.T) {
 	}
 }
 
+func TestWithRunSkipsDuplicateField(t *testing.T) {
+	capture := &logCapture{}
+	logger := pslog.NewWithOptions(capture, pslog.Options{
+		Mode:          pslog.ModeStructured,
+		NoColor:       true,
+		MinLevel:      pslog.InfoLevel,
+		VerboseFields: true,
+	})
+	ctx := pslog.ContextWithLogger(context.Background(), logger)
+	log := WithRun(ctx, "run1")
+	ctx = ContextWithRun(pslog.ContextWithLogger(ctx, log), "run1")
+	WithRun(ctx, "run1").Info("hello")
+
+	line := capture.buf.String()
+	if entry := capture.firstEntry(t); entry["run_id"] != "run1" {
+		t.Fatalf("expected run_id field, got %+v", entry)
+	}
+	if n := bytes.Count([]byte(line), []byte(`"run_id"`)); n != 1 {
+		t.Fatalf("expected a single run_id field, got %d in %s", n, line)
+	}
+}
+
 type logCapture struct {
 	buf bytes.Buffer
 }
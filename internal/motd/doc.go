@@ -0,0 +1,4 @@
+// Package motd reads the pre-auth login banner and the message of the day
+// shown when a user session starts. Files are re-read on every use so edits
+// take effect without a restart.
+package motd
@@ -0,0 +1,85 @@
+package motd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+)
+
+// MaxBytes caps how much of a banner or MOTD file is read. Longer files are
+// truncated and a warning is logged.
+const MaxBytes = 16 << 10
+
+// Data holds the template variables available to the MOTD.
+type Data struct {
+	User    schema.UserID
+	Version string
+}
+
+// Banner returns the contents of the banner file at path. It returns an
+// empty string when path is empty or the file cannot be read.
+func Banner(ctx context.Context, path string) string {
+	text, ok := readFile(ctx, "banner", path)
+	if !ok {
+		return ""
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
+
+// Lines renders the MOTD template at path with data and returns its lines.
+// It returns nil when path is empty or the file cannot be read or rendered.
+func Lines(ctx context.Context, path string, data Data) []string {
+	text, ok := readFile(ctx, "motd", path)
+	if !ok || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	log := logx.Ctx(ctx).With("motd_file", path)
+	tmpl, err := template.New("motd").Option("missingkey=error").Parse(text)
+	if err != nil {
+		log.Warn("motd template parse failed", "err", err)
+		return nil
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		log.Warn("motd template render failed", "err", err)
+		return nil
+	}
+	return strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+}
+
+func readFile(ctx context.Context, kind, path string) (string, bool) {
+	if strings.TrimSpace(path) == "" {
+		return "", false
+	}
+	log := logx.Ctx(ctx).With(kind+"_file", path)
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Debug(kind + " file missing")
+		} else {
+			log.Warn(kind+" file read failed", "err", err)
+		}
+		return "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxBytes+1))
+	if err != nil {
+		log.Warn(kind+" file read failed", "err", err)
+		return "", false
+	}
+	if len(data) > MaxBytes {
+		data = data[:MaxBytes]
+		log.Warn(kind+" file truncated", "max_bytes", MaxBytes)
+	}
+	return strings.ToValidUTF8(string(data), ""), true
+}
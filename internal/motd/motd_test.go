@@ -0,0 +1,44 @@
+package motd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinesRendersTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "motd")
+	if err := os.WriteFile(path, []byte("Welcome {{.User}}\ncentaurx {{.Version}}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	lines := Lines(context.Background(), path, Data{User: "alice", Version: "v1.2.3"})
+	if len(lines) != 2 || lines[0] != "Welcome alice" || lines[1] != "centaurx v1.2.3" {
+		t.Fatalf("unexpected motd: %q", lines)
+	}
+
+	if err := os.WriteFile(path, []byte("Hi {{.Nope}}"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if lines := Lines(context.Background(), path, Data{User: "alice"}); lines != nil {
+		t.Fatalf("expected broken template to be skipped, got %q", lines)
+	}
+	if lines := Lines(context.Background(), filepath.Join(t.TempDir(), "missing"), Data{}); lines != nil {
+		t.Fatalf("expected missing file to yield nothing, got %q", lines)
+	}
+}
+
+func TestBannerTruncatesOversizedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banner")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", MaxBytes+100)), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	banner := Banner(context.Background(), path)
+	if len(banner) != MaxBytes+1 || !strings.HasSuffix(banner, "\n") {
+		t.Fatalf("expected banner truncated to %d bytes plus newline, got %d", MaxBytes, len(banner))
+	}
+	if Banner(context.Background(), "") != "" {
+		t.Fatalf("expected empty banner without a file")
+	}
+}
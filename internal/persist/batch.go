@@ -0,0 +1,112 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// batchDir holds one batch file per user. It is a subdirectory so VerifyDir
+// and the user snapshot files never see it.
+const batchDir = "batches"
+
+// BatchSnapshot captures a user's batch repo selection and latest batch.
+type BatchSnapshot struct {
+	// User is stored because file names are sanitized and cannot be mapped
+	// back to a user ID.
+	User  schema.UserID       `json:"user"`
+	Repos []schema.RepoName   `json:"repos,omitempty"`
+	Batch *schema.BatchStatus `json:"batch,omitempty"`
+}
+
+// SaveBatch writes the user's batch snapshot atomically. An empty snapshot
+// removes the file.
+func (s *Store) SaveBatch(snapshot BatchSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.pathForBatch(snapshot.User)
+	if len(snapshot.Repos) == 0 && snapshot.Batch == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "batch-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// LoadBatches reads every saved batch snapshot. Unreadable files are
+// skipped and reported in the returned error.
+func (s *Store) LoadBatches() ([]BatchSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := filepath.Join(s.dir, batchDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []BatchSnapshot
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, "batch-") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var snapshot BatchSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if snapshot.User == "" {
+			errs = append(errs, fmt.Errorf("%s: missing user", path))
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, errors.Join(errs...)
+}
+
+func (s *Store) pathForBatch(userID schema.UserID) string {
+	name := sanitize(string(userID))
+	if name == "" {
+		name = "unknown"
+	}
+	return filepath.Join(s.dir, batchDir, name+".json")
+}
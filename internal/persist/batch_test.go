@@ -0,0 +1,63 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestStoreBatchRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	snapshot := BatchSnapshot{
+		User:  "alice@example.com",
+		Repos: []schema.RepoName{"api", "web"},
+		Batch: &schema.BatchStatus{
+			Prompt:    "bump deps",
+			StartedAt: started,
+			Repos: []schema.BatchRepoStatus{
+				{Repo: "api", TabID: "tab1", State: schema.BatchRepoSucceeded, StartedAt: started, FinishedAt: started.Add(time.Minute)},
+				{Repo: "web", State: schema.BatchRepoPending},
+			},
+		},
+	}
+	if err := store.SaveBatch(snapshot); err != nil {
+		t.Fatalf("save batch: %v", err)
+	}
+	if err := store.SaveBatch(BatchSnapshot{User: "bob", Repos: []schema.RepoName{"api"}}); err != nil {
+		t.Fatalf("save batch: %v", err)
+	}
+	loaded, err := store.LoadBatches()
+	if err != nil {
+		t.Fatalf("load batches: %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("expected two batches, got %+v", loaded)
+	}
+	byUser := map[schema.UserID]BatchSnapshot{}
+	for _, entry := range loaded {
+		byUser[entry.User] = entry
+	}
+	if !reflect.DeepEqual(byUser[snapshot.User], snapshot) {
+		t.Fatalf("unexpected batch: %+v", byUser[snapshot.User])
+	}
+	results, err := VerifyDir(dir)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected batch files to be ignored by verify, got %+v (err %v)", results, err)
+	}
+
+	if err := store.SaveBatch(BatchSnapshot{User: "bob"}); err != nil {
+		t.Fatalf("clear batch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, batchDir, "bob.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected empty batch to remove the file, got %v", err)
+	}
+}
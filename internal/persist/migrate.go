@@ -0,0 +1,222 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// CurrentVersion is the snapshot schema version written by Save.
+const CurrentVersion = 4
+
+// segmentsVersion is the first version that keeps buffer lines in segment
+// files instead of inline.
+const segmentsVersion = 4
+
+// migration upgrades a raw snapshot from version N to N+1 in place.
+type migration func(raw map[string]any) error
+
+// migrations is indexed by the version a migration upgrades from.
+var migrations = []migration{
+	0: migrateV0HistoryEntries,
+	1: migrateV1GlobalHistory,
+	2: migrateV2BufferLines,
+	3: migrateV3BufferSegments,
+}
+
+// decodeSnapshot decodes data, applying migrations up to CurrentVersion. It
+// returns the snapshot and the version it was stored as.
+func decodeSnapshot(data []byte) (UserSnapshot, int, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return UserSnapshot{}, 0, err
+	}
+	if raw == nil {
+		raw = map[string]any{}
+	}
+	from, err := snapshotVersion(raw)
+	if err != nil {
+		return UserSnapshot{}, 0, err
+	}
+	if from > CurrentVersion {
+		return UserSnapshot{}, from, newerVersionError{version: from}
+	}
+	for version := from; version < CurrentVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return UserSnapshot{}, from, fmt.Errorf("migrate state v%d to v%d: %w", version, version+1, err)
+		}
+		raw["version"] = version + 1
+	}
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return UserSnapshot{}, from, err
+	}
+	var snapshot UserSnapshot
+	if err := json.Unmarshal(migrated, &snapshot); err != nil {
+		return UserSnapshot{}, from, err
+	}
+	return snapshot, from, nil
+}
+
+// DecodeSnapshot decodes a JSON snapshot that carries its buffer lines
+// inline, such as one in a user export, migrating it to CurrentVersion.
+func DecodeSnapshot(data []byte) (UserSnapshot, error) {
+	snapshot, _, err := decodeSnapshot(data)
+	return snapshot, err
+}
+
+// newerVersionError reports a snapshot written by a newer centaurx. The file
+// is intact, so it is never treated as corrupt.
+type newerVersionError struct {
+	version int
+}
+
+func (e newerVersionError) Error() string {
+	return fmt.Sprintf("state version %d is newer than supported version %d", e.version, CurrentVersion)
+}
+
+// isCorrupt reports whether a decodeSnapshot error means the data is damaged
+// rather than merely unsupported.
+func isCorrupt(err error) bool {
+	var newer newerVersionError
+	return err != nil && !errors.As(err, &newer)
+}
+
+func snapshotVersion(raw map[string]any) (int, error) {
+	value, ok := raw["version"]
+	if !ok || value == nil {
+		return 0, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number < 0 || number != float64(int(number)) {
+		return 0, fmt.Errorf("invalid state version %v", value)
+	}
+	return int(number), nil
+}
+
+// migrateV0HistoryEntries converts tab history from a flat list of prompts to
+// entries with timestamps. v0 never recorded times, so they stay unset.
+func migrateV0HistoryEntries(raw map[string]any) error {
+	tabs, _ := raw["tabs"].([]any)
+	for i, item := range tabs {
+		tab, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("tab %d: unexpected %T", i, item)
+		}
+		history, ok := tab["history"].([]any)
+		if !ok {
+			continue
+		}
+		entries := make([]any, 0, len(history))
+		for _, entry := range history {
+			text, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("tab %d: history entry %T is not a string", i, entry)
+			}
+			entries = append(entries, map[string]any{"text": text})
+		}
+		tab["history"] = entries
+	}
+	return nil
+}
+
+// migrateV1GlobalHistory seeds the per-user global history from the tab
+// histories, ordered by time. Untimed entries sort first in tab order.
+func migrateV1GlobalHistory(raw map[string]any) error {
+	type timedEntry struct {
+		entry map[string]any
+		at    time.Time
+	}
+	var merged []timedEntry
+	tabs, _ := raw["tabs"].([]any)
+	for i, item := range tabs {
+		tab, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("tab %d: unexpected %T", i, item)
+		}
+		history, _ := tab["history"].([]any)
+		for _, value := range history {
+			entry, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("tab %d: history entry %T is not an object", i, value)
+			}
+			var at time.Time
+			if stamp, ok := entry["time"].(string); ok {
+				parsed, err := time.Parse(time.RFC3339Nano, stamp)
+				if err != nil {
+					return fmt.Errorf("tab %d: history time: %w", i, err)
+				}
+				at = parsed
+			}
+			merged = append(merged, timedEntry{entry: entry, at: at})
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].at.Before(merged[j].at)
+	})
+	global := make([]any, 0, len(merged))
+	var last any
+	for _, item := range merged {
+		if len(global) > 0 && item.entry["text"] == last {
+			continue
+		}
+		global = append(global, item.entry)
+		last = item.entry["text"]
+	}
+	if len(global) > 0 {
+		raw["global_history"] = global
+	}
+	return nil
+}
+
+// migrateV2BufferLines converts marker-prefixed buffer lines to typed lines.
+// v2 never recorded when lines were written, so timestamps stay unset.
+func migrateV2BufferLines(raw map[string]any) error {
+	tabs, _ := raw["tabs"].([]any)
+	for i, item := range tabs {
+		tab, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("tab %d: unexpected %T", i, item)
+		}
+		if err := migrateBufferLines(tab["buffer"]); err != nil {
+			return fmt.Errorf("tab %d: %w", i, err)
+		}
+	}
+	if err := migrateBufferLines(raw["system"]); err != nil {
+		return fmt.Errorf("system: %w", err)
+	}
+	return nil
+}
+
+func migrateBufferLines(value any) error {
+	buffer, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	lines, ok := buffer["lines"].([]any)
+	if !ok {
+		return nil
+	}
+	typed := make([]any, 0, len(lines))
+	for _, line := range lines {
+		text, ok := line.(string)
+		if !ok {
+			return fmt.Errorf("buffer line %T is not a string", line)
+		}
+		parsed := schema.ParseBufferLine(text)
+		typed = append(typed, map[string]any{"kind": string(parsed.Kind), "text": parsed.Text})
+	}
+	buffer["lines"] = typed
+	return nil
+}
+
+// migrateV3BufferSegments leaves the inline buffer lines in place. Moving
+// them to segment files needs the file system, so Store.Load does it by
+// saving the loaded snapshot.
+func migrateV3BufferSegments(map[string]any) error {
+	return nil
+}
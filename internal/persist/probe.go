@@ -0,0 +1,21 @@
+package persist
+
+import (
+	"errors"
+	"os"
+)
+
+// ProbeWritable checks that snapshots can be saved in dir by writing,
+// syncing and removing a temporary file there.
+func ProbeWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	_, werr := file.Write([]byte("probe\n"))
+	serr := file.Sync()
+	cerr := file.Close()
+	rerr := os.Remove(name)
+	return errors.Join(werr, serr, cerr, rerr)
+}
@@ -0,0 +1,217 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// Buffer lines live in one append-only segment file per buffer, next to the
+// user's metadata snapshot:
+//
+//	state_dir/<user>.buffers/system.seg
+//	state_dir/<user>.buffers/tab-<id>.seg
+//
+// A segment starts with a header line holding the sequence number of the
+// line before its first record, followed by one JSON encoded BufferLine per
+// line. Saves append the lines added since the last save; once the records
+// no longer in the buffer outnumber the buffer limit the segment is rewritten
+// with just the live lines. A record cut short by a crash is dropped, and the
+// file truncated, the next time the segment is read.
+
+const (
+	segmentDirSuffix = ".buffers"
+	segmentExt       = ".seg"
+	systemSegment    = "system"
+	tabSegmentPrefix = "tab-"
+)
+
+// segmentHeader is the first line of a segment file.
+type segmentHeader struct {
+	Base int64 `json:"base"`
+}
+
+// segmentState tracks what a segment file holds: the lines numbered
+// base+1 through base+count.
+type segmentState struct {
+	base  int64
+	count int64
+}
+
+func (st segmentState) end() int64 {
+	return st.base + st.count
+}
+
+// segment is a decoded segment file.
+type segment struct {
+	segmentState
+	lines []schema.BufferLine
+	// valid is the length of the file up to the last complete record.
+	valid int64
+	// size is the length of the file as read.
+	size int64
+}
+
+// torn reports whether the file ends in an incomplete or undecodable record.
+func (seg segment) torn() bool {
+	return seg.valid < seg.size
+}
+
+func segmentDirFor(path string) string {
+	return strings.TrimSuffix(path, ".json") + segmentDirSuffix
+}
+
+func tabSegmentName(id schema.TabID) string {
+	name := sanitize(string(id))
+	if name == "" {
+		name = "unknown"
+	}
+	return tabSegmentPrefix + name + segmentExt
+}
+
+// readSegment decodes the segment at path. Decoding stops at the first
+// record that is not newline terminated or does not decode; everything
+// before it is returned. Only an unreadable header is an error.
+func readSegment(path string) (segment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return segment{}, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader := bufio.NewReader(file)
+	header, err := reader.ReadBytes('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return segment{}, fmt.Errorf("segment %s: truncated header", path)
+		}
+		return segment{}, err
+	}
+	var head segmentHeader
+	if err := json.Unmarshal(header, &head); err != nil || head.Base < 0 {
+		return segment{}, fmt.Errorf("segment %s: invalid header", path)
+	}
+	seg := segment{segmentState: segmentState{base: head.Base}, valid: int64(len(header))}
+	seg.size = seg.valid
+	damaged := false
+	for {
+		record, err := reader.ReadBytes('\n')
+		seg.size += int64(len(record))
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return seg, nil
+			}
+			return segment{}, err
+		}
+		if damaged {
+			// Keep reading so size covers the whole file.
+			continue
+		}
+		var line schema.BufferLine
+		if err := json.Unmarshal(record, &line); err != nil {
+			damaged = true
+			continue
+		}
+		seg.lines = append(seg.lines, line)
+		seg.count++
+		seg.valid += int64(len(record))
+	}
+}
+
+// encodeRecords appends one newline terminated JSON record per line to buf.
+func encodeRecords(buf *bytes.Buffer, lines []schema.BufferLine) error {
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// writeSegmentFile replaces the segment at path with a header for base and
+// the given lines. It returns the number of bytes written.
+func writeSegmentFile(path string, base int64, lines []schema.BufferLine) (int64, error) {
+	var buf bytes.Buffer
+	header, err := json.Marshal(segmentHeader{Base: base})
+	if err != nil {
+		return 0, err
+	}
+	buf.Write(header)
+	buf.WriteByte('\n')
+	if err := encodeRecords(&buf, lines); err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), "segment-*"+segmentExt); err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()), nil
+}
+
+// appendSegmentFile appends lines to the segment at path. It returns the
+// number of bytes written.
+func appendSegmentFile(path string, lines []schema.BufferLine) (int64, error) {
+	var buf bytes.Buffer
+	if err := encodeRecords(&buf, lines); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()), nil
+}
+
+// writeFileAtomic writes data to a temp file in path's directory and renames
+// it over path.
+func writeFileAtomic(path string, data []byte, pattern string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), pattern)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// growingBuffer mimics the core buffer: lines are appended and trimmed from
+// the front past maxLines, and seq counts every line appended.
+type growingBuffer struct {
+	lines    []schema.BufferLine
+	seq      int64
+	maxLines int
+}
+
+func (b *growingBuffer) append(text string) {
+	b.lines = append(b.lines, schema.BufferLine{Kind: schema.LineKindAgent, Text: text, Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)})
+	b.seq++
+	if b.maxLines > 0 && len(b.lines) > b.maxLines {
+		b.lines = b.lines[len(b.lines)-b.maxLines:]
+	}
+}
+
+func (b *growingBuffer) snapshot() BufferSnapshot {
+	return BufferSnapshot{Lines: append([]schema.BufferLine(nil), b.lines...), Seq: b.seq}
+}
+
+func tabSnapshot(buf *growingBuffer) UserSnapshot {
+	return UserSnapshot{
+		Order: []schema.TabID{"tab1"},
+		Tabs:  []TabSnapshot{{ID: "tab1", Buffer: buf.snapshot()}},
+	}
+}
+
+func segmentFile(dir string, id schema.TabID) string {
+	return filepath.Join(dir, "alice"+segmentDirSuffix, tabSegmentName(id))
+}
+
+func loadTabLines(t *testing.T, store *Store) []string {
+	t.Helper()
+	got, ok, err := store.Load("alice")
+	if err != nil || !ok {
+		t.Fatalf("load: ok=%v err=%v", ok, err)
+	}
+	if len(got.Tabs) != 1 {
+		t.Fatalf("expected one tab, got %+v", got.Tabs)
+	}
+	var texts []string
+	for _, line := range got.Tabs[0].Buffer.Lines {
+		texts = append(texts, line.Text)
+	}
+	return texts
+}
+
+func TestStoreSaveAppendsOnlyNewLines(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	buf := &growingBuffer{}
+	for i := range 3 {
+		buf.append(fmt.Sprintf("line %d", i))
+	}
+	if err := store.Save("alice", tabSnapshot(buf)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	path := segmentFile(dir, "tab1")
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat segment: %v", err)
+	}
+	buf.append("line 3")
+	if err := store.Save("alice", tabSnapshot(buf)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat segment: %v", err)
+	}
+	record, _ := json.Marshal(buf.lines[3])
+	if grown := after.Size() - before.Size(); grown != int64(len(record))+1 {
+		t.Fatalf("expected segment to grow by one record (%d bytes), grew %d", len(record)+1, grown)
+	}
+	meta, err := os.ReadFile(filepath.Join(dir, "alice.json"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	if strings.Contains(string(meta), "line 3") {
+		t.Fatalf("expected buffer lines kept out of the metadata, got:\n%s", meta)
+	}
+
+	// A fresh store picks up where the segment left off.
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if got, want := loadTabLines(t, reopened), []string{"line 0", "line 1", "line 2", "line 3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStoreLoadRepairsTornSegment(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	buf := &growingBuffer{}
+	for i := range 3 {
+		buf.append(fmt.Sprintf("line %d", i))
+		if err := store.Save("alice", tabSnapshot(buf)); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	// Cut the last record in half, as a crash mid-append would.
+	path := segmentFile(dir, "tab1")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read segment: %v", err)
+	}
+	record, _ := json.Marshal(buf.lines[2])
+	torn := len(data) - len(record)/2
+	if err := os.WriteFile(path, data[:torn], 0o600); err != nil {
+		t.Fatalf("truncate segment: %v", err)
+	}
+
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if got, want := loadTabLines(t, reopened), []string{"line 0", "line 1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected complete records only, got %v", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != int64(len(data)-len(record)-1) {
+		t.Fatalf("expected segment truncated to last complete record, got %v err=%v", info.Size(), err)
+	}
+
+	// Appends continue cleanly after the repair.
+	buf = &growingBuffer{lines: buf.lines[:2], seq: 2}
+	buf.append("line 2 again")
+	if err := reopened.Save("alice", tabSnapshot(buf)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	again, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if got, want := loadTabLines(t, again), []string{"line 0", "line 1", "line 2 again"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStoreLoadUnreadableSegmentHeader(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	buf := &growingBuffer{}
+	buf.append("line 0")
+	if err := store.Save("alice", tabSnapshot(buf)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	path := segmentFile(dir, "tab1")
+	if err := os.WriteFile(path, []byte(`{"ba`), 0o600); err != nil {
+		t.Fatalf("write segment: %v", err)
+	}
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if got := loadTabLines(t, reopened); len(got) != 0 {
+		t.Fatalf("expected an empty buffer, got %v", got)
+	}
+	buf.append("line 1")
+	if err := reopened.Save("alice", tabSnapshot(buf)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got, want := loadTabLines(t, reopened), []string{"line 0", "line 1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected segment rewritten from the buffer, got %v", got)
+	}
+}
+
+func TestStoreCompactsSegment(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	store.SetBufferMaxLines(3)
+	buf := &growingBuffer{maxLines: 3}
+	for i := range 20 {
+		buf.append(fmt.Sprintf("line %d", i))
+		if err := store.Save("alice", tabSnapshot(buf)); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		seg, err := readSegment(segmentFile(dir, "tab1"))
+		if err != nil {
+			t.Fatalf("read segment: %v", err)
+		}
+		if seg.count > 6 {
+			t.Fatalf("expected segment compacted to at most twice the limit, holds %d records", seg.count)
+		}
+		if seg.end() != buf.seq {
+			t.Fatalf("expected segment to end at seq %d, ends at %d", buf.seq, seg.end())
+		}
+	}
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	reopened.SetBufferMaxLines(3)
+	if got, want := loadTabLines(t, reopened), []string{"line 17", "line 18", "line 19"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStoreRewritesSegmentForReplacedBuffer(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	buf := &growingBuffer{}
+	for i := range 5 {
+		buf.append(fmt.Sprintf("old %d", i))
+	}
+	if err := store.Save("alice", tabSnapshot(buf)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// A buffer restarted from scratch numbers its lines from zero again.
+	fresh := &growingBuffer{}
+	fresh.append("new 0")
+	if err := store.Save("alice", tabSnapshot(fresh)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got, want := loadTabLines(t, store), []string{"new 0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStoreRemovesSegmentsOfDroppedTabs(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	buf := &growingBuffer{}
+	buf.append("hi")
+	snapshot := UserSnapshot{
+		Order: []schema.TabID{"tab1", "tab2"},
+		Tabs: []TabSnapshot{
+			{ID: "tab1", Buffer: buf.snapshot()},
+			{ID: "tab2", Buffer: buf.snapshot()},
+		},
+		ClosedTabs: []ClosedTabSnapshot{{Tab: TabSnapshot{ID: "tab3", Buffer: buf.snapshot()}}},
+	}
+	if err := store.Save("alice", snapshot); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	snapshot.Order = []schema.TabID{"tab1"}
+	snapshot.Tabs = snapshot.Tabs[:1]
+	if err := store.Save("alice", snapshot); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	for id, want := range map[schema.TabID]bool{"tab1": true, "tab2": false, "tab3": true} {
+		_, err := os.Stat(segmentFile(dir, id))
+		if exists := err == nil; exists != want {
+			t.Fatalf("segment for %s exists=%v, want %v", id, exists, want)
+		}
+	}
+}
+
+func TestStoreMigratesInlineBuffersToSegments(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "v3.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "alice.json"), data, 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	first, ok, err := store.Load("alice")
+	if err != nil || !ok {
+		t.Fatalf("load: ok=%v err=%v", ok, err)
+	}
+	meta, err := os.ReadFile(filepath.Join(dir, "alice.json"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	if !strings.Contains(string(meta), fmt.Sprintf(`"version": %d`, CurrentVersion)) || strings.Contains(string(meta), `"lines"`) {
+		t.Fatalf("expected migrated metadata without inline lines, got:\n%s", meta)
+	}
+	if backup, err := os.ReadFile(filepath.Join(dir, "alice.json.bak")); err != nil || string(backup) != string(data) {
+		t.Fatalf("expected the single-file snapshot kept as backup, err=%v", err)
+	}
+	if _, err := os.Stat(segmentFile(dir, "tab1")); err != nil {
+		t.Fatalf("expected tab segment: %v", err)
+	}
+
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	second, _, err := reopened.Load("alice")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("snapshot changed across migration:\nfirst:  %+v\nsecond: %+v", first, second)
+	}
+}
+
+// BenchmarkStoreSaveAppendedLine reports the bytes written to disk per line
+// appended to one tab of a user with ten full tabs, for the single-file
+// snapshot written before segments and for segments.
+func BenchmarkStoreSaveAppendedLine(b *testing.B) {
+	const tabs, lines = 10, schema.DefaultBufferMaxLines
+	newBuffers := func() []*growingBuffer {
+		buffers := make([]*growingBuffer, tabs)
+		for i := range buffers {
+			buffers[i] = &growingBuffer{maxLines: lines}
+			for j := range lines {
+				buffers[i].append(fmt.Sprintf("tab %d line %d with some typical agent output", i, j))
+			}
+		}
+		return buffers
+	}
+	snapshotOf := func(buffers []*growingBuffer) UserSnapshot {
+		snapshot := UserSnapshot{Version: CurrentVersion}
+		for i, buf := range buffers {
+			id := schema.TabID(fmt.Sprintf("tab%d", i))
+			snapshot.Order = append(snapshot.Order, id)
+			snapshot.Tabs = append(snapshot.Tabs, TabSnapshot{ID: id, Buffer: buf.snapshot()})
+		}
+		return snapshot
+	}
+
+	b.Run("single-file", func(b *testing.B) {
+		buffers := newBuffers()
+		path := filepath.Join(b.TempDir(), "alice.json")
+		var written int64
+		for i := 0; b.Loop(); i++ {
+			buffers[0].append(fmt.Sprintf("appended %d", i))
+			data, err := json.MarshalIndent(snapshotOf(buffers), "", "  ")
+			if err != nil {
+				b.Fatalf("marshal: %v", err)
+			}
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				b.Fatalf("write: %v", err)
+			}
+			written += int64(len(data))
+		}
+		b.ReportMetric(float64(written)/float64(b.N), "bytes/line")
+	})
+
+	b.Run("segments", func(b *testing.B) {
+		buffers := newBuffers()
+		store, err := NewStore(b.TempDir())
+		if err != nil {
+			b.Fatalf("new store: %v", err)
+		}
+		if err := store.Save("alice", snapshotOf(buffers)); err != nil {
+			b.Fatalf("save: %v", err)
+		}
+		store.written = 0
+		for i := 0; b.Loop(); i++ {
+			buffers[0].append(fmt.Sprintf("appended %d", i))
+			if err := store.Save("alice", snapshotOf(buffers)); err != nil {
+				b.Fatalf("save: %v", err)
+			}
+		}
+		b.ReportMetric(float64(store.written)/float64(b.N), "bytes/line")
+	})
+}
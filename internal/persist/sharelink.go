@@ -0,0 +1,102 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// shareLinkDir holds one share link file per user, next to batchDir.
+const shareLinkDir = "sharelinks"
+
+// ShareLinkSnapshot captures the share links a user has created.
+type ShareLinkSnapshot struct {
+	// User is stored because file names are sanitized and cannot be mapped
+	// back to a user ID.
+	User  schema.UserID `json:"user"`
+	Links []ShareLink   `json:"links,omitempty"`
+}
+
+// ShareLink is a view link to a tab. Only the SHA-256 of the token is kept,
+// so the state directory holds no usable links.
+type ShareLink struct {
+	Hash      string       `json:"hash"`
+	TabID     schema.TabID `json:"tab_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// SaveShareLinks writes the user's share links atomically. An empty snapshot
+// removes the file.
+func (s *Store) SaveShareLinks(snapshot ShareLinkSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.pathForShareLinks(snapshot.User)
+	if len(snapshot.Links) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, "sharelinks-*.json")
+}
+
+// LoadShareLinks reads every saved share link snapshot. Unreadable files are
+// skipped and reported in the returned error.
+func (s *Store) LoadShareLinks() ([]ShareLinkSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := filepath.Join(s.dir, shareLinkDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []ShareLinkSnapshot
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, "sharelinks-") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var snapshot ShareLinkSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if snapshot.User == "" {
+			errs = append(errs, fmt.Errorf("%s: missing user", path))
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, errors.Join(errs...)
+}
+
+func (s *Store) pathForShareLinks(userID schema.UserID) string {
+	name := sanitize(string(userID))
+	if name == "" {
+		name = "unknown"
+	}
+	return filepath.Join(s.dir, shareLinkDir, name+".json")
+}
@@ -0,0 +1,44 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreShareLinkRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	snapshot := ShareLinkSnapshot{
+		User: "alice@example.com",
+		Links: []ShareLink{
+			{Hash: "abc", TabID: "tab1", ExpiresAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+	}
+	if err := store.SaveShareLinks(snapshot); err != nil {
+		t.Fatalf("save share links: %v", err)
+	}
+	loaded, err := store.LoadShareLinks()
+	if err != nil {
+		t.Fatalf("load share links: %v", err)
+	}
+	if len(loaded) != 1 || !reflect.DeepEqual(loaded[0], snapshot) {
+		t.Fatalf("unexpected share links: %+v", loaded)
+	}
+	results, err := VerifyDir(dir)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected share link files to be ignored by verify, got %+v (err %v)", results, err)
+	}
+
+	if err := store.SaveShareLinks(ShareLinkSnapshot{User: snapshot.User}); err != nil {
+		t.Fatalf("clear share links: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, shareLinkDir, "alice_example.com.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected empty snapshot to remove the file, got %v", err)
+	}
+}
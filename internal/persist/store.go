@@ -3,9 +3,12 @@ package persist
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"pkt.systems/centaurx/schema"
@@ -14,8 +17,14 @@ import (
 
 // BufferSnapshot captures buffer state for persistence.
 type BufferSnapshot struct {
-	Lines        []string `json:"lines"`
-	ScrollOffset int      `json:"scroll_offset"`
+	// Lines are kept in the buffer's segment file; the metadata snapshot
+	// leaves them empty.
+	Lines        []schema.BufferLine `json:"lines,omitempty"`
+	ScrollOffset int                 `json:"scroll_offset"`
+	// Seq counts every line ever appended to the buffer, including lines
+	// since trimmed, so Save can tell which lines are new. Zero means
+	// len(Lines).
+	Seq int64 `json:"seq,omitempty"`
 }
 
 // TabSnapshot captures a tab for persistence.
@@ -26,22 +35,141 @@ type TabSnapshot struct {
 	Model                schema.ModelID              `json:"model"`
 	ModelReasoningEffort schema.ModelReasoningEffort `json:"model_reasoning_effort,omitempty"`
 	SessionID            schema.SessionID            `json:"session_id"`
-	Buffer               BufferSnapshot              `json:"buffer"`
-	History              []string                    `json:"history,omitempty"`
+	// LastPrompt is the most recent prompt the tab accepted, for /redo.
+	LastPrompt    string         `json:"last_prompt,omitempty"`
+	Buffer        BufferSnapshot `json:"buffer"`
+	History       []HistoryEntry `json:"history,omitempty"`
+	OutputFilters []string       `json:"output_filters,omitempty"`
+	Shares        []TabShare     `json:"shares,omitempty"`
+	// SummariesEnabled records /summary on for the tab.
+	SummariesEnabled bool                `json:"summaries_enabled,omitempty"`
+	Summaries        []schema.TabSummary `json:"summaries,omitempty"`
+	// Ephemeral tabs are saved without buffer, history, session or
+	// prompts and come back empty.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// Tools records the codex tools turned on or off with /tools.
+	Tools map[schema.ToolName]bool `json:"tools,omitempty"`
+	// LastRunAt is when the tab's latest codex run started.
+	LastRunAt time.Time `json:"last_run_at,omitzero"`
+	// RepoURL is the URL the repo was cloned from, if any.
+	RepoURL string `json:"repo_url,omitempty"`
+	// Labels are the tab's /tag labels.
+	Labels []schema.TabLabel `json:"labels,omitempty"`
+	// AutoCommit records /autocommit on for the tab.
+	AutoCommit bool `json:"auto_commit,omitempty"`
+	// Archived records /archive tab for the tab.
+	Archived bool `json:"archived,omitempty"`
+}
+
+// TabShare captures another user's access to a tab.
+type TabShare struct {
+	User   schema.UserID      `json:"user"`
+	Access schema.ShareAccess `json:"access"`
+}
+
+// SharedTab records a tab another user has shared with this user. The
+// owner's TabShare is authoritative; this only lets the guest find the tab.
+type SharedTab struct {
+	TabID schema.TabID  `json:"tab_id"`
+	Owner schema.UserID `json:"owner"`
+}
+
+// ClosedTabSnapshot captures a closed tab kept for reopening.
+type ClosedTabSnapshot struct {
+	Tab      TabSnapshot `json:"tab"`
+	ClosedAt time.Time   `json:"closed_at"`
+}
+
+// HistoryEntry captures a prompt history entry for persistence.
+type HistoryEntry struct {
+	Text string    `json:"text"`
+	Time time.Time `json:"time,omitzero"`
 }
 
 // UserSnapshot captures a user's tab state for persistence.
 type UserSnapshot struct {
-	Order  []schema.TabID   `json:"order"`
-	Tabs   []TabSnapshot    `json:"tabs"`
-	System BufferSnapshot   `json:"system,omitempty"`
-	Theme  schema.ThemeName `json:"theme,omitempty"`
+	// Version is the snapshot schema version; Save always writes CurrentVersion.
+	Version int              `json:"version"`
+	Order   []schema.TabID   `json:"order"`
+	Tabs    []TabSnapshot    `json:"tabs"`
+	System  BufferSnapshot   `json:"system,omitempty"`
+	Theme   schema.ThemeName `json:"theme,omitempty"`
+	// GlobalHistory holds prompts from every tab, oldest first.
+	GlobalHistory []HistoryEntry `json:"global_history,omitempty"`
+	// SharedTabs lists tabs other users have shared with this user.
+	SharedTabs []SharedTab `json:"shared_tabs,omitempty"`
+	// ClosedTabs holds recently closed tabs that can be reopened, oldest
+	// first.
+	ClosedTabs []ClosedTabSnapshot `json:"closed_tabs,omitempty"`
+	// Aliases maps command alias names to their expansions.
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Timezone is the IANA zone chosen with /tz; empty uses the server
+	// default.
+	Timezone string `json:"timezone,omitempty"`
+	// TokenUsage counts the tokens used on the latest day with usage, for
+	// the daily token budget.
+	TokenUsage *TokenUsage `json:"token_usage,omitempty"`
+	// Onboarding is the first-run checklist progress of a new user; it is
+	// nil once the checklist is finished or turned off.
+	Onboarding *Onboarding `json:"onboarding,omitempty"`
+	// Recovery is set when the snapshot was restored from a backup after the
+	// state file was found corrupt. It stays until the user has been told.
+	Recovery *Recovery `json:"recovery,omitempty"`
+}
+
+// Onboarding records the first-run checklist steps a user has done.
+type Onboarding struct {
+	Done []schema.OnboardingStep `json:"done,omitempty"`
+}
+
+// TokenUsage is the number of codex tokens a user used on a UTC day.
+type TokenUsage struct {
+	// Day is the UTC date, formatted 2006-01-02.
+	Day    string `json:"day"`
+	Tokens int64  `json:"tokens"`
+}
+
+// Recovery describes a snapshot restored from its backup.
+type Recovery struct {
+	// BackupTakenAt is when the restored backup was written.
+	BackupTakenAt time.Time `json:"backup_taken_at"`
+	// CorruptPath is where the unreadable state file was moved.
+	CorruptPath string `json:"corrupt_path"`
+}
+
+// CorruptStateError reports a state file that could not be decoded and for
+// which no usable backup existed. The file has been moved to MovedTo unless
+// that failed.
+type CorruptStateError struct {
+	Path    string
+	MovedTo string
+	Err     error
+}
+
+func (e *CorruptStateError) Error() string {
+	if e.MovedTo == "" {
+		return fmt.Sprintf("state file %s is corrupt: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("state file %s is corrupt (moved to %s): %v", e.Path, e.MovedTo, e.Err)
+}
+
+func (e *CorruptStateError) Unwrap() error {
+	return e.Err
 }
 
 // Store persists user snapshots to disk.
 type Store struct {
 	dir string
 	log pslog.Logger
+	now func() time.Time
+
+	mu       sync.Mutex
+	maxLines int
+	// segments caches the state of segment files written or read since
+	// start, keyed by path.
+	segments map[string]segmentState
+	// written counts bytes written to state files, for benchmarks.
+	written int64
 }
 
 // NewStore constructs a persistent store at the given directory.
@@ -60,13 +188,46 @@ func NewStoreWithLogger(dir string, logger pslog.Logger) (*Store, error) {
 	if logger != nil {
 		logger = logger.With("state_dir", dir)
 	}
-	return &Store{dir: dir, log: logger}, nil
+	return &Store{
+		dir:      dir,
+		log:      logger,
+		now:      time.Now,
+		maxLines: schema.DefaultBufferMaxLines,
+		segments: make(map[string]segmentState),
+	}, nil
+}
+
+// SetBufferMaxLines sets the buffer limit segments are compacted against.
+// Non-positive values keep the default.
+func (s *Store) SetBufferMaxLines(maxLines int) {
+	if maxLines <= 0 {
+		return
+	}
+	s.mu.Lock()
+	s.maxLines = maxLines
+	s.mu.Unlock()
 }
 
-// Load reads a user snapshot from disk.
+// Load reads a user snapshot from disk. A state file that cannot be decoded
+// is moved aside and the snapshot is restored from its backup, with
+// UserSnapshot.Recovery set; without a usable backup Load returns a
+// *CorruptStateError.
 func (s *Store) Load(userID schema.UserID) (UserSnapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	path := s.pathForUser(userID)
 	data, err := os.ReadFile(path)
+	fromBackup := false
+	if errors.Is(err, os.ErrNotExist) {
+		// A missing file next to a backup means a save was interrupted after
+		// the previous file was moved aside; the backup is the last good
+		// snapshot.
+		data, err = os.ReadFile(backupPath(path))
+		fromBackup = true
+		if err == nil && s.log != nil {
+			s.log.Warn("state load using backup", "user", userID, "path", backupPath(path))
+		}
+	}
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			if s.log != nil {
@@ -79,28 +240,160 @@ func (s *Store) Load(userID schema.UserID) (UserSnapshot, bool, error) {
 		}
 		return UserSnapshot{}, false, err
 	}
-	var snapshot UserSnapshot
-	if err := json.Unmarshal(data, &snapshot); err != nil {
+	snapshot, from, err := s.decode(userID, path, data)
+	if err != nil {
+		if !fromBackup && isCorrupt(err) {
+			return s.recover(userID, path, err)
+		}
 		if s.log != nil {
 			s.log.Warn("state load failed", "user", userID, "err", err)
 		}
 		return UserSnapshot{}, false, err
 	}
 	if s.log != nil {
+		if from != CurrentVersion {
+			s.log.Info("state migrated", "user", userID, "from", from, "to", CurrentVersion)
+		}
 		s.log.Debug("state load ok", "user", userID, "tabs", len(snapshot.Tabs))
 	}
+	if from < segmentsVersion {
+		// Move the inline buffer lines to segment files right away; the old
+		// single-file snapshot stays behind as the backup.
+		if err := s.save(userID, snapshot); err != nil && s.log != nil {
+			s.log.Warn("state save after migration failed", "user", userID, "err", err)
+		}
+	}
+	return snapshot, true, nil
+}
+
+// decode decodes a snapshot read from path and fills in buffer lines from
+// the segment files of snapshots that keep them there.
+func (s *Store) decode(userID schema.UserID, path string, data []byte) (UserSnapshot, int, error) {
+	snapshot, from, err := decodeSnapshot(data)
+	if err != nil {
+		return snapshot, from, err
+	}
+	dir := segmentDirFor(path)
+	inline := from < segmentsVersion
+	s.loadBuffer(userID, filepath.Join(dir, systemSegment+segmentExt), &snapshot.System, inline)
+	for i := range snapshot.Tabs {
+		tab := &snapshot.Tabs[i]
+		s.loadBuffer(userID, filepath.Join(dir, tabSegmentName(tab.ID)), &tab.Buffer, inline)
+	}
+	for i := range snapshot.ClosedTabs {
+		tab := &snapshot.ClosedTabs[i].Tab
+		s.loadBuffer(userID, filepath.Join(dir, tabSegmentName(tab.ID)), &tab.Buffer, inline)
+	}
+	return snapshot, from, nil
+}
+
+// loadBuffer fills buf from the segment at path. A segment whose last
+// record was cut short is truncated to its last complete record; an
+// unreadable segment leaves the buffer empty and is rewritten by the next
+// save. Buffers from snapshots that predate segments keep their inline
+// lines.
+func (s *Store) loadBuffer(userID schema.UserID, path string, buf *BufferSnapshot, inline bool) {
+	if inline {
+		buf.Seq = max(buf.Seq, int64(len(buf.Lines)))
+		return
+	}
+	buf.Lines = nil
+	seg, err := readSegment(path)
+	if err != nil {
+		delete(s.segments, path)
+		if !errors.Is(err, os.ErrNotExist) && s.log != nil {
+			s.log.Warn("state segment unreadable", "user", userID, "path", path, "err", err)
+		}
+		buf.Seq = 0
+		return
+	}
+	if seg.torn() {
+		if s.log != nil {
+			s.log.Warn("state segment torn", "user", userID, "path", path, "dropped_bytes", seg.size-seg.valid)
+		}
+		if err := os.Truncate(path, seg.valid); err != nil {
+			delete(s.segments, path)
+			if s.log != nil {
+				s.log.Warn("state segment repair failed", "user", userID, "path", path, "err", err)
+			}
+		} else {
+			s.segments[path] = seg.segmentState
+		}
+	} else {
+		s.segments[path] = seg.segmentState
+	}
+	lines := seg.lines
+	if len(lines) > s.maxLines {
+		lines = lines[len(lines)-s.maxLines:]
+	}
+	buf.Lines = lines
+	buf.Seq = seg.end()
+}
+
+// recover moves the corrupt state file at path aside and restores the
+// snapshot from its backup. The restored snapshot is saved right away so the
+// recovery notice survives a restart.
+func (s *Store) recover(userID schema.UserID, path string, cause error) (UserSnapshot, bool, error) {
+	corrupt := &CorruptStateError{Path: path, Err: cause}
+	moved := corruptPath(path, s.now())
+	if err := os.Rename(path, moved); err != nil {
+		if s.log != nil {
+			s.log.Error("state corrupt and could not be moved aside", "user", userID, "err", cause, "move_err", err)
+		}
+		return UserSnapshot{}, false, corrupt
+	}
+	corrupt.MovedTo = moved
+	backup := backupPath(path)
+	info, err := os.Stat(backup)
+	if err != nil {
+		if s.log != nil {
+			s.log.Error("state corrupt without backup", "user", userID, "err", cause, "moved_to", moved)
+		}
+		return UserSnapshot{}, false, corrupt
+	}
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		if s.log != nil {
+			s.log.Error("state backup read failed", "user", userID, "err", err, "moved_to", moved)
+		}
+		return UserSnapshot{}, false, corrupt
+	}
+	snapshot, _, err := s.decode(userID, path, data)
+	if err != nil {
+		if s.log != nil {
+			s.log.Error("state backup corrupt", "user", userID, "err", err, "moved_to", moved)
+		}
+		return UserSnapshot{}, false, corrupt
+	}
+	snapshot.Recovery = &Recovery{BackupTakenAt: info.ModTime().UTC(), CorruptPath: moved}
+	if s.log != nil {
+		s.log.Warn("state recovered from backup", "user", userID, "err", cause, "moved_to", moved, "backup_taken_at", snapshot.Recovery.BackupTakenAt)
+	}
+	if err := s.save(userID, snapshot); err != nil && s.log != nil {
+		s.log.Warn("state save after recovery failed", "user", userID, "err", err)
+	}
 	return snapshot, true, nil
 }
 
-// Save writes a user snapshot to disk.
+// Save writes a user snapshot to disk. Buffer lines are appended to the
+// buffers' segment files; the rest of the snapshot is written atomically as
+// a small metadata file.
 func (s *Store) Save(userID schema.UserID, snapshot UserSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(userID, snapshot)
+}
+
+func (s *Store) save(userID schema.UserID, snapshot UserSnapshot) error {
 	path := s.pathForUser(userID)
-	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+	if err := s.saveSegments(path, snapshot); err != nil {
 		if s.log != nil {
 			s.log.Warn("state save failed", "user", userID, "err", err)
 		}
 		return err
 	}
+	snapshot = withoutBufferLines(snapshot)
+	snapshot.Version = CurrentVersion
 	data, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
 		if s.log != nil {
@@ -108,63 +401,224 @@ func (s *Store) Save(userID schema.UserID, snapshot UserSnapshot) error {
 		}
 		return err
 	}
-	tmp, err := os.CreateTemp(filepath.Dir(path), "state-*.json")
-	if err != nil {
+	if err := s.writeMetadata(path, data); err != nil {
 		if s.log != nil {
 			s.log.Warn("state save failed", "user", userID, "err", err)
 		}
 		return err
 	}
+	s.written += int64(len(data))
+	s.removeStaleSegments(userID, path, snapshot)
+	if s.log != nil {
+		s.log.Trace("state save ok", "user", userID, "tabs", len(snapshot.Tabs))
+	}
+	return nil
+}
+
+// writeMetadata writes data to a temp file and renames it over path, keeping
+// the previous file as the backup Load recovers from.
+func (s *Store) writeMetadata(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "state-*.json")
+	if err != nil {
+		return err
+	}
 	if _, err := tmp.Write(data); err != nil {
 		_ = tmp.Close()
 		_ = os.Remove(tmp.Name())
-		if s.log != nil {
-			s.log.Warn("state save failed", "user", userID, "err", err)
-		}
 		return err
 	}
 	if err := tmp.Sync(); err != nil {
 		_ = tmp.Close()
 		_ = os.Remove(tmp.Name())
-		if s.log != nil {
-			s.log.Warn("state save failed", "user", userID, "err", err)
-		}
 		return err
 	}
 	if err := tmp.Close(); err != nil {
 		_ = os.Remove(tmp.Name())
-		if s.log != nil {
-			s.log.Warn("state save failed", "user", userID, "err", err)
-		}
 		return err
 	}
 	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
 		_ = os.Remove(tmp.Name())
-		if s.log != nil {
-			s.log.Warn("state save failed", "user", userID, "err", err)
-		}
+		return err
+	}
+	if err := os.Rename(path, backupPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		_ = os.Remove(tmp.Name())
 		return err
 	}
 	if err := os.Rename(tmp.Name(), path); err != nil {
-		if s.log != nil {
-			s.log.Warn("state save failed", "user", userID, "err", err)
-		}
+		_ = os.Remove(tmp.Name())
 		return err
 	}
-	if s.log != nil {
-		s.log.Trace("state save ok", "user", userID, "tabs", len(snapshot.Tabs))
+	return nil
+}
+
+// saveSegments brings the segment file of every buffer in snapshot up to
+// date. Segments are written before the metadata, so a crash in between
+// leaves lines the metadata does not know about yet rather than the other
+// way around.
+func (s *Store) saveSegments(path string, snapshot UserSnapshot) error {
+	dir := segmentDirFor(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := s.saveSegment(filepath.Join(dir, systemSegment+segmentExt), snapshot.System); err != nil {
+		return err
+	}
+	for _, tab := range snapshot.Tabs {
+		if err := s.saveSegment(filepath.Join(dir, tabSegmentName(tab.ID)), tab.Buffer); err != nil {
+			return err
+		}
+	}
+	for _, entry := range snapshot.ClosedTabs {
+		if err := s.saveSegment(filepath.Join(dir, tabSegmentName(entry.Tab.ID)), entry.Tab.Buffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSegment appends the lines of buf past the end of the segment at path.
+// The segment is rewritten with just buf's lines when it is missing or
+// unreadable, when buf does not continue it (the buffer was replaced, or
+// more lines were trimmed than it holds), or when compaction is due.
+func (s *Store) saveSegment(path string, buf BufferSnapshot) error {
+	lines := buf.Lines
+	seq := max(buf.Seq, int64(len(lines)))
+	state, ok := s.segments[path]
+	if !ok {
+		seg, err := readSegment(path)
+		switch {
+		case err == nil && !seg.torn():
+			state, ok = seg.segmentState, true
+		case err != nil && !errors.Is(err, os.ErrNotExist) && s.log != nil:
+			s.log.Warn("state segment rewritten", "path", path, "err", err)
+		}
+		if !ok && seq == 0 && errors.Is(err, os.ErrNotExist) {
+			// Nothing to keep for an empty buffer that was never written.
+			return nil
+		}
+	}
+	added := seq - state.end()
+	dead := state.count + added - int64(len(lines))
+	if ok && added >= 0 && added <= int64(len(lines)) && dead <= int64(s.maxLines) {
+		if added == 0 {
+			return nil
+		}
+		n, err := appendSegmentFile(path, lines[int64(len(lines))-added:])
+		if err != nil {
+			// The file may now end in a partial record; read it again
+			// before the next append.
+			delete(s.segments, path)
+			return err
+		}
+		s.written += n
+		state.count += added
+		s.segments[path] = state
+		return nil
+	}
+	base := seq - int64(len(lines))
+	n, err := writeSegmentFile(path, base, lines)
+	if err != nil {
+		delete(s.segments, path)
+		return err
 	}
+	s.written += n
+	s.segments[path] = segmentState{base: base, count: int64(len(lines))}
 	return nil
 }
 
+// removeStaleSegments deletes segment files of tabs no longer in snapshot,
+// open or closed.
+func (s *Store) removeStaleSegments(userID schema.UserID, path string, snapshot UserSnapshot) {
+	dir := segmentDirFor(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	live := map[string]bool{systemSegment + segmentExt: true}
+	for _, tab := range snapshot.Tabs {
+		live[tabSegmentName(tab.ID)] = true
+	}
+	for _, entry := range snapshot.ClosedTabs {
+		live[tabSegmentName(entry.Tab.ID)] = true
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || live[name] || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		stale := filepath.Join(dir, name)
+		if err := os.Remove(stale); err != nil {
+			if s.log != nil {
+				s.log.Warn("state segment remove failed", "user", userID, "path", stale, "err", err)
+			}
+			continue
+		}
+		delete(s.segments, stale)
+	}
+}
+
+// withoutBufferLines returns snapshot with the buffer lines dropped, leaving
+// the caller's tab slices untouched.
+func withoutBufferLines(snapshot UserSnapshot) UserSnapshot {
+	snapshot.System.Lines = nil
+	if snapshot.Tabs != nil {
+		tabs := make([]TabSnapshot, len(snapshot.Tabs))
+		for i, tab := range snapshot.Tabs {
+			tab.Buffer.Lines = nil
+			tabs[i] = tab
+		}
+		snapshot.Tabs = tabs
+	}
+	if snapshot.ClosedTabs != nil {
+		closed := make([]ClosedTabSnapshot, len(snapshot.ClosedTabs))
+		for i, entry := range snapshot.ClosedTabs {
+			entry.Tab.Buffer.Lines = nil
+			closed[i] = entry
+		}
+		snapshot.ClosedTabs = closed
+	}
+	return snapshot
+}
+
 func (s *Store) pathForUser(userID schema.UserID) string {
+	return userStatePath(s.dir, userID)
+}
+
+// UserStatePaths are the files and directories holding the state of a user.
+type UserStatePaths struct {
+	// Snapshot is the snapshot file.
+	Snapshot string
+	// Backup is the previous snapshot file Load recovers from.
+	Backup string
+	// Segments is the directory of buffer segments.
+	Segments string
+}
+
+// UserPaths returns where the state of userID lives under dir.
+func UserPaths(dir string, userID schema.UserID) UserStatePaths {
+	snapshot := userStatePath(dir, userID)
+	return UserStatePaths{Snapshot: snapshot, Backup: backupPath(snapshot), Segments: segmentDirFor(snapshot)}
+}
+
+func userStatePath(dir string, userID schema.UserID) string {
 	name := sanitize(string(userID))
 	if name == "" {
 		name = "unknown"
 	}
-	return filepath.Join(s.dir, name+".json")
+	return filepath.Join(dir, name+".json")
 }
 
+func backupPath(path string) string {
+	return path + ".bak"
+}
+
+// corruptPath names the file a corrupt state file at path is moved to.
+func corruptPath(path string, now time.Time) string {
+	return path + corruptSuffix + now.UTC().Format("20060102T150405Z")
+}
+
+const corruptSuffix = ".corrupt-"
+
 func sanitize(value string) string {
 	var b strings.Builder
 	for _, r := range value {
@@ -1,10 +1,14 @@
 package persist
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"pkt.systems/centaurx/schema"
 )
@@ -31,7 +35,8 @@ func TestStoreSaveLoadRoundTrip(t *testing.T) {
 		t.Fatalf("new store: %v", err)
 	}
 	snapshot := UserSnapshot{
-		Order: []schema.TabID{"tab1"},
+		Version: CurrentVersion,
+		Order:   []schema.TabID{"tab1"},
 		Tabs: []TabSnapshot{
 			{
 				ID:        "tab1",
@@ -40,15 +45,20 @@ func TestStoreSaveLoadRoundTrip(t *testing.T) {
 				Model:     "gpt-5.2-codex",
 				SessionID: "sess-1",
 				Buffer: BufferSnapshot{
-					Lines:        []string{"hi"},
+					Lines: []schema.BufferLine{
+						{Kind: schema.LineKindPrompt, Text: "cmd", Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+						{Kind: schema.LineKindAgent, Text: "hi"},
+					},
 					ScrollOffset: 0,
+					Seq:          2,
 				},
-				History: []string{"cmd"},
+				History: []HistoryEntry{{Text: "cmd", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}},
 			},
 		},
 		System: BufferSnapshot{
-			Lines:        []string{"system"},
+			Lines:        []schema.BufferLine{{Kind: schema.LineKindSystem, Text: "system"}},
 			ScrollOffset: 1,
+			Seq:          1,
 		},
 		Theme: "outrun",
 	}
@@ -77,7 +87,278 @@ func TestStoreLoadInvalidJSON(t *testing.T) {
 	if err := os.WriteFile(path, []byte("{not-json"), 0o600); err != nil {
 		t.Fatalf("write bad json: %v", err)
 	}
-	if _, _, err := store.Load("alice"); err == nil {
-		t.Fatalf("expected error for invalid JSON")
+	_, _, err = store.Load("alice")
+	var corrupt *CorruptStateError
+	if !errors.As(err, &corrupt) {
+		t.Fatalf("expected corrupt state error for invalid JSON, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected corrupt file moved aside, got %v", err)
+	}
+	if data, err := os.ReadFile(corrupt.MovedTo); err != nil || string(data) != "{not-json" {
+		t.Fatalf("expected corrupt file kept at %q, got %q err=%v", corrupt.MovedTo, data, err)
+	}
+}
+
+func TestStoreLoadRecoversTruncatedStateFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	store.now = func() time.Time { return time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC) }
+	for _, theme := range []schema.ThemeName{"outrun", "gruvbox"} {
+		if err := store.Save("alice", UserSnapshot{Theme: theme}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	path := filepath.Join(dir, "alice.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/2], 0o600); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	backupTime := time.Date(2026, time.March, 1, 11, 58, 0, 0, time.UTC)
+	if err := os.Chtimes(path+".bak", backupTime, backupTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	got, ok, err := store.Load("alice")
+	if err != nil || !ok {
+		t.Fatalf("load: ok=%v err=%v", ok, err)
+	}
+	if got.Theme != "outrun" {
+		t.Fatalf("expected backup snapshot, got %+v", got)
+	}
+	moved := path + ".corrupt-20260301T120000Z"
+	want := &Recovery{BackupTakenAt: backupTime, CorruptPath: moved}
+	if !reflect.DeepEqual(got.Recovery, want) {
+		t.Fatalf("expected recovery %+v, got %+v", want, got.Recovery)
+	}
+	if corrupt, err := os.ReadFile(moved); err != nil || len(corrupt) != len(data)/2 {
+		t.Fatalf("expected truncated file kept at %s, err=%v", moved, err)
+	}
+
+	// The recovered snapshot is saved, so the notice survives a restart.
+	again, _, err := store.Load("alice")
+	if err != nil || again.Theme != "outrun" || !reflect.DeepEqual(again.Recovery, want) {
+		t.Fatalf("expected persisted recovery, got %+v err=%v", again, err)
+	}
+}
+
+func TestStoreLoadTruncatedStateWithTruncatedBackup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	path := filepath.Join(dir, "alice.json")
+	for _, name := range []string{path, path + ".bak"} {
+		if err := os.WriteFile(name, []byte(`{"version": 3, "tabs": [{"id": "t`), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	_, ok, err := store.Load("alice")
+	var corrupt *CorruptStateError
+	if ok || !errors.As(err, &corrupt) || corrupt.MovedTo == "" {
+		t.Fatalf("expected corrupt state error, got ok=%v err=%v", ok, err)
+	}
+	if _, err := os.Stat(path + ".bak"); err != nil {
+		t.Fatalf("expected backup left in place: %v", err)
+	}
+}
+
+func TestStoreLoadHistoricalVersions(t *testing.T) {
+	want := UserSnapshot{
+		Version: CurrentVersion,
+		Order:   []schema.TabID{"tab1"},
+		Tabs: []TabSnapshot{
+			{
+				ID:        "tab1",
+				Name:      "demo",
+				Repo:      schema.RepoRef{Name: "demo"},
+				Model:     "gpt-5.2-codex",
+				SessionID: "sess-1",
+				Buffer:    BufferSnapshot{Lines: []schema.BufferLine{{Kind: schema.LineKindSystem, Text: "hi"}}, Seq: 1},
+				History:   []HistoryEntry{{Text: "first"}, {Text: "second"}},
+			},
+		},
+		System:        BufferSnapshot{Lines: []schema.BufferLine{{Kind: schema.LineKindSystem, Text: "system"}}, Seq: 1},
+		Theme:         "outrun",
+		GlobalHistory: []HistoryEntry{{Text: "first"}, {Text: "second"}},
+	}
+	withTimes := want
+	withTimes.Tabs = append([]TabSnapshot(nil), want.Tabs...)
+	withTimes.Tabs[0].History = []HistoryEntry{
+		{Text: "first", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Text: "second", Time: time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC)},
+	}
+	withTimes.GlobalHistory = withTimes.Tabs[0].History
+	tests := []struct {
+		fixture string
+		want    UserSnapshot
+	}{
+		{fixture: "v0.json", want: want},
+		{fixture: "v1.json", want: withTimes},
+		{fixture: "v2.json", want: withTimes},
+		{fixture: "v3.json", want: withTimes},
+	}
+	for _, tc := range tests {
+		t.Run(tc.fixture, func(t *testing.T) {
+			dir := t.TempDir()
+			data, err := os.ReadFile(filepath.Join("testdata", tc.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "alice.json"), data, 0o600); err != nil {
+				t.Fatalf("write fixture: %v", err)
+			}
+			store, err := NewStore(dir)
+			if err != nil {
+				t.Fatalf("new store: %v", err)
+			}
+			got, ok, err := store.Load("alice")
+			if err != nil || !ok {
+				t.Fatalf("load: ok=%v err=%v", ok, err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("snapshot mismatch:\nwant: %+v\ngot:  %+v", tc.want, got)
+			}
+			if err := store.Save("alice", got); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+			saved, err := os.ReadFile(filepath.Join(dir, "alice.json"))
+			if err != nil {
+				t.Fatalf("read saved: %v", err)
+			}
+			if !strings.Contains(string(saved), fmt.Sprintf(`"version": %d`, CurrentVersion)) {
+				t.Fatalf("expected saved snapshot at latest version, got:\n%s", saved)
+			}
+		})
+	}
+}
+
+func TestStoreLoadRejectsNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "alice.json"), []byte(`{"version": 99}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, _, err := store.Load("alice"); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("expected newer version error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alice.json")); err != nil {
+		t.Fatalf("expected newer state file left in place: %v", err)
+	}
+}
+
+func TestStoreSaveKeepsPreviousSnapshotAsBackup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	for _, theme := range []schema.ThemeName{"outrun", "gruvbox"} {
+		if err := store.Save("alice", UserSnapshot{Theme: theme}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	backup, err := os.ReadFile(filepath.Join(dir, "alice.json.bak"))
+	if err != nil || !strings.Contains(string(backup), `"theme": "outrun"`) {
+		t.Fatalf("expected previous snapshot as backup, got %q err=%v", backup, err)
+	}
+	got, _, err := store.Load("alice")
+	if err != nil || got.Theme != "gruvbox" {
+		t.Fatalf("expected latest snapshot, got %+v err=%v", got, err)
+	}
+}
+
+func TestStoreLoadFallsBackToBackup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	// Simulate a save interrupted after the previous file was moved aside.
+	if err := os.WriteFile(filepath.Join(dir, "alice.json.bak"), []byte(`{"version": 1, "theme": "outrun"}`), 0o600); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+	got, ok, err := store.Load("alice")
+	if err != nil || !ok {
+		t.Fatalf("load: ok=%v err=%v", ok, err)
+	}
+	if got.Theme != "outrun" {
+		t.Fatalf("expected backup snapshot, got %+v", got)
+	}
+}
+
+func TestMigrateV1GlobalHistoryOrdersByTime(t *testing.T) {
+	data := []byte(`{"version": 1, "tabs": [
+		{"id": "a", "history": [{"text": "a1", "time": "2026-01-01T00:00:01Z"}, {"text": "same", "time": "2026-01-01T00:00:03Z"}]},
+		{"id": "b", "history": [{"text": "legacy"}, {"text": "b1", "time": "2026-01-01T00:00:02Z"}, {"text": "same", "time": "2026-01-01T00:00:04Z"}]}
+	]}`)
+	snapshot, from, err := decodeSnapshot(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if from != 1 {
+		t.Fatalf("expected source version 1, got %d", from)
+	}
+	var got []string
+	for _, entry := range snapshot.GlobalHistory {
+		got = append(got, entry.Text)
+	}
+	want := []string{"legacy", "a1", "b1", "same"}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("global history mismatch: want %v got %v", want, got)
+	}
+}
+
+func TestMigrateV2BufferLinesClassifiesMarkers(t *testing.T) {
+	data := []byte(`{"version": 2, "tabs": [
+		{"id": "a", "buffer": {"lines": ["> fix it", "\u001cdone", "\u001eWorked for 2s", "error: boom", "plain"]}}
+	], "system": {"lines": ["\u0016# Help"]}}`)
+	snapshot, from, err := decodeSnapshot(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if from != 2 {
+		t.Fatalf("expected source version 2, got %d", from)
+	}
+	want := []schema.BufferLine{
+		{Kind: schema.LineKindPrompt, Text: "fix it"},
+		{Kind: schema.LineKindAgent, Text: "done"},
+		{Kind: schema.LineKindSeparator, Text: "Worked for 2s"},
+		{Kind: schema.LineKindError, Text: "error: boom"},
+		{Kind: schema.LineKindSystem, Text: "plain"},
+	}
+	if !reflect.DeepEqual(want, snapshot.Tabs[0].Buffer.Lines) {
+		t.Fatalf("buffer lines mismatch:\nwant: %+v\ngot:  %+v", want, snapshot.Tabs[0].Buffer.Lines)
+	}
+	wantSystem := []schema.BufferLine{{Kind: schema.LineKindHelp, Text: "# Help"}}
+	if !reflect.DeepEqual(wantSystem, snapshot.System.Lines) {
+		t.Fatalf("system lines mismatch:\nwant: %+v\ngot:  %+v", wantSystem, snapshot.System.Lines)
+	}
+}
+
+func TestProbeWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := ProbeWritable(dir); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected probe file to be removed, found %d entries", len(entries))
+	}
+	if err := ProbeWritable(filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("expected error for missing directory")
 	}
 }
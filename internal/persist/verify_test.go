@@ -0,0 +1,55 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyDir(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	for range 2 {
+		if err := store.Save("alice", UserSnapshot{Tabs: []TabSnapshot{{ID: "a"}}}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	files := map[string]string{
+		"bob.json":                            `{"version": 3, "tabs": [{"id": "b", "buf`,
+		"carol.json.corrupt-20260301T120000Z": `{`,
+		"users.json":                          `[]`,
+		"notes.txt":                           `ignored`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	results, err := VerifyDir(dir, filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	got := make(map[string]VerifyResult, len(results))
+	for _, result := range results {
+		got[filepath.Base(result.Path)] = result
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 results, got %+v", results)
+	}
+	if r := got["alice.json"]; r.Err != nil || r.Version != CurrentVersion || r.Tabs != 1 || r.Backup {
+		t.Fatalf("unexpected alice result: %+v", r)
+	}
+	if r := got["alice.json.bak"]; r.Err != nil || !r.Backup {
+		t.Fatalf("unexpected backup result: %+v", r)
+	}
+	if r := got["bob.json"]; r.Err == nil {
+		t.Fatalf("expected truncated bob.json to fail, got %+v", r)
+	}
+	if r := got["carol.json.corrupt-20260301T120000Z"]; !r.Quarantined || r.Err != nil {
+		t.Fatalf("expected quarantined file to be listed only, got %+v", r)
+	}
+}
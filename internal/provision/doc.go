@@ -0,0 +1,3 @@
+// Package provision creates accounts on first SSH login for users whose
+// public key a trusted source vouches for.
+package provision
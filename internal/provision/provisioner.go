@@ -0,0 +1,131 @@
+package provision
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/sshkeys"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+const (
+	passwordLength = 20
+	totpIssuer     = "centaurx"
+)
+
+// UserStore creates and removes user records.
+type UserStore interface {
+	AddUser(user auth.User) error
+	DeleteUser(username string) error
+}
+
+// GitKeyStore creates and removes git SSH keys.
+type GitKeyStore interface {
+	GenerateKey(username, keyType string, bits int) (string, error)
+	RemoveKey(username string) error
+}
+
+// Provisioner creates the account of an unknown user on first login.
+type Provisioner struct {
+	source     KeySource
+	users      UserStore
+	gitKeys    GitKeyStore
+	ensureHome func(username string) error
+	log        pslog.Logger
+}
+
+// NewProvisioner returns a provisioner trusting source. ensureHome, when
+// set, creates the home and state directories of a new user.
+func NewProvisioner(source KeySource, users UserStore, gitKeys GitKeyStore, ensureHome func(username string) error, logger pslog.Logger) (*Provisioner, error) {
+	if source == nil {
+		return nil, errors.New("key source is required")
+	}
+	if users == nil || gitKeys == nil {
+		return nil, errors.New("user and git key stores are required")
+	}
+	return &Provisioner{source: source, users: users, gitKeys: gitKeys, ensureHome: ensureHome, log: logger}, nil
+}
+
+// Trusted reports whether the key source vouches for key as a key of
+// username.
+func (p *Provisioner) Trusted(ctx context.Context, username string, key ssh.PublicKey) (bool, error) {
+	if err := schema.ValidateUserID(schema.UserID(username)); err != nil {
+		return false, err
+	}
+	return Trusted(ctx, p.source, username, key)
+}
+
+// Provision creates username with key as its login key, a git SSH key and a
+// home directory, undoing what it did when a step fails. It returns the
+// welcome lines to show the user once; they carry the generated password and
+// TOTP secret, so they must not be stored.
+func (p *Provisioner) Provision(ctx context.Context, username string, key ssh.PublicKey) ([]string, error) {
+	if err := schema.ValidateUserID(schema.UserID(username)); err != nil {
+		return nil, err
+	}
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	otpKey, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: username})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.users.AddUser(auth.User{
+		Username:     username,
+		PasswordHash: string(hash),
+		TOTPSecret:   otpKey.Secret(),
+		LoginPubKeys: []string{strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))},
+	}); err != nil {
+		return nil, fmt.Errorf("add user: %w", err)
+	}
+	gitKey, err := p.gitKeys.GenerateKey(username, sshkeys.KeyTypeEd25519, 0)
+	if err != nil {
+		_ = p.users.DeleteUser(username)
+		return nil, fmt.Errorf("git ssh key: %w", err)
+	}
+	if p.ensureHome != nil {
+		if err := p.ensureHome(username); err != nil {
+			_ = p.gitKeys.RemoveKey(username)
+			_ = p.users.DeleteUser(username)
+			return nil, fmt.Errorf("home: %w", err)
+		}
+	}
+	if p.log != nil {
+		p.log.Info("user provisioned", "user", username, "source", p.source.Name(), "fingerprint", ssh.FingerprintSHA256(key))
+	}
+	return []string{
+		fmt.Sprintf("welcome to centaurx, %s: your account was created from your key in %s", username, p.source.Name()),
+		"later SSH logins ask for a verification code from this TOTP secret:",
+		"  totp secret: " + otpKey.Secret(),
+		"  otpauth url: " + otpKey.URL(),
+		"web login password: " + password + " (change it with /chpasswd)",
+		"git ssh public key, add it to your git host: " + strings.TrimSpace(gitKey),
+		"type /help to list the commands",
+	}, nil
+}
+
+func generatePassword() (string, error) {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	buf := make([]byte, passwordLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = charset[int(b)%len(charset)]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,125 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/internal/auth"
+)
+
+type fakeUsers struct {
+	users map[string]auth.User
+}
+
+func (f *fakeUsers) AddUser(user auth.User) error {
+	if _, ok := f.users[user.Username]; ok {
+		return errors.New("user already exists")
+	}
+	f.users[user.Username] = user
+	return nil
+}
+
+func (f *fakeUsers) DeleteUser(username string) error {
+	delete(f.users, username)
+	return nil
+}
+
+type fakeGitKeys struct {
+	keys map[string]bool
+	err  error
+}
+
+func (f *fakeGitKeys) GenerateKey(username, _ string, _ int) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.keys[username] = true
+	return "ssh-ed25519 AAAA git@" + username, nil
+}
+
+func (f *fakeGitKeys) RemoveKey(username string) error {
+	delete(f.keys, username)
+	return nil
+}
+
+type staticSource map[string][]ssh.PublicKey
+
+func (s staticSource) Name() string { return "test keys" }
+
+func (s staticSource) Keys(_ context.Context, username string) ([]ssh.PublicKey, error) {
+	return s[username], nil
+}
+
+func TestProvisionCreatesUser(t *testing.T) {
+	key := newTestKey(t)
+	users := &fakeUsers{users: map[string]auth.User{}}
+	gitKeys := &fakeGitKeys{keys: map[string]bool{}}
+	var homes []string
+	p, err := NewProvisioner(staticSource{"alice": {key}}, users, gitKeys, func(username string) error {
+		homes = append(homes, username)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provisioner: %v", err)
+	}
+	ctx := context.Background()
+	if ok, err := p.Trusted(ctx, "alice", key); err != nil || !ok {
+		t.Fatalf("expected alice's key to be trusted, got %v, %v", ok, err)
+	}
+	if _, err := p.Trusted(ctx, "Not Valid", key); err == nil {
+		t.Fatalf("expected an invalid username to be rejected")
+	}
+
+	welcome, err := p.Provision(ctx, "alice", key)
+	if err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	user, ok := users.users["alice"]
+	if !ok || user.PasswordHash == "" || user.TOTPSecret == "" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if len(user.LoginPubKeys) != 1 || !strings.HasPrefix(user.LoginPubKeys[0], "ssh-ed25519 ") {
+		t.Fatalf("expected the login key to be stored, got %q", user.LoginPubKeys)
+	}
+	if !gitKeys.keys["alice"] || len(homes) != 1 {
+		t.Fatalf("expected a git key and home, got %v, %q", gitKeys.keys, homes)
+	}
+	text := strings.Join(welcome, "\n")
+	if !strings.Contains(text, "welcome to centaurx, alice") || !strings.Contains(text, user.TOTPSecret) || !strings.Contains(text, "/help") {
+		t.Fatalf("unexpected welcome %q", welcome)
+	}
+
+	if _, err := p.Provision(ctx, "alice", key); err == nil {
+		t.Fatalf("expected an existing user not to be provisioned again")
+	}
+}
+
+func TestProvisionRollsBackOnFailure(t *testing.T) {
+	key := newTestKey(t)
+	users := &fakeUsers{users: map[string]auth.User{}}
+	gitKeys := &fakeGitKeys{keys: map[string]bool{}}
+	p, err := NewProvisioner(staticSource{}, users, gitKeys, func(string) error {
+		return errors.New("disk full")
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provisioner: %v", err)
+	}
+	if _, err := p.Provision(context.Background(), "alice", key); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected the home failure, got %v", err)
+	}
+	if len(users.users) != 0 || len(gitKeys.keys) != 0 {
+		t.Fatalf("expected a rollback, got users %v and keys %v", users.users, gitKeys.keys)
+	}
+
+	gitKeys.err = errors.New("key exists")
+	if _, err := p.Provision(context.Background(), "alice", key); err == nil {
+		t.Fatalf("expected the git key failure")
+	}
+	if len(users.users) != 0 {
+		t.Fatalf("expected the user to be removed, got %v", users.users)
+	}
+}
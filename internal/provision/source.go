@@ -0,0 +1,243 @@
+package provision
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Source defaults used for zero SourceConfig values.
+const (
+	DefaultRefresh       = 15 * time.Minute
+	defaultSourceTimeout = 15 * time.Second
+	defaultGitHubAPIURL  = "https://api.github.com"
+	defaultGitHubURL     = "https://github.com"
+	maxKeysBody          = 4 << 20
+)
+
+// KeySource lists the public keys a trusted source vouches for.
+type KeySource interface {
+	// Name describes the source in logs and welcome messages.
+	Name() string
+	// Keys returns the keys of username, or none when the source does not
+	// know the user.
+	Keys(ctx context.Context, username string) ([]ssh.PublicKey, error)
+}
+
+// SourceConfig selects the trusted key source; exactly one of GitHubOrg and
+// AuthorizedKeysURL is set.
+type SourceConfig struct {
+	// GitHubOrg trusts the keys GitHub publishes for members of the
+	// organization.
+	GitHubOrg string
+	// GitHubToken, when set, authenticates the membership check so private
+	// members count as well.
+	GitHubToken string
+	// AuthorizedKeysURL serves authorized_keys lines whose comment names
+	// the user, as "alice" or "alice@laptop".
+	AuthorizedKeysURL string
+	// Refresh is how long a fetched authorized keys list is used.
+	Refresh time.Duration
+	// HTTPClient defaults to a client with a 15 second timeout.
+	HTTPClient *http.Client
+}
+
+// NewSource returns the key source cfg selects.
+func NewSource(cfg SourceConfig) (KeySource, error) {
+	org := strings.TrimSpace(cfg.GitHubOrg)
+	keysURL := strings.TrimSpace(cfg.AuthorizedKeysURL)
+	if (org == "") == (keysURL == "") {
+		return nil, errors.New("exactly one of github org and authorized keys url is required")
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultSourceTimeout}
+	}
+	if org != "" {
+		return &gitHubSource{
+			org:    org,
+			token:  cfg.GitHubToken,
+			apiURL: defaultGitHubAPIURL,
+			webURL: defaultGitHubURL,
+			client: client,
+		}, nil
+	}
+	if _, err := url.ParseRequestURI(keysURL); err != nil {
+		return nil, fmt.Errorf("authorized keys url: %w", err)
+	}
+	refresh := cfg.Refresh
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	return &authorizedKeysSource{url: keysURL, refresh: refresh, client: client, now: time.Now}, nil
+}
+
+// Trusted reports whether source vouches for key as a key of username.
+func Trusted(ctx context.Context, source KeySource, username string, key ssh.PublicKey) (bool, error) {
+	keys, err := source.Keys(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	want := key.Marshal()
+	for _, candidate := range keys {
+		if bytes.Equal(candidate.Marshal(), want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// gitHubSource trusts the published keys of organization members.
+type gitHubSource struct {
+	org    string
+	token  string
+	apiURL string
+	webURL string
+	client *http.Client
+}
+
+func (s *gitHubSource) Name() string {
+	return "GitHub organization " + s.org
+}
+
+func (s *gitHubSource) Keys(ctx context.Context, username string) ([]ssh.PublicKey, error) {
+	member, err := s.isMember(ctx, username)
+	if err != nil || !member {
+		return nil, err
+	}
+	body, err := get(ctx, s.client, s.webURL+"/"+url.PathEscape(username)+".keys")
+	if err != nil {
+		return nil, fmt.Errorf("github keys: %w", err)
+	}
+	keys, _ := parseAuthorizedKeys(body)
+	return keys, nil
+}
+
+// isMember checks membership through the API; without a token GitHub only
+// reports public members.
+func (s *gitHubSource) isMember(ctx context.Context, username string) (bool, error) {
+	target := fmt.Sprintf("%s/orgs/%s/members/%s", s.apiURL, url.PathEscape(s.org), url.PathEscape(username))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	client := *s.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("github membership: %w", err)
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound, http.StatusFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("github membership: unexpected status %s", resp.Status)
+	}
+}
+
+// authorizedKeysSource trusts a shared authorized_keys list and refetches it
+// once it is older than refresh.
+type authorizedKeysSource struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string][]ssh.PublicKey
+	fetched time.Time
+}
+
+func (s *authorizedKeysSource) Name() string {
+	return "authorized keys at " + s.url
+}
+
+func (s *authorizedKeysSource) Keys(ctx context.Context, username string) ([]ssh.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil || s.now().Sub(s.fetched) >= s.refresh {
+		if err := s.fetchLocked(ctx); err != nil {
+			if s.keys == nil {
+				return nil, err
+			}
+			// Keep trusting the last list until the next refresh.
+			s.fetched = s.now()
+		}
+	}
+	return s.keys[username], nil
+}
+
+func (s *authorizedKeysSource) fetchLocked(ctx context.Context) error {
+	body, err := get(ctx, s.client, s.url)
+	if err != nil {
+		return fmt.Errorf("authorized keys: %w", err)
+	}
+	keys, comments := parseAuthorizedKeys(body)
+	byUser := make(map[string][]ssh.PublicKey)
+	for i, key := range keys {
+		user, _, _ := strings.Cut(comments[i], "@")
+		user = strings.ToLower(strings.TrimSpace(user))
+		if user == "" {
+			continue
+		}
+		byUser[user] = append(byUser[user], key)
+	}
+	s.keys = byUser
+	s.fetched = s.now()
+	return nil
+}
+
+// parseAuthorizedKeys returns the keys in data with their comments, skipping
+// lines that do not parse.
+func parseAuthorizedKeys(data []byte) ([]ssh.PublicKey, []string) {
+	var keys []ssh.PublicKey
+	var comments []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxKeysBody)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+		comments = append(comments, comment)
+	}
+	return keys, comments
+}
+
+func get(ctx context.Context, client *http.Client, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxKeysBody))
+}
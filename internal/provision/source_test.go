@@ -0,0 +1,147 @@
+package provision
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+	return key
+}
+
+func authorizedKey(key ssh.PublicKey, comment string) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + " " + comment + "\n"
+}
+
+func TestGitHubSourceTrustsOrgMembers(t *testing.T) {
+	alice := newTestKey(t)
+	other := newTestKey(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/acme/members/{user}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PathValue("user") == "alice" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/alice.keys", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(authorizedKey(alice, "")))
+	})
+	mux.HandleFunc("/mallory.keys", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(authorizedKey(other, "")))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	source, err := NewSource(SourceConfig{GitHubOrg: "acme", GitHubToken: "secret"})
+	if err != nil {
+		t.Fatalf("new source: %v", err)
+	}
+	github := source.(*gitHubSource)
+	github.apiURL, github.webURL = server.URL, server.URL
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		user string
+		key  ssh.PublicKey
+		want bool
+	}{
+		{"alice", alice, true},
+		{"alice", other, false},
+		{"mallory", other, false},
+	} {
+		got, err := Trusted(ctx, source, tc.user, tc.key)
+		if err != nil {
+			t.Fatalf("trusted %s: %v", tc.user, err)
+		}
+		if got != tc.want {
+			t.Fatalf("trusted %s = %v, want %v", tc.user, got, tc.want)
+		}
+	}
+
+	github.token = "wrong"
+	if _, err := Trusted(ctx, source, "alice", alice); err == nil {
+		t.Fatalf("expected an unexpected membership status to fail")
+	}
+}
+
+func TestAuthorizedKeysSourceMatchesCommentsAndRefreshes(t *testing.T) {
+	alice := newTestKey(t)
+	bob := newTestKey(t)
+	var body atomic.Value
+	body.Store("# team keys\n" + authorizedKey(alice, "alice@laptop") + "not a key\n")
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := NewSource(SourceConfig{AuthorizedKeysURL: server.URL, Refresh: time.Minute})
+	if err != nil {
+		t.Fatalf("new source: %v", err)
+	}
+	keys := source.(*authorizedKeysSource)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if ok, err := Trusted(ctx, source, "alice", alice); err != nil || !ok {
+		t.Fatalf("expected alice's key to be trusted, got %v, %v", ok, err)
+	}
+	if ok, _ := Trusted(ctx, source, "bob", bob); ok {
+		t.Fatalf("bob is not listed yet")
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("expected one fetch before the refresh, got %d", got)
+	}
+
+	body.Store(authorizedKey(bob, "Bob"))
+	now = now.Add(time.Minute)
+	if ok, err := Trusted(ctx, source, "bob", bob); err != nil || !ok {
+		t.Fatalf("expected bob's key after the refresh, got %v, %v", ok, err)
+	}
+	if ok, _ := Trusted(ctx, source, "alice", alice); ok {
+		t.Fatalf("alice was removed from the list")
+	}
+
+	server.Close()
+	now = now.Add(time.Minute)
+	if ok, err := Trusted(ctx, source, "bob", bob); err != nil || !ok {
+		t.Fatalf("expected the last list to be kept when a refresh fails, got %v, %v", ok, err)
+	}
+}
+
+func TestNewSourceRequiresExactlyOneSource(t *testing.T) {
+	for _, cfg := range []SourceConfig{
+		{},
+		{GitHubOrg: "acme", AuthorizedKeysURL: "https://keys.example.com"},
+		{AuthorizedKeysURL: "not a url"},
+	} {
+		if _, err := NewSource(cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// Describe reads metadata of the repo at path from the host file system.
+// The modified time is the newest of .git, .git/index and .git/logs/HEAD,
+// which change on commits, checkouts and staging. The size walks the whole
+// work tree and is only measured when withSize is set; otherwise
+// SizeBytes is -1.
+func Describe(path string, withSize bool) schema.RepoDetails {
+	details := schema.RepoDetails{SizeBytes: -1}
+	gitDir := filepath.Join(path, ".git")
+	for _, name := range []string{gitDir, filepath.Join(gitDir, "index"), filepath.Join(gitDir, "logs", "HEAD")} {
+		info, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(details.ModifiedAt) {
+			details.ModifiedAt = info.ModTime()
+		}
+	}
+	if withSize {
+		details.SizeBytes = diskSize(path)
+	}
+	return details
+}
+
+// diskSize sums the sizes of the regular files under root. Entries that
+// cannot be read are skipped.
+func diskSize(root string) int64 {
+	var total int64
+	_ = filepath.WalkDir(root, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,37 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".git", "logs"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), make([]byte, 1000), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	head := filepath.Join(root, ".git", "logs", "HEAD")
+	if err := os.WriteFile(head, make([]byte, 24), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	committed := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(head, committed, committed); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	details := Describe(root, false)
+	if !details.ModifiedAt.Equal(committed) {
+		t.Fatalf("expected modified at %v, got %v", committed, details.ModifiedAt)
+	}
+	if details.SizeBytes != -1 {
+		t.Fatalf("expected unmeasured size, got %d", details.SizeBytes)
+	}
+	if got := Describe(root, true).SizeBytes; got != 1024 {
+		t.Fatalf("expected 1024 bytes, got %d", got)
+	}
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"path"
 	"strings"
@@ -66,6 +67,74 @@ func NormalizeGitURL(raw string) (string, schema.RepoName, error) {
 	return "", "", schema.ErrInvalidRepo
 }
 
+// GitRemote is the ssh destination of a normalized clone URL.
+type GitRemote struct {
+	// User is the ssh login, such as "git"; it may be empty.
+	User string
+	// Host is the git host without port.
+	Host string
+	// Port is the ssh port; it defaults to 22.
+	Port string
+	// Path is the repo path on the host, such as "owner/repo.git".
+	Path string
+}
+
+// Destination returns the user@host form passed to ssh.
+func (r GitRemote) Destination() string {
+	if r.User == "" {
+		return r.Host
+	}
+	return r.User + "@" + r.Host
+}
+
+// Address returns the host, with the port when it is not 22.
+func (r GitRemote) Address() string {
+	if r.Port == "" || r.Port == "22" {
+		return r.Host
+	}
+	return net.JoinHostPort(r.Host, r.Port)
+}
+
+// ParseGitRemote splits a clone URL from NormalizeGitURL into its ssh
+// destination and repo path. Passwords in ssh:// URLs are dropped.
+func ParseGitRemote(cloneURL string) (GitRemote, error) {
+	input := strings.TrimSpace(cloneURL)
+	if strings.HasPrefix(strings.ToLower(input), "ssh://") {
+		parsed, err := url.Parse(input)
+		if err != nil {
+			return GitRemote{}, err
+		}
+		remote := GitRemote{
+			Host: parsed.Hostname(),
+			Port: parsed.Port(),
+			Path: strings.TrimPrefix(parsed.Path, "/"),
+		}
+		if parsed.User != nil {
+			remote.User = parsed.User.Username()
+		}
+		if remote.Port == "" {
+			remote.Port = "22"
+		}
+		if remote.Host == "" || remote.Path == "" {
+			return GitRemote{}, schema.ErrInvalidRepo
+		}
+		return remote, nil
+	}
+	dest, pathPart, ok := strings.Cut(input, ":")
+	if !ok || dest == "" || pathPart == "" {
+		return GitRemote{}, schema.ErrInvalidRepo
+	}
+	remote := GitRemote{Host: dest, Port: "22", Path: strings.TrimPrefix(pathPart, "/")}
+	if user, host, ok := strings.Cut(dest, "@"); ok {
+		remote.User = user
+		remote.Host = host
+	}
+	if remote.Host == "" || remote.Path == "" {
+		return GitRemote{}, schema.ErrInvalidRepo
+	}
+	return remote, nil
+}
+
 func ensureGitSuffix(value string) string {
 	if strings.HasSuffix(value, ".git") {
 		return value
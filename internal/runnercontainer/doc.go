@@ -5,5 +5,9 @@
 // are applied per container via runner.limits.cpu_percent and
 // runner.limits.memory_percent. Niceness settings (runner.exec_nice and
 // runner.command_nice) are passed to the runner process to prioritize Codex exec
-// relative to shell commands.
+// relative to shell commands. Hardening options from runner.security are
+// applied to every container spec through Security. With
+// runner.network.allowed_hosts set, containers get no network and reach the
+// outside through a per-container egress proxy on a socket next to the runner
+// socket.
 package runnercontainer
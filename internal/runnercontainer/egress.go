@@ -0,0 +1,65 @@
+package runnercontainer
+
+import (
+	"context"
+
+	"pkt.systems/centaurx/internal/egress"
+	"pkt.systems/centaurx/schema"
+)
+
+const egressSocketName = "egress.sock"
+
+// egressProxyCommand tunnels git over ssh through the egress proxy; the
+// runner sets the proxy address in the environment.
+const egressProxyCommand = "'ProxyCommand=centaurx egress-connect %h %p'"
+
+// startEgressProxy serves the egress proxy of key on socketPath until the
+// returned cancel func is called.
+func (p *Provider) startEgressProxy(key tabKey, socketPath string) (context.CancelFunc, error) {
+	listener, err := egress.ListenUnix(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	log := p.logger.With("user", key.user, "tab", key.tab)
+	proxy := &egress.Proxy{
+		Policy: p.egress,
+		Denied: func(dest string) { p.reportDenied(key, dest) },
+		Logger: log,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		if err := proxy.Serve(ctx, listener); err != nil {
+			log.Warn("runner egress proxy stopped", "err", err)
+		}
+	}()
+	log.Info("runner egress proxy listening", "socket", socketPath)
+	return cancel, nil
+}
+
+// reportDenied writes refused network access to the buffers of the tabs with
+// a run in progress on the runner of key, or to the tab of a per-tab runner.
+func (p *Provider) reportDenied(key tabKey, dest string) {
+	p.mu.Lock()
+	var tabs []schema.TabID
+	if entry := p.tabs[key]; entry != nil {
+		for tabID, count := range entry.active {
+			if count > 0 {
+				tabs = append(tabs, tabID)
+			}
+		}
+	}
+	if len(tabs) == 0 && key.tab != "" {
+		tabs = append(tabs, key.tab)
+	}
+	output := p.output
+	p.mu.Unlock()
+	if output == nil {
+		return
+	}
+	line := schema.BufferLine{Kind: schema.LineKindError, Text: egress.DeniedMessage(dest)}
+	for _, tabID := range tabs {
+		if _, err := output.AppendOutput(context.Background(), schema.AppendOutputRequest{UserID: key.user, TabID: tabID, Structured: []schema.BufferLine{line}}); err != nil {
+			p.logger.Debug("runner egress report failed", "user", key.user, "tab", tabID, "err", err)
+		}
+	}
+}
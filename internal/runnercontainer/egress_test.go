@@ -0,0 +1,151 @@
+package runnercontainer
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/egress"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/sshagent"
+	"pkt.systems/centaurx/schema"
+)
+
+type outputRecorder struct {
+	mu       sync.Mutex
+	requests []schema.AppendOutputRequest
+}
+
+func (r *outputRecorder) AppendOutput(_ context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+	return schema.AppendOutputResponse{}, nil
+}
+
+func (r *outputRecorder) list() []schema.AppendOutputRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]schema.AppendOutputRequest(nil), r.requests...)
+}
+
+func TestProviderEgressControl(t *testing.T) {
+	temp := t.TempDir()
+	repoRoot := filepath.Join(temp, "repos")
+	stateDir := filepath.Join(temp, "state")
+	agentDir := filepath.Join(stateDir, "agents")
+	sockDir := filepath.Join(stateDir, "sockets")
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		t.Fatalf("state dir: %v", err)
+	}
+	manager, err := sshagent.NewManager(fakeKeyProvider{}, agentDir)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	user := schema.UserID("tester")
+	tab1 := schema.TabID("tab1")
+	tab2 := schema.TabID("tab2")
+	runtime := &captureRuntime{socketPath: filepath.Join(sockDir, string(user), "runner.sock")}
+	provider, err := NewProvider(context.Background(), Config{
+		Image:           "test",
+		RepoRoot:        repoRoot,
+		RunnerRepoRoot:  "/repos",
+		HostRepoRoot:    repoRoot,
+		SockDir:         sockDir,
+		StateDir:        stateDir,
+		SSHAgentDir:     agentDir,
+		ContainerScope:  "user",
+		SocketWait:      time.Second,
+		SocketRetryWait: 10 * time.Millisecond,
+		AllowedHosts:    []string{"git.example.com:22"},
+	}, runtime, manager)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	output := &outputRecorder{}
+	provider.SetOutputAppender(output)
+	t.Cleanup(func() {
+		_ = provider.CloseAll(context.Background())
+		if runtime.listener != nil {
+			_ = runtime.listener.Close()
+		}
+	})
+
+	for _, tab := range []schema.TabID{tab1, tab2} {
+		if _, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tab}); err != nil {
+			t.Fatalf("runner for %s: %v", tab, err)
+		}
+	}
+	spec := runtime.lastSpec
+	if spec.NetworkMode != shipohoy.NetworkNone {
+		t.Fatalf("expected no container network, got %q", spec.NetworkMode)
+	}
+	idx := slices.Index(spec.Command, "--egress-proxy-socket")
+	if idx < 0 || spec.Command[idx+1] != filepath.Join(sockDir, string(user), "egress.sock") {
+		t.Fatalf("expected egress proxy socket flag, got %v", spec.Command)
+	}
+	if !strings.Contains(spec.Env["GIT_SSH_COMMAND"], "ProxyCommand=centaurx egress-connect %h %p") {
+		t.Fatalf("expected ssh ProxyCommand, got %q", spec.Env["GIT_SSH_COMMAND"])
+	}
+
+	// A run in tab2 makes it the tab a refused destination is reported to.
+	done := provider.startRun(provider.keyFor(user, tab2), tab2, "exec")
+	defer done()
+	bridge := startTestBridge(t, filepath.Join(sockDir, string(user), "egress.sock"))
+	err = egress.Connect(context.Background(), bridge, "example.com:443", strings.NewReader(""), io.Discard)
+	if err == nil || err.Error() != "network access to example.com:443 denied by policy" {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	requests := output.list()
+	if len(requests) != 1 || requests[0].UserID != user || requests[0].TabID != tab2 {
+		t.Fatalf("expected one report for tab2, got %+v", requests)
+	}
+	if line := requests[0].Structured; len(line) != 1 || line[0].Kind != schema.LineKindError || line[0].Text != "network access to example.com:443 denied by policy" {
+		t.Fatalf("unexpected report %+v", line)
+	}
+}
+
+func TestNewProviderRejectsInvalidAllowedHosts(t *testing.T) {
+	temp := t.TempDir()
+	manager, err := sshagent.NewManager(fakeKeyProvider{}, filepath.Join(temp, "agents"))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	_, err = NewProvider(context.Background(), Config{
+		Image:          "test",
+		RepoRoot:       filepath.Join(temp, "repos"),
+		RunnerRepoRoot: "/repos",
+		SockDir:        filepath.Join(temp, "sockets"),
+		StateDir:       filepath.Join(temp, "state"),
+		SSHAgentDir:    filepath.Join(temp, "agents"),
+		AllowedHosts:   []string{"git.example.com"},
+	}, fakeRuntime{}, manager)
+	if err == nil || !strings.Contains(err.Error(), "runner.network.allowed_hosts") {
+		t.Fatalf("expected allowed hosts error, got %v", err)
+	}
+}
+
+// startTestBridge relays a loopback port to the egress socket, as the runner
+// does inside the container.
+func startTestBridge(t *testing.T, socketPath string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = egress.Bridge(ctx, listener, socketPath) }()
+	return listener.Addr().String()
+}
@@ -0,0 +1,97 @@
+package runnercontainer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// systemPaths are container directories extra mounts may not shadow or sit
+// beneath.
+var systemPaths = []string{"/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/sbin", "/sys", "/usr"}
+
+// ValidateMounts checks extra mounts against cfg: the host path must exist,
+// the container path must be absolute and neither path may overlap a system
+// directory or a directory the provider mounts itself.
+func (cfg Config) ValidateMounts(mounts []Mount) error {
+	reserved := []string{defaultContainerHome, "/tmp", "/run", "/var/run", "/var/tmp"}
+	for _, dir := range []string{cfg.RunnerRepoRoot, cfg.SockDir, cfg.SSHAgentDir} {
+		if dir != "" {
+			reserved = append(reserved, path.Clean(dir))
+		}
+	}
+	targets := make(map[string]struct{}, len(mounts))
+	for _, mount := range mounts {
+		if !filepath.IsAbs(mount.Host) {
+			return fmt.Errorf("mount host path %q must be absolute", mount.Host)
+		}
+		if _, err := os.Stat(mount.Host); err != nil {
+			return fmt.Errorf("mount host path %q: %w", mount.Host, err)
+		}
+		if !path.IsAbs(mount.Container) {
+			return fmt.Errorf("mount container path %q must be absolute", mount.Container)
+		}
+		target := path.Clean(mount.Container)
+		if target == "/" {
+			return fmt.Errorf("mount container path %q must not be /", mount.Container)
+		}
+		for _, dir := range systemPaths {
+			if pathOverlaps(target, dir) {
+				return fmt.Errorf("mount container path %q overlaps system directory %s", mount.Container, dir)
+			}
+		}
+		for _, dir := range reserved {
+			if pathOverlaps(target, dir) {
+				return fmt.Errorf("mount container path %q overlaps %s, which centaurx mounts itself", mount.Container, dir)
+			}
+		}
+		if _, ok := targets[target]; ok {
+			return fmt.Errorf("mount container path %q is used twice", mount.Container)
+		}
+		targets[target] = struct{}{}
+	}
+	return nil
+}
+
+// mountsFor returns the extra mounts of a user's containers: the global ones
+// followed by the user's own, which replace global mounts at the same
+// container path.
+func (p *Provider) mountsFor(user schema.UserID) ([]Mount, error) {
+	if p.cfg.UserMounts == nil {
+		return p.cfg.Mounts, nil
+	}
+	own := p.cfg.UserMounts(user)
+	if len(own) == 0 {
+		return p.cfg.Mounts, nil
+	}
+	if err := p.cfg.ValidateMounts(own); err != nil {
+		return nil, fmt.Errorf("runner mounts of %s: %w", user, err)
+	}
+	replaced := make(map[string]struct{}, len(own))
+	for _, mount := range own {
+		replaced[path.Clean(mount.Container)] = struct{}{}
+	}
+	mounts := make([]Mount, 0, len(p.cfg.Mounts)+len(own))
+	for _, mount := range p.cfg.Mounts {
+		if _, ok := replaced[path.Clean(mount.Container)]; !ok {
+			mounts = append(mounts, mount)
+		}
+	}
+	return append(mounts, own...), nil
+}
+
+// pathOverlaps reports whether a and b are the same directory or one lies
+// within the other.
+func pathOverlaps(a, b string) bool {
+	return a == b || within(a, b) || within(b, a)
+}
+
+func within(p, root string) bool {
+	if root == "/" {
+		return true
+	}
+	return len(p) > len(root) && p[:len(root)] == root && p[len(root)] == '/'
+}
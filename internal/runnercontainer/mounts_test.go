@@ -0,0 +1,66 @@
+package runnercontainer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestValidateMounts(t *testing.T) {
+	host := t.TempDir()
+	cfg := Config{RunnerRepoRoot: "/repos", SockDir: "/state/sock", SSHAgentDir: "/state/agent"}
+	if err := cfg.ValidateMounts([]Mount{{Host: host, Container: "/data", ReadOnly: true}, {Host: host, Container: "/srv/cache"}}); err != nil {
+		t.Fatalf("expected valid mounts, got %v", err)
+	}
+	cases := map[string]struct {
+		mount Mount
+		want  string
+	}{
+		"relative host":     {Mount{Host: "data", Container: "/data"}, "must be absolute"},
+		"missing host":      {Mount{Host: filepath.Join(host, "missing"), Container: "/data"}, "no such file"},
+		"relative target":   {Mount{Host: host, Container: "data"}, "must be absolute"},
+		"root":              {Mount{Host: host, Container: "/"}, "must not be /"},
+		"system directory":  {Mount{Host: host, Container: "/usr/local/data"}, "system directory /usr"},
+		"repo root":         {Mount{Host: host, Container: "/repos/shared"}, "overlaps /repos"},
+		"contains home":     {Mount{Host: host, Container: "/centaurx"}, "overlaps /centaurx"},
+		"contains sock dir": {Mount{Host: host, Container: "/state"}, "overlaps /state/sock"},
+		"tmpfs":             {Mount{Host: host, Container: "/tmp/data"}, "overlaps /tmp"},
+	}
+	for name, tc := range cases {
+		err := cfg.ValidateMounts([]Mount{tc.mount})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+	if err := cfg.ValidateMounts([]Mount{{Host: host, Container: "/data"}, {Host: host, Container: "/data/"}}); err == nil {
+		t.Fatalf("expected a duplicate container path to be rejected")
+	}
+}
+
+func TestMountsForReplacesGlobalMounts(t *testing.T) {
+	shared := t.TempDir()
+	own := t.TempDir()
+	p := &Provider{cfg: Config{
+		RunnerRepoRoot: "/repos",
+		Mounts:         []Mount{{Host: shared, Container: "/data"}, {Host: shared, Container: "/cache"}},
+		UserMounts: func(user schema.UserID) []Mount {
+			if user != "alice" {
+				return nil
+			}
+			return []Mount{{Host: own, Container: "/data", ReadOnly: true}}
+		},
+	}}
+	mounts, err := p.mountsFor("alice")
+	if err != nil {
+		t.Fatalf("mounts for alice: %v", err)
+	}
+	want := []Mount{{Host: shared, Container: "/cache"}, {Host: own, Container: "/data", ReadOnly: true}}
+	if len(mounts) != len(want) || mounts[0] != want[0] || mounts[1] != want[1] {
+		t.Fatalf("expected %+v, got %+v", want, mounts)
+	}
+	if mounts, err := p.mountsFor("bob"); err != nil || len(mounts) != 2 {
+		t.Fatalf("expected bob to get the global mounts, got %+v %v", mounts, err)
+	}
+}
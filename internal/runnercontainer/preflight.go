@@ -0,0 +1,132 @@
+package runnercontainer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/schema"
+)
+
+const (
+	// maxClockSkew is the container clock drift tolerated before TLS and
+	// token validation are expected to fail.
+	maxClockSkew = 2 * time.Minute
+	// preflightTimeout bounds each preflight exec.
+	preflightTimeout = 5 * time.Second
+	caCertsDir       = "/etc/ssl/certs"
+)
+
+// preflight checks the clock and the CA certificates of a started
+// container. Checks that cannot run, for example because the image has no
+// date binary, are skipped rather than reported as problems.
+func (p *Provider) preflight(ctx context.Context, handle shipohoy.Handle) core.RunnerPreflight {
+	result := core.RunnerPreflight{Container: handle.Name(), CheckedAt: time.Now()}
+	if skew, ok := p.clockSkew(ctx, handle); ok {
+		result.ClockSkew = skew
+		result.ClockChecked = true
+		if skew > maxClockSkew || skew < -maxClockSkew {
+			direction := "ahead of"
+			if skew < 0 {
+				direction = "behind"
+			}
+			result.Problems = append(result.Problems, fmt.Sprintf("runner container clock is %s %s the host", skew.Abs(), direction))
+			result.Hints = append(result.Hints, "hint: sync the clock of the container host (chrony or systemd-timesyncd); TLS and login tokens fail on a skewed clock")
+		}
+	}
+	var out bytes.Buffer
+	res, err := p.rt.Exec(ctx, handle, shipohoy.ExecSpec{
+		Command: []string{"test", "-d", caCertsDir},
+		Stdout:  &out,
+		Stderr:  &out,
+		Timeout: preflightTimeout,
+	})
+	if err == nil {
+		result.CAChecked = true
+		result.CACerts = res.ExitCode == 0
+		if !result.CACerts {
+			result.Problems = append(result.Problems, "runner image has no CA certificates ("+caCertsDir+" is missing)")
+			result.Hints = append(result.Hints, "hint: install the ca-certificates package in the runner image")
+		}
+	}
+	return result
+}
+
+// clockSkew returns the container clock minus the host clock, measured
+// against the middle of the exec.
+func (p *Provider) clockSkew(ctx context.Context, handle shipohoy.Handle) (time.Duration, bool) {
+	var out bytes.Buffer
+	started := time.Now()
+	res, err := p.rt.Exec(ctx, handle, shipohoy.ExecSpec{
+		Command: []string{"date", "+%s"},
+		Stdout:  &out,
+		Timeout: preflightTimeout,
+	})
+	finished := time.Now()
+	if err != nil || res.ExitCode != 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	host := started.Add(finished.Sub(started) / 2)
+	return time.Unix(seconds, 0).Sub(host).Round(time.Second), true
+}
+
+// Preflight runs the environment checks again in the runner of a tab,
+// starting it when needed, and caches the result for the container.
+func (p *Provider) Preflight(ctx context.Context, req core.RunnerRequest) (core.RunnerPreflight, error) {
+	resp, err := p.RunnerFor(ctx, req)
+	if err != nil {
+		return core.RunnerPreflight{}, err
+	}
+	key := p.keyFor(req.UserID, req.TabID)
+	p.mu.Lock()
+	entry := p.tabs[key]
+	var handle shipohoy.Handle
+	if entry != nil {
+		handle = entry.handle
+	}
+	p.mu.Unlock()
+	if handle == nil {
+		return core.RunnerPreflight{}, errors.New("runner unavailable")
+	}
+	result := p.preflight(ctx, handle)
+	result.Mounts = append([]core.PathMapping(nil), resp.Info.Mounts...)
+	p.mu.Lock()
+	if p.tabs[key] == entry {
+		entry.preflight = result
+	}
+	p.mu.Unlock()
+	return result, nil
+}
+
+// preflightFor returns the cached preflight result of a runner.
+func (p *Provider) preflightFor(key tabKey) core.RunnerPreflight {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry := p.tabs[key]; entry != nil {
+		return entry.preflight
+	}
+	return core.RunnerPreflight{}
+}
+
+// environmentError wraps err in a RunnerErrorEnvironment naming the
+// problems preflight found, so failures that look like codex auth errors
+// point at their actual cause.
+func environmentError(op string, err error, result core.RunnerPreflight) error {
+	if err == nil || result.OK() || errors.Is(err, context.Canceled) || schema.ErrorCode(err) == schema.CodeRunnerCanceled {
+		return err
+	}
+	wrapped := core.NewRunnerError(core.RunnerErrorEnvironment, op, err)
+	wrapped.Message = fmt.Sprintf("runner environment problem: %s (%v)", strings.Join(result.Problems, "; "), err)
+	wrapped.Hints = result.Hints
+	return wrapped
+}
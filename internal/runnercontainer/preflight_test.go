@@ -0,0 +1,169 @@
+package runnercontainer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/sshagent"
+	"pkt.systems/centaurx/schema"
+)
+
+// preflightRuntime answers the preflight execs with a clock offset from the
+// host and an optional missing CA directory.
+type preflightRuntime struct {
+	captureRuntime
+	skew      time.Duration
+	dateOut   string
+	noCACerts bool
+	execs     int
+}
+
+func (r *preflightRuntime) Exec(_ context.Context, _ shipohoy.Handle, spec shipohoy.ExecSpec) (shipohoy.ExecResult, error) {
+	r.execs++
+	switch spec.Command[0] {
+	case "date":
+		out := r.dateOut
+		if out == "" {
+			out = fmt.Sprintf("%d\n", time.Now().Add(r.skew).Unix())
+		}
+		_, _ = io.WriteString(spec.Stdout, out)
+	case "test":
+		if r.noCACerts {
+			return shipohoy.ExecResult{ExitCode: 1}, nil
+		}
+	}
+	return shipohoy.ExecResult{}, nil
+}
+
+func TestPreflightDetectsClockSkew(t *testing.T) {
+	p := &Provider{rt: &preflightRuntime{skew: -10 * time.Minute}}
+	result := p.preflight(context.Background(), fakeHandle{})
+	if !result.ClockChecked || result.ClockSkew > -9*time.Minute {
+		t.Fatalf("expected a 10m skew, got %+v", result)
+	}
+	if result.OK() || !strings.Contains(strings.Join(result.Problems, "\n"), "behind the host") {
+		t.Fatalf("expected a clock problem, got %q", result.Problems)
+	}
+	if !result.CAChecked || !result.CACerts {
+		t.Fatalf("expected CA certs, got %+v", result)
+	}
+}
+
+func TestPreflightToleratesSmallSkew(t *testing.T) {
+	p := &Provider{rt: &preflightRuntime{skew: 30 * time.Second}}
+	if result := p.preflight(context.Background(), fakeHandle{}); !result.OK() {
+		t.Fatalf("expected no problems, got %q", result.Problems)
+	}
+}
+
+func TestPreflightDetectsMissingCACerts(t *testing.T) {
+	p := &Provider{rt: &preflightRuntime{noCACerts: true}}
+	result := p.preflight(context.Background(), fakeHandle{})
+	if result.CACerts || len(result.Problems) != 1 || !strings.Contains(result.Problems[0], "no CA certificates") {
+		t.Fatalf("expected a CA problem, got %+v", result)
+	}
+	if len(result.Hints) != 1 || !strings.Contains(result.Hints[0], "ca-certificates") {
+		t.Fatalf("expected a CA hint, got %q", result.Hints)
+	}
+}
+
+func TestPreflightSkipsUnreadableClock(t *testing.T) {
+	p := &Provider{rt: &preflightRuntime{dateOut: "Thu Jan  1 00:00:00 UTC 1970\n"}}
+	result := p.preflight(context.Background(), fakeHandle{})
+	if result.ClockChecked || !result.OK() {
+		t.Fatalf("expected the clock check to be skipped, got %+v", result)
+	}
+}
+
+func TestEnvironmentErrorWrapsRunnerErrors(t *testing.T) {
+	result := core.RunnerPreflight{Problems: []string{"runner image has no CA certificates"}, Hints: []string{"hint: install ca-certificates"}}
+	base := core.NewRunnerError(core.RunnerErrorUnauthorized, "exec", errors.New("401"))
+	err := environmentError("exec", base, result)
+	var runnerErr *core.RunnerError
+	if !errors.As(err, &runnerErr) || runnerErr.Kind != core.RunnerErrorEnvironment || schema.ErrorCode(err) != schema.CodeRunnerEnvironment {
+		t.Fatalf("expected an environment error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "no CA certificates") || len(runnerErr.Hints) != 1 {
+		t.Fatalf("expected the problem and hint, got %v %q", err, runnerErr.Hints)
+	}
+	if got := environmentError("exec", base, core.RunnerPreflight{}); got != error(base) {
+		t.Fatalf("expected errors to pass through without problems, got %v", got)
+	}
+	canceled := core.NewRunnerError(core.RunnerErrorCanceled, "exec", context.Canceled)
+	if got := environmentError("exec", canceled, result); got != error(canceled) {
+		t.Fatalf("expected cancellations to pass through, got %v", got)
+	}
+}
+
+func TestPreflightIsCachedPerContainer(t *testing.T) {
+	temp := t.TempDir()
+	repoRoot := filepath.Join(temp, "repos")
+	stateDir := filepath.Join(temp, "state")
+	agentDir := filepath.Join(stateDir, "agents")
+	sockDir := filepath.Join(stateDir, "sockets")
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		t.Fatalf("state dir: %v", err)
+	}
+	manager, err := sshagent.NewManager(fakeKeyProvider{}, agentDir)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	user := schema.UserID("tester")
+	runtime := &preflightRuntime{skew: time.Hour}
+	runtime.socketPath = filepath.Join(sockDir, string(user), "runner.sock")
+	provider, err := NewProvider(context.Background(), Config{
+		Image:           "test",
+		RepoRoot:        repoRoot,
+		RunnerRepoRoot:  "/repos",
+		SockDir:         sockDir,
+		StateDir:        stateDir,
+		SSHAgentDir:     agentDir,
+		ContainerScope:  "user",
+		SocketWait:      time.Second,
+		SocketRetryWait: 10 * time.Millisecond,
+	}, runtime, manager)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = provider.CloseAll(context.Background())
+		if runtime.listener != nil {
+			_ = runtime.listener.Close()
+		}
+	})
+
+	for _, tab := range []schema.TabID{"tab1", "tab2", "tab1"} {
+		if _, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tab}); err != nil {
+			t.Fatalf("runner for %s: %v", tab, err)
+		}
+	}
+	if runtime.execs != 2 {
+		t.Fatalf("expected one preflight (2 execs), got %d execs", runtime.execs)
+	}
+	if cached := provider.preflightFor(provider.keyFor(user, "tab1")); cached.OK() {
+		t.Fatalf("expected the cached preflight to report the skew, got %+v", cached)
+	}
+
+	runtime.skew = 0
+	result, err := provider.Preflight(context.Background(), core.RunnerRequest{UserID: user, TabID: "tab1"})
+	if err != nil {
+		t.Fatalf("preflight: %v", err)
+	}
+	if !result.OK() || runtime.execs != 4 {
+		t.Fatalf("expected a fresh passing preflight, got %+v after %d execs", result, runtime.execs)
+	}
+	if cached := provider.preflightFor(provider.keyFor(user, "tab1")); !cached.OK() {
+		t.Fatalf("expected the cache to be updated, got %+v", cached)
+	}
+}
@@ -9,13 +9,17 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/buildmanifest"
+	"pkt.systems/centaurx/internal/egress"
 	"pkt.systems/centaurx/internal/runnergrpc"
 	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
 	"pkt.systems/centaurx/internal/sshagent"
 	"pkt.systems/centaurx/internal/userhome"
 	"pkt.systems/centaurx/schema"
@@ -27,6 +31,14 @@ const (
 	defaultRunnerBinary   = "codex"
 	defaultNamePrefix     = "centaurx-runner"
 	defaultContainerHome  = "/centaurx"
+
+	defaultCloseParallelism = 8
+	// stopTimeout bounds the graceful stop of a container. When a deadline
+	// leaves less than minGracefulStop after reserving forceRemoveReserve
+	// for the removal, containers are force-removed without a stop.
+	stopTimeout        = 10 * time.Second
+	forceRemoveReserve = 2 * time.Second
+	minGracefulStop    = time.Second
 )
 
 type containerScope string
@@ -50,18 +62,24 @@ func parseContainerScope(value string) containerScope {
 
 // Config configures the runner container provider.
 type Config struct {
-	Image             string
-	RepoRoot          string
-	RunnerRepoRoot    string
-	HostRepoRoot      string
-	HostStateDir      string
-	SockDir           string
-	StateDir          string
-	SkelData          userhome.TemplateData
-	SSHAgentDir       string
-	RunnerBinary      string
-	RunnerArgs        []string
-	RunnerEnv         map[string]string
+	Image          string
+	RepoRoot       string
+	RunnerRepoRoot string
+	HostRepoRoot   string
+	HostStateDir   string
+	SockDir        string
+	StateDir       string
+	SkelData       userhome.TemplateData
+	SSHAgentDir    string
+	RunnerBinary   string
+	RunnerArgs     []string
+	RunnerEnv      map[string]string
+	// Mounts are extra host directories mounted into every container.
+	Mounts []Mount
+	// UserMounts returns the extra mounts of a user, added to Mounts and
+	// replacing those at the same container path. Changes apply when the
+	// user's containers are next created.
+	UserMounts        func(schema.UserID) []Mount
 	GitSSHDebug       bool
 	ContainerScope    string
 	ExecNice          int
@@ -75,6 +93,22 @@ type Config struct {
 	SocketRetryWait   time.Duration
 	CPUPercent        int
 	MemoryPercent     int
+	Security          Security
+	// AllowedHosts turns on egress control: containers run without a
+	// network and reach only these host:port and CIDR entries through a
+	// filtering proxy. Empty leaves the network alone.
+	AllowedHosts []string
+	// CloseParallelism bounds how many containers CloseAll, CloseUser and
+	// the idle sweep stop at once; 0 uses the default of 8.
+	CloseParallelism int
+}
+
+// Mount bind-mounts Host into containers at Container. Host must be the same
+// path for centaurx and the container runtime.
+type Mount struct {
+	Host      string
+	Container string
+	ReadOnly  bool
 }
 
 // Provider manages per-tab runner containers.
@@ -93,9 +127,15 @@ type Provider struct {
 	skelData          userhome.TemplateData
 	scope             containerScope
 	resourceCaps      *shipohoy.ResourceCaps
+	egress            *egress.Policy
 
 	mu   sync.Mutex
 	tabs map[tabKey]*tabRunner
+	// started remembers runners started before, so a new start is reported
+	// as a recreation.
+	started  map[tabKey]struct{}
+	activity core.ActivityRecorder
+	output   core.OutputAppender
 }
 
 type logTailer interface {
@@ -114,6 +154,14 @@ type tabRunner struct {
 	lastUsed        time.Time
 	running         int
 	keepaliveCancel context.CancelFunc
+	egressCancel    context.CancelFunc
+	// active counts the runs in progress per tab, so refused network
+	// access is reported to the tabs that may have caused it.
+	active map[schema.TabID]int
+
+	// preflight is the result of the environment checks run when the
+	// container started or on the last Preflight call.
+	preflight core.RunnerPreflight
 
 	wait chan struct{}
 	err  error
@@ -150,7 +198,18 @@ func NewProvider(ctx context.Context, cfg Config, rt shipohoy.Runtime, agents *s
 	if scope == scopeUnknown {
 		return nil, fmt.Errorf("runner.container_scope must be \"user\" or \"tab\"")
 	}
+	if err := cfg.ValidateMounts(cfg.Mounts); err != nil {
+		return nil, fmt.Errorf("runner.extra_mounts: %w", err)
+	}
 	caps := ResourceCapsFromPercent(cfg.CPUPercent, cfg.MemoryPercent, pslog.Ctx(ctx))
+	var egressPolicy *egress.Policy
+	if len(cfg.AllowedHosts) > 0 {
+		policy, err := egress.ParsePolicy(cfg.AllowedHosts)
+		if err != nil {
+			return nil, fmt.Errorf("runner.network.allowed_hosts: %w", err)
+		}
+		egressPolicy = policy
+	}
 
 	if strings.TrimSpace(cfg.HostRepoRoot) != "" {
 		runnerRoot := filepath.Clean(cfg.RunnerRepoRoot)
@@ -174,6 +233,9 @@ func NewProvider(ctx context.Context, cfg Config, rt shipohoy.Runtime, agents *s
 	if cfg.SocketRetryWait <= 0 {
 		cfg.SocketRetryWait = 200 * time.Millisecond
 	}
+	if cfg.CloseParallelism <= 0 {
+		cfg.CloseParallelism = defaultCloseParallelism
+	}
 
 	if err := os.MkdirAll(cfg.RepoRoot, 0o755); err != nil {
 		return nil, err
@@ -222,7 +284,9 @@ func NewProvider(ctx context.Context, cfg Config, rt shipohoy.Runtime, agents *s
 		skelData:          cfg.SkelData,
 		scope:             scope,
 		resourceCaps:      caps,
+		egress:            egressPolicy,
 		tabs:              make(map[tabKey]*tabRunner),
+		started:           make(map[tabKey]struct{}),
 	}
 	if cfg.IdleTimeout > 0 {
 		go p.sweep(ctx, cfg.IdleTimeout)
@@ -285,7 +349,14 @@ func (p *Provider) RunnerFor(ctx context.Context, req core.RunnerRequest) (core.
 	p.mu.Unlock()
 
 	log.Info("runner start requested")
-	client, info, handle, err := p.startRunner(ctx, key, req.TabID)
+	client, info, handle, egressCancel, err := p.startRunner(ctx, key, req.TabID)
+	var preflight core.RunnerPreflight
+	if err == nil {
+		preflight = p.preflight(ctx, handle)
+		if !preflight.OK() {
+			log.Warn("runner preflight failed", "container", handle.Name(), "problems", strings.Join(preflight.Problems, "; "))
+		}
+	}
 	p.mu.Lock()
 	if err != nil {
 		entry.err = err
@@ -299,14 +370,43 @@ func (p *Provider) RunnerFor(ctx context.Context, req core.RunnerRequest) (core.
 	entry.handle = handle
 	entry.info = info
 	entry.keepaliveCancel = p.startKeepalive(key, client)
+	entry.egressCancel = egressCancel
+	entry.preflight = preflight
 	entry.lastUsed = time.Now()
 	close(entry.wait)
 	entry.wait = nil
+	kind := schema.ActivityRunnerCreated
+	if _, ok := p.started[key]; ok {
+		kind = schema.ActivityRunnerRecreated
+	}
+	p.started[key] = struct{}{}
+	recorder := p.activity
 	p.mu.Unlock()
 	log.Info("runner ready", "container", handle.Name(), "socket", filepath.Join(p.cfg.SockDir, string(key.user), string(key.tab), "runner.sock"))
+	if recorder != nil {
+		if _, err := recorder.RecordActivity(ctx, schema.RecordActivityRequest{UserID: key.user, Kind: kind, Detail: handle.Name()}); err != nil {
+			log.Debug("runner activity record failed", "err", err)
+		}
+	}
 	return core.RunnerResponse{Runner: newTrackedRunner(entry.client, p, key, req.TabID), Info: entry.info}, nil
 }
 
+// SetActivityRecorder reports runner container starts to the users' activity
+// feeds.
+func (p *Provider) SetActivityRecorder(recorder core.ActivityRecorder) {
+	p.mu.Lock()
+	p.activity = recorder
+	p.mu.Unlock()
+}
+
+// SetOutputAppender lets the provider report refused network access in the
+// tab buffers.
+func (p *Provider) SetOutputAppender(appender core.OutputAppender) {
+	p.mu.Lock()
+	p.output = appender
+	p.mu.Unlock()
+}
+
 func (p *Provider) keyFor(user schema.UserID, tab schema.TabID) tabKey {
 	if p.scope == scopeTab {
 		return tabKey{user: user, tab: tab}
@@ -374,42 +474,121 @@ func (p *Provider) CloseTab(ctx context.Context, req core.RunnerCloseRequest) er
 	return p.stopRunner(ctx, entry, key, "closed")
 }
 
-// CloseAll stops and removes all runners.
+// CloseAll stops and removes all runners, CloseParallelism at a time.
+// Failures are collected while the remaining runners are still stopped.
 func (p *Provider) CloseAll(ctx context.Context) error {
 	p.mu.Lock()
-	entries := make([]struct {
-		key   tabKey
-		entry *tabRunner
-	}, 0, len(p.tabs))
+	items := make([]stopItem, 0, len(p.tabs))
 	for key, entry := range p.tabs {
-		entries = append(entries, struct {
-			key   tabKey
-			entry *tabRunner
-		}{key: key, entry: entry})
+		items = append(items, stopItem{key: key, entry: entry})
 	}
 	p.tabs = make(map[tabKey]*tabRunner)
 	p.mu.Unlock()
-	p.logger.Info("runner close all requested", "count", len(entries))
+	p.logger.Info("runner close all requested", "count", len(items))
 
-	var errs []error
-	stopped := 0
-	failed := 0
-	for _, item := range entries {
-		if err := p.stopRunner(ctx, item.entry, item.key, "shutdown"); err != nil {
-			errs = append(errs, err)
-			failed++
-			continue
+	started := time.Now()
+	stopped, err := p.stopAll(ctx, items, "shutdown")
+	p.logger.Info("runner close all completed", "stopped", stopped, "failed", len(items)-stopped, "duration_ms", time.Since(started).Milliseconds())
+	return err
+}
+
+// stopItem is a runner removed from the provider and waiting to be stopped.
+type stopItem struct {
+	key   tabKey
+	entry *tabRunner
+}
+
+// stopAll stops items on up to CloseParallelism goroutines. It returns how
+// many stopped cleanly and the joined errors of the others.
+func (p *Provider) stopAll(ctx context.Context, items []stopItem, reason string) (int, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	work := make(chan stopItem)
+	var (
+		mu      sync.Mutex
+		errs    []error
+		stopped int
+		wg      sync.WaitGroup
+	)
+	for range min(p.cfg.CloseParallelism, len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range work {
+				err := p.stopRunner(ctx, item.entry, item.key, reason)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					stopped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, item := range items {
+		work <- item
+	}
+	close(work)
+	wg.Wait()
+	return stopped, errors.Join(errs...)
+}
+
+// ListRunners reports the live runners, ordered by user and tab. Runners
+// still starting have no container name yet.
+func (p *Provider) ListRunners(_ context.Context) ([]core.RunnerStatus, error) {
+	p.mu.Lock()
+	out := make([]core.RunnerStatus, 0, len(p.tabs))
+	for key, entry := range p.tabs {
+		status := core.RunnerStatus{
+			UserID:   key.user,
+			TabID:    key.tab,
+			Running:  entry.running,
+			LastUsed: entry.lastUsed,
 		}
-		stopped++
+		if entry.handle != nil {
+			status.Container = entry.handle.Name()
+		}
+		for tabID := range entry.tabs {
+			status.Tabs = append(status.Tabs, tabID)
+		}
+		slices.Sort(status.Tabs)
+		out = append(out, status)
 	}
-	p.logger.Info("runner close all completed", "stopped", stopped, "failed", failed)
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+	p.mu.Unlock()
+	slices.SortFunc(out, func(a, b core.RunnerStatus) int {
+		if c := strings.Compare(string(a.UserID), string(b.UserID)); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.TabID), string(b.TabID))
+	})
+	return out, nil
+}
+
+// CloseUser stops and removes every runner of a user and returns how many
+// were stopped.
+func (p *Provider) CloseUser(ctx context.Context, userID schema.UserID) (int, error) {
+	if strings.TrimSpace(string(userID)) == "" {
+		p.logger.Warn("runner close user rejected", "reason", "missing user")
+		return 0, errors.New("user id is required")
 	}
-	return nil
+	p.mu.Lock()
+	var items []stopItem
+	for key, entry := range p.tabs {
+		if key.user == userID {
+			items = append(items, stopItem{key: key, entry: entry})
+			delete(p.tabs, key)
+		}
+	}
+	p.mu.Unlock()
+	p.logger.Info("runner close user requested", "user", userID, "count", len(items))
+	return p.stopAll(ctx, items, "admin")
 }
 
-func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.TabID) (*runnergrpc.Client, core.RunnerInfo, shipohoy.Handle, error) {
+// startRunner starts the container of key and dials its runner. The returned
+// cancel func stops the egress proxy, when egress control is on.
+func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.TabID) (_ *runnergrpc.Client, _ core.RunnerInfo, _ shipohoy.Handle, _ context.CancelFunc, err error) {
 	if logTab == "" {
 		logTab = key.tab
 	}
@@ -419,23 +598,28 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 		if errors.Is(err, os.ErrNotExist) {
 			log.Warn("ssh key missing; starting runner without agent")
 		} else {
-			return nil, core.RunnerInfo{}, nil, err
+			return nil, core.RunnerInfo{}, nil, nil, err
 		}
 	}
 
 	repoRoot := filepath.Join(p.cfg.RepoRoot, string(key.user))
 	if err := ensureDir(repoRoot, 0o755); err != nil {
-		return nil, core.RunnerInfo{}, nil, fmt.Errorf("runner repo root %q: %w", repoRoot, err)
+		return nil, core.RunnerInfo{}, nil, nil, fmt.Errorf("runner repo root %q: %w", repoRoot, err)
 	}
 	hostRepoRoot := filepath.Join(p.hostRepoRoot, string(key.user))
-	if _, err := p.ensureHome(string(key.user)); err != nil {
-		return nil, core.RunnerInfo{}, nil, err
+	homePath, err := p.ensureHome(string(key.user))
+	if err != nil {
+		return nil, core.RunnerInfo{}, nil, nil, err
 	}
 	hostHomePath := filepath.Join(p.hostHomeRoot, string(key.user))
+	extraMounts, err := p.mountsFor(key.user)
+	if err != nil {
+		return nil, core.RunnerInfo{}, nil, nil, core.NewRunnerError(core.RunnerErrorContainerStart, "container mounts", err)
+	}
 
 	localSocketDir := filepath.Join(p.cfg.SockDir, string(key.user), string(key.tab))
 	if err := ensureDir(localSocketDir, 0o700); err != nil {
-		return nil, core.RunnerInfo{}, nil, fmt.Errorf("runner socket dir %q: %w", localSocketDir, err)
+		return nil, core.RunnerInfo{}, nil, nil, fmt.Errorf("runner socket dir %q: %w", localSocketDir, err)
 	}
 	localSocketPath := filepath.Join(localSocketDir, "runner.sock")
 	_ = os.Remove(localSocketPath)
@@ -445,9 +629,25 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 	containerSocketDir := path.Join(p.containerSockDir, string(key.user), string(key.tab))
 	containerSocketPath := path.Join(containerSocketDir, "runner.sock")
 
+	var egressCancel context.CancelFunc
+	containerEgressPath := ""
+	if p.egress != nil {
+		localEgressPath := filepath.Join(localSocketDir, egressSocketName)
+		egressCancel, err = p.startEgressProxy(key, localEgressPath)
+		if err != nil {
+			return nil, core.RunnerInfo{}, nil, nil, fmt.Errorf("runner egress proxy %q: %w", localEgressPath, err)
+		}
+		defer func() {
+			if err != nil {
+				egressCancel()
+			}
+		}()
+		containerEgressPath = path.Join(containerSocketDir, egressSocketName)
+	}
+
 	localAgentDir := filepath.Join(p.cfg.SSHAgentDir, string(key.user))
 	if err := ensureDir(localAgentDir, 0o700); err != nil {
-		return nil, core.RunnerInfo{}, nil, fmt.Errorf("runner agent dir %q: %w", localAgentDir, err)
+		return nil, core.RunnerInfo{}, nil, nil, fmt.Errorf("runner agent dir %q: %w", localAgentDir, err)
 	}
 	hostAgentDir := filepath.Join(p.hostAgentDir, string(key.user))
 	containerAgentDir := path.Join(p.containerAgentDir, string(key.user))
@@ -469,6 +669,9 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 	} else {
 		knownHostsPath := path.Join(defaultContainerHome, ".ssh", "known_hosts")
 		env["GIT_SSH_COMMAND"] = defaultGitSSHCommand(containerAgentSock, knownHostsPath, p.cfg.GitSSHDebug)
+		if p.egress != nil {
+			env["GIT_SSH_COMMAND"] += " -o " + egressProxyCommand
+		}
 	}
 	if containerAgentSock != "" {
 		env["SSH_AUTH_SOCK"] = containerAgentSock
@@ -478,7 +681,7 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 		Name:           p.containerName(key),
 		Image:          p.cfg.Image,
 		Env:            env,
-		Command:        p.runnerCommand(containerSocketPath),
+		Command:        p.runnerCommand(containerSocketPath, containerEgressPath),
 		WorkingDir:     defaultContainerHome,
 		ReadOnlyRootfs: true,
 		AutoRemove:     true,
@@ -496,10 +699,14 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 			{Target: "/var/run", Options: []string{"mode=0755", "rw"}},
 			{Target: "/var/tmp", Options: []string{"mode=1777", "rw"}},
 		},
-		Labels: map[string]string{
-			"centaurx.user": string(key.user),
-			"centaurx.tab":  string(key.tab),
-		},
+		Labels: labels.Runner(string(key.user), string(key.tab), string(p.scope), time.Now()),
+	}
+	for _, mount := range extraMounts {
+		spec.Mounts = append(spec.Mounts, shipohoy.Mount{Source: mount.Host, Target: mount.Container, ReadOnly: mount.ReadOnly})
+	}
+	p.cfg.Security.Apply(&spec)
+	if p.egress != nil {
+		spec.NetworkMode = shipohoy.NetworkNone
 	}
 	log = log.With("container", spec.Name)
 	log.Trace("runner container spec", "image", spec.Image, "env_keys", len(spec.Env), "mounts", len(spec.Mounts), "tmpfs", len(spec.Tmpfs), "command_len", len(spec.Command))
@@ -509,9 +716,10 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 	if err != nil {
 		log.Warn("runner container ensure failed", "err", err)
 		wrapped := fmt.Errorf("runner container start failed: %w (repo_host=%s sock_host=%s agent_host=%s)", err, hostRepoRoot, hostSocketDir, hostAgentDir)
-		return nil, core.RunnerInfo{}, nil, core.NewRunnerError(core.RunnerErrorContainerStart, "container start", wrapped)
+		return nil, core.RunnerInfo{}, nil, nil, core.NewRunnerError(core.RunnerErrorContainerStart, "container start", wrapped)
 	}
 	log.Info("runner container started", "id", handle.ID())
+	p.logRunnerImage(ctx, log, spec.Image)
 
 	waitCtx, cancel := context.WithTimeout(ctx, p.cfg.SocketWait)
 	defer cancel()
@@ -520,7 +728,7 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 		p.logContainerTail(context.Background(), log, handle, "socket wait failed")
 		_ = p.rt.Stop(context.Background(), handle)
 		_ = p.rt.Remove(context.Background(), handle)
-		return nil, core.RunnerInfo{}, nil, core.NewRunnerError(core.RunnerErrorContainerSocket, "socket wait", fmt.Errorf("runner socket not ready at %s: %w", localSocketPath, err))
+		return nil, core.RunnerInfo{}, nil, nil, core.NewRunnerError(core.RunnerErrorContainerSocket, "socket wait", fmt.Errorf("runner socket not ready at %s: %w", localSocketPath, err))
 	}
 	log.Info("runner socket ready", "socket", localSocketPath)
 
@@ -530,14 +738,15 @@ func (p *Provider) startRunner(ctx context.Context, key tabKey, logTab schema.Ta
 		p.logContainerTail(context.Background(), log, handle, "grpc dial failed")
 		_ = p.rt.Stop(context.Background(), handle)
 		_ = p.rt.Remove(context.Background(), handle)
-		return nil, core.RunnerInfo{}, nil, err
+		return nil, core.RunnerInfo{}, nil, nil, err
 	}
 	info := core.RunnerInfo{
 		RepoRoot:    p.cfg.RunnerRepoRoot,
 		HomeDir:     defaultContainerHome,
 		SSHAuthSock: containerAgentSock,
+		Mounts:      pathMappings(repoRoot, homePath, containerRepoRoot, extraMounts),
 	}
-	return client, info, handle, nil
+	return client, info, handle, egressCancel, nil
 }
 
 func (p *Provider) stopRunner(ctx context.Context, entry *tabRunner, key tabKey, reason string) error {
@@ -554,28 +763,78 @@ func (p *Provider) stopRunner(ctx context.Context, entry *tabRunner, key tabKey,
 	if entry.keepaliveCancel != nil {
 		entry.keepaliveCancel()
 	}
+	if entry.egressCancel != nil {
+		entry.egressCancel()
+	}
 	var errs []error
+	started := time.Now()
+	forced := false
 	if entry.handle != nil {
-		stopCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
-		if err := p.rt.Stop(stopCtx, entry.handle); err != nil {
-			log.Warn("runner stop failed", "err", err)
-			errs = append(errs, err)
+		if budget := stopBudget(ctx, stopTimeout); budget > 0 {
+			stopCtx, cancel := context.WithTimeout(ctx, budget)
+			if err := p.rt.Stop(stopCtx, entry.handle); err != nil {
+				log.Warn("runner stop failed", "err", err)
+				errs = append(errs, err)
+			}
+			cancel()
+		} else {
+			forced = true
+			log.Warn("runner stop skipped; deadline too close, forcing removal")
+		}
+		removeCtx := ctx
+		if ctx.Err() != nil {
+			// Removal still gets its reserve when the deadline already passed.
+			var cancel context.CancelFunc
+			removeCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), forceRemoveReserve)
+			defer cancel()
 		}
-		if err := p.rt.Remove(stopCtx, entry.handle); err != nil {
+		if err := p.rt.Remove(removeCtx, entry.handle); err != nil {
 			log.Warn("runner remove failed", "err", err)
 			errs = append(errs, err)
 		}
 	}
 	socketDir := filepath.Join(p.cfg.SockDir, string(key.user), string(key.tab))
 	_ = os.RemoveAll(socketDir)
-	log.Info("runner stopped", "reason", reason)
+	log.Info("runner stopped", "reason", reason, "forced", forced, "duration_ms", time.Since(started).Milliseconds())
 	if len(errs) > 0 {
 		return errors.Join(errs...)
 	}
 	return nil
 }
 
+// stopBudget returns how long a graceful stop may take under ctx: timeout,
+// or less when the deadline must leave forceRemoveReserve for the removal.
+// It returns 0 when there is no time for a graceful stop.
+func stopBudget(ctx context.Context, timeout time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+	left := time.Until(deadline) - forceRemoveReserve
+	if left < minGracefulStop {
+		return 0
+	}
+	return min(timeout, left)
+}
+
+// logRunnerImage logs the digest and build labels of the image a runner was
+// started from, so support can compare it with a build manifest.
+func (p *Provider) logRunnerImage(ctx context.Context, log pslog.Logger, image string) {
+	inspector, ok := p.rt.(shipohoy.ImageInspector)
+	if !ok {
+		return
+	}
+	info, err := inspector.InspectImage(ctx, image)
+	if err != nil {
+		log.Debug("runner container image inspect failed", "image", image, "err", err)
+		return
+	}
+	log.Info("runner container image", "image", image, "digest", info.Digest,
+		"build_version", info.Labels[buildmanifest.LabelVersion],
+		"build_time", info.Labels[buildmanifest.LabelTime],
+		"base_images", info.Labels[buildmanifest.LabelBaseImages])
+}
+
 func (p *Provider) logContainerTail(ctx context.Context, log pslog.Logger, handle shipohoy.Handle, reason string) {
 	tailer, ok := p.rt.(logTailer)
 	if !ok || tailer == nil || handle == nil {
@@ -663,8 +922,11 @@ func (p *Provider) containerName(key tabKey) string {
 	return fmt.Sprintf("%s-%s-%s", p.cfg.NamePrefix, user, tab)
 }
 
-func (p *Provider) runnerCommand(socketPath string) []string {
+func (p *Provider) runnerCommand(socketPath, egressSocketPath string) []string {
 	cmd := []string{"runner", "--socket-path", socketPath, "--binary", p.cfg.RunnerBinary}
+	if egressSocketPath != "" {
+		cmd = append(cmd, "--egress-proxy-socket", egressSocketPath)
+	}
 	for _, arg := range p.cfg.RunnerArgs {
 		if strings.TrimSpace(arg) == "" {
 			continue
@@ -689,6 +951,19 @@ func (p *Provider) runnerCommand(socketPath string) []string {
 	return cmd
 }
 
+// pathMappings lists the directories mounted into a container, keyed by the
+// paths centaurx sees, so core can map repo paths into the container.
+func pathMappings(repoRoot, homePath, containerRepoRoot string, extra []Mount) []core.PathMapping {
+	mappings := []core.PathMapping{
+		{Host: repoRoot, Runner: containerRepoRoot},
+		{Host: homePath, Runner: defaultContainerHome},
+	}
+	for _, mount := range extra {
+		mappings = append(mappings, core.PathMapping{Host: mount.Host, Runner: mount.Container, ReadOnly: mount.ReadOnly})
+	}
+	return mappings
+}
+
 func (p *Provider) ensureHome(username string) (string, error) {
 	return userhome.EnsureHome(p.cfg.StateDir, username, p.skelDir, p.skelData)
 }
@@ -708,10 +983,7 @@ func (p *Provider) sweep(ctx context.Context, idle time.Duration) {
 
 func (p *Provider) collectIdle(idle time.Duration) {
 	now := time.Now()
-	var toStop []struct {
-		key   tabKey
-		entry *tabRunner
-	}
+	var toStop []stopItem
 	p.mu.Lock()
 	for key, entry := range p.tabs {
 		if entry.wait != nil {
@@ -724,17 +996,14 @@ func (p *Provider) collectIdle(idle time.Duration) {
 		}
 		if idle > 0 && now.Sub(entry.lastUsed) >= idle {
 			delete(p.tabs, key)
-			toStop = append(toStop, struct {
-				key   tabKey
-				entry *tabRunner
-			}{key: key, entry: entry})
+			toStop = append(toStop, stopItem{key: key, entry: entry})
 		}
 	}
 	p.mu.Unlock()
 	for _, item := range toStop {
 		p.logger.Info("runner idle timeout", "user", item.key.user, "tab", item.key.tab, "idle", idle)
-		_ = p.stopRunner(context.Background(), item.entry, item.key, "idle")
 	}
+	_, _ = p.stopAll(context.Background(), toStop, "idle")
 }
 
 func waitForSocket(ctx context.Context, socketPath string, interval time.Duration) error {
@@ -849,14 +1118,14 @@ func newTrackedRunner(base core.Runner, provider *Provider, key tabKey, logTab s
 }
 
 func (t *trackedRunner) Run(ctx context.Context, req core.RunRequest) (core.RunHandle, error) {
-	t.provider.logger.Info("runner exec requested", "user", t.key.user, "tab", t.logTab, "model", req.Model, "resume", req.ResumeSessionID != "", "json", req.JSON, "prompt_len", len(req.Prompt))
+	t.provider.logger.Info("runner exec requested", "user", t.key.user, "tab", t.logTab, "run_id", req.RunID, "model", req.Model, "resume", req.ResumeSessionID != "", "json", req.JSON, "prompt_len", len(req.Prompt))
 	done := t.provider.startRun(t.key, t.logTab, "exec")
 	handle, err := t.base.Run(ctx, req)
 	if err != nil {
 		done()
-		return nil, err
+		return nil, t.wrap("exec", err)
 	}
-	return &trackedRunHandle{RunHandle: handle, done: done}, nil
+	return &trackedRunHandle{RunHandle: handle, done: done, wrap: t.wrap}, nil
 }
 
 func (t *trackedRunner) RunCommand(ctx context.Context, req core.RunCommandRequest) (core.CommandHandle, error) {
@@ -865,7 +1134,7 @@ func (t *trackedRunner) RunCommand(ctx context.Context, req core.RunCommandReque
 	handle, err := t.base.RunCommand(ctx, req)
 	if err != nil {
 		done()
-		return nil, err
+		return nil, t.wrap("command", err)
 	}
 	return &trackedCommandHandle{CommandHandle: handle, done: done}, nil
 }
@@ -876,20 +1145,44 @@ func (t *trackedRunner) Usage(ctx context.Context) (core.UsageInfo, error) {
 		return core.UsageInfo{}, nil
 	}
 	t.provider.logger.Debug("runner usage requested", "user", t.key.user, "tab", t.logTab)
-	return reader.Usage(ctx)
+	info, err := reader.Usage(ctx)
+	return info, t.wrap("usage", err)
+}
+
+func (t *trackedRunner) Models(ctx context.Context) (core.ModelList, error) {
+	lister, ok := t.base.(core.ModelLister)
+	if !ok {
+		return core.ModelList{}, nil
+	}
+	t.provider.logger.Debug("runner model list requested", "user", t.key.user, "tab", t.logTab)
+	list, err := lister.Models(ctx)
+	return list, t.wrap("models", err)
+}
+
+// wrap blames failures on the container environment when its preflight
+// found problems.
+func (t *trackedRunner) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return environmentError(op, err, t.provider.preflightFor(t.key))
 }
 
 func (p *Provider) startRun(key tabKey, logTab schema.TabID, op string) func() {
+	if logTab == "" {
+		logTab = key.tab
+	}
 	p.mu.Lock()
 	entry := p.tabs[key]
 	if entry != nil {
 		entry.running++
 		entry.lastUsed = time.Now()
+		if entry.active == nil {
+			entry.active = make(map[schema.TabID]int)
+		}
+		entry.active[logTab]++
 	}
 	p.mu.Unlock()
-	if logTab == "" {
-		logTab = key.tab
-	}
 	p.logger.Trace("runner activity start", "user", key.user, "tab", logTab, "op", op)
 	var once sync.Once
 	return func() {
@@ -900,6 +1193,11 @@ func (p *Provider) startRun(key tabKey, logTab schema.TabID, op string) func() {
 				if entry.running > 0 {
 					entry.running--
 				}
+				if entry.active[logTab] > 1 {
+					entry.active[logTab]--
+				} else {
+					delete(entry.active, logTab)
+				}
 				entry.lastUsed = time.Now()
 			}
 			p.mu.Unlock()
@@ -911,13 +1209,14 @@ func (p *Provider) startRun(key tabKey, logTab schema.TabID, op string) func() {
 type trackedRunHandle struct {
 	core.RunHandle
 	done func()
+	wrap func(op string, err error) error
 	once sync.Once
 }
 
 func (h *trackedRunHandle) Wait(ctx context.Context) (core.RunResult, error) {
 	result, err := h.RunHandle.Wait(ctx)
 	h.once.Do(h.done)
-	return result, err
+	return result, h.wrap("exec", err)
 }
 
 func (h *trackedRunHandle) Done() <-chan struct{} {
@@ -927,6 +1226,13 @@ func (h *trackedRunHandle) Done() <-chan struct{} {
 	return nil
 }
 
+func (h *trackedRunHandle) Reattach(ctx context.Context) error {
+	if reattacher, ok := h.RunHandle.(core.RunReattacher); ok {
+		return reattacher.Reattach(ctx)
+	}
+	return errors.New("runner does not support reattaching")
+}
+
 func (h *trackedRunHandle) Close() error {
 	err := h.RunHandle.Close()
 	h.once.Do(h.done)
@@ -5,15 +5,20 @@ import (
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
+	"fmt"
 	"net"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"pkt.systems/centaurx/core"
 	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
 	"pkt.systems/centaurx/internal/sshagent"
 	"pkt.systems/centaurx/schema"
 )
@@ -93,6 +98,8 @@ func TestRunnerSpecSetsAutoRemove(t *testing.T) {
 		t.Fatalf("socket dir: %v", err)
 	}
 
+	projectsDir := t.TempDir()
+	datasetDir := t.TempDir()
 	runtime := &captureRuntime{socketPath: hostSocketPath}
 	provider, err := NewProvider(context.Background(), Config{
 		Image:           "test",
@@ -108,23 +115,55 @@ func TestRunnerSpecSetsAutoRemove(t *testing.T) {
 		ContainerScope:  "tab",
 		CPUPercent:      70,
 		MemoryPercent:   70,
+		Mounts:          []Mount{{Host: projectsDir, Container: "/projects", ReadOnly: true}},
+		UserMounts: func(user schema.UserID) []Mount {
+			return []Mount{{Host: datasetDir, Container: "/data", ReadOnly: true}}
+		},
 	}, runtime, manager)
 	if err != nil {
 		t.Fatalf("new provider: %v", err)
 	}
 
-	if _, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tab}); err != nil {
+	resp, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tab})
+	if err != nil {
 		t.Fatalf("runner for: %v", err)
 	}
+	wantMappings := []core.PathMapping{
+		{Host: filepath.Join(repoRoot, string(user)), Runner: "/repos/tester"},
+		{Host: filepath.Join(stateDir, "home", string(user)), Runner: defaultContainerHome},
+		{Host: projectsDir, Runner: "/projects", ReadOnly: true},
+		{Host: datasetDir, Runner: "/data", ReadOnly: true},
+	}
+	if !slices.Equal(resp.Info.Mounts, wantMappings) {
+		t.Fatalf("expected mappings %+v, got %+v", wantMappings, resp.Info.Mounts)
+	}
 	if runtime.listener != nil {
 		_ = runtime.listener.Close()
 	}
 	if runtime.lastSpec == nil {
 		t.Fatalf("expected spec to be captured")
 	}
+	for _, want := range []shipohoy.Mount{
+		{Source: projectsDir, Target: "/projects", ReadOnly: true},
+		{Source: datasetDir, Target: "/data", ReadOnly: true},
+	} {
+		if !slices.Contains(runtime.lastSpec.Mounts, want) {
+			t.Fatalf("expected mount %+v in %+v", want, runtime.lastSpec.Mounts)
+		}
+	}
 	if !runtime.lastSpec.AutoRemove {
 		t.Fatalf("expected AutoRemove=true")
 	}
+	specLabels := runtime.lastSpec.Labels
+	if !labels.IsManaged(specLabels) || specLabels[labels.User] != string(user) || specLabels[labels.Tab] != string(tab) || specLabels[labels.Scope] != "tab" {
+		t.Fatalf("unexpected labels %v", specLabels)
+	}
+	if _, ok := labels.CreatedTime(specLabels); !ok {
+		t.Fatalf("expected created-at label, got %v", specLabels)
+	}
+	if spec := runtime.lastSpec; spec.NoNewPrivileges || spec.SeccompProfile != "" || spec.DropCapabilities != nil || spec.NetworkMode != shipohoy.NetworkDefault {
+		t.Fatalf("expected runtime default hardening, got %+v", spec)
+	}
 }
 
 func TestRunnerUsesContainerAgentSock(t *testing.T) {
@@ -231,10 +270,17 @@ func TestRunnerUserScopeSharesContainer(t *testing.T) {
 		SocketRetryWait: 10 * time.Millisecond,
 		CPUPercent:      70,
 		MemoryPercent:   70,
+		Security: Security{
+			NoNewPrivileges:  true,
+			DropCapabilities: []string{"NET_RAW"},
+			NetworkMode:      shipohoy.NetworkNone,
+		},
 	}, runtime, manager)
 	if err != nil {
 		t.Fatalf("new provider: %v", err)
 	}
+	recorder := &activityRecorder{}
+	provider.SetActivityRecorder(recorder)
 
 	if _, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tab1}); err != nil {
 		t.Fatalf("runner for tab1: %v", err)
@@ -245,6 +291,12 @@ func TestRunnerUserScopeSharesContainer(t *testing.T) {
 	if runtime.ensureCount != 1 {
 		t.Fatalf("expected one container start, got %d", runtime.ensureCount)
 	}
+	if spec := runtime.lastSpec; !spec.NoNewPrivileges || !slices.Equal(spec.DropCapabilities, []string{"NET_RAW"}) || spec.NetworkMode != shipohoy.NetworkNone || !spec.ReadOnlyRootfs {
+		t.Fatalf("expected hardening options on spec, got %+v", spec)
+	}
+	if specLabels := runtime.lastSpec.Labels; specLabels[labels.User] != string(user) || specLabels[labels.Scope] != "user" || specLabels[labels.Tab] != "" {
+		t.Fatalf("unexpected labels %v", specLabels)
+	}
 
 	if err := provider.CloseTab(context.Background(), core.RunnerCloseRequest{UserID: user, TabID: tab1}); err != nil {
 		t.Fatalf("close tab1: %v", err)
@@ -262,6 +314,95 @@ func TestRunnerUserScopeSharesContainer(t *testing.T) {
 	if runtime.removeCount != 1 {
 		t.Fatalf("expected one remove after last tab, got %d", runtime.removeCount)
 	}
+
+	_ = runtime.listener.Close()
+	runtime.listener = nil
+	if _, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tab1}); err != nil {
+		t.Fatalf("runner restart: %v", err)
+	}
+	want := []schema.ActivityKind{schema.ActivityRunnerCreated, schema.ActivityRunnerRecreated}
+	if len(recorder.requests) != len(want) {
+		t.Fatalf("expected %d activity entries, got %+v", len(want), recorder.requests)
+	}
+	for i, req := range recorder.requests {
+		if req.UserID != user || req.Kind != want[i] || req.Detail != "fake" {
+			t.Fatalf("unexpected activity %d: %+v", i, req)
+		}
+	}
+}
+
+func TestProviderListAndCloseUser(t *testing.T) {
+	temp := t.TempDir()
+	repoRoot := filepath.Join(temp, "repos")
+	stateDir := filepath.Join(temp, "state")
+	agentDir := filepath.Join(stateDir, "agents")
+	sockDir := filepath.Join(stateDir, "sockets")
+	if err := os.MkdirAll(repoRoot, 0o755); err != nil {
+		t.Fatalf("repo root: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		t.Fatalf("state dir: %v", err)
+	}
+	manager, err := sshagent.NewManager(fakeKeyProvider{}, agentDir)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	user := schema.UserID("tester")
+	runtime := &captureRuntime{socketPath: filepath.Join(sockDir, string(user), "runner.sock")}
+	provider, err := NewProvider(context.Background(), Config{
+		Image:           "test",
+		RepoRoot:        repoRoot,
+		RunnerRepoRoot:  "/repos",
+		HostRepoRoot:    repoRoot,
+		SockDir:         sockDir,
+		StateDir:        stateDir,
+		SSHAgentDir:     agentDir,
+		RunnerBinary:    "codex",
+		ContainerScope:  "user",
+		SocketWait:      time.Second,
+		SocketRetryWait: 10 * time.Millisecond,
+	}, runtime, manager)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	for _, tabID := range []schema.TabID{"tab2", "tab1"} {
+		if _, err := provider.RunnerFor(context.Background(), core.RunnerRequest{UserID: user, TabID: tabID}); err != nil {
+			t.Fatalf("runner for %s: %v", tabID, err)
+		}
+	}
+
+	runners, err := provider.ListRunners(context.Background())
+	if err != nil {
+		t.Fatalf("list runners: %v", err)
+	}
+	if len(runners) != 1 || runners[0].UserID != user || runners[0].Container != "fake" || !slices.Equal(runners[0].Tabs, []schema.TabID{"tab1", "tab2"}) {
+		t.Fatalf("unexpected runners %+v", runners)
+	}
+
+	if _, err := provider.CloseUser(context.Background(), "other"); err != nil {
+		t.Fatalf("close other user: %v", err)
+	}
+	closed, err := provider.CloseUser(context.Background(), user)
+	if err != nil {
+		t.Fatalf("close user: %v", err)
+	}
+	if closed != 1 || runtime.stopCount != 1 || runtime.removeCount != 1 {
+		t.Fatalf("expected one runner closed, got %d (stops %d, removes %d)", closed, runtime.stopCount, runtime.removeCount)
+	}
+	if runners, _ := provider.ListRunners(context.Background()); len(runners) != 0 {
+		t.Fatalf("expected no runners after close, got %+v", runners)
+	}
+}
+
+type activityRecorder struct {
+	requests []schema.RecordActivityRequest
+}
+
+func (r *activityRecorder) RecordActivity(_ context.Context, req schema.RecordActivityRequest) (schema.RecordActivityResponse, error) {
+	r.requests = append(r.requests, req)
+	return schema.RecordActivityResponse{}, nil
 }
 
 type fakeRuntime struct{}
@@ -282,6 +423,9 @@ func (fakeRuntime) WaitForLog(context.Context, shipohoy.Handle, shipohoy.WaitLog
 	return nil
 }
 func (fakeRuntime) Janitor(context.Context, shipohoy.JanitorSpec) (int, error) { return 0, nil }
+func (fakeRuntime) ListManaged(context.Context, map[string]string) ([]shipohoy.ManagedContainer, error) {
+	return nil, nil
+}
 
 type fakeHandle struct{}
 
@@ -328,6 +472,9 @@ func (c *captureRuntime) WaitForLog(context.Context, shipohoy.Handle, shipohoy.W
 	return nil
 }
 func (c *captureRuntime) Janitor(context.Context, shipohoy.JanitorSpec) (int, error) { return 0, nil }
+func (c *captureRuntime) ListManaged(context.Context, map[string]string) ([]shipohoy.ManagedContainer, error) {
+	return nil, nil
+}
 
 type fakeKeyProvider struct{}
 
@@ -338,3 +485,147 @@ func (fakeKeyProvider) LoadPrivateKey(string) (crypto.PrivateKey, error) {
 	}
 	return key, nil
 }
+
+func TestCloseAllStopsInParallel(t *testing.T) {
+	const containers = 16
+	runtime := &slowStopRuntime{delay: 100 * time.Millisecond}
+	provider := newCloseTestProvider(t, runtime, 8, containers)
+
+	started := time.Now()
+	if err := provider.CloseAll(context.Background()); err != nil {
+		t.Fatalf("close all: %v", err)
+	}
+	elapsed := time.Since(started)
+	// Sequential stops would take containers*delay = 1.6s; eight workers
+	// need two rounds.
+	if elapsed > 800*time.Millisecond {
+		t.Fatalf("expected parallel close, took %s", elapsed)
+	}
+	if runtime.stops.Load() != containers || runtime.removes.Load() != containers {
+		t.Fatalf("expected %d stops and removes, got %d and %d", containers, runtime.stops.Load(), runtime.removes.Load())
+	}
+	if peak := runtime.peak.Load(); peak > 8 || peak < 2 {
+		t.Fatalf("expected between 2 and 8 concurrent stops, got %d", peak)
+	}
+	if runners, _ := provider.ListRunners(context.Background()); len(runners) != 0 {
+		t.Fatalf("expected no runners after close, got %+v", runners)
+	}
+}
+
+func TestCloseAllForcesRemovalNearDeadline(t *testing.T) {
+	runtime := &slowStopRuntime{delay: time.Minute, failRemove: "centaurx-runner-user1"}
+	provider := newCloseTestProvider(t, runtime, 2, 4)
+
+	// The deadline leaves less than forceRemoveReserve+minGracefulStop, so
+	// containers are removed without a graceful stop.
+	ctx, cancel := context.WithTimeout(context.Background(), forceRemoveReserve+minGracefulStop/2)
+	defer cancel()
+	started := time.Now()
+	err := provider.CloseAll(ctx)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected forced removal to skip the slow stop, took %s", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "centaurx-runner-user1") {
+		t.Fatalf("expected the failed removal to be reported, got %v", err)
+	}
+	if runtime.stops.Load() != 0 || runtime.removes.Load() != 4 {
+		t.Fatalf("expected 0 stops and 4 removes past the failure, got %d and %d", runtime.stops.Load(), runtime.removes.Load())
+	}
+}
+
+func TestStopBudget(t *testing.T) {
+	if got := stopBudget(context.Background(), stopTimeout); got != stopTimeout {
+		t.Fatalf("expected full timeout without deadline, got %s", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), forceRemoveReserve+5*time.Second)
+	defer cancel()
+	if got := stopBudget(ctx, stopTimeout); got <= 4*time.Second || got > 5*time.Second {
+		t.Fatalf("expected budget bounded by the deadline, got %s", got)
+	}
+	short, cancelShort := context.WithTimeout(context.Background(), forceRemoveReserve)
+	defer cancelShort()
+	if got := stopBudget(short, stopTimeout); got != 0 {
+		t.Fatalf("expected no graceful stop near the deadline, got %s", got)
+	}
+}
+
+// newCloseTestProvider returns a provider tracking count user runners with
+// their containers on runtime.
+func newCloseTestProvider(t *testing.T, runtime shipohoy.Runtime, parallelism, count int) *Provider {
+	t.Helper()
+	temp := t.TempDir()
+	stateDir := filepath.Join(temp, "state")
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		t.Fatalf("state dir: %v", err)
+	}
+	agentDir := filepath.Join(stateDir, "agents")
+	manager, err := sshagent.NewManager(fakeKeyProvider{}, agentDir)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	provider, err := NewProvider(context.Background(), Config{
+		Image:            "test",
+		RepoRoot:         filepath.Join(temp, "repos"),
+		RunnerRepoRoot:   "/repos",
+		SockDir:          filepath.Join(stateDir, "sockets"),
+		StateDir:         stateDir,
+		SSHAgentDir:      agentDir,
+		ContainerScope:   "user",
+		CloseParallelism: parallelism,
+	}, runtime, manager)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	provider.mu.Lock()
+	for i := range count {
+		user := schema.UserID(fmt.Sprintf("user%d", i))
+		key := provider.keyFor(user, "")
+		provider.tabs[key] = &tabRunner{handle: namedHandle(provider.containerName(key)), tabs: map[schema.TabID]struct{}{}}
+	}
+	provider.mu.Unlock()
+	return provider
+}
+
+type namedHandle string
+
+func (h namedHandle) Name() string { return string(h) }
+func (h namedHandle) ID() string   { return string(h) }
+
+// slowStopRuntime takes delay to stop a container, or until the stop
+// context ends, and fails the removal of failRemove.
+type slowStopRuntime struct {
+	fakeRuntime
+	delay      time.Duration
+	failRemove string
+	stops      atomic.Int32
+	removes    atomic.Int32
+	active     atomic.Int32
+	peak       atomic.Int32
+}
+
+func (r *slowStopRuntime) Stop(ctx context.Context, _ shipohoy.Handle) error {
+	r.stops.Add(1)
+	active := r.active.Add(1)
+	defer r.active.Add(-1)
+	for {
+		peak := r.peak.Load()
+		if active <= peak || r.peak.CompareAndSwap(peak, active) {
+			break
+		}
+	}
+	select {
+	case <-time.After(r.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *slowStopRuntime) Remove(_ context.Context, handle shipohoy.Handle) error {
+	r.removes.Add(1)
+	if handle.Name() == r.failRemove {
+		return fmt.Errorf("remove %s: device busy", handle.Name())
+	}
+	return nil
+}
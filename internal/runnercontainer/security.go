@@ -0,0 +1,43 @@
+package runnercontainer
+
+import (
+	"strings"
+
+	"pkt.systems/centaurx/internal/shipohoy"
+)
+
+// Security holds the hardening options applied to runner containers. The
+// zero value keeps the runtime defaults.
+type Security struct {
+	NoNewPrivileges  bool
+	SeccompProfile   string
+	DropCapabilities []string
+	NetworkMode      shipohoy.NetworkMode
+}
+
+// Apply sets the hardening options on spec.
+func (s Security) Apply(spec *shipohoy.ContainerSpec) {
+	spec.NoNewPrivileges = s.NoNewPrivileges
+	spec.SeccompProfile = s.SeccompProfile
+	spec.DropCapabilities = append([]string(nil), s.DropCapabilities...)
+	spec.NetworkMode = s.NetworkMode
+}
+
+// Active describes the enabled hardening options, read-only rootfs first
+// since runner containers always use it.
+func (s Security) Active() []string {
+	out := []string{"read-only rootfs"}
+	if s.NoNewPrivileges {
+		out = append(out, "no-new-privileges")
+	}
+	if s.SeccompProfile != "" {
+		out = append(out, "seccomp="+s.SeccompProfile)
+	}
+	if len(s.DropCapabilities) > 0 {
+		out = append(out, "drop-capabilities="+strings.Join(s.DropCapabilities, ","))
+	}
+	if s.NetworkMode != shipohoy.NetworkDefault {
+		out = append(out, "network="+string(s.NetworkMode))
+	}
+	return out
+}
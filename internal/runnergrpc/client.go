@@ -8,6 +8,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -15,6 +16,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/logx"
 	"pkt.systems/centaurx/internal/runnerpb"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
@@ -85,11 +87,30 @@ func (c *Client) Usage(ctx context.Context) (core.UsageInfo, error) {
 	}, nil
 }
 
+// Models lists the models available to the runner's codex account.
+func (c *Client) Models(ctx context.Context) (core.ModelList, error) {
+	if c.client == nil {
+		return core.ModelList{}, errors.New("runner client not initialized")
+	}
+	resp, err := c.client.ListModels(ctx, &runnerpb.ListModelsRequest{})
+	if err != nil {
+		return core.ModelList{}, err
+	}
+	list := core.ModelList{Listed: resp.GetListed()}
+	for _, model := range resp.GetModels() {
+		list.Models = append(list.Models, schema.ModelID(model))
+	}
+	return list, nil
+}
+
 // Run starts a codex exec session via gRPC.
 func (c *Client) Run(ctx context.Context, req core.RunRequest) (core.RunHandle, error) {
-	runID := newRunID()
-	log := pslog.Ctx(ctx).With("run_id", runID)
-	log.Info("runner grpc exec start", "model", req.Model, "reasoning_effort", req.ModelReasoningEffort, "json", req.JSON)
+	runID := string(req.RunID)
+	if runID == "" {
+		runID = newRunID()
+	}
+	log := logx.WithRun(ctx, schema.RunID(runID))
+	log.Info("runner grpc exec start", "model", req.Model, "reasoning_effort", req.ModelReasoningEffort, "json", req.JSON, "extra_args", req.ExtraArgs)
 	log.Debug("runner grpc exec request", "workdir", req.WorkingDir, "ssh_auth_sock", req.SSHAuthSock != "", "prompt_len", len(req.Prompt), "resume", req.ResumeSessionID != "")
 	if req.ResumeSessionID != "" {
 		stream, err := c.client.ExecResume(ctx, &runnerpb.ExecResumeRequest{
@@ -101,12 +122,14 @@ func (c *Client) Run(ctx context.Context, req core.RunRequest) (core.RunHandle,
 			ResumeSessionId:      string(req.ResumeSessionID),
 			Json:                 req.JSON,
 			SshAuthSock:          req.SSHAuthSock,
+			ExtraArgs:            req.ExtraArgs,
+			EnableWebSearch:      req.EnableWebSearch,
 		})
 		if err != nil {
 			logGRPCError(log, "runner grpc exec failed", err)
 			return nil, wrapRunnerError("exec", err)
 		}
-		return newRunHandle(c.client, runID, stream, log), nil
+		return newRunHandle(ctx, c.client, runID, stream, log), nil
 	}
 	stream, err := c.client.Exec(ctx, &runnerpb.ExecRequest{
 		RunId:                runID,
@@ -116,20 +139,22 @@ func (c *Client) Run(ctx context.Context, req core.RunRequest) (core.RunHandle,
 		ModelReasoningEffort: string(req.ModelReasoningEffort),
 		Json:                 req.JSON,
 		SshAuthSock:          req.SSHAuthSock,
+		ExtraArgs:            req.ExtraArgs,
+		EnableWebSearch:      req.EnableWebSearch,
 	})
 	if err != nil {
 		logGRPCError(log, "runner grpc exec failed", err)
 		return nil, wrapRunnerError("exec", err)
 	}
-	return newRunHandle(c.client, runID, stream, log), nil
+	return newRunHandle(ctx, c.client, runID, stream, log), nil
 }
 
 // RunCommand executes a command via gRPC.
 func (c *Client) RunCommand(ctx context.Context, req core.RunCommandRequest) (core.CommandHandle, error) {
 	runID := newRunID()
 	log := pslog.Ctx(ctx).With("run_id", runID)
-	log.Trace("runner grpc command start", "shell", req.UseShell)
-	log.Debug("runner grpc command request", "workdir", req.WorkingDir, "command_len", len(req.Command), "ssh_auth_sock", req.SSHAuthSock != "")
+	log.Trace("runner grpc command start", "shell", req.UseShell, "pty", req.PTY)
+	log.Debug("runner grpc command request", "workdir", req.WorkingDir, "command_len", len(req.Command), "stdin_len", len(req.Stdin), "save_output", req.SaveOutput != "", "ssh_auth_sock", req.SSHAuthSock != "")
 	if req.Command != "" {
 		log.Trace("runner grpc command", "command", req.Command)
 	}
@@ -139,6 +164,11 @@ func (c *Client) RunCommand(ctx context.Context, req core.RunCommandRequest) (co
 		Command:     req.Command,
 		UseShell:    req.UseShell,
 		SshAuthSock: req.SSHAuthSock,
+		Pty:         req.PTY,
+		Rows:        clampTermSize(req.Rows),
+		Cols:        clampTermSize(req.Cols),
+		Stdin:       req.Stdin,
+		SaveOutput:  req.SaveOutput,
 	})
 	if err != nil {
 		logGRPCError(log, "runner grpc command failed", err)
@@ -151,31 +181,36 @@ type grpcStream interface {
 	Recv() (*runnerpb.RunnerEvent, error)
 }
 
+// runHandle follows an exec run over one stream at a time. When a stream
+// breaks, Reattach continues the run on a new one; events, done and
+// streamErr belong to the current stream.
 type runHandle struct {
 	client runnerpb.RunnerClient
 	runID  string
-	stream grpcStream
 	logger pslog.Logger
 
-	events chan schema.ExecEvent
-	done   chan struct{}
-
 	mu        sync.Mutex
+	events    chan schema.ExecEvent
+	done      chan struct{}
+	lastSeq   uint64
+	finished  bool
 	result    core.RunResult
 	runErr    error
 	streamErr error
 }
 
-func newRunHandle(client runnerpb.RunnerClient, runID string, stream grpcStream, logger pslog.Logger) *runHandle {
+func newRunHandle(ctx context.Context, client runnerpb.RunnerClient, runID string, stream grpcStream, logger pslog.Logger) *runHandle {
 	h := &runHandle{
 		client: client,
 		runID:  runID,
-		stream: stream,
 		logger: logger,
 		events: make(chan schema.ExecEvent, 256),
 		done:   make(chan struct{}),
 	}
-	go h.consume()
+	go h.consume(stream, h.events, h.done)
+	// The runner keeps a run going for a while after its stream breaks, so
+	// cancelling the run's context has to stop it explicitly.
+	context.AfterFunc(ctx, h.kill)
 	return h
 }
 
@@ -193,7 +228,7 @@ func (h *runHandle) Signal(ctx context.Context, sig core.ProcessSignal) error {
 
 func (h *runHandle) Wait(ctx context.Context) (core.RunResult, error) {
 	select {
-	case <-h.done:
+	case <-h.Done():
 		h.mu.Lock()
 		defer h.mu.Unlock()
 		if h.runErr != nil {
@@ -209,6 +244,8 @@ func (h *runHandle) Wait(ctx context.Context) (core.RunResult, error) {
 }
 
 func (h *runHandle) Done() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.done
 }
 
@@ -216,11 +253,76 @@ func (h *runHandle) Close() error {
 	return nil
 }
 
-func (h *runHandle) consume() {
-	defer close(h.events)
-	defer close(h.done)
+// Reattach continues a run whose stream broke on a new Attach stream,
+// after the last event received.
+func (h *runHandle) Reattach(ctx context.Context) error {
+	h.mu.Lock()
+	if h.finished {
+		h.mu.Unlock()
+		return errors.New("run already finished")
+	}
+	select {
+	case <-h.done:
+	default:
+		h.mu.Unlock()
+		return errors.New("run stream still open")
+	}
+	after := h.lastSeq
+	h.mu.Unlock()
+
+	log := h.logger
+	if log == nil {
+		log = pslog.Ctx(ctx)
+	}
+	log.Info("runner grpc exec reattach", "after_seq", after)
+	stream, err := h.client.Attach(ctx, &runnerpb.AttachRequest{RunId: h.runID, AfterSeq: after})
+	if err != nil {
+		logGRPCError(log, "runner grpc exec reattach failed", err)
+		return wrapRunnerError("attach", err)
+	}
+	// The runner confirms a known run with a STARTED status before
+	// replaying, so a failed attach surfaces here rather than as a second
+	// broken stream.
+	msg, err := stream.Recv()
+	if err != nil {
+		logGRPCError(log, "runner grpc exec reattach failed", err)
+		return wrapRunnerError("attach", err)
+	}
+	if msg.GetStatus().GetState() != runnerpb.RunState_RUN_STATE_STARTED {
+		return errors.New("unexpected attach response")
+	}
+
+	events := make(chan schema.ExecEvent, 256)
+	done := make(chan struct{})
+	h.mu.Lock()
+	h.events = events
+	h.done = done
+	h.streamErr = nil
+	h.mu.Unlock()
+	go h.consume(stream, events, done)
+	return nil
+}
+
+// kill stops the run on the runner unless it has finished.
+func (h *runHandle) kill() {
+	h.mu.Lock()
+	finished := h.finished
+	h.mu.Unlock()
+	if finished {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Signal(ctx, core.ProcessSignalKILL); err != nil && h.logger != nil {
+		h.logger.Debug("runner grpc exec kill failed", "err", err)
+	}
+}
+
+func (h *runHandle) consume(stream grpcStream, events chan schema.ExecEvent, done chan struct{}) {
+	defer close(events)
+	defer close(done)
 	for {
-		msg, err := h.stream.Recv()
+		msg, err := stream.Recv()
 		if err != nil {
 			if h.logger != nil {
 				logGRPCError(h.logger, "runner grpc exec stream failed", err)
@@ -230,6 +332,11 @@ func (h *runHandle) consume() {
 			h.mu.Unlock()
 			return
 		}
+		if msg.Seq > 0 {
+			h.mu.Lock()
+			h.lastSeq = msg.Seq
+			h.mu.Unlock()
+		}
 		switch payload := msg.Payload.(type) {
 		case *runnerpb.RunnerEvent_Exec:
 			if payload.Exec != nil {
@@ -240,7 +347,7 @@ func (h *runHandle) consume() {
 					}
 					h.logger.Trace("runner grpc exec event", "type", payload.Exec.GetType().String(), "item_type", itemType)
 				}
-				h.events <- fromPBExecEvent(payload.Exec)
+				events <- fromPBExecEvent(payload.Exec)
 			}
 		case *runnerpb.RunnerEvent_Status:
 			if payload.Status != nil {
@@ -251,6 +358,7 @@ func (h *runHandle) consume() {
 					h.logger.Info("runner grpc exec finished", "state", payload.Status.State.String(), "exit_code", payload.Status.ExitCode)
 				}
 				h.mu.Lock()
+				h.finished = true
 				h.result = core.RunResult{ExitCode: int(payload.Status.ExitCode)}
 				if payload.Status.State == runnerpb.RunState_RUN_STATE_FAILED {
 					if payload.Status.Message != "" {
@@ -274,8 +382,11 @@ type eventStream struct {
 }
 
 func (s *eventStream) Next(ctx context.Context) (schema.ExecEvent, error) {
+	s.handle.mu.Lock()
+	events := s.handle.events
+	s.handle.mu.Unlock()
 	select {
-	case event, ok := <-s.handle.events:
+	case event, ok := <-events:
 		if !ok {
 			s.handle.mu.Lock()
 			defer s.handle.mu.Unlock()
@@ -334,6 +445,15 @@ func (h *commandHandle) Signal(ctx context.Context, sig core.ProcessSignal) erro
 	return err
 }
 
+func (h *commandHandle) Resize(ctx context.Context, rows, cols int) error {
+	_, err := h.client.ResizeCommand(ctx, &runnerpb.ResizeRequest{
+		RunId: h.runID,
+		Rows:  clampTermSize(rows),
+		Cols:  clampTermSize(cols),
+	})
+	return err
+}
+
 func (h *commandHandle) Wait(ctx context.Context) (core.RunResult, error) {
 	select {
 	case <-h.done:
@@ -390,7 +510,12 @@ func (h *commandHandle) consume() {
 					h.logger.Trace("runner grpc command finished", "state", payload.Status.State.String(), "exit_code", payload.Status.ExitCode)
 				}
 				h.mu.Lock()
-				h.result = core.RunResult{ExitCode: int(payload.Status.ExitCode)}
+				h.result = core.RunResult{
+					ExitCode:      int(payload.Status.ExitCode),
+					SavedOutput:   payload.Status.SavedOutput,
+					SavedLines:    int(payload.Status.SavedLines),
+					OrphansKilled: payload.Status.OrphansKilled,
+				}
 				if payload.Status.State == runnerpb.RunState_RUN_STATE_FAILED {
 					if payload.Status.Message != "" {
 						h.runErr = errors.New(payload.Status.Message)
@@ -491,11 +616,22 @@ func toPBSignal(sig core.ProcessSignal) runnerpb.ProcessSignal {
 		return runnerpb.ProcessSignal_PROCESS_SIGNAL_TERM
 	case core.ProcessSignalKILL:
 		return runnerpb.ProcessSignal_PROCESS_SIGNAL_KILL
+	case core.ProcessSignalINT:
+		return runnerpb.ProcessSignal_PROCESS_SIGNAL_INT
 	default:
 		return runnerpb.ProcessSignal_PROCESS_SIGNAL_UNSPECIFIED
 	}
 }
 
+// clampTermSize converts a terminal dimension for the wire; values outside
+// 0..65535 become 0, which the runner replaces with its default.
+func clampTermSize(n int) uint32 {
+	if n <= 0 || n > 0xffff {
+		return 0
+	}
+	return uint32(n)
+}
+
 func fromPBCommandOutput(output *runnerpb.CommandOutput) core.CommandOutput {
 	if output == nil {
 		return core.CommandOutput{}
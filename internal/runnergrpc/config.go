@@ -8,4 +8,17 @@ type Config struct {
 	KeepaliveInterval time.Duration
 	KeepaliveMisses   int
 	CommandNice       int
+	// ReattachWindow is how long an exec run whose client stream broke keeps
+	// running without a client, and how long a finished run's events can
+	// still be fetched with Attach. 0 uses DefaultReattachWindow.
+	ReattachWindow time.Duration
+	// ReplayEvents is how many of an exec run's latest events are kept for
+	// Attach. 0 uses DefaultReplayEvents.
+	ReplayEvents int
 }
+
+// DefaultReattachWindow is the default Config.ReattachWindow.
+const DefaultReattachWindow = 30 * time.Second
+
+// DefaultReplayEvents is the default Config.ReplayEvents.
+const DefaultReplayEvents = 4096
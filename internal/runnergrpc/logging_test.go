@@ -226,3 +226,50 @@ func requireLog(t *testing.T, entries []logEntry, level, message string) {
 	}
 	t.Fatalf("expected log level=%q message=%q; got %d entries", level, message, len(entries))
 }
+
+func TestRunnerLogsRequestRunID(t *testing.T) {
+	capture := newLogCapture(t)
+	logger := pslog.NewWithOptions(capture, pslog.Options{
+		Mode:          pslog.ModeStructured,
+		NoColor:       true,
+		VerboseFields: true,
+		MinLevel:      pslog.DebugLevel,
+	})
+	ctx := pslog.ContextWithLogger(context.Background(), logger)
+	runner := &fakeRunner{events: []schema.ExecEvent{{Type: schema.EventTurnCompleted}}}
+	client, cleanup := startTestServerWithContext(ctx, t, runner)
+	defer cleanup()
+
+	runCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	const runID = schema.RunID("7f3a0c1d2e3f4a5b")
+	handle, err := client.Run(runCtx, core.RunRequest{RunID: runID, Prompt: "hello", JSON: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := handle.Wait(runCtx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	runner.mu.Lock()
+	got := runner.lastReq.RunID
+	runner.mu.Unlock()
+	if got != runID {
+		t.Fatalf("runner got run id %q, want %q", got, runID)
+	}
+	for _, message := range []string{"runner grpc exec start", "runner exec start", "runner exec finished"} {
+		found := false
+		for _, entry := range capture.Entries() {
+			if entry.Message != message {
+				continue
+			}
+			found = true
+			if entry.Fields["run_id"] != string(runID) {
+				t.Fatalf("%q logged run_id %v, want %q", message, entry.Fields["run_id"], runID)
+			}
+		}
+		if !found {
+			t.Fatalf("expected log %q", message)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package runnergrpc
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"pkt.systems/centaurx/internal/runnerpb"
+)
+
+const (
+	defaultPTYRows = 24
+	defaultPTYCols = 80
+)
+
+// ptySize returns n as a terminal dimension, or def when unset or too large.
+func ptySize(n uint32, def uint16) uint16 {
+	if n == 0 || n > 0xffff {
+		return def
+	}
+	return uint16(n)
+}
+
+// ptyProcess is a command attached to a pty; master is the side the runner
+// reads from.
+type ptyProcess struct {
+	cmdProcess
+	master *os.File
+}
+
+// Resize changes the window size of the command's terminal.
+func (p ptyProcess) Resize(rows, cols uint16) error {
+	return setPTYSize(p.master, rows, cols)
+}
+
+// readPTYStream streams the terminal output as stdout lines with cursor
+// movement and other terminal control sequences removed. Reading the master
+// fails with EIO once the child side is closed, which ends the stream.
+func readPTYStream(wg *sync.WaitGroup, reader io.Reader, outputCh chan<- *runnerpb.CommandOutput) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(reader)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+	for scanner.Scan() {
+		outputCh <- &runnerpb.CommandOutput{Stream: runnerpb.StreamKind_STREAM_KIND_STDOUT, Text: sanitizePTYLine(scanner.Text())}
+	}
+}
+
+// sanitizePTYLine reduces a line of terminal output to plain text with SGR
+// color sequences. A carriage return restarts the line, so only the last
+// redraw of a progress line is kept; backspace removes the previous byte.
+// Other escape sequences and control characters are dropped.
+func sanitizePTYLine(line string) string {
+	if idx := strings.LastIndexByte(strings.TrimRight(line, "\r"), '\r'); idx >= 0 {
+		line = line[idx+1:]
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == 0x1b:
+			i = skipEscape(line, i, &b)
+		case c == '\b':
+			if out := b.String(); out != "" {
+				b.Reset()
+				b.WriteString(out[:len(out)-1])
+			}
+		case c == '\t' || c >= 0x20 && c != 0x7f:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// skipEscape consumes the escape sequence starting at line[i] and returns the
+// index of its last byte. SGR sequences (ESC [ ... m) are copied to b.
+func skipEscape(line string, i int, b *strings.Builder) int {
+	if i+1 >= len(line) {
+		return i
+	}
+	switch line[i+1] {
+	case '[':
+		j := i + 2
+		for j < len(line) && (line[j] < 0x40 || line[j] > 0x7e) {
+			j++
+		}
+		if j >= len(line) {
+			return len(line) - 1
+		}
+		if line[j] == 'm' {
+			b.WriteString(line[i : j+1])
+		}
+		return j
+	case ']', 'P', '_', '^':
+		// OSC, DCS and friends end with BEL or ESC \.
+		for j := i + 2; j < len(line); j++ {
+			if line[j] == 0x07 {
+				return j
+			}
+			if line[j] == 0x1b && j+1 < len(line) && line[j+1] == '\\' {
+				return j + 1
+			}
+		}
+		return len(line) - 1
+	case '(', ')', '*', '+', '#', '%':
+		// Character set selection takes one more byte.
+		return min(i+2, len(line)-1)
+	default:
+		return i + 1
+	}
+}
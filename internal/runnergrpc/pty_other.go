@@ -0,0 +1,23 @@
+//go:build !linux
+
+package runnergrpc
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errPTYUnsupported = errors.New("pty commands are only supported on linux")
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errPTYUnsupported
+}
+
+func setPTYSize(*os.File, uint16, uint16) error {
+	return errPTYUnsupported
+}
+
+func ptyProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,29 @@
+package runnergrpc
+
+import "testing"
+
+func TestSanitizePTYLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "hello\tworld", want: "hello\tworld"},
+		{name: "keeps colors", in: "\x1b[1;31merror\x1b[0m: x", want: "\x1b[1;31merror\x1b[0m: x"},
+		{name: "drops cursor movement", in: "\x1b[2J\x1b[H\x1b[?25lready\x1b[K", want: "ready"},
+		{name: "drops title", in: "\x1b]0;vim\x07text\x1b]2;x\x1b\\", want: "text"},
+		{name: "drops charset selection", in: "\x1b(Bbox\x1b=", want: "box"},
+		{name: "carriage return keeps last redraw", in: " 10%\r 50%\r100%", want: "100%"},
+		{name: "trailing carriage return", in: "done\r", want: "done"},
+		{name: "backspace", in: "ab\bc", want: "ac"},
+		{name: "controls", in: "a\x07b\x00c", want: "abc"},
+		{name: "unterminated escape", in: "x\x1b[12", want: "x"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizePTYLine(tc.in); got != tc.want {
+				t.Fatalf("sanitizePTYLine(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package runnergrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"pkt.systems/centaurx/internal/runnerpb"
+)
+
+// errEventsDropped reports that events a client asked for have been pushed
+// out of a runLog.
+var errEventsDropped = errors.New("events dropped")
+
+// runLog buffers the events of an exec run, numbered from 1, so a client
+// whose stream broke can re-attach and continue after the last event it
+// received. Only the latest limit events are kept.
+type runLog struct {
+	cancel context.CancelFunc
+	limit  int
+
+	mu      sync.Mutex
+	events  []*runnerpb.RunnerEvent
+	next    uint64
+	changed chan struct{}
+	done    bool
+	err     error
+	// followers counts the clients streaming the run; detaches counts the
+	// clients that went away, so a detach timer can tell whether another
+	// client came and went since.
+	followers int
+	detaches  uint64
+}
+
+func newRunLog(limit int, cancel context.CancelFunc) *runLog {
+	return &runLog{cancel: cancel, limit: limit, next: 1, changed: make(chan struct{})}
+}
+
+// append numbers event and wakes the followers.
+func (l *runLog) append(event *runnerpb.RunnerEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event.Seq = l.next
+	l.next++
+	l.events = append(l.events, event)
+	if len(l.events) > l.limit {
+		l.events = l.events[len(l.events)-l.limit:]
+	}
+	l.notifyLocked()
+}
+
+// finish marks the run as ended. Followers get err, or io.EOF when it is
+// nil, once they have read the buffered events.
+func (l *runLog) finish(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done = true
+	l.err = err
+	l.notifyLocked()
+}
+
+func (l *runLog) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the buffered events after seq and a channel closed on the
+// next change. With no events left it returns the run's end as an error:
+// io.EOF or the error the run failed with. Events after seq that are no
+// longer buffered give errEventsDropped.
+func (l *runLog) since(seq uint64) ([]*runnerpb.RunnerEvent, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	oldest := l.next - uint64(len(l.events))
+	if seq+1 < oldest {
+		return nil, nil, errEventsDropped
+	}
+	if seq+1 < l.next {
+		return l.events[seq+1-oldest:], l.changed, nil
+	}
+	if l.done {
+		if l.err != nil {
+			return nil, nil, l.err
+		}
+		return nil, nil, io.EOF
+	}
+	return nil, l.changed, nil
+}
+
+// retains reports whether the events after seq are still buffered.
+func (l *runLog) retains(seq uint64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return seq+1 >= l.next-uint64(len(l.events))
+}
+
+// follow registers a client streaming the run.
+func (l *runLog) follow() {
+	l.mu.Lock()
+	l.followers++
+	l.mu.Unlock()
+}
+
+// unfollow unregisters a client. It reports whether that left the run
+// running without clients, with a token for abandoned.
+func (l *runLog) unfollow() (uint64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.followers--
+	l.detaches++
+	return l.detaches, l.followers == 0 && !l.done
+}
+
+// abandoned reports whether the run is still running and no client has
+// followed it since the unfollow that returned token.
+func (l *runLog) abandoned(token uint64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.followers == 0 && l.detaches == token && !l.done
+}
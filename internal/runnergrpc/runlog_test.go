@@ -0,0 +1,68 @@
+package runnergrpc
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"pkt.systems/centaurx/internal/runnerpb"
+)
+
+func TestRunLogReplaysAfterSeq(t *testing.T) {
+	canceled := false
+	rl := newRunLog(2, func() { canceled = true })
+	for range 3 {
+		rl.append(&runnerpb.RunnerEvent{RunId: "run-1"})
+	}
+
+	if _, _, err := rl.since(0); !errors.Is(err, errEventsDropped) {
+		t.Fatalf("expected the trimmed first event to be reported, got %v", err)
+	}
+	if rl.retains(0) || !rl.retains(1) {
+		t.Fatalf("expected only the events after seq 1 to be retained")
+	}
+	events, changed, err := rl.since(1)
+	if err != nil {
+		t.Fatalf("since: %v", err)
+	}
+	if len(events) != 2 || events[0].Seq != 2 || events[1].Seq != 3 {
+		t.Fatalf("expected events 2 and 3, got %v", events)
+	}
+	if _, _, err := rl.since(3); err != nil {
+		t.Fatalf("expected to wait for more events, got %v", err)
+	}
+
+	rl.finish(nil)
+	select {
+	case <-changed:
+	default:
+		t.Fatalf("expected finish to wake followers")
+	}
+	if _, _, err := rl.since(3); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF after the last event, got %v", err)
+	}
+	if canceled {
+		t.Fatalf("expected the run log not to cancel the run itself")
+	}
+}
+
+func TestRunLogAbandonedAfterLastFollower(t *testing.T) {
+	rl := newRunLog(8, func() {})
+	rl.follow()
+	token, orphaned := rl.unfollow()
+	if !orphaned || !rl.abandoned(token) {
+		t.Fatalf("expected a running log without followers to be abandoned")
+	}
+	rl.follow()
+	if rl.abandoned(token) {
+		t.Fatalf("expected a re-attached follower to keep the run")
+	}
+	if _, orphaned := rl.unfollow(); !orphaned || rl.abandoned(token) {
+		t.Fatalf("expected an earlier detach token to be stale")
+	}
+	rl.finish(nil)
+	rl.follow()
+	if _, orphaned := rl.unfollow(); orphaned {
+		t.Fatalf("expected a finished run not to be orphaned")
+	}
+}
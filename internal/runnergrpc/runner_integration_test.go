@@ -6,11 +6,17 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/runnerpb"
 	"pkt.systems/centaurx/schema"
 )
 
@@ -120,6 +126,67 @@ func TestExecSignal(t *testing.T) {
 	close(runner.block)
 }
 
+func TestExecReattachReplaysEvents(t *testing.T) {
+	runner := &fakeRunner{
+		events: []schema.ExecEvent{
+			{Type: schema.EventThreadStarted, ThreadID: "thread-1"},
+			{Type: schema.EventItemCompleted, Item: &schema.ItemEvent{Type: schema.ItemAgentMessage, Text: "after the break"}},
+		},
+		block: make(chan struct{}),
+	}
+	client, cleanup := startTestServer(t, runner)
+	defer cleanup()
+
+	streamCtx, breakStream := context.WithCancel(context.Background())
+	defer breakStream()
+	stream, err := client.client.Exec(streamCtx, &runnerpb.ExecRequest{RunId: "run-reattach", Prompt: "hello", Json: true})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	// The stream breaks after the STARTED status and the first event.
+	handle := newRunHandle(context.Background(), client.client, "run-reattach", &brokenStream{stream: stream, limit: 2}, nil)
+	events := handle.Events()
+	event, err := events.Next(context.Background())
+	if err != nil || event.Type != schema.EventThreadStarted {
+		t.Fatalf("expected thread.started, got %+v: %v", event, err)
+	}
+	if _, err := events.Next(context.Background()); !core.IsTransientRunnerError(err) {
+		t.Fatalf("expected a transient stream error, got %v", err)
+	}
+	breakStream()
+
+	if err := handle.Reattach(context.Background()); err != nil {
+		t.Fatalf("Reattach: %v", err)
+	}
+	close(runner.block)
+	event, err = handle.Events().Next(context.Background())
+	if err != nil || event.Item == nil || event.Item.Text != "after the break" {
+		t.Fatalf("expected the replayed item event, got %+v: %v", event, err)
+	}
+	if _, err := handle.Events().Next(context.Background()); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if _, err := handle.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if err := handle.Reattach(context.Background()); err == nil {
+		t.Fatalf("expected a finished run not to re-attach")
+	}
+}
+
+func TestAttachUnknownRun(t *testing.T) {
+	client, cleanup := startTestServer(t, &fakeRunner{})
+	defer cleanup()
+
+	stream, err := client.client.Attach(context.Background(), &runnerpb.AttachRequest{RunId: "missing"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
 func TestRunCommand(t *testing.T) {
 	runner := &fakeRunner{}
 	client, cleanup := startTestServer(t, runner)
@@ -184,6 +251,224 @@ func TestRunCommandSignalStopsProcess(t *testing.T) {
 	}
 }
 
+func TestRunCommandSignalStopsProcessGroup(t *testing.T) {
+	client, cleanup := startTestServer(t, &fakeRunner{})
+	defer cleanup()
+
+	// The child holds no pipe, so only a signal to the group reaches it.
+	handle, err := client.RunCommand(context.Background(), core.RunCommandRequest{
+		Command:  "sleep 30 >/dev/null 2>&1 & echo $!; wait",
+		UseShell: true,
+	})
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	output, err := handle.Outputs().Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	child, err := strconv.Atoi(output.Text)
+	if err != nil {
+		t.Fatalf("unexpected output: %q", output.Text)
+	}
+	if err := handle.Signal(context.Background(), core.ProcessSignalTERM); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	if _, err := handle.Wait(ctx); err != nil && ctx.Err() != nil {
+		t.Fatalf("command did not stop after SIGTERM: %v", err)
+	}
+	if processAlive(child) {
+		t.Fatalf("child %d survived the stop", child)
+	}
+}
+
+func TestRunCommandKillsOrphans(t *testing.T) {
+	client, cleanup := startTestServer(t, &fakeRunner{})
+	defer cleanup()
+
+	handle, err := client.RunCommand(context.Background(), core.RunCommandRequest{
+		Command:  "sleep 30 >/dev/null 2>&1 & echo $!",
+		UseShell: true,
+	})
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output, err := handle.Outputs().Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	child, err := strconv.Atoi(output.Text)
+	if err != nil {
+		t.Fatalf("unexpected output: %q", output.Text)
+	}
+	result, err := handle.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if !result.OrphansKilled {
+		t.Fatalf("expected the background sleep to be reported as killed")
+	}
+	if processAlive(child) {
+		t.Fatalf("orphan %d survived the command", child)
+	}
+
+	handle, err = client.RunCommand(context.Background(), core.RunCommandRequest{Command: "true", UseShell: true})
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	if result, err := handle.Wait(ctx); err != nil || result.OrphansKilled {
+		t.Fatalf("expected a clean exit without orphans, got %+v (err %v)", result, err)
+	}
+}
+
+// processAlive reports whether pid exists and has not exited.
+func processAlive(pid int) bool {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunCommandSaveOutput(t *testing.T) {
+	client, cleanup := startTestServer(t, &fakeRunner{})
+	defer cleanup()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "out.txt"), []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	handle, err := client.RunCommand(context.Background(), core.RunCommandRequest{
+		WorkingDir: dir,
+		Command:    "echo one; echo two >&2",
+		UseShell:   true,
+		SaveOutput: "out.txt",
+	})
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	stream := handle.Outputs()
+	for {
+		if _, err := stream.Next(context.Background()); err != nil {
+			break
+		}
+	}
+	result, err := handle.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if result.SavedOutput != "out-1.txt" || result.SavedLines != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out-1.txt"))
+	if err != nil {
+		t.Fatalf("read saved output: %v", err)
+	}
+	if got := string(data); !strings.Contains(got, "one\n") || !strings.Contains(got, "two\n") {
+		t.Fatalf("unexpected saved output %q", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "out.txt")); string(data) != "keep\n" {
+		t.Fatalf("existing file overwritten: %q", data)
+	}
+
+	handle, err = client.RunCommand(context.Background(), core.RunCommandRequest{
+		WorkingDir: dir,
+		Command:    "true",
+		UseShell:   true,
+		SaveOutput: "../out.txt",
+	})
+	if err == nil {
+		_, err = handle.Wait(context.Background())
+	}
+	if err == nil || !strings.Contains(err.Error(), "inside the working directory") {
+		t.Fatalf("expected save path outside the working directory to fail, got %v", err)
+	}
+}
+
+func TestRunCommandStdin(t *testing.T) {
+	client, cleanup := startTestServer(t, &fakeRunner{})
+	defer cleanup()
+
+	handle, err := client.RunCommand(context.Background(), core.RunCommandRequest{
+		Command:  "wc -l",
+		UseShell: true,
+		Stdin:    []byte("one\ntwo\nthree\n"),
+	})
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	output, err := handle.Outputs().Next(context.Background())
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if strings.TrimSpace(output.Text) != "3" {
+		t.Fatalf("unexpected output: %q", output.Text)
+	}
+	_, _ = handle.Wait(context.Background())
+}
+
+func TestRunCommandPTY(t *testing.T) {
+	master, tty, err := openPTY()
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	_ = tty.Close()
+	_ = master.Close()
+	client, cleanup := startTestServer(t, &fakeRunner{})
+	defer cleanup()
+
+	handle, err := client.RunCommand(context.Background(), core.RunCommandRequest{
+		Command:  `trap 'stty size' WINCH; stty size; test -t 0 && printf '\033[2J\033[32mtty\033[0m\n'; while :; do sleep 0.05; done`,
+		UseShell: true,
+		PTY:      true,
+		Rows:     30,
+		Cols:     100,
+	})
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	stream := handle.Outputs()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	next := func() string {
+		t.Helper()
+		output, err := stream.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if output.Stream != core.CommandStreamStdout {
+			t.Fatalf("pty output should be stdout, got %s", output.Stream)
+		}
+		return output.Text
+	}
+	if got := next(); got != "30 100" {
+		t.Fatalf("unexpected initial size %q", got)
+	}
+	if got := next(); got != "\x1b[32mtty\x1b[0m" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	if err := handle.Resize(context.Background(), 40, 120); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	if got := next(); got != "40 120" {
+		t.Fatalf("unexpected size after resize %q", got)
+	}
+
+	if err := handle.Signal(context.Background(), core.ProcessSignalINT); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	result, err := handle.Wait(ctx)
+	if err == nil || result.ExitCode == 0 {
+		t.Fatalf("expected interrupted command to fail, got %+v err %v", result, err)
+	}
+}
+
 func startTestServer(t *testing.T, runner core.Runner) (*Client, func()) {
 	t.Helper()
 	socket := filepath.Join(t.TempDir(), "runner.sock")
@@ -327,6 +612,20 @@ func (s *fakeStream) Close() error {
 	return nil
 }
 
+// brokenStream fails like a reset connection after limit messages.
+type brokenStream struct {
+	stream grpcStream
+	limit  int
+}
+
+func (s *brokenStream) Recv() (*runnerpb.RunnerEvent, error) {
+	if s.limit == 0 {
+		return nil, status.Error(codes.Unavailable, "connection reset")
+	}
+	s.limit--
+	return s.stream.Recv()
+}
+
 func (s *fakeStream) closeDone() {
 	select {
 	case <-s.done:
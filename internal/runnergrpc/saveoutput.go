@@ -0,0 +1,97 @@
+package runnergrpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSaveOutputSuffix bounds the numeric suffixes tried when the requested
+// save path already exists.
+const maxSaveOutputSuffix = 1000
+
+// saveOutputFile receives the output lines of a command started with
+// save_output.
+type saveOutputFile struct {
+	file  *os.File
+	w     *bufio.Writer
+	path  string
+	lines int
+	err   error
+}
+
+// createSaveOutput creates name inside workDir for writing. The name must be
+// relative and stay inside workDir, also after resolving symlinks in its
+// directory. An existing file is never replaced: name-1.ext, name-2.ext, ...
+// are tried instead. The returned path is relative to workDir.
+func createSaveOutput(workDir, name string) (*saveOutputFile, error) {
+	name = filepath.Clean(strings.TrimSpace(name))
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("save path %q must stay inside the working directory", name)
+	}
+	if workDir == "" {
+		return nil, errors.New("save path needs a working directory")
+	}
+	root, err := filepath.EvalSymlinks(workDir)
+	if err != nil {
+		return nil, err
+	}
+	relDir := filepath.Dir(name)
+	dir, err := filepath.EvalSymlinks(filepath.Join(root, relDir))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, dir); err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return nil, fmt.Errorf("save path %q must stay inside the working directory", name)
+	}
+	base := filepath.Base(name)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		stem, ext = base, ""
+	}
+	for i := 0; i <= maxSaveOutputSuffix; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+		}
+		// O_EXCL also refuses a dangling symlink in the final element.
+		file, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &saveOutputFile{file: file, w: bufio.NewWriter(file), path: filepath.Join(relDir, candidate)}, nil
+	}
+	return nil, fmt.Errorf("save path %q: no free name after %d attempts", name, maxSaveOutputSuffix)
+}
+
+// writeLine appends one output line. After the first write error further
+// lines are dropped; the error is reported by close.
+func (s *saveOutputFile) writeLine(text string) {
+	if s.err != nil {
+		return
+	}
+	if _, err := s.w.WriteString(text + "\n"); err != nil {
+		s.err = err
+		return
+	}
+	s.lines++
+}
+
+func (s *saveOutputFile) close() error {
+	err := s.err
+	if flushErr := s.w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package runnergrpc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateSaveOutput(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "logs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"logs/out.txt", "logs/out-1.txt", "logs/out-2.txt"} {
+		save, err := createSaveOutput(dir, "logs/out.txt")
+		if err != nil {
+			t.Fatalf("createSaveOutput: %v", err)
+		}
+		save.writeLine("line")
+		if err := save.close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if save.path != want || save.lines != 1 {
+			t.Fatalf("saved %q (%d lines), want %q", save.path, save.lines, want)
+		}
+	}
+	save, err := createSaveOutput(dir, ".env")
+	if err != nil {
+		t.Fatalf("createSaveOutput dotfile: %v", err)
+	}
+	_ = save.close()
+	if save, err = createSaveOutput(dir, ".env"); err != nil || save.path != ".env-1" {
+		t.Fatalf("dotfile collision = %+v, %v", save, err)
+	}
+	_ = save.close()
+}
+
+func TestCreateSaveOutputStaysInside(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "target"), filepath.Join(dir, "dangling.txt")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../out.txt", "/tmp/out.txt", "escape/out.txt", "missing/out.txt"} {
+		if save, err := createSaveOutput(dir, name); err == nil {
+			_ = save.close()
+			t.Fatalf("createSaveOutput(%q) succeeded", name)
+		}
+	}
+	save, err := createSaveOutput(dir, "dangling.txt")
+	if err != nil {
+		t.Fatalf("createSaveOutput: %v", err)
+	}
+	_ = save.close()
+	if save.path != "dangling-1.txt" {
+		t.Fatalf("symlinked name was reused: %q", save.path)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "target")); !os.IsNotExist(err) {
+		t.Fatalf("file created through symlink: %v", err)
+	}
+}
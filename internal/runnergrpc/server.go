@@ -2,6 +2,7 @@ package runnergrpc
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -22,6 +23,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/codex"
 	"pkt.systems/centaurx/internal/runnerpb"
 	"pkt.systems/centaurx/internal/usage"
 	"pkt.systems/centaurx/schema"
@@ -38,6 +40,9 @@ type Server struct {
 
 	mu   sync.Mutex
 	runs map[string]runProcess
+	// runLogs holds the events of exec runs for Attach, until
+	// ReattachWindow after the run ended.
+	runLogs map[string]*runLog
 
 	lastPingUnix int64
 }
@@ -46,6 +51,11 @@ type runProcess interface {
 	Signal(sig core.ProcessSignal) error
 }
 
+// resizableProcess is implemented by runs attached to a pty.
+type resizableProcess interface {
+	Resize(rows, cols uint16) error
+}
+
 type handleProcess struct {
 	handle core.RunHandle
 }
@@ -75,6 +85,8 @@ func (p cmdProcess) Signal(sig core.ProcessSignal) error {
 		signal = syscall.SIGTERM
 	case core.ProcessSignalKILL:
 		signal = syscall.SIGKILL
+	case core.ProcessSignalINT:
+		signal = syscall.SIGINT
 	default:
 		return fmt.Errorf("unsupported signal: %s", sig)
 	}
@@ -100,7 +112,13 @@ func (p cmdProcess) Signal(sig core.ProcessSignal) error {
 
 // NewServer constructs a runner gRPC server.
 func NewServer(cfg Config, runner core.Runner) *Server {
-	return &Server{cfg: cfg, runner: runner, runs: make(map[string]runProcess)}
+	if cfg.ReattachWindow <= 0 {
+		cfg.ReattachWindow = DefaultReattachWindow
+	}
+	if cfg.ReplayEvents <= 0 {
+		cfg.ReplayEvents = DefaultReplayEvents
+	}
+	return &Server{cfg: cfg, runner: runner, runs: make(map[string]runProcess), runLogs: make(map[string]*runLog)}
 }
 
 // ListenAndServe starts the gRPC server over a Unix domain socket.
@@ -143,9 +161,11 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 
 	select {
 	case <-runCtx.Done():
+		s.cancelRuns()
 		grpcServer.GracefulStop()
 		return nil
 	case err := <-errCh:
+		s.cancelRuns()
 		return err
 	}
 }
@@ -171,6 +191,20 @@ func (s *Server) GetUsage(ctx context.Context, _ *runnerpb.UsageRequest) (*runne
 	}, nil
 }
 
+// ListModels lists the models available to ChatGPT logins.
+func (s *Server) ListModels(ctx context.Context, _ *runnerpb.ListModelsRequest) (*runnerpb.ListModelsResponse, error) {
+	list, err := usage.FetchModels(ctx)
+	if err != nil {
+		s.log(ctx).Warn("runner model list failed", "err", err)
+		return nil, status.Errorf(codes.Internal, "model list failed: %v", err)
+	}
+	resp := &runnerpb.ListModelsResponse{Listed: list.Listed}
+	for _, model := range list.Models {
+		resp.Models = append(resp.Models, string(model))
+	}
+	return resp, nil
+}
+
 // Exec runs a new codex exec session.
 func (s *Server) Exec(req *runnerpb.ExecRequest, stream runnerpb.Runner_ExecServer) error {
 	if err := validateExec(req); err != nil {
@@ -179,44 +213,29 @@ func (s *Server) Exec(req *runnerpb.ExecRequest, stream runnerpb.Runner_ExecServ
 	}
 	log := s.log(stream.Context()).With("run_id", req.RunId)
 	started := time.Now()
-	log.Info("runner exec start", "model", req.Model, "reasoning_effort", req.ModelReasoningEffort, "json", req.Json)
+	log.Info("runner exec start", "model", req.Model, "reasoning_effort", req.ModelReasoningEffort, "json", req.Json, "extra_args", req.ExtraArgs)
 	log.Debug("runner exec request", "workdir", req.WorkingDir, "ssh_auth_sock", req.SshAuthSock != "", "prompt_len", len(req.Prompt))
 	if len(req.Prompt) > 0 {
 		log.Trace("runner exec prompt", "preview", previewText(req.Prompt, 200), "truncated", len(req.Prompt) > 200)
 	}
-	runCtx := pslog.ContextWithLogger(stream.Context(), log)
+	runCtx, cancel := detachedRunContext(stream.Context(), log)
 	handle, err := s.runner.Run(runCtx, core.RunRequest{
+		RunID:                schema.RunID(req.RunId),
 		WorkingDir:           req.WorkingDir,
 		Prompt:               req.Prompt,
 		Model:                schema.ModelID(req.Model),
 		ModelReasoningEffort: schema.ModelReasoningEffort(req.ModelReasoningEffort),
 		JSON:                 req.Json,
 		SSHAuthSock:          req.SshAuthSock,
+		ExtraArgs:            req.ExtraArgs,
+		EnableWebSearch:      req.EnableWebSearch,
 	})
 	if err != nil {
+		cancel()
 		log.Error("runner exec failed", "err", err)
 		return status.Errorf(codes.Internal, "exec failed: %v", err)
 	}
-	s.register(req.RunId, handleProcess{handle: handle})
-	defer s.unregister(req.RunId)
-	defer func() { _ = handle.Close() }()
-
-	if err := stream.Send(&runnerpb.RunnerEvent{
-		RunId: req.RunId,
-		Payload: &runnerpb.RunnerEvent_Status{
-			Status: &runnerpb.RunStatus{State: runnerpb.RunState_RUN_STATE_STARTED},
-		},
-	}); err != nil {
-		log.Warn("runner exec stream start failed", "err", err)
-		return err
-	}
-
-	count, err := s.streamExecEvents(stream, req.RunId, handle.Events())
-	if err != nil {
-		log.Warn("runner exec stream failed", "err", err, "events", count)
-		return err
-	}
-	return s.finishRun(stream, req.RunId, handle, started, count)
+	return s.startRun(stream, runCtx, cancel, req.RunId, handle, started)
 }
 
 // ExecResume resumes an existing codex exec session.
@@ -227,13 +246,14 @@ func (s *Server) ExecResume(req *runnerpb.ExecResumeRequest, stream runnerpb.Run
 	}
 	log := s.log(stream.Context()).With("run_id", req.RunId)
 	started := time.Now()
-	log.Info("runner exec resume start", "model", req.Model, "reasoning_effort", req.ModelReasoningEffort, "json", req.Json, "resume_id", req.ResumeSessionId)
+	log.Info("runner exec resume start", "model", req.Model, "reasoning_effort", req.ModelReasoningEffort, "json", req.Json, "extra_args", req.ExtraArgs, "resume_id", req.ResumeSessionId)
 	log.Debug("runner exec resume request", "workdir", req.WorkingDir, "ssh_auth_sock", req.SshAuthSock != "", "prompt_len", len(req.Prompt))
 	if len(req.Prompt) > 0 {
 		log.Trace("runner exec resume prompt", "preview", previewText(req.Prompt, 200), "truncated", len(req.Prompt) > 200)
 	}
-	runCtx := pslog.ContextWithLogger(stream.Context(), log)
+	runCtx, cancel := detachedRunContext(stream.Context(), log)
 	handle, err := s.runner.Run(runCtx, core.RunRequest{
+		RunID:                schema.RunID(req.RunId),
 		WorkingDir:           req.WorkingDir,
 		Prompt:               req.Prompt,
 		Model:                schema.ModelID(req.Model),
@@ -241,31 +261,43 @@ func (s *Server) ExecResume(req *runnerpb.ExecResumeRequest, stream runnerpb.Run
 		ResumeSessionID:      schema.SessionID(req.ResumeSessionId),
 		JSON:                 req.Json,
 		SSHAuthSock:          req.SshAuthSock,
+		ExtraArgs:            req.ExtraArgs,
+		EnableWebSearch:      req.EnableWebSearch,
 	})
 	if err != nil {
+		cancel()
 		log.Error("runner exec resume failed", "err", err)
 		return status.Errorf(codes.Internal, "exec resume failed: %v", err)
 	}
-	s.register(req.RunId, handleProcess{handle: handle})
-	defer s.unregister(req.RunId)
-	defer func() { _ = handle.Close() }()
+	return s.startRun(stream, runCtx, cancel, req.RunId, handle, started)
+}
 
-	if err := stream.Send(&runnerpb.RunnerEvent{
-		RunId: req.RunId,
-		Payload: &runnerpb.RunnerEvent_Status{
-			Status: &runnerpb.RunStatus{State: runnerpb.RunState_RUN_STATE_STARTED},
-		},
-	}); err != nil {
-		log.Warn("runner exec resume stream start failed", "err", err)
-		return err
+// Attach streams the events of a running or recently finished exec run to
+// a client whose stream broke, after the event with seq AfterSeq. It starts
+// with a STARTED status without a seq, which confirms the run is known.
+func (s *Server) Attach(req *runnerpb.AttachRequest, stream runnerpb.Runner_AttachServer) error {
+	if strings.TrimSpace(req.RunId) == "" {
+		s.log(stream.Context()).Warn("runner attach rejected", "err", "run_id required")
+		return status.Error(codes.InvalidArgument, "run_id is required")
 	}
-
-	count, err := s.streamExecEvents(stream, req.RunId, handle.Events())
-	if err != nil {
-		log.Warn("runner exec resume stream failed", "err", err, "events", count)
+	log := s.log(stream.Context()).With("run_id", req.RunId)
+	s.mu.Lock()
+	rl := s.runLogs[req.RunId]
+	s.mu.Unlock()
+	if rl == nil {
+		log.Warn("runner attach rejected", "reason", "unknown run")
+		return status.Error(codes.NotFound, "run not found")
+	}
+	if !rl.retains(req.AfterSeq) {
+		log.Warn("runner attach rejected", "reason", "events dropped", "after_seq", req.AfterSeq)
+		return status.Errorf(codes.FailedPrecondition, "events after %d are no longer buffered", req.AfterSeq)
+	}
+	if err := stream.Send(startedEvent(req.RunId)); err != nil {
+		log.Warn("runner attach stream start failed", "err", err)
 		return err
 	}
-	return s.finishRun(stream, req.RunId, handle, started, count)
+	log.Info("runner exec reattached", "after_seq", req.AfterSeq)
+	return s.followRun(stream, req.RunId, rl, req.AfterSeq)
 }
 
 // RunCommand executes a shell command in the working directory.
@@ -278,10 +310,14 @@ func (s *Server) RunCommand(req *runnerpb.RunCommandRequest, stream runnerpb.Run
 		s.log(stream.Context()).Warn("runner command rejected", "run_id", req.RunId, "err", "command required")
 		return status.Error(codes.InvalidArgument, "command is required")
 	}
+	if req.Pty && len(req.Stdin) > 0 {
+		s.log(stream.Context()).Warn("runner command rejected", "run_id", req.RunId, "err", "stdin with pty")
+		return status.Error(codes.InvalidArgument, "stdin cannot be piped to a pty command")
+	}
 	log := s.log(stream.Context()).With("run_id", req.RunId)
 	started := time.Now()
-	log.Info("runner command start", "workdir", req.WorkingDir, "shell", req.UseShell)
-	log.Debug("runner command request", "command_len", len(req.Command), "ssh_auth_sock", req.SshAuthSock != "")
+	log.Info("runner command start", "workdir", req.WorkingDir, "shell", req.UseShell, "pty", req.Pty)
+	log.Debug("runner command request", "command_len", len(req.Command), "stdin_len", len(req.Stdin), "save_output", req.SaveOutput != "", "ssh_auth_sock", req.SshAuthSock != "")
 	log.Trace("runner command", "command", req.Command)
 
 	cmd, err := commandForRequest(req)
@@ -297,24 +333,74 @@ func (s *Server) RunCommand(req *runnerpb.RunCommandRequest, stream runnerpb.Run
 		cmd.Env = append(filterEnv(os.Environ(), "SSH_AUTH_SOCK"), fmt.Sprintf("SSH_AUTH_SOCK=%s", req.SshAuthSock))
 	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Error("runner command stdout failed", "err", err)
-		return status.Errorf(codes.Internal, "stdout pipe: %v", err)
+	var save *saveOutputFile
+	if req.SaveOutput != "" {
+		save, err = createSaveOutput(req.WorkingDir, req.SaveOutput)
+		if err != nil {
+			log.Warn("runner command save output failed", "err", err)
+			return status.Errorf(codes.InvalidArgument, "save output: %v", err)
+		}
+		defer func() {
+			if save != nil {
+				_ = save.close()
+			}
+		}()
+		log.Debug("runner command save output", "path", save.path)
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.Error("runner command stderr failed", "err", err)
-		return status.Errorf(codes.Internal, "stderr pipe: %v", err)
+
+	var stdout, stderr io.Reader
+	var master, tty *os.File
+	if req.Pty {
+		master, tty, err = openPTY()
+		if err != nil {
+			log.Error("runner command pty failed", "err", err)
+			return status.Errorf(codes.Internal, "pty: %v", err)
+		}
+		defer func() { _ = master.Close() }()
+		rows, cols := ptySize(req.Rows, defaultPTYRows), ptySize(req.Cols, defaultPTYCols)
+		if err := setPTYSize(master, rows, cols); err != nil {
+			log.Warn("runner command pty size failed", "rows", rows, "cols", cols, "err", err)
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+		// A session leader cannot also request its own process group; the
+		// new session gives the command one anyway.
+		cmd.SysProcAttr = ptyProcAttr()
+		env := cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		cmd.Env = append(filterEnv(env, "TERM"), "TERM=xterm-256color")
+	} else {
+		if len(req.Stdin) > 0 {
+			cmd.Stdin = bytes.NewReader(req.Stdin)
+		}
+		if stdout, err = cmd.StdoutPipe(); err != nil {
+			log.Error("runner command stdout failed", "err", err)
+			return status.Errorf(codes.Internal, "stdout pipe: %v", err)
+		}
+		if stderr, err = cmd.StderrPipe(); err != nil {
+			log.Error("runner command stderr failed", "err", err)
+			return status.Errorf(codes.Internal, "stderr pipe: %v", err)
+		}
+	}
+	err = cmd.Start()
+	if tty != nil {
+		// The child holds its own copy; closing ours lets reads on the
+		// master end when the command exits.
+		_ = tty.Close()
 	}
-	if err := cmd.Start(); err != nil {
+	if err != nil {
 		log.Error("runner command start failed", "err", err)
 		return status.Errorf(codes.Internal, "command start: %v", err)
 	}
 	applyNice(log, cmd.Process.Pid, s.cfg.CommandNice, "command")
 
 	pgid, _ := syscall.Getpgid(cmd.Process.Pid)
-	s.register(req.RunId, cmdProcess{cmd: cmd, pgid: pgid})
+	var proc runProcess = cmdProcess{cmd: cmd, pgid: pgid}
+	if master != nil {
+		proc = ptyProcess{cmdProcess: cmdProcess{cmd: cmd, pgid: pgid}, master: master}
+	}
+	s.register(req.RunId, proc)
 	defer s.unregister(req.RunId)
 
 	if err := stream.Send(&runnerpb.RunnerEvent{
@@ -329,9 +415,14 @@ func (s *Server) RunCommand(req *runnerpb.RunCommandRequest, stream runnerpb.Run
 
 	outputCh := make(chan *runnerpb.CommandOutput, 128)
 	var wg sync.WaitGroup
-	wg.Add(2)
-	go readCommandStream(&wg, stdout, runnerpb.StreamKind_STREAM_KIND_STDOUT, outputCh)
-	go readCommandStream(&wg, stderr, runnerpb.StreamKind_STREAM_KIND_STDERR, outputCh)
+	if master != nil {
+		wg.Add(1)
+		go readPTYStream(&wg, master, outputCh)
+	} else {
+		wg.Add(2)
+		go readCommandStream(&wg, stdout, runnerpb.StreamKind_STREAM_KIND_STDOUT, outputCh)
+		go readCommandStream(&wg, stderr, runnerpb.StreamKind_STREAM_KIND_STDERR, outputCh)
+	}
 	go func() {
 		wg.Wait()
 		close(outputCh)
@@ -346,6 +437,9 @@ func (s *Server) RunCommand(req *runnerpb.RunCommandRequest, stream runnerpb.Run
 		case runnerpb.StreamKind_STREAM_KIND_STDERR:
 			stderrLines++
 		}
+		if save != nil {
+			save.writeLine(output.Text)
+		}
 		event := &runnerpb.RunnerEvent{
 			RunId: req.RunId,
 			Payload: &runnerpb.RunnerEvent_CommandOutput{
@@ -355,11 +449,16 @@ func (s *Server) RunCommand(req *runnerpb.RunCommandRequest, stream runnerpb.Run
 		if err := stream.Send(event); err != nil {
 			log.Warn("runner command stream failed", "err", err)
 			_ = cmd.Wait()
+			killOrphans(pgid)
 			return err
 		}
 	}
 
 	err = cmd.Wait()
+	orphansKilled := killOrphans(pgid)
+	if orphansKilled {
+		log.Info("runner command orphans killed", "pgid", pgid)
+	}
 	exitCode := 0
 	state := runnerpb.RunState_RUN_STATE_FINISHED
 	if err != nil {
@@ -390,10 +489,19 @@ func (s *Server) RunCommand(req *runnerpb.RunCommandRequest, stream runnerpb.Run
 			"duration_ms", time.Since(started).Milliseconds(),
 		)
 	}
+	runStatus := &runnerpb.RunStatus{State: state, ExitCode: int32(exitCode), OrphansKilled: orphansKilled}
+	if save != nil {
+		if err := save.close(); err != nil {
+			log.Warn("runner command save output failed", "path", save.path, "err", err)
+		}
+		runStatus.SavedOutput = save.path
+		runStatus.SavedLines = int32(save.lines)
+		save = nil
+	}
 	return stream.Send(&runnerpb.RunnerEvent{
 		RunId: req.RunId,
 		Payload: &runnerpb.RunnerEvent_Status{
-			Status: &runnerpb.RunStatus{State: state, ExitCode: int32(exitCode)},
+			Status: runStatus,
 		},
 	})
 }
@@ -423,6 +531,27 @@ func (s *Server) SignalSession(ctx context.Context, req *runnerpb.SignalRequest)
 	return &runnerpb.SignalResponse{Ok: true}, nil
 }
 
+// ResizeCommand changes the terminal size of a running PTY command.
+func (s *Server) ResizeCommand(ctx context.Context, req *runnerpb.ResizeRequest) (*runnerpb.ResizeResponse, error) {
+	if strings.TrimSpace(req.RunId) == "" {
+		s.log(ctx).Warn("runner resize rejected", "err", "run_id required")
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	log := s.log(ctx).With("run_id", req.RunId)
+	proc, ok := s.lookup(req.RunId).(resizableProcess)
+	if !ok {
+		log.Debug("runner resize ignored", "reason", "not a running pty command")
+		return &runnerpb.ResizeResponse{Ok: false, Message: "not a running pty command"}, nil
+	}
+	rows, cols := ptySize(req.Rows, defaultPTYRows), ptySize(req.Cols, defaultPTYCols)
+	if err := proc.Resize(rows, cols); err != nil {
+		log.Warn("runner resize failed", "rows", rows, "cols", cols, "err", err)
+		return &runnerpb.ResizeResponse{Ok: false, Message: err.Error()}, nil
+	}
+	log.Debug("runner resize applied", "rows", rows, "cols", cols)
+	return &runnerpb.ResizeResponse{Ok: true}, nil
+}
+
 func (s *Server) register(runID string, proc runProcess) {
 	s.mu.Lock()
 	s.runs[runID] = proc
@@ -447,35 +576,142 @@ type execStream interface {
 	Send(*runnerpb.RunnerEvent) error
 }
 
-func (s *Server) streamExecEvents(stream execStream, runID string, events core.EventStream) (int, error) {
+// detachedRunContext returns the context an exec run runs in. It keeps the
+// values of the stream's context but not its cancellation, so the run
+// survives a broken stream until startRun gives up on it.
+func detachedRunContext(streamCtx context.Context, log pslog.Logger) (context.Context, context.CancelFunc) {
+	return context.WithCancel(pslog.ContextWithLogger(context.WithoutCancel(streamCtx), log))
+}
+
+// startRun registers a started exec run, reads its events into a runLog in
+// the background and streams them to the client that started it.
+func (s *Server) startRun(stream execStream, runCtx context.Context, cancel context.CancelFunc, runID string, handle core.RunHandle, started time.Time) error {
+	rl := newRunLog(s.cfg.ReplayEvents, cancel)
+	s.register(runID, handleProcess{handle: handle})
+	s.mu.Lock()
+	s.runLogs[runID] = rl
+	s.mu.Unlock()
+	go s.produceRun(runCtx, runID, rl, handle, started)
+	return s.followRun(stream, runID, rl, 0)
+}
+
+// produceRun reads the events of an exec run into rl until the run ends.
+// The log stays attachable for ReattachWindow afterwards, so a client that
+// lost the end of the stream can still fetch it.
+func (s *Server) produceRun(ctx context.Context, runID string, rl *runLog, handle core.RunHandle, started time.Time) {
+	log := s.log(ctx).With("run_id", runID)
+	defer func() {
+		rl.cancel()
+		_ = handle.Close()
+		s.unregister(runID)
+		time.AfterFunc(s.cfg.ReattachWindow, func() {
+			s.mu.Lock()
+			if s.runLogs[runID] == rl {
+				delete(s.runLogs, runID)
+			}
+			s.mu.Unlock()
+		})
+	}()
+	rl.append(startedEvent(runID))
 	count := 0
-	log := s.log(stream.Context()).With("run_id", runID)
+	events := handle.Events()
 	for {
-		event, err := events.Next(stream.Context())
+		event, err := events.Next(ctx)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				return count, nil
+				break
 			}
 			log.Warn("runner exec stream read failed", "err", err, "events", count)
-			return count, status.Errorf(codes.Internal, "stream error: %v", err)
+			rl.finish(status.Errorf(codes.Internal, "stream error: %v", err))
+			return
 		}
 		count++
-		payload := &runnerpb.RunnerEvent{
+		rl.append(&runnerpb.RunnerEvent{
 			RunId: runID,
 			Payload: &runnerpb.RunnerEvent_Exec{
 				Exec: toPBExecEvent(event),
 			},
+		})
+	}
+	rl.append(s.finishRun(ctx, runID, handle, started, count))
+	rl.finish(nil)
+}
+
+// followRun streams the events of rl after seq to a client until the run
+// ends or the client goes away. A run left without clients is cancelled
+// when none has re-attached within ReattachWindow.
+func (s *Server) followRun(stream execStream, runID string, rl *runLog, seq uint64) error {
+	log := s.log(stream.Context()).With("run_id", runID)
+	rl.follow()
+	defer func() {
+		token, orphaned := rl.unfollow()
+		if !orphaned {
+			return
+		}
+		log.Warn("runner exec stream detached", "after_seq", seq, "reattach_window", s.cfg.ReattachWindow)
+		time.AfterFunc(s.cfg.ReattachWindow, func() {
+			if rl.abandoned(token) {
+				log.Warn("runner exec abandoned", "reason", "no client re-attached")
+				rl.cancel()
+			}
+		})
+	}()
+	for {
+		events, changed, err := rl.since(seq)
+		if errors.Is(err, io.EOF) {
+			return nil
 		}
-		if err := stream.Send(payload); err != nil {
-			log.Warn("runner exec stream send failed", "err", err, "events", count)
-			return count, err
+		if errors.Is(err, errEventsDropped) {
+			log.Warn("runner exec stream fell behind", "after_seq", seq)
+			return status.Errorf(codes.DataLoss, "events after %d are no longer buffered", seq)
+		}
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := stream.Send(event); err != nil {
+				log.Warn("runner exec stream send failed", "err", err, "seq", event.Seq)
+				return err
+			}
+			seq = event.Seq
+		}
+		if len(events) > 0 {
+			continue
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
 		}
 	}
 }
 
-func (s *Server) finishRun(stream execStream, runID string, handle core.RunHandle, started time.Time, eventCount int) error {
-	result, err := handle.Wait(stream.Context())
-	log := s.log(stream.Context()).With("run_id", runID)
+// cancelRuns cancels the exec runs still going when the server stops.
+func (s *Server) cancelRuns() {
+	s.mu.Lock()
+	logs := make([]*runLog, 0, len(s.runLogs))
+	for _, rl := range s.runLogs {
+		logs = append(logs, rl)
+	}
+	s.mu.Unlock()
+	for _, rl := range logs {
+		rl.cancel()
+	}
+}
+
+func startedEvent(runID string) *runnerpb.RunnerEvent {
+	return &runnerpb.RunnerEvent{
+		RunId: runID,
+		Payload: &runnerpb.RunnerEvent_Status{
+			Status: &runnerpb.RunStatus{State: runnerpb.RunState_RUN_STATE_STARTED},
+		},
+	}
+}
+
+// finishRun waits for an exec run to exit and returns its final status.
+func (s *Server) finishRun(ctx context.Context, runID string, handle core.RunHandle, started time.Time, eventCount int) *runnerpb.RunnerEvent {
+	result, err := handle.Wait(ctx)
+	log := s.log(ctx).With("run_id", runID)
 	state := runnerpb.RunState_RUN_STATE_FINISHED
 	message := ""
 	if err != nil {
@@ -496,16 +732,12 @@ func (s *Server) finishRun(stream execStream, runID string, handle core.RunHandl
 	} else {
 		log.Info("runner exec finished", fields...)
 	}
-	if err := stream.Send(&runnerpb.RunnerEvent{
+	return &runnerpb.RunnerEvent{
 		RunId: runID,
 		Payload: &runnerpb.RunnerEvent_Status{
 			Status: &runnerpb.RunStatus{State: state, ExitCode: int32(result.ExitCode), Message: message},
 		},
-	}); err != nil {
-		log.Warn("runner exec status send failed", "err", err)
-		return err
 	}
-	return nil
 }
 
 func validateExec(req *runnerpb.ExecRequest) error {
@@ -515,6 +747,9 @@ func validateExec(req *runnerpb.ExecRequest) error {
 	if strings.TrimSpace(req.Prompt) == "" {
 		return status.Error(codes.InvalidArgument, "prompt is required")
 	}
+	if err := codex.ValidateExtraArgs(req.ExtraArgs); err != nil {
+		return status.Errorf(codes.InvalidArgument, "extra_args: %v", err)
+	}
 	return nil
 }
 
@@ -528,6 +763,9 @@ func validateExecResume(req *runnerpb.ExecResumeRequest) error {
 	if strings.TrimSpace(req.ResumeSessionId) == "" {
 		return status.Error(codes.InvalidArgument, "resume_session_id is required")
 	}
+	if err := codex.ValidateExtraArgs(req.ExtraArgs); err != nil {
+		return status.Errorf(codes.InvalidArgument, "extra_args: %v", err)
+	}
 	return nil
 }
 
@@ -561,6 +799,8 @@ func fromPBSignal(sig runnerpb.ProcessSignal) core.ProcessSignal {
 		return core.ProcessSignalTERM
 	case runnerpb.ProcessSignal_PROCESS_SIGNAL_KILL:
 		return core.ProcessSignalKILL
+	case runnerpb.ProcessSignal_PROCESS_SIGNAL_INT:
+		return core.ProcessSignalINT
 	default:
 		return ""
 	}
@@ -635,6 +875,59 @@ func killProcessTree(root int, sig syscall.Signal) {
 	}
 }
 
+// orphanKillWait bounds how long the killed leftovers of a command's process
+// group get to disappear.
+const orphanKillWait = 2 * time.Second
+
+// killOrphans SIGKILLs the members left in a command's process group after
+// the command itself exited, such as jobs it put in the background, and
+// reports whether there were any.
+func killOrphans(pgid int) bool {
+	if pgid <= 0 || pgid == syscall.Getpgrp() || len(processGroupMembers(pgid)) == 0 {
+		return false
+	}
+	_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	deadline := time.Now().Add(orphanKillWait)
+	for len(processGroupMembers(pgid)) > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
+
+// processGroupMembers lists the live processes in group pgid. Zombies are
+// left out: they have exited and only wait to be reaped.
+func processGroupMembers(pgid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var members []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name is in parentheses and may hold spaces; the
+		// state and ids follow the last closing parenthesis.
+		end := strings.LastIndexByte(string(data), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[end+1:]))
+		if len(fields) < 3 || fields[0] == "Z" {
+			continue
+		}
+		if group, err := strconv.Atoi(fields[2]); err == nil && group == pgid {
+			members = append(members, pid)
+		}
+	}
+	return members
+}
+
 func listProcessChildren(root int) ([]int, error) {
 	entries, err := os.ReadDir("/proc")
 	if err != nil {
@@ -28,6 +28,7 @@ const (
 	ProcessSignal_PROCESS_SIGNAL_HUP         ProcessSignal = 1
 	ProcessSignal_PROCESS_SIGNAL_TERM        ProcessSignal = 2
 	ProcessSignal_PROCESS_SIGNAL_KILL        ProcessSignal = 3
+	ProcessSignal_PROCESS_SIGNAL_INT         ProcessSignal = 4
 )
 
 // Enum value maps for ProcessSignal.
@@ -37,12 +38,14 @@ var (
 		1: "PROCESS_SIGNAL_HUP",
 		2: "PROCESS_SIGNAL_TERM",
 		3: "PROCESS_SIGNAL_KILL",
+		4: "PROCESS_SIGNAL_INT",
 	}
 	ProcessSignal_value = map[string]int32{
 		"PROCESS_SIGNAL_UNSPECIFIED": 0,
 		"PROCESS_SIGNAL_HUP":         1,
 		"PROCESS_SIGNAL_TERM":        2,
 		"PROCESS_SIGNAL_KILL":        3,
+		"PROCESS_SIGNAL_INT":         4,
 	}
 )
 
@@ -317,6 +320,8 @@ type ExecRequest struct {
 	Json                 bool                   `protobuf:"varint,5,opt,name=json,proto3" json:"json,omitempty"`
 	SshAuthSock          string                 `protobuf:"bytes,6,opt,name=ssh_auth_sock,json=sshAuthSock,proto3" json:"ssh_auth_sock,omitempty"`
 	ModelReasoningEffort string                 `protobuf:"bytes,7,opt,name=model_reasoning_effort,json=modelReasoningEffort,proto3" json:"model_reasoning_effort,omitempty"`
+	ExtraArgs            []string               `protobuf:"bytes,8,rep,name=extra_args,json=extraArgs,proto3" json:"extra_args,omitempty"`
+	EnableWebSearch      *bool                  `protobuf:"varint,9,opt,name=enable_web_search,json=enableWebSearch,proto3,oneof" json:"enable_web_search,omitempty"`
 	unknownFields        protoimpl.UnknownFields
 	sizeCache            protoimpl.SizeCache
 }
@@ -400,6 +405,20 @@ func (x *ExecRequest) GetModelReasoningEffort() string {
 	return ""
 }
 
+func (x *ExecRequest) GetExtraArgs() []string {
+	if x != nil {
+		return x.ExtraArgs
+	}
+	return nil
+}
+
+func (x *ExecRequest) GetEnableWebSearch() bool {
+	if x != nil && x.EnableWebSearch != nil {
+		return *x.EnableWebSearch
+	}
+	return false
+}
+
 type ExecResumeRequest struct {
 	state                protoimpl.MessageState `protogen:"open.v1"`
 	RunId                string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
@@ -410,6 +429,8 @@ type ExecResumeRequest struct {
 	Json                 bool                   `protobuf:"varint,6,opt,name=json,proto3" json:"json,omitempty"`
 	SshAuthSock          string                 `protobuf:"bytes,7,opt,name=ssh_auth_sock,json=sshAuthSock,proto3" json:"ssh_auth_sock,omitempty"`
 	ModelReasoningEffort string                 `protobuf:"bytes,8,opt,name=model_reasoning_effort,json=modelReasoningEffort,proto3" json:"model_reasoning_effort,omitempty"`
+	ExtraArgs            []string               `protobuf:"bytes,9,rep,name=extra_args,json=extraArgs,proto3" json:"extra_args,omitempty"`
+	EnableWebSearch      *bool                  `protobuf:"varint,10,opt,name=enable_web_search,json=enableWebSearch,proto3,oneof" json:"enable_web_search,omitempty"`
 	unknownFields        protoimpl.UnknownFields
 	sizeCache            protoimpl.SizeCache
 }
@@ -500,6 +521,75 @@ func (x *ExecResumeRequest) GetModelReasoningEffort() string {
 	return ""
 }
 
+func (x *ExecResumeRequest) GetExtraArgs() []string {
+	if x != nil {
+		return x.ExtraArgs
+	}
+	return nil
+}
+
+func (x *ExecResumeRequest) GetEnableWebSearch() bool {
+	if x != nil && x.EnableWebSearch != nil {
+		return *x.EnableWebSearch
+	}
+	return false
+}
+
+// AttachRequest re-attaches to an exec run whose stream broke.
+type AttachRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	RunId string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	// after_seq is the seq of the last event received; the stream continues
+	// with the next one.
+	AfterSeq      uint64 `protobuf:"varint,2,opt,name=after_seq,json=afterSeq,proto3" json:"after_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttachRequest) Reset() {
+	*x = AttachRequest{}
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttachRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttachRequest) ProtoMessage() {}
+
+func (x *AttachRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttachRequest.ProtoReflect.Descriptor instead.
+func (*AttachRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AttachRequest) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *AttachRequest) GetAfterSeq() uint64 {
+	if x != nil {
+		return x.AfterSeq
+	}
+	return 0
+}
+
 type RunCommandRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
@@ -507,13 +597,18 @@ type RunCommandRequest struct {
 	Command       string                 `protobuf:"bytes,3,opt,name=command,proto3" json:"command,omitempty"`
 	UseShell      bool                   `protobuf:"varint,4,opt,name=use_shell,json=useShell,proto3" json:"use_shell,omitempty"`
 	SshAuthSock   string                 `protobuf:"bytes,5,opt,name=ssh_auth_sock,json=sshAuthSock,proto3" json:"ssh_auth_sock,omitempty"`
+	Pty           bool                   `protobuf:"varint,6,opt,name=pty,proto3" json:"pty,omitempty"`
+	Rows          uint32                 `protobuf:"varint,7,opt,name=rows,proto3" json:"rows,omitempty"`
+	Cols          uint32                 `protobuf:"varint,8,opt,name=cols,proto3" json:"cols,omitempty"`
+	Stdin         []byte                 `protobuf:"bytes,9,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	SaveOutput    string                 `protobuf:"bytes,10,opt,name=save_output,json=saveOutput,proto3" json:"save_output,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RunCommandRequest) Reset() {
 	*x = RunCommandRequest{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[2]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -525,7 +620,7 @@ func (x *RunCommandRequest) String() string {
 func (*RunCommandRequest) ProtoMessage() {}
 
 func (x *RunCommandRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[2]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -538,7 +633,7 @@ func (x *RunCommandRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RunCommandRequest.ProtoReflect.Descriptor instead.
 func (*RunCommandRequest) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{2}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *RunCommandRequest) GetRunId() string {
@@ -576,6 +671,41 @@ func (x *RunCommandRequest) GetSshAuthSock() string {
 	return ""
 }
 
+func (x *RunCommandRequest) GetPty() bool {
+	if x != nil {
+		return x.Pty
+	}
+	return false
+}
+
+func (x *RunCommandRequest) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *RunCommandRequest) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *RunCommandRequest) GetStdin() []byte {
+	if x != nil {
+		return x.Stdin
+	}
+	return nil
+}
+
+func (x *RunCommandRequest) GetSaveOutput() string {
+	if x != nil {
+		return x.SaveOutput
+	}
+	return ""
+}
+
 type PingRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -584,7 +714,7 @@ type PingRequest struct {
 
 func (x *PingRequest) Reset() {
 	*x = PingRequest{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[3]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -596,7 +726,7 @@ func (x *PingRequest) String() string {
 func (*PingRequest) ProtoMessage() {}
 
 func (x *PingRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[3]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -609,7 +739,7 @@ func (x *PingRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
 func (*PingRequest) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{3}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{4}
 }
 
 type PingResponse struct {
@@ -621,7 +751,7 @@ type PingResponse struct {
 
 func (x *PingResponse) Reset() {
 	*x = PingResponse{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[4]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -633,7 +763,7 @@ func (x *PingResponse) String() string {
 func (*PingResponse) ProtoMessage() {}
 
 func (x *PingResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[4]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -646,7 +776,7 @@ func (x *PingResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
 func (*PingResponse) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{4}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *PingResponse) GetOk() bool {
@@ -666,7 +796,7 @@ type SignalRequest struct {
 
 func (x *SignalRequest) Reset() {
 	*x = SignalRequest{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[5]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -678,7 +808,7 @@ func (x *SignalRequest) String() string {
 func (*SignalRequest) ProtoMessage() {}
 
 func (x *SignalRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[5]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -691,7 +821,7 @@ func (x *SignalRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SignalRequest.ProtoReflect.Descriptor instead.
 func (*SignalRequest) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{5}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *SignalRequest) GetRunId() string {
@@ -718,7 +848,7 @@ type SignalResponse struct {
 
 func (x *SignalResponse) Reset() {
 	*x = SignalResponse{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[6]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -730,7 +860,7 @@ func (x *SignalResponse) String() string {
 func (*SignalResponse) ProtoMessage() {}
 
 func (x *SignalResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[6]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -743,7 +873,7 @@ func (x *SignalResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SignalResponse.ProtoReflect.Descriptor instead.
 func (*SignalResponse) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{6}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *SignalResponse) GetOk() bool {
@@ -760,6 +890,118 @@ func (x *SignalResponse) GetMessage() string {
 	return ""
 }
 
+type ResizeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Rows          uint32                 `protobuf:"varint,2,opt,name=rows,proto3" json:"rows,omitempty"`
+	Cols          uint32                 `protobuf:"varint,3,opt,name=cols,proto3" json:"cols,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResizeRequest) Reset() {
+	*x = ResizeRequest{}
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResizeRequest) ProtoMessage() {}
+
+func (x *ResizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResizeRequest.ProtoReflect.Descriptor instead.
+func (*ResizeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ResizeRequest) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *ResizeRequest) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *ResizeRequest) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+type ResizeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResizeResponse) Reset() {
+	*x = ResizeResponse{}
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResizeResponse) ProtoMessage() {}
+
+func (x *ResizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResizeResponse.ProtoReflect.Descriptor instead.
+func (*ResizeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ResizeResponse) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *ResizeResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type UsageRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -768,7 +1010,7 @@ type UsageRequest struct {
 
 func (x *UsageRequest) Reset() {
 	*x = UsageRequest{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[7]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -780,7 +1022,7 @@ func (x *UsageRequest) String() string {
 func (*UsageRequest) ProtoMessage() {}
 
 func (x *UsageRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[7]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -793,7 +1035,7 @@ func (x *UsageRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UsageRequest.ProtoReflect.Descriptor instead.
 func (*UsageRequest) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{7}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{10}
 }
 
 type UsageResponse struct {
@@ -807,7 +1049,7 @@ type UsageResponse struct {
 
 func (x *UsageResponse) Reset() {
 	*x = UsageResponse{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[8]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -819,7 +1061,7 @@ func (x *UsageResponse) String() string {
 func (*UsageResponse) ProtoMessage() {}
 
 func (x *UsageResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[8]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -832,7 +1074,7 @@ func (x *UsageResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UsageResponse.ProtoReflect.Descriptor instead.
 func (*UsageResponse) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{8}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *UsageResponse) GetChatgpt() bool {
@@ -867,7 +1109,7 @@ type UsageWindow struct {
 
 func (x *UsageWindow) Reset() {
 	*x = UsageWindow{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[9]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -879,7 +1121,7 @@ func (x *UsageWindow) String() string {
 func (*UsageWindow) ProtoMessage() {}
 
 func (x *UsageWindow) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[9]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -892,7 +1134,7 @@ func (x *UsageWindow) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UsageWindow.ProtoReflect.Descriptor instead.
 func (*UsageWindow) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{9}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *UsageWindow) GetUsedPercent() float64 {
@@ -924,14 +1166,18 @@ type RunnerEvent struct {
 	//	*RunnerEvent_Exec
 	//	*RunnerEvent_CommandOutput
 	//	*RunnerEvent_Status
-	Payload       isRunnerEvent_Payload `protobuf_oneof:"payload"`
+	Payload isRunnerEvent_Payload `protobuf_oneof:"payload"`
+	// seq numbers the events of an exec run from 1, so Attach can continue
+	// after the last one a client received. It is 0 for commands and for the
+	// status Attach starts with.
+	Seq           uint64 `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RunnerEvent) Reset() {
 	*x = RunnerEvent{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[10]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -943,7 +1189,7 @@ func (x *RunnerEvent) String() string {
 func (*RunnerEvent) ProtoMessage() {}
 
 func (x *RunnerEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[10]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -956,7 +1202,7 @@ func (x *RunnerEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RunnerEvent.ProtoReflect.Descriptor instead.
 func (*RunnerEvent) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{10}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *RunnerEvent) GetRunId() string {
@@ -1000,6 +1246,13 @@ func (x *RunnerEvent) GetStatus() *RunStatus {
 	return nil
 }
 
+func (x *RunnerEvent) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
 type isRunnerEvent_Payload interface {
 	isRunnerEvent_Payload()
 }
@@ -1023,17 +1276,22 @@ func (*RunnerEvent_CommandOutput) isRunnerEvent_Payload() {}
 func (*RunnerEvent_Status) isRunnerEvent_Payload() {}
 
 type RunStatus struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	State         RunState               `protobuf:"varint,1,opt,name=state,proto3,enum=centaurx.runner.v1.RunState" json:"state,omitempty"`
-	ExitCode      int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	State       RunState               `protobuf:"varint,1,opt,name=state,proto3,enum=centaurx.runner.v1.RunState" json:"state,omitempty"`
+	ExitCode    int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Message     string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	SavedOutput string                 `protobuf:"bytes,4,opt,name=saved_output,json=savedOutput,proto3" json:"saved_output,omitempty"`
+	SavedLines  int32                  `protobuf:"varint,5,opt,name=saved_lines,json=savedLines,proto3" json:"saved_lines,omitempty"`
+	// orphans_killed reports that processes were left in the command's process
+	// group after it exited and had to be killed.
+	OrphansKilled bool `protobuf:"varint,6,opt,name=orphans_killed,json=orphansKilled,proto3" json:"orphans_killed,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RunStatus) Reset() {
 	*x = RunStatus{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[11]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1045,7 +1303,7 @@ func (x *RunStatus) String() string {
 func (*RunStatus) ProtoMessage() {}
 
 func (x *RunStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[11]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1058,7 +1316,7 @@ func (x *RunStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RunStatus.ProtoReflect.Descriptor instead.
 func (*RunStatus) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{11}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *RunStatus) GetState() RunState {
@@ -1082,6 +1340,27 @@ func (x *RunStatus) GetMessage() string {
 	return ""
 }
 
+func (x *RunStatus) GetSavedOutput() string {
+	if x != nil {
+		return x.SavedOutput
+	}
+	return ""
+}
+
+func (x *RunStatus) GetSavedLines() int32 {
+	if x != nil {
+		return x.SavedLines
+	}
+	return 0
+}
+
+func (x *RunStatus) GetOrphansKilled() bool {
+	if x != nil {
+		return x.OrphansKilled
+	}
+	return false
+}
+
 type CommandOutput struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Stream        StreamKind             `protobuf:"varint,1,opt,name=stream,proto3,enum=centaurx.runner.v1.StreamKind" json:"stream,omitempty"`
@@ -1092,7 +1371,7 @@ type CommandOutput struct {
 
 func (x *CommandOutput) Reset() {
 	*x = CommandOutput{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[12]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1104,7 +1383,7 @@ func (x *CommandOutput) String() string {
 func (*CommandOutput) ProtoMessage() {}
 
 func (x *CommandOutput) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[12]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1117,7 +1396,7 @@ func (x *CommandOutput) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CommandOutput.ProtoReflect.Descriptor instead.
 func (*CommandOutput) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{12}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *CommandOutput) GetStream() StreamKind {
@@ -1149,7 +1428,7 @@ type ExecEvent struct {
 
 func (x *ExecEvent) Reset() {
 	*x = ExecEvent{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[13]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1161,7 +1440,7 @@ func (x *ExecEvent) String() string {
 func (*ExecEvent) ProtoMessage() {}
 
 func (x *ExecEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[13]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1174,7 +1453,7 @@ func (x *ExecEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExecEvent.ProtoReflect.Descriptor instead.
 func (*ExecEvent) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{13}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *ExecEvent) GetType() EventType {
@@ -1237,7 +1516,7 @@ type TurnUsage struct {
 
 func (x *TurnUsage) Reset() {
 	*x = TurnUsage{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[14]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1249,7 +1528,7 @@ func (x *TurnUsage) String() string {
 func (*TurnUsage) ProtoMessage() {}
 
 func (x *TurnUsage) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[14]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1262,7 +1541,7 @@ func (x *TurnUsage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TurnUsage.ProtoReflect.Descriptor instead.
 func (*TurnUsage) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{14}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *TurnUsage) GetInputTokens() int32 {
@@ -1305,7 +1584,7 @@ type ItemEvent struct {
 
 func (x *ItemEvent) Reset() {
 	*x = ItemEvent{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[15]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1317,7 +1596,7 @@ func (x *ItemEvent) String() string {
 func (*ItemEvent) ProtoMessage() {}
 
 func (x *ItemEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[15]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1330,7 +1609,7 @@ func (x *ItemEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ItemEvent.ProtoReflect.Descriptor instead.
 func (*ItemEvent) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{15}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ItemEvent) GetId() string {
@@ -1420,7 +1699,7 @@ type FileChange struct {
 
 func (x *FileChange) Reset() {
 	*x = FileChange{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[16]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1432,7 +1711,7 @@ func (x *FileChange) String() string {
 func (*FileChange) ProtoMessage() {}
 
 func (x *FileChange) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[16]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1445,7 +1724,7 @@ func (x *FileChange) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileChange.ProtoReflect.Descriptor instead.
 func (*FileChange) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{16}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *FileChange) GetPath() string {
@@ -1472,7 +1751,7 @@ type TodoItem struct {
 
 func (x *TodoItem) Reset() {
 	*x = TodoItem{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[17]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1484,7 +1763,7 @@ func (x *TodoItem) String() string {
 func (*TodoItem) ProtoMessage() {}
 
 func (x *TodoItem) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[17]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1497,7 +1776,7 @@ func (x *TodoItem) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TodoItem.ProtoReflect.Descriptor instead.
 func (*TodoItem) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{17}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *TodoItem) GetText() string {
@@ -1523,7 +1802,7 @@ type ErrorEvent struct {
 
 func (x *ErrorEvent) Reset() {
 	*x = ErrorEvent{}
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[18]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1535,7 +1814,7 @@ func (x *ErrorEvent) String() string {
 func (*ErrorEvent) ProtoMessage() {}
 
 func (x *ErrorEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runner_v1_runner_proto_msgTypes[18]
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1548,7 +1827,7 @@ func (x *ErrorEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ErrorEvent.ProtoReflect.Descriptor instead.
 func (*ErrorEvent) Descriptor() ([]byte, []int) {
-	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{18}
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *ErrorEvent) GetMessage() string {
@@ -1558,11 +1837,99 @@ func (x *ErrorEvent) GetMessage() string {
 	return ""
 }
 
+type ListModelsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListModelsRequest) Reset() {
+	*x = ListModelsRequest{}
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListModelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModelsRequest) ProtoMessage() {}
+
+func (x *ListModelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModelsRequest.ProtoReflect.Descriptor instead.
+func (*ListModelsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{22}
+}
+
+type ListModelsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Listed        bool                   `protobuf:"varint,1,opt,name=listed,proto3" json:"listed,omitempty"`
+	Models        []string               `protobuf:"bytes,2,rep,name=models,proto3" json:"models,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListModelsResponse) Reset() {
+	*x = ListModelsResponse{}
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListModelsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModelsResponse) ProtoMessage() {}
+
+func (x *ListModelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runner_v1_runner_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModelsResponse.ProtoReflect.Descriptor instead.
+func (*ListModelsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runner_v1_runner_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ListModelsResponse) GetListed() bool {
+	if x != nil {
+		return x.Listed
+	}
+	return false
+}
+
+func (x *ListModelsResponse) GetModels() []string {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
 var File_proto_runner_v1_runner_proto protoreflect.FileDescriptor
 
 const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\n" +
-	"\x1cproto/runner/v1/runner.proto\x12\x12centaurx.runner.v1\"\xe1\x01\n" +
+	"\x1cproto/runner/v1/runner.proto\x12\x12centaurx.runner.v1\"\xc7\x02\n" +
 	"\vExecRequest\x12\x15\n" +
 	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x1f\n" +
 	"\vworking_dir\x18\x02 \x01(\tR\n" +
@@ -1571,7 +1938,11 @@ const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\x05model\x18\x04 \x01(\tR\x05model\x12\x12\n" +
 	"\x04json\x18\x05 \x01(\bR\x04json\x12\"\n" +
 	"\rssh_auth_sock\x18\x06 \x01(\tR\vsshAuthSock\x124\n" +
-	"\x16model_reasoning_effort\x18\a \x01(\tR\x14modelReasoningEffort\"\x93\x02\n" +
+	"\x16model_reasoning_effort\x18\a \x01(\tR\x14modelReasoningEffort\x12\x1d\n" +
+	"\n" +
+	"extra_args\x18\b \x03(\tR\textraArgs\x12/\n" +
+	"\x11enable_web_search\x18\t \x01(\bH\x00R\x0fenableWebSearch\x88\x01\x01B\x14\n" +
+	"\x12_enable_web_search\"\xf9\x02\n" +
 	"\x11ExecResumeRequest\x12\x15\n" +
 	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x1f\n" +
 	"\vworking_dir\x18\x02 \x01(\tR\n" +
@@ -1581,14 +1952,29 @@ const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\x11resume_session_id\x18\x05 \x01(\tR\x0fresumeSessionId\x12\x12\n" +
 	"\x04json\x18\x06 \x01(\bR\x04json\x12\"\n" +
 	"\rssh_auth_sock\x18\a \x01(\tR\vsshAuthSock\x124\n" +
-	"\x16model_reasoning_effort\x18\b \x01(\tR\x14modelReasoningEffort\"\xa6\x01\n" +
+	"\x16model_reasoning_effort\x18\b \x01(\tR\x14modelReasoningEffort\x12\x1d\n" +
+	"\n" +
+	"extra_args\x18\t \x03(\tR\textraArgs\x12/\n" +
+	"\x11enable_web_search\x18\n" +
+	" \x01(\bH\x00R\x0fenableWebSearch\x88\x01\x01B\x14\n" +
+	"\x12_enable_web_search\"C\n" +
+	"\rAttachRequest\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x1b\n" +
+	"\tafter_seq\x18\x02 \x01(\x04R\bafterSeq\"\x97\x02\n" +
 	"\x11RunCommandRequest\x12\x15\n" +
 	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x1f\n" +
 	"\vworking_dir\x18\x02 \x01(\tR\n" +
 	"workingDir\x12\x18\n" +
 	"\acommand\x18\x03 \x01(\tR\acommand\x12\x1b\n" +
 	"\tuse_shell\x18\x04 \x01(\bR\buseShell\x12\"\n" +
-	"\rssh_auth_sock\x18\x05 \x01(\tR\vsshAuthSock\"\r\n" +
+	"\rssh_auth_sock\x18\x05 \x01(\tR\vsshAuthSock\x12\x10\n" +
+	"\x03pty\x18\x06 \x01(\bR\x03pty\x12\x12\n" +
+	"\x04rows\x18\a \x01(\rR\x04rows\x12\x12\n" +
+	"\x04cols\x18\b \x01(\rR\x04cols\x12\x14\n" +
+	"\x05stdin\x18\t \x01(\fR\x05stdin\x12\x1f\n" +
+	"\vsave_output\x18\n" +
+	" \x01(\tR\n" +
+	"saveOutput\"\r\n" +
 	"\vPingRequest\"\x1e\n" +
 	"\fPingResponse\x12\x0e\n" +
 	"\x02ok\x18\x01 \x01(\bR\x02ok\"a\n" +
@@ -1597,6 +1983,13 @@ const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\x06signal\x18\x02 \x01(\x0e2!.centaurx.runner.v1.ProcessSignalR\x06signal\":\n" +
 	"\x0eSignalResponse\x12\x0e\n" +
 	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"N\n" +
+	"\rResizeRequest\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x12\n" +
+	"\x04rows\x18\x02 \x01(\rR\x04rows\x12\x12\n" +
+	"\x04cols\x18\x03 \x01(\rR\x04cols\":\n" +
+	"\x0eResizeResponse\x12\x0e\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\"\x0e\n" +
 	"\fUsageRequest\"\xbd\x01\n" +
 	"\rUsageResponse\x12\x18\n" +
@@ -1606,17 +1999,22 @@ const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\vUsageWindow\x12!\n" +
 	"\fused_percent\x18\x01 \x01(\x01R\vusedPercent\x120\n" +
 	"\x14limit_window_seconds\x18\x02 \x01(\x03R\x12limitWindowSeconds\x12\x19\n" +
-	"\breset_at\x18\x03 \x01(\x03R\aresetAt\"\xe9\x01\n" +
+	"\breset_at\x18\x03 \x01(\x03R\aresetAt\"\xfb\x01\n" +
 	"\vRunnerEvent\x12\x15\n" +
 	"\x06run_id\x18\x01 \x01(\tR\x05runId\x123\n" +
 	"\x04exec\x18\x02 \x01(\v2\x1d.centaurx.runner.v1.ExecEventH\x00R\x04exec\x12J\n" +
 	"\x0ecommand_output\x18\x03 \x01(\v2!.centaurx.runner.v1.CommandOutputH\x00R\rcommandOutput\x127\n" +
-	"\x06status\x18\x04 \x01(\v2\x1d.centaurx.runner.v1.RunStatusH\x00R\x06statusB\t\n" +
-	"\apayload\"v\n" +
+	"\x06status\x18\x04 \x01(\v2\x1d.centaurx.runner.v1.RunStatusH\x00R\x06status\x12\x10\n" +
+	"\x03seq\x18\x05 \x01(\x04R\x03seqB\t\n" +
+	"\apayload\"\xe1\x01\n" +
 	"\tRunStatus\x122\n" +
 	"\x05state\x18\x01 \x01(\x0e2\x1c.centaurx.runner.v1.RunStateR\x05state\x12\x1b\n" +
 	"\texit_code\x18\x02 \x01(\x05R\bexitCode\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\"[\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12!\n" +
+	"\fsaved_output\x18\x04 \x01(\tR\vsavedOutput\x12\x1f\n" +
+	"\vsaved_lines\x18\x05 \x01(\x05R\n" +
+	"savedLines\x12%\n" +
+	"\x0eorphans_killed\x18\x06 \x01(\bR\rorphansKilled\"[\n" +
 	"\rCommandOutput\x126\n" +
 	"\x06stream\x18\x01 \x01(\x0e2\x1e.centaurx.runner.v1.StreamKindR\x06stream\x12\x12\n" +
 	"\x04text\x18\x02 \x01(\tR\x04text\"\xa5\x02\n" +
@@ -1656,12 +2054,17 @@ const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\tcompleted\x18\x02 \x01(\bR\tcompleted\"&\n" +
 	"\n" +
 	"ErrorEvent\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage*y\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"\x13\n" +
+	"\x11ListModelsRequest\"D\n" +
+	"\x12ListModelsResponse\x12\x16\n" +
+	"\x06listed\x18\x01 \x01(\bR\x06listed\x12\x16\n" +
+	"\x06models\x18\x02 \x03(\tR\x06models*\x91\x01\n" +
 	"\rProcessSignal\x12\x1e\n" +
 	"\x1aPROCESS_SIGNAL_UNSPECIFIED\x10\x00\x12\x16\n" +
 	"\x12PROCESS_SIGNAL_HUP\x10\x01\x12\x17\n" +
 	"\x13PROCESS_SIGNAL_TERM\x10\x02\x12\x17\n" +
-	"\x13PROCESS_SIGNAL_KILL\x10\x03*j\n" +
+	"\x13PROCESS_SIGNAL_KILL\x10\x03\x12\x16\n" +
+	"\x12PROCESS_SIGNAL_INT\x10\x04*j\n" +
 	"\bRunState\x12\x19\n" +
 	"\x15RUN_STATE_UNSPECIFIED\x10\x00\x12\x15\n" +
 	"\x11RUN_STATE_STARTED\x10\x01\x12\x16\n" +
@@ -1692,16 +2095,20 @@ const file_proto_runner_v1_runner_proto_rawDesc = "" +
 	"\x0fITEM_WEB_SEARCH\x10\x06\x12\x12\n" +
 	"\x0eITEM_TODO_LIST\x10\a\x12\x0e\n" +
 	"\n" +
-	"ITEM_ERROR\x10\b2\xf8\x03\n" +
+	"ITEM_ERROR\x10\b2\xfd\x05\n" +
 	"\x06Runner\x12J\n" +
 	"\x04Exec\x12\x1f.centaurx.runner.v1.ExecRequest\x1a\x1f.centaurx.runner.v1.RunnerEvent0\x01\x12V\n" +
 	"\n" +
-	"ExecResume\x12%.centaurx.runner.v1.ExecResumeRequest\x1a\x1f.centaurx.runner.v1.RunnerEvent0\x01\x12V\n" +
+	"ExecResume\x12%.centaurx.runner.v1.ExecResumeRequest\x1a\x1f.centaurx.runner.v1.RunnerEvent0\x01\x12N\n" +
+	"\x06Attach\x12!.centaurx.runner.v1.AttachRequest\x1a\x1f.centaurx.runner.v1.RunnerEvent0\x01\x12V\n" +
 	"\n" +
 	"RunCommand\x12%.centaurx.runner.v1.RunCommandRequest\x1a\x1f.centaurx.runner.v1.RunnerEvent0\x01\x12I\n" +
 	"\x04Ping\x12\x1f.centaurx.runner.v1.PingRequest\x1a .centaurx.runner.v1.PingResponse\x12V\n" +
-	"\rSignalSession\x12!.centaurx.runner.v1.SignalRequest\x1a\".centaurx.runner.v1.SignalResponse\x12O\n" +
-	"\bGetUsage\x12 .centaurx.runner.v1.UsageRequest\x1a!.centaurx.runner.v1.UsageResponseB1Z/pkt.systems/centaurx/internal/runnerpb;runnerpbb\x06proto3"
+	"\rSignalSession\x12!.centaurx.runner.v1.SignalRequest\x1a\".centaurx.runner.v1.SignalResponse\x12V\n" +
+	"\rResizeCommand\x12!.centaurx.runner.v1.ResizeRequest\x1a\".centaurx.runner.v1.ResizeResponse\x12O\n" +
+	"\bGetUsage\x12 .centaurx.runner.v1.UsageRequest\x1a!.centaurx.runner.v1.UsageResponse\x12[\n" +
+	"\n" +
+	"ListModels\x12%.centaurx.runner.v1.ListModelsRequest\x1a&.centaurx.runner.v1.ListModelsResponseB1Z/pkt.systems/centaurx/internal/runnerpb;runnerpbb\x06proto3"
 
 var (
 	file_proto_runner_v1_runner_proto_rawDescOnce sync.Once
@@ -1716,63 +2123,74 @@ func file_proto_runner_v1_runner_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_runner_v1_runner_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
-var file_proto_runner_v1_runner_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_proto_runner_v1_runner_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_proto_runner_v1_runner_proto_goTypes = []any{
-	(ProcessSignal)(0),        // 0: centaurx.runner.v1.ProcessSignal
-	(RunState)(0),             // 1: centaurx.runner.v1.RunState
-	(StreamKind)(0),           // 2: centaurx.runner.v1.StreamKind
-	(EventType)(0),            // 3: centaurx.runner.v1.EventType
-	(ItemType)(0),             // 4: centaurx.runner.v1.ItemType
-	(*ExecRequest)(nil),       // 5: centaurx.runner.v1.ExecRequest
-	(*ExecResumeRequest)(nil), // 6: centaurx.runner.v1.ExecResumeRequest
-	(*RunCommandRequest)(nil), // 7: centaurx.runner.v1.RunCommandRequest
-	(*PingRequest)(nil),       // 8: centaurx.runner.v1.PingRequest
-	(*PingResponse)(nil),      // 9: centaurx.runner.v1.PingResponse
-	(*SignalRequest)(nil),     // 10: centaurx.runner.v1.SignalRequest
-	(*SignalResponse)(nil),    // 11: centaurx.runner.v1.SignalResponse
-	(*UsageRequest)(nil),      // 12: centaurx.runner.v1.UsageRequest
-	(*UsageResponse)(nil),     // 13: centaurx.runner.v1.UsageResponse
-	(*UsageWindow)(nil),       // 14: centaurx.runner.v1.UsageWindow
-	(*RunnerEvent)(nil),       // 15: centaurx.runner.v1.RunnerEvent
-	(*RunStatus)(nil),         // 16: centaurx.runner.v1.RunStatus
-	(*CommandOutput)(nil),     // 17: centaurx.runner.v1.CommandOutput
-	(*ExecEvent)(nil),         // 18: centaurx.runner.v1.ExecEvent
-	(*TurnUsage)(nil),         // 19: centaurx.runner.v1.TurnUsage
-	(*ItemEvent)(nil),         // 20: centaurx.runner.v1.ItemEvent
-	(*FileChange)(nil),        // 21: centaurx.runner.v1.FileChange
-	(*TodoItem)(nil),          // 22: centaurx.runner.v1.TodoItem
-	(*ErrorEvent)(nil),        // 23: centaurx.runner.v1.ErrorEvent
+	(ProcessSignal)(0),         // 0: centaurx.runner.v1.ProcessSignal
+	(RunState)(0),              // 1: centaurx.runner.v1.RunState
+	(StreamKind)(0),            // 2: centaurx.runner.v1.StreamKind
+	(EventType)(0),             // 3: centaurx.runner.v1.EventType
+	(ItemType)(0),              // 4: centaurx.runner.v1.ItemType
+	(*ExecRequest)(nil),        // 5: centaurx.runner.v1.ExecRequest
+	(*ExecResumeRequest)(nil),  // 6: centaurx.runner.v1.ExecResumeRequest
+	(*AttachRequest)(nil),      // 7: centaurx.runner.v1.AttachRequest
+	(*RunCommandRequest)(nil),  // 8: centaurx.runner.v1.RunCommandRequest
+	(*PingRequest)(nil),        // 9: centaurx.runner.v1.PingRequest
+	(*PingResponse)(nil),       // 10: centaurx.runner.v1.PingResponse
+	(*SignalRequest)(nil),      // 11: centaurx.runner.v1.SignalRequest
+	(*SignalResponse)(nil),     // 12: centaurx.runner.v1.SignalResponse
+	(*ResizeRequest)(nil),      // 13: centaurx.runner.v1.ResizeRequest
+	(*ResizeResponse)(nil),     // 14: centaurx.runner.v1.ResizeResponse
+	(*UsageRequest)(nil),       // 15: centaurx.runner.v1.UsageRequest
+	(*UsageResponse)(nil),      // 16: centaurx.runner.v1.UsageResponse
+	(*UsageWindow)(nil),        // 17: centaurx.runner.v1.UsageWindow
+	(*RunnerEvent)(nil),        // 18: centaurx.runner.v1.RunnerEvent
+	(*RunStatus)(nil),          // 19: centaurx.runner.v1.RunStatus
+	(*CommandOutput)(nil),      // 20: centaurx.runner.v1.CommandOutput
+	(*ExecEvent)(nil),          // 21: centaurx.runner.v1.ExecEvent
+	(*TurnUsage)(nil),          // 22: centaurx.runner.v1.TurnUsage
+	(*ItemEvent)(nil),          // 23: centaurx.runner.v1.ItemEvent
+	(*FileChange)(nil),         // 24: centaurx.runner.v1.FileChange
+	(*TodoItem)(nil),           // 25: centaurx.runner.v1.TodoItem
+	(*ErrorEvent)(nil),         // 26: centaurx.runner.v1.ErrorEvent
+	(*ListModelsRequest)(nil),  // 27: centaurx.runner.v1.ListModelsRequest
+	(*ListModelsResponse)(nil), // 28: centaurx.runner.v1.ListModelsResponse
 }
 var file_proto_runner_v1_runner_proto_depIdxs = []int32{
 	0,  // 0: centaurx.runner.v1.SignalRequest.signal:type_name -> centaurx.runner.v1.ProcessSignal
-	14, // 1: centaurx.runner.v1.UsageResponse.primary_window:type_name -> centaurx.runner.v1.UsageWindow
-	14, // 2: centaurx.runner.v1.UsageResponse.secondary_window:type_name -> centaurx.runner.v1.UsageWindow
-	18, // 3: centaurx.runner.v1.RunnerEvent.exec:type_name -> centaurx.runner.v1.ExecEvent
-	17, // 4: centaurx.runner.v1.RunnerEvent.command_output:type_name -> centaurx.runner.v1.CommandOutput
-	16, // 5: centaurx.runner.v1.RunnerEvent.status:type_name -> centaurx.runner.v1.RunStatus
+	17, // 1: centaurx.runner.v1.UsageResponse.primary_window:type_name -> centaurx.runner.v1.UsageWindow
+	17, // 2: centaurx.runner.v1.UsageResponse.secondary_window:type_name -> centaurx.runner.v1.UsageWindow
+	21, // 3: centaurx.runner.v1.RunnerEvent.exec:type_name -> centaurx.runner.v1.ExecEvent
+	20, // 4: centaurx.runner.v1.RunnerEvent.command_output:type_name -> centaurx.runner.v1.CommandOutput
+	19, // 5: centaurx.runner.v1.RunnerEvent.status:type_name -> centaurx.runner.v1.RunStatus
 	1,  // 6: centaurx.runner.v1.RunStatus.state:type_name -> centaurx.runner.v1.RunState
 	2,  // 7: centaurx.runner.v1.CommandOutput.stream:type_name -> centaurx.runner.v1.StreamKind
 	3,  // 8: centaurx.runner.v1.ExecEvent.type:type_name -> centaurx.runner.v1.EventType
-	19, // 9: centaurx.runner.v1.ExecEvent.usage:type_name -> centaurx.runner.v1.TurnUsage
-	20, // 10: centaurx.runner.v1.ExecEvent.item:type_name -> centaurx.runner.v1.ItemEvent
-	23, // 11: centaurx.runner.v1.ExecEvent.error:type_name -> centaurx.runner.v1.ErrorEvent
+	22, // 9: centaurx.runner.v1.ExecEvent.usage:type_name -> centaurx.runner.v1.TurnUsage
+	23, // 10: centaurx.runner.v1.ExecEvent.item:type_name -> centaurx.runner.v1.ItemEvent
+	26, // 11: centaurx.runner.v1.ExecEvent.error:type_name -> centaurx.runner.v1.ErrorEvent
 	4,  // 12: centaurx.runner.v1.ItemEvent.type:type_name -> centaurx.runner.v1.ItemType
-	21, // 13: centaurx.runner.v1.ItemEvent.changes:type_name -> centaurx.runner.v1.FileChange
-	22, // 14: centaurx.runner.v1.ItemEvent.items:type_name -> centaurx.runner.v1.TodoItem
+	24, // 13: centaurx.runner.v1.ItemEvent.changes:type_name -> centaurx.runner.v1.FileChange
+	25, // 14: centaurx.runner.v1.ItemEvent.items:type_name -> centaurx.runner.v1.TodoItem
 	5,  // 15: centaurx.runner.v1.Runner.Exec:input_type -> centaurx.runner.v1.ExecRequest
 	6,  // 16: centaurx.runner.v1.Runner.ExecResume:input_type -> centaurx.runner.v1.ExecResumeRequest
-	7,  // 17: centaurx.runner.v1.Runner.RunCommand:input_type -> centaurx.runner.v1.RunCommandRequest
-	8,  // 18: centaurx.runner.v1.Runner.Ping:input_type -> centaurx.runner.v1.PingRequest
-	10, // 19: centaurx.runner.v1.Runner.SignalSession:input_type -> centaurx.runner.v1.SignalRequest
-	12, // 20: centaurx.runner.v1.Runner.GetUsage:input_type -> centaurx.runner.v1.UsageRequest
-	15, // 21: centaurx.runner.v1.Runner.Exec:output_type -> centaurx.runner.v1.RunnerEvent
-	15, // 22: centaurx.runner.v1.Runner.ExecResume:output_type -> centaurx.runner.v1.RunnerEvent
-	15, // 23: centaurx.runner.v1.Runner.RunCommand:output_type -> centaurx.runner.v1.RunnerEvent
-	9,  // 24: centaurx.runner.v1.Runner.Ping:output_type -> centaurx.runner.v1.PingResponse
-	11, // 25: centaurx.runner.v1.Runner.SignalSession:output_type -> centaurx.runner.v1.SignalResponse
-	13, // 26: centaurx.runner.v1.Runner.GetUsage:output_type -> centaurx.runner.v1.UsageResponse
-	21, // [21:27] is the sub-list for method output_type
-	15, // [15:21] is the sub-list for method input_type
+	7,  // 17: centaurx.runner.v1.Runner.Attach:input_type -> centaurx.runner.v1.AttachRequest
+	8,  // 18: centaurx.runner.v1.Runner.RunCommand:input_type -> centaurx.runner.v1.RunCommandRequest
+	9,  // 19: centaurx.runner.v1.Runner.Ping:input_type -> centaurx.runner.v1.PingRequest
+	11, // 20: centaurx.runner.v1.Runner.SignalSession:input_type -> centaurx.runner.v1.SignalRequest
+	13, // 21: centaurx.runner.v1.Runner.ResizeCommand:input_type -> centaurx.runner.v1.ResizeRequest
+	15, // 22: centaurx.runner.v1.Runner.GetUsage:input_type -> centaurx.runner.v1.UsageRequest
+	27, // 23: centaurx.runner.v1.Runner.ListModels:input_type -> centaurx.runner.v1.ListModelsRequest
+	18, // 24: centaurx.runner.v1.Runner.Exec:output_type -> centaurx.runner.v1.RunnerEvent
+	18, // 25: centaurx.runner.v1.Runner.ExecResume:output_type -> centaurx.runner.v1.RunnerEvent
+	18, // 26: centaurx.runner.v1.Runner.Attach:output_type -> centaurx.runner.v1.RunnerEvent
+	18, // 27: centaurx.runner.v1.Runner.RunCommand:output_type -> centaurx.runner.v1.RunnerEvent
+	10, // 28: centaurx.runner.v1.Runner.Ping:output_type -> centaurx.runner.v1.PingResponse
+	12, // 29: centaurx.runner.v1.Runner.SignalSession:output_type -> centaurx.runner.v1.SignalResponse
+	14, // 30: centaurx.runner.v1.Runner.ResizeCommand:output_type -> centaurx.runner.v1.ResizeResponse
+	16, // 31: centaurx.runner.v1.Runner.GetUsage:output_type -> centaurx.runner.v1.UsageResponse
+	28, // 32: centaurx.runner.v1.Runner.ListModels:output_type -> centaurx.runner.v1.ListModelsResponse
+	24, // [24:33] is the sub-list for method output_type
+	15, // [15:24] is the sub-list for method input_type
 	15, // [15:15] is the sub-list for extension type_name
 	15, // [15:15] is the sub-list for extension extendee
 	0,  // [0:15] is the sub-list for field type_name
@@ -1783,19 +2201,21 @@ func file_proto_runner_v1_runner_proto_init() {
 	if File_proto_runner_v1_runner_proto != nil {
 		return
 	}
-	file_proto_runner_v1_runner_proto_msgTypes[10].OneofWrappers = []any{
+	file_proto_runner_v1_runner_proto_msgTypes[0].OneofWrappers = []any{}
+	file_proto_runner_v1_runner_proto_msgTypes[1].OneofWrappers = []any{}
+	file_proto_runner_v1_runner_proto_msgTypes[13].OneofWrappers = []any{
 		(*RunnerEvent_Exec)(nil),
 		(*RunnerEvent_CommandOutput)(nil),
 		(*RunnerEvent_Status)(nil),
 	}
-	file_proto_runner_v1_runner_proto_msgTypes[15].OneofWrappers = []any{}
+	file_proto_runner_v1_runner_proto_msgTypes[18].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_runner_v1_runner_proto_rawDesc), len(file_proto_runner_v1_runner_proto_rawDesc)),
 			NumEnums:      5,
-			NumMessages:   19,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
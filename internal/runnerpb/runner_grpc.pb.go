@@ -21,10 +21,13 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	Runner_Exec_FullMethodName          = "/centaurx.runner.v1.Runner/Exec"
 	Runner_ExecResume_FullMethodName    = "/centaurx.runner.v1.Runner/ExecResume"
+	Runner_Attach_FullMethodName        = "/centaurx.runner.v1.Runner/Attach"
 	Runner_RunCommand_FullMethodName    = "/centaurx.runner.v1.Runner/RunCommand"
 	Runner_Ping_FullMethodName          = "/centaurx.runner.v1.Runner/Ping"
 	Runner_SignalSession_FullMethodName = "/centaurx.runner.v1.Runner/SignalSession"
+	Runner_ResizeCommand_FullMethodName = "/centaurx.runner.v1.Runner/ResizeCommand"
 	Runner_GetUsage_FullMethodName      = "/centaurx.runner.v1.Runner/GetUsage"
+	Runner_ListModels_FullMethodName    = "/centaurx.runner.v1.Runner/ListModels"
 )
 
 // RunnerClient is the client API for Runner service.
@@ -33,10 +36,13 @@ const (
 type RunnerClient interface {
 	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunnerEvent], error)
 	ExecResume(ctx context.Context, in *ExecResumeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunnerEvent], error)
+	Attach(ctx context.Context, in *AttachRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunnerEvent], error)
 	RunCommand(ctx context.Context, in *RunCommandRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunnerEvent], error)
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	SignalSession(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*SignalResponse, error)
+	ResizeCommand(ctx context.Context, in *ResizeRequest, opts ...grpc.CallOption) (*ResizeResponse, error)
 	GetUsage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*UsageResponse, error)
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
 }
 
 type runnerClient struct {
@@ -85,9 +91,28 @@ func (c *runnerClient) ExecResume(ctx context.Context, in *ExecResumeRequest, op
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Runner_ExecResumeClient = grpc.ServerStreamingClient[RunnerEvent]
 
+func (c *runnerClient) Attach(ctx context.Context, in *AttachRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunnerEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Runner_ServiceDesc.Streams[2], Runner_Attach_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AttachRequest, RunnerEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Runner_AttachClient = grpc.ServerStreamingClient[RunnerEvent]
+
 func (c *runnerClient) RunCommand(ctx context.Context, in *RunCommandRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunnerEvent], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Runner_ServiceDesc.Streams[2], Runner_RunCommand_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Runner_ServiceDesc.Streams[3], Runner_RunCommand_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +149,16 @@ func (c *runnerClient) SignalSession(ctx context.Context, in *SignalRequest, opt
 	return out, nil
 }
 
+func (c *runnerClient) ResizeCommand(ctx context.Context, in *ResizeRequest, opts ...grpc.CallOption) (*ResizeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResizeResponse)
+	err := c.cc.Invoke(ctx, Runner_ResizeCommand_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *runnerClient) GetUsage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*UsageResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(UsageResponse)
@@ -134,16 +169,29 @@ func (c *runnerClient) GetUsage(ctx context.Context, in *UsageRequest, opts ...g
 	return out, nil
 }
 
+func (c *runnerClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, Runner_ListModels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RunnerServer is the server API for Runner service.
 // All implementations must embed UnimplementedRunnerServer
 // for forward compatibility.
 type RunnerServer interface {
 	Exec(*ExecRequest, grpc.ServerStreamingServer[RunnerEvent]) error
 	ExecResume(*ExecResumeRequest, grpc.ServerStreamingServer[RunnerEvent]) error
+	Attach(*AttachRequest, grpc.ServerStreamingServer[RunnerEvent]) error
 	RunCommand(*RunCommandRequest, grpc.ServerStreamingServer[RunnerEvent]) error
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	SignalSession(context.Context, *SignalRequest) (*SignalResponse, error)
+	ResizeCommand(context.Context, *ResizeRequest) (*ResizeResponse, error)
 	GetUsage(context.Context, *UsageRequest) (*UsageResponse, error)
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
 	mustEmbedUnimplementedRunnerServer()
 }
 
@@ -160,6 +208,9 @@ func (UnimplementedRunnerServer) Exec(*ExecRequest, grpc.ServerStreamingServer[R
 func (UnimplementedRunnerServer) ExecResume(*ExecResumeRequest, grpc.ServerStreamingServer[RunnerEvent]) error {
 	return status.Error(codes.Unimplemented, "method ExecResume not implemented")
 }
+func (UnimplementedRunnerServer) Attach(*AttachRequest, grpc.ServerStreamingServer[RunnerEvent]) error {
+	return status.Error(codes.Unimplemented, "method Attach not implemented")
+}
 func (UnimplementedRunnerServer) RunCommand(*RunCommandRequest, grpc.ServerStreamingServer[RunnerEvent]) error {
 	return status.Error(codes.Unimplemented, "method RunCommand not implemented")
 }
@@ -169,9 +220,15 @@ func (UnimplementedRunnerServer) Ping(context.Context, *PingRequest) (*PingRespo
 func (UnimplementedRunnerServer) SignalSession(context.Context, *SignalRequest) (*SignalResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method SignalSession not implemented")
 }
+func (UnimplementedRunnerServer) ResizeCommand(context.Context, *ResizeRequest) (*ResizeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ResizeCommand not implemented")
+}
 func (UnimplementedRunnerServer) GetUsage(context.Context, *UsageRequest) (*UsageResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetUsage not implemented")
 }
+func (UnimplementedRunnerServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListModels not implemented")
+}
 func (UnimplementedRunnerServer) mustEmbedUnimplementedRunnerServer() {}
 func (UnimplementedRunnerServer) testEmbeddedByValue()                {}
 
@@ -215,6 +272,17 @@ func _Runner_ExecResume_Handler(srv interface{}, stream grpc.ServerStream) error
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Runner_ExecResumeServer = grpc.ServerStreamingServer[RunnerEvent]
 
+func _Runner_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AttachRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RunnerServer).Attach(m, &grpc.GenericServerStream[AttachRequest, RunnerEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Runner_AttachServer = grpc.ServerStreamingServer[RunnerEvent]
+
 func _Runner_RunCommand_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(RunCommandRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -262,6 +330,24 @@ func _Runner_SignalSession_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Runner_ResizeCommand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServer).ResizeCommand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runner_ResizeCommand_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServer).ResizeCommand(ctx, req.(*ResizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Runner_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UsageRequest)
 	if err := dec(in); err != nil {
@@ -280,6 +366,24 @@ func _Runner_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Runner_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runner_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Runner_ServiceDesc is the grpc.ServiceDesc for Runner service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -295,10 +399,18 @@ var Runner_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SignalSession",
 			Handler:    _Runner_SignalSession_Handler,
 		},
+		{
+			MethodName: "ResizeCommand",
+			Handler:    _Runner_ResizeCommand_Handler,
+		},
 		{
 			MethodName: "GetUsage",
 			Handler:    _Runner_GetUsage_Handler,
 		},
+		{
+			MethodName: "ListModels",
+			Handler:    _Runner_ListModels_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -311,6 +423,11 @@ var Runner_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Runner_ExecResume_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Attach",
+			Handler:       _Runner_Attach_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "RunCommand",
 			Handler:       _Runner_RunCommand_Handler,
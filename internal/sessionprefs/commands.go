@@ -0,0 +1,48 @@
+package sessionprefs
+
+import "sync"
+
+// CommandWaiter tracks the ! commands a session started so the session can
+// wait for them to finish, as SSH line mode does before it exits.
+type CommandWaiter struct {
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	exitCode int
+}
+
+// Start records a command that was started.
+func (w *CommandWaiter) Start() {
+	if w == nil {
+		return
+	}
+	w.wg.Add(1)
+}
+
+// Finish records the end of a started command. A failed command without an
+// exit code counts as exit code 1.
+func (w *CommandWaiter) Finish(exitCode int, err error) {
+	if w == nil {
+		return
+	}
+	if err != nil && exitCode == 0 {
+		exitCode = 1
+	}
+	w.mu.Lock()
+	if exitCode != 0 {
+		w.exitCode = exitCode
+	}
+	w.mu.Unlock()
+	w.wg.Done()
+}
+
+// Wait blocks until every started command finished and returns the exit
+// code of the last one that failed, or 0.
+func (w *CommandWaiter) Wait() int {
+	if w == nil {
+		return 0
+	}
+	w.wg.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.exitCode
+}
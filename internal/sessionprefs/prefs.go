@@ -9,7 +9,24 @@ import (
 // Prefs captures per-session preferences.
 type Prefs struct {
 	FullCommandOutput bool
-	ActiveTab         schema.TabID
+	FullReasoning     bool
+	// GlobalHistory makes prompt history navigation use the per-user
+	// history across all tabs instead of the active tab's history.
+	GlobalHistory bool
+	// Timestamps prefixes viewport lines with the time they were appended.
+	Timestamps bool
+	// Timezone is the IANA zone chosen with /tz in this session; empty
+	// falls back to the user's saved zone.
+	Timezone  string
+	ActiveTab schema.TabID
+	// TermRows and TermCols are the size of the session's terminal, used
+	// for commands run on a pseudo-terminal with !!. Zero means unknown.
+	TermRows int
+	TermCols int
+	// Capabilities are those the auth backend granted the account at login.
+	Capabilities schema.Capabilities
+	// Commands, when set, tracks the ! commands started in this session.
+	Commands *CommandWaiter
 }
 
 type prefsKey struct{}
@@ -27,6 +44,14 @@ func WithContext(ctx context.Context, prefs *Prefs) context.Context {
 	return context.WithValue(ctx, prefsKey{}, prefs)
 }
 
+// WithoutContext hides any prefs stored in ctx from FromContext.
+func WithoutContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, prefsKey{}, (*Prefs)(nil))
+}
+
 // FromContext returns the prefs stored in the context, if any.
 func FromContext(ctx context.Context) *Prefs {
 	if ctx == nil {
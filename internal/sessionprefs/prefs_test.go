@@ -2,6 +2,7 @@ package sessionprefs
 
 import (
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -33,3 +34,26 @@ func TestWithContextNil(t *testing.T) {
 		t.Fatalf("expected no prefs for empty context")
 	}
 }
+
+func TestWithoutContextHidesPrefs(t *testing.T) {
+	ctx := WithoutContext(WithContext(context.Background(), New()))
+	if FromContext(ctx) != nil {
+		t.Fatalf("expected prefs to be hidden")
+	}
+}
+
+func TestCommandWaiterReportsFailures(t *testing.T) {
+	var waiter CommandWaiter
+	waiter.Start()
+	waiter.Start()
+	go waiter.Finish(0, nil)
+	go waiter.Finish(0, errors.New("stream closed"))
+	if code := waiter.Wait(); code != 1 {
+		t.Fatalf("expected an error to count as exit code 1, got %d", code)
+	}
+	var none *CommandWaiter
+	none.Start()
+	if code := none.Wait(); code != 0 {
+		t.Fatalf("expected a nil waiter to report success, got %d", code)
+	}
+}
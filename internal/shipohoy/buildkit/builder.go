@@ -100,6 +100,12 @@ func (b *Builder) build(ctx context.Context, spec shipohoy.BuildSpec, events cha
 	for k, v := range spec.BuildArgs {
 		attrs["build-arg:"+k] = v
 	}
+	for k, v := range spec.Labels {
+		attrs["label:"+k] = v
+	}
+	if spec.Platform != "" {
+		attrs["platform"] = spec.Platform
+	}
 
 	var statusCh chan *client.SolveStatus
 	var wg sync.WaitGroup
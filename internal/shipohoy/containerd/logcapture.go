@@ -0,0 +1,281 @@
+package containerd
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"pkt.systems/centaurx/internal/shipohoy"
+)
+
+const (
+	defaultLogBufferBytes = 128 * 1024
+	// defaultLogCaptureGrace keeps the captures of containers younger than
+	// this out of reach of the global limit, so WaitForLog keeps working
+	// while a container starts.
+	defaultLogCaptureGrace = 2 * time.Minute
+	// minLogBufferBytes is the smallest stream buffer a shrunk capture
+	// keeps; below it the capture is dropped.
+	minLogBufferBytes = 4 * 1024
+)
+
+type logCapture struct {
+	name     string
+	stdout   *ringBuffer
+	stderr   *ringBuffer
+	attached bool
+	created  time.Time
+	// size is the per-stream size the capture was created with. used is
+	// refreshed when the capture is looked up; it is guarded by
+	// Runtime.logsMu.
+	size int
+	used time.Time
+}
+
+func (r *Runtime) ensureLogCapture(name string, size int) *logCapture {
+	if size <= 0 {
+		size = defaultLogBufferBytes
+	}
+	now := time.Now()
+	r.logsMu.Lock()
+	defer r.logsMu.Unlock()
+	if capture, ok := r.logs[name]; ok {
+		capture.used = now
+		return capture
+	}
+	capture := &logCapture{
+		name:    name,
+		stdout:  newRingBuffer(size),
+		stderr:  newRingBuffer(size),
+		created: now,
+		size:    size,
+		used:    now,
+	}
+	r.logs[name] = capture
+	r.trimLogCapturesLocked(now)
+	return capture
+}
+
+func (r *Runtime) getLogCapture(name string) *logCapture {
+	r.logsMu.Lock()
+	defer r.logsMu.Unlock()
+	capture := r.logs[name]
+	if capture != nil {
+		capture.used = time.Now()
+	}
+	return capture
+}
+
+func (r *Runtime) clearLogCapture(name string) {
+	r.logsMu.Lock()
+	defer r.logsMu.Unlock()
+	delete(r.logs, name)
+}
+
+// trimLogCapturesLocked shrinks the least recently used captures until their
+// total capacity fits logCaptureLimit. Captures younger than logCaptureGrace
+// are never shrunk, so the total may stay above the limit until they age.
+func (r *Runtime) trimLogCapturesLocked(now time.Time) {
+	if r.logCaptureLimit <= 0 {
+		return
+	}
+	var total int64
+	victims := make([]*logCapture, 0, len(r.logs))
+	for _, capture := range r.logs {
+		total += capture.capacity()
+		if now.Sub(capture.created) >= r.logCaptureGrace {
+			victims = append(victims, capture)
+		}
+	}
+	slices.SortFunc(victims, func(a, b *logCapture) int { return a.used.Compare(b.used) })
+	for _, capture := range victims {
+		over := total - r.logCaptureLimit
+		if over <= 0 {
+			break
+		}
+		total -= capture.shrink(over)
+	}
+}
+
+// LogCaptureStats reports the memory held by the log captures.
+func (r *Runtime) LogCaptureStats() shipohoy.LogCaptureStats {
+	r.logsMu.Lock()
+	defer r.logsMu.Unlock()
+	stats := shipohoy.LogCaptureStats{
+		LimitBytes: r.logCaptureLimit,
+		Containers: make([]shipohoy.LogCaptureUsage, 0, len(r.logs)),
+	}
+	for _, capture := range r.logs {
+		usage := shipohoy.LogCaptureUsage{
+			Name:          capture.name,
+			Created:       capture.created,
+			LastUsed:      capture.used,
+			CapacityBytes: capture.capacity(),
+			UsedBytes:     int64(capture.stdout.Len() + capture.stderr.Len()),
+		}
+		usage.Shrunk = usage.CapacityBytes < int64(2*capture.size)
+		stats.CapacityBytes += usage.CapacityBytes
+		stats.UsedBytes += usage.UsedBytes
+		stats.Containers = append(stats.Containers, usage)
+	}
+	slices.SortFunc(stats.Containers, func(a, b shipohoy.LogCaptureUsage) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+func (l *logCapture) capacity() int64 {
+	return int64(l.stdout.Capacity() + l.stderr.Capacity())
+}
+
+// shrink releases at least over bytes by shrinking both stream buffers, or
+// drops them when less than minLogBufferBytes per stream would be left. It
+// returns the number of bytes released.
+func (l *logCapture) shrink(over int64) int64 {
+	before := l.capacity()
+	perStream := (before - over) / 2
+	if perStream < minLogBufferBytes {
+		perStream = 0
+	}
+	l.stdout.Resize(int(perStream))
+	l.stderr.Resize(int(perStream))
+	return before - l.capacity()
+}
+
+func (l *logCapture) contains(stream shipohoy.LogStream, text []byte) bool {
+	switch stream {
+	case shipohoy.LogStdout:
+		return bytesContains(l.stdout.Snapshot(), text)
+	case shipohoy.LogStderr:
+		return bytesContains(l.stderr.Snapshot(), text)
+	case shipohoy.LogBoth:
+		if bytesContains(l.stdout.Snapshot(), text) {
+			return true
+		}
+		return bytesContains(l.stderr.Snapshot(), text)
+	default:
+		return false
+	}
+}
+
+func bytesContains(buf []byte, text []byte) bool {
+	if len(text) == 0 {
+		return true
+	}
+	return bytes.Contains(buf, text)
+}
+
+func tailLines(data []byte, limit int) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if limit > 0 && len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines
+}
+
+type ringBuffer struct {
+	mu     sync.Mutex
+	buf    []byte
+	size   int
+	start  int
+	length int
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	if size < 0 {
+		size = 0
+	}
+	return &ringBuffer{size: size}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size == 0 {
+		return len(p), nil
+	}
+	if r.buf == nil {
+		r.buf = make([]byte, r.size)
+	}
+	if len(p) >= r.size {
+		copy(r.buf, p[len(p)-r.size:])
+		r.start = 0
+		r.length = r.size
+		return len(p), nil
+	}
+	for _, b := range p {
+		if r.length < r.size {
+			idx := (r.start + r.length) % r.size
+			r.buf[idx] = b
+			r.length++
+		} else {
+			r.buf[r.start] = b
+			r.start = (r.start + 1) % r.size
+		}
+	}
+	return len(p), nil
+}
+
+func (r *ringBuffer) Snapshot() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshotLocked()
+}
+
+func (r *ringBuffer) snapshotLocked() []byte {
+	if r.length == 0 {
+		return nil
+	}
+	out := make([]byte, r.length)
+	if r.start+r.length <= r.size {
+		copy(out, r.buf[r.start:r.start+r.length])
+		return out
+	}
+	n := r.size - r.start
+	copy(out, r.buf[r.start:])
+	copy(out[n:], r.buf[:r.length-n])
+	return out
+}
+
+// Resize changes the buffer size and keeps the most recent bytes. A size of
+// 0 frees the buffer; later writes are discarded.
+func (r *ringBuffer) Resize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if size == r.size {
+		return
+	}
+	data := r.snapshotLocked()
+	r.size = size
+	r.buf = nil
+	r.start = 0
+	r.length = 0
+	if size == 0 || len(data) == 0 {
+		return
+	}
+	if len(data) > size {
+		data = data[len(data)-size:]
+	}
+	r.buf = make([]byte, size)
+	r.length = copy(r.buf, data)
+}
+
+// Capacity returns the buffer size.
+func (r *ringBuffer) Capacity() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// Len returns the number of buffered bytes.
+func (r *ringBuffer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.length
+}
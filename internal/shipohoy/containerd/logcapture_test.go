@@ -0,0 +1,124 @@
+package containerd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/shipohoy"
+)
+
+func newLogCaptureRuntime(limit int64) *Runtime {
+	return &Runtime{
+		logs:            make(map[string]*logCapture),
+		logCaptureLimit: limit,
+		logCaptureGrace: time.Minute,
+	}
+}
+
+// age moves a capture's creation and last use into the past.
+func age(capture *logCapture, created, used time.Duration) {
+	now := time.Now()
+	capture.created = now.Add(-created)
+	capture.used = now.Add(-used)
+}
+
+func TestLogCaptureLimitShrinksLeastRecentlyUsed(t *testing.T) {
+	const size = 8 * 1024
+	r := newLogCaptureRuntime(4 * 2 * size)
+	a := r.ensureLogCapture("a", size)
+	b := r.ensureLogCapture("b", size)
+	c := r.ensureLogCapture("c", size)
+	_, _ = a.stdout.Write(bytes.Repeat([]byte("x"), size-4))
+	_, _ = a.stdout.Write([]byte("tail"))
+	age(a, 5*time.Minute, 3*time.Minute)
+	age(b, 5*time.Minute, time.Minute)
+	age(c, 5*time.Minute, 2*time.Minute)
+
+	// Five captures exceed the limit by one capture; a was used least
+	// recently and is dropped.
+	r.ensureLogCapture("d", size)
+	r.ensureLogCapture("e", size)
+	if got := a.capacity(); got != 0 {
+		t.Fatalf("expected least recently used capture dropped, got capacity %d", got)
+	}
+	if b.capacity() != 2*size || c.capacity() != 2*size {
+		t.Fatalf("expected other captures intact, got %d and %d", b.capacity(), c.capacity())
+	}
+	if n, _ := a.stdout.Write([]byte("more")); n != 4 || a.stdout.Len() != 0 {
+		t.Fatalf("expected writes to a dropped capture to be discarded")
+	}
+
+	stats := r.LogCaptureStats()
+	if stats.LimitBytes != 4*2*size || stats.CapacityBytes != 4*2*size || len(stats.Containers) != 5 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if first := stats.Containers[0]; first.Name != "a" || !first.Shrunk || first.CapacityBytes != 0 {
+		t.Fatalf("expected a reported as dropped, got %+v", first)
+	}
+	if stats.Containers[1].Shrunk {
+		t.Fatalf("expected b not shrunk, got %+v", stats.Containers[1])
+	}
+}
+
+func TestLogCaptureLimitShrinksBeforeDropping(t *testing.T) {
+	const size = 16 * 1024
+	r := newLogCaptureRuntime(3 * size)
+	a := r.ensureLogCapture("a", size)
+	_, _ = a.stdout.Write(bytes.Repeat([]byte("x"), size-4))
+	_, _ = a.stdout.Write([]byte("tail"))
+	age(a, 5*time.Minute, 5*time.Minute)
+
+	r.ensureLogCapture("b", size)
+	if got := a.capacity(); got != size {
+		t.Fatalf("expected capture shrunk to %d, got %d", size, got)
+	}
+	if !bytes.HasSuffix(a.stdout.Snapshot(), []byte("tail")) || a.stdout.Len() != size/2 {
+		t.Fatalf("expected shrunk capture to keep the newest output")
+	}
+	if !a.contains(shipohoy.LogStdout, []byte("tail")) {
+		t.Fatalf("expected shrunk capture to still match recent output")
+	}
+}
+
+func TestLogCaptureLimitKeepsYoungCaptures(t *testing.T) {
+	const size = 8 * 1024
+	r := newLogCaptureRuntime(2 * size)
+	a := r.ensureLogCapture("a", size)
+	b := r.ensureLogCapture("b", size)
+	if a.capacity() != 2*size || b.capacity() != 2*size {
+		t.Fatalf("expected captures within the grace period kept, got %d and %d", a.capacity(), b.capacity())
+	}
+	if stats := r.LogCaptureStats(); stats.CapacityBytes <= stats.LimitBytes {
+		t.Fatalf("expected young captures to exceed the limit, got %+v", stats)
+	}
+
+	// Once a ages past the grace period, the next capture pushes it out.
+	age(a, 5*time.Minute, 5*time.Minute)
+	r.ensureLogCapture("c", size)
+	if a.capacity() != 0 || b.capacity() != 2*size {
+		t.Fatalf("expected only the aged capture dropped, got %d and %d", a.capacity(), b.capacity())
+	}
+}
+
+func TestRingBufferResize(t *testing.T) {
+	buf := newRingBuffer(8)
+	_, _ = buf.Write([]byte("abcdefghij"))
+	buf.Resize(4)
+	if got := string(buf.Snapshot()); got != "ghij" {
+		t.Fatalf("expected newest bytes kept, got %q", got)
+	}
+	_, _ = buf.Write([]byte("kl"))
+	if got := string(buf.Snapshot()); got != "ijkl" {
+		t.Fatalf("expected ring to wrap after resize, got %q", got)
+	}
+	buf.Resize(16)
+	_, _ = buf.Write([]byte("m"))
+	if got := string(buf.Snapshot()); got != "ijklm" {
+		t.Fatalf("expected grown buffer to keep contents, got %q", got)
+	}
+	buf.Resize(0)
+	if buf.Capacity() != 0 || buf.Snapshot() != nil {
+		t.Fatalf("expected buffer freed")
+	}
+}
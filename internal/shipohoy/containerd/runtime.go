@@ -1,7 +1,6 @@
 package containerd
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -16,7 +15,9 @@ import (
 	"time"
 
 	containerd "github.com/containerd/containerd/v2/client"
+	"github.com/containerd/containerd/v2/contrib/seccomp"
 	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/core/images"
 	transferimage "github.com/containerd/containerd/v2/core/transfer/image"
 	"github.com/containerd/containerd/v2/core/transfer/registry"
@@ -30,6 +31,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
 	"pkt.systems/pslog"
 )
 
@@ -38,6 +40,9 @@ type Config struct {
 	Address     string
 	Namespace   string
 	PullTimeout time.Duration
+	// LogCaptureTotalBytes caps the memory of all in-memory log captures
+	// together; 0 leaves it unbounded.
+	LogCaptureTotalBytes int64
 }
 
 // Runtime implements shipohoy.Runtime using containerd.
@@ -45,11 +50,15 @@ type Runtime struct {
 	client      *containerd.Client
 	namespace   string
 	pullTimeout time.Duration
-
-	logsMu   sync.Mutex
-	logs     map[string]*logCapture
-	watchMu  sync.Mutex
-	watchers map[string]struct{}
+	// platform is the host platform images are matched against.
+	platform ocispec.Platform
+
+	logsMu          sync.Mutex
+	logs            map[string]*logCapture
+	logCaptureLimit int64
+	logCaptureGrace time.Duration
+	watchMu         sync.Mutex
+	watchers        map[string]struct{}
 }
 
 // New constructs a containerd runtime, trying fallback socket paths if needed.
@@ -71,11 +80,14 @@ func New(ctx context.Context, cfg Config) (*Runtime, error) {
 			}
 			log.Info("containerd runtime ready", "address", addr, "namespace", namespace)
 			return &Runtime{
-				client:      client,
-				namespace:   namespace,
-				pullTimeout: timeout,
-				logs:        make(map[string]*logCapture),
-				watchers:    make(map[string]struct{}),
+				client:          client,
+				namespace:       namespace,
+				pullTimeout:     timeout,
+				platform:        platforms.DefaultSpec(),
+				logs:            make(map[string]*logCapture),
+				logCaptureLimit: cfg.LogCaptureTotalBytes,
+				logCaptureGrace: defaultLogCaptureGrace,
+				watchers:        make(map[string]struct{}),
 			}, nil
 		}
 		log.Warn("containerd connect failed", "address", addr, "err", err)
@@ -134,11 +146,17 @@ func (r *Runtime) Import(ctx context.Context, tarPath string, tags []string) err
 	defer func() { _ = file.Close() }()
 
 	ctx = namespaces.WithNamespace(ctx, r.namespace)
-	imported, err := r.client.Import(ctx, file)
+	imported, err := r.client.Import(ctx, file, containerd.WithImportPlatform(platforms.Only(r.platform)))
 	if err != nil {
 		log.Warn("containerd import failed", "err", err)
 		return err
 	}
+	for _, img := range imported {
+		if err := checkImagePlatform(ctx, r.client.ContentStore(), img.Name, img.Target, r.platform); err != nil {
+			log.Warn("containerd import failed", "image", img.Name, "err", err)
+			return err
+		}
+	}
 	if len(tags) == 0 {
 		log.Info("containerd import ok", "images", len(imported))
 		return nil
@@ -220,6 +238,10 @@ func (r *Runtime) ensureImage(ctx context.Context, image, snapshotter string) (c
 	img, err := r.client.GetImage(ctx, image)
 	if err == nil {
 		log.Debug("containerd image present")
+		if err := checkImagePlatform(ctx, r.client.ContentStore(), image, img.Target(), r.platform); err != nil {
+			log.Warn("containerd image platform mismatch", "err", err)
+			return nil, err
+		}
 		if snapshotter != "" && !rootless {
 			if err := img.Unpack(ctx, snapshotter); err != nil && !errdefs.IsAlreadyExists(err) {
 				log.Warn("containerd image unpack failed", "err", err)
@@ -236,13 +258,17 @@ func (r *Runtime) ensureImage(ctx context.Context, image, snapshotter string) (c
 	defer cancel()
 	log.Info("containerd image pull start", "rootless", rootless)
 	if pulled, err := r.pullWithTransfer(pullCtx, image, snapshotter, !rootless); err == nil {
+		if err := checkImagePlatform(ctx, r.client.ContentStore(), image, pulled.Target(), r.platform); err != nil {
+			log.Warn("containerd image platform mismatch", "err", err)
+			return nil, err
+		}
 		log.Info("containerd image pull ok", "method", "transfer")
 		return pulled, nil
 	} else if rootless {
 		log.Warn("containerd transfer pull failed", "err", err)
 		return nil, fmt.Errorf("transfer pull failed: %w", err)
 	}
-	opts := []containerd.RemoteOpt{containerd.WithPullUnpack}
+	opts := []containerd.RemoteOpt{containerd.WithPullUnpack, containerd.WithPlatformMatcher(platforms.Only(r.platform))}
 	if snapshotter != "" {
 		opts = append(opts, containerd.WithPullSnapshotter(snapshotter))
 	}
@@ -251,15 +277,18 @@ func (r *Runtime) ensureImage(ctx context.Context, image, snapshotter string) (c
 		log.Warn("containerd image pull failed", "err", err)
 		return nil, err
 	}
+	if err := checkImagePlatform(ctx, r.client.ContentStore(), image, img.Target(), r.platform); err != nil {
+		log.Warn("containerd image platform mismatch", "err", err)
+		return nil, err
+	}
 	log.Info("containerd image pull ok", "method", "pull")
 	return img, nil
 }
 
 func (r *Runtime) pullWithTransfer(ctx context.Context, image, snapshotter string, unpack bool) (containerd.Image, error) {
-	storeOpts := []transferimage.StoreOpt{}
+	storeOpts := []transferimage.StoreOpt{transferimage.WithPlatforms(r.platform)}
 	if unpack {
-		platform := platforms.DefaultSpec()
-		storeOpts = append(storeOpts, transferimage.WithUnpack(platform, snapshotter))
+		storeOpts = append(storeOpts, transferimage.WithUnpack(r.platform, snapshotter))
 	}
 	store := transferimage.NewStore(image, storeOpts...)
 	reg, err := registry.NewOCIRegistry(ctx, image)
@@ -272,6 +301,33 @@ func (r *Runtime) pullWithTransfer(ctx context.Context, image, snapshotter strin
 	return r.client.GetImage(ctx, image)
 }
 
+// checkImagePlatform fails with a *shipohoy.PlatformError when the image at
+// target has no manifest for host. For a multi-arch index the platforms of
+// its entries are compared, so content for other platforms need not be
+// present.
+func checkImagePlatform(ctx context.Context, provider content.Provider, name string, target ocispec.Descriptor, host ocispec.Platform) error {
+	available, err := images.Platforms(ctx, provider, target)
+	if err != nil {
+		return fmt.Errorf("image %s: read platforms: %w", name, err)
+	}
+	return shipohoy.CheckPlatform(name, host, available)
+}
+
+// InspectImage returns the digest and labels of a local image.
+func (r *Runtime) InspectImage(ctx context.Context, image string) (shipohoy.ImageInfo, error) {
+	ctx = namespaces.WithNamespace(ctx, r.namespace)
+	img, err := r.client.GetImage(ctx, image)
+	if err != nil {
+		return shipohoy.ImageInfo{}, err
+	}
+	img = containerd.NewImageWithPlatform(r.client, img.Metadata(), platforms.Only(r.platform))
+	spec, err := img.Spec(ctx)
+	if err != nil {
+		return shipohoy.ImageInfo{}, fmt.Errorf("image %s: read config: %w", image, err)
+	}
+	return shipohoy.ImageInfo{Digest: img.Target().Digest.String(), Labels: spec.Config.Labels}, nil
+}
+
 // EnsureRunning ensures a container exists and is running.
 func (r *Runtime) EnsureRunning(ctx context.Context, spec shipohoy.ContainerSpec) (shipohoy.Handle, error) {
 	if strings.TrimSpace(spec.Name) == "" {
@@ -286,8 +342,8 @@ func (r *Runtime) EnsureRunning(ctx context.Context, spec shipohoy.ContainerSpec
 	log.Info("containerd ensure running start")
 	ctx = namespaces.WithNamespace(ctx, r.namespace)
 
-	labels := mergeLabels(spec.Labels, map[string]string{
-		labelManaged: "true",
+	containerLabels := mergeLabels(spec.Labels, map[string]string{
+		labels.Managed: "true",
 	})
 
 	container, err := r.client.LoadContainer(ctx, spec.Name)
@@ -304,7 +360,7 @@ func (r *Runtime) EnsureRunning(ctx context.Context, spec shipohoy.ContainerSpec
 		specOpts := append([]oci.SpecOpts{oci.WithImageConfig(image)}, r.specOptions(spec)...)
 		containerOpts := []containerd.NewContainerOpts{
 			containerd.WithImage(image),
-			containerd.WithContainerLabels(labels),
+			containerd.WithContainerLabels(containerLabels),
 		}
 		if strings.TrimSpace(spec.Snapshotter) != "" {
 			containerOpts = append(containerOpts, containerd.WithSnapshotter(spec.Snapshotter))
@@ -402,7 +458,8 @@ func (r *Runtime) Stop(ctx context.Context, handle shipohoy.Handle) error {
 	return nil
 }
 
-// Remove deletes the container and its snapshot.
+// Remove deletes the container and its snapshot, killing a task that is
+// still running.
 func (r *Runtime) Remove(ctx context.Context, handle shipohoy.Handle) error {
 	if handle == nil {
 		return nil
@@ -419,6 +476,11 @@ func (r *Runtime) Remove(ctx context.Context, handle shipohoy.Handle) error {
 		log.Warn("containerd remove failed", "err", err)
 		return err
 	}
+	if task, err := container.Task(ctx, nil); err == nil {
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+			log.Warn("containerd task kill failed", "err", err)
+		}
+	}
 	err = container.Delete(ctx, containerd.WithSnapshotCleanup)
 	r.clearLogCapture(handle.Name())
 	if err != nil {
@@ -626,33 +688,95 @@ func (r *Runtime) TailLogs(ctx context.Context, handle shipohoy.Handle, limit in
 	return stdout, stderr, nil
 }
 
+// ListManaged returns the managed containers matching selector.
+func (r *Runtime) ListManaged(ctx context.Context, selector map[string]string) ([]shipohoy.ManagedContainer, error) {
+	log := r.logger(ctx)
+	ctx = namespaces.WithNamespace(ctx, r.namespace)
+	list, err := r.client.ContainerService().List(ctx)
+	if err != nil {
+		log.Warn("containerd list managed failed", "err", err)
+		return nil, err
+	}
+	out := managedContainers(list, selector, func(id string) shipohoy.ContainerState {
+		return r.containerState(ctx, id)
+	})
+	log.Debug("containerd list managed ok", "count", len(out))
+	return out, nil
+}
+
+// managedContainers converts the managed containers in list matching
+// selector; state looks up the task state of a container.
+func managedContainers(list []containers.Container, selector map[string]string, state func(id string) shipohoy.ContainerState) []shipohoy.ManagedContainer {
+	out := make([]shipohoy.ManagedContainer, 0, len(list))
+	for _, info := range list {
+		if !labels.IsManaged(info.Labels) || !labels.Matches(info.Labels, selector) {
+			continue
+		}
+		out = append(out, shipohoy.ManagedContainer{
+			Name:    info.ID,
+			ID:      info.ID,
+			Image:   info.Image,
+			Labels:  info.Labels,
+			State:   state(info.ID),
+			Created: info.CreatedAt,
+		})
+	}
+	return out
+}
+
+// containerState reports the state of a container's task; a container
+// without a task has not been started.
+func (r *Runtime) containerState(ctx context.Context, id string) shipohoy.ContainerState {
+	container, err := r.client.LoadContainer(ctx, id)
+	if err != nil {
+		return shipohoy.ContainerUnknown
+	}
+	task, err := container.Task(ctx, nil)
+	if errdefs.IsNotFound(err) {
+		return shipohoy.ContainerCreated
+	}
+	if err != nil {
+		return shipohoy.ContainerUnknown
+	}
+	status, err := task.Status(ctx)
+	if err != nil {
+		return shipohoy.ContainerUnknown
+	}
+	return taskState(status.Status)
+}
+
+// taskState maps a containerd task status to a ContainerState.
+func taskState(status containerd.ProcessStatus) shipohoy.ContainerState {
+	switch status {
+	case containerd.Running:
+		return shipohoy.ContainerRunning
+	case containerd.Paused, containerd.Pausing:
+		return shipohoy.ContainerPaused
+	case containerd.Created:
+		return shipohoy.ContainerCreated
+	case containerd.Stopped:
+		return shipohoy.ContainerStopped
+	default:
+		return shipohoy.ContainerUnknown
+	}
+}
+
 // Janitor stops and removes managed containers.
 func (r *Runtime) Janitor(ctx context.Context, spec shipohoy.JanitorSpec) (int, error) {
 	log := r.logger(ctx)
 	log.Info("containerd janitor start")
-	ctx = namespaces.WithNamespace(ctx, r.namespace)
-	containers, err := r.client.Containers(ctx)
+	list, err := r.ListManaged(ctx, spec.LabelSelector)
 	if err != nil {
 		log.Warn("containerd janitor failed", "err", err)
 		return 0, err
 	}
 	removed := 0
 	now := time.Now()
-	for _, container := range containers {
-		info, err := container.Info(ctx)
-		if err != nil {
-			continue
-		}
-		if !matchesLabels(info.Labels, spec.LabelSelector) {
-			continue
-		}
-		if info.Labels[labelManaged] != "true" {
-			continue
-		}
-		if spec.MinAge > 0 && now.Sub(info.CreatedAt) < spec.MinAge {
+	for _, item := range list {
+		if spec.MinAge > 0 && now.Sub(item.Created) < spec.MinAge {
 			continue
 		}
-		handle := &handle{name: info.ID, id: info.ID}
+		handle := &handle{name: item.Name, id: item.ID}
 		_ = r.Stop(ctx, handle)
 		if err := r.Remove(ctx, handle); err == nil {
 			removed++
@@ -677,15 +801,50 @@ func (r *Runtime) specOptions(spec shipohoy.ContainerSpec) []oci.SpecOpts {
 	if spec.ReadOnlyRootfs {
 		opts = append(opts, oci.WithRootFSReadonly())
 	}
-	if spec.HostNetwork {
+	switch spec.NetworkMode {
+	case shipohoy.NetworkHost:
 		opts = append(opts, oci.WithHostNamespace(specs.NetworkNamespace))
+	case shipohoy.NetworkNone:
+		opts = append(opts, oci.WithLinuxNamespace(specs.LinuxNamespace{Type: specs.NetworkNamespace}))
 	}
 	if spec.ResourceCaps != nil {
 		opts = append(opts, withResources(*spec.ResourceCaps))
 	}
+	if spec.NoNewPrivileges {
+		opts = append(opts, oci.WithNoNewPrivileges)
+	}
+	if len(spec.DropCapabilities) > 0 {
+		opts = append(opts, withDroppedCapabilities(spec.DropCapabilities))
+	}
+	// The seccomp profile goes last: the default profile depends on the
+	// capabilities left in the spec.
+	switch spec.SeccompProfile {
+	case "", shipohoy.SeccompUnconfined:
+	case shipohoy.SeccompDefault:
+		opts = append(opts, seccomp.WithDefaultProfile())
+	default:
+		opts = append(opts, seccomp.WithProfile(spec.SeccompProfile))
+	}
 	return opts
 }
 
+// withDroppedCapabilities drops caps given with or without the CAP_ prefix;
+// "ALL" clears every capability set.
+func withDroppedCapabilities(caps []string) oci.SpecOpts {
+	names := make([]string, 0, len(caps))
+	for _, c := range caps {
+		name := strings.ToUpper(strings.TrimSpace(c))
+		if name == "ALL" {
+			return oci.WithCapabilities([]string{})
+		}
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		names = append(names, name)
+	}
+	return oci.WithDroppedCapabilities(names)
+}
+
 func flattenEnv(env map[string]string) []string {
 	if len(env) == 0 {
 		return nil
@@ -710,18 +869,6 @@ func mergeLabels(base map[string]string, extra map[string]string) map[string]str
 	return out
 }
 
-func matchesLabels(labels map[string]string, selector map[string]string) bool {
-	if len(selector) == 0 {
-		return true
-	}
-	for k, v := range selector {
-		if labels[k] != v {
-			return false
-		}
-	}
-	return true
-}
-
 func (r *Runtime) processSpec(ctx context.Context, container containerd.Container, spec shipohoy.ExecSpec) (*specs.Process, error) {
 	baseSpec, err := container.Spec(ctx)
 	if err != nil {
@@ -765,35 +912,6 @@ func mergeEnv(base []string, add map[string]string) []string {
 	return out
 }
 
-func (r *Runtime) ensureLogCapture(name string, size int) *logCapture {
-	if size <= 0 {
-		size = defaultLogBufferBytes
-	}
-	r.logsMu.Lock()
-	defer r.logsMu.Unlock()
-	if capture, ok := r.logs[name]; ok {
-		return capture
-	}
-	capture := &logCapture{
-		stdout: newRingBuffer(size),
-		stderr: newRingBuffer(size),
-	}
-	r.logs[name] = capture
-	return capture
-}
-
-func (r *Runtime) getLogCapture(name string) *logCapture {
-	r.logsMu.Lock()
-	defer r.logsMu.Unlock()
-	return r.logs[name]
-}
-
-func (r *Runtime) clearLogCapture(name string) {
-	r.logsMu.Lock()
-	defer r.logsMu.Unlock()
-	delete(r.logs, name)
-}
-
 func (r *Runtime) watchAutoRemove(container containerd.Container, task containerd.Task, name string) {
 	if name == "" {
 		return
@@ -981,111 +1099,6 @@ type handle struct {
 func (h *handle) Name() string { return h.name }
 func (h *handle) ID() string   { return h.id }
 
-const (
-	labelManaged          = "shipohoy.managed"
-	defaultLogBufferBytes = 128 * 1024
-)
-
-type logCapture struct {
-	stdout   *ringBuffer
-	stderr   *ringBuffer
-	attached bool
-}
-
-func (l *logCapture) contains(stream shipohoy.LogStream, text []byte) bool {
-	switch stream {
-	case shipohoy.LogStdout:
-		return bytesContains(l.stdout.Snapshot(), text)
-	case shipohoy.LogStderr:
-		return bytesContains(l.stderr.Snapshot(), text)
-	case shipohoy.LogBoth:
-		if bytesContains(l.stdout.Snapshot(), text) {
-			return true
-		}
-		return bytesContains(l.stderr.Snapshot(), text)
-	default:
-		return false
-	}
-}
-
-func bytesContains(buf []byte, text []byte) bool {
-	if len(text) == 0 {
-		return true
-	}
-	return bytes.Contains(buf, text)
-}
-
-func tailLines(data []byte, limit int) []string {
-	if len(data) == 0 {
-		return nil
-	}
-	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
-	if limit > 0 && len(lines) > limit {
-		lines = lines[len(lines)-limit:]
-	}
-	return lines
-}
-
-type ringBuffer struct {
-	mu     sync.Mutex
-	buf    []byte
-	size   int
-	start  int
-	length int
-}
-
-func newRingBuffer(size int) *ringBuffer {
-	if size < 0 {
-		size = 0
-	}
-	return &ringBuffer{size: size}
-}
-
-func (r *ringBuffer) Write(p []byte) (int, error) {
-	if r.size == 0 {
-		return len(p), nil
-	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if r.buf == nil {
-		r.buf = make([]byte, r.size)
-	}
-	if len(p) >= r.size {
-		copy(r.buf, p[len(p)-r.size:])
-		r.start = 0
-		r.length = r.size
-		return len(p), nil
-	}
-	for _, b := range p {
-		if r.length < r.size {
-			idx := (r.start + r.length) % r.size
-			r.buf[idx] = b
-			r.length++
-		} else {
-			r.buf[r.start] = b
-			r.start = (r.start + 1) % r.size
-		}
-	}
-	return len(p), nil
-}
-
-func (r *ringBuffer) Snapshot() []byte {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if r.length == 0 {
-		return nil
-	}
-	out := make([]byte, r.length)
-	if r.start+r.length <= r.size {
-		copy(out, r.buf[r.start:r.start+r.length])
-		return out
-	}
-	n := r.size - r.start
-	copy(out, r.buf[r.start:])
-	copy(out[n:], r.buf[:r.length-n])
-	return out
-}
-
 func (r *Runtime) logger(ctx context.Context) pslog.Logger {
 	return pslog.Ctx(ctx).With("runtime", "containerd")
 }
@@ -0,0 +1,260 @@
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	containerd "github.com/containerd/containerd/v2/client"
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	specsversioned "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
+)
+
+func TestManagedContainers(t *testing.T) {
+	created := time.Date(2025, time.March, 4, 5, 6, 7, 0, time.UTC)
+	list := []containers.Container{
+		{ID: "centaurx-runner-alice", Labels: labels.Runner("alice", "", "user", created), CreatedAt: created},
+		{ID: "centaurx-runner-bob-t1", Labels: labels.Runner("bob", "t1", "tab", created), CreatedAt: created},
+		{ID: "old-runner", Labels: map[string]string{labels.LegacyManaged: "true"}, CreatedAt: created},
+		{ID: "unrelated", Labels: map[string]string{"other": "x"}, CreatedAt: created},
+	}
+	states := map[string]shipohoy.ContainerState{
+		"centaurx-runner-alice":  shipohoy.ContainerRunning,
+		"centaurx-runner-bob-t1": shipohoy.ContainerCreated,
+		"old-runner":             shipohoy.ContainerStopped,
+	}
+	state := func(id string) shipohoy.ContainerState { return states[id] }
+
+	out := managedContainers(list, nil, state)
+	if len(out) != 3 {
+		t.Fatalf("expected 3 managed containers, got %+v", out)
+	}
+	for _, item := range out {
+		if item.Name != item.ID || item.State != states[item.ID] || !item.Created.Equal(created) {
+			t.Fatalf("unexpected container %+v", item)
+		}
+	}
+
+	out = managedContainers(list, map[string]string{labels.Tab: "t1"}, state)
+	if len(out) != 1 || out[0].ID != "centaurx-runner-bob-t1" || out[0].Labels[labels.User] != "bob" {
+		t.Fatalf("expected only bob's tab container, got %+v", out)
+	}
+}
+
+func TestTaskState(t *testing.T) {
+	tests := map[containerd.ProcessStatus]shipohoy.ContainerState{
+		containerd.Running: shipohoy.ContainerRunning,
+		containerd.Pausing: shipohoy.ContainerPaused,
+		containerd.Paused:  shipohoy.ContainerPaused,
+		containerd.Created: shipohoy.ContainerCreated,
+		containerd.Stopped: shipohoy.ContainerStopped,
+		containerd.Unknown: shipohoy.ContainerUnknown,
+	}
+	for status, want := range tests {
+		if got := taskState(status); got != want {
+			t.Fatalf("taskState(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func generateSpec(t *testing.T, spec shipohoy.ContainerSpec) *oci.Spec {
+	t.Helper()
+	ctx := namespaces.WithNamespace(context.Background(), "test")
+	out, err := oci.GenerateSpecWithPlatform(ctx, nil, "linux/amd64", &containers.Container{ID: "test"}, (&Runtime{}).specOptions(spec)...)
+	if err != nil {
+		t.Fatalf("generate spec: %v", err)
+	}
+	return out
+}
+
+func networkNamespace(spec *oci.Spec) (specs.LinuxNamespace, bool) {
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.NetworkNamespace {
+			return ns, true
+		}
+	}
+	return specs.LinuxNamespace{}, false
+}
+
+func TestSpecOptionsDefaultsUnchanged(t *testing.T) {
+	spec := generateSpec(t, shipohoy.ContainerSpec{Command: []string{"true"}})
+	if spec.Linux.Seccomp != nil {
+		t.Fatalf("expected no seccomp profile by default")
+	}
+	if !slices.Contains(spec.Process.Capabilities.Bounding, "CAP_NET_RAW") {
+		t.Fatalf("expected default capabilities, got %v", spec.Process.Capabilities.Bounding)
+	}
+	if ns, ok := networkNamespace(spec); !ok || ns.Path != "" {
+		t.Fatalf("expected a private network namespace, got %+v %v", ns, ok)
+	}
+}
+
+func TestSpecOptionsHardening(t *testing.T) {
+	spec := generateSpec(t, shipohoy.ContainerSpec{
+		Command:          []string{"true"},
+		NoNewPrivileges:  true,
+		DropCapabilities: []string{"net_raw", "CAP_CHOWN"},
+		SeccompProfile:   shipohoy.SeccompDefault,
+		NetworkMode:      shipohoy.NetworkHost,
+	})
+	if !spec.Process.NoNewPrivileges {
+		t.Fatalf("expected no-new-privileges")
+	}
+	for _, set := range [][]string{spec.Process.Capabilities.Bounding, spec.Process.Capabilities.Effective, spec.Process.Capabilities.Permitted} {
+		if slices.Contains(set, "CAP_NET_RAW") || slices.Contains(set, "CAP_CHOWN") {
+			t.Fatalf("expected dropped capabilities, got %v", set)
+		}
+	}
+	if spec.Linux.Seccomp == nil || spec.Linux.Seccomp.DefaultAction != specs.ActErrno {
+		t.Fatalf("expected the default seccomp profile, got %+v", spec.Linux.Seccomp)
+	}
+	if _, ok := networkNamespace(spec); ok {
+		t.Fatalf("expected the host network namespace")
+	}
+
+	spec = generateSpec(t, shipohoy.ContainerSpec{Command: []string{"true"}, DropCapabilities: []string{"ALL"}, NetworkMode: shipohoy.NetworkNone})
+	if len(spec.Process.Capabilities.Bounding) != 0 || len(spec.Process.Capabilities.Effective) != 0 {
+		t.Fatalf("expected every capability dropped, got %+v", spec.Process.Capabilities)
+	}
+	if _, ok := networkNamespace(spec); !ok {
+		t.Fatalf("expected a private network namespace")
+	}
+}
+
+func TestSpecOptionsSeccompProfileFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seccomp.json")
+	if err := os.WriteFile(path, []byte(`{"defaultAction":"SCMP_ACT_LOG"}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	spec := generateSpec(t, shipohoy.ContainerSpec{Command: []string{"true"}, SeccompProfile: path})
+	if spec.Linux.Seccomp == nil || spec.Linux.Seccomp.DefaultAction != specs.ActLog {
+		t.Fatalf("expected the profile from %s, got %+v", path, spec.Linux.Seccomp)
+	}
+	spec = generateSpec(t, shipohoy.ContainerSpec{Command: []string{"true"}, SeccompProfile: shipohoy.SeccompUnconfined})
+	if spec.Linux.Seccomp != nil {
+		t.Fatalf("expected no seccomp profile when unconfined")
+	}
+}
+
+func TestCheckImagePlatform(t *testing.T) {
+	amd64 := ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	store := memoryContent{}
+	amd64Manifest := store.manifest(t, amd64)
+	arm64Manifest := store.manifest(t, arm64)
+	multiArch := store.index(t, amd64Manifest, arm64Manifest)
+	armOnly := store.index(t, arm64Manifest)
+	ctx := context.Background()
+
+	cases := []struct {
+		name     string
+		target   ocispec.Descriptor
+		mismatch bool
+	}{
+		{name: "manifest match", target: amd64Manifest},
+		{name: "manifest mismatch", target: arm64Manifest, mismatch: true},
+		{name: "index match", target: multiArch},
+		{name: "index mismatch", target: armOnly, mismatch: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := tc.target
+			if target.MediaType == ocispec.MediaTypeImageManifest {
+				// Image targets do not carry a platform; it is read from the config.
+				target.Platform = nil
+			}
+			err := checkImagePlatform(ctx, store, "runner:test", target, amd64)
+			if !tc.mismatch {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var perr *shipohoy.PlatformError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected platform error, got %v", err)
+			}
+			if perr.Host != "linux/amd64" || !slices.Equal(perr.Available, []string{"linux/arm64"}) {
+				t.Fatalf("unexpected platform error: %+v", perr)
+			}
+			if !strings.Contains(err.Error(), "linux/arm64") || !strings.Contains(err.Error(), "linux/amd64") {
+				t.Fatalf("expected both platforms in %q", err)
+			}
+		})
+	}
+
+	// A multi-arch index only needs the entry for the host to be present.
+	if err := checkImagePlatform(ctx, store, "runner:test", multiArch, arm64); err != nil {
+		t.Fatalf("unexpected error for arm64 host: %v", err)
+	}
+}
+
+// memoryContent is a content.Provider over blobs keyed by digest.
+type memoryContent map[digest.Digest][]byte
+
+func (m memoryContent) ReaderAt(_ context.Context, desc ocispec.Descriptor) (content.ReaderAt, error) {
+	data, ok := m[desc.Digest]
+	if !ok {
+		return nil, fmt.Errorf("content %s: %w", desc.Digest, errdefs.ErrNotFound)
+	}
+	return blobReader{Reader: bytes.NewReader(data)}, nil
+}
+
+func (m memoryContent) add(t *testing.T, mediaType string, v any) ocispec.Descriptor {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", mediaType, err)
+	}
+	dgst := digest.FromBytes(data)
+	m[dgst] = data
+	return ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+}
+
+// manifest stores an image config and manifest for platform and returns the
+// manifest descriptor annotated with the platform, as an index entry.
+func (m memoryContent) manifest(t *testing.T, platform ocispec.Platform) ocispec.Descriptor {
+	t.Helper()
+	config := m.add(t, ocispec.MediaTypeImageConfig, ocispec.Image{Platform: platform})
+	desc := m.add(t, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specsversioned.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{},
+	})
+	desc.Platform = &platform
+	return desc
+}
+
+func (m memoryContent) index(t *testing.T, manifests ...ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	return m.add(t, ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specsversioned.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+}
+
+type blobReader struct {
+	*bytes.Reader
+}
+
+func (blobReader) Close() error { return nil }
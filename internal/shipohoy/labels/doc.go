@@ -0,0 +1,4 @@
+// Package labels defines the labels set on the containers centaurx manages,
+// so runtimes, the runner provider and operator tooling agree on how a
+// container maps back to its user and tab.
+package labels
@@ -0,0 +1,65 @@
+package labels
+
+import "time"
+
+const (
+	// Managed marks a container created through a shipohoy runtime.
+	Managed = "centaurx.managed"
+	// User is the centaurx user owning the container.
+	User = "centaurx.user"
+	// Tab is the tab a per-tab container serves; per-user ones leave it out.
+	Tab = "centaurx.tab"
+	// Scope is the runner container scope: "user" or "tab".
+	Scope = "centaurx.scope"
+	// CreatedAt is the creation time in RFC 3339 format.
+	CreatedAt = "centaurx.created-at"
+	// Check marks short-lived containers started by startup checks.
+	Check = "centaurx.check"
+	// LegacyManaged marks containers created before the centaurx.* keys.
+	LegacyManaged = "shipohoy.managed"
+)
+
+// Runner returns the labels for a runner container of user. tab is left out
+// for per-user containers.
+func Runner(user, tab, scope string, created time.Time) map[string]string {
+	out := map[string]string{
+		Managed:   "true",
+		User:      user,
+		Scope:     scope,
+		CreatedAt: created.UTC().Format(time.RFC3339),
+	}
+	if tab != "" {
+		out[Tab] = tab
+	}
+	return out
+}
+
+// IsManaged reports whether labels mark a managed container, including
+// containers labeled before the centaurx.* keys.
+func IsManaged(labels map[string]string) bool {
+	return labels[Managed] == "true" || labels[LegacyManaged] == "true"
+}
+
+// Matches reports whether labels has every key and value in selector. An
+// empty selector matches everything.
+func Matches(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// CreatedTime returns the time recorded under CreatedAt.
+func CreatedTime(labels map[string]string) (time.Time, bool) {
+	value, ok := labels[CreatedAt]
+	if !ok {
+		return time.Time{}, false
+	}
+	created, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return created, true
+}
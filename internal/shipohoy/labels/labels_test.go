@@ -0,0 +1,44 @@
+package labels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	created := time.Date(2025, time.March, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	user := Runner("alice", "", "user", created)
+	if user[Managed] != "true" || user[User] != "alice" || user[Scope] != "user" || user[CreatedAt] != "2025-03-04T04:06:07Z" {
+		t.Fatalf("unexpected user labels %v", user)
+	}
+	if _, ok := user[Tab]; ok {
+		t.Fatalf("expected no tab label for user scope, got %v", user)
+	}
+	tab := Runner("alice", "t1", "tab", created)
+	if tab[Tab] != "t1" || tab[Scope] != "tab" {
+		t.Fatalf("unexpected tab labels %v", tab)
+	}
+	got, ok := CreatedTime(tab)
+	if !ok || !got.Equal(created) {
+		t.Fatalf("CreatedTime = %v, %v", got, ok)
+	}
+}
+
+func TestIsManagedAndMatches(t *testing.T) {
+	if !IsManaged(map[string]string{Managed: "true"}) || !IsManaged(map[string]string{LegacyManaged: "true"}) {
+		t.Fatalf("expected managed labels to be recognised")
+	}
+	if IsManaged(map[string]string{Managed: "false"}) || IsManaged(nil) {
+		t.Fatalf("expected unmanaged labels to be rejected")
+	}
+	set := map[string]string{User: "alice", Tab: "t1"}
+	if !Matches(set, nil) || !Matches(set, map[string]string{User: "alice"}) {
+		t.Fatalf("expected selector to match")
+	}
+	if Matches(set, map[string]string{User: "bob"}) || Matches(set, map[string]string{Scope: "tab"}) {
+		t.Fatalf("expected selector not to match")
+	}
+	if _, ok := CreatedTime(map[string]string{CreatedAt: "yesterday"}); ok {
+		t.Fatalf("expected invalid created-at to be rejected")
+	}
+}
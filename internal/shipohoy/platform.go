@@ -0,0 +1,42 @@
+package shipohoy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/containerd/platforms"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// PlatformError reports an image without a variant for the host platform.
+// Containers from such an image fail at start with exec format errors.
+type PlatformError struct {
+	Image     string
+	Host      string
+	Available []string
+}
+
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("image %s is built for %s, but this host is %s", e.Image, strings.Join(e.Available, ", "), e.Host)
+}
+
+// CheckPlatform returns a *PlatformError unless one of the available
+// platforms of image runs on host. A multi-arch image passes when any of its
+// manifests matches; an image that does not declare a platform passes too.
+func CheckPlatform(image string, host ocispec.Platform, available []ocispec.Platform) error {
+	if len(available) == 0 {
+		return nil
+	}
+	matcher := platforms.Only(host)
+	var names []string
+	for _, platform := range available {
+		if matcher.Match(platform) {
+			return nil
+		}
+		if name := platforms.Format(platform); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return &PlatformError{Image: image, Host: platforms.Format(host), Available: names}
+}
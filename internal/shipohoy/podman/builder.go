@@ -99,6 +99,17 @@ func (b *Builder) build(ctx context.Context, spec shipohoy.BuildSpec, events cha
 		}
 		query.Set("buildargs", string(args))
 	}
+	if len(spec.Labels) > 0 {
+		imageLabels, err := json.Marshal(spec.Labels)
+		if err != nil {
+			log.Warn("podman build failed", "err", err)
+			return shipohoy.BuildResult{}, err
+		}
+		query.Set("labels", string(imageLabels))
+	}
+	if spec.Platform != "" {
+		query.Set("platform", spec.Platform)
+	}
 
 	res, err := client.do(ctx, "POST", "/build", query, tarStream, "application/x-tar")
 	if err != nil {
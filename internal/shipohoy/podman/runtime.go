@@ -15,14 +15,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/containerd/platforms"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
 	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
 	"pkt.systems/pslog"
 )
 
-const (
-	labelManaged = "shipohoy.managed"
-)
-
 // Config configures the Podman runtime.
 type Config struct {
 	Address     string
@@ -35,6 +35,8 @@ type Runtime struct {
 	client      *client
 	pullTimeout time.Duration
 	usernsMode  string
+	// platform is the host platform images are matched against.
+	platform ocispec.Platform
 }
 
 // New constructs a Podman runtime, trying fallback socket paths if needed.
@@ -64,6 +66,7 @@ func New(ctx context.Context, cfg Config) (*Runtime, error) {
 			client:      cl,
 			pullTimeout: timeout,
 			usernsMode:  strings.TrimSpace(cfg.UserNSMode),
+			platform:    platforms.DefaultSpec(),
 		}, nil
 	}
 	if lastErr == nil {
@@ -113,6 +116,10 @@ func (r *Runtime) EnsureImage(ctx context.Context, image string) error {
 		return err
 	}
 	if ok {
+		if err := r.checkImagePlatform(ctx, image); err != nil {
+			log.Warn("podman image platform mismatch", "err", err)
+			return err
+		}
 		log.Info("podman ensure image ok")
 		return nil
 	}
@@ -124,6 +131,7 @@ func (r *Runtime) EnsureImage(ctx context.Context, image string) error {
 	if tag != "" {
 		query.Set("tag", tag)
 	}
+	query.Set("platform", platforms.Format(r.platform))
 	res, err := r.client.do(pullCtx, "POST", "/images/create", query, nil, "")
 	if err != nil {
 		log.Warn("podman image pull failed", "err", err)
@@ -135,10 +143,57 @@ func (r *Runtime) EnsureImage(ctx context.Context, image string) error {
 		return readAPIError(res)
 	}
 	_, _ = io.Copy(io.Discard, res.Body)
+	if err := r.checkImagePlatform(ctx, image); err != nil {
+		log.Warn("podman image platform mismatch", "err", err)
+		return err
+	}
 	log.Info("podman ensure image ok")
 	return nil
 }
 
+// checkImagePlatform fails with a *shipohoy.PlatformError when the local
+// image was built for another platform than the host. Podman resolves
+// manifest lists on pull, so the local image has a single platform.
+func (r *Runtime) checkImagePlatform(ctx context.Context, image string) error {
+	res, err := r.client.do(ctx, "GET", fmt.Sprintf("/libpod/images/%s/json", escapeImagePath(image)), nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode >= 300 {
+		return readAPIError(res)
+	}
+	var inspect inspectImage
+	if err := json.NewDecoder(res.Body).Decode(&inspect); err != nil {
+		return err
+	}
+	if inspect.Os == "" || inspect.Architecture == "" {
+		return nil
+	}
+	return shipohoy.CheckPlatform(image, r.platform, []ocispec.Platform{{
+		OS:           inspect.Os,
+		Architecture: inspect.Architecture,
+		Variant:      inspect.Variant,
+	}})
+}
+
+// InspectImage returns the digest and labels of a local image.
+func (r *Runtime) InspectImage(ctx context.Context, image string) (shipohoy.ImageInfo, error) {
+	res, err := r.client.do(ctx, "GET", fmt.Sprintf("/libpod/images/%s/json", escapeImagePath(image)), nil, nil, "")
+	if err != nil {
+		return shipohoy.ImageInfo{}, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode >= 300 {
+		return shipohoy.ImageInfo{}, readAPIError(res)
+	}
+	var inspect inspectImage
+	if err := json.NewDecoder(res.Body).Decode(&inspect); err != nil {
+		return shipohoy.ImageInfo{}, err
+	}
+	return shipohoy.ImageInfo{Digest: inspect.Digest, Labels: inspect.Labels}, nil
+}
+
 // EnsureRunning ensures a container exists and is running.
 func (r *Runtime) EnsureRunning(ctx context.Context, spec shipohoy.ContainerSpec) (shipohoy.Handle, error) {
 	if strings.TrimSpace(spec.Name) == "" {
@@ -397,56 +452,80 @@ func (r *Runtime) TailLogs(ctx context.Context, handle shipohoy.Handle, limit in
 	return stdout, stderr, nil
 }
 
-// Janitor prunes managed containers by label.
-func (r *Runtime) Janitor(ctx context.Context, spec shipohoy.JanitorSpec) (int, error) {
+// ListManaged returns the managed containers matching selector.
+func (r *Runtime) ListManaged(ctx context.Context, selector map[string]string) ([]shipohoy.ManagedContainer, error) {
 	log := r.logger(ctx)
-	log.Info("podman janitor start")
-	filters := map[string][]string{}
-	labels := []string{labelManaged + "=true"}
-	for k, v := range spec.LabelSelector {
+	var filter []string
+	for k, v := range selector {
 		if strings.TrimSpace(k) == "" {
 			continue
 		}
-		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
-	}
-	filters["label"] = labels
-	filterJSON, err := json.Marshal(filters)
-	if err != nil {
-		log.Warn("podman janitor failed", "err", err)
-		return 0, err
+		filter = append(filter, fmt.Sprintf("%s=%s", k, v))
 	}
 	query := url.Values{}
 	query.Set("all", "1")
-	query.Set("filters", string(filterJSON))
+	if len(filter) > 0 {
+		filterJSON, err := json.Marshal(map[string][]string{"label": filter})
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(filterJSON))
+	}
 	res, err := r.client.do(ctx, "GET", "/containers/json", query, nil, "")
 	if err != nil {
-		log.Warn("podman janitor failed", "err", err)
-		return 0, err
+		log.Warn("podman list managed failed", "err", err)
+		return nil, err
 	}
 	defer func() { _ = res.Body.Close() }()
 	if res.StatusCode >= 300 {
-		log.Warn("podman janitor failed", "status", res.StatusCode)
-		return 0, readAPIError(res)
+		log.Warn("podman list managed failed", "status", res.StatusCode)
+		return nil, readAPIError(res)
 	}
 	var list []containerListItem
 	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		log.Warn("podman list managed failed", "err", err)
+		return nil, err
+	}
+	out := make([]shipohoy.ManagedContainer, 0, len(list))
+	for _, item := range list {
+		// Label filters are ANDed, so the managed markers (current or
+		// legacy) are checked here.
+		if !labels.IsManaged(item.Labels) || !labels.Matches(item.Labels, selector) {
+			continue
+		}
+		out = append(out, shipohoy.ManagedContainer{
+			Name:    containerName(item),
+			ID:      item.ID,
+			Image:   item.Image,
+			Labels:  item.Labels,
+			State:   containerState(item.State),
+			Created: time.Unix(item.Created, 0),
+		})
+	}
+	log.Debug("podman list managed ok", "count", len(out))
+	return out, nil
+}
+
+// Janitor prunes managed containers by label.
+func (r *Runtime) Janitor(ctx context.Context, spec shipohoy.JanitorSpec) (int, error) {
+	log := r.logger(ctx)
+	log.Info("podman janitor start")
+	list, err := r.ListManaged(ctx, spec.LabelSelector)
+	if err != nil {
 		log.Warn("podman janitor failed", "err", err)
 		return 0, err
 	}
 	removed := 0
 	cutoff := time.Now().Add(-spec.MinAge)
 	for _, item := range list {
-		if spec.MinAge > 0 {
-			created := time.Unix(item.Created, 0)
-			if created.After(cutoff) {
-				continue
-			}
+		if spec.MinAge > 0 && item.Created.After(cutoff) {
+			continue
 		}
 		autoRemove := false
 		if inspect, ok, err := r.inspectContainer(ctx, item.ID); err == nil && ok {
 			autoRemove = inspect.HostConfig.AutoRemove
 		}
-		h := &handle{name: containerName(item), id: item.ID}
+		h := &handle{name: item.Name, id: item.ID}
 		_ = r.Stop(ctx, h)
 		if autoRemove {
 			removed++
@@ -462,6 +541,22 @@ func (r *Runtime) Janitor(ctx context.Context, spec shipohoy.JanitorSpec) (int,
 	return removed, nil
 }
 
+// containerState maps a podman container state to a ContainerState.
+func containerState(state string) shipohoy.ContainerState {
+	switch strings.ToLower(strings.TrimSpace(state)) {
+	case "running":
+		return shipohoy.ContainerRunning
+	case "paused":
+		return shipohoy.ContainerPaused
+	case "created", "configured", "initialized":
+		return shipohoy.ContainerCreated
+	case "exited", "stopped", "dead":
+		return shipohoy.ContainerStopped
+	default:
+		return shipohoy.ContainerUnknown
+	}
+}
+
 func (r *Runtime) inspectContainer(ctx context.Context, name string) (inspectContainer, bool, error) {
 	res, err := r.client.do(ctx, "GET", fmt.Sprintf("/containers/%s/json", url.PathEscape(name)), nil, nil, "")
 	if err != nil {
@@ -482,20 +577,26 @@ func (r *Runtime) inspectContainer(ctx context.Context, name string) (inspectCon
 }
 
 func (r *Runtime) createContainer(ctx context.Context, spec shipohoy.ContainerSpec) (createResponse, error) {
-	labels := mergeLabels(spec.Labels, map[string]string{labelManaged: "true"})
+	containerLabels := mergeLabels(spec.Labels, map[string]string{labels.Managed: "true"})
 	req := map[string]any{
 		"Image":      spec.Image,
 		"Cmd":        spec.Command,
 		"WorkingDir": spec.WorkingDir,
-		"Labels":     labels,
+		"Labels":     containerLabels,
 	}
 	env := envMapToSlice(spec.Env)
 	if len(env) > 0 {
 		req["Env"] = env
 	}
 	hostConfig := map[string]any{}
-	if spec.HostNetwork {
-		hostConfig["NetworkMode"] = "host"
+	if spec.NetworkMode != shipohoy.NetworkDefault {
+		hostConfig["NetworkMode"] = string(spec.NetworkMode)
+	}
+	if securityOpt := buildSecurityOpt(spec); len(securityOpt) > 0 {
+		hostConfig["SecurityOpt"] = securityOpt
+	}
+	if len(spec.DropCapabilities) > 0 {
+		hostConfig["CapDrop"] = spec.DropCapabilities
 	}
 	if spec.ReadOnlyRootfs {
 		hostConfig["ReadonlyRootfs"] = true
@@ -694,6 +795,19 @@ func buildTmpfs(tmpfs []shipohoy.TmpfsMount) map[string]string {
 	return out
 }
 
+// buildSecurityOpt maps the hardening options of spec to podman security
+// options. The default seccomp profile is podman's own, so it needs no option.
+func buildSecurityOpt(spec shipohoy.ContainerSpec) []string {
+	var out []string
+	if spec.NoNewPrivileges {
+		out = append(out, "no-new-privileges")
+	}
+	if spec.SeccompProfile != "" && spec.SeccompProfile != shipohoy.SeccompDefault {
+		out = append(out, "seccomp="+spec.SeccompProfile)
+	}
+	return out
+}
+
 func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
 	if timeout <= 0 {
 		return context.WithCancel(ctx)
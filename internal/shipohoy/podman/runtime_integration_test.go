@@ -20,7 +20,7 @@ func TestRuntimeLifecycle(t *testing.T) {
 		Name:           name,
 		Image:          "docker.io/library/busybox:1.36",
 		Command:        []string{"sh", "-c", "echo ready; httpd -f -p 18081"},
-		HostNetwork:    true,
+		NetworkMode:    shipohoy.NetworkHost,
 		LogBufferBytes: 128 * 1024,
 		Labels: map[string]string{
 			"shipohoy.run_id": name,
@@ -89,7 +89,7 @@ func TestRuntimeJanitor(t *testing.T) {
 		Name:           name,
 		Image:          "docker.io/library/busybox:1.36",
 		Command:        []string{"sh", "-c", "sleep 60"},
-		HostNetwork:    true,
+		NetworkMode:    shipohoy.NetworkHost,
 		LogBufferBytes: 128 * 1024,
 		Labels: map[string]string{
 			"shipohoy.run_id": name,
@@ -104,6 +104,14 @@ func TestRuntimeJanitor(t *testing.T) {
 		_ = rt.Remove(ctx, handle)
 	})
 
+	managed, err := rt.ListManaged(ctx, map[string]string{"shipohoy.run_id": name})
+	if err != nil {
+		t.Fatalf("ListManaged: %v", err)
+	}
+	if len(managed) != 1 || managed[0].Name != name || managed[0].State != shipohoy.ContainerRunning || managed[0].Created.IsZero() {
+		t.Fatalf("ListManaged: unexpected containers %+v", managed)
+	}
+
 	removed, err := rt.Janitor(ctx, shipohoy.JanitorSpec{
 		LabelSelector: map[string]string{"shipohoy.run_id": name},
 	})
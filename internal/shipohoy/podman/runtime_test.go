@@ -0,0 +1,244 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"pkt.systems/centaurx/internal/shipohoy"
+	"pkt.systems/centaurx/internal/shipohoy/labels"
+)
+
+// fakePodman serves the container list and lifecycle endpoints used by
+// ListManaged and Janitor, and the image endpoints used by EnsureImage.
+type fakePodman struct {
+	mu      sync.Mutex
+	list    []containerListItem
+	filters []string
+	stopped []string
+	removed []string
+	created []map[string]any
+	// images maps local image names to their inspect data; pullable holds
+	// what a pull of a name stores.
+	images   map[string]inspectImage
+	pullable map[string]inspectImage
+	pulls    []string
+}
+
+func (f *fakePodman) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	switch {
+	case path == "/libpod/info":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && path == "/containers/create":
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.created = append(f.created, req)
+		_ = json.NewEncoder(w).Encode(createResponse{ID: "created"})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/libpod/images/"):
+		ref := strings.TrimPrefix(path, "/libpod/images/")
+		slash := strings.LastIndex(ref, "/")
+		name, endpoint := ref[:max(slash, 0)], ref[slash+1:]
+		image, ok := f.images[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if endpoint == "json" {
+			_ = json.NewEncoder(w).Encode(image)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && path == "/images/create":
+		query := r.URL.Query()
+		name := query.Get("fromImage") + ":" + query.Get("tag")
+		f.pulls = append(f.pulls, query.Get("platform"))
+		if f.images == nil {
+			f.images = map[string]inspectImage{}
+		}
+		f.images[name] = f.pullable[name]
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && path == "/containers/json":
+		f.filters = append(f.filters, r.URL.Query().Get("filters"))
+		_ = json.NewEncoder(w).Encode(f.list)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json"):
+		_ = json.NewEncoder(w).Encode(inspectContainer{})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/stop"):
+		f.stopped = append(f.stopped, strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/stop"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		f.removed = append(f.removed, strings.TrimPrefix(path, "/containers/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeRuntime(t *testing.T, fake *fakePodman) *Runtime {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	rt, err := New(context.Background(), Config{Address: srv.URL})
+	if err != nil {
+		t.Fatalf("new runtime: %v", err)
+	}
+	return rt
+}
+
+func TestListManaged(t *testing.T) {
+	created := time.Date(2025, time.March, 4, 5, 6, 7, 0, time.UTC)
+	fake := &fakePodman{list: []containerListItem{
+		{ID: "a1", Names: []string{"/centaurx-runner-alice"}, Created: created.Unix(), State: "running", Labels: labels.Runner("alice", "", "user", created)},
+		{ID: "b1", Names: []string{"/old-runner"}, Created: created.Unix(), State: "exited", Labels: map[string]string{labels.LegacyManaged: "true"}},
+		{ID: "c1", Names: []string{"/unrelated"}, Created: created.Unix(), State: "running"},
+	}}
+	rt := newFakeRuntime(t, fake)
+
+	list, err := rt.ListManaged(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("list managed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 managed containers, got %+v", list)
+	}
+	first := list[0]
+	if first.Name != "centaurx-runner-alice" || first.ID != "a1" || first.State != shipohoy.ContainerRunning || !first.Created.Equal(created) || first.Labels[labels.User] != "alice" {
+		t.Fatalf("unexpected container %+v", first)
+	}
+	if list[1].State != shipohoy.ContainerStopped {
+		t.Fatalf("expected legacy container stopped, got %+v", list[1])
+	}
+
+	list, err = rt.ListManaged(context.Background(), map[string]string{labels.User: "alice"})
+	if err != nil {
+		t.Fatalf("list managed with selector: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "a1" {
+		t.Fatalf("expected only alice's container, got %+v", list)
+	}
+	if got := fake.filters[len(fake.filters)-1]; got != `{"label":["centaurx.user=alice"]}` {
+		t.Fatalf("unexpected filters %q", got)
+	}
+}
+
+func TestJanitorUsesListManaged(t *testing.T) {
+	now := time.Now()
+	fake := &fakePodman{list: []containerListItem{
+		{ID: "old", Names: []string{"/old"}, Created: now.Add(-time.Hour).Unix(), State: "running", Labels: labels.Runner("alice", "", "user", now)},
+		{ID: "new", Names: []string{"/new"}, Created: now.Unix(), State: "running", Labels: labels.Runner("bob", "", "user", now)},
+		{ID: "foreign", Names: []string{"/foreign"}, Created: now.Add(-time.Hour).Unix(), State: "running"},
+	}}
+	rt := newFakeRuntime(t, fake)
+
+	removed, err := rt.Janitor(context.Background(), shipohoy.JanitorSpec{MinAge: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("janitor: %v", err)
+	}
+	if removed != 1 || len(fake.removed) != 1 || fake.removed[0] != "old" {
+		t.Fatalf("expected only the old managed container removed, got %d %v", removed, fake.removed)
+	}
+}
+
+func TestContainerState(t *testing.T) {
+	tests := map[string]shipohoy.ContainerState{
+		"running":    shipohoy.ContainerRunning,
+		"Paused":     shipohoy.ContainerPaused,
+		"configured": shipohoy.ContainerCreated,
+		"exited":     shipohoy.ContainerStopped,
+		"removing":   shipohoy.ContainerUnknown,
+	}
+	for input, want := range tests {
+		if got := containerState(input); got != want {
+			t.Fatalf("containerState(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestCreateContainerHardening(t *testing.T) {
+	fake := &fakePodman{}
+	rt := newFakeRuntime(t, fake)
+	ctx := context.Background()
+
+	if _, err := rt.createContainer(ctx, shipohoy.ContainerSpec{Name: "plain", Image: "test"}); err != nil {
+		t.Fatalf("create plain: %v", err)
+	}
+	if hostConfig, ok := fake.created[0]["HostConfig"].(map[string]any); ok {
+		for _, key := range []string{"NetworkMode", "SecurityOpt", "CapDrop"} {
+			if _, set := hostConfig[key]; set {
+				t.Fatalf("expected no %s by default, got %v", key, hostConfig)
+			}
+		}
+	}
+
+	if _, err := rt.createContainer(ctx, shipohoy.ContainerSpec{
+		Name:             "hardened",
+		Image:            "test",
+		NoNewPrivileges:  true,
+		SeccompProfile:   "/etc/centaurx/seccomp.json",
+		DropCapabilities: []string{"NET_RAW"},
+		NetworkMode:      shipohoy.NetworkNone,
+	}); err != nil {
+		t.Fatalf("create hardened: %v", err)
+	}
+	hostConfig := fake.created[1]["HostConfig"].(map[string]any)
+	if hostConfig["NetworkMode"] != "none" {
+		t.Fatalf("unexpected network mode %v", hostConfig["NetworkMode"])
+	}
+	if got, _ := json.Marshal(hostConfig["SecurityOpt"]); string(got) != `["no-new-privileges","seccomp=/etc/centaurx/seccomp.json"]` {
+		t.Fatalf("unexpected security options %s", got)
+	}
+	if got, _ := json.Marshal(hostConfig["CapDrop"]); string(got) != `["NET_RAW"]` {
+		t.Fatalf("unexpected dropped capabilities %s", got)
+	}
+}
+
+func TestBuildSecurityOptDefaultSeccomp(t *testing.T) {
+	if got := buildSecurityOpt(shipohoy.ContainerSpec{SeccompProfile: shipohoy.SeccompDefault}); len(got) != 0 {
+		t.Fatalf("expected podman's default profile to need no option, got %v", got)
+	}
+	if got := buildSecurityOpt(shipohoy.ContainerSpec{SeccompProfile: shipohoy.SeccompUnconfined}); len(got) != 1 || got[0] != "seccomp=unconfined" {
+		t.Fatalf("unexpected options %v", got)
+	}
+}
+
+func TestEnsureImageChecksPlatform(t *testing.T) {
+	amd64 := inspectImage{Os: "linux", Architecture: "amd64"}
+	arm64 := inspectImage{Os: "linux", Architecture: "arm64", Variant: "v8"}
+	fake := &fakePodman{
+		images:   map[string]inspectImage{"runner:amd64": amd64, "runner:arm64": arm64},
+		pullable: map[string]inspectImage{"registry.example/runner:arm64": arm64},
+	}
+	rt := newFakeRuntime(t, fake)
+	rt.platform = ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	ctx := context.Background()
+
+	if err := rt.EnsureImage(ctx, "runner:amd64"); err != nil {
+		t.Fatalf("ensure matching image: %v", err)
+	}
+	err := rt.EnsureImage(ctx, "runner:arm64")
+	var perr *shipohoy.PlatformError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected platform error, got %v", err)
+	}
+	if perr.Host != "linux/amd64" || len(perr.Available) != 1 || perr.Available[0] != "linux/arm64/v8" {
+		t.Fatalf("unexpected platform error: %+v", perr)
+	}
+
+	err = rt.EnsureImage(ctx, "registry.example/runner:arm64")
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected platform error after pull, got %v", err)
+	}
+	if len(fake.pulls) != 1 || fake.pulls[0] != "linux/amd64" {
+		t.Fatalf("expected one pull for linux/amd64, got %v", fake.pulls)
+	}
+}
@@ -21,6 +21,15 @@ type inspectContainer struct {
 	} `json:"State"`
 }
 
+type inspectImage struct {
+	ID           string            `json:"Id"`
+	Digest       string            `json:"Digest"`
+	Os           string            `json:"Os"`
+	Architecture string            `json:"Architecture"`
+	Variant      string            `json:"Variant"`
+	Labels       map[string]string `json:"Labels"`
+}
+
 type execCreateResponse struct {
 	ID string `json:"Id"`
 }
@@ -33,7 +42,9 @@ type execInspect struct {
 type containerListItem struct {
 	ID      string            `json:"Id"`
 	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
 	Created int64             `json:"Created"`
+	State   string            `json:"State"`
 	Labels  map[string]string `json:"Labels"`
 }
 
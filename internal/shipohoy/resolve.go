@@ -0,0 +1,30 @@
+package shipohoy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/distribution/reference"
+)
+
+// ResolveDigest asks the registry of image for the digest its tag points
+// to, without pulling it. Multi-platform images resolve to the digest of
+// their index. Only anonymous registry access is supported.
+func ResolveDigest(ctx context.Context, image string) (string, error) {
+	named, err := reference.ParseDockerRef(image)
+	if err != nil {
+		return "", fmt.Errorf("image %s: %w", image, err)
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		return canonical.Digest().String(), nil
+	}
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Hosts: docker.ConfigureDefaultRegistries(),
+	})
+	_, desc, err := resolver.Resolve(ctx, named.String())
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", image, err)
+	}
+	return desc.Digest.String(), nil
+}
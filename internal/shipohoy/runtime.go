@@ -12,6 +12,20 @@ type Runtime interface {
 	WaitForPort(ctx context.Context, handle Handle, spec WaitPortSpec) error
 	WaitForLog(ctx context.Context, handle Handle, spec WaitLogSpec) error
 	Janitor(ctx context.Context, spec JanitorSpec) (int, error)
+	// ListManaged returns the managed containers whose labels match
+	// selector; an empty selector returns all of them.
+	ListManaged(ctx context.Context, selector map[string]string) ([]ManagedContainer, error)
+}
+
+// LogCaptureReporter is implemented by runtimes that keep container output
+// in memory for WaitForLog and log tails.
+type LogCaptureReporter interface {
+	LogCaptureStats() LogCaptureStats
+}
+
+// ImageInspector is implemented by runtimes that can describe local images.
+type ImageInspector interface {
+	InspectImage(ctx context.Context, image string) (ImageInfo, error)
 }
 
 // Builder builds container images.
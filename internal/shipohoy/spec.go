@@ -33,6 +33,25 @@ type TmpfsMount struct {
 	Options []string
 }
 
+// NetworkMode selects the network namespace of a container.
+type NetworkMode string
+
+const (
+	// NetworkDefault keeps the runtime's default network setup.
+	NetworkDefault NetworkMode = ""
+	// NetworkHost shares the host network namespace.
+	NetworkHost NetworkMode = "host"
+	// NetworkNone gives the container its own namespace with only loopback.
+	NetworkNone NetworkMode = "none"
+)
+
+// Seccomp profile names understood by the runtimes; any other non-empty
+// SeccompProfile is the path to a JSON profile.
+const (
+	SeccompDefault    = "default"
+	SeccompUnconfined = "unconfined"
+)
+
 // ContainerSpec describes a container.
 type ContainerSpec struct {
 	Name           string
@@ -47,8 +66,17 @@ type ContainerSpec struct {
 	ReadOnlyRootfs bool
 	AutoRemove     bool
 	ResourceCaps   *ResourceCaps
-	HostNetwork    bool
+	NetworkMode    NetworkMode
 	LogBufferBytes int
+	// NoNewPrivileges stops processes from gaining privileges through
+	// setuid binaries or file capabilities.
+	NoNewPrivileges bool
+	// SeccompProfile is empty for the runtime default, SeccompDefault,
+	// SeccompUnconfined or the path to a JSON profile.
+	SeccompProfile string
+	// DropCapabilities lists capabilities to drop, with or without the CAP_
+	// prefix; "ALL" drops every capability.
+	DropCapabilities []string
 }
 
 // BuildSpec describes a container image build.
@@ -60,6 +88,11 @@ type BuildSpec struct {
 	BuildArgs         map[string]string
 	Timeout           time.Duration
 	OutputPath        string
+	// Platform is the target platform, such as "linux/arm64". Empty builds
+	// for the builder's own platform.
+	Platform string
+	// Labels are set on the built image.
+	Labels map[string]string
 }
 
 // BuildResult captures build output metadata.
@@ -144,6 +177,62 @@ type JanitorSpec struct {
 	MinAge        time.Duration
 }
 
+// ContainerState is the lifecycle state of a container.
+type ContainerState string
+
+const (
+	// ContainerCreated is a container that has not been started.
+	ContainerCreated ContainerState = "created"
+	// ContainerRunning is a started container.
+	ContainerRunning ContainerState = "running"
+	// ContainerPaused is a paused container.
+	ContainerPaused ContainerState = "paused"
+	// ContainerStopped is a container whose process exited.
+	ContainerStopped ContainerState = "stopped"
+	// ContainerUnknown is reported when the runtime state is not recognized.
+	ContainerUnknown ContainerState = "unknown"
+)
+
+// ManagedContainer describes a container created through a shipohoy runtime.
+type ManagedContainer struct {
+	Name    string
+	ID      string
+	Image   string
+	Labels  map[string]string
+	State   ContainerState
+	Created time.Time
+}
+
+// ImageInfo describes a local image.
+type ImageInfo struct {
+	// Digest is the digest of the image manifest (or index) as stored by
+	// the runtime.
+	Digest string
+	Labels map[string]string
+}
+
+// LogCaptureStats reports the memory held by in-memory log captures.
+// Capacity is what the ring buffers may grow to and is what LimitBytes caps;
+// Used is what they currently hold.
+type LogCaptureStats struct {
+	LimitBytes    int64
+	CapacityBytes int64
+	UsedBytes     int64
+	Containers    []LogCaptureUsage
+}
+
+// LogCaptureUsage describes the log capture of one container.
+type LogCaptureUsage struct {
+	Name          string
+	Created       time.Time
+	LastUsed      time.Time
+	CapacityBytes int64
+	UsedBytes     int64
+	// Shrunk is set when the global limit reduced the capture below the
+	// size it was created with; a capacity of 0 means it was dropped.
+	Shrunk bool
+}
+
 // Container is implemented by runtime-specific adapters.
 type Container interface {
 	Name() string
@@ -123,6 +123,35 @@ func (s *Store) RemoveKey(username string) error {
 	return nil
 }
 
+// ImportKey replaces the user's SSH key with the PEM encoded private key,
+// as returned by ExportKey, and returns the public key.
+func (s *Store) ImportKey(username string, pemData []byte) (string, error) {
+	if strings.TrimSpace(username) == "" {
+		return "", errors.New("username is required")
+	}
+	priv, err := ssh.ParseRawPrivateKey(pemData)
+	if err != nil {
+		return "", fmt.Errorf("parse ssh private key: %w", err)
+	}
+	if s.log != nil {
+		s.log.Info("ssh key import start", "user", username)
+	}
+	return s.storeKey(username, priv, "imported", true)
+}
+
+// ExportKey decrypts the user's private key and returns it PEM encoded.
+func (s *Store) ExportKey(username string) ([]byte, error) {
+	priv, err := s.LoadPrivateKey(username)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, username)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
 // LoadSigner loads the user's private key as an ssh.Signer.
 func (s *Store) LoadSigner(username string) (ssh.Signer, error) {
 	priv, err := s.LoadPrivateKey(username)
@@ -251,7 +280,15 @@ func (s *Store) writeKey(username, keyType string, bits int, rotate bool) (strin
 	default:
 		return "", fmt.Errorf("unsupported ssh key type %q", keyType)
 	}
+	action := "generated"
+	if rotate {
+		action = "rotated"
+	}
+	return s.storeKey(username, priv, action, rotate)
+}
 
+// storeKey encrypts priv as the user's key and writes its public key.
+func (s *Store) storeKey(username string, priv crypto.PrivateKey, action string, rotate bool) (string, error) {
 	block, err := ssh.MarshalPrivateKey(priv, username)
 	if err != nil {
 		if s.log != nil {
@@ -342,10 +379,6 @@ func (s *Store) writeKey(username, keyType string, bits int, rotate bool) (strin
 		return "", err
 	}
 	if s.log != nil {
-		action := "generated"
-		if rotate {
-			action = "rotated"
-		}
 		s.log.Info("ssh key write ok", "user", username, "action", action)
 	}
 	return strings.TrimSpace(string(pub)), nil
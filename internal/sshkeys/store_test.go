@@ -62,3 +62,36 @@ func TestStoreRemoveKey(t *testing.T) {
 		t.Fatalf("expected os.ErrNotExist after removal, got %v", err)
 	}
 }
+
+func TestStoreExportImportKey(t *testing.T) {
+	dir := t.TempDir()
+	source, err := NewStore(filepath.Join(dir, "a.bundle"), filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	target, err := NewStore(filepath.Join(dir, "b.bundle"), filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	pub, err := source.GenerateKey("alice", KeyTypeEd25519, 0)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	data, err := source.ExportKey("alice")
+	if err != nil {
+		t.Fatalf("export key: %v", err)
+	}
+	imported, err := target.ImportKey("alice2", data)
+	if err != nil {
+		t.Fatalf("import key: %v", err)
+	}
+	if imported != pub {
+		t.Fatalf("expected the imported public key %q, got %q", pub, imported)
+	}
+	if _, err := target.LoadSigner("alice2"); err != nil {
+		t.Fatalf("load imported key: %v", err)
+	}
+	if _, err := target.ImportKey("carol", []byte("not a key")); err == nil {
+		t.Fatalf("expected an invalid key to be rejected")
+	}
+}
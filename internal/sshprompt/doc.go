@@ -0,0 +1,3 @@
+// Package sshprompt renders the input line prefix of SSH sessions from the
+// ssh.prompt_template setting.
+package sshprompt
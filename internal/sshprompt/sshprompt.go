@@ -0,0 +1,65 @@
+package sshprompt
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// DefaultTemplate is the prompt used when no template is configured.
+const DefaultTemplate = "> "
+
+// Data holds the template variables available to the prompt. Repo, Branch,
+// Model and Tab are empty when no tab is open or nothing has run yet.
+type Data struct {
+	User   schema.UserID
+	Repo   schema.RepoName
+	Branch string
+	Model  schema.ModelID
+	Tab    schema.TabName
+}
+
+// Template is a parsed prompt template.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses text as a prompt template. Empty text selects
+// DefaultTemplate. Unknown variables are reported here rather than on every
+// render.
+func Parse(text string) (*Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, Data{}); err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Render executes the template with data and returns a single line. A nil
+// Template or a failed render yields DefaultTemplate.
+func (t *Template) Render(data Data) string {
+	if t == nil {
+		return DefaultTemplate
+	}
+	var out bytes.Buffer
+	if err := t.tmpl.Execute(&out, data); err != nil {
+		return DefaultTemplate
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return ' '
+		case r < ' ' || r == 0x7f:
+			return -1
+		}
+		return r
+	}, out.String())
+}
@@ -0,0 +1,29 @@
+package sshprompt
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tmpl, err := Parse("{{.User}}@{{.Repo}}{{with .Branch}}:{{.}}{{end}} [{{.Tab}}]\n$ ")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := tmpl.Render(Data{User: "alice", Repo: "demo", Branch: "main", Tab: "demo"})
+	if want := "alice@demo:main [demo] $ "; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := tmpl.Render(Data{User: "alice"}); got != "alice@ [] $ " {
+		t.Fatalf("unexpected prompt without a tab %q", got)
+	}
+}
+
+func TestParseRejectsInvalidTemplates(t *testing.T) {
+	for _, text := range []string{"{{.User", "{{.Host}} > "} {
+		if _, err := Parse(text); err == nil {
+			t.Fatalf("expected %q to be rejected", text)
+		}
+	}
+	var nilTemplate *Template
+	if got := nilTemplate.Render(Data{User: "alice"}); got != DefaultTemplate {
+		t.Fatalf("expected default prompt, got %q", got)
+	}
+}
@@ -0,0 +1,5 @@
+// Package statereport measures what centaurx keeps in its state directory:
+// the size of each top-level entry and, per user, the persisted snapshot,
+// the home directory and whether a codex auth.json and a git SSH key exist.
+// Unreadable entries are reported rather than aborting the walk.
+package statereport
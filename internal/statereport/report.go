@@ -0,0 +1,269 @@
+package statereport
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/userhome"
+	"pkt.systems/centaurx/schema"
+)
+
+// Paths locates the state of an installation.
+type Paths struct {
+	// StateDir is the service state directory.
+	StateDir string
+	// GitKeyDir holds the per-user git SSH keys (ssh.key_dir).
+	GitKeyDir string
+}
+
+// Problem is an entry that could not be read.
+type Problem struct {
+	Path string
+	Err  error
+}
+
+// Usage is the disk usage below a path. Symlinks are counted but not
+// followed, and entries listed in Problems are left out of Bytes.
+type Usage struct {
+	Path     string
+	Exists   bool
+	Bytes    int64
+	Files    int
+	Symlinks int
+	Problems []Problem
+}
+
+func (u *Usage) add(other Usage) {
+	u.Exists = u.Exists || other.Exists
+	u.Bytes += other.Bytes
+	u.Files += other.Files
+	u.Symlinks += other.Symlinks
+	u.Problems = append(u.Problems, other.Problems...)
+}
+
+// UserReport is the state kept for one user.
+type UserReport struct {
+	User schema.UserID
+	// Snapshot covers the snapshot file, its backup and its buffer
+	// segments.
+	Snapshot Usage
+	Home     Usage
+	AuthJSON bool
+	GitKey   bool
+}
+
+// Bytes is the total size of the user's state.
+func (u UserReport) Bytes() int64 {
+	return u.Snapshot.Bytes + u.Home.Bytes
+}
+
+// Report is the state directory layout and the state of each user.
+type Report struct {
+	StateDir Usage
+	// Entries are the top-level entries of the state directory by name.
+	Entries []Usage
+	Users   []UserReport
+}
+
+// Measure walks path and sums the sizes of the regular files below it.
+func Measure(root string) Usage {
+	info, err := os.Lstat(root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Usage{Path: root}
+		}
+		return Usage{Path: root, Problems: []Problem{{Path: root, Err: err}}}
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return Usage{Path: root, Exists: true, Symlinks: 1}
+	case !info.IsDir():
+		return Usage{Path: root, Exists: true, Bytes: info.Size(), Files: 1}
+	}
+	usage := measureFS(os.DirFS(root), root)
+	usage.Path = root
+	return usage
+}
+
+// measureFS walks fsys, naming entries below root in problems.
+func measureFS(fsys fs.FS, root string) Usage {
+	usage := Usage{Exists: true}
+	_ = fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			usage.Problems = append(usage.Problems, Problem{Path: filepath.Join(root, filepath.FromSlash(name)), Err: err})
+			return nil
+		}
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			usage.Symlinks++
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				usage.Problems = append(usage.Problems, Problem{Path: filepath.Join(root, filepath.FromSlash(name)), Err: err})
+				return nil
+			}
+			usage.Bytes += info.Size()
+			usage.Files++
+		}
+		return nil
+	})
+	return usage
+}
+
+// MeasureUser reports the state kept for user.
+func MeasureUser(paths Paths, user schema.UserID) UserReport {
+	report := UserReport{User: user}
+	state := persist.UserPaths(paths.StateDir, user)
+	for _, p := range []string{state.Snapshot, state.Backup, state.Segments} {
+		report.Snapshot.add(Measure(p))
+	}
+	report.Snapshot.Path = state.Snapshot
+	report.Home = Measure(userhome.HomeDir(paths.StateDir, string(user)))
+	report.AuthJSON = fileExists(userhome.AuthPath(paths.StateDir, string(user)))
+	if paths.GitKeyDir != "" {
+		report.GitKey = dirHasFiles(filepath.Join(paths.GitKeyDir, string(user)))
+	}
+	return report
+}
+
+// Build measures the top-level entries of the state directory and the
+// state of each of users.
+func Build(paths Paths, users []schema.UserID) Report {
+	report := Report{StateDir: Usage{Path: paths.StateDir}}
+	entries, err := os.ReadDir(paths.StateDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			report.StateDir.Problems = append(report.StateDir.Problems, Problem{Path: paths.StateDir, Err: err})
+		}
+	} else {
+		report.StateDir.Exists = true
+	}
+	for _, entry := range entries {
+		usage := Measure(filepath.Join(paths.StateDir, entry.Name()))
+		report.Entries = append(report.Entries, usage)
+		report.StateDir.add(usage)
+	}
+	sort.Slice(report.Entries, func(i, j int) bool { return report.Entries[i].Path < report.Entries[j].Path })
+	for _, user := range users {
+		report.Users = append(report.Users, MeasureUser(paths, user))
+	}
+	return report
+}
+
+// Lines renders the report for a terminal: the state directory total, one
+// line per top-level entry, one line per user and any problems.
+func (r Report) Lines() []string {
+	lines := []string{fmt.Sprintf("state dir %s: %s", r.StateDir.Path, summary(r.StateDir))}
+	if !r.StateDir.Exists {
+		lines[0] = fmt.Sprintf("state dir %s: missing", r.StateDir.Path)
+	}
+	width := 0
+	for _, entry := range r.Entries {
+		width = max(width, len(displayName(r.StateDir.Path, entry)))
+	}
+	for _, entry := range r.Entries {
+		lines = append(lines, fmt.Sprintf("  %-*s  %s", width, displayName(r.StateDir.Path, entry), summary(entry)))
+	}
+	for _, user := range r.Users {
+		lines = append(lines, user.Line())
+	}
+	problems := r.StateDir.Problems
+	for _, user := range r.Users {
+		problems = append(problems, user.problems()...)
+	}
+	return append(lines, problemLines(problems)...)
+}
+
+// Line summarizes the user's state on one line.
+func (u UserReport) Line() string {
+	return fmt.Sprintf("user %s: snapshot %s, home %s, auth.json %s, git key %s, total %s",
+		u.User, formatBytes(u.Snapshot.Bytes), formatBytes(u.Home.Bytes), yesNo(u.AuthJSON), yesNo(u.GitKey), formatBytes(u.Bytes()))
+}
+
+// Lines renders the user's state followed by where it lives and any
+// problems.
+func (u UserReport) Lines() []string {
+	lines := []string{
+		u.Line(),
+		"  snapshot: " + u.Snapshot.Path,
+		"  home:     " + u.Home.Path,
+	}
+	return append(lines, problemLines(u.problems())...)
+}
+
+func (u UserReport) problems() []Problem {
+	return append(append([]Problem(nil), u.Snapshot.Problems...), u.Home.Problems...)
+}
+
+func problemLines(problems []Problem) []string {
+	if len(problems) == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("%d unreadable entr%s, not counted:", len(problems), plural(len(problems), "y", "ies"))}
+	for _, problem := range problems {
+		lines = append(lines, fmt.Sprintf("  %s: %v", problem.Path, problem.Err))
+	}
+	return lines
+}
+
+func summary(u Usage) string {
+	text := fmt.Sprintf("%s in %d file%s", formatBytes(u.Bytes), u.Files, plural(u.Files, "", "s"))
+	if u.Symlinks > 0 {
+		text += fmt.Sprintf(", %d symlink%s not followed", u.Symlinks, plural(u.Symlinks, "", "s"))
+	}
+	return text
+}
+
+func displayName(dir string, u Usage) string {
+	name, err := filepath.Rel(dir, u.Path)
+	if err != nil {
+		name = u.Path
+	}
+	if info, err := os.Lstat(u.Path); err == nil && info.IsDir() {
+		name += "/"
+	}
+	return name
+}
+
+// formatBytes formats n as "512 B", "12.0 KiB", "3.4 MiB" or "1.2 GiB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func dirHasFiles(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) > 0
+}
+
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,117 @@
+package statereport
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/internal/userhome"
+	"pkt.systems/centaurx/schema"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestBuildReportsLayoutAndUsers(t *testing.T) {
+	root := t.TempDir()
+	stateDir := filepath.Join(root, "state")
+	keyDir := filepath.Join(stateDir, "ssh", "keys")
+	state := persist.UserPaths(stateDir, "alice")
+	writeFile(t, state.Snapshot, 100)
+	writeFile(t, state.Backup, 50)
+	writeFile(t, filepath.Join(state.Segments, "tab-1.seg"), 1000)
+	writeFile(t, userhome.AuthPath(stateDir, "alice"), 20)
+	writeFile(t, filepath.Join(userhome.HomeDir(stateDir, "alice"), "notes.txt"), 30)
+	writeFile(t, filepath.Join(keyDir, "alice", "id_ed25519"), 400)
+	writeFile(t, filepath.Join(stateDir, "users.json"), 10)
+	// A symlink out of the state dir is counted but not followed.
+	writeFile(t, filepath.Join(root, "outside", "big.bin"), 1<<20)
+	if err := os.Symlink(filepath.Join(root, "outside"), filepath.Join(userhome.HomeDir(stateDir, "alice"), "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	report := Build(Paths{StateDir: stateDir, GitKeyDir: keyDir}, []schema.UserID{"alice", "bob"})
+	if report.StateDir.Bytes != 100+50+1000+20+30+400+10 {
+		t.Fatalf("unexpected state dir bytes %d", report.StateDir.Bytes)
+	}
+	if report.StateDir.Symlinks != 1 {
+		t.Fatalf("expected one symlink, got %d", report.StateDir.Symlinks)
+	}
+	alice := report.Users[0]
+	if alice.Snapshot.Bytes != 1150 || alice.Snapshot.Files != 3 {
+		t.Fatalf("unexpected snapshot usage %+v", alice.Snapshot)
+	}
+	if alice.Home.Bytes != 50 || !alice.AuthJSON || !alice.GitKey || alice.Bytes() != 1200 {
+		t.Fatalf("unexpected alice report %+v", alice)
+	}
+	bob := report.Users[1]
+	if bob.Snapshot.Exists || bob.Home.Exists || bob.AuthJSON || bob.GitKey || bob.Bytes() != 0 {
+		t.Fatalf("unexpected bob report %+v", bob)
+	}
+	text := strings.Join(report.Lines(), "\n")
+	for _, want := range []string{
+		"state dir " + stateDir + ": 1.6 KiB in 7 files, 1 symlink not followed",
+		"  home/",
+		"  users.json",
+		"user alice: snapshot 1.1 KiB, home 50 B, auth.json yes, git key yes, total 1.2 KiB",
+		"user bob: snapshot 0 B, home 0 B, auth.json no, git key no, total 0 B",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in report:\n%s", want, text)
+		}
+	}
+}
+
+// deniedFS fails to read one directory, as a permission error would.
+type deniedFS struct {
+	fstest.MapFS
+	denied string
+}
+
+func (d deniedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == d.denied {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.MapFS.ReadDir(name)
+}
+
+func TestMeasureReportsUnreadableEntries(t *testing.T) {
+	fsys := deniedFS{
+		MapFS: fstest.MapFS{
+			"ok/a.txt":     {Data: make([]byte, 10)},
+			"locked/b.txt": {Data: make([]byte, 20)},
+			"c.txt":        {Data: make([]byte, 5)},
+		},
+		denied: "locked",
+	}
+	usage := measureFS(fsys, "/state")
+	if usage.Bytes != 15 || usage.Files != 2 {
+		t.Fatalf("expected readable files counted, got %+v", usage)
+	}
+	if len(usage.Problems) != 1 || usage.Problems[0].Path != filepath.Join("/state", "locked") || !errors.Is(usage.Problems[0].Err, fs.ErrPermission) {
+		t.Fatalf("expected permission problem for locked, got %+v", usage.Problems)
+	}
+	lines := problemLines(usage.Problems)
+	if len(lines) != 2 || lines[0] != "1 unreadable entry, not counted:" {
+		t.Fatalf("unexpected problem lines %q", lines)
+	}
+}
+
+func TestMeasureMissingPath(t *testing.T) {
+	usage := Measure(filepath.Join(t.TempDir(), "missing"))
+	if usage.Exists || usage.Bytes != 0 || len(usage.Problems) != 0 {
+		t.Fatalf("unexpected usage for missing path %+v", usage)
+	}
+}
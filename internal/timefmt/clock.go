@@ -0,0 +1,129 @@
+package timefmt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Zone names must resolve in minimal containers without tzdata.
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+// DefaultLayout is the time layout used when none is configured.
+const DefaultLayout = "15:04:05"
+
+// dateLayout prefixes times that fall on another day. ISO dates read the same
+// in every locale.
+const dateLayout = "2006-01-02"
+
+// Clock formats times in a Go layout and a time zone. The zero Clock uses
+// DefaultLayout in the server's local zone.
+type Clock struct {
+	layout string
+	loc    *time.Location
+}
+
+// New returns a Clock for layout and the IANA zone name. Empty values select
+// DefaultLayout and the server's local zone.
+func New(layout, zone string) (Clock, error) {
+	if err := ValidateLayout(layout); err != nil {
+		return Clock{}, err
+	}
+	loc, err := LoadLocation(zone)
+	if err != nil {
+		return Clock{}, err
+	}
+	return Clock{layout: layout, loc: loc}, nil
+}
+
+// LoadLocation resolves an IANA zone name. Empty and "Local" select the
+// server's local zone.
+func LoadLocation(zone string) (*time.Location, error) {
+	zone = strings.TrimSpace(zone)
+	if zone == "" || zone == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", zone)
+	}
+	return loc, nil
+}
+
+// ValidateLayout reports whether layout is a Go time layout that shows the
+// time of day. An empty layout selects DefaultLayout.
+func ValidateLayout(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	morning := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	evening := time.Date(2001, time.February, 3, 16, 17, 18, 0, time.UTC)
+	if morning.Format(layout) == evening.Format(layout) {
+		return fmt.Errorf("time format %q does not show the time of day", layout)
+	}
+	return nil
+}
+
+// WithZone returns a copy of c using the IANA zone name; empty keeps c's
+// zone.
+func (c Clock) WithZone(zone string) (Clock, error) {
+	if strings.TrimSpace(zone) == "" {
+		return c, nil
+	}
+	loc, err := LoadLocation(zone)
+	if err != nil {
+		return Clock{}, err
+	}
+	c.loc = loc
+	return c, nil
+}
+
+// Layout returns the layout in use.
+func (c Clock) Layout() string {
+	if c.layout == "" {
+		return DefaultLayout
+	}
+	return c.layout
+}
+
+// Location returns the time zone in use.
+func (c Clock) Location() *time.Location {
+	if c.loc == nil {
+		return time.Local
+	}
+	return c.loc
+}
+
+// Format renders the time of day of t.
+func (c Clock) Format(t time.Time) string {
+	return t.In(c.Location()).Format(c.Layout())
+}
+
+// FormatFrom renders t like Format, prefixed with its date when t falls on
+// another day than now in the clock's zone.
+func (c Clock) FormatFrom(t, now time.Time) string {
+	local := t.In(c.Location())
+	if sameDay(local, now.In(c.Location())) {
+		return local.Format(c.Layout())
+	}
+	return local.Format(dateLayout) + " " + local.Format(c.Layout())
+}
+
+// Width returns the widest rendering of the layout in runes, for aligning
+// columns of formatted times.
+func (c Clock) Width() int {
+	width := 0
+	// A Wednesday in September with two-digit hours in both halves of the
+	// day covers the longest names and numbers a layout can produce.
+	for _, hour := range []int{10, 22} {
+		ref := time.Date(2006, time.September, 27, hour, 59, 59, 999999999, c.Location())
+		width = max(width, utf8.RuneCountInString(ref.Format(c.Layout())))
+	}
+	return width
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
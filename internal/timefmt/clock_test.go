@@ -0,0 +1,89 @@
+package timefmt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockFormatAcrossZonesAndDST(t *testing.T) {
+	cases := []struct {
+		zone string
+		at   time.Time
+		want string
+	}{
+		// Stockholm leaves CET for CEST at 01:00 UTC on 2026-03-29.
+		{"Europe/Stockholm", time.Date(2026, time.March, 29, 0, 59, 0, 0, time.UTC), "01:59:00"},
+		{"Europe/Stockholm", time.Date(2026, time.March, 29, 1, 0, 0, 0, time.UTC), "03:00:00"},
+		// New York leaves EDT for EST at 06:00 UTC on 2026-11-01, repeating 01:xx.
+		{"America/New_York", time.Date(2026, time.November, 1, 5, 30, 0, 0, time.UTC), "01:30:00"},
+		{"America/New_York", time.Date(2026, time.November, 1, 6, 30, 0, 0, time.UTC), "01:30:00"},
+		{"UTC", time.Date(2026, time.November, 1, 6, 30, 0, 0, time.UTC), "06:30:00"},
+	}
+	for _, tc := range cases {
+		clock, err := New("", tc.zone)
+		if err != nil {
+			t.Fatalf("new %s: %v", tc.zone, err)
+		}
+		if got := clock.Format(tc.at); got != tc.want {
+			t.Fatalf("%s at %s: expected %q, got %q", tc.zone, tc.at, tc.want, got)
+		}
+	}
+}
+
+func TestClockFormatFromAddsDateOnOtherDays(t *testing.T) {
+	clock, err := New("3:04 PM MST", "America/New_York")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	// 23:30 EDT the evening before the switch back to EST.
+	now := time.Date(2026, time.November, 1, 3, 30, 0, 0, time.UTC)
+	if got := clock.FormatFrom(time.Date(2026, time.November, 1, 3, 45, 0, 0, time.UTC), now); got != "11:45 PM EDT" {
+		t.Fatalf("expected same-day time, got %q", got)
+	}
+	if got := clock.FormatFrom(time.Date(2026, time.November, 1, 7, 0, 0, 0, time.UTC), now); got != "2026-11-01 2:00 AM EST" {
+		t.Fatalf("expected dated time after midnight and DST change, got %q", got)
+	}
+	// The same instants are on one day in Tokyo.
+	tokyo, err := clock.WithZone("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("with zone: %v", err)
+	}
+	if got := tokyo.FormatFrom(time.Date(2026, time.November, 1, 7, 0, 0, 0, time.UTC), now); got != "4:00 PM JST" {
+		t.Fatalf("expected same-day Tokyo time, got %q", got)
+	}
+}
+
+func TestClockWidth(t *testing.T) {
+	for layout, want := range map[string]int{
+		"":                 8,
+		"3:04 PM":          8,
+		"Monday 15:04":     15,
+		"January 2 15:04":  18,
+		"15:04:05.000 MST": 16,
+	} {
+		clock, err := New(layout, "UTC")
+		if err != nil {
+			t.Fatalf("new %q: %v", layout, err)
+		}
+		if got := clock.Width(); got != want {
+			t.Fatalf("layout %q: expected width %d, got %d", layout, want, got)
+		}
+	}
+}
+
+func TestNewRejectsInvalidValues(t *testing.T) {
+	if _, err := New("", "Mars/Olympus_Mons"); err == nil || !strings.Contains(err.Error(), `"Mars/Olympus_Mons"`) {
+		t.Fatalf("expected unknown zone error naming the zone, got %v", err)
+	}
+	if _, err := New("2006-01-02", ""); err == nil || !strings.Contains(err.Error(), `"2006-01-02"`) {
+		t.Fatalf("expected date-only layout to be rejected, got %v", err)
+	}
+	if _, err := New("hh:mm", ""); err == nil {
+		t.Fatalf("expected layout without time elements to be rejected")
+	}
+	clock, err := New("", "Local")
+	if err != nil || clock.Location() != time.Local {
+		t.Fatalf("expected Local zone, got %v err=%v", clock.Location(), err)
+	}
+}
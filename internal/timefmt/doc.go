@@ -0,0 +1,3 @@
+// Package timefmt formats times shown to users in a configured layout and
+// time zone.
+package timefmt
@@ -0,0 +1,63 @@
+package timefmt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Duration formats d for countdowns such as "resets in 1h10m", rounding up
+// to whole minutes: "45m", "2h", "1h10m" or "3d 4h".
+func Duration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	if d%time.Minute != 0 {
+		d = d.Truncate(time.Minute) + time.Minute
+	} else {
+		d = d.Truncate(time.Minute)
+	}
+	totalMinutes := int64(d / time.Minute)
+	if totalMinutes == 0 {
+		return "0m"
+	}
+	const minutesPerHour = 60
+	const minutesPerDay = 24 * minutesPerHour
+	days := totalMinutes / minutesPerDay
+	hours := (totalMinutes / minutesPerHour) % 24
+	minutes := totalMinutes % minutesPerHour
+	if days > 0 {
+		parts := make([]string, 0, 3)
+		parts = append(parts, fmt.Sprintf("%dd", days))
+		if hours > 0 {
+			parts = append(parts, fmt.Sprintf("%dh", hours))
+		}
+		if minutes > 0 {
+			parts = append(parts, fmt.Sprintf("%dm", minutes))
+		}
+		return strings.Join(parts, " ")
+	}
+	if hours > 0 {
+		if minutes > 0 {
+			return fmt.Sprintf("%dh%dm", hours, minutes)
+		}
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// Ago describes how long before now at was, in the largest whole unit:
+// "just now", "5m ago", "2h ago" or "3d ago".
+func Ago(now, at time.Time) string {
+	elapsed := now.Sub(at)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed/time.Minute))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(elapsed/(24*time.Hour)))
+	}
+}
@@ -0,0 +1,44 @@
+package timefmt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationRoundsUpToMinutes(t *testing.T) {
+	cases := []struct {
+		in   time.Duration
+		want string
+	}{
+		{-time.Minute, "0m"},
+		{0, "0m"},
+		{30 * time.Second, "1m"},
+		{45 * time.Minute, "45m"},
+		{2 * time.Hour, "2h"},
+		{time.Hour + 9*time.Minute + time.Second, "1h10m"},
+		{3*24*time.Hour + 4*time.Hour, "3d 4h"},
+	}
+	for _, tc := range cases {
+		if got := Duration(tc.in); got != tc.want {
+			t.Fatalf("Duration(%s) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestAgo(t *testing.T) {
+	now := time.Date(2026, time.March, 4, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(-30 * time.Second), "just now"},
+		{now.Add(-5 * time.Minute), "5m ago"},
+		{now.Add(-2*time.Hour - 59*time.Minute), "2h ago"},
+		{now.Add(-75 * time.Hour), "3d ago"},
+	}
+	for _, tc := range cases {
+		if got := Ago(now, tc.at); got != tc.want {
+			t.Fatalf("Ago(%s) = %q, want %q", now.Sub(tc.at), got, tc.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"pkt.systems/centaurx/bootstrap"
 )
@@ -12,19 +13,41 @@ func main() {
 	var output string
 	var overwrite bool
 	var seedUsers bool
+	var dryRun bool
+	var models string
+	var opts bootstrap.Options
 	flag.StringVar(&output, "output", ".", "output directory")
 	flag.StringVar(&output, "o", ".", "output directory")
 	flag.BoolVar(&overwrite, "force", false, "overwrite existing files")
 	flag.BoolVar(&seedUsers, "seed-users", false, "seed default users (admin)")
+	flag.BoolVar(&dryRun, "dry-run", false, "print generated files to stdout instead of writing them")
+	flag.IntVar(&opts.HTTPPort, "http-port", 0, "HTTP listen and published port (default 27480)")
+	flag.IntVar(&opts.SSHPort, "ssh-port", 0, "SSH listen and published port (default 27422)")
+	flag.StringVar(&opts.RepoRoot, "repo-root", "", "host repo root mounted into the container")
+	flag.StringVar(&opts.StateDir, "state-dir", "", "host state dir mounted into the container")
+	flag.StringVar(&opts.ContainerScope, "container-scope", "", "runner container scope (user or tab)")
+	flag.IntVar(&opts.CPUPercent, "cpu-percent", 0, "runner CPU limit in percent of host")
+	flag.IntVar(&opts.MemoryPercent, "memory-percent", 0, "runner memory limit in percent of host")
+	flag.StringVar(&models, "models", "", "comma-separated list of allowed models")
+	flag.StringVar(&opts.DefaultModel, "default-model", "", "default model")
 	flag.Parse()
 
-	files, assets, err := bootstrap.DefaultRepoBundleWithOptions(bootstrap.Options{
-		SeedUsers: seedUsers,
-	})
+	opts.SeedUsers = seedUsers
+	if models != "" {
+		opts.AllowedModels = strings.Split(models, ",")
+	}
+	files, assets, err := bootstrap.DefaultRepoBundleWithOptions(opts)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+	if dryRun {
+		if err := bootstrap.DryRunFiles(os.Stdout, output, files); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	paths, err := bootstrap.WriteFilesWithAssets(output, files, assets, overwrite)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
@@ -0,0 +1,6 @@
+// Package tuitest drives the SSH terminal UI end to end in tests. A
+// Harness runs the real core service, command handler and SSH server over
+// temp dirs with a codex-mock runner, connects with an SSH client that
+// requests a PTY, and keeps a screen model of what the client would see so
+// tests can type keys and assert on rendered rows.
+package tuitest
@@ -0,0 +1,332 @@
+package tuitest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/auth"
+	"pkt.systems/centaurx/internal/codex"
+	"pkt.systems/centaurx/internal/command"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/centaurx/sshserver"
+)
+
+const (
+	// DefaultCols and DefaultRows size the PTY when Options leaves them zero.
+	DefaultCols = 100
+	DefaultRows = 30
+	// DefaultTimeout bounds each Expect call.
+	DefaultTimeout = 10 * time.Second
+	// User is the login of the harness user.
+	User schema.UserID = "tester"
+)
+
+// Key sequences for Type.
+const (
+	KeyEnter = "\r"
+	KeyCtrlC = "\x03"
+	KeyTab   = "\t"
+	KeyUp    = "\x1b[A"
+	KeyDown  = "\x1b[B"
+)
+
+// Options configures a Harness.
+type Options struct {
+	// CodexBinary is a codex-mock binary from BuildCodexMock.
+	CodexBinary string
+	// Scenario is a codex-mock scenario file; empty uses the mock's
+	// default stream.
+	Scenario string
+	// Cols and Rows size the PTY.
+	Cols int
+	Rows int
+	// Timeout bounds each Expect call.
+	Timeout time.Duration
+}
+
+// Harness is a running SSH server with one connected PTY session.
+type Harness struct {
+	// Service is the core service behind the server, for setup and
+	// assertions that are awkward through the screen.
+	Service core.Service
+	// Screen holds what the client terminal shows.
+	Screen *Screen
+
+	t       testing.TB
+	timeout time.Duration
+	session *ssh.Session
+	stdin   io.Writer
+}
+
+// BuildCodexMock builds the centaurx binary under the codex-mock argv0
+// alias so the codex runner executes it like the real codex binary. Build
+// it once per test and share it between harnesses.
+func BuildCodexMock(t testing.TB) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "codex-mock")
+	cmd := exec.Command("go", "build", "-o", bin, "pkt.systems/centaurx/cmd/centaurx")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build codex-mock: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// New starts the service, command handler and SSH server over temp dirs
+// and opens a PTY session as User. Everything is torn down by t.Cleanup.
+func New(t testing.TB, opts Options) *Harness {
+	t.Helper()
+	if opts.CodexBinary == "" {
+		t.Fatalf("tuitest: CodexBinary is required")
+	}
+	if opts.Cols <= 0 {
+		opts.Cols = DefaultCols
+	}
+	if opts.Rows <= 0 {
+		opts.Rows = DefaultRows
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	args := []string{"--delay-ms", "0"}
+	if opts.Scenario != "" {
+		args = append(args, "--scenario", opts.Scenario)
+	}
+	runner, err := codex.NewRunner(codex.Config{BinaryPath: opts.CodexBinary, ExtraArgs: args})
+	if err != nil {
+		t.Fatalf("tuitest: codex runner: %v", err)
+	}
+	runners := core.StaticRunnerProvider{Runner: runner}
+
+	repoRoot := t.TempDir()
+	service, err := core.NewService(schema.ServiceConfig{
+		RepoRoot:      repoRoot,
+		StateDir:      filepath.Join(t.TempDir(), "state"),
+		DefaultModel:  "gpt-5.2-codex",
+		AllowedModels: []schema.ModelID{"gpt-5.2-codex"},
+	}, core.ServiceDeps{RunnerProvider: runners})
+	if err != nil {
+		t.Fatalf("tuitest: service: %v", err)
+	}
+	handler := command.NewHandler(service, runners, command.HandlerConfig{
+		AllowedModels: []schema.ModelID{"gpt-5.2-codex"},
+		RepoRoot:      repoRoot,
+	})
+
+	authStore, totpSecret := newAuthStore(t)
+	signer := newSigner(t)
+	pubKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	if _, err := authStore.AddLoginPubKey(User, pubKey); err != nil {
+		t.Fatalf("tuitest: add login key: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("tuitest: listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &sshserver.Server{
+		Addr:        ln.Addr().String(),
+		Listener:    ln,
+		HostKeyPath: filepath.Join(t.TempDir(), "host_key"),
+		Service:     service,
+		Handler:     handler,
+		AuthStore:   authStore,
+	}
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = ln.Close()
+	})
+
+	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User: string(User),
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+			ssh.KeyboardInteractive(func(_, _ string, _ []string, _ []bool) ([]string, error) {
+				code, err := totp.GenerateCode(totpSecret, time.Now())
+				return []string{code}, err
+			}),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("tuitest: dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("tuitest: session: %v", err)
+	}
+	t.Cleanup(func() { _ = session.Close() })
+	if err := session.RequestPty("xterm-256color", opts.Rows, opts.Cols, ssh.TerminalModes{}); err != nil {
+		t.Fatalf("tuitest: pty: %v", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		t.Fatalf("tuitest: stdin: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		t.Fatalf("tuitest: stdout: %v", err)
+	}
+	if err := session.Shell(); err != nil {
+		t.Fatalf("tuitest: shell: %v", err)
+	}
+	screen := NewScreen(opts.Cols, opts.Rows)
+	go func() {
+		_, _ = io.Copy(screen, stdout)
+	}()
+	return &Harness{
+		Service: service,
+		Screen:  screen,
+		t:       t,
+		timeout: opts.Timeout,
+		session: session,
+		stdin:   stdin,
+	}
+}
+
+// Type sends keys to the session as typed, without a trailing Enter.
+func (h *Harness) Type(keys string) {
+	h.t.Helper()
+	if _, err := io.WriteString(h.stdin, keys); err != nil {
+		h.t.Fatalf("tuitest: type %q: %v", keys, err)
+	}
+}
+
+// TypeLine types line and presses Enter.
+func (h *Harness) TypeLine(line string) {
+	h.t.Helper()
+	h.Type(line + KeyEnter)
+}
+
+// ExpectRow waits until a screen row contains text and returns its index.
+func (h *Harness) ExpectRow(text string) int {
+	h.t.Helper()
+	var row int
+	h.waitFor(fmt.Sprintf("a row containing %q", text), func(rows []string) bool {
+		for i, line := range rows {
+			if strings.Contains(line, text) {
+				row = i
+				return true
+			}
+		}
+		return false
+	})
+	return row
+}
+
+// ExpectRowAt waits until row index (negative counts from the bottom, -1
+// being the last row) contains text.
+func (h *Harness) ExpectRowAt(index int, text string) {
+	h.t.Helper()
+	h.waitFor(fmt.Sprintf("row %d containing %q", index, text), func(rows []string) bool {
+		i := index
+		if i < 0 {
+			i += len(rows)
+		}
+		return i >= 0 && i < len(rows) && strings.Contains(rows[i], text)
+	})
+}
+
+// ExpectNoRow waits until no screen row contains text.
+func (h *Harness) ExpectNoRow(text string) {
+	h.t.Helper()
+	h.waitFor(fmt.Sprintf("no row containing %q", text), func(rows []string) bool {
+		for _, line := range rows {
+			if strings.Contains(line, text) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// ResizeTo changes the PTY size as a terminal window resize would.
+func (h *Harness) ResizeTo(cols, rows int) {
+	h.t.Helper()
+	h.Screen.Resize(cols, rows)
+	if err := h.session.WindowChange(rows, cols); err != nil {
+		h.t.Fatalf("tuitest: resize: %v", err)
+	}
+}
+
+// Wait waits for the session to end, e.g. after /quit.
+func (h *Harness) Wait() {
+	h.t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.session.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(h.timeout):
+		h.t.Fatalf("tuitest: session did not end within %s", h.timeout)
+	}
+}
+
+func (h *Harness) waitFor(what string, match func([]string) bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for {
+		if match(h.Screen.Rows()) {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("tuitest: timed out waiting for %s; screen:\n%s", what, h.Screen.String())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func newAuthStore(t testing.TB) (*auth.Store, string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("tuitest"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("tuitest: password hash: %v", err)
+	}
+	secret, err := totp.Generate(totp.GenerateOpts{Issuer: "centaurx", AccountName: string(User)})
+	if err != nil {
+		t.Fatalf("tuitest: totp: %v", err)
+	}
+	store, err := auth.NewStoreWithLogger(filepath.Join(t.TempDir(), "users.json"), []appconfig.SeedUser{{
+		Username:     string(User),
+		PasswordHash: string(hash),
+		TOTPSecret:   secret.Secret(),
+	}}, nil)
+	if err != nil {
+		t.Fatalf("tuitest: auth store: %v", err)
+	}
+	return store, secret.Secret()
+}
+
+func newSigner(t testing.TB) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("tuitest: generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("tuitest: signer: %v", err)
+	}
+	return signer
+}
@@ -0,0 +1,195 @@
+package tuitest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Screen is a minimal terminal emulator for the escape sequences the SSH
+// server writes: cursor positioning, erase in display and line, and the
+// alternate screen. Colors and other attributes are dropped, so rows hold
+// plain text.
+type Screen struct {
+	mu    sync.Mutex
+	cells [][]rune
+	row   int
+	col   int
+	// pending holds an escape sequence split across writes.
+	pending []byte
+}
+
+// NewScreen returns a blank screen of cols by rows cells.
+func NewScreen(cols, rows int) *Screen {
+	s := &Screen{}
+	s.resizeLocked(cols, rows)
+	return s
+}
+
+// Resize changes the screen size, keeping the top left of its contents.
+func (s *Screen) Resize(cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resizeLocked(cols, rows)
+}
+
+func (s *Screen) resizeLocked(cols, rows int) {
+	cells := make([][]rune, rows)
+	for i := range cells {
+		cells[i] = blankRow(cols)
+		if i < len(s.cells) {
+			copy(cells[i], s.cells[i])
+		}
+	}
+	s.cells = cells
+	s.row = min(s.row, rows-1)
+	s.col = min(s.col, cols-1)
+}
+
+// Write feeds terminal output to the screen.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := append(s.pending, p...)
+	s.pending = nil
+	text := string(data)
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			n, ok := s.escapeLocked(text[i:])
+			if !ok {
+				s.pending = []byte(text[i:])
+				break
+			}
+			i += n
+			continue
+		}
+		if !utf8.FullRuneInString(text[i:]) {
+			s.pending = []byte(text[i:])
+			break
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		s.putLocked(r)
+		i += size
+	}
+	return len(p), nil
+}
+
+// escapeLocked applies the escape sequence at the start of seq and returns
+// its length; ok is false when seq ends before the sequence does.
+func (s *Screen) escapeLocked(seq string) (int, bool) {
+	if len(seq) < 2 {
+		return 0, false
+	}
+	if seq[1] != '[' {
+		// Two byte escapes carry nothing the screen model needs.
+		return 2, true
+	}
+	end := 2
+	for end < len(seq) && (seq[end] < 0x40 || seq[end] > 0x7e) {
+		end++
+	}
+	if end >= len(seq) {
+		return 0, false
+	}
+	params := seq[2:end]
+	switch seq[end] {
+	case 'H', 'f':
+		row, col := 1, 1
+		if params != "" {
+			parts := strings.SplitN(params, ";", 2)
+			row = atoiDefault(parts[0], 1)
+			if len(parts) == 2 {
+				col = atoiDefault(parts[1], 1)
+			}
+		}
+		s.row = clamp(row-1, 0, len(s.cells)-1)
+		s.col = clamp(col-1, 0, len(s.cells[0])-1)
+	case 'J':
+		if params == "2" || params == "3" {
+			for i := range s.cells {
+				s.cells[i] = blankRow(len(s.cells[i]))
+			}
+		}
+	case 'K':
+		line := s.cells[s.row]
+		switch params {
+		case "2":
+			s.cells[s.row] = blankRow(len(line))
+		case "", "0":
+			for i := s.col; i < len(line); i++ {
+				line[i] = ' '
+			}
+		}
+	case 'h':
+		if params == "?1049" {
+			for i := range s.cells {
+				s.cells[i] = blankRow(len(s.cells[i]))
+			}
+			s.row, s.col = 0, 0
+		}
+	}
+	return end + 1, true
+}
+
+func (s *Screen) putLocked(r rune) {
+	switch r {
+	case '\r':
+		s.col = 0
+	case '\n':
+		if s.row < len(s.cells)-1 {
+			s.row++
+		} else {
+			copy(s.cells, s.cells[1:])
+			s.cells[len(s.cells)-1] = blankRow(len(s.cells[0]))
+		}
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+	case '\a', '\t':
+	default:
+		if s.col >= len(s.cells[s.row]) {
+			return
+		}
+		s.cells[s.row][s.col] = r
+		s.col++
+	}
+}
+
+// Rows returns the screen contents, one string per row with trailing
+// blanks trimmed.
+func (s *Screen) Rows() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make([]string, len(s.cells))
+	for i, line := range s.cells {
+		rows[i] = strings.TrimRight(string(line), " ")
+	}
+	return rows
+}
+
+// String returns the rows joined by newlines, for failure messages.
+func (s *Screen) String() string {
+	return strings.Join(s.Rows(), "\n")
+}
+
+func blankRow(cols int) []rune {
+	row := make([]rune, cols)
+	for i := range row {
+		row[i] = ' '
+	}
+	return row
+}
+
+func atoiDefault(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fallback
+	}
+	return n
+}
+
+func clamp(value, lo, hi int) int {
+	return max(lo, min(value, hi))
+}
@@ -0,0 +1,24 @@
+package tuitest
+
+import "testing"
+
+func TestScreenAppliesRenderSequences(t *testing.T) {
+	s := NewScreen(10, 3)
+	_, _ = s.Write([]byte("\x1b[?1049h\x1b[H\x1b[2J\x1b[?25l\x1b[1mtab\x1b[0m\r\nline one\r\n> "))
+	_, _ = s.Write([]byte("\x1b[2;1H\x1b[2Kline"))
+	// An escape sequence and a rune split across writes.
+	_, _ = s.Write([]byte("\x1b[3;3"))
+	_, _ = s.Write([]byte("Hhi \xe2\x80"))
+	_, _ = s.Write([]byte("\xa6"))
+	rows := s.Rows()
+	want := []string{"tab", "line", "> hi …"}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Fatalf("row %d: got %q want %q (screen %q)", i, rows[i], want[i], rows)
+		}
+	}
+	s.Resize(4, 2)
+	if rows := s.Rows(); len(rows) != 2 || rows[0] != "tab" || rows[1] != "line" {
+		t.Fatalf("unexpected rows after resize: %q", rows)
+	}
+}
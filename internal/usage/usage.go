@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
 )
 
@@ -44,6 +45,14 @@ type rateLimitWindow struct {
 	ResetAt            int64   `json:"reset_at"`
 }
 
+type modelListPayload struct {
+	Models []modelInfo `json:"models"`
+}
+
+type modelInfo struct {
+	Slug string `json:"slug"`
+}
+
 // Fetch retrieves ChatGPT usage windows based on the codex auth.json file.
 func Fetch(ctx context.Context) (core.UsageInfo, error) {
 	log := pslog.Ctx(ctx)
@@ -85,6 +94,45 @@ func Fetch(ctx context.Context) (core.UsageInfo, error) {
 	return info, nil
 }
 
+// FetchModels lists the models available to the ChatGPT account of the
+// codex auth.json file. The list is not Listed for other logins.
+func FetchModels(ctx context.Context) (core.ModelList, error) {
+	log := pslog.Ctx(ctx)
+	dotcodex := resolveDotCodexDir()
+	if dotcodex == "" {
+		log.Debug("model list skipped", "reason", "dotcodex not found")
+		return core.ModelList{}, nil
+	}
+	auth, chatgpt, err := loadAuth(filepath.Join(dotcodex, "auth.json"))
+	if err != nil {
+		log.Warn("model list auth load failed", "err", err)
+		return core.ModelList{}, err
+	}
+	if !chatgpt {
+		log.Debug("model list skipped", "reason", "non-chatgpt auth")
+		return core.ModelList{}, nil
+	}
+	baseURL, err := resolveBaseURL(filepath.Join(dotcodex, "config.toml"))
+	if err != nil {
+		log.Warn("model list base url failed", "err", err)
+		return core.ModelList{}, err
+	}
+	started := time.Now()
+	payload, err := fetchModelList(ctx, baseURL, auth)
+	if err != nil {
+		log.Warn("model list fetch failed", "err", err, "duration_ms", time.Since(started).Milliseconds())
+		return core.ModelList{}, err
+	}
+	list := core.ModelList{Listed: true}
+	for _, model := range payload.Models {
+		if slug := strings.TrimSpace(model.Slug); slug != "" {
+			list.Models = append(list.Models, schema.ModelID(slug))
+		}
+	}
+	log.Debug("model list fetch completed", "models", len(list.Models), "duration_ms", time.Since(started).Milliseconds())
+	return list, nil
+}
+
 func resolveDotCodexDir() string {
 	if value := strings.TrimSpace(os.Getenv("CENTAURX_DOTCODEX_DIR")); value != "" {
 		return value
@@ -186,10 +234,27 @@ func loadAuth(path string) (authTokens, bool, error) {
 }
 
 func fetchRateLimits(ctx context.Context, baseURL string, auth authTokens) (*rateLimitStatusPayload, error) {
-	url := strings.TrimRight(baseURL, "/") + "/wham/usage"
+	var payload rateLimitStatusPayload
+	if err := getJSON(ctx, strings.TrimRight(baseURL, "/")+"/wham/usage", auth, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+func fetchModelList(ctx context.Context, baseURL string, auth authTokens) (*modelListPayload, error) {
+	var payload modelListPayload
+	if err := getJSON(ctx, strings.TrimRight(baseURL, "/")+"/codex/models", auth, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// getJSON sends an authenticated GET to the ChatGPT backend and decodes the
+// JSON response into out.
+func getJSON(ctx context.Context, url string, auth authTokens, out any) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("build request: %w", err)
+		return fmt.Errorf("build request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+auth.AccessToken)
 	req.Header.Set("ChatGPT-Account-Id", auth.AccountID)
@@ -198,21 +263,20 @@ func fetchRateLimits(ctx context.Context, baseURL string, auth authTokens) (*rat
 	client := &http.Client{Timeout: 15 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request %s: %w", url, err)
+		return fmt.Errorf("request %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
-		return nil, fmt.Errorf("request %s failed: %s; body=%s", url, resp.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("request %s failed: %s; body=%s", url, resp.Status, strings.TrimSpace(string(body)))
 	}
 
-	var payload rateLimitStatusPayload
 	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&payload); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
 	}
-	return &payload, nil
+	return nil
 }
 
 func toUsageWindow(window *rateLimitWindow) *core.UsageWindow {
@@ -1,6 +1,9 @@
 package usage
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -73,3 +76,35 @@ func TestLoadAuth(t *testing.T) {
 		t.Fatalf("unexpected auth tokens: %+v", got)
 	}
 }
+
+func TestFetchModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/codex/models" || r.Header.Get("Authorization") != "Bearer tok" || r.Header.Get("ChatGPT-Account-Id") != "acct" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"models":[{"slug":"gpt-5.2-codex"},{"slug":" "},{"slug":"gpt-5.2"}]}`))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	t.Setenv("CENTAURX_DOTCODEX_DIR", dir)
+
+	list, err := FetchModels(context.Background())
+	if err != nil || list.Listed {
+		t.Fatalf("expected no list without auth, got %+v (err %v)", list, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "auth.json"), []byte(`{"tokens":{"access_token":"tok","account_id":"acct"}}`), 0o600); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte("chatgpt_base_url = \""+srv.URL+"/api/\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	list, err = FetchModels(context.Background())
+	if err != nil {
+		t.Fatalf("FetchModels: %v", err)
+	}
+	if !list.Listed || len(list.Models) != 2 || list.Models[0] != "gpt-5.2-codex" || list.Models[1] != "gpt-5.2" {
+		t.Fatalf("unexpected model list: %+v", list)
+	}
+}
@@ -0,0 +1,184 @@
+package userexport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"pkt.systems/centaurx/internal/persist"
+)
+
+// FormatVersion is the bundle format Write produces and Read accepts.
+const FormatVersion = 1
+
+const (
+	manifestName  = "manifest.json"
+	accountName   = "account.json"
+	stateName     = "state.json"
+	gitKeyName    = "git_key"
+	codexAuthName = "codex_auth.json"
+	maxEntrySize  = 1 << 30
+)
+
+// Manifest describes a bundle.
+type Manifest struct {
+	FormatVersion int `json:"format_version"`
+	// ServerVersion is the centaurx version that wrote the bundle.
+	ServerVersion string    `json:"server_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Username      string    `json:"username"`
+	// RepoRoot is the repo root of the source server; the repo paths in the
+	// snapshot start with it.
+	RepoRoot string `json:"repo_root"`
+	// IncludesSecrets is set when the bundle carries the password hash, the
+	// TOTP secret, the git private key and the codex auth.json.
+	IncludesSecrets bool `json:"includes_secrets"`
+}
+
+// Account holds the user's auth material. The password hash and TOTP secret
+// are only set when the bundle includes secrets.
+type Account struct {
+	PasswordHash string   `json:"password_hash,omitempty"`
+	TOTPSecret   string   `json:"totp_secret,omitempty"`
+	LoginPubKeys []string `json:"login_pubkeys,omitempty"`
+	GitPublicKey string   `json:"git_public_key,omitempty"`
+}
+
+// Bundle is the content of an export.
+type Bundle struct {
+	Manifest Manifest
+	Account  Account
+	// Snapshot holds the user's tabs with their buffers and prompt history;
+	// nil when the user has no saved state.
+	Snapshot *persist.UserSnapshot
+	// GitPrivateKey is the PEM encoded git SSH key.
+	GitPrivateKey []byte
+	// CodexAuth is the user's codex auth.json.
+	CodexAuth []byte
+}
+
+// Write writes b as a tar.gz archive. Secrets are left out unless the
+// manifest says the bundle includes them.
+func Write(w io.Writer, b Bundle) error {
+	b.Manifest.FormatVersion = FormatVersion
+	if !b.Manifest.IncludesSecrets {
+		b.Account.PasswordHash = ""
+		b.Account.TOTPSecret = ""
+		b.GitPrivateKey = nil
+		b.CodexAuth = nil
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: b.Manifest.ExportedAt,
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	addJSON := func(name string, value any) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, data)
+	}
+	if err := addJSON(manifestName, b.Manifest); err != nil {
+		return err
+	}
+	if err := addJSON(accountName, b.Account); err != nil {
+		return err
+	}
+	if b.Snapshot != nil {
+		snapshot := *b.Snapshot
+		snapshot.Version = persist.CurrentVersion
+		if err := addJSON(stateName, snapshot); err != nil {
+			return err
+		}
+	}
+	if len(b.GitPrivateKey) > 0 {
+		if err := add(gitKeyName, b.GitPrivateKey); err != nil {
+			return err
+		}
+	}
+	if len(b.CodexAuth) > 0 {
+		if err := add(codexAuthName, b.CodexAuth); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read reads a bundle written by Write. It rejects bundles of another
+// format version and migrates state written by an older server.
+func Read(r io.Reader) (Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("read bundle: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Bundle{}, fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > maxEntrySize {
+			return Bundle{}, fmt.Errorf("read bundle: %s is too large", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("read bundle: %w", err)
+		}
+		entries[hdr.Name] = data
+	}
+
+	var b Bundle
+	raw, ok := entries[manifestName]
+	if !ok {
+		return Bundle{}, errors.New("read bundle: no manifest")
+	}
+	if err := json.Unmarshal(raw, &b.Manifest); err != nil {
+		return Bundle{}, fmt.Errorf("read bundle manifest: %w", err)
+	}
+	if b.Manifest.FormatVersion != FormatVersion {
+		return Bundle{}, fmt.Errorf("unsupported bundle format version %d (this server reads version %d)", b.Manifest.FormatVersion, FormatVersion)
+	}
+	if b.Manifest.Username == "" {
+		return Bundle{}, errors.New("read bundle: manifest names no user")
+	}
+	if raw, ok := entries[accountName]; ok {
+		if err := json.Unmarshal(raw, &b.Account); err != nil {
+			return Bundle{}, fmt.Errorf("read bundle account: %w", err)
+		}
+	}
+	if raw, ok := entries[stateName]; ok {
+		snapshot, err := persist.DecodeSnapshot(raw)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("read bundle state: %w", err)
+		}
+		b.Snapshot = &snapshot
+	}
+	b.GitPrivateKey = entries[gitKeyName]
+	b.CodexAuth = entries[codexAuthName]
+	return b, nil
+}
@@ -0,0 +1,142 @@
+package userexport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/schema"
+)
+
+func testBundle() Bundle {
+	return Bundle{
+		Manifest: Manifest{
+			ServerVersion: "v1.2.3",
+			ExportedAt:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Username:      "alice",
+			RepoRoot:      "/srv/repos",
+		},
+		Account: Account{
+			PasswordHash: "hash",
+			TOTPSecret:   "secret",
+			LoginPubKeys: []string{"ssh-ed25519 AAAA alice"},
+			GitPublicKey: "ssh-ed25519 BBBB alice",
+		},
+		Snapshot: &persist.UserSnapshot{
+			Tabs: []persist.TabSnapshot{{
+				ID:   "tab1",
+				Repo: schema.RepoRef{Name: "demo", Path: "/srv/repos/alice/demo"},
+				Buffer: persist.BufferSnapshot{
+					Lines: []schema.BufferLine{{Text: "hello"}},
+				},
+				History: []persist.HistoryEntry{{Text: "fix the tests"}},
+			}},
+		},
+		GitPrivateKey: []byte("private"),
+		CodexAuth:     []byte(`{"token":"x"}`),
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	in := testBundle()
+	in.Manifest.IncludesSecrets = true
+	var buf bytes.Buffer
+	if err := Write(&buf, in); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if out.Manifest.FormatVersion != FormatVersion || out.Manifest.ServerVersion != "v1.2.3" || out.Manifest.Username != "alice" {
+		t.Fatalf("unexpected manifest %+v", out.Manifest)
+	}
+	if out.Account.PasswordHash != "hash" || out.Account.TOTPSecret != "secret" || len(out.Account.LoginPubKeys) != 1 {
+		t.Fatalf("unexpected account %+v", out.Account)
+	}
+	if string(out.GitPrivateKey) != "private" || string(out.CodexAuth) != `{"token":"x"}` {
+		t.Fatalf("expected the secrets, got %q and %q", out.GitPrivateKey, out.CodexAuth)
+	}
+	if out.Snapshot == nil || len(out.Snapshot.Tabs) != 1 {
+		t.Fatalf("expected one tab, got %+v", out.Snapshot)
+	}
+	tab := out.Snapshot.Tabs[0]
+	if len(tab.Buffer.Lines) != 1 || tab.Buffer.Lines[0].Text != "hello" || len(tab.History) != 1 {
+		t.Fatalf("expected the buffer and history, got %+v", tab)
+	}
+}
+
+func TestWriteLeavesOutSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testBundle()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if out.Account.PasswordHash != "" || out.Account.TOTPSecret != "" || out.GitPrivateKey != nil || out.CodexAuth != nil {
+		t.Fatalf("expected no secrets, got %+v", out)
+	}
+	if out.Account.GitPublicKey == "" || len(out.Account.LoginPubKeys) != 1 {
+		t.Fatalf("expected the public keys, got %+v", out.Account)
+	}
+}
+
+func TestReadRejectsOtherFormatVersions(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	data, _ := json.Marshal(Manifest{FormatVersion: FormatVersion + 1, Username: "alice"})
+	_ = tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0o600, Size: int64(len(data))})
+	_, _ = tw.Write(data)
+	_ = tw.Close()
+	_ = gz.Close()
+	if _, err := Read(&buf); err == nil || !strings.Contains(err.Error(), "unsupported bundle format version") {
+		t.Fatalf("expected a format version error, got %v", err)
+	}
+}
+
+func TestPrepareSnapshotRebasesRepos(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bob", "demo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	b := testBundle()
+	b.Snapshot.Tabs[0].Shares = []persist.TabShare{{User: "carol", Access: "ro"}}
+	b.Snapshot.Tabs[0].SessionID = "session"
+	b.Snapshot.Tabs = append(b.Snapshot.Tabs, persist.TabSnapshot{
+		ID:   "tab2",
+		Repo: schema.RepoRef{Name: "gone", Path: "/srv/repos/alice/gone"},
+	}, persist.TabSnapshot{
+		ID:   "tab3",
+		Repo: schema.RepoRef{Name: "elsewhere", Path: root},
+	})
+	b.Snapshot.SharedTabs = []persist.SharedTab{{TabID: "x", Owner: "dave"}}
+
+	warnings := PrepareSnapshot(b.Snapshot, b.Manifest, root, "bob")
+	tabs := b.Snapshot.Tabs
+	if got, want := tabs[0].Repo.Path, filepath.Join(root, "bob", "demo"); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if got, want := tabs[1].Repo.Path, filepath.Join(root, "bob", "gone"); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if tabs[2].Repo.Path != root {
+		t.Fatalf("expected a path outside the repo root to be kept, got %s", tabs[2].Repo.Path)
+	}
+	if tabs[0].Shares != nil || tabs[0].SessionID != "" || b.Snapshot.SharedTabs != nil {
+		t.Fatalf("expected shares and sessions to be dropped, got %+v", b.Snapshot)
+	}
+	text := strings.Join(warnings, "\n")
+	if len(warnings) != 2 || !strings.Contains(text, "dropped 2 tab shares") || !strings.Contains(text, "repo gone is not on this server") {
+		t.Fatalf("unexpected warnings %q", warnings)
+	}
+}
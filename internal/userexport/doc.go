@@ -0,0 +1,3 @@
+// Package userexport reads and writes the tar.gz bundles that move a user's
+// state from one centaurx server to another.
+package userexport
@@ -0,0 +1,83 @@
+package userexport
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkt.systems/centaurx/internal/persist"
+	"pkt.systems/centaurx/schema"
+)
+
+// PrepareSnapshot adapts an imported snapshot to this server and returns
+// warnings for the operator. It moves repo paths from the source repo root
+// and user to repoRoot and username, drops tab shares, which name users of
+// the source server, and clears codex session ids, whose sessions stay
+// behind. Repos that do not exist locally are reported, not created.
+func PrepareSnapshot(snapshot *persist.UserSnapshot, manifest Manifest, repoRoot, username string) []string {
+	var warnings []string
+	shares := len(snapshot.SharedTabs)
+	snapshot.SharedTabs = nil
+	snapshot.Recovery = nil
+	prepare := func(tab *persist.TabSnapshot) {
+		tab.Repo.Path = rebasePath(tab.Repo.Path, manifest.RepoRoot, manifest.Username, repoRoot, username)
+		shares += len(tab.Shares)
+		tab.Shares = nil
+		tab.SessionID = ""
+	}
+	for i := range snapshot.Tabs {
+		prepare(&snapshot.Tabs[i])
+	}
+	for i := range snapshot.ClosedTabs {
+		prepare(&snapshot.ClosedTabs[i].Tab)
+	}
+	if shares > 0 {
+		warnings = append(warnings, fmt.Sprintf("dropped %d tab shares; share the tabs again with /share", shares))
+	}
+	for _, repo := range MissingRepos(*snapshot) {
+		warnings = append(warnings, fmt.Sprintf("repo %s is not on this server (expected at %s); clone it with /new", repo.Name, repo.Path))
+	}
+	return warnings
+}
+
+// MissingRepos returns the repos referenced by open and closed tabs whose
+// paths do not exist.
+func MissingRepos(snapshot persist.UserSnapshot) []schema.RepoRef {
+	var missing []schema.RepoRef
+	seen := make(map[string]bool)
+	check := func(repo schema.RepoRef) {
+		if repo.Path == "" || seen[repo.Path] {
+			return
+		}
+		seen[repo.Path] = true
+		if _, err := os.Stat(repo.Path); errors.Is(err, os.ErrNotExist) {
+			missing = append(missing, repo)
+		}
+	}
+	for _, tab := range snapshot.Tabs {
+		check(tab.Repo)
+	}
+	for _, closed := range snapshot.ClosedTabs {
+		check(closed.Tab.Repo)
+	}
+	return missing
+}
+
+// rebasePath moves path from fromRoot to toRoot, renaming the user
+// directory directly below the root. Paths outside fromRoot are kept.
+func rebasePath(path, fromRoot, fromUser, toRoot, toUser string) string {
+	if path == "" || fromRoot == "" {
+		return path
+	}
+	rel, err := filepath.Rel(fromRoot, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	first, rest, _ := strings.Cut(rel, string(filepath.Separator))
+	if first == fromUser {
+		rel = filepath.Join(toUser, rest)
+	}
+	return filepath.Join(toRoot, rel)
+}
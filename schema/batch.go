@@ -0,0 +1,44 @@
+package schema
+
+import "time"
+
+// BatchRepoState is the progress of one repo in a batch.
+type BatchRepoState string
+
+// BatchRepoPending and related constants are the states of a batch repo.
+const (
+	BatchRepoPending   BatchRepoState = "pending"
+	BatchRepoRunning   BatchRepoState = "running"
+	BatchRepoSucceeded BatchRepoState = "succeeded"
+	BatchRepoFailed    BatchRepoState = "failed"
+	BatchRepoCanceled  BatchRepoState = "canceled"
+)
+
+// BatchRepoStatus tracks the prompt of a batch in one repo.
+type BatchRepoStatus struct {
+	Repo RepoName `json:"repo"`
+	// TabID is the tab the prompt runs in, once one was picked.
+	TabID      TabID          `json:"tab_id,omitempty"`
+	State      BatchRepoState `json:"state"`
+	StartedAt  time.Time      `json:"started_at,omitzero"`
+	FinishedAt time.Time      `json:"finished_at,omitzero"`
+	// Error explains why the repo failed.
+	Error string `json:"error,omitempty"`
+}
+
+// BatchStatus describes a batch: one prompt sent to each of a list of
+// repos.
+type BatchStatus struct {
+	Prompt    string    `json:"prompt"`
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is set once no repo is pending or running.
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	// Stopped is set by /batch stop, which cancels the pending repos.
+	Stopped bool              `json:"stopped,omitempty"`
+	Repos   []BatchRepoStatus `json:"repos"`
+}
+
+// Active reports whether the batch still has repos pending or running.
+func (b BatchStatus) Active() bool {
+	return b.FinishedAt.IsZero()
+}
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"strings"
+	"time"
+)
+
+// LineKind classifies a buffer line so clients can render it without
+// knowing the legacy prefix markers.
+type LineKind string
+
+const (
+	// LineKindSystem is plain status or informational output.
+	LineKindSystem LineKind = "system"
+	// LineKindPrompt is a prompt submitted by the user.
+	LineKindPrompt LineKind = "prompt"
+	// LineKindAgent is agent message text (markdown).
+	LineKindAgent LineKind = "agent"
+	// LineKindAnswer is the final agent message of a turn (markdown).
+	LineKindAnswer LineKind = "answer"
+	// LineKindReasoning is agent reasoning text (markdown).
+	LineKindReasoning LineKind = "reasoning"
+	// LineKindCommand is command execution output.
+	LineKindCommand LineKind = "command"
+	// LineKindStderr is shell command output written to stderr.
+	LineKindStderr LineKind = "stderr"
+	// LineKindError is an error message.
+	LineKindError LineKind = "error"
+	// LineKindSeparator separates turns ("Worked for ...").
+	LineKindSeparator LineKind = "separator"
+	// LineKindHelp is help output (markdown).
+	LineKindHelp LineKind = "help"
+	// LineKindAboutVersion is the version line of /version output.
+	LineKindAboutVersion LineKind = "about_version"
+	// LineKindAboutCopyright is the copyright line of /version output.
+	LineKindAboutCopyright LineKind = "about_copyright"
+	// LineKindAboutLink is the link line of /version output.
+	LineKindAboutLink LineKind = "about_link"
+	// LineKindActivity is an activity feed entry in the system buffer.
+	LineKindActivity LineKind = "activity"
+	// LineKindField is a labeled field; see FieldMarker.
+	LineKindField LineKind = "field"
+)
+
+// Rendering names a representation of the codex output in a tab buffer.
+// Lines not rendered from a codex event (prompts, command output, status
+// lines) read the same in every rendering.
+type Rendering string
+
+const (
+	// RenderingPlain is the text the buffer stores, as shown by the TUI.
+	RenderingPlain Rendering = "plain"
+	// RenderingMarkdown renders codex events as markdown for clients that
+	// format it themselves.
+	RenderingMarkdown Rendering = "markdown"
+	// RenderingRaw renders each codex event as a line of JSON.
+	RenderingRaw Rendering = "raw"
+)
+
+// BufferLine is a typed scrollback line.
+type BufferLine struct {
+	Kind      LineKind  `json:"kind"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp,omitzero"`
+}
+
+// legacyMarkers maps kinds to the prefix used by marker-prefixed strings.
+// Kinds without a marker render as their text.
+var legacyMarkers = []struct {
+	kind   LineKind
+	marker string
+}{
+	{LineKindSeparator, WorkedForMarker},
+	{LineKindAgent, AgentMarker},
+	{LineKindAnswer, FinalAgentMarker},
+	{LineKindReasoning, ReasoningMarker},
+	{LineKindCommand, CommandMarker},
+	{LineKindHelp, HelpMarker},
+	{LineKindAboutVersion, AboutVersionMarker},
+	{LineKindAboutCopyright, AboutCopyrightMarker},
+	{LineKindAboutLink, AboutLinkMarker},
+	{LineKindStderr, StderrMarker},
+	{LineKindField, FieldMarker},
+}
+
+const promptPrefix = "> "
+
+// Line constructs a BufferLine of the given kind without a timestamp.
+func Line(kind LineKind, text string) BufferLine {
+	return BufferLine{Kind: kind, Text: text}
+}
+
+// Legacy renders the line as a marker-prefixed string for clients that
+// predate typed lines.
+func (l BufferLine) Legacy() string {
+	if l.Kind == LineKindPrompt {
+		return promptPrefix + l.Text
+	}
+	for _, entry := range legacyMarkers {
+		if entry.kind == l.Kind {
+			return entry.marker + l.Text
+		}
+	}
+	return l.Text
+}
+
+// ParseBufferLine classifies a marker-prefixed string. It is the inverse of
+// Legacy for lines produced by Legacy.
+func ParseBufferLine(raw string) BufferLine {
+	for _, entry := range legacyMarkers {
+		if strings.HasPrefix(raw, entry.marker) {
+			return BufferLine{Kind: entry.kind, Text: strings.TrimPrefix(raw, entry.marker)}
+		}
+	}
+	switch {
+	case strings.HasPrefix(raw, promptPrefix):
+		return BufferLine{Kind: LineKindPrompt, Text: strings.TrimPrefix(raw, promptPrefix)}
+	case strings.HasPrefix(raw, "error:"),
+		strings.HasPrefix(raw, "command failed:"),
+		strings.HasPrefix(raw, "command error:"):
+		return BufferLine{Kind: LineKindError, Text: raw}
+	}
+	return BufferLine{Kind: LineKindSystem, Text: raw}
+}
+
+// ParseBufferLines classifies each marker-prefixed string.
+func ParseBufferLines(raw []string) []BufferLine {
+	if len(raw) == 0 {
+		return nil
+	}
+	out := make([]BufferLine, 0, len(raw))
+	for _, line := range raw {
+		out = append(out, ParseBufferLine(line))
+	}
+	return out
+}
+
+// LegacyLines renders typed lines as marker-prefixed strings.
+func LegacyLines(lines []BufferLine) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, line.Legacy())
+	}
+	return out
+}
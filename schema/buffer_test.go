@@ -0,0 +1,36 @@
+package schema
+
+import "testing"
+
+func TestBufferLineLegacyRoundTrip(t *testing.T) {
+	cases := []struct {
+		raw  string
+		line BufferLine
+	}{
+		{"> fix the tests", Line(LineKindPrompt, "fix the tests")},
+		{AgentMarker + "done", Line(LineKindAgent, "done")},
+		{FinalAgentMarker + "**all green**", Line(LineKindAnswer, "**all green**")},
+		{ReasoningMarker + "thinking", Line(LineKindReasoning, "thinking")},
+		{CommandMarker + "$ go test ./...", Line(LineKindCommand, "$ go test ./...")},
+		{StderrMarker + "warning", Line(LineKindStderr, "warning")},
+		{WorkedForMarker + "Worked for 3s", Line(LineKindSeparator, "Worked for 3s")},
+		{HelpMarker + "**/help**", Line(LineKindHelp, "**/help**")},
+		{AboutVersionMarker + "v1", Line(LineKindAboutVersion, "v1")},
+		{AboutCopyrightMarker + "(C)", Line(LineKindAboutCopyright, "(C)")},
+		{AboutLinkMarker + "https://example.com", Line(LineKindAboutLink, "https://example.com")},
+		{FieldLine("Branch:    ", "main"), Line(LineKindField, "Branch:    \tmain")},
+		{"error: boom", Line(LineKindError, "error: boom")},
+		{"command failed: exit 1", Line(LineKindError, "command failed: exit 1")},
+		{"tab opened: demo", Line(LineKindSystem, "tab opened: demo")},
+		{"", Line(LineKindSystem, "")},
+	}
+	for _, tc := range cases {
+		got := ParseBufferLine(tc.raw)
+		if got != tc.line {
+			t.Fatalf("parse %q: got %+v want %+v", tc.raw, got, tc.line)
+		}
+		if legacy := tc.line.Legacy(); legacy != tc.raw {
+			t.Fatalf("legacy %+v: got %q want %q", tc.line, legacy, tc.raw)
+		}
+	}
+}
@@ -2,27 +2,199 @@ package schema
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // ServiceConfig defines defaults and limits for the core service.
 type ServiceConfig struct {
-	RepoRoot       string
-	StateDir       string
-	DefaultModel   ModelID
-	AllowedModels  []ModelID
-	DefaultTheme   ThemeName
-	TabNameMax     int
-	TabNameSuffix  string
-	BufferMaxLines int
+	RepoRoot      string
+	StateDir      string
+	DefaultModel  ModelID
+	AllowedModels []ModelID
+	// CodexFlags are appended to every codex exec run, followed by the
+	// CodexFlagsByModel entry of the run's model.
+	CodexFlags        []string
+	CodexFlagsByModel map[ModelID][]string
+	DefaultTheme      ThemeName
+	TabNameMax        int
+	TabNameSuffix     string
+	BufferMaxLines    int
+	// HistoryMax bounds each tab's prompt history.
+	HistoryMax int
+	// GlobalHistoryMax bounds the per-user prompt history across tabs.
+	GlobalHistoryMax int
 	// DisableAuditLogging disables audit trail debug logs for commands.
 	DisableAuditLogging bool
+	// DisableOnboarding hides the first-run checklist new users get in the
+	// system buffer.
+	DisableOnboarding bool
+	// EventQueueSize bounds the event sink dispatch queue.
+	EventQueueSize int
+	// ClosedTabTTL is how long closed tabs can be reopened.
+	ClosedTabTTL time.Duration
+	// ClosedTabsMax bounds the per-user list of recently closed tabs.
+	ClosedTabsMax int
+	// GitSummaryTTL is how long the git branch, remotes and status shown
+	// when a prompt starts are reused for the same tab.
+	GitSummaryTTL time.Duration
+	// GitSummaryRemotesMax bounds the remotes listed in the exec start
+	// summary; the rest are counted on a "+N more" line.
+	GitSummaryRemotesMax int
+	// TimeFormat is the Go layout for times of day shown to users; empty
+	// uses "15:04:05".
+	TimeFormat string
+	// Timezone is the IANA zone times are shown in unless a user picks their
+	// own; empty uses the server's local zone.
+	Timezone string
+	// SummariesEnabled turns on the daily summary job for tabs that opted in
+	// with /summary on.
+	SummariesEnabled bool
+	// SummaryTime is the time of day (15:04, in Timezone) the daily summaries
+	// are written. Each covers the 24 hours before it.
+	SummaryTime string
+	// SummaryModel is the model summaries are written with.
+	SummaryModel ModelID
+	// SummaryInputMax bounds the bytes of tab activity sent to the model per
+	// summary.
+	SummaryInputMax int
+	// SummariesMax bounds the summaries kept per tab.
+	SummariesMax int
+	// UsageWarnBelowPercent lists remaining-percent thresholds of the
+	// account usage windows; crossing one appends a warning to the tab
+	// buffer once per window.
+	UsageWarnBelowPercent []int
+	// UsageBlockBelowPercent refuses prompts while a usage window has less
+	// than this percent remaining; 0 turns blocking off.
+	UsageBlockBelowPercent int
+	// DailyTokenBudget refuses prompts once a user has used this many codex
+	// tokens in the current UTC day; 0 turns budgets off. Users can have
+	// their own budget through ServiceDeps.TokenBudgets.
+	DailyTokenBudget int64
+	// TokenBudgetWarnPercent appends a warning to the tab buffer for prompts
+	// started with at least this share of the budget used; 0 turns warnings
+	// off.
+	TokenBudgetWarnPercent int
+	// BatchParallelism is how many repos of a /batch run at once.
+	BatchParallelism int
+	// PromptMaxBytes refuses prompts larger than this; 0 uses
+	// DefaultPromptMaxBytes.
+	PromptMaxBytes int
+	// PromptWarnBytes appends a warning to the tab buffer for prompts larger
+	// than this that still run; 0 uses DefaultPromptWarnBytes.
+	PromptWarnBytes int
+	// ConcurrentRuns decides what a prompt does while another tab runs
+	// codex in the same repo; empty uses ConcurrentRunsReject.
+	ConcurrentRuns ConcurrentRunsPolicy
+	// StopGracePeriod is how long a stopped run or command gets to exit
+	// after SIGTERM before it is sent SIGKILL.
+	StopGracePeriod time.Duration
+	// StreamReattachAttempts is how often a run whose event stream failed
+	// with a transient runner error is re-attached, with backoff, before
+	// the stream error is reported; 0 turns re-attaching off.
+	StreamReattachAttempts int
 }
 
 // DefaultBufferMaxLines is the default per-tab buffer limit.
 const DefaultBufferMaxLines = 5000
 
+// DefaultSearchMaxLinesPerTab is the default number of buffer lines
+// SearchAll scans per tab.
+const DefaultSearchMaxLinesPerTab = 2000
+
+// DefaultSearchMaxMatches is the default number of matches SearchAll
+// returns.
+const DefaultSearchMaxMatches = 50
+
+// DefaultRepoFileMaxBytes is the default size limit for reading repo files.
+const DefaultRepoFileMaxBytes = 1 << 20
+
+// DefaultRepoArchiveMaxBytes is the default size limit for repo archives.
+const DefaultRepoArchiveMaxBytes = 256 << 20
+
+// DefaultPromptMaxBytes is the default size limit of a prompt.
+const DefaultPromptMaxBytes = 256 << 10
+
+// DefaultPromptWarnBytes is the default prompt size above which a warning is
+// appended to the tab buffer.
+const DefaultPromptWarnBytes = 128 << 10
+
+// ConcurrentRunsPolicy decides what happens to a prompt sent while another
+// tab runs codex in the same repo.
+type ConcurrentRunsPolicy string
+
+const (
+	// ConcurrentRunsReject refuses the prompt with ErrRepoBusy.
+	ConcurrentRunsReject ConcurrentRunsPolicy = "reject"
+	// ConcurrentRunsQueue starts the prompt once the other run ends.
+	ConcurrentRunsQueue ConcurrentRunsPolicy = "queue"
+)
+
+// DefaultHistoryMax is the default per-tab prompt history limit.
+const DefaultHistoryMax = 200
+
+// DefaultGlobalHistoryMax is the default per-user global prompt history limit.
+const DefaultGlobalHistoryMax = 1000
+
+// DefaultEventQueueSize is the default event sink dispatch queue size.
+const DefaultEventQueueSize = 1024
+
+// DefaultClosedTabTTL is the default time closed tabs can be reopened.
+const DefaultClosedTabTTL = 24 * time.Hour
+
+// DefaultShareLinkTTL is how long a /sharelink link stays valid when no TTL
+// is given.
+const DefaultShareLinkTTL = time.Hour
+
+// MaxShareLinkTTL is the longest a /sharelink link can stay valid.
+const MaxShareLinkTTL = 7 * 24 * time.Hour
+
+// DefaultGitSummaryTTL is the default time an exec start git summary is
+// reused.
+const DefaultGitSummaryTTL = 5 * time.Second
+
+// DefaultGitSummaryRemotesMax is the default number of remotes listed in
+// the exec start summary.
+const DefaultGitSummaryRemotesMax = 3
+
+// DefaultStopGracePeriod is the default time between SIGTERM and SIGKILL
+// when a tab is stopped.
+const DefaultStopGracePeriod = 10 * time.Second
+
+// DefaultStreamReattachAttempts is the default number of times a broken run
+// event stream is re-attached.
+const DefaultStreamReattachAttempts = 3
+
+// DefaultClosedTabsMax is the default number of recently closed tabs kept per
+// user.
+const DefaultClosedTabsMax = 10
+
+// DefaultSummaryTime is the default time of day daily summaries are written.
+const DefaultSummaryTime = "23:30"
+
+// DefaultSummaryModel is the default model daily summaries are written with.
+const DefaultSummaryModel ModelID = "gpt-5.1-codex-mini"
+
+// DefaultSummaryInputMax is the default limit of tab activity, in bytes, sent
+// to the model per summary.
+const DefaultSummaryInputMax = 16 << 10
+
+// DefaultSummariesMax is the default number of summaries kept per tab.
+const DefaultSummariesMax = 60
+
+// DefaultBatchParallelism is the default number of repos a batch runs at
+// once.
+const DefaultBatchParallelism = 1
+
+// DefaultTokenBudgetWarnPercent is the default share of the daily token
+// budget used at which prompts get a warning.
+const DefaultTokenBudgetWarnPercent = 80
+
+// SummaryTimeLayout is the layout of ServiceConfig.SummaryTime.
+const SummaryTimeLayout = "15:04"
+
 // NormalizeServiceConfig applies defaults and validates the config.
 func NormalizeServiceConfig(cfg ServiceConfig) (ServiceConfig, error) {
 	if cfg.RepoRoot == "" {
@@ -61,6 +233,55 @@ func NormalizeServiceConfig(cfg ServiceConfig) (ServiceConfig, error) {
 	if cfg.BufferMaxLines <= 0 {
 		cfg.BufferMaxLines = DefaultBufferMaxLines
 	}
+	if cfg.HistoryMax <= 0 {
+		cfg.HistoryMax = DefaultHistoryMax
+	}
+	if cfg.GlobalHistoryMax <= 0 {
+		cfg.GlobalHistoryMax = DefaultGlobalHistoryMax
+	}
+	if cfg.EventQueueSize <= 0 {
+		cfg.EventQueueSize = DefaultEventQueueSize
+	}
+	if cfg.ClosedTabTTL <= 0 {
+		cfg.ClosedTabTTL = DefaultClosedTabTTL
+	}
+	if cfg.ClosedTabsMax <= 0 {
+		cfg.ClosedTabsMax = DefaultClosedTabsMax
+	}
+	if cfg.GitSummaryTTL <= 0 {
+		cfg.GitSummaryTTL = DefaultGitSummaryTTL
+	}
+	if cfg.GitSummaryRemotesMax <= 0 {
+		cfg.GitSummaryRemotesMax = DefaultGitSummaryRemotesMax
+	}
+	if cfg.SummaryTime == "" {
+		cfg.SummaryTime = DefaultSummaryTime
+	}
+	switch cfg.ConcurrentRuns {
+	case "":
+		cfg.ConcurrentRuns = ConcurrentRunsReject
+	case ConcurrentRunsReject, ConcurrentRunsQueue:
+	default:
+		return ServiceConfig{}, fmt.Errorf("invalid concurrent runs policy %q: use reject or queue", cfg.ConcurrentRuns)
+	}
+	if _, err := time.Parse(SummaryTimeLayout, cfg.SummaryTime); err != nil {
+		return ServiceConfig{}, fmt.Errorf("invalid summary time %q: use HH:MM", cfg.SummaryTime)
+	}
+	if cfg.SummaryModel == "" {
+		cfg.SummaryModel = DefaultSummaryModel
+	}
+	if cfg.SummaryInputMax <= 0 {
+		cfg.SummaryInputMax = DefaultSummaryInputMax
+	}
+	if cfg.SummariesMax <= 0 {
+		cfg.SummariesMax = DefaultSummariesMax
+	}
+	if cfg.BatchParallelism <= 0 {
+		cfg.BatchParallelism = DefaultBatchParallelism
+	}
+	if cfg.StopGracePeriod <= 0 {
+		cfg.StopGracePeriod = DefaultStopGracePeriod
+	}
 	if cfg.TabNameMax <= len(cfg.TabNameSuffix) {
 		return ServiceConfig{}, errors.New("tab name max must exceed suffix length")
 	}
@@ -2,31 +2,234 @@ package schema
 
 import "errors"
 
+// Error codes are stable, machine-readable identifiers for errors reported to
+// API clients. Clients match on these, so existing values must not change.
+const (
+	CodeInvalidRequest              = "invalid_request"
+	CodeInvalidCodexAuth            = "invalid_codex_auth"
+	CodeInvalidUser                 = "invalid_user"
+	CodeInvalidRepo                 = "invalid_repo"
+	CodeRepoExists                  = "repo_exists"
+	CodeRepoNotFound                = "repo_not_found"
+	CodeTabNotFound                 = "tab_not_found"
+	CodeClosedTabNotFound           = "closed_tab_not_found"
+	CodeNoTabs                      = "no_tabs"
+	CodeInvalidModel                = "invalid_model"
+	CodeInvalidModelReasoningEffort = "invalid_model_reasoning_effort"
+	CodeEmptyPrompt                 = "empty_prompt"
+	CodeNoPrompt                    = "no_prompt"
+	CodePromptTooLarge              = "prompt_too_large"
+	CodeRunnerUnavailable           = "runner_unavailable"
+	CodeRunnerUnauthorized          = "runner_unauthorized"
+	CodeRunnerTimeout               = "runner_timeout"
+	CodeRunnerCanceled              = "runner_canceled"
+	CodeRunnerContainerStart        = "runner_container_start"
+	CodeRunnerContainerSocket       = "runner_container_socket"
+	CodeRunnerExec                  = "runner_exec"
+	CodeRunnerCommand               = "runner_command"
+	CodeRunnerFailed                = "runner_failed"
+	CodeRunnerEnvironment           = "runner_environment"
+	CodeTabBusy                     = "tab_busy"
+	CodeRepoBusy                    = "repo_busy"
+	CodeGitHostUnreachable          = "git_host_unreachable"
+	CodeUsageLimited                = "usage_limited"
+	CodeTokenBudgetExceeded         = "token_budget_exceeded"
+	CodePermissionDenied            = "permission_denied"
+	CodeInvalidOutputFilter         = "invalid_output_filter"
+	CodeInvalidAlias                = "invalid_alias"
+	CodeAliasNotFound               = "alias_not_found"
+	CodeInvalidTabLabel             = "invalid_tab_label"
+	CodeShareLinkNotFound           = "share_link_not_found"
+	CodeSummariesDisabled           = "summaries_disabled"
+	CodeInvalidTimezone             = "invalid_timezone"
+	CodeInvalidPath                 = "invalid_path"
+	CodeFileNotFound                = "file_not_found"
+	CodeNotDirectory                = "not_directory"
+	CodeIsDirectory                 = "is_directory"
+	CodeFileTooLarge                = "file_too_large"
+	CodeInvalidArchiveFormat        = "invalid_archive_format"
+	CodeBatchRunning                = "batch_running"
+	CodeTabArchived                 = "tab_archived"
+	CodeInvalidSearchQuery          = "invalid_search_query"
+	CodeManagedExternally           = "managed_externally"
+	CodeUserNotFound                = "user_not_found"
+	// CodeUnauthorized is reported for missing or invalid credentials.
+	CodeUnauthorized = "unauthorized"
+	// CodeTooManyRequests is reported when a per-user limit is reached.
+	CodeTooManyRequests = "too_many_requests"
+	// CodeNotFound is reported for missing resources without a more
+	// specific code.
+	CodeNotFound = "not_found"
+	// CodeInternal is reported for errors that carry no code.
+	CodeInternal = "internal"
+)
+
 var (
 	// ErrInvalidRequest indicates a malformed request payload.
-	ErrInvalidRequest = errors.New("invalid request")
+	ErrInvalidRequest = NewCodedError(CodeInvalidRequest, "invalid request")
 	// ErrInvalidCodexAuth indicates the codex auth payload is invalid.
-	ErrInvalidCodexAuth = errors.New("auth.json must be valid JSON")
+	ErrInvalidCodexAuth = NewCodedError(CodeInvalidCodexAuth, "auth.json must be valid JSON")
 	// ErrInvalidUser indicates an invalid user identifier.
-	ErrInvalidUser = errors.New("invalid user")
+	ErrInvalidUser = NewCodedError(CodeInvalidUser, "invalid user")
 	// ErrInvalidRepo indicates an invalid repo identifier.
-	ErrInvalidRepo = errors.New("invalid repo")
+	ErrInvalidRepo = NewCodedError(CodeInvalidRepo, "invalid repo")
 	// ErrRepoExists indicates a repo already exists.
-	ErrRepoExists = errors.New("repo already exists")
+	ErrRepoExists = NewCodedError(CodeRepoExists, "repo already exists")
 	// ErrRepoNotFound indicates a repo could not be found.
-	ErrRepoNotFound = errors.New("repo not found")
+	ErrRepoNotFound = NewCodedError(CodeRepoNotFound, "repo not found")
 	// ErrTabNotFound indicates a requested tab could not be found.
-	ErrTabNotFound = errors.New("tab not found")
+	ErrTabNotFound = NewCodedError(CodeTabNotFound, "tab not found")
+	// ErrNoActiveTab indicates a request that needs a tab was made without
+	// one. It shares the code of ErrTabNotFound so clients handle both alike.
+	ErrNoActiveTab = NewCodedError(CodeTabNotFound, "no active tab — open one with /new <repo>")
+	// ErrClosedTabNotFound indicates no recently closed tab matches a reopen
+	// request.
+	ErrClosedTabNotFound = NewCodedError(CodeClosedTabNotFound, "closed tab not found")
 	// ErrNoTabs indicates no tabs exist for the user.
-	ErrNoTabs = errors.New("no tabs")
+	ErrNoTabs = NewCodedError(CodeNoTabs, "no tabs")
 	// ErrInvalidModel indicates an invalid model identifier.
-	ErrInvalidModel = errors.New("invalid model")
+	ErrInvalidModel = NewCodedError(CodeInvalidModel, "invalid model")
 	// ErrInvalidModelReasoningEffort indicates an invalid reasoning effort value.
-	ErrInvalidModelReasoningEffort = errors.New("invalid model reasoning effort")
+	ErrInvalidModelReasoningEffort = NewCodedError(CodeInvalidModelReasoningEffort, "invalid model reasoning effort")
 	// ErrEmptyPrompt indicates the prompt was empty.
-	ErrEmptyPrompt = errors.New("empty prompt")
+	ErrEmptyPrompt = NewCodedError(CodeEmptyPrompt, "empty prompt")
+	// ErrNoPrompt indicates a tab has not accepted a prompt yet.
+	ErrNoPrompt = NewCodedError(CodeNoPrompt, "no previous prompt in this tab")
+	// ErrPromptTooLarge indicates a prompt exceeds prompts.max_bytes.
+	ErrPromptTooLarge = NewCodedError(CodePromptTooLarge, "prompt too large")
 	// ErrRunnerUnavailable indicates no runner is configured.
-	ErrRunnerUnavailable = errors.New("runner not configured")
+	ErrRunnerUnavailable = NewCodedError(CodeRunnerUnavailable, "runner not configured")
 	// ErrTabBusy indicates the tab is already running.
-	ErrTabBusy = errors.New("tab is busy")
+	ErrTabBusy = NewCodedError(CodeTabBusy, "tab is busy")
+	// ErrRepoBusy indicates another tab runs codex in the same repo.
+	ErrRepoBusy = NewCodedError(CodeRepoBusy, "repo busy")
+	// ErrGitHostUnreachable indicates the runner cannot connect to a git host.
+	ErrGitHostUnreachable = NewCodedError(CodeGitHostUnreachable, "cannot reach git host")
+	// ErrUsageLimited indicates prompts are refused because an account usage
+	// window is below usage.block_below_percent.
+	ErrUsageLimited = NewCodedError(CodeUsageLimited, "usage limit reached")
+	// ErrTokenBudgetExceeded indicates prompts are refused because the user
+	// has used up the daily token budget.
+	ErrTokenBudgetExceeded = NewCodedError(CodeTokenBudgetExceeded, "daily token budget exceeded")
+	// ErrTabAccessDenied indicates a shared tab does not grant the
+	// requested operation.
+	ErrTabAccessDenied = NewCodedError(CodePermissionDenied, "tab access denied")
+	// ErrInvalidOutputFilter indicates an output filter pattern failed to compile.
+	ErrInvalidOutputFilter = NewCodedError(CodeInvalidOutputFilter, "invalid output filter")
+	// ErrInvalidAlias indicates a command alias name or expansion is invalid.
+	ErrInvalidAlias = NewCodedError(CodeInvalidAlias, "invalid alias")
+	// ErrAliasNotFound indicates a command alias does not exist.
+	ErrAliasNotFound = NewCodedError(CodeAliasNotFound, "alias not found")
+	// ErrInvalidTabLabel indicates a malformed tab label, a label the tab
+	// does not have, or too many labels on a tab.
+	ErrInvalidTabLabel = NewCodedError(CodeInvalidTabLabel, "invalid tab label")
+	// ErrShareLinkNotFound indicates an unknown, revoked or expired share
+	// link token.
+	ErrShareLinkNotFound = NewCodedError(CodeShareLinkNotFound, "share link not found or expired")
+	// ErrSummariesDisabled indicates the server does not write tab summaries.
+	ErrSummariesDisabled = NewCodedError(CodeSummariesDisabled, "summaries are disabled on this server")
+	// ErrInvalidTimezone indicates an unknown IANA time zone name.
+	ErrInvalidTimezone = NewCodedError(CodeInvalidTimezone, "invalid time zone")
+	// ErrInvalidPath indicates a repo path is malformed or outside the repo.
+	ErrInvalidPath = NewCodedError(CodeInvalidPath, "invalid path")
+	// ErrFileNotFound indicates a repo path does not exist.
+	ErrFileNotFound = NewCodedError(CodeFileNotFound, "file not found")
+	// ErrNotDirectory indicates a listing was requested for a non-directory.
+	ErrNotDirectory = NewCodedError(CodeNotDirectory, "not a directory")
+	// ErrIsDirectory indicates a read was requested for a directory.
+	ErrIsDirectory = NewCodedError(CodeIsDirectory, "is a directory")
+	// ErrFileTooLarge indicates a file exceeds the read size limit.
+	ErrFileTooLarge = NewCodedError(CodeFileTooLarge, "file too large")
+	// ErrInvalidArchiveFormat indicates an unsupported archive format.
+	ErrInvalidArchiveFormat = NewCodedError(CodeInvalidArchiveFormat, "invalid archive format")
+	// ErrBatchRunning indicates a batch is already running for the user.
+	ErrBatchRunning = NewCodedError(CodeBatchRunning, "a batch is already running")
+	// ErrTabArchived indicates a prompt for an archived tab.
+	ErrTabArchived = NewCodedError(CodeTabArchived, "tab is archived")
+	// ErrInvalidSearchQuery indicates an empty search query or a regular
+	// expression that does not compile.
+	ErrInvalidSearchQuery = NewCodedError(CodeInvalidSearchQuery, "invalid search query")
+	// ErrManagedExternally indicates an account change that the external
+	// auth backend owns, such as a password or TOTP change.
+	ErrManagedExternally = NewCodedError(CodeManagedExternally, "managed externally")
+	// ErrReadOnlyAccount indicates a change requested by an account the
+	// auth backend maps to read-only access.
+	ErrReadOnlyAccount = NewCodedError(CodePermissionDenied, "account is read-only")
+	// ErrAdminOnly indicates a command reserved for admin accounts.
+	ErrAdminOnly = NewCodedError(CodePermissionDenied, "permission denied: admins only")
+	// ErrUserNotFound indicates a request naming a user the server does not
+	// know, such as the guest of a share.
+	ErrUserNotFound = NewCodedError(CodeUserNotFound, "no such user")
 )
+
+// CodedError is an error with a stable machine-readable code. Err, when set,
+// is the underlying cause and is reachable through errors.Is and errors.As.
+type CodedError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+// NewCodedError returns an error with the given code and message.
+func NewCodedError(code, message string) error {
+	return &CodedError{Code: code, Message: message}
+}
+
+// WithCode attaches code to err; the message stays the one of err.
+func WithCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+func (e *CodedError) Error() string {
+	switch {
+	case e == nil:
+		return "error"
+	case e.Message != "" && e.Err != nil:
+		return e.Message + ": " + e.Err.Error()
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return e.Code
+	}
+}
+
+func (e *CodedError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// ErrorCode implements Coder.
+func (e *CodedError) ErrorCode() string {
+	if e == nil {
+		return ""
+	}
+	return e.Code
+}
+
+// Coder is implemented by errors that carry an error code, such as
+// CodedError and classified runner errors.
+type Coder interface {
+	ErrorCode() string
+}
+
+// ErrorCode returns the code of the outermost error in the chain of err that
+// has one, CodeInternal when none does, and "" for a nil err.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var coder Coder
+	if errors.As(err, &coder) {
+		if code := coder.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	return CodeInternal
+}
@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	// Clients match on these strings; changing one is a breaking API change.
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{ErrInvalidRequest, "invalid_request"},
+		{ErrInvalidCodexAuth, "invalid_codex_auth"},
+		{ErrInvalidUser, "invalid_user"},
+		{ErrInvalidRepo, "invalid_repo"},
+		{ErrRepoExists, "repo_exists"},
+		{ErrRepoNotFound, "repo_not_found"},
+		{ErrTabNotFound, "tab_not_found"},
+		{ErrNoActiveTab, "tab_not_found"},
+		{ErrClosedTabNotFound, "closed_tab_not_found"},
+		{ErrNoTabs, "no_tabs"},
+		{ErrInvalidModel, "invalid_model"},
+		{ErrInvalidModelReasoningEffort, "invalid_model_reasoning_effort"},
+		{ErrEmptyPrompt, "empty_prompt"},
+		{ErrNoPrompt, "no_prompt"},
+		{ErrRunnerUnavailable, "runner_unavailable"},
+		{ErrTabBusy, "tab_busy"},
+		{ErrRepoBusy, "repo_busy"},
+		{ErrGitHostUnreachable, "git_host_unreachable"},
+		{ErrTabAccessDenied, "permission_denied"},
+		{ErrInvalidOutputFilter, "invalid_output_filter"},
+		{ErrInvalidAlias, "invalid_alias"},
+		{ErrInvalidTabLabel, "invalid_tab_label"},
+		{ErrAliasNotFound, "alias_not_found"},
+		{ErrShareLinkNotFound, "share_link_not_found"},
+		{ErrSummariesDisabled, "summaries_disabled"},
+		{ErrBatchRunning, "batch_running"},
+		{ErrTokenBudgetExceeded, "token_budget_exceeded"},
+		{ErrManagedExternally, "managed_externally"},
+		{ErrUserNotFound, "user_not_found"},
+		{ErrReadOnlyAccount, "permission_denied"},
+		{ErrInvalidTimezone, "invalid_timezone"},
+		{ErrInvalidPath, "invalid_path"},
+		{ErrFileNotFound, "file_not_found"},
+		{ErrNotDirectory, "not_directory"},
+		{ErrIsDirectory, "is_directory"},
+		{ErrFileTooLarge, "file_too_large"},
+		{ErrInvalidArchiveFormat, "invalid_archive_format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := ErrorCode(tt.err); got != tt.code {
+				t.Fatalf("ErrorCode(%v) = %q, want %q", tt.err, got, tt.code)
+			}
+			wrapped := fmt.Errorf("%w: detail", tt.err)
+			if got := ErrorCode(wrapped); got != tt.code {
+				t.Fatalf("ErrorCode(wrapped %v) = %q, want %q", tt.err, got, tt.code)
+			}
+			if !errors.Is(wrapped, tt.err) {
+				t.Fatalf("errors.Is lost %v through wrapping", tt.err)
+			}
+		})
+	}
+}
+
+func TestErrorCodeFallbacks(t *testing.T) {
+	if got := ErrorCode(nil); got != "" {
+		t.Fatalf("ErrorCode(nil) = %q, want empty", got)
+	}
+	if got := ErrorCode(errors.New("boom")); got != CodeInternal {
+		t.Fatalf("ErrorCode(uncoded) = %q, want %q", got, CodeInternal)
+	}
+}
+
+func TestWithCode(t *testing.T) {
+	cause := errors.New("runner not available")
+	err := WithCode(CodeRunnerUnavailable, cause)
+	if err.Error() != "runner not available" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+	if ErrorCode(err) != CodeRunnerUnavailable {
+		t.Fatalf("unexpected code %q", ErrorCode(err))
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected cause to be reachable")
+	}
+	if WithCode(CodeInternal, nil) != nil {
+		t.Fatalf("expected nil for nil error")
+	}
+	// The outermost code wins.
+	outer := WithCode(CodeInvalidRequest, fmt.Errorf("parse: %w", ErrInvalidRepo))
+	if ErrorCode(outer) != CodeInvalidRequest || !errors.Is(outer, ErrInvalidRepo) {
+		t.Fatalf("unexpected code %q for nested coded error", ErrorCode(outer))
+	}
+}
@@ -0,0 +1,33 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TabLabel is a short tag set on a tab with /tag, used to group and filter
+// tabs.
+type TabLabel string
+
+// Tab label limits.
+const (
+	// MaxTabLabelLen bounds the length of a tab label.
+	MaxTabLabelLen = 16
+	// MaxTabLabels bounds the number of labels per tab.
+	MaxTabLabels = 5
+)
+
+// NormalizeTabLabel validates a tab label. Labels are lowercase letters,
+// digits, '-' and '_'; a leading '#' is dropped.
+func NormalizeTabLabel(value string) (TabLabel, error) {
+	label := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "#"))
+	if label == "" || len(label) > MaxTabLabelLen {
+		return "", fmt.Errorf("%w: labels must be 1-%d characters", ErrInvalidTabLabel, MaxTabLabelLen)
+	}
+	for _, r := range label {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return "", fmt.Errorf("%w: labels may only contain a-z, 0-9, '-' and '_'", ErrInvalidTabLabel)
+		}
+	}
+	return TabLabel(label), nil
+}
@@ -1,11 +1,19 @@
 package schema
 
+import (
+	"fmt"
+	"strings"
+)
+
 // StderrMarker prefixes lines that originated from stderr.
 const StderrMarker = "\x1f"
 
 // AgentMarker prefixes agent message lines (markdown-enabled).
 const AgentMarker = "\x1c"
 
+// FinalAgentMarker prefixes lines of the final agent message in a turn (markdown-enabled).
+const FinalAgentMarker = "\x15"
+
 // ReasoningMarker prefixes reasoning lines (markdown-enabled).
 const ReasoningMarker = "\x1d"
 
@@ -26,3 +34,40 @@ const AboutCopyrightMarker = "\x18"
 
 // AboutLinkMarker prefixes the link line in /version output.
 const AboutLinkMarker = "\x19"
+
+// FieldMarker prefixes a labeled field line, such as the exec start summary.
+// The text is the label, a tab, then the value. Producers pad labels in a
+// block to a common width, and continuation values carry a blank label of
+// that width. Renderers wrap the value with a hanging indent at the value
+// column, so alignment holds at any terminal width.
+const FieldMarker = "\x14"
+
+// FieldLine returns a marker-prefixed field line.
+func FieldLine(label, value string) string {
+	return FieldMarker + label + "\t" + value
+}
+
+// SplitField splits the text of a field line, without its marker, into the
+// padded label and the value.
+func SplitField(text string) (label, value string) {
+	label, value, _ = strings.Cut(text, "\t")
+	return label, value
+}
+
+// LabelWidth returns the column width that fits every label with its colon,
+// for aligning a block of labeled lines.
+func LabelWidth(labels ...string) int {
+	width := 0
+	for _, label := range labels {
+		if label != "" {
+			width = max(width, len(label)+1)
+		}
+	}
+	return width
+}
+
+// PadLabel returns label with a colon, padded to width. A width of zero or
+// less fits the label alone.
+func PadLabel(label string, width int) string {
+	return fmt.Sprintf("%-*s", max(width, len(label)+1), label+":")
+}
@@ -1,5 +1,10 @@
 package schema
 
+import (
+	"io"
+	"time"
+)
+
 // Tab lifecycle.
 
 // CreateTabRequest describes a request to create a tab.
@@ -9,6 +14,8 @@ type CreateTabRequest struct {
 	RepoURL    string
 	CreateRepo bool
 	TabName    TabName
+	// Ephemeral tabs never persist their buffer, history or prompts.
+	Ephemeral bool
 }
 
 // CreateTabResponse reports the created tab and repo status.
@@ -28,9 +35,35 @@ type CloseTabResponse struct {
 	Tab TabSnapshot
 }
 
+// ReopenTabRequest describes a request to restore a recently closed tab.
+// Index counts from the most recently closed tab, starting at 1; zero
+// reopens the most recent one.
+type ReopenTabRequest struct {
+	UserID UserID
+	Index  int
+}
+
+// ReopenTabResponse reports the restored tab.
+type ReopenTabResponse struct {
+	Tab TabSnapshot
+}
+
+// ListClosedTabsRequest describes a request to list recently closed tabs.
+type ListClosedTabsRequest struct {
+	UserID UserID
+}
+
+// ListClosedTabsResponse lists recently closed tabs, most recent first.
+type ListClosedTabsResponse struct {
+	Tabs []ClosedTabInfo
+}
+
 // ListTabsRequest describes a request to list tabs.
 type ListTabsRequest struct {
 	UserID UserID
+	// IncludeArchived lists archived tabs too; they are left out by
+	// default.
+	IncludeArchived bool
 }
 
 // ListTabsResponse reports tabs and active context.
@@ -39,6 +72,10 @@ type ListTabsResponse struct {
 	ActiveTab  TabID
 	ActiveRepo RepoRef
 	Theme      ThemeName
+	// TimeFormat and Timezone describe how times are shown to the user,
+	// after applying their /tz choice.
+	TimeFormat string
+	Timezone   string
 }
 
 // ActivateTabRequest describes a request to activate a tab.
@@ -52,6 +89,78 @@ type ActivateTabResponse struct {
 	Tab TabSnapshot
 }
 
+// ShareTabRequest describes a request by the tab owner to grant Guest
+// access to the tab. Access defaults to ShareAccessRead.
+type ShareTabRequest struct {
+	UserID UserID
+	TabID  TabID
+	Guest  UserID
+	Access ShareAccess
+}
+
+// ShareTabResponse reports the tab's grants after the change.
+type ShareTabResponse struct {
+	Shares []TabShare
+}
+
+// UnshareTabRequest describes a request by the tab owner to revoke Guest's
+// access to the tab.
+type UnshareTabRequest struct {
+	UserID UserID
+	TabID  TabID
+	Guest  UserID
+}
+
+// UnshareTabResponse reports the tab's grants after the change.
+type UnshareTabResponse struct {
+	Shares []TabShare
+}
+
+// CreateShareLinkRequest describes a request by the tab owner for a link
+// that lets anyone holding it watch the tab read-only. TTL defaults to
+// DefaultShareLinkTTL and is capped at MaxShareLinkTTL.
+type CreateShareLinkRequest struct {
+	UserID UserID
+	TabID  TabID
+	TTL    time.Duration
+}
+
+// CreateShareLinkResponse returns the link token. The token is the only
+// credential of the link and is not stored by the server.
+type CreateShareLinkResponse struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// RevokeShareLinksRequest describes a request by the tab owner to revoke
+// every link to the tab.
+type RevokeShareLinksRequest struct {
+	UserID UserID
+	TabID  TabID
+}
+
+// RevokeShareLinksResponse reports how many links were revoked.
+type RevokeShareLinksResponse struct {
+	Revoked int
+}
+
+// ViewShareLinkRequest resolves a link token to the tab it shows. Limit is
+// the number of buffer lines to return from the bottom.
+type ViewShareLinkRequest struct {
+	Token string
+	Limit int
+}
+
+// ViewShareLinkResponse is the tab as seen through a link. Owner is for the
+// server to follow the tab's events and must not be shown to the viewer.
+type ViewShareLinkResponse struct {
+	Owner     UserID
+	TabID     TabID
+	TabName   TabName
+	Buffer    BufferSnapshot
+	ExpiresAt time.Time
+}
+
 // Repo operations.
 
 // SwitchRepoRequest describes a request to switch repos.
@@ -69,11 +178,25 @@ type SwitchRepoResponse struct {
 // ListReposRequest describes a request to list repos.
 type ListReposRequest struct {
 	UserID UserID
+	// Sizes asks for sizes on disk even when the user has more repos than
+	// the service measures by default.
+	Sizes bool
 }
 
 // ListReposResponse reports available repos.
 type ListReposResponse struct {
 	Repos []RepoRef
+	// Details describes Repos in the same order.
+	Details []RepoDetails
+}
+
+// RepoDetails is host-side metadata about a repo.
+type RepoDetails struct {
+	// ModifiedAt is when the repo's git metadata last changed.
+	ModifiedAt time.Time
+	// SizeBytes is the size of the repo on disk, or -1 when it was not
+	// measured.
+	SizeBytes int64
 }
 
 // Prompt and schema.
@@ -103,6 +226,9 @@ type SetModelRequest struct {
 // SetModelResponse reports the updated tab snapshot.
 type SetModelResponse struct {
 	Tab TabSnapshot
+	// Changed is false when the tab already used the model and reasoning
+	// effort.
+	Changed bool
 }
 
 // SetThemeRequest describes a request to set the UI theme.
@@ -116,12 +242,31 @@ type SetThemeResponse struct {
 	Theme ThemeName
 }
 
+// SetTimezoneRequest describes a request to set the zone times are shown in.
+// An empty Timezone returns to the server default.
+type SetTimezoneRequest struct {
+	UserID   UserID
+	Timezone string
+}
+
+// SetTimezoneResponse reports the zone now in effect.
+type SetTimezoneResponse struct {
+	Timezone string
+	// Default reports that the server default zone is in effect.
+	Default bool
+}
+
 // Stop session.
 
 // StopSessionRequest describes a request to stop a running session.
 type StopSessionRequest struct {
 	UserID UserID
 	TabID  TabID
+	// GracePeriod overrides how long processes get to exit after SIGTERM
+	// before SIGKILL; zero uses the configured period.
+	GracePeriod time.Duration
+	// Kill sends SIGKILL right away, skipping SIGTERM.
+	Kill bool
 }
 
 // StopSessionResponse reports the updated tab snapshot.
@@ -147,6 +292,11 @@ type GetBufferRequest struct {
 	UserID UserID
 	TabID  TabID
 	Limit  int
+	// Structured also returns the typed lines in BufferSnapshot.Structured.
+	Structured bool
+	// Rendering selects how codex output is rendered; empty means
+	// RenderingPlain.
+	Rendering Rendering
 }
 
 // GetBufferResponse reports the buffer snapshot.
@@ -160,6 +310,9 @@ type ScrollBufferRequest struct {
 	TabID  TabID
 	Delta  int
 	Limit  int
+	// ToLine, when set, scrolls to the buffer line with that number (see
+	// SearchMatch.Line) instead of by Delta, placing it mid-view.
+	ToLine int64
 }
 
 // ScrollBufferResponse reports the buffer snapshot after scrolling.
@@ -167,13 +320,71 @@ type ScrollBufferResponse struct {
 	Buffer BufferSnapshot
 }
 
+// SearchAllRequest describes a search of the buffers and prompt histories
+// of every tab of a user, archived and shared tabs included.
+type SearchAllRequest struct {
+	UserID UserID
+	Query  string
+	// CaseSensitive matches case exactly; by default case is ignored.
+	CaseSensitive bool
+	// Regexp treats Query as a regular expression.
+	Regexp bool
+	// MaxLinesPerTab caps the buffer lines scanned per tab, newest first.
+	// Zero means DefaultSearchMaxLinesPerTab.
+	MaxLinesPerTab int
+	// MaxMatches caps the matches returned. Zero means
+	// DefaultSearchMaxMatches.
+	MaxMatches int
+}
+
+// SearchSource names where a search match was found.
+type SearchSource string
+
+const (
+	// SearchSourceBuffer is a line of the tab's scrollback.
+	SearchSourceBuffer SearchSource = "buffer"
+	// SearchSourceHistory is a prompt in the tab's history.
+	SearchSourceHistory SearchSource = "history"
+)
+
+// SearchMatch is one matching buffer line or history entry.
+type SearchMatch struct {
+	TabID   TabID
+	TabName TabName
+	Source  SearchSource
+	// Line numbers a buffer line among all lines ever appended to the tab,
+	// from 1, so it stays valid while output arrives; pass it as
+	// ScrollBufferRequest.ToLine. For history matches it is the entry's
+	// position from the oldest, from 1.
+	Line int64
+	Kind LineKind `json:",omitempty"`
+	// Excerpt is the matching text, shortened around the match.
+	Excerpt   string
+	Timestamp time.Time `json:",omitzero"`
+}
+
+// SearchAllResponse lists matches grouped by tab in tab order, newest
+// first within a tab.
+type SearchAllResponse struct {
+	Matches []SearchMatch
+	// Truncated is set when MaxMatches left matches out.
+	Truncated bool
+}
+
 // Output append.
 
 // AppendOutputRequest describes a request to append output lines to a tab.
 type AppendOutputRequest struct {
 	UserID UserID
 	TabID  TabID
-	Lines  []string
+	// Lines are marker-prefixed strings, classified on append.
+	Lines []string
+	// Structured lines are appended after Lines.
+	Structured []BufferLine
+	// Transient lines, such as status notices, are delivered and shown by
+	// GetBuffer like others but are not persisted, do not count against the
+	// buffer line limit or TotalLines, and are gone after a restart.
+	Transient bool
 }
 
 // AppendOutputResponse reports the updated tab snapshot.
@@ -186,7 +397,12 @@ type AppendOutputResponse struct {
 // AppendSystemOutputRequest describes a request to append system output lines.
 type AppendSystemOutputRequest struct {
 	UserID UserID
-	Lines  []string
+	// Lines are marker-prefixed strings, classified on append.
+	Lines []string
+	// Structured lines are appended after Lines.
+	Structured []BufferLine
+	// Transient is as in AppendOutputRequest.
+	Transient bool
 }
 
 // AppendSystemOutputResponse reports completion of the append.
@@ -196,6 +412,8 @@ type AppendSystemOutputResponse struct{}
 type GetSystemBufferRequest struct {
 	UserID UserID
 	Limit  int
+	// Structured also returns the typed lines in SystemBufferSnapshot.Structured.
+	Structured bool
 }
 
 // GetSystemBufferResponse reports the system buffer snapshot.
@@ -203,29 +421,225 @@ type GetSystemBufferResponse struct {
 	Buffer SystemBufferSnapshot
 }
 
+// Activity feed.
+
+// RecordActivityRequest describes a request to add an entry to the user's
+// activity feed in the system buffer. Detail is optional context appended
+// to the kind's label.
+type RecordActivityRequest struct {
+	UserID UserID
+	Kind   ActivityKind
+	Detail string
+}
+
+// RecordActivityResponse reports the appended line.
+type RecordActivityResponse struct {
+	Line BufferLine
+}
+
+// ListActivityRequest describes a request for the most recent activity
+// entries. A Limit of zero or less returns all entries still in the system
+// buffer.
+type ListActivityRequest struct {
+	UserID UserID
+	Limit  int
+}
+
+// ListActivityResponse lists activity entries, oldest first.
+type ListActivityResponse struct {
+	Entries []BufferLine
+}
+
+// Command aliases.
+
+// ListAliasesRequest describes a request for the user's command aliases.
+type ListAliasesRequest struct {
+	UserID UserID
+}
+
+// ListAliasesResponse lists command aliases sorted by name.
+type ListAliasesResponse struct {
+	Aliases []CommandAlias
+}
+
+// SetAliasRequest describes a request to create or replace a command alias.
+type SetAliasRequest struct {
+	UserID UserID
+	Alias  CommandAlias
+}
+
+// SetAliasResponse reports the updated aliases.
+type SetAliasResponse struct {
+	Aliases []CommandAlias
+}
+
+// RemoveAliasRequest describes a request to remove a command alias by name.
+type RemoveAliasRequest struct {
+	UserID UserID
+	Name   string
+}
+
+// RemoveAliasResponse reports the removed alias and the remaining ones.
+type RemoveAliasResponse struct {
+	Removed CommandAlias
+	Aliases []CommandAlias
+}
+
 // History.
 
-// GetHistoryRequest describes a request to fetch prompt history.
+// GetHistoryRequest describes a request to fetch prompt history. An empty
+// Scope follows the session preference and defaults to the tab history.
 type GetHistoryRequest struct {
 	UserID UserID
 	TabID  TabID
+	Scope  HistoryScope
 }
 
-// GetHistoryResponse reports the prompt history.
+// GetHistoryResponse reports the prompt history, oldest first.
 type GetHistoryResponse struct {
 	Entries []string
+	Items   []HistoryEntry
+	Scope   HistoryScope
 }
 
-// AppendHistoryRequest describes a request to append a history entry.
+// AppendHistoryRequest describes a request to append a history entry. The
+// entry is recorded in both the tab and the global history; Scope selects
+// which of them the response reports.
 type AppendHistoryRequest struct {
 	UserID UserID
 	TabID  TabID
 	Entry  string
+	Scope  HistoryScope
 }
 
 // AppendHistoryResponse reports the updated history.
 type AppendHistoryResponse struct {
 	Entries []string
+	Scope   HistoryScope
+}
+
+// GetLastPromptRequest describes a request for the most recent prompt a tab
+// accepted.
+type GetLastPromptRequest struct {
+	UserID UserID
+	TabID  TabID
+}
+
+// GetLastPromptResponse reports the most recent prompt of a tab.
+type GetLastPromptResponse struct {
+	Prompt string
+}
+
+// Output filters.
+
+// ListOutputFiltersRequest describes a request to list a tab's output filters.
+type ListOutputFiltersRequest struct {
+	UserID UserID
+	TabID  TabID
+}
+
+// ListOutputFiltersResponse reports a tab's output filter patterns in order.
+type ListOutputFiltersResponse struct {
+	Patterns []string
+}
+
+// AddOutputFilterRequest describes a request to suppress command output lines
+// matching Pattern, a Go regular expression.
+type AddOutputFilterRequest struct {
+	UserID  UserID
+	TabID   TabID
+	Pattern string
+}
+
+// AddOutputFilterResponse reports the updated output filter patterns.
+type AddOutputFilterResponse struct {
+	Patterns []string
+}
+
+// RemoveOutputFilterRequest describes a request to remove an output filter by
+// its 1-based position in the list.
+type RemoveOutputFilterRequest struct {
+	UserID UserID
+	TabID  TabID
+	Index  int
+}
+
+// RemoveOutputFilterResponse reports the removed pattern and the remaining ones.
+type RemoveOutputFilterResponse struct {
+	Removed  string
+	Patterns []string
+}
+
+// Repo files.
+
+// ListRepoFilesRequest describes a request to list a directory in a tab's repo.
+// Path is relative to the repo root; empty lists the root.
+type ListRepoFilesRequest struct {
+	UserID UserID
+	TabID  TabID
+	Path   string
+}
+
+// ListRepoFilesResponse reports the entries of the listed directory.
+type ListRepoFilesResponse struct {
+	Path    string
+	Entries []RepoFileEntry
+}
+
+// ReadRepoFileRequest describes a request to read a file in a tab's repo.
+// MaxBytes defaults to DefaultRepoFileMaxBytes.
+type ReadRepoFileRequest struct {
+	UserID   UserID
+	TabID    TabID
+	Path     string
+	MaxBytes int64
+}
+
+// ReadRepoFileResponse reports the file contents.
+type ReadRepoFileResponse struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Content []byte
+}
+
+// WriteRepoArchiveRequest describes a request to archive a path in a tab's
+// repo. The archive is built from HEAD unless Worktree is set, in which case
+// the working tree (uncommitted changes included) is archived. Format
+// defaults to ArchiveFormatTarGz and MaxBytes to DefaultRepoArchiveMaxBytes.
+// The archive is streamed to Writer.
+type WriteRepoArchiveRequest struct {
+	UserID   UserID
+	TabID    TabID
+	Path     string
+	Format   ArchiveFormat
+	Worktree bool
+	MaxBytes int64
+	Writer   io.Writer
+}
+
+// WriteRepoArchiveResponse reports the written archive.
+type WriteRepoArchiveResponse struct {
+	// Name is a suggested download file name.
+	Name   string
+	Format ArchiveFormat
+	Size   int64
+}
+
+// Tab status.
+
+// GetTabStatusRequest describes a request for the status report of a tab.
+type GetTabStatusRequest struct {
+	UserID UserID
+	TabID  TabID
+	// Refresh reads the account usage through the runner instead of the
+	// cache.
+	Refresh bool
+}
+
+// GetTabStatusResponse reports the status of a tab.
+type GetTabStatusResponse struct {
+	Status TabStatusInfo
 }
 
 // Codex auth.
@@ -239,6 +653,45 @@ type SaveCodexAuthRequest struct {
 // SaveCodexAuthResponse reports completion of the write.
 type SaveCodexAuthResponse struct{}
 
+// Onboarding.
+
+// OnboardingStep names an item of the first-run checklist.
+type OnboardingStep string
+
+const (
+	// OnboardingCodexAuth is done once the user has a codex auth.json.
+	OnboardingCodexAuth OnboardingStep = "codexauth"
+	// OnboardingPubKey is done once the user has shown their git public key
+	// with /pubkey, to add it to their git host.
+	OnboardingPubKey OnboardingStep = "pubkey"
+	// OnboardingFirstTab is done once the user has opened a tab.
+	OnboardingFirstTab OnboardingStep = "new"
+)
+
+// OnboardingSteps lists the checklist steps in the order they are shown.
+var OnboardingSteps = []OnboardingStep{OnboardingCodexAuth, OnboardingPubKey, OnboardingFirstTab}
+
+// OnboardingStatus reports a user's progress through the checklist. Active
+// is false once every step is done, the user turned it off, or the user
+// started before onboarding existed.
+type OnboardingStatus struct {
+	Active bool
+	Done   []OnboardingStep
+}
+
+// UpdateOnboardingRequest marks a checklist step done or turns the
+// checklist off. An empty request only reports the status.
+type UpdateOnboardingRequest struct {
+	UserID   UserID
+	Complete OnboardingStep
+	Off      bool
+}
+
+// UpdateOnboardingResponse reports the status after the update.
+type UpdateOnboardingResponse struct {
+	Onboarding OnboardingStatus
+}
+
 // Tab usage.
 
 // GetTabUsageRequest describes a request to fetch latest usage for a tab.
@@ -251,3 +704,139 @@ type GetTabUsageRequest struct {
 type GetTabUsageResponse struct {
 	Usage *TurnUsage
 }
+
+// Tab summaries.
+
+// SetTabSummariesRequest turns the daily summary of a tab on or off.
+type SetTabSummariesRequest struct {
+	UserID  UserID
+	TabID   TabID
+	Enabled bool
+}
+
+// SetTabSummariesResponse reports the tab's summary setting.
+type SetTabSummariesResponse struct {
+	Enabled bool
+	// Time is the time of day summaries are written.
+	Time string
+}
+
+// SetTabArchivedRequest archives a tab or restores an archived one.
+type SetTabArchivedRequest struct {
+	UserID   UserID
+	TabID    TabID
+	Archived bool
+}
+
+// SetTabArchivedResponse returns the updated tab.
+type SetTabArchivedResponse struct {
+	Tab TabSnapshot
+}
+
+// SetTabAutoCommitRequest turns automatic commits after successful runs on
+// or off for a tab.
+type SetTabAutoCommitRequest struct {
+	UserID  UserID
+	TabID   TabID
+	Enabled bool
+}
+
+// SetTabAutoCommitResponse returns the updated tab.
+type SetTabAutoCommitResponse struct {
+	Tab TabSnapshot
+}
+
+// ListTabSummariesRequest describes a request for the summaries of a tab.
+type ListTabSummariesRequest struct {
+	UserID UserID
+	TabID  TabID
+}
+
+// ListTabSummariesResponse lists a tab's summaries, oldest first.
+type ListTabSummariesResponse struct {
+	// Available reports whether the server writes summaries at all.
+	Available bool
+	Enabled   bool
+	Time      string
+	Summaries []TabSummary
+}
+
+// Tab tools.
+
+// SetTabToolRequest turns a codex tool on or off for a tab.
+type SetTabToolRequest struct {
+	UserID  UserID
+	TabID   TabID
+	Tool    ToolName
+	Enabled bool
+}
+
+// SetTabToolResponse returns the updated tab.
+type SetTabToolResponse struct {
+	Tab TabSnapshot
+}
+
+// Tab labels.
+
+// SetTabLabelRequest adds a label to a tab, or removes it with Remove.
+type SetTabLabelRequest struct {
+	UserID UserID
+	TabID  TabID
+	Label  TabLabel
+	Remove bool
+}
+
+// SetTabLabelResponse returns the updated tab.
+type SetTabLabelResponse struct {
+	Tab TabSnapshot
+}
+
+// Batches.
+
+// UpdateBatchReposRequest adds repos to or removes repos from the user's
+// batch selection.
+type UpdateBatchReposRequest struct {
+	UserID UserID
+	Add    []RepoName
+	Remove []RepoName
+}
+
+// UpdateBatchReposResponse lists the selected repos.
+type UpdateBatchReposResponse struct {
+	Repos []RepoName
+}
+
+// GetBatchRequest describes a request for the user's batch selection and
+// latest batch.
+type GetBatchRequest struct {
+	UserID UserID
+}
+
+// GetBatchResponse reports the selected repos and the latest batch, if any.
+type GetBatchResponse struct {
+	Repos []RepoName
+	Batch *BatchStatus
+	// Parallelism is how many repos run at once.
+	Parallelism int
+}
+
+// StartBatchRequest sends Prompt to every selected repo.
+type StartBatchRequest struct {
+	UserID UserID
+	Prompt string
+}
+
+// StartBatchResponse returns the started batch.
+type StartBatchResponse struct {
+	Batch BatchStatus
+}
+
+// StopBatchRequest cancels the pending repos of the running batch.
+type StopBatchRequest struct {
+	UserID UserID
+}
+
+// StopBatchResponse returns the stopped batch.
+type StopBatchResponse struct {
+	Batch BatchStatus
+}
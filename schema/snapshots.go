@@ -1,5 +1,7 @@
 package schema
 
+import "time"
+
 // TabStatus describes the current state of a tab session.
 type TabStatus string
 
@@ -8,10 +10,19 @@ const (
 	TabStatusIdle TabStatus = "idle"
 	// TabStatusRunning indicates a tab is processing a prompt or command.
 	TabStatusRunning TabStatus = "running"
+	// TabStatusStopping indicates a tab's run has been signalled to stop and
+	// has not exited yet.
+	TabStatusStopping TabStatus = "stopping"
 	// TabStatusStopped indicates a tab has been stopped.
 	TabStatusStopped TabStatus = "stopped"
 )
 
+// Busy reports whether a tab with this status still has a run, including
+// one that is stopping.
+func (s TabStatus) Busy() bool {
+	return s == TabStatusRunning || s == TabStatusStopping
+}
+
 // TabSnapshot is a read-only view of tab state for transports.
 type TabSnapshot struct {
 	ID                   TabID
@@ -22,12 +33,71 @@ type TabSnapshot struct {
 	SessionID            SessionID
 	Status               TabStatus
 	Active               bool
+	// Owner and Access are set when the tab is shared with the viewer by
+	// another user.
+	Owner  UserID      `json:",omitempty"`
+	Access ShareAccess `json:",omitempty"`
+	// RunID identifies the running or most recent prompt of the tab.
+	RunID RunID `json:",omitempty"`
+	// Ephemeral is set for tabs whose buffer, history and prompts are
+	// never persisted.
+	Ephemeral bool `json:",omitempty"`
+	// Branch is the git branch collected when the last prompt started.
+	Branch string `json:",omitempty"`
+	// Tools records the tools turned on or off with /tools. Tools not
+	// listed use the codex default.
+	Tools map[ToolName]bool `json:",omitempty"`
+	// RepoURL is the URL the repo was cloned from when the tab was opened
+	// with one.
+	RepoURL string `json:",omitempty"`
+	// Labels are the tab's /tag labels, sorted.
+	Labels []TabLabel `json:",omitempty"`
+	// AutoCommit is set when /autocommit commits the changes of every
+	// successful run.
+	AutoCommit bool `json:",omitempty"`
+	// Archived tabs are hidden from the tab list but keep their buffer,
+	// history and session until restored.
+	Archived bool `json:",omitempty"`
+	// LastActivityAt is when the tab last produced output or started a run.
+	LastActivityAt time.Time `json:",omitzero"`
+	// Progress describes what a running prompt is doing, such as
+	// "running: go test ./..." or "thinking…". It is empty while idle and
+	// is not persisted.
+	Progress string `json:",omitempty"`
+	// LastError is the runner failure of the tab's latest prompt. It is
+	// cleared when a prompt starts and is not persisted.
+	LastError *TabError `json:",omitempty"`
+	// RenderErrors counts the events of the latest prompt that could not
+	// be rendered and were shown raw. It is reset when a prompt starts and
+	// is not persisted.
+	RenderErrors int `json:",omitempty"`
+}
+
+// TabError describes a runner failure shown on a tab until the next prompt
+// starts.
+type TabError struct {
+	// Kind is the schema error code of the failure, such as
+	// CodeRunnerUnavailable.
+	Kind    string
+	Message string
+	Time    time.Time
+}
+
+// ClosedTabInfo describes a recently closed tab that can be reopened until
+// ExpiresAt.
+type ClosedTabInfo struct {
+	Tab       TabSnapshot
+	ClosedAt  time.Time
+	ExpiresAt time.Time
 }
 
 // BufferSnapshot represents the current scrollback view.
 type BufferSnapshot struct {
-	TabID        TabID
-	Lines        []string
+	TabID TabID
+	// Lines are the visible lines as marker-prefixed strings.
+	Lines []string
+	// Structured holds the visible lines as typed lines when requested.
+	Structured   []BufferLine `json:",omitempty"`
 	TotalLines   int
 	ScrollOffset int
 	AtBottom     bool
@@ -35,8 +105,75 @@ type BufferSnapshot struct {
 
 // SystemBufferSnapshot represents output not tied to a tab.
 type SystemBufferSnapshot struct {
-	Lines        []string
+	Lines []string
+	// Structured holds the visible lines as typed lines when requested.
+	Structured   []BufferLine `json:",omitempty"`
 	TotalLines   int
 	ScrollOffset int
 	AtBottom     bool
 }
+
+// HistoryEntry is a prompt history entry. Time is zero for entries recorded
+// before history was timestamped.
+type HistoryEntry struct {
+	Text string
+	Time time.Time
+}
+
+// TabSummary is a daily summary of the activity in a tab.
+type TabSummary struct {
+	// Date is the day the summary was written, as 2006-01-02 in the server
+	// time zone. It covers the 24 hours before CreatedAt.
+	Date      string    `json:"date"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	// Usage is the token usage of writing the summary, when codex reported
+	// it.
+	Usage *TurnUsage `json:"usage,omitempty"`
+}
+
+// RepoFileEntry describes a directory entry in a repo.
+type RepoFileEntry struct {
+	Name string `json:"name"`
+	// Type is one of "file", "dir", "symlink", or "other".
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	// Mode is the permission string, e.g. "-rw-r--r--".
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// TabStatusInfo is the machine-readable form of the /status report for a tab.
+type TabStatusInfo struct {
+	TabID                TabID                `json:"tab_id"`
+	Model                ModelID              `json:"model"`
+	ModelReasoningEffort ModelReasoningEffort `json:"model_reasoning_effort,omitempty"`
+	// Directory is the repo path as seen by the runner, or the host path
+	// when the runner is unavailable.
+	Directory  string    `json:"directory"`
+	SessionID  SessionID `json:"session_id,omitempty"`
+	TokensUsed int       `json:"tokens_used"`
+	// Usage is nil when the runner cannot report account usage.
+	Usage *AccountUsageStatus `json:"usage,omitempty"`
+	// Ephemeral is set when the tab's buffer and history are not persisted.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+}
+
+// AccountUsageStatus reports rate limit usage of the account a tab runs as.
+type AccountUsageStatus struct {
+	ChatGPT bool `json:"chatgpt"`
+	// Error is set when the usage lookup failed.
+	Error     string             `json:"error,omitempty"`
+	Primary   *UsageWindowStatus `json:"primary,omitempty"`
+	Secondary *UsageWindowStatus `json:"secondary,omitempty"`
+	// FetchedAt is when the usage was read from the runner; lookups are
+	// cached by the service.
+	FetchedAt time.Time `json:"fetched_at,omitzero"`
+}
+
+// UsageWindowStatus reports usage of a single rate limit window.
+type UsageWindowStatus struct {
+	UsedPercent   float64   `json:"used_percent"`
+	WindowSeconds int64     `json:"window_seconds,omitempty"`
+	ResetAt       time.Time `json:"reset_at,omitzero"`
+}
@@ -1,6 +1,10 @@
 package schema
 
-import "strings"
+import (
+	"slices"
+	"strings"
+	"sync"
+)
 
 // DefaultTheme is the default UI theme name.
 const DefaultTheme ThemeName = "outrun"
@@ -11,18 +15,67 @@ var themeNames = []ThemeName{
 	"tokyo-midnight",
 }
 
-// AvailableThemes returns the supported theme names.
+var (
+	customThemesMu sync.RWMutex
+	customThemes   []ThemeName
+)
+
+// AvailableThemes returns the supported theme names: the built-in themes
+// followed by the custom themes registered with SetCustomThemes.
 func AvailableThemes() []ThemeName {
-	out := make([]ThemeName, len(themeNames))
-	copy(out, themeNames)
-	return out
+	customThemesMu.RLock()
+	defer customThemesMu.RUnlock()
+	out := make([]ThemeName, 0, len(themeNames)+len(customThemes))
+	out = append(out, themeNames...)
+	return append(out, customThemes...)
+}
+
+// SetCustomThemes replaces the registered custom theme names. Names must
+// already be canonical (see CanonicalThemeName); names of built-in themes are
+// ignored.
+func SetCustomThemes(names []ThemeName) {
+	custom := make([]ThemeName, 0, len(names))
+	for _, name := range names {
+		if _, builtin := builtinThemeName(string(name)); builtin || slices.Contains(custom, name) {
+			continue
+		}
+		custom = append(custom, name)
+	}
+	customThemesMu.Lock()
+	defer customThemesMu.Unlock()
+	customThemes = custom
+}
+
+// IsBuiltinTheme reports whether name refers to a built-in theme, including
+// its aliases.
+func IsBuiltinTheme(name string) bool {
+	_, ok := builtinThemeName(name)
+	return ok
+}
+
+// CanonicalThemeName folds case and underscores the way NormalizeThemeName
+// does, without checking that the theme exists.
+func CanonicalThemeName(name string) ThemeName {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	return ThemeName(strings.ReplaceAll(normalized, "_", "-"))
 }
 
 // NormalizeThemeName returns a canonical theme name if supported.
 func NormalizeThemeName(name string) (ThemeName, bool) {
-	normalized := strings.ToLower(strings.TrimSpace(name))
-	normalized = strings.ReplaceAll(normalized, "_", "-")
-	switch normalized {
+	if builtin, ok := builtinThemeName(name); ok {
+		return builtin, true
+	}
+	normalized := CanonicalThemeName(name)
+	customThemesMu.RLock()
+	defer customThemesMu.RUnlock()
+	if slices.Contains(customThemes, normalized) {
+		return normalized, true
+	}
+	return "", false
+}
+
+func builtinThemeName(name string) (ThemeName, bool) {
+	switch CanonicalThemeName(name) {
 	case "outrun", "outrun-electric":
 		return "outrun", true
 	case "gruvbox":
@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToolName identifies a codex tool that can be turned on or off per tab.
+type ToolName string
+
+// ToolWebSearch lets codex search the web.
+const ToolWebSearch ToolName = "web"
+
+// KnownTools lists the tools that can be toggled per tab, in display order.
+var KnownTools = []ToolName{ToolWebSearch}
+
+// NormalizeToolName validates a tool name against KnownTools. Unknown names
+// are reported with the list of known ones.
+func NormalizeToolName(value string) (ToolName, error) {
+	name := ToolName(strings.TrimSpace(strings.ToLower(value)))
+	for _, known := range KnownTools {
+		if name == known {
+			return name, nil
+		}
+	}
+	names := make([]string, 0, len(KnownTools))
+	for _, known := range KnownTools {
+		names = append(names, string(known))
+	}
+	return "", fmt.Errorf("%w: unknown tool %q (known: %s)", ErrInvalidRequest, value, strings.Join(names, ", "))
+}
+
+// EnabledTools returns the tools switched on in tools, in KnownTools order.
+func EnabledTools(tools map[ToolName]bool) []ToolName {
+	var enabled []ToolName
+	for _, name := range KnownTools {
+		if tools[name] {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
@@ -12,6 +12,10 @@ type TabName string
 // SessionID identifies an exec session.
 type SessionID string
 
+// RunID identifies one accepted prompt from start to exit. It ties service,
+// runner and container logs of the run together.
+type RunID string
+
 // RepoName identifies a repository.
 type RepoName string
 
@@ -24,6 +28,106 @@ type ModelReasoningEffort string
 // ThemeName identifies a UI theme.
 type ThemeName string
 
+// HistoryScope selects which prompt history a request reads.
+type HistoryScope string
+
+const (
+	// HistoryScopeTab is the history of a single tab.
+	HistoryScopeTab HistoryScope = "tab"
+	// HistoryScopeGlobal is the per-user history across all tabs.
+	HistoryScopeGlobal HistoryScope = "global"
+)
+
+// ShareAccess is the access a tab owner grants another user.
+type ShareAccess string
+
+const (
+	// ShareAccessRead lets the guest view the tab.
+	ShareAccessRead ShareAccess = "read"
+	// ShareAccessReadWrite also lets the guest send prompts, run shell
+	// commands and stop runs in the tab.
+	ShareAccessReadWrite ShareAccess = "rw"
+)
+
+// Capabilities are what an account may do, as mapped by the auth backend
+// from directory groups or identity provider claims. The zero value is a
+// regular account.
+type Capabilities struct {
+	// Admin marks operators of the server.
+	Admin bool `json:"admin,omitempty"`
+	// ReadOnly accounts can view their tabs but not send prompts, run shell
+	// commands or stop runs.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// TabShare is a grant of access to a tab.
+type TabShare struct {
+	User   UserID
+	Access ShareAccess
+}
+
+// ActivityKind identifies an entry of a user's activity feed.
+type ActivityKind string
+
+const (
+	// ActivityRunnerCreated reports a runner container started for the user.
+	ActivityRunnerCreated ActivityKind = "runner_created"
+	// ActivityRunnerRecreated reports a runner container started again after
+	// the previous one stopped.
+	ActivityRunnerRecreated ActivityKind = "runner_recreated"
+	// ActivityCodexAuthUpdated reports a new codex auth.json.
+	ActivityCodexAuthUpdated ActivityKind = "codex_auth_updated"
+	// ActivitySSHKeyRotated reports a rotated git SSH key.
+	ActivitySSHKeyRotated ActivityKind = "ssh_key_rotated"
+	// ActivityRepoCloned reports a repo cloned from a git URL.
+	ActivityRepoCloned ActivityKind = "repo_cloned"
+	// ActivityQuotaWarning reports codex usage close to a rate limit.
+	ActivityQuotaWarning ActivityKind = "quota_warning"
+	// ActivitySummaryWritten reports a daily tab summary and the tokens it
+	// used.
+	ActivitySummaryWritten ActivityKind = "summary_written"
+)
+
+// Label returns a short human readable description of the kind, or an empty
+// string for unknown kinds.
+func (k ActivityKind) Label() string {
+	switch k {
+	case ActivityRunnerCreated:
+		return "runner container started"
+	case ActivityRunnerRecreated:
+		return "runner container recreated"
+	case ActivityCodexAuthUpdated:
+		return "codex auth.json updated"
+	case ActivitySSHKeyRotated:
+		return "git SSH key rotated"
+	case ActivityRepoCloned:
+		return "repo cloned"
+	case ActivityQuotaWarning:
+		return "usage quota warning"
+	case ActivitySummaryWritten:
+		return "tab summary written"
+	default:
+		return ""
+	}
+}
+
+// CommandAlias is a user-defined slash command. Typing /Name runs Expansion,
+// a slash command or `!` shell command, with any arguments appended.
+type CommandAlias struct {
+	Name      string
+	Expansion string
+}
+
+// ArchiveFormat identifies a repo archive format.
+type ArchiveFormat string
+
+const (
+	// ArchiveFormatTar is an uncompressed tarball.
+	ArchiveFormatTar ArchiveFormat = "tar"
+	// ArchiveFormatTarGz is a gzip-compressed tarball.
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+)
+
 // RepoRef identifies a repository available to the runner.
 type RepoRef struct {
 	Name RepoName
@@ -3,14 +3,18 @@ package centaurx
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"sync"
 
 	"pkt.systems/centaurx/core"
 	"pkt.systems/centaurx/httpapi"
 	"pkt.systems/centaurx/internal/appconfig"
+	"pkt.systems/centaurx/internal/archivestore"
 	"pkt.systems/centaurx/internal/auth"
 	"pkt.systems/centaurx/internal/command"
 	"pkt.systems/centaurx/internal/eventbus"
+	"pkt.systems/centaurx/internal/provision"
 	"pkt.systems/centaurx/internal/sshkeys"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/centaurx/sshserver"
@@ -39,6 +43,19 @@ type ServerConfig struct {
 type AuthConfig struct {
 	UserFile  string
 	SeedUsers []SeedUser
+	// Throttle configures failed login throttling for password and TOTP
+	// checks.
+	Throttle auth.ThrottleConfig
+	// LDAP, when its URL is set, verifies passwords against a directory
+	// instead of the users file.
+	LDAP auth.LDAPConfig
+	// OIDC, when its issuer is set, adds device-code logins to the web UI.
+	OIDC auth.OIDCConfig
+	// Groups maps LDAP groups and OIDC group claims to capabilities.
+	Groups auth.GroupMapping
+	// AutoProvision, when set, creates unknown users on their first SSH
+	// login with a public key its source vouches for.
+	AutoProvision *provision.SourceConfig
 }
 
 // SeedUser seeds an initial user record.
@@ -52,6 +69,14 @@ type SeedUser struct {
 type ServerDeps struct {
 	ServiceDeps core.ServiceDeps
 	Runner      core.RunnerServer
+	// Readiness, when set, is served on /readyz. New marks the service and
+	// SSH listener ready; the caller reports on everything else.
+	Readiness *httpapi.Readiness
+	// EnsureUserHome, when set, creates the home and state directories of
+	// automatically provisioned users.
+	EnsureUserHome func(username string) error
+	// ServerLog, when set, backs the admin /serverlog command.
+	ServerLog command.ServerLog
 }
 
 // ServerOption toggles compositor components.
@@ -91,9 +116,11 @@ func New(cfg ServerConfig, deps ServerDeps, opts ...ServerOption) (Server, error
 	var hub *httpapi.Hub
 	var bus *eventbus.Bus
 	var authStore *auth.Store
+	var authProvider auth.Provider
 	var gitKeyStore *sshkeys.Store
 	var httpSrv *httpapi.Server
 	var sshSrv *sshserver.Server
+	var events core.EventDispatcher
 	if options.enableHTTP || options.enableSSH {
 		if deps.ServiceDeps.RunnerProvider == nil {
 			return nil, errors.New("runner dependency is required")
@@ -132,18 +159,49 @@ func New(cfg ServerConfig, deps ServerDeps, opts ...ServerOption) (Server, error
 			}
 		}
 
+		logger := deps.ServiceDeps.Logger
+		seeds := toSeedUsers(cfg.Auth.SeedUsers)
+		store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, seeds, logger)
+		if err != nil {
+			return nil, err
+		}
+		if serviceDeps.TokenBudgets == nil {
+			serviceDeps.TokenBudgets = store
+		}
+		if serviceDeps.Users == nil {
+			serviceDeps.Users = store
+		}
+
 		service, err := core.NewService(cfg.Service, serviceDeps)
 		if err != nil {
 			return nil, err
 		}
+		events, _ = service.(core.EventDispatcher)
+		deps.Readiness.SetReady(httpapi.DependencyService, true)
+		if reporter, ok := serviceDeps.RunnerProvider.(core.ActivityReporter); ok {
+			reporter.SetActivityRecorder(service)
+		}
+		if reporter, ok := serviceDeps.RunnerProvider.(core.OutputReporter); ok {
+			reporter.SetOutputAppender(service)
+		}
 
-		logger := deps.ServiceDeps.Logger
-		seeds := toSeedUsers(cfg.Auth.SeedUsers)
-		store, err := auth.NewStoreWithLogger(cfg.Auth.UserFile, seeds, logger)
+		throttleCfg := cfg.Auth.Throttle
+		throttleCfg.Logger = logger
+		throttle, err := auth.NewThrottle(throttleCfg)
 		if err != nil {
 			return nil, err
 		}
+		store.SetThrottle(throttle)
 		authStore = store
+		authProvider = store
+		if cfg.Auth.LDAP.URL != "" {
+			ldap, err := auth.NewLDAPProvider(cfg.Auth.LDAP, cfg.Auth.Groups, store, logger)
+			if err != nil {
+				return nil, err
+			}
+			ldap.SetThrottle(throttle)
+			authProvider = ldap
+		}
 
 		gitStore, err := sshkeys.NewStoreWithLogger(cfg.SSH.KeyStorePath, cfg.SSH.KeyDir, logger)
 		if err != nil {
@@ -151,6 +209,16 @@ func New(cfg ServerConfig, deps ServerDeps, opts ...ServerOption) (Server, error
 		}
 		gitKeyStore = gitStore
 
+		var archives archivestore.Store
+		var viewURLPrefix string
+		if options.enableHTTP {
+			archives, err = archivestore.New("", archivestore.DefaultTTL)
+			if err != nil {
+				return nil, err
+			}
+			viewURLPrefix = httpapi.ViewURLPrefix(cfg.HTTP)
+		}
+
 		cmdHandler := command.NewHandler(service, serviceDeps.RunnerProvider, command.HandlerConfig{
 			AllowedModels:       cfg.Service.AllowedModels,
 			CommitModel:         cfg.CommitModel,
@@ -159,21 +227,62 @@ func New(cfg ServerConfig, deps ServerDeps, opts ...ServerOption) (Server, error
 			GitKeyStore:         gitKeyStore,
 			GitKeyRotator:       gitKeyStore,
 			DisableAuditLogging: cfg.DisableAuditLogging,
+			ArchiveStore:        archives,
+			ArchiveURLPrefix:    httpapi.ArchiveURLPrefix(cfg.HTTP),
+			ViewURLPrefix:       viewURLPrefix,
+			ServerLog:           deps.ServerLog,
+			StateDir:            cfg.Service.StateDir,
+			GitKeyDir:           cfg.SSH.KeyDir,
 		})
+		if reporter, ok := service.(core.AutoCommitReporter); ok {
+			reporter.SetAutoCommitter(cmdHandler)
+		}
 
 		if options.enableHTTP {
-			httpSrv = httpapi.NewServer(cfg.HTTP, service, cmdHandler, authStore, hub)
+			httpSrv = httpapi.NewServer(cfg.HTTP, service, cmdHandler, authProvider, hub)
+			if cfg.Auth.OIDC.Issuer != "" {
+				flow, err := auth.NewOIDCDeviceFlow(cfg.Auth.OIDC, cfg.Auth.Groups, store)
+				if err != nil {
+					return nil, err
+				}
+				httpSrv.SetDeviceLogin(flow)
+			}
+			httpSrv.SetArchiveStore(archives)
+			httpSrv.SetLoginPubKeyStore(authStore)
+			httpSrv.SetReadiness(deps.Readiness)
+		}
+
+		var provisioner *provision.Provisioner
+		if options.enableSSH && cfg.Auth.AutoProvision != nil {
+			source, err := provision.NewSource(*cfg.Auth.AutoProvision)
+			if err != nil {
+				return nil, fmt.Errorf("auto provision: %w", err)
+			}
+			provisioner, err = provision.NewProvisioner(source, store, gitKeyStore, deps.EnsureUserHome, logger)
+			if err != nil {
+				return nil, fmt.Errorf("auto provision: %w", err)
+			}
 		}
 
 		if options.enableSSH {
 			sshSrv = &sshserver.Server{
-				Addr:        cfg.SSH.Addr,
-				HostKeyPath: cfg.SSH.HostKeyPath,
-				Service:     service,
-				Handler:     cmdHandler,
-				IdlePrompt:  cfg.SSH.IdlePrompt,
-				AuthStore:   authStore,
-				EventBus:    bus,
+				Addr:           cfg.SSH.Addr,
+				HostKeyPath:    cfg.SSH.HostKeyPath,
+				HostKeys:       cfg.SSH.HostKeys,
+				Service:        service,
+				Handler:        cmdHandler,
+				AuthStore:      authProvider,
+				EventBus:       bus,
+				BannerFile:     cfg.SSH.BannerFile,
+				MOTDFile:       cfg.SSH.MOTDFile,
+				PromptTemplate: cfg.SSH.PromptTemplate,
+				ShowTabNumbers: cfg.SSH.ShowTabNumbers,
+				OnListen: func(net.Addr) {
+					deps.Readiness.SetReady(httpapi.DependencySSH, true)
+				},
+			}
+			if provisioner != nil {
+				sshSrv.Provisioner = provisioner
 			}
 		}
 	}
@@ -183,23 +292,27 @@ func New(cfg ServerConfig, deps ServerDeps, opts ...ServerOption) (Server, error
 	}
 
 	return &compositeServer{
-		cfg:     cfg,
-		options: options,
-		httpSrv: httpSrv,
-		sshSrv:  sshSrv,
-		runner:  deps.Runner,
-		runners: deps.ServiceDeps.RunnerProvider,
+		cfg:       cfg,
+		options:   options,
+		httpSrv:   httpSrv,
+		sshSrv:    sshSrv,
+		runner:    deps.Runner,
+		runners:   deps.ServiceDeps.RunnerProvider,
+		events:    events,
+		readiness: deps.Readiness,
 	}, nil
 }
 
 type compositeServer struct {
-	cfg     ServerConfig
-	options serverOptions
-	httpSrv *httpapi.Server
-	sshSrv  *sshserver.Server
-	runner  core.RunnerServer
-	runners core.RunnerProvider
-	logger  pslog.Logger
+	cfg       ServerConfig
+	options   serverOptions
+	httpSrv   *httpapi.Server
+	sshSrv    *sshserver.Server
+	runner    core.RunnerServer
+	runners   core.RunnerProvider
+	events    core.EventDispatcher
+	readiness *httpapi.Readiness
+	logger    pslog.Logger
 
 	mu      sync.Mutex
 	ctx     context.Context
@@ -299,13 +412,29 @@ func (s *compositeServer) Stop(ctx context.Context) error {
 		log = pslog.Ctx(context.Background())
 	}
 	log.Info("server stop requested")
+	// Report not ready first so load balancers stop routing new sessions
+	// while runners and events drain; /healthz keeps answering.
+	s.readiness.Drain()
 	if s.runners != nil {
-		if err := s.runners.CloseAll(context.Background()); err != nil {
+		closeCtx := ctx
+		if closeCtx == nil {
+			closeCtx = context.Background()
+		}
+		if err := s.runners.CloseAll(closeCtx); err != nil {
 			log.Warn("server runner close failed", "err", err)
 		} else {
 			log.Info("server runner close ok")
 		}
 	}
+	if s.events != nil {
+		drainCtx := ctx
+		if drainCtx == nil {
+			drainCtx = context.Background()
+		}
+		if err := s.events.CloseEvents(drainCtx); err != nil {
+			log.Warn("server event drain failed", "err", err)
+		}
+	}
 	if cancel != nil {
 		cancel()
 	}
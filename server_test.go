@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/httpapi"
 )
 
 func TestServerStopClosesRunners(t *testing.T) {
@@ -33,8 +34,36 @@ func TestServerStopClosesRunners(t *testing.T) {
 	}
 }
 
+func TestServerStopDrainsReadinessBeforeClosingRunners(t *testing.T) {
+	readiness := httpapi.NewReadiness(httpapi.DependencyService)
+	readiness.SetReady(httpapi.DependencyService, true)
+	runners := &trackingRunnerProvider{}
+	runners.onClose = func() {
+		if readiness.Report(context.Background()).Ready {
+			t.Errorf("expected readiness to drain before runners close")
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &compositeServer{
+		runners:   runners,
+		readiness: readiness,
+		ctx:       ctx,
+		cancel:    cancel,
+		started:   true,
+	}
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	if err := server.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if runners.closed != 1 {
+		t.Fatalf("expected CloseAll to be called, got %d", runners.closed)
+	}
+}
+
 type trackingRunnerProvider struct {
-	closed int
+	closed  int
+	onClose func()
 }
 
 func (t *trackingRunnerProvider) RunnerFor(context.Context, core.RunnerRequest) (core.RunnerResponse, error) {
@@ -47,5 +76,8 @@ func (t *trackingRunnerProvider) CloseTab(context.Context, core.RunnerCloseReque
 
 func (t *trackingRunnerProvider) CloseAll(context.Context) error {
 	t.closed++
+	if t.onClose != nil {
+		t.onClose()
+	}
 	return nil
 }
@@ -2,9 +2,16 @@ package sshserver
 
 // Config defines SSH server settings.
 type Config struct {
-	Addr         string
-	HostKeyPath  string
-	IdlePrompt   string
+	Addr        string
+	HostKeyPath string
+	// HostKeys are more host key files served next to HostKeyPath.
+	HostKeys     []string
 	KeyStorePath string
 	KeyDir       string
+	BannerFile   string
+	MOTDFile     string
+	// PromptTemplate renders the input line prefix; see sshprompt.Parse.
+	PromptTemplate string
+	// ShowTabNumbers prefixes tab names in the tab bar with their number.
+	ShowTabNumbers bool
 }
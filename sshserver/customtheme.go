@@ -0,0 +1,235 @@
+package sshserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// customThemes holds the themes loaded by LoadThemes.
+var customThemes atomic.Pointer[map[schema.ThemeName]tuiTheme]
+
+// themesGeneration changes whenever LoadThemes replaces the custom themes,
+// so cached renders of a reloaded theme are dropped.
+var themesGeneration atomic.Uint64
+
+var customThemeName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// themeFile is the on-disk form of a custom theme. Colors are written as
+// #rrggbb or #rgb; all but banner_bg and final_agent_fg are required.
+type themeFile struct {
+	// Name defaults to the file name without its extension.
+	Name             string `yaml:"name" json:"name"`
+	TabBarBG         string `yaml:"tab_bar_bg" json:"tab_bar_bg"`
+	TabActiveBG      string `yaml:"tab_active_bg" json:"tab_active_bg"`
+	TabActiveFG      string `yaml:"tab_active_fg" json:"tab_active_fg"`
+	TabInactiveBG    string `yaml:"tab_inactive_bg" json:"tab_inactive_bg"`
+	TabInactiveFG    string `yaml:"tab_inactive_fg" json:"tab_inactive_fg"`
+	ErrorFG          string `yaml:"error_fg" json:"error_fg"`
+	StderrFG         string `yaml:"stderr_fg" json:"stderr_fg"`
+	MetaFG           string `yaml:"meta_fg" json:"meta_fg"`
+	PromptFG         string `yaml:"prompt_fg" json:"prompt_fg"`
+	SpinnerFG        string `yaml:"spinner_fg" json:"spinner_fg"`
+	ReasoningFG      string `yaml:"reasoning_fg" json:"reasoning_fg"`
+	ReasoningBold    string `yaml:"reasoning_bold" json:"reasoning_bold"`
+	CodeFG           string `yaml:"code_fg" json:"code_fg"`
+	AboutLinkFG      string `yaml:"about_link_fg" json:"about_link_fg"`
+	AboutCopyrightFG string `yaml:"about_copyright_fg" json:"about_copyright_fg"`
+	HelpArgFG        string `yaml:"help_arg_fg" json:"help_arg_fg"`
+	// BannerBG defaults to TabBarBG; it was added after the other colors.
+	BannerBG string `yaml:"banner_bg" json:"banner_bg"`
+	// FinalAgentFG defaults to TabActiveBG; it was added after BannerBG.
+	FinalAgentFG string `yaml:"final_agent_fg" json:"final_agent_fg"`
+}
+
+// LoadThemes loads the custom themes in dir (*.yaml, *.yml and *.json) and
+// makes them available to all sessions, replacing any previously loaded set.
+// Files that cannot be parsed or miss colors are skipped and logged, as are
+// themes named like a built-in theme. A missing dir clears the custom themes.
+func LoadThemes(ctx context.Context, dir string) ([]schema.ThemeName, error) {
+	log := pslog.Ctx(ctx).With("themes_dir", dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	loaded := make(map[schema.ThemeName]tuiTheme)
+	var names []schema.ThemeName
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		theme, err := readThemeFile(path)
+		if err != nil {
+			log.Warn("custom theme skipped", "path", path, "err", err)
+			continue
+		}
+		if schema.IsBuiltinTheme(string(theme.Name)) {
+			log.Warn("custom theme skipped", "path", path, "theme", theme.Name, "reason", "name of a built-in theme")
+			continue
+		}
+		if _, ok := loaded[theme.Name]; ok {
+			log.Warn("custom theme skipped", "path", path, "theme", theme.Name, "reason", "duplicate name")
+			continue
+		}
+		loaded[theme.Name] = theme
+		names = append(names, theme.Name)
+	}
+	slices.Sort(names)
+	customThemes.Store(&loaded)
+	themesGeneration.Add(1)
+	schema.SetCustomThemes(names)
+	log.Info("custom themes loaded", "count", len(names))
+	return names, nil
+}
+
+func readThemeFile(path string) (tuiTheme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tuiTheme{}, err
+	}
+	var file themeFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&file)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&file)
+	}
+	if err != nil {
+		return tuiTheme{}, err
+	}
+	if strings.TrimSpace(file.Name) == "" {
+		file.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return file.theme()
+}
+
+// theme validates the file and converts it into a tuiTheme.
+func (f themeFile) theme() (tuiTheme, error) {
+	name := schema.CanonicalThemeName(f.Name)
+	if !customThemeName.MatchString(string(name)) {
+		return tuiTheme{}, fmt.Errorf("invalid theme name %q: use letters, digits and dashes", f.Name)
+	}
+	theme := tuiTheme{Name: name}
+	if strings.TrimSpace(f.BannerBG) == "" {
+		f.BannerBG = f.TabBarBG
+	}
+	if strings.TrimSpace(f.FinalAgentFG) == "" {
+		f.FinalAgentFG = f.TabActiveBG
+	}
+	var errs []error
+	for _, field := range []struct {
+		key   string
+		value string
+		dst   *rgb
+	}{
+		{"tab_bar_bg", f.TabBarBG, &theme.TabBarBG},
+		{"tab_active_bg", f.TabActiveBG, &theme.TabActiveBG},
+		{"tab_active_fg", f.TabActiveFG, &theme.TabActiveFG},
+		{"tab_inactive_bg", f.TabInactiveBG, &theme.TabInactiveBG},
+		{"tab_inactive_fg", f.TabInactiveFG, &theme.TabInactiveFG},
+		{"error_fg", f.ErrorFG, &theme.ErrorFG},
+		{"stderr_fg", f.StderrFG, &theme.StderrFG},
+		{"meta_fg", f.MetaFG, &theme.MetaFG},
+		{"prompt_fg", f.PromptFG, &theme.PromptFG},
+		{"spinner_fg", f.SpinnerFG, &theme.SpinnerFG},
+		{"reasoning_fg", f.ReasoningFG, &theme.ReasoningFG},
+		{"reasoning_bold", f.ReasoningBold, &theme.ReasoningBold},
+		{"code_fg", f.CodeFG, &theme.CodeFG},
+		{"about_link_fg", f.AboutLinkFG, &theme.AboutLinkFG},
+		{"about_copyright_fg", f.AboutCopyrightFG, &theme.AboutCopyrightFG},
+		{"help_arg_fg", f.HelpArgFG, &theme.HelpArgFG},
+		{"banner_bg", f.BannerBG, &theme.BannerBG},
+		{"final_agent_fg", f.FinalAgentFG, &theme.FinalAgentFG},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", field.key))
+			continue
+		}
+		c, err := parseHexColor(field.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.key, err))
+			continue
+		}
+		*field.dst = c
+	}
+	if len(errs) > 0 {
+		return tuiTheme{}, errors.Join(errs...)
+	}
+	return theme, nil
+}
+
+// parseHexColor parses #rrggbb or #rgb; the leading # is optional.
+func parseHexColor(value string) (rgb, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return rgb{}, fmt.Errorf("invalid color %q: use #rrggbb", value)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb{}, fmt.Errorf("invalid color %q: use #rrggbb", value)
+	}
+	return rgb{r: int(n >> 16 & 0xff), g: int(n >> 8 & 0xff), b: int(n & 0xff)}, nil
+}
+
+// SampleThemeYAML returns a complete custom theme file, using the colors of
+// the default theme, for operators to copy and adapt.
+func SampleThemeYAML() []byte {
+	theme := themeForName(schema.DefaultTheme)
+	hex := func(c rgb) string {
+		return fmt.Sprintf("\"#%02x%02x%02x\"", c.r, c.g, c.b)
+	}
+	var b strings.Builder
+	b.WriteString("# Custom centaurx theme. Copy this file into the themes directory\n")
+	b.WriteString("# (ui.themes_dir, default <state_dir>/themes) as <name>.yaml and send\n")
+	b.WriteString("# the server SIGHUP or restart it to load it. Every color but\n")
+	b.WriteString("# banner_bg and final_agent_fg is required.\n")
+	b.WriteString("name: example\n")
+	for _, field := range []struct {
+		key   string
+		color rgb
+	}{
+		{"tab_bar_bg", theme.TabBarBG},
+		{"tab_active_bg", theme.TabActiveBG},
+		{"tab_active_fg", theme.TabActiveFG},
+		{"tab_inactive_bg", theme.TabInactiveBG},
+		{"tab_inactive_fg", theme.TabInactiveFG},
+		{"error_fg", theme.ErrorFG},
+		{"stderr_fg", theme.StderrFG},
+		{"meta_fg", theme.MetaFG},
+		{"prompt_fg", theme.PromptFG},
+		{"spinner_fg", theme.SpinnerFG},
+		{"reasoning_fg", theme.ReasoningFG},
+		{"reasoning_bold", theme.ReasoningBold},
+		{"code_fg", theme.CodeFG},
+		{"about_link_fg", theme.AboutLinkFG},
+		{"about_copyright_fg", theme.AboutCopyrightFG},
+		{"help_arg_fg", theme.HelpArgFG},
+		{"banner_bg", theme.BannerBG},
+		{"final_agent_fg", theme.FinalAgentFG},
+	} {
+		b.WriteString(field.key + ": " + hex(field.color) + "\n")
+	}
+	return []byte(b.String())
+}
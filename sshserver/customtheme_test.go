@@ -0,0 +1,96 @@
+package sshserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func writeThemeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestLoadThemes(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() { _, _ = LoadThemes(ctx, filepath.Join(t.TempDir(), "missing")) })
+	dir := t.TempDir()
+	sample := string(SampleThemeYAML())
+	writeThemeFile(t, dir, "acme.yaml", strings.Replace(sample, "name: example", "name: Acme_Brand", 1))
+	writeThemeFile(t, dir, "plain.yml", strings.Replace(sample, "name: example\n", "", 1))
+	writeThemeFile(t, dir, "json.json", `{"name": "json-theme", "tab_bar_bg": "#123", "tab_active_bg": "#000000", "tab_active_fg": "#000000",
+		"tab_inactive_bg": "#000000", "tab_inactive_fg": "#000000", "error_fg": "#000000", "stderr_fg": "#000000",
+		"meta_fg": "#000000", "prompt_fg": "#000000", "spinner_fg": "#000000", "reasoning_fg": "#000000",
+		"reasoning_bold": "#000000", "code_fg": "#000000", "about_link_fg": "#000000", "about_copyright_fg": "#000000",
+		"help_arg_fg": "#000000"}`)
+	writeThemeFile(t, dir, "missing.yaml", "name: missing\nprompt_fg: \"#ffffff\"\n")
+	writeThemeFile(t, dir, "badhex.yaml", strings.Replace(strings.Replace(sample, "name: example", "name: badhex", 1), `prompt_fg: "#ffffff"`, `prompt_fg: "#fffzzz"`, 1))
+	writeThemeFile(t, dir, "typo.yaml", strings.Replace(sample, "name: example", "name: typo\nprompt_colour: red", 1))
+	writeThemeFile(t, dir, "shadow.yaml", strings.Replace(sample, "name: example", "name: tokyo", 1))
+	writeThemeFile(t, dir, "README.md", "not a theme")
+
+	names, err := LoadThemes(ctx, dir)
+	if err != nil {
+		t.Fatalf("LoadThemes: %v", err)
+	}
+	want := []schema.ThemeName{"acme-brand", "json-theme", "plain"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("expected themes %v, got %v", want, names)
+	}
+	available := schema.AvailableThemes()
+	if !slices.Contains(available, "acme-brand") || !slices.Contains(available, "tokyo-midnight") {
+		t.Fatalf("expected built-in and custom themes, got %v", available)
+	}
+	if name, ok := schema.NormalizeThemeName("ACME_brand"); !ok || name != "acme-brand" {
+		t.Fatalf("expected custom theme to normalize, got %q %v", name, ok)
+	}
+	if name, ok := schema.NormalizeThemeName("tokyo"); !ok || name != "tokyo-midnight" {
+		t.Fatalf("expected built-in theme to win, got %q %v", name, ok)
+	}
+	if got := themeForName("json-theme").TabBarBG; got != (rgb{r: 0x11, g: 0x22, b: 0x33}) {
+		t.Fatalf("expected short hex color to expand, got %+v", got)
+	}
+	if got := themeForName("json-theme").BannerBG; got != (rgb{r: 0x11, g: 0x22, b: 0x33}) {
+		t.Fatalf("expected a missing banner_bg to default to tab_bar_bg, got %+v", got)
+	}
+	if got := themeForName("acme-brand").PromptFG; got != (rgb{r: 255, g: 255, b: 255}) {
+		t.Fatalf("unexpected prompt color %+v", got)
+	}
+
+	// Reloading replaces the set; removed themes disappear.
+	if err := os.Remove(filepath.Join(dir, "acme.yaml")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := LoadThemes(ctx, dir); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, ok := schema.NormalizeThemeName("acme-brand"); ok {
+		t.Fatalf("expected removed theme to be gone after reload")
+	}
+	if themeForName("acme-brand").Name != schema.DefaultTheme {
+		t.Fatalf("expected removed theme to fall back to the default")
+	}
+}
+
+func TestThemeFileValidation(t *testing.T) {
+	_, err := themeFile{Name: "x", PromptFG: "#zzzzzz"}.theme()
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	msg := err.Error()
+	for _, want := range []string{"tab_bar_bg is required", `prompt_fg: invalid color "#zzzzzz"`} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("expected %q in %q", want, msg)
+		}
+	}
+	if _, err := (themeFile{Name: "bad name"}).theme(); err == nil || !strings.Contains(err.Error(), "invalid theme name") {
+		t.Fatalf("expected invalid name error, got %v", err)
+	}
+}
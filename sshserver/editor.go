@@ -29,6 +29,8 @@ const (
 	keyShiftTab
 	keyAltB
 	keyAltF
+	// keyAltDigit carries the digit pressed with Alt in r.
+	keyAltDigit
 	keyUp
 	keyDown
 	keyCtrlJ
@@ -113,6 +115,8 @@ func readEscape(br *bufio.Reader, out chan<- key) {
 			out <- key{kind: keyAltB}
 		case 'f', 'F':
 			out <- key{kind: keyAltF}
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			out <- key{kind: keyAltDigit, r: rune(b)}
 		}
 	}
 }
@@ -16,3 +16,17 @@ func TestReadKeysShiftTab(t *testing.T) {
 		t.Fatalf("expected shift tab, got %v", k.kind)
 	}
 }
+
+func TestReadKeysAltDigit(t *testing.T) {
+	keys := make(chan key, 2)
+	go readKeys(strings.NewReader("\x1b3\x1b0"), keys)
+	for _, want := range []rune{'3', '0'} {
+		k, ok := <-keys
+		if !ok {
+			t.Fatalf("expected key, got closed channel")
+		}
+		if k.kind != keyAltDigit || k.r != want {
+			t.Fatalf("expected alt+%c, got %v %q", want, k.kind, k.r)
+		}
+	}
+}
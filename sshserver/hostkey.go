@@ -1,8 +1,10 @@
 package sshserver
 
 import (
+	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/rsa"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -13,54 +15,125 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// rsaHostKeyBits is the size of generated RSA host keys.
+const rsaHostKeyBits = 3072
+
+// HostKey is a loaded SSH host key.
+type HostKey struct {
+	Path   string
+	Signer ssh.Signer
+	// Generated is set when the key did not exist and was created.
+	Generated bool
+}
+
+// Fingerprint returns the SHA256 fingerprint of the key as ssh-keygen -l
+// prints it.
+func (k HostKey) Fingerprint() string {
+	return ssh.FingerprintSHA256(k.Signer.PublicKey())
+}
+
+// Type returns the key algorithm, e.g. ssh-ed25519.
+func (k HostKey) Type() string {
+	return k.Signer.PublicKey().Type()
+}
+
 // EnsureHostKey ensures the SSH host key exists at path and returns the signer.
 func EnsureHostKey(path string) (ssh.Signer, error) {
-	if strings.TrimSpace(path) == "" {
+	key, err := ensureHostKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.Signer, nil
+}
+
+// EnsureHostKeys ensures every host key in paths exists, generating missing
+// ones, and returns them in order. Duplicate and empty paths are skipped.
+// Generated keys are RSA when the file name contains "rsa" and ed25519
+// otherwise.
+func EnsureHostKeys(paths []string) ([]HostKey, error) {
+	seen := make(map[string]struct{}, len(paths))
+	keys := make([]HostKey, 0, len(paths))
+	for _, path := range paths {
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		clean := filepath.Clean(path)
+		if _, ok := seen[clean]; ok {
+			continue
+		}
+		seen[clean] = struct{}{}
+		key, err := ensureHostKey(clean)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", clean, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
 		return nil, errors.New("ssh host key path is required")
 	}
+	return keys, nil
+}
+
+// LoadHostKey reads an existing SSH host key.
+func LoadHostKey(path string) (HostKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HostKey{}, fmt.Errorf("read host key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return HostKey{}, fmt.Errorf("parse host key: %w", err)
+	}
+	return HostKey{Path: path, Signer: signer}, nil
+}
+
+func ensureHostKey(path string) (HostKey, error) {
+	if strings.TrimSpace(path) == "" {
+		return HostKey{}, errors.New("ssh host key path is required")
+	}
 	if _, err := os.Stat(path); err == nil {
-		return loadHostKey(path)
+		return LoadHostKey(path)
 	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("stat host key: %w", err)
+		return HostKey{}, fmt.Errorf("stat host key: %w", err)
 	}
 
 	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
-		return nil, fmt.Errorf("create host key dir: %w", err)
+		return HostKey{}, fmt.Errorf("create host key dir: %w", err)
 	}
 
-	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	priv, err := generateHostKey(path)
 	if err != nil {
-		return nil, fmt.Errorf("generate host key: %w", err)
+		return HostKey{}, fmt.Errorf("generate host key: %w", err)
 	}
 
 	block, err := ssh.MarshalPrivateKey(priv, "centaurx")
 	if err != nil {
-		return nil, fmt.Errorf("marshal host key: %w", err)
+		return HostKey{}, fmt.Errorf("marshal host key: %w", err)
 	}
 
 	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
 	if err != nil {
-		return nil, fmt.Errorf("write host key: %w", err)
+		return HostKey{}, fmt.Errorf("write host key: %w", err)
 	}
 	if err := pem.Encode(file, block); err != nil {
 		_ = file.Close()
-		return nil, fmt.Errorf("encode host key: %w", err)
+		return HostKey{}, fmt.Errorf("encode host key: %w", err)
 	}
 	if err := file.Close(); err != nil {
-		return nil, fmt.Errorf("close host key: %w", err)
+		return HostKey{}, fmt.Errorf("close host key: %w", err)
 	}
 
-	return ssh.NewSignerFromKey(priv)
-}
-
-func loadHostKey(path string) (ssh.Signer, error) {
-	data, err := os.ReadFile(path)
+	signer, err := ssh.NewSignerFromKey(priv)
 	if err != nil {
-		return nil, fmt.Errorf("read host key: %w", err)
+		return HostKey{}, err
 	}
-	signer, err := ssh.ParsePrivateKey(data)
-	if err != nil {
-		return nil, fmt.Errorf("parse host key: %w", err)
+	return HostKey{Path: path, Signer: signer, Generated: true}, nil
+}
+
+func generateHostKey(path string) (crypto.Signer, error) {
+	if strings.Contains(strings.ToLower(filepath.Base(path)), "rsa") {
+		return rsa.GenerateKey(rand.Reader, rsaHostKeyBits)
 	}
-	return signer, nil
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	return priv, err
 }
@@ -0,0 +1,67 @@
+package sshserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEnsureHostKeysGeneratesOnceAndReuses(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "ssh_host_ed25519_key"),
+		filepath.Join(dir, "keys", "ssh_host_rsa_key"),
+		filepath.Join(dir, "ssh_host_ed25519_key"),
+		"",
+	}
+	first, err := EnsureHostKeys(paths)
+	if err != nil {
+		t.Fatalf("ensure host keys: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("expected duplicate and empty paths to be skipped, got %d keys", len(first))
+	}
+	if first[0].Type() != ssh.KeyAlgoED25519 || first[1].Type() != ssh.KeyAlgoRSA {
+		t.Fatalf("expected ed25519 and rsa keys, got %s and %s", first[0].Type(), first[1].Type())
+	}
+	for _, key := range first {
+		if !key.Generated {
+			t.Fatalf("expected %s to be generated", key.Path)
+		}
+		info, err := os.Stat(key.Path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", key.Path, err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Fatalf("expected %s to have mode 0600, got %04o", key.Path, perm)
+		}
+	}
+
+	second, err := EnsureHostKeys(paths)
+	if err != nil {
+		t.Fatalf("ensure host keys again: %v", err)
+	}
+	for i, key := range second {
+		if key.Generated {
+			t.Fatalf("expected %s to be reused", key.Path)
+		}
+		if key.Fingerprint() != first[i].Fingerprint() {
+			t.Fatalf("expected %s to keep fingerprint %s, got %s", key.Path, first[i].Fingerprint(), key.Fingerprint())
+		}
+	}
+	signer, err := EnsureHostKey(paths[0])
+	if err != nil {
+		t.Fatalf("ensure host key: %v", err)
+	}
+	if ssh.FingerprintSHA256(signer.PublicKey()) != first[0].Fingerprint() {
+		t.Fatalf("expected EnsureHostKey to load the same key")
+	}
+}
+
+func TestEnsureHostKeysRequiresAPath(t *testing.T) {
+	if _, err := EnsureHostKeys([]string{"", " "}); err == nil {
+		t.Fatalf("expected an error without host key paths")
+	}
+}
@@ -0,0 +1,70 @@
+package sshserver
+
+import (
+	"hash/maphash"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// lineCache memoizes renderLines output keyed by the raw line content for a
+// fixed width and theme. Changing either drops every entry. Entries that are
+// not used for two consecutive frames are evicted so the cache stays bounded
+// by what is actually on screen.
+type lineCache struct {
+	seed       maphash.Seed
+	width      int
+	theme      schema.ThemeName
+	generation uint64
+	cur        map[uint64]cachedLine
+	prev       map[uint64]cachedLine
+}
+
+type cachedLine struct {
+	raw   string
+	lines []string
+}
+
+func newLineCache() *lineCache {
+	return &lineCache{seed: maphash.MakeSeed()}
+}
+
+// begin starts a frame for width and theme, resetting the cache when either
+// changed since the previous frame or custom themes were reloaded.
+func (c *lineCache) begin(width int, theme schema.ThemeName) {
+	generation := themesGeneration.Load()
+	if c.width != width || c.theme != theme || c.generation != generation {
+		c.width = width
+		c.theme = theme
+		c.generation = generation
+		c.cur = nil
+		c.prev = nil
+	}
+	c.prev = c.cur
+	c.cur = make(map[uint64]cachedLine, len(c.prev))
+}
+
+// render returns the rendered rows for raw. The returned slice is shared and
+// must not be modified. A nil cache renders without memoization.
+func (c *lineCache) render(raw string, width int, theme tuiTheme) []string {
+	if c == nil {
+		return renderLines(raw, width, theme)
+	}
+	key := maphash.String(c.seed, raw)
+	if entry, ok := c.cur[key]; ok && entry.raw == raw {
+		return entry.lines
+	}
+	if entry, ok := c.prev[key]; ok && entry.raw == raw {
+		c.cur[key] = entry
+		return entry.lines
+	}
+	lines := renderLines(raw, width, theme)
+	if c.cur != nil {
+		c.cur[key] = cachedLine{raw: raw, lines: lines}
+	}
+	return lines
+}
+
+// len reports the number of entries retained for the current frame.
+func (c *lineCache) len() int {
+	return len(c.cur)
+}
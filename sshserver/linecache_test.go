@@ -0,0 +1,232 @@
+package sshserver
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"pkt.systems/centaurx/internal/timefmt"
+	"pkt.systems/centaurx/schema"
+)
+
+func TestLineCacheInvalidatesOnWidthAndTheme(t *testing.T) {
+	cache := newLineCache()
+	raw := schema.AgentMarker + strings.Repeat("word ", 20)
+	theme := themeForName("outrun")
+
+	cache.begin(40, "outrun")
+	first := cache.render(raw, 40, theme)
+	if again := cache.render(raw, 40, theme); &again[0] != &first[0] {
+		t.Fatalf("expected cached rows to be reused")
+	}
+
+	cache.begin(40, "outrun")
+	if kept := cache.render(raw, 40, theme); &kept[0] != &first[0] {
+		t.Fatalf("expected rows from the previous frame to be reused")
+	}
+
+	cache.begin(20, "outrun")
+	if cache.len() != 0 {
+		t.Fatalf("expected resize to drop cached rows, got %d", cache.len())
+	}
+	narrow := cache.render(raw, 20, theme)
+	if len(narrow) <= len(first) {
+		t.Fatalf("expected narrower width to wrap into more rows: %d vs %d", len(narrow), len(first))
+	}
+
+	cache.begin(20, "gruvbox")
+	if cache.len() != 0 {
+		t.Fatalf("expected theme change to drop cached rows, got %d", cache.len())
+	}
+}
+
+func TestLineCacheEvictsUnusedLines(t *testing.T) {
+	cache := newLineCache()
+	theme := themeForName("outrun")
+	cache.begin(80, "outrun")
+	cache.render("old", 80, theme)
+	cache.begin(80, "outrun")
+	cache.render("new", 80, theme)
+	cache.begin(80, "outrun")
+	cache.render("new", 80, theme)
+	for _, entries := range []map[uint64]cachedLine{cache.cur, cache.prev} {
+		for _, entry := range entries {
+			if entry.raw == "old" {
+				t.Fatalf("expected line unused for two frames to be evicted")
+			}
+		}
+	}
+}
+
+func TestRenderViewportCachedMatchesUncached(t *testing.T) {
+	theme := themeForName("outrun")
+	viewLines := benchmarkViewLines(200)
+	cache := newLineCache()
+	for _, atBottom := range []bool{true, false} {
+		cache.begin(60, "outrun")
+		want := renderViewport(viewport{lines: viewLines, width: 60, height: 40, theme: theme, atBottom: atBottom}, nil)
+		got := renderViewport(viewport{lines: viewLines, width: 60, height: 40, theme: theme, atBottom: atBottom}, cache)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("cached viewport differs from uncached (atBottom=%v)", atBottom)
+		}
+	}
+}
+
+func TestRenderViewportTimestampColumn(t *testing.T) {
+	theme := themeForName("outrun")
+	stamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	view := viewport{
+		lines: []string{
+			schema.WorkedForMarker + "Worked for 2s",
+			strings.Repeat("x", 30),
+			"legacy",
+		},
+		stamps:   []time.Time{stamp, stamp, {}},
+		width:    30,
+		height:   5,
+		theme:    theme,
+		atBottom: true,
+	}
+	cache := newLineCache()
+	cache.begin(view.contentWidth(), "outrun")
+	got := renderViewport(view, cache)
+	plain := make([]string, len(got))
+	for i, line := range got {
+		plain[i] = sanitizeOutputLine(line)
+	}
+	if !strings.Contains(plain[0], "Worked for 2s") || strings.HasPrefix(plain[0], "03:04:05") {
+		t.Fatalf("expected worked-for line without timestamp, got %q", plain[0])
+	}
+	if visibleWidth(got[0]) != view.width {
+		t.Fatalf("expected worked-for line to span the full width, got %d", visibleWidth(got[0]))
+	}
+	if plain[1] != "03:04:05 "+strings.Repeat("x", 21) || plain[2] != strings.Repeat(" ", view.timestampColumnWidth())+strings.Repeat("x", 9) {
+		t.Fatalf("expected stamped and indented wrapped rows, got %q", plain[1:3])
+	}
+	if plain[3] != strings.Repeat(" ", view.timestampColumnWidth())+"legacy" {
+		t.Fatalf("expected untimed line without a timestamp, got %q", plain[3])
+	}
+}
+
+func TestLineStampsRequiresMatchingEntries(t *testing.T) {
+	entries := []schema.BufferLine{schema.Line(schema.LineKindSystem, "a")}
+	if lineStamps(entries, 2) != nil {
+		t.Fatalf("expected no stamps when entries do not match lines")
+	}
+	if stamps := lineStamps(entries, 1); len(stamps) != 1 {
+		t.Fatalf("expected one stamp, got %v", stamps)
+	}
+}
+
+func TestScreenRenderRewritesOnlyChangedRows(t *testing.T) {
+	var out bytes.Buffer
+	s := newScreen(&out)
+	if err := s.Render([]string{"one", "two", "three"}, 1, 1); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(out.String(), "\x1b[2J") {
+		t.Fatalf("expected first frame to clear the screen, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := s.Render([]string{"one", "TWO", "three"}, 1, 1); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	frame := out.String()
+	if strings.Contains(frame, "\x1b[2J") || strings.Contains(frame, "one") || strings.Contains(frame, "three") {
+		t.Fatalf("expected only the changed row to be written, got %q", frame)
+	}
+	if !strings.Contains(frame, "\x1b[2;1H\x1b[2KTWO") {
+		t.Fatalf("expected row 2 rewrite, got %q", frame)
+	}
+
+	out.Reset()
+	s.Invalidate()
+	if err := s.Render([]string{"one", "TWO", "three"}, 1, 1); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(out.String(), "\x1b[2J") || !strings.Contains(out.String(), "three") {
+		t.Fatalf("expected full redraw after invalidate, got %q", out.String())
+	}
+}
+
+func benchmarkViewLines(n int) []string {
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			lines = append(lines, schema.AgentMarker+fmt.Sprintf("Agent reply %d with **bold** text and `code` spans that wrap across the terminal width when it is narrow enough.", i))
+		case 1:
+			lines = append(lines, fmt.Sprintf("$ bash -lc 'go test ./... -run Test%d'", i))
+		case 2:
+			lines = append(lines, schema.ReasoningMarker+fmt.Sprintf("Reasoning about step %d before answering.", i))
+		default:
+			lines = append(lines, fmt.Sprintf("ok  \tpkt.systems/centaurx/pkg%d\t0.%03ds", i, i%1000))
+		}
+	}
+	return lines
+}
+
+// BenchmarkRenderViewport renders a 2,000-line buffer at 200 columns, tailed
+// and scrolled up, with and without the per-width line cache.
+func BenchmarkRenderViewport(b *testing.B) {
+	theme := themeForName("outrun")
+	viewLines := benchmarkViewLines(2000)
+	for _, tc := range []struct {
+		name     string
+		atBottom bool
+		cached   bool
+	}{
+		{name: "bottom/uncached", atBottom: true},
+		{name: "bottom/cached", atBottom: true, cached: true},
+		{name: "scrolled/uncached"},
+		{name: "scrolled/cached", cached: true},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			var cache *lineCache
+			if tc.cached {
+				cache = newLineCache()
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if cache != nil {
+					cache.begin(200, "outrun")
+				}
+				renderViewport(viewport{lines: viewLines, width: 200, height: 60, theme: theme, atBottom: tc.atBottom}, cache)
+			}
+		})
+	}
+}
+
+func TestRenderViewportTimestampColumnUsesClock(t *testing.T) {
+	clock, err := timefmt.New("3:04 PM", "America/New_York")
+	if err != nil {
+		t.Fatalf("clock: %v", err)
+	}
+	// Before and after New York moves to EDT at 07:00 UTC on 2026-03-08.
+	view := viewport{
+		lines:    []string{"before", "after"},
+		stamps:   []time.Time{time.Date(2026, 3, 8, 6, 59, 0, 0, time.UTC), time.Date(2026, 3, 8, 15, 0, 0, 0, time.UTC)},
+		clock:    clock,
+		width:    30,
+		height:   5,
+		theme:    themeForName("outrun"),
+		atBottom: true,
+	}
+	cache := newLineCache()
+	cache.begin(view.contentWidth(), "outrun")
+	got := renderViewport(view, cache)
+	var plain []string
+	for _, line := range got {
+		if text := strings.TrimRight(sanitizeOutputLine(line), " "); text != "" {
+			plain = append(plain, text)
+		}
+	}
+	want := []string{"1:59 AM  before", "11:00 AM after"}
+	if !slices.Equal(plain, want) {
+		t.Fatalf("expected %q, got %q", want, plain)
+	}
+}
@@ -0,0 +1,109 @@
+package sshserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/eventbus"
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+// lineModeUsage is written to sessions that ask for neither a pty nor a
+// command.
+const lineModeUsage = `centaurx needs a terminal for its interactive UI.
+Connect with a pty (ssh -t) or pass one command to run:
+  ssh <host> '/listrepos'
+  ssh <host> '! git status'
+`
+
+// errLineModePrompt rejects prompts in line mode, which only runs commands.
+var errLineModePrompt = errors.New("line mode runs slash commands and ! shell commands; connect with a pty (ssh -t) to send prompts")
+
+// runLineCommand runs input for a session without a pty: the command goes
+// through the command handler in the active tab, the output it writes to
+// that tab or the system buffer is copied to stdout as plain text, and
+// errors go to stderr. ! commands are waited for. It returns the exit
+// status for the session.
+func (s *Server) runLineCommand(ctx context.Context, stdout, stderr io.Writer, userID schema.UserID, caps schema.Capabilities, input string) int {
+	log := logx.WithUser(ctx, userID)
+	fail := func(err error) int {
+		_, _ = fmt.Fprintf(stderr, "error: %v\n", err)
+		log.Warn("ssh line command failed", "err", err)
+		return 1
+	}
+	if s.Handler == nil {
+		return fail(errors.New("commands unavailable"))
+	}
+	tabs, err := s.Service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		return fail(err)
+	}
+	tabID := tabs.ActiveTab
+	prefs := sessionprefs.New()
+	prefs.ActiveTab = tabID
+	prefs.Capabilities = caps
+	prefs.Commands = &sessionprefs.CommandWaiter{}
+	ctx = sessionprefs.WithContext(ctx, prefs)
+
+	var events <-chan eventbus.Event
+	unsubscribe := func() {}
+	if s.EventBus != nil {
+		events, unsubscribe = s.EventBus.Subscribe(userID)
+	}
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		copyLineOutput(stdout, events, tabID)
+	}()
+
+	handled, err := s.Handler.Handle(ctx, userID, tabID, input)
+	if err == nil && !handled {
+		err = errLineModePrompt
+	}
+	code := 0
+	if err == nil {
+		code = prefs.Commands.Wait()
+	}
+	if dispatcher, ok := s.Service.(core.EventDispatcher); ok {
+		// Events are delivered from a queue; copy all of them before
+		// the session ends.
+		_ = dispatcher.FlushEvents(ctx)
+	}
+	unsubscribe()
+	<-copied
+	if err != nil {
+		return fail(err)
+	}
+	log.Info("ssh line command done", "exit_code", code)
+	return code
+}
+
+// copyLineOutput writes the output lines of tabID and the system buffer to w
+// until events is closed.
+func copyLineOutput(w io.Writer, events <-chan eventbus.Event, tabID schema.TabID) {
+	if events == nil {
+		return
+	}
+	for event := range events {
+		var lines []string
+		switch event.Type {
+		case eventbus.EventOutput:
+			if event.Output.TabID != tabID {
+				continue
+			}
+			lines = event.Output.Lines
+		case eventbus.EventSystemOutput:
+			lines = event.System.Lines
+		default:
+			continue
+		}
+		for _, line := range lines {
+			_, _ = io.WriteString(w, plainBufferLine(schema.ParseBufferLine(line))+"\n")
+		}
+	}
+}
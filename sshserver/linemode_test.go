@@ -0,0 +1,68 @@
+package sshserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/internal/eventbus"
+	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/schema"
+)
+
+// lineHandler answers /ok, starts a ! command that finishes in the
+// background with exitCode, and fails /bad.
+type lineHandler struct {
+	bus      *eventbus.Bus
+	exitCode int
+}
+
+func (h lineHandler) Handle(ctx context.Context, userID schema.UserID, tabID schema.TabID, input string) (bool, error) {
+	switch {
+	case input == "/ok":
+		h.bus.OnOutput(schema.OutputEvent{UserID: userID, TabID: tabID, Lines: []string{schema.Line(schema.LineKindHelp, "\x1b[1mall good\x1b[0m").Legacy()}})
+		h.bus.OnOutput(schema.OutputEvent{UserID: userID, TabID: "other", Lines: []string{"not this tab"}})
+		return true, nil
+	case strings.HasPrefix(input, "!"):
+		prefs := sessionprefs.FromContext(ctx)
+		prefs.Commands.Start()
+		go func() {
+			h.bus.OnSystemOutput(schema.SystemOutputEvent{UserID: userID, Lines: []string{"$ false", "--- command finished ---"}})
+			prefs.Commands.Finish(h.exitCode, nil)
+		}()
+		return true, nil
+	case input == "/bad":
+		return true, errors.New("bad command")
+	}
+	return false, nil
+}
+
+func TestRunLineCommand(t *testing.T) {
+	bus := eventbus.New(nil)
+	svc := &stubService{
+		listTabsFn: func(context.Context, schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{ActiveTab: "tab1", Tabs: []schema.TabSnapshot{{ID: "tab1", Name: "api"}}}, nil
+		},
+	}
+	srv := &Server{Service: svc, EventBus: bus, Handler: lineHandler{bus: bus, exitCode: 3}}
+	run := func(input string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := srv.runLineCommand(context.Background(), &stdout, &stderr, "alice", schema.Capabilities{}, input)
+		return code, stdout.String(), stderr.String()
+	}
+
+	if code, out, errOut := run("/ok"); code != 0 || out != "all good\n" || errOut != "" {
+		t.Fatalf("expected plain output of the active tab, got %d %q %q", code, out, errOut)
+	}
+	if code, out, _ := run("! false"); code != 3 || out != "$ false\n--- command finished ---\n" {
+		t.Fatalf("expected to wait for the shell command and exit with its code, got %d %q", code, out)
+	}
+	if code, _, errOut := run("/bad"); code != 1 || errOut != "error: bad command\n" {
+		t.Fatalf("expected the error on stderr, got %d %q", code, errOut)
+	}
+	if code, _, errOut := run("hello codex"); code != 1 || !strings.Contains(errOut, "ssh -t") {
+		t.Fatalf("expected prompts to be refused, got %d %q", code, errOut)
+	}
+}
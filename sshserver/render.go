@@ -1,6 +1,8 @@
 package sshserver
 
 import (
+	"hash/fnv"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -18,15 +20,32 @@ const (
 	lineMeta
 	lineWorked
 	lineAgent
+	lineFinalAgent
 	lineReasoning
 	lineCommand
 	lineHelp
 	lineAboutVersion
 	lineAboutCopyright
 	lineAboutLink
+	lineField
 )
 
-func renderTabBar(tabs []schema.TabSnapshot, active schema.TabID, width int, theme tuiTheme, windowStart int) (string, int) {
+// tabStyle picks the tab label style; tabs shared by another user are
+// rendered in italics.
+func tabStyle(tab schema.TabSnapshot, active schema.TabID, activeStyle, inactiveStyle string) string {
+	style := inactiveStyle
+	if tab.ID == active {
+		style = activeStyle
+	}
+	if tab.Owner != "" {
+		style += ansiItalic
+	}
+	return style
+}
+
+// renderTabBar renders the tab bar, prefixing names with their number in
+// numbers when it is set.
+func renderTabBar(tabs []schema.TabSnapshot, active schema.TabID, numbers map[schema.TabID]int, width int, theme tuiTheme, windowStart int) (string, int) {
 	if width <= 0 {
 		width = 80
 	}
@@ -56,9 +75,24 @@ func renderTabBar(tabs []schema.TabSnapshot, active schema.TabID, width int, the
 				name = string(tab.ID)
 			}
 			name = truncateName(name, 10)
+			if tab.Ephemeral {
+				// Ephemeral tabs are marked so it is clear nothing is saved.
+				name = "~" + name
+			}
+			if tab.Owner != "" {
+				// Tabs shared by another user are prefixed with the owner.
+				name = truncateName(string(tab.Owner), 8) + ":" + name
+			}
+			if n, ok := numbers[tab.ID]; ok {
+				name = strconv.Itoa(n) + ":" + name
+			}
 			label := " " + name + " "
-			labels = append(labels, label)
 			labelWidth := utf8.RuneCountInString(label)
+			if len(tab.Labels) > 0 {
+				label = " " + name + " " + tabLabelDots(tab.Labels, theme, tabStyle(tab, active, activeStyle, inactiveStyle)) + " "
+				labelWidth += len(tab.Labels) + 1
+			}
+			labels = append(labels, label)
 			widths = append(widths, labelWidth)
 			totalWidth += labelWidth
 			if tab.ID == active {
@@ -74,15 +108,9 @@ func renderTabBar(tabs []schema.TabSnapshot, active schema.TabID, width int, the
 			start := 0
 			end := len(tabs)
 			for i := start; i < end; i++ {
-				if tabs[i].ID == active {
-					b.WriteString(activeStyle)
-					b.WriteString(labels[i])
-					b.WriteString(barStyle)
-				} else {
-					b.WriteString(inactiveStyle)
-					b.WriteString(labels[i])
-					b.WriteString(barStyle)
-				}
+				b.WriteString(tabStyle(tabs[i], active, activeStyle, inactiveStyle))
+				b.WriteString(labels[i])
+				b.WriteString(ansiReset + barStyle)
 			}
 			line := b.String()
 			if visible := visibleWidth(line); visible < width {
@@ -106,15 +134,9 @@ func renderTabBar(tabs []schema.TabSnapshot, active schema.TabID, width int, the
 			b.WriteString(barStyle)
 		}
 		for i := window.start; i < window.end; i++ {
-			if tabs[i].ID == active {
-				b.WriteString(activeStyle)
-				b.WriteString(labels[i])
-				b.WriteString(barStyle)
-			} else {
-				b.WriteString(inactiveStyle)
-				b.WriteString(labels[i])
-				b.WriteString(barStyle)
-			}
+			b.WriteString(tabStyle(tabs[i], active, activeStyle, inactiveStyle))
+			b.WriteString(labels[i])
+			b.WriteString(ansiReset + barStyle)
 		}
 		line := b.String()
 		if window.rightHidden {
@@ -145,6 +167,22 @@ func renderTabBar(tabs []schema.TabSnapshot, active schema.TabID, width int, the
 	return line + ansiReset, windowStart
 }
 
+// tabLabelDots renders one colored dot per /tag label. A label keeps its
+// color across tabs and sessions; style restores the tab's own colors after
+// each dot.
+func tabLabelDots(labels []schema.TabLabel, theme tuiTheme, style string) string {
+	palette := []rgb{theme.SpinnerFG, theme.StderrFG, theme.CodeFG, theme.ErrorFG, theme.HelpArgFG, theme.ReasoningBold}
+	var b strings.Builder
+	for _, label := range labels {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(label))
+		b.WriteString(ansiFgRGB(palette[hash.Sum32()%uint32(len(palette))]))
+		b.WriteString("•")
+		b.WriteString(style)
+	}
+	return b.String()
+}
+
 type tabWindow struct {
 	start       int
 	end         int
@@ -348,6 +386,13 @@ func renderLine(raw string, width int, theme tuiTheme) string {
 		return renderMarkdownLine(info.text, width, markdownStyle{
 			codeFG: &theme.CodeFG,
 		})
+	case lineFinalAgent:
+		if width <= finalAgentGutterWidth {
+			return renderMarkdownLine(info.text, width, markdownStyle{codeFG: &theme.CodeFG})
+		}
+		return finalAgentGutter(theme) + renderMarkdownLine(info.text, width-finalAgentGutterWidth, markdownStyle{
+			codeFG: &theme.CodeFG,
+		})
 	case lineReasoning:
 		return renderMarkdownLine(info.text, width, markdownStyle{
 			baseItalic: true,
@@ -384,6 +429,9 @@ func renderLine(raw string, width int, theme tuiTheme) string {
 			return text
 		}
 		return ansiItalic + ansiFgRGB(theme.AboutLinkFG) + text + ansiReset
+	case lineField:
+		label, value := schema.SplitField(info.text)
+		return trimToWidth(sanitizeOutputLine(label)+" "+sanitizeOutputLine(value), width)
 	default:
 		text := trimToWidth(sanitizeOutputLine(info.text), width)
 		if text == "" {
@@ -403,6 +451,18 @@ func renderLines(raw string, width int, theme tuiTheme) []string {
 		return renderMarkdownLines(info.text, width, markdownStyle{
 			codeFG: &theme.CodeFG,
 		})
+	case lineFinalAgent:
+		if width <= finalAgentGutterWidth {
+			return renderMarkdownLines(info.text, width, markdownStyle{codeFG: &theme.CodeFG})
+		}
+		lines := renderMarkdownLines(info.text, width-finalAgentGutterWidth, markdownStyle{
+			codeFG: &theme.CodeFG,
+		})
+		gutter := finalAgentGutter(theme)
+		for i := range lines {
+			lines[i] = gutter + lines[i]
+		}
+		return lines
 	case lineReasoning:
 		return renderMarkdownLines(info.text, width, markdownStyle{
 			baseItalic: true,
@@ -431,11 +491,25 @@ func renderLines(raw string, width int, theme tuiTheme) []string {
 		return wrapStyledLines(info.text, width, ansiItalic+ansiFgRGB(theme.AboutLinkFG))
 	case lineWorked:
 		return []string{renderLine(raw, width, theme)}
+	case lineField:
+		return renderFieldLines(info.text, width)
 	default:
+		if strings.Contains(info.text, "\x1b[") {
+			return wrapSGRLines(info.text, width)
+		}
 		return wrapPlainLines(info.text, width)
 	}
 }
 
+// finalAgentGutterWidth is the number of columns finalAgentGutter takes.
+const finalAgentGutterWidth = 2
+
+// finalAgentGutter marks the lines of the final agent message of a turn,
+// like the left border the web UI draws beside it.
+func finalAgentGutter(theme tuiTheme) string {
+	return ansiFgRGB(theme.FinalAgentFG) + "▎" + ansiReset + " "
+}
+
 type lineInfo struct {
 	text string
 	kind lineKind
@@ -454,6 +528,11 @@ func classifyLine(raw string) lineInfo {
 		text = strings.TrimPrefix(text, schema.AgentMarker)
 		return lineInfo{text: text, kind: kind}
 	}
+	if strings.HasPrefix(text, schema.FinalAgentMarker) {
+		kind = lineFinalAgent
+		text = strings.TrimPrefix(text, schema.FinalAgentMarker)
+		return lineInfo{text: text, kind: kind}
+	}
 	if strings.HasPrefix(text, schema.ReasoningMarker) {
 		kind = lineReasoning
 		text = strings.TrimPrefix(text, schema.ReasoningMarker)
@@ -484,6 +563,11 @@ func classifyLine(raw string) lineInfo {
 		text = strings.TrimPrefix(text, schema.AboutLinkMarker)
 		return lineInfo{text: text, kind: kind}
 	}
+	if strings.HasPrefix(text, schema.FieldMarker) {
+		kind = lineField
+		text = strings.TrimPrefix(text, schema.FieldMarker)
+		return lineInfo{text: text, kind: kind}
+	}
 	if strings.HasPrefix(text, schema.StderrMarker) {
 		kind = lineStderr
 		text = strings.TrimPrefix(text, schema.StderrMarker)
@@ -499,6 +583,29 @@ func classifyLine(raw string) lineInfo {
 	return lineInfo{text: text, kind: kind}
 }
 
+// renderFieldLines wraps the value of a field line with a hanging indent at
+// the value column. When the label takes more than half the width the line
+// wraps like plain text instead.
+func renderFieldLines(text string, width int) []string {
+	label, value := schema.SplitField(text)
+	label = sanitizeOutputLine(label)
+	indent := visibleWidth(label) + 1
+	if indent*2 > width {
+		return wrapPlainLines(label+" "+value, width)
+	}
+	rows := wrapPlainLines(value, width-indent)
+	pad := strings.Repeat(" ", indent)
+	out := make([]string, len(rows))
+	for i, row := range rows {
+		if i == 0 {
+			out[i] = label + " " + row
+		} else {
+			out[i] = pad + row
+		}
+	}
+	return out
+}
+
 func renderWorkedLine(label string, width int) string {
 	if width <= 0 {
 		return ""
@@ -516,6 +623,17 @@ func renderWorkedLine(label string, width int) string {
 	return lead + strings.Repeat("─", fill)
 }
 
+// renderErrorBanner renders text as a full-width highlighted line for the
+// error banner above the input.
+func renderErrorBanner(text string, width int, theme tuiTheme) string {
+	if width <= 0 {
+		return ""
+	}
+	text = trimToWidth(" ! "+sanitizeOutputLine(text), width)
+	pad := max(width-utf8.RuneCountInString(text), 0)
+	return ansiBgRGB(theme.BannerBG) + ansiBold + ansiFgRGB(theme.PromptFG) + text + strings.Repeat(" ", pad) + ansiReset
+}
+
 type markdownStyle struct {
 	baseItalic bool
 	baseBold   bool
@@ -862,6 +980,63 @@ func wrapStyledLines(text string, width int, style string) []string {
 	return styled
 }
 
+// wrapSGRLines hard-wraps output that carries SGR color sequences, as
+// commands run with !! produce, keeping the colors. Other escapes and control
+// characters are dropped like sanitizeOutputLine does. Attributes still
+// active at a row break are reset and reopened on the next row.
+func wrapSGRLines(text string, width int) []string {
+	if width <= 0 {
+		return []string{""}
+	}
+	lines := make([]string, 0, 2)
+	var b strings.Builder
+	active := ""
+	visible := 0
+	flush := func() {
+		if active != "" {
+			b.WriteString(ansiReset)
+		}
+		lines = append(lines, b.String())
+		b.Reset()
+		b.WriteString(active)
+		visible = 0
+	}
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			end := skipEscape(text, i+1)
+			if seq := text[i:end]; strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m") {
+				b.WriteString(seq)
+				if seq == "\x1b[m" || seq == ansiReset {
+					active = ""
+				} else {
+					active += seq
+				}
+			}
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		cells := []rune{r}
+		if r == '\t' {
+			cells = []rune("    ")
+		} else if r < 0x20 || r == 0x7f || (r == utf8.RuneError && size == 1) {
+			continue
+		}
+		for _, cell := range cells {
+			if visible == width {
+				flush()
+			}
+			b.WriteRune(cell)
+			visible++
+		}
+	}
+	if active != "" {
+		b.WriteString(ansiReset)
+	}
+	return append(lines, b.String())
+}
+
 func sanitizeOutputLine(text string) string {
 	if text == "" {
 		return ""
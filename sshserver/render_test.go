@@ -1,6 +1,11 @@
 package sshserver
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -13,7 +18,7 @@ func TestRenderTabBarFullWidth(t *testing.T) {
 		{ID: "tab2", Name: "beta"},
 	}
 	theme := themeForName("outrun")
-	line, _ := renderTabBar(tabs, "tab2", 40, theme, 0)
+	line, _ := renderTabBar(tabs, "tab2", nil, 40, theme, 0)
 	if got := visibleWidth(line); got != 40 {
 		t.Fatalf("expected tab bar width 40, got %d", got)
 	}
@@ -34,7 +39,7 @@ func TestRenderTabBarIndicators(t *testing.T) {
 		{ID: "tab4", Name: "delta"},
 		{ID: "tab5", Name: "epsilon"},
 	}
-	line, _ := renderTabBar(tabs, "tab3", 20, theme, 0)
+	line, _ := renderTabBar(tabs, "tab3", nil, 20, theme, 0)
 	if !strings.Contains(line, "<") {
 		t.Fatalf("expected left indicator for hidden tabs")
 	}
@@ -42,7 +47,7 @@ func TestRenderTabBarIndicators(t *testing.T) {
 		t.Fatalf("expected right indicator for hidden tabs")
 	}
 
-	line, _ = renderTabBar(tabs, "tab1", 20, theme, 0)
+	line, _ = renderTabBar(tabs, "tab1", nil, 20, theme, 0)
 	if strings.Contains(line, "<") {
 		t.Fatalf("did not expect left indicator when at first tab")
 	}
@@ -50,7 +55,7 @@ func TestRenderTabBarIndicators(t *testing.T) {
 		t.Fatalf("expected right indicator when more tabs exist")
 	}
 
-	line, _ = renderTabBar(tabs, "tab5", 20, theme, 0)
+	line, _ = renderTabBar(tabs, "tab5", nil, 20, theme, 0)
 	if !strings.Contains(line, "<") {
 		t.Fatalf("expected left indicator when more tabs exist")
 	}
@@ -69,27 +74,27 @@ func TestRenderTabBarWindowShift(t *testing.T) {
 		{ID: "tab5", Name: "five"},
 	}
 	start := 0
-	_, start = renderTabBar(tabs, "tab1", 20, theme, start)
+	_, start = renderTabBar(tabs, "tab1", nil, 20, theme, start)
 	if start != 0 {
 		t.Fatalf("expected window start 0, got %d", start)
 	}
-	_, start = renderTabBar(tabs, "tab2", 20, theme, start)
+	_, start = renderTabBar(tabs, "tab2", nil, 20, theme, start)
 	if start != 0 {
 		t.Fatalf("expected window start to stay 0, got %d", start)
 	}
-	_, start = renderTabBar(tabs, "tab3", 20, theme, start)
+	_, start = renderTabBar(tabs, "tab3", nil, 20, theme, start)
 	if start != 0 {
 		t.Fatalf("expected window start to stay 0, got %d", start)
 	}
-	_, start = renderTabBar(tabs, "tab4", 20, theme, start)
+	_, start = renderTabBar(tabs, "tab4", nil, 20, theme, start)
 	if start != 1 {
 		t.Fatalf("expected window to shift right to 1, got %d", start)
 	}
-	_, start = renderTabBar(tabs, "tab5", 20, theme, start)
+	_, start = renderTabBar(tabs, "tab5", nil, 20, theme, start)
 	if start != 2 {
 		t.Fatalf("expected window to shift right to 2, got %d", start)
 	}
-	_, start = renderTabBar(tabs, "tab2", 20, theme, start)
+	_, start = renderTabBar(tabs, "tab2", nil, 20, theme, start)
 	if start != 1 {
 		t.Fatalf("expected window to shift left to 1, got %d", start)
 	}
@@ -123,6 +128,26 @@ func TestSanitizeOutputLineStripsAnsiAndControl(t *testing.T) {
 	}
 }
 
+func TestRenderLinesKeepsSGRColors(t *testing.T) {
+	theme := themeForName("outrun")
+	lines := renderLines("\x1b[2K\x1b[31mabcdef\x1b[0mgh", 4, theme)
+	want := []string{
+		"\x1b[31mabcd" + ansiReset,
+		"\x1b[31mef\x1b[0mgh",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d rows, got %q", len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("row %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+	if got := renderLines("plain \x1b]0;title\x07text", 80, theme); len(got) != 1 || got[0] != "plain text" {
+		t.Fatalf("expected plain line without escapes, got %q", got)
+	}
+}
+
 func TestRenderWorkedLineFullWidth(t *testing.T) {
 	theme := themeForName("outrun")
 	line := renderLine(schema.WorkedForMarker+"Worked for 19s", 50, theme)
@@ -159,6 +184,31 @@ func TestRenderAgentMarkdownCode(t *testing.T) {
 	}
 }
 
+func TestRenderFinalAgentGutter(t *testing.T) {
+	theme := themeForName("outrun")
+	gutter := finalAgentGutter(theme)
+	final := renderLine(schema.FinalAgentMarker+"done", 80, theme)
+	if !strings.HasPrefix(final, gutter) {
+		t.Fatalf("expected the final agent message to start with the gutter, got %q", final)
+	}
+	intermediate := renderLine(schema.AgentMarker+"done", 80, theme)
+	if strings.Contains(intermediate, gutter) {
+		t.Fatalf("expected no gutter on an intermediate agent message, got %q", intermediate)
+	}
+	lines := renderLines(schema.FinalAgentMarker+strings.Repeat("word ", 10), 12, theme)
+	if len(lines) < 2 {
+		t.Fatalf("expected wrapped lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, gutter) {
+			t.Fatalf("line %d lacks the gutter: %q", i, line)
+		}
+		if got := visibleWidth(line); got > 12 {
+			t.Fatalf("line %d width %d exceeds limit", i, got)
+		}
+	}
+}
+
 func TestRenderAboutVersionStyles(t *testing.T) {
 	theme := themeForName("outrun")
 	line := renderLine(schema.AboutVersionMarker+"pkt.systems/centaurx v0.0.0", 80, theme)
@@ -236,3 +286,131 @@ func sanitizeLines(lines []string) []string {
 	}
 	return out
 }
+
+func TestRenderTabBarMarksSharedTabs(t *testing.T) {
+	theme := themeForName("outrun")
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Name: "alpha"},
+		{ID: "tab2", Name: "beta", Owner: "bob", Access: schema.ShareAccessRead},
+	}
+	line, _ := renderTabBar(tabs, "tab1", nil, 60, theme, 0)
+	if !strings.Contains(line, " bob:beta ") {
+		t.Fatalf("expected shared tab to carry its owner, got %q", line)
+	}
+	if !strings.Contains(line, ansiItalic+" bob:beta ") {
+		t.Fatalf("expected shared tab to be rendered in italics, got %q", line)
+	}
+	if got := visibleWidth(line); got != 60 {
+		t.Fatalf("expected tab bar width 60, got %d", got)
+	}
+}
+
+func TestRenderTabBarShowsLabelDots(t *testing.T) {
+	theme := themeForName("outrun")
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Name: "alpha", Labels: []schema.TabLabel{"backend", "release"}},
+		{ID: "tab2", Name: "beta"},
+	}
+	line, _ := renderTabBar(tabs, "tab2", nil, 40, theme, 0)
+	if got := visibleWidth(line); got != 40 {
+		t.Fatalf("expected tab bar width 40, got %d", got)
+	}
+	if !strings.Contains(line, " alpha ") || strings.Count(line, "•") != 2 {
+		t.Fatalf("expected a dot per label, got %q", line)
+	}
+	dots := tabLabelDots([]schema.TabLabel{"backend"}, theme, "")
+	if again := tabLabelDots([]schema.TabLabel{"backend"}, theme, ""); again != dots || !strings.Contains(line, dots) {
+		t.Fatalf("expected a label to keep its color, got %q and %q", dots, again)
+	}
+}
+
+func TestRenderTabBarShowsTabNumbers(t *testing.T) {
+	theme := themeForName("outrun")
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Name: "alpha"},
+		{ID: "tab3", Name: "gamma"},
+	}
+	numbers := map[schema.TabID]int{"tab1": 1, "tab2": 2, "tab3": 3}
+	line, _ := renderTabBar(tabs, "tab1", numbers, 40, theme, 0)
+	if !strings.Contains(line, " 1:alpha ") || !strings.Contains(line, " 3:gamma ") {
+		t.Fatalf("expected tabs to carry their numbers, got %q", line)
+	}
+	if got := visibleWidth(line); got != 40 {
+		t.Fatalf("expected tab bar width 40, got %d", got)
+	}
+}
+
+func TestFilterTabsKeepsActiveTab(t *testing.T) {
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Labels: []schema.TabLabel{"backend"}},
+		{ID: "tab2"},
+		{ID: "tab3", Labels: []schema.TabLabel{"backend", "api"}},
+		{ID: "tab4", Labels: []schema.TabLabel{"frontend"}},
+	}
+	got := filterTabs(tabs, "backend", "tab2")
+	ids := make([]schema.TabID, 0, len(got))
+	for _, tab := range got {
+		ids = append(ids, tab.ID)
+	}
+	if want := []schema.TabID{"tab1", "tab2", "tab3"}; !slices.Equal(ids, want) {
+		t.Fatalf("unexpected filtered tabs %v", ids)
+	}
+	if arg, ok := tabFilterCommand("/tabfilter off"); !ok || arg != "off" {
+		t.Fatalf("expected /tabfilter off to parse, got %q %v", arg, ok)
+	}
+	if _, ok := tabFilterCommand("/tabfilters"); ok {
+		t.Fatalf("expected other commands not to parse as /tabfilter")
+	}
+}
+
+// execStartBlock is the exec start summary as core appends it.
+var execStartBlock = []string{
+	schema.WorkedForMarker + "12:00:00 Starting codex exec",
+	schema.FieldLine("Repository:", "centaurx"),
+	schema.FieldLine("Branch:    ", "feature/render-time-wrapping-of-exec-start"),
+	schema.FieldLine("Remote:    ", "git@github.com:sa6mwa/centaurx.git"),
+	schema.FieldLine("Git status:", "M core/exec_start.go"),
+	schema.FieldLine("           ", "M sshserver/render.go with a long enough name to wrap"),
+	schema.FieldLine("Model:     ", "gpt-5.2-codex (reasoning medium)"),
+	schema.FieldLine("Session:   ", "resuming 0199a1b2… (last used 2h ago)"),
+	schema.FieldLine("Run:       ", "1c2017cc…"),
+}
+
+func TestRenderExecStartGolden(t *testing.T) {
+	theme := themeForName("outrun")
+	for _, width := range []int{40, 80} {
+		t.Run(strconv.Itoa(width), func(t *testing.T) {
+			var got []string
+			for _, raw := range execStartBlock {
+				for _, row := range renderLines(raw, width, theme) {
+					if visibleWidth(row) > width {
+						t.Fatalf("row wider than %d: %q", width, row)
+					}
+					got = append(got, sanitizeOutputLine(row))
+				}
+			}
+			data, err := os.ReadFile(filepath.Join("testdata", fmt.Sprintf("exec_start_%d.golden", width)))
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			want := strings.TrimRight(string(data), "\n")
+			if strings.Join(got, "\n") != want {
+				t.Fatalf("output mismatch\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), want)
+			}
+		})
+	}
+}
+
+func TestRenderErrorBanner(t *testing.T) {
+	theme := themeForName("outrun")
+	line := renderErrorBanner("runner unavailable\x1b[31m", 30, theme)
+	if !strings.HasPrefix(line, ansiBgRGB(theme.BannerBG)) || !strings.Contains(line, " ! runner unavailable") {
+		t.Fatalf("expected a highlighted banner, got %q", line)
+	}
+	if got := visibleWidth(line); got != 30 {
+		t.Fatalf("expected banner width 30, got %d", got)
+	}
+	if got := visibleWidth(renderErrorBanner(strings.Repeat("x", 50), 20, theme)); got != 20 {
+		t.Fatalf("expected a long banner to be cut to 20, got %d", got)
+	}
+}
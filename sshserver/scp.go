@@ -0,0 +1,250 @@
+package sshserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	gliderssh "github.com/gliderlabs/ssh"
+
+	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/schema"
+	"pkt.systems/pslog"
+)
+
+// Files served per tab by the SCP read surface.
+const (
+	scpBufferFile  = "buffer.txt"
+	scpHistoryFile = "history.txt"
+)
+
+// scpSFTPHint is what clients asking for the sftp subsystem are told, since
+// OpenSSH 9+ scp uses SFTP unless run with -O.
+const scpSFTPHint = "centaurx serves only the legacy SCP protocol; copy with: scp -O <host>:/tabs/<name>/" + scpBufferFile + " ."
+
+// scpSource is a file to send: a tab's buffer or prompt history, written as
+// plain text one line at a time. lines renders them on each iteration, so
+// the file is never held in memory as a whole.
+type scpSource struct {
+	name  string
+	lines iter.Seq[string]
+}
+
+// size returns the byte count SCP announces before the data.
+func (f scpSource) size() int64 {
+	var n int64
+	for line := range f.lines {
+		n += int64(len(line)) + 1
+	}
+	return n
+}
+
+// parseSCPCommand returns the path of an `scp -f <path>` download. Uploads
+// (-t) and recursive copies are rejected; -p, -v and -q are accepted and
+// ignored.
+func parseSCPCommand(argv []string) (string, error) {
+	if len(argv) == 0 || argv[0] != "scp" {
+		return "", errors.New("not an scp command")
+	}
+	var (
+		source bool
+		target string
+	)
+	for _, arg := range argv[1:] {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if target != "" {
+				return "", errors.New("only one file can be copied at a time")
+			}
+			target = arg
+			continue
+		}
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'f':
+				source = true
+			case 't':
+				return "", errors.New("uploads are not supported")
+			case 'r', 'd':
+				return "", errors.New("recursive copies are not supported")
+			case 'p', 'v', 'q':
+			default:
+				return "", fmt.Errorf("unsupported scp flag -%c", flag)
+			}
+		}
+	}
+	if !source {
+		return "", errors.New("only downloads (scp -f) are supported")
+	}
+	if target == "" {
+		return "", errors.New("missing path")
+	}
+	return target, nil
+}
+
+// resolveSCPPath maps /tabs/<name>/buffer.txt or /tabs/<name>/history.txt
+// to one of the user's own tabs. Names match case-insensitively; shared tabs
+// of other users are not reachable.
+func resolveSCPPath(p string, tabs []schema.TabSnapshot) (schema.TabSnapshot, string, error) {
+	parts := strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/")
+	if len(parts) != 3 || parts[0] != "tabs" {
+		return schema.TabSnapshot{}, "", fmt.Errorf("%s: expected /tabs/<name>/%s or /tabs/<name>/%s", p, scpBufferFile, scpHistoryFile)
+	}
+	name, file := parts[1], parts[2]
+	if file != scpBufferFile && file != scpHistoryFile {
+		return schema.TabSnapshot{}, "", fmt.Errorf("%s: no such file; tabs have %s and %s", p, scpBufferFile, scpHistoryFile)
+	}
+	var matches []schema.TabSnapshot
+	for _, tab := range tabs {
+		if tab.Owner == "" && strings.EqualFold(string(tab.Name), name) {
+			matches = append(matches, tab)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return schema.TabSnapshot{}, "", fmt.Errorf("%s: tab not found: %s", p, name)
+	case 1:
+		return matches[0], file, nil
+	default:
+		candidates := make([]string, 0, len(matches))
+		for _, tab := range matches {
+			candidates = append(candidates, fmt.Sprintf("%s (%s)", tab.Name, tab.ID))
+		}
+		return schema.TabSnapshot{}, "", fmt.Errorf("%s: tab name %s is ambiguous: %s", p, name, strings.Join(candidates, ", "))
+	}
+}
+
+// plainBufferLine renders a buffer line as plain text: markers and ANSI
+// escapes are stripped, prompts keep their "> " and fields read "label value".
+func plainBufferLine(line schema.BufferLine) string {
+	switch line.Kind {
+	case schema.LineKindPrompt:
+		return "> " + sanitizeOutputLine(line.Text)
+	case schema.LineKindField:
+		label, value := schema.SplitField(line.Text)
+		return sanitizeOutputLine(label) + " " + sanitizeOutputLine(value)
+	default:
+		return sanitizeOutputLine(line.Text)
+	}
+}
+
+// loadSCPSource reads the file a download asked for.
+func loadSCPSource(ctx context.Context, service core.Service, userID schema.UserID, p string) (scpSource, error) {
+	tabs, err := service.ListTabs(ctx, schema.ListTabsRequest{UserID: userID})
+	if err != nil {
+		return scpSource{}, err
+	}
+	tab, file, err := resolveSCPPath(p, tabs.Tabs)
+	if err != nil {
+		return scpSource{}, err
+	}
+	src := scpSource{name: file}
+	if file == scpHistoryFile {
+		resp, err := service.GetHistory(ctx, schema.GetHistoryRequest{UserID: userID, TabID: tab.ID, Scope: schema.HistoryScopeTab})
+		if err != nil {
+			return scpSource{}, err
+		}
+		src.lines = slices.Values(resp.Entries)
+		return src, nil
+	}
+	resp, err := service.GetBuffer(ctx, schema.GetBufferRequest{UserID: userID, TabID: tab.ID, Structured: true})
+	if err != nil {
+		return scpSource{}, err
+	}
+	src.lines = func(yield func(string) bool) {
+		for _, line := range resp.Buffer.Structured {
+			if !yield(plainBufferLine(line)) {
+				return
+			}
+		}
+	}
+	return src, nil
+}
+
+// serveSCP speaks the source side of the SCP protocol for one file: wait
+// for the client, announce the file, stream its lines and wait for the final
+// acknowledgement. Errors are reported to the client as an SCP error line.
+func (s *Server) serveSCP(ctx context.Context, rw io.ReadWriter, userID schema.UserID, argv []string) error {
+	log := logx.WithUser(ctx, userID)
+	in := bufio.NewReader(rw)
+	out := bufio.NewWriter(rw)
+	fail := func(err error) error {
+		_, _ = fmt.Fprintf(out, "\x01scp: %v\n", err)
+		_ = out.Flush()
+		log.Warn("ssh scp failed", "err", err)
+		return err
+	}
+	p, err := parseSCPCommand(argv)
+	if err != nil {
+		return fail(err)
+	}
+	if err := readSCPAck(in); err != nil {
+		return err
+	}
+	src, err := loadSCPSource(ctx, s.Service, userID, p)
+	if err != nil {
+		return fail(err)
+	}
+	size := src.size()
+	if _, err := fmt.Fprintf(out, "C0644 %s %s\n", strconv.FormatInt(size, 10), src.name); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if err := readSCPAck(in); err != nil {
+		return err
+	}
+	for line := range src.lines {
+		if _, err := out.WriteString(line); err != nil {
+			return err
+		}
+		if err := out.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := out.WriteByte(0); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if err := readSCPAck(in); err != nil {
+		return err
+	}
+	log.Info("ssh scp sent", "path", p, "bytes", size)
+	return nil
+}
+
+// readSCPAck reads the client's status byte: 0 is ok, anything else is
+// followed by an error message.
+func readSCPAck(in *bufio.Reader) error {
+	b, err := in.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := in.ReadString('\n')
+	return fmt.Errorf("scp client error: %s", strings.TrimSpace(msg))
+}
+
+// handleSFTP answers sftp subsystem requests, which plain scp sends on
+// OpenSSH 9+, with how to fall back to the legacy protocol.
+func (s *Server) handleSFTP(sess gliderssh.Session) {
+	log := s.logger
+	if log == nil {
+		log = pslog.Ctx(sess.Context())
+	}
+	log.Info("ssh sftp rejected", "user", sess.User(), "remote", sess.RemoteAddr().String())
+	_, _ = fmt.Fprintln(sess.Stderr(), scpSFTPHint)
+	_ = sess.Exit(1)
+}
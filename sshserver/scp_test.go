@@ -0,0 +1,98 @@
+package sshserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pkt.systems/centaurx/schema"
+)
+
+func TestParseSCPCommand(t *testing.T) {
+	p, err := parseSCPCommand([]string{"scp", "-v", "-pf", "/tabs/api/buffer.txt"})
+	if err != nil || p != "/tabs/api/buffer.txt" {
+		t.Fatalf("expected the download path, got %q %v", p, err)
+	}
+	for _, argv := range [][]string{
+		{"scp", "-t", "/tabs/api/buffer.txt"},
+		{"scp", "-r", "-f", "/tabs"},
+		{"scp", "/tabs/api/buffer.txt"},
+		{"scp", "-f"},
+	} {
+		if _, err := parseSCPCommand(argv); err == nil {
+			t.Fatalf("expected %q to be rejected", argv)
+		}
+	}
+}
+
+func TestResolveSCPPath(t *testing.T) {
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Name: "API"},
+		{ID: "tab2", Name: "web"},
+		{ID: "tab3", Name: "Web"},
+		{ID: "tab4", Name: "docs", Owner: "bob"},
+	}
+	tab, file, err := resolveSCPPath("tabs/api/history.txt", tabs)
+	if err != nil || tab.ID != "tab1" || file != scpHistoryFile {
+		t.Fatalf("expected a case-insensitive match, got %q %q %v", tab.ID, file, err)
+	}
+	if _, _, err := resolveSCPPath("/tabs/web/buffer.txt", tabs); err == nil || !strings.Contains(err.Error(), "web (tab2), Web (tab3)") {
+		t.Fatalf("expected the candidates of an ambiguous name, got %v", err)
+	}
+	if _, _, err := resolveSCPPath("/tabs/docs/buffer.txt", tabs); err == nil || !strings.Contains(err.Error(), "tab not found") {
+		t.Fatalf("expected shared tabs to be out of reach, got %v", err)
+	}
+	for _, p := range []string{"/tabs/api/notes.txt", "/tabs/api", "/etc/passwd", "/tabs/../tabs/api/x/buffer.txt"} {
+		if _, _, err := resolveSCPPath(p, tabs); err == nil {
+			t.Fatalf("expected %s to be rejected", p)
+		}
+	}
+}
+
+func TestServeSCPSendsPlainBuffer(t *testing.T) {
+	svc := &stubService{
+		listTabsFn: func(context.Context, schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{Tabs: []schema.TabSnapshot{{ID: "tab1", Name: "api"}}}, nil
+		},
+		getBufferFn: func(_ context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+			if req.TabID != "tab1" || !req.Structured || req.Limit != 0 {
+				t.Fatalf("unexpected buffer request %+v", req)
+			}
+			return schema.GetBufferResponse{Buffer: schema.BufferSnapshot{Structured: []schema.BufferLine{
+				{Kind: schema.LineKindPrompt, Text: "fix the build"},
+				{Kind: schema.LineKindField, Text: "Model:\tgpt-5.2-codex"},
+				{Kind: schema.LineKindCommand, Text: "go build ./..."},
+				{Kind: schema.LineKindStderr, Text: "\x1b[31mfailed\x1b[0m"},
+				{Kind: schema.LineKindAnswer, Text: "Fixed."},
+			}}}, nil
+		},
+	}
+	srv := &Server{Service: svc}
+	rw := &scpPipe{in: bytes.NewBufferString("\x00\x00\x00")}
+	if err := srv.serveSCP(context.Background(), rw, "alice", []string{"scp", "-f", "/tabs/API/buffer.txt"}); err != nil {
+		t.Fatalf("serve scp: %v", err)
+	}
+	body := "> fix the build\nModel: gpt-5.2-codex\ngo build ./...\nfailed\nFixed.\n"
+	want := fmt.Sprintf("C0644 %d buffer.txt\n%s\x00", len(body), body)
+	if got := rw.out.String(); got != want {
+		t.Fatalf("unexpected scp stream %q, want %q", got, want)
+	}
+
+	rw = &scpPipe{in: bytes.NewBufferString("\x00")}
+	if err := srv.serveSCP(context.Background(), rw, "alice", []string{"scp", "-f", "/tabs/web/buffer.txt"}); err == nil {
+		t.Fatal("expected a missing tab to fail")
+	}
+	if got := rw.out.String(); !strings.HasPrefix(got, "\x01scp: ") || !strings.Contains(got, "tab not found: web") {
+		t.Fatalf("expected an scp error line, got %q", got)
+	}
+}
+
+type scpPipe struct {
+	in  *bytes.Buffer
+	out bytes.Buffer
+}
+
+func (p *scpPipe) Read(b []byte) (int, error)  { return p.in.Read(b) }
+func (p *scpPipe) Write(b []byte) (int, error) { return p.out.Write(b) }
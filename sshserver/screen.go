@@ -7,15 +7,25 @@ import (
 )
 
 type screen struct {
-	out io.Writer
+	out  io.Writer
+	prev []string
+	full bool
 }
 
 func newScreen(out io.Writer) *screen {
-	return &screen{out: out}
+	return &screen{out: out, full: true}
 }
 
 func (s *screen) EnterAltScreen() {
 	_, _ = io.WriteString(s.out, "\x1b[?1049h\x1b[H\x1b[2J")
+	s.Invalidate()
+}
+
+// Invalidate forces the next Render to redraw the whole screen, e.g. after a
+// resize when the terminal contents can no longer be trusted.
+func (s *screen) Invalidate() {
+	s.prev = nil
+	s.full = true
 }
 
 func (s *screen) ExitAltScreen() {
@@ -31,15 +41,31 @@ func (s *screen) Render(lines []string, cursorRow, cursorCol int) error {
 	}
 	var b strings.Builder
 	b.WriteString("\x1b[?25l")
-	b.WriteString("\x1b[H\x1b[2J")
-	for i, line := range lines {
-		if i > 0 {
-			b.WriteString("\r\n")
+	if s.full || len(lines) != len(s.prev) {
+		b.WriteString("\x1b[H\x1b[2J")
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString("\r\n")
+			}
+			b.WriteString(line)
+		}
+	} else {
+		// Rewrite only the rows that changed since the previous frame.
+		for i, line := range lines {
+			if line == s.prev[i] {
+				continue
+			}
+			b.WriteString(fmt.Sprintf("\x1b[%d;1H\x1b[2K", i+1))
+			b.WriteString(line)
 		}
-		b.WriteString(line)
 	}
 	b.WriteString(fmt.Sprintf("\x1b[%d;%dH", cursorRow, cursorCol))
 	b.WriteString("\x1b[?25h")
-	_, err := io.WriteString(s.out, b.String())
-	return err
+	if _, err := io.WriteString(s.out, b.String()); err != nil {
+		s.Invalidate()
+		return err
+	}
+	s.prev = append(s.prev[:0], lines...)
+	s.full = false
+	return nil
 }
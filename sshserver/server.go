@@ -3,15 +3,21 @@ package sshserver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"strings"
 
 	gliderssh "github.com/gliderlabs/ssh"
 	"golang.org/x/crypto/ssh"
 
 	"pkt.systems/centaurx/core"
+	"pkt.systems/centaurx/internal/auth"
 	"pkt.systems/centaurx/internal/eventbus"
 	"pkt.systems/centaurx/internal/logx"
+	"pkt.systems/centaurx/internal/motd"
+	"pkt.systems/centaurx/internal/sshprompt"
+	"pkt.systems/centaurx/internal/version"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
 )
@@ -25,39 +31,89 @@ type CommandHandler interface {
 type Server struct {
 	Addr        string
 	HostKeyPath string
-	Listener    net.Listener
-	Service     core.Service
-	Handler     CommandHandler
-	IdlePrompt  string
-	AuthStore   LoginAuthStore
+	// HostKeys are more host key files served next to HostKeyPath, so
+	// clients can pick the algorithm they prefer. Missing ones are
+	// generated.
+	HostKeys  []string
+	Listener  net.Listener
+	Service   core.Service
+	Handler   CommandHandler
+	AuthStore LoginAuthStore
+	// Provisioner, when set, creates unknown users on their first login
+	// with a public key it trusts.
+	Provisioner UserProvisioner
 	EventBus    *eventbus.Bus
-	logger      pslog.Logger
+	// BannerFile is shown to clients before authentication.
+	BannerFile string
+	// MOTDFile is rendered into the user's system buffer when a session
+	// starts.
+	MOTDFile string
+	// PromptTemplate renders the input line prefix from the user and the
+	// active tab. Empty selects sshprompt.DefaultTemplate.
+	PromptTemplate string
+	// ShowTabNumbers prefixes tab names in the tab bar with the numbers
+	// Alt+digit and /tab take.
+	ShowTabNumbers bool
+	// OnListen is called with the bound address once the server accepts
+	// connections.
+	OnListen func(net.Addr)
+	logger   pslog.Logger
+	prompt   *sshprompt.Template
 }
 
 // LoginAuthStore validates SSH login credentials and supports password changes.
 type LoginAuthStore interface {
 	HasLoginPubKey(userID schema.UserID, key ssh.PublicKey) (bool, error)
-	ValidateTOTP(username string, totpCode string) error
+	ValidateTOTPFrom(source, username, totpCode string) error
+	AuthenticateFrom(source, username, password, totpCode string) error
 	ChangePassword(username, currentPassword, totpCode, newPassword string) error
+	// ExternalCredentials reports whether an external backend owns
+	// passwords; logins then ask for the password instead of a login key
+	// and TOTP code.
+	ExternalCredentials() bool
+	Capabilities(username string) schema.Capabilities
+}
+
+// UserProvisioner creates accounts for unknown users on first login.
+type UserProvisioner interface {
+	// Trusted reports whether key may create the account of username.
+	Trusted(ctx context.Context, username string, key ssh.PublicKey) (bool, error)
+	// Provision creates the account and returns welcome lines for the
+	// user's system buffer.
+	Provision(ctx context.Context, username string, key ssh.PublicKey) ([]string, error)
 }
 
 type authContextKey string
 
-const loginPubKeyOK authContextKey = "login-pubkey-ok"
+const (
+	loginPubKeyOK     authContextKey = "login-pubkey-ok"
+	loginCapabilities authContextKey = "login-capabilities"
+	loginProvision    authContextKey = "login-provision"
+	loginWelcome      authContextKey = "login-welcome"
+)
 
 // ListenAndServe starts the SSH server and shuts down on context cancellation.
 func (s *Server) ListenAndServe(ctx context.Context) error {
-	if s.IdlePrompt == "" {
-		s.IdlePrompt = "> "
+	prompt, err := sshprompt.Parse(s.PromptTemplate)
+	if err != nil {
+		return fmt.Errorf("ssh prompt template: %w", err)
 	}
+	s.prompt = prompt
 	if s.logger == nil {
 		s.logger = pslog.Ctx(ctx)
 	}
 
-	signer, err := EnsureHostKey(s.HostKeyPath)
+	hostKeys, err := EnsureHostKeys(append([]string{s.HostKeyPath}, s.HostKeys...))
 	if err != nil {
 		return err
 	}
+	for _, key := range hostKeys {
+		if key.Generated {
+			s.logger.Info("ssh host key generated", "path", key.Path, "type", key.Type(), "fingerprint", key.Fingerprint())
+			continue
+		}
+		s.logger.Info("ssh host key loaded", "path", key.Path, "type", key.Type(), "fingerprint", key.Fingerprint())
+	}
 
 	if s.AuthStore == nil {
 		return errors.New("auth store is required for SSH")
@@ -68,16 +124,44 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 		Handler:                    s.handleSession,
 		PublicKeyHandler:           s.handlePublicKey,
 		KeyboardInteractiveHandler: s.handleKeyboardInteractive,
+		SubsystemHandlers:          map[string]gliderssh.SubsystemHandler{"sftp": s.handleSFTP},
+	}
+	if s.Provisioner != nil {
+		server.ServerConfigCallback = func(ctx gliderssh.Context) *ssh.ServerConfig {
+			return &ssh.ServerConfig{
+				VerifiedPublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey, perms *ssh.Permissions, _ string) (*ssh.Permissions, error) {
+					return perms, s.provisionUser(ctx, key)
+				},
+			}
+		}
+	}
+	if s.BannerFile != "" {
+		server.BannerHandler = func(ctx gliderssh.Context) string {
+			return motd.Banner(ctx, s.BannerFile)
+		}
+	}
+	for _, key := range hostKeys {
+		server.AddHostKey(key.Signer)
+	}
+
+	listener := s.Listener
+	if listener == nil {
+		addr := s.Addr
+		if addr == "" {
+			addr = ":22"
+		}
+		listener, err = net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+	}
+	if s.OnListen != nil {
+		s.OnListen(listener.Addr())
 	}
-	server.AddHostKey(signer)
 
 	errCh := make(chan error, 1)
 	go func() {
-		if s.Listener != nil {
-			errCh <- server.Serve(s.Listener)
-			return
-		}
-		errCh <- server.ListenAndServe()
+		errCh <- server.Serve(listener)
 	}()
 
 	select {
@@ -107,6 +191,9 @@ func (s *Server) handlePublicKey(ctx gliderssh.Context, key gliderssh.PublicKey)
 		log = log.With("ssh_session", sshSession)
 	}
 	ok, err := s.AuthStore.HasLoginPubKey(userID, key)
+	if errors.Is(err, auth.ErrUserNotFound) && s.Provisioner != nil {
+		return s.checkProvisionKey(ctx, log, key)
+	}
 	if err != nil {
 		log.Warn("ssh pubkey rejected", "err", err)
 		return false
@@ -120,7 +207,49 @@ func (s *Server) handlePublicKey(ctx gliderssh.Context, key gliderssh.PublicKey)
 	return false
 }
 
+// checkProvisionKey accepts the key of an unknown user when the provisioner
+// trusts it. The account is only created by provisionUser, once the client
+// has proven it holds the private key.
+func (s *Server) checkProvisionKey(ctx gliderssh.Context, log pslog.Logger, key ssh.PublicKey) bool {
+	trusted, err := s.Provisioner.Trusted(ctx, ctx.User(), key)
+	if err != nil {
+		log.Warn("ssh provision rejected", "reason", "key source failed", "err", err)
+		return false
+	}
+	if !trusted {
+		log.Warn("ssh provision rejected", "reason", "key not trusted")
+		return false
+	}
+	ctx.SetValue(loginProvision, true)
+	log.Info("ssh provision key trusted")
+	return true
+}
+
+// provisionUser runs after the client signed with a key checkProvisionKey
+// accepted; failures surface as a failed login.
+func (s *Server) provisionUser(ctx gliderssh.Context, key ssh.PublicKey) error {
+	if ctx.Value(loginProvision) != true {
+		return errors.New("permission denied")
+	}
+	log := s.logger
+	if log == nil {
+		log = pslog.Ctx(ctx)
+	}
+	log = log.With("user", ctx.User(), "remote", remoteAddr(ctx), "fingerprint", ssh.FingerprintSHA256(key))
+	welcome, err := s.Provisioner.Provision(ctx, ctx.User(), key)
+	if err != nil {
+		log.Warn("ssh provision failed", "err", err)
+		return errors.New("permission denied")
+	}
+	ctx.SetValue(loginWelcome, welcome)
+	log.Info("ssh provision ok")
+	return nil
+}
+
 func (s *Server) handleKeyboardInteractive(ctx gliderssh.Context, challenger ssh.KeyboardInteractiveChallenge) bool {
+	if s.AuthStore.ExternalCredentials() {
+		return s.handleExternalPassword(ctx, challenger)
+	}
 	if ctx.Value(loginPubKeyOK) != true {
 		return false
 	}
@@ -146,11 +275,42 @@ func (s *Server) handleKeyboardInteractive(ctx gliderssh.Context, challenger ssh
 		log.Warn("ssh totp rejected", "reason", "invalid answer count", "count", len(answers))
 		return false
 	}
-	if err := s.AuthStore.ValidateTOTP(ctx.User(), answers[0]); err != nil {
+	if err := s.AuthStore.ValidateTOTPFrom(remote, ctx.User(), answers[0]); err != nil {
 		log.Warn("ssh totp rejected", "reason", "invalid code", "err", err)
 		return false
 	}
 	log.Info("ssh totp accepted")
+	ctx.SetValue(loginCapabilities, s.AuthStore.Capabilities(ctx.User()))
+	return true
+}
+
+// handleExternalPassword checks the password against the external backend,
+// which owns second factors as well.
+func (s *Server) handleExternalPassword(ctx gliderssh.Context, challenger ssh.KeyboardInteractiveChallenge) bool {
+	log := s.logger
+	if log == nil {
+		log = pslog.Ctx(ctx)
+	}
+	remote := remoteAddr(ctx)
+	log = log.With("user", ctx.User(), "remote", remote)
+	if sshSession := ctx.SessionID(); sshSession != "" {
+		log = log.With("ssh_session", sshSession)
+	}
+	answers, err := challenger(ctx.User(), "", []string{"Password: "}, []bool{false})
+	if err != nil {
+		log.Warn("ssh password rejected", "reason", "challenge failed", "err", err)
+		return false
+	}
+	if len(answers) != 1 {
+		log.Warn("ssh password rejected", "reason", "invalid answer count", "count", len(answers))
+		return false
+	}
+	if err := s.AuthStore.AuthenticateFrom(remote, ctx.User(), answers[0], ""); err != nil {
+		log.Warn("ssh password rejected", "err", err)
+		return false
+	}
+	ctx.SetValue(loginCapabilities, s.AuthStore.Capabilities(ctx.User()))
+	log.Info("ssh password accepted")
 	return true
 }
 
@@ -179,15 +339,39 @@ func (s *Server) handleSession(sess gliderssh.Session) {
 		log = log.With("ssh_session", sshSession)
 	}
 	ctx := logx.ContextWithUserLogger(sess.Context(), log, userID)
+	welcome := takeWelcome(sess.Context())
+
+	if argv := sess.Command(); len(argv) > 0 && argv[0] == "scp" {
+		writeWelcome(sess.Stderr(), welcome)
+		log.Info("ssh scp session opened", "command", sess.RawCommand())
+		if err := s.serveSCP(ctx, sess, userID, argv); err != nil {
+			_ = sess.Exit(1)
+			return
+		}
+		_ = sess.Exit(0)
+		return
+	}
 
 	pty, winCh, ok := sess.Pty()
 	if !ok {
-		log.Info("ssh session rejected", "reason", "pty required", "user", userID, "remote", sess.RemoteAddr().String())
-		_, _ = io.WriteString(sess, "pty required\n")
+		// Without a pty there is no screen for the TUI: run the command
+		// in line mode, or explain how to connect.
+		command := strings.TrimSpace(sess.RawCommand())
+		if command == "" {
+			log.Info("ssh session rejected", "reason", "pty required", "user", userID, "remote", sess.RemoteAddr().String())
+			_, _ = io.WriteString(sess.Stderr(), lineModeUsage)
+			_ = sess.Exit(2)
+			return
+		}
+		writeWelcome(sess.Stderr(), welcome)
+		log.Info("ssh line command", "command_len", len(command))
+		caps, _ := sess.Context().Value(loginCapabilities).(schema.Capabilities)
+		_ = sess.Exit(s.runLineCommand(ctx, sess, sess.Stderr(), userID, caps, command))
 		return
 	}
 
-	log.Info("ssh session opened", "term", pty.Term)
+	depth := detectColorDepth(pty.Term, sess.Environ())
+	log.Info("ssh session opened", "term", pty.Term, "color_depth", depth.String())
 	var events <-chan eventbus.Event
 	var unsubscribe func()
 	if s.EventBus != nil {
@@ -196,8 +380,46 @@ func (s *Server) handleSession(sess gliderssh.Session) {
 	if unsubscribe != nil {
 		defer unsubscribe()
 	}
-	ui := newTerminalSession(sess, s.Service, s.Handler, s.AuthStore, userID, s.IdlePrompt, events)
+	s.appendMOTD(ctx, userID)
+	ui := newTerminalSession(sess, s.Service, s.Handler, s.AuthStore, userID, s.prompt, depth, events)
+	ui.welcome = welcome
+	ui.caps, _ = sess.Context().Value(loginCapabilities).(schema.Capabilities)
+	ui.showTabNumbers = s.ShowTabNumbers
 	ui.SetSize(pty.Window.Width, pty.Window.Height)
 	_ = ui.Run(ctx, winCh)
 	log.Info("ssh session closed", "term", pty.Term)
 }
+
+// takeWelcome returns the welcome lines of a login that provisioned the
+// user and clears them, so only the first session of the connection shows
+// them.
+func takeWelcome(ctx gliderssh.Context) []string {
+	welcome, _ := ctx.Value(loginWelcome).([]string)
+	if welcome != nil {
+		ctx.SetValue(loginWelcome, []string(nil))
+	}
+	return welcome
+}
+
+// writeWelcome prints the welcome lines to a session without a terminal.
+func writeWelcome(w io.Writer, welcome []string) {
+	for _, line := range welcome {
+		_, _ = io.WriteString(w, line+"\n")
+	}
+}
+
+// appendMOTD writes the message of the day to the user's system buffer once
+// per session.
+func (s *Server) appendMOTD(ctx context.Context, userID schema.UserID) {
+	lines := motd.Lines(ctx, s.MOTDFile, motd.Data{User: userID, Version: version.Current()})
+	s.appendSystemLines(ctx, userID, lines, "motd")
+}
+
+func (s *Server) appendSystemLines(ctx context.Context, userID schema.UserID, lines []string, what string) {
+	if len(lines) == 0 {
+		return
+	}
+	if _, err := s.Service.AppendSystemOutput(ctx, schema.AppendSystemOutputRequest{UserID: userID, Lines: lines}); err != nil {
+		logx.WithUser(ctx, userID).Warn("ssh "+what+" append failed", "err", err)
+	}
+}
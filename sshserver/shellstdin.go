@@ -0,0 +1,129 @@
+package sshserver
+
+import (
+	"strings"
+)
+
+// stdinCaptureState collects the input piped to a `! <cmd> <<` command.
+type stdinCaptureState struct {
+	command string
+}
+
+func (s *stdinCaptureState) prompt() string {
+	return "stdin: "
+}
+
+// isShellStdinCommand reports whether line is `! <cmd> <<`, which reads the
+// command's stdin from the following lines.
+func isShellStdinCommand(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "!") && !strings.HasPrefix(trimmed, "!!") && strings.HasSuffix(trimmed, "<<")
+}
+
+func (t *terminalSession) startStdinCapture(line string) {
+	t.logTab(t.activeTab).Info("tui stdin capture start")
+	t.stdinCapture = &stdinCaptureState{command: strings.TrimSpace(line)}
+	t.editor.Clear()
+	t.historyDirty = true
+	t.notice = "type or paste the input, then a line with a single . or press Ctrl+D"
+	t.requestRedraw()
+}
+
+func (t *terminalSession) cancelStdinCapture() {
+	if t.stdinCapture == nil {
+		return
+	}
+	t.logTab(t.activeTab).Info("tui stdin capture cancel")
+	t.stdinCapture = nil
+	t.editor.Clear()
+	t.notice = ""
+	t.appendMessage(t.activeTab, "piped command cancelled")
+	t.requestRedraw()
+}
+
+// finishStdinCapture runs the command with the collected lines. A final
+// line holding only "." ends the input and is not piped.
+func (t *terminalSession) finishStdinCapture() {
+	if t.stdinCapture == nil {
+		return
+	}
+	command := t.stdinCapture.command
+	input := t.editor.String()
+	if idx := strings.LastIndex(input, "\n"); idx >= 0 && strings.TrimSpace(input[idx+1:]) == "." {
+		input = input[:idx]
+	} else if strings.TrimSpace(input) == "." {
+		input = ""
+	}
+	t.stdinCapture = nil
+	t.editor.Clear()
+	t.notice = ""
+	t.runCommandAsync(command + "\n" + input)
+}
+
+// stdinCaptureEnded reports whether the line being edited is the "."
+// terminator.
+func (t *terminalSession) stdinCaptureEnded() bool {
+	input := t.editor.String()
+	if idx := strings.LastIndex(input, "\n"); idx >= 0 {
+		input = input[idx+1:]
+	}
+	return strings.TrimSpace(input) == "."
+}
+
+func (t *terminalSession) handleStdinCaptureKey(k key) bool {
+	switch k.kind {
+	case keyCtrlC:
+		t.cancelStdinCapture()
+	case keyCtrlD:
+		t.finishStdinCapture()
+	case keyEnter, keyCtrlJ:
+		if t.stdinCaptureEnded() {
+			t.finishStdinCapture()
+			break
+		}
+		t.cancelScroll()
+		t.editor.InsertRune('\n')
+	case keyCtrlA, keyHome:
+		t.editor.MoveStart()
+	case keyCtrlE, keyEnd:
+		t.editor.MoveEnd()
+	case keyAltB:
+		t.editor.MoveWordLeft()
+	case keyAltF:
+		t.editor.MoveWordRight()
+	case keyCtrlW:
+		t.cancelScroll()
+		t.editor.DeleteWordBackward()
+	case keyCtrlU:
+		t.cancelScroll()
+		t.editor.KillLineStart()
+	case keyCtrlK:
+		t.cancelScroll()
+		t.editor.KillLineEnd()
+	case keyLeft:
+		t.editor.MoveLeft()
+	case keyRight:
+		t.editor.MoveRight()
+	case keyUp:
+		t.editor.MoveUp()
+	case keyDown:
+		t.editor.MoveDown()
+	case keyBackspace:
+		t.cancelScroll()
+		t.editor.Backspace()
+	case keyDelete:
+		t.cancelScroll()
+		t.editor.Delete()
+	case keyRune:
+		t.cancelScroll()
+		t.editor.InsertRune(k.r)
+	case keyPageUp:
+		t.scroll(1)
+	case keyPageDown:
+		t.scroll(-1)
+	case keyTab, keyShiftTab:
+		// Tabs stay put while the input is collected.
+	}
+	t.dirty = true
+	return false
+}
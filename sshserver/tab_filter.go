@@ -0,0 +1,64 @@
+package sshserver
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// tabFilterCommand reports whether line is `/tabfilter [<label>|off]` and
+// returns its argument.
+func tabFilterCommand(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "/tabfilter" {
+		return "", false
+	}
+	if len(fields) != 2 {
+		return "", true
+	}
+	return fields[1], true
+}
+
+// setTabFilter limits the tab bar and tab cycling to tabs with a label, or
+// shows all tabs again with off. The filter lasts for the session.
+func (t *terminalSession) setTabFilter(arg string) {
+	if arg == "" {
+		t.appendError(t.activeTab, fmt.Errorf("usage: /tabfilter <label>|off"))
+		return
+	}
+	if strings.EqualFold(arg, "off") {
+		t.tabFilter = ""
+		t.appendMessage(t.activeTab, "tab filter: off")
+		t.log().Info("tui tab filter cleared")
+		return
+	}
+	label, err := schema.NormalizeTabLabel(arg)
+	if err != nil {
+		t.appendError(t.activeTab, err)
+		return
+	}
+	t.tabFilter = label
+	t.appendMessage(t.activeTab, fmt.Sprintf("tab filter: %s (%d tabs)", label, len(t.visibleTabs())))
+	t.log().Info("tui tab filter set", "label", label)
+}
+
+// visibleTabs returns the tabs the tab bar shows. With a filter set these are
+// the tabs carrying its label, plus the active tab so it never disappears.
+func (t *terminalSession) visibleTabs() []schema.TabSnapshot {
+	if t.tabFilter == "" {
+		return t.tabs
+	}
+	return filterTabs(t.tabs, t.tabFilter, t.activeTab)
+}
+
+func filterTabs(tabs []schema.TabSnapshot, label schema.TabLabel, active schema.TabID) []schema.TabSnapshot {
+	out := make([]schema.TabSnapshot, 0, len(tabs))
+	for _, tab := range tabs {
+		if tab.ID == active || slices.Contains(tab.Labels, label) {
+			out = append(out, tab)
+		}
+	}
+	return out
+}
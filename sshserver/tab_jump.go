@@ -0,0 +1,55 @@
+package sshserver
+
+import (
+	"fmt"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// altDigitTab maps the digit of Alt+1 … Alt+9 to tab 1 … 9 and Alt+0 to
+// tab 10.
+func altDigitTab(r rune) int {
+	if r == '0' {
+		return 10
+	}
+	return int(r - '0')
+}
+
+// jumpToTab activates the nth tab (1-based) in the order /rm and /tabs
+// number them. The tab filter does not change the numbering.
+func (t *terminalSession) jumpToTab(n int) {
+	if n <= 0 || n > len(t.tabs) {
+		message := fmt.Sprintf("no tab %d (%d open)", n, len(t.tabs))
+		if t.activeTab == "" {
+			t.appendNotice(message)
+		} else {
+			t.appendMessage(t.activeTab, message)
+		}
+		return
+	}
+	next := t.tabs[n-1]
+	if next.ID == t.activeTab {
+		return
+	}
+	prev := t.activeTab
+	_, _ = t.service.ActivateTab(t.ctx, schema.ActivateTabRequest{
+		UserID: t.userID,
+		TabID:  next.ID,
+	})
+	t.activeTab = next.ID
+	t.refreshState()
+	t.logTab(t.activeTab).Debug("tui tab jumped", "from", prev, "to", next.ID, "number", n)
+}
+
+// tabNumbers returns the number of each tab for the tab bar, or nil when
+// ssh.show_tab_numbers is off.
+func (t *terminalSession) tabNumbers() map[schema.TabID]int {
+	if !t.showTabNumbers {
+		return nil
+	}
+	numbers := make(map[schema.TabID]int, len(t.tabs))
+	for i, tab := range t.tabs {
+		numbers[tab.ID] = i + 1
+	}
+	return numbers
+}
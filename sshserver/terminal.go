@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -14,6 +16,8 @@ import (
 	"pkt.systems/centaurx/core"
 	"pkt.systems/centaurx/internal/eventbus"
 	"pkt.systems/centaurx/internal/sessionprefs"
+	"pkt.systems/centaurx/internal/sshprompt"
+	"pkt.systems/centaurx/internal/timefmt"
 	"pkt.systems/centaurx/schema"
 	"pkt.systems/pslog"
 )
@@ -24,6 +28,8 @@ type terminalSession struct {
 	handler    CommandHandler
 	authStore  LoginAuthStore
 	userID     schema.UserID
+	caps       schema.Capabilities
+	prompt     *sshprompt.Template
 	promptIdle string
 	screen     *screen
 	ctx        context.Context
@@ -35,16 +41,32 @@ type terminalSession struct {
 	tabs           []schema.TabSnapshot
 	activeTab      schema.TabID
 	tabWindowStart int
-	buffer         schema.BufferSnapshot
-	system         schema.SystemBufferSnapshot
-	tabStatus      map[schema.TabID]schema.TabStatus
-	queues         map[schema.TabID][]string
-	themeName      schema.ThemeName
+	// tabFilter limits the tab bar and tab cycling to tabs with this
+	// label; see /tabfilter.
+	tabFilter schema.TabLabel
+	// showTabNumbers prefixes tab bar names with the numbers Alt+digit and
+	// /tab take.
+	showTabNumbers bool
+	// welcome holds the credentials of a user provisioned by this login.
+	// They are shown below the system buffer for this session only and never
+	// reach the service, so they are not stored or searchable.
+	welcome []string
+
+	buffer     schema.BufferSnapshot
+	system     schema.SystemBufferSnapshot
+	tabStatus  map[schema.TabID]schema.TabStatus
+	queues     map[schema.TabID][]string
+	themeName  schema.ThemeName
+	colorDepth colorDepth
+	preview    *tuiTheme
+	clock      timefmt.Clock
+	lineCache  *lineCache
 
 	editor         lineEditor
 	notice         string
 	spinnerIdx     int
 	running        bool
+	stopping       bool
 	commandActive  atomic.Int32
 	commandSpinner atomic.Bool
 	dirty          bool
@@ -54,10 +76,14 @@ type terminalSession struct {
 	historyIndex int
 	historyDirty bool
 	historyTabID schema.TabID
+	historyScope schema.HistoryScope
 
 	chpasswd  *chpasswdState
 	codexauth *codexAuthState
 	rotateSSH *rotateSSHKeyState
+	// stdinCapture is set while the input of a `! <cmd> <<` command is
+	// being typed.
+	stdinCapture *stdinCaptureState
 }
 
 type chpasswdStep int
@@ -106,20 +132,23 @@ func (r *rotateSSHKeyState) prompt() string {
 	return "type YES to rotate SSH key: "
 }
 
-func newTerminalSession(sess gliderssh.Session, service core.Service, handler CommandHandler, authStore LoginAuthStore, userID schema.UserID, idlePrompt string, events <-chan eventbus.Event) *terminalSession {
+func newTerminalSession(sess gliderssh.Session, service core.Service, handler CommandHandler, authStore LoginAuthStore, userID schema.UserID, prompt *sshprompt.Template, depth colorDepth, events <-chan eventbus.Event) *terminalSession {
 	return &terminalSession{
 		sess:         sess,
 		service:      service,
 		handler:      handler,
 		authStore:    authStore,
 		userID:       userID,
-		promptIdle:   idlePrompt,
+		prompt:       prompt,
+		promptIdle:   prompt.Render(sshprompt.Data{User: userID}),
+		colorDepth:   depth,
 		screen:       newScreen(sess),
 		events:       events,
 		tabStatus:    make(map[schema.TabID]schema.TabStatus),
 		queues:       make(map[schema.TabID][]string),
 		historyIndex: -1,
 		redrawCh:     make(chan struct{}, 1),
+		lineCache:    newLineCache(),
 	}
 }
 
@@ -146,15 +175,62 @@ func (t *terminalSession) SetSize(width, height int) {
 	if height <= 0 {
 		height = 24
 	}
+	if t.screen != nil && (width != t.width || height != t.height) {
+		t.screen.Invalidate()
+	}
 	t.width = width
 	t.height = height
+	if prefs := sessionprefs.FromContext(t.ctx); prefs != nil {
+		prefs.TermRows, prefs.TermCols = height, width
+	}
+}
+
+// resizeCommands forwards the terminal size to the PTY commands (started
+// with !!) running in the session's tabs.
+func (t *terminalSession) resizeCommands() {
+	tracker, ok := t.service.(core.CommandTracker)
+	if !ok {
+		return
+	}
+	tabIDs := make([]schema.TabID, 0, len(t.tabs))
+	for _, tab := range t.tabs {
+		tabIDs = append(tabIDs, tab.ID)
+	}
+	ctx, userID, rows, cols := t.ctx, t.userID, t.height, t.width
+	go func() {
+		for _, tabID := range tabIDs {
+			tracker.ResizeCommands(ctx, userID, tabID, rows, cols)
+		}
+	}()
+}
+
+// interruptCommand sends SIGINT to the active tab's most recent command if
+// it runs on a pseudo-terminal, and reports whether it did.
+func (t *terminalSession) interruptCommand() bool {
+	tracker, ok := t.service.(core.CommandTracker)
+	if !ok || t.activeTab == "" {
+		return false
+	}
+	sent, err := tracker.InterruptCommand(t.ctx, t.userID, t.activeTab)
+	if !sent {
+		return false
+	}
+	if err != nil {
+		t.appendNotice(fmt.Sprintf("interrupt failed: %v", err))
+	} else {
+		t.appendNotice("interrupt sent")
+	}
+	return true
 }
 
 func (t *terminalSession) Run(ctx context.Context, winCh <-chan gliderssh.Window) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	t.ctx = sessionprefs.WithContext(ctx, sessionprefs.New())
+	prefs := sessionprefs.New()
+	prefs.TermRows, prefs.TermCols = t.height, t.width
+	prefs.Capabilities = t.caps
+	t.ctx = sessionprefs.WithContext(ctx, prefs)
 	defer t.saveHistoryOnExit()
 	t.screen.EnterAltScreen()
 	defer t.screen.ExitAltScreen()
@@ -187,6 +263,7 @@ func (t *terminalSession) Run(ctx context.Context, winCh <-chan gliderssh.Window
 		case win, ok := <-winCh:
 			if ok {
 				t.SetSize(win.Width, win.Height)
+				t.resizeCommands()
 				t.refreshBuffer()
 				t.dirty = true
 				t.log().Debug("tui resize", "width", t.width, "height", t.height)
@@ -237,6 +314,12 @@ func (t *terminalSession) handleEvent(ev eventbus.Event) {
 }
 
 func (t *terminalSession) handleKey(k key) bool {
+	if t.preview != nil {
+		// Any key dismisses a theme preview and is otherwise ignored.
+		t.preview = nil
+		t.dirty = true
+		return false
+	}
 	if t.codexauth != nil {
 		return t.handleCodexAuthKey(k)
 	}
@@ -246,6 +329,9 @@ func (t *terminalSession) handleKey(k key) bool {
 	if t.rotateSSH != nil {
 		return t.handleRotateSSHKeyKey(k)
 	}
+	if t.stdinCapture != nil {
+		return t.handleStdinCaptureKey(k)
+	}
 	switch k.kind {
 	case keyCtrlD:
 		if t.editor.Len() == 0 {
@@ -256,6 +342,9 @@ func (t *terminalSession) handleKey(k key) bool {
 		t.cancelScroll()
 		t.editor.Delete()
 	case keyCtrlC:
+		if t.interruptCommand() {
+			break
+		}
 		t.editor.Clear()
 		t.historyDirty = true
 	case keyEnter:
@@ -306,6 +395,8 @@ func (t *terminalSession) handleKey(k key) bool {
 		t.cycleTab(1)
 	case keyShiftTab:
 		t.cycleTab(-1)
+	case keyAltDigit:
+		t.jumpToTab(altDigitTab(k.r))
 	case keyUp:
 		if t.editor.cursor == 0 || t.editor.cursor == t.editor.Len() {
 			t.historyUp()
@@ -396,9 +487,33 @@ func (t *terminalSession) handleEnter() bool {
 			t.startRotateSSHKey()
 			return false
 		}
+		if name, ok := themePreviewCommand(line); ok {
+			t.startThemePreview(name)
+			return false
+		}
+		if isShellStdinCommand(line) {
+			t.startStdinCapture(line)
+			return false
+		}
+		if arg, ok := tabFilterCommand(line); ok {
+			t.setTabFilter(arg)
+			return false
+		}
+		if isThemeListCommand(line) && t.colorDepth.limited() {
+			t.listThemes()
+			return false
+		}
+		if line == "/redo" {
+			t.redoLastPrompt()
+			return false
+		}
+		if line == "/edit-last" {
+			t.editLastPrompt()
+			return false
+		}
 		if strings.HasPrefix(line, "/") || strings.HasPrefix(line, "!") {
 			t.logTab(t.activeTab).Debug("tui command", "input", line)
-			if isStatusCommand(line) || isNewCommand(line) || strings.HasPrefix(line, "!") {
+			if isStatusCommand(line) || isNewCommand(line) || isGitCommand(line) || strings.HasPrefix(line, "!") {
 				t.runCommandAsync(line)
 				return false
 			}
@@ -410,27 +525,88 @@ func (t *terminalSession) handleEnter() bool {
 		}
 	}
 
+	t.submitPrompt(raw)
+	return false
+}
+
+// submitPrompt sends raw to the active tab, or queues it while the tab is
+// running.
+func (t *terminalSession) submitPrompt(raw string) {
 	if t.activeTab == "" {
 		t.log().Warn("tui prompt rejected", "reason", "no active tab")
-		t.appendNotice("no active tab; use /new <repo>")
-		return false
+		t.appendNotice(schema.ErrNoActiveTab.Error())
+		return
 	}
 
-	if t.tabStatus[t.activeTab] == schema.TabStatusRunning {
+	switch t.tabStatus[t.activeTab] {
+	case schema.TabStatusStopping:
+		// A prompt queued now would start right after the stop, which is
+		// rarely what the user stopping the run wants.
+		t.logTab(t.activeTab).Debug("tui prompt rejected", "reason", "stopping")
+		t.appendNotice("tab is stopping; send the prompt once it is idle")
+		return
+	case schema.TabStatusRunning:
 		t.logTab(t.activeTab).Debug("tui prompt queued", "len", len(raw))
 		t.queuePrompt(t.activeTab, raw)
-		return false
+		return
 	}
 
 	if err := t.sendPrompt(t.activeTab, raw); err != nil {
 		if errors.Is(err, schema.ErrTabBusy) {
 			t.logTab(t.activeTab).Debug("tui prompt queued", "reason", "busy")
 			t.queuePrompt(t.activeTab, raw)
-			return false
+			return
+		}
+		if errors.Is(err, schema.ErrTokenBudgetExceeded) {
+			// The service wrote the budget and reset time to the tab.
+			return
 		}
 		t.appendError(t.activeTab, err)
 	}
-	return false
+}
+
+// lastPrompt returns the most recent prompt of the active tab.
+func (t *terminalSession) lastPrompt() (string, bool) {
+	if t.activeTab == "" {
+		t.appendNotice(schema.ErrNoActiveTab.Error())
+		return "", false
+	}
+	resp, err := t.service.GetLastPrompt(t.ctx, schema.GetLastPromptRequest{
+		UserID: t.userID,
+		TabID:  t.activeTab,
+	})
+	if err != nil {
+		t.logTab(t.activeTab).Debug("tui last prompt unavailable", "err", err)
+		t.appendError(t.activeTab, err)
+		return "", false
+	}
+	return resp.Prompt, true
+}
+
+// redoLastPrompt resends the most recent prompt of the active tab as if it
+// had been typed: it is recorded in history and queued while the tab runs.
+func (t *terminalSession) redoLastPrompt() {
+	prompt, ok := t.lastPrompt()
+	if !ok {
+		return
+	}
+	t.logTab(t.activeTab).Info("tui prompt redo", "len", len(prompt))
+	t.saveHistoryEntry(prompt)
+	t.submitPrompt(prompt)
+}
+
+// editLastPrompt loads the most recent prompt of the active tab into the
+// editor without sending it.
+func (t *terminalSession) editLastPrompt() {
+	prompt, ok := t.lastPrompt()
+	if !ok {
+		return
+	}
+	t.logTab(t.activeTab).Debug("tui prompt edit last", "len", len(prompt))
+	t.editor.SetString(prompt)
+	t.historyIndex = -1
+	t.historyDirty = true
+	t.requestRedraw()
 }
 
 func (t *terminalSession) handleCommand(line string) error {
@@ -456,6 +632,10 @@ func (t *terminalSession) startChpasswd() {
 		t.appendError(t.activeTab, errors.New("password change unavailable"))
 		return
 	}
+	if t.authStore.ExternalCredentials() {
+		t.appendError(t.activeTab, schema.ErrManagedExternally)
+		return
+	}
 	log := t.log()
 	if t.activeTab != "" {
 		log = log.With("tab", t.activeTab)
@@ -598,6 +778,11 @@ func (t *terminalSession) finishCodexAuth() {
 		t.appendError(t.activeTab, err)
 		return
 	}
+	_, _ = t.service.RecordActivity(t.ctx, schema.RecordActivityRequest{
+		UserID: t.userID,
+		Kind:   schema.ActivityCodexAuthUpdated,
+		Detail: "via ssh",
+	})
 	t.appendMessage(t.activeTab, "codex auth updated")
 }
 
@@ -752,7 +937,12 @@ func (t *terminalSession) submitChpasswdField() {
 func (t *terminalSession) runCommandAsync(line string) {
 	stopSpinner := t.startCommandSpinner(commandSpinnerDelay)
 	tabID := t.activeTab
-	t.logTab(tabID).Debug("tui command async start", "input", line)
+	if command, stdin, piped := strings.Cut(line, "\n"); piped {
+		// Piped input is only logged by size.
+		t.logTab(tabID).Debug("tui command async start", "input", command, "stdin_len", len(stdin))
+	} else {
+		t.logTab(tabID).Debug("tui command async start", "input", line)
+	}
 	go func() {
 		defer stopSpinner()
 		if t.handler == nil {
@@ -823,6 +1013,7 @@ func (t *terminalSession) refreshState() {
 	prevActive := t.activeTab
 	prevStatus := t.tabStatus
 	prevRunning := t.running
+	prevStopping := t.stopping
 	prevTheme := t.themeName
 	resp, err := t.service.ListTabs(t.ctx, schema.ListTabsRequest{UserID: t.userID})
 	if err != nil {
@@ -835,13 +1026,19 @@ func (t *terminalSession) refreshState() {
 	if t.themeName == "" {
 		t.themeName = schema.DefaultTheme
 	}
+	if clock, err := timefmt.New(resp.TimeFormat, resp.Timezone); err == nil {
+		t.clock = clock
+	}
 	t.tabStatus = make(map[schema.TabID]schema.TabStatus, len(resp.Tabs))
 	for _, tab := range resp.Tabs {
 		t.tabStatus[tab.ID] = tab.Status
 	}
-	t.running = t.tabStatus[t.activeTab] == schema.TabStatusRunning
+	t.running = t.tabStatus[t.activeTab].Busy()
+	t.stopping = t.tabStatus[t.activeTab] == schema.TabStatusStopping
+	prevPrompt := t.promptIdle
+	t.promptIdle = t.renderIdlePrompt()
 	bufferChanged := t.refreshBuffer()
-	if prevActive != t.activeTab || t.historyTabID != t.activeTab {
+	if prevActive != t.activeTab || t.historyTabID != t.activeTab || t.historyScope != t.historyScopePref() {
 		t.refreshHistory()
 	}
 
@@ -855,7 +1052,15 @@ func (t *terminalSession) refreshState() {
 		}
 		prompt := queue[0]
 		if err := t.sendPrompt(tab.ID, prompt); err != nil {
-			if errors.Is(err, schema.ErrTabBusy) {
+			// Another tab running in the same repo holds the prompt back
+			// like a busy tab does.
+			if errors.Is(err, schema.ErrTabBusy) || errors.Is(err, schema.ErrRepoBusy) {
+				continue
+			}
+			if errors.Is(err, schema.ErrTokenBudgetExceeded) {
+				// The rest of the queue would be refused too; the service
+				// wrote why to the tab.
+				delete(t.queues, tab.ID)
 				continue
 			}
 			t.appendError(tab.ID, err)
@@ -866,7 +1071,9 @@ func (t *terminalSession) refreshState() {
 	stateChanged := bufferChanged ||
 		prevActive != t.activeTab ||
 		prevRunning != t.running ||
+		prevStopping != t.stopping ||
 		prevTheme != t.themeName ||
+		prevPrompt != t.promptIdle ||
 		!tabsEqual(prevTabs, t.tabs) ||
 		!tabStatusEqual(prevStatus, t.tabStatus)
 	if stateChanged {
@@ -883,12 +1090,14 @@ func (t *terminalSession) refreshHistory() {
 		t.historyTabID = ""
 		return
 	}
-	if t.activeTab == t.historyTabID {
+	scope := t.historyScopePref()
+	if t.activeTab == t.historyTabID && t.historyScope == scope {
 		return
 	}
 	resp, err := t.service.GetHistory(t.ctx, schema.GetHistoryRequest{
 		UserID: t.userID,
 		TabID:  t.activeTab,
+		Scope:  scope,
 	})
 	if err != nil {
 		t.logTab(t.activeTab).Warn("tui history refresh failed", "err", err)
@@ -896,20 +1105,37 @@ func (t *terminalSession) refreshHistory() {
 		t.historyIndex = -1
 		t.historyDirty = false
 		t.historyTabID = t.activeTab
+		t.historyScope = scope
 		return
 	}
 	t.history = resp.Entries
 	t.historyIndex = -1
 	t.historyDirty = false
 	t.historyTabID = t.activeTab
-	t.logTab(t.activeTab).Trace("tui history refreshed", "entries", len(t.history))
+	t.historyScope = scope
+	t.logTab(t.activeTab).Trace("tui history refreshed", "scope", scope, "entries", len(t.history))
+}
+
+// historyScopePref reports the history Up/Down navigates, as selected by the
+// session preference.
+func (t *terminalSession) historyScopePref() schema.HistoryScope {
+	if prefs := sessionprefs.FromContext(t.ctx); prefs != nil && prefs.GlobalHistory {
+		return schema.HistoryScopeGlobal
+	}
+	return schema.HistoryScopeTab
+}
+
+func (t *terminalSession) timestampsPref() bool {
+	prefs := sessionprefs.FromContext(t.ctx)
+	return prefs != nil && prefs.Timestamps
 }
 
 func (t *terminalSession) refreshBuffer() bool {
 	if t.activeTab == "" {
 		resp, err := t.service.GetSystemBuffer(t.ctx, schema.GetSystemBufferRequest{
-			UserID: t.userID,
-			Limit:  t.viewHeight(),
+			UserID:     t.userID,
+			Limit:      t.viewHeight(),
+			Structured: t.timestampsPref(),
 		})
 		if err != nil {
 			t.log().Warn("tui system buffer failed", "err", err)
@@ -924,9 +1150,10 @@ func (t *terminalSession) refreshBuffer() bool {
 	}
 	limit := t.viewHeight()
 	resp, err := t.service.GetBuffer(t.ctx, schema.GetBufferRequest{
-		UserID: t.userID,
-		TabID:  t.activeTab,
-		Limit:  limit,
+		UserID:     t.userID,
+		TabID:      t.activeTab,
+		Limit:      limit,
+		Structured: t.timestampsPref(),
 	})
 	if err != nil {
 		t.logTab(t.activeTab).Warn("tui buffer refresh failed", "err", err)
@@ -938,7 +1165,8 @@ func (t *terminalSession) refreshBuffer() bool {
 }
 
 func (t *terminalSession) cycleTab(step int) {
-	if len(t.tabs) == 0 {
+	tabs := t.visibleTabs()
+	if len(tabs) == 0 {
 		return
 	}
 	if step == 0 {
@@ -947,14 +1175,14 @@ func (t *terminalSession) cycleTab(step int) {
 	var next schema.TabSnapshot
 	if t.activeTab == "" {
 		if step < 0 {
-			next = t.tabs[len(t.tabs)-1]
+			next = tabs[len(tabs)-1]
 		} else {
-			next = t.tabs[0]
+			next = tabs[0]
 		}
 	} else {
 		activeIndex := 0
 		found := false
-		for i, tab := range t.tabs {
+		for i, tab := range tabs {
 			if tab.ID == t.activeTab {
 				activeIndex = i
 				found = true
@@ -966,17 +1194,17 @@ func (t *terminalSession) cycleTab(step int) {
 		}
 		if activeIndex < 0 {
 			if step < 0 {
-				next = t.tabs[len(t.tabs)-1]
+				next = tabs[len(tabs)-1]
 			} else {
-				next = t.tabs[0]
+				next = tabs[0]
 			}
 		} else {
 			nextIndex := activeIndex + step
 			for nextIndex < 0 {
-				nextIndex += len(t.tabs)
+				nextIndex += len(tabs)
 			}
-			nextIndex = nextIndex % len(t.tabs)
-			next = t.tabs[nextIndex]
+			nextIndex = nextIndex % len(tabs)
+			next = tabs[nextIndex]
 		}
 	}
 	if next.ID == t.activeTab {
@@ -1096,6 +1324,7 @@ func (t *terminalSession) saveHistoryDraft() bool {
 		UserID: t.userID,
 		TabID:  t.activeTab,
 		Entry:  entry,
+		Scope:  t.historyScopePref(),
 	})
 	if err != nil {
 		t.logTab(t.activeTab).Warn("tui history save failed", "err", err)
@@ -1103,6 +1332,7 @@ func (t *terminalSession) saveHistoryDraft() bool {
 	}
 	t.history = resp.Entries
 	t.historyTabID = t.activeTab
+	t.historyScope = resp.Scope
 	t.historyDirty = false
 	t.logTab(t.activeTab).Trace("tui history saved", "entries", len(t.history))
 	return appended
@@ -1119,6 +1349,7 @@ func (t *terminalSession) saveHistoryEntry(entry string) {
 		UserID: t.userID,
 		TabID:  t.activeTab,
 		Entry:  entry,
+		Scope:  t.historyScopePref(),
 	})
 	if err != nil {
 		t.logTab(t.activeTab).Warn("tui history save failed", "err", err)
@@ -1126,6 +1357,7 @@ func (t *terminalSession) saveHistoryEntry(entry string) {
 	}
 	t.history = resp.Entries
 	t.historyTabID = t.activeTab
+	t.historyScope = resp.Scope
 	t.logTab(t.activeTab).Trace("tui history saved", "entries", len(t.history))
 }
 
@@ -1233,24 +1465,30 @@ func (t *terminalSession) render() {
 	}
 	lines := make([]string, 0, height)
 	theme := themeForName(t.themeName)
-	tabLine, windowStart := renderTabBar(t.tabs, t.activeTab, width, theme, t.tabWindowStart)
+	tabLine, windowStart := renderTabBar(t.visibleTabs(), t.activeTab, t.tabNumbers(), width, theme, t.tabWindowStart)
 	t.tabWindowStart = windowStart
 	lines = append(lines, tabLine)
 
 	viewLines := t.buffer.Lines
+	entries := t.buffer.Structured
 	if t.activeTab == "" {
+		entries = nil
 		if t.notice != "" {
 			viewLines = []string{t.notice}
+		} else if len(t.welcome) > 0 {
+			viewLines = append(slices.Clip(t.system.Lines), t.welcome...)
 		} else if len(t.system.Lines) > 0 {
 			viewLines = t.system.Lines
+			entries = t.system.Structured
 		} else {
-			viewLines = []string{"no active tab; use /new <repo>"}
+			viewLines = []string{schema.ErrNoActiveTab.Error()}
 		}
 	}
 
 	prefix, input := t.inputDisplay()
 	inputLines, cursorRow, cursorCol := renderInputLines(stylePromptPrefix(prefix, theme), input, t.editor.cursor, width)
-	outputHeight := height - 1 - len(inputLines)
+	banner := t.errorBanner(width, theme)
+	outputHeight := height - 1 - len(inputLines) - len(banner)
 	if outputHeight < 0 {
 		outputHeight = 0
 	}
@@ -1259,8 +1497,21 @@ func (t *terminalSession) render() {
 	if t.activeTab == "" {
 		atBottom = t.system.AtBottom
 	}
-	lines = append(lines, renderViewport(viewLines, width, outputHeight, theme, atBottom)...)
+	view := viewport{lines: viewLines, width: width, height: outputHeight, theme: theme, atBottom: atBottom, clock: t.clock}
+	if t.timestampsPref() && width-view.timestampColumnWidth() >= minTimestampContentWidth {
+		view.stamps = lineStamps(entries, len(viewLines))
+	}
+	if t.lineCache == nil {
+		t.lineCache = newLineCache()
+	}
+	if t.preview != nil {
+		lines = append(lines, renderThemePreview(*t.preview, width, outputHeight)...)
+	} else {
+		t.lineCache.begin(view.contentWidth(), t.themeName)
+		lines = append(lines, renderViewport(view, t.lineCache)...)
+	}
 
+	lines = append(lines, banner...)
 	lines = append(lines, inputLines...)
 	cursorRow = len(lines) - len(inputLines) + cursorRow
 	if err := t.screen.Render(lines, cursorRow, cursorCol); err != nil {
@@ -1278,31 +1529,122 @@ func (t *terminalSession) inputDisplay() (string, string) {
 		input = maskInput(input)
 	} else if t.rotateSSH != nil {
 		prefix = t.rotateSSH.prompt()
+	} else if t.stdinCapture != nil {
+		prefix = t.stdinCapture.prompt()
 	}
 	return prefix, input
 }
 
-func renderViewport(viewLines []string, width, height int, theme tuiTheme, atBottom bool) []string {
+// viewport describes the output area rendered between the tab bar and the
+// input line.
+type viewport struct {
+	lines []string
+	// stamps holds the append time of each line when the timestamp column is
+	// shown; nil hides the column.
+	stamps []time.Time
+	// clock formats the timestamp column.
+	clock    timefmt.Clock
+	width    int
+	height   int
+	theme    tuiTheme
+	atBottom bool
+}
+
+// minTimestampContentWidth is the narrowest content width that still shows
+// the timestamp column.
+const minTimestampContentWidth = 20
+
+// timestampColumnWidth is the width of the timestamp prefix column, including
+// the separating space.
+func (v viewport) timestampColumnWidth() int {
+	return v.clock.Width() + 1
+}
+
+// contentWidth is the width available to line content after the timestamp
+// column.
+func (v viewport) contentWidth() int {
+	if v.stamps == nil {
+		return v.width
+	}
+	return v.width - v.timestampColumnWidth()
+}
+
+// rows renders line i into terminal rows. With timestamps shown, the first row
+// is prefixed with the append time and continuation rows are indented to
+// match. Worked-for separators keep spanning the full width.
+func (v viewport) rows(i int, cache *lineCache) []string {
+	raw := v.lines[i]
+	if v.stamps == nil {
+		return cache.render(raw, v.width, v.theme)
+	}
+	if strings.HasPrefix(raw, schema.WorkedForMarker) {
+		return []string{renderLine(raw, v.width, v.theme)}
+	}
+	rows := cache.render(raw, v.contentWidth(), v.theme)
+	columnWidth := v.timestampColumnWidth()
+	indent := strings.Repeat(" ", columnWidth)
+	first := indent
+	if stamp := v.stamps[i]; !stamp.IsZero() {
+		text := v.clock.Format(stamp)
+		pad := max(columnWidth-utf8.RuneCountInString(text), 1)
+		first = ansiDim + ansiFgRGB(v.theme.MetaFG) + text + ansiReset + strings.Repeat(" ", pad)
+	}
+	stamped := make([]string, len(rows))
+	for j, row := range rows {
+		if j == 0 {
+			stamped[j] = first + row
+		} else {
+			stamped[j] = indent + row
+		}
+	}
+	return stamped
+}
+
+// lineStamps returns the append times of entries when they correspond to the
+// n rendered lines, or nil otherwise.
+func lineStamps(entries []schema.BufferLine, n int) []time.Time {
+	if n == 0 || len(entries) != n {
+		return nil
+	}
+	stamps := make([]time.Time, n)
+	for i, entry := range entries {
+		stamps[i] = entry.Timestamp
+	}
+	return stamps
+}
+
+func renderViewport(view viewport, cache *lineCache) []string {
+	height := view.height
 	if height <= 0 {
 		return nil
 	}
 	rendered := make([]string, 0, height)
-	if atBottom {
-		var flattened []string
-		for _, raw := range viewLines {
-			flattened = append(flattened, renderLines(raw, width, theme)...)
+	if view.atBottom {
+		// Walk backwards so only the lines that reach the viewport are rendered.
+		var tail [][]string
+		count := 0
+		for i := len(view.lines) - 1; i >= 0 && count < height; i-- {
+			lines := view.rows(i, cache)
+			tail = append(tail, lines)
+			count += len(lines)
 		}
-		if len(flattened) > height {
-			flattened = flattened[len(flattened)-height:]
+		skip := count - height
+		for i := len(tail) - 1; i >= 0; i-- {
+			lines := tail[i]
+			if skip > 0 {
+				drop := min(skip, len(lines))
+				lines = lines[drop:]
+				skip -= drop
+			}
+			rendered = append(rendered, lines...)
 		}
-		rendered = append(rendered, flattened...)
 	} else {
 		count := 0
-		for _, raw := range viewLines {
+		for i := range view.lines {
 			if count >= height {
 				break
 			}
-			for _, line := range renderLines(raw, width, theme) {
+			for _, line := range view.rows(i, cache) {
 				if count >= height {
 					break
 				}
@@ -1318,13 +1660,84 @@ func renderViewport(viewLines []string, width, height int, theme tuiTheme, atBot
 }
 
 func (t *terminalSession) promptPrefix() string {
+	width := t.width
+	if width <= 0 {
+		width = 80
+	}
 	if (t.running || t.commandSpinner.Load()) && len(spinnerFrames) > 0 {
-		return fmt.Sprintf("%c ", spinnerFrames[t.spinnerIdx])
+		spinner := fmt.Sprintf("%c ", spinnerFrames[t.spinnerIdx])
+		if t.stopping {
+			return spinner + stoppingLabel + " "
+		}
+		if progress := t.activeProgress(); t.running && progress != "" {
+			return truncatePrompt(spinner+progress+" ", min(maxPromptWidth, width/2))
+		}
+		return spinner
 	}
 	if t.promptIdle == "" {
-		return "> "
+		return sshprompt.DefaultTemplate
 	}
-	return t.promptIdle
+	return truncatePrompt(t.promptIdle, min(maxPromptWidth, width/2))
+}
+
+// activeProgress returns the progress hint of the active tab, such as
+// "running: go test ./...".
+func (t *terminalSession) activeProgress() string {
+	for _, tab := range t.tabs {
+		if tab.ID == t.activeTab {
+			return tab.Progress
+		}
+	}
+	return ""
+}
+
+// errorBanner returns the banner line for the runner failure of the active
+// tab, or nil when it has none.
+func (t *terminalSession) errorBanner(width int, theme tuiTheme) []string {
+	for _, tab := range t.tabs {
+		if tab.ID != t.activeTab || tab.LastError == nil {
+			continue
+		}
+		text := tab.LastError.Message
+		if !tab.LastError.Time.IsZero() {
+			text += " (" + t.clock.Format(tab.LastError.Time) + ")"
+		}
+		return []string{renderErrorBanner(text, width, theme)}
+	}
+	return nil
+}
+
+// renderIdlePrompt evaluates the prompt template for the active tab.
+func (t *terminalSession) renderIdlePrompt() string {
+	data := sshprompt.Data{User: t.userID}
+	for _, tab := range t.tabs {
+		if tab.ID != t.activeTab {
+			continue
+		}
+		data.Repo = tab.Repo.Name
+		data.Branch = tab.Branch
+		data.Model = tab.Model
+		data.Tab = tab.Name
+		break
+	}
+	return t.prompt.Render(data)
+}
+
+// maxPromptWidth caps the idle prompt so the editor keeps most of the
+// input line; narrow terminals give it at most half the width.
+const maxPromptWidth = 40
+
+// truncatePrompt shortens prompt to width runes, keeping a trailing space
+// so input does not run into the ellipsis.
+func truncatePrompt(prompt string, width int) string {
+	runes := []rune(prompt)
+	if len(runes) <= width {
+		return prompt
+	}
+	if width < 3 {
+		return sshprompt.DefaultTemplate
+	}
+	return string(runes[:width-2]) + "… "
 }
 
 func isStatusCommand(line string) bool {
@@ -1351,6 +1764,20 @@ func isNewCommand(line string) bool {
 	return next == ' ' || next == '\t'
 }
 
+// isGitCommand reports /git, which runs in the background so /stop can
+// interrupt it.
+func isGitCommand(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "/git") {
+		return false
+	}
+	if len(trimmed) == len("/git") {
+		return true
+	}
+	next := trimmed[len("/git")]
+	return next == ' ' || next == '\t'
+}
+
 func isChpasswdCommand(line string) bool {
 	trimmed := strings.TrimSpace(line)
 	if !strings.HasPrefix(trimmed, "/chpasswd") {
@@ -1380,12 +1807,20 @@ func isRotateSSHKeyCommand(line string) bool {
 	return trimmed == "/rotatesshkey"
 }
 
+// stoppingLabel follows the spinner while the active tab's run is being
+// stopped.
+const stoppingLabel = "stopping…"
+
 func stylePromptPrefix(prefix string, theme tuiTheme) string {
 	if strings.HasPrefix(prefix, ">") {
 		return ansiBold + ansiFgRGB(theme.PromptFG) + ">" + ansiReset + strings.TrimPrefix(prefix, ">")
 	}
 	if spinner := spinnerPrefix(prefix); spinner != "" {
-		return ansiFgRGB(theme.SpinnerFG) + spinner + ansiReset + strings.TrimPrefix(prefix, spinner)
+		rest := strings.TrimPrefix(prefix, spinner)
+		if tail, ok := strings.CutPrefix(rest, " "+stoppingLabel); ok {
+			return ansiFgRGB(theme.ErrorFG) + spinner + " " + stoppingLabel + ansiReset + tail
+		}
+		return ansiFgRGB(theme.SpinnerFG) + spinner + ansiReset + rest
 	}
 	return prefix
 }
@@ -1530,15 +1965,9 @@ func truncateName(name string, max int) string {
 }
 
 func tabsEqual(a, b []schema.TabSnapshot) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
+	return slices.EqualFunc(a, b, func(x, y schema.TabSnapshot) bool {
+		return reflect.DeepEqual(x, y)
+	})
 }
 
 func tabStatusEqual(a, b map[schema.TabID]schema.TabStatus) bool {
@@ -1557,7 +1986,7 @@ func bufferEqual(a, b schema.BufferSnapshot) bool {
 	if a.TabID != b.TabID || a.TotalLines != b.TotalLines || a.ScrollOffset != b.ScrollOffset || a.AtBottom != b.AtBottom {
 		return false
 	}
-	if len(a.Lines) != len(b.Lines) {
+	if len(a.Lines) != len(b.Lines) || len(a.Structured) != len(b.Structured) {
 		return false
 	}
 	for i := range a.Lines {
@@ -1572,7 +2001,7 @@ func systemBufferEqual(a, b schema.SystemBufferSnapshot) bool {
 	if a.TotalLines != b.TotalLines || a.ScrollOffset != b.ScrollOffset || a.AtBottom != b.AtBottom {
 		return false
 	}
-	if len(a.Lines) != len(b.Lines) {
+	if len(a.Lines) != len(b.Lines) || len(a.Structured) != len(b.Structured) {
 		return false
 	}
 	for i := range a.Lines {
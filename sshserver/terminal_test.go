@@ -3,10 +3,13 @@ package sshserver
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"pkt.systems/centaurx/internal/sshprompt"
 	"pkt.systems/centaurx/schema"
 )
 
@@ -54,11 +57,77 @@ func TestTerminalRefreshStateNoChangeKeepsClean(t *testing.T) {
 	}
 }
 
+func TestTerminalPromptTemplate(t *testing.T) {
+	tmpl, err := sshprompt.Parse("{{.User}}@{{.Repo}}{{with .Branch}}:{{.}}{{end}}> ")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tabs := []schema.TabSnapshot{{ID: "tab1", Name: "demo", Repo: schema.RepoRef{Name: "demo"}, Status: schema.TabStatusIdle, Branch: "main"}}
+	svc := &stubService{
+		listTabsFn: func(context.Context, schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{Tabs: tabs, ActiveTab: "tab1"}, nil
+		},
+		getBufferFn: func(context.Context, schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+			return schema.GetBufferResponse{Buffer: schema.BufferSnapshot{TabID: "tab1", AtBottom: true}}, nil
+		},
+	}
+	session := newTerminalSession(nil, svc, nil, nil, "alice", tmpl, colorDepth256, nil)
+	session.ctx = context.Background()
+	if got := session.promptPrefix(); got != "alice@> " {
+		t.Fatalf("expected prompt without a tab, got %q", got)
+	}
+	session.SetSize(80, 24)
+	session.refreshState()
+	if got := session.promptPrefix(); got != "alice@demo:main> " {
+		t.Fatalf("unexpected prompt %q", got)
+	}
+
+	tabs[0].Branch = "feature/" + strings.Repeat("x", 60)
+	session.dirty = false
+	session.refreshState()
+	if !session.dirty {
+		t.Fatal("expected a prompt change to redraw")
+	}
+	got := session.promptPrefix()
+	if len([]rune(got)) != maxPromptWidth || !strings.HasSuffix(got, "… ") {
+		t.Fatalf("expected the prompt to be truncated to %d runes, got %q", maxPromptWidth, got)
+	}
+	session.SetSize(20, 24)
+	if got := session.promptPrefix(); len([]rune(got)) != 10 {
+		t.Fatalf("expected half the width on narrow terminals, got %q", got)
+	}
+
+	session.running = true
+	if got := session.promptPrefix(); got != fmt.Sprintf("%c ", spinnerFrames[0]) {
+		t.Fatalf("expected the spinner while running, got %q", got)
+	}
+
+	session.SetSize(80, 24)
+	tabs[0].Status = schema.TabStatusRunning
+	tabs[0].Progress = "running: go test ./..."
+	session.dirty = false
+	session.refreshState()
+	if !session.dirty {
+		t.Fatal("expected a progress change to redraw")
+	}
+	if got, want := session.promptPrefix(), fmt.Sprintf("%c running: go test ./... ", spinnerFrames[0]); got != want {
+		t.Fatalf("expected the progress next to the spinner, got %q want %q", got, want)
+	}
+	tabs[0].Progress = "running: " + strings.Repeat("x", 80)
+	session.refreshState()
+	if got := session.promptPrefix(); len([]rune(got)) != maxPromptWidth || !strings.HasSuffix(got, "… ") {
+		t.Fatalf("expected the progress to be truncated, got %q", got)
+	}
+	if got := stylePromptPrefix(session.promptPrefix(), themeForName("outrun")); !strings.Contains(got, "running: ") {
+		t.Fatalf("expected the styled prefix to keep the hint, got %q", got)
+	}
+}
+
 func TestRenderViewportAtBottomKeepsTail(t *testing.T) {
 	theme := themeForName("outrun")
 	longLine := schema.AgentMarker + strings.Repeat("a", 25)
 	viewLines := []string{longLine, "LAST"}
-	got := renderViewport(viewLines, 10, 3, theme, true)
+	got := renderViewport(viewport{lines: viewLines, width: 10, height: 3, theme: theme, atBottom: true}, nil)
 	if len(got) != 3 {
 		t.Fatalf("expected 3 lines, got %d", len(got))
 	}
@@ -83,6 +152,51 @@ func TestStylePromptPrefixSpinnerColored(t *testing.T) {
 	}
 }
 
+func TestTerminalStoppingTab(t *testing.T) {
+	tabs := []schema.TabSnapshot{{ID: "tab1", Name: "demo", Status: schema.TabStatusStopping, Progress: "running: go test ./..."}}
+	var sent []string
+	svc := &stubService{
+		listTabsFn: func(context.Context, schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{Tabs: tabs, ActiveTab: "tab1"}, nil
+		},
+		getBufferFn: func(context.Context, schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+			return schema.GetBufferResponse{Buffer: schema.BufferSnapshot{TabID: "tab1", AtBottom: true}}, nil
+		},
+		sendPromptFn: func(_ context.Context, req schema.SendPromptRequest) (schema.SendPromptResponse, error) {
+			sent = append(sent, req.Prompt)
+			return schema.SendPromptResponse{Accepted: true}, nil
+		},
+	}
+	session := newTerminalSession(nil, svc, nil, nil, "alice", nil, colorDepth256, nil)
+	session.ctx = context.Background()
+	session.SetSize(80, 24)
+	session.refreshState()
+	if !session.running || !session.stopping {
+		t.Fatalf("expected a stopping tab to spin as stopping, running=%v stopping=%v", session.running, session.stopping)
+	}
+	if got, want := session.promptPrefix(), fmt.Sprintf("%c %s ", spinnerFrames[0], stoppingLabel); got != want {
+		t.Fatalf("expected the stopping label instead of the progress, got %q want %q", got, want)
+	}
+	theme := themeForName("outrun")
+	if styled := stylePromptPrefix(session.promptPrefix(), theme); !strings.Contains(styled, ansiFgRGB(theme.ErrorFG)) {
+		t.Fatalf("expected the stopping spinner in the error color, got %q", styled)
+	}
+
+	session.submitPrompt("fix the tests")
+	if len(sent) != 0 || len(session.queues["tab1"]) != 0 {
+		t.Fatalf("expected no prompt sent or queued while stopping, sent=%v queue=%v", sent, session.queues["tab1"])
+	}
+	if !strings.Contains(session.notice, "stopping") {
+		t.Fatalf("expected a stopping notice, got %q", session.notice)
+	}
+
+	tabs[0].Status = schema.TabStatusIdle
+	session.refreshState()
+	if session.running || session.stopping {
+		t.Fatalf("expected an idle tab to stop spinning, running=%v stopping=%v", session.running, session.stopping)
+	}
+}
+
 func TestTerminalHistoryNavigationPreservesDraft(t *testing.T) {
 	history := []string{"one", "two"}
 	svc := &stubService{
@@ -178,6 +292,57 @@ func (h blockingHandler) Handle(ctx context.Context, userID schema.UserID, tabID
 	return true, nil
 }
 
+type recordingHandler struct {
+	inputs chan string
+}
+
+func (h recordingHandler) Handle(_ context.Context, _ schema.UserID, _ schema.TabID, input string) (bool, error) {
+	h.inputs <- input
+	return true, nil
+}
+
+func TestTerminalShellStdinCapture(t *testing.T) {
+	inputs := make(chan string, 1)
+	session := &terminalSession{
+		service:   &stubService{},
+		handler:   recordingHandler{inputs: inputs},
+		redrawCh:  make(chan struct{}, 1),
+		userID:    "alice",
+		activeTab: "tab1",
+		ctx:       context.Background(),
+	}
+	session.editor.SetString("! wc -l <<")
+	session.handleEnter()
+	if session.stdinCapture == nil {
+		t.Fatalf("expected stdin capture to start")
+	}
+	if prefix, _ := session.inputDisplay(); prefix != "stdin: " {
+		t.Fatalf("unexpected prompt %q", prefix)
+	}
+	for _, r := range "one" {
+		session.handleKey(key{kind: keyRune, r: r})
+	}
+	session.handleKey(key{kind: keyEnter})
+	session.handleKey(key{kind: keyEnter})
+	for _, r := range "three" {
+		session.handleKey(key{kind: keyRune, r: r})
+	}
+	session.handleKey(key{kind: keyEnter})
+	session.handleKey(key{kind: keyRune, r: '.'})
+	session.handleKey(key{kind: keyEnter})
+	if session.stdinCapture != nil {
+		t.Fatalf("expected a lone . to end the capture")
+	}
+	select {
+	case got := <-inputs:
+		if want := "! wc -l <<\none\n\nthree"; got != want {
+			t.Fatalf("handler input = %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("piped command was not run")
+	}
+}
+
 func TestCommandSpinnerStartsForNewCommand(t *testing.T) {
 	previous := commandSpinnerDelay
 	commandSpinnerDelay = 5 * time.Millisecond
@@ -261,6 +426,7 @@ type stubService struct {
 	appendOutputFn func(context.Context, schema.AppendOutputRequest) (schema.AppendOutputResponse, error)
 	getHistoryFn   func(context.Context, schema.GetHistoryRequest) (schema.GetHistoryResponse, error)
 	appendHistFn   func(context.Context, schema.AppendHistoryRequest) (schema.AppendHistoryResponse, error)
+	lastPromptFn   func(context.Context, schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error)
 	createTabFn    func(context.Context, schema.CreateTabRequest) (schema.CreateTabResponse, error)
 	closeTabFn     func(context.Context, schema.CloseTabRequest) (schema.CloseTabResponse, error)
 	activateTabFn  func(context.Context, schema.ActivateTabRequest) (schema.ActivateTabResponse, error)
@@ -347,6 +513,10 @@ func (s *stubService) SetTheme(ctx context.Context, req schema.SetThemeRequest)
 	return schema.SetThemeResponse{}, errors.New("unexpected SetTheme")
 }
 
+func (s *stubService) SetTimezone(context.Context, schema.SetTimezoneRequest) (schema.SetTimezoneResponse, error) {
+	return schema.SetTimezoneResponse{}, errors.New("unexpected SetTimezone")
+}
+
 func (s *stubService) SwitchRepo(ctx context.Context, req schema.SwitchRepoRequest) (schema.SwitchRepoResponse, error) {
 	if s.switchRepoFn != nil {
 		return s.switchRepoFn(ctx, req)
@@ -389,6 +559,10 @@ func (s *stubService) ScrollBuffer(ctx context.Context, req schema.ScrollBufferR
 	return schema.ScrollBufferResponse{}, errors.New("unexpected ScrollBuffer")
 }
 
+func (s *stubService) SearchAll(context.Context, schema.SearchAllRequest) (schema.SearchAllResponse, error) {
+	return schema.SearchAllResponse{}, errors.New("unexpected SearchAll")
+}
+
 func (s *stubService) AppendOutput(ctx context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error) {
 	if s.appendOutputFn != nil {
 		return s.appendOutputFn(ctx, req)
@@ -424,6 +598,13 @@ func (s *stubService) AppendHistory(ctx context.Context, req schema.AppendHistor
 	return schema.AppendHistoryResponse{}, errors.New("unexpected AppendHistory")
 }
 
+func (s *stubService) GetLastPrompt(ctx context.Context, req schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error) {
+	if s.lastPromptFn != nil {
+		return s.lastPromptFn(ctx, req)
+	}
+	return schema.GetLastPromptResponse{}, errors.New("unexpected GetLastPrompt")
+}
+
 func (s *stubService) SaveCodexAuth(ctx context.Context, req schema.SaveCodexAuthRequest) (schema.SaveCodexAuthResponse, error) {
 	if s.saveCodexFn != nil {
 		return s.saveCodexFn(ctx, req)
@@ -431,9 +612,400 @@ func (s *stubService) SaveCodexAuth(ctx context.Context, req schema.SaveCodexAut
 	return schema.SaveCodexAuthResponse{}, errors.New("unexpected SaveCodexAuth")
 }
 
+func (s *stubService) UpdateOnboarding(context.Context, schema.UpdateOnboardingRequest) (schema.UpdateOnboardingResponse, error) {
+	return schema.UpdateOnboardingResponse{}, errors.New("unexpected UpdateOnboarding")
+}
+
 func (s *stubService) GetTabUsage(ctx context.Context, req schema.GetTabUsageRequest) (schema.GetTabUsageResponse, error) {
 	if s.getTabUsageFn != nil {
 		return s.getTabUsageFn(ctx, req)
 	}
 	return schema.GetTabUsageResponse{}, errors.New("unexpected GetTabUsage")
 }
+
+func (s *stubService) GetTabStatus(context.Context, schema.GetTabStatusRequest) (schema.GetTabStatusResponse, error) {
+	return schema.GetTabStatusResponse{}, errors.New("unexpected GetTabStatus")
+}
+
+func (s *stubService) ListOutputFilters(context.Context, schema.ListOutputFiltersRequest) (schema.ListOutputFiltersResponse, error) {
+	return schema.ListOutputFiltersResponse{}, errors.New("unexpected ListOutputFilters")
+}
+
+func (s *stubService) AddOutputFilter(context.Context, schema.AddOutputFilterRequest) (schema.AddOutputFilterResponse, error) {
+	return schema.AddOutputFilterResponse{}, errors.New("unexpected AddOutputFilter")
+}
+
+func (s *stubService) RemoveOutputFilter(context.Context, schema.RemoveOutputFilterRequest) (schema.RemoveOutputFilterResponse, error) {
+	return schema.RemoveOutputFilterResponse{}, errors.New("unexpected RemoveOutputFilter")
+}
+
+func (s *stubService) ListRepoFiles(context.Context, schema.ListRepoFilesRequest) (schema.ListRepoFilesResponse, error) {
+	return schema.ListRepoFilesResponse{}, errors.New("unexpected ListRepoFiles")
+}
+
+func (s *stubService) ReadRepoFile(context.Context, schema.ReadRepoFileRequest) (schema.ReadRepoFileResponse, error) {
+	return schema.ReadRepoFileResponse{}, errors.New("unexpected ReadRepoFile")
+}
+
+func (s *stubService) WriteRepoArchive(context.Context, schema.WriteRepoArchiveRequest) (schema.WriteRepoArchiveResponse, error) {
+	return schema.WriteRepoArchiveResponse{}, errors.New("unexpected WriteRepoArchive")
+}
+
+func (s *stubService) ShareTab(context.Context, schema.ShareTabRequest) (schema.ShareTabResponse, error) {
+	return schema.ShareTabResponse{}, errors.New("unexpected ShareTab")
+}
+
+func (s *stubService) UnshareTab(context.Context, schema.UnshareTabRequest) (schema.UnshareTabResponse, error) {
+	return schema.UnshareTabResponse{}, errors.New("unexpected UnshareTab")
+}
+
+func (s *stubService) CreateShareLink(context.Context, schema.CreateShareLinkRequest) (schema.CreateShareLinkResponse, error) {
+	return schema.CreateShareLinkResponse{}, errors.New("unexpected CreateShareLink")
+}
+
+func (s *stubService) RevokeShareLinks(context.Context, schema.RevokeShareLinksRequest) (schema.RevokeShareLinksResponse, error) {
+	return schema.RevokeShareLinksResponse{}, errors.New("unexpected RevokeShareLinks")
+}
+
+func (s *stubService) ViewShareLink(context.Context, schema.ViewShareLinkRequest) (schema.ViewShareLinkResponse, error) {
+	return schema.ViewShareLinkResponse{}, errors.New("unexpected ViewShareLink")
+}
+
+func (s *stubService) RecordActivity(context.Context, schema.RecordActivityRequest) (schema.RecordActivityResponse, error) {
+	return schema.RecordActivityResponse{}, nil
+}
+
+func (s *stubService) ListActivity(context.Context, schema.ListActivityRequest) (schema.ListActivityResponse, error) {
+	return schema.ListActivityResponse{}, errors.New("unexpected ListActivity")
+}
+
+func (s *stubService) ReopenTab(context.Context, schema.ReopenTabRequest) (schema.ReopenTabResponse, error) {
+	return schema.ReopenTabResponse{}, errors.New("unexpected ReopenTab")
+}
+
+func (s *stubService) ListClosedTabs(context.Context, schema.ListClosedTabsRequest) (schema.ListClosedTabsResponse, error) {
+	return schema.ListClosedTabsResponse{}, errors.New("unexpected ListClosedTabs")
+}
+
+func (s *stubService) ListAliases(context.Context, schema.ListAliasesRequest) (schema.ListAliasesResponse, error) {
+	return schema.ListAliasesResponse{}, nil
+}
+
+func (s *stubService) SetAlias(context.Context, schema.SetAliasRequest) (schema.SetAliasResponse, error) {
+	return schema.SetAliasResponse{}, errors.New("unexpected SetAlias")
+}
+
+func (s *stubService) RemoveAlias(context.Context, schema.RemoveAliasRequest) (schema.RemoveAliasResponse, error) {
+	return schema.RemoveAliasResponse{}, errors.New("unexpected RemoveAlias")
+}
+
+func (s *stubService) SetTabSummaries(context.Context, schema.SetTabSummariesRequest) (schema.SetTabSummariesResponse, error) {
+	return schema.SetTabSummariesResponse{}, errors.New("unexpected SetTabSummaries")
+}
+
+func (s *stubService) ListTabSummaries(context.Context, schema.ListTabSummariesRequest) (schema.ListTabSummariesResponse, error) {
+	return schema.ListTabSummariesResponse{}, errors.New("unexpected ListTabSummaries")
+}
+
+func (s *stubService) SetTabTool(context.Context, schema.SetTabToolRequest) (schema.SetTabToolResponse, error) {
+	return schema.SetTabToolResponse{}, errors.New("unexpected SetTabTool")
+}
+
+func (s *stubService) SetTabAutoCommit(context.Context, schema.SetTabAutoCommitRequest) (schema.SetTabAutoCommitResponse, error) {
+	return schema.SetTabAutoCommitResponse{}, errors.New("unexpected SetTabAutoCommit")
+}
+
+func (s *stubService) SetTabArchived(context.Context, schema.SetTabArchivedRequest) (schema.SetTabArchivedResponse, error) {
+	return schema.SetTabArchivedResponse{}, errors.New("unexpected SetTabArchived")
+}
+
+func (s *stubService) SetTabLabel(context.Context, schema.SetTabLabelRequest) (schema.SetTabLabelResponse, error) {
+	return schema.SetTabLabelResponse{}, errors.New("unexpected SetTabLabel")
+}
+
+func (s *stubService) UpdateBatchRepos(context.Context, schema.UpdateBatchReposRequest) (schema.UpdateBatchReposResponse, error) {
+	return schema.UpdateBatchReposResponse{}, errors.New("unexpected UpdateBatchRepos")
+}
+
+func (s *stubService) GetBatch(context.Context, schema.GetBatchRequest) (schema.GetBatchResponse, error) {
+	return schema.GetBatchResponse{}, errors.New("unexpected GetBatch")
+}
+
+func (s *stubService) StartBatch(context.Context, schema.StartBatchRequest) (schema.StartBatchResponse, error) {
+	return schema.StartBatchResponse{}, errors.New("unexpected StartBatch")
+}
+
+func (s *stubService) StopBatch(context.Context, schema.StopBatchRequest) (schema.StopBatchResponse, error) {
+	return schema.StopBatchResponse{}, errors.New("unexpected StopBatch")
+}
+
+func TestDetectColorDepth(t *testing.T) {
+	cases := []struct {
+		term    string
+		environ []string
+		want    colorDepth
+	}{
+		{term: "xterm-256color", want: colorDepth256},
+		{term: "xterm-256color", environ: []string{"LANG=C", "COLORTERM=truecolor"}, want: colorDepthTruecolor},
+		{term: "screen-256color", environ: []string{"COLORTERM=24bit"}, want: colorDepthTruecolor},
+		{term: "xterm-direct", want: colorDepthTruecolor},
+		{term: "vt100", want: colorDepthMono},
+		{term: "", want: colorDepthMono},
+	}
+	for _, tc := range cases {
+		if got := detectColorDepth(tc.term, tc.environ); got != tc.want {
+			t.Fatalf("detectColorDepth(%q, %v) = %s, want %s", tc.term, tc.environ, got, tc.want)
+		}
+	}
+}
+
+func TestThemeNeedsTruecolor(t *testing.T) {
+	if !themeForName("outrun").needsTruecolor() {
+		t.Fatalf("expected outrun to need truecolor")
+	}
+	if themeForName("tokyo-midnight").needsTruecolor() {
+		t.Fatalf("expected tokyo-midnight to survive the 256-color palette")
+	}
+}
+
+func TestTerminalThemeListMarksTruecolorThemes(t *testing.T) {
+	var lines []string
+	svc := &stubService{
+		appendOutputFn: func(_ context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error) {
+			lines = append(lines, req.Lines...)
+			return schema.AppendOutputResponse{}, nil
+		},
+	}
+	session := &terminalSession{
+		service:    svc,
+		userID:     "alice",
+		activeTab:  "tab1",
+		themeName:  "gruvbox",
+		colorDepth: colorDepth256,
+		tabStatus:  make(map[schema.TabID]schema.TabStatus),
+		queues:     make(map[schema.TabID][]string),
+	}
+	session.ctx = context.Background()
+	session.editor.SetString("/theme")
+	session.handleEnter()
+	got := strings.Join(lines, "\n")
+	if !strings.Contains(got, "theme: gruvbox") || !strings.Contains(got, "outrun*") || !strings.Contains(got, "gruvbox*") {
+		t.Fatalf("expected truecolor themes to be marked, got %q", got)
+	}
+	if strings.Contains(got, "tokyo-midnight*") || !strings.Contains(got, "detected as 256-color") {
+		t.Fatalf("unexpected theme list %q", got)
+	}
+}
+
+func TestTerminalThemePreviewRestoresOnKeypress(t *testing.T) {
+	session := &terminalSession{
+		service:   &stubService{},
+		userID:    "alice",
+		activeTab: "tab1",
+		themeName: "outrun",
+		buffer:    schema.BufferSnapshot{TabID: "tab1", Lines: []string{"hello"}, AtBottom: true},
+		tabStatus: make(map[schema.TabID]schema.TabStatus),
+		queues:    make(map[schema.TabID][]string),
+	}
+	session.ctx = context.Background()
+	session.editor.SetString("/theme preview tokyo-midnight")
+	session.handleEnter()
+	if session.preview == nil || session.preview.Name != "tokyo-midnight" {
+		t.Fatalf("expected tokyo-midnight preview, got %+v", session.preview)
+	}
+	rows := renderThemePreview(*session.preview, 80, 20)
+	joined := strings.Join(rows, "\n")
+	for _, want := range []string{"theme preview: tokyo-midnight", ansiBgRGB(session.preview.TabActiveBG), ansiFgRGB(session.preview.StderrFG), "70%"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected preview to contain %q, got %q", want, joined)
+		}
+	}
+	if session.themeName != "outrun" {
+		t.Fatalf("preview must not change the theme, got %q", session.themeName)
+	}
+
+	session.handleKey(key{kind: keyRune, r: 'x'})
+	if session.preview != nil {
+		t.Fatalf("expected keypress to end the preview")
+	}
+	if session.editor.String() != "" {
+		t.Fatalf("expected dismissing key to be consumed, got %q", session.editor.String())
+	}
+}
+
+func TestTerminalRedoResendsLastPrompt(t *testing.T) {
+	var history, sent, output []string
+	svc := &stubService{
+		lastPromptFn: func(_ context.Context, req schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error) {
+			if req.TabID != "tab1" {
+				t.Fatalf("unexpected tab %q", req.TabID)
+			}
+			return schema.GetLastPromptResponse{Prompt: "fix the tests"}, nil
+		},
+		appendHistFn: func(_ context.Context, req schema.AppendHistoryRequest) (schema.AppendHistoryResponse, error) {
+			history = append(history, req.Entry)
+			return schema.AppendHistoryResponse{Entries: history}, nil
+		},
+		sendPromptFn: func(_ context.Context, req schema.SendPromptRequest) (schema.SendPromptResponse, error) {
+			sent = append(sent, req.Prompt)
+			return schema.SendPromptResponse{Accepted: true}, nil
+		},
+		appendOutputFn: func(_ context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error) {
+			output = append(output, req.Lines...)
+			return schema.AppendOutputResponse{}, nil
+		},
+	}
+	session := &terminalSession{
+		service:   svc,
+		redrawCh:  make(chan struct{}, 1),
+		userID:    "alice",
+		activeTab: "tab1",
+		ctx:       context.Background(),
+		tabStatus: make(map[schema.TabID]schema.TabStatus),
+		queues:    make(map[schema.TabID][]string),
+	}
+	session.editor.SetString("/redo")
+	session.handleEnter()
+	if !slices.Equal(sent, []string{"fix the tests"}) {
+		t.Fatalf("expected last prompt to be sent, got %v", sent)
+	}
+	if !slices.Equal(history, []string{"/redo", "fix the tests"}) {
+		t.Fatalf("expected command and prompt in history, got %v", history)
+	}
+
+	session.tabStatus["tab1"] = schema.TabStatusRunning
+	session.editor.SetString("/redo")
+	session.handleEnter()
+	if len(sent) != 1 || !slices.Equal(session.queues["tab1"], []string{"fix the tests"}) {
+		t.Fatalf("expected redo to queue on a busy tab, sent=%v queue=%v", sent, session.queues["tab1"])
+	}
+
+	svc.lastPromptFn = func(context.Context, schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error) {
+		return schema.GetLastPromptResponse{}, schema.ErrNoPrompt
+	}
+	session.tabStatus["tab1"] = schema.TabStatusIdle
+	session.editor.SetString("/redo")
+	session.handleEnter()
+	if len(sent) != 1 {
+		t.Fatalf("expected nothing sent without a previous prompt, got %v", sent)
+	}
+	if !strings.Contains(strings.Join(output, "\n"), schema.ErrNoPrompt.Error()) {
+		t.Fatalf("expected no-prompt error, got %v", output)
+	}
+}
+
+func TestTerminalEditLastLoadsEditor(t *testing.T) {
+	session := &terminalSession{
+		service: &stubService{
+			lastPromptFn: func(context.Context, schema.GetLastPromptRequest) (schema.GetLastPromptResponse, error) {
+				return schema.GetLastPromptResponse{Prompt: "fix the tests"}, nil
+			},
+			appendHistFn: func(context.Context, schema.AppendHistoryRequest) (schema.AppendHistoryResponse, error) {
+				return schema.AppendHistoryResponse{}, nil
+			},
+		},
+		redrawCh:  make(chan struct{}, 1),
+		userID:    "alice",
+		activeTab: "tab1",
+		ctx:       context.Background(),
+		tabStatus: make(map[schema.TabID]schema.TabStatus),
+		queues:    make(map[schema.TabID][]string),
+	}
+	session.editor.SetString("/edit-last")
+	session.handleEnter()
+	if got := session.editor.String(); got != "fix the tests" {
+		t.Fatalf("expected last prompt in editor, got %q", got)
+	}
+}
+
+func TestTerminalAltDigitJumpsToTab(t *testing.T) {
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Name: "alpha", Status: schema.TabStatusIdle},
+		{ID: "tab2", Name: "beta", Status: schema.TabStatusIdle},
+		{ID: "tab3", Name: "gamma", Status: schema.TabStatusIdle},
+	}
+	active := schema.TabID("tab1")
+	var notices []string
+	svc := &stubService{
+		listTabsFn: func(context.Context, schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{Tabs: tabs, ActiveTab: active}, nil
+		},
+		getBufferFn: func(_ context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+			return schema.GetBufferResponse{Buffer: schema.BufferSnapshot{TabID: req.TabID, AtBottom: true}}, nil
+		},
+		activateTabFn: func(_ context.Context, req schema.ActivateTabRequest) (schema.ActivateTabResponse, error) {
+			active = req.TabID
+			return schema.ActivateTabResponse{}, nil
+		},
+		appendOutputFn: func(_ context.Context, req schema.AppendOutputRequest) (schema.AppendOutputResponse, error) {
+			notices = append(notices, req.Lines...)
+			return schema.AppendOutputResponse{}, nil
+		},
+	}
+	tmpl, err := sshprompt.Parse("")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	session := newTerminalSession(nil, svc, nil, nil, "alice", tmpl, colorDepth256, nil)
+	session.ctx = context.Background()
+	session.SetSize(80, 24)
+	session.refreshState()
+	// The filter hides tab 3, but the numbers follow /rm and /tabs.
+	session.tabFilter = "none"
+
+	session.handleKey(key{kind: keyAltDigit, r: '3'})
+	if active != "tab3" || session.activeTab != "tab3" {
+		t.Fatalf("expected Alt+3 to activate the third tab, got %q", active)
+	}
+	session.handleKey(key{kind: keyAltDigit, r: '0'})
+	if active != "tab3" || len(notices) != 1 || notices[0] != "no tab 10 (3 open)" {
+		t.Fatalf("expected a notice for a missing tab, got %q (active %q)", notices, active)
+	}
+
+	session.showTabNumbers = true
+	if line, _ := renderTabBar(session.visibleTabs(), session.activeTab, session.tabNumbers(), 80, themeForName(""), 0); !strings.Contains(line, " 3:gamma ") {
+		t.Fatalf("expected the tab bar to show tab numbers, got %q", line)
+	}
+}
+
+func TestTerminalErrorBannerFollowsLastError(t *testing.T) {
+	failedAt := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	tabs := []schema.TabSnapshot{
+		{ID: "tab1", Name: "alpha", LastError: &schema.TabError{Kind: schema.CodeRunnerUnavailable, Message: "runner unavailable", Time: failedAt}},
+		{ID: "tab2", Name: "beta"},
+	}
+	active := schema.TabID("tab1")
+	svc := &stubService{
+		listTabsFn: func(context.Context, schema.ListTabsRequest) (schema.ListTabsResponse, error) {
+			return schema.ListTabsResponse{Tabs: tabs, ActiveTab: active}, nil
+		},
+		getBufferFn: func(_ context.Context, req schema.GetBufferRequest) (schema.GetBufferResponse, error) {
+			return schema.GetBufferResponse{Buffer: schema.BufferSnapshot{TabID: req.TabID, AtBottom: true}}, nil
+		},
+	}
+	tmpl, err := sshprompt.Parse("")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	session := newTerminalSession(nil, svc, nil, nil, "alice", tmpl, colorDepth256, nil)
+	session.ctx = context.Background()
+	session.SetSize(80, 24)
+	session.refreshState()
+	theme := themeForName(session.themeName)
+
+	banner := session.errorBanner(80, theme)
+	if len(banner) != 1 || !strings.Contains(banner[0], "runner unavailable ("+session.clock.Format(failedAt)+")") {
+		t.Fatalf("expected a banner for the active tab, got %q", banner)
+	}
+	active = "tab2"
+	session.refreshState()
+	if banner := session.errorBanner(80, theme); banner != nil {
+		t.Fatalf("expected no banner on a tab without errors, got %q", banner)
+	}
+	active = "tab1"
+	tabs[0].LastError = nil
+	session.refreshState()
+	if banner := session.errorBanner(80, theme); banner != nil {
+		t.Fatalf("expected a cleared error to remove the banner, got %q", banner)
+	}
+}
@@ -1,7 +1,9 @@
 package sshserver
 
 import (
+	"math"
 	"strconv"
+	"strings"
 
 	"pkt.systems/centaurx/schema"
 )
@@ -30,6 +32,11 @@ type tuiTheme struct {
 	AboutLinkFG      rgb
 	AboutCopyrightFG rgb
 	HelpArgFG        rgb
+	// BannerBG is the background of the error banner above the input.
+	BannerBG rgb
+	// FinalAgentFG colors the gutter beside the final agent message of a
+	// turn.
+	FinalAgentFG rgb
 }
 
 const (
@@ -58,6 +65,8 @@ var tuiThemes = map[schema.ThemeName]tuiTheme{
 		AboutLinkFG:      rgb{r: 112, g: 214, b: 255},
 		AboutCopyrightFG: rgb{r: 60, g: 79, b: 184},
 		HelpArgFG:        rgb{r: 154, g: 182, b: 255},
+		BannerBG:         rgb{r: 92, g: 16, b: 48},
+		FinalAgentFG:     rgb{r: 0, g: 229, b: 255},
 	},
 	"gruvbox": {
 		Name:             "gruvbox",
@@ -77,6 +86,8 @@ var tuiThemes = map[schema.ThemeName]tuiTheme{
 		AboutLinkFG:      rgb{r: 250, g: 189, b: 47},
 		AboutCopyrightFG: rgb{r: 75, g: 110, b: 166},
 		HelpArgFG:        rgb{r: 131, g: 165, b: 152},
+		BannerBG:         rgb{r: 135, g: 0, b: 0},
+		FinalAgentFG:     rgb{r: 250, g: 189, b: 47},
 	},
 	"tokyo-midnight": {
 		Name:             "tokyo-midnight",
@@ -96,6 +107,8 @@ var tuiThemes = map[schema.ThemeName]tuiTheme{
 		AboutLinkFG:      rgb{r: 122, g: 162, b: 247},
 		AboutCopyrightFG: rgb{r: 59, g: 79, b: 159},
 		HelpArgFG:        rgb{r: 125, g: 207, b: 255},
+		BannerBG:         rgb{r: 95, g: 0, b: 95},
+		FinalAgentFG:     rgb{r: 122, g: 162, b: 247},
 	},
 }
 
@@ -106,6 +119,11 @@ func themeForName(name schema.ThemeName) tuiTheme {
 	if theme, ok := tuiThemes[name]; ok {
 		return theme
 	}
+	if custom := customThemes.Load(); custom != nil {
+		if theme, ok := (*custom)[name]; ok {
+			return theme
+		}
+	}
 	return tuiThemes[schema.DefaultTheme]
 }
 
@@ -116,3 +134,111 @@ func ansiFgRGB(c rgb) string {
 func ansiBgRGB(c rgb) string {
 	return "\x1b[48;2;" + strconv.Itoa(c.r) + ";" + strconv.Itoa(c.g) + ";" + strconv.Itoa(c.b) + "m"
 }
+
+// colorDepth is the color support detected for a session's terminal.
+type colorDepth int
+
+const (
+	// colorDepthUnknown means no detection took place; themes are not
+	// annotated.
+	colorDepthUnknown colorDepth = iota
+	colorDepthMono
+	colorDepth256
+	colorDepthTruecolor
+)
+
+func (d colorDepth) String() string {
+	switch d {
+	case colorDepthMono:
+		return "mono"
+	case colorDepth256:
+		return "256-color"
+	case colorDepthTruecolor:
+		return "truecolor"
+	default:
+		return "unknown"
+	}
+}
+
+// limited reports whether the terminal is known to lack truecolor support.
+func (d colorDepth) limited() bool {
+	return d == colorDepthMono || d == colorDepth256
+}
+
+// detectColorDepth guesses the color support of a terminal from the TERM the
+// client requested with the pty and the environment it sent. COLORTERM is
+// only forwarded by clients configured to send it, so its absence alone does
+// not rule out truecolor when TERM says so.
+func detectColorDepth(term string, environ []string) colorDepth {
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name != "COLORTERM" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "truecolor", "24bit":
+			return colorDepthTruecolor
+		}
+	}
+	term = strings.ToLower(strings.TrimSpace(term))
+	switch {
+	case strings.Contains(term, "direct"), strings.Contains(term, "truecolor"), strings.Contains(term, "24bit"):
+		return colorDepthTruecolor
+	case strings.Contains(term, "256color"):
+		return colorDepth256
+	default:
+		return colorDepthMono
+	}
+}
+
+// truecolorDistance is how far a theme color may be from its nearest xterm
+// 256-color palette entry before the theme is considered to need truecolor.
+const truecolorDistance = 32
+
+// needsTruecolor reports whether the theme loses noticeably when a terminal
+// maps its colors to the 256-color palette.
+func (t tuiTheme) needsTruecolor() bool {
+	for _, c := range []rgb{
+		t.TabBarBG, t.TabActiveBG, t.TabActiveFG, t.TabInactiveBG, t.TabInactiveFG,
+		t.ErrorFG, t.StderrFG, t.MetaFG, t.PromptFG, t.SpinnerFG,
+		t.ReasoningFG, t.ReasoningBold, t.CodeFG, t.HelpArgFG, t.BannerBG, t.FinalAgentFG,
+	} {
+		if c.palette256Distance() > truecolorDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// palette256Distance returns the distance between c and the closest color of
+// the xterm 256-color cube or grayscale ramp.
+func (c rgb) palette256Distance() float64 {
+	cube := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(v int) int {
+		best := cube[0]
+		for _, level := range cube[1:] {
+			if abs(level-v) < abs(best-v) {
+				best = level
+			}
+		}
+		return best
+	}
+	best := c.distance(rgb{r: nearest(c.r), g: nearest(c.g), b: nearest(c.b)})
+	for i := range 24 {
+		level := 8 + 10*i
+		best = math.Min(best, c.distance(rgb{r: level, g: level, b: level}))
+	}
+	return best
+}
+
+func (c rgb) distance(o rgb) float64 {
+	dr, dg, db := float64(c.r-o.r), float64(c.g-o.g), float64(c.b-o.b)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
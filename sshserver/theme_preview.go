@@ -0,0 +1,118 @@
+package sshserver
+
+import (
+	"fmt"
+	"strings"
+
+	"pkt.systems/centaurx/schema"
+)
+
+// themePreviewLines is the sample output shown by /theme preview, one raw
+// buffer line per entry so it renders through the regular line styles.
+var themePreviewLines = []string{
+	schema.AgentMarker + "The failing test was a **stale fixture**; `parser_test.go` now builds it inline.",
+	schema.ReasoningMarker + "Checking whether **other tests** share the fixture.",
+	schema.CommandMarker + "$ go test ./parser/...",
+	"ok   example.com/demo/parser 0.412s",
+	schema.StderrMarker + "warning: -race is not supported on this platform",
+	"error: example.com/demo/lexer: build failed",
+	"--- command finished (exit 1) ---",
+	"5h limit:   ███████░░░ 70% / reset in 2h 14m",
+}
+
+// themePreviewCommand reports whether line is `/theme preview <name>` and
+// returns the requested name.
+func themePreviewCommand(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "/theme" || fields[1] != "preview" {
+		return "", false
+	}
+	if len(fields) != 3 {
+		return "", true
+	}
+	return fields[2], true
+}
+
+// isThemeListCommand reports whether line asks for the theme list.
+func isThemeListCommand(line string) bool {
+	return strings.TrimSpace(line) == "/theme"
+}
+
+// startThemePreview shows the sample block in the named theme until the next
+// keypress. The persisted theme is left unchanged.
+func (t *terminalSession) startThemePreview(arg string) {
+	if arg == "" {
+		t.appendError(t.activeTab, fmt.Errorf("usage: /theme preview <name>"))
+		return
+	}
+	name, ok := schema.NormalizeThemeName(arg)
+	if !ok {
+		t.appendError(t.activeTab, fmt.Errorf("unknown theme %q (available: %s)", arg, strings.Join(themeNames(), ", ")))
+		return
+	}
+	theme := themeForName(name)
+	t.preview = &theme
+	t.log().Debug("tui theme preview", "theme", name)
+}
+
+// listThemes writes the current and available themes like the /theme command
+// does, marking the ones that need truecolor when the terminal was detected
+// without it.
+func (t *terminalSession) listThemes() {
+	current := t.themeName
+	if current == "" {
+		current = schema.DefaultTheme
+	}
+	names := themeNames()
+	marked := false
+	for i, name := range names {
+		if t.colorDepth.limited() && themeForName(schema.ThemeName(name)).needsTruecolor() {
+			names[i] += "*"
+			marked = true
+		}
+	}
+	t.appendMessage(t.activeTab, "theme: "+string(current))
+	t.appendMessage(t.activeTab, "available themes: "+strings.Join(names, ", "))
+	if marked {
+		t.appendMessage(t.activeTab, fmt.Sprintf("* needs a truecolor terminal; this terminal was detected as %s", t.colorDepth))
+	}
+	t.log().Info("tui theme listed", "current", current, "color_depth", t.colorDepth.String())
+}
+
+func themeNames() []string {
+	available := schema.AvailableThemes()
+	names := make([]string, 0, len(available))
+	for _, name := range available {
+		names = append(names, string(name))
+	}
+	return names
+}
+
+// renderThemePreview renders the preview block for theme into height rows:
+// a sample tab bar, a prompt line and the sample output.
+func renderThemePreview(theme tuiTheme, width, height int) []string {
+	if height <= 0 {
+		return nil
+	}
+	tabs := []schema.TabSnapshot{
+		{ID: "preview-active", Name: "demo", Status: schema.TabStatusIdle},
+		{ID: "preview-other", Name: "notes", Status: schema.TabStatusIdle},
+	}
+	tabBar, _ := renderTabBar(tabs, "preview-active", nil, width, theme, 0)
+	header := fmt.Sprintf("theme preview: %s (press any key to return)", theme.Name)
+	rows := []string{
+		ansiDim + ansiItalic + ansiFgRGB(theme.MetaFG) + trimToWidth(header, width) + ansiReset,
+		tabBar,
+		stylePromptPrefix("> ", theme) + trimToWidth("fix the failing parser test", width-2),
+	}
+	for _, raw := range themePreviewLines {
+		rows = append(rows, renderLines(raw, width, theme)...)
+	}
+	if len(rows) > height {
+		rows = rows[:height]
+	}
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+	return rows
+}